@@ -0,0 +1,61 @@
+// The rules that the Reserve is proved to keep; see cmd/rsvprove.
+
+methods {
+    function totalSupply() external returns (uint256) envfree;
+    function maxSupply() external returns (uint256) envfree;
+    function paused() external returns (bool) envfree;
+    function balanceOf(address) external returns (uint256) envfree;
+}
+
+/// Minting adds the amount minted to the supply, and to the balance of the account minted to.
+rule mintAddsToSupply(address account, uint256 value) {
+    env e;
+    mathint supplyBefore = totalSupply();
+    mathint balanceBefore = balanceOf(account);
+
+    mint(e, account, value);
+
+    assert to_mathint(totalSupply()) == supplyBefore + value, "mint must add value to the supply";
+    assert to_mathint(balanceOf(account)) == balanceBefore + value,
+        "mint must add value to the balance";
+}
+
+/// Burning takes the amount burned from the supply.
+rule burnTakesFromSupply(address account, uint256 value) {
+    env e;
+    mathint supplyBefore = totalSupply();
+
+    burnFrom(e, account, value);
+
+    assert to_mathint(totalSupply()) == supplyBefore - value,
+        "burnFrom must take value from the supply";
+}
+
+/// Nothing mints while the Reserve is paused.
+rule pausedBlocksMint(address account, uint256 value) {
+    env e;
+    require paused();
+
+    mint@withrevert(e, account, value);
+
+    assert lastReverted, "mint must fail while paused";
+}
+
+/// Only minting and burning change the supply.
+rule onlyMintAndBurnChangeSupply(method f) filtered { f -> !f.isView } {
+    env e;
+    calldataarg args;
+    uint256 supplyBefore = totalSupply();
+
+    f(e, args);
+
+    assert totalSupply() != supplyBefore =>
+        f.selector == sig:mint(address, uint256).selector ||
+        f.selector == sig:burnFrom(address, uint256).selector ||
+        f.selector == sig:emergencyBurnFrom(address, uint256).selector,
+        "only mint and the burns may change the supply";
+}
+
+/// The supply is never over the max supply.
+invariant supplyWithinMax()
+    totalSupply() <= maxSupply();
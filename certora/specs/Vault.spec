@@ -0,0 +1,29 @@
+// The rules that the Vault is proved to keep; see cmd/rsvprove.
+
+methods {
+    function manager() external returns (address) envfree;
+    function owner() external returns (address) envfree;
+}
+
+/// Only the manager withdraws.
+rule onlyManagerWithdraws(address token, uint256 amount, address to) {
+    env e;
+    require e.msg.sender != manager();
+
+    withdrawTo@withrevert(e, token, amount, to);
+
+    assert lastReverted, "withdrawTo must fail unless the manager calls it";
+}
+
+/// Only the owner changes the manager, and never to address zero.
+rule onlyOwnerChangesManager(method f) filtered { f -> !f.isView } {
+    env e;
+    calldataarg args;
+    address managerBefore = manager();
+
+    f(e, args);
+
+    assert manager() != managerBefore => e.msg.sender == owner(),
+        "only the owner may change the manager";
+    assert manager() != managerBefore => manager() != 0, "the manager is never address zero";
+}
@@ -0,0 +1,402 @@
+// Command rsvabi keeps the ABI of every deployed contract in committed JSON files, one per
+// contract in abis/, and checks that the ABIs of the working tree keep every function and event
+// of a release's, so that integrators built against the release keep working.
+//
+// `rsvabi extract` writes the ABIs from the artifacts that `make json` writes to -dir, leaving out
+// the test contracts in contracts/test/ and removing the ABIs of contracts that are gone; with
+// -check, it writes nothing, and fails if the committed ABIs are out of date.
+//
+// `rsvabi check <old> [<new>]` compares two sets of ABIs, each a git revision whose -dir is read,
+// or a directory; <new> defaults to -dir as it is, or, if there is no -dir, to the artifacts in
+// -artifacts. A revision with no ABIs committed, such as a release from before abis/, is built
+// instead: checked out in a temporary git worktree, where `make json` compiles it. It lists, for
+// each contract, the functions and events added, removed, and changed (see ops/abicheck), and
+// fails if any change would break an integrator: a contract, function, or event removed, a
+// signature or return type changed, a view no longer a view, or an event's topics altered. With
+// -json, it prints the same as JSON, for tools.
+//
+// `rsvabi selectors` audits the ABIs of every contract that `make json` writes, forwarders and
+// proxies included, for two functions with one selector, two events with one topic, and functions
+// of the Reserve that the ReserveProxy shadows (see abicheck.Audit), and fails if it finds any.
+// -proxy names the proxies and their implementations; -deployed leaves out the test contracts;
+// -list prints every selector and topic, and who has it, as well.
+//
+// Usage:
+//
+//	rsvabi extract [-artifacts evm] [-dir abis] [-check]
+//	rsvabi check [-dir abis] [-artifacts evm] [-json] <revision or directory> [<revision or directory>]
+//	rsvabi selectors [-artifacts evm] [-proxy ReserveProxy=Reserve,...] [-deployed] [-list] [-json]
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/abicheck"
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("rsvabi: ")
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "extract":
+		err = extract(os.Args[2:])
+	case "check":
+		err = check(os.Args[2:])
+	case "selectors":
+		err = selectors(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: rsvabi extract [-artifacts evm] [-dir abis] [-check]")
+	fmt.Fprintln(os.Stderr, "       rsvabi check [-dir abis] [-artifacts evm] [-json] <old> [<new>]")
+	fmt.Fprintln(os.Stderr, "       rsvabi selectors [-artifacts evm] [-proxy ReserveProxy=Reserve,...] [-deployed] [-list] [-json]")
+	os.Exit(2)
+}
+
+func extract(args []string) error {
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	artifactsDir := fs.String("artifacts", "evm", "the artifacts that `make json` writes")
+	dir := fs.String("dir", "abis", "where the ABIs are committed")
+	checkOnly := fs.Bool("check", false, "fail if the committed ABIs are out of date, instead of writing them")
+	fs.Parse(args)
+
+	abis, err := loadABIs(*artifactsDir, true)
+	if err != nil {
+		return err
+	}
+	if !*checkOnly {
+		if err := abicheck.WriteDir(*dir, abis); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote the ABIs of %v contracts to %v.\n", len(abis), *dir)
+		return nil
+	}
+
+	var stale []string
+	for name, raw := range abis {
+		want, err := abicheck.Format(raw)
+		if err != nil {
+			return errors.Wrap(err, name)
+		}
+		have, err := ioutil.ReadFile(filepath.Join(*dir, name+".json"))
+		if err != nil || !bytes.Equal(want, have) {
+			stale = append(stale, name)
+		}
+	}
+	committed, err := abicheck.ReadDir(*dir)
+	if err != nil {
+		return err
+	}
+	for name := range committed {
+		if _, ok := abis[name]; !ok {
+			stale = append(stale, name)
+		}
+	}
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		return errors.Errorf("the ABIs in %v are out of date for %v; run `make abis`", *dir, strings.Join(stale, ", "))
+	}
+	fmt.Printf("The ABIs in %v are up to date.\n", *dir)
+	return nil
+}
+
+// loadABIs returns the ABI of every contract in the artifacts in dir, leaving out the test
+// contracts in contracts/test/ if deployed is set.
+func loadABIs(dir string, deployed bool) (map[string][]byte, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "listing artifacts")
+	}
+	if len(paths) == 0 {
+		return nil, errors.Errorf("no artifacts in %v; run `make json`", dir)
+	}
+	artifacts := chain.NewArtifacts(dir)
+	abis := make(map[string][]byte)
+	for _, p := range paths {
+		a, err := artifacts.Load(strings.TrimSuffix(filepath.Base(p), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		if !deployed || !strings.HasPrefix(a.Source, "contracts/test/") {
+			abis[a.Name] = []byte(a.ABIJSON)
+		}
+	}
+	return abis, nil
+}
+
+// contractChanges is how one contract's ABI changed.
+type contractChanges struct {
+	Contract string            `json:"contract"`
+	Status   string            `json:"status"` // "added", "removed", or "changed"
+	Changes  []abicheck.Change `json:"changes,omitempty"`
+}
+
+func check(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	dir := fs.String("dir", "abis", "where the ABIs are committed")
+	artifactsDir := fs.String("artifacts", "evm", "the artifacts that `make json` writes, to compare if there is no -dir")
+	asJSON := fs.Bool("json", false, "print the changes as JSON")
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		usage()
+	}
+
+	old, err := read(fs.Arg(0), *dir)
+	if err != nil {
+		return err
+	}
+	var new map[string]abicheck.ABI
+	switch _, statErr := os.Stat(*dir); {
+	case fs.NArg() == 2:
+		new, err = read(fs.Arg(1), *dir)
+	case os.IsNotExist(statErr):
+		new, err = artifactABIs(*artifactsDir)
+	default:
+		new, err = read(*dir, *dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	all := []contractChanges{}
+	breaking := 0
+	for _, name := range names {
+		o, inOld := old[name]
+		n, inNew := new[name]
+		switch {
+		case !inOld:
+			all = append(all, contractChanges{Contract: name, Status: "added"})
+		case !inNew:
+			all = append(all, contractChanges{Contract: name, Status: "removed"})
+			breaking++
+		default:
+			changes := abicheck.Compare(o, n)
+			if len(changes) == 0 {
+				continue
+			}
+			all = append(all, contractChanges{Contract: name, Status: "changed", Changes: changes})
+			breaking += len(abicheck.Breaking(changes))
+		}
+	}
+
+	if *asJSON {
+		b, err := json.MarshalIndent(all, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		if len(all) == 0 {
+			fmt.Println("No ABI changed.")
+		}
+		for _, c := range all {
+			switch c.Status {
+			case "added":
+				fmt.Printf("%v: added\n", c.Contract)
+			case "removed":
+				fmt.Printf("%v: removed (breaking)\n", c.Contract)
+			default:
+				fmt.Printf("%v:\n", c.Contract)
+				for _, change := range c.Changes {
+					fmt.Printf("  %v\n", change)
+				}
+			}
+		}
+	}
+	if breaking > 0 {
+		return errors.Errorf("%v changes since %v would break integrators", breaking, fs.Arg(0))
+	}
+	return nil
+}
+
+func selectors(args []string) error {
+	fs := flag.NewFlagSet("selectors", flag.ExitOnError)
+	artifactsDir := fs.String("artifacts", "evm", "the artifacts that `make json` writes")
+	proxyList := fs.String("proxy", "ReserveProxy=Reserve,ReserveProxy=ReserveV2", "the proxies, each as <proxy>=<implementation>, comma-separated")
+	deployed := fs.Bool("deployed", false, "leave out the test contracts in contracts/test/")
+	list := fs.Bool("list", false, "print every selector and topic as well")
+	asJSON := fs.Bool("json", false, "print the findings, and with -list the selectors and topics, as JSON")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		usage()
+	}
+
+	raw, err := loadABIs(*artifactsDir, *deployed)
+	if err != nil {
+		return err
+	}
+	contracts := make(map[string]abicheck.ABI)
+	for name, b := range raw {
+		if contracts[name], err = abicheck.Parse(b); err != nil {
+			return errors.Wrap(err, name)
+		}
+	}
+	var proxies []abicheck.Proxy
+	for _, s := range strings.Split(*proxyList, ",") {
+		if s == "" {
+			continue
+		}
+		p, err := abicheck.ParseProxy(s)
+		if err != nil {
+			return err
+		}
+		// A proxy or implementation that isn't built, as with -deployed, has nothing to shadow.
+		if contracts[p.Proxy] != nil && contracts[p.Implementation] != nil {
+			proxies = append(proxies, p)
+		}
+	}
+	ids := abicheck.IDs(contracts)
+	findings := abicheck.Audit(contracts, proxies)
+
+	if *asJSON {
+		out := struct {
+			IDs      []abicheck.ID      `json:"ids,omitempty"`
+			Findings []abicheck.Finding `json:"findings"`
+		}{Findings: findings}
+		if *list {
+			out.IDs = ids
+		}
+		if out.Findings == nil {
+			out.Findings = []abicheck.Finding{}
+		}
+		b, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		if *list {
+			for _, id := range ids {
+				fmt.Printf("%v %v %v\n", id.Kind, id.ID, id)
+			}
+			fmt.Println()
+		}
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+		if len(findings) == 0 {
+			fmt.Printf("No collisions among the %v selectors and topics of %v contracts.\n", len(ids), len(contracts))
+		}
+	}
+	if len(findings) > 0 {
+		return errors.Errorf("found %v colliding or shadowed selectors and topics", len(findings))
+	}
+	return nil
+}
+
+// read reads a set of ABIs: those in side, if it is a directory, or else those in dir as of the
+// git revision side.
+func read(side, dir string) (map[string]abicheck.ABI, error) {
+	if info, err := os.Stat(side); err == nil && info.IsDir() {
+		return abicheck.ReadDir(side)
+	}
+	prefix := path.Clean(filepath.ToSlash(dir)) + "/"
+	list, err := git("ls-tree", "--name-only", side, "--", prefix)
+	if err != nil {
+		return nil, err
+	}
+	abis := make(map[string]abicheck.ABI)
+	for _, file := range strings.Fields(string(list)) {
+		if !strings.HasSuffix(file, ".json") {
+			continue
+		}
+		b, err := git("show", side+":"+file)
+		if err != nil {
+			return nil, err
+		}
+		a, err := abicheck.Parse(b)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%v at %v", file, side)
+		}
+		abis[strings.TrimSuffix(path.Base(file), ".json")] = a
+	}
+	if len(abis) == 0 {
+		return build(side)
+	}
+	return abis, nil
+}
+
+// build returns the ABIs of the deployed contracts as of revision, compiled with `make json` in
+// a temporary git worktree, for a revision that has none committed.
+func build(revision string) (map[string]abicheck.ABI, error) {
+	tmp, err := ioutil.TempDir("", "rsvabi")
+	if err != nil {
+		return nil, errors.Wrap(err, "making a directory for the worktree")
+	}
+	defer os.RemoveAll(tmp)
+	worktree := filepath.Join(tmp, "tree")
+	if _, err := git("worktree", "add", "--detach", worktree, revision); err != nil {
+		return nil, err
+	}
+	defer git("worktree", "remove", "--force", worktree)
+
+	fmt.Fprintf(os.Stderr, "No ABIs are committed at %v; building them.\n", revision)
+	cmd := exec.Command("make", "json")
+	cmd.Dir = worktree
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "building %v", revision)
+	}
+	abis, err := artifactABIs(filepath.Join(worktree, "evm"))
+	return abis, errors.Wrap(err, revision)
+}
+
+// artifactABIs returns the parsed ABI of every deployed contract in the artifacts in dir.
+func artifactABIs(dir string) (map[string]abicheck.ABI, error) {
+	raw, err := loadABIs(dir, true)
+	if err != nil {
+		return nil, err
+	}
+	abis := make(map[string]abicheck.ABI)
+	for name, b := range raw {
+		a, err := abicheck.Parse(b)
+		if err != nil {
+			return nil, errors.Wrap(err, name)
+		}
+		abis[name] = a
+	}
+	return abis, nil
+}
+
+// git runs a git command and returns its output.
+func git(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	return out, errors.Wrapf(err, "git %v: %s", strings.Join(args, " "), bytes.TrimSpace(stderr.Bytes()))
+}
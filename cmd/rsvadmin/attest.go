@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/attest"
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+func init() {
+	register(&command{
+		name:    "attest",
+		usage:   "-out <file.json> [-text <file.txt>] [-block <block>] [-statement <text>] | -verify <file.json>",
+		summary: "Write a signed proof-of-reserve attestation, or verify one.",
+		run:     runAttest,
+	})
+}
+
+func runAttest(ctx context.Context, e *env, args []string) error {
+	fs := commands["attest"].flags()
+	out := fs.String("out", "", "output file for the signed attestation")
+	text := fs.String("text", "", "output file for a readable report of it")
+	block := fs.Uint64("block", 0, "block to attest to (default: latest)")
+	statement := fs.String("statement", "", "the issuer's statement to include")
+	verify := fs.String("verify", "", "signed attestation to verify instead")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *verify != "" {
+		return verifyAttestation(e, *verify)
+	}
+	if *out == "" {
+		return errors.New("-out is required")
+	}
+
+	s, err := e.open(ctx, "attest")
+	if err != nil {
+		return err
+	}
+	// The attestation is signed by the configured signer, as the issuer.
+	t, err := s.RequireTransactor()
+	if err != nil {
+		return err
+	}
+	reader, err := metrics.NewReader(s)
+	if err != nil {
+		return err
+	}
+	var number *big.Int
+	if *block != 0 {
+		number = new(big.Int).SetUint64(*block)
+	}
+	header, err := s.Client.HeaderByNumber(ctx, number)
+	if err != nil {
+		return errors.Wrap(err, "reading block header")
+	}
+	st, err := reader.ReadAt(ctx, header.Number.Uint64())
+	if err != nil {
+		return err
+	}
+
+	// The contracts involved: the deployment's, and the basket tokens.
+	reserve, err := s.Contract("Reserve")
+	if err != nil {
+		return err
+	}
+	manager, err := s.Contract("Manager")
+	if err != nil {
+		return err
+	}
+	contracts := []attest.Contract{{Name: "Reserve", Address: reserve.Address}}
+	if _, ok := reserve.ABI.Methods["getEternalStorageAddress"]; ok {
+		storage, err := reserve.At(header.Number).CallAddress(ctx, "getEternalStorageAddress")
+		if err != nil {
+			return err
+		}
+		contracts = append(contracts, attest.Contract{Name: "ReserveEternalStorage", Address: storage})
+	}
+	contracts = append(contracts,
+		attest.Contract{Name: "Manager", Address: manager.Address},
+		attest.Contract{Name: "Vault", Address: st.Vault},
+		attest.Contract{Name: "Basket", Address: st.Basket},
+	)
+	if relayer, err := s.Manifest.Address("Relayer"); err == nil {
+		contracts = append(contracts, attest.Contract{Name: "Relayer", Address: relayer})
+	}
+	for _, token := range st.Tokens {
+		name := token.Symbol
+		if name == "" {
+			name = "token"
+		}
+		contracts = append(contracts, attest.Contract{Name: name, Address: token.Address})
+	}
+	for i := range contracts {
+		code, err := s.Client.CodeAt(ctx, contracts[i].Address, header.Number)
+		if err != nil {
+			return errors.Wrapf(err, "reading the code of %v", contracts[i].Name)
+		}
+		if len(code) == 0 {
+			return errors.Errorf("there is no code at %v (%v) at block %v", contracts[i].Address.Hex(), contracts[i].Name, header.Number)
+		}
+		contracts[i].CodeHash = crypto.Keccak256Hash(code)
+	}
+
+	a := attest.New(s.Config.Network, s.Config.ChainID, header, st, contracts)
+	a.Statement = *statement
+	doc, err := a.Sign(ctx, t.Signer)
+	if err != nil {
+		return err
+	}
+	err = writeFile(*out, func(w io.Writer) error {
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if *text != "" {
+		if err := writeFile(*text, doc.WriteText); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(e.out, "Attested to block %v (%v): supply %v RSV, collateralization %v, signed by %v.\nWrote %v.\n",
+		a.Block, a.BlockHash.Hex(), units.Format(st.Supply, rsvDecimals), a.Collateralization, doc.Signer.Hex(), *out)
+	return nil
+}
+
+// verifyAttestation checks the signature of the attestation at path, and prints it.
+func verifyAttestation(e *env, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "reading attestation")
+	}
+	var doc attest.Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return errors.Wrapf(err, "parsing %v", path)
+	}
+	if doc.Signer == (common.Address{}) || len(doc.Attestation) == 0 {
+		return errors.Errorf("%v is not a signed attestation", path)
+	}
+	if _, err := attest.Verify(&doc); err != nil {
+		return err
+	}
+	if err := doc.WriteText(e.out); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "\nThe signature is valid.\n")
+	return nil
+}
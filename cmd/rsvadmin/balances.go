@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/holders"
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+// divergencesShown is how many divergent balances check-balances prints; -out gets them all.
+const divergencesShown = 20
+
+func init() {
+	register(&command{
+		name:    "check-balances",
+		usage:   "[-block <block>] [-from <block>] [-also <address,...>] [-out <divergences.csv>]",
+		summary: "Rebuild every RSV balance from Transfer events and compare each with balanceOf.",
+		run:     runCheckBalances,
+	})
+}
+
+func runCheckBalances(ctx context.Context, e *env, args []string) error {
+	fs := commands["check-balances"].flags()
+	block := fs.Uint64("block", 0, "block to check at (default: latest)")
+	from := fs.Uint64("from", 0, "first block to scan; the Reserve's deployment block is enough")
+	also := fs.String("also", "", "comma-separated earlier Reserve implementations that shared its eternal storage")
+	chunk := fs.Uint64("chunk", 10000, "blocks per log query")
+	out := fs.String("out", "", "if set, write every divergent balance to this CSV file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	s, err := e.open(ctx, "check-balances")
+	if err != nil {
+		return err
+	}
+	reserve, err := s.Contract("Reserve")
+	if err != nil {
+		return err
+	}
+	contracts := []common.Address{reserve.Address}
+	if *also != "" {
+		for _, a := range strings.Split(*also, ",") {
+			addr, err := checksummedAddress(strings.TrimSpace(a))
+			if err != nil {
+				return errors.Wrap(err, "-also")
+			}
+			contracts = append(contracts, addr)
+		}
+	}
+	var number *big.Int
+	if *block != 0 {
+		number = new(big.Int).SetUint64(*block)
+	}
+	header, err := s.Client.HeaderByNumber(ctx, number)
+	if err != nil {
+		return errors.Wrap(err, "reading block header")
+	}
+	*block = header.Number.Uint64()
+
+	state := holders.NewState()
+	fmt.Fprintf(e.out, "Scanning blocks %v-%v of %v ...\n", *from, *block, s.Config.Network)
+	err = state.Scan(ctx, s.Client, contracts, *from, *block, *chunk, func(st *holders.State) {
+		fmt.Fprintf(e.out, "\r  block %v: %v transfers", st.Block, st.Transfers)
+	})
+	fmt.Fprintln(e.out)
+	if err != nil {
+		return err
+	}
+
+	// Everyone who has ever held RSV, including those the events say hold none now: storage that
+	// kept a balance the events took away is as wrong as a balance the events never gave.
+	addresses := state.Addresses()
+	fmt.Fprintf(e.out, "Reading the balances of %v addresses at block %v ...\n", len(addresses), *block)
+	balances, err := readBalances(ctx, s.Client, reserve, header.Number, addresses)
+	if err != nil {
+		return err
+	}
+	divergences, err := state.Diff(addresses, balances)
+	if err != nil {
+		return err
+	}
+	supply, err := reserve.At(header.Number).CallBig(ctx, "totalSupply")
+	if err != nil {
+		return err
+	}
+
+	if *out != "" {
+		err := writeFile(*out, func(w io.Writer) error { return holders.WriteDivergencesCSV(w, divergences) })
+		if err != nil {
+			return err
+		}
+	}
+
+	supplyOK := state.Supply.Cmp(supply) == 0
+	if !supplyOK {
+		fmt.Fprintf(e.out, "MISMATCH: rebuilt supply %v RSV, but totalSupply() is %v RSV\n",
+			units.Format(state.Supply, rsvDecimals), units.Format(supply, rsvDecimals))
+	}
+	for i, d := range divergences {
+		if i == divergencesShown {
+			fmt.Fprintf(e.out, "... and %v more\n", len(divergences)-divergencesShown)
+			break
+		}
+		fmt.Fprintf(e.out, "MISMATCH: %v: rebuilt %v RSV, but balanceOf() is %v RSV\n",
+			d.Address.Hex(), units.Format(d.Rebuilt, rsvDecimals), units.Format(d.OnChain, rsvDecimals))
+	}
+	if *out != "" {
+		fmt.Fprintf(e.out, "Wrote %v divergent balances to %v.\n", len(divergences), *out)
+	}
+	if len(divergences) > 0 || !supplyOK {
+		return errors.Errorf("%v of %v balances at block %v diverge from the events; were -from or -also wrong?",
+			len(divergences), len(addresses), *block)
+	}
+	fmt.Fprintf(e.out, "All %v balances at block %v match the events; total supply %v RSV.\n",
+		len(addresses), *block, units.Format(supply, rsvDecimals))
+	return nil
+}
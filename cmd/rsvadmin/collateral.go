@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+func init() {
+	register(&command{
+		name:    "collateral",
+		usage:   "list|approve|remove [flags]",
+		summary: "List, approve, and remove the tokens of the CollateralRegistry.",
+		run:     runCollateral,
+	})
+}
+
+func runCollateral(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		commands["collateral"].flags().Usage()
+		return errors.New("missing collateral subcommand")
+	}
+	switch args[0] {
+	case "list":
+		return e.collateralList(ctx, args[1:])
+	case "approve":
+		return e.collateralApprove(ctx, args[1:])
+	case "remove":
+		return e.collateralRemove(ctx, args[1:])
+	}
+	return errors.Errorf("unknown collateral subcommand %q", args[0])
+}
+
+// collateral is a token's entry in the CollateralRegistry.
+type collateral struct {
+	Approved bool
+	Decimals uint8
+	Feed     common.Address
+	Cap      *big.Int // unit: aqToken/RSV
+}
+
+func readCollateral(ctx context.Context, registry *chain.Contract, token common.Address) (*collateral, error) {
+	c := new(collateral)
+	err := registry.Call(&bind.CallOpts{Context: ctx}, c, "collaterals", token)
+	return c, errors.Wrapf(err, "calling %v.collaterals", registry.Name)
+}
+
+// registryOwner returns the session's transactor and the CollateralRegistry, which the
+// transactor must own.
+func registryOwner(ctx context.Context, s *session.Session) (*chain.Transactor, *chain.Contract, error) {
+	t, err := s.RequireTransactor()
+	if err != nil {
+		return nil, nil, err
+	}
+	registry, err := s.Contract("CollateralRegistry")
+	if err != nil {
+		return nil, nil, err
+	}
+	owner, err := registry.CallAddress(ctx, "owner")
+	if err != nil {
+		return nil, nil, err
+	}
+	if owner != t.From() {
+		return nil, nil, errors.Errorf("signer %v is not the CollateralRegistry owner (%v)", t.From().Hex(), owner.Hex())
+	}
+	return t, registry, nil
+}
+
+// formatCap formats a weight cap in tokens per RSV.
+func formatCap(c *collateral) string {
+	if c.Cap.Sign() == 0 {
+		return "none"
+	}
+	return units.Format(c.Cap, c.Decimals+rsvDecimals) + " per RSV"
+}
+
+func (e *env) collateralList(ctx context.Context, args []string) error {
+	fs := commands["collateral"].flags()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := e.open(ctx, "collateral list")
+	if err != nil {
+		return err
+	}
+	registry, err := s.Contract("CollateralRegistry")
+	if err != nil {
+		return err
+	}
+	n, err := registry.CallBig(ctx, "tokensLength")
+	if err != nil {
+		return err
+	}
+
+	namer := e.namer(ctx, s)
+	w := tabwriter.NewWriter(e.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TOKEN\tDECIMALS\tFEED\tCAP")
+	for i := int64(0); i < n.Int64(); i++ {
+		token, err := registry.CallAddress(ctx, "tokens", big.NewInt(i))
+		if err != nil {
+			return err
+		}
+		c, err := readCollateral(ctx, registry, token)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", namer.Label(ctx, token), c.Decimals, c.Feed.Hex(), formatCap(c))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "%v tokens approved on %v.\n", n, s.Config.Network)
+	return nil
+}
+
+func (e *env) collateralApprove(ctx context.Context, args []string) error {
+	fs := commands["collateral"].flags()
+	tokenArg := fs.String("token", "", "checksummed address of the token")
+	decimals := fs.Uint("decimals", 0, "the token's decimals")
+	feedArg := fs.String("feed", "", "checksummed address of the token's Chainlink price feed, if it has one")
+	capArg := fs.String("cap", "0", "the greatest weight the token may have in a basket, in tokens per RSV, or 0 for no cap")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	token, err := checksummedAddress(*tokenArg)
+	if err != nil {
+		return errors.Wrap(err, "-token")
+	}
+	if *decimals == 0 || *decimals > 36 {
+		return errors.Errorf("-decimals must be from 1 to 36, not %v", *decimals)
+	}
+	var feed common.Address
+	if *feedArg != "" {
+		if feed, err = checksummedAddress(*feedArg); err != nil {
+			return errors.Wrap(err, "-feed")
+		}
+	}
+	want := &collateral{Approved: true, Decimals: uint8(*decimals), Feed: feed}
+	if want.Cap, err = units.Parse(*capArg, want.Decimals+rsvDecimals); err != nil {
+		return errors.Wrap(err, "-cap")
+	}
+
+	s, err := e.open(ctx, "collateral approve")
+	if err != nil {
+		return err
+	}
+	t, registry, err := registryOwner(ctx, s)
+	if err != nil {
+		return err
+	}
+	current, err := readCollateral(ctx, registry, token)
+	if err != nil {
+		return err
+	}
+	if current.Approved && current.Decimals == want.Decimals && current.Feed == want.Feed && current.Cap.Cmp(want.Cap) == 0 {
+		fmt.Fprintf(e.out, "%v is already approved as asked.\n", token.Hex())
+		return nil
+	}
+
+	if current.Approved {
+		fmt.Fprintf(e.out, "About to update %v in the CollateralRegistry on %v:\n", token.Hex(), s.Config.Network)
+		fmt.Fprintf(e.out, "  decimals %v, feed %v, cap %v\n", current.Decimals, current.Feed.Hex(), formatCap(current))
+		fmt.Fprintf(e.out, "  becomes decimals %v, feed %v, cap %v\n", want.Decimals, want.Feed.Hex(), formatCap(want))
+	} else {
+		fmt.Fprintf(e.out, "About to approve %v as collateral on %v, with decimals %v, feed %v, cap %v.\n",
+			token.Hex(), s.Config.Network, want.Decimals, want.Feed.Hex(), formatCap(want))
+	}
+	if err := e.prompt.Expect("Re-type the token address to confirm:", token.Hex()); err != nil {
+		return err
+	}
+	receipt, err := t.SendAndWait(ctx, chain.Call{
+		Contract: registry,
+		Method:   "approve",
+		Args:     []interface{}{token, want.Decimals, want.Feed, want.Cap},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Done: %v (gas used: %v).\n", receipt.TxHash.Hex(), receipt.GasUsed)
+	return nil
+}
+
+func (e *env) collateralRemove(ctx context.Context, args []string) error {
+	fs := commands["collateral"].flags()
+	tokenArg := fs.String("token", "", "checksummed address of the token")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	token, err := checksummedAddress(*tokenArg)
+	if err != nil {
+		return errors.Wrap(err, "-token")
+	}
+
+	s, err := e.open(ctx, "collateral remove")
+	if err != nil {
+		return err
+	}
+	t, registry, err := registryOwner(ctx, s)
+	if err != nil {
+		return err
+	}
+	current, err := readCollateral(ctx, registry, token)
+	if err != nil {
+		return err
+	}
+	if !current.Approved {
+		return errors.Errorf("%v is not approved", token.Hex())
+	}
+
+	// A proposal can only be executed if its new basket is all approved tokens.
+	manager, err := s.Contract("Manager")
+	if err != nil {
+		return err
+	}
+	basketAddress, err := manager.CallAddress(ctx, "trustedBasket")
+	if err != nil {
+		return err
+	}
+	artifact, err := s.Artifacts.Load("Basket")
+	if err != nil {
+		return err
+	}
+	basket := artifact.Bind(basketAddress, s.Client)
+	inBasket, err := basket.CallBool(ctx, "has", token)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.out, "About to remove %v from the approved collateral on %v.\n", token.Hex(), s.Config.Network)
+	if inBasket {
+		fmt.Fprintln(e.out, "It is in the basket: from now on, only proposals that take it out can be executed.")
+	}
+	if err := e.prompt.Expect("Re-type the token address to confirm:", token.Hex()); err != nil {
+		return err
+	}
+	receipt, err := t.SendAndWait(ctx, chain.Call{Contract: registry, Method: "remove", Args: []interface{}{token}})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Done: %v (gas used: %v).\n", receipt.TxHash.Hex(), receipt.GasUsed)
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+func init() {
+	register(&command{
+		name:    "distribute",
+		usage:   "-in <payments.csv> [-chunk <n>] [-skip <n>]",
+		summary: "Send RSV from the signer to every address in a CSV file, with transferBatch.",
+		run:     runDistribute,
+	})
+}
+
+// payment is a row of a distribution file.
+type payment struct {
+	to     common.Address
+	amount *big.Int
+}
+
+func runDistribute(ctx context.Context, e *env, args []string) error {
+	fs := commands["distribute"].flags()
+	in := fs.String("in", "", "CSV file of address,amount rows, amounts in RSV")
+	chunk := fs.Int("chunk", 100, "payments per transaction")
+	skip := fs.Int("skip", 0, "payments to skip at the start of the file, to resume a distribution")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+	if *chunk <= 0 {
+		return errors.New("-chunk must be positive")
+	}
+	f, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	payments, err := readPayments(f)
+	f.Close()
+	if err != nil {
+		return errors.Wrap(err, *in)
+	}
+	if *skip < 0 || *skip >= len(payments) {
+		return errors.Errorf("-skip must be less than the %v payments in %v", len(payments), *in)
+	}
+	payments = payments[*skip:]
+
+	s, err := e.open(ctx, "distribute")
+	if err != nil {
+		return err
+	}
+	t, err := s.RequireTransactor()
+	if err != nil {
+		return err
+	}
+	reserve, err := s.Contract("Reserve")
+	if err != nil {
+		return err
+	}
+
+	// On-chain preconditions, as for mint: each would revert a whole chunk.
+	total := new(big.Int)
+	for _, p := range payments {
+		total.Add(total, p.amount)
+	}
+	paused, err := reserve.CallBool(ctx, "paused")
+	if err != nil {
+		return err
+	}
+	if paused {
+		return errors.New("Reserve is paused")
+	}
+	transfersPaused, err := reserve.CallBool(ctx, "transfersPaused")
+	if err != nil {
+		return err
+	}
+	if transfersPaused {
+		return errors.New("Reserve transfers are paused")
+	}
+	balance, err := reserve.CallBig(ctx, "balanceOf", t.From())
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(total) < 0 {
+		return errors.Errorf("signer %v holds only %v RSV of the %v RSV to distribute",
+			t.From().Hex(), units.Format(balance, rsvDecimals), units.Format(total, rsvDecimals))
+	}
+	accounts := []common.Address{t.From()}
+	for _, p := range payments {
+		accounts = append(accounts, p.to)
+	}
+	frozen, err := readFrozen(ctx, s.Client, reserve, accounts)
+	if err != nil {
+		return err
+	}
+	for i, account := range accounts {
+		if frozen[i] {
+			return errors.Errorf("%v is frozen", account.Hex())
+		}
+	}
+
+	chunks := (len(payments) + *chunk - 1) / *chunk
+	fmt.Fprintf(e.out, "About to send %v RSV from %v to %v addresses on %v, in %v transactions of up to %v.\n",
+		units.Format(total, rsvDecimals), t.From().Hex(), len(payments), s.Config.Network, chunks, *chunk)
+	if err := e.prompt.Expect("Re-type the total, in RSV, to confirm:", units.Format(total, rsvDecimals)); err != nil {
+		return err
+	}
+
+	// Each chunk is all or nothing, but the chunks before a failed one have been sent, so the
+	// operator resumes from where it failed rather than starting over.
+	for i := 0; i < chunks; i++ {
+		start, end := i**chunk, (i+1)**chunk
+		if end > len(payments) {
+			end = len(payments)
+		}
+		recipients := make([]common.Address, 0, end-start)
+		amounts := make([]*big.Int, 0, end-start)
+		for _, p := range payments[start:end] {
+			recipients = append(recipients, p.to)
+			amounts = append(amounts, p.amount)
+		}
+		receipt, err := t.SendAndWait(ctx, chain.Call{
+			Contract: reserve,
+			Method:   "transferBatch",
+			Args:     []interface{}{recipients, amounts},
+		})
+		if err != nil {
+			return errors.Wrapf(err, "payments %v-%v were not sent; to resume, rerun with -skip %v",
+				*skip+start+1, *skip+end, *skip+start)
+		}
+		fmt.Fprintf(e.out, "Sent payments %v-%v: %v (gas used: %v).\n",
+			*skip+start+1, *skip+end, receipt.TxHash.Hex(), receipt.GasUsed)
+	}
+	fmt.Fprintln(e.out, "Done.")
+	return nil
+}
+
+// readPayments reads a distribution file: CSV rows of a checksummed address and an amount in
+// RSV, optionally under an "address,amount" header. No address may be listed twice.
+func readPayments(r io.Reader) ([]payment, error) {
+	rows := csv.NewReader(r)
+	rows.FieldsPerRecord = 2
+	rows.TrimLeadingSpace = true
+	var payments []payment
+	seen := make(map[common.Address]int)
+	for line := 1; ; line++ {
+		row, err := rows.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(row[0], "address") && strings.EqualFold(row[1], "amount") {
+			continue
+		}
+		to, err := checksummedAddress(row[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %v", line)
+		}
+		if first, ok := seen[to]; ok {
+			return nil, errors.Errorf("line %v: %v is already on line %v", line, to.Hex(), first)
+		}
+		seen[to] = line
+		amount, err := units.Parse(row[1], rsvDecimals)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %v", line)
+		}
+		if amount.Sign() <= 0 {
+			return nil, errors.Errorf("line %v: amount must be positive", line)
+		}
+		payments = append(payments, payment{to: to, amount: amount})
+	}
+	if len(payments) == 0 {
+		return nil, errors.New("no payments")
+	}
+	return payments, nil
+}
+
+// readFrozen reads whether each of accounts is frozen, in batches.
+func readFrozen(ctx context.Context, client *chain.Client, reserve *chain.Contract, accounts []common.Address) ([]bool, error) {
+	frozen := make([]bool, len(accounts))
+	for start := 0; start < len(accounts); start += balancesPerBatch {
+		batch := client.NewBatch(nil)
+		for i := start; i < len(accounts) && i < start+balancesPerBatch; i++ {
+			if err := batch.Add(reserve, &frozen[i], "frozen", accounts[i]); err != nil {
+				return nil, err
+			}
+		}
+		if err := batch.Do(ctx); err != nil {
+			return nil, errors.Wrap(err, "reading frozen accounts")
+		}
+	}
+	return frozen, nil
+}
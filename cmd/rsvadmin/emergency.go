@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/emergency"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// emergencyConfig configures the emergency command.
+type emergencyConfig struct {
+	// SnapshotDir is where state snapshots are written.
+	SnapshotDir string `json:"snapshotDir"`
+
+	// Webhooks are notified of every emergency response.
+	Webhooks []emergency.Webhook `json:"webhooks,omitempty"`
+}
+
+func init() {
+	register(&command{
+		name:    "emergency",
+		usage:   "-reason <text> [-freeze <file>] [address ...]",
+		summary: "Pause RSV, freeze addresses, snapshot the deployment, and notify the team.",
+		run:     runEmergency,
+	})
+}
+
+func runEmergency(ctx context.Context, e *env, args []string) error {
+	fs := commands["emergency"].flags()
+	reason := fs.String("reason", "", "what happened, for the snapshot and notifications")
+	freezeFile := fs.String("freeze", "", "file of addresses to freeze, one per line")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *reason == "" {
+		return errors.New("-reason is required")
+	}
+	c := e.config.Emergency
+	if c.SnapshotDir == "" {
+		return errors.New("config: emergency.snapshotDir is not set")
+	}
+
+	var accounts []common.Address
+	if *freezeFile != "" {
+		f, err := os.Open(*freezeFile)
+		if err != nil {
+			return errors.Wrap(err, "opening -freeze file")
+		}
+		accounts, err = emergency.ReadAddresses(f)
+		f.Close()
+		if err != nil {
+			return errors.Wrapf(err, "reading %v", *freezeFile)
+		}
+	}
+	for _, arg := range fs.Args() {
+		addr, err := emergency.ParseAddress(arg)
+		if err != nil {
+			return err
+		}
+		accounts = appendNew(accounts, addr)
+	}
+
+	notifier, err := emergency.NewNotifier(c.Webhooks)
+	if err != nil {
+		return err
+	}
+	// Transactions are not simulated first, even if Tenderly is configured: in an incident,
+	// getting the pause in quickly matters more. Nor is the session's network interlock
+	// installed, since the operator types the network name below on every network anyway.
+	s, err := session.Open(ctx, e.config.Config, "rsvadmin emergency")
+	if err != nil {
+		return err
+	}
+	ch, err := emergency.NewChain(s)
+	if err != nil {
+		return err
+	}
+	if err := e.checkEmergencyRoles(ctx, s, ch, len(accounts) > 0); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.out, "EMERGENCY RESPONSE on %v:\n", s.Config.Network)
+	fmt.Fprintf(e.out, "  1. pause the Reserve\n")
+	fmt.Fprintf(e.out, "  2. freeze %v addresses\n", len(accounts))
+	namer := e.namer(ctx, s)
+	for _, addr := range accounts {
+		fmt.Fprintf(e.out, "       %v\n", namer.Label(ctx, addr))
+	}
+	fmt.Fprintf(e.out, "  3. snapshot the deployment to %v\n", c.SnapshotDir)
+	fmt.Fprintf(e.out, "  4. notify %v webhooks\n", len(c.Webhooks))
+	fmt.Fprintf(e.out, "Reason: %v\n", *reason)
+	if err := e.prompt.Expect("Type the network name to proceed:", s.Config.Network); err != nil {
+		return err
+	}
+
+	runbook := &emergency.Runbook{
+		Chain:       ch,
+		Notifier:    notifier,
+		SnapshotDir: c.SnapshotDir,
+		Network:     s.Config.Network,
+		Log:         e.out,
+	}
+	report, err := runbook.Run(ctx, s.Transactor.From(), *reason, accounts)
+	if report.SnapshotFile != "" {
+		fmt.Fprintf(e.out, "Snapshot: %v\n", report.SnapshotFile)
+	}
+	return err
+}
+
+// checkEmergencyRoles checks, before anything is sent, that the signer can pause the Reserve and
+// freeze addresses. If it can't, the operator should know now rather than halfway through.
+func (e *env) checkEmergencyRoles(ctx context.Context, s *session.Session, ch emergency.Chain, freezing bool) error {
+	reserve, err := s.Contract("Reserve")
+	if err != nil {
+		return err
+	}
+	from := s.Transactor.From()
+	paused, err := ch.Paused(ctx)
+	if err != nil {
+		return err
+	}
+	if !paused {
+		var guardian common.Address
+		if _, ok := reserve.ABI.Methods["guardian"]; ok {
+			if guardian, err = reserve.CallAddress(ctx, "guardian"); err != nil {
+				return err
+			}
+		}
+		pauser, err := reserve.CallAddress(ctx, "pauser")
+		if err != nil {
+			return err
+		}
+		if pauser != from && guardian != from {
+			return errors.Errorf("signer %v is not the Reserve pauser (%v) or guardian", from.Hex(), pauser.Hex())
+		}
+	}
+	if !freezing {
+		return nil
+	}
+	if !ch.CanFreeze() {
+		return errors.New("the deployed Reserve cannot freeze addresses; run without addresses to pause only")
+	}
+	if _, ok := reserve.ABI.Methods["freezer"]; ok {
+		freezer, err := reserve.CallAddress(ctx, "freezer")
+		if err != nil {
+			return err
+		}
+		if freezer != from {
+			return errors.Errorf("signer %v is not the Reserve freezer (%v)", from.Hex(), freezer.Hex())
+		}
+	}
+	return nil
+}
+
+func appendNew(addrs []common.Address, addr common.Address) []common.Address {
+	for _, a := range addrs {
+		if a == addr {
+			return addrs
+		}
+	}
+	return append(addrs, addr)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/ens"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+func init() {
+	register(&command{
+		name:    "ens",
+		usage:   "list | set -contract <name> -name <ENS name> | lookup <ENS name or address>",
+		summary: "Check, set, and look up the ENS names of deployed contracts.",
+		run:     runENS,
+	})
+}
+
+func runENS(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		commands["ens"].flags().Usage()
+		return errors.New("missing ens subcommand")
+	}
+	switch args[0] {
+	case "list":
+		return e.ensList(ctx)
+	case "set":
+		return e.ensSet(ctx, args[1:])
+	case "lookup":
+		return e.ensLookup(ctx, args[1:])
+	}
+	return errors.Errorf("unknown ens subcommand %q", args[0])
+}
+
+// openENS opens a session and the chain's ENS registry, which must exist.
+func (e *env) openENS(ctx context.Context, name string) (*session.Session, *ens.ENS, error) {
+	s, err := e.open(ctx, "ens "+name)
+	if err != nil {
+		return nil, nil, err
+	}
+	registry, err := ens.Open(ctx, s.Client)
+	if err != nil {
+		return nil, nil, err
+	}
+	if registry == nil {
+		return nil, nil, errors.Errorf("there is no ENS registry on %v", s.Config.Network)
+	}
+	return s, registry, nil
+}
+
+// namer returns a Namer for labelling addresses in s's deployment. Without an ENS registry, it
+// labels only the manifest's contracts. Labels are a convenience, so errors finding the
+// registry are ignored.
+func (e *env) namer(ctx context.Context, s *session.Session) *ens.Namer {
+	registry, _ := ens.Open(ctx, s.Client)
+	return ens.NewNamer(registry, s.Manifest)
+}
+
+// ensList checks that each of the manifest's ENS names resolves to its contract.
+func (e *env) ensList(ctx context.Context) error {
+	s, registry, err := e.openENS(ctx, "list")
+	if err != nil {
+		return err
+	}
+	contracts := make([]string, 0, len(s.Manifest.Names))
+	for contract := range s.Manifest.Names {
+		contracts = append(contracts, contract)
+	}
+	sort.Strings(contracts)
+	wrong := 0
+	for _, contract := range contracts {
+		name := s.Manifest.Names[contract]
+		want, err := s.Manifest.Address(contract)
+		if err != nil {
+			return err
+		}
+		status := "ok"
+		got, err := registry.Resolve(ctx, name)
+		switch {
+		case errors.Cause(err) == ens.ErrNoResolver:
+			status = "no resolver"
+		case err != nil:
+			return err
+		case got != want:
+			status = "resolves to " + got.Hex()
+		}
+		if status != "ok" {
+			wrong++
+		}
+		fmt.Fprintf(e.out, "%-32v %-16v %v  %v\n", name, contract, want.Hex(), status)
+	}
+	if wrong > 0 {
+		return errors.Errorf("%v names do not resolve to their contracts", wrong)
+	}
+	return nil
+}
+
+func (e *env) ensSet(ctx context.Context, args []string) error {
+	fs := commands["ens"].flags()
+	contract := fs.String("contract", "", "manifest name of the contract")
+	name := fs.String("name", "", "ENS name to point at it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *contract == "" || *name == "" {
+		return errors.New("-contract and -name are required")
+	}
+	s, registry, err := e.openENS(ctx, "set")
+	if err != nil {
+		return err
+	}
+	tx, err := s.RequireTransactor()
+	if err != nil {
+		return err
+	}
+	addr, err := s.Manifest.Address(*contract)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.out, "About to point %v at %v (%v) on %v.\n", *name, *contract, addr.Hex(), s.Config.Network)
+	if err := e.prompt.Confirm("Set this name?"); err != nil {
+		return err
+	}
+	if err := registry.Set(ctx, tx, *name, addr); err != nil {
+		return err
+	}
+	got, err := registry.Resolve(ctx, *name)
+	if err != nil {
+		return err
+	}
+	if got != addr {
+		return errors.Errorf("%v resolves to %v after setting it to %v", *name, got.Hex(), addr.Hex())
+	}
+	s.Manifest.Names[*contract] = strings.ToLower(*name)
+	if err := s.Manifest.Save(s.Config.Manifest); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "%v now resolves to %v.\n", *name, addr.Hex())
+	return nil
+}
+
+func (e *env) ensLookup(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("lookup takes one ENS name or address")
+	}
+	s, registry, err := e.openENS(ctx, "lookup")
+	if err != nil {
+		return err
+	}
+	if common.IsHexAddress(args[0]) {
+		fmt.Fprintln(e.out, ens.NewNamer(registry, s.Manifest).Label(ctx, common.HexToAddress(args[0])))
+		return nil
+	}
+	addr, err := registry.Resolve(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(e.out, ens.NewNamer(registry, s.Manifest).Label(ctx, addr))
+	return nil
+}
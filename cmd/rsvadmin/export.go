@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/holders"
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+func init() {
+	register(&command{
+		name:    "export-holders",
+		usage:   "-out <prefix> [-format csv|json] [-from <block>] [-to <block>] [-also <address,...>] [-check-balances]",
+		summary: "Rebuild every RSV balance and allowance from event history, and export them.",
+		run:     runExportHolders,
+	})
+}
+
+func runExportHolders(ctx context.Context, e *env, args []string) error {
+	fs := commands["export-holders"].flags()
+	out := fs.String("out", "", "output path prefix: <prefix>.json, or <prefix>-holders.csv and <prefix>-allowances.csv")
+	format := fs.String("format", "csv", "csv or json")
+	from := fs.Uint64("from", 0, "first block to scan; the Reserve's deployment block is enough")
+	to := fs.Uint64("to", 0, "last block to scan (default: latest)")
+	also := fs.String("also", "", "comma-separated earlier Reserve implementations that shared its eternal storage")
+	chunk := fs.Uint64("chunk", 10000, "blocks per log query")
+	checkBalances := fs.Bool("check-balances", false, "also compare every rebuilt balance with balanceOf")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("-out is required")
+	}
+	if *format != "csv" && *format != "json" {
+		return errors.Errorf("-format must be csv or json, not %q", *format)
+	}
+
+	s, err := e.open(ctx, "export-holders")
+	if err != nil {
+		return err
+	}
+	reserve, err := s.Contract("Reserve")
+	if err != nil {
+		return err
+	}
+	contracts := []common.Address{reserve.Address}
+	if *also != "" {
+		for _, a := range strings.Split(*also, ",") {
+			addr, err := checksummedAddress(strings.TrimSpace(a))
+			if err != nil {
+				return errors.Wrap(err, "-also")
+			}
+			contracts = append(contracts, addr)
+		}
+	}
+	if *to == 0 {
+		header, err := s.Client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return errors.Wrap(err, "reading latest block")
+		}
+		*to = header.Number.Uint64()
+	}
+
+	state := holders.NewState()
+	fmt.Fprintf(e.out, "Scanning blocks %v-%v of %v ...\n", *from, *to, s.Config.Network)
+	err = state.Scan(ctx, s.Client, contracts, *from, *to, *chunk, func(st *holders.State) {
+		fmt.Fprintf(e.out, "\r  block %v: %v transfers, %v approvals", st.Block, st.Transfers, st.Approvals)
+	})
+	fmt.Fprintln(e.out)
+	if err != nil {
+		return err
+	}
+
+	// Cross-check against the contract, as of the last block scanned.
+	pinned := reserve.At(new(big.Int).SetUint64(state.Block))
+	supply, err := pinned.CallBig(ctx, "totalSupply")
+	if err != nil {
+		return err
+	}
+	var problems []string
+	if state.Supply.Cmp(supply) != 0 {
+		problems = append(problems, fmt.Sprintf("rebuilt supply %v RSV, but totalSupply() is %v RSV",
+			units.Format(state.Supply, rsvDecimals), units.Format(supply, rsvDecimals)))
+	}
+	if sum := state.HoldersSum(); sum.Cmp(state.Supply) != 0 {
+		problems = append(problems, fmt.Sprintf("balances sum to %v RSV, but rebuilt supply is %v RSV",
+			units.Format(sum, rsvDecimals), units.Format(state.Supply, rsvDecimals)))
+	}
+	if *checkBalances {
+		for _, h := range state.Holders() {
+			balance, err := pinned.CallBig(ctx, "balanceOf", h.Address)
+			if err != nil {
+				return err
+			}
+			if balance.Cmp(h.Balance) != 0 {
+				problems = append(problems, fmt.Sprintf("rebuilt balance of %v is %v, but balanceOf() is %v", h.Address.Hex(), h.Balance, balance))
+			}
+		}
+	}
+
+	if *format == "json" {
+		err = writeFile(*out+".json", state.WriteJSON)
+	} else if err = writeFile(*out+"-holders.csv", state.WriteHoldersCSV); err == nil {
+		err = writeFile(*out+"-allowances.csv", state.WriteAllowancesCSV)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Exported %v holders and %v allowances as of block %v; total supply %v RSV.\n",
+		len(state.Holders()), len(state.Allowances()), state.Block, units.Format(state.Supply, rsvDecimals))
+
+	if len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Fprintln(e.out, "MISMATCH:", p)
+		}
+		return errors.New("the rebuilt state does not match the contract; were -from or -also wrong?")
+	}
+	fmt.Fprintln(e.out, "The rebuilt supply matches totalSupply().")
+	return nil
+}
+
+// writeFile creates path and writes it with write.
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "creating output")
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "writing %v", path)
+	}
+	return errors.Wrapf(f.Close(), "writing %v", path)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/authorize"
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+func init() {
+	register(&command{
+		name:    "guardians",
+		usage:   "list | sign -action <action> [flags] | execute -action <action> -sigs <sig,...> [flags]",
+		summary: "Sign and execute the GuardianMultisig's m-of-n pauses and freezes.",
+		run:     runGuardians,
+	})
+}
+
+// guardianActions are the GuardianMultisig's actions, by the names the command takes.
+var guardianActions = map[string]uint8{
+	"pause":     authorize.Pause,
+	"freeze":    authorize.Freeze,
+	"unfreeze":  authorize.Unfreeze,
+	"add":       authorize.AddGuardian,
+	"remove":    authorize.RemoveGuardian,
+	"threshold": authorize.ChangeThreshold,
+}
+
+func runGuardians(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		commands["guardians"].flags().Usage()
+		return errors.New("missing guardians subcommand")
+	}
+	switch args[0] {
+	case "list":
+		return e.guardiansList(ctx, args[1:])
+	case "sign":
+		return e.guardiansSign(ctx, args[1:])
+	case "execute":
+		return e.guardiansExecute(ctx, args[1:])
+	}
+	return errors.Errorf("unknown guardians subcommand %q", args[0])
+}
+
+// guardianActionFlags are the flags that name an action, which every guardian must give alike.
+type guardianActionFlags struct {
+	action, account, value *string
+	deadline               *int64
+}
+
+func newGuardianActionFlags(fs *flag.FlagSet) *guardianActionFlags {
+	return &guardianActionFlags{
+		action:   fs.String("action", "", "pause, freeze, unfreeze, add, remove, or threshold"),
+		account:  fs.String("account", "", "checksummed address to freeze, unfreeze, add, or remove"),
+		value:    fs.String("value", "0", "the new threshold, for add, remove, and threshold"),
+		deadline: fs.Int64("deadline", 0, "Unix time after which the signatures are void"),
+	}
+}
+
+// parse returns the action the flags name, at the multisig's current nonce.
+func (f *guardianActionFlags) parse(ctx context.Context, multisig *chain.Contract) (*authorize.Action, error) {
+	kind, ok := guardianActions[*f.action]
+	if !ok {
+		return nil, errors.Errorf("unknown -action %q", *f.action)
+	}
+	if *f.deadline <= 0 {
+		return nil, errors.New("-deadline is required")
+	}
+	a := &authorize.Action{Kind: kind, Value: new(big.Int), Deadline: big.NewInt(*f.deadline)}
+	if kind != authorize.Pause && kind != authorize.ChangeThreshold {
+		addr, err := checksummedAddress(*f.account)
+		if err != nil {
+			return nil, errors.Wrap(err, "-account")
+		}
+		a.Account = addr
+	}
+	if kind != authorize.Pause && kind != authorize.Freeze && kind != authorize.Unfreeze {
+		if _, ok := a.Value.SetString(*f.value, 10); !ok || a.Value.Sign() <= 0 {
+			return nil, errors.Errorf("-value must be a positive integer, not %q", *f.value)
+		}
+	}
+	nonce, err := multisig.CallBig(ctx, "nonce")
+	if err != nil {
+		return nil, err
+	}
+	a.Nonce = nonce
+	return a, nil
+}
+
+// guardianDigest returns the digest of a, which it checks against the multisig's own, so that a
+// guardian never signs for the wrong chain or contract.
+func guardianDigest(ctx context.Context, s *session.Session, multisig *chain.Contract, a *authorize.Action) (common.Hash, error) {
+	chainID, err := s.Client.ChainID(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	hash := a.Hash(authorize.GuardianMultisig(multisig.Address, chainID))
+	var onchain [32]byte
+	if err := multisig.Call(&bind.CallOpts{Context: ctx}, &onchain, "digest", a.Kind, a.Account, a.Value, a.Deadline); err != nil {
+		return common.Hash{}, errors.Wrapf(err, "calling %v.digest", multisig.Name)
+	}
+	if hash != common.Hash(onchain) {
+		return common.Hash{}, errors.Errorf("%v was not deployed for chain %v", multisig.Name, chainID)
+	}
+	return hash, nil
+}
+
+func (e *env) guardiansList(ctx context.Context, args []string) error {
+	fs := commands["guardians"].flags()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := e.open(ctx, "guardians list")
+	if err != nil {
+		return err
+	}
+	multisig, err := s.Contract("GuardianMultisig")
+	if err != nil {
+		return err
+	}
+	n, err := multisig.CallBig(ctx, "guardiansLength")
+	if err != nil {
+		return err
+	}
+	threshold, err := multisig.CallBig(ctx, "threshold")
+	if err != nil {
+		return err
+	}
+	nonce, err := multisig.CallBig(ctx, "nonce")
+	if err != nil {
+		return err
+	}
+	namer := e.namer(ctx, s)
+	for i := int64(0); i < n.Int64(); i++ {
+		guardian, err := multisig.CallAddress(ctx, "guardians", big.NewInt(i))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(e.out, "  %v\n", namer.Label(ctx, guardian))
+	}
+	fmt.Fprintf(e.out, "%v of %v guardians must sign; the next action has nonce %v.\n", threshold, n, nonce)
+	return nil
+}
+
+func (e *env) guardiansSign(ctx context.Context, args []string) error {
+	fs := commands["guardians"].flags()
+	af := newGuardianActionFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := e.open(ctx, "guardians sign")
+	if err != nil {
+		return err
+	}
+	t, err := s.RequireTransactor()
+	if err != nil {
+		return err
+	}
+	multisig, err := s.Contract("GuardianMultisig")
+	if err != nil {
+		return err
+	}
+	isGuardian, err := multisig.CallBool(ctx, "isGuardian", t.From())
+	if err != nil {
+		return err
+	}
+	if !isGuardian {
+		return errors.Errorf("signer %v is not a guardian", t.From().Hex())
+	}
+	a, err := af.parse(ctx, multisig)
+	if err != nil {
+		return err
+	}
+	hash, err := guardianDigest(ctx, s, multisig, a)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.out, "About to sign %v %v, value %v, at nonce %v, until %v, on %v.\n",
+		*af.action, a.Account.Hex(), a.Value, a.Nonce, a.Deadline, s.Config.Network)
+	if err := e.prompt.Confirm("Sign?"); err != nil {
+		return err
+	}
+	sig, err := authorize.Sign(ctx, t.Signer, hash)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Signature: 0x%x%x%02x\n", sig.R, sig.S, sig.V)
+	return nil
+}
+
+func (e *env) guardiansExecute(ctx context.Context, args []string) error {
+	fs := commands["guardians"].flags()
+	af := newGuardianActionFlags(fs)
+	sigsArg := fs.String("sigs", "", "comma-separated guardians' signatures, as sign prints them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var sigs []authorize.Signature
+	for _, arg := range strings.Split(*sigsArg, ",") {
+		raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(arg), "0x"))
+		if err != nil || len(raw) != 65 {
+			return errors.Errorf("-sigs: %q is not a 65-byte hex signature", arg)
+		}
+		var sig authorize.Signature
+		copy(sig.R[:], raw[:32])
+		copy(sig.S[:], raw[32:64])
+		sig.V = raw[64]
+		sigs = append(sigs, sig)
+	}
+
+	s, err := e.open(ctx, "guardians execute")
+	if err != nil {
+		return err
+	}
+	t, err := s.RequireTransactor()
+	if err != nil {
+		return err
+	}
+	multisig, err := s.Contract("GuardianMultisig")
+	if err != nil {
+		return err
+	}
+	a, err := af.parse(ctx, multisig)
+	if err != nil {
+		return err
+	}
+	hash, err := guardianDigest(ctx, s, multisig, a)
+	if err != nil {
+		return err
+	}
+	collected, err := authorize.Collect(hash, sigs)
+	if err != nil {
+		return errors.Wrap(err, "-sigs")
+	}
+	for _, guardian := range collected.Signers {
+		ok, err := multisig.CallBool(ctx, "isGuardian", guardian)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.Errorf("-sigs: %v, who signed, is not a guardian; were the flags signed alike?", guardian.Hex())
+		}
+	}
+	threshold, err := multisig.CallBig(ctx, "threshold")
+	if err != nil {
+		return err
+	}
+	if big.NewInt(int64(len(sigs))).Cmp(threshold) < 0 {
+		return errors.Errorf("-sigs: %v signatures, but %v are needed", len(sigs), threshold)
+	}
+
+	fmt.Fprintf(e.out, "About to execute %v %v, value %v, signed by %v guardians, on %v.\n",
+		*af.action, a.Account.Hex(), a.Value, len(sigs), s.Config.Network)
+	if err := e.prompt.Expect("Type the network name to proceed:", s.Config.Network); err != nil {
+		return err
+	}
+	receipt, err := t.SendAndWait(ctx, chain.Call{
+		Contract: multisig,
+		Method:   "execute",
+		Args:     []interface{}{a.Kind, a.Account, a.Value, a.Deadline, collected.V, collected.R, collected.S},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Done: %v (gas used: %v).\n", receipt.TxHash.Hex(), receipt.GasUsed)
+	return nil
+}
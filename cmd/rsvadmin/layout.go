@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/bytecode"
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/layout"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+	"github.com/reserve-protocol/rsv-beta/ops/upgrade"
+)
+
+func init() {
+	register(&command{
+		name:    "check-layout",
+		usage:   "<old contract or layout file> <new contract or layout file>",
+		summary: "Check that a new implementation keeps every state variable of the old one where it was.",
+		run:     runCheckLayout,
+	})
+}
+
+func runCheckLayout(ctx context.Context, e *env, args []string) error {
+	fs := commands["check-layout"].flags()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("give the old and new contract names")
+	}
+	// Everything comes from the artifacts, so this runs without a node.
+	return checkLayout(e, chain.NewArtifacts(e.config.Artifacts).Dir, fs.Arg(0), fs.Arg(1))
+}
+
+// checkLayout prints how the storage layout of contract new differs from that of old, and
+// fails if new would misread old's storage.
+func checkLayout(e *env, dir, old, new string) error {
+	oldLayout, err := loadLayout(dir, old)
+	if err != nil {
+		return err
+	}
+	newLayout, err := loadLayout(dir, new)
+	if err != nil {
+		return err
+	}
+	changes := layout.Compare(oldLayout, newLayout)
+	bad := layout.Incompatible(changes)
+	fmt.Fprintf(e.out, "Storage layout of %v against %v:", new, old)
+	if len(changes) == 0 {
+		fmt.Fprint(e.out, " unchanged")
+	}
+	fmt.Fprintln(e.out)
+	for _, c := range changes {
+		fmt.Fprintf(e.out, "  %v\n", c)
+	}
+	if len(bad) > 0 {
+		return errors.Errorf("%v is not storage-compatible with %v: %v incompatible changes", new, old, len(bad))
+	}
+	return nil
+}
+
+// loadLayout reads the layout of name: from the layout file name, such as one that `rsvlayout
+// extract` committed for a release, or else from the artifact of contract name in dir.
+func loadLayout(dir, name string) (*layout.Layout, error) {
+	if strings.HasSuffix(name, ".json") {
+		return layout.ReadFile(name)
+	}
+	return layout.Load(dir, name)
+}
+
+// checkUpgradeLayouts checks each upgradeTo and upgradeToAndCall step of p against the
+// implementation that the proxy will have just before it. Implementations are identified by
+// their code, since the manifest names them for the deployment (such as ReserveImplV2), not
+// the artifact; a step that gives the old implementation's committed layout is checked against
+// that instead.
+func (e *env) checkUpgradeLayouts(ctx context.Context, s *session.Session, p *upgrade.Plan) error {
+	current := make(map[string]common.Address)
+	for i, step := range p.Steps {
+		if step.Method != "upgradeTo" && step.Method != "upgradeToAndCall" {
+			continue
+		}
+		if len(step.Args) == 0 {
+			return errors.Errorf("step %v: %v takes the new implementation", i+1, step.Method)
+		}
+		old, ok := current[step.Contract]
+		if !ok {
+			proxy, err := s.Manifest.Address(step.Contract)
+			if err != nil {
+				return errors.Wrapf(err, "step %v", i+1)
+			}
+			if old, err = s.Client.Implementation(ctx, proxy, nil); err != nil {
+				return errors.Wrapf(err, "step %v", i+1)
+			}
+			if old == (common.Address{}) {
+				return errors.Errorf("step %v: %v is not behind a proxy", i+1, step.Contract)
+			}
+		}
+		new, err := address(s, step.Args[0])
+		if err != nil {
+			return errors.Wrapf(err, "step %v", i+1)
+		}
+		oldName := step.Layout
+		if oldName == "" {
+			if oldName, err = e.artifactAt(ctx, s, old); err != nil {
+				return errors.Wrapf(err, "step %v: the current implementation of %v", i+1, step.Contract)
+			}
+		}
+		newName, err := e.artifactAt(ctx, s, new)
+		if err != nil {
+			return errors.Wrapf(err, "step %v: the new implementation of %v", i+1, step.Contract)
+		}
+		if err := checkLayout(e, s.Artifacts.Dir, oldName, newName); err != nil {
+			return errors.Wrapf(err, "step %v", i+1)
+		}
+		current[step.Contract] = new
+	}
+	return nil
+}
+
+// artifactAt returns the name of the local artifact whose runtime code is deployed at addr,
+// preferring an exact match over one that differs only in solc's metadata hash.
+func (e *env) artifactAt(ctx context.Context, s *session.Session, addr common.Address) (string, error) {
+	deployed, err := s.Client.CodeAt(ctx, addr, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading code at %v", addr.Hex())
+	}
+	if len(deployed) == 0 {
+		return "", errors.Errorf("there is no code at %v", addr.Hex())
+	}
+	files, err := ioutil.ReadDir(s.Artifacts.Dir)
+	if err != nil {
+		return "", errors.Wrap(err, "listing artifacts")
+	}
+	var partial []string
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), ".json")
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		artifact, err := s.Artifacts.Load(name)
+		if err != nil {
+			// Libraries and interfaces may have no artifact of their own name.
+			continue
+		}
+		switch bytecode.Compare(deployed, artifact.BinRuntime) {
+		case bytecode.Exact:
+			return name, nil
+		case bytecode.Partial:
+			partial = append(partial, name)
+		}
+	}
+	if len(partial) == 0 {
+		return "", errors.Errorf("no artifact in %v matches the code at %v", s.Artifacts.Dir, addr.Hex())
+	}
+	sort.Strings(partial)
+	return partial[0], nil
+}
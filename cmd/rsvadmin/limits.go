@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/audit"
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+// rsvDecimals is Reserve.decimals.
+const rsvDecimals = 18
+
+// limitWindow is the span over which daily limits are counted. It is rolling, not calendar-based,
+// so that an operator can't double up by straddling midnight.
+const limitWindow = 24 * time.Hour
+
+// limitsConfig holds client-side limits for one kind of supply change, in whole RSV.
+type limitsConfig struct {
+	MaxPerInvocation string `json:"maxPerInvocation"`
+	MaxPerDay        string `json:"maxPerDay"`
+}
+
+// limits is a parsed limitsConfig, in attoRSV.
+type limits struct {
+	perInvocation *big.Int
+	perDay        *big.Int
+}
+
+// parse checks that both limits are set. Unset limits are an error rather than "unlimited", so a
+// missing config section fails closed.
+func (c limitsConfig) parse(kind string) (limits, error) {
+	if c.MaxPerInvocation == "" || c.MaxPerDay == "" {
+		return limits{}, errors.Errorf("config: %v.maxPerInvocation and %v.maxPerDay must both be set", kind, kind)
+	}
+	perInvocation, err := units.Parse(c.MaxPerInvocation, rsvDecimals)
+	if err != nil {
+		return limits{}, errors.Wrapf(err, "config: %v.maxPerInvocation", kind)
+	}
+	perDay, err := units.Parse(c.MaxPerDay, rsvDecimals)
+	if err != nil {
+		return limits{}, errors.Wrapf(err, "config: %v.maxPerDay", kind)
+	}
+	return limits{perInvocation: perInvocation, perDay: perDay}, nil
+}
+
+// check returns an error if sending amount would exceed l, given the amounts already sent in
+// the current window according to the audit trail.
+func (l limits) check(amount, sentToday *big.Int) error {
+	if amount.Cmp(l.perInvocation) > 0 {
+		return errors.Errorf("%v RSV exceeds the per-invocation limit of %v RSV",
+			units.Format(amount, rsvDecimals), units.Format(l.perInvocation, rsvDecimals))
+	}
+	total := new(big.Int).Add(sentToday, amount)
+	if total.Cmp(l.perDay) > 0 {
+		return errors.Errorf("%v RSV would bring the last 24h total to %v RSV, over the daily limit of %v RSV",
+			units.Format(amount, rsvDecimals), units.Format(total, rsvDecimals), units.Format(l.perDay, rsvDecimals))
+	}
+	return nil
+}
+
+// sentInWindow sums the amounts of Reserve.`method` calls on chainID in the audit trail during
+// the limit window ending at now. The amount is the call's last argument. Transactions that are
+// known to have failed are not counted; transactions still in flight are.
+func sentInWindow(entries []audit.Entry, chainID uint64, method string, now time.Time) (*big.Int, error) {
+	total := new(big.Int)
+	for _, e := range audit.Latest(entries) {
+		if e.ChainID != chainID || e.Contract != "Reserve" || e.Method != method {
+			continue
+		}
+		if e.Status == audit.StatusFailed || now.Sub(e.Time) >= limitWindow {
+			continue
+		}
+		if len(e.Args) == 0 {
+			return nil, errors.Errorf("audit entry for %v has no arguments", e.TxHash)
+		}
+		amount, ok := new(big.Int).SetString(e.Args[len(e.Args)-1], 10)
+		if !ok {
+			return nil, errors.Errorf("audit entry for %v has unparseable amount %q", e.TxHash, e.Args[len(e.Args)-1])
+		}
+		total.Add(total, amount)
+	}
+	return total, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/audit"
+)
+
+func TestLimitsCheck(t *testing.T) {
+	lim, err := limitsConfig{MaxPerInvocation: "100", MaxPerDay: "250"}.parse("mint")
+	require.NoError(t, err)
+
+	rsv := func(n int64) *big.Int {
+		return new(big.Int).Mul(big.NewInt(n), new(big.Int).Exp(big.NewInt(10), big.NewInt(rsvDecimals), nil))
+	}
+
+	assert.NoError(t, lim.check(rsv(100), rsv(0)))
+	assert.Error(t, lim.check(new(big.Int).Add(rsv(100), big.NewInt(1)), rsv(0)))
+	assert.NoError(t, lim.check(rsv(50), rsv(200)))
+	assert.Error(t, lim.check(rsv(51), rsv(200)))
+}
+
+func TestLimitsMustBeConfigured(t *testing.T) {
+	_, err := limitsConfig{MaxPerInvocation: "100"}.parse("mint")
+	assert.Error(t, err)
+	_, err = limitsConfig{}.parse("burn")
+	assert.Error(t, err)
+}
+
+func TestSentInWindow(t *testing.T) {
+	now := time.Date(2020, 7, 20, 12, 0, 0, 0, time.UTC)
+	entry := func(hash, method, status string, age time.Duration, amount string) audit.Entry {
+		return audit.Entry{
+			Time:     now.Add(-age),
+			ChainID:  1,
+			Contract: "Reserve",
+			Method:   method,
+			Args:     []string{"0x0000000000000000000000000000000000000001", amount},
+			TxHash:   hash,
+			Status:   status,
+		}
+	}
+	entries := []audit.Entry{
+		entry("0x01", "mint", audit.StatusSubmitted, 2*time.Hour, "10"),
+		entry("0x01", "mint", audit.StatusConfirmed, time.Hour, "10"),
+		entry("0x02", "mint", audit.StatusSubmitted, time.Hour, "20"),    // still in flight: counts
+		entry("0x03", "mint", audit.StatusFailed, time.Hour, "40"),       // failed: doesn't count
+		entry("0x04", "mint", audit.StatusConfirmed, 25*time.Hour, "80"), // too old
+		entry("0x05", "burnFrom", audit.StatusConfirmed, time.Hour, "160"),
+	}
+	other := entry("0x06", "mint", audit.StatusConfirmed, time.Hour, "320")
+	other.ChainID = 3
+	entries = append(entries, other)
+
+	sent, err := sentInWindow(entries, 1, "mint", now)
+	require.NoError(t, err)
+	assert.Equal(t, "30", sent.String())
+
+	sent, err = sentInWindow(entries, 1, "burnFrom", now)
+	require.NoError(t, err)
+	assert.Equal(t, "160", sent.String())
+}
+
+func TestChecksummedAddress(t *testing.T) {
+	_, err := checksummedAddress("0x196f4727526eA7FB1e17b2071B3d8eAA38486988")
+	assert.NoError(t, err)
+
+	_, err = checksummedAddress("0x196f4727526ea7fb1e17b2071b3d8eaa38486988")
+	assert.Error(t, err, "lowercase addresses carry no checksum")
+
+	_, err = checksummedAddress("0x196f4727526eA7FB1e17b2071B3d8eAA38486989")
+	assert.Error(t, err, "wrong checksum")
+
+	_, err = checksummedAddress("0x0000000000000000000000000000000000000000")
+	assert.Error(t, err)
+}
@@ -0,0 +1,134 @@
+// Command rsvadmin performs privileged operations against a deployed RSV system.
+//
+// Usage:
+//
+//	rsvadmin [-config rsvadmin.json] <command> [flags]
+//
+// Run `rsvadmin help` for the list of commands.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/reserve-protocol/rsv-beta/ops/prompt"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+	"github.com/reserve-protocol/rsv-beta/ops/tenderly"
+)
+
+// config is the rsvadmin configuration file.
+type config struct {
+	session.Config
+
+	// Tenderly, if configured, simulates every transaction before it is signed.
+	Tenderly tenderly.Config `json:"tenderly,omitempty"`
+
+	Timelock timelockConfig `json:"timelock,omitempty"`
+
+	Emergency emergencyConfig `json:"emergency,omitempty"`
+
+	Mint limitsConfig `json:"mint"`
+	Burn limitsConfig `json:"burn"`
+}
+
+// command is an rsvadmin subcommand.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(ctx context.Context, e *env, args []string) error
+}
+
+var commands = map[string]*command{}
+
+func register(c *command) {
+	commands[c.name] = c
+}
+
+// env is what commands run with.
+type env struct {
+	configPath string
+	config     config
+	prompt     *prompt.Prompter
+	out        io.Writer
+}
+
+// open opens a session for the named command.
+func (e *env) open(ctx context.Context, name string) (*session.Session, error) {
+	s, err := session.Open(ctx, e.config.Config, "rsvadmin "+name)
+	if err != nil {
+		return nil, err
+	}
+	if s.Transactor != nil {
+		s.Transactor.Preflight = append(s.Transactor.Preflight, s.Interlock(e.prompt))
+	}
+	if s.Transactor != nil && e.config.Tenderly.Enabled() {
+		check, err := e.simulation(s)
+		if err != nil {
+			return nil, err
+		}
+		s.Transactor.Preflight = append(s.Transactor.Preflight, check)
+	}
+	return s, nil
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("rsvadmin: ")
+
+	configPath := flag.String("config", "rsvadmin.json", "configuration file")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 || flag.Arg(0) == "help" {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		log.Printf("unknown command %q", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	e := &env{
+		configPath: *configPath,
+		prompt:     prompt.New(os.Stdin, os.Stdout),
+		out:        os.Stdout,
+	}
+	if err := session.LoadConfig(*configPath, &e.config); err != nil {
+		log.Fatal(err)
+	}
+	if err := cmd.run(context.Background(), e, flag.Args()[1:]); err != nil {
+		log.Fatalf("%v: %v", cmd.name, err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: rsvadmin [-config file] <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-16v %v\n", name, commands[name].summary)
+	}
+}
+
+// flags returns a FlagSet for c that prints c's usage on error.
+func (c *command) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: rsvadmin %v %v\n\n%v\n\n", c.name, c.usage, c.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+func init() {
+	register(&command{
+		name:    "mint",
+		usage:   "-to <address> -amount <RSV>",
+		summary: "Mint RSV to an address, within the configured mint limits.",
+		run:     runMint,
+	})
+	register(&command{
+		name:    "burn",
+		usage:   "-from <address> -amount <RSV>",
+		summary: "Burn RSV from an address that has approved the minter, within the configured burn limits.",
+		run:     runBurn,
+	})
+}
+
+func runMint(ctx context.Context, e *env, args []string) error {
+	fs := commands["mint"].flags()
+	to := fs.String("to", "", "checksummed address to mint to")
+	amount := fs.String("amount", "", "amount to mint, in RSV")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return e.changeSupply(ctx, "mint", *to, *amount)
+}
+
+func runBurn(ctx context.Context, e *env, args []string) error {
+	fs := commands["burn"].flags()
+	from := fs.String("from", "", "checksummed address to burn from")
+	amount := fs.String("amount", "", "amount to burn, in RSV")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return e.changeSupply(ctx, "burn", *from, *amount)
+}
+
+// changeSupply carries out a mint or burn, after checking limits, the on-chain preconditions,
+// and the operator's confirmation of the counterparty address.
+func (e *env) changeSupply(ctx context.Context, kind, addressArg, amountArg string) error {
+	account, err := checksummedAddress(addressArg)
+	if err != nil {
+		return err
+	}
+	amount, err := units.Parse(amountArg, rsvDecimals)
+	if err != nil {
+		return err
+	}
+	if amount.Sign() == 0 {
+		return errors.New("amount must be positive")
+	}
+
+	limitsConfig, method := e.config.Mint, "mint"
+	if kind == "burn" {
+		limitsConfig, method = e.config.Burn, "burnFrom"
+	}
+	lim, err := limitsConfig.parse(kind)
+	if err != nil {
+		return err
+	}
+	if e.config.AuditLog == "" {
+		return errors.New("config: auditLog must be set, since daily limits are counted from it")
+	}
+
+	s, err := e.open(ctx, kind)
+	if err != nil {
+		return err
+	}
+	t, err := s.RequireTransactor()
+	if err != nil {
+		return err
+	}
+	reserve, err := s.Contract("Reserve")
+	if err != nil {
+		return err
+	}
+
+	// On-chain preconditions. These would all make the transaction revert anyway, but checking
+	// here gives the operator a clear reason.
+	minter, err := reserve.CallAddress(ctx, "minter")
+	if err != nil {
+		return err
+	}
+	if minter != t.From() {
+		return errors.Errorf("signer %v is not the Reserve minter (%v)", t.From().Hex(), minter.Hex())
+	}
+	paused, err := reserve.CallBool(ctx, "paused")
+	if err != nil {
+		return err
+	}
+	if paused {
+		return errors.New("Reserve is paused")
+	}
+	if kind == "burn" {
+		if err := checkBurnable(ctx, reserve, account, t.From(), amount); err != nil {
+			return err
+		}
+	}
+
+	entries, err := s.Audit.Entries()
+	if err != nil {
+		return err
+	}
+	sent, err := sentInWindow(entries, s.ChainID.Uint64(), method, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := lim.check(amount, sent); err != nil {
+		return err
+	}
+
+	direction := "to"
+	if kind == "burn" {
+		direction = "from"
+	}
+	fmt.Fprintf(e.out, "About to %v %v RSV %v %v on %v.\n",
+		kind, units.Format(amount, rsvDecimals), direction, e.namer(ctx, s).Label(ctx, account), s.Config.Network)
+	fmt.Fprintf(e.out, "Already sent in the last 24h: %v RSV of %v RSV allowed.\n",
+		units.Format(sent, rsvDecimals), units.Format(lim.perDay, rsvDecimals))
+	if err := e.prompt.Expect("Re-type the "+direction+" address to confirm:", account.Hex()); err != nil {
+		return err
+	}
+
+	receipt, err := t.SendAndWait(ctx, chain.Call{
+		Contract: reserve,
+		Method:   method,
+		Args:     []interface{}{account, amount},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Done: %v (gas used: %v).\n", receipt.TxHash.Hex(), receipt.GasUsed)
+	return nil
+}
+
+// checkBurnable checks that holder has at least amount RSV and has approved minter for it.
+func checkBurnable(ctx context.Context, reserve *chain.Contract, holder, minter common.Address, amount *big.Int) error {
+	balance, err := reserve.CallBig(ctx, "balanceOf", holder)
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(amount) < 0 {
+		return errors.Errorf("%v holds only %v RSV", holder.Hex(), units.Format(balance, rsvDecimals))
+	}
+	allowance, err := reserve.CallBig(ctx, "allowance", holder, minter)
+	if err != nil {
+		return err
+	}
+	if allowance.Cmp(amount) < 0 {
+		return errors.Errorf("%v has approved the minter for only %v RSV", holder.Hex(), units.Format(allowance, rsvDecimals))
+	}
+	return nil
+}
+
+// checksummedAddress parses s, which must be an EIP-55 checksummed address. Unchecksummed
+// (all-lowercase or all-uppercase) addresses are rejected: they carry no protection against typos.
+func checksummedAddress(s string) (common.Address, error) {
+	if !common.IsHexAddress(s) {
+		return common.Address{}, errors.Errorf("%q is not an address", s)
+	}
+	addr := common.HexToAddress(s)
+	if addr.Hex() != s {
+		return common.Address{}, errors.Errorf("%q is not EIP-55 checksummed", s)
+	}
+	if addr == (common.Address{}) {
+		return common.Address{}, errors.New("the zero address is not allowed")
+	}
+	return addr, nil
+}
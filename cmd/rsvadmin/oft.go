@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+	"github.com/reserve-protocol/rsv-beta/ops/signer"
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+func init() {
+	register(&command{
+		name:    "oft",
+		usage:   "remote|send [flags]",
+		summary: "Set the OFTAdapter's trusted remotes, and send RSV to another chain over LayerZero.",
+		run:     runOFT,
+	})
+}
+
+func runOFT(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		commands["oft"].flags().Usage()
+		return errors.New("missing oft subcommand")
+	}
+	switch args[0] {
+	case "remote":
+		return e.oftRemote(ctx, args[1:])
+	case "send":
+		return e.oftSend(ctx, args[1:])
+	}
+	return errors.Errorf("unknown oft subcommand %q", args[0])
+}
+
+// lzChainID parses a LayerZero chain ID, which is not the chain's EIP-155 ID.
+func lzChainID(s string) (uint16, error) {
+	id, err := strconv.ParseUint(s, 10, 16)
+	if err != nil || id == 0 {
+		return 0, errors.Errorf("-chain %q is not a LayerZero chain ID", s)
+	}
+	return uint16(id), nil
+}
+
+// trustedRemote returns the address of adapter's trusted remote on the chain with LayerZero ID
+// id, or the zero address if there is none.
+func trustedRemote(ctx context.Context, adapter *chain.Contract, id uint16) (common.Address, error) {
+	path, err := adapter.CallBytes(ctx, "trustedRemoteLookup", id)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(path) == 0 {
+		return common.Address{}, nil
+	}
+	if len(path) != 2*common.AddressLength || !bytes.Equal(path[common.AddressLength:], adapter.Address.Bytes()) {
+		return common.Address{}, errors.Errorf("trusted remote path 0x%x on chain %v is not an EVM adapter's", path, id)
+	}
+	return common.BytesToAddress(path[:common.AddressLength]), nil
+}
+
+func (e *env) oftRemote(ctx context.Context, args []string) error {
+	fs := commands["oft"].flags()
+	chainArg := fs.String("chain", "", "LayerZero chain ID of the remote chain")
+	remoteArg := fs.String("address", "", "checksummed address of the OFTAdapter there, or empty to trust none")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	id, err := lzChainID(*chainArg)
+	if err != nil {
+		return err
+	}
+	var remote common.Address
+	if *remoteArg != "" {
+		if remote, err = checksummedAddress(*remoteArg); err != nil {
+			return err
+		}
+	}
+
+	s, err := e.open(ctx, "oft remote")
+	if err != nil {
+		return err
+	}
+	t, err := s.RequireTransactor()
+	if err != nil {
+		return err
+	}
+	adapter, err := s.Contract("OFTAdapter")
+	if err != nil {
+		return err
+	}
+	owner, err := adapter.CallAddress(ctx, "owner")
+	if err != nil {
+		return err
+	}
+	if owner != t.From() {
+		return errors.Errorf("signer %v is not the OFTAdapter owner (%v)", t.From().Hex(), owner.Hex())
+	}
+	current, err := trustedRemote(ctx, adapter, id)
+	if err != nil {
+		return err
+	}
+	if current == remote {
+		fmt.Fprintf(e.out, "The trusted remote on LayerZero chain %v is already %v.\n", id, describeRemote(remote))
+		return nil
+	}
+
+	fmt.Fprintf(e.out, "About to change the trusted remote on LayerZero chain %v from %v to %v, on %v.\n",
+		id, describeRemote(current), describeRemote(remote), s.Config.Network)
+	var remoteBytes []byte
+	if remote != (common.Address{}) {
+		remoteBytes = remote.Bytes()
+		if err := e.prompt.Expect("Re-type the remote address to confirm:", remote.Hex()); err != nil {
+			return err
+		}
+	} else if err := e.prompt.Expect("Type the chain ID to confirm:", strconv.Itoa(int(id))); err != nil {
+		return err
+	}
+
+	receipt, err := t.SendAndWait(ctx, chain.Call{
+		Contract: adapter,
+		Method:   "setTrustedRemoteAddress",
+		Args:     []interface{}{id, remoteBytes},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Done: %v (gas used: %v).\n", receipt.TxHash.Hex(), receipt.GasUsed)
+	return nil
+}
+
+func describeRemote(remote common.Address) string {
+	if remote == (common.Address{}) {
+		return "none"
+	}
+	return remote.Hex()
+}
+
+func (e *env) oftSend(ctx context.Context, args []string) error {
+	fs := commands["oft"].flags()
+	chainArg := fs.String("chain", "", "LayerZero chain ID of the destination chain")
+	toArg := fs.String("to", "", "checksummed address to send to on the destination chain")
+	amountArg := fs.String("amount", "", "amount to send, in RSV")
+	await := fs.String("await", "", "rsvadmin config of the destination chain, to wait there for the transfer to arrive")
+	timeout := fs.Duration("timeout", 30*time.Minute, "how long to wait, with -await")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	id, err := lzChainID(*chainArg)
+	if err != nil {
+		return err
+	}
+	to, err := checksummedAddress(*toArg)
+	if err != nil {
+		return err
+	}
+	amount, err := units.Parse(*amountArg, rsvDecimals)
+	if err != nil {
+		return err
+	}
+	if amount.Sign() <= 0 {
+		return errors.New("amount must be positive")
+	}
+	// Open the destination first, so that a bad -await fails before anything is sent.
+	var dest *session.Session
+	var destAdapter *chain.Contract
+	if *await != "" {
+		if dest, destAdapter, err = e.openOFTDestination(ctx, *await); err != nil {
+			return errors.Wrap(err, "-await")
+		}
+	}
+
+	s, err := e.open(ctx, "oft send")
+	if err != nil {
+		return err
+	}
+	t, err := s.RequireTransactor()
+	if err != nil {
+		return err
+	}
+	reserve, err := s.Contract("Reserve")
+	if err != nil {
+		return err
+	}
+	adapter, err := s.Contract("OFTAdapter")
+	if err != nil {
+		return err
+	}
+	remote, err := trustedRemote(ctx, adapter, id)
+	if err != nil {
+		return err
+	}
+	if remote == (common.Address{}) {
+		return errors.Errorf("the OFTAdapter trusts no remote on LayerZero chain %v", id)
+	}
+	if dest != nil && destAdapter.Address != remote {
+		return errors.Errorf("the -await manifest's OFTAdapter is %v, but the trusted remote on chain %v is %v",
+			destAdapter.Address.Hex(), id, remote.Hex())
+	}
+
+	// On-chain preconditions.
+	from := t.From()
+	paused, err := reserve.CallBool(ctx, "paused")
+	if err != nil {
+		return err
+	}
+	if paused {
+		return errors.New("Reserve is paused")
+	}
+	balance, err := reserve.CallBig(ctx, "balanceOf", from)
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(amount) < 0 {
+		return errors.Errorf("signer %v holds only %v RSV", from.Hex(), units.Format(balance, rsvDecimals))
+	}
+	allowance, err := reserve.CallBig(ctx, "allowance", from, adapter.Address)
+	if err != nil {
+		return err
+	}
+	var fee struct {
+		NativeFee *big.Int
+		ZroFee    *big.Int
+	}
+	err = adapter.Call(&bind.CallOpts{Context: ctx}, &fee, "estimateSendFee", id, to.Bytes(), amount, false, []byte{})
+	if err != nil {
+		return errors.Wrap(err, "calling OFTAdapter.estimateSendFee")
+	}
+
+	fmt.Fprintf(e.out, "About to send %v RSV from %v on %v to %v on LayerZero chain %v, for a LayerZero fee of %v wei.\n",
+		units.Format(amount, rsvDecimals), from.Hex(), s.Config.Network, to.Hex(), id, fee.NativeFee)
+	if err := e.prompt.Expect("Re-type the to address to confirm:", to.Hex()); err != nil {
+		return err
+	}
+
+	// Find where the destination is now before sending, so that the wait can't miss the
+	// transfer arriving.
+	var start uint64
+	if dest != nil {
+		header, err := dest.Client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return errors.Wrap(err, "reading the destination's latest block")
+		}
+		start = header.Number.Uint64()
+	}
+	if allowance.Cmp(amount) < 0 {
+		receipt, err := t.SendAndWait(ctx, chain.Call{
+			Contract: reserve,
+			Method:   "approve",
+			Args:     []interface{}{adapter.Address, amount},
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(e.out, "Approved the OFTAdapter: %v.\n", receipt.TxHash.Hex())
+	}
+	receipt, err := t.SendAndWait(ctx, chain.Call{
+		Contract: adapter,
+		Method:   "sendFrom",
+		Args:     []interface{}{from, id, to.Bytes(), amount, from, common.Address{}, []byte{}},
+		Value:    fee.NativeFee,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Sent: %v (gas used: %v).\n", receipt.TxHash.Hex(), receipt.GasUsed)
+	if dest == nil {
+		return nil
+	}
+
+	fmt.Fprintf(e.out, "Waiting up to %v for it to arrive...\n", *timeout)
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	arrival, err := awaitReceive(ctx, dest.Client, destAdapter, start, to, amount)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Arrived: %v.\n", arrival.Hex())
+	return nil
+}
+
+// openOFTDestination opens the rsvadmin config at path, without its signer, and returns it
+// with its OFTAdapter.
+func (e *env) openOFTDestination(ctx context.Context, path string) (*session.Session, *chain.Contract, error) {
+	var c config
+	if err := session.LoadConfig(path, &c); err != nil {
+		return nil, nil, err
+	}
+	c.Signer, c.AuditLog = signer.Config{}, ""
+	s, err := session.Open(ctx, c.Config, "rsvadmin oft send")
+	if err != nil {
+		return nil, nil, err
+	}
+	adapter, err := s.Contract("OFTAdapter")
+	return s, adapter, err
+}
+
+// oftPollInterval is how often awaitReceive looks for the transfer.
+var oftPollInterval = 15 * time.Second
+
+// awaitReceive waits for dest to credit amount attotokens to to, from block start on, and
+// returns the hash of the transaction that did.
+func awaitReceive(
+	ctx context.Context, client *chain.Client, dest *chain.Contract, start uint64, to common.Address, amount *big.Int,
+) (common.Hash, error) {
+	event := dest.ABI.Events["ReceiveFromChain"]
+	query := ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(start),
+		Addresses: []common.Address{dest.Address},
+		Topics:    [][]common.Hash{{event.Id()}, nil, {common.BytesToHash(to.Bytes())}},
+	}
+	for {
+		logs, err := client.FilterLogs(ctx, query)
+		if err != nil && ctx.Err() == nil {
+			return common.Hash{}, errors.Wrap(err, "reading the destination's logs")
+		}
+		for _, l := range logs {
+			received := new(big.Int)
+			if err := dest.ABI.Unpack(&received, "ReceiveFromChain", l.Data); err != nil {
+				return common.Hash{}, errors.Wrap(err, "decoding ReceiveFromChain")
+			}
+			if !l.Removed && received.Cmp(amount) == 0 {
+				return l.TxHash, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return common.Hash{}, errors.New("timed out; the transfer may still arrive, or may have failed there " +
+				"(look for MessageFailed, and retryMessage it)")
+		case <-time.After(oftPollInterval):
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+func init() {
+	register(&command{
+		name:    "operators",
+		usage:   "list | add -account <address> | remove -account <address>",
+		summary: "List, add, and remove the Manager's operators.",
+		run:     runOperators,
+	})
+}
+
+func runOperators(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		commands["operators"].flags().Usage()
+		return errors.New("missing operators subcommand")
+	}
+	switch args[0] {
+	case "list":
+		return e.operatorsList(ctx, args[1:])
+	case "add":
+		return e.operatorsChange(ctx, "add", args[1:])
+	case "remove":
+		return e.operatorsChange(ctx, "remove", args[1:])
+	}
+	return errors.Errorf("unknown operators subcommand %q", args[0])
+}
+
+// managerOperators returns the Manager's operators.
+func managerOperators(ctx context.Context, manager *chain.Contract) ([]common.Address, error) {
+	n, err := manager.CallBig(ctx, "operatorsLength")
+	if err != nil {
+		return nil, err
+	}
+	operators := make([]common.Address, n.Int64())
+	for i := range operators {
+		if operators[i], err = manager.CallAddress(ctx, "operators", big.NewInt(int64(i))); err != nil {
+			return nil, err
+		}
+	}
+	return operators, nil
+}
+
+// managerOwner returns the session's transactor and the Manager, which the transactor must own.
+func managerOwner(ctx context.Context, s *session.Session) (*chain.Transactor, *chain.Contract, error) {
+	t, err := s.RequireTransactor()
+	if err != nil {
+		return nil, nil, err
+	}
+	manager, err := s.Contract("Manager")
+	if err != nil {
+		return nil, nil, err
+	}
+	owner, err := manager.CallAddress(ctx, "owner")
+	if err != nil {
+		return nil, nil, err
+	}
+	if owner != t.From() {
+		return nil, nil, errors.Errorf("signer %v is not the Manager owner (%v)", t.From().Hex(), owner.Hex())
+	}
+	return t, manager, nil
+}
+
+func (e *env) operatorsList(ctx context.Context, args []string) error {
+	fs := commands["operators"].flags()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := e.open(ctx, "operators list")
+	if err != nil {
+		return err
+	}
+	manager, err := s.Contract("Manager")
+	if err != nil {
+		return err
+	}
+	operators, err := managerOperators(ctx, manager)
+	if err != nil {
+		return err
+	}
+	namer := e.namer(ctx, s)
+	for _, operator := range operators {
+		fmt.Fprintf(e.out, "  %v\n", namer.Label(ctx, operator))
+	}
+	fmt.Fprintf(e.out, "%v operators on %v.\n", len(operators), s.Config.Network)
+	return nil
+}
+
+// operatorsChange adds or removes, as verb says, the operator that -account names.
+func (e *env) operatorsChange(ctx context.Context, verb string, args []string) error {
+	fs := commands["operators"].flags()
+	accountArg := fs.String("account", "", "checksummed address of the operator")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	account, err := checksummedAddress(*accountArg)
+	if err != nil {
+		return errors.Wrap(err, "-account")
+	}
+
+	s, err := e.open(ctx, "operators "+verb)
+	if err != nil {
+		return err
+	}
+	t, manager, err := managerOwner(ctx, s)
+	if err != nil {
+		return err
+	}
+	operators, err := managerOperators(ctx, manager)
+	if err != nil {
+		return err
+	}
+	isOperator := false
+	for _, operator := range operators {
+		isOperator = isOperator || operator == account
+	}
+	method := "addOperator"
+	switch {
+	case verb == "add" && isOperator:
+		fmt.Fprintf(e.out, "%v is already an operator.\n", account.Hex())
+		return nil
+	case verb == "remove" && !isOperator:
+		fmt.Fprintf(e.out, "%v is not an operator.\n", account.Hex())
+		return nil
+	case verb == "remove" && len(operators) == 1:
+		// Add the replacement first, so that there is never no one to operate the Manager.
+		return errors.Errorf("%v is the only operator; add another before removing it", account.Hex())
+	case verb == "remove":
+		method = "removeOperator"
+	}
+
+	fmt.Fprintf(e.out, "About to %v %v as an operator of the Manager on %v, which has %v.\n",
+		verb, e.namer(ctx, s).Label(ctx, account), s.Config.Network, len(operators))
+	if err := e.prompt.Expect("Re-type the operator address to confirm:", account.Hex()); err != nil {
+		return err
+	}
+	receipt, err := t.SendAndWait(ctx, chain.Call{Contract: manager, Method: method, Args: []interface{}{account}})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Done: %v (gas used: %v).\n", receipt.TxHash.Hex(), receipt.GasUsed)
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/params"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+func init() {
+	register(&command{
+		name:    "params",
+		usage:   "-file <desired.json> [-dry-run]",
+		summary: "Bring admin parameters to the values in a file, with as few transactions as possible.",
+		run:     runParams,
+	})
+}
+
+// paramReader reads parameters from the contracts of a session.
+type paramReader struct {
+	s         *session.Session
+	contracts map[string]*chain.Contract
+}
+
+func (r *paramReader) contract(name string) (*chain.Contract, error) {
+	if c, ok := r.contracts[name]; ok {
+		return c, nil
+	}
+	c, err := r.s.Contract(name)
+	if err != nil {
+		return nil, err
+	}
+	r.contracts[name] = c
+	return c, nil
+}
+
+func (r *paramReader) Read(ctx context.Context, contract, getter, kind string, args ...interface{}) (string, error) {
+	c, err := r.contract(contract)
+	if err != nil {
+		return "", err
+	}
+	switch kind {
+	case params.Address:
+		v, err := c.CallAddress(ctx, getter, args...)
+		return v.Hex(), err
+	case params.Uint:
+		v, err := c.CallBig(ctx, getter, args...)
+		return v.String(), err
+	}
+	v, err := c.CallBool(ctx, getter, args...)
+	return fmt.Sprint(v), err
+}
+
+func runParams(ctx context.Context, e *env, args []string) error {
+	fs := commands["params"].flags()
+	file := fs.String("file", "", "desired-parameters file")
+	dryRun := fs.Bool("dry-run", false, "print the plan without sending anything")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+	desired, err := params.LoadDesired(*file)
+	if err != nil {
+		return err
+	}
+	s, err := e.open(ctx, "params")
+	if err != nil {
+		return err
+	}
+	tx, err := s.RequireTransactor()
+	if err != nil {
+		return err
+	}
+	r := &paramReader{s: s, contracts: make(map[string]*chain.Contract)}
+	changes, err := params.Plan(ctx, r, s.Manifest, desired, tx.From())
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(e.out, "Every parameter in %v already has its desired value on %v.\n", *file, s.Config.Network)
+		return nil
+	}
+
+	namer := e.namer(ctx, s)
+	label := func(kind, v string) string {
+		if kind == params.Address {
+			return namer.Label(ctx, common.HexToAddress(v))
+		}
+		return v
+	}
+	fmt.Fprintf(e.out, "Plan for %v, sending from %v:\n", s.Config.Network, tx.From().Hex())
+	unauthorized := 0
+	for i, c := range changes {
+		note := ""
+		if !c.Authorized {
+			note = "  NOT AUTHORIZED: needs " + fmt.Sprint(c.Roles)
+			unauthorized++
+		}
+		fmt.Fprintf(e.out, "  %v. %v.%v(%v)%v\n", i+1, c.Contract, c.Setter, label(c.Kind, c.Desired), note)
+		fmt.Fprintf(e.out, "       %v: %v -> %v\n", c.Name, label(c.Kind, c.Current), label(c.Kind, c.Desired))
+	}
+	if unauthorized > 0 {
+		return errors.Errorf("the signer may not make %v of these changes", unauthorized)
+	}
+	if *dryRun {
+		return nil
+	}
+	if err := e.prompt.Confirm(fmt.Sprintf("Send these %v transactions?", len(changes))); err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		contract, err := r.contract(c.Contract)
+		if err != nil {
+			return err
+		}
+		receipt, err := tx.SendAndWait(ctx, chain.Call{Contract: contract, Method: c.Setter, Args: []interface{}{c.Arg()}})
+		if err != nil {
+			return errors.Wrapf(err, "setting %v.%v", c.Contract, c.Name)
+		}
+		fmt.Fprintf(e.out, "Set %v.%v in %v.\n", c.Contract, c.Name, receipt.TxHash.Hex())
+	}
+
+	// Check that we converged, in case something else changed meanwhile.
+	remaining, err := params.Plan(ctx, r, s.Manifest, desired, tx.From())
+	if err != nil {
+		return err
+	}
+	if len(remaining) > 0 {
+		return errors.Errorf("%v parameters still differ from %v after the changes", len(remaining), *file)
+	}
+	fmt.Fprintf(e.out, "All parameters in %v now have their desired values.\n", *file)
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/params"
+	"github.com/reserve-protocol/rsv-beta/ops/rotate"
+)
+
+func init() {
+	register(&command{
+		name:    "rotate-role",
+		usage:   "-role minter|pauser|freezer|vetoer -to <address> [-from <address>]",
+		summary: "Move a role to a new key, verifying the grant and the revocation, and rolling back on failure.",
+		run:     runRotateRole,
+	})
+}
+
+// roleChain is the rotate.Chain of a session.
+type roleChain struct {
+	*paramReader
+	tx *chain.Transactor
+}
+
+func (c *roleChain) Holder(ctx context.Context, contract, getter string) (common.Address, error) {
+	k, err := c.contract(contract)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return k.CallAddress(ctx, getter)
+}
+
+func (c *roleChain) Set(ctx context.Context, p *params.Param, addr common.Address) (common.Hash, error) {
+	k, err := c.contract(p.Contract)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	receipt, err := c.tx.SendAndWait(ctx, chain.Call{Contract: k, Method: p.Setter, Args: []interface{}{addr}})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return receipt.TxHash, nil
+}
+
+func runRotateRole(ctx context.Context, e *env, args []string) error {
+	fs := commands["rotate-role"].flags()
+	role := fs.String("role", "", "role to rotate")
+	to := fs.String("to", "", "new key")
+	from := fs.String("from", "", "key expected to hold the role now (default: whoever does)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	p, err := rotate.Lookup(*role)
+	if err != nil {
+		return err
+	}
+	newKey, err := checksummedAddress(*to)
+	if err != nil {
+		return errors.Wrap(err, "-to")
+	}
+
+	s, err := e.open(ctx, "rotate-role")
+	if err != nil {
+		return err
+	}
+	tx, err := s.RequireTransactor()
+	if err != nil {
+		return err
+	}
+	c := &roleChain{paramReader: &paramReader{s: s, contracts: make(map[string]*chain.Contract)}, tx: tx}
+	k, err := c.contract(p.Contract)
+	if err != nil {
+		return err
+	}
+	if _, ok := k.ABI.Methods[p.Name]; !ok {
+		return errors.Errorf("the deployed %v has no %v role", p.Contract, p.Name)
+	}
+	oldKey, err := c.Holder(ctx, p.Contract, p.Name)
+	if err != nil {
+		return err
+	}
+	if *from != "" {
+		expected, err := checksummedAddress(*from)
+		if err != nil {
+			return errors.Wrap(err, "-from")
+		}
+		if expected != oldKey {
+			return errors.Errorf("%v.%v is held by %v, not %v", p.Contract, p.Name, oldKey.Hex(), expected.Hex())
+		}
+	}
+
+	namer := e.namer(ctx, s)
+	fmt.Fprintf(e.out, "Rotating %v.%v on %v, sending from %v:\n", p.Contract, p.Name, s.Config.Network, namer.Label(ctx, tx.From()))
+	fmt.Fprintf(e.out, "  from %v\n  to   %v\n", namer.Label(ctx, oldKey), namer.Label(ctx, newKey))
+	if err := e.prompt.Confirm("Rotate?"); err != nil {
+		return err
+	}
+	r := &rotate.Rotation{Chain: c, Param: p, Signer: tx.From(), Log: e.out}
+	if err := r.Rotate(ctx, oldKey, newKey); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "%v.%v is now held by %v.\n", p.Contract, p.Name, newKey.Hex())
+	return nil
+}
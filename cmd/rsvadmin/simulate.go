@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+	"github.com/reserve-protocol/rsv-beta/ops/tenderly"
+)
+
+// simulation returns a Preflight that runs each transaction through Tenderly, shows the
+// operator the events it would emit and the state it would change, and asks them to go ahead.
+// A transaction that fails in simulation is never sent.
+func (e *env) simulation(s *session.Session) (chain.Preflight, error) {
+	client, err := tenderly.New(e.config.Tenderly)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[common.Address]*chain.Artifact)
+	for name, addr := range s.Manifest.Contracts {
+		artifact, err := s.Artifacts.Load(name)
+		if err != nil {
+			return nil, err
+		}
+		known[addr] = artifact
+	}
+	return func(ctx context.Context, tx *chain.PendingTx) error {
+		fmt.Fprintf(e.out, "Simulating %v on Tenderly...\n", tx.Call)
+		result, err := client.Simulate(ctx, s.ChainID.Uint64(), tx)
+		if err != nil {
+			return err
+		}
+		printSimulation(e.out, result, known)
+		fmt.Fprintf(e.out, "Details: %v\n", client.DashboardURL(result))
+		if !result.Success {
+			return errors.Errorf("transaction fails in simulation: %v", result.Error)
+		}
+		return e.prompt.Confirm("Simulation succeeded. Broadcast the transaction?")
+	}, nil
+}
+
+// printSimulation describes result, decoding events from contracts in known.
+func printSimulation(out io.Writer, result *tenderly.Result, known map[common.Address]*chain.Artifact) {
+	label := func(addr common.Address) string {
+		if a, ok := known[addr]; ok {
+			return a.Name
+		}
+		return addr.Hex()
+	}
+
+	if result.Success {
+		fmt.Fprintf(out, "Simulation: success, %v gas.\n", result.GasUsed)
+	} else {
+		fmt.Fprintf(out, "Simulation: FAILED: %v\n", result.Error)
+	}
+
+	fmt.Fprintf(out, "Events (%v):\n", len(result.Logs))
+	for _, l := range result.Logs {
+		artifact, ok := known[l.Address]
+		if !ok {
+			fmt.Fprintf(out, "  %v: undecoded log, topics %v\n", l.Address.Hex(), l.Topics)
+			continue
+		}
+		event, err := artifact.DecodeLog(l.Topics, l.Data)
+		if err != nil {
+			fmt.Fprintf(out, "  %v: %v\n", artifact.Name, err)
+			continue
+		}
+		fmt.Fprintf(out, "  %v.%v\n", artifact.Name, event)
+	}
+
+	fmt.Fprintf(out, "State changes (%v):\n", len(result.StateChanges))
+	for _, c := range result.StateChanges {
+		variable := c.Name
+		if variable == "" {
+			variable = "slot " + c.Slot
+		}
+		fmt.Fprintf(out, "  %v.%v: %v -> %v\n", label(c.Address), variable, c.Original, c.Dirty)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/holders"
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+// balancesPerBatch is how many balanceOf calls an archive snapshot sends per batch request.
+const balancesPerBatch = 500
+
+func init() {
+	register(&command{
+		name:    "snapshot",
+		usage:   "-out <file.json> [-block <block>] [-source events|archive] [-from <block>] [-also <address,...>]",
+		summary: "Export every RSV balance as of one block, with a Merkle root over them.",
+		run:     runSnapshot,
+	})
+}
+
+func runSnapshot(ctx context.Context, e *env, args []string) error {
+	fs := commands["snapshot"].flags()
+	out := fs.String("out", "", "output file")
+	block := fs.Uint64("block", 0, "block to snapshot (default: latest)")
+	source := fs.String("source", "events", "events, to take balances from replayed Transfer events, or archive, to read balanceOf at -block (needs an archive node)")
+	from := fs.Uint64("from", 0, "first block to scan; the Reserve's deployment block is enough")
+	also := fs.String("also", "", "comma-separated earlier Reserve implementations that shared its eternal storage")
+	chunk := fs.Uint64("chunk", 10000, "blocks per log query")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("-out is required")
+	}
+	if *source != "events" && *source != "archive" {
+		return errors.Errorf("-source must be events or archive, not %q", *source)
+	}
+
+	s, err := e.open(ctx, "snapshot")
+	if err != nil {
+		return err
+	}
+	reserve, err := s.Contract("Reserve")
+	if err != nil {
+		return err
+	}
+	contracts := []common.Address{reserve.Address}
+	if *also != "" {
+		for _, a := range strings.Split(*also, ",") {
+			addr, err := checksummedAddress(strings.TrimSpace(a))
+			if err != nil {
+				return errors.Wrap(err, "-also")
+			}
+			contracts = append(contracts, addr)
+		}
+	}
+	var number *big.Int
+	if *block != 0 {
+		number = new(big.Int).SetUint64(*block)
+	}
+	header, err := s.Client.HeaderByNumber(ctx, number)
+	if err != nil {
+		return errors.Wrap(err, "reading block header")
+	}
+	*block = header.Number.Uint64()
+
+	// Either way, the events say who has ever held RSV.
+	state := holders.NewState()
+	fmt.Fprintf(e.out, "Scanning blocks %v-%v of %v ...\n", *from, *block, s.Config.Network)
+	err = state.Scan(ctx, s.Client, contracts, *from, *block, *chunk, func(st *holders.State) {
+		fmt.Fprintf(e.out, "\r  block %v: %v transfers", st.Block, st.Transfers)
+	})
+	fmt.Fprintln(e.out)
+	if err != nil {
+		return err
+	}
+
+	list := state.Holders()
+	if *source == "archive" {
+		addresses := state.Addresses()
+		fmt.Fprintf(e.out, "Reading the balances of %v addresses at block %v ...\n", len(addresses), *block)
+		balances, err := readBalances(ctx, s.Client, reserve, header.Number, addresses)
+		if err != nil {
+			return err
+		}
+		list = make([]holders.Holder, len(addresses))
+		for i := range addresses {
+			list[i] = holders.Holder{Address: addresses[i], Balance: balances[i]}
+		}
+	}
+
+	snap := &holders.Snapshot{
+		Network:   s.Config.Network,
+		ChainID:   s.Config.ChainID,
+		Token:     reserve.Address,
+		Block:     *block,
+		BlockHash: header.Hash(),
+		Source:    *source,
+	}
+	snap.SetHolders(list)
+
+	// A snapshot that doesn't add up to totalSupply() is missing someone.
+	supply, err := reserve.At(header.Number).CallBig(ctx, "totalSupply")
+	if err != nil {
+		return err
+	}
+	if snap.Supply != supply.String() {
+		sum, _ := new(big.Int).SetString(snap.Supply, 10)
+		return errors.Errorf("balances sum to %v RSV, but totalSupply() at block %v is %v RSV; were -from or -also wrong?",
+			units.Format(sum, rsvDecimals), *block, units.Format(supply, rsvDecimals))
+	}
+
+	if err := writeFile(*out, snap.Write); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Wrote %v holders as of block %v (%v) to %v; total supply %v RSV.\n",
+		len(snap.Holders), snap.Block, snap.BlockHash.Hex(), *out, units.Format(supply, rsvDecimals))
+	fmt.Fprintf(e.out, "Merkle root: %v\n", snap.MerkleRoot.Hex())
+	return nil
+}
+
+// readBalances reads the balanceOf of each of addresses at block, in batches.
+func readBalances(ctx context.Context, client *chain.Client, reserve *chain.Contract, block *big.Int, addresses []common.Address) ([]*big.Int, error) {
+	balances := make([]*big.Int, len(addresses))
+	for start := 0; start < len(addresses); start += balancesPerBatch {
+		batch := client.NewBatch(block)
+		for i := start; i < len(addresses) && i < start+balancesPerBatch; i++ {
+			balances[i] = new(big.Int)
+			if err := batch.Add(reserve, balances[i], "balanceOf", addresses[i]); err != nil {
+				return nil, err
+			}
+		}
+		if err := batch.Do(ctx); err != nil {
+			return nil, errors.Wrapf(err, "reading balances at block %v", block)
+		}
+	}
+	return balances, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/manifest"
+	"github.com/reserve-protocol/rsv-beta/ops/subgraph"
+)
+
+func init() {
+	register(&command{
+		name:    "subgraph",
+		usage:   "-out <dir> [-contracts Reserve,Manager,Vault] [-start-block <block>] [-check]",
+		summary: "Generate a subgraph for The Graph indexing the deployment's events.",
+		run:     runSubgraph,
+	})
+}
+
+func runSubgraph(ctx context.Context, e *env, args []string) error {
+	fs := commands["subgraph"].flags()
+	out := fs.String("out", "", "subgraph directory")
+	names := fs.String("contracts", "Reserve,Manager,Vault", "comma-separated manifest contracts to index")
+	startBlock := fs.Uint64("start-block", 0, "first block to index; the earliest deployment block is enough")
+	check := fs.Bool("check", false, "write nothing, and fail if the subgraph in -out is out of date")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("-out is required")
+	}
+
+	// Everything comes from the manifest and artifacts, so this runs without a node.
+	m, err := manifest.Load(e.config.Manifest)
+	if err != nil {
+		return err
+	}
+	artifacts := chain.NewArtifacts(e.config.Artifacts)
+	var sources []subgraph.Source
+	for _, name := range strings.Split(*names, ",") {
+		name = strings.TrimSpace(name)
+		addr, err := m.Address(name)
+		if err != nil {
+			return err
+		}
+		artifact, err := artifacts.Load(name)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, subgraph.Source{Name: name, Address: addr, StartBlock: *startBlock, Artifact: artifact})
+	}
+	files, err := subgraph.Generate(m.Network, sources)
+	if err != nil {
+		return err
+	}
+
+	if *check {
+		stale, err := files.Stale(*out)
+		if err != nil {
+			return err
+		}
+		if len(stale) > 0 {
+			for _, path := range stale {
+				fmt.Fprintln(e.out, "STALE:", path)
+			}
+			return errors.Errorf("the subgraph in %v does not match the %v manifest and ABIs; rerun without -check", *out, m.Network)
+		}
+		fmt.Fprintf(e.out, "The subgraph in %v is up to date.\n", *out)
+		return nil
+	}
+	if err := files.Write(*out); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Wrote a subgraph of %v for %v to %v. Build it with `graph codegen && graph build` there.\n",
+		*names, m.Network, *out)
+	return nil
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+	"github.com/reserve-protocol/rsv-beta/ops/timelock"
+)
+
+// timelockConfig configures the timelock commands.
+type timelockConfig struct {
+	// Preimages is the file that records every operation we queue.
+	Preimages string `json:"preimages"`
+}
+
+func init() {
+	register(&command{
+		name:    "timelock",
+		usage:   "queue|list|execute|cancel [flags]",
+		summary: "Queue, list, execute, and cancel admin operations behind the Timelock.",
+		run:     runTimelock,
+	})
+}
+
+func runTimelock(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		commands["timelock"].flags().Usage()
+		return errors.New("missing timelock subcommand")
+	}
+	if e.config.Timelock.Preimages == "" {
+		return errors.New("config: timelock.preimages is not set")
+	}
+	switch args[0] {
+	case "queue":
+		return e.timelockQueue(ctx, args[1:])
+	case "list":
+		return e.timelockList(ctx, args[1:])
+	case "execute":
+		return e.timelockFinish(ctx, "execute", args[1:])
+	case "cancel":
+		return e.timelockFinish(ctx, "cancel", args[1:])
+	}
+	return errors.Errorf("unknown timelock subcommand %q", args[0])
+}
+
+// timelockSession is an open session together with the Timelock and the preimage store.
+type timelockSession struct {
+	*session.Session
+	timelock *chain.Contract
+	store    *timelock.Store
+}
+
+func (e *env) openTimelock(ctx context.Context, name string) (*timelockSession, error) {
+	s, err := e.open(ctx, "timelock "+name)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := s.Manifest.Address("Timelock")
+	if err != nil {
+		return nil, err
+	}
+	store, err := timelock.OpenStore(e.config.Timelock.Preimages)
+	if err != nil {
+		return nil, err
+	}
+	return &timelockSession{
+		Session:  s,
+		timelock: timelock.Artifact().Bind(addr, s.Client),
+		store:    store,
+	}, nil
+}
+
+// requireAdmin checks that the signer is the Timelock's admin.
+func (t *timelockSession) requireAdmin(ctx context.Context) (*chain.Transactor, error) {
+	tx, err := t.RequireTransactor()
+	if err != nil {
+		return nil, err
+	}
+	admin, err := t.timelock.CallAddress(ctx, "admin")
+	if err != nil {
+		return nil, err
+	}
+	if admin != tx.From() {
+		return nil, errors.Errorf("signer %v is not the Timelock admin (%v)", tx.From().Hex(), admin.Hex())
+	}
+	return tx, nil
+}
+
+// now is the timestamp of the latest block, which is what the Timelock compares ETAs against.
+func (t *timelockSession) now(ctx context.Context) (uint64, error) {
+	header, err := t.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "reading latest block")
+	}
+	return header.Time, nil
+}
+
+func (e *env) timelockQueue(ctx context.Context, args []string) error {
+	fs := commands["timelock"].flags()
+	contractName := fs.String("contract", "", "manifest name of the target contract")
+	methodName := fs.String("method", "", "method to call, by name or full signature")
+	value := fs.String("value", "0", "wei to send with the call")
+	margin := fs.Duration("margin", 15*time.Minute, "time beyond the Timelock's delay to set the ETA")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *contractName == "" || *methodName == "" {
+		return errors.New("-contract and -method are required")
+	}
+	wei, ok := new(big.Int).SetString(*value, 10)
+	if !ok || wei.Sign() < 0 {
+		return errors.Errorf("-value %q is not a non-negative integer", *value)
+	}
+
+	t, err := e.openTimelock(ctx, "queue")
+	if err != nil {
+		return err
+	}
+	tx, err := t.requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	target, err := t.Contract(*contractName)
+	if err != nil {
+		return err
+	}
+	artifact, err := t.Artifacts.Load(*contractName)
+	if err != nil {
+		return err
+	}
+	method, err := artifact.FindMethod(*methodName)
+	if err != nil {
+		return err
+	}
+	callArgs, err := chain.ParseArgs(method, fs.Args())
+	if err != nil {
+		return err
+	}
+	data, err := method.Inputs.Pack(callArgs...)
+	if err != nil {
+		return errors.Wrapf(err, "packing %v", method.Sig())
+	}
+
+	delay, err := t.timelock.CallBig(ctx, "delay")
+	if err != nil {
+		return err
+	}
+	now, err := t.now(ctx)
+	if err != nil {
+		return err
+	}
+	op := &timelock.Operation{
+		Target:      target.Address,
+		Value:       wei,
+		Signature:   method.Sig(),
+		Data:        data,
+		ETA:         now + delay.Uint64() + uint64(margin.Seconds()),
+		Description: fmt.Sprintf("%v.%v%v", *contractName, method.Name, chain.FormatArgs(callArgs)),
+	}
+	hash := op.Hash()
+
+	fmt.Fprintf(e.out, "About to queue on %v:\n", t.Config.Network)
+	fmt.Fprintf(e.out, "  call:  %v\n", op.Description)
+	fmt.Fprintf(e.out, "  value: %v wei\n", op.Value)
+	fmt.Fprintf(e.out, "  eta:   %v\n", formatTime(op.ETA))
+	fmt.Fprintf(e.out, "  hash:  %v\n", hash.Hex())
+	if err := e.prompt.Confirm("Queue this operation?"); err != nil {
+		return err
+	}
+
+	// Record the preimage before queueing, so that it can't be lost if anything below fails.
+	if err := t.store.Put(op); err != nil {
+		return err
+	}
+	receipt, err := tx.SendAndWait(ctx, chain.Call{Contract: t.timelock, Method: "queueTransaction", Args: op.Args()})
+	if err != nil {
+		return err
+	}
+	op.QueueTx = receipt.TxHash.Hex()
+	if err := t.store.Put(op); err != nil {
+		return err
+	}
+	queued, err := t.timelock.CallBool(ctx, "queuedTransactions", hash)
+	if err != nil {
+		return err
+	}
+	if !queued {
+		return errors.Errorf("transaction %v confirmed, but the Timelock does not list %v as queued", op.QueueTx, hash.Hex())
+	}
+	fmt.Fprintf(e.out, "Queued %v in %v; executable from %v.\n", hash.Hex(), op.QueueTx, formatTime(op.ETA))
+	return nil
+}
+
+func (e *env) timelockList(ctx context.Context, args []string) error {
+	fs := commands["timelock"].flags()
+	all := fs.Bool("all", false, "include executed and cancelled operations")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	t, err := e.openTimelock(ctx, "list")
+	if err != nil {
+		return err
+	}
+	grace, err := t.timelock.CallBig(ctx, "GRACE_PERIOD")
+	if err != nil {
+		return err
+	}
+	now, err := t.now(ctx)
+	if err != nil {
+		return err
+	}
+	for _, op := range t.store.All() {
+		if op.Outcome != "" && !*all {
+			continue
+		}
+		status, err := t.status(ctx, op, now, grace.Uint64())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(e.out, "%v  %-22v eta %v\n    %v\n", op.Hash().Hex(), status, formatTime(op.ETA), op.Description)
+	}
+	return nil
+}
+
+// status describes where op stands, as of block time now.
+func (t *timelockSession) status(ctx context.Context, op *timelock.Operation, now, grace uint64) (string, error) {
+	if op.Outcome != "" {
+		return op.Outcome, nil
+	}
+	queued, err := t.timelock.CallBool(ctx, "queuedTransactions", op.Hash())
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case !queued && op.QueueTx == "":
+		return "never queued", nil
+	case !queued:
+		return "gone (done elsewhere)", nil
+	case now < op.ETA:
+		return "pending (" + (time.Duration(op.ETA-now) * time.Second).String() + ")", nil
+	case now <= op.ETA+grace:
+		return "ready", nil
+	default:
+		return "stale", nil
+	}
+}
+
+// timelockFinish executes or cancels a queued operation, identified by its hash.
+func (e *env) timelockFinish(ctx context.Context, action string, args []string) error {
+	fs := commands["timelock"].flags()
+	hashArg := fs.String("hash", "", "hash of the queued operation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*hashArg) != 66 {
+		return errors.New("-hash must be a 32-byte 0x-prefixed hash")
+	}
+	hash := common.HexToHash(*hashArg)
+
+	t, err := e.openTimelock(ctx, action)
+	if err != nil {
+		return err
+	}
+	tx, err := t.requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	op := t.store.Get(hash)
+	if op == nil {
+		return errors.Errorf("no preimage recorded for %v", hash.Hex())
+	}
+	grace, err := t.timelock.CallBig(ctx, "GRACE_PERIOD")
+	if err != nil {
+		return err
+	}
+	now, err := t.now(ctx)
+	if err != nil {
+		return err
+	}
+	status, err := t.status(ctx, op, now, grace.Uint64())
+	if err != nil {
+		return err
+	}
+	verb, outcome := "Execute", timelock.OutcomeExecuted
+	if action == "cancel" {
+		verb, outcome = "Cancel", timelock.OutcomeCancelled
+	}
+	// Only matured operations can be executed, but anything still queued can be cancelled.
+	queued := status == "ready" || status == "stale" || strings.HasPrefix(status, "pending")
+	if action == "execute" && status != "ready" || !queued {
+		return errors.Errorf("cannot %v %v: it is %v", action, hash.Hex(), status)
+	}
+
+	fmt.Fprintf(e.out, "About to %v on %v:\n", action, t.Config.Network)
+	fmt.Fprintf(e.out, "  call:     %v\n", op.Description)
+	fmt.Fprintf(e.out, "  calldata: 0x%x\n", op.Calldata())
+	fmt.Fprintf(e.out, "  value:    %v wei\n", op.Value)
+	fmt.Fprintf(e.out, "  hash:     %v (matches the preimage)\n", hash.Hex())
+	if err := e.prompt.Confirm(verb + " this operation?"); err != nil {
+		return err
+	}
+
+	call := chain.Call{Contract: t.timelock, Method: action + "Transaction", Args: op.Args()}
+	if action == "execute" {
+		call.Value = op.Value
+	}
+	receipt, err := tx.SendAndWait(ctx, call)
+	if err != nil {
+		return err
+	}
+	op.Outcome = outcome
+	op.OutcomeTx = receipt.TxHash.Hex()
+	if err := t.store.Put(op); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Done: %v (gas used: %v).\n", receipt.TxHash.Hex(), receipt.GasUsed)
+	return nil
+}
+
+func formatTime(unix uint64) string {
+	return time.Unix(int64(unix), 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+func init() {
+	register(&command{
+		name:    "treasury",
+		usage:   "status [-token <address>] | distribute [-token <address>]",
+		summary: "Show the FeeTreasury's targets and undistributed fees, and distribute them.",
+		run:     runTreasury,
+	})
+}
+
+func runTreasury(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		commands["treasury"].flags().Usage()
+		return errors.New("missing treasury subcommand")
+	}
+	switch args[0] {
+	case "status":
+		return e.treasuryStatus(ctx, args[1:])
+	case "distribute":
+		return e.treasuryDistribute(ctx, args[1:])
+	}
+	return errors.Errorf("unknown treasury subcommand %q", args[0])
+}
+
+// treasuryToken returns the FeeTreasury and the token that -token names, RSV by default, with a
+// function that formats amounts of it.
+func treasuryToken(s *session.Session, tokenArg string) (*chain.Contract, common.Address, func(*big.Int) string, error) {
+	treasury, err := s.Contract("FeeTreasury")
+	if err != nil {
+		return nil, common.Address{}, nil, err
+	}
+	reserve, err := s.Contract("Reserve")
+	if err != nil {
+		return nil, common.Address{}, nil, err
+	}
+	if tokenArg == "" {
+		format := func(amount *big.Int) string { return units.Format(amount, rsvDecimals) + " RSV" }
+		return treasury, reserve.Address, format, nil
+	}
+	token, err := checksummedAddress(tokenArg)
+	if err != nil {
+		return nil, common.Address{}, nil, errors.Wrap(err, "-token")
+	}
+	format := func(amount *big.Int) string { return amount.String() + " qToken" }
+	return treasury, token, format, nil
+}
+
+// treasuryNext returns when the treasury may next distribute token, formatted, and whether that
+// time has come.
+func treasuryNext(ctx context.Context, s *session.Session, treasury *chain.Contract, token common.Address) (string, bool, error) {
+	next, err := treasury.CallBig(ctx, "nextDistribution", token)
+	if err != nil {
+		return "", false, err
+	}
+	header, err := s.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return "", false, errors.Wrap(err, "reading block header")
+	}
+	if next.Cmp(new(big.Int).SetUint64(header.Time)) <= 0 {
+		return "now", true, nil
+	}
+	return formatTime(next.Uint64()), false, nil
+}
+
+func (e *env) treasuryStatus(ctx context.Context, args []string) error {
+	fs := commands["treasury"].flags()
+	tokenArg := fs.String("token", "", "checksummed address of the fee token (default: RSV)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := e.open(ctx, "treasury status")
+	if err != nil {
+		return err
+	}
+	treasury, token, format, err := treasuryToken(s, *tokenArg)
+	if err != nil {
+		return err
+	}
+	n, err := treasury.CallBig(ctx, "targetsLength")
+	if err != nil {
+		return err
+	}
+
+	namer := e.namer(ctx, s)
+	w := tabwriter.NewWriter(e.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tSHARE\tCLAIMABLE")
+	for i := int64(0); i < n.Int64(); i++ {
+		target, err := treasury.CallAddress(ctx, "targets", big.NewInt(i))
+		if err != nil {
+			return err
+		}
+		share, err := treasury.CallBig(ctx, "shares", big.NewInt(i))
+		if err != nil {
+			return err
+		}
+		claimable, err := treasury.CallBig(ctx, "claimable", token, target)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%v\t%v%%\t%v\n", namer.Label(ctx, target), units.Format(share, 2), format(claimable))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	undistributed, err := treasury.CallBig(ctx, "undistributed", token)
+	if err != nil {
+		return err
+	}
+	interval, err := treasury.CallBig(ctx, "distributionInterval")
+	if err != nil {
+		return err
+	}
+	next, _, err := treasuryNext(ctx, s, treasury, token)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "%v undistributed; distributed at most every %v, next %v.\n",
+		format(undistributed), time.Duration(interval.Int64())*time.Second, next)
+	return nil
+}
+
+func (e *env) treasuryDistribute(ctx context.Context, args []string) error {
+	fs := commands["treasury"].flags()
+	tokenArg := fs.String("token", "", "checksummed address of the fee token (default: RSV)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := e.open(ctx, "treasury distribute")
+	if err != nil {
+		return err
+	}
+	t, err := s.RequireTransactor()
+	if err != nil {
+		return err
+	}
+	treasury, token, format, err := treasuryToken(s, *tokenArg)
+	if err != nil {
+		return err
+	}
+	n, err := treasury.CallBig(ctx, "targetsLength")
+	if err != nil {
+		return err
+	}
+	if n.Sign() == 0 {
+		return errors.New("the FeeTreasury has no distribution targets")
+	}
+	undistributed, err := treasury.CallBig(ctx, "undistributed", token)
+	if err != nil {
+		return err
+	}
+	if undistributed.Sign() == 0 {
+		fmt.Fprintf(e.out, "Nothing of %v to distribute.\n", token.Hex())
+		return nil
+	}
+	next, due, err := treasuryNext(ctx, s, treasury, token)
+	if err != nil {
+		return err
+	}
+	if !due {
+		return errors.Errorf("%v can't be distributed again until %v", token.Hex(), next)
+	}
+
+	fmt.Fprintf(e.out, "About to distribute %v of %v among %v targets on %v.\n",
+		format(undistributed), token.Hex(), n, s.Config.Network)
+	if err := e.prompt.Confirm("Distribute?"); err != nil {
+		return err
+	}
+	receipt, err := t.SendAndWait(ctx, chain.Call{Contract: treasury, Method: "distribute", Args: []interface{}{token}})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Done: %v (gas used: %v).\n", receipt.TxHash.Hex(), receipt.GasUsed)
+	return nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/bytecode"
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+	"github.com/reserve-protocol/rsv-beta/ops/upgrade"
+)
+
+func init() {
+	register(&command{
+		name:    "upgrade",
+		usage:   "[-dry-run] <plan.json> | -rollback <plan-rollback.json>",
+		summary: "Run an upgrade plan, first writing the plan that rolls it back; or run that rollback.",
+		run:     runUpgrade,
+	})
+}
+
+// rollbackPath is where the rollback of the upgrade plan at path is written.
+func rollbackPath(path string) string {
+	return strings.TrimSuffix(path, ".json") + "-rollback.json"
+}
+
+func runUpgrade(ctx context.Context, e *env, args []string) error {
+	fs := commands["upgrade"].flags()
+	rollback := fs.String("rollback", "", "rollback plan to run")
+	dryRun := fs.Bool("dry-run", false, "print the upgrade and its rollback, but write and send nothing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *rollback != "" {
+		if fs.NArg() != 0 || *dryRun {
+			return errors.New("-rollback takes no other arguments")
+		}
+		return e.rollback(ctx, *rollback)
+	}
+	if fs.NArg() != 1 {
+		return errors.New("give one upgrade plan")
+	}
+	path := fs.Arg(0)
+	p, err := upgrade.Load(path)
+	if err != nil {
+		return err
+	}
+	s, err := e.open(ctx, "upgrade")
+	if err != nil {
+		return err
+	}
+	tx, err := s.RequireTransactor()
+	if err != nil {
+		return err
+	}
+
+	// Work out and check the rollback before touching anything.
+	r := &paramReader{s: s, contracts: make(map[string]*chain.Contract)}
+	inverse, err := upgrade.Inverse(ctx, r, s.Manifest, p, tx.From())
+	if err != nil {
+		return err
+	}
+	inverse.For = path
+	for _, step := range inverse.Steps {
+		if step.Deploy != "" {
+			if err := e.checkRedeploy(ctx, s, step); err != nil {
+				return err
+			}
+		}
+	}
+	// Nor may any implementation swap misread the proxy's storage.
+	if err := e.checkUpgradeLayouts(ctx, s, p); err != nil {
+		return err
+	}
+	out := rollbackPath(path)
+	if _, err := os.Stat(out); err == nil {
+		return errors.Errorf("%v already exists: this upgrade may have been started before. "+
+			"Roll it back with `upgrade -rollback %v`, or move the file aside", out, out)
+	}
+
+	fmt.Fprintf(e.out, "Upgrade %v on %v", path, s.Config.Network)
+	if p.Description != "" {
+		fmt.Fprintf(e.out, " (%v)", p.Description)
+	}
+	fmt.Fprintln(e.out, ":")
+	for i, step := range p.Steps {
+		fmt.Fprintf(e.out, "  %v. %v\n", i+1, step)
+	}
+	fmt.Fprintf(e.out, "Rollback, to be written to %v:\n", out)
+	printRollback(e, inverse.Steps, inverse.Manifest)
+	if *dryRun {
+		return nil
+	}
+	if err := e.prompt.Confirm("Upgrade?"); err != nil {
+		return err
+	}
+	if err := inverse.Save(out); err != nil {
+		return err
+	}
+
+	for i, step := range p.Steps {
+		if err := e.sendStep(ctx, s, tx, step, nil); err != nil {
+			return errors.Wrapf(err, "upgrade stopped at step %v (%v); roll back what was done with `upgrade -rollback %v`", i+1, step, out)
+		}
+		inverse.Executed = i + 1
+		if err := inverse.Save(out); err != nil {
+			return err
+		}
+	}
+	for name, v := range p.Manifest {
+		addr, err := address(s, v)
+		if err != nil {
+			return errors.Wrap(err, "plan manifest")
+		}
+		s.Manifest.Contracts[name] = addr
+	}
+	if err := s.Manifest.Save(s.Config.Manifest); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Upgrade done; the manifest is updated. %v rolls it back.\n", out)
+	return nil
+}
+
+func printRollback(e *env, steps []upgrade.Step, records []upgrade.Record) {
+	for i, step := range steps {
+		fmt.Fprintf(e.out, "  %v. %v  (undoes step %v)\n", i+1, step, step.Undoes)
+	}
+	for _, rec := range records {
+		fmt.Fprintf(e.out, "  then record %v as %v in the manifest\n", rec.Name, rec.Address)
+	}
+}
+
+// checkRedeploy checks that a rollback deployment would recreate the code it replaces.
+func (e *env) checkRedeploy(ctx context.Context, s *session.Session, step upgrade.Step) error {
+	artifact, err := s.Artifacts.Load(step.Deploy)
+	if err != nil {
+		return err
+	}
+	deployed, err := s.Client.CodeAt(ctx, common.HexToAddress(step.Matches), nil)
+	if err != nil {
+		return errors.Wrapf(err, "reading code at %v", step.Matches)
+	}
+	if result := bytecode.Compare(deployed, artifact.BinRuntime); result != bytecode.Exact && result != bytecode.Partial {
+		return errors.Errorf("the rollback would redeploy %v, but the local artifact does not match the code at %v (%v)",
+			step.Deploy, step.Matches, result)
+	}
+	return nil
+}
+
+// address resolves an address or "@Name" reference.
+func address(s *session.Session, v string) (common.Address, error) {
+	resolved, err := s.Manifest.Resolve([]string{v})
+	if err != nil {
+		return common.Address{}, err
+	}
+	if !common.IsHexAddress(resolved[0]) {
+		return common.Address{}, errors.Errorf("%q is not an address", v)
+	}
+	return common.HexToAddress(resolved[0]), nil
+}
+
+// sendStep sends one step of an upgrade or rollback. artifacts gives the artifact of contracts
+// deployed by a rollback, whose manifest name isn't an artifact name.
+func (e *env) sendStep(ctx context.Context, s *session.Session, tx *chain.Transactor, step upgrade.Step, artifacts map[string]string) error {
+	if step.Deploy != "" {
+		if addr, ok := s.Manifest.Contracts[step.As]; ok {
+			fmt.Fprintf(e.out, "%v is already at %v; skipping\n", step.As, addr.Hex())
+			return nil
+		}
+		if err := e.checkRedeploy(ctx, s, step); err != nil {
+			return err
+		}
+		artifact, err := s.Artifacts.Load(step.Deploy)
+		if err != nil {
+			return err
+		}
+		receipt, err := tx.SendAndWait(ctx, chain.Call{Create: artifact})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(e.out, "Deployed %v at %v\n", step.As, receipt.ContractAddress.Hex())
+		s.Manifest.Contracts[step.As] = receipt.ContractAddress
+		return s.Manifest.Save(s.Config.Manifest)
+	}
+
+	name := step.Contract
+	if a, ok := artifacts[name]; ok {
+		name = a
+	}
+	artifact, err := s.Artifacts.Load(name)
+	if err != nil {
+		return err
+	}
+	addr, err := s.Manifest.Address(step.Contract)
+	if err != nil {
+		return err
+	}
+	method, err := artifact.FindMethod(step.Method)
+	if err != nil {
+		return err
+	}
+	strs, err := s.Manifest.Resolve(step.Args)
+	if err != nil {
+		return err
+	}
+	args, err := chain.ParseArgs(method, strs)
+	if err != nil {
+		return err
+	}
+	call := chain.Call{Contract: artifact.Bind(addr, s.Client), Method: method.Name, Args: args}
+	fmt.Fprintf(e.out, "Calling %v\n", call)
+	_, err = tx.SendAndWait(ctx, call)
+	return err
+}
+
+// rollback runs the pending steps of a rollback plan, recording its progress in the plan.
+func (e *env) rollback(ctx context.Context, path string) error {
+	r, err := upgrade.LoadRollback(path)
+	if err != nil {
+		return err
+	}
+	s, err := e.open(ctx, "upgrade -rollback")
+	if err != nil {
+		return err
+	}
+	tx, err := s.RequireTransactor()
+	if err != nil {
+		return err
+	}
+	steps := r.Pending()
+	if r.RolledBack > len(steps) {
+		return errors.Errorf("%v is corrupt: %v steps rolled back, of %v", path, r.RolledBack, len(steps))
+	}
+	artifacts := make(map[string]string)
+	for _, step := range r.Steps {
+		if step.Deploy != "" {
+			artifacts[step.As] = step.Deploy
+		}
+	}
+
+	fmt.Fprintf(e.out, "Rolling back %v on %v, whose first %v steps were carried out:\n", r.For, s.Config.Network, r.Executed)
+	printRollback(e, steps, r.PendingRecords())
+	if r.RolledBack > 0 {
+		fmt.Fprintf(e.out, "The first %v of these are already done.\n", r.RolledBack)
+	}
+	if err := e.prompt.Confirm("Roll back?"); err != nil {
+		return err
+	}
+	for i := r.RolledBack; i < len(steps); i++ {
+		if err := e.sendStep(ctx, s, tx, steps[i], artifacts); err != nil {
+			return errors.Wrapf(err, "rollback stopped at its step %v (%v); rerun it to continue", i+1, steps[i])
+		}
+		r.RolledBack = i + 1
+		if err := r.Save(path); err != nil {
+			return err
+		}
+	}
+	for _, rec := range r.PendingRecords() {
+		addr, err := address(s, rec.Address)
+		if err != nil {
+			return err
+		}
+		s.Manifest.Contracts[rec.Name] = addr
+	}
+	if err := s.Manifest.Save(s.Config.Manifest); err != nil {
+		return err
+	}
+	fmt.Fprintln(e.out, "Rollback done; the manifest is restored.")
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/bytecode"
+)
+
+func init() {
+	register(&command{
+		name:    "verify-bytecode",
+		usage:   "",
+		summary: "Compare the code deployed at every manifest address against the local artifacts.",
+		run:     runVerifyBytecode,
+	})
+}
+
+func runVerifyBytecode(ctx context.Context, e *env, args []string) error {
+	fs := commands["verify-bytecode"].flags()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := e.open(ctx, "verify-bytecode")
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(s.Manifest.Contracts))
+	for name := range s.Manifest.Contracts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	header, err := s.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "reading latest block")
+	}
+	fmt.Fprintf(e.out, "Verifying %v contracts on %v at block %v against %v:\n", len(names), s.Config.Network, header.Number, s.Artifacts.Dir)
+
+	bad := 0
+	for _, name := range names {
+		addr := s.Manifest.Contracts[name]
+		artifact, err := s.Artifacts.Load(name)
+		if os.IsNotExist(errors.Cause(err)) {
+			fmt.Fprintf(e.out, "  %-24v %v  no local artifact\n", name, addr.Hex())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// A proxy's code is the proxy's; compare its implementation's instead.
+		impl, err := s.Client.Implementation(ctx, addr, header.Number)
+		if err != nil {
+			return err
+		}
+		at, via := addr, ""
+		if impl != (common.Address{}) {
+			at, via = impl, fmt.Sprintf(" (proxy to %v)", impl.Hex())
+		}
+		deployed, err := s.Client.CodeAt(ctx, at, header.Number)
+		if err != nil {
+			return errors.Wrapf(err, "reading code of %v at %v", name, at.Hex())
+		}
+		result := bytecode.Compare(deployed, artifact.BinRuntime)
+		if result == bytecode.Mismatch || result == bytecode.NoCode {
+			bad++
+		}
+		fmt.Fprintf(e.out, "  %-24v %v  %v%v\n", name, addr.Hex(), result, via)
+	}
+	if bad > 0 {
+		return errors.Errorf("%v contracts do not match their artifacts", bad)
+	}
+	return nil
+}
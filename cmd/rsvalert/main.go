@@ -0,0 +1,120 @@
+// Command rsvalert runs the alerting service: it evaluates the configured invariants against the
+// deployment every poll, and alerts PagerDuty and chat webhooks when one breaks or recovers.
+//
+// Usage:
+//
+//	rsvalert [-config rsvalert.json]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/reserve-protocol/rsv-beta/ops/alert"
+	"github.com/reserve-protocol/rsv-beta/ops/emergency"
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// config is the rsvalert configuration file.
+type config struct {
+	session.Config
+
+	Invariants alert.Config `json:"invariants"`
+
+	// PagerDuty, if set, receives an incident for each broken invariant.
+	PagerDuty *alert.PagerDutyConfig `json:"pagerDuty,omitempty"`
+
+	// Webhooks receive a message for each broken or recovered invariant.
+	Webhooks []emergency.Webhook `json:"webhooks,omitempty"`
+
+	// PollSeconds is how often to evaluate the invariants (default 30).
+	PollSeconds int `json:"pollSeconds,omitempty"`
+
+	// MaxReadFailures is how many reads of the chain in a row may fail before alerting
+	// (default 3).
+	MaxReadFailures int `json:"maxReadFailures,omitempty"`
+
+	// LogFile, if set, receives the service log, rotated as it grows. Otherwise it goes to stderr.
+	LogFile string `json:"logFile,omitempty"`
+}
+
+func main() {
+	log.SetPrefix("rsvalert: ")
+	configPath := flag.String("config", "rsvalert.json", "configuration file")
+	flag.Parse()
+
+	var c config
+	if err := session.LoadConfig(*configPath, &c); err != nil {
+		log.Fatal(err)
+	}
+	if c.LogFile != "" {
+		log.SetOutput(&lumberjack.Logger{Filename: c.LogFile, MaxSize: 100, MaxBackups: 10})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		log.Printf("received %v, shutting down", <-sigs)
+		cancel()
+	}()
+
+	if err := run(ctx, c); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, c config) error {
+	if c.PollSeconds == 0 {
+		c.PollSeconds = 30
+	}
+	invariants, err := c.Invariants.Invariants()
+	if err != nil {
+		return err
+	}
+	var sinks []alert.Sink
+	if c.PagerDuty != nil {
+		pd, err := alert.NewPagerDuty(*c.PagerDuty, c.Network)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, pd)
+	}
+	if len(c.Webhooks) > 0 {
+		notifier, err := emergency.NewNotifier(c.Webhooks)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, &alert.Webhooks{Notifier: notifier, Network: c.Network})
+	}
+	if len(sinks) == 0 {
+		return errors.New("config: neither pagerDuty nor webhooks is set, so alerts would go nowhere")
+	}
+
+	s, err := session.Open(ctx, c.Config, "rsvalert")
+	if err != nil {
+		return err
+	}
+	reader, err := metrics.NewReader(s)
+	if err != nil {
+		return err
+	}
+	engine := &alert.Engine{
+		Read:            reader.Read,
+		Invariants:      invariants,
+		Sinks:           sinks,
+		Interval:        time.Duration(c.PollSeconds) * time.Second,
+		MaxReadFailures: c.MaxReadFailures,
+	}
+	log.Printf("evaluating %v invariants on %v", len(invariants), c.Network)
+	return engine.Run(ctx)
+}
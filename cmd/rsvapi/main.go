@@ -0,0 +1,146 @@
+// Command rsvapi serves the deployment's supply, basket, holder balances, transfer history, and
+// proposals as a JSON API, from the chain and the database of an rsvindexer following the same
+// deployment.
+//
+// Usage:
+//
+//	rsvapi [-config rsvapi.json]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/reserve-protocol/rsv-beta/ops/api"
+	"github.com/reserve-protocol/rsv-beta/ops/indexer"
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// config is the rsvapi configuration file.
+type config struct {
+	session.Config
+
+	// Database is the rsvindexer's database, as in its config.
+	Database struct {
+		Driver string `json:"driver"`
+		DSN    string `json:"dsn,omitempty"`
+		DSNEnv string `json:"dsnEnv,omitempty"`
+	} `json:"database"`
+
+	// Keys are the clients allowed to use the API, each with its key in the environment
+	// variable named by KeyEnv.
+	Keys []struct {
+		Name   string `json:"name"`
+		KeyEnv string `json:"keyEnv"`
+	} `json:"keys"`
+
+	// Listen is the address to serve the API on (default ":9690").
+	Listen string `json:"listen,omitempty"`
+
+	// PollSeconds is how often to read the chain and the new events (default 30).
+	PollSeconds int `json:"pollSeconds,omitempty"`
+
+	// LogFile, if set, receives the service log, rotated as it grows. Otherwise it goes to stderr.
+	LogFile string `json:"logFile,omitempty"`
+}
+
+func main() {
+	log.SetPrefix("rsvapi: ")
+	configPath := flag.String("config", "rsvapi.json", "configuration file")
+	flag.Parse()
+
+	var c config
+	if err := session.LoadConfig(*configPath, &c); err != nil {
+		log.Fatal(err)
+	}
+	if c.LogFile != "" {
+		log.SetOutput(&lumberjack.Logger{Filename: c.LogFile, MaxSize: 100, MaxBackups: 10})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		log.Printf("received %v, shutting down", <-sigs)
+		cancel()
+	}()
+
+	if err := run(ctx, c); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, c config) error {
+	dsn := c.Database.DSN
+	if c.Database.DSNEnv != "" {
+		if dsn = os.Getenv(c.Database.DSNEnv); dsn == "" {
+			return errors.Errorf("config: environment variable %v is not set", c.Database.DSNEnv)
+		}
+	}
+	if dsn == "" {
+		return errors.New("config: database dsn is not set")
+	}
+	if len(c.Keys) == 0 {
+		return errors.New("config: no keys are set, so no one could use the API")
+	}
+	keys := make(map[string]string, len(c.Keys))
+	for _, k := range c.Keys {
+		key := os.Getenv(k.KeyEnv)
+		if key == "" {
+			return errors.Errorf("config: environment variable %v, the key of %v, is not set", k.KeyEnv, k.Name)
+		}
+		keys[key] = k.Name
+	}
+	if c.Listen == "" {
+		c.Listen = ":9690"
+	}
+	if c.PollSeconds == 0 {
+		c.PollSeconds = 30
+	}
+
+	s, err := session.Open(ctx, c.Config, "rsvapi")
+	if err != nil {
+		return err
+	}
+	reader, err := metrics.NewReader(s)
+	if err != nil {
+		return err
+	}
+	store, err := indexer.OpenStore(c.Database.Driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	server := &api.Server{
+		Read:     reader.Read,
+		Store:    store,
+		Indexer:  c.Network, // as rsvindexer names its stream
+		Keys:     keys,
+		Interval: time.Duration(c.PollSeconds) * time.Second,
+	}
+
+	listener, err := net.Listen("tcp", c.Listen)
+	if err != nil {
+		return errors.Wrap(err, "listening")
+	}
+	httpServer := &http.Server{Handler: server, ReadTimeout: 10 * time.Second, WriteTimeout: 30 * time.Second}
+	defer httpServer.Close()
+	go func() {
+		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	log.Printf("serving the API for %v to %v clients on %v", c.Network, len(keys), listener.Addr())
+	return server.Run(ctx)
+}
@@ -0,0 +1,145 @@
+// Command rsvblocklist runs the blocklist sync: it fetches a sanctions or blocklist feed, diffs it
+// against the addresses frozen on the Reserve, and prepares, or with -submit sends, the freezes
+// and unfreezes that converge them, logging every change.
+//
+// Usage:
+//
+//	rsvblocklist [-config rsvblocklist.json] [-submit] [-once]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/reserve-protocol/rsv-beta/ops/blocklist"
+	"github.com/reserve-protocol/rsv-beta/ops/emergency"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// config is the rsvblocklist configuration file.
+type config struct {
+	session.Config
+
+	Feed blocklist.Feed `json:"feed"`
+
+	// StateFile keeps what the feed listed across restarts.
+	StateFile string `json:"stateFile"`
+
+	// ChangeLog is the JSON-lines log of every change of the feed and of the frozen addresses.
+	ChangeLog string `json:"changeLog"`
+
+	// Prepared, if set, receives the changes still needed after each sync, for the freezer to
+	// send when rsvblocklist doesn't.
+	Prepared string `json:"prepared,omitempty"`
+
+	// MaxChanges is the most changes one sync may make (default 20). A feed calling for more
+	// makes none, for someone to check it.
+	MaxChanges int `json:"maxChanges,omitempty"`
+
+	// Webhooks, if set, receive a summary of each sync that changes anything.
+	Webhooks []emergency.Webhook `json:"webhooks,omitempty"`
+
+	// PollSeconds is how often to fetch the feed (default 3600).
+	PollSeconds int `json:"pollSeconds,omitempty"`
+
+	// LogFile, if set, receives the service log, rotated as it grows. Otherwise it goes to stderr.
+	LogFile string `json:"logFile,omitempty"`
+}
+
+func main() {
+	log.SetPrefix("rsvblocklist: ")
+	configPath := flag.String("config", "rsvblocklist.json", "configuration file")
+	submit := flag.Bool("submit", false, "send the freezes and unfreezes with the configured signer, which must be the Reserve's freezer, rather than only prepare them")
+	once := flag.Bool("once", false, "sync once and exit")
+	flag.Parse()
+
+	var c config
+	if err := session.LoadConfig(*configPath, &c); err != nil {
+		log.Fatal(err)
+	}
+	if c.LogFile != "" {
+		log.SetOutput(&lumberjack.Logger{Filename: c.LogFile, MaxSize: 100, MaxBackups: 10})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		log.Printf("received %v, shutting down", <-sigs)
+		cancel()
+	}()
+
+	if err := run(ctx, c, *submit, *once); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, c config, submit, once bool) error {
+	if c.StateFile == "" {
+		return errors.New("config: stateFile is not set")
+	}
+	if c.ChangeLog == "" {
+		return errors.New("config: changeLog is not set")
+	}
+	if c.MaxChanges == 0 {
+		c.MaxChanges = 20
+	}
+	if c.PollSeconds == 0 {
+		c.PollSeconds = 3600
+	}
+
+	s, err := session.Open(ctx, c.Config, "rsvblocklist")
+	if err != nil {
+		return err
+	}
+	if submit {
+		if _, err := s.RequireTransactor(); err != nil {
+			return err
+		}
+	}
+	ch, err := blocklist.NewChain(s)
+	if err != nil {
+		return err
+	}
+	syncer := &blocklist.Syncer{
+		Fetch:      c.Feed.Fetch,
+		Chain:      ch,
+		Submit:     submit,
+		Prepared:   c.Prepared,
+		MaxChanges: c.MaxChanges,
+		StateFile:  c.StateFile,
+		Log:        c.ChangeLog,
+		Network:    c.Network,
+		Interval:   time.Duration(c.PollSeconds) * time.Second,
+	}
+	if len(c.Webhooks) > 0 {
+		notifier, err := emergency.NewNotifier(c.Webhooks)
+		if err != nil {
+			return err
+		}
+		syncer.Post = notifier.Notify
+	}
+
+	if once {
+		changes, err := syncer.Sync(ctx)
+		for _, change := range changes {
+			fmt.Printf("%v %v\n", change.Action, change.Account.Hex())
+		}
+		return err
+	}
+	mode := "preparing"
+	if submit {
+		mode = "sending"
+	}
+	log.Printf("syncing the frozen addresses on %v with the feed, %v changes", c.Network, mode)
+	return syncer.Run(ctx)
+}
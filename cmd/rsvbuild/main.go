@@ -0,0 +1,149 @@
+// Command rsvbuild keeps a committed record of how every contract is compiled, build.lock.json,
+// and checks that a build reproduces it, so that every machine building the repo makes the same
+// artifacts, and those match what is deployed.
+//
+// `rsvbuild record` reads the metadata that `make json` has solc write into each artifact in
+// -artifacts: the exact solc version, the optimizer runs, the EVM version, and the keccak256 of
+// every source file compiled in. It writes those, with the keccak256 of each contract's code with
+// its metadata hashes zeroed, to -file. Commit the lock along with any change to the contracts or
+// to how they are compiled.
+//
+// `rsvbuild verify` first checks the sources in the working tree against the lock, which needs no
+// compiler, and then, if there are artifacts in -artifacts, checks that each is built from the
+// same inputs into the same code. It lists every difference, and exits nonzero if there is any,
+// or if no lock is committed.
+//
+// Usage:
+//
+//	rsvbuild record [-artifacts evm] [-file build.lock.json]
+//	rsvbuild verify [-artifacts evm] [-file build.lock.json]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/build"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("rsvbuild: ")
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "record":
+		err = record(os.Args[2:])
+	case "verify":
+		err = verify(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: rsvbuild record [-artifacts evm] [-file build.lock.json]")
+	fmt.Fprintln(os.Stderr, "       rsvbuild verify [-artifacts evm] [-file build.lock.json]")
+	os.Exit(2)
+}
+
+func record(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	artifacts := fs.String("artifacts", "evm", "the artifacts that `make json` writes")
+	file := fs.String("file", "build.lock.json", "the committed build lock")
+	fs.Parse(args)
+
+	lock, err := build.LoadAll(*artifacts)
+	if err != nil {
+		return err
+	}
+	if err := build.WriteFile(*file, lock); err != nil {
+		return err
+	}
+	fmt.Printf("Recorded the builds of %v contracts in %v.\n", len(lock), *file)
+	return nil
+}
+
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	artifacts := fs.String("artifacts", "evm", "the artifacts that `make json` writes")
+	file := fs.String("file", "build.lock.json", "the committed build lock")
+	fs.Parse(args)
+
+	lock, err := build.ReadFile(*file)
+	if os.IsNotExist(errors.Cause(err)) {
+		return errors.Errorf("there is no %v to verify against; run `make build-lock` and commit it", *file)
+	}
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(lock))
+	for name := range lock {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Contracts share source files; check each file once.
+	bad := 0
+	sources := make(map[string]bool)
+	for _, name := range names {
+		for _, diff := range build.CheckSources(".", lock[name]) {
+			if !sources[diff] {
+				sources[diff] = true
+				fmt.Printf("source %v\n", diff)
+				bad++
+			}
+		}
+	}
+	if bad > 0 {
+		fmt.Println("The sources differ from those recorded: check that they are committed, with LF line endings.")
+	}
+
+	if paths, _ := filepath.Glob(filepath.Join(*artifacts, "*.json")); len(paths) == 0 {
+		fmt.Printf("No artifacts in %v to check; run `make json` to build them.\n", *artifacts)
+	} else {
+		built, err := build.LoadAll(*artifacts)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if built[name] == nil {
+				fmt.Printf("%v: not built\n", name)
+				bad++
+				continue
+			}
+			for _, diff := range build.Compare(lock[name], built[name]) {
+				fmt.Printf("%v: %v\n", name, diff)
+				bad++
+			}
+		}
+		var unrecorded []string
+		for name := range built {
+			if lock[name] == nil {
+				unrecorded = append(unrecorded, name)
+			}
+		}
+		sort.Strings(unrecorded)
+		for _, name := range unrecorded {
+			fmt.Printf("%v: built, but not recorded in %v\n", name, *file)
+			bad++
+		}
+	}
+
+	if bad > 0 {
+		return errors.Errorf("the build differs from %v in %v ways", *file, bad)
+	}
+	fmt.Printf("The build matches %v.\n", *file)
+	return nil
+}
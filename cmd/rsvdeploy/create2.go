@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+)
+
+// deploymentProxy is the keyless deterministic deployment proxy
+// (https://github.com/Arachnid/deterministic-deployment-proxy), which lives at this address on
+// most chains. Sending it a 32-byte salt followed by init code CREATE2-deploys that code. We
+// bootstrap the Create2Deployer through it, so that the Create2Deployer itself has the same
+// address everywhere.
+var deploymentProxy = common.HexToAddress("0x4e59b44847b379578588920cA78FbF26c0B4956C")
+
+// contractSalt is the CREATE2 salt for the contract called name, under the deployment salt salt.
+// Deriving a salt per contract lets one -salt value cover a whole plan.
+func contractSalt(salt, name string) [32]byte {
+	return crypto.Keccak256Hash([]byte(salt + "/" + name))
+}
+
+// create2Address is where the Create2Deployer at deployer puts initCode, deployed by from with
+// salt. It mirrors Create2Deployer.computeAddress, so that we can check the contract's answer.
+func create2Address(deployer, from common.Address, salt [32]byte, initCode []byte) common.Address {
+	fullSalt := crypto.Keccak256Hash(from.Bytes(), salt[:])
+	return crypto.CreateAddress2(deployer, fullSalt, crypto.Keccak256(initCode))
+}
+
+// deployCreate2 deploys artifact with args through the Create2Deployer, checking the target
+// address before sending and the deployed contract afterwards.
+func (d *deployer) deployCreate2(ctx context.Context, artifact *chain.Artifact, args []interface{}) (common.Address, error) {
+	initCode, err := artifact.InitCode(args...)
+	if err != nil {
+		return common.Address{}, err
+	}
+	salt := contractSalt(d.salt, artifact.Name)
+	expected := create2Address(d.create2.Address, d.tx.From(), salt, initCode)
+
+	computed, err := d.create2.CallAddress(ctx, "computeAddress", d.tx.From(), salt, initCode)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if computed != expected {
+		return common.Address{}, errors.Errorf(
+			"%v computes %v for %v, but we expected %v", d.create2, computed.Hex(), artifact.Name, expected.Hex(),
+		)
+	}
+	code, err := d.s.Client.CodeAt(ctx, expected, nil)
+	if err != nil {
+		return common.Address{}, errors.Wrapf(err, "reading code at %v", expected.Hex())
+	}
+	if len(code) > 0 {
+		return common.Address{}, errors.Errorf(
+			"%v is already deployed at %v; add it to the manifest, or choose another salt", artifact.Name, expected.Hex(),
+		)
+	}
+
+	fmt.Fprintf(d.out, "Deploying %v to %v (salt %x)\n", artifact.Name, expected.Hex(), salt)
+	if _, err := d.tx.SendAndWait(ctx, chain.Call{
+		Contract: d.create2,
+		Method:   "deploy",
+		Args:     []interface{}{salt, initCode},
+	}); err != nil {
+		return common.Address{}, err
+	}
+
+	code, err = d.s.Client.CodeAt(ctx, expected, nil)
+	if err != nil {
+		return common.Address{}, errors.Wrapf(err, "reading code at %v", expected.Hex())
+	}
+	if len(code) == 0 {
+		return common.Address{}, errors.Errorf("deployed %v, but there is no code at %v", artifact.Name, expected.Hex())
+	}
+	deployedBy, err := d.create2.CallAddress(ctx, "deployerOf", expected)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if deployedBy != d.tx.From() {
+		return common.Address{}, errors.Errorf(
+			"%v records %v as deployed by %v, not by us (%v)", d.create2, expected.Hex(), deployedBy.Hex(), d.tx.From().Hex(),
+		)
+	}
+	return expected, nil
+}
+
+// viaCreate2 reports whether we deployed addr through the Create2Deployer, in which case the
+// Create2Deployer holds its deployer roles and we act on it through execute.
+func (d *deployer) viaCreate2(ctx context.Context, addr common.Address) (bool, error) {
+	if d.create2 == nil {
+		return false, nil
+	}
+	deployedBy, err := d.create2.CallAddress(ctx, "deployerOf", addr)
+	return deployedBy == d.tx.From(), err
+}
+
+// bootstrap deploys the Create2Deployer through the deterministic deployment proxy, and records
+// it in the manifest.
+func (d *deployer) bootstrap(ctx context.Context) error {
+	artifact, err := d.s.Artifacts.Load("Create2Deployer")
+	if err != nil {
+		return err
+	}
+	proxyCode, err := d.s.Client.CodeAt(ctx, deploymentProxy, nil)
+	if err != nil {
+		return errors.Wrap(err, "reading deployment proxy code")
+	}
+	if len(proxyCode) == 0 {
+		return errors.Errorf("the deterministic deployment proxy is not deployed at %v on this chain", deploymentProxy.Hex())
+	}
+
+	var salt [32]byte
+	expected := crypto.CreateAddress2(deploymentProxy, salt, crypto.Keccak256(artifact.Bin))
+	code, err := d.s.Client.CodeAt(ctx, expected, nil)
+	if err != nil {
+		return errors.Wrapf(err, "reading code at %v", expected.Hex())
+	}
+	if len(code) == 0 {
+		fmt.Fprintf(d.out, "Deploying Create2Deployer to %v\n", expected.Hex())
+		proxy := (&chain.Artifact{Name: "DeploymentProxy"}).Bind(deploymentProxy, d.s.Client)
+		data := append(salt[:], artifact.Bin...)
+		if _, err := d.tx.SendAndWait(ctx, chain.Call{Contract: proxy, Data: data}); err != nil {
+			return err
+		}
+		if code, err = d.s.Client.CodeAt(ctx, expected, nil); err != nil {
+			return errors.Wrapf(err, "reading code at %v", expected.Hex())
+		}
+		if len(code) == 0 {
+			return errors.Errorf("deployed Create2Deployer, but there is no code at %v", expected.Hex())
+		}
+	} else {
+		fmt.Fprintf(d.out, "Create2Deployer is already deployed at %v\n", expected.Hex())
+	}
+	d.s.Manifest.Contracts["Create2Deployer"] = expected
+	return d.s.Manifest.Save(d.s.Config.Manifest)
+}
@@ -0,0 +1,298 @@
+// Command rsvdeploy deploys contracts according to a plan file, then makes the plan's
+// configuration calls, recording each deployed contract in the manifest as it goes. A contract
+// already in the manifest is not deployed again, so an interrupted deployment can be resumed by
+// running the same plan again.
+//
+// With -create2, contracts are deployed through the Create2Deployer listed in the manifest, so
+// that their addresses depend only on the deploying account, the salt, and their init code. The
+// same plan with the same salt, run from the same account, then yields the same addresses on
+// every chain. -bootstrap-create2 deploys the Create2Deployer itself, at the same address on
+// every chain that has the deterministic deployment proxy.
+//
+// Usage:
+//
+//	rsvdeploy [-config rsvdeploy.json] [-create2 -salt <salt>] <plan.json>
+//	rsvdeploy [-config rsvdeploy.json] -bootstrap-create2
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/ens"
+	"github.com/reserve-protocol/rsv-beta/ops/prompt"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// config is the rsvdeploy configuration file.
+type config struct {
+	session.Config
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("rsvdeploy: ")
+	configPath := flag.String("config", "rsvdeploy.json", "configuration file")
+	create2 := flag.Bool("create2", false, "deploy through the manifest's Create2Deployer")
+	salt := flag.String("salt", "", "deployment salt for -create2")
+	bootstrap := flag.Bool("bootstrap-create2", false, "deploy the Create2Deployer itself")
+	flag.Parse()
+
+	var c config
+	if err := session.LoadConfig(*configPath, &c); err != nil {
+		log.Fatal(err)
+	}
+	if err := run(context.Background(), c, *create2, *salt, *bootstrap, flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// deployer carries out a plan.
+type deployer struct {
+	s      *session.Session
+	tx     *chain.Transactor
+	out    io.Writer
+	prompt *prompt.Prompter
+
+	// create2 is the Create2Deployer, or nil to deploy contracts directly.
+	create2 *chain.Contract
+	salt    string
+}
+
+func run(ctx context.Context, c config, create2 bool, salt string, bootstrap bool, args []string) error {
+	if create2 && salt == "" {
+		return errors.New("-create2 needs a -salt")
+	}
+	if !create2 && salt != "" {
+		return errors.New("-salt only applies with -create2")
+	}
+	if bootstrap != (len(args) == 0) {
+		return errors.New("give either a plan file or -bootstrap-create2")
+	}
+
+	s, err := session.Open(ctx, c.Config, "rsvdeploy")
+	if err != nil {
+		return err
+	}
+	tx, err := s.RequireTransactor()
+	if err != nil {
+		return err
+	}
+	d := &deployer{s: s, tx: tx, out: os.Stdout, prompt: prompt.New(os.Stdin, os.Stdout), salt: salt}
+	tx.Preflight = append(tx.Preflight, s.Interlock(d.prompt))
+	if bootstrap {
+		return d.bootstrap(ctx)
+	}
+
+	p, err := loadPlan(args[0])
+	if err != nil {
+		return err
+	}
+	if create2 {
+		if d.create2, err = s.Contract("Create2Deployer"); err != nil {
+			return err
+		}
+	}
+
+	mode := "directly"
+	if d.create2 != nil {
+		mode = fmt.Sprintf("through %v with salt %q", d.create2, salt)
+	}
+	fmt.Fprintf(d.out, "About to deploy %v contracts on %v from %v, %v.\n", len(p.Contracts), c.Network, tx.From().Hex(), mode)
+	if err := d.prompt.Confirm("Deploy?"); err != nil {
+		return err
+	}
+	return d.execute(ctx, p)
+}
+
+// execute deploys p's contracts, makes its calls, sets its ENS names, and nominates its owner.
+func (d *deployer) execute(ctx context.Context, p *plan) error {
+	for _, c := range p.Contracts {
+		if err := d.deploy(ctx, c); err != nil {
+			return errors.Wrapf(err, "deploying %v", c.Name)
+		}
+	}
+	for _, c := range p.Calls {
+		if err := d.call(ctx, c); err != nil {
+			return errors.Wrapf(err, "calling %v.%v", c.Contract, c.Method)
+		}
+	}
+	if err := d.name(ctx, p.Names); err != nil {
+		return err
+	}
+	if p.Owner == (common.Address{}) {
+		return nil
+	}
+	var nominated []string
+	for _, c := range p.Contracts {
+		ok, err := d.nominate(ctx, c.Name, p.Owner)
+		if err != nil {
+			return errors.Wrapf(err, "nominating the owner of %v", c.Name)
+		}
+		if ok {
+			nominated = append(nominated, c.Name)
+		}
+	}
+	if len(nominated) > 0 {
+		fmt.Fprintf(d.out, "\n%v must now call acceptOwnership() on:\n", p.Owner.Hex())
+		for _, name := range nominated {
+			fmt.Fprintf(d.out, "  %v %v\n", name, d.s.Manifest.Contracts[name].Hex())
+		}
+	}
+	return nil
+}
+
+// deploy deploys one contract, unless the manifest already has it.
+func (d *deployer) deploy(ctx context.Context, c planContract) error {
+	if addr, ok := d.s.Manifest.Contracts[c.Name]; ok {
+		fmt.Fprintf(d.out, "%v is already at %v; skipping\n", c.Name, addr.Hex())
+		return nil
+	}
+	artifact, err := d.s.Artifacts.Load(c.Name)
+	if err != nil {
+		return err
+	}
+	strs, err := d.s.Manifest.Resolve(c.Args)
+	if err != nil {
+		return err
+	}
+	args, err := chain.ParseArgs(artifact.ABI.Constructor, strs)
+	if err != nil {
+		return err
+	}
+
+	var addr common.Address
+	if d.create2 != nil {
+		if addr, err = d.deployCreate2(ctx, artifact, args); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(d.out, "Deploying %v\n", artifact.Name)
+		receipt, err := d.tx.SendAndWait(ctx, chain.Call{Create: artifact, Args: args})
+		if err != nil {
+			return err
+		}
+		addr = receipt.ContractAddress
+	}
+	fmt.Fprintf(d.out, "Deployed %v at %v\n", c.Name, addr.Hex())
+	d.s.Manifest.Contracts[c.Name] = addr
+	return d.s.Manifest.Save(d.s.Config.Manifest)
+}
+
+// call makes one configuration call.
+func (d *deployer) call(ctx context.Context, c planCall) error {
+	contract, err := d.s.Contract(c.Contract)
+	if err != nil {
+		return err
+	}
+	artifact, err := d.s.Artifacts.Load(c.Contract)
+	if err != nil {
+		return err
+	}
+	method, err := artifact.FindMethod(c.Method)
+	if err != nil {
+		return err
+	}
+	strs, err := d.s.Manifest.Resolve(c.Args)
+	if err != nil {
+		return err
+	}
+	args, err := chain.ParseArgs(method, strs)
+	if err != nil {
+		return err
+	}
+	return d.send(ctx, contract, method.Name, args)
+}
+
+// send calls method on contract, through the Create2Deployer if it holds the contract's roles.
+func (d *deployer) send(ctx context.Context, contract *chain.Contract, method string, args []interface{}) error {
+	call := chain.Call{Contract: contract, Method: method, Args: args}
+	fmt.Fprintf(d.out, "Calling %v\n", call)
+	via, err := d.viaCreate2(ctx, contract.Address)
+	if err != nil {
+		return err
+	}
+	if via {
+		data, err := contract.ABI.Pack(method, args...)
+		if err != nil {
+			return errors.Wrapf(err, "packing %v.%v", contract.Name, method)
+		}
+		call = chain.Call{Contract: d.create2, Method: "execute", Args: []interface{}{contract.Address, data}}
+	}
+	_, err = d.tx.SendAndWait(ctx, call)
+	return err
+}
+
+// name points ENS names at contracts, and records them in the manifest.
+func (d *deployer) name(ctx context.Context, names map[string]string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	registry, err := ens.Open(ctx, d.s.Client)
+	if err != nil {
+		return err
+	}
+	if registry == nil {
+		return errors.Errorf("the plan sets ENS names, but there is no ENS registry on %v", d.s.Config.Network)
+	}
+	contracts := make([]string, 0, len(names))
+	for contract := range names {
+		contracts = append(contracts, contract)
+	}
+	sort.Strings(contracts)
+	for _, contract := range contracts {
+		addr, err := d.s.Manifest.Address(contract)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(d.out, "Pointing %v at %v\n", names[contract], contract)
+		if err := registry.Set(ctx, d.tx, names[contract], addr); err != nil {
+			return errors.Wrapf(err, "setting ENS name %v", names[contract])
+		}
+		d.s.Manifest.Names[contract] = names[contract]
+		if err := d.s.Manifest.Save(d.s.Config.Manifest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nominate nominates owner as the next owner of the contract called name, if it is owned and
+// we control it. It reports whether the nomination now stands.
+func (d *deployer) nominate(ctx context.Context, name string, owner common.Address) (bool, error) {
+	artifact, err := d.s.Artifacts.Load(name)
+	if err != nil {
+		return false, err
+	}
+	if !artifact.HasMethod("nominateNewOwner") {
+		return false, nil
+	}
+	contract, err := d.s.Contract(name)
+	if err != nil {
+		return false, err
+	}
+	current, err := contract.CallAddress(ctx, "owner")
+	if err != nil {
+		return false, err
+	}
+	if current == owner {
+		return false, nil
+	}
+	nominee, err := contract.CallAddress(ctx, "nominatedOwner")
+	if err != nil {
+		return false, err
+	}
+	if nominee == owner {
+		return true, nil
+	}
+	return true, d.send(ctx, contract, "nominateNewOwner", []interface{}{owner})
+}
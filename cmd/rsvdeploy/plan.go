@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+// plan is a deployment: contracts to create, then calls to configure them.
+//
+// Arguments are strings, parsed according to the constructor or method they are passed to (see
+// chain.ParseArgs). An argument "@Name" stands for the manifest address of contract Name, which
+// may be one deployed earlier in the same plan.
+type plan struct {
+	// Owner, if set, is nominated as the owner of every deployed contract that has an owner,
+	// once the calls are done. It must then call acceptOwnership on each of them.
+	Owner common.Address `json:"owner,omitempty"`
+
+	Contracts []planContract `json:"contracts"`
+	Calls     []planCall     `json:"calls,omitempty"`
+
+	// Names maps contract names to ENS names to point at them, once the calls are done. Each
+	// name must exist and be ours, or have a parent that is ours.
+	Names map[string]string `json:"names,omitempty"`
+}
+
+type planContract struct {
+	// Name is both the artifact to deploy and the name to record in the manifest.
+	Name string   `json:"name"`
+	Args []string `json:"args,omitempty"`
+}
+
+type planCall struct {
+	Contract string   `json:"contract"`
+	Method   string   `json:"method"`
+	Args     []string `json:"args,omitempty"`
+}
+
+func loadPlan(path string) (*plan, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading plan")
+	}
+	var p plan
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, errors.Wrapf(err, "parsing plan %v", path)
+	}
+	seen := make(map[string]bool)
+	for _, c := range p.Contracts {
+		if c.Name == "" {
+			return nil, errors.New("plan: a contract has no name")
+		}
+		if seen[c.Name] {
+			return nil, errors.Errorf("plan: %v is deployed twice", c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return &p, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePlan(t *testing.T, dir, body string) string {
+	path := filepath.Join(dir, "plan.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(body), 0644))
+	return path
+}
+
+func TestLoadPlan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rsvdeploy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p, err := loadPlan(writePlan(t, dir, `{
+		"owner": "0x4B481872f31bab47C6780D5488c84D309b1B8Bb6",
+		"contracts": [{"name": "Vault"}, {"name": "Manager", "args": ["@Vault", "@Basket", "0"]}],
+		"calls": [{"contract": "Vault", "method": "changeManager", "args": ["@Manager"]}]
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, common.HexToAddress("0x4B481872f31bab47C6780D5488c84D309b1B8Bb6"), p.Owner)
+	assert.Len(t, p.Contracts, 2)
+	assert.Equal(t, []string{"@Vault", "@Basket", "0"}, p.Contracts[1].Args)
+	assert.Equal(t, "changeManager", p.Calls[0].Method)
+
+	_, err = loadPlan(writePlan(t, dir, `{"contracts": [{"name": "Vault"}, {"name": "Vault"}]}`))
+	assert.Error(t, err)
+	_, err = loadPlan(writePlan(t, dir, `{"contracts": [{"args": ["1"]}]}`))
+	assert.Error(t, err)
+}
+
+func TestContractSalt(t *testing.T) {
+	assert.Equal(t, contractSalt("v1", "Vault"), contractSalt("v1", "Vault"))
+	assert.NotEqual(t, contractSalt("v1", "Vault"), contractSalt("v2", "Vault"))
+	assert.NotEqual(t, contractSalt("v1", "Vault"), contractSalt("v1", "Manager"))
+}
@@ -0,0 +1,167 @@
+// Command rsvflat bundles the sources of contracts, with everything they import, for verifying the
+// deployed code on Etherscan and for handing to auditors. It resolves imports as `make json` does,
+// in a fixed order, so the same sources always bundle the same; see ops/flatten.
+//
+// `rsvflat contracts/Manager.sol` prints one file holding the source and everything it imports,
+// for Etherscan's single-file verification, or for Remix. `make flat` runs it for every file.
+//
+// `rsvflat -json Manager` prints solc's standard-JSON input for the contract Manager, with the
+// compiler settings recorded for it in build.lock.json (see cmd/rsvbuild), and then, on stderr,
+// what Etherscan's "Standard-Json-Input" verification asks for besides: the compiler and the
+// contract's name. Its files keep the paths they are built from, so the metadata hash, and so the
+// whole deployed code, matches.
+//
+// `rsvflat -dir audit` writes both, as <contract>.sol and <contract>.json, for every contract in
+// build.lock.json, along with sources.txt listing the files that each is built from.
+//
+// Usage:
+//
+//	rsvflat [-out file] <source.sol>
+//	rsvflat -json [-lock build.lock.json] [-out file] <contract>
+//	rsvflat -dir <dir> [-lock build.lock.json]
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/build"
+	"github.com/reserve-protocol/rsv-beta/ops/flatten"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("rsvflat: ")
+	asJSON := flag.Bool("json", false, "print the standard-JSON input of the named contract")
+	lockFile := flag.String("lock", "build.lock.json", "the build lock, for the compiler settings of each contract")
+	out := flag.String("out", "", "write to this file instead of standard output")
+	dir := flag.String("dir", "", "write both forms of every contract in the build lock to this directory")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: rsvflat [-out file] <source.sol>")
+		fmt.Fprintln(os.Stderr, "       rsvflat -json [-lock build.lock.json] [-out file] <contract>")
+		fmt.Fprintln(os.Stderr, "       rsvflat -dir <dir> [-lock build.lock.json]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var err error
+	switch {
+	case *dir != "" && flag.NArg() == 0:
+		err = writeAll(*dir, *lockFile)
+	case *dir == "" && flag.NArg() == 1 && *asJSON:
+		err = standardJSON(flag.Arg(0), *lockFile, *out)
+	case *dir == "" && flag.NArg() == 1:
+		var flat []byte
+		if flat, err = flatten.Flatten(".", flag.Arg(0)); err == nil {
+			err = write(*out, flat)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readLock reads the build lock, which `make build-lock` writes.
+func readLock(lockFile string) (build.Lock, error) {
+	lock, err := build.ReadFile(lockFile)
+	return lock, errors.Wrap(err, "the compiler settings come from the build lock (see `make build-lock`)")
+}
+
+// lookup returns the build record of contract.
+func lookup(lockFile, contract string) (*build.Record, error) {
+	lock, err := readLock(lockFile)
+	if err != nil {
+		return nil, err
+	}
+	r := lock[contract]
+	if r == nil {
+		return nil, errors.Errorf("%v has no record of %v; run `make build-lock`", lockFile, contract)
+	}
+	return r, nil
+}
+
+func standardJSON(contract, lockFile, out string) error {
+	r, err := lookup(lockFile, contract)
+	if err != nil {
+		return err
+	}
+	input, err := flatten.StandardJSON(".", r.Source, settings(r))
+	if err != nil {
+		return err
+	}
+	if err := write(out, input); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Verify as %v:%v, compiled with solc v%v.\n", r.Source, r.Contract, r.Compiler)
+	return nil
+}
+
+func settings(r *build.Record) flatten.Settings {
+	return flatten.Settings{Optimizer: r.Optimizer, EVMVersion: r.EVMVersion}
+}
+
+func writeAll(dir, lockFile string) error {
+	lock, err := readLock(lockFile)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "creating the bundle directory")
+	}
+	names := make([]string, 0, len(lock))
+	for name := range lock {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var list bytes.Buffer
+	for _, name := range names {
+		r := lock[name]
+		flat, err := flatten.Flatten(".", r.Source)
+		if err != nil {
+			return err
+		}
+		input, err := flatten.StandardJSON(".", r.Source, settings(r))
+		if err != nil {
+			return err
+		}
+		sources, err := flatten.Sources(".", r.Source)
+		if err != nil {
+			return err
+		}
+		if err := write(filepath.Join(dir, name+".sol"), flat); err != nil {
+			return err
+		}
+		if err := write(filepath.Join(dir, name+".json"), input); err != nil {
+			return err
+		}
+		fmt.Fprintf(&list, "%v (solc v%v, %v optimizer runs): %v\n",
+			name, r.Compiler, r.Optimizer, strings.Join(sources, " "))
+	}
+	if err := write(filepath.Join(dir, "sources.txt"), list.Bytes()); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote the sources of %v contracts to %v.\n", len(names), dir)
+	return nil
+}
+
+// write writes b to path, or to standard output if path is empty.
+func write(path string, b []byte) error {
+	if path == "" {
+		_, err := os.Stdout.Write(b)
+		return err
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, b, 0644), "writing %v", path)
+}
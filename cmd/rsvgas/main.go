@@ -0,0 +1,219 @@
+// Command rsvgas keeps the gas that each gas benchmark uses in a committed golden file, gas.json,
+// and reports how it changed between revisions, so that a change's effect on gas is part of its
+// review.
+//
+// `rsvgas run` runs the gas benchmarks, the tests in tests/ named Test...Gas, on the simulated
+// backend, and writes the gas that each used to -file; with -check, it writes nothing, and fails
+// if the committed file is out of date. The benchmarks use the bindings in abi/, so run `make abi`
+// first; `make gas` does both.
+//
+// `rsvgas diff [<old> [<new>]]` lists the benchmarks whose gas changed between two reports, each
+// a git revision whose -file is read, or a file. <old> defaults to HEAD, and <new> to -file as it
+// is, so that with no arguments it shows what the working tree changes. A revision with no report
+// committed is benchmarked instead: checked out in a temporary git worktree, where `make gas` runs.
+// With -json, it prints the same as JSON, for tools.
+//
+// Usage:
+//
+//	rsvgas run [-file gas.json] [-check]
+//	rsvgas diff [-file gas.json] [-json] [<revision or file> [<revision or file>]]
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/gas"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("rsvgas: ")
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = run(os.Args[2:])
+	case "diff":
+		err = diff(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: rsvgas run [-file gas.json] [-check]")
+	fmt.Fprintln(os.Stderr, "       rsvgas diff [-file gas.json] [-json] [<old> [<new>]]")
+	os.Exit(2)
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	file := fs.String("file", "gas.json", "the committed gas report")
+	check := fs.Bool("check", false, "fail if the committed report is out of date, instead of writing it")
+	fs.Parse(args)
+
+	tmp, err := ioutil.TempFile("", "gas")
+	if err != nil {
+		return errors.Wrap(err, "creating the report file")
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	// The tests run in tests/, so the report's path must not be relative. COVERAGE_ENABLED would
+	// run the benchmarks on instrumented contracts, which use more gas.
+	cmd := exec.Command("go", "test", "./tests", "-tags", "all", "-count", "1",
+		"-testify.m", "Gas$", "-args", "-gas-report="+tmp.Name())
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = []string{}
+	for _, v := range os.Environ() {
+		if !strings.HasPrefix(v, "COVERAGE_ENABLED=") {
+			cmd.Env = append(cmd.Env, v)
+		}
+	}
+	if err := cmd.Run(); err != nil {
+		return errors.Wrap(err, "running the gas benchmarks")
+	}
+	report, err := gas.ReadFile(tmp.Name())
+	if err != nil {
+		return err
+	}
+	if len(report) == 0 {
+		return errors.New("no gas benchmark ran")
+	}
+
+	if !*check {
+		if err := gas.WriteFile(*file, report); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote the gas of %v benchmarks to %v.\n", len(report), *file)
+		return nil
+	}
+	committed, err := gas.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	if deltas := gas.Diff(committed, report); len(deltas) > 0 {
+		for _, d := range deltas {
+			fmt.Println(d)
+		}
+		return errors.Errorf("%v is out of date for %v benchmarks; run `make gas`", *file, len(deltas))
+	}
+	fmt.Printf("%v is up to date.\n", *file)
+	return nil
+}
+
+func diff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	file := fs.String("file", "gas.json", "the committed gas report")
+	asJSON := fs.Bool("json", false, "print the changes as JSON")
+	fs.Parse(args)
+	if fs.NArg() > 2 {
+		usage()
+	}
+
+	oldSide, newSide := "HEAD", *file
+	if fs.NArg() > 0 {
+		oldSide = fs.Arg(0)
+	}
+	if fs.NArg() > 1 {
+		newSide = fs.Arg(1)
+	}
+	old, err := read(oldSide, *file)
+	if err != nil {
+		return err
+	}
+	new, err := read(newSide, *file)
+	if err != nil {
+		return err
+	}
+	deltas := gas.Diff(old, new)
+
+	if *asJSON {
+		if deltas == nil {
+			deltas = []gas.Delta{}
+		}
+		b, err := json.MarshalIndent(deltas, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	if len(deltas) == 0 {
+		fmt.Println("No benchmark's gas changed.")
+		return nil
+	}
+	for _, d := range deltas {
+		fmt.Println(d)
+	}
+	fmt.Printf("%v of %v benchmarks changed.\n", len(deltas), len(new))
+	return nil
+}
+
+// read reads a gas report: side, if it is a file, or else file as of the git revision side,
+// benchmarked if it has none.
+func read(side, file string) (gas.Report, error) {
+	if info, err := os.Stat(side); err == nil && !info.IsDir() {
+		return gas.ReadFile(side)
+	}
+	if _, err := git("cat-file", "-e", side+":./"+filepath.ToSlash(filepath.Clean(file))); err != nil {
+		return bench(side)
+	}
+	// A leading ./ makes git read the path relative to the working directory, as -file is.
+	b, err := git("show", side+":./"+filepath.ToSlash(filepath.Clean(file)))
+	if err != nil {
+		return nil, err
+	}
+	r, err := gas.Parse(b)
+	return r, errors.Wrapf(err, "%v at %v", file, side)
+}
+
+// bench returns the gas report of revision, benchmarked with `make gas` in a temporary git
+// worktree, for a revision that has none committed.
+func bench(revision string) (gas.Report, error) {
+	tmp, err := ioutil.TempDir("", "rsvgas")
+	if err != nil {
+		return nil, errors.Wrap(err, "making a directory for the worktree")
+	}
+	defer os.RemoveAll(tmp)
+	worktree := filepath.Join(tmp, "tree")
+	if _, err := git("worktree", "add", "--detach", worktree, revision); err != nil {
+		return nil, err
+	}
+	defer git("worktree", "remove", "--force", worktree)
+
+	fmt.Fprintf(os.Stderr, "No gas report is committed at %v; running the benchmarks.\n", revision)
+	cmd := exec.Command("make", "gas")
+	cmd.Dir = worktree
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "benchmarking %v", revision)
+	}
+	report, err := gas.ReadFile(filepath.Join(worktree, "gas.json"))
+	return report, errors.Wrap(err, revision)
+}
+
+// git runs a git command and returns its output.
+func git(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	return out, errors.Wrapf(err, "git %v: %s", strings.Join(args, " "), bytes.TrimSpace(stderr.Bytes()))
+}
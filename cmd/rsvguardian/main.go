@@ -0,0 +1,140 @@
+// Command rsvguardian runs the guardian: it checks the deployment's backing and its basket
+// tokens' oracle prices at every block and, when a threshold is crossed, pauses the Reserve from
+// the guardian key, which must hold the Reserve's guardian or pauser role, and alerts the
+// configured webhooks. The guardian role can pause but not unpause, so it is the one to give
+// an unattended key.
+//
+// In "dry-run" mode it checks that the pause would succeed without sending it, and in "alert"
+// mode, the default, it only alerts.
+//
+// Usage:
+//
+//	rsvguardian [-config rsvguardian.json]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/reserve-protocol/rsv-beta/ops/emergency"
+	"github.com/reserve-protocol/rsv-beta/ops/guardian"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// config is the rsvguardian configuration file.
+type config struct {
+	session.Config
+
+	// Feeds are the Chainlink price feeds of the basket tokens.
+	Feeds      []guardian.Feed     `json:"feeds,omitempty"`
+	Thresholds guardian.Thresholds `json:"thresholds"`
+
+	// Mode is "pause", "dry-run", or "alert" (default).
+	Mode string `json:"mode,omitempty"`
+
+	// Consecutive is how many readings in a row must cross a threshold before the guardian acts
+	// (default 1).
+	Consecutive int `json:"consecutive,omitempty"`
+
+	// GasPricePercent scales the node's suggested gas price for the pause (default 150), so
+	// that it is mined in the next block.
+	GasPricePercent uint64 `json:"gasPricePercent,omitempty"`
+
+	Webhooks []emergency.Webhook `json:"webhooks"`
+
+	// PollSeconds is how often to read the chain (default 3), under the block time.
+	PollSeconds int `json:"pollSeconds,omitempty"`
+
+	// LogFile, if set, receives the service log, rotated as it grows. Otherwise it goes to stderr.
+	LogFile string `json:"logFile,omitempty"`
+}
+
+func main() {
+	log.SetPrefix("rsvguardian: ")
+	configPath := flag.String("config", "rsvguardian.json", "configuration file")
+	flag.Parse()
+
+	var c config
+	if err := session.LoadConfig(*configPath, &c); err != nil {
+		log.Fatal(err)
+	}
+	if c.LogFile != "" {
+		log.SetOutput(&lumberjack.Logger{Filename: c.LogFile, MaxSize: 100, MaxBackups: 10})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		log.Printf("received %v, shutting down", <-sigs)
+		cancel()
+	}()
+
+	if err := run(ctx, c); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, c config) error {
+	if c.Mode == "" {
+		c.Mode = guardian.AlertOnly
+	}
+	switch c.Mode {
+	case guardian.Pause, guardian.DryRun, guardian.AlertOnly:
+	default:
+		return errors.Errorf("config: mode must be %q, %q, or %q, got %q", guardian.Pause, guardian.DryRun, guardian.AlertOnly, c.Mode)
+	}
+	if len(c.Webhooks) == 0 {
+		return errors.New("config: webhooks is not set")
+	}
+	if c.GasPricePercent == 0 {
+		c.GasPricePercent = 150
+	}
+	if c.PollSeconds == 0 {
+		c.PollSeconds = 3
+	}
+	notifier, err := emergency.NewNotifier(c.Webhooks)
+	if err != nil {
+		return err
+	}
+
+	// The guardian runs unattended, so it has no network interlock; session.Open still refuses
+	// a node, config, or manifest on the wrong chain.
+	s, err := session.Open(ctx, c.Config, "rsvguardian")
+	if err != nil {
+		return err
+	}
+	ch, err := guardian.NewChain(ctx, s, c.Feeds)
+	if err != nil {
+		return err
+	}
+	g := &guardian.Guardian{
+		Read:        ch.Read,
+		Thresholds:  c.Thresholds,
+		Mode:        c.Mode,
+		Consecutive: c.Consecutive,
+		Post:        notifier.Notify,
+		Network:     c.Network,
+		Interval:    time.Duration(c.PollSeconds) * time.Second,
+	}
+	if c.Mode != guardian.AlertOnly {
+		// Refuse to start with a key that couldn't pause, rather than find out in a breach.
+		if err := ch.CheckPauser(ctx); err != nil {
+			return err
+		}
+		s.Transactor.GasPricePercent = c.GasPricePercent
+		if g.Pauser, err = ch.Pauser(); err != nil {
+			return err
+		}
+	}
+	log.Printf("guarding %v in %v mode, every %v", c.Network, c.Mode, g.Interval)
+	return g.Run(ctx)
+}
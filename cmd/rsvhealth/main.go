@@ -0,0 +1,132 @@
+// Command rsvhealth serves the deployment's health at /healthz: whether the node answers and is
+// current, every manifest contract has code, the switches are where they should be, and the
+// owners are the expected addresses, for load balancers and uptime monitors.
+//
+// Usage:
+//
+//	rsvhealth [-config rsvhealth.json]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/reserve-protocol/rsv-beta/ops/health"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// config is the rsvhealth configuration file.
+type config struct {
+	session.Config
+
+	// Expect is what the deployment is expected to be.
+	Expect struct {
+		// Switches are the expected positions of "Reserve.paused", "Reserve.transfersPaused",
+		// "Manager.issuancePaused", "Manager.redemptionPaused", and "Manager.emergency"; those
+		// not given are expected off.
+		Switches map[string]bool `json:"switches,omitempty"`
+
+		// Owners are the expected owners, by manifest contract name.
+		Owners map[string]common.Address `json:"owners,omitempty"`
+	} `json:"expect"`
+
+	// MaxBlockAgeSeconds is how old the latest block may be (default 300).
+	MaxBlockAgeSeconds int `json:"maxBlockAgeSeconds,omitempty"`
+
+	// Listen is the address to serve /healthz on (default ":9700").
+	Listen string `json:"listen,omitempty"`
+
+	// PollSeconds is how often to check (default 15).
+	PollSeconds int `json:"pollSeconds,omitempty"`
+
+	// LogFile, if set, receives the service log, rotated as it grows. Otherwise it goes to stderr.
+	LogFile string `json:"logFile,omitempty"`
+}
+
+func main() {
+	log.SetPrefix("rsvhealth: ")
+	configPath := flag.String("config", "rsvhealth.json", "configuration file")
+	flag.Parse()
+
+	var c config
+	if err := session.LoadConfig(*configPath, &c); err != nil {
+		log.Fatal(err)
+	}
+	if c.LogFile != "" {
+		log.SetOutput(&lumberjack.Logger{Filename: c.LogFile, MaxSize: 100, MaxBackups: 10})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		log.Printf("received %v, shutting down", <-sigs)
+		cancel()
+	}()
+
+	if err := run(ctx, c); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, c config) error {
+	if c.MaxBlockAgeSeconds == 0 {
+		c.MaxBlockAgeSeconds = 300
+	}
+	if c.Listen == "" {
+		c.Listen = ":9700"
+	}
+	if c.PollSeconds == 0 {
+		c.PollSeconds = 15
+	}
+
+	s, err := session.Open(ctx, c.Config, "rsvhealth")
+	if err != nil {
+		return err
+	}
+	var owners []string
+	for name := range c.Expect.Owners {
+		owners = append(owners, name)
+	}
+	reader, err := health.NewReader(s, owners)
+	if err != nil {
+		return err
+	}
+	checker := &health.Checker{
+		Read: reader.Read,
+		Expect: health.Expect{
+			Switches:    c.Expect.Switches,
+			Owners:      c.Expect.Owners,
+			MaxBlockAge: time.Duration(c.MaxBlockAgeSeconds) * time.Second,
+		},
+		Network:  c.Network,
+		Interval: time.Duration(c.PollSeconds) * time.Second,
+	}
+
+	listener, err := net.Listen("tcp", c.Listen)
+	if err != nil {
+		return errors.Wrap(err, "listening")
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", checker)
+	server := &http.Server{Handler: mux, ReadTimeout: 10 * time.Second, WriteTimeout: 10 * time.Second}
+	defer server.Close()
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	log.Printf("serving the health of %v on %v", c.Network, listener.Addr())
+	return checker.Run(ctx)
+}
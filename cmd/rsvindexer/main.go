@@ -0,0 +1,361 @@
+// Command rsvindexer runs the event indexer: it follows the chain and stores every event of the
+// deployment's Reserve, Manager, and Vault in a SQLite or Postgres database. It can also keep
+// time series, flag anomalous transfers, record what the operator keys spend on gas, and keep an
+// audit trail of privileged operations.
+//
+// Usage:
+//
+//	rsvindexer [-config rsvindexer.json]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"math/big"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/reserve-protocol/rsv-beta/ops/backfill"
+	"github.com/reserve-protocol/rsv-beta/ops/emergency"
+	"github.com/reserve-protocol/rsv-beta/ops/indexer"
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/safe"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+// config is the rsvindexer configuration file.
+type config struct {
+	session.Config
+
+	Database struct {
+		// Driver is "sqlite3" or "postgres".
+		Driver string `json:"driver"`
+
+		// DSN is the data source name: a file path for SQLite, or a connection string for
+		// Postgres. For a DSN with a password in it, set DSNEnv to the name of an
+		// environment variable holding it instead.
+		DSN    string `json:"dsn,omitempty"`
+		DSNEnv string `json:"dsnEnv,omitempty"`
+	} `json:"database"`
+
+	// Contracts are the manifest contracts to index; by default the Reserve, Manager, and Vault.
+	Contracts []string `json:"contracts,omitempty"`
+
+	// FromBlock is where to start indexing, the first time; the earliest deployment block of
+	// the contracts is enough.
+	FromBlock uint64 `json:"fromBlock"`
+
+	Confirmations uint64 `json:"confirmations"`
+
+	// Depth is how many blocks beyond the confirmations a reorganization may reach and have
+	// its events deleted, rather than stop the indexer (default 64).
+	Depth uint64 `json:"depth,omitempty"`
+
+	// Chunk is the most blocks fetched per log query (default 10000).
+	Chunk uint64 `json:"chunk,omitempty"`
+
+	// PollSeconds is how often to check for new blocks (default 15).
+	PollSeconds int `json:"pollSeconds,omitempty"`
+
+	// Series, if set, also materializes time series of the supply and backing.
+	Series *struct {
+		// Intervals are the series to keep: "hour", "day", or both.
+		Intervals []string `json:"intervals"`
+
+		// Backing also records the Vault's balance of each basket token at the end of each
+		// bucket. Catching up on past buckets needs an archive node.
+		Backing bool `json:"backing,omitempty"`
+	} `json:"series,omitempty"`
+
+	// Anomalies, if set, flags the Reserve's transfers that look anomalous for review, and
+	// posts an alert for each to its webhooks. Amounts are in RSV; each rule is optional.
+	Anomalies *struct {
+		// LargeTransfer flags each transfer, mint, or burn of more than this amount.
+		LargeTransfer string `json:"largeTransfer,omitempty"`
+
+		// Concentration flags an account receiving more than percent of the supply within
+		// blocks blocks.
+		Concentration *struct {
+			Percent float64 `json:"percent"`
+			Blocks  uint64  `json:"blocks"`
+		} `json:"concentration,omitempty"`
+
+		// MintBurst flags more than amount being minted within blocks blocks.
+		MintBurst *struct {
+			Amount string `json:"amount"`
+			Blocks uint64 `json:"blocks"`
+		} `json:"mintBurst,omitempty"`
+
+		Webhooks []emergency.Webhook `json:"webhooks,omitempty"`
+	} `json:"anomalies,omitempty"`
+
+	// Backfill, if set, fetches the history too old to be reorganized with adaptive chunks, at
+	// a limited rate, as a new indexer starting from a block long past needs to against a
+	// rate-limited provider. Every field is optional.
+	Backfill *struct {
+		// Chunk is the range of the first query (default 1000 blocks); queries then range
+		// between MinChunk (default 1) and MaxChunk (default 100000) blocks.
+		Chunk    uint64 `json:"chunk,omitempty"`
+		MinChunk uint64 `json:"minChunk,omitempty"`
+		MaxChunk uint64 `json:"maxChunk,omitempty"`
+
+		// TargetLogs is about how many logs a query should return (default 2000).
+		TargetLogs int `json:"targetLogs,omitempty"`
+
+		// RequestsPerSecond is the most requests a second; by default, no limit.
+		RequestsPerSecond float64 `json:"requestsPerSecond,omitempty"`
+
+		// MaxRetries is how many times a failing request is tried again (default 8).
+		MaxRetries int `json:"maxRetries,omitempty"`
+	} `json:"backfill,omitempty"`
+
+	// Gas, if set, records the gas that each operator key pays, for rsvapi to serve, and
+	// alerts when one spends more than a budget.
+	Gas *struct {
+		// Operators maps names, such as "relayer", to the addresses of the keys to track.
+		Operators map[string]common.Address `json:"operators"`
+
+		// Budgets are the most an operator should spend on gas each "day", "week", or "month";
+		// limits are in ETH.
+		Budgets []struct {
+			Operator string `json:"operator"`
+			Period   string `json:"period"`
+			Limit    string `json:"limit"`
+		} `json:"budgets,omitempty"`
+
+		Webhooks []emergency.Webhook `json:"webhooks,omitempty"`
+	} `json:"gas,omitempty"`
+
+	// Admin, if set, keeps an audit trail of every transaction that made a privileged
+	// operation, with the Safe transaction, if any, behind it, for rsvapi to serve.
+	Admin *struct {
+		// SafeService, if set, is the Safe Transaction Service to read who proposed each Safe
+		// transaction, and when each owner confirmed it, such as
+		// "https://safe-transaction-mainnet.safe.global".
+		SafeService string `json:"safeService,omitempty"`
+
+		// SafeLink, if set, is the template of a link to each Safe transaction, with {safe}
+		// and {safeTxHash} in it.
+		SafeLink string `json:"safeLink,omitempty"`
+	} `json:"admin,omitempty"`
+
+	// LogFile, if set, receives the service log, rotated as it grows. Otherwise it goes to stderr.
+	LogFile string `json:"logFile,omitempty"`
+}
+
+func main() {
+	log.SetPrefix("rsvindexer: ")
+	configPath := flag.String("config", "rsvindexer.json", "configuration file")
+	flag.Parse()
+
+	var c config
+	if err := session.LoadConfig(*configPath, &c); err != nil {
+		log.Fatal(err)
+	}
+	if c.LogFile != "" {
+		log.SetOutput(&lumberjack.Logger{Filename: c.LogFile, MaxSize: 100, MaxBackups: 10})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		log.Printf("received %v, shutting down", <-sigs)
+		cancel()
+	}()
+
+	if err := run(ctx, c); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, c config) error {
+	dsn := c.Database.DSN
+	if c.Database.DSNEnv != "" {
+		if dsn = os.Getenv(c.Database.DSNEnv); dsn == "" {
+			return errors.Errorf("config: environment variable %v is not set", c.Database.DSNEnv)
+		}
+	}
+	if dsn == "" {
+		return errors.New("config: database dsn is not set")
+	}
+	if len(c.Contracts) == 0 {
+		c.Contracts = []string{"Reserve", "Manager", "Vault"}
+	}
+	if c.Chunk == 0 {
+		c.Chunk = 10000
+	}
+	if c.PollSeconds == 0 {
+		c.PollSeconds = 15
+	}
+
+	s, err := session.Open(ctx, c.Config, "rsvindexer")
+	if err != nil {
+		return err
+	}
+	var contracts []indexer.Contract
+	for _, name := range c.Contracts {
+		contract, err := s.Contract(name)
+		if err != nil {
+			return err
+		}
+		artifact, err := s.Artifacts.Load(name)
+		if err != nil {
+			return err
+		}
+		contracts = append(contracts, indexer.Contract{Name: name, Address: contract.Address, Artifact: artifact})
+	}
+	store, err := indexer.OpenStore(c.Database.Driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ix := &indexer.Indexer{
+		Node:          s.Client,
+		Store:         store,
+		Contracts:     contracts,
+		Name:          c.Network,
+		From:          c.FromBlock,
+		Confirmations: c.Confirmations,
+		Chunk:         c.Chunk,
+		Depth:         c.Depth,
+		PollInterval:  time.Duration(c.PollSeconds) * time.Second,
+	}
+	if c.Series != nil {
+		for _, name := range c.Series.Intervals {
+			if _, ok := indexer.Intervals[name]; !ok {
+				return errors.Errorf("config: unknown series interval %q", name)
+			}
+		}
+		ix.Series = &indexer.Series{Node: s.Client, Store: store, Indexer: ix.Name, Intervals: c.Series.Intervals}
+		if c.Series.Backing {
+			reader, err := metrics.NewReader(s)
+			if err != nil {
+				return err
+			}
+			ix.Series.ReadAt = reader.ReadAt
+		}
+	}
+	if b := c.Backfill; b != nil {
+		ix.Backfill = &backfill.Walker{
+			Chunk:      b.Chunk,
+			MinChunk:   b.MinChunk,
+			MaxChunk:   b.MaxChunk,
+			TargetLogs: b.TargetLogs,
+			Rate:       b.RequestsPerSecond,
+			MaxRetries: b.MaxRetries,
+		}
+	}
+	if c.Anomalies != nil {
+		if ix.Anomalies, err = anomalies(c); err != nil {
+			return err
+		}
+		ix.Anomalies.Store, ix.Anomalies.Indexer = store, ix.Name
+	}
+	if c.Gas != nil {
+		if ix.Gas, err = gas(c); err != nil {
+			return err
+		}
+		ix.Gas.Node, ix.Gas.Store, ix.Gas.Indexer = s.Client, store, ix.Name
+	}
+	if c.Admin != nil {
+		ix.Admin = &indexer.Admin{
+			Node:      s.Client,
+			Store:     store,
+			Indexer:   ix.Name,
+			Contracts: contracts,
+			ChainID:   new(big.Int).SetUint64(c.ChainID),
+			Depth:     c.Depth,
+			Link:      c.Admin.SafeLink,
+		}
+		if c.Admin.SafeService != "" {
+			ix.Admin.Service = safe.NewService(c.Admin.SafeService)
+		}
+	}
+	log.Printf("indexing %v on %v into %v", c.Contracts, c.Network, c.Database.Driver)
+	return ix.Run(ctx)
+}
+
+// anomalies returns the Anomalies that c configures, without its store.
+func anomalies(c config) (*indexer.Anomalies, error) {
+	a := &indexer.Anomalies{Network: c.Network}
+	r := c.Anomalies
+	if r.LargeTransfer != "" {
+		amount, err := units.Parse(r.LargeTransfer, 18)
+		if err != nil {
+			return nil, errors.Wrap(err, "config: anomalies: largeTransfer")
+		}
+		a.Rules.LargeTransfer = amount
+	}
+	if r.Concentration != nil {
+		if r.Concentration.Percent <= 0 || r.Concentration.Blocks == 0 {
+			return nil, errors.New("config: anomalies: concentration needs percent and blocks")
+		}
+		a.Rules.Concentration = &indexer.ConcentrationRule{Percent: r.Concentration.Percent, Blocks: r.Concentration.Blocks}
+	}
+	if r.MintBurst != nil {
+		amount, err := units.Parse(r.MintBurst.Amount, 18)
+		if err != nil {
+			return nil, errors.Wrap(err, "config: anomalies: mintBurst amount")
+		}
+		if r.MintBurst.Blocks == 0 {
+			return nil, errors.New("config: anomalies: mintBurst needs blocks")
+		}
+		a.Rules.MintBurst = &indexer.MintBurstRule{Amount: amount, Blocks: r.MintBurst.Blocks}
+	}
+	if len(r.Webhooks) > 0 {
+		notifier, err := emergency.NewNotifier(r.Webhooks)
+		if err != nil {
+			return nil, err
+		}
+		a.Post = notifier.Notify
+	}
+	return a, nil
+}
+
+// gas returns the Gas that c configures, without its node and store.
+func gas(c config) (*indexer.Gas, error) {
+	g := &indexer.Gas{ChainID: new(big.Int).SetUint64(c.ChainID), Network: c.Network}
+	names := make([]string, 0, len(c.Gas.Operators))
+	for name := range c.Gas.Operators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.Operators = append(g.Operators, indexer.Operator{Name: name, Address: c.Gas.Operators[name]})
+	}
+	if len(g.Operators) == 0 {
+		return nil, errors.New("config: gas: no operators")
+	}
+	for _, b := range c.Gas.Budgets {
+		if _, ok := c.Gas.Operators[b.Operator]; !ok {
+			return nil, errors.Errorf("config: gas: budget of unknown operator %q", b.Operator)
+		}
+		if _, err := indexer.PeriodStart(b.Period, time.Now()); err != nil {
+			return nil, errors.Wrapf(err, "config: gas: budget of %v", b.Operator)
+		}
+		limit, err := units.Parse(b.Limit, 18)
+		if err != nil {
+			return nil, errors.Wrapf(err, "config: gas: budget of %v", b.Operator)
+		}
+		g.Budgets = append(g.Budgets, indexer.Budget{Operator: b.Operator, Period: b.Period, Limit: limit})
+	}
+	if len(c.Gas.Webhooks) > 0 {
+		notifier, err := emergency.NewNotifier(c.Gas.Webhooks)
+		if err != nil {
+			return nil, err
+		}
+		g.Post = notifier.Notify
+	}
+	return g, nil
+}
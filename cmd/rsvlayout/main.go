@@ -0,0 +1,277 @@
+// Command rsvlayout keeps the storage layout of every contract in committed JSON files, one per
+// contract in layouts/, and reports how the layouts changed between revisions.
+//
+// `rsvlayout extract` computes the layouts from the artifacts that `make json` writes, as
+// `rsvadmin check-layout` does, and writes them to -dir, removing the layouts of contracts that
+// are gone; with -check, it writes nothing, and fails if the committed layouts are out of date.
+//
+// `rsvlayout diff <old> [<new>]` lists, for each contract, the variables added, removed, moved,
+// renamed, and retyped between two sets of layouts, each a git revision whose -dir is read, or a
+// directory; <new> defaults to -dir as it is, or, if there is no -dir, to the artifacts in
+// -artifacts. A revision with no layouts committed is built instead: checked out in a temporary
+// git worktree, where `make json` compiles it. It also lists the changes that a proxy could not
+// take, as `rsvadmin check-layout` would fail on them. With -json, it prints the same as JSON,
+// for tools.
+//
+// Usage:
+//
+//	rsvlayout extract [-artifacts evm] [-dir layouts] [-check]
+//	rsvlayout diff [-dir layouts] [-artifacts evm] [-json] <revision or directory> [<revision or directory>]
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/layout"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("rsvlayout: ")
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "extract":
+		err = extract(os.Args[2:])
+	case "diff":
+		err = diff(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: rsvlayout extract [-artifacts evm] [-dir layouts] [-check]")
+	fmt.Fprintln(os.Stderr, "       rsvlayout diff [-dir layouts] [-artifacts evm] [-json] <old> [<new>]")
+	os.Exit(2)
+}
+
+func extract(args []string) error {
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	artifacts := fs.String("artifacts", "evm", "the artifacts that `make json` writes")
+	dir := fs.String("dir", "layouts", "where the layouts are committed")
+	check := fs.Bool("check", false, "fail if the committed layouts are out of date, instead of writing them")
+	fs.Parse(args)
+
+	layouts, err := layout.LoadAll(*artifacts)
+	if err != nil {
+		return err
+	}
+	if !*check {
+		if err := layout.WriteDir(*dir, layouts); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote the layouts of %v contracts to %v.\n", len(layouts), *dir)
+		return nil
+	}
+
+	committed, err := layout.ReadDir(*dir)
+	if err != nil {
+		return err
+	}
+	var stale []string
+	for name, l := range layouts {
+		want, err := l.Marshal()
+		if err != nil {
+			return err
+		}
+		have, err := ioutil.ReadFile(filepath.Join(*dir, name+".json"))
+		if err != nil || !bytes.Equal(want, have) {
+			stale = append(stale, name)
+		}
+	}
+	for name := range committed {
+		if _, ok := layouts[name]; !ok {
+			stale = append(stale, name)
+		}
+	}
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		return errors.Errorf("the layouts in %v are out of date for %v; run `make layouts`", *dir, strings.Join(stale, ", "))
+	}
+	fmt.Printf("The layouts in %v are up to date.\n", *dir)
+	return nil
+}
+
+// contractDiff is how one contract's layout changed.
+type contractDiff struct {
+	Contract string          `json:"contract"`
+	Status   string          `json:"status"` // "added", "removed", or "changed"
+	Changes  []layout.Change `json:"changes,omitempty"`
+
+	// Incompatible are the changes that a proxy holding the old layout could not take.
+	Incompatible []layout.Change `json:"incompatible,omitempty"`
+}
+
+func diff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	dir := fs.String("dir", "layouts", "where the layouts are committed")
+	artifacts := fs.String("artifacts", "evm", "the artifacts that `make json` writes, to compare if there is no -dir")
+	asJSON := fs.Bool("json", false, "print the changes as JSON")
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		usage()
+	}
+
+	old, err := read(fs.Arg(0), *dir)
+	if err != nil {
+		return err
+	}
+	var new map[string]*layout.Layout
+	switch _, statErr := os.Stat(*dir); {
+	case fs.NArg() == 2:
+		new, err = read(fs.Arg(1), *dir)
+	case os.IsNotExist(statErr):
+		new, err = layout.LoadAll(*artifacts)
+	default:
+		new, err = read(*dir, *dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	diffs := []contractDiff{}
+	for _, name := range names {
+		o, n := old[name], new[name]
+		switch {
+		case o == nil:
+			diffs = append(diffs, contractDiff{Contract: name, Status: layout.Added})
+		case n == nil:
+			diffs = append(diffs, contractDiff{Contract: name, Status: layout.Removed})
+		default:
+			changes := layout.Diff(o, n)
+			if len(changes) == 0 {
+				continue
+			}
+			diffs = append(diffs, contractDiff{
+				Contract:     name,
+				Status:       "changed",
+				Changes:      changes,
+				Incompatible: layout.Incompatible(layout.Compare(o, n)),
+			})
+		}
+	}
+
+	if *asJSON {
+		b, err := json.MarshalIndent(diffs, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	if len(diffs) == 0 {
+		fmt.Println("No layout changed.")
+	}
+	for _, d := range diffs {
+		if d.Status != "changed" {
+			fmt.Printf("%v: %v\n", d.Contract, d.Status)
+			continue
+		}
+		fmt.Printf("%v:\n", d.Contract)
+		for _, c := range d.Changes {
+			fmt.Printf("  %v\n", c)
+		}
+		if len(d.Incompatible) > 0 {
+			fmt.Printf("  Behind a proxy, %v changes would corrupt its storage:\n", len(d.Incompatible))
+			for _, c := range d.Incompatible {
+				fmt.Printf("    %v\n", c)
+			}
+		}
+	}
+	return nil
+}
+
+// read reads a set of layouts: those in side, if it is a directory, or else those in dir as of
+// the git revision side, built from its source if it has none.
+func read(side, dir string) (map[string]*layout.Layout, error) {
+	if info, err := os.Stat(side); err == nil && info.IsDir() {
+		return layout.ReadDir(side)
+	}
+	prefix := path.Clean(filepath.ToSlash(dir)) + "/"
+	list, err := git("ls-tree", "--name-only", side, "--", prefix)
+	if err != nil {
+		return nil, err
+	}
+	layouts := make(map[string]*layout.Layout)
+	for _, file := range strings.Fields(string(list)) {
+		if !strings.HasSuffix(file, ".json") {
+			continue
+		}
+		b, err := git("show", side+":"+file)
+		if err != nil {
+			return nil, err
+		}
+		l, err := layout.Parse(b)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%v at %v", file, side)
+		}
+		layouts[l.Contract] = l
+	}
+	if len(layouts) == 0 {
+		return build(side)
+	}
+	return layouts, nil
+}
+
+// build returns the layouts of the contracts as of revision, compiled with `make json` in a
+// temporary git worktree, for a revision that has none committed.
+func build(revision string) (map[string]*layout.Layout, error) {
+	tmp, err := ioutil.TempDir("", "rsvlayout")
+	if err != nil {
+		return nil, errors.Wrap(err, "making a directory for the worktree")
+	}
+	defer os.RemoveAll(tmp)
+	worktree := filepath.Join(tmp, "tree")
+	if _, err := git("worktree", "add", "--detach", worktree, revision); err != nil {
+		return nil, err
+	}
+	defer git("worktree", "remove", "--force", worktree)
+
+	fmt.Fprintf(os.Stderr, "No layouts are committed at %v; building them.\n", revision)
+	cmd := exec.Command("make", "json")
+	cmd.Dir = worktree
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "building %v", revision)
+	}
+	layouts, err := layout.LoadAll(filepath.Join(worktree, "evm"))
+	return layouts, errors.Wrap(err, revision)
+}
+
+// git runs a git command and returns its output.
+func git(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	return out, errors.Wrapf(err, "git %v: %s", strings.Join(args, " "), bytes.TrimSpace(stderr.Bytes()))
+}
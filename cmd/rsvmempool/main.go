@@ -0,0 +1,143 @@
+// Command rsvmempool runs the mempool watcher: it follows the node's pending transactions, and
+// posts a message to chat webhooks for each privileged call to the deployment's contracts from
+// a sender not expected to make it, before the call is mined.
+//
+// Usage:
+//
+//	rsvmempool [-config rsvmempool.json]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/emergency"
+	"github.com/reserve-protocol/rsv-beta/ops/ens"
+	"github.com/reserve-protocol/rsv-beta/ops/mempool"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// config is the rsvmempool configuration file.
+type config struct {
+	session.Config
+
+	// Webhooks receive a message for each unexpected call.
+	Webhooks []emergency.Webhook `json:"webhooks"`
+
+	// Contracts are the manifest contracts to watch; by default the Reserve, Manager, and Vault.
+	Contracts []string `json:"contracts,omitempty"`
+
+	// Expected lists, for a privileged call such as "Reserve.mint", the senders expected to make
+	// it, in place of the holders of the roles that may.
+	Expected map[string][]common.Address `json:"expected,omitempty"`
+
+	// RolesSeconds is how often to read the role holders again (default 60).
+	RolesSeconds int `json:"rolesSeconds,omitempty"`
+
+	// PollSeconds is how often to poll for pending transactions when the rpc connection cannot
+	// subscribe to them, as over HTTP (default 1).
+	PollSeconds int `json:"pollSeconds,omitempty"`
+
+	// LogFile, if set, receives the service log, rotated as it grows. Otherwise it goes to stderr.
+	LogFile string `json:"logFile,omitempty"`
+}
+
+func main() {
+	log.SetPrefix("rsvmempool: ")
+	configPath := flag.String("config", "rsvmempool.json", "configuration file")
+	flag.Parse()
+
+	var c config
+	if err := session.LoadConfig(*configPath, &c); err != nil {
+		log.Fatal(err)
+	}
+	if c.LogFile != "" {
+		log.SetOutput(&lumberjack.Logger{Filename: c.LogFile, MaxSize: 100, MaxBackups: 10})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		log.Printf("received %v, shutting down", <-sigs)
+		cancel()
+	}()
+
+	if err := run(ctx, c); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, c config) error {
+	if len(c.Webhooks) == 0 {
+		return errors.New("config: no webhooks are set")
+	}
+	notifier, err := emergency.NewNotifier(c.Webhooks)
+	if err != nil {
+		return err
+	}
+	if len(c.Contracts) == 0 {
+		c.Contracts = []string{"Reserve", "Manager", "Vault"}
+	}
+	if c.RolesSeconds == 0 {
+		c.RolesSeconds = 60
+	}
+	if c.PollSeconds == 0 {
+		c.PollSeconds = 1
+	}
+
+	s, err := session.Open(ctx, c.Config, "rsvmempool")
+	if err != nil {
+		return err
+	}
+	var contracts []*chain.Contract
+	for _, name := range c.Contracts {
+		if _, ok := mempool.Permissions[name]; !ok {
+			return errors.Errorf("config: %v has no privileged calls to watch", name)
+		}
+		contract, err := s.Contract(name)
+		if err != nil {
+			return err
+		}
+		contracts = append(contracts, contract)
+	}
+	for call := range c.Expected {
+		found := false
+		for name, methods := range mempool.Permissions {
+			for method := range methods {
+				found = found || call == name+"."+method
+			}
+		}
+		if !found {
+			return errors.Errorf("config: expected: %v is not a privileged call", call)
+		}
+	}
+	// Labels are a convenience, so errors finding the ENS registry are ignored.
+	registry, _ := ens.Open(ctx, s.Client)
+
+	w := &mempool.Watcher{
+		Node:          s.Client,
+		Pending:       mempool.Pending(s.Client.RPC, time.Duration(c.PollSeconds)*time.Second),
+		Signer:        types.NewEIP155Signer(s.ChainID),
+		Contracts:     contracts,
+		Roles:         mempool.Roles(s.Client, contracts),
+		Expected:      c.Expected,
+		Post:          notifier.Notify,
+		Label:         ens.NewNamer(registry, s.Manifest).Label,
+		Network:       c.Network,
+		RolesInterval: time.Duration(c.RolesSeconds) * time.Second,
+	}
+	log.Printf("watching pending calls to %v on %v", c.Contracts, c.Network)
+	return w.Run(ctx)
+}
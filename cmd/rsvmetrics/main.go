@@ -0,0 +1,137 @@
+// Command rsvmetrics runs the metrics exporter: it reads the deployment's supply, collateral,
+// switches, pending proposals, and admin roles every poll, and serves them to Prometheus at
+// /metrics.
+//
+// With -dashboard, it instead writes a Grafana dashboard of its metrics, the relayer's,
+// rsvreconcile's, and rsvapi's to the file ("-" for stdout), ready to import, and exits.
+//
+// Usage:
+//
+//	rsvmetrics [-config rsvmetrics.json]
+//	rsvmetrics -dashboard rsv-dashboard.json
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/reserve-protocol/rsv-beta/ops/api"
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/reconcile"
+	"github.com/reserve-protocol/rsv-beta/ops/relay"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// config is the rsvmetrics configuration file.
+type config struct {
+	session.Config
+
+	// Listen is the address to serve metrics on (default ":9680").
+	Listen string `json:"listen,omitempty"`
+
+	// PollSeconds is how often to read the chain (default 30).
+	PollSeconds int `json:"pollSeconds,omitempty"`
+
+	// LogFile, if set, receives the service log, rotated as it grows. Otherwise it goes to stderr.
+	LogFile string `json:"logFile,omitempty"`
+}
+
+func main() {
+	log.SetPrefix("rsvmetrics: ")
+	configPath := flag.String("config", "rsvmetrics.json", "configuration file")
+	dashboard := flag.String("dashboard", "", "write a Grafana dashboard to this file (\"-\" for stdout) and exit")
+	flag.Parse()
+
+	if *dashboard != "" {
+		if err := writeDashboard(*dashboard); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var c config
+	if err := session.LoadConfig(*configPath, &c); err != nil {
+		log.Fatal(err)
+	}
+	if c.LogFile != "" {
+		log.SetOutput(&lumberjack.Logger{Filename: c.LogFile, MaxSize: 100, MaxBackups: 10})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		log.Printf("received %v, shutting down", <-sigs)
+		cancel()
+	}()
+
+	if err := run(ctx, c); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+// writeDashboard writes the dashboard of all the ops metrics to path.
+func writeDashboard(path string) error {
+	var defs []metrics.Definition
+	defs = append(defs, metrics.Definitions...)
+	defs = append(defs, relay.Definitions...)
+	defs = append(defs, reconcile.Definitions...)
+	defs = append(defs, api.Definitions...)
+	if path == "-" {
+		return metrics.WriteDashboard(os.Stdout, "RSV", "rsv", defs)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "creating dashboard file")
+	}
+	if err := metrics.WriteDashboard(f, "RSV", "rsv", defs); err != nil {
+		f.Close()
+		return err
+	}
+	return errors.Wrap(f.Close(), "closing dashboard file")
+}
+
+func run(ctx context.Context, c config) error {
+	if c.Listen == "" {
+		c.Listen = ":9680"
+	}
+	if c.PollSeconds == 0 {
+		c.PollSeconds = 30
+	}
+
+	s, err := session.Open(ctx, c.Config, "rsvmetrics")
+	if err != nil {
+		return err
+	}
+	reader, err := metrics.NewReader(s)
+	if err != nil {
+		return err
+	}
+	exporter := &metrics.Exporter{Read: reader.Read, Interval: time.Duration(c.PollSeconds) * time.Second}
+
+	listener, err := net.Listen("tcp", c.Listen)
+	if err != nil {
+		return errors.Wrap(err, "listening")
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	server := &http.Server{Handler: mux}
+	defer server.Close()
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	log.Printf("serving metrics for %v on %v", c.Network, listener.Addr())
+	return exporter.Run(ctx)
+}
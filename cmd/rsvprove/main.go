@@ -0,0 +1,204 @@
+// Command rsvprove runs the formal specs in certora/specs/ on the Certora Prover, and renders how
+// each rule came out, so that proving the contracts is part of working on them.
+//
+// The runs are committed in certora/runs.json: each names a contract, the spec it is verified
+// against, and its scene, the source files of it and of the contracts it is linked to.
+//
+// `rsvprove conf` writes the configuration of each run for certoraRun to -dir, to run by hand.
+//
+// `rsvprove run [<run>...]` writes the configurations, starts a job of each run (or of those
+// named) with certoraRun, which needs the Certora key in $CERTORAKEY, and polls every -poll until
+// all are done or -timeout passes. It prints each run's rules and how they came out, with a link
+// to the run's report, writes the results to -out if it is set, and fails if any rule isn't
+// proved.
+//
+// `rsvprove show <run> <report link>` polls a job that is already started, as run does.
+//
+// Usage:
+//
+//	rsvprove conf [-runs certora/runs.json] [-dir certora/conf] [-solc solc]
+//	rsvprove run [-runs certora/runs.json] [-dir certora/conf] [-solc solc] [-certora certoraRun] [-poll 30s] [-timeout 2h] [-out file] [<run>...]
+//	rsvprove show [-poll 30s] [-timeout 2h] [-out file] <run> <report link>
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/prover"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("rsvprove: ")
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "conf":
+		err = conf(os.Args[2:])
+	case "run":
+		err = run(os.Args[2:])
+	case "show":
+		err = show(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: rsvprove conf [-runs certora/runs.json] [-dir certora/conf] [-solc solc]")
+	fmt.Fprintln(os.Stderr, "       rsvprove run [-runs certora/runs.json] [-dir certora/conf] [-solc solc] [-certora certoraRun] [-poll 30s] [-timeout 2h] [-out file] [<run>...]")
+	fmt.Fprintln(os.Stderr, "       rsvprove show [-poll 30s] [-timeout 2h] [-out file] <run> <report link>")
+	os.Exit(2)
+}
+
+// writeConfs writes the configuration of each run to dir, and returns their paths by run.
+func writeConfs(runs []prover.Run, dir, solc string) (map[string]string, error) {
+	paths := make(map[string]string)
+	for _, r := range runs {
+		p, err := r.WriteConf(dir, solc)
+		if err != nil {
+			return nil, err
+		}
+		paths[r.Name] = p
+	}
+	return paths, nil
+}
+
+func conf(args []string) error {
+	fs := flag.NewFlagSet("conf", flag.ExitOnError)
+	runsFile := fs.String("runs", "certora/runs.json", "the committed prover runs")
+	dir := fs.String("dir", "certora/conf", "where to write the configurations")
+	solc := fs.String("solc", "solc", "the solc executable for the prover to compile with, which must be 0.5.7")
+	fs.Parse(args)
+
+	runs, err := prover.ReadRuns(*runsFile)
+	if err != nil {
+		return err
+	}
+	if _, err := writeConfs(runs, *dir, *solc); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote the configurations of %v runs to %v.\n", len(runs), *dir)
+	return nil
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	runsFile := fs.String("runs", "certora/runs.json", "the committed prover runs")
+	dir := fs.String("dir", "certora/conf", "where to write the configurations")
+	solc := fs.String("solc", "solc", "the solc executable for the prover to compile with, which must be 0.5.7")
+	certora := fs.String("certora", "certoraRun", "the certoraRun executable")
+	poll := fs.Duration("poll", 30*time.Second, "how often to poll the jobs")
+	timeout := fs.Duration("timeout", 2*time.Hour, "how long to wait for the jobs")
+	out := fs.String("out", "", "write the results to this file, as JSON")
+	fs.Parse(args)
+
+	runs, err := prover.ReadRuns(*runsFile)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		byName := make(map[string]prover.Run)
+		for _, r := range runs {
+			byName[r.Name] = r
+		}
+		runs = nil
+		for _, name := range fs.Args() {
+			r, ok := byName[name]
+			if !ok {
+				return errors.Errorf("%v has no run %v", *runsFile, name)
+			}
+			runs = append(runs, r)
+		}
+	}
+	paths, err := writeConfs(runs, *dir, *solc)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	p := prover.NewCertora(*certora)
+	var jobs []*prover.Job
+	for _, r := range runs {
+		job, err := p.Start(ctx, r.Name, paths[r.Name])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Started %v: %v\n", r.Name, job.URL)
+		jobs = append(jobs, job)
+	}
+	return report(ctx, p, jobs, *poll, *out)
+}
+
+func show(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	poll := fs.Duration("poll", 30*time.Second, "how often to poll the job")
+	timeout := fs.Duration("timeout", 2*time.Hour, "how long to wait for the job")
+	out := fs.String("out", "", "write the result to this file, as JSON")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		usage()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	job := &prover.Job{Run: fs.Arg(0), URL: fs.Arg(1)}
+	return report(ctx, prover.NewCertora("certoraRun"), []*prover.Job{job}, *poll, *out)
+}
+
+// report waits for every job, prints how their rules came out, and fails if any rule isn't
+// proved.
+func report(ctx context.Context, p prover.Prover, jobs []*prover.Job, poll time.Duration, out string) error {
+	results := make([]*prover.Result, len(jobs))
+	errs := make([]error, len(jobs))
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		wg.Add(1)
+		go func(i int, job *prover.Job) {
+			defer wg.Done()
+			results[i], errs[i] = prover.Wait(ctx, p, job, poll)
+		}(i, job)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Print(prover.Format(results))
+	if out != "" {
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "marshaling the results")
+		}
+		if err := ioutil.WriteFile(out, append(b, '\n'), 0644); err != nil {
+			return errors.Wrapf(err, "writing %v", out)
+		}
+	}
+	failed := 0
+	for _, r := range results {
+		failed += len(r.Failed())
+	}
+	if failed > 0 {
+		return errors.Errorf("%v rules not proved", failed)
+	}
+	fmt.Println("Every rule is proved.")
+	return nil
+}
@@ -0,0 +1,118 @@
+// Command rsvreconcile runs the reconciliation service: at every block, or every few, it
+// compares what the Vault should hold of each basket token to back the RSV supply with what it
+// holds, records each change in the difference, and serves the latest as Prometheus metrics.
+//
+// Usage:
+//
+//	rsvreconcile [-config rsvreconcile.json]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/reconcile"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// config is the rsvreconcile configuration file.
+type config struct {
+	session.Config
+
+	// Record is the JSON-lines file that each change in a token's surplus or shortfall is
+	// appended to.
+	Record string `json:"record"`
+
+	// IntervalBlocks is how many blocks apart the comparisons are (default 1, every block).
+	IntervalBlocks uint64 `json:"intervalBlocks,omitempty"`
+
+	// Listen, if set, is the address to serve metrics on.
+	Listen string `json:"listen,omitempty"`
+
+	// PollSeconds is how often to check for new blocks (default 15).
+	PollSeconds int `json:"pollSeconds,omitempty"`
+
+	// LogFile, if set, receives the service log, rotated as it grows. Otherwise it goes to stderr.
+	LogFile string `json:"logFile,omitempty"`
+}
+
+func main() {
+	log.SetPrefix("rsvreconcile: ")
+	configPath := flag.String("config", "rsvreconcile.json", "configuration file")
+	flag.Parse()
+
+	var c config
+	if err := session.LoadConfig(*configPath, &c); err != nil {
+		log.Fatal(err)
+	}
+	if c.LogFile != "" {
+		log.SetOutput(&lumberjack.Logger{Filename: c.LogFile, MaxSize: 100, MaxBackups: 10})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		log.Printf("received %v, shutting down", <-sigs)
+		cancel()
+	}()
+
+	if err := run(ctx, c); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, c config) error {
+	if c.Record == "" {
+		return errors.New("config: record is not set")
+	}
+	if c.PollSeconds == 0 {
+		c.PollSeconds = 15
+	}
+
+	s, err := session.Open(ctx, c.Config, "rsvreconcile")
+	if err != nil {
+		return err
+	}
+	reader, err := metrics.NewReader(s)
+	if err != nil {
+		return err
+	}
+	r := &reconcile.Reconciler{
+		Node:         s.Client,
+		ReadAt:       reader.ReadAt,
+		Interval:     c.IntervalBlocks,
+		Record:       c.Record,
+		PollInterval: time.Duration(c.PollSeconds) * time.Second,
+	}
+
+	if c.Listen != "" {
+		listener, err := net.Listen("tcp", c.Listen)
+		if err != nil {
+			return errors.Wrap(err, "listening")
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", r)
+		server := &http.Server{Handler: mux}
+		defer server.Close()
+		go func() {
+			if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}()
+		log.Printf("serving metrics on %v", listener.Addr())
+	}
+	log.Printf("reconciling %v into %v", c.Network, c.Record)
+	return r.Run(ctx)
+}
@@ -0,0 +1,147 @@
+// Command rsvrelayer runs the relayer service: it accepts signed metatransactions over HTTP,
+// pays the gas to forward them through the Relayer contract, and tracks them to confirmation.
+// The relayer account collects each request's fee.
+//
+// Usage:
+//
+//	rsvrelayer [-config rsvrelayer.json]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/reserve-protocol/rsv-beta/ops/emergency"
+	"github.com/reserve-protocol/rsv-beta/ops/relay"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// config is the rsvrelayer configuration file.
+type config struct {
+	session.Config
+
+	// Listen is the HTTP listen address, e.g. "127.0.0.1:8545".
+	Listen string `json:"listen"`
+
+	// MinFee is the smallest fee accepted, as a decimal string of attoRSV.
+	MinFee string `json:"minFee"`
+
+	Confirmations uint64 `json:"confirmations"`
+
+	// PollSeconds is how often to send queued requests and check on sent ones.
+	PollSeconds int `json:"pollSeconds,omitempty"`
+
+	// StateFile holds the request queue across restarts.
+	StateFile string `json:"stateFile"`
+
+	// DeadlineSeconds is how soon after it is received a request should be confirmed (default
+	// 900); one that isn't is reported stuck, once, to Webhooks.
+	DeadlineSeconds int                 `json:"deadlineSeconds,omitempty"`
+	Webhooks        []emergency.Webhook `json:"webhooks,omitempty"`
+
+	// LogFile, if set, receives the service log, rotated as it grows. Otherwise it goes to stderr.
+	LogFile string `json:"logFile,omitempty"`
+}
+
+func main() {
+	log.SetPrefix("rsvrelayer: ")
+	configPath := flag.String("config", "rsvrelayer.json", "configuration file")
+	flag.Parse()
+
+	var c config
+	if err := session.LoadConfig(*configPath, &c); err != nil {
+		log.Fatal(err)
+	}
+	if c.LogFile != "" {
+		log.SetOutput(&lumberjack.Logger{Filename: c.LogFile, MaxSize: 100, MaxBackups: 10})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		log.Printf("received %v, shutting down", <-sigs)
+		cancel()
+	}()
+
+	if err := run(ctx, c); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, c config) error {
+	if c.Listen == "" {
+		return errors.New("config: listen is not set")
+	}
+	if c.StateFile == "" {
+		return errors.New("config: stateFile is not set")
+	}
+	if c.DeadlineSeconds == 0 {
+		c.DeadlineSeconds = 900
+	}
+	minFee, ok := new(big.Int).SetString(c.MinFee, 10)
+	if !ok || minFee.Sign() < 0 {
+		return errors.Errorf("config: minFee must be a non-negative decimal integer, got %q", c.MinFee)
+	}
+
+	// The relayer runs unattended, so it has no network interlock; session.Open still refuses a
+	// node, config, or manifest on the wrong chain.
+	s, err := session.Open(ctx, c.Config, "rsvrelayer")
+	if err != nil {
+		return err
+	}
+	ch, err := relay.NewChain(ctx, s)
+	if err != nil {
+		return err
+	}
+	store, err := relay.OpenStore(c.StateFile)
+	if err != nil {
+		return err
+	}
+	config := relay.Config{
+		MinFee:        minFee,
+		Confirmations: c.Confirmations,
+		PollInterval:  time.Duration(c.PollSeconds) * time.Second,
+		Deadline:      time.Duration(c.DeadlineSeconds) * time.Second,
+		Network:       c.Network,
+	}
+	if len(c.Webhooks) > 0 {
+		notifier, err := emergency.NewNotifier(c.Webhooks)
+		if err != nil {
+			return err
+		}
+		config.Post = notifier.Notify
+	}
+	svc := relay.NewService(ch, store, config)
+
+	server := &http.Server{
+		Addr:         c.Listen,
+		Handler:      svc.Handler(),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	errs := make(chan error, 2)
+	go func() {
+		log.Printf("relaying for %v from %v, listening on %v", ch.RSV().Hex(), s.Transactor.From().Hex(), c.Listen)
+		errs <- server.ListenAndServe()
+	}()
+	go func() {
+		errs <- svc.Run(ctx)
+	}()
+
+	err = <-errs
+	shutdownCtx, done := context.WithTimeout(context.Background(), 10*time.Second)
+	defer done()
+	server.Shutdown(shutdownCtx)
+	return err
+}
@@ -0,0 +1,193 @@
+// Command rsvreport sends a daily operations report compiled from rsvindexer's database: the
+// day's mints, burns, and large transfers, its governance and admin events, the anomalies
+// flagged, what the operator keys spent on gas, and the backing ratio at the day's end. It posts
+// the report to chat webhooks and emails it.
+//
+// Usage:
+//
+//	rsvreport [-config rsvreport.json] [-once]
+//
+// With -once, it prints the report of yesterday (UTC) and exits, sending nothing.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/reserve-protocol/rsv-beta/ops/emergency"
+	"github.com/reserve-protocol/rsv-beta/ops/indexer"
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/report"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+// config is the rsvreport configuration file.
+type config struct {
+	session.Config
+
+	// Database is rsvindexer's database, as in its config.
+	Database struct {
+		Driver string `json:"driver"`
+		DSN    string `json:"dsn,omitempty"`
+		DSNEnv string `json:"dsnEnv,omitempty"`
+	} `json:"database"`
+
+	// Indexer is the name rsvindexer stores its progress under; by default, the network.
+	Indexer string `json:"indexer,omitempty"`
+
+	// Contracts are the indexed contracts whose governance events to report; by default the
+	// Reserve, Manager, and Vault.
+	Contracts []string `json:"contracts,omitempty"`
+
+	// LargeTransfer, if set, lists each transfer, mint, or burn of more than this many RSV.
+	LargeTransfer string `json:"largeTransfer,omitempty"`
+
+	// Backing, if set, reads the backing ratio at the end of each day from the node. Reports of
+	// days long past need an archive node.
+	Backing bool `json:"backing,omitempty"`
+
+	// Webhooks, if set, receive the report.
+	Webhooks []emergency.Webhook `json:"webhooks,omitempty"`
+
+	// Mail, if set, emails the report.
+	Mail *report.Mail `json:"mail,omitempty"`
+
+	// StateFile records which reports have been sent (default "rsvreport-state.json").
+	StateFile string `json:"stateFile,omitempty"`
+
+	// DelayMinutes is how long after midnight UTC to wait for the indexer before reporting the
+	// day (default 15).
+	DelayMinutes int `json:"delayMinutes,omitempty"`
+
+	// PollSeconds is how often to check whether a report is due (default 300).
+	PollSeconds int `json:"pollSeconds,omitempty"`
+
+	// LogFile, if set, receives the service log, rotated as it grows. Otherwise it goes to stderr.
+	LogFile string `json:"logFile,omitempty"`
+}
+
+func main() {
+	log.SetPrefix("rsvreport: ")
+	configPath := flag.String("config", "rsvreport.json", "configuration file")
+	once := flag.Bool("once", false, "print yesterday's report and exit, without sending it")
+	flag.Parse()
+
+	var c config
+	if err := session.LoadConfig(*configPath, &c); err != nil {
+		log.Fatal(err)
+	}
+	if c.LogFile != "" && !*once {
+		log.SetOutput(&lumberjack.Logger{Filename: c.LogFile, MaxSize: 100, MaxBackups: 10})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		log.Printf("received %v, shutting down", <-sigs)
+		cancel()
+	}()
+
+	if err := run(ctx, c, *once); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, c config, once bool) error {
+	dsn := c.Database.DSN
+	if c.Database.DSNEnv != "" {
+		if dsn = os.Getenv(c.Database.DSNEnv); dsn == "" {
+			return errors.Errorf("config: environment variable %v is not set", c.Database.DSNEnv)
+		}
+	}
+	if dsn == "" {
+		return errors.New("config: database dsn is not set")
+	}
+	if c.Indexer == "" {
+		c.Indexer = c.Network
+	}
+	if c.StateFile == "" {
+		c.StateFile = "rsvreport-state.json"
+	}
+	if c.DelayMinutes == 0 {
+		c.DelayMinutes = 15
+	}
+	if c.PollSeconds == 0 {
+		c.PollSeconds = 300
+	}
+	if !once && len(c.Webhooks) == 0 && c.Mail == nil {
+		return errors.New("config: set webhooks or mail to send reports to")
+	}
+	if c.Mail != nil {
+		if err := c.Mail.Check(); err != nil {
+			return err
+		}
+	}
+
+	s, err := session.Open(ctx, c.Config, "rsvreport")
+	if err != nil {
+		return err
+	}
+	store, err := indexer.OpenStore(c.Database.Driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	compiler := &report.Compiler{
+		Node:      s.Client,
+		Store:     store,
+		Indexer:   c.Indexer,
+		Contracts: c.Contracts,
+		Network:   c.Network,
+	}
+	if c.LargeTransfer != "" {
+		if compiler.LargeTransfer, err = units.Parse(c.LargeTransfer, 18); err != nil {
+			return errors.Wrap(err, "config: largeTransfer")
+		}
+	}
+	if c.Backing {
+		reader, err := metrics.NewReader(s)
+		if err != nil {
+			return err
+		}
+		compiler.ReadAt = reader.ReadAt
+	}
+
+	if once {
+		to := time.Now().UTC().Truncate(24 * time.Hour)
+		r, err := compiler.Compile(ctx, to.Add(-24*time.Hour), to)
+		if err != nil {
+			return err
+		}
+		fmt.Print(r.Text())
+		return nil
+	}
+
+	reporter := &report.Reporter{
+		Compiler:  compiler,
+		Delay:     time.Duration(c.DelayMinutes) * time.Minute,
+		Mail:      c.Mail,
+		StateFile: c.StateFile,
+		Interval:  time.Duration(c.PollSeconds) * time.Second,
+	}
+	if len(c.Webhooks) > 0 {
+		notifier, err := emergency.NewNotifier(c.Webhooks)
+		if err != nil {
+			return err
+		}
+		reporter.Post = notifier.Notify
+	}
+	log.Printf("reporting daily on %v from %v", c.Network, c.Database.Driver)
+	return reporter.Run(ctx)
+}
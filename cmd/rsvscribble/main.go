@@ -0,0 +1,135 @@
+// Command rsvscribble runs the Go tests against the contracts instrumented with their Scribble
+// annotations, the #if_succeeds comments on their functions, so that a test that drives a
+// contract into violating one fails, with the annotation's message.
+//
+// It reads from the artifacts that `make json` writes how each contract is compiled, copies the
+// contracts to -dir, has Scribble instrument the copies, and builds each instrumented contract as
+// its artifact was built, into -dir/evm. It then regenerates the bindings in abi/ from those,
+// runs the tests in tests/, and regenerates the bindings from -artifacts again, whether or not the
+// tests pass; with -keep, it leaves the instrumented bindings in abi/ instead, to run tests
+// against by hand, until `make -B abi` puts back the others.
+//
+// Instrumented code emits an AssertionFailed event where an annotation is violated, rather than
+// reverting; the tests fail on any transaction whose receipt has one.
+//
+// Usage:
+//
+//	rsvscribble [-artifacts evm] [-dir scribble] [-scribble scribble] [-solc solc] [-run regexp] [-keep]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/build"
+	"github.com/reserve-protocol/rsv-beta/ops/scribble"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("rsvscribble: ")
+	artifacts := flag.String("artifacts", "evm", "the artifacts that `make json` writes")
+	dir := flag.String("dir", "scribble", "where to write the instrumented contracts and their artifacts")
+	scribblePath := flag.String("scribble", "scribble", "the scribble executable")
+	solc := flag.String("solc", "solc", "the solc executable, of the version the artifacts were built with")
+	run := flag.String("run", "", "run only the tests matching this regexp, as `go test -run` does")
+	keep := flag.Bool("keep", false, "leave the instrumented bindings in abi/")
+	flag.Parse()
+
+	if err := test(*artifacts, *dir, *scribblePath, *solc, *run, *keep); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("The tests pass against the instrumented contracts.")
+}
+
+func test(artifacts, dir, scribblePath, solc, run string, keep bool) (err error) {
+	lock, err := build.LoadAll(artifacts)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(lock))
+	sourceSet := make(map[string]bool)
+	var version string
+	for name, r := range lock {
+		names = append(names, name)
+		sourceSet[r.Source] = true
+		// Scribble takes the version alone, such as 0.5.7 of 0.5.7+commit.6da8b019.
+		v := strings.SplitN(r.Compiler, "+", 2)[0]
+		if version != "" && v != version {
+			return errors.Errorf("the artifacts were built with both solc %v and %v; rebuild them with `make json`", version, v)
+		}
+		version = v
+	}
+	sort.Strings(names)
+	sources := make([]string, 0, len(sourceSet))
+	for source := range sourceSet {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	ctx := context.Background()
+	fmt.Printf("Instrumenting %v sources in %v.\n", len(sources), dir)
+	if err := scribble.Instrument(ctx, scribblePath, ".", dir, sources, version); err != nil {
+		return err
+	}
+	evm := filepath.Join(dir, "evm")
+	if err := os.MkdirAll(evm, 0755); err != nil {
+		return errors.Wrap(err, "creating the instrumented artifacts' directory")
+	}
+	for _, name := range names {
+		out, err := scribble.Compile(ctx, solc, dir, lock[name])
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(evm, name+".json")
+		if err := ioutil.WriteFile(path, out, 0644); err != nil {
+			return errors.Wrapf(err, "writing %v", path)
+		}
+	}
+	fmt.Printf("Built %v instrumented contracts in %v.\n", len(names), evm)
+
+	if !keep {
+		// Put back the bindings of the contracts as built, even if the tests fail.
+		defer func() {
+			if restoreErr := bindings(artifacts, names); err == nil {
+				err = restoreErr
+			}
+		}()
+	}
+	if err := bindings(evm, names); err != nil {
+		return err
+	}
+
+	args := []string{"test", "./tests", "-tags", "all", "-count", "1"}
+	if run != "" {
+		args = append(args, "-run", run)
+	}
+	// Against instrumented contracts, coverage would trace the checks as well as the code.
+	cmd := exec.Command("go", args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = []string{}
+	for _, v := range os.Environ() {
+		if !strings.HasPrefix(v, "COVERAGE_ENABLED=") {
+			cmd.Env = append(cmd.Env, v)
+		}
+	}
+	return errors.Wrap(cmd.Run(), "the tests failed against the instrumented contracts")
+}
+
+// bindings regenerates the bindings in abi/ of the contracts names from the artifacts in dir.
+func bindings(dir string, names []string) error {
+	cmd := exec.Command("go", append([]string{"run", "genABI.go"}, names...)...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), "EVM_DIR="+dir)
+	return errors.Wrapf(cmd.Run(), "generating the bindings from %v", dir)
+}
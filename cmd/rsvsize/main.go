@@ -0,0 +1,298 @@
+// Command rsvsize keeps each deployed contract's code size and deployment gas in a committed
+// report, sizes.json, and shows how they changed between revisions and over the file's history,
+// so that we can see which changes are eating into the EIP-170 limit on deployed code.
+//
+// `rsvsize record` reads the size of each contract's deployed code and init code from the
+// artifacts that `make json` writes, leaving out the test contracts in contracts/test/, and the
+// gas that deploying it used from the gas report that `make gas` writes, where the gas benchmarks
+// record it as "<Contract> deployment". It prints them, largest first, with the headroom left
+// under the limit, and writes them to -file; with -check, it writes nothing, and fails if the
+// committed file is out of date. Either way it fails if a contract's deployed code is over the
+// EIP-170 limit, or its init code over the EIP-3860 one.
+//
+// `rsvsize diff [<old> [<new>]]` lists the contracts whose sizes changed between two reports,
+// each a git revision whose -file is read, or a file. <old> defaults to HEAD, and <new> to -file
+// as it is. A revision with no report committed is measured instead: checked out in a temporary
+// git worktree, where `make sizes` runs. With -json, it prints the same as JSON, for tools.
+//
+// `rsvsize history [-n 20] [<contract>...]` walks the last -n commits that changed -file, oldest
+// first, and lists what each changed, for the contracts named or for all of them.
+//
+// Usage:
+//
+//	rsvsize record [-artifacts evm] [-gas gas.json] [-file sizes.json] [-check]
+//	rsvsize diff [-file sizes.json] [-json] [<revision or file> [<revision or file>]]
+//	rsvsize history [-file sizes.json] [-n 20] [<contract>...]
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/gas"
+	"github.com/reserve-protocol/rsv-beta/ops/sizes"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("rsvsize: ")
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "record":
+		err = record(os.Args[2:])
+	case "diff":
+		err = diff(os.Args[2:])
+	case "history":
+		err = history(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: rsvsize record [-artifacts evm] [-gas gas.json] [-file sizes.json] [-check]")
+	fmt.Fprintln(os.Stderr, "       rsvsize diff [-file sizes.json] [-json] [<old> [<new>]]")
+	fmt.Fprintln(os.Stderr, "       rsvsize history [-file sizes.json] [-n 20] [<contract>...]")
+	os.Exit(2)
+}
+
+func record(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	artifactsDir := fs.String("artifacts", "evm", "the artifacts that `make json` writes")
+	gasFile := fs.String("gas", "gas.json", "the gas report that `make gas` writes, for the gas of deployments")
+	file := fs.String("file", "sizes.json", "the committed size report")
+	check := fs.Bool("check", false, "fail if the committed report is out of date, instead of writing it")
+	fs.Parse(args)
+
+	paths, err := filepath.Glob(filepath.Join(*artifactsDir, "*.json"))
+	if err != nil {
+		return errors.Wrap(err, "listing artifacts")
+	}
+	if len(paths) == 0 {
+		return errors.Errorf("no artifacts in %v; run `make json`", *artifactsDir)
+	}
+	gasReport, err := gas.ReadFile(*gasFile)
+	if err != nil {
+		log.Printf("%v; leaving out the gas of deployments", err)
+		gasReport = gas.Report{}
+	}
+	artifacts := chain.NewArtifacts(*artifactsDir)
+	report := sizes.Report{}
+	for _, p := range paths {
+		a, err := artifacts.Load(strings.TrimSuffix(filepath.Base(p), ".json"))
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(a.Source, "contracts/test/") || len(a.BinRuntime) == 0 {
+			// Test contracts aren't deployed, and neither are abstract contracts or interfaces.
+			continue
+		}
+		report[a.Name] = sizes.Size{
+			Runtime:   len(a.BinRuntime),
+			Init:      len(a.Bin),
+			DeployGas: gasReport[sizes.DeploymentBenchmark(a.Name)],
+		}
+	}
+	fmt.Print(report.Format())
+
+	if *check {
+		committed, err := sizes.ReadFile(*file)
+		if err != nil {
+			return err
+		}
+		if deltas := sizes.Diff(committed, report); len(deltas) > 0 {
+			for _, d := range deltas {
+				fmt.Println(d)
+			}
+			return errors.Errorf("%v is out of date for %v contracts; run `make sizes`", *file, len(deltas))
+		}
+		fmt.Printf("%v is up to date.\n", *file)
+	} else {
+		if err := sizes.WriteFile(*file, report); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote the sizes of %v contracts to %v.\n", len(report), *file)
+	}
+	if over := report.Over(); len(over) > 0 {
+		return errors.Errorf("%v over the limit of %v bytes of deployed code or %v of init code",
+			strings.Join(over, ", "), sizes.MaxCodeSize, sizes.MaxInitCodeSize)
+	}
+	return nil
+}
+
+func diff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	file := fs.String("file", "sizes.json", "the committed size report")
+	asJSON := fs.Bool("json", false, "print the changes as JSON")
+	fs.Parse(args)
+	if fs.NArg() > 2 {
+		usage()
+	}
+
+	oldSide, newSide := "HEAD", *file
+	if fs.NArg() > 0 {
+		oldSide = fs.Arg(0)
+	}
+	if fs.NArg() > 1 {
+		newSide = fs.Arg(1)
+	}
+	old, err := read(oldSide, *file)
+	if err != nil {
+		return err
+	}
+	new, err := read(newSide, *file)
+	if err != nil {
+		return err
+	}
+	deltas := sizes.Diff(old, new)
+
+	if *asJSON {
+		if deltas == nil {
+			deltas = []sizes.Delta{}
+		}
+		b, err := json.MarshalIndent(deltas, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	if len(deltas) == 0 {
+		fmt.Println("No contract's size changed.")
+		return nil
+	}
+	for _, d := range deltas {
+		fmt.Println(d)
+	}
+	return nil
+}
+
+func history(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	file := fs.String("file", "sizes.json", "the committed size report")
+	n := fs.Int("n", 20, "how many of the commits that changed the report to show")
+	fs.Parse(args)
+	only := make(map[string]bool)
+	for _, contract := range fs.Args() {
+		only[contract] = true
+	}
+
+	path := "./" + filepath.ToSlash(filepath.Clean(*file))
+	out, err := git("log", fmt.Sprintf("-n%v", *n+1), "--format=%H %h %ad %s", "--date=short", "--", path)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if lines[0] == "" {
+		return errors.Errorf("no commit changed %v", *file)
+	}
+
+	// git log lists the newest commit first, and one more than -n, to diff the oldest against.
+	var prev sizes.Report
+	if len(lines) > *n {
+		if prev, err = read(strings.Fields(lines[len(lines)-1])[0], *file); err != nil {
+			return err
+		}
+		lines = lines[:*n]
+	}
+	for i := len(lines) - 1; i >= 0; i-- {
+		// The full hash, the short one, the date, and the subject, which may be empty.
+		fields := strings.SplitN(lines[i]+" ", " ", 4)
+		report, err := read(fields[0], *file)
+		if err != nil {
+			return err
+		}
+		var changed []string
+		if prev == nil {
+			for _, contract := range report.Contracts() {
+				if len(only) == 0 || only[contract] {
+					changed = append(changed, fmt.Sprintf("%v: %v bytes deployed", contract, report[contract].Runtime))
+				}
+			}
+		} else {
+			for _, d := range sizes.Diff(prev, report) {
+				if len(only) == 0 || only[d.Contract] {
+					changed = append(changed, d.String())
+				}
+			}
+		}
+		prev = report
+		if len(changed) == 0 {
+			continue
+		}
+		fmt.Printf("%v %v %v\n", fields[1], fields[2], strings.TrimSpace(fields[3]))
+		for _, c := range changed {
+			fmt.Printf("  %v\n", c)
+		}
+	}
+	return nil
+}
+
+// read reads a size report: side, if it is a file, or else file as of the git revision side,
+// measured if it has none.
+func read(side, file string) (sizes.Report, error) {
+	if info, err := os.Stat(side); err == nil && !info.IsDir() {
+		return sizes.ReadFile(side)
+	}
+	if _, err := git("cat-file", "-e", side+":./"+filepath.ToSlash(filepath.Clean(file))); err != nil {
+		return measure(side)
+	}
+	// A leading ./ makes git read the path relative to the working directory, as -file is.
+	b, err := git("show", side+":./"+filepath.ToSlash(filepath.Clean(file)))
+	if err != nil {
+		return nil, err
+	}
+	r, err := sizes.Parse(b)
+	return r, errors.Wrapf(err, "%v at %v", file, side)
+}
+
+// measure returns the size report of revision, measured with `make sizes` in a temporary git
+// worktree, for a revision that has none committed.
+func measure(revision string) (sizes.Report, error) {
+	tmp, err := ioutil.TempDir("", "rsvsize")
+	if err != nil {
+		return nil, errors.Wrap(err, "making a directory for the worktree")
+	}
+	defer os.RemoveAll(tmp)
+	worktree := filepath.Join(tmp, "tree")
+	if _, err := git("worktree", "add", "--detach", worktree, revision); err != nil {
+		return nil, err
+	}
+	defer git("worktree", "remove", "--force", worktree)
+
+	fmt.Fprintf(os.Stderr, "No size report is committed at %v; measuring it.\n", revision)
+	cmd := exec.Command("make", "sizes")
+	cmd.Dir = worktree
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "measuring %v", revision)
+	}
+	report, err := sizes.ReadFile(filepath.Join(worktree, "sizes.json"))
+	return report, errors.Wrap(err, revision)
+}
+
+// git runs a git command and returns its output.
+func git(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	return out, errors.Wrapf(err, "git %v: %s", strings.Join(args, " "), bytes.TrimSpace(stderr.Bytes()))
+}
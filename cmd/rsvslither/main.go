@@ -0,0 +1,74 @@
+// Command rsvslither runs Slither on the contracts and fails if it finds anything that the
+// triaged baseline doesn't already list, so that static analysis can run on every change. It
+// prints each new finding, and notes the baseline's findings that are no longer found.
+//
+// Once the new findings have been triaged, -update rewrites the baseline with everything
+// Slither finds now, dropping what it no longer finds. Review the baseline's diff before
+// committing it: each finding added to it is accepted for good.
+//
+// Usage:
+//
+//	rsvslither [-baseline slither.db.json] [-slither slither] [-target contracts] [-min-impact Low] [-update]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/reserve-protocol/rsv-beta/ops/slither"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("rsvslither: ")
+	baselinePath := flag.String("baseline", "slither.db.json", "the triaged findings, in the format of Slither's triage database")
+	slitherPath := flag.String("slither", "slither", "the slither executable")
+	target := flag.String("target", "contracts", "what slither analyzes")
+	minImpact := flag.String("min-impact", "Informational", "the least impact of the findings to check: Optimization, Informational, Low, Medium, or High")
+	update := flag.Bool("update", false, "rewrite the baseline with the current findings instead of checking them")
+	flag.Parse()
+	known := false
+	for _, level := range slither.Impacts {
+		known = known || strings.EqualFold(level, *minImpact)
+	}
+	if !known {
+		log.Fatalf("-min-impact %q is not one of %v", *minImpact, slither.Impacts)
+	}
+
+	findings, err := slither.Run(context.Background(), *slitherPath, *target)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *update {
+		if err := slither.WriteBaseline(*baselinePath, findings); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Wrote %v findings to %v.\n", len(findings), *baselinePath)
+		return
+	}
+
+	baseline, err := slither.LoadBaseline(*baselinePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fresh, fixed := slither.Compare(findings, baseline)
+	if len(fixed) > 0 {
+		fmt.Printf("%v findings of %v are no longer found; run with -update to drop them.\n", len(fixed), *baselinePath)
+	}
+	var failed int
+	for _, f := range fresh {
+		if f.AtLeast(*minImpact) {
+			fmt.Printf("NEW %v\n", f)
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("%v new findings. Fix them, or triage them and run with -update to accept them.\n", failed)
+		os.Exit(1)
+	}
+	fmt.Printf("No new findings (%v found, %v in the baseline).\n", len(findings), len(baseline))
+}
@@ -0,0 +1,144 @@
+// Command rsvwatch runs the privileged-event notifier: it follows the chain, and posts a
+// message to chat webhooks for each ownership transfer, pause, role change, or basket proposal
+// of the deployment's contracts, as soon as it is mined.
+//
+// Usage:
+//
+//	rsvwatch [-config rsvwatch.json]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/reserve-protocol/rsv-beta/ops/emergency"
+	"github.com/reserve-protocol/rsv-beta/ops/ens"
+	"github.com/reserve-protocol/rsv-beta/ops/indexer"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+	"github.com/reserve-protocol/rsv-beta/ops/watch"
+)
+
+// config is the rsvwatch configuration file.
+type config struct {
+	session.Config
+
+	// Webhooks receive a message for each event.
+	Webhooks []emergency.Webhook `json:"webhooks"`
+
+	// Contracts are the manifest contracts to watch; by default the Reserve, Manager, and Vault.
+	Contracts []string `json:"contracts,omitempty"`
+
+	// Events, if set, limits the events posted to those named. By default every privileged
+	// event is.
+	Events []string `json:"events,omitempty"`
+
+	// Confirmations is how many blocks an event must be under before it is posted (default 0,
+	// as soon as it is mined).
+	Confirmations uint64 `json:"confirmations,omitempty"`
+
+	// StateFile keeps the position of the watcher across restarts.
+	StateFile string `json:"stateFile"`
+
+	// PollSeconds is how often to check for new blocks (default 5).
+	PollSeconds int `json:"pollSeconds,omitempty"`
+
+	// LogFile, if set, receives the service log, rotated as it grows. Otherwise it goes to stderr.
+	LogFile string `json:"logFile,omitempty"`
+}
+
+func main() {
+	log.SetPrefix("rsvwatch: ")
+	configPath := flag.String("config", "rsvwatch.json", "configuration file")
+	flag.Parse()
+
+	var c config
+	if err := session.LoadConfig(*configPath, &c); err != nil {
+		log.Fatal(err)
+	}
+	if c.LogFile != "" {
+		log.SetOutput(&lumberjack.Logger{Filename: c.LogFile, MaxSize: 100, MaxBackups: 10})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		log.Printf("received %v, shutting down", <-sigs)
+		cancel()
+	}()
+
+	if err := run(ctx, c); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, c config) error {
+	if c.StateFile == "" {
+		return errors.New("config: stateFile is not set")
+	}
+	if len(c.Webhooks) == 0 {
+		return errors.New("config: no webhooks are set")
+	}
+	notifier, err := emergency.NewNotifier(c.Webhooks)
+	if err != nil {
+		return err
+	}
+	if len(c.Contracts) == 0 {
+		c.Contracts = []string{"Reserve", "Manager", "Vault"}
+	}
+	if c.PollSeconds == 0 {
+		c.PollSeconds = 5
+	}
+	messages := watch.Messages
+	if len(c.Events) > 0 {
+		messages = map[string]string{}
+		for _, name := range c.Events {
+			msg, ok := watch.Messages[name]
+			if !ok {
+				return errors.Errorf("config: %v is not a privileged event", name)
+			}
+			messages[name] = msg
+		}
+	}
+
+	s, err := session.Open(ctx, c.Config, "rsvwatch")
+	if err != nil {
+		return err
+	}
+	var contracts []indexer.Contract
+	for _, name := range c.Contracts {
+		addr, err := s.Manifest.Address(name)
+		if err != nil {
+			return err
+		}
+		artifact, err := s.Artifacts.Load(name)
+		if err != nil {
+			return err
+		}
+		contracts = append(contracts, indexer.Contract{Name: name, Address: addr, Artifact: artifact})
+	}
+	// Labels are a convenience, so errors finding the ENS registry are ignored.
+	registry, _ := ens.Open(ctx, s.Client)
+
+	w := &watch.Watcher{
+		Node:          s.Client,
+		Contracts:     contracts,
+		Messages:      messages,
+		Post:          notifier.Notify,
+		Label:         ens.NewNamer(registry, s.Manifest).Label,
+		Network:       c.Network,
+		Confirmations: c.Confirmations,
+		StateFile:     c.StateFile,
+		PollInterval:  time.Duration(c.PollSeconds) * time.Second,
+	}
+	log.Printf("watching %v on %v", c.Contracts, c.Network)
+	return w.Run(ctx)
+}
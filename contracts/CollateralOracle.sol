@@ -0,0 +1,175 @@
+pragma solidity 0.5.7;
+
+import "./zeppelin/math/SafeMath.sol";
+import "./ownership/Ownable.sol";
+
+/// The part of Chainlink's AggregatorV3Interface that the CollateralOracle reads.
+interface AggregatorV3Interface {
+    function decimals() external view returns (uint8);
+    function latestRoundData() external view returns (
+        uint80 roundId,
+        int256 answer,
+        uint256 startedAt,
+        uint256 updatedAt,
+        uint80 answeredInRound
+    );
+}
+
+/// The part of a Compound cToken, or of an adapter in its image, that gives the exchange rate
+/// of an interest-bearing wrapper for its underlying token.
+interface IExchangeRate {
+    function exchangeRateStored() external view returns (uint256);
+}
+
+/// The part of the CollateralOracle that the Manager reads.
+interface ICollateralOracle {
+    function value(address token, uint256 amount) external view returns (uint256);
+    function exchangeRate(address token) external view returns (uint256);
+    function amountOf(address token, uint256 usd) external view returns (uint256);
+}
+
+/**
+ * The CollateralOracle prices the Vault's tokens in dollars through Chainlink price feeds, such
+ * as USDC/USD. Every basket token is meant to be worth a dollar, so a price is only given out if
+ * its feed has been updated within the feed's `heartbeat`, and it is within `maxDeviation` of a
+ * dollar; otherwise the call reverts. The Manager uses it, when it is set, to refuse issuance
+ * that the Vault's dollar value wouldn't cover, and to price redemptions in a single token.
+ *
+ * The owner sets each token's feed, heartbeat, and decimals, and the maximum deviation.
+ *
+ * Some tokens are interest-bearing wrappers, such as Compound's cTokens, each worth a growing
+ * amount of an underlying token. For such a token the owner also sets an exchange rate source,
+ * and the token's feed and decimals are those of the underlying token, such as the USDC/USD
+ * feed and 6 decimals for cUSDC. The Manager reads the exchange rate too: a wrapper's basket
+ * weight is in its underlying token, so that its interest accrues to the Vault. Aave's aTokens
+ * need no exchange rate, since their balances grow instead.
+ */
+
+// On "unit" comments, see comment at top of Manager.sol. In addition:
+// - 1 aUSD: 1 atto-dollar, 10**-18 dollars.
+contract CollateralOracle is Ownable {
+    using SafeMath for uint256;
+
+    struct Feed {
+        AggregatorV3Interface aggregator;
+        uint256 heartbeat; // unit: seconds
+        uint8 feedDecimals;
+        uint8 tokenDecimals;
+    }
+
+    mapping(address => Feed) public feeds;
+
+    // The source of each interest-bearing wrapper's exchange rate.
+    mapping(address => IExchangeRate) public exchangeRateSources;
+
+    // How far from a dollar a price may be.
+    uint256 public maxDeviation; // unit: BPS
+
+    uint256 constant BPS_FACTOR = 10000; // unit: BPS
+    uint256 constant DOLLAR = 10**18; // unit: aUSD
+    uint256 constant RATE_SCALE = 10**18; // unit: aqUnderlying/qUnderlying
+
+    event FeedChanged(
+        address indexed token,
+        address indexed aggregator,
+        uint256 heartbeat,
+        uint8 tokenDecimals
+    );
+    event MaxDeviationChanged(uint256 oldVal, uint256 newVal);
+    event ExchangeRateSourceChanged(address indexed token, address indexed source);
+
+    constructor(uint256 _maxDeviation) public {
+        require(_maxDeviation <= BPS_FACTOR, "max deviation above 100%");
+        maxDeviation = _maxDeviation;
+    }
+
+    /// Sets the price feed of `token`, which has `tokenDecimals` decimals, and how long the feed
+    /// may go without an update. An aggregator of address zero removes the token's feed.
+    function setFeed(
+        address token,
+        AggregatorV3Interface aggregator,
+        uint256 heartbeat,
+        uint8 tokenDecimals
+    ) external onlyOwner {
+        if (address(aggregator) == address(0)) {
+            delete feeds[token];
+        } else {
+            require(heartbeat > 0, "heartbeat cannot be zero");
+            feeds[token] = Feed(aggregator, heartbeat, aggregator.decimals(), tokenDecimals);
+        }
+        emit FeedChanged(token, address(aggregator), heartbeat, tokenDecimals);
+    }
+
+    /// Sets the source of `token`'s exchange rate for its underlying token, which is often the
+    /// token itself. Address zero makes `token` an ordinary token again.
+    ///
+    /// A wrapper's basket weight is read in its underlying token whenever it has a source, so
+    /// set this before the wrapper enters the basket, and leave it while it is there.
+    function setExchangeRateSource(address token, IExchangeRate source) external onlyOwner {
+        exchangeRateSources[token] = source;
+        emit ExchangeRateSourceChanged(token, address(source));
+    }
+
+    /// Sets how far from a dollar a price may be, in BPS.
+    function setMaxDeviation(uint256 _maxDeviation) external onlyOwner {
+        require(_maxDeviation <= BPS_FACTOR, "max deviation above 100%");
+        emit MaxDeviationChanged(maxDeviation, _maxDeviation);
+        maxDeviation = _maxDeviation;
+    }
+
+    /// Returns the price of a whole `token`, if its feed is fresh and the price within
+    /// `maxDeviation` of a dollar.
+    /// return unit: aUSD/Token
+    function price(address token) public view returns (uint256) {
+        Feed memory feed = feeds[token];
+        require(address(feed.aggregator) != address(0), "no price feed");
+
+        (uint80 roundId, int256 answer, , uint256 updatedAt, uint80 answeredInRound) =
+            feed.aggregator.latestRoundData();
+        require(answer > 0, "price not positive");
+        require(updatedAt > 0 && answeredInRound >= roundId, "round not complete");
+        require(now.sub(updatedAt) <= feed.heartbeat, "stale price");
+
+        uint256 p = uint256(answer).mul(DOLLAR).div(uint256(10)**feed.feedDecimals);
+        // unit: aUSD/Token
+        uint256 deviation = p > DOLLAR ? p - DOLLAR : DOLLAR - p;
+        require(
+            deviation.mul(BPS_FACTOR) <= maxDeviation.mul(DOLLAR),
+            "price deviates from a dollar"
+        );
+        return p;
+    }
+
+    /// Returns how much of its underlying token a quantum of `token` is worth, scaled by 10**18:
+    /// 10**18 for a token without an exchange rate source.
+    /// return unit: aqUnderlying/qToken
+    function exchangeRate(address token) public view returns (uint256) {
+        IExchangeRate source = exchangeRateSources[token];
+        if (address(source) == address(0)) {
+            return RATE_SCALE;
+        }
+        uint256 rate = source.exchangeRateStored();
+        require(rate > 0, "exchange rate is zero");
+        return rate;
+    }
+
+    /// Returns the value of `amount` of `token`, rounded down.
+    /// amount unit: qToken
+    /// return unit: aUSD
+    function value(address token, uint256 amount) external view returns (uint256) {
+        uint256 underlying = amount.mul(exchangeRate(token)).div(RATE_SCALE);
+        // unit: qUnderlying == qToken * aqUnderlying/qToken / (aqUnderlying/qUnderlying)
+        return underlying.mul(price(token)).div(uint256(10)**feeds[token].tokenDecimals);
+        // unit check: aUSD == qUnderlying * aUSD/Underlying / (qUnderlying/Underlying)
+    }
+
+    /// Returns how much of `token` is worth `usd`, rounded down: the inverse of `value`.
+    /// usd unit: aUSD
+    /// return unit: qToken
+    function amountOf(address token, uint256 usd) external view returns (uint256) {
+        uint256 underlying = usd.mul(uint256(10)**feeds[token].tokenDecimals).div(price(token));
+        // unit: qUnderlying == aUSD * (qUnderlying/Underlying) / (aUSD/Underlying)
+        return underlying.mul(RATE_SCALE).div(exchangeRate(token));
+        // unit check: qToken == qUnderlying * (aqUnderlying/qUnderlying) / (aqUnderlying/qToken)
+    }
+}
@@ -0,0 +1,89 @@
+pragma solidity 0.5.7;
+
+import "./ownership/Ownable.sol";
+
+/// The part of the CollateralRegistry that the Manager consults.
+interface ICollateralRegistry {
+    function isApproved(address token) external view returns (bool);
+    function capOf(address token) external view returns (uint256);
+}
+
+/**
+ * The CollateralRegistry lists the tokens approved as collateral for RSV, with what is known of
+ * each: its decimals, the Chainlink feed that prices it, and its cap, the greatest weight that it
+ * may have in a basket. The Manager, when it is set, refuses proposals that would bring any other
+ * token into the basket.
+ *
+ * The owner approves tokens, updates their metadata by approving them again, and removes them.
+ * Removing a token doesn't change the basket, but from then on only proposals that take it out
+ * of the basket can be executed.
+ */
+
+// On "unit" comments, see comment at top of Manager.sol.
+contract CollateralRegistry is Ownable {
+    struct Collateral {
+        bool approved;
+        uint8 decimals;
+        address feed;
+        uint256 cap; // unit: aqToken/RSV; 0 for no cap
+    }
+
+    mapping(address => Collateral) public collaterals;
+
+    // The approved tokens, in no particular order, and each one's index in it.
+    address[] public tokens;
+    mapping(address => uint256) internal _indexes;
+
+    event CollateralApproved(
+        address indexed token,
+        uint8 decimals,
+        address indexed feed,
+        uint256 cap
+    );
+    event CollateralRemoved(address indexed token);
+
+    /// Approves `token`, which has `decimals` decimals and is priced by `feed`, as collateral,
+    /// with at most `cap` weight in a basket, or none if `cap` is zero. Approving a token again
+    /// updates its metadata.
+    function approve(address token, uint8 decimals, address feed, uint256 cap)
+        external
+        onlyOwner
+    {
+        require(token != address(0), "cannot be 0 address");
+        if (!collaterals[token].approved) {
+            _indexes[token] = tokens.length;
+            tokens.push(token);
+        }
+        collaterals[token] = Collateral(true, decimals, feed, cap);
+        emit CollateralApproved(token, decimals, feed, cap);
+    }
+
+    /// Removes `token` from the approved collateral.
+    function remove(address token) external onlyOwner {
+        require(collaterals[token].approved, "token not approved");
+        uint256 index = _indexes[token];
+        address last = tokens[tokens.length - 1];
+        tokens[index] = last;
+        _indexes[last] = index;
+        tokens.length--;
+        delete _indexes[token];
+        delete collaterals[token];
+        emit CollateralRemoved(token);
+    }
+
+    /// @return how many tokens are approved.
+    function tokensLength() external view returns (uint256) {
+        return tokens.length;
+    }
+
+    /// @return whether `token` is approved as collateral.
+    function isApproved(address token) external view returns (bool) {
+        return collaterals[token].approved;
+    }
+
+    /// @return the greatest weight `token` may have in a basket, or 0 for no cap.
+    /// return unit: aqToken/RSV
+    function capOf(address token) external view returns (uint256) {
+        return collaterals[token].cap;
+    }
+}
@@ -0,0 +1,62 @@
+pragma solidity 0.5.7;
+
+/**
+ * The Create2Deployer deploys contracts with CREATE2, so that a contract's address depends only
+ * on who deployed it, a salt, and its init code -- not on any account's nonce. Deploying the
+ * same contract with the same salt from the same account gives the same address on every chain
+ * where this deployer lives at the same address.
+ *
+ * Contracts see this deployer as `msg.sender` in their constructors, so it ends up holding
+ * whatever roles they give their deployer (like ownership). The account that deployed a
+ * contract through this deployer can act with those roles through `execute`, typically to
+ * configure the contract and then nominate a permanent owner.
+ */
+contract Create2Deployer {
+
+    /// The account that deployed each contract through this deployer.
+    mapping(address => address) public deployerOf;
+
+    event Deployed(address indexed addr, address indexed deployer, bytes32 indexed salt);
+    event Executed(address indexed target, address indexed deployer, bytes data);
+
+    /// Deploys `initCode` with `salt`, as seen from the caller.
+    function deploy(bytes32 salt, bytes calldata initCode) external returns (address addr) {
+        bytes memory code = initCode;
+        bytes32 fullSalt = _fullSalt(msg.sender, salt);
+        // solium-disable-next-line security/no-inline-assembly
+        assembly {
+            addr := create2(0, add(code, 0x20), mload(code), fullSalt)
+        }
+        require(addr != address(0), "deployment failed");
+        deployerOf[addr] = msg.sender;
+        emit Deployed(addr, msg.sender, salt);
+    }
+
+    /// Returns the address that `deploy(salt, initCode)` would deploy to, if called by `deployer`.
+    function computeAddress(address deployer, bytes32 salt, bytes calldata initCode)
+        external view returns (address)
+    {
+        return address(uint256(keccak256(abi.encodePacked(
+            bytes1(0xff),
+            address(this),
+            _fullSalt(deployer, salt),
+            keccak256(initCode)
+        ))));
+    }
+
+    /// Calls `target` with `data`, on behalf of the account that deployed `target`.
+    function execute(address target, bytes calldata data) external returns (bytes memory) {
+        require(deployerOf[target] == msg.sender, "caller did not deploy target");
+        // solium-disable-next-line security/no-low-level-calls
+        (bool success, bytes memory result) = target.call(data);
+        require(success, "call failed");
+        emit Executed(target, msg.sender, data);
+        return result;
+    }
+
+    /// The salt actually passed to CREATE2. Mixing in the caller means that nobody can occupy
+    /// another account's addresses.
+    function _fullSalt(address deployer, bytes32 salt) internal pure returns (bytes32) {
+        return keccak256(abi.encodePacked(deployer, salt));
+    }
+}
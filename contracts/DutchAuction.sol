@@ -0,0 +1,210 @@
+pragma solidity 0.5.7;
+
+import "./zeppelin/token/ERC20/SafeERC20.sol";
+import "./zeppelin/token/ERC20/IERC20.sol";
+import "./zeppelin/math/SafeMath.sol";
+import "./WeightMath.sol";
+
+/// The part of the DutchAuction that the Manager calls.
+interface IDutchAuction {
+    function start(
+        address sellToken,
+        address buyToken,
+        uint256 sellAmount,
+        uint256 startPrice,
+        uint256 endPrice,
+        uint256 duration,
+        address proceedsTo
+    ) external returns (uint256);
+    function close(uint256 id) external;
+}
+
+/**
+ * The DutchAuction sells tokens that the Vault holds outside the basket, such as the surplus of
+ * a token that a basket migration has dropped, for a basket token, by descending-price auction.
+ * This way the Vault takes what the market will pay, rather than the rate that a proposer
+ * offers in a swap proposal.
+ *
+ * Each auction, or lot, is started by the Manager, which first sends it the tokens to sell. The
+ * price, in the bought token per sold token, falls linearly from `startPrice` at the start to
+ * `endPrice` at the end of `duration`. Until then, anyone can `bid` for any part of what is left
+ * at the current price, paid to the lot's `proceedsTo`, the Vault. Once the lot has sold out,
+ * the lot is closed; once it has ended, anyone can `close` it, and the Manager can close it
+ * sooner. Whatever is unsold on closing goes back to `proceedsTo`.
+ */
+
+// On "unit" comments, see comment at top of Manager.sol. Prices are
+// aqBuyToken/qSellToken: quanta of the bought token per quantum of the sold token, times 10**18.
+contract DutchAuction is IDutchAuction {
+    using SafeMath for uint256;
+    using SafeERC20 for IERC20;
+
+    struct Lot {
+        address sellToken;
+        address buyToken;
+        address proceedsTo;
+        uint256 remaining; // unit: qSellToken
+        uint256 startPrice; // unit: aqBuyToken/qSellToken
+        uint256 endPrice; // unit: aqBuyToken/qSellToken
+        uint256 start; // unit: seconds
+        uint256 end; // unit: seconds
+        bool open;
+    }
+
+    // The only account that can start lots, and close them before they end.
+    address public manager;
+
+    mapping(uint256 => Lot) public lots;
+    uint256 public lotsLength;
+
+    // How much of each token the open lots have left to sell, so that a lot can only be
+    // started with tokens that no other lot is selling.
+    mapping(address => uint256) public committed; // unit: qToken
+
+    uint256 constant PRICE_SCALE = 10**18; // unit: aqBuyToken/qBuyToken
+
+    event AuctionStarted(
+        uint256 indexed id,
+        address indexed sellToken,
+        address indexed buyToken,
+        uint256 sellAmount,
+        uint256 startPrice,
+        uint256 endPrice,
+        uint256 end
+    );
+    event Bid(
+        uint256 indexed id,
+        address indexed bidder,
+        uint256 amount,
+        uint256 cost,
+        uint256 bidPrice
+    );
+    event AuctionClosed(uint256 indexed id, uint256 unsold);
+
+    constructor(address _manager) public {
+        require(_manager != address(0), "cannot be 0 address");
+        manager = _manager;
+    }
+
+    /// Modifies a function to run only when called by `manager`.
+    modifier onlyManager() {
+        require(msg.sender == manager, "must be manager");
+        _;
+    }
+
+    /// Start a lot of `sellAmount` of `sellToken`, already sent to this contract, for
+    /// `buyToken`, its price falling from `startPrice` to `endPrice` over `duration` seconds.
+    /// The proceeds, and anything unsold, go to `proceedsTo`.
+    /// @return the lot's ID.
+    function start(
+        address sellToken,
+        address buyToken,
+        uint256 sellAmount,
+        uint256 startPrice,
+        uint256 endPrice,
+        uint256 duration,
+        address proceedsTo
+    ) external onlyManager returns (uint256) {
+        require(sellAmount > 0, "cannot sell 0 tokens");
+        require(sellToken != buyToken, "cannot sell a token for itself");
+        require(startPrice >= endPrice, "price must fall");
+        require(duration > 0, "duration cannot be 0");
+        require(proceedsTo != address(0), "cannot be 0 address");
+
+        committed[sellToken] = committed[sellToken].add(sellAmount);
+        require(
+            IERC20(sellToken).balanceOf(address(this)) >= committed[sellToken],
+            "tokens not received"
+        );
+
+        uint256 id = lotsLength;
+        lotsLength = id.add(1);
+        Lot storage lot = lots[id];
+        lot.sellToken = sellToken;
+        lot.buyToken = buyToken;
+        lot.proceedsTo = proceedsTo;
+        lot.remaining = sellAmount;
+        lot.startPrice = startPrice;
+        lot.endPrice = endPrice;
+        lot.start = now;
+        lot.end = now.add(duration);
+        lot.open = true;
+
+        emit AuctionStarted(
+            id,
+            lot.sellToken,
+            lot.buyToken,
+            lot.remaining,
+            lot.startPrice,
+            lot.endPrice,
+            lot.end
+        );
+        return id;
+    }
+
+    /// @return the current price of lot `id`, which falls linearly from its start price to its
+    /// end price, and stays there once it has ended.
+    /// return unit: aqBuyToken/qSellToken
+    function price(uint256 id) public view returns (uint256) {
+        Lot storage lot = lots[id];
+        require(lot.end > 0, "nonexistent lot");
+        if (now >= lot.end) {
+            return lot.endPrice;
+        }
+        return lot.startPrice.sub(WeightMath.mulDiv(
+            lot.startPrice - lot.endPrice,
+            now - lot.start,
+            lot.end - lot.start
+        ));
+        // unit check: aqBuyToken/qSellToken == aqBuyToken/qSellToken * seconds / seconds
+    }
+
+    /// Buy `amount` of lot `id`'s token, or all that is left if that is less, at the current
+    /// price, if it is no more than `maxPrice`. The sender pays the cost, rounded up, in the
+    /// lot's bought token, out of its allowance to this contract.
+    /// @return how much of the sold token the sender got.
+    function bid(uint256 id, uint256 amount, uint256 maxPrice) external returns (uint256) {
+        Lot storage lot = lots[id];
+        require(lot.open && now < lot.end, "lot not open");
+        require(amount > 0, "cannot buy 0 tokens");
+
+        uint256 p = price(id); // unit: aqBuyToken/qSellToken
+        require(p <= maxPrice, "price above max");
+
+        if (amount > lot.remaining) {
+            amount = lot.remaining;
+        }
+        uint256 cost = WeightMath.mulDivUp(amount, p, PRICE_SCALE);
+        // unit check: qBuyToken == qSellToken * aqBuyToken/qSellToken / (aqBuyToken/qBuyToken)
+
+        lot.remaining = lot.remaining - amount;
+        committed[lot.sellToken] = committed[lot.sellToken].sub(amount);
+        if (lot.remaining == 0) {
+            lot.open = false;
+        }
+
+        IERC20(lot.buyToken).safeTransferFrom(msg.sender, lot.proceedsTo, cost);
+        IERC20(lot.sellToken).safeTransfer(msg.sender, amount);
+        emit Bid(id, msg.sender, amount, cost, p);
+        if (!lot.open) {
+            emit AuctionClosed(id, 0);
+        }
+        return amount;
+    }
+
+    /// Close lot `id`, sending whatever is unsold to its `proceedsTo`. Anyone can close a lot
+    /// that has ended; the Manager can close one at any time.
+    function close(uint256 id) external {
+        Lot storage lot = lots[id];
+        require(lot.open, "lot not open");
+        require(msg.sender == manager || now >= lot.end, "lot not ended");
+
+        uint256 unsold = lot.remaining;
+        lot.remaining = 0;
+        lot.open = false;
+        committed[lot.sellToken] = committed[lot.sellToken].sub(unsold);
+
+        IERC20(lot.sellToken).safeTransfer(lot.proceedsTo, unsold);
+        emit AuctionClosed(id, unsold);
+    }
+}
@@ -0,0 +1,124 @@
+pragma solidity 0.5.7;
+
+import "./zeppelin/token/ERC20/SafeERC20.sol";
+import "./zeppelin/token/ERC20/IERC20.sol";
+import "./zeppelin/math/SafeMath.sol";
+import "./ownership/Ownable.sol";
+
+/**
+ * The FeeTreasury collects the Manager's fees, by being its `issuanceFeeRecipient`, which is paid
+ * in RSV, and its `redemptionFeeRecipient`, which is paid in basket tokens, and shares them out
+ * among distribution targets that its owner sets, each with a share in basis points.
+ *
+ * Distribution is in two steps. Anyone may `distribute` a token, once every
+ * `distributionInterval` for that token, which credits each target with its share of what the
+ * treasury holds of it and has not yet credited; each target then `claim`s what it is owed.
+ * Shares round down, so that the treasury never credits more than it holds, and the dust is
+ * left for the next distribution.
+ */
+
+// On "unit" comments, see comment at top of Manager.sol.
+contract FeeTreasury is Ownable {
+    using SafeMath for uint256;
+    using SafeERC20 for IERC20;
+
+    uint256 public constant BPS_FACTOR = 10000;
+
+    // The distribution targets, and each one's share of every distribution.
+    address[] public targets;
+    uint256[] public shares; // unit: BPS
+
+    // The least time between two distributions of a token, and when each was last distributed.
+    uint256 public distributionInterval; // unit: seconds
+    mapping(address => uint256) public lastDistribution; // unit: seconds since the epoch
+
+    // What each target may claim of each token, and the total that all may claim of it.
+    mapping(address => mapping(address => uint256)) public claimable; // unit: qToken
+    mapping(address => uint256) public totalClaimable; // unit: qToken
+
+    event TargetsChanged(address[] targets, uint256[] shares);
+    event DistributionIntervalChanged(uint256 oldVal, uint256 newVal);
+    event FeesDistributed(address indexed token, address indexed by, uint256 amount);
+    event FeesClaimed(address indexed token, address indexed target, uint256 amount);
+
+    /// interval unit: seconds
+    constructor(uint256 interval) public {
+        distributionInterval = interval;
+    }
+
+    /// Set the distribution targets, giving each of `_targets` the share in `_shares` at the same
+    /// index. Shares must total 100%. What targets are owed already, they still may claim.
+    /// _shares unit: BPS
+    function setTargets(address[] calldata _targets, uint256[] calldata _shares)
+        external
+        onlyOwner
+    {
+        require(_targets.length == _shares.length, "unequal lengths");
+        uint256 total;
+        for (uint256 i = 0; i < _targets.length; i++) {
+            require(_targets[i] != address(0), "cannot be 0 address");
+            require(_shares[i] > 0, "share must be positive");
+            total = total.add(_shares[i]);
+        }
+        require(total == BPS_FACTOR, "shares must total 100%");
+        targets = _targets;
+        shares = _shares;
+        emit TargetsChanged(_targets, _shares);
+    }
+
+    /// Set the least time between two distributions of a token.
+    /// interval unit: seconds
+    function setDistributionInterval(uint256 interval) external onlyOwner {
+        emit DistributionIntervalChanged(distributionInterval, interval);
+        distributionInterval = interval;
+    }
+
+    /// @return how many distribution targets there are.
+    function targetsLength() external view returns (uint256) {
+        return targets.length;
+    }
+
+    /// @return how much of `token` the treasury holds and has not credited to any target.
+    /// return unit: qToken
+    function undistributed(address token) public view returns (uint256) {
+        return IERC20(token).balanceOf(address(this)).sub(totalClaimable[token]);
+    }
+
+    /// @return the earliest time at which `token` may be distributed.
+    /// return unit: seconds since the epoch
+    function nextDistribution(address token) public view returns (uint256) {
+        if (lastDistribution[token] == 0) {
+            return 0;
+        }
+        return lastDistribution[token].add(distributionInterval);
+    }
+
+    /// Credit each target with its share of what the treasury holds of `token` and has not yet
+    /// credited.
+    function distribute(address token) external {
+        require(targets.length > 0, "no distribution targets");
+        require(now >= nextDistribution(token), "distribution not due");
+        uint256 amount = undistributed(token);
+        require(amount > 0, "nothing to distribute");
+
+        uint256 credited;
+        for (uint256 i = 0; i < targets.length; i++) {
+            uint256 share = amount.mul(shares[i]).div(BPS_FACTOR);
+            claimable[token][targets[i]] = claimable[token][targets[i]].add(share);
+            credited = credited.add(share);
+        }
+        totalClaimable[token] = totalClaimable[token].add(credited);
+        lastDistribution[token] = now;
+        emit FeesDistributed(token, _msgSender(), credited);
+    }
+
+    /// Pay the sender everything it has been credited of `token`.
+    function claim(address token) external {
+        uint256 amount = claimable[token][_msgSender()];
+        require(amount > 0, "nothing to claim");
+        claimable[token][_msgSender()] = 0;
+        totalClaimable[token] = totalClaimable[token].sub(amount);
+        IERC20(token).safeTransfer(_msgSender(), amount);
+        emit FeesClaimed(token, _msgSender(), amount);
+    }
+}
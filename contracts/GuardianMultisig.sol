@@ -0,0 +1,198 @@
+pragma solidity 0.5.7;
+
+import "./zeppelin/math/SafeMath.sol";
+import "./zeppelin/utils/ECDSA.sol";
+
+/// The parts of the Reserve that the GuardianMultisig calls.
+interface IGuardedReserve {
+    function pause() external;
+    function freeze(address account) external;
+    function unfreeze(address account) external;
+}
+
+/**
+ * The GuardianMultisig is an m-of-n approval contract for the Reserve's emergency functions:
+ * as the Reserve's `guardian` and `freezer`, it pauses the Reserve and freezes and unfreezes
+ * accounts once `threshold` of its guardians have signed for it. It is meant to act within
+ * minutes, so it holds no other power: the owner multisig remains in charge of everything else,
+ * unpausing included, and can take either role from it at any time.
+ *
+ * Guardians sign EIP-712 messages off chain, and anyone may submit them with `execute`, in
+ * ascending order of signer. Each message names the multisig's `nonce`, which every execution
+ * advances, so that a signature is good for one execution here only, and a deadline past which
+ * it is void. The guardians change their own number and threshold the same way.
+ */
+contract GuardianMultisig {
+    using SafeMath for uint256;
+
+    IGuardedReserve public reserve;
+
+    // The guardians, in no particular order, and each one's index in it, plus one.
+    address[] public guardians;
+    mapping(address => uint256) internal _indexes;
+
+    // How many guardians must sign each action.
+    uint256 public threshold;
+
+    // The nonce that the next action is signed with.
+    uint256 public nonce;
+
+    // Actions, as signed.
+    uint8 public constant PAUSE = 0;
+    uint8 public constant FREEZE = 1;
+    uint8 public constant UNFREEZE = 2;
+    uint8 public constant ADD_GUARDIAN = 3;
+    uint8 public constant REMOVE_GUARDIAN = 4;
+    uint8 public constant CHANGE_THRESHOLD = 5;
+
+    // EIP-712 type hashes, and this contract's domain separator for the chain it was deployed
+    // for.
+    bytes32 public constant ACTION_TYPEHASH = keccak256(
+        "Action(uint8 action,address account,uint256 value,uint256 nonce,uint256 deadline)"
+    );
+    bytes32 internal constant DOMAIN_TYPEHASH = keccak256(
+        "EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"
+    );
+    bytes32 public DOMAIN_SEPARATOR;
+
+    event ActionExecuted(
+        uint8 indexed action,
+        address indexed account,
+        uint256 value,
+        uint256 nonce
+    );
+    event GuardianAdded(address indexed guardian);
+    event GuardianRemoved(address indexed guardian);
+    event ThresholdChanged(uint256 oldVal, uint256 newVal);
+
+    /// `chainId` is the chain that guardians sign for; this compiler can't read it on chain.
+    constructor(
+        address reserveAddr,
+        uint256 chainId,
+        address[] memory _guardians,
+        uint256 _threshold
+    ) public {
+        require(reserveAddr != address(0), "cannot be 0 address");
+        reserve = IGuardedReserve(reserveAddr);
+        for (uint256 i = 0; i < _guardians.length; i++) {
+            _addGuardian(_guardians[i]);
+        }
+        _setThreshold(_threshold);
+        DOMAIN_SEPARATOR = keccak256(abi.encode(
+            DOMAIN_TYPEHASH,
+            keccak256(bytes("GuardianMultisig")),
+            keccak256(bytes("1")),
+            chainId,
+            address(this)
+        ));
+    }
+
+    /// @return how many guardians there are.
+    function guardiansLength() external view returns (uint256) {
+        return guardians.length;
+    }
+
+    /// @return whether `account` is a guardian.
+    function isGuardian(address account) public view returns (bool) {
+        return _indexes[account] != 0;
+    }
+
+    /// @return the digest that guardians sign to approve `action` at the current nonce.
+    function digest(uint8 action, address account, uint256 value, uint256 deadline)
+        public
+        view
+        returns (bytes32)
+    {
+        return keccak256(abi.encodePacked(
+            "\x19\x01",
+            DOMAIN_SEPARATOR,
+            keccak256(abi.encode(ACTION_TYPEHASH, action, account, value, nonce, deadline))
+        ));
+    }
+
+    /// Carry out `action`, signed by at least `threshold` guardians in signatures (`v`, `r`,
+    /// `s`), in ascending order of signer:
+    /// - PAUSE pauses the Reserve; `account` and `value` must be zero.
+    /// - FREEZE and UNFREEZE freeze and unfreeze `account` on the Reserve.
+    /// - ADD_GUARDIAN and REMOVE_GUARDIAN add and remove the guardian `account`, and set the
+    ///   threshold to `value`.
+    /// - CHANGE_THRESHOLD sets the threshold to `value`.
+    function execute(
+        uint8 action,
+        address account,
+        uint256 value,
+        uint256 deadline,
+        uint8[] memory v,
+        bytes32[] memory r,
+        bytes32[] memory s
+    ) public {
+        require(now <= deadline, "signatures expired");
+        _requireSigned(digest(action, account, value, deadline), v, r, s);
+        emit ActionExecuted(action, account, value, nonce);
+        nonce = nonce.add(1);
+
+        if (action == PAUSE) {
+            require(account == address(0) && value == 0, "pause takes no arguments");
+            reserve.pause();
+        } else if (action == FREEZE) {
+            reserve.freeze(account);
+        } else if (action == UNFREEZE) {
+            reserve.unfreeze(account);
+        } else if (action == ADD_GUARDIAN) {
+            _addGuardian(account);
+            _setThreshold(value);
+        } else if (action == REMOVE_GUARDIAN) {
+            _removeGuardian(account);
+            _setThreshold(value);
+        } else if (action == CHANGE_THRESHOLD) {
+            _setThreshold(value);
+        } else {
+            revert("unknown action");
+        }
+    }
+
+    /// @dev Require that at least `threshold` distinct guardians signed `hash`.
+    function _requireSigned(bytes32 hash, uint8[] memory v, bytes32[] memory r, bytes32[] memory s)
+        internal
+        view
+    {
+        require(v.length == r.length && r.length == s.length, "unequal lengths");
+        require(v.length >= threshold, "too few signatures");
+        address last = address(0);
+        for (uint256 i = 0; i < v.length; i++) {
+            address signer = ECDSA.recover(hash, v[i], r[i], s[i]);
+            require(signer > last, "signers out of order");
+            require(isGuardian(signer), "signer not a guardian");
+            last = signer;
+        }
+    }
+
+    /// @dev Add the guardian `guardian`.
+    function _addGuardian(address guardian) internal {
+        require(guardian != address(0), "cannot be 0 address");
+        require(!isGuardian(guardian), "already a guardian");
+        guardians.push(guardian);
+        _indexes[guardian] = guardians.length;
+        emit GuardianAdded(guardian);
+    }
+
+    /// @dev Remove the guardian `guardian`, moving the last guardian into its place.
+    function _removeGuardian(address guardian) internal {
+        require(isGuardian(guardian), "not a guardian");
+        uint256 index = _indexes[guardian] - 1;
+        address last = guardians[guardians.length - 1];
+        guardians[index] = last;
+        _indexes[last] = index + 1;
+        guardians.length--;
+        delete _indexes[guardian];
+        emit GuardianRemoved(guardian);
+    }
+
+    /// @dev Set the threshold, which must be at least one and at most the number of guardians.
+    function _setThreshold(uint256 _threshold) internal {
+        require(_threshold > 0, "threshold must be positive");
+        require(_threshold <= guardians.length, "threshold above guardians");
+        emit ThresholdChanged(threshold, _threshold);
+        threshold = _threshold;
+    }
+}
@@ -0,0 +1,92 @@
+pragma solidity 0.5.7;
+
+import "./zeppelin/token/ERC20/SafeERC20.sol";
+import "./zeppelin/token/ERC20/IERC20.sol";
+import "./zeppelin/math/SafeMath.sol";
+import "./ownership/Ownable.sol";
+import "./Basket.sol";
+
+/// The parts of the Manager that the InsuranceFund reads.
+interface IInsuredManager {
+    function trustedVault() external view returns (address);
+    function trustedBasket() external view returns (Basket);
+}
+
+/**
+ * The InsuranceFund is a backstop for RSV's backing. It accumulates fees, by being the
+ * Manager's `redemptionFeeRecipient`, along with whatever anyone `deposit`s, and when a basket
+ * token is impaired, such as by a hack or a depeg that leaves the Vault short of it, its owner
+ * can `topUp` the Vault with the fund's holdings of that token.
+ *
+ * Its owner should be the Timelock, so that every draw on the fund is queued in public and
+ * waits out the Timelock's delay. The fund only ever pays the Manager's current Vault, and only
+ * in basket tokens.
+ *
+ * For each token, it records what was deposited and what it has drawn, so that the fees it has
+ * received are its balance, plus what it has drawn, less what was deposited; see `feesReceived`.
+ */
+
+// On "unit" comments, see comment at top of Manager.sol.
+contract InsuranceFund is Ownable {
+    using SafeMath for uint256;
+    using SafeERC20 for IERC20;
+
+    IInsuredManager public manager;
+
+    // What has been deposited of each token, and what the fund has paid the Vault of it.
+    mapping(address => uint256) public deposited; // unit: qToken
+    mapping(address => uint256) public drawn; // unit: qToken
+
+    event ManagerChanged(address indexed oldManager, address indexed newManager);
+    event InsuranceDeposited(address indexed token, address indexed from, uint256 amount);
+    event VaultToppedUp(
+        address indexed token,
+        address indexed vault,
+        uint256 amount,
+        string reason
+    );
+
+    constructor(address managerAddr) public {
+        manager = IInsuredManager(managerAddr);
+    }
+
+    /// Set the Manager, whose Vault and basket the fund tops up.
+    function setManager(address newManager) external onlyOwner {
+        emit ManagerChanged(address(manager), newManager);
+        manager = IInsuredManager(newManager);
+    }
+
+    /// Contribute `amount` of `token` to the fund, out of the sender's allowance to it.
+    /// amount unit: qToken
+    function deposit(address token, uint256 amount) external {
+        require(amount > 0, "cannot deposit 0");
+        IERC20(token).safeTransferFrom(_msgSender(), address(this), amount);
+        deposited[token] = deposited[token].add(amount);
+        emit InsuranceDeposited(token, _msgSender(), amount);
+    }
+
+    /// Pay `amount` of the basket token `token` to the Vault, recognizing an impairment of its
+    /// backing, which `reason` describes.
+    /// amount unit: qToken
+    function topUp(address token, uint256 amount, string calldata reason) external onlyOwner {
+        require(amount > 0, "cannot top up 0");
+        require(manager.trustedBasket().has(token), "token not in basket");
+        address vault = manager.trustedVault();
+        drawn[token] = drawn[token].add(amount);
+        IERC20(token).safeTransfer(vault, amount);
+        emit VaultToppedUp(token, vault, amount, reason);
+    }
+
+    /// @return how much of `token` the fund holds.
+    /// return unit: qToken
+    function balanceOf(address token) public view returns (uint256) {
+        return IERC20(token).balanceOf(address(this));
+    }
+
+    /// @return how much of `token` the fund has received other than by `deposit`: the fees sent
+    /// to it.
+    /// return unit: qToken
+    function feesReceived(address token) external view returns (uint256) {
+        return balanceOf(token).add(drawn[token]).sub(deposited[token]);
+    }
+}
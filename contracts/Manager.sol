@@ -7,12 +7,28 @@ import "./rsv/IRSV.sol";
 import "./ownership/Ownable.sol";
 import "./Basket.sol";
 import "./Proposal.sol";
+import "./CollateralOracle.sol";
+import "./DutchAuction.sol";
+import "./CollateralRegistry.sol";
+import "./WeightMath.sol";
 
 
 interface IVault {
     function withdrawTo(address, uint256, address) external;
 }
 
+/// The part of Uniswap's Permit2 that the Manager calls: the transfer of an allowance that its
+/// `permit` granted.
+interface IPermit2 {
+    function transferFrom(address from, address to, uint160 amount, address token) external;
+}
+
+/// The parts of a Proposal that the Manager reads to tell whether it can be vetoed.
+interface IProposalState {
+    function state() external view returns (uint8);
+    function time() external view returns (uint256);
+}
+
 /**
  * The Manager contract is the point of contact between the Reserve ecosystem and the
  * surrounding world. It manages the Issuance and Redemption of RSV, a decentralized stablecoin
@@ -23,16 +39,18 @@ interface IVault {
  * proposal, then after a pre-determined delay the proposal is eligible for execution by
  * anyone. However, the funds to execute the proposal must come from the proposer.
  *
- * There are two different ways to propose changes to the backing of RSV:
+ * There are three different ways to propose changes to the backing of RSV:
  * - proposeSwap()
  * - proposeWeights()
+ * - proposeRebalance()
  *
  * In both cases, tokens are exchanged with the Vault and a new RSV backing is set. You can
  * think of the first type of proposal as being useful when you don't want to rebalance the
  * Vault by exchanging absolute quantities of tokens; its downside is that you don't know
  * precisely what the resulting basket weights will be. The second type of proposal is more
  * useful when you want to fine-tune the Vault weights and accept the downside that it's
- * difficult to know what capital will be required when the proposal is executed.
+ * difficult to know what capital will be required when the proposal is executed. The third
+ * exchanges a portion of one token for another, leaving the rest of the basket alone.
  */
 
 /* On "unit" comments:
@@ -61,8 +79,13 @@ contract Manager is Ownable {
 
     // ROLES
 
-    // Manager is already Ownable, but in addition it also has an `operator`.
-    address public operator;
+    // Manager is already Ownable, but in addition it also has operators, any of which may act as
+    // the operator. They are in no particular order, each with its index in `operators`, plus one.
+    address[] public operators;
+    mapping(address => uint256) internal _operatorIndexes;
+
+    // The `vetoer` can cancel proposals that have been accepted, until they can be executed.
+    address public vetoer;
 
     // DATA
 
@@ -71,33 +94,104 @@ contract Manager is Ownable {
     IRSV public trustedRSV;
     IProposalFactory public trustedProposalFactory;
 
+    // If set, prices the Vault's tokens, and issuance must leave the Vault worth the supply.
+    ICollateralOracle public trustedOracle;
+
+    // If set, auctions the Vault's tokens outside the basket for basket tokens.
+    IDutchAuction public trustedAuction;
+
+    // If set, lists the only tokens that proposals may bring into the basket.
+    ICollateralRegistry public trustedRegistry;
+
+    // If set, lets issuers pay the collateral through their Permit2 allowances; see
+    // `issueWithPermit2`.
+    IPermit2 public trustedPermit2;
+
+    // The greatest share of the basket's value that each token may have, by the oracle; 0 for
+    // no cap. Checked on issuance, and on accepting and executing proposals.
+    mapping(address => uint256) public exposureCaps; // unit: BPS
+
     // Proposals
     mapping(uint256 => IProposal) public trustedProposals;
     uint256 public proposalsLength;
+    // Proposals before this ID have been cleared. IDs are never reused: clearing only moves this.
+    uint256 public firstLiveProposal;
     uint256 public delay = 24 hours;
 
+    // How long a proposal can wait to be accepted and executed, from when it was made. After its
+    // deadline, it can only be expired.
+    uint256 public proposalValidity = 7 days;
+    mapping(uint256 => uint256) public proposalDeadlines; // unit: seconds
+
+    // The baskets of weight proposals, known before they are executed.
+    mapping(uint256 => Basket) internal _proposedBaskets;
+
     // Controls
     bool public issuancePaused;
+    bool public redemptionPaused;
     bool public emergency;
 
     // The spread between issuance and redemption in basis points (BPS).
     uint256 public seigniorage;              // 0.1% spread -> 10 BPS. unit: BPS
     uint256 constant BPS_FACTOR = 10000;     // This is what 100% looks like in BPS. unit: BPS
-    uint256 constant WEIGHT_SCALE = 10**18; // unit: aqToken/qToken
+
+    // The share of each redemption's collateral paid to `redemptionFeeRecipient`, in BPS.
+    uint256 public redemptionFee;            // unit: BPS
+    address public redemptionFeeRecipient;
+
+    // The share of each issuance's RSV minted to `issuanceFeeRecipient` instead, in BPS.
+    uint256 public issuanceFee;              // unit: BPS
+    address public issuanceFeeRecipient;
+
+    // The exchange rate of a token that isn't an interest-bearing wrapper.
+    uint256 constant RATE_SCALE = 10**18; // unit: aqToken/qToken
+
+    // ERC-165 interface IDs: ERC-165 itself, and issuance and redemption, the XOR of the
+    // selectors of `issue`, `redeem`, `toIssue`, and `toRedeem`
+    bytes4 constant ERC165_INTERFACE_ID = 0x01ffc9a7;
+    bytes4 constant ISSUER_INTERFACE_ID = 0x2e195d20;
+
+    // The selector of Permit2's batch `permit`, the only call that `issueWithPermit2` forwards:
+    // permit(address,((address,uint160,uint48,uint48)[],address,uint256),bytes)
+    bytes4 constant PERMIT2_PERMIT_BATCH = 0x2a2d80d1;
 
     event ProposalsCleared();
 
     // RSV traded events
     event Issuance(address indexed user, uint256 indexed amount);
     event Redemption(address indexed user, uint256 indexed amount);
+    event SingleRedemption(
+        address indexed user,
+        address indexed token,
+        uint256 indexed amount,
+        uint256 tokenAmount
+    );
+    event EmergencyRedemption(address indexed user, uint256 indexed amount);
 
     // Pause events
     event IssuancePausedChanged(bool indexed oldVal, bool indexed newVal);
+    event RedemptionPausedChanged(bool indexed oldVal, bool indexed newVal);
     event EmergencyChanged(bool indexed oldVal, bool indexed newVal);
-    event OperatorChanged(address indexed oldAccount, address indexed newAccount);
+    event OperatorAdded(address indexed account);
+    event OperatorRemoved(address indexed account);
+    event VetoerChanged(address indexed oldAccount, address indexed newAccount);
     event SeigniorageChanged(uint256 oldVal, uint256 newVal);
+    event RedemptionFeeChanged(uint256 oldVal, uint256 newVal);
+    event RedemptionFeeRecipientChanged(address indexed oldAccount, address indexed newAccount);
+    event IssuanceFeeChanged(uint256 oldVal, uint256 newVal);
+    event IssuanceFeeRecipientChanged(address indexed oldAccount, address indexed newAccount);
     event VaultChanged(address indexed oldVaultAddr, address indexed newVaultAddr);
+    event OracleChanged(address indexed oldOracle, address indexed newOracle);
+    event AuctionChanged(address indexed oldAuction, address indexed newAuction);
+    event RegistryChanged(address indexed oldRegistry, address indexed newRegistry);
+    event Permit2Changed(address indexed oldPermit2, address indexed newPermit2);
+    event ExposureCapChanged(address indexed token, uint256 oldVal, uint256 newVal);
     event DelayChanged(uint256 oldVal, uint256 newVal);
+    event ProposalValidityChanged(uint256 oldVal, uint256 newVal);
+
+    // Recovery events, for tokens sent to the Manager or the Vault by mistake
+    event TokenSwept(address indexed token, address indexed to, uint256 amount);
+    event VaultTokenSwept(address indexed token, address indexed to, uint256 amount);
 
     // Proposals
     event WeightsProposed(uint256 indexed id,
@@ -111,8 +205,18 @@ contract Manager is Ownable {
         uint256[] amounts,
         bool[] toVault);
 
+    event RebalanceProposed(uint256 indexed id,
+        address indexed proposer,
+        address fromToken,
+        address toToken,
+        uint256 portion,
+        uint256 rate);
+
     event ProposalAccepted(uint256 indexed id, address indexed proposer);
     event ProposalCanceled(uint256 indexed id, address indexed proposer, address indexed canceler);
+    event ProposalCancelled(uint256 indexed id, address indexed proposer, string reason);
+    event ProposalVetoed(uint256 indexed id, address indexed proposer, address indexed vetoer);
+    event ProposalExpired(uint256 indexed id, address indexed proposer);
     event ProposalExecuted(uint256 indexed id,
         address indexed proposer,
         address indexed executor,
@@ -135,7 +239,7 @@ contract Manager is Ownable {
         trustedRSV = IRSV(rsvAddr);
         trustedProposalFactory = IProposalFactory(proposalFactoryAddr);
         trustedBasket = Basket(basketAddr);
-        operator = operatorAddr;
+        _addOperator(operatorAddr);
         seigniorage = _seigniorage;
         emergency = true; // it's not an emergency, but we want everything to start paused.
     }
@@ -148,15 +252,21 @@ contract Manager is Ownable {
         _;
     }
 
+    /// Modifies a function to run only when redemption is not paused.
+    modifier redemptionNotPaused() {
+        require(!redemptionPaused, "redemption is paused");
+        _;
+    }
+
     /// Modifies a function to run only when there is not some emergency that requires upgrades.
     modifier notEmergency() {
         require(!emergency, "contract is paused");
         _;
     }
 
-    /// Modifies a function to run only when the caller is the operator account.
+    /// Modifies a function to run only when the caller is an operator account.
     modifier onlyOperator() {
-        require(_msgSender() == operator, "operator only");
+        require(isOperator(_msgSender()), "operator only");
         _;
     }
 
@@ -175,6 +285,13 @@ contract Manager is Ownable {
         issuancePaused = val;
     }
 
+    /// Set if redemption, in the basket or in a single token, should be paused. Emergency
+    /// redemption is never paused.
+    function setRedemptionPaused(bool val) external onlyOperator {
+        emit RedemptionPausedChanged(redemptionPaused, val);
+        redemptionPaused = val;
+    }
+
     /// Set if all contract actions should be paused.
     function setEmergency(bool val) external onlyOperator {
         emit EmergencyChanged(emergency, val);
@@ -187,16 +304,70 @@ contract Manager is Ownable {
         trustedVault = IVault(newVaultAddress);
     }
 
-    /// Clear the list of proposals.
+    /// Set the collateral oracle. Address zero turns off the dollar-value check on issuance.
+    /// The oracle also gives the exchange rates of interest-bearing wrappers, so don't change
+    /// or clear it while the basket holds one.
+    function setOracle(address newOracle) external onlyOwner {
+        emit OracleChanged(address(trustedOracle), newOracle);
+        trustedOracle = ICollateralOracle(newOracle);
+    }
+
+    /// Set the collateral registry, or unset it with the zero address. Once it is set, proposals
+    /// can only make baskets of the tokens it approves, so approve the basket's tokens first.
+    function setRegistry(address newRegistry) external onlyOwner {
+        emit RegistryChanged(address(trustedRegistry), newRegistry);
+        trustedRegistry = ICollateralRegistry(newRegistry);
+    }
+
+    /// Set Permit2, or unset it with the zero address, which turns off `issueWithPermit2`.
+    function setPermit2(address newPermit2) external onlyOwner {
+        emit Permit2Changed(address(trustedPermit2), newPermit2);
+        trustedPermit2 = IPermit2(newPermit2);
+    }
+
+    /// Set the greatest share of the basket's value that `token` may have, in BPS, or 0 for no
+    /// cap. Caps are measured by the oracle, so a basket with a capped token needs one.
+    function setExposureCap(address token, uint256 cap) external onlyOwner {
+        require(cap <= BPS_FACTOR, "max exposure cap 100%");
+        emit ExposureCapChanged(token, exposureCaps[token], cap);
+        exposureCaps[token] = cap;
+    }
+
+    /// Set the Dutch auction, whose manager must be this Manager. Lots already started in the
+    /// previous one run on, but only it can close them early.
+    function setAuction(address newAuction) external onlyOwner {
+        emit AuctionChanged(address(trustedAuction), newAuction);
+        trustedAuction = IDutchAuction(newAuction);
+    }
+
+    /// Clear the list of proposals. New proposals still get new IDs.
     function clearProposals() external onlyOperator {
-        proposalsLength = 0;
+        firstLiveProposal = proposalsLength;
         emit ProposalsCleared();
     }
 
-    /// Set the operator.
-    function setOperator(address _operator) external onlyOwner {
-        emit OperatorChanged(operator, _operator);
-        operator = _operator;
+    /// Add the operator `account`.
+    function addOperator(address account) external onlyOwner {
+        _addOperator(account);
+    }
+
+    /// Remove the operator `account`, which may no longer act as the operator from this block on.
+    /// Removing the last operator leaves no one to act as the operator.
+    function removeOperator(address account) external onlyOwner {
+        require(isOperator(account), "not an operator");
+        uint256 index = _operatorIndexes[account] - 1;
+        address last = operators[operators.length - 1];
+        operators[index] = last;
+        _operatorIndexes[last] = index + 1;
+        operators.length--;
+        delete _operatorIndexes[account];
+        emit OperatorRemoved(account);
+    }
+
+    /// Set the vetoer. Address zero leaves no one to veto.
+    function setVetoer(address _vetoer) external onlyOwner {
+        emit VetoerChanged(vetoer, _vetoer);
+        vetoer = _vetoer;
     }
 
     /// Set the seigniorage, in BPS.
@@ -206,33 +377,169 @@ contract Manager is Ownable {
         seigniorage = _seigniorage;
     }
 
-    /// Set the Proposal delay in hours.
+    /// Set the redemption fee, in BPS. A nonzero fee needs a fee recipient.
+    function setRedemptionFee(uint256 _redemptionFee) external onlyOwner {
+        require(_redemptionFee <= 1000, "max redemption fee 10%");
+        require(_redemptionFee == 0 || redemptionFeeRecipient != address(0), "no fee recipient");
+        emit RedemptionFeeChanged(redemptionFee, _redemptionFee);
+        redemptionFee = _redemptionFee;
+    }
+
+    /// Set the account that redemption fees are paid to.
+    function setRedemptionFeeRecipient(address _recipient) external onlyOwner {
+        require(_recipient != address(0), "fee recipient cannot be address zero");
+        emit RedemptionFeeRecipientChanged(redemptionFeeRecipient, _recipient);
+        redemptionFeeRecipient = _recipient;
+    }
+
+    /// Set the issuance fee, in BPS. A nonzero fee needs a fee recipient.
+    function setIssuanceFee(uint256 _issuanceFee) external onlyOwner {
+        require(_issuanceFee <= 1000, "max issuance fee 10%");
+        require(_issuanceFee == 0 || issuanceFeeRecipient != address(0), "no fee recipient");
+        emit IssuanceFeeChanged(issuanceFee, _issuanceFee);
+        issuanceFee = _issuanceFee;
+    }
+
+    /// Set the account that issuance fees are minted to.
+    function setIssuanceFeeRecipient(address _recipient) external onlyOwner {
+        require(_recipient != address(0), "fee recipient cannot be address zero");
+        emit IssuanceFeeRecipientChanged(issuanceFeeRecipient, _recipient);
+        issuanceFeeRecipient = _recipient;
+    }
+
+    /// Set the Proposal delay in hours. It must be under `proposalValidity`, or no accepted
+    /// proposal could be executed before its deadline.
     function setDelay(uint256 _delay) external onlyOwner {
+        require(_delay < proposalValidity, "delay must be under validity");
         emit DelayChanged(delay, _delay);
         delay = _delay;
     }
 
+    /// Set how long new proposals stay valid, in seconds. Proposals already made keep their
+    /// deadlines. It must be over `delay`, or no accepted proposal could be executed in time.
+    function setProposalValidity(uint256 _validity) external onlyOwner {
+        require(_validity > delay, "validity must be over delay");
+        emit ProposalValidityChanged(proposalValidity, _validity);
+        proposalValidity = _validity;
+    }
+
+    /// Send `amount` of `token`, sent to the Manager by mistake, to `to`. The Manager holds no
+    /// tokens of its own, so any token may be swept.
+    function sweep(address token, uint256 amount, address to) external onlyOwner {
+        require(to != address(0), "cannot be 0 address");
+        IERC20(token).safeTransfer(to, amount);
+        emit TokenSwept(token, to, amount);
+    }
+
+    /// Send `amount` of `token`, sent to the Vault by mistake, to `to`. Neither a token in the
+    /// basket nor RSV can be swept, and the Vault must stay fully collateralized.
+    /// A YieldVault's shares in a yield source aren't in the basket, but it tracks them, so
+    /// withdraw them with YieldVault.withdraw instead.
+    function sweepVault(address token, uint256 amount, address to)
+        external
+        onlyOwner
+        vaultCollateralized
+    {
+        require(to != address(0), "cannot be 0 address");
+        require(!trustedBasket.has(token), "cannot sweep collateral");
+        require(token != address(trustedRSV), "cannot sweep RSV");
+        trustedVault.withdrawTo(token, amount, to);
+        emit VaultTokenSwept(token, to, amount);
+    }
+
+    /// Auction `sellAmount` of `sellToken`, which the Vault holds outside the basket, for the
+    /// basket token `buyToken`, at a price falling from `startPrice` to `endPrice` over
+    /// `duration` seconds; see DutchAuction. The proceeds, and anything unsold, go to the Vault.
+    /// RSV can't be auctioned, and the Vault must stay fully collateralized.
+    /// startPrice, endPrice unit: aqBuyToken/qSellToken
+    /// @return the lot's ID in the auction.
+    function startAuction(
+        address sellToken,
+        address buyToken,
+        uint256 sellAmount,
+        uint256 startPrice,
+        uint256 endPrice,
+        uint256 duration
+    ) external onlyOwner notEmergency vaultCollateralized returns (uint256) {
+        require(address(trustedAuction) != address(0), "no auction");
+        require(!trustedBasket.has(sellToken), "cannot auction collateral");
+        require(sellToken != address(trustedRSV), "cannot auction RSV");
+        require(trustedBasket.has(buyToken), "can only buy collateral");
+        trustedVault.withdrawTo(sellToken, sellAmount, address(trustedAuction));
+        return trustedAuction.start(
+            sellToken,
+            buyToken,
+            sellAmount,
+            startPrice,
+            endPrice,
+            duration,
+            address(trustedVault)
+        );
+    }
+
+    /// Close lot `id` of the auction before it ends, returning what is unsold to its Vault.
+    function cancelAuction(uint256 id) external onlyOperator {
+        trustedAuction.close(id);
+    }
+
+    /// @return whether the Manager implements the interface `interfaceId`, per
+    /// [ERC-165](https://eips.ethereum.org/EIPS/eip-165): ERC-165 itself, and issuance and
+    /// redemption.
+    function supportsInterface(bytes4 interfaceId) external pure returns (bool) {
+        return interfaceId == ERC165_INTERFACE_ID || interfaceId == ISSUER_INTERFACE_ID;
+    }
+
+    /// @return how many operators there are.
+    function operatorsLength() external view returns (uint256) {
+        return operators.length;
+    }
+
+    /// @return whether `account` is an operator.
+    function isOperator(address account) public view returns (bool) {
+        return _operatorIndexes[account] != 0;
+    }
+
     /// Ensure that the Vault is fully collateralized.  That this is true should be an
     /// invariant of this contract: it's true before and after every txn.
     function isFullyCollateralized() public view returns(bool) {
-        uint256 scaleFactor = WEIGHT_SCALE.mul(uint256(10) ** trustedRSV.decimals());
-        // scaleFactor unit: aqToken/qToken * qRSV/RSV
-
         for (uint256 i = 0; i < trustedBasket.size(); i++) {
 
             address trustedToken = trustedBasket.tokens(i);
             uint256 weight = trustedBasket.weights(trustedToken); // unit: aqToken/RSV
             uint256 balance = IERC20(trustedToken).balanceOf(address(trustedVault)); //unit: qToken
 
-            // Return false if this token is undercollateralized:
-            if (trustedRSV.totalSupply().mul(weight) > balance.mul(scaleFactor)) {
-                // checking units: [qRSV] * [aqToken/RSV] == [qToken] * [aqToken/qToken * qRSV/RSV]
+            // Return false if this token is undercollateralized: if the supply, weighted and
+            // rounded up, needs more than the balance.
+            uint256 needed = _weighted(
+                trustedToken,
+                trustedRSV.totalSupply(),
+                weight,
+                RoundingMode.UP
+            ); // unit: qToken
+            if (needed > balance) {
+                // checking units: [qToken] > [qToken]
                 return false;
             }
         }
         return true;
     }
 
+    /// Get the dollar value of the Vault's basket tokens, by the oracle, which reverts if any
+    /// token's price is stale or too far from a dollar.
+    /// return unit: aUSD (10**-18 dollars)
+    function collateralValue() public view returns (uint256) {
+        require(address(trustedOracle) != address(0), "no oracle");
+        uint256 total; // unit: aUSD
+        for (uint256 i = 0; i < trustedBasket.size(); i++) {
+            address trustedToken = trustedBasket.tokens(i);
+            total = total.add(trustedOracle.value(
+                trustedToken,
+                IERC20(trustedToken).balanceOf(address(trustedVault))
+            ));
+        }
+        return total;
+    }
+
     /// Get amounts of basket tokens required to issue an amount of RSV.
     /// The returned array will be in the same order as the current basket.tokens.
     /// return unit: qToken[]
@@ -250,6 +557,7 @@ contract Manager is Ownable {
         for (uint256 i = 0; i < trustedBasket.size(); i++) {
             address trustedToken = trustedBasket.tokens(i);
             amounts[i] = _weighted(
+                trustedToken,
                 effectiveAmount,
                 trustedBasket.weights(trustedToken),
                 RoundingMode.UP
@@ -260,10 +568,21 @@ contract Manager is Ownable {
         return amounts; // unit: qToken[]
     }
 
-    /// Get amounts of basket tokens that would be sent upon redeeming an amount of RSV.
+    /// Get amounts of basket tokens that would be sent to the redeemer upon redeeming an amount
+    /// of RSV, after the redemption fee.
     /// The returned array will be in the same order as the current basket.tokens.
     /// return unit: qToken[]
     function toRedeem(uint256 rsvAmount) public view returns (uint256[] memory) {
+        // rsvAmount unit: qRSV
+        uint256 fee = rsvAmount.mul(redemptionFee).div(BPS_FACTOR);
+        // fee unit: qRSV == qRSV*BPS/BPS
+        return _toRedeem(rsvAmount.sub(fee));
+    }
+
+    /// Get amounts of basket tokens that leave the vault upon redeeming an amount of RSV: what
+    /// the redeemer gets, and the redemption fee.
+    /// return unit: qToken[]
+    function _toRedeem(uint256 rsvAmount) internal view returns (uint256[] memory) {
         // rsvAmount unit: qRSV
         uint256[] memory amounts = new uint256[](trustedBasket.size());
 
@@ -272,6 +591,7 @@ contract Manager is Ownable {
         for (uint256 i = 0; i < trustedBasket.size(); i++) {
             address trustedToken = trustedBasket.tokens(i);
             amounts[i] = _weighted(
+                trustedToken,
                 rsvAmount,
                 trustedBasket.weights(trustedToken),
                 RoundingMode.DOWN
@@ -282,6 +602,29 @@ contract Manager is Ownable {
         return amounts;
     }
 
+    /// Get the amount of `token` that would be sent to the redeemer upon redeeming an amount of
+    /// RSV in that token alone, after the redemption fee; see `redeemSingle`.
+    /// return unit: qToken
+    function toRedeemSingle(address token, uint256 rsvAmount) public view returns (uint256) {
+        // rsvAmount unit: qRSV
+        uint256 fee = rsvAmount.mul(redemptionFee).div(BPS_FACTOR);
+        // fee unit: qRSV == qRSV*BPS/BPS
+        return _toRedeemSingle(token, rsvAmount.sub(fee));
+    }
+
+    /// Get the amount of `token` worth an amount of RSV at a dollar per RSV, by the oracle,
+    /// which reverts if the token's price is stale or too far from a dollar.
+    /// return unit: qToken
+    function _toRedeemSingle(address token, uint256 rsvAmount) internal view returns (uint256) {
+        // rsvAmount unit: qRSV
+        require(address(trustedOracle) != address(0), "no oracle");
+        return trustedOracle.amountOf(
+            token,
+            rsvAmount.mul(10**18).div(uint256(10) ** trustedRSV.decimals())
+        );
+        // unit check: aUSD == qRSV * aUSD/RSV / (qRSV/RSV)
+    }
+
     /// Handles issuance.
     /// rsvAmount unit: qRSV
     function issue(uint256 rsvAmount) external
@@ -289,30 +632,168 @@ contract Manager is Ownable {
         notEmergency
         vaultCollateralized
     {
+        _issue(rsvAmount, false);
+    }
+
+    /// Handles issuance, pulling the collateral through the sender's Permit2 allowances to the
+    /// Manager rather than its allowances to the Manager, so that an issuer who has approved
+    /// Permit2 needs no approval of the Manager for each token. `permitCall`, if not empty, is
+    /// a call of Permit2's batch `permit`, signed by the sender, which the Manager makes first
+    /// to grant those allowances; otherwise, the allowances must already be granted.
+    /// rsvAmount unit: qRSV
+    function issueWithPermit2(uint256 rsvAmount, bytes calldata permitCall) external
+        issuanceNotPaused
+        notEmergency
+        vaultCollateralized
+    {
+        require(address(trustedPermit2) != address(0), "no permit2");
+        if (permitCall.length > 0) {
+            _permit2(permitCall);
+        }
+        _issue(rsvAmount, true);
+    }
+
+    /// Handles issuance, like `issue`, but fails unless the basket is `tokens`, in order, and
+    /// the collateral taken of each is at most the amount at the same index of `maxAmountsIn`.
+    /// This protects an issuer who quoted the amounts from a basket change landing first.
+    /// rsvAmount unit: qRSV
+    /// maxAmountsIn unit: qToken[]
+    function issueWithMaxIn(
+        uint256 rsvAmount,
+        address[] calldata tokens,
+        uint256[] calldata maxAmountsIn
+    ) external
+        issuanceNotPaused
+        notEmergency
+        vaultCollateralized
+    {
+        _requireBasket(tokens, maxAmountsIn.length);
+        uint256[] memory amounts = _issue(rsvAmount, false); // unit: qToken[]
+        for (uint256 i = 0; i < amounts.length; i++) {
+            require(amounts[i] <= maxAmountsIn[i], "collateral in above max");
+        }
+    }
+
+    /// @dev Make the Permit2 `permit` call `permitCall`, which must be a batch permit of the
+    /// sender's. The Manager is a spender of Permit2 allowances, so it mustn't make other calls.
+    function _permit2(bytes memory permitCall) internal {
+        require(permitCall.length >= 36, "not a permit2 permit");
+        bytes32 selector;
+        uint256 owner;
+        assembly {
+            selector := mload(add(permitCall, 32))
+            owner := mload(add(permitCall, 36))
+        }
+        require(bytes4(selector) == PERMIT2_PERMIT_BATCH, "not a permit2 permit");
+        require(address(uint160(owner)) == _msgSender(), "not the sender's permit");
+        (bool success, ) = address(trustedPermit2).call(permitCall);
+        require(success, "permit2 permit failed");
+    }
+
+    /// @dev Require that the basket is `tokens`, in order, and that there are `limitsLength`
+    /// limits, one for each.
+    function _requireBasket(address[] memory tokens, uint256 limitsLength) internal view {
+        require(tokens.length == limitsLength, "unequal lengths");
+        require(tokens.length == trustedBasket.size(), "basket changed");
+        for (uint256 i = 0; i < tokens.length; i++) {
+            require(tokens[i] == trustedBasket.tokens(i), "basket changed");
+        }
+    }
+
+    /// @dev Issue `rsvAmount` to the sender, for collateral pulled through Permit2 if
+    /// `viaPermit2`, and otherwise by the sender's allowances.
+    /// @return the amounts of collateral taken.
+    /// rsvAmount unit: qRSV
+    /// return unit: qToken[]
+    function _issue(uint256 rsvAmount, bool viaPermit2) internal returns (uint256[] memory) {
         require(rsvAmount > 0, "cannot issue zero RSV");
         require(trustedBasket.size() > 0, "basket cannot be empty");
 
         // Accept collateral tokens.
         uint256[] memory amounts = toIssue(rsvAmount); // unit: qToken[]
         for (uint256 i = 0; i < trustedBasket.size(); i++) {
-            IERC20(trustedBasket.tokens(i)).safeTransferFrom(
-                _msgSender(),
-                address(trustedVault),
-                amounts[i]
-            );
+            if (viaPermit2) {
+                require(amounts[i] < 2**160, "amount too large for permit2");
+                trustedPermit2.transferFrom(
+                    _msgSender(),
+                    address(trustedVault),
+                    uint160(amounts[i]),
+                    trustedBasket.tokens(i)
+                );
+            } else {
+                IERC20(trustedBasket.tokens(i)).safeTransferFrom(
+                    _msgSender(),
+                    address(trustedVault),
+                    amounts[i]
+                );
+            }
             // unit check for amounts[i]: qToken.
         }
 
-        // Compensate with RSV.
-        trustedRSV.mint(_msgSender(), rsvAmount);
+        // Compensate with RSV, less the fee. The collateral backs all of rsvAmount, so that
+        // the fee is as backed as the rest; it rounds down, in the issuer's favor.
+        uint256 fee = rsvAmount.mul(issuanceFee).div(BPS_FACTOR);
+        // fee unit: qRSV == qRSV*BPS/BPS
+        trustedRSV.mint(_msgSender(), rsvAmount.sub(fee));
+        if (fee > 0) {
+            trustedRSV.mint(issuanceFeeRecipient, fee);
+        }
         // unit check for rsvAmount: qRSV.
 
+        // With an oracle, the Vault must be worth at least a dollar per RSV. Redemption isn't
+        // checked, so that holders can always leave.
+        if (address(trustedOracle) != address(0)) {
+            require(
+                collateralValue() >= trustedRSV.totalSupply().mul(10**18).div(
+                    uint256(10) ** trustedRSV.decimals()
+                ),
+                "collateral value below supply"
+            );
+            // unit check: aUSD >= qRSV * aUSD/RSV / (qRSV/RSV)
+        }
+        _requireExposure(trustedBasket, true);
+
         emit Issuance(_msgSender(), rsvAmount);
+        return amounts;
     }
 
     /// Handles redemption.
     /// rsvAmount unit: qRSV
-    function redeem(uint256 rsvAmount) external notEmergency vaultCollateralized {
+    function redeem(uint256 rsvAmount)
+        external
+        redemptionNotPaused
+        notEmergency
+        vaultCollateralized
+    {
+        _redeem(rsvAmount);
+    }
+
+    /// Handles redemption, like `redeem`, but fails unless the basket is `tokens`, in order, and
+    /// the collateral paid of each is at least the amount at the same index of `minAmountsOut`.
+    /// This protects a redeemer who quoted the amounts from a basket change landing first.
+    /// rsvAmount unit: qRSV
+    /// minAmountsOut unit: qToken[]
+    function redeemWithMinOut(
+        uint256 rsvAmount,
+        address[] calldata tokens,
+        uint256[] calldata minAmountsOut
+    ) external
+        redemptionNotPaused
+        notEmergency
+        vaultCollateralized
+    {
+        _requireBasket(tokens, minAmountsOut.length);
+        uint256[] memory amounts = _redeem(rsvAmount); // unit: qToken[]
+        for (uint256 i = 0; i < amounts.length; i++) {
+            require(amounts[i] >= minAmountsOut[i], "collateral out below min");
+        }
+    }
+
+    /// @dev Redeem `rsvAmount` of the sender's RSV for the basket.
+    /// @return the amounts of collateral paid to the sender, net of the fee.
+    /// rsvAmount unit: qRSV
+    /// return unit: qToken[]
+    function _redeem(uint256 rsvAmount) internal returns (uint256[] memory) {
         require(rsvAmount > 0, "cannot redeem 0 RSV");
         require(trustedBasket.size() > 0, "basket cannot be empty");
 
@@ -320,14 +801,98 @@ contract Manager is Ownable {
         trustedRSV.burnFrom(_msgSender(), rsvAmount);
         // unit check: rsvAmount is qRSV.
 
-        // Compensate with collateral tokens.
+        // Compensate with collateral tokens, less the fee. The fee is what the whole amount
+        // would withdraw, less what the redeemer gets, so that together they leave the vault
+        // no less backed than a redemption without a fee.
         uint256[] memory amounts = toRedeem(rsvAmount); // unit: qToken[]
+        uint256[] memory gross = _toRedeem(rsvAmount); // unit: qToken[]
         for (uint256 i = 0; i < trustedBasket.size(); i++) {
             trustedVault.withdrawTo(trustedBasket.tokens(i), amounts[i], _msgSender());
             // unit check for amounts[i]: qToken.
+            if (gross[i] > amounts[i]) {
+                trustedVault.withdrawTo(
+                    trustedBasket.tokens(i),
+                    gross[i] - amounts[i],
+                    redemptionFeeRecipient
+                );
+            }
         }
 
         emit Redemption(_msgSender(), rsvAmount);
+        return amounts;
+    }
+
+    /// Handles redemption in a single basket token, `token`, rather than the whole basket. The
+    /// redeemer gets the worth of rsvAmount in `token`, at a dollar per RSV by the oracle's
+    /// price, less the redemption fee. It needs the oracle to price `token`, and the Vault to
+    /// hold that much of it beyond what the basket needs for the rest of the supply.
+    /// rsvAmount unit: qRSV
+    function redeemSingle(address token, uint256 rsvAmount)
+        external
+        redemptionNotPaused
+        notEmergency
+        vaultCollateralized
+    {
+        require(rsvAmount > 0, "cannot redeem 0 RSV");
+        require(trustedBasket.has(token), "token not in basket");
+
+        // As with `redeem`, the fee is what the whole amount would withdraw, less what the
+        // redeemer gets.
+        uint256 amount = toRedeemSingle(token, rsvAmount); // unit: qToken
+        uint256 gross = _toRedeemSingle(token, rsvAmount); // unit: qToken
+
+        // The rest of the basket stays in the Vault, so what's left of `token` must still back
+        // the remaining supply on its own.
+        uint256 needed = _weighted(
+            token,
+            trustedRSV.totalSupply().sub(rsvAmount),
+            trustedBasket.weights(token),
+            RoundingMode.UP
+        ); // unit: qToken
+        require(
+            IERC20(token).balanceOf(address(trustedVault)) >= needed.add(gross),
+            "insufficient inventory"
+        );
+        // unit check: qToken >= qToken + qToken
+
+        trustedRSV.burnFrom(_msgSender(), rsvAmount);
+        trustedVault.withdrawTo(token, amount, _msgSender());
+        if (gross > amount) {
+            trustedVault.withdrawTo(token, gross - amount, redemptionFeeRecipient);
+        }
+
+        emit SingleRedemption(_msgSender(), token, rsvAmount, amount);
+    }
+
+    /// Handles emergency redemption, which pays out a pro-rata share of every basket token in
+    /// the Vault, rounded down, rather than the basket's weights. It needs neither the Manager
+    /// nor the Vault to be healthy, only the Reserve to have opened emergency redemption; see
+    /// Reserve.emergencyRedemptionOpen. No fee is taken.
+    /// rsvAmount unit: qRSV
+    function emergencyRedeem(uint256 rsvAmount) external {
+        require(rsvAmount > 0, "cannot redeem 0 RSV");
+        require(trustedRSV.emergencyRedemptionOpen(), "emergency redemption not open");
+
+        // Compute the shares before burning, while rsvAmount is still in the supply.
+        uint256 supply = trustedRSV.totalSupply(); // unit: qRSV
+        uint256[] memory amounts = new uint256[](trustedBasket.size()); // unit: qToken[]
+        for (uint256 i = 0; i < trustedBasket.size(); i++) {
+            amounts[i] = WeightMath.mulDiv(
+                IERC20(trustedBasket.tokens(i)).balanceOf(address(trustedVault)),
+                rsvAmount,
+                supply
+            );
+            // unit check: qToken == qToken * qRSV / qRSV
+        }
+
+        trustedRSV.emergencyBurnFrom(_msgSender(), rsvAmount);
+        for (uint256 i = 0; i < trustedBasket.size(); i++) {
+            if (amounts[i] > 0) {
+                trustedVault.withdrawTo(trustedBasket.tokens(i), amounts[i], _msgSender());
+            }
+        }
+
+        emit EmergencyRedemption(_msgSender(), rsvAmount);
     }
 
     /**
@@ -375,7 +940,13 @@ contract Manager is Ownable {
     {
         require(tokens.length == amounts.length && amounts.length == toVault.length,
             "proposeSwap: unequal lengths");
+        for (uint256 i = 0; i < tokens.length; i++) {
+            if (toVault[i]) {
+                _requireCollateral(tokens[i], 0);
+            }
+        }
         uint256 proposalID = proposalsLength++;
+        proposalDeadlines[proposalID] = now.add(proposalValidity);
 
         trustedProposals[proposalID] = trustedProposalFactory.createSwapProposal(
             _msgSender(),
@@ -405,12 +976,17 @@ contract Manager is Ownable {
     {
         require(tokens.length == weights.length, "proposeWeights: unequal lengths");
         require(tokens.length > 0, "proposeWeights: zero length");
+        for (uint256 i = 0; i < tokens.length; i++) {
+            _requireCollateral(tokens[i], weights[i]);
+        }
 
         uint256 proposalID = proposalsLength++;
+        proposalDeadlines[proposalID] = now.add(proposalValidity);
+        _proposedBaskets[proposalID] = new Basket(Basket(0), tokens, weights);
 
         trustedProposals[proposalID] = trustedProposalFactory.createWeightProposal(
             _msgSender(),
-            new Basket(Basket(0), tokens, weights)
+            _proposedBaskets[proposalID]
         );
         trustedProposals[proposalID].acceptOwnership();
 
@@ -418,9 +994,51 @@ contract Manager is Ownable {
         return proposalID;
     }
 
-    /// Accepts a proposal for a new basket, beginning the required delay.
+    /**
+     * Propose exchanging `portion` (in BPS) of the weight of `fromToken` in the basket for
+     * `toToken`, at `rate` aqToToken per aqFromToken, scaled by 10**18. The other tokens' weights
+     * are left as they are when the proposal is executed.
+     *
+     * Note: Like proposeWeights, the amounts of tokens exchanged with the proposer depend on the
+     * supply of RSV when the proposal is executed.
+     *
+     * Returns the new proposal's ID.
+     */
+    function proposeRebalance(address fromToken, address toToken, uint256 portion, uint256 rate)
+    external notEmergency vaultCollateralized returns(uint256)
+    {
+        _requireCollateral(toToken, 0);
+        uint256 proposalID = proposalsLength++;
+        proposalDeadlines[proposalID] = now.add(proposalValidity);
+
+        trustedProposals[proposalID] = trustedProposalFactory.createRebalanceProposal(
+            _msgSender(),
+            fromToken,
+            toToken,
+            portion,
+            rate
+        );
+        trustedProposals[proposalID].acceptOwnership();
+
+        emit RebalanceProposed(proposalID, _msgSender(), fromToken, toToken, portion, rate);
+        return proposalID;
+    }
+
+    /// Accepts a proposal for a new basket, beginning the required delay. It must leave time to
+    /// execute the proposal after the delay and before its deadline; past the deadline, it expires
+    /// the proposal instead.
     function acceptProposal(uint256 id) external onlyOperator notEmergency vaultCollateralized {
-        require(proposalsLength > id, "proposals length <= id");
+        _requireLiveProposal(id);
+        if (now > proposalDeadlines[id]) {
+            _expireProposal(id);
+            return;
+        }
+        // A proposal can be executed only after its delay, and only until its deadline.
+        require(now.add(delay) < proposalDeadlines[id], "too late to accept");
+        // Swap and rebalance proposals' baskets are only known once executed.
+        if (address(_proposedBaskets[id]) != address(0)) {
+            _requireExposure(_proposedBaskets[id], false);
+        }
         trustedProposals[id].accept(now.add(delay));
         emit ProposalAccepted(id, trustedProposals[id].proposer());
     }
@@ -431,17 +1049,59 @@ contract Manager is Ownable {
         require(
             _msgSender() == trustedProposals[id].proposer() ||
             _msgSender() == owner() ||
-            _msgSender() == operator,
+            isOperator(_msgSender()),
             "cannot cancel"
         );
-        require(proposalsLength > id, "proposals length <= id");
+        _requireLiveProposal(id);
         trustedProposals[id].cancel();
         emit ProposalCanceled(id, trustedProposals[id].proposer(), _msgSender());
     }
 
-    /// Executes a proposal by exchanging collateral tokens with the proposer.
+    /// Cancels a proposal of the sender's own that is still pending, created or accepted, giving
+    /// `reason` for the record.
+    function withdrawProposal(uint256 id, string calldata reason)
+    external notEmergency vaultCollateralized
+    {
+        _requireLiveProposal(id);
+        require(_msgSender() == trustedProposals[id].proposer(), "proposer only");
+        uint8 state = IProposalState(address(trustedProposals[id])).state();
+        require(
+            state == uint8(Proposal.State.Created) || state == uint8(Proposal.State.Accepted),
+            "proposal not pending"
+        );
+        trustedProposals[id].cancel();
+        emit ProposalCancelled(id, _msgSender(), reason);
+    }
+
+    /// Expires a pending proposal whose deadline has passed, cancelling it, as accepting or
+    /// executing it would. Anyone can expire a proposal.
+    function expireProposal(uint256 id) external {
+        _requireLiveProposal(id);
+        require(now > proposalDeadlines[id], "proposal not expired");
+        _expireProposal(id);
+    }
+
+    /// Vetoes a proposal that has been accepted, while it waits out the delay, cancelling it.
+    /// Only the vetoer can veto, and it can even during an emergency, so that a proposal it
+    /// distrusts never runs.
+    function vetoProposal(uint256 id) external {
+        require(_msgSender() == vetoer, "vetoer only");
+        _requireLiveProposal(id);
+        IProposalState proposal = IProposalState(address(trustedProposals[id]));
+        require(proposal.state() == uint8(Proposal.State.Accepted), "proposal not accepted");
+        require(now <= proposal.time(), "veto window over");
+        trustedProposals[id].cancel();
+        emit ProposalVetoed(id, trustedProposals[id].proposer(), _msgSender());
+    }
+
+    /// Executes a proposal by exchanging collateral tokens with the proposer. Past the
+    /// proposal's deadline, it expires the proposal instead.
     function executeProposal(uint256 id) external onlyOperator notEmergency vaultCollateralized {
-        require(proposalsLength > id, "proposals length <= id");
+        _requireLiveProposal(id);
+        if (now > proposalDeadlines[id]) {
+            _expireProposal(id);
+            return;
+        }
         address proposer = trustedProposals[id].proposer();
         Basket trustedOldBasket = trustedBasket;
 
@@ -460,6 +1120,7 @@ contract Manager is Ownable {
         }
         for (uint256 i = 0; i < trustedBasket.size(); i++) {
             address trustedToken = trustedBasket.tokens(i);
+            _requireCollateral(trustedToken, trustedBasket.weights(trustedToken));
             if (!trustedOldBasket.has(trustedToken)) {
                 _executeBasketShift(
                     trustedOldBasket.weights(trustedToken),
@@ -469,6 +1130,7 @@ contract Manager is Ownable {
                 );
             }
         }
+        _requireExposure(trustedBasket, false);
 
         emit ProposalExecuted(
             id,
@@ -482,6 +1144,78 @@ contract Manager is Ownable {
 
     // ============================= Internal ================================
 
+    /// Adds the operator `account`.
+    function _addOperator(address account) internal {
+        require(account != address(0), "cannot be 0 address");
+        require(!isOperator(account), "already an operator");
+        operators.push(account);
+        _operatorIndexes[account] = operators.length;
+        emit OperatorAdded(account);
+    }
+
+    /// Expires proposal `id`, which must still be pending, cancelling it.
+    function _expireProposal(uint256 id) internal {
+        uint8 state = IProposalState(address(trustedProposals[id])).state();
+        require(
+            state == uint8(Proposal.State.Created) || state == uint8(Proposal.State.Accepted),
+            "proposal not pending"
+        );
+        trustedProposals[id].cancel();
+        emit ProposalExpired(id, trustedProposals[id].proposer());
+    }
+
+    /// Requires that proposal `id` has been made and hasn't been cleared.
+    function _requireLiveProposal(uint256 id) internal view {
+        require(proposalsLength > id, "proposals length <= id");
+        require(id >= firstLiveProposal, "proposal cleared");
+    }
+
+    /// Requires, if the collateral registry is set, that it approves `token`, with a cap of at
+    /// least `weight`.
+    function _requireCollateral(address token, uint256 weight) internal view {
+        if (address(trustedRegistry) == address(0)) {
+            return;
+        }
+        require(trustedRegistry.isApproved(token), "token not approved");
+        uint256 cap = trustedRegistry.capOf(token); // unit: aqToken/RSV
+        require(cap == 0 || weight <= cap, "weight above cap");
+    }
+
+    /// Requires that no token of `basket` is worth more than its exposure cap of the whole, by
+    /// the oracle: of the Vault's balances if `held`, or else of the basket's weights.
+    function _requireExposure(Basket basket, bool held) internal view {
+        bool capped;
+        for (uint256 i = 0; i < basket.size(); i++) {
+            capped = capped || exposureCaps[basket.tokens(i)] > 0;
+        }
+        if (!capped) {
+            return;
+        }
+        require(address(trustedOracle) != address(0), "no oracle");
+
+        // The weights are valued as the tokens backing 1 RSV, rounded down.
+        uint256[] memory values = new uint256[](basket.size()); // unit: aUSD
+        uint256 total; // unit: aUSD
+        for (uint256 i = 0; i < basket.size(); i++) {
+            address token = basket.tokens(i);
+            uint256 amount = held ?
+                IERC20(token).balanceOf(address(trustedVault)) :
+                _weighted(
+                    token,
+                    uint256(10) ** trustedRSV.decimals(),
+                    basket.weights(token),
+                    RoundingMode.DOWN
+                ); // unit: qToken
+            values[i] = trustedOracle.value(token, amount);
+            total = total.add(values[i]);
+        }
+        for (uint256 i = 0; i < basket.size(); i++) {
+            uint256 cap = exposureCaps[basket.tokens(i)]; // unit: BPS
+            require(cap == 0 || values[i].mul(BPS_FACTOR) <= total.mul(cap), "exposure above cap");
+            // unit check: aUSD * BPS <= aUSD * BPS
+        }
+    }
+
     /// _executeBasketShift transfers the necessary amount of `token` between vault and `proposer`
     /// to rebalance the vault's balance of token, as it goes from oldBasket to newBasket.
     /// @dev To carry out a proposal, this is executed once per relevant token.
@@ -495,6 +1229,7 @@ contract Manager is Ownable {
             // This token must increase in the vault, so transfer from proposer to vault.
             // (Transfer into vault: round up)
             uint256 transferAmount =_weighted(
+                trustedToken,
                 trustedRSV.totalSupply(),
                 newWeight.sub(oldWeight),
                 RoundingMode.UP
@@ -513,6 +1248,7 @@ contract Manager is Ownable {
             // This token will decrease in the vault, so transfer from vault to proposer.
             // (Transfer out of vault: round down)
             uint256 transferAmount =_weighted(
+                trustedToken,
                 trustedRSV.totalSupply(),
                 oldWeight.sub(newWeight),
                 RoundingMode.DOWN
@@ -524,6 +1260,15 @@ contract Manager is Ownable {
         }
     }
 
+    /// The exchange rate of `trustedToken` for its underlying token, from the oracle, if any.
+    /// return unit: aqUnderlying/qToken
+    function _exchangeRate(address trustedToken) internal view returns(uint256) {
+        if (address(trustedOracle) == address(0)) {
+            return RATE_SCALE;
+        }
+        return trustedOracle.exchangeRate(trustedToken);
+    }
+
     // When you perform a weighting of some amount of RSV, it will involve a division, and
     // precision will be lost. When it rounds, do you want to round UP or DOWN? Be maximally
     // conservative.
@@ -531,22 +1276,24 @@ contract Manager is Ownable {
 
     /// From a weighting of RSV (e.g., a basket weight) and an amount of RSV,
     /// compute the amount of the weighted token that matches that amount of RSV.
+    /// The weight of an interest-bearing wrapper is in its underlying token, which the
+    /// exchange rate converts to the wrapper; for any other token, the rate is RATE_SCALE.
     function _weighted(
+        address trustedToken,
         uint256 amount, // unit: qRSV
         uint256 weight, // unit: aqToken/RSV
         RoundingMode rnd
     ) internal view returns(uint256) // return unit: qTokens
     {
-        uint256 scaleFactor = WEIGHT_SCALE.mul(uint256(10)**(trustedRSV.decimals()));
+        uint256 scaleFactor = WeightMath.scale(trustedRSV.decimals(), _exchangeRate(trustedToken));
         // scaleFactor unit: aqTokens/qTokens * qRSV/RSV
-        uint256 shiftedWeight = amount.mul(weight);
-        // shiftedWeight unit: qRSV/RSV * aqTokens
 
-        // If the weighting is precise, or we're rounding down, then use normal division.
-        if (rnd == RoundingMode.DOWN || shiftedWeight.mod(scaleFactor) == 0) {
-            return shiftedWeight.div(scaleFactor);
+        // The product, qRSV/RSV * aqTokens, can overflow for tokens of many decimals, so
+        // WeightMath keeps it to 512 bits.
+        if (rnd == RoundingMode.DOWN) {
+            return WeightMath.mulDiv(amount, weight, scaleFactor);
             // return unit: qTokens == qRSV/RSV * aqTokens * (qTokens/aqTokens * RSV/qRSV)
         }
-        return shiftedWeight.div(scaleFactor).add(1); // return unit: qTokens
+        return WeightMath.mulDivUp(amount, weight, scaleFactor); // return unit: qTokens
     }
 }
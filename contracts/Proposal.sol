@@ -5,6 +5,7 @@ import "./zeppelin/token/ERC20/SafeERC20.sol";
 import "./rsv/IRSV.sol";
 import "./ownership/Ownable.sol";
 import "./Basket.sol";
+import "./WeightMath.sol";
 
 /**
  * A Proposal represents a suggestion to change the backing for RSV.
@@ -23,6 +24,8 @@ import "./Basket.sol";
  *   proposal execution.
  * - A specific quantity of tokens to be exchanged is proposed, and the resultant RSV basket is
  *   determined at the time of proposal execution.
+ * - A portion of one token's weight is proposed to be exchanged for another token at a given
+ *   rate, leaving the rest of the basket as it is at the time of proposal execution.
  */
 
 interface IProposal {
@@ -42,6 +45,14 @@ interface IProposalFactory {
     ) external returns (IProposal);
 
     function createWeightProposal(address proposer, Basket basket) external returns (IProposal);
+
+    function createRebalanceProposal(
+        address proposer,
+        address fromToken,
+        address toToken,
+        uint256 portion,
+        uint256 rate
+    ) external returns (IProposal);
 }
 
 contract ProposalFactory is IProposalFactory {
@@ -63,6 +74,22 @@ contract ProposalFactory is IProposalFactory {
         proposal.nominateNewOwner(msg.sender);
         return proposal;
     }
+
+    function createRebalanceProposal(
+        address proposer,
+        address fromToken,
+        address toToken,
+        uint256 portion,
+        uint256 rate
+    )
+        external returns (IProposal)
+    {
+        IProposal proposal = IProposal(
+            new RebalanceProposal(proposer, fromToken, toToken, portion, rate)
+        );
+        proposal.nominateNewOwner(msg.sender);
+        return proposal;
+    }
 }
 
 contract Proposal is IProposal, Ownable {
@@ -158,8 +185,6 @@ contract SwapProposal is Proposal {
     uint256[] public amounts; // unit: qToken
     bool[] public toVault;
 
-    uint256 constant WEIGHT_SCALE = uint256(10)**18; // unit: aqToken / qToken
-
     constructor(address _proposer,
                 address[] memory _tokens,
                 uint256[] memory _amounts, // unit: qToken
@@ -180,7 +205,7 @@ contract SwapProposal is Proposal {
         uint256[] memory weights = new uint256[](tokens.length);
         // unit: aqToken/RSV
 
-        uint256 scaleFactor = WEIGHT_SCALE.mul(uint256(10)**(trustedRSV.decimals()));
+        uint256 scaleFactor = WeightMath.scale(trustedRSV.decimals());
         // unit: aqToken/qToken * qRSV/RSV
 
         uint256 rsvSupply = trustedRSV.totalSupply();
@@ -198,10 +223,12 @@ contract SwapProposal is Proposal {
                 // this mechanism to overspend the proposer's tokens by 1 qToken. We avoid that,
                 // here, by making the effective proposal one less. Yeah, it's pretty fiddly.
                 
-                weights[i] = oldWeight.add( (amounts[i].sub(1)).mul(scaleFactor).div(rsvSupply) );
+                weights[i] = oldWeight.add(
+                    WeightMath.mulDiv(amounts[i].sub(1), scaleFactor, rsvSupply)
+                );
                 //unit: aqToken/RSV == aqToken/RSV == [qToken] * [aqToken/qToken*qRSV/RSV] / [qRSV]
             } else {
-                weights[i] = oldWeight.sub( amounts[i].mul(scaleFactor).div(rsvSupply) );
+                weights[i] = oldWeight.sub(WeightMath.mulDiv(amounts[i], scaleFactor, rsvSupply));
                 //unit: aqToken/RSV
             }
         }
@@ -211,4 +238,68 @@ contract SwapProposal is Proposal {
     }
 }
 
+/**
+ * A RebalanceProposal represents a suggestion to exchange a portion of one token in the basket,
+ * `fromToken`, for another, `toToken`, at a fixed rate. Unlike a WeightProposal, it leaves the
+ * weights of every other token as they are when it is executed, so that it doesn't undo another
+ * proposal executed in the meantime; unlike a SwapProposal, it is a change to the backing of a
+ * single RSV, and so doesn't depend on the RSV supply.
+ *
+ * When this proposal is completed, it takes `portion` of the weight of `fromToken` in the old
+ * basket, and adds it times `rate` to the weight of `toToken`, which need not be in the old
+ * basket. A portion of 100% leaves `fromToken` in the basket with a weight of zero; to remove it
+ * entirely, use a WeightProposal.
+ */
 
+// On "unit" comments, see comment at top of Manager.sol.
+contract RebalanceProposal is Proposal {
+    address public fromToken;
+    address public toToken;
+    uint256 public portion; // unit: BPS
+    uint256 public rate; // unit: aqToToken/aqFromToken, scaled by RATE_SCALE
+
+    uint256 constant BPS_FACTOR = 10000; // unit: BPS
+    uint256 constant RATE_SCALE = uint256(10)**18;
+
+    constructor(
+        address _proposer,
+        address _fromToken,
+        address _toToken,
+        uint256 _portion, // unit: BPS
+        uint256 _rate
+    )
+        Proposal(_proposer) public
+    {
+        require(_fromToken != _toToken, "tokens must differ");
+        require(_portion > 0 && _portion <= BPS_FACTOR, "portion must be in (0, 100%]");
+        fromToken = _fromToken;
+        toToken = _toToken;
+        portion = _portion;
+        rate = _rate;
+    }
+
+    /// Return the newly-proposed basket, based on the old basket.
+    function _newBasket(IRSV, Basket trustedOldBasket) internal returns(Basket) {
+        require(trustedOldBasket.has(fromToken), "token not in basket");
+
+        uint256 fromWeight = trustedOldBasket.weights(fromToken);
+        // unit: aqFromToken/RSV
+
+        // Round what leaves down and what enters up, so that the proposal never asks for less
+        // of `toToken` than `rate` says.
+        uint256 moved = fromWeight.mul(portion).div(BPS_FACTOR);
+        // unit: aqFromToken/RSV == aqFromToken/RSV * BPS / BPS
+        uint256 added = WeightMath.mulDivUp(moved, rate, RATE_SCALE);
+        // unit: aqToToken/RSV == aqFromToken/RSV * aqToToken/aqFromToken
+
+        address[] memory tokens = new address[](2);
+        uint256[] memory weights = new uint256[](2);
+        tokens[0] = fromToken;
+        weights[0] = fromWeight.sub(moved);
+        tokens[1] = toToken;
+        weights[1] = trustedOldBasket.weights(toToken).add(added);
+
+        return new Basket(trustedOldBasket, tokens, weights);
+        // unit check for weights: aqToken/RSV
+    }
+}
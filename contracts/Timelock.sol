@@ -0,0 +1,175 @@
+pragma solidity 0.5.7;
+
+import "./zeppelin/math/SafeMath.sol";
+
+/**
+ * The Timelock makes the critical administrative actions of a deployment wait out a delay
+ * between being queued and being executed, so that RSV holders see them coming and the admin
+ * can cancel one queued by mistake or with a stolen key. It is Compound's Timelock, ported to
+ * Solidity 0.5.7, which `rsvadmin timelock` drives.
+ *
+ * To put an action behind the Timelock, make it the holder of the role that takes the action:
+ *
+ * - the Reserve's admin (its owner), for minter changes and implementation swaps, which begin
+ *   with the old Reserve's `nominateNewOwner` of the new one;
+ * - the Manager's only operator, for basket changes, which it accepts and executes. This delays
+ *   the operator's emergency switches, `setEmergency` and `setIssuancePaused`, too;
+ * - the Manager's and the Vault's owner, for swapping the Vault or the Manager.
+ *
+ * A transaction is identified by the hash of its target, value, signature, data, and `eta`, the
+ * time from which it may be executed. It must be executed within `GRACE_PERIOD` of its eta, or
+ * queued again.
+ *
+ * The Timelock can also let a `keeper`, such as the Upkeep that a keeper network runs, execute
+ * queued transactions once they are due, so that timelocked actions don't wait on the admin's
+ * signers a second time. Only the admin can queue and cancel transactions.
+ */
+contract Timelock {
+    using SafeMath for uint256;
+
+    uint256 public constant GRACE_PERIOD = 14 days;
+    uint256 public constant MINIMUM_DELAY = 2 days;
+    uint256 public constant MAXIMUM_DELAY = 30 days;
+
+    address public admin;
+    address public pendingAdmin;
+    uint256 public delay;
+
+    // If set, can execute queued transactions as the admin can.
+    address public keeper;
+
+    mapping (bytes32 => bool) public queuedTransactions;
+
+    event NewAdmin(address indexed newAdmin);
+    event NewPendingAdmin(address indexed newPendingAdmin);
+    event NewDelay(uint256 indexed newDelay);
+    event NewKeeper(address indexed newKeeper);
+    event CancelTransaction(
+        bytes32 indexed txHash, address indexed target, uint256 value, string signature, bytes data, uint256 eta
+    );
+    event ExecuteTransaction(
+        bytes32 indexed txHash, address indexed target, uint256 value, string signature, bytes data, uint256 eta
+    );
+    event QueueTransaction(
+        bytes32 indexed txHash, address indexed target, uint256 value, string signature, bytes data, uint256 eta
+    );
+
+    constructor(address _admin, uint256 _delay) public {
+        require(_delay >= MINIMUM_DELAY, "delay must exceed minimum delay");
+        require(_delay <= MAXIMUM_DELAY, "delay must not exceed maximum delay");
+
+        admin = _admin;
+        delay = _delay;
+        emit NewAdmin(_admin);
+        emit NewDelay(_delay);
+    }
+
+    function() external payable { }
+
+    /// Modifies a function to run only when called by the Timelock itself, that is, through a
+    /// queued transaction.
+    modifier onlyTimelock() {
+        require(msg.sender == address(this), "call must come from the timelock");
+        _;
+    }
+
+    /// Modifies a function to run only when called by the admin.
+    modifier onlyAdmin() {
+        require(msg.sender == admin, "call must come from admin");
+        _;
+    }
+
+    /// Modifies a function to run only when called by the admin or the keeper.
+    modifier onlyAdminOrKeeper() {
+        require(msg.sender == admin || msg.sender == keeper, "call must come from admin or keeper");
+        _;
+    }
+
+    /// Changes the delay, through a queued transaction.
+    function setDelay(uint256 _delay) external onlyTimelock {
+        require(_delay >= MINIMUM_DELAY, "delay must exceed minimum delay");
+        require(_delay <= MAXIMUM_DELAY, "delay must not exceed maximum delay");
+        delay = _delay;
+        emit NewDelay(_delay);
+    }
+
+    /// Sets the keeper, or unsets it with the zero address, through a queued transaction.
+    function setKeeper(address _keeper) external onlyTimelock {
+        keeper = _keeper;
+        emit NewKeeper(_keeper);
+    }
+
+    /// Nominates the next admin, through a queued transaction.
+    function setPendingAdmin(address _pendingAdmin) external onlyTimelock {
+        pendingAdmin = _pendingAdmin;
+        emit NewPendingAdmin(_pendingAdmin);
+    }
+
+    /// Makes the nominated admin the admin. Only the nominee can call this.
+    function acceptAdmin() external {
+        require(msg.sender == pendingAdmin, "call must come from pendingAdmin");
+        admin = msg.sender;
+        pendingAdmin = address(0);
+        emit NewAdmin(admin);
+    }
+
+    /// Queues a transaction, to be executed from `eta`, which must be at least `delay` from now.
+    function queueTransaction(
+        address target,
+        uint256 value,
+        string calldata signature,
+        bytes calldata data,
+        uint256 eta
+    ) external onlyAdmin returns (bytes32) {
+        require(eta >= now.add(delay), "estimated execution block must satisfy delay");
+
+        bytes32 txHash = keccak256(abi.encode(target, value, signature, data, eta));
+        queuedTransactions[txHash] = true;
+        emit QueueTransaction(txHash, target, value, signature, data, eta);
+        return txHash;
+    }
+
+    /// Cancels a queued transaction.
+    function cancelTransaction(
+        address target,
+        uint256 value,
+        string calldata signature,
+        bytes calldata data,
+        uint256 eta
+    ) external onlyAdmin {
+        bytes32 txHash = keccak256(abi.encode(target, value, signature, data, eta));
+        queuedTransactions[txHash] = false;
+        emit CancelTransaction(txHash, target, value, signature, data, eta);
+    }
+
+    /// Executes a queued transaction whose eta has passed, less than `GRACE_PERIOD` ago. If the
+    /// call fails, so does this, and the transaction stays queued.
+    function executeTransaction(
+        address target,
+        uint256 value,
+        string calldata signature,
+        bytes calldata data,
+        uint256 eta
+    ) external payable onlyAdminOrKeeper returns (bytes memory) {
+        bytes32 txHash = keccak256(abi.encode(target, value, signature, data, eta));
+        require(queuedTransactions[txHash], "transaction hasn't been queued");
+        require(now >= eta, "transaction hasn't surpassed time lock");
+        require(now <= eta.add(GRACE_PERIOD), "transaction is stale");
+
+        queuedTransactions[txHash] = false;
+
+        bytes memory callData;
+        if (bytes(signature).length == 0) {
+            callData = data;
+        } else {
+            callData = abi.encodePacked(bytes4(keccak256(bytes(signature))), data);
+        }
+
+        // solium-disable-next-line security/no-call-value
+        (bool success, bytes memory returnData) = target.call.value(value)(callData);
+        require(success, "transaction execution reverted");
+
+        emit ExecuteTransaction(txHash, target, value, signature, data, eta);
+        return returnData;
+    }
+}
@@ -0,0 +1,181 @@
+pragma solidity 0.5.7;
+
+import "./zeppelin/math/SafeMath.sol";
+
+/// The part of the Timelock that the Upkeep uses.
+interface IUpkeepTimelock {
+    function admin() external view returns (address);
+    function queuedTransactions(bytes32 txHash) external view returns (bool);
+    function GRACE_PERIOD() external view returns (uint256);
+    function executeTransaction(
+        address target,
+        uint256 value,
+        string calldata signature,
+        bytes calldata data,
+        uint256 eta
+    ) external payable returns (bytes memory);
+}
+
+/// The part of the Manager that the Upkeep uses.
+interface IUpkeepManager {
+    function proposalsLength() external view returns (uint256);
+    function firstLiveProposal() external view returns (uint256);
+    function proposalDeadlines(uint256 id) external view returns (uint256);
+    function trustedProposals(uint256 id) external view returns (address);
+    function expireProposal(uint256 id) external;
+}
+
+/// The part of a Proposal that the Upkeep uses.
+interface IUpkeepProposal {
+    function state() external view returns (uint8);
+}
+
+/**
+ * The Upkeep lets a keeper network, such as Chainlink Automation, run the scheduled operations
+ * of a deployment: it executes the Timelock's queued transactions once they are due, and expires
+ * the Manager's proposals once their deadlines have passed.
+ *
+ * Keepers call `checkUpkeep` off chain, and, when it finds work, send its `performData` to
+ * `performUpkeep`, one task at a time. `performUpkeep` checks the task again, so anyone may
+ * call it; at worst, a task is done sooner than a keeper would have done it.
+ *
+ * The Timelock only keeps the hashes of its transactions, so its admin must `schedule` each
+ * transaction here too, once queued, and the Upkeep must be the Timelock's `keeper`. A
+ * transaction that is executed, cancelled, or stale is dropped from the schedule. Should a due
+ * transaction's call fail, so does its upkeep, and the Upkeep keeps finding it first; the admin
+ * should then cancel it on the Timelock.
+ */
+contract Upkeep {
+    using SafeMath for uint256;
+
+    struct Operation {
+        address target;
+        uint256 value;
+        string signature;
+        bytes data;
+        uint256 eta; // unit: seconds
+    }
+
+    IUpkeepTimelock public timelock;
+    IUpkeepManager public manager;
+
+    // The Timelock's transactions to execute once due, in no particular order.
+    Operation[] public operations;
+
+    // The tasks of `performUpkeep`.
+    uint8 constant EXECUTE = 0;
+    uint8 constant DROP = 1;
+    uint8 constant EXPIRE = 2;
+
+    // Proposal.State.Created and Proposal.State.Accepted.
+    uint8 constant CREATED = 0;
+    uint8 constant ACCEPTED = 1;
+
+    event OperationScheduled(bytes32 indexed txHash, uint256 eta);
+    event OperationDropped(bytes32 indexed txHash);
+
+    constructor(address timelockAddr, address managerAddr) public {
+        require(timelockAddr != address(0), "cannot be 0 address");
+        require(managerAddr != address(0), "cannot be 0 address");
+        timelock = IUpkeepTimelock(timelockAddr);
+        manager = IUpkeepManager(managerAddr);
+    }
+
+    /// @return how many operations are scheduled.
+    function operationsLength() external view returns (uint256) {
+        return operations.length;
+    }
+
+    /// Schedule the Timelock's queued transaction, to be executed once due. Only the Timelock's
+    /// admin can schedule.
+    function schedule(
+        address target,
+        uint256 value,
+        string calldata signature,
+        bytes calldata data,
+        uint256 eta
+    ) external {
+        require(msg.sender == timelock.admin(), "must be timelock admin");
+        bytes32 txHash = keccak256(abi.encode(target, value, signature, data, eta));
+        require(timelock.queuedTransactions(txHash), "transaction hasn't been queued");
+
+        operations.push(Operation(target, value, signature, data, eta));
+        emit OperationScheduled(txHash, eta);
+    }
+
+    /// Find the next task for a keeper: a due operation to execute, an operation to drop, or a
+    /// proposal to expire. `checkData` is unused.
+    /// @return whether there is a task, and the `performData` to do it.
+    function checkUpkeep(bytes calldata)
+        external
+        view
+        returns (bool upkeepNeeded, bytes memory performData)
+    {
+        for (uint256 i = 0; i < operations.length; i++) {
+            Operation memory op = operations[i];
+            bytes32 txHash = _hash(op);
+            if (!_live(op, txHash)) {
+                return (true, abi.encode(DROP, i, txHash));
+            }
+            if (now >= op.eta) {
+                return (true, abi.encode(EXECUTE, i, txHash));
+            }
+        }
+        uint256 proposalsLength = manager.proposalsLength();
+        for (uint256 id = manager.firstLiveProposal(); id < proposalsLength; id++) {
+            if (now > manager.proposalDeadlines(id) && _pending(id)) {
+                return (true, abi.encode(EXPIRE, id, bytes32(0)));
+            }
+        }
+        return (false, "");
+    }
+
+    /// Do the task that `checkUpkeep` found.
+    function performUpkeep(bytes calldata performData) external {
+        (uint8 task, uint256 index, bytes32 txHash) =
+            abi.decode(performData, (uint8, uint256, bytes32));
+        if (task == EXPIRE) {
+            manager.expireProposal(index);
+            return;
+        }
+
+        require(task == EXECUTE || task == DROP, "unknown task");
+        require(index < operations.length, "no such operation");
+        Operation memory op = operations[index];
+        require(_hash(op) == txHash, "operation moved");
+        _remove(index);
+
+        if (task == DROP) {
+            require(!_live(op, txHash), "operation still queued");
+            emit OperationDropped(txHash);
+        } else {
+            timelock.executeTransaction(op.target, op.value, op.signature, op.data, op.eta);
+        }
+    }
+
+    /// @dev The Timelock's hash of `op`.
+    function _hash(Operation memory op) internal pure returns (bytes32) {
+        return keccak256(abi.encode(op.target, op.value, op.signature, op.data, op.eta));
+    }
+
+    /// @dev Whether `op`, whose hash is `txHash`, is still queued and not yet stale.
+    function _live(Operation memory op, bytes32 txHash) internal view returns (bool) {
+        return timelock.queuedTransactions(txHash) &&
+            now <= op.eta.add(timelock.GRACE_PERIOD());
+    }
+
+    /// @dev Whether the Manager's proposal `id` is created or accepted.
+    function _pending(uint256 id) internal view returns (bool) {
+        uint8 state = IUpkeepProposal(manager.trustedProposals(id)).state();
+        return state == CREATED || state == ACCEPTED;
+    }
+
+    /// @dev Remove operation `index`, moving the last operation into its place.
+    function _remove(uint256 index) internal {
+        uint256 last = operations.length - 1;
+        if (index != last) {
+            operations[index] = operations[last];
+        }
+        operations.length--;
+    }
+}
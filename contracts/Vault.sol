@@ -15,6 +15,10 @@ contract Vault is Ownable {
 
     address public manager;
 
+    // ERC-165 interface IDs: ERC-165 itself, and the Vault's `withdrawTo`, which the Manager uses
+    bytes4 internal constant ERC165_INTERFACE_ID = 0x01ffc9a7;
+    bytes4 internal constant VAULT_INTERFACE_ID = 0xc4e2c1e6;
+
     event ManagerTransferred(
         address indexed previousManager,
         address indexed newManager
@@ -50,4 +54,10 @@ contract Vault is Ownable {
         IERC20(token).safeTransfer(to, amount);
         emit Withdrawal(token, amount, to);
     }
+
+    /// @return whether the Vault implements the interface `interfaceId`, per
+    /// [ERC-165](https://eips.ethereum.org/EIPS/eip-165): ERC-165 itself, and `withdrawTo`.
+    function supportsInterface(bytes4 interfaceId) external pure returns (bool) {
+        return interfaceId == ERC165_INTERFACE_ID || interfaceId == VAULT_INTERFACE_ID;
+    }
 }
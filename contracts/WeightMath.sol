@@ -0,0 +1,103 @@
+pragma solidity 0.5.7;
+
+import "./zeppelin/math/SafeMath.sol";
+
+/**
+ * WeightMath converts between amounts of RSV and amounts of basket tokens, for tokens of any
+ * number of decimals. Basket weights are in aqToken/RSV, so a weight carries its token's
+ * decimals with it, and the conversions only ever divide by `scale`, the same for every token.
+ *
+ * A weight for a token of many decimals is a large number: a 36-decimal token at one Token/RSV
+ * weighs 10**54 aqToken/RSV, and a billion RSV of it, 10**27 qRSV, multiplies out to 10**81,
+ * past what a uint256 holds. So the products here are computed to 512 bits, and only the
+ * quotient has to fit; a conversion whose result doesn't fit reverts.
+ *
+ * @dev For notes on units, see the header comment for Manager.sol.
+ */
+library WeightMath {
+    using SafeMath for uint256;
+
+    uint256 constant WEIGHT_SCALE = 10**18; // unit: aqToken/qToken
+
+    /// The factor that converts qRSV * aqToken/RSV to qToken, for an RSV of `rsvDecimals`.
+    /// return unit: aqToken/qToken * qRSV/RSV
+    function scale(uint8 rsvDecimals) internal pure returns (uint256) {
+        return scale(rsvDecimals, WEIGHT_SCALE);
+    }
+
+    /// The factor that converts qRSV * aqUnderlying/RSV to qToken, for an RSV of `rsvDecimals`
+    /// and a token worth `rate` aqUnderlying each, as an interest-bearing wrapper is. A token
+    /// that is its own underlying has a rate of WEIGHT_SCALE.
+    /// return unit: aqUnderlying/qToken * qRSV/RSV
+    function scale(uint8 rsvDecimals, uint256 rate) internal pure returns (uint256) {
+        return rate.mul(uint256(10)**uint256(rsvDecimals));
+    }
+
+    /// Returns a * b / denominator, rounded down, without overflow in the product.
+    /// Reverts if the denominator is zero or the result doesn't fit in a uint256.
+    ///
+    /// This is Remco Bloemen's full-precision mulDiv, as in Uniswap v3's FullMath (MIT license).
+    function mulDiv(uint256 a, uint256 b, uint256 denominator)
+        internal
+        pure
+        returns (uint256 result)
+    {
+        // The 512-bit product is prod1 * 2**256 + prod0.
+        uint256 prod0;
+        uint256 prod1;
+        // solium-disable-next-line security/no-inline-assembly
+        assembly {
+            let mm := mulmod(a, b, not(0))
+            prod0 := mul(a, b)
+            prod1 := sub(sub(mm, prod0), lt(mm, prod0))
+        }
+
+        if (prod1 == 0) {
+            require(denominator > 0, "division by zero");
+            return prod0 / denominator;
+        }
+        require(denominator > prod1, "multiplication overflow");
+
+        // Subtract the remainder, so that the division is exact.
+        uint256 remainder = mulmod(a, b, denominator);
+        // solium-disable-next-line security/no-inline-assembly
+        assembly {
+            prod1 := sub(prod1, gt(remainder, prod0))
+            prod0 := sub(prod0, remainder)
+        }
+
+        // Divide out the largest power of two dividing the denominator, which leaves it odd.
+        uint256 twos = (~denominator + 1) & denominator;
+        // solium-disable-next-line security/no-inline-assembly
+        assembly {
+            denominator := div(denominator, twos)
+            prod0 := div(prod0, twos)
+            // twos becomes 2**256 / twos.
+            twos := add(div(sub(0, twos), twos), 1)
+        }
+        prod0 |= prod1 * twos;
+
+        // The denominator is now odd, so has an inverse mod 2**256. Newton-Raphson doubles
+        // the correct bits of the inverse each step, from 4 bits to 256.
+        uint256 inv = (3 * denominator) ^ 2;
+        inv *= 2 - denominator * inv; // 8 bits
+        inv *= 2 - denominator * inv; // 16 bits
+        inv *= 2 - denominator * inv; // 32 bits
+        inv *= 2 - denominator * inv; // 64 bits
+        inv *= 2 - denominator * inv; // 128 bits
+        inv *= 2 - denominator * inv; // 256 bits
+
+        // The division is exact, so multiplying by the inverse gives the quotient.
+        return prod0 * inv;
+    }
+
+    /// Returns a * b / denominator, rounded up, without overflow in the product.
+    function mulDivUp(uint256 a, uint256 b, uint256 denominator) internal pure returns (uint256) {
+        uint256 result = mulDiv(a, b, denominator);
+        if (mulmod(a, b, denominator) > 0) {
+            require(result < 2 ** 256 - 1, "multiplication overflow");
+            result++;
+        }
+        return result;
+    }
+}
@@ -0,0 +1,83 @@
+pragma solidity 0.5.7;
+
+import "./zeppelin/token/ERC20/SafeERC20.sol";
+import "./zeppelin/token/ERC20/IERC20.sol";
+import "./zeppelin/math/SafeMath.sol";
+import "./Vault.sol";
+
+/// The part of an ERC-4626 tokenized vault that the YieldVault uses.
+interface IYieldSource {
+    function asset() external view returns (address);
+    function deposit(uint256 assets, address receiver) external returns (uint256 shares);
+    function redeem(uint256 shares, address receiver, address owner)
+        external
+        returns (uint256 assets);
+    function convertToAssets(uint256 shares) external view returns (uint256 assets);
+}
+
+/// The part of the Manager that the YieldVault reads.
+interface ICollateralized {
+    function isFullyCollateralized() external view returns (bool);
+}
+
+/**
+ * The YieldVault is a Vault that can also put collateral to work in yield sources: ERC-4626
+ * vaults, such as sDAI, that the owner has whitelisted. The owner deposits and withdraws; the
+ * YieldVault tracks the shares it holds in each source.
+ *
+ * The Manager reckons backing by what the Vault holds, and pays redemptions from it, so every
+ * deposit must leave the Manager fully collateralized by what stays behind: only the surplus
+ * over what the supply needs, such as the seigniorage, can be put to work. Yield is realized,
+ * and becomes backing, when shares are withdrawn. If a source loses value, only the surplus
+ * put in it is lost. Before handing off to another Vault, or to let emergency redemption pay
+ * out the surplus too, the owner should withdraw the shares.
+ */
+contract YieldVault is Vault {
+    using SafeMath for uint256;
+    using SafeERC20 for IERC20;
+
+    // The yield sources that the owner may deposit into.
+    mapping(address => bool) public yieldSources;
+
+    // The shares held in each yield source.
+    mapping(address => uint256) public sharesHeld;
+
+    event YieldSourceChanged(address indexed source, bool indexed approved);
+    event YieldDeposited(address indexed source, uint256 assets, uint256 shares);
+    event YieldWithdrawn(address indexed source, uint256 assets, uint256 shares);
+
+    /// Approve, or stop approving, deposits into `source`. Withdrawals from it stay possible.
+    function setYieldSource(address source, bool approved) external onlyOwner {
+        yieldSources[source] = approved;
+        emit YieldSourceChanged(source, approved);
+    }
+
+    /// Deposit `assets` of the source's asset into `source`. The Manager must still be fully
+    /// collateralized afterwards.
+    function deposit(address source, uint256 assets) external onlyOwner {
+        require(yieldSources[source], "yield source not approved");
+        IERC20 token = IERC20(IYieldSource(source).asset());
+
+        token.safeApprove(source, assets);
+        uint256 received = IYieldSource(source).deposit(assets, address(this));
+        sharesHeld[source] = sharesHeld[source].add(received);
+
+        require(
+            ICollateralized(manager).isFullyCollateralized(),
+            "deposit would leave the supply undercollateralized"
+        );
+        emit YieldDeposited(source, assets, received);
+    }
+
+    /// Withdraw `amount` of the shares held in `source`, for the source's asset.
+    function withdraw(address source, uint256 amount) external onlyOwner {
+        sharesHeld[source] = sharesHeld[source].sub(amount);
+        uint256 assets = IYieldSource(source).redeem(amount, address(this), address(this));
+        emit YieldWithdrawn(source, assets, amount);
+    }
+
+    /// Returns what the shares held in `source` are worth in its asset, as the source reckons.
+    function assetsOf(address source) external view returns (uint256) {
+        return IYieldSource(source).convertToAssets(sharesHeld[source]);
+    }
+}
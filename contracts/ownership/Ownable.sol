@@ -10,22 +10,36 @@ import "../zeppelin/GSN/Context.sol";
  * To change ownership, use a 2-part nominate-accept pattern.
  *
  * This contract is loosely based off of https://git.io/JenNF but additionally requires new owners
- * to accept ownership before the transition occurs.
+ * to accept ownership before the transition occurs. A nomination not accepted within
+ * `nominationPeriod` expires, so that an address nominated by mistake can't take ownership long
+ * after everyone has forgotten about it.
  */
 contract Ownable is Context {
     address private _owner;
     address private _nominatedOwner;
+    uint256 private _nominationDeadline;
+    uint256 private _nominationPeriod;
 
     event NewOwnerNominated(address indexed previousOwner, address indexed nominee);
     event OwnershipTransferred(address indexed previousOwner, address indexed newOwner);
+    event NominationExpired(address indexed nominee);
+    event NominationPeriodChanged(uint256 newPeriod);
 
     /**
      * @dev Initializes the contract setting the deployer as the initial owner.
      */
     constructor () internal {
-        address msgSender = _msgSender();
-        _owner = msgSender;
-        emit OwnershipTransferred(address(0), msgSender);
+        _initializeOwner(_msgSender());
+    }
+
+    /**
+     * @dev Sets the initial owner and nomination period. A contract used behind a proxy, whose
+     * constructor doesn't run in the proxy's storage, calls this from its initializer instead.
+     */
+    function _initializeOwner(address initialOwner) internal {
+        _owner = initialOwner;
+        _nominationPeriod = 30 days;
+        emit OwnershipTransferred(address(0), initialOwner);
     }
 
     /**
@@ -36,12 +50,30 @@ contract Ownable is Context {
     }
 
     /**
-     * @dev Returns the address of the current nominated owner.
+     * @dev Returns the address of the current nominated owner, or the zero address if the
+     * nomination has expired.
      */
     function nominatedOwner() external view returns (address) {
+        if (_nominationExpired()) {
+            return address(0);
+        }
         return _nominatedOwner;
     }
 
+    /**
+     * @dev Returns the last time at which the current nomination can be accepted.
+     */
+    function nominationDeadline() external view returns (uint256) {
+        return _nominationDeadline;
+    }
+
+    /**
+     * @dev Returns how long a nomination stands before it expires.
+     */
+    function nominationPeriod() external view returns (uint256) {
+        return _nominationPeriod;
+    }
+
     /**
      * @dev Throws if called by any account other than the owner.
      */
@@ -63,6 +95,34 @@ contract Ownable is Context {
         require(newOwner != address(0), "new owner is 0 address");
         emit NewOwnerNominated(_owner, newOwner);
         _nominatedOwner = newOwner;
+        _nominationDeadline = now + _nominationPeriod;
+    }
+
+    /**
+     * @dev Changes how long later nominations stand before they expire.
+     * Can only be called by the current owner.
+     */
+    function changeNominationPeriod(uint256 newPeriod) external onlyOwner {
+        require(newPeriod > 0 && newPeriod <= 365 days, "nomination period out of range");
+        _nominationPeriod = newPeriod;
+        emit NominationPeriodChanged(newPeriod);
+    }
+
+    /**
+     * @dev Clears an expired nomination, recording its expiry. Anyone may call it.
+     */
+    function expireNomination() external {
+        require(_nominationExpired(), "no expired nomination");
+        emit NominationExpired(_nominatedOwner);
+        _nominatedOwner = address(0);
+        _nominationDeadline = 0;
+    }
+
+    /**
+     * @dev Whether there is a nomination, not yet accepted, whose deadline has passed.
+     */
+    function _nominationExpired() internal view returns (bool) {
+        return _nominatedOwner != address(0) && _nominatedOwner != _owner && now > _nominationDeadline;
     }
 
     /**
@@ -70,6 +130,7 @@ contract Ownable is Context {
      */
     function acceptOwnership() external {
         require(_nominatedOwner == _msgSender(), "unauthorized");
+        require(!_nominationExpired(), "nomination expired");
         emit OwnershipTransferred(_owner, _nominatedOwner);
         _owner = _nominatedOwner;
     }
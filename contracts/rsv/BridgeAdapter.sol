@@ -0,0 +1,143 @@
+pragma solidity 0.5.7;
+
+import "../zeppelin/math/SafeMath.sol";
+import "../ownership/Ownable.sol";
+import "./IRSV.sol";
+
+/**
+ * @title The Bridge Adapter
+ * @dev Lets a canonical bridge mint and burn RSV on a chain that RSV only reaches over that
+ * bridge, such as an L2. The adapter is the Reserve's minter there, in place of a Manager, and
+ * the `bridge` calls `mint` for each deposit of RSV locked on L1, and `burn` for each
+ * withdrawal back to it.
+ *
+ * What the bridge may mint, and burn, in each LIMIT_WINDOW is limited, so that a compromised
+ * bridge can only do so much before the owner changes or removes it. Both limits start at zero:
+ * nothing moves until the owner sets them. A deposit can be minted only once.
+ *
+ * The Reserve only burns out of an allowance to its minter, so a holder approves the adapter
+ * for what it withdraws, as for a Manager redemption.
+ */
+contract BridgeAdapter is Ownable {
+    using SafeMath for uint256;
+
+    IRSV public trustedRSV;
+
+    // The bridge operator: the bridge contract, or its relayer, that mints and burns.
+    address public bridge;
+
+    // The RSV contract on the other side of the bridge.
+    address public remoteToken;
+
+    // Limits: at most `mintLimit` attotokens can be minted, and `burnLimit` burned, in each
+    // LIMIT_WINDOW, a window that starts at the first mint (or burn) after the previous one ends.
+    uint256 public mintLimit;
+    uint256 public mintWindowStart;
+    uint256 public mintedInWindow;
+    uint256 public burnLimit;
+    uint256 public burnWindowStart;
+    uint256 public burnedInWindow;
+
+    // The deposits that have been minted, by the ID the bridge gives them.
+    mapping(bytes32 => bool) public minted;
+
+    uint256 public constant LIMIT_WINDOW = 1 days;
+
+    event BridgeChanged(address indexed oldBridge, address indexed newBridge);
+    event MintLimitChanged(uint256 oldVal, uint256 newVal);
+    event BurnLimitChanged(uint256 oldVal, uint256 newVal);
+    event BridgeMinted(bytes32 indexed depositId, address indexed to, uint256 amount);
+    event BridgeBurned(address indexed from, uint256 amount);
+
+    constructor(address rsvAddress, address remoteTokenAddress, address bridgeAddress) public {
+        trustedRSV = IRSV(rsvAddress);
+        remoteToken = remoteTokenAddress;
+        bridge = bridgeAddress;
+        emit BridgeChanged(address(0), bridgeAddress);
+    }
+
+    /// Modifies a function to run only when called by `bridge`.
+    modifier onlyBridge() {
+        require(_msgSender() == bridge, "must be bridge");
+        _;
+    }
+
+    /// Change the bridge operator. The zero address stops all bridging.
+    function changeBridge(address newBridge) external onlyOwner {
+        emit BridgeChanged(bridge, newBridge);
+        bridge = newBridge;
+    }
+
+    /// Change the most attotokens that the bridge may mint in each LIMIT_WINDOW.
+    function changeMintLimit(uint256 newMintLimit) external onlyOwner {
+        emit MintLimitChanged(mintLimit, newMintLimit);
+        mintLimit = newMintLimit;
+    }
+
+    /// Change the most attotokens that the bridge may burn in each LIMIT_WINDOW.
+    function changeBurnLimit(uint256 newBurnLimit) external onlyOwner {
+        emit BurnLimitChanged(burnLimit, newBurnLimit);
+        burnLimit = newBurnLimit;
+    }
+
+    /// @return how many more attotokens the bridge may mint in the current window.
+    function mintableInWindow() external view returns (uint256) {
+        return _leftInWindow(mintLimit, mintWindowStart, mintedInWindow);
+    }
+
+    /// @return how many more attotokens the bridge may burn in the current window.
+    function burnableInWindow() external view returns (uint256) {
+        return _leftInWindow(burnLimit, burnWindowStart, burnedInWindow);
+    }
+
+    /// Mint `amount` attotokens to `to`, for the deposit `depositId` on the other side of the
+    /// bridge. Each deposit is minted at most once.
+    function mint(bytes32 depositId, address to, uint256 amount) external onlyBridge {
+        require(!minted[depositId], "deposit already minted");
+        minted[depositId] = true;
+
+        (mintWindowStart, mintedInWindow) = _spend(mintWindowStart, mintedInWindow, amount);
+        require(mintedInWindow <= mintLimit, "mint limit exceeded");
+
+        trustedRSV.mint(to, amount);
+        emit BridgeMinted(depositId, to, amount);
+    }
+
+    /// Burn `amount` attotokens from `from`, which has approved the adapter for them, for a
+    /// withdrawal to the other side of the bridge.
+    function burn(address from, uint256 amount) external onlyBridge {
+        (burnWindowStart, burnedInWindow) = _spend(burnWindowStart, burnedInWindow, amount);
+        require(burnedInWindow <= burnLimit, "burn limit exceeded");
+
+        trustedRSV.burnFrom(from, amount);
+        emit BridgeBurned(from, amount);
+    }
+
+    /// @dev A window's start and use once `amount` more is used in it, opening a new window
+    /// if the one that started at `windowStart` has ended.
+    function _spend(uint256 windowStart, uint256 used, uint256 amount)
+        internal
+        view
+        returns (uint256, uint256)
+    {
+        if (now >= windowStart.add(LIMIT_WINDOW)) {
+            return (now, amount);
+        }
+        return (windowStart, used.add(amount));
+    }
+
+    /// @dev What is left of `limit` in the window that started at `windowStart`.
+    function _leftInWindow(uint256 limit, uint256 windowStart, uint256 used)
+        internal
+        view
+        returns (uint256)
+    {
+        if (now >= windowStart.add(LIMIT_WINDOW)) {
+            return limit;
+        }
+        if (used >= limit) {
+            return 0;
+        }
+        return limit - used;
+    }
+}
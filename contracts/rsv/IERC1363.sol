@@ -0,0 +1,18 @@
+pragma solidity 0.5.7;
+
+/// The hook that `transferAndCall` and `transferFromAndCall` call on their recipient, per
+/// [ERC-1363](https://eips.ethereum.org/EIPS/eip-1363). It must return its own selector,
+/// `onTransferReceived.selector`, to accept the transfer, and revert to refuse it.
+interface IERC1363Receiver {
+    function onTransferReceived(address operator, address from, uint256 value, bytes calldata data)
+        external
+        returns (bytes4);
+}
+
+/// The hook that `approveAndCall` calls on its spender, per ERC-1363. It must return its own
+/// selector, `onApprovalReceived.selector`, to accept the approval, and revert to refuse it.
+interface IERC1363Spender {
+    function onApprovalReceived(address owner, uint256 value, bytes calldata data)
+        external
+        returns (bytes4);
+}
@@ -0,0 +1,31 @@
+pragma solidity 0.5.7;
+
+/// The hook that `flashLoan` calls on its receiver, per
+/// [ERC-3156](https://eips.ethereum.org/EIPS/eip-3156). It must return
+/// keccak256("ERC3156FlashBorrower.onFlashLoan") to accept the loan, and must leave the lender
+/// an allowance of `amount + fee` to take back.
+interface IERC3156FlashBorrower {
+    function onFlashLoan(
+        address initiator,
+        address token,
+        uint256 amount,
+        uint256 fee,
+        bytes calldata data
+    )
+        external
+        returns (bytes32);
+}
+
+/// An ERC-3156 flash lender.
+interface IERC3156FlashLender {
+    function maxFlashLoan(address token) external view returns (uint256);
+    function flashFee(address token, uint256 amount) external view returns (uint256);
+    function flashLoan(
+        IERC3156FlashBorrower receiver,
+        address token,
+        uint256 amount,
+        bytes calldata data
+    )
+        external
+        returns (bool);
+}
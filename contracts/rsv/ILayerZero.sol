@@ -0,0 +1,39 @@
+pragma solidity 0.5.7;
+
+/// The parts of a [LayerZero](https://layerzero.network) v1 endpoint that an application uses.
+/// `destination` and `srcAddress` are paths: the remote application's address followed by the
+/// local one, packed, as in LayerZero's own `LzApp`.
+interface ILayerZeroEndpoint {
+    function send(
+        uint16 dstChainId,
+        bytes calldata destination,
+        bytes calldata payload,
+        address payable refundAddress,
+        address zroPaymentAddress,
+        bytes calldata adapterParams
+    )
+        external
+        payable;
+
+    function estimateFees(
+        uint16 dstChainId,
+        address userApplication,
+        bytes calldata payload,
+        bool payInZRO,
+        bytes calldata adapterParams
+    )
+        external
+        view
+        returns (uint256 nativeFee, uint256 zroFee);
+}
+
+/// What a LayerZero endpoint calls to deliver a message.
+interface ILayerZeroReceiver {
+    function lzReceive(
+        uint16 srcChainId,
+        bytes calldata srcAddress,
+        uint64 nonce,
+        bytes calldata payload
+    )
+        external;
+}
@@ -15,7 +15,10 @@ interface IRSV {
     function decimals() external view returns(uint8);
     function mint(address, uint256) external;
     function burnFrom(address, uint256) external;
+    function emergencyBurnFrom(address, uint256) external;
+    function emergencyRedemptionOpen() external view returns(bool);
     function relayTransfer(address, address, uint256) external returns(bool);
     function relayTransferFrom(address, address, address, uint256) external returns(bool);
     function relayApprove(address, address, uint256) external returns(bool);
+    function permit(address, address, uint256, uint256, uint8, bytes32, bytes32) external;
 }
@@ -0,0 +1,10 @@
+pragma solidity 0.5.7;
+
+/// A compliance check that the Reserve consults on every transfer, such as to enforce
+/// jurisdiction rules; see `Reserve.changeTransferHook`. It is a view, so the Reserve calls it
+/// with STATICCALL and it can't change state or call back into the token. It refuses a transfer
+/// by returning false or by reverting.
+interface ITransferHook {
+    /// @return whether `value` attotokens may move from `from` to `to`.
+    function checkTransfer(address from, address to, uint256 value) external view returns (bool);
+}
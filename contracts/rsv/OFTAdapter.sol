@@ -0,0 +1,238 @@
+pragma solidity 0.5.7;
+
+import "../ownership/Ownable.sol";
+import "./IRSV.sol";
+import "./ILayerZero.sol";
+
+/**
+ * @title The OFT Adapter
+ * @dev Moves RSV between chains over LayerZero, speaking the messages of LayerZero's v1 Omnichain
+ * Fungible Token (OFT), so that an adapter on each chain is the other's trusted remote.
+ *
+ * On RSV's home chain the adapter is a lockbox: `sendFrom` locks RSV in it, and a transfer in
+ * from another chain releases it. On every other chain it is that chain's Reserve minter and
+ * burns and mints instead. Either way the sender approves the adapter for what it sends.
+ *
+ * A message is accepted only from the trusted remote of its source chain, which the owner sets.
+ * Its transfer is made in a call of the adapter to itself, so that a transfer that fails, such
+ * as to a frozen account, doesn't block the messages behind it; it is kept, and anyone can
+ * `retryMessage` it once it can succeed, as with LayerZero's `NonblockingLzApp`.
+ */
+contract OFTAdapter is Ownable, ILayerZeroReceiver {
+
+    // Packet type of an OFT transfer.
+    uint16 public constant PT_SEND = 0;
+
+    IRSV public trustedRSV;
+    ILayerZeroEndpoint public lzEndpoint;
+
+    // Whether this is the home chain's adapter, which locks RSV rather than burning it.
+    bool public lockbox;
+
+    // The path to the trusted remote adapter on each chain, by LayerZero chain ID: its address
+    // packed with this one's.
+    mapping(uint16 => bytes) public trustedRemoteLookup;
+
+    // The hash of each message whose transfer failed, by source chain, path, and nonce.
+    mapping(uint16 => mapping(bytes => mapping(uint64 => bytes32))) public failedMessages;
+
+    event SetTrustedRemoteAddress(uint16 remoteChainId, bytes remoteAddress);
+    event SendToChain(
+        uint16 indexed dstChainId,
+        address indexed from,
+        bytes toAddress,
+        uint256 amount
+    );
+    event ReceiveFromChain(uint16 indexed srcChainId, address indexed to, uint256 amount);
+    event MessageFailed(
+        uint16 srcChainId,
+        bytes srcAddress,
+        uint64 nonce,
+        bytes payload,
+        bytes reason
+    );
+    event RetryMessageSuccess(
+        uint16 srcChainId,
+        bytes srcAddress,
+        uint64 nonce,
+        bytes32 payloadHash
+    );
+
+    constructor(address rsvAddress, address endpointAddress, bool isLockbox) public {
+        trustedRSV = IRSV(rsvAddress);
+        lzEndpoint = ILayerZeroEndpoint(endpointAddress);
+        lockbox = isLockbox;
+    }
+
+    /// Trust the adapter at `remoteAddress` on the chain with LayerZero ID `remoteChainId`, to
+    /// send to and receive from. An empty `remoteAddress` trusts none there.
+    function setTrustedRemoteAddress(uint16 remoteChainId, bytes calldata remoteAddress)
+        external
+        onlyOwner
+    {
+        if (remoteAddress.length == 0) {
+            delete trustedRemoteLookup[remoteChainId];
+        } else {
+            trustedRemoteLookup[remoteChainId] = abi.encodePacked(remoteAddress, address(this));
+        }
+        emit SetTrustedRemoteAddress(remoteChainId, remoteAddress);
+    }
+
+    /// @return the address of the trusted remote adapter on `remoteChainId`.
+    function getTrustedRemoteAddress(uint16 remoteChainId) external view returns (bytes memory) {
+        bytes memory path = trustedRemoteLookup[remoteChainId];
+        require(path.length != 0, "no trusted remote");
+        return _slice(path, 0, path.length - 20);
+    }
+
+    /// @return the LayerZero fees, in wei and in ZRO, for sending `amount` attotokens to
+    /// `toAddress` on `dstChainId`.
+    function estimateSendFee(
+        uint16 dstChainId,
+        bytes calldata toAddress,
+        uint256 amount,
+        bool useZro,
+        bytes calldata adapterParams
+    )
+        external
+        view
+        returns (uint256 nativeFee, uint256 zroFee)
+    {
+        return lzEndpoint.estimateFees(
+            dstChainId, address(this), _payload(toAddress, amount), useZro, adapterParams
+        );
+    }
+
+    /// Send `amount` of the sender's attotokens to `toAddress` on `dstChainId`, paying the
+    /// LayerZero fee with the ether sent along, of which `refundAddress` gets back what is left.
+    /// `from` must be the sender, which must have approved the adapter for `amount`.
+    function sendFrom(
+        address from,
+        uint16 dstChainId,
+        bytes calldata toAddress,
+        uint256 amount,
+        address payable refundAddress,
+        address zroPaymentAddress,
+        bytes calldata adapterParams
+    )
+        external
+        payable
+    {
+        require(from == _msgSender(), "from must be the sender");
+        require(amount > 0, "zero amount");
+        bytes memory path = trustedRemoteLookup[dstChainId];
+        require(path.length != 0, "destination chain is not trusted");
+
+        if (lockbox) {
+            require(trustedRSV.transferFrom(from, address(this), amount), "transfer failed");
+        } else {
+            trustedRSV.burnFrom(from, amount);
+        }
+
+        lzEndpoint.send.value(msg.value)(
+            dstChainId,
+            path,
+            _payload(toAddress, amount),
+            refundAddress,
+            zroPaymentAddress,
+            adapterParams
+        );
+        emit SendToChain(dstChainId, from, toAddress, amount);
+    }
+
+    /// Receive a message from the endpoint. Only the endpoint may call this, and only with a
+    /// message from a trusted remote.
+    function lzReceive(
+        uint16 srcChainId,
+        bytes calldata srcAddress,
+        uint64 nonce,
+        bytes calldata payload
+    )
+        external
+    {
+        require(_msgSender() == address(lzEndpoint), "must be endpoint");
+        bytes memory path = trustedRemoteLookup[srcChainId];
+        require(
+            path.length != 0 && keccak256(srcAddress) == keccak256(path),
+            "source is not trusted"
+        );
+
+        // solium-disable-next-line security/no-low-level-calls
+        (bool success, bytes memory reason) = address(this).call(abi.encodeWithSelector(
+            this.nonblockingLzReceive.selector, srcChainId, srcAddress, nonce, payload
+        ));
+        if (!success) {
+            failedMessages[srcChainId][srcAddress][nonce] = keccak256(payload);
+            emit MessageFailed(srcChainId, srcAddress, nonce, payload, reason);
+        }
+    }
+
+    /// Make the transfer of a received message. Only the adapter itself may call this, from
+    /// `lzReceive`.
+    function nonblockingLzReceive(
+        uint16 srcChainId,
+        bytes calldata,
+        uint64,
+        bytes calldata payload
+    )
+        external
+    {
+        require(_msgSender() == address(this), "must be the adapter itself");
+        _credit(srcChainId, payload);
+    }
+
+    /// Make the transfer of a message that failed, as it was received.
+    function retryMessage(
+        uint16 srcChainId,
+        bytes calldata srcAddress,
+        uint64 nonce,
+        bytes calldata payload
+    )
+        external
+    {
+        bytes32 payloadHash = failedMessages[srcChainId][srcAddress][nonce];
+        require(payloadHash != bytes32(0), "no failed message");
+        require(keccak256(payload) == payloadHash, "wrong payload");
+        delete failedMessages[srcChainId][srcAddress][nonce];
+        _credit(srcChainId, payload);
+        emit RetryMessageSuccess(srcChainId, srcAddress, nonce, payloadHash);
+    }
+
+    /// @dev Give the recipient of a transfer message its tokens.
+    function _credit(uint16 srcChainId, bytes memory payload) internal {
+        (uint16 packetType, bytes memory toAddress, uint256 amount) =
+            abi.decode(payload, (uint16, bytes, uint256));
+        require(packetType == PT_SEND, "unknown packet type");
+        require(toAddress.length == 20, "not an address");
+        address to;
+        // solium-disable-next-line security/no-inline-assembly
+        assembly {
+            to := div(mload(add(toAddress, 32)), 0x1000000000000000000000000)
+        }
+
+        if (lockbox) {
+            require(trustedRSV.transfer(to, amount), "transfer failed");
+        } else {
+            trustedRSV.mint(to, amount);
+        }
+        emit ReceiveFromChain(srcChainId, to, amount);
+    }
+
+    /// @dev The message of a transfer of `amount` attotokens to `toAddress`, as an OFT sends it.
+    function _payload(bytes memory toAddress, uint256 amount) internal pure returns (bytes memory) {
+        return abi.encode(PT_SEND, toAddress, amount);
+    }
+
+    /// @dev `length` bytes of `b` from `start`.
+    function _slice(bytes memory b, uint256 start, uint256 length)
+        internal
+        pure
+        returns (bytes memory)
+    {
+        bytes memory result = new bytes(length);
+        for (uint256 i = 0; i < length; i++) {
+            result[i] = b[start + i];
+        }
+        return result;
+    }
+}
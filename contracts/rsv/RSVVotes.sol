@@ -0,0 +1,204 @@
+pragma solidity 0.5.7;
+
+import "../zeppelin/token/ERC20/ERC20.sol";
+import "../zeppelin/token/ERC20/IERC20.sol";
+import "../zeppelin/token/ERC20/SafeERC20.sol";
+import "../zeppelin/math/SafeMath.sol";
+
+/// The Reserve's freezing, as the vote token honors it.
+interface IFreezable {
+    function frozen(address account) external view returns (bool);
+}
+
+/**
+ * @title RSV Votes
+ * @dev A wrapper of RSV with vote delegation, as in OpenZeppelin's ERC20Votes and Compound's
+ * COMP, for governance experiments that shouldn't put their weight on the Reserve itself.
+ *
+ * `depositFor` wraps RSV 1:1 into vRSV, and `withdrawTo` unwraps it. A holder's vRSV counts as
+ * votes only once delegated, to another account or to itself, with `delegate`. The votes of
+ * each delegate, and the total supply, are checkpointed at every change, by block number, so
+ * that `getPastVotes` and `getPastTotalSupply` can read them as of any past block, such as a
+ * proposal's. vRSV moves only as RSV could: not from or to an account the Reserve has frozen.
+ */
+contract RSVVotes is ERC20 {
+    using SafeMath for uint256;
+    using SafeERC20 for IERC20;
+
+    // The token wrapped: RSV.
+    IERC20 public underlying;
+
+    // Basic information as constants
+    string public constant name = "Reserve Votes";
+    string public constant symbol = "vRSV";
+    uint8 public constant decimals = 18;
+
+    // A delegate's votes, or the total supply, from block `fromBlock` on.
+    struct Checkpoint {
+        uint32 fromBlock;
+        uint224 votes;
+    }
+
+    // Each holder's delegate, or the zero address if it has none.
+    mapping(address => address) public delegates;
+
+    mapping(address => Checkpoint[]) internal _checkpoints;
+    Checkpoint[] internal _totalSupplyCheckpoints;
+
+    event DelegateChanged(
+        address indexed delegator,
+        address indexed fromDelegate,
+        address indexed toDelegate
+    );
+    event DelegateVotesChanged(
+        address indexed delegate,
+        uint256 previousBalance,
+        uint256 newBalance
+    );
+
+    constructor(address rsvAddress) public {
+        underlying = IERC20(rsvAddress);
+    }
+
+    /// Wrap `amount` of the sender's attoRSV, as vRSV for `account`. The sender must have
+    /// approved this contract for them. Should RSV charge a fee on the transfer, `account` gets
+    /// what arrives.
+    function depositFor(address account, uint256 amount) external returns (bool) {
+        uint256 before = underlying.balanceOf(address(this));
+        underlying.safeTransferFrom(_msgSender(), address(this), amount);
+        _mint(account, underlying.balanceOf(address(this)).sub(before));
+        return true;
+    }
+
+    /// Unwrap `amount` of the sender's vRSV, sending the RSV to `account`.
+    function withdrawTo(address account, uint256 amount) external returns (bool) {
+        _burn(_msgSender(), amount);
+        underlying.safeTransfer(account, amount);
+        return true;
+    }
+
+    /// Delegate the sender's votes to `delegatee`, in place of its current delegate.
+    function delegate(address delegatee) external {
+        _delegate(_msgSender(), delegatee);
+    }
+
+    /// @return the current votes of `account`.
+    function getVotes(address account) external view returns (uint256) {
+        uint256 count = _checkpoints[account].length;
+        return count == 0 ? 0 : _checkpoints[account][count - 1].votes;
+    }
+
+    /// @return the votes of `account` at the end of block `blockNumber`, which must be mined.
+    function getPastVotes(address account, uint256 blockNumber) external view returns (uint256) {
+        require(blockNumber < block.number, "block not yet mined");
+        return _checkpointsLookup(_checkpoints[account], blockNumber);
+    }
+
+    /// @return the total supply at the end of block `blockNumber`, which must be mined. This is
+    /// all vRSV, whether or not it is delegated.
+    function getPastTotalSupply(uint256 blockNumber) external view returns (uint256) {
+        require(blockNumber < block.number, "block not yet mined");
+        return _checkpointsLookup(_totalSupplyCheckpoints, blockNumber);
+    }
+
+    /// @return how many checkpoints `account` has.
+    function numCheckpoints(address account) external view returns (uint32) {
+        return uint32(_checkpoints[account].length);
+    }
+
+    /// @return the `pos`th checkpoint of `account`.
+    function checkpoints(address account, uint32 pos)
+        external
+        view
+        returns (uint32 fromBlock, uint224 votes)
+    {
+        Checkpoint storage checkpoint = _checkpoints[account][pos];
+        return (checkpoint.fromBlock, checkpoint.votes);
+    }
+
+    /// @dev Transfer vRSV, and its votes with it, unless the Reserve has frozen either account.
+    function _transfer(address sender, address recipient, uint256 amount) internal {
+        IFreezable reserve = IFreezable(address(underlying));
+        require(!reserve.frozen(sender), "sender is frozen");
+        require(!reserve.frozen(recipient), "recipient is frozen");
+        super._transfer(sender, recipient, amount);
+        _moveVotingPower(delegates[sender], delegates[recipient], amount);
+    }
+
+    /// @dev Mint vRSV, with its votes, and checkpoint the total supply.
+    function _mint(address account, uint256 amount) internal {
+        super._mint(account, amount);
+        require(totalSupply() <= uint224(-1), "votes overflow");
+        _writeCheckpoint(_totalSupplyCheckpoints, totalSupply());
+        _moveVotingPower(address(0), delegates[account], amount);
+    }
+
+    /// @dev Burn vRSV, with its votes, and checkpoint the total supply.
+    function _burn(address account, uint256 amount) internal {
+        super._burn(account, amount);
+        _writeCheckpoint(_totalSupplyCheckpoints, totalSupply());
+        _moveVotingPower(delegates[account], address(0), amount);
+    }
+
+    /// @dev Change `delegator`'s delegate, moving the votes of its whole balance.
+    function _delegate(address delegator, address delegatee) internal {
+        address current = delegates[delegator];
+        delegates[delegator] = delegatee;
+        emit DelegateChanged(delegator, current, delegatee);
+        _moveVotingPower(current, delegatee, balanceOf(delegator));
+    }
+
+    /// @dev Move `amount` votes from the delegate `src` to the delegate `dst`. The zero address
+    /// stands for no delegate, whose votes aren't counted.
+    function _moveVotingPower(address src, address dst, uint256 amount) internal {
+        if (src == dst || amount == 0) {
+            return;
+        }
+        if (src != address(0)) {
+            Checkpoint[] storage ckpts = _checkpoints[src];
+            uint256 oldVotes = ckpts.length == 0 ? 0 : ckpts[ckpts.length - 1].votes;
+            uint256 newVotes = oldVotes.sub(amount);
+            _writeCheckpoint(ckpts, newVotes);
+            emit DelegateVotesChanged(src, oldVotes, newVotes);
+        }
+        if (dst != address(0)) {
+            Checkpoint[] storage ckpts = _checkpoints[dst];
+            uint256 oldVotes = ckpts.length == 0 ? 0 : ckpts[ckpts.length - 1].votes;
+            uint256 newVotes = oldVotes.add(amount);
+            _writeCheckpoint(ckpts, newVotes);
+            emit DelegateVotesChanged(dst, oldVotes, newVotes);
+        }
+    }
+
+    /// @dev Record `votes` in `ckpts` as of this block, in place of any checkpoint already
+    /// written in it.
+    function _writeCheckpoint(Checkpoint[] storage ckpts, uint256 votes) internal {
+        require(block.number <= uint32(-1), "block number overflow");
+        uint256 count = ckpts.length;
+        if (count > 0 && ckpts[count - 1].fromBlock == block.number) {
+            ckpts[count - 1].votes = uint224(votes);
+        } else {
+            ckpts.push(Checkpoint({fromBlock: uint32(block.number), votes: uint224(votes)}));
+        }
+    }
+
+    /// @dev The votes in `ckpts` at the end of block `blockNumber`: those of the last checkpoint
+    /// from at or before it, by binary search, or zero if there is none.
+    function _checkpointsLookup(Checkpoint[] storage ckpts, uint256 blockNumber)
+        internal
+        view
+        returns (uint256)
+    {
+        uint256 low = 0;
+        uint256 high = ckpts.length;
+        while (low < high) {
+            uint256 mid = (low + high) / 2;
+            if (ckpts[mid].fromBlock > blockNumber) {
+                high = mid;
+            } else {
+                low = mid + 1;
+            }
+        }
+        return high == 0 ? 0 : ckpts[high - 1].votes;
+    }
+}
@@ -38,6 +38,13 @@ contract Relayer is Ownable {
         uint256 amount,
         uint256 fee
     );
+    event PermitForwarded(
+        bytes sig,
+        address indexed holder,
+        address indexed spender,
+        uint256 amount,
+        uint256 fee
+    );
     event FeeTaken(address indexed from, address indexed to, uint256 indexed value);
 
     constructor(address rsvAddress) public {
@@ -155,6 +162,79 @@ contract Relayer is Ownable {
         emit TransferFromForwarded(sig, holder, spender, to, amount, fee);
     }
 
+    /// Forward a signed EIP-2612 `permit` to the RSV contract if `sig` matches the signature.
+    /// `sig` authorizes the fee, and `permitSig` is the holder's signature of the permit
+    /// itself, which the RSV contract checks against its own nonce for the holder.
+    function forwardPermit(
+        bytes calldata sig,
+        address holder,
+        address spender,
+        uint256 amount,
+        uint256 deadline,
+        bytes calldata permitSig,
+        uint256 fee
+    )
+        external
+    {
+        address recoveredSigner = _recoverSignerAddress(
+            _permitHash(holder, spender, amount, deadline, fee),
+            sig
+        );
+        require(recoveredSigner == holder, "invalid signature");
+        nonce[holder]++;
+
+        _takeFee(holder, fee);
+
+        _permit(holder, spender, amount, deadline, permitSig);
+        emit PermitForwarded(sig, holder, spender, amount, fee);
+    }
+
+    /// The hash that `holder` signs to authorize forwardPermit.
+    function _permitHash(
+        address holder,
+        address spender,
+        uint256 amount,
+        uint256 deadline,
+        uint256 fee
+    )
+        internal view
+        returns (bytes32)
+    {
+        return keccak256(abi.encodePacked(
+            address(trustedRSV),
+            "forwardPermit",
+            holder,
+            spender,
+            amount,
+            deadline,
+            fee,
+            nonce[holder]
+        ));
+    }
+
+    /// Split `permitSig` into v, r, and s, and submit the permit.
+    function _permit(
+        address holder,
+        address spender,
+        uint256 amount,
+        uint256 deadline,
+        bytes memory permitSig
+    )
+        internal
+    {
+        require(permitSig.length == 65, "invalid permit signature length");
+        bytes32 r;
+        bytes32 s;
+        uint8 v;
+        // solium-disable-next-line security/no-inline-assembly
+        assembly {
+            r := mload(add(permitSig, 0x20))
+            s := mload(add(permitSig, 0x40))
+            v := byte(0, mload(add(permitSig, 0x60)))
+        }
+        trustedRSV.permit(holder, spender, amount, deadline, v, r, s);
+    }
+
     /// Recover the signer's address from the hash and signature.
     function _recoverSignerAddress(bytes32 hash, bytes memory sig)
         internal pure
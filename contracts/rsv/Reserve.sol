@@ -1,9 +1,14 @@
 pragma solidity 0.5.7;
 
 import "../zeppelin/token/ERC20/IERC20.sol";
+import "../zeppelin/token/ERC20/SafeERC20.sol";
 import "../zeppelin/math/SafeMath.sol";
 import "../ownership/Ownable.sol";
+import "../zeppelin/utils/ECDSA.sol";
 import "./ReserveEternalStorage.sol";
+import "./IERC1363.sol";
+import "./IERC3156.sol";
+import "./ITransferHook.sol";
 
 /**
  * @title An interface representing a contract that calculates transaction fees
@@ -14,13 +19,22 @@ import "./ReserveEternalStorage.sol";
 
 /**
  * @title The Reserve Token
- * @dev An ERC-20 token with minting, burning, pausing, and user freezing.
+ * @dev An ERC-20 token with minting, burning, pausing, user freezing, EIP-2612 permits, and
+ * EIP-3009 transfers with authorization, ERC-1363 transfers and approvals that call their
+ * recipient, ERC-3156 flash mints, an optional compliance hook on transfers, and snapshots of
+ * balances for off-chain distributions. Holders can also send their own token operations through
+ * an EIP-2771 trusted forwarder; see `_tokenSender`.
+ * Access is by role (see `hasRole`): the admin, who is the owner, sets parameters and assigns
+ * the other roles, each of which is held by one account.
  * Based on OpenZeppelin's [implementation](https://github.com/OpenZeppelin/openzeppelin-solidity/blob/41aa39afbc13f0585634061701c883fe512a5469/contracts/token/ERC20/ERC20.sol).
  *
  * Non-constant-sized data is held in ReserveEternalStorage, to facilitate potential future upgrades.
+ * A Reserve can also be deployed behind a ReserveProxy, and then upgraded in place with
+ * `upgradeTo`; see "Upgradeability", below.
  */
 contract Reserve is IERC20, Ownable {
     using SafeMath for uint256;
+    using SafeERC20 for IERC20;
 
 
     // ==== State ====
@@ -35,18 +49,86 @@ contract Reserve is IERC20, Ownable {
     // Relayer
     address public trustedRelayer;
 
+    // EIP-2771 forwarder
+    address public trustedForwarder;
+
     // Basic token data
     uint256 public totalSupply;
     uint256 public maxSupply;
+    // Whether the owner has set `maxSupply`, after which it can only be raised.
+    bool public maxSupplySet;
+
+    // Mint limit: at most `mintCap` attotokens can be minted in any MINT_WINDOW. Mints are
+    // counted in buckets of MINT_BUCKET, `mintedInBucket[i]` being what was minted in the `i`th
+    // bucket since the epoch, and a mint counts until its bucket is a whole MINT_WINDOW old: for
+    // at least MINT_WINDOW, and at most MINT_WINDOW plus MINT_BUCKET.
+    uint256 public mintCap;
+    mapping(uint256 => uint256) public mintedInBucket;
 
     // Paused data
     bool public paused;
+    uint256 public pausedAt;
+
+    // Whether the admin has opened emergency redemption early; see emergencyRedemptionOpen.
+    bool public emergencyRedemptionStarted;
 
     // Auth roles
     address public minter;
     address public pauser;
+    address public guardian;
+    address public freezer;
+    address public wiper;
     address public feeRecipient;
 
+    // Frozen accounts. Like the nonces below, these stay with this contract rather than in
+    // eternal storage, whose deployed instance has no place for them; a replacement Reserve
+    // must freeze them again (see ops/blocklist).
+    mapping(address => bool) public frozen;
+
+    // When each proposed wipe of a frozen account may be made, as a Unix time; zero if none is.
+    mapping(address => uint256) public wipeReadyAt;
+
+    // Permit and authorization data. Nonces stay with this contract rather than in eternal
+    // storage: a signature names the contract it is for, so an upgrade voids the permits and
+    // authorizations signed for the previous one anyway.
+    uint256 public chainId;
+    bytes32 public DOMAIN_SEPARATOR;
+    mapping(address => uint256) public nonces;
+    mapping(address => mapping(bytes32 => bool)) public authorizationState;
+
+    // Whether this Reserve's fields have been initialized: by the constructor, or, behind a
+    // ReserveProxy, by `initialize`. A proxied Reserve keeps its state in the proxy, so later
+    // implementations may only add state variables after this one.
+    bool public initialized;
+
+    // Flash mints, per ERC-3156: a flash loan mints at most `flashMintCap` attotokens, for a fee
+    // of `flashMintFee` of them, paid to the fee recipient. `flashMinting` is set while a loan is
+    // out, from the mint to the burn that repays it.
+    uint256 public flashMintCap;
+    uint256 public flashMintFee; // unit: BPS
+    bool public flashMinting;
+
+    // Compliance hook that every transfer must pass, if set; see `changeTransferHook`.
+    ITransferHook public transferHook;
+
+    // Snapshots, as in OpenZeppelin's ERC20Snapshot: `snapshot`, called by the snapshotter,
+    // records every balance and the total supply as of that moment, for `balanceOfAt` and
+    // `totalSupplyAt`. A value is written only when it first changes after a snapshot, as the
+    // snapshot's ID and the value before the change. Like frozen accounts, they stay with this
+    // contract, so a replacement Reserve starts with none.
+    struct Snapshots {
+        uint256[] ids;
+        uint256[] values;
+    }
+    address public snapshotter;
+    uint256 public currentSnapshotId;
+    mapping(address => Snapshots) internal accountSnapshots;
+    Snapshots internal totalSupplySnapshots;
+
+    // Whether transfers alone are paused, while minting and burning, and so issuance and
+    // redemption through the Manager, go on; see `pauseTransfers`.
+    bool public transfersPaused;
+
 
     // ==== Events, Constants, and Constructor ====
 
@@ -54,15 +136,58 @@ contract Reserve is IERC20, Ownable {
     // Auth role change events
     event MinterChanged(address indexed newMinter);
     event PauserChanged(address indexed newPauser);
+    event GuardianChanged(address indexed newGuardian);
+    event FreezerChanged(address indexed newFreezer);
+    event WiperChanged(address indexed newWiper);
     event FeeRecipientChanged(address indexed newFeeRecipient);
+    event SnapshotterChanged(address indexed newSnapshotter);
     event MaxSupplyChanged(uint256 indexed newMaxSupply);
+    event MintCapChanged(uint256 indexed newMintCap);
     event EternalStorageTransferred(address indexed newReserveAddress);
     event TxFeeHelperChanged(address indexed newTxFeeHelper);
     event TrustedRelayerChanged(address indexed newTrustedRelayer);
+    event TrustedForwarderChanged(address indexed newTrustedForwarder);
+    event TransferHookChanged(address indexed newTransferHook);
+    event ChainIdChanged(uint256 indexed newChainId);
+    event FlashMintCapChanged(uint256 indexed newFlashMintCap);
+    event FlashMintFeeChanged(uint256 indexed newFlashMintFee);
+
+    // Flash mint event, after the loan is repaid
+    event FlashMinted(
+        address indexed receiver,
+        address indexed initiator,
+        uint256 value,
+        uint256 fee
+    );
+
+    // Snapshot event
+    event Snapshot(uint256 id);
+
+    // Recovery event, for tokens sent to this contract by mistake
+    event TokenSwept(address indexed token, address indexed to, uint256 amount);
+
+    // ERC-1967 upgrade event
+    event Upgraded(address indexed implementation);
+
+    // Authorization events
+    event AuthorizationUsed(address indexed authorizer, bytes32 indexed nonce);
+    event AuthorizationCanceled(address indexed authorizer, bytes32 indexed nonce);
 
     // Pause events
     event Paused(address indexed account);
     event Unpaused(address indexed account);
+    event EmergencyRedemptionStarted(address indexed account);
+    event TransfersPaused(address indexed account);
+    event TransfersUnpaused(address indexed account);
+
+    // Freeze events
+    event AddressFrozen(address indexed account);
+    event AddressUnfrozen(address indexed account);
+
+    // Wipe events
+    event WipeProposed(address indexed account, uint256 readyAt);
+    event WipeCanceled(address indexed account);
+    event FrozenBalanceWiped(address indexed account, uint256 value, address indexed wipedBy);
 
     // Basic information as constants
     string public constant name = "Reserve";
@@ -70,14 +195,102 @@ contract Reserve is IERC20, Ownable {
     string public constant version = "2.1";
     uint8 public constant decimals = 18;
 
+    // How long a wipe must wait after it is proposed.
+    uint256 public constant WIPE_DELAY = 2 days;
+
+    // How far back the mint limit looks, and how finely it counts what was minted then.
+    uint256 public constant MINT_WINDOW = 1 days;
+    uint256 public constant MINT_BUCKET = 1 hours;
+
+    // How long a pause must last before holders may redeem against the Vault pro-rata.
+    uint256 public constant EMERGENCY_REDEMPTION_DELAY = 30 days;
+
+    // The largest flash mint fee, and what 100% is, in basis points (BPS).
+    uint256 public constant MAX_FLASH_MINT_FEE = 1000; // unit: BPS
+    uint256 internal constant BPS_FACTOR = 10000; // unit: BPS
+
+    // Role identifiers, for `hasRole`, `grantRole`, `revokeRole`, and `renounceRole`. Each role
+    // has a single holder, who is also returned by the role's own getter, such as `minter()`, so
+    // that changing the holder grants and revokes the role at once. ADMIN_ROLE is the owner: it
+    // changes hands only by `nominateNewOwner` and `acceptOwnership`.
+    bytes32 public constant ADMIN_ROLE = 0x00;
+    bytes32 public constant MINTER_ROLE = keccak256("MINTER_ROLE");
+    bytes32 public constant PAUSER_ROLE = keccak256("PAUSER_ROLE");
+    bytes32 public constant GUARDIAN_ROLE = keccak256("GUARDIAN_ROLE");
+    bytes32 public constant FREEZER_ROLE = keccak256("FREEZER_ROLE");
+    bytes32 public constant WIPER_ROLE = keccak256("WIPER_ROLE");
+    bytes32 public constant FEE_RECIPIENT_ROLE = keccak256("FEE_RECIPIENT_ROLE");
+    bytes32 public constant SNAPSHOTTER_ROLE = keccak256("SNAPSHOTTER_ROLE");
+
+    // EIP-712 type hashes, for permits
+    bytes32 public constant PERMIT_TYPEHASH = keccak256(
+        "Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)"
+    );
+    bytes32 public constant TRANSFER_WITH_AUTHORIZATION_TYPEHASH = keccak256(
+        "TransferWithAuthorization(address from,address to,uint256 value,uint256 validAfter,uint256 validBefore,bytes32 nonce)"
+    );
+    bytes32 public constant RECEIVE_WITH_AUTHORIZATION_TYPEHASH = keccak256(
+        "ReceiveWithAuthorization(address from,address to,uint256 value,uint256 validAfter,uint256 validBefore,bytes32 nonce)"
+    );
+    bytes32 public constant CANCEL_AUTHORIZATION_TYPEHASH = keccak256(
+        "CancelAuthorization(address authorizer,bytes32 nonce)"
+    );
+    bytes32 internal constant DOMAIN_TYPEHASH = keccak256(
+        "EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"
+    );
+
+    // ERC-165 interface IDs, each the XOR of the selectors of the standard's functions, and the
+    // values that ERC-1363 hooks return to accept a call
+    bytes4 internal constant ERC165_INTERFACE_ID = 0x01ffc9a7;
+    bytes4 internal constant ERC20_INTERFACE_ID = 0x36372b07;
+    bytes4 internal constant ERC20_METADATA_INTERFACE_ID = 0xa219a025; // name, symbol, decimals
+    bytes4 internal constant EIP2612_INTERFACE_ID = 0x9d8ff7da;
+    bytes4 internal constant EIP2771_INTERFACE_ID = 0x572b6c05;
+    bytes4 internal constant EIP3009_INTERFACE_ID = 0xbff533ba;
+    bytes4 internal constant ERC1363_INTERFACE_ID = 0xb0202a11;
+    bytes4 internal constant ERC1822_INTERFACE_ID = 0x52d1902d;
+    bytes4 internal constant ERC3156_INTERFACE_ID = 0xe4143091; // the lender's
+    bytes4 internal constant ERC1363_RECEIVED = 0x88a7ca5c; // onTransferReceived.selector
+    bytes4 internal constant ERC1363_APPROVED = 0x7b04a2d0; // onApprovalReceived.selector
+
+    // What an ERC-3156 borrower's `onFlashLoan` returns to accept a loan
+    bytes32 internal constant ERC3156_CALLBACK_SUCCESS =
+        keccak256("ERC3156FlashBorrower.onFlashLoan");
+
+    // The ERC-1967 slot that a ReserveProxy keeps its implementation in:
+    // bytes32(uint256(keccak256("eip1967.proxy.implementation")) - 1)
+    bytes32 internal constant IMPLEMENTATION_SLOT =
+        0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc;
+
     /// Initialize critical fields.
     constructor() public {
+        _initialize();
+    }
+
+    /// Initialize a Reserve behind a ReserveProxy, in the proxy's storage, as the constructor
+    /// does a Reserve deployed on its own, making the sender its owner. The proxy calls this as
+    /// it is deployed, so that no one else can get there first. The constructor has already
+    /// initialized the implementation itself, so no one can initialize that.
+    function initialize() external {
+        _initialize();
+        _initializeOwner(msg.sender);
+    }
+
+    /// @dev Set the fields that the constructor sets, once only.
+    function _initialize() internal {
+        require(!initialized, "already initialized");
+        initialized = true;
+
         pauser = msg.sender;
         feeRecipient = msg.sender;
-        // minter defaults to the zero address.
+        // minter and freezer default to the zero address.
 
         maxSupply = 2 ** 256 - 1;
+        mintCap = 2 ** 256 - 1;
+        // flashMintCap and flashMintFee default to zero: no flash mints until the owner sets a
+        // cap.
         paused = true;
+        pausedAt = now;
 
         trustedTxFee = ITXFee(address(0));
         trustedRelayer = address(0);
@@ -93,74 +306,366 @@ contract Reserve is IERC20, Ownable {
     // ==== Admin functions ====
 
 
-    /// Modifies a function to only run if sent by `role`.
-    modifier only(address role) {
-        require(msg.sender == role, "unauthorized: not role holder");
+    /// Modifies a function to only run if sent by `account`.
+    modifier only(address account) {
+        require(msg.sender == account, "unauthorized: not role holder");
         _;
     }
 
-    /// Modifies a function to only run if sent by `role` or the contract's `owner`.
-    modifier onlyOwnerOr(address role) {
-        require(msg.sender == owner() || msg.sender == role, "unauthorized: not owner or role");
+    /// Modifies a function to only run if sent by the holder of `role`.
+    modifier onlyRole(bytes32 role) {
+        require(hasRole(role, msg.sender), "unauthorized: not role holder");
+        _;
+    }
+
+    /// Modifies a function to only run if sent by the admin or the holder of `role`.
+    modifier onlyAdminOr(bytes32 role) {
+        require(
+            hasRole(ADMIN_ROLE, msg.sender) || hasRole(role, msg.sender),
+            "unauthorized: not admin or role"
+        );
         _;
     }
 
+    /// @return the holder of `role`, or the zero address if no one holds it.
+    function roleHolder(bytes32 role) public view returns (address) {
+        if (role == ADMIN_ROLE) return owner();
+        if (role == MINTER_ROLE) return minter;
+        if (role == PAUSER_ROLE) return pauser;
+        if (role == GUARDIAN_ROLE) return guardian;
+        if (role == FREEZER_ROLE) return freezer;
+        if (role == WIPER_ROLE) return wiper;
+        if (role == FEE_RECIPIENT_ROLE) return feeRecipient;
+        if (role == SNAPSHOTTER_ROLE) return snapshotter;
+        return address(0);
+    }
+
+    /// @return whether `account` holds `role`.
+    function hasRole(bytes32 role, address account) public view returns (bool) {
+        return account != address(0) && roleHolder(role) == account;
+    }
+
+    /// @return whether `account` may grant or revoke `role`: the admin may for any role but its
+    /// own, and each other role's holder may hand it on, except the guardian's, which can only
+    /// pause.
+    function canGrantRole(bytes32 role, address account) public view returns (bool) {
+        if (role == ADMIN_ROLE) return false;
+        return hasRole(ADMIN_ROLE, account) || (role != GUARDIAN_ROLE && hasRole(role, account));
+    }
+
+    /// Give `role` to `account`, taking it from its current holder.
+    function grantRole(bytes32 role, address account) external {
+        require(canGrantRole(role, msg.sender), "unauthorized: may not grant role");
+        _setRole(role, account);
+    }
+
+    /// Take `role` from `account`, leaving no one holding it.
+    function revokeRole(bytes32 role, address account) external {
+        require(canGrantRole(role, msg.sender), "unauthorized: may not revoke role");
+        require(hasRole(role, account), "account does not hold role");
+        _setRole(role, address(0));
+    }
+
+    /// Give up `role`, leaving no one holding it. The admin role is given up only by
+    /// `renounceOwnership`.
+    function renounceRole(bytes32 role) external {
+        require(role != ADMIN_ROLE, "use renounceOwnership");
+        require(hasRole(role, msg.sender), "sender does not hold role");
+        _setRole(role, address(0));
+    }
+
+    /// @dev Make `account` the holder of `role`, emitting the role's change event.
+    function _setRole(bytes32 role, address account) internal {
+        if (role == MINTER_ROLE) {
+            minter = account;
+            emit MinterChanged(account);
+        } else if (role == PAUSER_ROLE) {
+            pauser = account;
+            emit PauserChanged(account);
+        } else if (role == GUARDIAN_ROLE) {
+            guardian = account;
+            emit GuardianChanged(account);
+        } else if (role == FREEZER_ROLE) {
+            freezer = account;
+            emit FreezerChanged(account);
+        } else if (role == WIPER_ROLE) {
+            wiper = account;
+            emit WiperChanged(account);
+        } else if (role == FEE_RECIPIENT_ROLE) {
+            feeRecipient = account;
+            emit FeeRecipientChanged(account);
+        } else if (role == SNAPSHOTTER_ROLE) {
+            snapshotter = account;
+            emit SnapshotterChanged(account);
+        } else {
+            revert("unknown role");
+        }
+    }
+
     /// Change who holds the `minter` role.
-    function changeMinter(address newMinter) external onlyOwnerOr(minter) {
-        minter = newMinter;
-        emit MinterChanged(newMinter);
+    function changeMinter(address newMinter) external onlyAdminOr(MINTER_ROLE) {
+        _setRole(MINTER_ROLE, newMinter);
     }
 
     /// Change who holds the `pauser` role.
-    function changePauser(address newPauser) external onlyOwnerOr(pauser) {
-        pauser = newPauser;
-        emit PauserChanged(newPauser);
+    function changePauser(address newPauser) external onlyAdminOr(PAUSER_ROLE) {
+        _setRole(PAUSER_ROLE, newPauser);
+    }
+
+    /// Change who holds the `guardian` role. The guardian can only pause, so only the admin can
+    /// change it.
+    function changeGuardian(address newGuardian) external onlyRole(ADMIN_ROLE) {
+        _setRole(GUARDIAN_ROLE, newGuardian);
+    }
+
+    /// Change who holds the `freezer` role.
+    function changeFreezer(address newFreezer) external onlyAdminOr(FREEZER_ROLE) {
+        _setRole(FREEZER_ROLE, newFreezer);
+    }
+
+    /// Change who holds the `wiper` role.
+    function changeWiper(address newWiper) external onlyAdminOr(WIPER_ROLE) {
+        _setRole(WIPER_ROLE, newWiper);
     }
 
-    function changeFeeRecipient(address newFeeRecipient) external onlyOwnerOr(feeRecipient) {
-        feeRecipient = newFeeRecipient;
-        emit FeeRecipientChanged(newFeeRecipient);
+    function changeFeeRecipient(address newFeeRecipient) external onlyAdminOr(FEE_RECIPIENT_ROLE) {
+        _setRole(FEE_RECIPIENT_ROLE, newFeeRecipient);
+    }
+
+    /// Change who holds the `snapshotter` role.
+    function changeSnapshotter(address newSnapshotter) external onlyAdminOr(SNAPSHOTTER_ROLE) {
+        _setRole(SNAPSHOTTER_ROLE, newSnapshotter);
     }
 
     /// Make a different address the EternalStorage contract's reserveAddress.
     /// This will break this contract, so only do it if you're
     /// abandoning this contract, e.g., for an upgrade.
-    function transferEternalStorage(address newReserveAddress) external onlyOwner isPaused {
+    function transferEternalStorage(address newReserveAddress) external onlyRole(ADMIN_ROLE) isPaused {
         require(newReserveAddress != address(0), "zero address");
         emit EternalStorageTransferred(newReserveAddress);
         trustedData.updateReserveAddress(newReserveAddress);
     }
 
     /// Change the contract that is able to do metatransactions.
-    function changeRelayer(address newTrustedRelayer) external onlyOwner {
+    function changeRelayer(address newTrustedRelayer) external onlyRole(ADMIN_ROLE) {
         trustedRelayer = newTrustedRelayer;
         emit TrustedRelayerChanged(newTrustedRelayer);
     }
 
+    /// Change the EIP-2771 forwarder whose calls are made on behalf of the address it appends to
+    /// them. The zero address turns forwarding off.
+    function changeForwarder(address newTrustedForwarder) external onlyRole(ADMIN_ROLE) {
+        trustedForwarder = newTrustedForwarder;
+        emit TrustedForwarderChanged(newTrustedForwarder);
+    }
+
+    /// Change the compliance hook that is asked about every transfer, and may refuse it, such as
+    /// to enforce jurisdiction rules. Minting and burning don't consult it, so it can't stop
+    /// issuance or redemption. The zero address, the default, checks nothing; since the hook has
+    /// no say over this call, the owner can always unset a hook that refuses too much.
+    function changeTransferHook(address newTransferHook) external onlyRole(ADMIN_ROLE) {
+        transferHook = ITransferHook(newTransferHook);
+        emit TransferHookChanged(newTransferHook);
+    }
+
     /// Change the contract that helps with transaction fee calculation.
-    function changeTxFeeHelper(address newTrustedTxFee) external onlyOwner {
+    function changeTxFeeHelper(address newTrustedTxFee) external onlyRole(ADMIN_ROLE) {
         trustedTxFee = ITXFee(newTrustedTxFee);
         emit TxFeeHelperChanged(newTrustedTxFee);
     }
 
-    /// Change the maximum supply allowed.
-    function changeMaxSupply(uint256 newMaxSupply) external onlyOwner {
+    /// Change the hard cap on the total supply, which no mint may take it past. The cap starts
+    /// unlimited; once it is set, even to unlimited, it can only be raised, so that holders can
+    /// count on it.
+    ///
+    /// The Reserve doesn't enforce a delay on raises itself: it can't tell a Timelock from any
+    /// other owner, and an owner free of the Timelock could as well upgrade the Reserve past any
+    /// rule here. The delay comes from making the Timelock the owner, as for every admin change.
+    /// #if_succeeds {:msg "the max supply is set, at least the supply"}
+    ///     maxSupply == newMaxSupply && maxSupplySet && totalSupply <= maxSupply;
+    function changeMaxSupply(uint256 newMaxSupply) external onlyRole(ADMIN_ROLE) {
+        require(!maxSupplySet || newMaxSupply >= maxSupply, "max supply can only be raised");
+        require(newMaxSupply >= totalSupply, "max supply below total supply");
         maxSupply = newMaxSupply;
+        maxSupplySet = true;
         emit MaxSupplyChanged(newMaxSupply);
     }
 
-    /// Pause the contract.
-    function pause() external only(pauser) {
+    /// Change the most that can be minted in any MINT_WINDOW. It applies at once, counting
+    /// what has already been minted in the current window.
+    function changeMintCap(uint256 newMintCap) external onlyRole(ADMIN_ROLE) {
+        mintCap = newMintCap;
+        emit MintCapChanged(newMintCap);
+    }
+
+    /// How many attotokens can be minted now before the mint limit is reached. More becomes
+    /// mintable as the buckets of earlier mints leave the window.
+    function mintableInWindow() external view returns (uint256) {
+        uint256 minted = _mintedInWindow();
+        if (minted >= mintCap) {
+            return 0;
+        }
+        return mintCap - minted;
+    }
+
+    /// What still counts against the mint limit: what was minted in the current bucket and in
+    /// each bucket that started at most a MINT_WINDOW before it.
+    function _mintedInWindow() internal view returns (uint256 minted) {
+        uint256 bucket = now / MINT_BUCKET;
+        for (uint256 i = 0; i <= MINT_WINDOW / MINT_BUCKET; i++) {
+            minted = minted.add(mintedInBucket[bucket - i]);
+        }
+    }
+
+    /// Change the most that a single flash loan may mint. Zero turns flash loans off.
+    function changeFlashMintCap(uint256 newFlashMintCap) external onlyRole(ADMIN_ROLE) {
+        flashMintCap = newFlashMintCap;
+        emit FlashMintCapChanged(newFlashMintCap);
+    }
+
+    /// Change the fee for a flash loan, in BPS of the amount lent.
+    function changeFlashMintFee(uint256 newFlashMintFee) external onlyRole(ADMIN_ROLE) {
+        require(newFlashMintFee <= MAX_FLASH_MINT_FEE, "max flash mint fee 10%");
+        flashMintFee = newFlashMintFee;
+        emit FlashMintFeeChanged(newFlashMintFee);
+    }
+
+    /// Change the chain ID that permits and authorizations are signed for, recomputing
+    /// `DOMAIN_SEPARATOR`.
+    /// The EVM version this contract targets has no CHAINID opcode, so the chain ID is set here:
+    /// once after deployment, and again on each side of a fork that changes it, which voids the
+    /// signatures made for the other side.
+    function changeChainId(uint256 newChainId) external onlyRole(ADMIN_ROLE) {
+        chainId = newChainId;
+        DOMAIN_SEPARATOR = keccak256(abi.encode(
+            DOMAIN_TYPEHASH,
+            keccak256(bytes(name)),
+            keccak256(bytes(version)),
+            newChainId,
+            address(this)
+        ));
+        emit ChainIdChanged(newChainId);
+    }
+
+    /// Send `amount` of `token`, sent to this contract by mistake, to `to`. This contract holds
+    /// no tokens of its own, so any token may be swept, including RSV, which moves like any
+    /// other transfer.
+    function sweep(address token, uint256 amount, address to) external onlyRole(ADMIN_ROLE) {
+        if (token == address(this)) {
+            _transfer(address(this), to, amount);
+        } else {
+            require(to != address(0), "can't transfer to address zero");
+            IERC20(token).safeTransfer(to, amount);
+        }
+        emit TokenSwept(token, to, amount);
+    }
+
+    /// Pause the contract, as the `pauser`, or as the `guardian`, whose key is kept at hand for
+    /// incident response.
+    /// #if_succeeds {:msg "pausing leaves the contract paused"} paused;
+    function pause() external {
+        require(
+            hasRole(PAUSER_ROLE, msg.sender) || hasRole(GUARDIAN_ROLE, msg.sender),
+            "unauthorized: not pauser or guardian"
+        );
+        if (!paused) {
+            pausedAt = now;
+        }
         paused = true;
-        emit Paused(pauser);
+        emit Paused(msg.sender);
     }
 
-    /// Unpause the contract.
-    function unpause() external only(pauser) {
+    /// Unpause the contract. Only the `pauser` can; the guardian can't undo what it stops. This
+    /// ends emergency redemption.
+    function unpause() external onlyRole(PAUSER_ROLE) {
         paused = false;
+        emergencyRedemptionStarted = false;
         emit Unpaused(pauser);
     }
 
+    /// Pause transfers between holders, as the `pauser` or the `guardian`, but not minting or
+    /// burning. Unlike `pause`, this starts no clock toward emergency redemption, since holders
+    /// can still redeem.
+    function pauseTransfers() external {
+        require(
+            hasRole(PAUSER_ROLE, msg.sender) || hasRole(GUARDIAN_ROLE, msg.sender),
+            "unauthorized: not pauser or guardian"
+        );
+        transfersPaused = true;
+        emit TransfersPaused(msg.sender);
+    }
+
+    /// Unpause transfers. As with `unpause`, only the `pauser` can.
+    function unpauseTransfers() external onlyRole(PAUSER_ROLE) {
+        transfersPaused = false;
+        emit TransfersUnpaused(msg.sender);
+    }
+
+    /// Open emergency redemption now, rather than EMERGENCY_REDEMPTION_DELAY into the pause.
+    function startEmergencyRedemption() external onlyRole(ADMIN_ROLE) isPaused {
+        emergencyRedemptionStarted = true;
+        emit EmergencyRedemptionStarted(msg.sender);
+    }
+
+    /// Whether holders may redeem against the Vault pro-rata, through the minter's
+    /// `emergencyBurnFrom`: only while paused, and once the pause has lasted
+    /// EMERGENCY_REDEMPTION_DELAY or the admin has started emergency redemption.
+    function emergencyRedemptionOpen() public view returns (bool) {
+        return paused && (emergencyRedemptionStarted || now >= pausedAt.add(EMERGENCY_REDEMPTION_DELAY));
+    }
+
+    /// Freeze `account`, so that it can neither send nor receive tokens. Works while paused, so
+    /// that accounts can be frozen in an emergency before the contract is unpaused.
+    function freeze(address account) external onlyRole(FREEZER_ROLE) {
+        frozen[account] = true;
+        emit AddressFrozen(account);
+    }
+
+    /// Unfreeze `account`. This cancels any wipe of it that is proposed.
+    function unfreeze(address account) external onlyRole(FREEZER_ROLE) {
+        frozen[account] = false;
+        emit AddressUnfrozen(account);
+        if (wipeReadyAt[account] != 0) {
+            delete wipeReadyAt[account];
+            emit WipeCanceled(account);
+        }
+    }
+
+    /// Propose to wipe the frozen `account`, which `wipe` can do once `WIPE_DELAY` has passed.
+    /// The delay gives the account holder and the owner time to contest it.
+    function proposeWipe(address account) external onlyRole(WIPER_ROLE) {
+        require(frozen[account], "account is not frozen");
+        require(wipeReadyAt[account] == 0, "wipe already proposed");
+        wipeReadyAt[account] = now.add(WIPE_DELAY);
+        emit WipeProposed(account, wipeReadyAt[account]);
+    }
+
+    /// Cancel the proposed wipe of `account`.
+    function cancelWipe(address account) external onlyAdminOr(WIPER_ROLE) {
+        require(wipeReadyAt[account] != 0, "no wipe proposed");
+        delete wipeReadyAt[account];
+        emit WipeCanceled(account);
+    }
+
+    /// Burn the whole balance of the frozen `account`, as required by law enforcement, once its
+    /// proposed wipe is ready. Works while paused, as freezing does.
+    function wipe(address account) external onlyRole(WIPER_ROLE) {
+        require(frozen[account], "account is not frozen");
+        require(wipeReadyAt[account] != 0, "no wipe proposed");
+        require(now >= wipeReadyAt[account], "wipe is not yet ready");
+        delete wipeReadyAt[account];
+
+        uint256 value = trustedData.balance(account);
+        _burn(account, value);
+        emit FrozenBalanceWiped(account, value, msg.sender);
+    }
+
+    /// Modifies a function to run only when `account` is not frozen.
+    modifier notFrozen(address account) {
+        require(!frozen[account], "account is frozen");
+        _;
+    }
+
     /// Modifies a function to run only when the contract is paused.
     modifier isPaused() {
         require(paused, "contract is not paused");
@@ -173,6 +678,12 @@ contract Reserve is IERC20, Ownable {
         _;
     }
 
+    /// Modifies a function to run only when no flash loan is out.
+    modifier notFlashMinting() {
+        require(!flashMinting, "not during a flash mint");
+        _;
+    }
+
 
     // ==== Token transfers, allowances, minting, and burning ====
 
@@ -187,18 +698,34 @@ contract Reserve is IERC20, Ownable {
         return trustedData.allowed(holder, spender);
     }
 
-    /// Transfer `value` attoRSV from `msg.sender` to `to`.
+    /// Transfer `value` attoRSV from the sender to `to`.
     function transfer(address to, uint256 value)
         external
         notPaused
         returns (bool)
     {
-        _transfer(msg.sender, to, value);
+        _transfer(_tokenSender(), to, value);
+        return true;
+    }
+
+    /// Transfer `amounts[i]` attotokens from the sender to `recipients[i]`, for each `i`, in one
+    /// transaction, such as for a distribution. It is all or nothing: if any one transfer would
+    /// fail, none of them are made.
+    function transferBatch(address[] calldata recipients, uint256[] calldata amounts)
+        external
+        notPaused
+        returns (bool)
+    {
+        require(recipients.length == amounts.length, "recipients and amounts differ in length");
+        address from = _tokenSender();
+        for (uint256 i = 0; i < recipients.length; i++) {
+            _transfer(from, recipients[i], amounts[i]);
+        }
         return true;
     }
 
     /**
-     * Approve `spender` to spend `value` attotokens on behalf of `msg.sender`.
+     * Approve `spender` to spend `value` attotokens on behalf of the sender.
      *
      * Beware that changing a nonzero allowance with this method brings the risk that
      * someone may use both the old and the new allowance by unfortunate transaction ordering. One
@@ -216,7 +743,7 @@ contract Reserve is IERC20, Ownable {
         notPaused
         returns (bool)
     {
-        _approve(msg.sender, spender, value);
+        _approve(_tokenSender(), spender, value);
         return true;
     }
 
@@ -229,8 +756,7 @@ contract Reserve is IERC20, Ownable {
         notPaused
         returns (bool)
     {
-        _transfer(from, to, value);
-        _approve(from, msg.sender, trustedData.allowed(from, msg.sender).sub(value));
+        _transferFrom(_tokenSender(), from, to, value);
         return true;
     }
 
@@ -243,7 +769,7 @@ contract Reserve is IERC20, Ownable {
         notPaused
         returns (bool)
     {
-        _approve(msg.sender, spender, trustedData.allowed(msg.sender, spender).add(addedValue));
+        _approve(_tokenSender(), spender, trustedData.allowed(_tokenSender(), spender).add(addedValue));
         return true;
     }
 
@@ -257,37 +783,417 @@ contract Reserve is IERC20, Ownable {
         returns (bool)
     {
         _approve(
-            msg.sender,
+            _tokenSender(),
             spender,
-            trustedData.allowed(msg.sender, spender).sub(subtractedValue)
+            trustedData.allowed(_tokenSender(), spender).sub(subtractedValue)
+        );
+        return true;
+    }
+
+    /// Transfer `value` attoRSV from the sender to `to`, and then call `to`'s
+    /// `onTransferReceived` hook, per [ERC-1363](https://eips.ethereum.org/EIPS/eip-1363), so
+    /// that a receiving contract can act on the payment in the same transaction. `to` must be a
+    /// contract that accepts the transfer; if its hook reverts, so does the transfer.
+    function transferAndCall(address to, uint256 value) external notPaused returns (bool) {
+        return transferAndCall(to, value, "");
+    }
+
+    /// Like `transferAndCall(to, value)`, passing `data` on to the hook.
+    function transferAndCall(address to, uint256 value, bytes memory data)
+        public
+        notPaused
+        returns (bool)
+    {
+        _transfer(_tokenSender(), to, value);
+        _callOnTransferReceived(_tokenSender(), to, value, data);
+        return true;
+    }
+
+    /// Transfer approved tokens from `from` to `to`, like `transferFrom`, and then call `to`'s
+    /// `onTransferReceived` hook, like `transferAndCall`.
+    function transferFromAndCall(address from, address to, uint256 value)
+        external
+        notPaused
+        returns (bool)
+    {
+        return transferFromAndCall(from, to, value, "");
+    }
+
+    /// Like `transferFromAndCall(from, to, value)`, passing `data` on to the hook.
+    function transferFromAndCall(address from, address to, uint256 value, bytes memory data)
+        public
+        notPaused
+        returns (bool)
+    {
+        _transferFrom(_tokenSender(), from, to, value);
+        _callOnTransferReceived(from, to, value, data);
+        return true;
+    }
+
+    /// Approve `spender` to spend `value` attotokens on behalf of the sender, like `approve`,
+    /// and then call `spender`'s `onApprovalReceived` hook, per ERC-1363, so that it can spend
+    /// them in the same transaction. `spender` must be a contract that accepts the approval.
+    function approveAndCall(address spender, uint256 value) external notPaused returns (bool) {
+        return approveAndCall(spender, value, "");
+    }
+
+    /// Like `approveAndCall(spender, value)`, passing `data` on to the hook.
+    function approveAndCall(address spender, uint256 value, bytes memory data)
+        public
+        notPaused
+        returns (bool)
+    {
+        _approve(_tokenSender(), spender, value);
+        require(_isContract(spender), "spender is not a contract");
+        require(
+            IERC1363Spender(spender).onApprovalReceived(_tokenSender(), value, data)
+                == ERC1363_APPROVED,
+            "spender refused the approval"
         );
         return true;
     }
 
+    /// @return whether the Reserve implements the interface `interfaceId`, per
+    /// [ERC-165](https://eips.ethereum.org/EIPS/eip-165): ERC-165 itself, ERC-20 and its
+    /// metadata, EIP-2612 permits, EIP-2771 forwarding, EIP-3009 authorizations, ERC-1363,
+    /// ERC-1822 `proxiableUUID`, and ERC-3156 flash loans.
+    function supportsInterface(bytes4 interfaceId) external pure returns (bool) {
+        return interfaceId == ERC165_INTERFACE_ID ||
+            interfaceId == ERC20_INTERFACE_ID ||
+            interfaceId == ERC20_METADATA_INTERFACE_ID ||
+            interfaceId == EIP2612_INTERFACE_ID ||
+            interfaceId == EIP2771_INTERFACE_ID ||
+            interfaceId == EIP3009_INTERFACE_ID ||
+            interfaceId == ERC1363_INTERFACE_ID ||
+            interfaceId == ERC1822_INTERFACE_ID ||
+            interfaceId == ERC3156_INTERFACE_ID;
+    }
+
+    /**
+     * Approve `spender` to spend `value` attotokens on behalf of `holder`, given `holder`'s
+     * EIP-712 signature of the approval, per [EIP-2612](https://eips.ethereum.org/EIPS/eip-2612).
+     *
+     * Anyone may submit the signature, until `deadline`; each is good for one use, as it signs
+     * `holder`'s next nonce. Only signatures with `s` in the lower half order are accepted, so
+     * that a signature can't be altered into a second valid one.
+     *
+     * @param holder address The address whose tokens `spender` may spend.
+     * @param spender address The address which will spend the funds.
+     * @param value uint256 How many attotokens to allow `spender` to spend.
+     * @param deadline uint256 The last block timestamp at which the signature is valid.
+     */
+    function permit(
+        address holder,
+        address spender,
+        uint256 value,
+        uint256 deadline,
+        uint8 v,
+        bytes32 r,
+        bytes32 s
+    )
+        external
+        notPaused
+    {
+        require(deadline >= now, "permit expired");
+
+        _requireSignedBy(
+            holder,
+            keccak256(abi.encode(PERMIT_TYPEHASH, holder, spender, value, nonces[holder], deadline)),
+            v, r, s
+        );
+        nonces[holder] = nonces[holder].add(1);
+        _approve(holder, spender, value);
+    }
+
+    /**
+     * Transfer `value` attotokens from `from` to `to`, given `from`'s EIP-712 signature of the
+     * transfer, per [EIP-3009](https://eips.ethereum.org/EIPS/eip-3009).
+     *
+     * Anyone may submit the signature, after `validAfter` and before `validBefore`. Each uses a
+     * `nonce` of the signer's choosing, typically random, which can be used once; unlike permit
+     * nonces, they needn't be used in order.
+     *
+     * Beware that anyone who sees the signature, such as in the transaction pool, can submit it
+     * first; contracts taking a payment should use `receiveWithAuthorization` instead.
+     */
+    function transferWithAuthorization(
+        address from,
+        address to,
+        uint256 value,
+        uint256 validAfter,
+        uint256 validBefore,
+        bytes32 nonce,
+        uint8 v,
+        bytes32 r,
+        bytes32 s
+    )
+        external
+        notPaused
+    {
+        _useAuthorization(
+            from,
+            _authorizationHash(TRANSFER_WITH_AUTHORIZATION_TYPEHASH, from, to, value, validAfter, validBefore, nonce),
+            validAfter, validBefore, nonce, v, r, s
+        );
+        _transfer(from, to, value);
+    }
+
+    /// Like `transferWithAuthorization`, but callable only by the payee, `to`, so that no one
+    /// else can submit the transfer ahead of the payee's own call.
+    function receiveWithAuthorization(
+        address from,
+        address to,
+        uint256 value,
+        uint256 validAfter,
+        uint256 validBefore,
+        bytes32 nonce,
+        uint8 v,
+        bytes32 r,
+        bytes32 s
+    )
+        external
+        notPaused
+    {
+        require(to == _tokenSender(), "caller must be the payee");
+        _useAuthorization(
+            from,
+            _authorizationHash(RECEIVE_WITH_AUTHORIZATION_TYPEHASH, from, to, value, validAfter, validBefore, nonce),
+            validAfter, validBefore, nonce, v, r, s
+        );
+        _transfer(from, to, value);
+    }
+
+    /// Cancel `authorizer`'s unused authorization with `nonce`, given `authorizer`'s EIP-712
+    /// signature of the cancellation.
+    function cancelAuthorization(address authorizer, bytes32 nonce, uint8 v, bytes32 r, bytes32 s)
+        external
+        notPaused
+    {
+        require(!authorizationState[authorizer][nonce], "authorization is used or canceled");
+        _requireSignedBy(
+            authorizer,
+            keccak256(abi.encode(CANCEL_AUTHORIZATION_TYPEHASH, authorizer, nonce)),
+            v, r, s
+        );
+        authorizationState[authorizer][nonce] = true;
+        emit AuthorizationCanceled(authorizer, nonce);
+    }
+
     /// Mint `value` new attotokens to `account`.
+    /// #if_succeeds {:msg "minting adds value to the supply"}
+    ///     totalSupply == old(totalSupply) + value;
+    /// #if_succeeds {:msg "minting stays within the max supply"} totalSupply <= maxSupply;
     function mint(address account, uint256 value)
         external
         notPaused
-        only(minter)
+        notFlashMinting
+        onlyRole(MINTER_ROLE)
+        notFrozen(account)
     {
         require(account != address(0), "can't mint to address zero");
 
+        // Mints are counted even while the cap is unlimited, so that a cap set later counts
+        // them, but only checked against a cap.
+        uint256 bucket = now / MINT_BUCKET;
+        mintedInBucket[bucket] = mintedInBucket[bucket].add(value);
+        if (mintCap != 2 ** 256 - 1) {
+            require(_mintedInWindow() <= mintCap, "mint cap exceeded");
+        }
+
+        _updateAccountSnapshot(account);
+        _updateTotalSupplySnapshot();
         totalSupply = totalSupply.add(value);
-        require(totalSupply < maxSupply, "max supply exceeded");
+        require(totalSupply <= maxSupply, "max supply exceeded");
         trustedData.addBalance(account, value);
         emit Transfer(address(0), account, value);
     }
 
     /// Burn `value` attotokens from `account`, if sender has that much allowance from `account`.
+    /// #if_succeeds {:msg "burning takes value from the supply"}
+    ///     totalSupply == old(totalSupply) - value;
     function burnFrom(address account, uint256 value)
         external
         notPaused
-        only(minter)
+        notFlashMinting
+        onlyRole(MINTER_ROLE)
+        notFrozen(account)
     {
         _burn(account, value);
         _approve(account, msg.sender, trustedData.allowed(account, msg.sender).sub(value));
     }
 
+    /// Burn `value` attotokens from `account`, as burnFrom does, for an emergency redemption,
+    /// which is while paused.
+    /// #if_succeeds {:msg "burning takes value from the supply"}
+    ///     totalSupply == old(totalSupply) - value;
+    function emergencyBurnFrom(address account, uint256 value)
+        external
+        onlyRole(MINTER_ROLE)
+        notFrozen(account)
+    {
+        require(emergencyRedemptionOpen(), "emergency redemption not open");
+        _burn(account, value);
+        _approve(account, msg.sender, trustedData.allowed(account, msg.sender).sub(value));
+    }
+
+    // ==== Flash mints ==== //
+
+    /// @return the most attotokens of `token` that a flash loan can lend: none unless `token`
+    /// is RSV and flash loans can be made now, and otherwise `flashMintCap`, or as many as
+    /// `maxSupply` leaves room for, if that is fewer.
+    function maxFlashLoan(address token) external view returns (uint256) {
+        if (token != address(this) || paused || flashMinting || totalSupply >= maxSupply) {
+            return 0;
+        }
+        uint256 room = maxSupply - totalSupply;
+        return room < flashMintCap ? room : flashMintCap;
+    }
+
+    /// @return the fee, in attotokens, for a flash loan of `amount` attotokens of `token`,
+    /// which must be RSV.
+    function flashFee(address token, uint256 amount) external view returns (uint256) {
+        require(token == address(this), "flash loans are only of RSV");
+        return _flashFee(amount);
+    }
+
+    /**
+     * Mint `amount` attotokens to `receiver`, call its `onFlashLoan`, and then burn them again
+     * and pay the fee to the fee recipient, both out of the allowance that `receiver` has given
+     * this contract by then, per [ERC-3156](https://eips.ethereum.org/EIPS/eip-3156).
+     *
+     * The loan doesn't count against the mint cap, since it is gone by the end of the
+     * transaction. Until it is repaid, neither `mint` nor `burnFrom` works, so that the Manager
+     * can't issue against the loan or redeem it for collateral, and loans don't nest.
+     */
+    function flashLoan(
+        IERC3156FlashBorrower receiver,
+        address token,
+        uint256 amount,
+        bytes calldata data
+    )
+        external
+        notPaused
+        notFlashMinting
+        returns (bool)
+    {
+        require(token == address(this), "flash loans are only of RSV");
+        require(amount <= flashMintCap, "flash mint cap exceeded");
+        address borrower = address(receiver);
+        require(borrower != address(0), "can't mint to address zero");
+        require(!frozen[borrower], "receiver is frozen");
+        uint256 fee = _flashFee(amount);
+
+        flashMinting = true;
+        _updateAccountSnapshot(borrower);
+        _updateTotalSupplySnapshot();
+        totalSupply = totalSupply.add(amount);
+        require(totalSupply <= maxSupply, "max supply exceeded");
+        trustedData.addBalance(borrower, amount);
+        emit Transfer(address(0), borrower, amount);
+
+        require(
+            receiver.onFlashLoan(msg.sender, token, amount, fee, data) == ERC3156_CALLBACK_SUCCESS,
+            "receiver refused the flash loan"
+        );
+
+        _approve(
+            borrower,
+            address(this),
+            trustedData.allowed(borrower, address(this)).sub(amount.add(fee))
+        );
+        _burn(borrower, amount);
+        if (fee > 0) {
+            _updateAccountSnapshot(feeRecipient);
+            trustedData.subBalance(borrower, fee);
+            trustedData.addBalance(feeRecipient, fee);
+            emit Transfer(borrower, feeRecipient, fee);
+        }
+        flashMinting = false;
+        emit FlashMinted(borrower, msg.sender, amount, fee);
+        return true;
+    }
+
+    /// @dev The fee for a flash loan of `amount` attotokens, rounded down.
+    function _flashFee(uint256 amount) internal view returns (uint256) {
+        return amount.mul(flashMintFee).div(BPS_FACTOR);
+        // unit check: qRSV == qRSV * BPS / BPS
+    }
+
+    // ==== Snapshots ==== //
+
+    /// Take a snapshot of every balance and the total supply, for `balanceOfAt` and
+    /// `totalSupplyAt` to read at the returned ID. IDs start at 1. Not during a flash mint, whose
+    /// loan would be in it.
+    function snapshot() external onlyRole(SNAPSHOTTER_ROLE) notFlashMinting returns (uint256) {
+        currentSnapshotId = currentSnapshotId.add(1);
+        emit Snapshot(currentSnapshotId);
+        return currentSnapshotId;
+    }
+
+    /// @return how many attoRSV `holder` had at snapshot `snapshotId`.
+    function balanceOfAt(address holder, uint256 snapshotId) external view returns (uint256) {
+        (bool snapshotted, uint256 value) = _valueAt(snapshotId, accountSnapshots[holder]);
+        return snapshotted ? value : trustedData.balance(holder);
+    }
+
+    /// @return the total supply at snapshot `snapshotId`.
+    function totalSupplyAt(uint256 snapshotId) external view returns (uint256) {
+        (bool snapshotted, uint256 value) = _valueAt(snapshotId, totalSupplySnapshots);
+        return snapshotted ? value : totalSupply;
+    }
+
+    /// @dev The value at snapshot `snapshotId` that `snapshots` recorded, if it recorded one; if
+    /// not, the value hasn't changed since, and is the current one.
+    function _valueAt(uint256 snapshotId, Snapshots storage snapshots)
+        internal
+        view
+        returns (bool, uint256)
+    {
+        require(snapshotId > 0, "snapshot id is 0");
+        require(snapshotId <= currentSnapshotId, "nonexistent snapshot id");
+
+        // The first recorded ID at or after snapshotId, by binary search: the value it recorded
+        // was the value since before snapshotId.
+        uint256[] storage ids = snapshots.ids;
+        uint256 low = 0;
+        uint256 high = ids.length;
+        while (low < high) {
+            uint256 mid = (low + high) / 2;
+            if (ids[mid] < snapshotId) {
+                low = mid + 1;
+            } else {
+                high = mid;
+            }
+        }
+        if (low == ids.length) {
+            return (false, 0);
+        }
+        return (true, snapshots.values[low]);
+    }
+
+    /// @dev Record `account`'s balance for the current snapshot, before it changes.
+    function _updateAccountSnapshot(address account) internal {
+        if (currentSnapshotId != 0) {
+            _updateSnapshot(accountSnapshots[account], trustedData.balance(account));
+        }
+    }
+
+    /// @dev Record the total supply for the current snapshot, before it changes.
+    function _updateTotalSupplySnapshot() internal {
+        if (currentSnapshotId != 0) {
+            _updateSnapshot(totalSupplySnapshots, totalSupply);
+        }
+    }
+
+    /// @dev Record `currentValue` in `snapshots` for the current snapshot, unless it has been.
+    function _updateSnapshot(Snapshots storage snapshots, uint256 currentValue) internal {
+        uint256 length = snapshots.ids.length;
+        if (length == 0 || snapshots.ids[length - 1] < currentSnapshotId) {
+            snapshots.ids.push(currentSnapshotId);
+            snapshots.values.push(currentValue);
+        }
+    }
+
     // ==== Relay functions === //
     
     /// Transfer `value` attotokens from `from` to `to`.
@@ -323,15 +1229,98 @@ contract Reserve is IERC20, Ownable {
         only(trustedRelayer)
         returns (bool)
     {
+        require(!frozen[spender], "spender is frozen");
         _transfer(holder, to, value);
         _approve(holder, spender, trustedData.allowed(holder, spender).sub(value));
         return true;
     }
 
+    /// @return whether `forwarder` is the EIP-2771 trusted forwarder.
+    function isTrustedForwarder(address forwarder) public view returns (bool) {
+        return forwarder != address(0) && forwarder == trustedForwarder;
+    }
+
+    /// @dev The account that token operations are made for: `msg.sender`, unless it is the
+    /// trusted forwarder, which appends the address of the account it forwards for to the
+    /// calldata, per EIP-2771.
+    /// Roles are only ever exercised by `msg.sender` itself, so the forwarder can't act as the
+    /// owner, minter, or pauser. That is why this doesn't override `Context._msgSender`, which
+    /// `Ownable` checks the owner against.
+    function _tokenSender() internal view returns (address sender) {
+        if (msg.data.length >= 20 && isTrustedForwarder(msg.sender)) {
+            // solium-disable-next-line security/no-inline-assembly
+            assembly {
+                sender := shr(96, calldataload(sub(calldatasize(), 20)))
+            }
+            return sender;
+        }
+        return msg.sender;
+    }
+
+    /// @dev The EIP-712 struct hash of a transfer or receive authorization.
+    function _authorizationHash(
+        bytes32 typeHash,
+        address from,
+        address to,
+        uint256 value,
+        uint256 validAfter,
+        uint256 validBefore,
+        bytes32 nonce
+    )
+        internal
+        pure
+        returns (bytes32)
+    {
+        return keccak256(abi.encode(typeHash, from, to, value, validAfter, validBefore, nonce));
+    }
+
+    /// @dev Check and use up `authorizer`'s authorization with `nonce` and struct hash `hash`.
+    function _useAuthorization(
+        address authorizer,
+        bytes32 hash,
+        uint256 validAfter,
+        uint256 validBefore,
+        bytes32 nonce,
+        uint8 v,
+        bytes32 r,
+        bytes32 s
+    )
+        internal
+    {
+        require(now > validAfter, "authorization is not yet valid");
+        require(now < validBefore, "authorization expired");
+        require(!authorizationState[authorizer][nonce], "authorization is used or canceled");
+        _requireSignedBy(authorizer, hash, v, r, s);
+
+        authorizationState[authorizer][nonce] = true;
+        emit AuthorizationUsed(authorizer, nonce);
+    }
+
+    /// @dev Require that the EIP-712 message with struct hash `hash` is signed by `signer`.
+    /// Signatures are only checked once the owner has set the chain ID.
+    function _requireSignedBy(address signer, bytes32 hash, uint8 v, bytes32 r, bytes32 s)
+        internal
+        view
+    {
+        require(chainId != 0, "signatures are not enabled");
+        bytes32 digest = keccak256(abi.encodePacked("\x19\x01", DOMAIN_SEPARATOR, hash));
+        address recovered = ECDSA.recover(digest, v, r, s);
+        require(recovered != address(0) && recovered == signer, "invalid signature");
+    }
+
     /// @dev Transfer of `value` attotokens from `from` to `to`.
-    /// Internal; doesn't check permissions.
+    /// Internal; doesn't check permissions, but does check that transfers aren't paused, that
+    /// neither account is frozen, and that the transfer hook, if any, allows it.
     function _transfer(address from, address to, uint256 value) internal {
+        require(!transfersPaused, "transfers are paused");
         require(to != address(0), "can't transfer to address zero");
+        require(!frozen[from], "sender is frozen");
+        require(!frozen[to], "recipient is frozen");
+        if (address(transferHook) != address(0)) {
+            require(transferHook.checkTransfer(from, to, value), "transfer refused by hook");
+        }
+        _updateAccountSnapshot(from);
+        _updateAccountSnapshot(to);
         trustedData.subBalance(from, value);
         uint256 fee = 0;
 
@@ -339,6 +1328,7 @@ contract Reserve is IERC20, Ownable {
             fee = trustedTxFee.calculateFee(from, to, value);
             require(fee <= value, "transaction fee out of bounds");
 
+            _updateAccountSnapshot(feeRecipient);
             trustedData.addBalance(feeRecipient, fee);
             emit Transfer(from, feeRecipient, fee);
         }
@@ -347,11 +1337,45 @@ contract Reserve is IERC20, Ownable {
         emit Transfer(from, to, value.sub(fee));
     }
 
+    /// @dev Transfer of `value` of `from`'s attotokens to `to` by `spender`, out of its allowance.
+    /// Internal; checks that `spender` isn't frozen, and `_transfer`'s checks.
+    function _transferFrom(address spender, address from, address to, uint256 value) internal {
+        require(!frozen[spender], "spender is frozen");
+        _transfer(from, to, value);
+        _approve(from, spender, trustedData.allowed(from, spender).sub(value));
+    }
+
+    /// @dev Call the ERC-1363 hook of `to`, the recipient of a transfer of `value` attotokens
+    /// from `from`, made by the sender; `value` is what was sent, before any transaction fee.
+    /// Reverts unless `to` is a contract that accepts it.
+    function _callOnTransferReceived(address from, address to, uint256 value, bytes memory data)
+        internal
+    {
+        require(_isContract(to), "recipient is not a contract");
+        require(
+            IERC1363Receiver(to).onTransferReceived(_tokenSender(), from, value, data)
+                == ERC1363_RECEIVED,
+            "recipient refused the transfer"
+        );
+    }
+
+    /// @dev Whether there is code at `account`. A contract under construction has none yet.
+    function _isContract(address account) internal view returns (bool) {
+        uint256 size;
+        // solium-disable-next-line security/no-inline-assembly
+        assembly {
+            size := extcodesize(account)
+        }
+        return size > 0;
+    }
+
     /// @dev Burn `value` attotokens from `account`.
     /// Internal; doesn't check permissions.
     function _burn(address account, uint256 value) internal {
         require(account != address(0), "can't burn from address zero");
 
+        _updateAccountSnapshot(account);
+        _updateTotalSupplySnapshot();
         totalSupply = totalSupply.sub(value);
         trustedData.subBalance(account, value);
         emit Transfer(account, address(0), value);
@@ -369,8 +1393,64 @@ contract Reserve is IERC20, Ownable {
 
 // ===========================  Upgradeability   =====================================
 
+    /// Switch this Reserve, behind a ReserveProxy, to the implementation at
+    /// `newImplementation`, per [EIP-1822](https://eips.ethereum.org/EIPS/eip-1822) (UUPS).
+    /// Balances and allowances stay in eternal storage, and roles, frozen accounts, nonces, and
+    /// every other field stay in the proxy, so nothing has to be carried over, and the token
+    /// keeps its address. `newImplementation` must be an upgradeable Reserve too, so that an
+    /// upgrade can't strand the proxy without a way to upgrade again.
+    function upgradeTo(address newImplementation) external onlyRole(ADMIN_ROLE) {
+        _upgradeTo(newImplementation);
+    }
+
+    /// Like `upgradeTo`, then call the new implementation with `data`, such as to set up the
+    /// fields it adds, in the same transaction.
+    function upgradeToAndCall(address newImplementation, bytes calldata data)
+        external
+        onlyRole(ADMIN_ROLE)
+    {
+        _upgradeTo(newImplementation);
+        // solium-disable-next-line security/no-low-level-calls
+        (bool success, ) = newImplementation.delegatecall(data);
+        require(success, "upgrade call failed");
+    }
+
+    /// @return the implementation behind this Reserve's proxy, or the zero address if this
+    /// Reserve isn't behind one.
+    function implementation() public view returns (address impl) {
+        bytes32 slot = IMPLEMENTATION_SLOT;
+        // solium-disable-next-line security/no-inline-assembly
+        assembly {
+            impl := sload(slot)
+        }
+    }
+
+    /// @return the slot that UUPS proxies keep their implementation in, which they check that a
+    /// new implementation reports. Only an implementation reports it, not a proxy, so that a
+    /// proxy can't be made the implementation of another.
+    function proxiableUUID() external view returns (bytes32) {
+        require(implementation() == address(0), "called through a proxy");
+        return IMPLEMENTATION_SLOT;
+    }
+
+    /// @dev Point the proxy at `newImplementation`.
+    function _upgradeTo(address newImplementation) internal {
+        require(implementation() != address(0), "not behind a proxy");
+        require(
+            Reserve(newImplementation).proxiableUUID() == IMPLEMENTATION_SLOT,
+            "new implementation is not upgradeable"
+        );
+        bytes32 slot = IMPLEMENTATION_SLOT;
+        // solium-disable-next-line security/no-inline-assembly
+        assembly {
+            sstore(slot, newImplementation)
+        }
+        emit Upgraded(newImplementation);
+    }
+
+
     /// Accept upgrade from previous RSV instance. Can only be called once. 
-    function acceptUpgrade(address previousImplementation) external onlyOwner {
+    function acceptUpgrade(address previousImplementation) external onlyRole(ADMIN_ROLE) {
         require(address(trustedData) == address(0), "can only be run once");
         Reserve previous = Reserve(previousImplementation);
         trustedData = ReserveEternalStorage(previous.getEternalStorageAddress());
@@ -378,10 +1458,12 @@ contract Reserve is IERC20, Ownable {
         // Copy values from old contract
         totalSupply = previous.totalSupply();
         maxSupply = previous.maxSupply();
+        maxSupplySet = _maxSupplySet(previousImplementation);
         emit MaxSupplyChanged(maxSupply);
         
         // Unpause.
         paused = false;
+        emergencyRedemptionStarted = false;
         emit Unpaused(pauser);
 
         previous.acceptOwnership();
@@ -396,4 +1478,14 @@ contract Reserve is IERC20, Ownable {
         previous.changePauser(address(0));
         previous.renounceOwnership("I hereby renounce ownership of this contract forever.");
     }
+
+    /// Whether `previousImplementation` had its max supply set. A Reserve from before
+    /// `maxSupplySet` never held its max supply to only rising, so it counts as unset.
+    function _maxSupplySet(address previousImplementation) internal view returns (bool) {
+        // solium-disable-next-line security/no-low-level-calls
+        (bool success, bytes memory result) = previousImplementation.staticcall(
+            abi.encodeWithSignature("maxSupplySet()")
+        );
+        return success && result.length == 32 && abi.decode(result, (bool));
+    }
 }
@@ -0,0 +1,61 @@
+pragma solidity 0.5.7;
+
+/// The part of a UUPS implementation that the proxy checks.
+interface IProxiable {
+    function proxiableUUID() external view returns (bytes32);
+}
+
+/**
+ * @title A proxy for the Reserve Token
+ * @dev An [ERC-1967](https://eips.ethereum.org/EIPS/eip-1967) proxy that delegates every call to
+ * its implementation, a Reserve, which holds the logic to upgrade it (see `Reserve.upgradeTo`),
+ * per EIP-1822 (UUPS). The proxy has no functions of its own, so none can clash with the
+ * Reserve's.
+ *
+ * Deploy it with the implementation's address and the calldata of `initialize()`, which runs in
+ * the proxy's storage and makes the deployer the Reserve's owner; the constructor refuses empty
+ * calldata, so the proxy is never left for someone else to initialize. Then, to carry balances
+ * over from a Reserve deployed on its own, have that Reserve nominate the proxy as its owner and
+ * call `acceptUpgrade` on the proxy, as for any other replacement.
+ */
+contract ReserveProxy {
+
+    // bytes32(uint256(keccak256("eip1967.proxy.implementation")) - 1)
+    bytes32 internal constant IMPLEMENTATION_SLOT =
+        0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc;
+
+    event Upgraded(address indexed implementation);
+
+    constructor(address implementation, bytes memory data) public {
+        require(data.length > 0, "initialization required");
+        require(
+            IProxiable(implementation).proxiableUUID() == IMPLEMENTATION_SLOT,
+            "implementation is not upgradeable"
+        );
+        bytes32 slot = IMPLEMENTATION_SLOT;
+        // solium-disable-next-line security/no-inline-assembly
+        assembly {
+            sstore(slot, implementation)
+        }
+        emit Upgraded(implementation);
+
+        // solium-disable-next-line security/no-low-level-calls
+        (bool success, ) = implementation.delegatecall(data);
+        require(success, "initialization failed");
+    }
+
+    /// Delegate the call to the implementation, returning or reverting with whatever it does.
+    function () external payable {
+        bytes32 slot = IMPLEMENTATION_SLOT;
+        // solium-disable-next-line security/no-inline-assembly
+        assembly {
+            let impl := sload(slot)
+            calldatacopy(0, 0, calldatasize())
+            let result := delegatecall(gas(), impl, 0, calldatasize(), 0, 0)
+            returndatacopy(0, 0, returndatasize())
+            switch result
+            case 0 { revert(0, returndatasize()) }
+            default { return(0, returndatasize()) }
+        }
+    }
+}
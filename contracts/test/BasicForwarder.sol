@@ -0,0 +1,26 @@
+pragma solidity 0.5.7;
+
+import "../zeppelin/utils/ECDSA.sol";
+
+/**
+ * Simple EIP-2771 forwarder for testing. It makes the calls that `from` signs, appending `from`
+ * to the calldata, as trusted forwarders do.
+ */
+contract BasicForwarder {
+
+    mapping(address => uint256) public nonces;
+
+    function execute(address from, address to, bytes calldata data, bytes calldata sig)
+        external
+        returns (bytes memory)
+    {
+        bytes32 hash = keccak256(abi.encodePacked(address(this), from, to, data, nonces[from]));
+        require(ECDSA.recover(ECDSA.toEthSignedMessageHash(hash), sig) == from, "invalid signature");
+        nonces[from]++;
+
+        // solium-disable-next-line security/no-low-level-calls
+        (bool success, bytes memory result) = to.call(abi.encodePacked(data, from));
+        require(success, "forwarded call failed");
+        return result;
+    }
+}
@@ -0,0 +1,47 @@
+pragma solidity 0.5.7;
+
+
+/**
+ * Chainlink aggregator for testing, whose rounds are set by hand.
+ */
+contract MockAggregator {
+
+    uint8 public decimals;
+    uint80 currentRound;
+    int256 currentAnswer;
+    uint256 currentUpdatedAt;
+    uint80 currentAnsweredInRound;
+
+    constructor(uint8 _decimals) public {
+        decimals = _decimals;
+    }
+
+    /// Starts a new round, answered with `answer` at `updatedAt`.
+    function setAnswer(int256 answer, uint256 updatedAt) external {
+        currentRound++;
+        currentAnswer = answer;
+        currentUpdatedAt = updatedAt;
+        currentAnsweredInRound = currentRound;
+    }
+
+    /// Starts a new round that carries over the last answer, as a round still in progress does.
+    function startRound() external {
+        currentRound++;
+    }
+
+    function latestRoundData() external view returns (
+        uint80 roundId,
+        int256 answer,
+        uint256 startedAt,
+        uint256 updatedAt,
+        uint80 answeredInRound
+    ) {
+        return (
+            currentRound,
+            currentAnswer,
+            currentUpdatedAt,
+            currentUpdatedAt,
+            currentAnsweredInRound
+        );
+    }
+}
@@ -0,0 +1,21 @@
+pragma solidity 0.5.7;
+
+import "../zeppelin/token/ERC20/ERC20.sol";
+
+/**
+ * An interest-bearing wrapper for testing, in the image of a Compound cToken, whose exchange
+ * rate is set by hand.
+ */
+contract MockCToken is ERC20 {
+    uint256 public exchangeRateStored;
+
+    constructor(uint256 _exchangeRate) public {
+        exchangeRateStored = _exchangeRate;
+        _mint(msg.sender, 1e48);
+    }
+
+    /// Sets the exchange rate, in qUnderlying per qToken, scaled by 10**18.
+    function setExchangeRate(uint256 _exchangeRate) external {
+        exchangeRateStored = _exchangeRate;
+    }
+}
@@ -0,0 +1,41 @@
+pragma solidity 0.5.7;
+
+import "../zeppelin/token/ERC20/IERC20.sol";
+import "../rsv/IERC1363.sol";
+
+/**
+ * An ERC-1363 receiver and spender for testing. It records each call to its hooks, along with
+ * what it then holds of, or may spend of, the calling token, and answers with `retval`, or
+ * reverts if `reverts` is set.
+ */
+contract MockERC1363Receiver is IERC1363Receiver, IERC1363Spender {
+
+    bytes4 public retval;
+    bool public reverts;
+
+    event Received(address operator, address from, uint256 value, bytes data, uint256 balance);
+    event Approved(address owner, uint256 value, bytes data, uint256 allowance);
+
+    constructor(bytes4 _retval, bool _reverts) public {
+        retval = _retval;
+        reverts = _reverts;
+    }
+
+    function onTransferReceived(address operator, address from, uint256 value, bytes calldata data)
+        external
+        returns (bytes4)
+    {
+        require(!reverts, "receiver reverted");
+        emit Received(operator, from, value, data, IERC20(msg.sender).balanceOf(address(this)));
+        return retval;
+    }
+
+    function onApprovalReceived(address owner, uint256 value, bytes calldata data)
+        external
+        returns (bytes4)
+    {
+        require(!reverts, "receiver reverted");
+        emit Approved(owner, value, data, IERC20(msg.sender).allowance(owner, address(this)));
+        return retval;
+    }
+}
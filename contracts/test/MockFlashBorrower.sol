@@ -0,0 +1,101 @@
+pragma solidity 0.5.7;
+
+import "../zeppelin/token/ERC20/IERC20.sol";
+import "../zeppelin/math/SafeMath.sol";
+import "../rsv/IERC3156.sol";
+
+/// The part of the Manager that the MockFlashBorrower calls.
+interface IIssuer {
+    function issue(uint256 rsvAmount) external;
+    function redeem(uint256 rsvAmount) external;
+}
+
+/// The minter's part of the Reserve, for a borrower that is also its minter.
+interface IMintable {
+    function mint(address account, uint256 value) external;
+    function burnFrom(address account, uint256 value) external;
+}
+
+/**
+ * An ERC-3156 flash borrower for testing. `borrow` takes out a loan from `lender`, and what
+ * `onFlashLoan` then does with it is the `action` passed along as the loan's data. It records
+ * each loan, along with what it holds of the token during it.
+ */
+contract MockFlashBorrower is IERC3156FlashBorrower {
+    using SafeMath for uint256;
+
+    // Actions for a loan.
+    uint8 public constant REPAY = 0; // approve the lender for the amount and fee
+    uint8 public constant REPAY_AMOUNT_ONLY = 1; // approve the lender for the amount
+    uint8 public constant REFUSE = 2; // return the wrong value
+    uint8 public constant ISSUE = 3; // issue the amount through the Manager, then repay
+    uint8 public constant REDEEM = 4; // redeem the amount through the Manager, then repay
+    uint8 public constant BORROW_AGAIN = 5; // take out a second loan of the amount, then repay
+    uint8 public constant MINT = 6; // mint the amount, as minter, then repay
+    uint8 public constant BURN = 7; // burn the amount, as minter, then repay
+
+    IERC3156FlashLender public lender;
+    IIssuer public manager;
+
+    event Borrowed(address initiator, address token, uint256 amount, uint256 fee, uint256 balance);
+
+    constructor(address _lender, address _manager) public {
+        lender = IERC3156FlashLender(_lender);
+        manager = IIssuer(_manager);
+    }
+
+    /// Borrow `amount` of the lender's own token, doing `action` with the loan.
+    function borrow(uint256 amount, uint8 action) external {
+        lender.flashLoan(this, address(lender), amount, abi.encode(action));
+    }
+
+    function onFlashLoan(
+        address initiator,
+        address token,
+        uint256 amount,
+        uint256 fee,
+        bytes calldata data
+    )
+        external
+        returns (bytes32)
+    {
+        require(msg.sender == address(lender), "untrusted lender");
+        uint8 action = abi.decode(data, (uint8));
+        emit Borrowed(initiator, token, amount, fee, IERC20(token).balanceOf(address(this)));
+
+        if (action == REFUSE) {
+            return bytes32(0);
+        } else if (action == ISSUE) {
+            manager.issue(amount);
+        } else if (action == REDEEM) {
+            require(IERC20(token).approve(address(manager), amount), "approve failed");
+            manager.redeem(amount);
+        } else if (action == BORROW_AGAIN) {
+            lender.flashLoan(this, token, amount, abi.encode(REPAY));
+        } else if (action == MINT) {
+            IMintable(token).mint(address(this), amount);
+        } else if (action == BURN) {
+            require(IERC20(token).approve(address(this), amount), "approve failed");
+            IMintable(token).burnFrom(address(this), amount);
+        }
+
+        uint256 repayment = action == REPAY_AMOUNT_ONLY ? amount : amount.add(fee);
+        require(IERC20(token).approve(address(lender), repayment), "approve failed");
+        return keccak256("ERC3156FlashBorrower.onFlashLoan");
+    }
+
+    /// Approve `spender` for `amount` of `token`, such as the Manager for collateral.
+    function approve(address token, address spender, uint256 amount) external {
+        require(IERC20(token).approve(spender, amount), "approve failed");
+    }
+
+    /// Issue and redeem, outside of a loan.
+    function issue(uint256 rsvAmount) external {
+        manager.issue(rsvAmount);
+    }
+
+    function redeem(uint256 rsvAmount) external {
+        require(IERC20(address(lender)).approve(address(manager), rsvAmount), "approve failed");
+        manager.redeem(rsvAmount);
+    }
+}
@@ -0,0 +1,86 @@
+pragma solidity 0.5.7;
+
+import "../rsv/ILayerZero.sol";
+
+/**
+ * A LayerZero endpoint for testing, after LayerZero's own LZEndpointMock: it delivers each
+ * message at once, in the same transaction, to the endpoint that `setDestLzEndpoint` names for
+ * its destination. Endpoints for several chains can live on one test chain. Each message costs
+ * `nativeFee` wei, and the rest of what is sent along is refunded.
+ */
+contract MockLZEndpoint is ILayerZeroEndpoint {
+
+    uint16 public chainId;
+    uint256 public nativeFee;
+
+    // The endpoint that delivers to each application.
+    mapping(address => address) public lzEndpointLookup;
+
+    // The last nonce of each path, by remote chain, inbound and outbound.
+    mapping(uint16 => mapping(bytes => uint64)) public inboundNonce;
+    mapping(uint16 => mapping(address => uint64)) public outboundNonce;
+
+    event Delivered(uint16 srcChainId, bytes srcAddress, address dstAddress, uint64 nonce);
+
+    constructor(uint16 _chainId, uint256 _nativeFee) public {
+        chainId = _chainId;
+        nativeFee = _nativeFee;
+    }
+
+    function setDestLzEndpoint(address destAddr, address lzEndpointAddr) external {
+        lzEndpointLookup[destAddr] = lzEndpointAddr;
+    }
+
+    function send(
+        uint16 dstChainId,
+        bytes calldata destination,
+        bytes calldata payload,
+        address payable refundAddress,
+        address,
+        bytes calldata
+    )
+        external
+        payable
+    {
+        require(destination.length == 40, "destination must be a path");
+        require(msg.value >= nativeFee, "not enough native fee");
+        address dstAddress;
+        bytes memory path = destination;
+        // solium-disable-next-line security/no-inline-assembly
+        assembly {
+            dstAddress := div(mload(add(path, 32)), 0x1000000000000000000000000)
+        }
+        address dstEndpoint = lzEndpointLookup[dstAddress];
+        require(dstEndpoint != address(0), "no endpoint for destination");
+
+        uint64 nonce = ++outboundNonce[dstChainId][msg.sender];
+        if (msg.value > nativeFee) {
+            refundAddress.transfer(msg.value - nativeFee);
+        }
+        MockLZEndpoint(dstEndpoint).receivePayload(
+            chainId, abi.encodePacked(msg.sender, dstAddress), dstAddress, nonce, payload
+        );
+    }
+
+    function receivePayload(
+        uint16 srcChainId,
+        bytes calldata srcAddress,
+        address dstAddress,
+        uint64 nonce,
+        bytes calldata payload
+    )
+        external
+    {
+        require(nonce == ++inboundNonce[srcChainId][srcAddress], "wrong nonce");
+        ILayerZeroReceiver(dstAddress).lzReceive(srcChainId, srcAddress, nonce, payload);
+        emit Delivered(srcChainId, srcAddress, dstAddress, nonce);
+    }
+
+    function estimateFees(uint16, address, bytes calldata, bool, bytes calldata)
+        external
+        view
+        returns (uint256, uint256)
+    {
+        return (nativeFee, 0);
+    }
+}
@@ -0,0 +1,14 @@
+pragma solidity 0.5.7;
+
+
+/**
+ * Stands in for the Manager, for testing a YieldVault without the rest of the system. It is
+ * fully collateralized until told otherwise.
+ */
+contract MockManager {
+    bool public isFullyCollateralized = true;
+
+    function setFullyCollateralized(bool val) external {
+        isFullyCollateralized = val;
+    }
+}
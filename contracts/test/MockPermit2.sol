@@ -0,0 +1,60 @@
+pragma solidity 0.5.7;
+
+import "../zeppelin/token/ERC20/SafeERC20.sol";
+import "../zeppelin/token/ERC20/IERC20.sol";
+
+/**
+ * A stand-in for Uniswap's Permit2, for testing: its batch `permit` grants the allowances it is
+ * given without checking the signature, and `transferFrom` spends them.
+ *
+ * This compiler can't take the permit's struct arguments without the experimental encoder, so
+ * the fallback function decodes the `permit` call from the calldata itself.
+ */
+contract MockPermit2 {
+    using SafeERC20 for IERC20;
+
+    // permit(address,((address,uint160,uint48,uint48)[],address,uint256),bytes)
+    bytes4 constant PERMIT_BATCH = 0x2a2d80d1;
+
+    // owner => token => spender => amount
+    mapping(address => mapping(address => mapping(address => uint256))) public allowance;
+
+    event Permitted(
+        address indexed owner,
+        address indexed token,
+        address indexed spender,
+        uint256 amount
+    );
+
+    function () external {
+        require(msg.sig == PERMIT_BATCH, "unknown function");
+        address owner;
+        address spender;
+        uint256 details;
+        uint256 n;
+        assembly {
+            owner := calldataload(4)
+            let batch := add(4, calldataload(36))
+            spender := calldataload(add(batch, 32))
+            details := add(batch, calldataload(batch))
+            n := calldataload(details)
+        }
+        for (uint256 i = 0; i < n; i++) {
+            address token;
+            uint256 amount;
+            assembly {
+                let detail := add(add(details, 32), mul(i, 128))
+                token := calldataload(detail)
+                amount := calldataload(add(detail, 32))
+            }
+            allowance[owner][token][spender] = amount;
+            emit Permitted(owner, token, spender, amount);
+        }
+    }
+
+    function transferFrom(address from, address to, uint160 amount, address token) external {
+        require(allowance[from][token][msg.sender] >= amount, "insufficient allowance");
+        allowance[from][token][msg.sender] -= amount;
+        IERC20(token).safeTransferFrom(from, to, amount);
+    }
+}
@@ -0,0 +1,32 @@
+pragma solidity 0.5.7;
+
+import "../rsv/ITransferHook.sol";
+
+/**
+ * A transfer hook for testing, standing in for a jurisdiction check. It refuses transfers from
+ * or to a blocked account, refuses every transfer if `refuses` is set, and reverts on every
+ * transfer if `reverts` is set.
+ */
+contract MockTransferHook is ITransferHook {
+
+    mapping(address => bool) public blocked;
+    bool public refuses;
+    bool public reverts;
+
+    function setBlocked(address account, bool isBlocked) external {
+        blocked[account] = isBlocked;
+    }
+
+    function setRefuses(bool _refuses) external {
+        refuses = _refuses;
+    }
+
+    function setReverts(bool _reverts) external {
+        reverts = _reverts;
+    }
+
+    function checkTransfer(address from, address to, uint256) external view returns (bool) {
+        require(!reverts, "hook reverted");
+        return !refuses && !blocked[from] && !blocked[to];
+    }
+}
@@ -0,0 +1,52 @@
+pragma solidity 0.5.7;
+
+import "../zeppelin/token/ERC20/ERC20.sol";
+import "../zeppelin/token/ERC20/IERC20.sol";
+import "../zeppelin/math/SafeMath.sol";
+
+/**
+ * A minimal ERC-4626 vault for testing. Its shares are worth a share of all of the asset it
+ * holds, so sending it the asset is yield, and `lose` is a loss.
+ */
+contract MockYieldSource is ERC20 {
+    using SafeMath for uint256;
+
+    IERC20 public trustedAsset;
+
+    constructor(address _asset) public {
+        trustedAsset = IERC20(_asset);
+    }
+
+    function asset() external view returns (address) {
+        return address(trustedAsset);
+    }
+
+    function totalAssets() public view returns (uint256) {
+        return trustedAsset.balanceOf(address(this));
+    }
+
+    function convertToAssets(uint256 shares) public view returns (uint256) {
+        if (totalSupply() == 0) {
+            return shares;
+        }
+        return shares.mul(totalAssets()).div(totalSupply());
+    }
+
+    function deposit(uint256 assets, address receiver) external returns (uint256 shares) {
+        shares = totalSupply() == 0 ? assets : assets.mul(totalSupply()).div(totalAssets());
+        require(trustedAsset.transferFrom(msg.sender, address(this), assets), "transfer failed");
+        _mint(receiver, shares);
+    }
+
+    function redeem(uint256 shares, address receiver, address owner) external returns (uint256 assets) {
+        require(msg.sender == owner, "must be owner");
+        assets = convertToAssets(shares);
+        _burn(owner, shares);
+        require(trustedAsset.transfer(receiver, assets), "transfer failed");
+    }
+
+    /// Lose `amount` of the asset, as a hack of the source would.
+    function lose(uint256 amount) external {
+        require(trustedAsset.transfer(address(1), amount), "transfer failed");
+    }
+}
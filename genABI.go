@@ -20,7 +20,14 @@ func outputGoFile(contractName string) string {
 	return fmt.Sprintf("abi/%v.go", contractName)
 }
 
-const combinedJsonDir = "evm"
+// combinedJsonDir is where solc's combined-json outputs are: evm/, unless $EVM_DIR names
+// another build of the same contracts, such as the instrumented one that cmd/rsvscribble makes.
+var combinedJsonDir = func() string {
+	if dir := os.Getenv("EVM_DIR"); dir != "" {
+		return dir
+	}
+	return "evm"
+}()
 
 func combinedJsonFilename(contractName string) string {
 	return fmt.Sprintf("%v/%v.json", combinedJsonDir, contractName)
@@ -28,7 +35,7 @@ func combinedJsonFilename(contractName string) string {
 
 func main() {
 	if len(os.Args) <= 1 {
-		log.Fatal("genABI: requires at least one argument, got \"%v\"", os.Args[1:])
+		log.Fatalf("genABI: requires at least one argument, got \"%v\"", os.Args[1:])
 	}
 
 	for _, contractName := range os.Args[1:] {
@@ -64,13 +71,13 @@ func main() {
 			tail := k[index+1:]
 			if tail == contractName {
 				if contractKey != "" {
-					log.Fatal("multiple $v instances in evm/%v.json", contractName, contractName)
+					log.Fatalf("multiple %v instances in %v", contractName, jsonName)
 				}
 				contractKey = k
 			}
 		}
 		if contractKey == "" {
-			log.Fatal("no $v instances in evm/%s.json.", contractName, contractName)
+			log.Fatalf("no %v instances in %v.", contractName, jsonName)
 		}
 		output := compilationResult.Contracts[contractKey]
 
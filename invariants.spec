@@ -0,0 +1,8 @@
+# The invariants of a deployment, which the tests check after every step of the fuzz tests and
+# rsvalert checks on chain, so that both check the same properties; see ops/spec for the format.
+
+# The Vault holds, of each basket token, at least the supply times its weight.
+backed: vault[token] * 1e36 >= supply * weight[token]
+
+# The basket has at least one token.
+basketNotEmpty: tokens > 0
@@ -0,0 +1,332 @@
+// Package abicheck compares a contract's ABI with that of an earlier release, for the changes that
+// would break an integrator built against the earlier one: a function removed, or taking other
+// arguments, returning other values, or newly able to change state; an event removed, or with
+// another topic or other indexed parameters. Additions, and renamed parameters, break nothing.
+//
+// It also audits a set of contracts for selectors and event topics that one function or event
+// could be taken for another by, and for functions that a proxy shadows; see Audit.
+//
+// The ABI is parsed here rather than with go-ethereum's abi package, which keeps one function of
+// each name, and so misses changes to overloads such as the Reserve's transferAndCall.
+package abicheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Param is an argument, return value, or event parameter.
+type Param struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Indexed    bool    `json:"indexed,omitempty"`
+	Components []Param `json:"components,omitempty"`
+}
+
+// canonical returns the type as it appears in a signature, with tuples spelled out.
+func (p Param) canonical() string {
+	if !strings.HasPrefix(p.Type, "tuple") {
+		return p.Type
+	}
+	types := make([]string, len(p.Components))
+	for i, c := range p.Components {
+		types[i] = c.canonical()
+	}
+	return "(" + strings.Join(types, ",") + ")" + strings.TrimPrefix(p.Type, "tuple")
+}
+
+// Entry is one entry of an ABI: a function, an event, the constructor, or the fallback.
+type Entry struct {
+	Type            string  `json:"type"`
+	Name            string  `json:"name"`
+	Inputs          []Param `json:"inputs"`
+	Outputs         []Param `json:"outputs"`
+	StateMutability string  `json:"stateMutability"`
+	Constant        bool    `json:"constant"`
+	Payable         bool    `json:"payable"`
+	Anonymous       bool    `json:"anonymous"`
+}
+
+// Signature is the entry's name and argument types, as hashed into a selector or an event topic.
+func (e Entry) Signature() string {
+	return e.Name + "(" + types(e.Inputs) + ")"
+}
+
+// mutability is the entry's state mutability, worked out from the older constant and payable
+// fields where the ABI lacks stateMutability.
+func (e Entry) mutability() string {
+	switch {
+	case e.StateMutability != "":
+		return e.StateMutability
+	case e.Constant:
+		return "view"
+	case e.Payable:
+		return "payable"
+	}
+	return "nonpayable"
+}
+
+func types(params []Param) string {
+	t := make([]string, len(params))
+	for i, p := range params {
+		t[i] = p.canonical()
+	}
+	return strings.Join(t, ",")
+}
+
+func names(params []Param) string {
+	n := make([]string, len(params))
+	for i, p := range params {
+		n[i] = p.Name
+	}
+	return strings.Join(n, ",")
+}
+
+// indexed lists the parameters that an event indexes, by position, which decides its topics.
+func indexed(params []Param) string {
+	var n []string
+	for i, p := range params {
+		if p.Indexed {
+			n = append(n, fmt.Sprintf("#%v %v", i+1, p.canonical()))
+		}
+	}
+	return strings.Join(n, ", ")
+}
+
+// ABI is a parsed ABI.
+type ABI []Entry
+
+// Parse parses an ABI, as solc writes it.
+func Parse(b []byte) (ABI, error) {
+	var a ABI
+	if err := json.Unmarshal(b, &a); err != nil {
+		return nil, errors.Wrap(err, "parsing the ABI")
+	}
+	for i := range a {
+		if a[i].Type == "" {
+			a[i].Type = "function" // as the ABI spec has it
+		}
+	}
+	return a, nil
+}
+
+// byKind returns the entries of a type, by signature.
+func (a ABI) byKind(kind string) map[string]Entry {
+	m := make(map[string]Entry)
+	for _, e := range a {
+		if e.Type == kind {
+			m[e.Signature()] = e
+		}
+	}
+	return m
+}
+
+// Change is a difference between two ABIs of a contract.
+type Change struct {
+	Item     string `json:"item"` // such as "function transfer(address,uint256)"
+	What     string `json:"what"`
+	Breaking bool   `json:"breaking"`
+}
+
+func (c Change) String() string {
+	if c.Breaking {
+		return fmt.Sprintf("%v: %v (breaking)", c.Item, c.What)
+	}
+	return fmt.Sprintf("%v: %v", c.Item, c.What)
+}
+
+// Compare lists how new differs from old: functions, then events, then the fallback, each
+// sorted by signature.
+func Compare(old, new ABI) []Change {
+	changes := compareFunctions(old.byKind("function"), new.byKind("function"))
+	changes = append(changes, compareEvents(old.byKind("event"), new.byKind("event"))...)
+
+	oldFallback, newFallback := old.byKind("fallback")["()"], new.byKind("fallback")["()"]
+	switch {
+	case oldFallback.Type != "" && newFallback.Type == "":
+		changes = append(changes, Change{"fallback", "removed", true})
+	case oldFallback.Type == "" && newFallback.Type != "":
+		changes = append(changes, Change{"fallback", "added", false})
+	case oldFallback.mutability() == "payable" && newFallback.mutability() != "payable":
+		changes = append(changes, Change{"fallback", "no longer accepts ether", true})
+	}
+	return changes
+}
+
+func compareFunctions(old, new map[string]Entry) []Change {
+	var changes []Change
+	for _, sig := range sorted(old) {
+		o := old[sig]
+		item := "function " + sig
+		n, ok := new[sig]
+		if !ok {
+			what := "removed"
+			if others := overloads(new, o.Name, old); len(others) > 0 {
+				what += "; " + o.Name + " now takes (" + strings.Join(others, "), (") + ")"
+			}
+			changes = append(changes, Change{item, what, true})
+			continue
+		}
+		if types(o.Outputs) != types(n.Outputs) {
+			changes = append(changes, Change{item, fmt.Sprintf("returns (%v), not (%v)", types(n.Outputs), types(o.Outputs)), true})
+		}
+		om, nm := o.mutability(), n.mutability()
+		switch {
+		case om == nm:
+		case (om == "view" || om == "pure") && nm != "view" && nm != "pure":
+			changes = append(changes, Change{item, fmt.Sprintf("is %v, not %v, so it can no longer be called for free", nm, om), true})
+		case om == "payable":
+			changes = append(changes, Change{item, fmt.Sprintf("is %v, so it no longer accepts ether", nm), true})
+		default:
+			changes = append(changes, Change{item, fmt.Sprintf("is %v, not %v", nm, om), false})
+		}
+		if names(o.Inputs) != names(n.Inputs) || names(o.Outputs) != names(n.Outputs) {
+			changes = append(changes, Change{item, "renames its parameters", false})
+		}
+	}
+	for _, sig := range sorted(new) {
+		if _, ok := old[sig]; !ok {
+			changes = append(changes, Change{"function " + sig, "added", false})
+		}
+	}
+	return changes
+}
+
+// overloads lists the argument types of the functions called name in new that are not in old.
+func overloads(new map[string]Entry, name string, old map[string]Entry) []string {
+	var args []string
+	for _, sig := range sorted(new) {
+		if _, ok := old[sig]; !ok && new[sig].Name == name {
+			args = append(args, types(new[sig].Inputs))
+		}
+	}
+	return args
+}
+
+func compareEvents(old, new map[string]Entry) []Change {
+	var changes []Change
+	for _, sig := range sorted(old) {
+		o := old[sig]
+		item := "event " + sig
+		n, ok := new[sig]
+		if !ok {
+			what := "removed, so its topic is gone"
+			if others := overloads(new, o.Name, old); len(others) > 0 {
+				what = "removed; " + o.Name + " now has (" + strings.Join(others, "), (") + "), and another topic"
+			}
+			changes = append(changes, Change{item, what, true})
+			continue
+		}
+		if o.Anonymous != n.Anonymous {
+			what := "is anonymous, so it has no topic"
+			if !n.Anonymous {
+				what = "is no longer anonymous, so its first topic is its signature"
+			}
+			changes = append(changes, Change{item, what, true})
+		}
+		if indexed(o.Inputs) != indexed(n.Inputs) {
+			changes = append(changes, Change{item, fmt.Sprintf("indexes (%v), not (%v)", indexed(n.Inputs), indexed(o.Inputs)), true})
+		}
+		if names(o.Inputs) != names(n.Inputs) {
+			changes = append(changes, Change{item, "renames its parameters", false})
+		}
+	}
+	for _, sig := range sorted(new) {
+		if _, ok := old[sig]; !ok {
+			changes = append(changes, Change{"event " + sig, "added", false})
+		}
+	}
+	return changes
+}
+
+func sorted(m map[string]Entry) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Breaking returns the changes that break integrators.
+func Breaking(changes []Change) []Change {
+	var breaking []Change
+	for _, c := range changes {
+		if c.Breaking {
+			breaking = append(breaking, c)
+		}
+	}
+	return breaking
+}
+
+// Format returns an ABI as solc writes it, but indented, so that the diff of a committed ABI shows
+// what changed.
+func Format(raw []byte) ([]byte, error) {
+	if _, err := Parse(raw); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := json.Indent(&b, raw, "", "  "); err != nil {
+		return nil, errors.Wrap(err, "formatting the ABI")
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// WriteDir writes each contract's ABI, formatted, to <dir>/<contract>.json, removing the ABIs of
+// contracts not in abis.
+func WriteDir(dir string, abis map[string][]byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "creating the ABI directory")
+	}
+	stale, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return errors.Wrap(err, "listing ABIs")
+	}
+	for _, path := range stale {
+		if _, ok := abis[strings.TrimSuffix(filepath.Base(path), ".json")]; !ok {
+			if err := os.Remove(path); err != nil {
+				return errors.Wrap(err, "removing a stale ABI")
+			}
+		}
+	}
+	for name, raw := range abis {
+		b, err := Format(raw)
+		if err != nil {
+			return errors.Wrap(err, name)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".json"), b, 0644); err != nil {
+			return errors.Wrapf(err, "writing the ABI of %v", name)
+		}
+	}
+	return nil
+}
+
+// ReadDir reads the ABIs that WriteDir wrote to dir, by contract.
+func ReadDir(dir string) (map[string]ABI, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "listing ABIs")
+	}
+	abis := make(map[string]ABI)
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading the ABI")
+		}
+		a, err := Parse(b)
+		if err != nil {
+			return nil, errors.Wrap(err, path)
+		}
+		abis[strings.TrimSuffix(filepath.Base(path), ".json")] = a
+	}
+	return abis, nil
+}
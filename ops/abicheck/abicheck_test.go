@@ -0,0 +1,97 @@
+package abicheck
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// release is an ABI as solc 0.5.7 writes it, with both the older constant and payable fields and
+// stateMutability.
+const release = `[
+	{"constant":false,"inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"name":"transfer","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},
+	{"constant":false,"inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"name":"transferAndCall","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},
+	{"constant":false,"inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"}],"name":"transferAndCall","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},
+	{"constant":true,"inputs":[{"name":"who","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},
+	{"constant":true,"inputs":[],"name":"paused","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"view","type":"function"},
+	{"constant":false,"inputs":[],"name":"pause","outputs":[],"payable":false,"stateMutability":"nonpayable","type":"function"},
+	{"constant":false,"inputs":[{"name":"id","type":"uint256"}],"name":"deposit","outputs":[],"payable":true,"stateMutability":"payable","type":"function"},
+	{"payable":true,"stateMutability":"payable","type":"fallback"},
+	{"inputs":[],"payable":false,"stateMutability":"nonpayable","type":"constructor"},
+	{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Transfer","type":"event"},
+	{"anonymous":false,"inputs":[{"indexed":true,"name":"account","type":"address"}],"name":"Paused","type":"event"},
+	{"anonymous":false,"inputs":[{"indexed":false,"name":"id","type":"uint256"}],"name":"Deposited","type":"event"}
+]`
+
+func TestCompareSame(t *testing.T) {
+	a, err := Parse([]byte(release))
+	require.NoError(t, err)
+	assert.Empty(t, Compare(a, a))
+}
+
+func TestCompare(t *testing.T) {
+	old, err := Parse([]byte(release))
+	require.NoError(t, err)
+	new, err := Parse([]byte(`[
+		{"inputs":[{"name":"recipient","type":"address"},{"name":"amount","type":"uint256"}],"name":"transfer","outputs":[{"name":"","type":"bool"}],"stateMutability":"nonpayable","type":"function"},
+		{"inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"name":"transferAndCall","outputs":[{"name":"","type":"bool"}],"stateMutability":"nonpayable","type":"function"},
+		{"inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes32"}],"name":"transferAndCall","outputs":[{"name":"","type":"bool"}],"stateMutability":"nonpayable","type":"function"},
+		{"inputs":[{"name":"who","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint128"}],"stateMutability":"view","type":"function"},
+		{"inputs":[],"name":"paused","outputs":[{"name":"","type":"bool"}],"stateMutability":"nonpayable","type":"function"},
+		{"inputs":[],"name":"pause","outputs":[],"stateMutability":"view","type":"function"},
+		{"inputs":[{"name":"id","type":"uint256"}],"name":"deposit","outputs":[],"stateMutability":"nonpayable","type":"function"},
+		{"inputs":[{"components":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"name":"transfers","type":"tuple[]"}],"name":"transferBatch","outputs":[],"stateMutability":"nonpayable","type":"function"},
+		{"inputs":[{"name":"supply","type":"uint256"}],"stateMutability":"nonpayable","type":"constructor"},
+		{"anonymous":false,"inputs":[{"indexed":true,"name":"sender","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Transfer","type":"event"},
+		{"anonymous":false,"inputs":[{"indexed":false,"name":"account","type":"address"}],"name":"Paused","type":"event"},
+		{"anonymous":false,"inputs":[{"indexed":false,"name":"id","type":"uint256"},{"indexed":false,"name":"from","type":"address"}],"name":"Deposited","type":"event"}
+	]`))
+	require.NoError(t, err)
+
+	changes := Compare(old, new)
+	var lines []string
+	for _, c := range changes {
+		lines = append(lines, c.String())
+	}
+	assert.Equal(t, []string{
+		"function balanceOf(address): returns (uint128), not (uint256) (breaking)",
+		"function deposit(uint256): is nonpayable, so it no longer accepts ether (breaking)",
+		"function pause(): is view, not nonpayable",
+		"function paused(): is nonpayable, not view, so it can no longer be called for free (breaking)",
+		"function transfer(address,uint256): renames its parameters",
+		"function transferAndCall(address,uint256,bytes): removed; transferAndCall now takes (address,uint256,bytes32) (breaking)",
+		"function transferAndCall(address,uint256,bytes32): added",
+		"function transferBatch((address,uint256)[]): added",
+		"event Deposited(uint256): removed; Deposited now has (uint256,address), and another topic (breaking)",
+		"event Paused(address): indexes (), not (#1 address) (breaking)",
+		"event Transfer(address,address,uint256): renames its parameters",
+		"event Deposited(uint256,address): added",
+		"fallback: removed (breaking)",
+	}, lines)
+	assert.Len(t, Breaking(changes), 7)
+}
+
+func TestDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "abicheck")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Stale.json"), []byte("[]"), 0644))
+
+	require.NoError(t, WriteDir(dir, map[string][]byte{"Reserve": []byte(release)}))
+	abis, err := ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, abis, 1)
+	want, err := Parse([]byte(release))
+	require.NoError(t, err)
+	assert.Equal(t, want, abis["Reserve"])
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "Reserve.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "\n  {\n    \"constant\": false,\n")
+
+	assert.Error(t, WriteDir(dir, map[string][]byte{"Reserve": []byte("{")}))
+}
@@ -0,0 +1,164 @@
+package abicheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// ID is a function's selector, the first 4 bytes of the keccak256 of its signature, or an event's
+// topic 0, all 32 of them.
+type ID struct {
+	Kind      string `json:"kind"` // "function" or "event"
+	Contract  string `json:"contract"`
+	Signature string `json:"signature"`
+	ID        string `json:"id"`                // 0x-prefixed hex
+	Indexed   string `json:"indexed,omitempty"` // for an event, which parameters it indexes
+}
+
+func (id ID) String() string {
+	return fmt.Sprintf("%v.%v", id.Contract, id.Signature)
+}
+
+// IDs lists the selector of every function and the topic 0 of every event of each contract,
+// sorted by kind, ID, and contract. Anonymous events have no topic 0, and are left out.
+func IDs(contracts map[string]ABI) []ID {
+	var ids []ID
+	for contract, a := range contracts {
+		for _, e := range a {
+			hash := crypto.Keccak256([]byte(e.Signature()))
+			switch {
+			case e.Type == "function":
+				ids = append(ids, ID{"function", contract, e.Signature(), fmt.Sprintf("0x%x", hash[:4]), ""})
+			case e.Type == "event" && !e.Anonymous:
+				ids = append(ids, ID{"event", contract, e.Signature(), fmt.Sprintf("0x%x", hash), indexed(e.Inputs)})
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := ids[i], ids[j]
+		if a.Kind != b.Kind {
+			return a.Kind == "function"
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Contract < b.Contract
+	})
+	return ids
+}
+
+// Proxy names a proxy and the implementation it delegates to, which share an address: a call to
+// a function of the proxy's own never reaches the implementation, and the events of both are
+// logged by that one address.
+type Proxy struct {
+	Proxy          string
+	Implementation string
+}
+
+// ParseProxy parses a proxy as "ReserveProxy=Reserve".
+func ParseProxy(s string) (Proxy, error) {
+	parts := strings.Split(s, "=")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Proxy{}, errors.Errorf("%q is not <proxy>=<implementation>", s)
+	}
+	return Proxy{parts[0], parts[1]}, nil
+}
+
+// Finding is a selector or topic shared in a way that lets one call or event pass for another.
+type Finding struct {
+	Kind string `json:"kind"` // "selector collision", "topic collision", or "shadowed"
+	ID   string `json:"id"`
+	What string `json:"what"`
+	IDs  []ID   `json:"ids"`
+}
+
+func (f Finding) String() string {
+	items := make([]string, len(f.IDs))
+	for i, id := range f.IDs {
+		items[i] = id.String()
+	}
+	return fmt.Sprintf("%v %v: %v: %v", f.Kind, f.ID, strings.Join(items, ", "), f.What)
+}
+
+// Audit looks for selectors and topics that are ambiguous across contracts, and for functions
+// that proxies shadow:
+//
+// A selector collision is two function signatures with one selector, anywhere in contracts:
+// within one contract solc refuses them, but across contracts they let a call, or the data a
+// forwarder or multisig relays, be decoded as the wrong function, and a call sent to the wrong
+// address be taken for another.
+//
+// A topic collision is two event signatures with one topic 0, anywhere in contracts, or, among
+// the contracts logged at one proxy's address, one event signature indexing other parameters, so
+// that indexers filtering on the topic decode the logs of one as the other.
+//
+// A proxy shadows a function of its implementation that has a selector of one of its own.
+func Audit(contracts map[string]ABI, proxies []Proxy) []Finding {
+	ids := IDs(contracts)
+	var findings []Finding
+	for _, group := range byID(ids) {
+		if signatures(group) < 2 {
+			continue
+		}
+		kind, what := "selector collision", "one selector for different functions"
+		if group[0].Kind == "event" {
+			kind, what = "topic collision", "one topic for different events"
+		}
+		findings = append(findings, Finding{kind, group[0].ID, what, group})
+	}
+
+	for _, p := range proxies {
+		var shared []ID
+		for _, id := range ids {
+			if id.Contract == p.Proxy || id.Contract == p.Implementation {
+				shared = append(shared, id)
+			}
+		}
+		for _, group := range byID(shared) {
+			contracts := make(map[string]bool)
+			indexing := make(map[string]bool)
+			for _, id := range group {
+				contracts[id.Contract] = true
+				indexing[id.Indexed] = true
+			}
+			switch {
+			case len(contracts) < 2 || signatures(group) > 1:
+				// Within one contract, or already found above.
+			case group[0].Kind == "function":
+				findings = append(findings, Finding{"shadowed", group[0].ID, fmt.Sprintf(
+					"%v runs its own, so calls through it never reach %v's", p.Proxy, p.Implementation), group})
+			case len(indexing) > 1:
+				findings = append(findings, Finding{"topic collision", group[0].ID,
+					"logged at one address, indexing different parameters", group})
+			}
+		}
+	}
+	return findings
+}
+
+// byID groups ids, which IDs sorted, by kind and ID.
+func byID(ids []ID) [][]ID {
+	var groups [][]ID
+	for i := 0; i < len(ids); {
+		j := i + 1
+		for j < len(ids) && ids[j].Kind == ids[i].Kind && ids[j].ID == ids[i].ID {
+			j++
+		}
+		groups = append(groups, ids[i:j])
+		i = j
+	}
+	return groups
+}
+
+// signatures counts the different signatures among ids.
+func signatures(ids []ID) int {
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		seen[id.Signature] = true
+	}
+	return len(seen)
+}
@@ -0,0 +1,68 @@
+package abicheck
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDs(t *testing.T) {
+	a, err := Parse([]byte(release))
+	require.NoError(t, err)
+	ids := IDs(map[string]ABI{"Reserve": a})
+	require.Len(t, ids, 10)
+	assert.Equal(t, ID{"function", "Reserve", "transfer(address,uint256)", "0xa9059cbb", ""}, ids[5])
+	assert.Equal(t, ID{
+		"event", "Reserve", "Transfer(address,address,uint256)",
+		"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+		"#1 address, #2 address",
+	}, ids[9])
+}
+
+func TestAudit(t *testing.T) {
+	parse := func(s string) ABI {
+		a, err := Parse([]byte(s))
+		require.NoError(t, err)
+		return a
+	}
+	reserve := parse(release)
+	contracts := map[string]ABI{
+		"Reserve": reserve,
+		// The same functions and events as the Reserve's break nothing.
+		"ReserveV2": reserve,
+		// burn(uint256) and collate_propagate_storage(bytes16) share the selector 0x42966c68.
+		"Burner": parse(`[
+			{"inputs":[{"name":"value","type":"uint256"}],"name":"burn","outputs":[],"type":"function"}
+		]`),
+		"Forwarder": parse(`[
+			{"inputs":[{"name":"","type":"bytes16"}],"name":"collate_propagate_storage","outputs":[],"type":"function"},
+			{"anonymous":true,"inputs":[],"name":"Forwarded","type":"event"}
+		]`),
+		"Proxy": parse(`[
+			{"inputs":[],"name":"paused","outputs":[{"name":"","type":"bool"}],"stateMutability":"view","type":"function"},
+			{"anonymous":false,"inputs":[{"indexed":false,"name":"account","type":"address"}],"name":"Paused","type":"event"},
+			{"payable":true,"stateMutability":"payable","type":"fallback"}
+		]`),
+	}
+	assert.Empty(t, Audit(map[string]ABI{"Reserve": reserve, "ReserveV2": reserve}, nil))
+
+	var lines []string
+	for _, f := range Audit(contracts, []Proxy{{"Proxy", "Reserve"}}) {
+		lines = append(lines, f.String())
+	}
+	assert.Equal(t, []string{
+		"selector collision 0x42966c68: Burner.burn(uint256), Forwarder.collate_propagate_storage(bytes16): one selector for different functions",
+		"shadowed 0x5c975abb: Proxy.paused(), Reserve.paused(): Proxy runs its own, so calls through it never reach Reserve's",
+		"topic collision 0x62e78cea01bee320cd4e420270b5ea74000d11b0c9f74754ebdbfc544b05a258: " +
+			"Proxy.Paused(address), Reserve.Paused(address): logged at one address, indexing different parameters",
+	}, lines)
+
+	// Without the proxy, the Reserve's paused() and Paused(address) are unambiguous.
+	assert.Len(t, Audit(contracts, nil), 1)
+
+	_, err := ParseProxy("ReserveProxy=Reserve")
+	assert.NoError(t, err)
+	_, err = ParseProxy("ReserveProxy")
+	assert.Error(t, err)
+}
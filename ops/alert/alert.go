@@ -0,0 +1,316 @@
+// Package alert evaluates invariants of an RSV deployment against its state on chain, and
+// alerts when one breaks: the Vault backs the supply, each admin role is held by an allowed
+// address, the Reserve is paused exactly when it is expected to be, and the specs of a spec file,
+// such as the invariants.spec that the tests check (see ops/spec), hold.
+//
+// An alert fires once when an invariant starts failing, with the values that break it, and
+// resolves once when it holds again.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/spec"
+)
+
+// Config selects the invariants to evaluate.
+type Config struct {
+	// Backed requires the Vault to hold, of each basket token, at least the supply times the
+	// token's weight.
+	Backed bool `json:"backed"`
+
+	// Roles maps roles, as "Contract.role" such as "Reserve.owner", to the addresses allowed
+	// to hold them.
+	Roles map[string][]common.Address `json:"roles,omitempty"`
+
+	// Paused, if set, is whether the Reserve is expected to be paused.
+	Paused *bool `json:"paused,omitempty"`
+
+	// Specs, if set, is a file of specs (see ops/spec), each of which must hold.
+	Specs string `json:"specs,omitempty"`
+}
+
+// Violation is a broken invariant.
+type Violation struct {
+	// Invariant names the invariant, such as "backed" or "role Reserve.owner". It identifies
+	// the alert from firing to resolution.
+	Invariant string
+
+	Summary string
+
+	// Values are the violating values, by name.
+	Values map[string]string
+}
+
+// Invariant checks one property of the state, returning nil if it holds.
+type Invariant struct {
+	Name  string
+	Check func(s *metrics.State) *Violation
+}
+
+// Invariants returns the invariants that c selects.
+func (c Config) Invariants() ([]Invariant, error) {
+	var invariants []Invariant
+	if c.Backed {
+		invariants = append(invariants, Invariant{Name: "backed", Check: checkBacked})
+	}
+	var roles []string
+	for role := range c.Roles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		parts := strings.Split(role, ".")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("config: role %q is not of the form Contract.role", role)
+		}
+		if len(c.Roles[role]) == 0 {
+			return nil, errors.Errorf("config: role %v allows no addresses", role)
+		}
+		invariants = append(invariants, roleInvariant(parts[0], parts[1], c.Roles[role]))
+	}
+	if c.Paused != nil {
+		invariants = append(invariants, pausedInvariant(*c.Paused))
+	}
+	if c.Specs != "" {
+		specs, err := spec.ReadFile(c.Specs)
+		if err != nil {
+			return nil, errors.Wrap(err, "config: specs")
+		}
+		for _, s := range specs {
+			invariants = append(invariants, specInvariant(s))
+		}
+	}
+	if len(invariants) == 0 {
+		return nil, errors.New("config: no invariants are selected")
+	}
+	return invariants, nil
+}
+
+func checkBacked(s *metrics.State) *Violation {
+	v := &Violation{Invariant: "backed", Values: map[string]string{"supply": s.Supply.String()}}
+	var short []string
+	for _, t := range s.Tokens {
+		needed := s.Needed(t)
+		if t.Balance.Cmp(needed) >= 0 {
+			continue
+		}
+		name := t.Address.Hex()
+		if t.Symbol != "" {
+			name = t.Symbol
+		}
+		short = append(short, name)
+		v.Values[name+" balance"] = t.Balance.String()
+		v.Values[name+" needed"] = needed.String()
+	}
+	if len(short) == 0 {
+		return nil
+	}
+	v.Summary = fmt.Sprintf("the Vault does not back the RSV supply in %v", strings.Join(short, ", "))
+	return v
+}
+
+func roleInvariant(contract, role string, allowed []common.Address) Invariant {
+	name := "role " + contract + "." + role
+	return Invariant{Name: name, Check: func(s *metrics.State) *Violation {
+		for _, r := range s.Roles {
+			if r.Contract != contract || r.Role != role {
+				continue
+			}
+			for _, a := range allowed {
+				if r.Holder == a {
+					return nil
+				}
+			}
+			return &Violation{
+				Invariant: name,
+				Summary:   fmt.Sprintf("%v.%v is held by %v, which is not allowed", contract, role, r.Holder.Hex()),
+				Values:    map[string]string{"holder": r.Holder.Hex(), "allowed": formatAddresses(allowed)},
+			}
+		}
+		return &Violation{
+			Invariant: name,
+			Summary:   fmt.Sprintf("%v.%v could not be read", contract, role),
+			Values:    map[string]string{"allowed": formatAddresses(allowed)},
+		}
+	}}
+}
+
+func pausedInvariant(expected bool) Invariant {
+	return Invariant{Name: "paused", Check: func(s *metrics.State) *Violation {
+		if s.Paused == expected {
+			return nil
+		}
+		summary := "the Reserve is paused"
+		if expected {
+			summary = "the Reserve is not paused"
+		}
+		return &Violation{
+			Invariant: "paused",
+			Summary:   summary,
+			Values:    map[string]string{"paused": fmt.Sprint(s.Paused), "expected": fmt.Sprint(expected)},
+		}
+	}}
+}
+
+func specInvariant(s *spec.Spec) Invariant {
+	name := "spec " + s.Name
+	return Invariant{Name: name, Check: func(state *metrics.State) *Violation {
+		failures := s.Check(spec.FromState(state))
+		if len(failures) == 0 {
+			return nil
+		}
+		v := &Violation{Invariant: name, Values: map[string]string{}}
+		var described []string
+		for _, f := range failures {
+			described = append(described, s.Describe(f))
+			for k, value := range f.Values {
+				v.Values[k] = value
+			}
+		}
+		v.Summary = strings.Join(described, "; ")
+		return v
+	}}
+}
+
+func formatAddresses(addrs []common.Address) string {
+	var hex []string
+	for _, a := range addrs {
+		hex = append(hex, a.Hex())
+	}
+	return strings.Join(hex, ", ")
+}
+
+// Alert is a notification that an invariant started or stopped failing.
+type Alert struct {
+	Violation
+	Block    uint64
+	Resolved bool
+}
+
+// Text is the alert as a chat message, listing the violating values.
+func (a Alert) Text(network string) string {
+	if a.Resolved {
+		return fmt.Sprintf("RSV on %v: resolved at block %v: %v", network, a.Block, a.Invariant)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "RSV on %v: invariant %v broken at block %v: %v", network, a.Invariant, a.Block, a.Summary)
+	for _, k := range a.keys() {
+		fmt.Fprintf(&b, "\n  %v: %v", k, a.Values[k])
+	}
+	return b.String()
+}
+
+func (a Alert) keys() []string {
+	var keys []string
+	for k := range a.Values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Sink delivers alerts, such as to PagerDuty or a chat webhook.
+type Sink interface {
+	Send(ctx context.Context, a Alert) error
+}
+
+// Engine reads the state periodically and evaluates the invariants against it.
+type Engine struct {
+	Read       func(ctx context.Context) (*metrics.State, error)
+	Invariants []Invariant
+	Sinks      []Sink
+	Interval   time.Duration
+
+	// MaxReadFailures is how many reads in a row may fail before the engine alerts that it
+	// cannot see the chain; zero means 3.
+	MaxReadFailures int
+
+	firing       map[string]bool
+	readFailures int
+}
+
+// readInvariant names the alert for failing to read the chain.
+const readInvariant = "read"
+
+// Run evaluates the invariants every Interval until ctx is done.
+func (e *Engine) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+	for {
+		e.Step(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Step reads the state once, and sends an alert for each invariant that started or stopped
+// failing since the last Step.
+func (e *Engine) Step(ctx context.Context) {
+	if e.firing == nil {
+		e.firing = map[string]bool{}
+	}
+	s, err := e.Read(ctx)
+	if err != nil {
+		log.Printf("alert: reading state: %v", err)
+		e.readFailures++
+		max := e.MaxReadFailures
+		if max == 0 {
+			max = 3
+		}
+		if e.readFailures >= max {
+			e.update(ctx, 0, readInvariant, &Violation{
+				Invariant: readInvariant,
+				Summary:   fmt.Sprintf("the chain could not be read %v times in a row", e.readFailures),
+				Values:    map[string]string{"error": err.Error()},
+			})
+		}
+		return
+	}
+	e.readFailures = 0
+	e.update(ctx, s.Block, readInvariant, nil)
+	for _, inv := range e.Invariants {
+		e.update(ctx, s.Block, inv.Name, inv.Check(s))
+	}
+}
+
+// update records whether the invariant called name holds, alerting on a change.
+func (e *Engine) update(ctx context.Context, block uint64, name string, v *Violation) {
+	switch {
+	case v != nil && !e.firing[name]:
+		log.Printf("alert: %v: %v", name, v.Summary)
+		if e.send(ctx, Alert{Violation: *v, Block: block}) {
+			e.firing[name] = true
+		}
+	case v == nil && e.firing[name]:
+		log.Printf("alert: %v: resolved", name)
+		if e.send(ctx, Alert{Violation: Violation{Invariant: name}, Block: block, Resolved: true}) {
+			delete(e.firing, name)
+		}
+	}
+}
+
+// send delivers a to every sink, reporting whether all of them took it. If not, the change is
+// sent again, to every sink, on the next Step: a duplicate beats a lost alert.
+func (e *Engine) send(ctx context.Context, a Alert) bool {
+	ok := true
+	for _, sink := range e.Sinks {
+		if err := sink.Send(ctx, a); err != nil {
+			log.Printf("alert: sending %v: %v", a.Invariant, err)
+			ok = false
+		}
+	}
+	return ok
+}
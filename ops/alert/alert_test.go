@@ -0,0 +1,173 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+)
+
+var (
+	usdc  = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	owner = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	eve   = common.HexToAddress("0x0000000000000000000000000000000000000e7e")
+)
+
+// state is 1000 RSV backed by half a USDC each.
+func state(balance int64, holder common.Address, paused bool) *metrics.State {
+	return &metrics.State{
+		Block:       10,
+		Supply:      new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)),
+		RSVDecimals: 18,
+		Paused:      paused,
+		Tokens: []metrics.Token{{
+			Address: usdc, Symbol: "USDC", Decimals: 6,
+			Weight:  new(big.Int).Mul(big.NewInt(5e5), big.NewInt(1e18)),
+			Balance: big.NewInt(balance),
+		}},
+		Roles: []metrics.Role{{Contract: "Reserve", Role: "owner", Holder: holder}},
+	}
+}
+
+// TestSpecInvariants checks the repo's invariants.spec, which the tests check too.
+func TestSpecInvariants(t *testing.T) {
+	invariants, err := Config{Specs: "../../invariants.spec"}.Invariants()
+	require.NoError(t, err)
+	require.NotEmpty(t, invariants)
+	assert.Equal(t, "spec backed", invariants[0].Name)
+
+	for _, inv := range invariants {
+		assert.Nil(t, inv.Check(state(500e6, owner, false)), inv.Name)
+	}
+	v := invariants[0].Check(state(500e6-1, owner, false))
+	require.NotNil(t, v)
+	assert.Equal(t, "spec backed", v.Invariant)
+	assert.Equal(t, "backed: vault[USDC] * 1e36 >= supply * weight[USDC] fails, "+
+		"as 499999999000000000000000000000000000000000000 is not >= 500000000000000000000000000000000000000000000", v.Summary)
+	assert.Equal(t, map[string]string{
+		"supply": "1000000000000000000000", "vault[USDC]": "499999999", "weight[USDC]": "500000000000000000000000",
+	}, v.Values)
+}
+
+type recorder struct {
+	alerts []Alert
+	fail   bool
+}
+
+func (r *recorder) Send(ctx context.Context, a Alert) error {
+	if r.fail {
+		return errors.New("unreachable")
+	}
+	r.alerts = append(r.alerts, a)
+	return nil
+}
+
+func TestInvariants(t *testing.T) {
+	paused := false
+	invariants, err := Config{Backed: true, Roles: map[string][]common.Address{"Reserve.owner": {owner}}, Paused: &paused}.Invariants()
+	require.NoError(t, err)
+	require.Len(t, invariants, 3)
+
+	ok := state(500e6, owner, false)
+	for _, inv := range invariants {
+		assert.Nil(t, inv.Check(ok), inv.Name)
+	}
+
+	broken := state(500e6-1, eve, true)
+	v := invariants[0].Check(broken)
+	require.NotNil(t, v)
+	assert.Equal(t, map[string]string{
+		"supply": "1000000000000000000000", "USDC balance": "499999999", "USDC needed": "500000000",
+	}, v.Values)
+	v = invariants[1].Check(broken)
+	require.NotNil(t, v)
+	assert.Equal(t, eve.Hex(), v.Values["holder"])
+	assert.NotNil(t, invariants[2].Check(broken))
+
+	_, err = Config{Specs: "missing.spec"}.Invariants()
+	assert.Error(t, err)
+	_, err = Config{Roles: map[string][]common.Address{"owner": {owner}}}.Invariants()
+	assert.Error(t, err)
+	_, err = Config{}.Invariants()
+	assert.Error(t, err)
+}
+
+func TestEngine(t *testing.T) {
+	invariants, err := Config{Backed: true}.Invariants()
+	require.NoError(t, err)
+	current := state(500e6, owner, false)
+	var readErr error
+	sink := &recorder{}
+	e := &Engine{
+		Read:       func(ctx context.Context) (*metrics.State, error) { return current, readErr },
+		Invariants: invariants,
+		Sinks:      []Sink{sink},
+	}
+	ctx := context.Background()
+
+	e.Step(ctx)
+	assert.Empty(t, sink.alerts)
+
+	// A violation fires once, however long it lasts.
+	current = state(1, owner, false)
+	e.Step(ctx)
+	e.Step(ctx)
+	require.Len(t, sink.alerts, 1)
+	assert.Equal(t, "backed", sink.alerts[0].Invariant)
+	assert.False(t, sink.alerts[0].Resolved)
+
+	// An alert that could not be delivered is retried.
+	current = state(500e6, owner, false)
+	sink.fail = true
+	e.Step(ctx)
+	sink.fail = false
+	e.Step(ctx)
+	require.Len(t, sink.alerts, 2)
+	assert.True(t, sink.alerts[1].Resolved)
+
+	// Failing to read the chain alerts after three tries.
+	readErr = errors.New("connection refused")
+	e.Step(ctx)
+	e.Step(ctx)
+	assert.Len(t, sink.alerts, 2)
+	e.Step(ctx)
+	require.Len(t, sink.alerts, 3)
+	assert.Equal(t, "read", sink.alerts[2].Invariant)
+	readErr = nil
+	e.Step(ctx)
+	require.Len(t, sink.alerts, 4)
+	assert.True(t, sink.alerts[3].Resolved)
+}
+
+func TestPagerDuty(t *testing.T) {
+	var events []pagerDutyEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event pagerDutyEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events = append(events, event)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	p := &PagerDuty{RoutingKey: "key", Severity: "critical", Network: "ropsten", URL: server.URL, HTTP: server.Client()}
+	ctx := context.Background()
+
+	v := Violation{Invariant: "paused", Summary: "the Reserve is paused", Values: map[string]string{"paused": "true"}}
+	require.NoError(t, p.Send(ctx, Alert{Violation: v, Block: 3}))
+	require.NoError(t, p.Send(ctx, Alert{Violation: Violation{Invariant: "paused"}, Resolved: true}))
+	require.Len(t, events, 2)
+	assert.Equal(t, "trigger", events[0].EventAction)
+	assert.Equal(t, "rsv/ropsten/paused", events[0].DedupKey)
+	assert.Equal(t, map[string]string{"paused": "true"}, events[0].Payload.CustomDetails)
+	assert.Equal(t, "resolve", events[1].EventAction)
+	assert.Equal(t, events[0].DedupKey, events[1].DedupKey)
+	assert.Nil(t, events[1].Payload)
+}
@@ -0,0 +1,123 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/emergency"
+)
+
+// Webhooks sends alerts as chat messages, through an emergency.Notifier.
+type Webhooks struct {
+	Notifier *emergency.Notifier
+	Network  string
+}
+
+// Send posts a.Text to every webhook.
+func (w *Webhooks) Send(ctx context.Context, a Alert) error {
+	return w.Notifier.Notify(ctx, a.Text(w.Network))
+}
+
+// PagerDutyEventsURL is the PagerDuty Events API v2 endpoint.
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyConfig configures the PagerDuty sink. The routing key is a credential, so the config
+// names an environment variable holding it.
+type PagerDutyConfig struct {
+	RoutingKeyEnv string `json:"routingKeyEnv"`
+
+	// Severity of triggered incidents (default "critical").
+	Severity string `json:"severity,omitempty"`
+}
+
+// PagerDuty triggers and resolves PagerDuty incidents, one per invariant and network.
+type PagerDuty struct {
+	RoutingKey string
+	Severity   string
+	Network    string
+	URL        string
+	HTTP       *http.Client
+}
+
+// NewPagerDuty returns the PagerDuty sink that c configures.
+func NewPagerDuty(c PagerDutyConfig, network string) (*PagerDuty, error) {
+	if c.RoutingKeyEnv == "" {
+		return nil, errors.New("config: pagerDuty has no routingKeyEnv")
+	}
+	key := os.Getenv(c.RoutingKeyEnv)
+	if key == "" {
+		return nil, errors.Errorf("environment variable %v (pagerDuty routing key) is empty", c.RoutingKeyEnv)
+	}
+	severity := c.Severity
+	if severity == "" {
+		severity = "critical"
+	}
+	return &PagerDuty{
+		RoutingKey: key,
+		Severity:   severity,
+		Network:    network,
+		URL:        PagerDutyEventsURL,
+		HTTP:       &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// Send triggers or resolves the incident for a's invariant.
+func (p *PagerDuty) Send(ctx context.Context, a Alert) error {
+	event := pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    "rsv/" + p.Network + "/" + a.Invariant,
+	}
+	if a.Resolved {
+		event.EventAction = "resolve"
+	} else {
+		event.Payload = &pagerDutyPayload{
+			Summary:       a.Text(p.Network),
+			Source:        "rsv-" + p.Network,
+			Severity:      p.Severity,
+			CustomDetails: a.Values,
+		}
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "encoding PagerDuty event")
+	}
+	req, err := http.NewRequest(http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "building request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.HTTP.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "posting PagerDuty event")
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("PagerDuty: HTTP %v", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/reserve-protocol/rsv-beta/ops/indexer"
+	"github.com/reserve-protocol/rsv-beta/ops/safe"
+)
+
+// AdminOp is a transaction that made privileged operations, in /v1/admin.
+type AdminOp struct {
+	Time   time.Time      `json:"time"`
+	Block  uint64         `json:"block"`
+	TxHash common.Hash    `json:"txHash"`
+	Events []string       `json:"events"`
+	Sender common.Address `json:"sender"`
+	To     common.Address `json:"to"`
+	Method string         `json:"method,omitempty"`
+
+	Safe *AdminSafe `json:"safe,omitempty"`
+}
+
+// AdminSafe is how the owners of a Safe approved an AdminOp.
+type AdminSafe struct {
+	Address    common.Address   `json:"address"`
+	SafeTxHash common.Hash      `json:"safeTxHash"`
+	Signers    []common.Address `json:"signers"`
+
+	// Nonce, Proposer, Threshold, and Confirmations are from the Safe Transaction Service.
+	Nonce         *uint64             `json:"nonce,omitempty"`
+	Proposer      *common.Address     `json:"proposer,omitempty"`
+	Threshold     int                 `json:"threshold,omitempty"`
+	Confirmations []safe.Confirmation `json:"confirmations,omitempty"`
+
+	Link string `json:"link,omitempty"`
+}
+
+// AdminOps is the response of /v1/admin.
+type AdminOps struct {
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Operations []AdminOp `json:"operations"`
+}
+
+// admin serves the audit trail of privileged operations recorded by the indexer in blocks from
+// ?from= to ?to= (default: all of them), as JSON or, with ?format=csv, as CSV. The trail is not
+// paged; privileged operations are few.
+func (s *Server) admin(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	from, err := parseTime(r, "from", time.Unix(0, 0))
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseTime(r, "to", time.Now())
+	if err != nil {
+		return nil, err
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		return nil, badRequest("format must be json or csv, not %q", format)
+	}
+
+	ops, err := s.Store.AdminOps(r.Context(), from, to)
+	if err != nil {
+		return nil, err
+	}
+	if format == "csv" {
+		return csvBody(func(w io.Writer) error { return indexer.WriteAdminOpsCSV(w, ops) }), nil
+	}
+	result := AdminOps{From: from.UTC(), To: to.UTC(), Operations: []AdminOp{}}
+	for _, op := range ops {
+		a := AdminOp{Time: op.Time, Block: op.Block, TxHash: op.TxHash, Events: op.Events, Sender: op.Sender, To: op.To, Method: op.Method}
+		if sa := op.Safe; sa != nil {
+			a.Safe = &AdminSafe{Address: sa.Address, SafeTxHash: sa.SafeTxHash, Signers: sa.Signers, Link: sa.Link}
+			if a.Safe.Signers == nil {
+				a.Safe.Signers = []common.Address{}
+			}
+			if p := sa.Proposal; p != nil {
+				nonce := p.Nonce
+				a.Safe.Nonce, a.Safe.Threshold, a.Safe.Confirmations = &nonce, p.ConfirmationsRequired, p.Confirmations
+				if p.Proposer != (common.Address{}) {
+					proposer := p.Proposer
+					a.Safe.Proposer = &proposer
+				}
+			}
+		}
+		result.Operations = append(result.Operations, a)
+	}
+	return result, nil
+}
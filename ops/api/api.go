@@ -0,0 +1,474 @@
+// Package api serves the state of an RSV deployment over HTTP as JSON, for exchanges and
+// dashboards: the supply and switches, the basket and the Vault's collateral, holder balances,
+// transfer history, the Manager's proposals, time series of the supply and backing, what the
+// operator keys spend on gas, and the audit trail of privileged operations.
+//
+// The supply and basket are read from the chain, through a metrics.Reader, every Interval. The
+// rest comes from the database of an indexer following the same deployment: balances are
+// replayed from its Transfer events as they arrive, and history, proposals, time series, gas
+// spending, and the audit trail are read from it per request. Lists are paged: each page has up
+// to limit items (default 100, at most 1000), and when there are more, a "next" cursor to pass
+// as ?cursor= for the next page.
+//
+// The operators' gas spending is also served to Prometheus at /metrics. Every request but
+// /healthz and /metrics needs an API key, as the X-API-Key header or an
+// "Authorization: Bearer <key>" header.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/holders"
+	"github.com/reserve-protocol/rsv-beta/ops/indexer"
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+)
+
+// Page sizes.
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Server is the API.
+type Server struct {
+	// Read reads the deployment's state from the chain.
+	Read func(ctx context.Context) (*metrics.State, error)
+
+	// Store is the indexer's database, and Indexer the name of the indexer whose progress to
+	// report.
+	Store   *indexer.Store
+	Indexer string
+
+	// Keys maps each accepted API key to the name of its client, for the log.
+	Keys map[string]string
+
+	Interval time.Duration
+
+	mu      sync.Mutex
+	state   *metrics.State
+	holders *holders.State
+	last    *indexer.Event // the last Transfer replayed into holders
+}
+
+// Run refreshes the chain state and balances every Interval until ctx is done.
+func (s *Server) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		s.Refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh reads the chain state and replays new Transfer events once. On failure, the previous
+// state is kept.
+func (s *Server) Refresh(ctx context.Context) {
+	if state, err := s.Read(ctx); err != nil {
+		log.Printf("api: reading chain: %v", err)
+	} else {
+		s.mu.Lock()
+		s.state = state
+		s.mu.Unlock()
+	}
+	if err := s.replay(ctx); err != nil {
+		log.Printf("api: replaying transfers: %v", err)
+	}
+}
+
+// replay brings the balances up to date with the indexer. If the last event replayed has since
+// been deleted, as the indexer does with events of orphaned blocks, it starts over.
+func (s *Server) replay(ctx context.Context) error {
+	s.mu.Lock()
+	state, last := s.holders, s.last
+	s.mu.Unlock()
+
+	// Read the indexer's progress first: every event up to it is then in the database.
+	var indexed uint64
+	st, err := s.Store.State(ctx, s.Indexer)
+	if err != nil {
+		return err
+	}
+	if st != nil {
+		indexed, _ = st.Last()
+	}
+
+	var from uint64
+	if last != nil {
+		from = last.Block
+	}
+	events, err := s.Store.Events(ctx, "Reserve", "Transfer", from)
+	if err != nil {
+		return err
+	}
+	if last != nil {
+		found := -1
+		for i, e := range events {
+			if e.TxHash == last.TxHash && e.LogIndex == last.LogIndex {
+				found = i
+				break
+			}
+		}
+		if found >= 0 {
+			events = events[found+1:]
+		} else {
+			log.Printf("api: transfer %v of tx %v is gone; replaying every transfer", last.LogIndex, last.TxHash.Hex())
+			last = nil
+			if events, err = s.Store.Events(ctx, "Reserve", "Transfer", 0); err != nil {
+				return err
+			}
+		}
+	}
+	if last == nil {
+		state = holders.NewState()
+	} else {
+		// Replay into a copy, so that a failure part way leaves the served balances intact.
+		state = state.Copy()
+	}
+	for i := range events {
+		l, err := events[i].Log()
+		if err != nil {
+			return err
+		}
+		if err := state.Apply(l); err != nil {
+			return err
+		}
+		state.Block = l.BlockNumber
+		last = &events[i]
+	}
+	if indexed > state.Block {
+		state.Block = indexed
+	}
+
+	s.mu.Lock()
+	s.holders, s.last = state, last
+	s.mu.Unlock()
+	return nil
+}
+
+// ServeHTTP serves the API.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/healthz" {
+		s.healthz(w, r)
+		return
+	}
+	if r.URL.Path == "/metrics" {
+		s.serveMetrics(w, r)
+		return
+	}
+	client, ok := s.authenticate(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "missing or unknown API key")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "only GET is supported")
+		return
+	}
+	var handler func(http.ResponseWriter, *http.Request) (interface{}, error)
+	switch path := r.URL.Path; {
+	case path == "/v1/supply":
+		handler = s.supply
+	case path == "/v1/basket":
+		handler = s.basket
+	case path == "/v1/holders":
+		handler = s.holderList
+	case strings.HasPrefix(path, "/v1/holders/"):
+		handler = s.holder
+	case path == "/v1/transfers":
+		handler = s.transfers
+	case path == "/v1/proposals":
+		handler = s.proposals
+	case path == "/v1/series":
+		handler = s.series
+	case path == "/v1/gas":
+		handler = s.gas
+	case path == "/v1/admin":
+		handler = s.admin
+	default:
+		writeError(w, http.StatusNotFound, "no such endpoint")
+		return
+	}
+	result, err := handler(w, r)
+	if err != nil {
+		if e, ok := err.(*httpError); ok {
+			writeError(w, e.status, e.message)
+			return
+		}
+		log.Printf("api: %v %v for %v: %v", r.Method, r.URL, client, err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if body, ok := result.(csvBody); ok {
+		w.Header().Set("Content-Type", "text/csv")
+		if err := body(w); err != nil {
+			log.Printf("api: writing response: %v", err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// csvBody is a result that handlers write as CSV rather than JSON.
+type csvBody func(w io.Writer) error
+
+// authenticate returns the name of the client whose key r carries.
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	key := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); key == "" && strings.HasPrefix(auth, "Bearer ") {
+		key = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if key == "" {
+		return "", false
+	}
+	// Compare against every key, in constant time, so that timing reveals nothing about them.
+	client, ok := "", false
+	for k, name := range s.Keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			client, ok = name, true
+		}
+	}
+	return client, ok
+}
+
+// httpError is an error to report to the client as is.
+type httpError struct {
+	status  int
+	message string
+}
+
+func (e *httpError) Error() string { return e.message }
+
+func badRequest(format string, args ...interface{}) error {
+	return &httpError{http.StatusBadRequest, errors.Errorf(format, args...).Error()}
+}
+
+var errNotReady = &httpError{http.StatusServiceUnavailable, "the chain has not been read yet"}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("api: writing response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// limit parses the ?limit= of r.
+func limit(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return DefaultLimit, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > MaxLimit {
+		return 0, badRequest("limit must be from 1 to %v", MaxLimit)
+	}
+	return n, nil
+}
+
+// offset parses an offset ?cursor= of r.
+func offset(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("cursor")
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, badRequest("bad cursor %q", v)
+	}
+	return n, nil
+}
+
+// page returns the bounds of the page of a list of n items, and the cursor of the next page.
+func page(n, offset, limit int) (int, int, string) {
+	if offset > n {
+		offset = n
+	}
+	end := offset + limit
+	if end >= n {
+		return offset, n, ""
+	}
+	return offset, end, strconv.Itoa(end)
+}
+
+// ratio returns r, or nil if it is infinite, which JSON can't express.
+func ratio(r float64) *float64 {
+	if math.IsInf(r, 0) {
+		return nil
+	}
+	return &r
+}
+
+func (s *Server) chainState() (*metrics.State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == nil {
+		return nil, errNotReady
+	}
+	return s.state, nil
+}
+
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.chainState(); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Supply is the response of /v1/supply.
+type Supply struct {
+	Block    uint64 `json:"block"`
+	Supply   string `json:"supply"` // attoRSV
+	Decimals uint8  `json:"decimals"`
+
+	Paused           bool `json:"paused"`
+	TransfersPaused  bool `json:"transfersPaused"`
+	IssuancePaused   bool `json:"issuancePaused"`
+	RedemptionPaused bool `json:"redemptionPaused"`
+	Emergency        bool `json:"emergency"`
+
+	// Collateralization is the smallest ratio of the Vault's balance of a basket token to the
+	// balance needed to back the supply; it is absent while there is no supply to back.
+	Collateralization *float64 `json:"collateralization,omitempty"`
+}
+
+func (s *Server) supply(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	state, err := s.chainState()
+	if err != nil {
+		return nil, err
+	}
+	return Supply{
+		Block:             state.Block,
+		Supply:            state.Supply.String(),
+		Decimals:          state.RSVDecimals,
+		Paused:            state.Paused,
+		TransfersPaused:   state.TransfersPaused,
+		IssuancePaused:    state.IssuancePaused,
+		RedemptionPaused:  state.RedemptionPaused,
+		Emergency:         state.Emergency,
+		Collateralization: ratio(state.Collateralization()),
+	}, nil
+}
+
+// Basket is the response of /v1/basket.
+type Basket struct {
+	Block  uint64        `json:"block"`
+	Tokens []BasketToken `json:"tokens"`
+}
+
+// BasketToken is one token of a Basket.
+type BasketToken struct {
+	Address  common.Address `json:"address"`
+	Symbol   string         `json:"symbol,omitempty"`
+	Decimals uint8          `json:"decimals"`
+
+	Weight       string   `json:"weight"`       // aqToken/RSV
+	VaultBalance string   `json:"vaultBalance"` // qTokens
+	Needed       string   `json:"needed"`       // qTokens, to back the supply
+	Ratio        *float64 `json:"ratio,omitempty"`
+}
+
+func (s *Server) basket(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	state, err := s.chainState()
+	if err != nil {
+		return nil, err
+	}
+	b := Basket{Block: state.Block, Tokens: []BasketToken{}}
+	for _, t := range state.Tokens {
+		b.Tokens = append(b.Tokens, BasketToken{
+			Address:      t.Address,
+			Symbol:       t.Symbol,
+			Decimals:     t.Decimals,
+			Weight:       t.Weight.String(),
+			VaultBalance: t.Balance.String(),
+			Needed:       state.Needed(t).String(),
+			Ratio:        ratio(state.Ratio(t)),
+		})
+	}
+	return b, nil
+}
+
+// Balance is an address's RSV balance.
+type Balance struct {
+	Address common.Address `json:"address"`
+	Balance string         `json:"balance"` // attoRSV
+}
+
+// Holders is the response of /v1/holders: the indexed balances as of Block, largest first.
+type Holders struct {
+	Block   uint64    `json:"block"`
+	Supply  string    `json:"supply"` // attoRSV, as the indexed events add up
+	Holders []Balance `json:"holders"`
+	Next    string    `json:"next,omitempty"`
+}
+
+// HolderBalance is the response of /v1/holders/<address>.
+type HolderBalance struct {
+	Block uint64 `json:"block"`
+	Balance
+}
+
+func (s *Server) balances() (*holders.State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.holders == nil {
+		return nil, &httpError{http.StatusServiceUnavailable, "balances have not been replayed yet"}
+	}
+	return s.holders, nil
+}
+
+func (s *Server) holderList(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	n, err := limit(r)
+	if err != nil {
+		return nil, err
+	}
+	off, err := offset(r)
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.balances()
+	if err != nil {
+		return nil, err
+	}
+	all := state.Holders()
+	start, end, next := page(len(all), off, n)
+	result := Holders{Block: state.Block, Supply: state.Supply.String(), Holders: []Balance{}, Next: next}
+	for _, h := range all[start:end] {
+		result.Holders = append(result.Holders, Balance{h.Address, h.Balance.String()})
+	}
+	return result, nil
+}
+
+func (s *Server) holder(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	v := strings.TrimPrefix(r.URL.Path, "/v1/holders/")
+	if !common.IsHexAddress(v) {
+		return nil, badRequest("%q is not an address", v)
+	}
+	addr := common.HexToAddress(v)
+	state, err := s.balances()
+	if err != nil {
+		return nil, err
+	}
+	return HolderBalance{Block: state.Block, Balance: Balance{addr, state.Balance(addr).String()}}, nil
+}
@@ -0,0 +1,418 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/holders"
+	"github.com/reserve-protocol/rsv-beta/ops/indexer"
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/stream"
+)
+
+var (
+	alice = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	bob   = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	carol = common.HexToAddress("0x00000000000000000000000000000000000ca201")
+	usdc  = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+)
+
+func txHash(block uint64, index uint) common.Hash {
+	return common.BigToHash(big.NewInt(int64(block*100) + int64(index)))
+}
+
+func transfer(block uint64, index uint, from, to common.Address, value int64) indexer.Event {
+	topics, _ := json.Marshal([]common.Hash{holders.TransferTopic, from.Hash(), to.Hash()})
+	args, _ := json.Marshal(map[string]string{"from": from.Hex(), "to": to.Hex(), "value": big.NewInt(value).String()})
+	return indexer.Event{
+		Block:    block,
+		TxHash:   txHash(block, index),
+		LogIndex: index,
+		Contract: "Reserve",
+		Event:    "Transfer",
+		Args:     string(args),
+		Topics:   string(topics),
+		Data:     "0x" + hex.EncodeToString(common.LeftPadBytes(big.NewInt(value).Bytes(), 32)),
+	}
+}
+
+func managerEvent(block uint64, name string, args map[string]string) indexer.Event {
+	b, _ := json.Marshal(args)
+	return indexer.Event{
+		Block:    block,
+		TxHash:   txHash(block, 0),
+		Contract: "Manager",
+		Event:    name,
+		Args:     string(b),
+		Topics:   "[]",
+		Data:     "0x",
+	}
+}
+
+func saved(block uint64) stream.State {
+	return stream.State{Blocks: []stream.Block{{Number: block}}}
+}
+
+func testServer(t *testing.T) (*Server, func()) {
+	dir, err := ioutil.TempDir("", "api")
+	require.NoError(t, err)
+	store, err := indexer.OpenStore(indexer.SQLite, filepath.Join(dir, "events.db"))
+	require.NoError(t, err)
+	events := []indexer.Event{
+		transfer(2, 0, common.Address{}, alice, 100),
+		transfer(3, 1, alice, bob, 40),
+		transfer(5, 1, bob, carol, 10),
+		managerEvent(3, "WeightsProposed", map[string]string{"id": "0", "proposer": alice.Hex()}),
+		managerEvent(4, "SwapProposed", map[string]string{"id": "1", "proposer": bob.Hex()}),
+		managerEvent(5, "ProposalAccepted", map[string]string{"id": "0", "proposer": alice.Hex()}),
+		managerEvent(6, "ProposalExecuted", map[string]string{"id": "0", "proposer": alice.Hex(), "newBasket": usdc.Hex()}),
+		managerEvent(7, "ProposalsCleared", map[string]string{}),
+		managerEvent(8, "WeightsProposed", map[string]string{"id": "0", "proposer": carol.Hex()}),
+	}
+	require.NoError(t, store.Save(context.Background(), "test", events, saved(9)))
+
+	one := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	s := &Server{
+		Read: func(ctx context.Context) (*metrics.State, error) {
+			return &metrics.State{
+				Block:       12,
+				Supply:      one,
+				RSVDecimals: 18,
+				Emergency:   true,
+				Tokens: []metrics.Token{{
+					Address:  usdc,
+					Symbol:   "USDC",
+					Decimals: 6,
+					Weight:   new(big.Int).Mul(one, big.NewInt(1000000)),
+					Balance:  big.NewInt(2000000),
+				}},
+			}, nil
+		},
+		Store:   store,
+		Indexer: "test",
+		Keys:    map[string]string{"secret": "dashboard"},
+	}
+	return s, func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func get(t *testing.T, s *Server, path string, result interface{}) int {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.Header.Set("X-API-Key", "secret")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if result != nil && w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), result))
+	}
+	return w.Code
+}
+
+func TestAuth(t *testing.T) {
+	s, done := testServer(t)
+	defer done()
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, s, "/v1/supply", nil), "not read yet")
+	s.Refresh(context.Background())
+
+	for header, status := range map[string]int{
+		"":                   http.StatusUnauthorized,
+		"Bearer wrong":       http.StatusUnauthorized,
+		"Bearer sec":         http.StatusUnauthorized,
+		"Basic c2VjcmV0Og==": http.StatusUnauthorized,
+		"Bearer secret":      http.StatusOK,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/supply", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		assert.Equal(t, status, w.Code, header)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "health checks need no key")
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/supply", nil)
+	r.Header.Set("X-API-Key", "secret")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestChainEndpoints(t *testing.T) {
+	s, done := testServer(t)
+	defer done()
+	s.Refresh(context.Background())
+
+	var supply Supply
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/supply", &supply))
+	assert.Equal(t, "1000000000000000000", supply.Supply)
+	assert.True(t, supply.Emergency)
+	require.NotNil(t, supply.Collateralization)
+	assert.Equal(t, 2.0, *supply.Collateralization)
+
+	var basket Basket
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/basket", &basket))
+	require.Len(t, basket.Tokens, 1)
+	assert.Equal(t, "1000000", basket.Tokens[0].Needed)
+	assert.Equal(t, "2000000", basket.Tokens[0].VaultBalance)
+	assert.Equal(t, uint64(12), basket.Block)
+}
+
+func TestHolders(t *testing.T) {
+	s, done := testServer(t)
+	defer done()
+	ctx := context.Background()
+	s.Refresh(ctx)
+
+	var page Holders
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/holders?limit=2", &page))
+	assert.Equal(t, uint64(9), page.Block, "balances are as of the indexer's progress")
+	assert.Equal(t, []Balance{{alice, "60"}, {bob, "30"}}, page.Holders)
+	require.Equal(t, "2", page.Next)
+	page = Holders{}
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/holders?limit=2&cursor=2", &page))
+	assert.Equal(t, []Balance{{carol, "10"}}, page.Holders)
+	assert.Empty(t, page.Next)
+
+	var balance HolderBalance
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/holders/"+carol.Hex(), &balance))
+	assert.Equal(t, "10", balance.Balance.Balance)
+	assert.Equal(t, http.StatusBadRequest, get(t, s, "/v1/holders/carol", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, s, "/v1/holders?limit=0", nil))
+
+	// New transfers are replayed as the indexer stores them.
+	require.NoError(t, s.Store.Save(ctx, "test", []indexer.Event{transfer(10, 0, alice, carol, 5)}, saved(10)))
+	s.Refresh(ctx)
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/holders/"+carol.Hex(), &balance))
+	assert.Equal(t, "15", balance.Balance.Balance)
+	assert.Equal(t, uint64(10), balance.Block)
+
+	// If the indexer deletes the last transfer replayed, the balances are replayed again.
+	orphan := transfer(10, 0, alice, carol, 5)
+	orphan.Removed = true
+	require.NoError(t, s.Store.Save(ctx, "test", []indexer.Event{orphan}, saved(10)))
+	s.Refresh(ctx)
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/holders/"+carol.Hex(), &balance))
+	assert.Equal(t, "10", balance.Balance.Balance)
+}
+
+func TestTransfers(t *testing.T) {
+	s, done := testServer(t)
+	defer done()
+
+	var page Transfers
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/transfers?address="+bob.Hex()+"&limit=1", &page))
+	require.Len(t, page.Transfers, 1)
+	assert.Equal(t, Transfer{Block: 5, TxHash: txHash(5, 1), LogIndex: 1, From: bob, To: carol, Value: "10"}, page.Transfers[0])
+	assert.Equal(t, "5-1", page.Next)
+	next := page.Next
+	page = Transfers{}
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/transfers?address="+bob.Hex()+"&limit=1&cursor="+next, &page))
+	require.Len(t, page.Transfers, 1)
+	assert.Equal(t, alice, page.Transfers[0].From)
+	assert.Empty(t, page.Next)
+
+	page = Transfers{}
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/transfers", &page))
+	assert.Len(t, page.Transfers, 3)
+	assert.Equal(t, http.StatusBadRequest, get(t, s, "/v1/transfers?cursor=5", nil))
+}
+
+func TestProposals(t *testing.T) {
+	s, done := testServer(t)
+	defer done()
+
+	var page Proposals
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/proposals", &page))
+	require.Len(t, page.Proposals, 3)
+	assert.Equal(t, Proposal{ID: 0, Kind: "weights", Proposer: carol, Status: Created, ProposedBlock: 8, ProposedTx: txHash(8, 0)}, page.Proposals[0])
+	assert.Equal(t, Cleared, page.Proposals[1].Status)
+	assert.Equal(t, "swap", page.Proposals[1].Kind)
+	assert.Equal(t, uint64(7), page.Proposals[1].ClosedBlock)
+	executed := page.Proposals[2]
+	assert.Equal(t, Executed, executed.Status)
+	assert.Equal(t, uint64(5), executed.AcceptedBlock)
+	require.NotNil(t, executed.NewBasket)
+	assert.Equal(t, usdc, *executed.NewBasket)
+
+	page = Proposals{}
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/proposals?status=executed", &page))
+	assert.Len(t, page.Proposals, 1)
+	page = Proposals{}
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/proposals?limit=2&cursor=2", &page))
+	assert.Equal(t, []Proposal{executed}, page.Proposals)
+	assert.Equal(t, http.StatusBadRequest, get(t, s, "/v1/proposals?status=pending", nil))
+
+	// The proposer withdraws the last proposal, with a reason.
+	withdrawn := managerEvent(9, "ProposalCancelled", map[string]string{"id": "0", "proposer": carol.Hex(), "reason": "wrong weights"})
+	require.NoError(t, s.Store.Save(context.Background(), "test", []indexer.Event{withdrawn}, saved(10)))
+	page = Proposals{}
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/proposals?status=canceled", &page))
+	require.Len(t, page.Proposals, 1)
+	assert.Equal(t, uint64(9), page.Proposals[0].ClosedBlock)
+	assert.Equal(t, "wrong weights", page.Proposals[0].Reason)
+}
+
+// headers serves headers 600 seconds apart.
+type headers struct{}
+
+func (headers) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: number, Time: number.Uint64() * 600}, nil
+}
+
+func TestSeries(t *testing.T) {
+	s, done := testServer(t)
+	defer done()
+	series := &indexer.Series{Node: headers{}, Store: s.Store, Indexer: "test", Intervals: []string{"hour"}}
+	require.NoError(t, series.Step(context.Background()))
+
+	var result Series
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/series?interval=hour&from=1970-01-01", &result))
+	require.Len(t, result.Points, 1)
+	assert.Equal(t, SeriesPoint{Start: time.Unix(0, 0).UTC(), Block: 5, Supply: "100", Minted: "100", Burned: "0"}, result.Points[0])
+	result = Series{}
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/series", &result))
+	assert.Empty(t, result.Points, "no day has ended")
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/series?interval=hour&format=csv", nil)
+	r.Header.Set("X-API-Key", "secret")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, "start,block,supply,minted,burned\n1970-01-01T00:00:00Z,5,100,100,0\n", w.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, get(t, s, "/v1/series?interval=week", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, s, "/v1/series?from=yesterday", nil))
+}
+
+// gasNode serves blocks of transactions.
+type gasNode struct {
+	headers
+	txs map[uint64][]*types.Transaction
+}
+
+func (n gasNode) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	h, _ := n.HeaderByNumber(ctx, number)
+	return types.NewBlock(h, n.txs[number.Uint64()], nil, nil), nil
+}
+
+func (n gasNode) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	return &types.Receipt{GasUsed: 50000}, nil
+}
+
+func (n gasNode) NonceAt(ctx context.Context, account common.Address, number *big.Int) (uint64, error) {
+	var nonce uint64
+	for block, txs := range n.txs {
+		if block <= number.Uint64() {
+			nonce += uint64(len(txs))
+		}
+	}
+	return nonce, nil
+}
+
+func TestGas(t *testing.T) {
+	s, done := testServer(t)
+	defer done()
+	ctx := context.Background()
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tx, err := types.SignTx(types.NewTransaction(0, bob, new(big.Int), 50000, big.NewInt(2e9), nil), types.NewEIP155Signer(big.NewInt(1)), key)
+	require.NoError(t, err)
+	relayer := crypto.PubkeyToAddress(key.PublicKey)
+	node := gasNode{txs: map[uint64][]*types.Transaction{}}
+	g := &indexer.Gas{Node: node, Store: s.Store, Indexer: "test", ChainID: big.NewInt(1), Operators: []indexer.Operator{{Name: "relayer", Address: relayer}}}
+	require.NoError(t, g.Step(ctx))
+	node.txs[10] = []*types.Transaction{tx}
+	require.NoError(t, s.Store.Save(ctx, "test", nil, saved(10)))
+	require.NoError(t, g.Step(ctx))
+
+	var result Gas
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/gas", &result))
+	assert.Equal(t, []GasSpend{{Operator: "relayer", Address: relayer, Transactions: 1, Spent: "100000000000000"}}, result.Operators)
+	result = Gas{}
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/gas?from=1970-01-02", &result))
+	assert.Empty(t, result.Operators)
+
+	// Prometheus needs no key.
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `rsv_operator_gas_spent_eth_total{operator="relayer",address="`+relayer.Hex()+`"} 0.0001`+"\n")
+	assert.Contains(t, w.Body.String(), `rsv_operator_transactions_total{operator="relayer",address="`+relayer.Hex()+`"} 1`+"\n")
+}
+
+// adminNode serves the same transaction for every hash.
+type adminNode struct {
+	headers
+	tx *types.Transaction
+}
+
+func (n adminNode) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	return n.tx, false, nil
+}
+
+func (n adminNode) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	return &types.Receipt{}, nil
+}
+
+func TestAdmin(t *testing.T) {
+	s, done := testServer(t)
+	defer done()
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	manager := common.HexToAddress("0x0000000000000000000000000000000000000123")
+	tx, err := types.SignTx(types.NewTransaction(0, manager, new(big.Int), 50000, big.NewInt(2e9), nil), types.NewEIP155Signer(big.NewInt(1)), key)
+	require.NoError(t, err)
+	a := &indexer.Admin{
+		Node:      adminNode{tx: tx},
+		Store:     s.Store,
+		Indexer:   "test",
+		Contracts: []indexer.Contract{{Name: "Manager", Address: manager}},
+		ChainID:   big.NewInt(1),
+		Depth:     1,
+	}
+	require.NoError(t, a.Step(context.Background()))
+
+	var result AdminOps
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/admin", &result))
+	require.Len(t, result.Operations, 6)
+	assert.Equal(t, AdminOp{
+		Time:   time.Unix(3*600, 0).UTC(),
+		Block:  3,
+		TxHash: txHash(3, 0),
+		Events: []string{"Manager.WeightsProposed"},
+		Sender: crypto.PubkeyToAddress(key.PublicKey),
+		To:     manager,
+	}, result.Operations[0])
+	result = AdminOps{}
+	require.Equal(t, http.StatusOK, get(t, s, "/v1/admin?from=1970-01-01T01:00:00Z", &result))
+	require.Len(t, result.Operations, 3)
+	assert.Equal(t, uint64(6), result.Operations[0].Block)
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/admin?format=csv", nil)
+	r.Header.Set("X-API-Key", "secret")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "1970-01-01T00:30:00Z,3,"+txHash(3, 0).Hex()+",Manager.WeightsProposed,")
+	assert.Equal(t, http.StatusBadRequest, get(t, s, "/v1/admin?format=xml", nil))
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+)
+
+// GasSpend is what one operator key spent on gas, in /v1/gas.
+type GasSpend struct {
+	Operator     string         `json:"operator"`
+	Address      common.Address `json:"address"`
+	Transactions int            `json:"transactions"`
+	Spent        string         `json:"spent"` // wei
+}
+
+// Gas is the response of /v1/gas.
+type Gas struct {
+	From      time.Time  `json:"from"`
+	To        time.Time  `json:"to"`
+	Operators []GasSpend `json:"operators"`
+}
+
+// gas serves what each operator key tracked by the indexer spent on gas in blocks from ?from=
+// to ?to= (default: all of them).
+func (s *Server) gas(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	from, err := parseTime(r, "from", time.Unix(0, 0))
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseTime(r, "to", time.Now())
+	if err != nil {
+		return nil, err
+	}
+	totals, err := s.Store.GasTotals(r.Context(), from, to)
+	if err != nil {
+		return nil, err
+	}
+	result := Gas{From: from.UTC(), To: to.UTC(), Operators: []GasSpend{}}
+	for _, t := range totals {
+		result.Operators = append(result.Operators, GasSpend{Operator: t.Operator, Address: t.Address, Transactions: t.Transactions, Spent: t.Cost.String()})
+	}
+	return result, nil
+}
+
+// The API's metrics.
+var (
+	gasSpent = metrics.Definition{Name: "rsv_operator_gas_spent_eth_total", Kind: "counter", Help: "ETH paid for gas by each operator key, since the indexer started tracking it.",
+		Panel: &metrics.Panel{Row: "Operator gas", Title: "Gas spend per day (ETH)", Expr: "increase(rsv_operator_gas_spent_eth_total[1d])", Legend: "{{operator}}"}}
+	gasTransactions = metrics.Definition{Name: "rsv_operator_transactions_total", Kind: "counter", Help: "Transactions sent by each operator key, since the indexer started tracking it.",
+		Panel: &metrics.Panel{Row: "Operator gas", Title: "Operator transactions per day", Expr: "increase(rsv_operator_transactions_total[1d])", Legend: "{{operator}}"}}
+)
+
+// Definitions are the API's metrics.
+var Definitions = []metrics.Definition{gasSpent, gasTransactions}
+
+var weiPerEth = new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil))
+
+// serveMetrics serves the operators' gas spending as Prometheus metrics.
+func (s *Server) serveMetrics(rw http.ResponseWriter, r *http.Request) {
+	totals, err := s.Store.GasTotals(r.Context(), time.Unix(0, 0), time.Now())
+	if err != nil {
+		log.Printf("api: reading gas totals: %v", err)
+		http.Error(rw, "internal error", http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w := metrics.NewTextWriter(rw)
+	w.Define(gasSpent)
+	for _, t := range totals {
+		eth, _ := new(big.Float).Quo(new(big.Float).SetInt(t.Cost), weiPerEth).Float64()
+		w.Sample(gasSpent.Name, []string{"operator", t.Operator, "address", t.Address.Hex()}, eth)
+	}
+	w.Define(gasTransactions)
+	for _, t := range totals {
+		w.Sample(gasTransactions.Name, []string{"operator", t.Operator, "address", t.Address.Hex()}, float64(t.Transactions))
+	}
+	if err := w.Err(); err != nil {
+		log.Printf("api: writing metrics: %v", err)
+	}
+}
@@ -0,0 +1,338 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/indexer"
+)
+
+// Transfer is one RSV transfer. Mints are from, and burns to, the zero address.
+type Transfer struct {
+	Block    uint64         `json:"block"`
+	TxHash   common.Hash    `json:"txHash"`
+	LogIndex uint           `json:"logIndex"`
+	From     common.Address `json:"from"`
+	To       common.Address `json:"to"`
+	Value    string         `json:"value"` // attoRSV
+}
+
+// Transfers is the response of /v1/transfers: transfers, newest first.
+type Transfers struct {
+	Transfers []Transfer `json:"transfers"`
+	Next      string     `json:"next,omitempty"`
+}
+
+// args decodes the arguments of an indexed event.
+func args(e indexer.Event) (map[string]string, error) {
+	var result map[string]string
+	err := json.Unmarshal([]byte(e.Args), &result)
+	return result, errors.Wrapf(err, "parsing arguments of log %v of tx %v", e.LogIndex, e.TxHash.Hex())
+}
+
+// transfers serves the transfers to or from ?address=, or every transfer if it is not given.
+// Its cursors are the position of the last transfer of the page, as <block>-<log index>.
+func (s *Server) transfers(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	n, err := limit(r)
+	if err != nil {
+		return nil, err
+	}
+	q := indexer.Query{Contract: "Reserve", Event: "Transfer", Limit: n + 1}
+	if v := r.URL.Query().Get("address"); v != "" {
+		if !common.IsHexAddress(v) {
+			return nil, badRequest("%q is not an address", v)
+		}
+		q.Topic = common.HexToAddress(v).Hash()
+	}
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		parts := strings.Split(v, "-")
+		if len(parts) != 2 {
+			return nil, badRequest("bad cursor %q", v)
+		}
+		block, err1 := strconv.ParseUint(parts[0], 10, 64)
+		index, err2 := strconv.ParseUint(parts[1], 10, 32)
+		if err1 != nil || err2 != nil {
+			return nil, badRequest("bad cursor %q", v)
+		}
+		q.Before = &indexer.Position{Block: block, LogIndex: uint(index)}
+	}
+
+	// One event beyond the page says whether there is another page.
+	events, err := s.Store.Page(r.Context(), q)
+	if err != nil {
+		return nil, err
+	}
+	result := Transfers{Transfers: []Transfer{}}
+	if len(events) > n {
+		events = events[:n]
+		last := events[n-1]
+		result.Next = strconv.FormatUint(last.Block, 10) + "-" + strconv.FormatUint(uint64(last.LogIndex), 10)
+	}
+	for _, e := range events {
+		a, err := args(e)
+		if err != nil {
+			return nil, err
+		}
+		result.Transfers = append(result.Transfers, Transfer{
+			Block:    e.Block,
+			TxHash:   e.TxHash,
+			LogIndex: e.LogIndex,
+			From:     common.HexToAddress(a["from"]),
+			To:       common.HexToAddress(a["to"]),
+			Value:    a["value"],
+		})
+	}
+	return result, nil
+}
+
+// Proposal statuses.
+const (
+	Created  = "created"
+	Accepted = "accepted"
+	Canceled = "canceled"
+	Executed = "executed"
+
+	// Expired proposals were still pending at their deadline, and then expired.
+	Expired = "expired"
+
+	// Cleared proposals were created or accepted when the operator cleared the Manager's
+	// proposals, which can then no longer be accepted or executed.
+	Cleared = "cleared"
+)
+
+// Proposal is one of the Manager's proposals to change the basket.
+type Proposal struct {
+	// ID is the Manager's id of the proposal. Clearing the proposals starts the ids from 0
+	// again, so ids may repeat; ProposedTx never does.
+	ID       uint64         `json:"id"`
+	Kind     string         `json:"kind"` // "weights", "swap", or "rebalance"
+	Proposer common.Address `json:"proposer"`
+	Status   string         `json:"status"`
+
+	ProposedBlock uint64      `json:"proposedBlock"`
+	ProposedTx    common.Hash `json:"proposedTx"`
+	AcceptedBlock uint64      `json:"acceptedBlock,omitempty"`
+
+	// ClosedBlock is when the proposal was canceled, executed, expired, or cleared.
+	ClosedBlock uint64 `json:"closedBlock,omitempty"`
+
+	// Reason is what the proposer gave, if it canceled the proposal itself.
+	Reason string `json:"reason,omitempty"`
+
+	// NewBasket is the basket that an executed proposal made.
+	NewBasket *common.Address `json:"newBasket,omitempty"`
+}
+
+// Proposals is the response of /v1/proposals: proposals, newest first.
+type Proposals struct {
+	Proposals []Proposal `json:"proposals"`
+	Next      string     `json:"next,omitempty"`
+}
+
+// proposalEvents are the Manager events that make up a proposal's history.
+var proposalEvents = []string{
+	"WeightsProposed", "SwapProposed", "RebalanceProposed", "ProposalAccepted", "ProposalCanceled", "ProposalCancelled", "ProposalVetoed", "ProposalExpired", "ProposalExecuted", "ProposalsCleared",
+}
+
+// loadProposals replays the history of every proposal from the Manager's indexed events.
+func (s *Server) loadProposals(ctx context.Context) ([]Proposal, error) {
+	var events []indexer.Event
+	for _, name := range proposalEvents {
+		e, err := s.Store.Events(ctx, "Manager", name, 0)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e...)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Block != events[j].Block {
+			return events[i].Block < events[j].Block
+		}
+		return events[i].LogIndex < events[j].LogIndex
+	})
+
+	var proposals []Proposal
+	open := map[uint64]int{} // open proposals by id, as indexes into proposals
+	for _, e := range events {
+		if e.Event == "ProposalsCleared" {
+			for _, i := range open {
+				proposals[i].Status, proposals[i].ClosedBlock = Cleared, e.Block
+			}
+			open = map[uint64]int{}
+			continue
+		}
+		a, err := args(e)
+		if err != nil {
+			return nil, err
+		}
+		id, err := strconv.ParseUint(a["id"], 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("bad proposal id %q in log %v of tx %v", a["id"], e.LogIndex, e.TxHash.Hex())
+		}
+		switch e.Event {
+		case "WeightsProposed", "SwapProposed", "RebalanceProposed":
+			kind := "weights"
+			switch e.Event {
+			case "SwapProposed":
+				kind = "swap"
+			case "RebalanceProposed":
+				kind = "rebalance"
+			}
+			open[id] = len(proposals)
+			proposals = append(proposals, Proposal{
+				ID:            id,
+				Kind:          kind,
+				Proposer:      common.HexToAddress(a["proposer"]),
+				Status:        Created,
+				ProposedBlock: e.Block,
+				ProposedTx:    e.TxHash,
+			})
+			continue
+		}
+		i, ok := open[id]
+		if !ok {
+			// The indexer started after the proposal was made.
+			continue
+		}
+		p := &proposals[i]
+		switch e.Event {
+		case "ProposalAccepted":
+			p.Status, p.AcceptedBlock = Accepted, e.Block
+		case "ProposalCanceled", "ProposalVetoed":
+			p.Status, p.ClosedBlock = Canceled, e.Block
+			delete(open, id)
+		case "ProposalCancelled":
+			p.Status, p.ClosedBlock, p.Reason = Canceled, e.Block, a["reason"]
+			delete(open, id)
+		case "ProposalExpired":
+			p.Status, p.ClosedBlock = Expired, e.Block
+			delete(open, id)
+		case "ProposalExecuted":
+			basket := common.HexToAddress(a["newBasket"])
+			p.Status, p.ClosedBlock, p.NewBasket = Executed, e.Block, &basket
+			delete(open, id)
+		}
+	}
+	return proposals, nil
+}
+
+// proposals serves the proposals with ?status=, or every proposal if it is not given.
+func (s *Server) proposals(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	n, err := limit(r)
+	if err != nil {
+		return nil, err
+	}
+	off, err := offset(r)
+	if err != nil {
+		return nil, err
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", Created, Accepted, Canceled, Executed, Expired, Cleared:
+	default:
+		return nil, badRequest("unknown status %q", status)
+	}
+
+	all, err := s.loadProposals(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	var matching []Proposal
+	for i := len(all) - 1; i >= 0; i-- {
+		if status == "" || all[i].Status == status {
+			matching = append(matching, all[i])
+		}
+	}
+	start, end, next := page(len(matching), off, n)
+	return Proposals{Proposals: append([]Proposal{}, matching[start:end]...), Next: next}, nil
+}
+
+// SeriesPoint is one bucket of a time series.
+type SeriesPoint struct {
+	Start  time.Time `json:"start"`
+	Block  uint64    `json:"block"`
+	Supply string    `json:"supply"` // attoRSV
+	Minted string    `json:"minted"`
+	Burned string    `json:"burned"`
+
+	Backing []SeriesBacking `json:"backing,omitempty"`
+}
+
+// SeriesBacking is the Vault's balance of one basket token at a SeriesPoint.
+type SeriesBacking struct {
+	Token   common.Address `json:"token"`
+	Symbol  string         `json:"symbol,omitempty"`
+	Balance string         `json:"balance"` // qTokens
+	Needed  string         `json:"needed"`  // qTokens
+}
+
+// Series is the response of /v1/series.
+type Series struct {
+	Interval string        `json:"interval"`
+	Points   []SeriesPoint `json:"points"`
+}
+
+// parseTime parses a ?from= or ?to= time, as RFC 3339 or a date.
+func parseTime(r *http.Request, key string, otherwise time.Time) (time.Time, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return otherwise, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, badRequest("%v must be a date or an RFC 3339 time, not %q", key, v)
+}
+
+// series serves the ?interval= time series (default "day") materialized by the indexer, for
+// buckets starting from ?from= to ?to= (default: all of them), as JSON or, with ?format=csv, as
+// CSV. The series are not paged; a year of hours is under 9000 points.
+func (s *Server) series(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = "day"
+	}
+	if _, ok := indexer.Intervals[interval]; !ok {
+		return nil, badRequest("unknown interval %q", interval)
+	}
+	from, err := parseTime(r, "from", time.Unix(0, 0))
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseTime(r, "to", time.Now())
+	if err != nil {
+		return nil, err
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		return nil, badRequest("format must be json or csv, not %q", format)
+	}
+
+	points, err := s.Store.Points(r.Context(), interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if format == "csv" {
+		return csvBody(func(w io.Writer) error { return indexer.WritePointsCSV(w, points) }), nil
+	}
+	result := Series{Interval: interval, Points: []SeriesPoint{}}
+	for _, p := range points {
+		sp := SeriesPoint{Start: p.Start, Block: p.Block, Supply: p.Supply.String(), Minted: p.Minted.String(), Burned: p.Burned.String()}
+		for _, b := range p.Backing {
+			sp.Backing = append(sp.Backing, SeriesBacking{Token: b.Token, Symbol: b.Symbol, Balance: b.Balance.String(), Needed: b.Needed.String()})
+		}
+		result.Points = append(result.Points, sp)
+	}
+	return result, nil
+}
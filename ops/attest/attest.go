@@ -0,0 +1,202 @@
+// Package attest builds proof-of-reserve attestations: signed statements, as of one block, of
+// the RSV supply, the basket, the Vault's holdings of each basket token, and the code of the
+// contracts involved, for the issuer to publish.
+//
+// A Document holds the attestation and its signature: an Ethereum signed message (EIP-191, as
+// personal_sign makes) over the attestation's compact JSON, as json.Compact or JSON.stringify
+// renders the "attestation" of the document, so that anyone can check it with common wallet
+// tools as well as with Verify.
+package attest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/relay"
+	"github.com/reserve-protocol/rsv-beta/ops/signer"
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+// Attestation is what the issuer attests to.
+type Attestation struct {
+	Network   string      `json:"network"`
+	ChainID   uint64      `json:"chainId"`
+	Block     uint64      `json:"block"`
+	BlockHash common.Hash `json:"blockHash"`
+	BlockTime time.Time   `json:"blockTime"`
+
+	// Supply is the RSV total supply, in attoRSV.
+	Supply string `json:"supply"`
+
+	Collateral []Collateral `json:"collateral"`
+
+	// Collateralization is the smallest ratio of the Vault's balance to the balance needed,
+	// over the basket; at least 1 means the Vault can redeem the whole supply.
+	Collateralization string `json:"collateralization"`
+
+	Contracts []Contract `json:"contracts"`
+
+	// Statement is the issuer's own words, if any.
+	Statement string `json:"statement,omitempty"`
+}
+
+// Collateral is one basket token.
+type Collateral struct {
+	Token    common.Address `json:"token"`
+	Symbol   string         `json:"symbol,omitempty"`
+	Decimals uint8          `json:"decimals"`
+
+	// Weight is the basket weight, in aqTokens per RSV.
+	Weight string `json:"weight"`
+
+	// Balance is the Vault's balance, and Needed the balance needed to back the supply, in
+	// qTokens.
+	Balance string `json:"balance"`
+	Needed  string `json:"needed"`
+}
+
+// Contract is a contract involved, with the hash of its code at the block.
+type Contract struct {
+	Name     string         `json:"name"`
+	Address  common.Address `json:"address"`
+	CodeHash common.Hash    `json:"codeHash"`
+}
+
+// New returns the attestation of st, read at header, and the contracts.
+func New(network string, chainID uint64, header *types.Header, st *metrics.State, contracts []Contract) *Attestation {
+	a := &Attestation{
+		Network:           network,
+		ChainID:           chainID,
+		Block:             st.Block,
+		BlockHash:         header.Hash(),
+		BlockTime:         time.Unix(int64(header.Time), 0).UTC(),
+		Supply:            st.Supply.String(),
+		Collateral:        []Collateral{},
+		Collateralization: strconv.FormatFloat(st.Collateralization(), 'f', 6, 64),
+		Contracts:         contracts,
+	}
+	for _, t := range st.Tokens {
+		a.Collateral = append(a.Collateral, Collateral{
+			Token:    t.Address,
+			Symbol:   t.Symbol,
+			Decimals: t.Decimals,
+			Weight:   t.Weight.String(),
+			Balance:  t.Balance.String(),
+			Needed:   st.Needed(t).String(),
+		})
+	}
+	return a
+}
+
+// Document is a signed attestation.
+type Document struct {
+	// Attestation is the attestation's JSON. Its compact form is what is signed.
+	Attestation json.RawMessage `json:"attestation"`
+
+	Signer    common.Address `json:"signer"`
+	Signature hexutil.Bytes  `json:"signature"`
+}
+
+// messageHash is the hash that personal_sign signs for message.
+func messageHash(message []byte) common.Hash {
+	return crypto.Keccak256Hash([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%v", len(message))), message)
+}
+
+// Sign signs a with s.
+func (a *Attestation) Sign(ctx context.Context, s signer.Signer) (*Document, error) {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding attestation")
+	}
+	sig, err := relay.Sign(ctx, s, messageHash(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "signing attestation")
+	}
+	return &Document{Attestation: data, Signer: s.Address(), Signature: sig}, nil
+}
+
+// Verify checks that d's signature is its signer's, and returns its attestation.
+func Verify(d *Document) (*Attestation, error) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, d.Attestation); err != nil {
+		return nil, errors.Wrap(err, "parsing attestation")
+	}
+	from, err := relay.Recover(messageHash(compact.Bytes()), d.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if from != d.Signer {
+		return nil, errors.Errorf("the attestation is signed by %v, not %v", from.Hex(), d.Signer.Hex())
+	}
+	var a Attestation
+	if err := json.Unmarshal(d.Attestation, &a); err != nil {
+		return nil, errors.Wrap(err, "parsing attestation")
+	}
+	return &a, nil
+}
+
+// WriteText writes d as a readable report.
+func (d *Document) WriteText(w io.Writer) error {
+	var a Attestation
+	if err := json.Unmarshal(d.Attestation, &a); err != nil {
+		return errors.Wrap(err, "parsing attestation")
+	}
+	supply, ok := parse(a.Supply)
+	if !ok {
+		return errors.Errorf("bad supply %q", a.Supply)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "RSV proof of reserve\n\n")
+	fmt.Fprintf(tw, "Network:\t%v (chain %v)\n", a.Network, a.ChainID)
+	fmt.Fprintf(tw, "Block:\t%v (%v)\n", a.Block, a.BlockHash.Hex())
+	fmt.Fprintf(tw, "Time:\t%v\n", a.BlockTime.Format(time.RFC3339))
+	fmt.Fprintf(tw, "Total supply:\t%v RSV\n", units.Format(supply, 18))
+	fmt.Fprintf(tw, "Collateralization:\t%v\n", a.Collateralization)
+	fmt.Fprintf(tw, "\nCollateral held by the Vault\n\n")
+	fmt.Fprintf(tw, "Token\tAddress\tPer RSV\tHeld\tNeeded\n")
+	for _, c := range a.Collateral {
+		name := c.Symbol
+		if name == "" {
+			name = "?"
+		}
+		weight, ok1 := parse(c.Weight)
+		balance, ok2 := parse(c.Balance)
+		needed, ok3 := parse(c.Needed)
+		if !ok1 || !ok2 || !ok3 {
+			return errors.Errorf("bad amounts for %v", c.Token.Hex())
+		}
+		// Weights are in aqTokens: 1e18 per qToken.
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", name, c.Token.Hex(), units.Format(weight, c.Decimals+18),
+			units.Format(balance, c.Decimals), units.Format(needed, c.Decimals))
+	}
+	fmt.Fprintf(tw, "\nContracts\n\n")
+	fmt.Fprintf(tw, "Name\tAddress\tCode hash\n")
+	for _, c := range a.Contracts {
+		fmt.Fprintf(tw, "%v\t%v\t%v\n", c.Name, c.Address.Hex(), c.CodeHash.Hex())
+	}
+	if a.Statement != "" {
+		fmt.Fprintf(tw, "\n%v\n", a.Statement)
+	}
+	fmt.Fprintf(tw, "\nSigned by %v\nSignature %v\n", d.Signer.Hex(), d.Signature)
+	fmt.Fprintf(tw, "\nThe signature is an Ethereum signed message (personal_sign) over the compact JSON of the \"attestation\" of the JSON document.\n")
+	return tw.Flush()
+}
+
+func parse(s string) (*big.Int, bool) {
+	return new(big.Int).SetString(s, 10)
+}
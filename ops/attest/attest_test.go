@@ -0,0 +1,67 @@
+package attest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/signer"
+)
+
+var usdc = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+
+func e(n int64, decimals int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), new(big.Int).Exp(big.NewInt(10), big.NewInt(decimals), nil))
+}
+
+func TestSignAndVerify(t *testing.T) {
+	st := &metrics.State{
+		Block:       7,
+		Supply:      e(1000, 18),
+		RSVDecimals: 18,
+		// Half a USDC per RSV, and the Vault holds 600 USDC.
+		Tokens: []metrics.Token{{Address: usdc, Symbol: "USDC", Decimals: 6, Weight: e(5, 23), Balance: e(600, 6)}},
+	}
+	header := &types.Header{Number: big.NewInt(7), Time: 1600000000}
+	a := New("testnet", 3, header, st, []Contract{{Name: "Reserve", Address: usdc, CodeHash: crypto.Keccak256Hash([]byte{1})}})
+	a.Statement = "Attested by the issuer."
+	assert.Equal(t, "500000000", a.Collateral[0].Needed)
+	assert.Equal(t, "1.200000", a.Collateralization)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	doc, err := a.Sign(context.Background(), signer.NewKey(key))
+	require.NoError(t, err)
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), doc.Signer)
+
+	// The document survives a round trip through JSON, indented for publication.
+	data, err := json.MarshalIndent(doc, "", "  ")
+	require.NoError(t, err)
+	var parsed Document
+	require.NoError(t, json.Unmarshal(data, &parsed))
+	got, err := Verify(&parsed)
+	require.NoError(t, err)
+	assert.Equal(t, a.Supply, got.Supply)
+	assert.Equal(t, header.Hash(), got.BlockHash)
+
+	// Any change to the attestation breaks the signature.
+	parsed.Attestation = bytes.Replace(parsed.Attestation, []byte(`"supply": "1`), []byte(`"supply": "2`), 1)
+	_, err = Verify(&parsed)
+	assert.Error(t, err)
+
+	var text bytes.Buffer
+	require.NoError(t, doc.WriteText(&text))
+	assert.Contains(t, text.String(), "Total supply:       1000 RSV")
+	assert.Contains(t, text.String(), "USDC   "+usdc.Hex()+"  0.5      600   500")
+	assert.Contains(t, text.String(), "2020-09-13T12:26:40Z")
+	assert.Contains(t, text.String(), "Attested by the issuer.")
+}
@@ -0,0 +1,126 @@
+// Package audit keeps the local audit trail of operations that operator tools perform.
+//
+// The trail is an append-only file of JSON lines. Every transaction an operator tool sends is
+// recorded when it is submitted and again when its outcome is known, so the file can answer both
+// "what did we do?" and "what is still in flight?".
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Statuses that an Entry can record.
+const (
+	StatusSubmitted = "submitted"
+	StatusConfirmed = "confirmed"
+	StatusFailed    = "failed"
+)
+
+// Entry is one line of the audit trail.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Network  string    `json:"network"`
+	ChainID  uint64    `json:"chainId"`
+	Command  string    `json:"command"`
+	Operator string    `json:"operator"`
+	Contract string    `json:"contract"`
+	Address  string    `json:"address"`
+	Method   string    `json:"method"`
+	Args     []string  `json:"args"`
+	TxHash   string    `json:"txHash,omitempty"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Log is an audit trail stored at Path.
+type Log struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Open returns the audit trail at path, creating the file if it doesn't exist yet.
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening audit log")
+	}
+	if err := f.Close(); err != nil {
+		return nil, errors.Wrap(err, "opening audit log")
+	}
+	return &Log{Path: path}, nil
+}
+
+// Append durably adds e to the trail. If e.Time is zero, it is set to the current time.
+func (l *Log) Append(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "encoding audit entry")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.Path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "opening audit log")
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return errors.Wrap(err, "writing audit log")
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return errors.Wrap(err, "syncing audit log")
+	}
+	return errors.Wrap(f.Close(), "closing audit log")
+}
+
+// Entries returns every entry in the trail, oldest first.
+func (l *Log) Entries() ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.Open(l.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening audit log")
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, errors.Wrapf(err, "audit log line %v", line)
+		}
+		entries = append(entries, e)
+	}
+	return entries, errors.Wrap(scanner.Err(), "reading audit log")
+}
+
+// Latest collapses entries to the most recent entry for each transaction hash, keeping the
+// order in which the transactions were first seen. Entries without a hash are dropped.
+func Latest(entries []Entry) []Entry {
+	index := make(map[string]int)
+	var result []Entry
+	for _, e := range entries {
+		if e.TxHash == "" {
+			continue
+		}
+		if i, ok := index[e.TxHash]; ok {
+			result[i] = e
+			continue
+		}
+		index[e.TxHash] = len(result)
+		result = append(result, e)
+	}
+	return result
+}
@@ -0,0 +1,360 @@
+// Package authorize builds and signs the EIP-712 messages by which RSV holders authorize
+// actions without sending a transaction themselves: EIP-2612 permits, which approve a spender,
+// and EIP-3009 authorizations, which transfer RSV. The hashes mirror contracts/rsv/Reserve.sol.
+//
+// It also builds the actions that the guardians of a GuardianMultisig sign, and collects their
+// signatures into the order that the contract takes them; those hashes mirror
+// contracts/GuardianMultisig.sol.
+//
+// And it builds the batch permits of Uniswap's Permit2 by which an issuer grants the Manager
+// allowances of the basket tokens, for issueWithPermit2; those hashes mirror Permit2's own.
+package authorize
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"math/big"
+	"sort"
+	"strings"
+
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/signer"
+)
+
+// Type hashes of the messages, as in Reserve.sol.
+var (
+	domainTypeHash   = crypto.Keccak256Hash([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+	permitTypeHash   = crypto.Keccak256Hash([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)"))
+	transferTypeHash = crypto.Keccak256Hash([]byte("TransferWithAuthorization(address from,address to,uint256 value,uint256 validAfter,uint256 validBefore,bytes32 nonce)"))
+	receiveTypeHash  = crypto.Keccak256Hash([]byte("ReceiveWithAuthorization(address from,address to,uint256 value,uint256 validAfter,uint256 validBefore,bytes32 nonce)"))
+	cancelTypeHash   = crypto.Keccak256Hash([]byte("CancelAuthorization(address authorizer,bytes32 nonce)"))
+	actionTypeHash   = crypto.Keccak256Hash([]byte("Action(uint8 action,address account,uint256 value,uint256 nonce,uint256 deadline)"))
+)
+
+// Type hashes of Permit2's messages, whose domain has no version.
+var (
+	versionlessDomainTypeHash = crypto.Keccak256Hash([]byte("EIP712Domain(string name,uint256 chainId,address verifyingContract)"))
+	permitDetailsTypeHash     = crypto.Keccak256Hash([]byte("PermitDetails(address token,uint160 amount,uint48 expiration,uint48 nonce)"))
+	permitBatchTypeHash       = crypto.Keccak256Hash([]byte("PermitBatch(PermitDetails[] details,address spender,uint256 sigDeadline)PermitDetails(address token,uint160 amount,uint48 expiration,uint48 nonce)"))
+)
+
+// Permit2Address is where Uniswap deployed Permit2, at the same address on every chain.
+var Permit2Address = common.HexToAddress("0x000000000022D473030F116dDEE9F6B43aC78BA3")
+
+// Actions of a GuardianMultisig, as in GuardianMultisig.sol.
+const (
+	Pause           uint8 = 0
+	Freeze          uint8 = 1
+	Unfreeze        uint8 = 2
+	AddGuardian     uint8 = 3
+	RemoveGuardian  uint8 = 4
+	ChangeThreshold uint8 = 5
+)
+
+// Domain is the EIP-712 domain that a contract's messages are signed in.
+type Domain struct {
+	Name     string
+	Version  string
+	ChainID  *big.Int
+	Contract common.Address
+}
+
+// Reserve returns the domain of the Reserve at address, with the chain ID set on it by
+// changeChainId.
+func Reserve(address common.Address, chainID *big.Int) Domain {
+	return Domain{Name: "Reserve", Version: "2.1", ChainID: chainID, Contract: address}
+}
+
+// GuardianMultisig returns the domain of the GuardianMultisig at address, deployed for chainID.
+func GuardianMultisig(address common.Address, chainID *big.Int) Domain {
+	return Domain{Name: "GuardianMultisig", Version: "1", ChainID: chainID, Contract: address}
+}
+
+// Permit2 returns the domain of Permit2 at Permit2Address on chainID.
+func Permit2(chainID *big.Int) Domain {
+	return Domain{Name: "Permit2", ChainID: chainID, Contract: Permit2Address}
+}
+
+// Separator returns the domain separator, as the Reserve's DOMAIN_SEPARATOR. A domain without
+// a Version, such as Permit2's, leaves the version out of the separator.
+func (d Domain) Separator() common.Hash {
+	if d.Version == "" {
+		return crypto.Keccak256Hash(
+			versionlessDomainTypeHash.Bytes(),
+			crypto.Keccak256([]byte(d.Name)),
+			uint256(d.ChainID),
+			common.LeftPadBytes(d.Contract.Bytes(), 32),
+		)
+	}
+	return crypto.Keccak256Hash(
+		domainTypeHash.Bytes(),
+		crypto.Keccak256([]byte(d.Name)),
+		crypto.Keccak256([]byte(d.Version)),
+		uint256(d.ChainID),
+		common.LeftPadBytes(d.Contract.Bytes(), 32),
+	)
+}
+
+// hash returns the digest of the message with the given struct hash, which is what is signed.
+func (d Domain) hash(structHash common.Hash) common.Hash {
+	return crypto.Keccak256Hash([]byte("\x19\x01"), d.Separator().Bytes(), structHash.Bytes())
+}
+
+// Permit is an EIP-2612 approval of Spender to spend Value of Holder's attoRSV.
+type Permit struct {
+	Holder   common.Address
+	Spender  common.Address
+	Value    *big.Int
+	Nonce    *big.Int // the Reserve's nonces(Holder)
+	Deadline *big.Int // a Unix time
+}
+
+// Hash returns the digest that Holder signs.
+func (p Permit) Hash(d Domain) common.Hash {
+	return d.hash(crypto.Keccak256Hash(
+		permitTypeHash.Bytes(),
+		common.LeftPadBytes(p.Holder.Bytes(), 32),
+		common.LeftPadBytes(p.Spender.Bytes(), 32),
+		uint256(p.Value),
+		uint256(p.Nonce),
+		uint256(p.Deadline),
+	))
+}
+
+// Transfer is an EIP-3009 authorization to transfer Value attoRSV from From to To, valid
+// strictly between the Unix times ValidAfter and ValidBefore.
+type Transfer struct {
+	From        common.Address
+	To          common.Address
+	Value       *big.Int
+	ValidAfter  *big.Int
+	ValidBefore *big.Int
+	Nonce       common.Hash // from NewNonce
+
+	// Receive makes it an authorization for receiveWithAuthorization, which only To may submit,
+	// rather than transferWithAuthorization.
+	Receive bool
+}
+
+// Hash returns the digest that From signs.
+func (t Transfer) Hash(d Domain) common.Hash {
+	typeHash := transferTypeHash
+	if t.Receive {
+		typeHash = receiveTypeHash
+	}
+	return d.hash(crypto.Keccak256Hash(
+		typeHash.Bytes(),
+		common.LeftPadBytes(t.From.Bytes(), 32),
+		common.LeftPadBytes(t.To.Bytes(), 32),
+		uint256(t.Value),
+		uint256(t.ValidAfter),
+		uint256(t.ValidBefore),
+		t.Nonce.Bytes(),
+	))
+}
+
+// Cancel is an EIP-3009 cancellation of Authorizer's unused authorization with Nonce.
+type Cancel struct {
+	Authorizer common.Address
+	Nonce      common.Hash
+}
+
+// Hash returns the digest that Authorizer signs.
+func (c Cancel) Hash(d Domain) common.Hash {
+	return d.hash(crypto.Keccak256Hash(
+		cancelTypeHash.Bytes(),
+		common.LeftPadBytes(c.Authorizer.Bytes(), 32),
+		c.Nonce.Bytes(),
+	))
+}
+
+// Action is a GuardianMultisig action: Kind, one of Pause through ChangeThreshold, with its
+// Account and Value, as execute takes them.
+type Action struct {
+	Kind     uint8
+	Account  common.Address
+	Value    *big.Int
+	Nonce    *big.Int // the multisig's nonce()
+	Deadline *big.Int // a Unix time
+}
+
+// Hash returns the digest that the guardians sign, which the multisig's digest() returns for
+// its current nonce.
+func (a Action) Hash(d Domain) common.Hash {
+	return d.hash(crypto.Keccak256Hash(
+		actionTypeHash.Bytes(),
+		common.LeftPadBytes([]byte{a.Kind}, 32),
+		common.LeftPadBytes(a.Account.Bytes(), 32),
+		uint256(a.Value),
+		uint256(a.Nonce),
+		uint256(a.Deadline),
+	))
+}
+
+// PermitDetails is a Permit2 allowance of Amount of Token, until the Unix time Expiration, at
+// Nonce, the owner's Permit2 nonce for the token and spender.
+type PermitDetails struct {
+	Token      common.Address
+	Amount     *big.Int // at most 2^160 - 1
+	Expiration *big.Int // a Unix time; 0 for the end of the block
+	Nonce      *big.Int // from Permit2's allowance(owner, Token, Spender)
+}
+
+// PermitBatch is a Permit2 batch permit, granting Spender the allowances in Details, which its
+// owner must sign by the Unix time SigDeadline.
+type PermitBatch struct {
+	Details     []PermitDetails
+	Spender     common.Address
+	SigDeadline *big.Int
+}
+
+// Hash returns the digest that the owner signs.
+func (p PermitBatch) Hash(d Domain) common.Hash {
+	var details []byte
+	for _, detail := range p.Details {
+		details = append(details, crypto.Keccak256(
+			permitDetailsTypeHash.Bytes(),
+			common.LeftPadBytes(detail.Token.Bytes(), 32),
+			uint256(detail.Amount),
+			uint256(detail.Expiration),
+			uint256(detail.Nonce),
+		)...)
+	}
+	return d.hash(crypto.Keccak256Hash(
+		permitBatchTypeHash.Bytes(),
+		crypto.Keccak256(details),
+		common.LeftPadBytes(p.Spender.Bytes(), 32),
+		uint256(p.SigDeadline),
+	))
+}
+
+// permit2ABI is the ABI of Permit2's batch permit.
+const permit2ABI = `[{"type":"function","name":"permit","stateMutability":"nonpayable","outputs":[],"inputs":[
+	{"name":"owner","type":"address"},
+	{"name":"permitBatch","type":"tuple","components":[
+		{"name":"details","type":"tuple[]","components":[
+			{"name":"token","type":"address"},{"name":"amount","type":"uint160"},
+			{"name":"expiration","type":"uint48"},{"name":"nonce","type":"uint48"}]},
+		{"name":"spender","type":"address"},{"name":"sigDeadline","type":"uint256"}]},
+	{"name":"signature","type":"bytes"}]}]`
+
+var permit2, permit2Err = ethabi.JSON(strings.NewReader(permit2ABI))
+
+// Call returns the calldata of Permit2's batch permit, signed by owner with sig, which is what
+// issueWithPermit2 takes as its permitCall.
+func (p PermitBatch) Call(owner common.Address, sig Signature) ([]byte, error) {
+	if permit2Err != nil {
+		return nil, errors.Wrap(permit2Err, "parsing Permit2 ABI")
+	}
+	raw := append(append(append([]byte(nil), sig.R[:]...), sig.S[:]...), sig.V)
+	data, err := permit2.Pack("permit", owner, p, raw)
+	return data, errors.Wrap(err, "encoding Permit2 permit")
+}
+
+// Signatures are signatures of one action, split into the v, r, and s arguments of execute.
+type Signatures struct {
+	V []uint8
+	R [][32]byte
+	S [][32]byte
+
+	// Signers are the accounts that made them, in the same order.
+	Signers []common.Address
+}
+
+// Collect puts guardians' signatures of hash in the order that execute takes them, ascending by
+// signer. It fails on a signature that doesn't recover, and on two from the same signer, which
+// the multisig would only count once.
+func Collect(hash common.Hash, sigs []Signature) (*Signatures, error) {
+	type signed struct {
+		sig    Signature
+		signer common.Address
+	}
+	all := make([]signed, len(sigs))
+	for i, sig := range sigs {
+		from, err := Recover(hash, sig)
+		if err != nil {
+			return nil, errors.Wrapf(err, "signature %v", i)
+		}
+		all[i] = signed{sig, from}
+	}
+	sort.Slice(all, func(i, j int) bool { return bytes.Compare(all[i].signer[:], all[j].signer[:]) < 0 })
+
+	out := new(Signatures)
+	for i, s := range all {
+		if i > 0 && s.signer == all[i-1].signer {
+			return nil, errors.Errorf("two signatures from %v", s.signer.Hex())
+		}
+		out.V = append(out.V, s.sig.V)
+		out.R = append(out.R, s.sig.R)
+		out.S = append(out.S, s.sig.S)
+		out.Signers = append(out.Signers, s.signer)
+	}
+	return out, nil
+}
+
+// NewNonce returns a random authorization nonce.
+func NewNonce() (common.Hash, error) {
+	var nonce common.Hash
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return common.Hash{}, errors.Wrap(err, "generating nonce")
+	}
+	return nonce, nil
+}
+
+// Signature is a signature split into the v, r, and s arguments that permit and the
+// authorization methods take.
+type Signature struct {
+	V uint8 // 27 or 28
+	R [32]byte
+	S [32]byte
+}
+
+// Sign signs hash with s.
+func Sign(ctx context.Context, s signer.Signer, hash common.Hash) (Signature, error) {
+	sig, err := s.SignHash(ctx, hash)
+	if err != nil {
+		return Signature{}, err
+	}
+	if len(sig) != 65 {
+		return Signature{}, errors.Errorf("signature has length %v, want 65", len(sig))
+	}
+	var out Signature
+	copy(out.R[:], sig[:32])
+	copy(out.S[:], sig[32:64])
+	out.V = sig[64]
+	if out.V < 27 {
+		out.V += 27
+	}
+	return out, nil
+}
+
+// Recover returns the address that made sig over hash. Like the Reserve, it rejects malleable
+// (high-s) signatures and v values other than 27 and 28.
+func Recover(hash common.Hash, sig Signature) (common.Address, error) {
+	if sig.V != 27 && sig.V != 28 {
+		return common.Address{}, errors.Errorf("signature has invalid v value %v", sig.V)
+	}
+	if new(big.Int).SetBytes(sig.S[:]).Cmp(secp256k1HalfN) > 0 {
+		return common.Address{}, errors.New("signature has invalid s value")
+	}
+	raw := append(append(append([]byte(nil), sig.R[:]...), sig.S[:]...), sig.V-27)
+	pub, err := crypto.SigToPub(hash.Bytes(), raw)
+	if err != nil {
+		return common.Address{}, errors.Wrap(err, "recovering signer")
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+var secp256k1HalfN, _ = new(big.Int).SetString("7fffffffffffffffffffffffffffffff5d576e7357a4501ddfe92f46681b20a0", 16)
+
+func uint256(n *big.Int) []byte {
+	if n == nil {
+		n = new(big.Int)
+	}
+	return common.LeftPadBytes(n.Bytes(), 32)
+}
@@ -0,0 +1,214 @@
+package authorize
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/signer"
+)
+
+func TestSeparator(t *testing.T) {
+	// The domain of the example in EIP-712.
+	d := Domain{
+		Name:     "Ether Mail",
+		Version:  "1",
+		ChainID:  big.NewInt(1),
+		Contract: common.HexToAddress("0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"),
+	}
+	assert.Equal(t, "0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f", d.Separator().Hex())
+}
+
+func TestSignAndRecover(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	s := signer.NewKey(key)
+	d := Reserve(common.HexToAddress("0x196f4727526eA7FB1e17b2071B3d8eAA38486988"), big.NewInt(1))
+	nonce, err := NewNonce()
+	require.NoError(t, err)
+
+	transfer := Transfer{
+		From:        s.Address(),
+		To:          common.HexToAddress("0x02"),
+		Value:       big.NewInt(100),
+		ValidAfter:  big.NewInt(0),
+		ValidBefore: big.NewInt(2000000000),
+		Nonce:       nonce,
+	}
+	receive := transfer
+	receive.Receive = true
+	hashes := []common.Hash{
+		transfer.Hash(d),
+		receive.Hash(d),
+		Cancel{Authorizer: s.Address(), Nonce: nonce}.Hash(d),
+		Permit{Holder: s.Address(), Spender: transfer.To, Value: big.NewInt(100), Nonce: big.NewInt(0), Deadline: big.NewInt(2000000000)}.Hash(d),
+		transfer.Hash(Reserve(d.Contract, big.NewInt(2))),
+	}
+	seen := map[common.Hash]bool{}
+	for _, hash := range hashes {
+		assert.False(t, seen[hash], "hashes of different messages collide")
+		seen[hash] = true
+
+		sig, err := Sign(context.Background(), s, hash)
+		require.NoError(t, err)
+		assert.Contains(t, []uint8{27, 28}, sig.V)
+		from, err := Recover(hash, sig)
+		require.NoError(t, err)
+		assert.Equal(t, s.Address(), from)
+	}
+}
+
+func TestRecoverRejectsMalleableSignatures(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	hash := Cancel{Authorizer: crypto.PubkeyToAddress(key.PublicKey)}.Hash(Reserve(common.Address{}, big.NewInt(1)))
+	sig, err := Sign(context.Background(), signer.NewKey(key), hash)
+	require.NoError(t, err)
+
+	// The same signature with s mirrored into the upper half order, and v flipped.
+	n, _ := new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+	high := sig
+	copy(high.S[:], common.LeftPadBytes(new(big.Int).Sub(n, new(big.Int).SetBytes(sig.S[:])).Bytes(), 32))
+	high.V = 55 - sig.V
+	_, err = Recover(hash, high)
+	assert.Error(t, err)
+
+	low := sig
+	low.V -= 27
+	_, err = Recover(hash, low)
+	assert.Error(t, err)
+}
+
+func TestActionHash(t *testing.T) {
+	d := GuardianMultisig(common.HexToAddress("0x196f4727526eA7FB1e17b2071B3d8eAA38486988"), big.NewInt(1))
+	pause := Action{Kind: Pause, Value: big.NewInt(0), Nonce: big.NewInt(0), Deadline: big.NewInt(2000000000)}
+	freeze := pause
+	freeze.Kind, freeze.Account = Freeze, common.HexToAddress("0x02")
+	next := pause
+	next.Nonce = big.NewInt(1)
+
+	// Each part of the action, and of its domain, changes the hash.
+	hashes := []common.Hash{
+		pause.Hash(d),
+		freeze.Hash(d),
+		next.Hash(d),
+		pause.Hash(GuardianMultisig(d.Contract, big.NewInt(2))),
+		pause.Hash(GuardianMultisig(common.HexToAddress("0x03"), big.NewInt(1))),
+		Cancel{}.Hash(d),
+	}
+	seen := map[common.Hash]bool{}
+	for _, hash := range hashes {
+		assert.False(t, seen[hash], "hashes of different actions collide")
+		seen[hash] = true
+	}
+}
+
+func TestPermit2Separator(t *testing.T) {
+	// Permit2's domain has no version field.
+	d := Permit2(big.NewInt(1))
+	want := crypto.Keccak256Hash(
+		crypto.Keccak256([]byte("EIP712Domain(string name,uint256 chainId,address verifyingContract)")),
+		crypto.Keccak256([]byte("Permit2")),
+		common.LeftPadBytes([]byte{1}, 32),
+		common.LeftPadBytes(Permit2Address.Bytes(), 32),
+	)
+	assert.Equal(t, want, d.Separator())
+	versioned := d
+	versioned.Version = "1"
+	assert.NotEqual(t, d.Separator(), versioned.Separator())
+}
+
+func TestPermitBatch(t *testing.T) {
+	d := Permit2(big.NewInt(1))
+	batch := PermitBatch{
+		Details: []PermitDetails{
+			{Token: common.HexToAddress("0x02"), Amount: big.NewInt(3), Expiration: big.NewInt(4), Nonce: big.NewInt(5)},
+		},
+		Spender:     common.HexToAddress("0x06"),
+		SigDeadline: big.NewInt(7),
+	}
+	more := batch
+	more.Details = append([]PermitDetails{batch.Details[0]}, batch.Details[0])
+	later := batch
+	later.SigDeadline = big.NewInt(8)
+	nonce := batch
+	nonce.Details = []PermitDetails{batch.Details[0]}
+	nonce.Details[0].Nonce = big.NewInt(6)
+
+	// Each part of the permit, and of its domain, changes the hash.
+	hashes := []common.Hash{
+		batch.Hash(d),
+		more.Hash(d),
+		later.Hash(d),
+		nonce.Hash(d),
+		batch.Hash(Permit2(big.NewInt(2))),
+	}
+	seen := map[common.Hash]bool{}
+	for _, hash := range hashes {
+		assert.False(t, seen[hash], "hashes of different permits collide")
+		seen[hash] = true
+	}
+
+	// The call is permit(owner, batch, r || s || v), ABI-encoded.
+	owner := common.HexToAddress("0x01")
+	sig := Signature{V: 27, R: [32]byte{9}, S: [32]byte{10}}
+	data, err := batch.Call(owner, sig)
+	require.NoError(t, err)
+	word := func(n int64) []byte { return common.LeftPadBytes(big.NewInt(n).Bytes(), 32) }
+	var want []byte
+	for _, w := range [][]byte{
+		{0x2a, 0x2d, 0x80, 0xd1},
+		word(1), word(0x60), word(0x160), // owner, and the offsets of the batch and the signature
+		word(0x60), word(6), word(7), // the offset of the details, the spender, and the deadline
+		word(1), word(2), word(3), word(4), word(5), // the details
+		word(65), sig.R[:], sig.S[:], {27}, make([]byte, 31), // the signature
+	} {
+		want = append(want, w...)
+	}
+	assert.Equal(t, common.Bytes2Hex(want), common.Bytes2Hex(data))
+}
+
+func TestCollect(t *testing.T) {
+	hash := Action{Kind: Pause, Nonce: big.NewInt(0), Deadline: big.NewInt(2000000000)}.Hash(
+		GuardianMultisig(common.HexToAddress("0x196f4727526eA7FB1e17b2071B3d8eAA38486988"), big.NewInt(1)),
+	)
+	var sigs []Signature
+	for i := 0; i < 4; i++ {
+		key, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		sig, err := Sign(context.Background(), signer.NewKey(key), hash)
+		require.NoError(t, err)
+		sigs = append(sigs, sig)
+	}
+
+	collected, err := Collect(hash, sigs)
+	require.NoError(t, err)
+	require.Len(t, collected.Signers, 4)
+	require.Len(t, collected.V, 4)
+	for i, guardian := range collected.Signers {
+		if i > 0 {
+			assert.True(t, bytes.Compare(collected.Signers[i-1][:], guardian[:]) < 0, "signers out of order")
+		}
+		from, err := Recover(hash, Signature{V: collected.V[i], R: collected.R[i], S: collected.S[i]})
+		require.NoError(t, err)
+		assert.Equal(t, guardian, from)
+	}
+
+	// The same signer twice is refused, as is a signature that doesn't recover.
+	_, err = Collect(hash, append(sigs, sigs[2]))
+	assert.Error(t, err)
+	bad := sigs[0]
+	bad.V = 29
+	_, err = Collect(hash, []Signature{sigs[1], bad})
+	assert.Error(t, err)
+
+	none, err := Collect(hash, nil)
+	require.NoError(t, err)
+	assert.Empty(t, none.Signers)
+}
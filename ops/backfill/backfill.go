@@ -0,0 +1,231 @@
+// Package backfill fetches the logs of a long range of blocks from a node, as a new indexer does
+// to rebuild years of history, without being throttled by the provider.
+//
+// A Walker sizes its queries to what the provider accepts: it halves the range of a query that
+// the provider refuses as too large, or whose logs are over the target, and doubles it while
+// queries come back light. It spaces its requests to stay under a rate, and when the provider
+// says it is rate limited anyway, it waits, longer each time. Each chunk is handed over with the
+// hash of its last block, for the consumer to store with a checkpoint, so that a backfill that
+// stops resumes after the last chunk stored.
+package backfill
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+// Node is what the Walker needs from an Ethereum node.
+type Node interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// Chunk is the logs of blocks From to To, in chain order.
+type Chunk struct {
+	Logs     []types.Log
+	From, To uint64
+
+	// Hash is the hash of block To.
+	Hash common.Hash
+}
+
+// Walker fetches the logs matching Addresses and Topics.
+type Walker struct {
+	Node      Node
+	Addresses []common.Address
+	Topics    [][]common.Hash
+
+	// Chunk is the range of the first query (default 1000 blocks); queries then range between
+	// MinChunk (default 1) and MaxChunk (default 100000) blocks.
+	Chunk, MinChunk, MaxChunk uint64
+
+	// TargetLogs is about how many logs a query should return (default 2000). Chunks shrink
+	// when a query returns more, and grow when it returns under half as many.
+	TargetLogs int
+
+	// Rate is the most requests a second; zero means no limit.
+	Rate float64
+
+	// MaxRetries is how many times a failing request is tried again, with growing waits,
+	// before Walk gives up (default 8).
+	MaxRetries int
+
+	chunk uint64
+	last  time.Time
+}
+
+// minBackoff and maxBackoff bound the wait after a rate-limited or failed request: the first
+// wait is the least, and each wait doubles, to the most.
+var (
+	minBackoff = time.Second
+	maxBackoff = 2 * time.Minute
+)
+
+// Walk hands the chunks of blocks from to to, in order, to handle, which stores them. It stops
+// at the first error, from the node or from handle; the chunks handled before it stay handled.
+func (w *Walker) Walk(ctx context.Context, from, to uint64, handle func(ctx context.Context, c Chunk) error) error {
+	w.defaults()
+	started, blocks := time.Now(), to-from+1
+	lastReport := started
+	for next := from; next <= to; {
+		c, err := w.fetch(ctx, next, to)
+		if err != nil {
+			return err
+		}
+		if err := handle(ctx, *c); err != nil {
+			return err
+		}
+		next = c.To + 1
+		if time.Since(lastReport) >= time.Minute && next <= to {
+			done := next - from
+			log.Printf("backfill: at block %v of %v-%v (%.1f%%), %v blocks a query, %v elapsed",
+				next, from, to, 100*float64(done)/float64(blocks), w.chunk, time.Since(started).Round(time.Second))
+			lastReport = time.Now()
+		}
+	}
+	return nil
+}
+
+func (w *Walker) defaults() {
+	if w.MinChunk == 0 {
+		w.MinChunk = 1
+	}
+	if w.MaxChunk == 0 {
+		w.MaxChunk = 100000
+	}
+	if w.TargetLogs == 0 {
+		w.TargetLogs = 2000
+	}
+	if w.MaxRetries == 0 {
+		w.MaxRetries = 8
+	}
+	if w.chunk == 0 {
+		w.chunk = w.Chunk
+		if w.chunk == 0 {
+			w.chunk = 1000
+		}
+	}
+	w.chunk = clamp(w.chunk, w.MinChunk, w.MaxChunk)
+}
+
+// fetch returns the next chunk starting at from and ending by to, adapting the chunk size.
+func (w *Walker) fetch(ctx context.Context, from, to uint64) (*Chunk, error) {
+	backoff := minBackoff
+	failures := 0
+	retry := func(err error) error {
+		failures++
+		if failures > w.MaxRetries {
+			return err
+		}
+		log.Printf("backfill: %v; waiting %v", err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		return nil
+	}
+
+	for {
+		end := from + w.chunk - 1
+		if end > to || end < from {
+			end = to
+		}
+		if err := w.wait(ctx); err != nil {
+			return nil, err
+		}
+		logs, err := w.Node.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: w.Addresses,
+			Topics:    w.Topics,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			limited := RateLimited(err)
+			err = errors.Wrapf(err, "fetching logs of blocks %v-%v", from, end)
+			if !limited && w.chunk > w.MinChunk {
+				w.chunk = clamp(w.chunk/2, w.MinChunk, w.MaxChunk)
+				continue
+			}
+			if err := retry(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if len(logs) > w.TargetLogs && end > from {
+			// Keep the logs, but ask for less next time.
+			w.chunk = clamp(w.chunk/2, w.MinChunk, w.MaxChunk)
+		} else if len(logs) < w.TargetLogs/2 && end-from+1 == w.chunk {
+			w.chunk = clamp(w.chunk*2, w.MinChunk, w.MaxChunk)
+		}
+
+		for {
+			if err := w.wait(ctx); err != nil {
+				return nil, err
+			}
+			header, err := w.Node.HeaderByNumber(ctx, new(big.Int).SetUint64(end))
+			if err == nil {
+				return &Chunk{Logs: logs, From: from, To: end, Hash: header.Hash()}, nil
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if err := retry(errors.Wrapf(err, "reading block %v", end)); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// wait spaces requests to stay under Rate.
+func (w *Walker) wait(ctx context.Context) error {
+	if w.Rate <= 0 {
+		return nil
+	}
+	next := w.last.Add(time.Duration(float64(time.Second) / w.Rate))
+	if d := time.Until(next); d > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+	w.last = time.Now()
+	return nil
+}
+
+// RateLimited reports whether err is a provider's refusal for making too many requests, as
+// opposed to one for a query that is too large.
+func RateLimited(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"429", "too many requests", "rate limit", "rate exceeded", "request rate", "exceeded its compute units"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func clamp(n, min, max uint64) uint64 {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
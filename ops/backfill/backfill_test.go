@@ -0,0 +1,121 @@
+package backfill
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNode has a log in every tenth block. It refuses queries of more than limit blocks, and
+// rate limits the first throttled requests.
+type fakeNode struct {
+	limit     uint64
+	throttled int
+	ranges    [][2]uint64
+	requests  int
+}
+
+func (n *fakeNode) throttle() error {
+	n.requests++
+	if n.throttled > 0 {
+		n.throttled--
+		return errors.New("429 Too Many Requests")
+	}
+	return nil
+}
+
+func (n *fakeNode) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if err := n.throttle(); err != nil {
+		return nil, err
+	}
+	return &types.Header{Number: number}, nil
+}
+
+func (n *fakeNode) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	if err := n.throttle(); err != nil {
+		return nil, err
+	}
+	from, to := q.FromBlock.Uint64(), q.ToBlock.Uint64()
+	if to-from+1 > n.limit {
+		return nil, errors.New("query returned more than 10000 results")
+	}
+	n.ranges = append(n.ranges, [2]uint64{from, to})
+	var logs []types.Log
+	for b := from; b <= to; b++ {
+		if b%10 == 0 {
+			logs = append(logs, types.Log{BlockNumber: b})
+		}
+	}
+	return logs, nil
+}
+
+func TestWalk(t *testing.T) {
+	minBackoff, maxBackoff = time.Millisecond, 4*time.Millisecond
+	defer func() { minBackoff, maxBackoff = time.Second, 2*time.Minute }()
+
+	node := &fakeNode{limit: 400, throttled: 2}
+	w := &Walker{Node: node, Chunk: 100, MaxChunk: 1000, TargetLogs: 1000}
+	var blocks []uint64
+	var chunks []Chunk
+	err := w.Walk(context.Background(), 1, 2000, func(ctx context.Context, c Chunk) error {
+		for _, l := range c.Logs {
+			blocks = append(blocks, l.BlockNumber)
+		}
+		chunks = append(chunks, c)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Every log arrives once, in order, in chunks that cover the range.
+	require.Len(t, blocks, 200)
+	for i, b := range blocks {
+		assert.Equal(t, uint64(10*(i+1)), b)
+	}
+	next := uint64(1)
+	for _, c := range chunks {
+		assert.Equal(t, next, c.From)
+		assert.Equal(t, (&types.Header{Number: new(big.Int).SetUint64(c.To)}).Hash(), c.Hash, "the hash is of the last block")
+		next = c.To + 1
+	}
+	assert.Equal(t, uint64(2001), next)
+
+	// Chunks grow while they are light, and shrink to what the node accepts, without the rate
+	// limiting shrinking them.
+	assert.Equal(t, [2]uint64{1, 100}, node.ranges[0])
+	assert.Equal(t, [2]uint64{101, 300}, node.ranges[1])
+	for _, r := range node.ranges[2:] {
+		assert.True(t, r[1]-r[0]+1 <= 400)
+	}
+	assert.Equal(t, [2]uint64{301, 700}, node.ranges[2])
+
+	// Heavy chunks shrink too.
+	node = &fakeNode{limit: 1000}
+	w = &Walker{Node: node, Chunk: 400, TargetLogs: 20}
+	require.NoError(t, w.Walk(context.Background(), 1, 800, func(ctx context.Context, c Chunk) error { return nil }))
+	assert.Equal(t, [][2]uint64{{1, 400}, {401, 600}, {601, 800}}, node.ranges)
+
+	// A node that stays rate limited fails the walk.
+	node = &fakeNode{limit: 1000, throttled: 100}
+	w = &Walker{Node: node, MaxRetries: 3}
+	err = w.Walk(context.Background(), 1, 10, func(ctx context.Context, c Chunk) error { return nil })
+	assert.Error(t, err)
+	assert.True(t, RateLimited(err))
+	assert.Equal(t, 4, node.requests)
+}
+
+func TestRate(t *testing.T) {
+	node := &fakeNode{limit: 10}
+	w := &Walker{Node: node, Chunk: 10, MaxChunk: 10, Rate: 100}
+	started := time.Now()
+	require.NoError(t, w.Walk(context.Background(), 1, 50, func(ctx context.Context, c Chunk) error { return nil }))
+	// 5 chunks of a log query and a header read each, 10ms apart.
+	assert.Equal(t, 10, node.requests)
+	assert.True(t, time.Since(started) >= 90*time.Millisecond)
+}
@@ -0,0 +1,426 @@
+// Package blocklist keeps the Reserve's frozen addresses in step with an external sanctions or
+// blocklist feed.
+//
+// Each sync fetches the feed, reads which of its addresses, and of the addresses it listed
+// before, are frozen, and works out the changes that converge the two: freeze every listed
+// address that isn't frozen, and unfreeze every address that the feed has delisted. Addresses
+// frozen some other way, as by the emergency runbook, are never unfrozen, since the feed never
+// listed them. The changes are prepared for someone to send, or sent with the freezer key; either
+// way each is appended to a log.
+package blocklist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/emergency"
+)
+
+// Feed formats.
+const (
+	// Lines is one address per line, with "#" comments, as emergency.ReadAddresses reads.
+	Lines = "lines"
+
+	// JSON is a JSON array of address strings.
+	JSON = "json"
+
+	// Scan takes every 0x-prefixed address that appears anywhere in the document, as in a
+	// sanctions list published as XML or CSV with addresses among other fields.
+	Scan = "scan"
+)
+
+// Feed is where the blocklist comes from: an HTTP(S) URL or a local file.
+type Feed struct {
+	URL  string `json:"url,omitempty"`
+	File string `json:"file,omitempty"`
+
+	// Format is Lines (the default), JSON, or Scan.
+	Format string `json:"format,omitempty"`
+
+	// Headers are sent with the request for URL, such as an API key. A value of the form
+	// "$NAME" is read from the environment variable NAME.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+var client = &http.Client{Timeout: time.Minute}
+
+// Fetch reads and parses the feed.
+func (f Feed) Fetch(ctx context.Context) ([]common.Address, error) {
+	var data []byte
+	switch {
+	case f.URL != "" && f.File != "":
+		return nil, errors.New("feed: set url or file, not both")
+	case f.File != "":
+		var err error
+		if data, err = ioutil.ReadFile(f.File); err != nil {
+			return nil, errors.Wrap(err, "reading feed")
+		}
+	case f.URL != "":
+		req, err := http.NewRequest(http.MethodGet, f.URL, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "building feed request")
+		}
+		for k, v := range f.Headers {
+			if strings.HasPrefix(v, "$") {
+				if v = os.Getenv(v[1:]); v == "" {
+					return nil, errors.Errorf("feed: environment variable %v, for header %v, is not set", f.Headers[k][1:], k)
+				}
+			}
+			req.Header.Set(k, v)
+		}
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching feed")
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("fetching feed: %v", resp.Status)
+		}
+		if data, err = ioutil.ReadAll(resp.Body); err != nil {
+			return nil, errors.Wrap(err, "reading feed")
+		}
+	default:
+		return nil, errors.New("feed: url or file is not set")
+	}
+	return Parse(f.Format, data)
+}
+
+var addressPattern = regexp.MustCompile(`0x[0-9a-fA-F]{40}\b`)
+
+// Parse parses a feed of the given format, returning its addresses without duplicates.
+func Parse(format string, data []byte) ([]common.Address, error) {
+	switch format {
+	case "", Lines:
+		return emergency.ReadAddresses(bytes.NewReader(data))
+	case JSON:
+		var list []string
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, errors.Wrap(err, "parsing feed")
+		}
+		return parseAll(list)
+	case Scan:
+		return parseAll(addressPattern.FindAllString(string(data), -1))
+	}
+	return nil, errors.Errorf("unknown feed format %q", format)
+}
+
+func parseAll(list []string) ([]common.Address, error) {
+	var result []common.Address
+	seen := map[common.Address]bool{}
+	for _, s := range list {
+		addr, err := emergency.ParseAddress(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		if !seen[addr] {
+			seen[addr] = true
+			result = append(result, addr)
+		}
+	}
+	return result, nil
+}
+
+// Chain is the part of the deployment a Syncer needs.
+type Chain interface {
+	// Frozen reports which of accounts are frozen.
+	Frozen(ctx context.Context, accounts []common.Address) (map[common.Address]bool, error)
+
+	// Send sends the change and waits for it to be mined.
+	Send(ctx context.Context, c Change) (common.Hash, error)
+}
+
+// Change actions.
+const (
+	Freeze   = "freeze"
+	Unfreeze = "unfreeze"
+)
+
+// Change is a freeze or unfreeze needed to converge.
+type Change struct {
+	Action  string         `json:"action"`
+	Account common.Address `json:"account"`
+}
+
+// Entry is one line of the change log.
+type Entry struct {
+	Time    time.Time      `json:"time"`
+	Network string         `json:"network"`
+	Action  string         `json:"action"` // Freeze or Unfreeze; or "listed" or "delisted" for the feed
+	Account common.Address `json:"account"`
+
+	// Status is "prepared", "confirmed", or "failed" for a change; "" for the feed. A change
+	// still needed is logged as prepared once, when it is first needed.
+	Status string      `json:"status,omitempty"`
+	TxHash common.Hash `json:"txHash,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// state is what the state file keeps: the addresses the feed listed at the last sync, the
+// delisted ones still to be unfrozen, and the changes last prepared.
+type state struct {
+	Listed   []common.Address `json:"listed"`
+	Delisted []common.Address `json:"delisted,omitempty"`
+	Prepared []Change         `json:"prepared,omitempty"`
+}
+
+// Syncer converges the frozen addresses on a feed.
+type Syncer struct {
+	Fetch func(ctx context.Context) ([]common.Address, error)
+	Chain Chain
+
+	// Submit sends the changes. Otherwise they are only prepared: logged, and written to
+	// Prepared for someone holding the freezer role to send.
+	Submit bool
+
+	// Prepared, if set, receives the changes still needed after each sync, as a JSON array.
+	Prepared string
+
+	// MaxChanges is the most changes a sync makes. If the feed calls for more, as when it comes
+	// back empty or truncated, the sync makes none and fails, for someone to check the feed.
+	MaxChanges int
+
+	// StateFile keeps what the feed listed, across restarts.
+	StateFile string
+
+	// Log is the JSON-lines change log that every change, and every change of the feed, is
+	// appended to.
+	Log string
+
+	// Post, if set, delivers a summary of each sync that sends or newly prepares changes.
+	Post func(ctx context.Context, text string) error
+
+	Network  string
+	Interval time.Duration
+}
+
+// Run syncs until ctx is done.
+func (s *Syncer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Sync(ctx); err != nil {
+			log.Printf("blocklist: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync fetches the feed once and prepares or makes the changes it calls for, which it returns.
+func (s *Syncer) Sync(ctx context.Context) ([]Change, error) {
+	feed, err := s.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	st, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	listed, previous := set(feed), set(st.Listed)
+	delisted := set(st.Delisted)
+	var entries []Entry
+	for _, a := range sorted(listed) {
+		delete(delisted, a)
+		if !previous[a] {
+			entries = append(entries, Entry{Action: "listed", Account: a})
+		}
+	}
+	for _, a := range st.Listed {
+		if !listed[a] {
+			delisted[a] = true
+			entries = append(entries, Entry{Action: "delisted", Account: a})
+		}
+	}
+
+	frozen, err := s.Chain.Frozen(ctx, append(sorted(listed), sorted(delisted)...))
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, a := range sorted(listed) {
+		if !frozen[a] {
+			changes = append(changes, Change{Freeze, a})
+		}
+	}
+	for _, a := range sorted(delisted) {
+		if frozen[a] {
+			changes = append(changes, Change{Unfreeze, a})
+		} else {
+			delete(delisted, a)
+		}
+	}
+	if s.MaxChanges > 0 && len(changes) > s.MaxChanges {
+		return nil, errors.Errorf("the feed calls for %v changes, more than the %v allowed at once; check the feed", len(changes), s.MaxChanges)
+	}
+
+	// The feed's changes are logged, and its state saved, before any transaction, so that each
+	// is logged once. So are the changes newly prepared.
+	next := state{Listed: sortedList(feed), Delisted: sorted(delisted)}
+	var fresh []Change
+	if !s.Submit {
+		prepared := map[Change]bool{}
+		for _, c := range st.Prepared {
+			prepared[c] = true
+		}
+		for _, c := range changes {
+			if !prepared[c] {
+				fresh = append(fresh, c)
+				entries = append(entries, Entry{Action: c.Action, Account: c.Account, Status: "prepared"})
+			}
+		}
+		next.Prepared = changes
+	}
+	if err := s.append(entries...); err != nil {
+		return nil, err
+	}
+	if err := s.save(next); err != nil {
+		return nil, err
+	}
+
+	var failed error
+	remaining := changes
+	if s.Submit {
+		remaining = nil
+		for _, c := range changes {
+			hash, err := s.Chain.Send(ctx, c)
+			e := Entry{Action: c.Action, Account: c.Account, Status: "confirmed", TxHash: hash}
+			if err != nil {
+				e.Status, e.Error = "failed", err.Error()
+				remaining = append(remaining, c)
+				if failed == nil {
+					failed = errors.Wrapf(err, "%v %v", c.Action, c.Account.Hex())
+				}
+			}
+			if err := s.append(e); err != nil {
+				return changes, err
+			}
+		}
+		fresh = changes
+	}
+	if s.Prepared != "" {
+		if err := writeJSON(s.Prepared, append([]Change{}, remaining...)); err != nil {
+			return changes, err
+		}
+	}
+	if len(fresh) > 0 && s.Post != nil {
+		if err := s.Post(ctx, s.summary(fresh, remaining)); err != nil {
+			log.Printf("blocklist: posting summary: %v", err)
+		}
+	}
+	return changes, failed
+}
+
+func (s *Syncer) summary(changes, remaining []Change) string {
+	var freezes, unfreezes int
+	for _, c := range changes {
+		if c.Action == Freeze {
+			freezes++
+		} else {
+			unfreezes++
+		}
+	}
+	verb := "prepared"
+	if s.Submit {
+		verb = "sent"
+	}
+	text := fmt.Sprintf("RSV on %v: blocklist sync %v %v freezes and %v unfreezes", s.Network, verb, freezes, unfreezes)
+	if s.Submit && len(remaining) > 0 {
+		text += fmt.Sprintf("; %v failed and will be retried", len(remaining))
+	}
+	return text
+}
+
+func (s *Syncer) append(entries ...Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(s.Log, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "opening change log")
+	}
+	for _, e := range entries {
+		e.Time, e.Network = time.Now().UTC(), s.Network
+		line, err := json.Marshal(e)
+		if err != nil {
+			f.Close()
+			return errors.Wrap(err, "encoding change log entry")
+		}
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			f.Close()
+			return errors.Wrap(err, "writing change log")
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return errors.Wrap(err, "writing change log")
+	}
+	return errors.Wrap(f.Close(), "writing change log")
+}
+
+func (s *Syncer) load() (state, error) {
+	var st state
+	data, err := ioutil.ReadFile(s.StateFile)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return st, errors.Wrap(err, "reading state")
+	}
+	return st, errors.Wrapf(json.Unmarshal(data, &st), "parsing %v", s.StateFile)
+}
+
+func (s *Syncer) save(st state) error {
+	return writeJSON(s.StateFile, st)
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "encoding %v", path)
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return errors.Wrapf(err, "writing %v", path)
+	}
+	return errors.Wrapf(os.Rename(tmp, path), "writing %v", path)
+}
+
+func set(list []common.Address) map[common.Address]bool {
+	m := make(map[common.Address]bool, len(list))
+	for _, a := range list {
+		m[a] = true
+	}
+	return m
+}
+
+// sorted returns the addresses of m in order.
+func sorted(m map[common.Address]bool) []common.Address {
+	list := make([]common.Address, 0, len(m))
+	for a := range m {
+		list = append(list, a)
+	}
+	return sortedList(list)
+}
+
+func sortedList(list []common.Address) []common.Address {
+	list = append([]common.Address{}, list...)
+	sort.Slice(list, func(i, j int) bool { return bytes.Compare(list[i][:], list[j][:]) < 0 })
+	return list
+}
@@ -0,0 +1,156 @@
+package blocklist
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	alice = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	bob   = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	carol = common.HexToAddress("0x00000000000000000000000000000000000ca401")
+	dave  = common.HexToAddress("0x000000000000000000000000000000000000da7e")
+)
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct{ format, data string }{
+		{Lines, "# sanctioned\n" + alice.Hex() + "\n\n" + bob.Hex() + " # also\n" + alice.Hex() + "\n"},
+		{JSON, `["` + alice.Hex() + `", "` + bob.Hex() + `"]`},
+		{Scan, "<entry><id>Digital Currency Address - ETH " + alice.Hex() + "</id></entry>\n" +
+			"name,address\nx," + bob.Hex() + "\n"},
+	} {
+		list, err := Parse(c.format, []byte(c.data))
+		require.NoError(t, err, c.format)
+		assert.Equal(t, []common.Address{alice, bob}, list, c.format)
+	}
+	_, err := Parse(JSON, []byte(`["0x12"]`))
+	assert.Error(t, err)
+	_, err = Parse("xml", nil)
+	assert.Error(t, err)
+}
+
+type fakeChain struct {
+	frozen map[common.Address]bool
+	sent   []Change
+	fail   bool
+}
+
+func (c *fakeChain) Frozen(ctx context.Context, accounts []common.Address) (map[common.Address]bool, error) {
+	result := map[common.Address]bool{}
+	for _, a := range accounts {
+		result[a] = c.frozen[a]
+	}
+	return result, nil
+}
+
+func (c *fakeChain) Send(ctx context.Context, change Change) (common.Hash, error) {
+	if c.fail {
+		return common.Hash{}, errors.New("out of gas")
+	}
+	c.sent = append(c.sent, change)
+	c.frozen[change.Account] = change.Action == Freeze
+	return common.HexToHash("0x1"), nil
+}
+
+func readLog(t *testing.T, path string) []string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		lines = append(lines, e.Action+" "+e.Account.Hex()+" "+e.Status)
+	}
+	return lines
+}
+
+func TestSync(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blocklist")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	feed := []common.Address{alice, bob}
+	c := &fakeChain{frozen: map[common.Address]bool{bob: true, dave: true}}
+	var posted []string
+	s := &Syncer{
+		Fetch:      func(ctx context.Context) ([]common.Address, error) { return feed, nil },
+		Chain:      c,
+		Prepared:   filepath.Join(dir, "prepared.json"),
+		MaxChanges: 2,
+		StateFile:  filepath.Join(dir, "state.json"),
+		Log:        filepath.Join(dir, "changes.log"),
+		Post: func(ctx context.Context, text string) error {
+			posted = append(posted, text)
+			return nil
+		},
+		Network: "testnet",
+	}
+
+	// Without Submit, changes are only prepared, and logged once however often they are needed.
+	for i := 0; i < 2; i++ {
+		changes, err := s.Sync(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []Change{{Freeze, alice}}, changes)
+	}
+	assert.Empty(t, c.sent)
+	assert.Equal(t, []string{
+		"listed " + bob.Hex() + " ",
+		"listed " + alice.Hex() + " ",
+		"freeze " + alice.Hex() + " prepared",
+	}, readLog(t, s.Log))
+	assert.Equal(t, []string{"RSV on testnet: blocklist sync prepared 1 freezes and 0 unfreezes"}, posted)
+	data, err := ioutil.ReadFile(s.Prepared)
+	require.NoError(t, err)
+	var prepared []Change
+	require.NoError(t, json.Unmarshal(data, &prepared))
+	assert.Equal(t, []Change{{Freeze, alice}}, prepared)
+
+	// With Submit, they are sent.
+	s.Submit = true
+	_, err = s.Sync(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{Freeze, alice}}, c.sent)
+
+	// Delisted addresses are unfrozen, but not those the feed never listed.
+	feed = []common.Address{alice, carol}
+	c.fail = true
+	_, err = s.Sync(ctx)
+	assert.Error(t, err)
+	c.fail = false
+	changes, err := s.Sync(ctx)
+	require.NoError(t, err, "failed changes are retried")
+	assert.Equal(t, []Change{{Freeze, carol}, {Unfreeze, bob}}, changes)
+	assert.Equal(t, map[common.Address]bool{alice: true, bob: false, carol: true, dave: true}, c.frozen)
+	lines := readLog(t, s.Log)
+	assert.Equal(t, []string{
+		"freeze " + alice.Hex() + " confirmed",
+		"listed " + carol.Hex() + " ",
+		"delisted " + bob.Hex() + " ",
+		"freeze " + carol.Hex() + " failed",
+		"unfreeze " + bob.Hex() + " failed",
+		"freeze " + carol.Hex() + " confirmed",
+		"unfreeze " + bob.Hex() + " confirmed",
+	}, lines[3:])
+
+	// A feed calling for too many changes at once changes nothing.
+	feed = nil
+	s.MaxChanges = 1
+	_, err = s.Sync(ctx)
+	assert.Error(t, err)
+	changes, err = (&Syncer{Fetch: s.Fetch, Chain: c, StateFile: s.StateFile, Log: s.Log}).Sync(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{Unfreeze, alice}, {Unfreeze, carol}}, changes, "the state kept the last feed")
+}
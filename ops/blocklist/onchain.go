@@ -0,0 +1,67 @@
+package blocklist
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// frozenPerBatch is how many frozen calls are sent per batch request.
+const frozenPerBatch = 500
+
+// onchain is the Chain of a live deployment.
+type onchain struct {
+	client  *chain.Client
+	tx      *chain.Transactor
+	reserve *chain.Contract
+}
+
+// NewChain returns the Chain for the deployment that s is connected to. Sending changes needs
+// s's signer, which must hold the Reserve's freezer role; without one, the Chain can only read.
+func NewChain(s *session.Session) (Chain, error) {
+	reserve, err := s.Contract("Reserve")
+	if err != nil {
+		return nil, err
+	}
+	for _, method := range []string{"frozen", Freeze, Unfreeze} {
+		if _, ok := reserve.ABI.Methods[method]; !ok {
+			return nil, errors.Errorf("the deployed Reserve cannot freeze addresses: its ABI has no %v", method)
+		}
+	}
+	return &onchain{client: s.Client, tx: s.Transactor, reserve: reserve}, nil
+}
+
+func (c *onchain) Frozen(ctx context.Context, accounts []common.Address) (map[common.Address]bool, error) {
+	results := make([]bool, len(accounts))
+	for start := 0; start < len(accounts); start += frozenPerBatch {
+		b := c.client.NewBatch(nil)
+		for i := start; i < len(accounts) && i < start+frozenPerBatch; i++ {
+			if err := b.Add(c.reserve, &results[i], "frozen", accounts[i]); err != nil {
+				return nil, err
+			}
+		}
+		if err := b.Do(ctx); err != nil {
+			return nil, errors.Wrap(err, "reading frozen addresses")
+		}
+	}
+	frozen := make(map[common.Address]bool, len(accounts))
+	for i, a := range accounts {
+		frozen[a] = results[i]
+	}
+	return frozen, nil
+}
+
+func (c *onchain) Send(ctx context.Context, change Change) (common.Hash, error) {
+	if c.tx == nil {
+		return common.Hash{}, errors.New("no signer is configured")
+	}
+	receipt, err := c.tx.SendAndWait(ctx, chain.Call{Contract: c.reserve, Method: change.Action, Args: []interface{}{change.Account}})
+	if receipt != nil {
+		return receipt.TxHash, err
+	}
+	return common.Hash{}, err
+}
@@ -0,0 +1,247 @@
+// Package build records how each contract in evm/ was compiled, so that the artifacts can be
+// rebuilt byte for byte on another machine, and checked against what is deployed.
+//
+// solc hashes its metadata, which holds the compiler version, the settings, and the keccak256 of
+// every source file, into the code it emits, so two builds come out the same only if all of those
+// are the same. `make json` has solc include the metadata in each artifact. A Record keeps the
+// parts of it that decide the output, with the hashes of the code as bytecode.Normalize leaves it:
+// with its metadata hashes zeroed, the code holds steady where only the metadata changes, and
+// where it doesn't, the compiler or the optimizer did something else. The records of every
+// contract are committed as a Lock, and Compare says where a build departs from one.
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/bytecode"
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+)
+
+// Record is how one contract was compiled, and what came out.
+type Record struct {
+	Contract   string   `json:"contract"`
+	Source     string   `json:"source"`   // the file compiled, relative to the repo
+	Compiler   string   `json:"compiler"` // the full solc version, such as "0.5.7+commit.6da8b019"
+	Optimizer  int      `json:"optimizer"`
+	EVMVersion string   `json:"evmVersion,omitempty"`
+	Remappings []string `json:"remappings,omitempty"`
+
+	// Sources are the keccak256 of every source file compiled in, by path relative to the repo.
+	Sources map[string]string `json:"sources"`
+
+	// Code and Runtime are the keccak256 of the init code and of the deployed code, normalized.
+	Code    string `json:"code"`
+	Runtime string `json:"runtime"`
+
+	// Metadata is the keccak256 of solc's metadata, which matches only if the code does, to the
+	// byte.
+	Metadata string `json:"metadata"`
+}
+
+// metadata is the part of solc's metadata that decides what it compiles.
+type metadata struct {
+	Compiler struct {
+		Version string
+	}
+	Settings struct {
+		CompilationTarget map[string]string
+		EVMVersion        string `json:"evmVersion"`
+		Optimizer         struct {
+			Enabled bool
+			Runs    int
+		}
+		Remappings []string
+	}
+	Sources map[string]struct {
+		Keccak256 string
+	}
+}
+
+// FromArtifact records how an artifact was compiled. It fails if solc compiled a source from an
+// absolute path, which another checkout would not share.
+func FromArtifact(a *chain.Artifact) (*Record, error) {
+	if a.Metadata == "" {
+		return nil, errors.Errorf("the artifact of %v has no metadata; rebuild it with `make json`", a.Name)
+	}
+	var m metadata
+	if err := json.Unmarshal([]byte(a.Metadata), &m); err != nil {
+		return nil, errors.Wrapf(err, "parsing the metadata of %v", a.Name)
+	}
+	r := &Record{
+		Contract:   a.Name,
+		Compiler:   m.Compiler.Version,
+		EVMVersion: m.Settings.EVMVersion,
+		Sources:    make(map[string]string),
+		Code:       crypto.Keccak256Hash(bytecode.Normalize(a.Bin)).Hex(),
+		Runtime:    crypto.Keccak256Hash(bytecode.Normalize(a.BinRuntime)).Hex(),
+		Metadata:   crypto.Keccak256Hash([]byte(a.Metadata)).Hex(),
+	}
+	if len(m.Settings.Remappings) > 0 {
+		r.Remappings = m.Settings.Remappings
+	}
+	if m.Settings.Optimizer.Enabled {
+		r.Optimizer = m.Settings.Optimizer.Runs
+	}
+	for source := range m.Settings.CompilationTarget {
+		r.Source = source
+	}
+	for source, s := range m.Sources {
+		if path.IsAbs(source) || filepath.IsAbs(source) {
+			return nil, errors.Errorf("%v was compiled from the absolute path %v; compile from the repo root, as `make json` does", a.Name, source)
+		}
+		r.Sources[source] = s.Keccak256
+	}
+	return r, nil
+}
+
+// Lock is the record of every contract, by name.
+type Lock map[string]*Record
+
+// LoadAll records how every artifact in dir was compiled.
+func LoadAll(dir string) (Lock, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "listing artifacts")
+	}
+	if len(paths) == 0 {
+		return nil, errors.Errorf("no artifacts in %v; run `make json`", dir)
+	}
+	artifacts := chain.NewArtifacts(dir)
+	lock := make(Lock)
+	for _, p := range paths {
+		a, err := artifacts.Load(strings.TrimSuffix(filepath.Base(p), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		if lock[a.Name], err = FromArtifact(a); err != nil {
+			return nil, err
+		}
+	}
+	return lock, nil
+}
+
+// ReadFile reads a lock written by WriteFile.
+func ReadFile(path string) (Lock, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the build lock")
+	}
+	var lock Lock
+	if err := json.Unmarshal(b, &lock); err != nil {
+		return nil, errors.Wrapf(err, "parsing %v", path)
+	}
+	return lock, nil
+}
+
+// WriteFile writes the lock to path, as indented JSON sorted by contract.
+func WriteFile(path string, lock Lock) error {
+	b, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshaling the build lock")
+	}
+	return errors.Wrap(ioutil.WriteFile(path, append(b, '\n'), 0644), "writing the build lock")
+}
+
+// Compare lists how the build have departs from the record want: first the compiler inputs that
+// differ, then the code. It returns nil if have is the same to the byte.
+func Compare(want, have *Record) []string {
+	var diffs []string
+	if have.Source != want.Source {
+		diffs = append(diffs, fmt.Sprintf("compiled from %v, not %v", have.Source, want.Source))
+	}
+	if have.Compiler != want.Compiler {
+		diffs = append(diffs, fmt.Sprintf("compiled with solc %v, not %v", have.Compiler, want.Compiler))
+	}
+	if have.Optimizer != want.Optimizer {
+		diffs = append(diffs, fmt.Sprintf("optimized for %v runs, not %v (0 is unoptimized)", have.Optimizer, want.Optimizer))
+	}
+	if have.EVMVersion != want.EVMVersion {
+		diffs = append(diffs, fmt.Sprintf("compiled for EVM version %q, not %q", have.EVMVersion, want.EVMVersion))
+	}
+	if !reflect.DeepEqual(have.Remappings, want.Remappings) {
+		diffs = append(diffs, fmt.Sprintf("compiled with remappings %q, not %q", have.Remappings, want.Remappings))
+	}
+	diffs = append(diffs, compareSources(want.Sources, have.Sources)...)
+
+	var code []string
+	if have.Code != want.Code {
+		code = append(code, "init")
+	}
+	if have.Runtime != want.Runtime {
+		code = append(code, "deployed")
+	}
+	if len(code) > 0 {
+		d := "the " + strings.Join(code, " and ") + " code differs"
+		if len(code) > 1 {
+			d = strings.TrimSuffix(d, "s")
+		}
+		if len(diffs) == 0 {
+			d += ", though the compiler inputs are the same"
+		}
+		diffs = append(diffs, d)
+	}
+	if len(diffs) == 0 && have.Metadata != want.Metadata {
+		diffs = append(diffs, "the metadata differs, though not in anything recorded")
+	}
+	return diffs
+}
+
+// compareSources lists the source files that differ between want and have, sorted by path.
+func compareSources(want, have map[string]string) []string {
+	var paths []string
+	for p := range want {
+		paths = append(paths, p)
+	}
+	for p := range have {
+		if _, ok := want[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	var diffs []string
+	for _, p := range paths {
+		w, inWant := want[p]
+		h, inHave := have[p]
+		switch {
+		case !inWant:
+			diffs = append(diffs, "compiled in "+p+", which the record lacks")
+		case !inHave:
+			diffs = append(diffs, "did not compile in "+p)
+		case w != h:
+			diffs = append(diffs, "compiled a different "+p)
+		}
+	}
+	return diffs
+}
+
+// CheckSources lists the source files of r whose contents in repoDir differ from what was
+// compiled, as when a checkout has changed their line endings.
+func CheckSources(repoDir string, r *Record) []string {
+	var paths []string
+	for p := range r.Sources {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var diffs []string
+	for _, p := range paths {
+		b, err := ioutil.ReadFile(filepath.Join(repoDir, filepath.FromSlash(p)))
+		if err != nil {
+			diffs = append(diffs, fmt.Sprintf("%v: %v", p, errors.Cause(err)))
+			continue
+		}
+		if hash := crypto.Keccak256Hash(b).Hex(); hash != r.Sources[p] {
+			diffs = append(diffs, fmt.Sprintf("%v: has keccak256 %v, not %v", p, hash, r.Sources[p]))
+		}
+	}
+	return diffs
+}
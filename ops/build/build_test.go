@@ -0,0 +1,141 @@
+package build
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	runtime = "6080604052600080fdfe"
+	hashA   = "a165627a7a72305820" + "1111111111111111111111111111111111111111111111111111111111111111" + "0029"
+	hashB   = "a165627a7a72305820" + "2222222222222222222222222222222222222222222222222222222222222222" + "0029"
+	source  = "pragma solidity 0.5.7;\ncontract Vault {}\n"
+)
+
+// writeArtifact writes the combined-JSON artifact of a contract Vault, compiled from
+// contracts/Vault.sol with source, and returns the directory it is in.
+func writeArtifact(t *testing.T, metadataHash, source, sourcePath string) string {
+	meta, err := json.Marshal(map[string]interface{}{
+		"compiler": map[string]string{"version": "0.5.7+commit.6da8b019"},
+		"language": "Solidity",
+		"settings": map[string]interface{}{
+			"compilationTarget": map[string]string{sourcePath: "Vault"},
+			"evmVersion":        "petersburg",
+			"optimizer":         map[string]interface{}{"enabled": true, "runs": 100000},
+			"remappings":        []string{},
+		},
+		"sources": map[string]interface{}{
+			sourcePath: map[string]string{"keccak256": crypto.Keccak256Hash([]byte(source)).Hex()},
+		},
+	})
+	require.NoError(t, err)
+	combined, err := json.Marshal(map[string]interface{}{
+		"contracts": map[string]interface{}{
+			sourcePath + ":Vault": map[string]string{
+				"abi":         "[]",
+				"bin":         "6080" + runtime + metadataHash,
+				"bin-runtime": runtime + metadataHash,
+				"metadata":    string(meta),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "build")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Vault.json"), combined, 0644))
+	return dir
+}
+
+func TestLoadAll(t *testing.T) {
+	dir := writeArtifact(t, hashA, source, "contracts/Vault.sol")
+	defer os.RemoveAll(dir)
+	lock, err := LoadAll(dir)
+	require.NoError(t, err)
+	require.Contains(t, lock, "Vault")
+	r := lock["Vault"]
+	assert.Equal(t, "contracts/Vault.sol", r.Source)
+	assert.Equal(t, "0.5.7+commit.6da8b019", r.Compiler)
+	assert.Equal(t, 100000, r.Optimizer)
+	assert.Equal(t, "petersburg", r.EVMVersion)
+	assert.Equal(t, map[string]string{"contracts/Vault.sol": crypto.Keccak256Hash([]byte(source)).Hex()}, r.Sources)
+
+	abs := writeArtifact(t, hashA, source, "/home/someone/rsv/contracts/Vault.sol")
+	defer os.RemoveAll(abs)
+	_, err = LoadAll(abs)
+	assert.Error(t, err)
+
+	empty, err := ioutil.TempDir("", "build")
+	require.NoError(t, err)
+	defer os.RemoveAll(empty)
+	_, err = LoadAll(empty)
+	assert.Error(t, err)
+}
+
+func TestCompare(t *testing.T) {
+	load := func(metadataHash, source string) *Record {
+		dir := writeArtifact(t, metadataHash, source, "contracts/Vault.sol")
+		defer os.RemoveAll(dir)
+		lock, err := LoadAll(dir)
+		require.NoError(t, err)
+		return lock["Vault"]
+	}
+	want := load(hashA, source)
+	assert.Empty(t, Compare(want, load(hashA, source)))
+
+	// The normalized code leaves out the metadata hash.
+	assert.Empty(t, Compare(want, load(hashB, source)))
+
+	// A source with other line endings hashes differently.
+	assert.Equal(t, []string{"compiled a different contracts/Vault.sol"},
+		Compare(want, load(hashB, "pragma solidity 0.5.7;\r\ncontract Vault {}\r\n")))
+
+	have := *want
+	have.Compiler = "0.5.7+commit.00000000"
+	have.Optimizer = 0
+	have.Runtime = "0x00"
+	assert.Equal(t, []string{
+		"compiled with solc 0.5.7+commit.00000000, not 0.5.7+commit.6da8b019",
+		"optimized for 0 runs, not 100000 (0 is unoptimized)",
+		"the deployed code differs",
+	}, Compare(want, &have))
+
+	have = *want
+	have.Metadata = "0x00"
+	assert.Equal(t, []string{"the metadata differs, though not in anything recorded"}, Compare(want, &have))
+
+	have = *want
+	have.Code, have.Runtime = "0x00", "0x00"
+	assert.Equal(t, []string{"the init and deployed code differ, though the compiler inputs are the same"},
+		Compare(want, &have))
+}
+
+func TestLockFile(t *testing.T) {
+	dir := writeArtifact(t, hashA, source, "contracts/Vault.sol")
+	defer os.RemoveAll(dir)
+	lock, err := LoadAll(dir)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "build.lock.json")
+	require.NoError(t, WriteFile(path, lock))
+	read, err := ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, lock, read)
+
+	// The sources in the repo are checked against the record.
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "contracts"), 0755))
+	vault := filepath.Join(dir, "contracts", "Vault.sol")
+	require.NoError(t, ioutil.WriteFile(vault, []byte(source), 0644))
+	assert.Empty(t, CheckSources(dir, lock["Vault"]))
+	require.NoError(t, ioutil.WriteFile(vault, []byte("pragma solidity 0.5.7;\r\ncontract Vault {}\r\n"), 0644))
+	assert.Len(t, CheckSources(dir, lock["Vault"]), 1)
+	require.NoError(t, os.Remove(vault))
+	assert.Len(t, CheckSources(dir, lock["Vault"]), 1)
+}
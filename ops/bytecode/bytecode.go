@@ -0,0 +1,91 @@
+// Package bytecode compares deployed contract code against locally compiled artifacts.
+//
+// solc appends a CBOR-encoded metadata section to the runtime code of every contract, ending in
+// a two-byte big-endian length. The metadata holds a hash of the contract's metadata file, which
+// covers source file paths and comments, so two builds of identical code from different
+// checkouts can differ only there. Comparing with the metadata hashes zeroed tells those apart
+// from real differences.
+//
+// A contract that creates others, as the ProposalFactory does, embeds their init code, and with
+// it their metadata, so the hashes to zero are not only at the end.
+package bytecode
+
+import (
+	"bytes"
+)
+
+// Results of a comparison.
+const (
+	Exact    = "exact"    // byte-for-byte identical
+	Partial  = "partial"  // identical apart from the metadata section
+	Mismatch = "mismatch" // different code
+	NoCode   = "no code"  // nothing is deployed at the address
+)
+
+// Compare compares deployed runtime code against compiled runtime code.
+func Compare(deployed, compiled []byte) string {
+	switch {
+	case len(deployed) == 0:
+		return NoCode
+	case bytes.Equal(deployed, compiled):
+		return Exact
+	}
+	d, dOK := StripMetadata(deployed)
+	c, cOK := StripMetadata(compiled)
+	if dOK && cOK && bytes.Equal(Normalize(d), Normalize(c)) {
+		return Partial
+	}
+	return Mismatch
+}
+
+// StripMetadata returns code without its trailing metadata section. It reports false, and
+// returns code unchanged, if code doesn't end in something that looks like solc metadata.
+func StripMetadata(code []byte) ([]byte, bool) {
+	if len(code) < 2 {
+		return code, false
+	}
+	n := int(code[len(code)-2])<<8 | int(code[len(code)-1])
+	start := len(code) - 2 - n
+	if n == 0 || start < 0 {
+		return code, false
+	}
+	// The metadata is a CBOR map with a handful of entries: major type 5, small length.
+	if header := code[start]; header < 0xa1 || header > 0xa7 {
+		return code, false
+	}
+	return code[:start], true
+}
+
+// metadataHashes are the hash entries that solc writes in its metadata, as CBOR: each key,
+// followed by the header of a byte string of the hash's length. 0.5.7 writes bzzr0; 0.5.9 and
+// later write bzzr1, and 0.6 and later ipfs.
+var metadataHashes = [][]byte{
+	append([]byte{0x65}, "bzzr0\x58\x20"...),
+	append([]byte{0x65}, "bzzr1\x58\x20"...),
+	append([]byte{0x64}, "ipfs\x58\x22"...),
+}
+
+// Normalize returns a copy of code with the hash in every metadata section zeroed, embedded ones
+// included, so that two builds of the same code from different checkouts come out the same. The
+// rest of each section, such as the solc version that later compilers record, is kept.
+func Normalize(code []byte) []byte {
+	normalized := append([]byte(nil), code...)
+	for _, key := range metadataHashes {
+		size := int(key[len(key)-1])
+		for i := 0; ; {
+			at := bytes.Index(normalized[i:], key)
+			if at < 0 {
+				break
+			}
+			start := i + at + len(key)
+			if start+size > len(normalized) {
+				break
+			}
+			for j := start; j < start+size; j++ {
+				normalized[j] = 0
+			}
+			i = start + size
+		}
+	}
+	return normalized
+}
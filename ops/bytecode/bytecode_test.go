@@ -0,0 +1,68 @@
+package bytecode
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// The tail of a solc 0.5.7 runtime: code, then {"bzzr0": <32-byte swarm hash>}, then its length.
+const (
+	code      = "0x6080604052600080fdfe"
+	metadataA = "a165627a7a72305820" + "1111111111111111111111111111111111111111111111111111111111111111" + "0029"
+	metadataB = "a165627a7a72305820" + "2222222222222222222222222222222222222222222222222222222222222222" + "0029"
+)
+
+func TestStripMetadata(t *testing.T) {
+	stripped, ok := StripMetadata(hexutil.MustDecode(code + metadataA))
+	assert.True(t, ok)
+	assert.Equal(t, hexutil.MustDecode(code), stripped)
+
+	// Code without metadata is left alone.
+	for _, s := range []string{code, "0x00", "0x"} {
+		stripped, ok = StripMetadata(hexutil.MustDecode(s))
+		assert.False(t, ok, s)
+		assert.Equal(t, hexutil.MustDecode(s), stripped, s)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	deployed := hexutil.MustDecode(code + metadataA)
+	assert.Equal(t, Exact, Compare(deployed, hexutil.MustDecode(code+metadataA)))
+	assert.Equal(t, Partial, Compare(deployed, hexutil.MustDecode(code+metadataB)))
+	assert.Equal(t, Mismatch, Compare(deployed, hexutil.MustDecode("0x6080604052600180fdfe"+metadataA)))
+	assert.Equal(t, Mismatch, Compare(hexutil.MustDecode(code), hexutil.MustDecode(code+metadataA)))
+	assert.Equal(t, NoCode, Compare(nil, hexutil.MustDecode(code+metadataA)))
+}
+
+func TestNormalize(t *testing.T) {
+	zeroed := "a165627a7a72305820" + "0000000000000000000000000000000000000000000000000000000000000000" + "0029"
+	assert.Equal(t, hexutil.MustDecode(code+zeroed), Normalize(hexutil.MustDecode(code+metadataA)))
+
+	// A factory embeds the init code, and so the metadata, of what it creates.
+	factory := func(child, own string) []byte {
+		return hexutil.MustDecode(code + "6080" + child + "fe" + own)
+	}
+	a, b := factory(metadataA, metadataA), factory(metadataB, metadataA)
+	assert.Equal(t, Normalize(a), Normalize(b))
+	assert.Equal(t, Partial, Compare(a, b))
+	assert.Equal(t, factory(metadataA, metadataA), a, "Normalize changed its argument")
+
+	// Later compilers hash with bzzr1 or ipfs, and record their version, which is kept.
+	bzzr1 := "a265627a7a72315820" + "3333333333333333333333333333333333333333333333333333333333333333" +
+		"64736f6c6343000511" + "0032"
+	assert.Equal(t,
+		"0x"+code[2:]+"a265627a7a72315820"+"0000000000000000000000000000000000000000000000000000000000000000"+
+			"64736f6c6343000511"+"0032",
+		hexutil.Encode(Normalize(hexutil.MustDecode(code+bzzr1))))
+	ipfs := "a264697066735822" + "1220" + "4444444444444444444444444444444444444444444444444444444444444444" +
+		"64736f6c634300060c" + "0033"
+	assert.Equal(t,
+		"0x"+code[2:]+"a264697066735822"+"0000"+"0000000000000000000000000000000000000000000000000000000000000000"+
+			"64736f6c634300060c"+"0033",
+		hexutil.Encode(Normalize(hexutil.MustDecode(code+ipfs))))
+
+	// Code without metadata is left alone.
+	assert.Equal(t, hexutil.MustDecode(code), Normalize(hexutil.MustDecode(code)))
+}
@@ -0,0 +1,129 @@
+package chain
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+// ParseArgs converts command-line strings to arguments for method, with the Go types that
+// the abi package expects. Integers are decimal (or 0x-prefixed hex), booleans are
+// "true" or "false", byte strings are 0x-prefixed hex, and arrays are written
+// "[elem,elem,...]".
+func ParseArgs(method abi.Method, args []string) ([]interface{}, error) {
+	if len(args) != len(method.Inputs) {
+		return nil, errors.Errorf("%v takes %v arguments, got %v", method.Sig(), len(method.Inputs), len(args))
+	}
+	result := make([]interface{}, len(args))
+	for i, input := range method.Inputs {
+		v, err := parseArg(input.Type, args[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "argument %v (%v %v)", i+1, input.Type, input.Name)
+		}
+		result[i] = v.Interface()
+	}
+	return result, nil
+}
+
+// FindMethod looks up a method of a by name, or by full signature like "mint(address,uint256)".
+func (a *Artifact) FindMethod(name string) (abi.Method, error) {
+	for _, m := range a.ABI.Methods {
+		if m.Name == name || m.Sig() == name {
+			return m, nil
+		}
+	}
+	return abi.Method{}, errors.Errorf("%v has no method %q", a.Name, name)
+}
+
+var bigIntType = reflect.TypeOf(&big.Int{})
+
+func parseArg(t abi.Type, s string) (reflect.Value, error) {
+	s = strings.TrimSpace(s)
+	switch t.T {
+	case abi.AddressTy:
+		if !common.IsHexAddress(s) {
+			return reflect.Value{}, errors.Errorf("%q is not an address", s)
+		}
+		return reflect.ValueOf(common.HexToAddress(s)), nil
+	case abi.BoolTy:
+		switch s {
+		case "true":
+			return reflect.ValueOf(true), nil
+		case "false":
+			return reflect.ValueOf(false), nil
+		}
+		return reflect.Value{}, errors.Errorf("%q is not true or false", s)
+	case abi.StringTy:
+		return reflect.ValueOf(s), nil
+	case abi.BytesTy:
+		b, err := hexutil.Decode(s)
+		if err != nil {
+			return reflect.Value{}, errors.Wrapf(err, "parsing %q", s)
+		}
+		return reflect.ValueOf(b), nil
+	case abi.FixedBytesTy:
+		b, err := hexutil.Decode(s)
+		if err != nil {
+			return reflect.Value{}, errors.Wrapf(err, "parsing %q", s)
+		}
+		if len(b) != t.Size {
+			return reflect.Value{}, errors.Errorf("%q is %v bytes, want %v", s, len(b), t.Size)
+		}
+		v := reflect.New(t.Type).Elem()
+		reflect.Copy(v, reflect.ValueOf(b))
+		return v, nil
+	case abi.IntTy, abi.UintTy:
+		n, ok := new(big.Int).SetString(s, 0)
+		if !ok {
+			return reflect.Value{}, errors.Errorf("%q is not an integer", s)
+		}
+		if t.T == abi.UintTy && n.Sign() < 0 {
+			return reflect.Value{}, errors.Errorf("%q is negative", s)
+		}
+		bits := t.Size
+		if t.T == abi.IntTy {
+			bits--
+		}
+		if n.BitLen() > bits {
+			return reflect.Value{}, errors.Errorf("%q does not fit in %v", s, t)
+		}
+		if t.Type == bigIntType {
+			return reflect.ValueOf(n), nil
+		}
+		if t.T == abi.IntTy {
+			return reflect.ValueOf(n.Int64()).Convert(t.Type), nil
+		}
+		return reflect.ValueOf(n.Uint64()).Convert(t.Type), nil
+	case abi.SliceTy, abi.ArrayTy:
+		if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+			return reflect.Value{}, errors.Errorf("%q is not a bracketed list", s)
+		}
+		var elems []string
+		if inner := strings.TrimSpace(s[1 : len(s)-1]); inner != "" {
+			elems = strings.Split(inner, ",")
+		}
+		var v reflect.Value
+		if t.T == abi.SliceTy {
+			v = reflect.MakeSlice(t.Type, len(elems), len(elems))
+		} else {
+			if len(elems) != t.Size {
+				return reflect.Value{}, errors.Errorf("%q has %v elements, want %v", s, len(elems), t.Size)
+			}
+			v = reflect.New(t.Type).Elem()
+		}
+		for i, elem := range elems {
+			ev, err := parseArg(*t.Elem, elem)
+			if err != nil {
+				return reflect.Value{}, errors.Wrapf(err, "element %v", i)
+			}
+			v.Index(i).Set(ev)
+		}
+		return v, nil
+	}
+	return reflect.Value{}, errors.Errorf("arguments of type %v are not supported", t)
+}
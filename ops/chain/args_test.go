@@ -0,0 +1,55 @@
+package chain
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const argsABI = `[{"type":"function","name":"f","inputs":[
+	{"name":"a","type":"address"},
+	{"name":"n","type":"uint256"},
+	{"name":"small","type":"uint8"},
+	{"name":"b","type":"bool"},
+	{"name":"h","type":"bytes32"},
+	{"name":"list","type":"uint256[]"}],"outputs":[]}]`
+
+func TestParseArgs(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(argsABI))
+	require.NoError(t, err)
+	method := parsed.Methods["f"]
+
+	args, err := ParseArgs(method, []string{
+		"0x196f4727526eA7FB1e17b2071B3d8eAA38486988",
+		"1000000000000000000000",
+		"0x12",
+		"true",
+		"0x" + strings.Repeat("ab", 32),
+		"[1, 2,3]",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, common.HexToAddress("0x196f4727526eA7FB1e17b2071B3d8eAA38486988"), args[0])
+	assert.Equal(t, "1000000000000000000000", args[1].(*big.Int).String())
+	assert.Equal(t, uint8(18), args[2])
+	assert.Equal(t, true, args[3])
+	assert.Equal(t, []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}, args[5])
+
+	_, err = method.Inputs.Pack(args...)
+	assert.NoError(t, err, "parsed arguments have the types that abi expects")
+
+	good := []string{"0x0000000000000000000000000000000000000001", "1", "1", "true", "0x" + strings.Repeat("00", 32), "[]"}
+	bad := map[int]string{0: "0x1", 1: "-1", 2: "256", 3: "yes", 4: "0x00", 5: "[1,x]"}
+	for i, value := range bad {
+		args := append([]string(nil), good...)
+		args[i] = value
+		_, err := ParseArgs(method, args)
+		assert.Error(t, err, "argument %v = %q", i, value)
+	}
+	_, err = ParseArgs(method, []string{"too few"})
+	assert.Error(t, err)
+}
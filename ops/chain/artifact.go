@@ -0,0 +1,142 @@
+// Package chain holds the pieces that the operator tools share for talking to the deployed RSV
+// contracts: loading compiled contract artifacts, binding them to addresses, and building,
+// signing, and sending transactions.
+//
+// Contract ABIs are read at runtime from the same solc combined-JSON outputs in evm/ that
+// genABI.go turns into the abi package, so the tools build without first generating bindings.
+package chain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/pkg/errors"
+)
+
+// DefaultArtifactsDir is where `make json` writes solc's combined-JSON output.
+const DefaultArtifactsDir = "evm"
+
+// Artifact is the compiled form of one contract.
+type Artifact struct {
+	Name       string
+	Source     string // the file it was compiled from, as solc names it, such as contracts/Vault.sol
+	ABI        abi.ABI
+	ABIJSON    string
+	Bin        []byte // init code
+	BinRuntime []byte // deployed code
+
+	// Metadata is the metadata that solc hashes into the code: the compiler, its settings, and
+	// the hash of every source file. It is empty in artifacts built without it.
+	Metadata string
+}
+
+// Artifacts loads and caches contract artifacts from a directory of solc combined-JSON files,
+// one per contract, named <contract name>.json.
+type Artifacts struct {
+	Dir string
+
+	mu    sync.Mutex
+	cache map[string]*Artifact
+}
+
+// NewArtifacts returns an artifact loader for dir. An empty dir means DefaultArtifactsDir.
+func NewArtifacts(dir string) *Artifacts {
+	if dir == "" {
+		dir = DefaultArtifactsDir
+	}
+	return &Artifacts{Dir: dir, cache: make(map[string]*Artifact)}
+}
+
+// Load returns the artifact for the contract called name.
+func (a *Artifacts) Load(name string) (*Artifact, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if artifact, ok := a.cache[name]; ok {
+		return artifact, nil
+	}
+	artifact, err := loadArtifact(filepath.Join(a.Dir, name+".json"), name)
+	if err != nil {
+		return nil, err
+	}
+	a.cache[name] = artifact
+	return artifact, nil
+}
+
+func loadArtifact(path, name string) (*Artifact, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening artifact for %v (has `make json` been run?)", name)
+	}
+	defer f.Close()
+
+	var combined struct {
+		Contracts map[string]struct {
+			ABI        string
+			Bin        string
+			BinRuntime string `json:"bin-runtime"`
+			Metadata   string
+		}
+	}
+	if err := json.NewDecoder(f).Decode(&combined); err != nil {
+		return nil, errors.Wrapf(err, "parsing solc output in %v", path)
+	}
+
+	// Keys have the format <.sol filename>:<contract name>; as in genABI.go, we treat all
+	// contracts as living in one namespace.
+	found := ""
+	for key := range combined.Contracts {
+		if key[strings.LastIndex(key, ":")+1:] == name {
+			if found != "" {
+				return nil, errors.Errorf("multiple %v instances in %v", name, path)
+			}
+			found = key
+		}
+	}
+	if found == "" {
+		return nil, errors.Errorf("no %v instances in %v", name, path)
+	}
+	output := combined.Contracts[found]
+
+	parsed, err := abi.JSON(strings.NewReader(output.ABI))
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing ABI of %v", name)
+	}
+	bin, err := hex.DecodeString(output.Bin)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding bytecode of %v", name)
+	}
+	binRuntime, err := hex.DecodeString(output.BinRuntime)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding runtime bytecode of %v", name)
+	}
+	return &Artifact{
+		Name:       name,
+		Source:     found[:strings.LastIndex(found, ":")],
+		ABI:        parsed,
+		ABIJSON:    output.ABI,
+		Bin:        bin,
+		BinRuntime: binRuntime,
+		Metadata:   output.Metadata,
+	}, nil
+}
+
+// HasMethod reports whether the contract's ABI has a method called name.
+func (a *Artifact) HasMethod(name string) bool {
+	_, ok := a.ABI.Methods[name]
+	return ok
+}
+
+// InitCode returns the code that creates an instance of the contract with the given
+// constructor arguments.
+func (a *Artifact) InitCode(args ...interface{}) ([]byte, error) {
+	packed, err := a.ABI.Pack("", args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "packing constructor arguments for %v", a.Name)
+	}
+	return append(append([]byte(nil), a.Bin...), packed...), nil
+}
@@ -0,0 +1,88 @@
+package chain
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+// Batch aggregates view calls into a single JSON-RPC batch request, all reading state at the
+// same block, so that a dashboard's worth of reads costs one round trip and sees one
+// consistent state.
+type Batch struct {
+	client *Client
+	block  *big.Int
+	calls  []batchCall
+}
+
+type batchCall struct {
+	contract *Contract
+	method   string
+	result   interface{}
+	data     []byte
+	output   hexutil.Bytes
+}
+
+// NewBatch returns an empty batch that reads state at block, or at the latest block if block is
+// nil. Pin the block when the batch is one of several that must agree.
+func (c *Client) NewBatch(block *big.Int) *Batch {
+	return &Batch{client: c, block: block}
+}
+
+// Add queues a call of a view method, whose return value Do will unpack into result, as for
+// bind.BoundContract.Call.
+func (b *Batch) Add(contract *Contract, result interface{}, method string, args ...interface{}) error {
+	data, err := contract.ABI.Pack(method, args...)
+	if err != nil {
+		return errors.Wrapf(err, "packing %v.%v", contract.Name, method)
+	}
+	b.calls = append(b.calls, batchCall{contract: contract, method: method, result: result, data: data})
+	return nil
+}
+
+// Len is the number of calls queued.
+func (b *Batch) Len() int {
+	return len(b.calls)
+}
+
+// Do sends the queued calls and unpacks their results. It fails if any call fails.
+func (b *Batch) Do(ctx context.Context) error {
+	if len(b.calls) == 0 {
+		return nil
+	}
+	block := "latest"
+	if b.block != nil {
+		block = hexutil.EncodeBig(b.block)
+	}
+	elems := make([]rpc.BatchElem, len(b.calls))
+	for i := range b.calls {
+		call := &b.calls[i]
+		elems[i] = rpc.BatchElem{
+			Method: "eth_call",
+			Args: []interface{}{map[string]interface{}{
+				"to":   call.contract.Address,
+				"data": hexutil.Bytes(call.data),
+			}, block},
+			Result: &call.output,
+		}
+	}
+	if err := b.client.RPC.BatchCallContext(ctx, elems); err != nil {
+		return errors.Wrap(err, "sending batched calls")
+	}
+	for i, call := range b.calls {
+		if elems[i].Error != nil {
+			return errors.Wrapf(elems[i].Error, "calling %v.%v", call.contract.Name, call.method)
+		}
+		if len(call.output) == 0 {
+			return errors.Errorf("calling %v.%v: no result (is there code at %v?)",
+				call.contract.Name, call.method, call.contract.Address.Hex())
+		}
+		if err := call.contract.ABI.Unpack(call.result, call.method, call.output); err != nil {
+			return errors.Wrapf(err, "unpacking %v.%v", call.contract.Name, call.method)
+		}
+	}
+	return nil
+}
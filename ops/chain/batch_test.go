@@ -0,0 +1,70 @@
+package chain
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const viewsABI = `[
+	{"constant":true,"type":"function","name":"totalSupply","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"constant":true,"type":"function","name":"owner","inputs":[],"outputs":[{"name":"","type":"address"}]}]`
+
+// FakeEth serves eth_call, answering each method of viewsABI with a fixed value. It and its
+// argument type are exported because the rpc package only serves exported types.
+type FakeEth struct {
+	abi    abi.ABI
+	blocks []string
+}
+
+type CallArgs struct {
+	To   common.Address `json:"to"`
+	Data hexutil.Bytes  `json:"data"`
+}
+
+func (f *FakeEth) Call(ctx context.Context, args CallArgs, block string) (hexutil.Bytes, error) {
+	f.blocks = append(f.blocks, block)
+	switch {
+	case string(args.Data[:4]) == string(f.abi.Methods["totalSupply"].Id()):
+		return common.LeftPadBytes(big.NewInt(1000).Bytes(), 32), nil
+	case string(args.Data[:4]) == string(f.abi.Methods["owner"].Id()):
+		return common.LeftPadBytes(args.To.Bytes(), 32), nil
+	}
+	return nil, errors.New("execution reverted")
+}
+
+func TestBatch(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(viewsABI))
+	require.NoError(t, err)
+	eth := &FakeEth{abi: parsed}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", eth))
+	rpcClient := rpc.DialInProc(server)
+	client := &Client{Client: ethclient.NewClient(rpcClient), RPC: rpcClient}
+
+	addr := common.HexToAddress("0x196f4727526eA7FB1e17b2071B3d8eAA38486988")
+	reserve := (&Artifact{Name: "Reserve", ABI: parsed}).Bind(addr, client)
+
+	b := client.NewBatch(big.NewInt(7))
+	supply := new(big.Int)
+	var owner common.Address
+	require.NoError(t, b.Add(reserve, &supply, "totalSupply"))
+	require.NoError(t, b.Add(reserve, &owner, "owner"))
+	assert.Equal(t, 2, b.Len())
+	require.NoError(t, b.Do(context.Background()))
+	assert.Equal(t, big.NewInt(1000), supply)
+	assert.Equal(t, addr, owner)
+	assert.Equal(t, []string{"0x7", "0x7"}, eth.blocks)
+
+	assert.Error(t, b.Add(reserve, &owner, "balanceOf"), "unknown method")
+}
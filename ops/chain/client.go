@@ -0,0 +1,53 @@
+package chain
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+// Client is a connection to an Ethereum node.
+//
+// It is an *ethclient.Client that also keeps the underlying RPC connection around, for the
+// handful of calls that ethclient doesn't wrap.
+type Client struct {
+	*ethclient.Client
+	RPC *rpc.Client
+}
+
+// Dial connects to the node at url.
+func Dial(url string) (*Client, error) {
+	rpcClient, err := rpc.Dial(url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "dialing %v", url)
+	}
+	return &Client{Client: ethclient.NewClient(rpcClient), RPC: rpcClient}, nil
+}
+
+// ChainID returns the EIP-155 chain ID that the node reports.
+func (c *Client) ChainID(ctx context.Context) (*big.Int, error) {
+	var result hexutil.Big
+	if err := c.RPC.CallContext(ctx, &result, "eth_chainId"); err != nil {
+		return nil, errors.Wrap(err, "reading chain ID")
+	}
+	return (*big.Int)(&result), nil
+}
+
+// ImplementationSlot is the storage slot in which an ERC-1967 proxy, such as a ReserveProxy,
+// keeps the address of its implementation.
+var ImplementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+
+// Implementation returns the implementation behind the ERC-1967 proxy at addr, as of block
+// (nil for the latest), or the zero address if addr isn't a proxy.
+func (c *Client) Implementation(ctx context.Context, addr common.Address, block *big.Int) (common.Address, error) {
+	b, err := c.StorageAt(ctx, addr, ImplementationSlot, block)
+	if err != nil {
+		return common.Address{}, errors.Wrapf(err, "reading the implementation slot of %v", addr.Hex())
+	}
+	return common.BytesToAddress(b), nil
+}
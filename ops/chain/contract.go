@@ -0,0 +1,83 @@
+package chain
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+// Contract is a deployed contract, bound to its ABI.
+type Contract struct {
+	*bind.BoundContract
+	Name    string
+	Address common.Address
+	ABI     abi.ABI
+
+	// Block, if set, is the block that view calls read state at. Otherwise they read the latest.
+	Block *big.Int
+}
+
+// Bind binds the artifact to a deployed instance at address.
+func (a *Artifact) Bind(address common.Address, backend bind.ContractBackend) *Contract {
+	return &Contract{
+		BoundContract: bind.NewBoundContract(address, a.ABI, backend, backend, backend),
+		Name:          a.Name,
+		Address:       address,
+		ABI:           a.ABI,
+	}
+}
+
+// String returns the contract's name and address, for messages.
+func (c *Contract) String() string {
+	return c.Name + "@" + c.Address.Hex()
+}
+
+// At returns a copy of c whose view calls read state at block.
+func (c *Contract) At(block *big.Int) *Contract {
+	pinned := *c
+	pinned.Block = block
+	return &pinned
+}
+
+func (c *Contract) opts(ctx context.Context) *bind.CallOpts {
+	return &bind.CallOpts{Context: ctx, BlockNumber: c.Block}
+}
+
+// CallAddress calls a view method that returns a single address.
+func (c *Contract) CallAddress(ctx context.Context, method string, args ...interface{}) (common.Address, error) {
+	var result common.Address
+	err := c.Call(c.opts(ctx), &result, method, args...)
+	return result, errors.Wrapf(err, "calling %v.%v", c.Name, method)
+}
+
+// CallBig calls a view method that returns a single integer.
+func (c *Contract) CallBig(ctx context.Context, method string, args ...interface{}) (*big.Int, error) {
+	result := new(big.Int)
+	err := c.Call(c.opts(ctx), &result, method, args...)
+	return result, errors.Wrapf(err, "calling %v.%v", c.Name, method)
+}
+
+// CallBool calls a view method that returns a single bool.
+func (c *Contract) CallBool(ctx context.Context, method string, args ...interface{}) (bool, error) {
+	var result bool
+	err := c.Call(c.opts(ctx), &result, method, args...)
+	return result, errors.Wrapf(err, "calling %v.%v", c.Name, method)
+}
+
+// CallString calls a view method that returns a single string.
+func (c *Contract) CallString(ctx context.Context, method string, args ...interface{}) (string, error) {
+	var result string
+	err := c.Call(c.opts(ctx), &result, method, args...)
+	return result, errors.Wrapf(err, "calling %v.%v", c.Name, method)
+}
+
+// CallBytes calls a view method that returns a single bytes.
+func (c *Contract) CallBytes(ctx context.Context, method string, args ...interface{}) ([]byte, error) {
+	var result []byte
+	err := c.Call(c.opts(ctx), &result, method, args...)
+	return result, errors.Wrapf(err, "calling %v.%v", c.Name, method)
+}
@@ -0,0 +1,86 @@
+package chain
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+// Event is a decoded contract event.
+type Event struct {
+	Name   string
+	Names  []string
+	Values []interface{}
+}
+
+// String renders the event for humans, e.g. `Transfer(from=0x12..., to=0x34..., value=100)`.
+func (e *Event) String() string {
+	parts := make([]string, len(e.Names))
+	for i, arg := range formatArgList(e.Values) {
+		parts[i] = e.Names[i] + "=" + arg
+	}
+	return e.Name + "(" + strings.Join(parts, ", ") + ")"
+}
+
+// DecodeLog decodes a log emitted by a contract with a's ABI. Indexed arguments of dynamic
+// type are only available as hashes, and are returned as such.
+func (a *Artifact) DecodeLog(topics []common.Hash, data []byte) (*Event, error) {
+	if len(topics) == 0 {
+		return nil, errors.New("anonymous logs cannot be decoded")
+	}
+	var event *abi.Event
+	for _, e := range a.ABI.Events {
+		if e.Id() == topics[0] {
+			e := e
+			event = &e
+			break
+		}
+	}
+	if event == nil {
+		return nil, errors.Errorf("%v has no event with topic %v", a.Name, topics[0].Hex())
+	}
+
+	nonIndexed, err := event.Inputs.NonIndexed().UnpackValues(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding %v.%v", a.Name, event.Name)
+	}
+	result := &Event{Name: event.Name}
+	topic := 1
+	for _, input := range event.Inputs {
+		var value interface{}
+		if input.Indexed {
+			if topic >= len(topics) {
+				return nil, errors.Errorf("%v.%v log is missing topics", a.Name, event.Name)
+			}
+			value = decodeTopic(input.Type, topics[topic])
+			topic++
+		} else {
+			value, nonIndexed = nonIndexed[0], nonIndexed[1:]
+		}
+		result.Names = append(result.Names, input.Name)
+		result.Values = append(result.Values, value)
+	}
+	return result, nil
+}
+
+func decodeTopic(t abi.Type, topic common.Hash) interface{} {
+	switch t.T {
+	case abi.AddressTy:
+		return common.BytesToAddress(topic.Bytes())
+	case abi.UintTy:
+		return new(big.Int).SetBytes(topic.Bytes())
+	case abi.IntTy:
+		n := new(big.Int).SetBytes(topic.Bytes())
+		if topic[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		return n
+	case abi.BoolTy:
+		return topic[31] != 0
+	default:
+		return topic
+	}
+}
@@ -0,0 +1,39 @@
+package chain
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const transferABI = `[{"anonymous":false,"type":"event","name":"Transfer","inputs":[
+	{"indexed":true,"name":"from","type":"address"},
+	{"indexed":true,"name":"to","type":"address"},
+	{"indexed":false,"name":"value","type":"uint256"}]}]`
+
+func TestDecodeLog(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(transferABI))
+	require.NoError(t, err)
+	a := &Artifact{Name: "Reserve", ABI: parsed}
+
+	from := common.HexToAddress("0x196f4727526eA7FB1e17b2071B3d8eAA38486988")
+	to := common.HexToAddress("0x4B481872f31bab47C6780D5488c84D309b1B8Bb6")
+	topics := []common.Hash{
+		parsed.Events["Transfer"].Id(),
+		common.BytesToHash(from.Bytes()),
+		common.BytesToHash(to.Bytes()),
+	}
+	event, err := a.DecodeLog(topics, common.LeftPadBytes(big.NewInt(100).Bytes(), 32))
+	require.NoError(t, err)
+	assert.Equal(t, "Transfer(from="+from.Hex()+", to="+to.Hex()+", value=100)", event.String())
+
+	_, err = a.DecodeLog([]common.Hash{{}}, nil)
+	assert.Error(t, err, "unknown event")
+	_, err = a.DecodeLog(topics[:2], nil)
+	assert.Error(t, err, "missing topic")
+}
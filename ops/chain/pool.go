@@ -0,0 +1,573 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+// Pool spreads the JSON-RPC requests of a Client over several HTTP(S) providers, so that the
+// tools keep working when one of them fails or falls behind, and so that no single provider
+// can feed them wrong contract state.
+//
+//   - Each request goes to the first healthy provider, in the order given, and to the next if
+//     that one fails. A provider is healthy unless its last request failed within Backoff, or
+//     it trails the provider with the highest block by more than MaxLag blocks.
+//   - Reads of contract state (eth_call, eth_getBalance, eth_getCode, and eth_getStorageAt),
+//     which return the owners, the supply, and the Vault's balances, go to every healthy
+//     provider, and need Quorum of them to give the same answer.
+//   - "latest" is the lowest block among the healthy providers, which all of them have, so
+//     that honest providers agree: eth_blockNumber returns it, and reads of the latest block or
+//     state read it.
+//
+// Filters live on the provider that created them, so they are not failed over: their requests
+// fail with the provider, and the caller creates the filter again. Subscriptions need a
+// websocket or IPC connection, which a Pool is not.
+type Pool struct {
+	URLs []string
+
+	// Quorum is how many providers must agree on a read of contract state; at most 1 means
+	// that reads are failed over, but not compared.
+	Quorum int
+
+	// MaxLag is how many blocks a provider may trail the one with the highest block.
+	MaxLag uint64
+
+	// Backoff is how long a failed provider is skipped; zero means 30 seconds.
+	Backoff time.Duration
+
+	// HeadInterval is how long the providers' latest blocks are cached; zero means a second.
+	HeadInterval time.Duration
+
+	once    sync.Once
+	client  *http.Client
+	mu      sync.Mutex
+	state   []providerState
+	headsAt time.Time
+	filters map[string]int
+}
+
+type providerState struct {
+	head      uint64
+	downUntil time.Time
+	err       error
+}
+
+// jsonrpcMessage is a JSON-RPC request or response.
+type jsonrpcMessage struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+// blockParams is the position of the block parameter of the methods "latest" is pinned for.
+var blockParams = map[string]int{
+	"eth_getBlockByNumber": 0,
+	"eth_call":             1,
+	"eth_getBalance":       1,
+	"eth_getCode":          1,
+	"eth_getStorageAt":     2,
+}
+
+// quorumMethods are the reads of contract state.
+var quorumMethods = map[string]bool{
+	"eth_call":         true,
+	"eth_getBalance":   true,
+	"eth_getCode":      true,
+	"eth_getStorageAt": true,
+}
+
+// Filters are created, used, and removed by these methods.
+var (
+	newFilterMethods = map[string]bool{"eth_newFilter": true, "eth_newBlockFilter": true, "eth_newPendingTransactionFilter": true}
+	filterMethods    = map[string]bool{"eth_getFilterChanges": true, "eth_getFilterLogs": true, "eth_uninstallFilter": true}
+)
+
+// Dial returns a Client whose requests go through p.
+func (p *Pool) Dial() (*Client, error) {
+	if len(p.URLs) == 0 {
+		return nil, errors.New("no providers are given")
+	}
+	for _, url := range p.URLs {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			return nil, errors.Errorf("provider %v is not an HTTP(S) URL", url)
+		}
+	}
+	if p.Quorum > len(p.URLs) {
+		return nil, errors.Errorf("the quorum of %v is more than the %v providers", p.Quorum, len(p.URLs))
+	}
+	rpcClient, err := rpc.DialHTTPWithClient("http://pool", &http.Client{Transport: p})
+	if err != nil {
+		return nil, errors.Wrap(err, "dialing provider pool")
+	}
+	return &Client{Client: ethclient.NewClient(rpcClient), RPC: rpcClient}, nil
+}
+
+func (p *Pool) init() {
+	p.once.Do(func() {
+		p.client = &http.Client{Timeout: time.Minute}
+		p.state = make([]providerState, len(p.URLs))
+		p.filters = map[string]int{}
+		if p.Backoff == 0 {
+			p.Backoff = 30 * time.Second
+		}
+		if p.HeadInterval == 0 {
+			p.HeadInterval = time.Second
+		}
+	})
+}
+
+// RoundTrip answers an HTTP request of the rpc package: one JSON-RPC request, or a batch.
+func (p *Pool) RoundTrip(req *http.Request) (*http.Response, error) {
+	p.init()
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	batch := len(bytes.TrimSpace(body)) > 0 && bytes.TrimSpace(body)[0] == '['
+	var msgs []*jsonrpcMessage
+	if batch {
+		err = json.Unmarshal(body, &msgs)
+	} else {
+		msg := new(jsonrpcMessage)
+		err = json.Unmarshal(body, msg)
+		msgs = []*jsonrpcMessage{msg}
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing JSON-RPC request")
+	}
+
+	resps, err := p.handle(req.Context(), msgs)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	if batch {
+		out, err = json.Marshal(resps)
+	} else {
+		out, err = json.Marshal(resps[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(out)),
+		ContentLength: int64(len(out)),
+		Request:       req,
+	}, nil
+}
+
+// handle answers msgs, in order.
+func (p *Pool) handle(ctx context.Context, msgs []*jsonrpcMessage) ([]*jsonrpcMessage, error) {
+	healthy, pin, err := p.heads(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resps := make([]*jsonrpcMessage, len(msgs))
+	var forward []*jsonrpcMessage
+	var forwardAt []int
+	quorum := false
+	for i, msg := range msgs {
+		switch {
+		case msg.Method == "eth_blockNumber":
+			resps[i] = result(msg, hexutil.Uint64(pin))
+		case newFilterMethods[msg.Method] || filterMethods[msg.Method]:
+			if resps[i], err = p.filter(ctx, healthy, msg); err != nil {
+				return nil, err
+			}
+		default:
+			pinned, err := pinBlock(msg, pin)
+			if err != nil {
+				resps[i] = errorResult(msg, -32602, err.Error())
+				continue
+			}
+			quorum = quorum || (pinned && quorumMethods[msg.Method])
+			forward = append(forward, msg)
+			forwardAt = append(forwardAt, i)
+		}
+	}
+	if len(forward) == 0 {
+		return resps, nil
+	}
+
+	var got []*jsonrpcMessage
+	if quorum && p.Quorum > 1 {
+		got, err = p.quorum(ctx, healthy, forward)
+	} else {
+		got, _, err = p.failover(ctx, healthy, forward)
+	}
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*jsonrpcMessage, len(got))
+	for _, r := range got {
+		byID[string(r.ID)] = r
+	}
+	for j, msg := range forward {
+		if r, ok := byID[string(msg.ID)]; ok {
+			resps[forwardAt[j]] = r
+		} else {
+			resps[forwardAt[j]] = errorResult(msg, -32603, "no response from the provider")
+		}
+	}
+	return resps, nil
+}
+
+// heads returns the healthy providers, in order, and the block that "latest" is pinned to,
+// reading the providers' latest blocks if the last read is over HeadInterval old. If every
+// provider is skipped after failing, they are all tried again rather than none.
+func (p *Pool) heads(ctx context.Context) ([]int, uint64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Since(p.headsAt) >= p.HeadInterval {
+		p.probe(ctx, false)
+	}
+	healthy, pin := p.healthy()
+	if len(healthy) == 0 {
+		p.probe(ctx, true)
+		healthy, pin = p.healthy()
+	}
+	if len(healthy) == 0 {
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		var errs []string
+		for i, s := range p.state {
+			errs = append(errs, fmt.Sprintf("%v: %v", p.URLs[i], s.err))
+		}
+		return nil, 0, errors.Errorf("no provider is available (%v)", strings.Join(errs, "; "))
+	}
+	return healthy, pin, nil
+}
+
+// probe reads the latest block of each provider not skipped, or of all of them if all is
+// set. p.mu must be held.
+func (p *Pool) probe(ctx context.Context, all bool) {
+	now := time.Now()
+	var wg sync.WaitGroup
+	heads := make([]uint64, len(p.URLs))
+	errs := make([]error, len(p.URLs))
+	probed := make([]bool, len(p.URLs))
+	for i := range p.URLs {
+		if !all && now.Before(p.state[i].downUntil) {
+			continue
+		}
+		probed[i] = true
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			heads[i], errs[i] = p.blockNumber(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+	for i := range p.URLs {
+		switch {
+		case !probed[i]:
+		case errs[i] != nil:
+			p.markDown(i, errs[i])
+		default:
+			p.state[i] = providerState{head: heads[i]}
+		}
+	}
+	p.headsAt = now
+}
+
+// healthy returns the providers neither skipped nor lagging, and the lowest of their latest
+// blocks. p.mu must be held.
+func (p *Pool) healthy() ([]int, uint64) {
+	now := time.Now()
+	var best uint64
+	for _, s := range p.state {
+		if !now.Before(s.downUntil) && s.head > best {
+			best = s.head
+		}
+	}
+	var healthy []int
+	var pin uint64
+	for i, s := range p.state {
+		if now.Before(s.downUntil) || s.head+p.MaxLag < best {
+			continue
+		}
+		if len(healthy) == 0 || s.head < pin {
+			pin = s.head
+		}
+		healthy = append(healthy, i)
+	}
+	return healthy, pin
+}
+
+// markDown records that provider i failed with err. p.mu must be held.
+func (p *Pool) markDown(i int, err error) {
+	if time.Now().After(p.state[i].downUntil) {
+		log.Printf("chain: provider %v failed, skipping it for %v: %v", p.URLs[i], p.Backoff, err)
+	}
+	p.state[i].downUntil = time.Now().Add(p.Backoff)
+	p.state[i].err = err
+}
+
+func (p *Pool) blockNumber(ctx context.Context, i int) (uint64, error) {
+	resps, err := p.post(ctx, i, []*jsonrpcMessage{{Version: "2.0", ID: json.RawMessage("1"), Method: "eth_blockNumber", Params: json.RawMessage("[]")}})
+	if err != nil {
+		return 0, err
+	}
+	if len(resps) != 1 || len(resps[0].Error) > 0 {
+		return 0, errors.Errorf("eth_blockNumber failed: %s", resps[0].Error)
+	}
+	var head hexutil.Uint64
+	if err := json.Unmarshal(resps[0].Result, &head); err != nil {
+		return 0, errors.Wrap(err, "parsing eth_blockNumber")
+	}
+	return uint64(head), nil
+}
+
+// post sends msgs, as a batch, to provider i.
+func (p *Pool) post(ctx context.Context, i int, msgs []*jsonrpcMessage) ([]*jsonrpcMessage, error) {
+	body, err := json.Marshal(msgs)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.URLs[i], bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, errors.New(resp.Status)
+	}
+	var resps []*jsonrpcMessage
+	if err := json.Unmarshal(data, &resps); err != nil {
+		// Some providers answer a batch that fails as a whole with a single error.
+		var single jsonrpcMessage
+		if json.Unmarshal(data, &single) == nil && len(single.Error) > 0 {
+			return nil, errors.Errorf("batch failed: %s", single.Error)
+		}
+		return nil, errors.Wrap(err, "parsing JSON-RPC response")
+	}
+	return resps, nil
+}
+
+// failover sends msgs to the first of the healthy providers that answers, and returns its
+// answer and index.
+func (p *Pool) failover(ctx context.Context, healthy []int, msgs []*jsonrpcMessage) ([]*jsonrpcMessage, int, error) {
+	var errs []string
+	for _, i := range healthy {
+		resps, err := p.post(ctx, i, msgs)
+		if err == nil {
+			return resps, i, nil
+		}
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		p.mu.Lock()
+		p.markDown(i, err)
+		p.mu.Unlock()
+		errs = append(errs, fmt.Sprintf("%v: %v", p.URLs[i], err))
+	}
+	return nil, 0, errors.Errorf("every provider failed (%v)", strings.Join(errs, "; "))
+}
+
+// quorum sends msgs to every healthy provider, and answers each with the response that Quorum
+// of them give, or with an error if they don't agree.
+func (p *Pool) quorum(ctx context.Context, healthy []int, msgs []*jsonrpcMessage) ([]*jsonrpcMessage, error) {
+	if len(healthy) < p.Quorum {
+		return nil, errors.Errorf("only %v providers are available, fewer than the quorum of %v", len(healthy), p.Quorum)
+	}
+	var wg sync.WaitGroup
+	answers := make([][]*jsonrpcMessage, len(healthy))
+	errs := make([]error, len(healthy))
+	for j, i := range healthy {
+		wg.Add(1)
+		go func(j, i int) {
+			defer wg.Done()
+			answers[j], errs[j] = p.post(ctx, i, msgs)
+		}(j, i)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	var failed []string
+	var answered []map[string]*jsonrpcMessage
+	for j, i := range healthy {
+		if errs[j] != nil {
+			p.mu.Lock()
+			p.markDown(i, errs[j])
+			p.mu.Unlock()
+			failed = append(failed, fmt.Sprintf("%v: %v", p.URLs[i], errs[j]))
+			continue
+		}
+		byID := make(map[string]*jsonrpcMessage, len(answers[j]))
+		for _, r := range answers[j] {
+			byID[string(r.ID)] = r
+		}
+		answered = append(answered, byID)
+	}
+	if len(answered) < p.Quorum {
+		return nil, errors.Errorf("only %v providers answered, fewer than the quorum of %v (%v)", len(answered), p.Quorum, strings.Join(failed, "; "))
+	}
+
+	resps := make([]*jsonrpcMessage, len(msgs))
+	for k, msg := range msgs {
+		counts := map[string]int{}
+		var agreed *jsonrpcMessage
+		for _, byID := range answered {
+			r, ok := byID[string(msg.ID)]
+			if !ok {
+				continue
+			}
+			key := compact(r.Result) + "|" + compact(r.Error)
+			counts[key]++
+			if counts[key] >= p.Quorum && agreed == nil {
+				agreed = r
+			}
+		}
+		if agreed == nil {
+			log.Printf("chain: providers disagree on %v %s: %v", msg.Method, msg.Params, len(counts))
+			agreed = errorResult(msg, -32000, fmt.Sprintf("no quorum: fewer than %v providers agree on the result", p.Quorum))
+		}
+		resps[k] = agreed
+	}
+	return resps, nil
+}
+
+// filter answers a request to create, use, or remove a filter, on the provider that has the
+// filter.
+func (p *Pool) filter(ctx context.Context, healthy []int, msg *jsonrpcMessage) (*jsonrpcMessage, error) {
+	if newFilterMethods[msg.Method] {
+		resps, i, err := p.failover(ctx, healthy, []*jsonrpcMessage{msg})
+		if err != nil {
+			return nil, err
+		}
+		if len(resps) != 1 {
+			return errorResult(msg, -32603, "no response from the provider"), nil
+		}
+		var id string
+		if json.Unmarshal(resps[0].Result, &id) == nil && id != "" {
+			p.mu.Lock()
+			p.filters[id] = i
+			p.mu.Unlock()
+		}
+		return resps[0], nil
+	}
+
+	var params []string
+	if err := json.Unmarshal(msg.Params, &params); err != nil || len(params) != 1 {
+		return errorResult(msg, -32602, "bad filter id"), nil
+	}
+	p.mu.Lock()
+	i, ok := p.filters[params[0]]
+	if msg.Method == "eth_uninstallFilter" {
+		delete(p.filters, params[0])
+	}
+	p.mu.Unlock()
+	if !ok {
+		return errorResult(msg, -32000, "filter not found"), nil
+	}
+	resps, err := p.post(ctx, i, []*jsonrpcMessage{msg})
+	if err != nil {
+		p.mu.Lock()
+		p.markDown(i, err)
+		p.mu.Unlock()
+		return errorResult(msg, -32000, "filter not found: its provider failed: "+err.Error()), nil
+	}
+	if len(resps) != 1 {
+		return errorResult(msg, -32603, "no response from the provider"), nil
+	}
+	return resps[0], nil
+}
+
+// pinBlock replaces a "latest" block parameter of msg, given or left out, with pin, and
+// reports whether msg then reads a fixed block.
+func pinBlock(msg *jsonrpcMessage, pin uint64) (bool, error) {
+	pos, ok := blockParams[msg.Method]
+	if !ok {
+		return false, nil
+	}
+	var params []json.RawMessage
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return false, errors.Errorf("bad params for %v", msg.Method)
+		}
+	}
+	pinned, err := json.Marshal(hexutil.Uint64(pin))
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case len(params) < pos:
+		return false, errors.Errorf("too few params for %v", msg.Method)
+	case len(params) == pos:
+		params = append(params, pinned)
+	default:
+		var tag string
+		if json.Unmarshal(params[pos], &tag) != nil {
+			// A block hash object, as EIP-1898 allows.
+			return true, nil
+		}
+		switch tag {
+		case "latest":
+			params[pos] = pinned
+		case "pending":
+			return false, nil
+		}
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return false, err
+	}
+	msg.Params = data
+	return true, nil
+}
+
+func result(msg *jsonrpcMessage, v interface{}) *jsonrpcMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errorResult(msg, -32603, err.Error())
+	}
+	return &jsonrpcMessage{Version: "2.0", ID: msg.ID, Result: data}
+}
+
+func errorResult(msg *jsonrpcMessage, code int, message string) *jsonrpcMessage {
+	data, _ := json.Marshal(map[string]interface{}{"code": code, "message": message})
+	return &jsonrpcMessage{Version: "2.0", ID: msg.ID, Error: data}
+}
+
+func compact(data json.RawMessage) string {
+	var buf bytes.Buffer
+	if json.Compact(&buf, data) != nil {
+		return string(data)
+	}
+	return buf.String()
+}
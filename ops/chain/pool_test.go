@@ -0,0 +1,118 @@
+package chain
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// FakeProvider serves eth_blockNumber, and eth_call of owner(), answering with its own head
+// and owner.
+type FakeProvider struct {
+	head  uint64
+	owner common.Address
+
+	mu     sync.Mutex
+	blocks []string
+}
+
+func (f *FakeProvider) BlockNumber() hexutil.Uint64 {
+	return hexutil.Uint64(f.head)
+}
+
+func (f *FakeProvider) Call(ctx context.Context, args CallArgs, block string) (hexutil.Bytes, error) {
+	f.mu.Lock()
+	f.blocks = append(f.blocks, block)
+	f.mu.Unlock()
+	return common.LeftPadBytes(f.owner.Bytes(), 32), nil
+}
+
+func serve(t *testing.T, f *FakeProvider) *httptest.Server {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", f))
+	return httptest.NewServer(server)
+}
+
+func TestPool(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(viewsABI))
+	require.NoError(t, err)
+	owner := common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	liar := common.HexToAddress("0x0000000000000000000000000000000000000bad")
+	reserve := common.HexToAddress("0x196f4727526eA7FB1e17b2071B3d8eAA38486988")
+	data, err := parsed.Pack("owner")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	blockNumber := func(client *Client) uint64 {
+		var head hexutil.Uint64
+		require.NoError(t, client.RPC.CallContext(ctx, &head, "eth_blockNumber"))
+		return uint64(head)
+	}
+	readOwner := func(client *Client) (common.Address, error) {
+		out, err := client.CallContract(ctx, ethereum.CallMsg{To: &reserve, Data: data}, nil)
+		return common.BytesToAddress(out), err
+	}
+	dial := func(quorum int, providers ...*FakeProvider) (*Client, []*httptest.Server) {
+		var urls []string
+		var servers []*httptest.Server
+		for _, f := range providers {
+			s := serve(t, f)
+			servers = append(servers, s)
+			urls = append(urls, s.URL)
+		}
+		client, err := (&Pool{URLs: urls, Quorum: quorum, MaxLag: 5, HeadInterval: time.Nanosecond}).Dial()
+		require.NoError(t, err)
+		return client, servers
+	}
+
+	// Two honest providers outvote a lying one, at the block all of them have.
+	a := &FakeProvider{head: 100, owner: owner}
+	b := &FakeProvider{head: 99, owner: owner}
+	c := &FakeProvider{head: 100, owner: liar}
+	client, servers := dial(2, a, b, c)
+	assert.Equal(t, uint64(99), blockNumber(client))
+	got, err := readOwner(client)
+	require.NoError(t, err)
+	assert.Equal(t, owner, got)
+	for _, f := range []*FakeProvider{a, b, c} {
+		assert.Equal(t, []string{"0x63"}, f.blocks)
+	}
+
+	// Without the second honest one, there is no quorum.
+	servers[1].Close()
+	_, err = readOwner(client)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no quorum")
+	servers[0].Close()
+	servers[2].Close()
+
+	// Without a quorum, reads fail over from a provider that is down, or lagging.
+	down := &FakeProvider{head: 100, owner: liar}
+	lagging := &FakeProvider{head: 90, owner: liar}
+	good := &FakeProvider{head: 100, owner: owner}
+	client, servers = dial(1, down, lagging, good)
+	defer servers[1].Close()
+	defer servers[2].Close()
+	servers[0].Close()
+	got, err = readOwner(client)
+	require.NoError(t, err)
+	assert.Equal(t, owner, got)
+	assert.Empty(t, lagging.blocks)
+	assert.Equal(t, uint64(100), blockNumber(client))
+
+	_, err = (&Pool{URLs: []string{"ws://localhost:8546"}}).Dial()
+	assert.Error(t, err)
+	_, err = (&Pool{URLs: []string{"http://a", "http://b"}, Quorum: 3}).Dial()
+	assert.Error(t, err)
+}
@@ -0,0 +1,265 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/audit"
+	"github.com/reserve-protocol/rsv-beta/ops/signer"
+)
+
+// Backend is what a Transactor needs from an Ethereum node.
+type Backend interface {
+	bind.ContractBackend
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// Call is a transaction to be sent: usually a contract method invocation, but possibly a
+// contract creation (when Create is set) or a call with precomputed calldata (when Data is set).
+type Call struct {
+	Contract *Contract
+	Method   string
+	Args     []interface{}
+	Value    *big.Int
+
+	// Create is the contract to deploy, with Args as its constructor arguments. Contract is nil.
+	Create *Artifact
+
+	// Data, if set, is sent as-is instead of packing Method and Args.
+	Data []byte
+}
+
+// String renders the call for humans, e.g. `Reserve@0x1234...abcd.mint(0x5678...ef01, 100)`.
+func (c Call) String() string {
+	switch {
+	case c.Create != nil:
+		return fmt.Sprintf("new %v%v", c.Create.Name, FormatArgs(c.Args))
+	case c.Data != nil && c.Method == "":
+		return fmt.Sprintf("%v <calldata 0x%x>", c.Contract, c.Data)
+	}
+	return fmt.Sprintf("%v.%v%v", c.Contract, c.Method, FormatArgs(c.Args))
+}
+
+// data is the transaction's calldata, or init code for a contract creation.
+func (c Call) data() ([]byte, error) {
+	switch {
+	case c.Data != nil:
+		return c.Data, nil
+	case c.Create != nil:
+		return c.Create.InitCode(c.Args...)
+	}
+	data, err := c.Contract.ABI.Pack(c.Method, c.Args...)
+	return data, errors.Wrapf(err, "packing %v.%v", c.Contract.Name, c.Method)
+}
+
+// to is the transaction's recipient, or nil for a contract creation.
+func (c Call) to() *common.Address {
+	if c.Contract == nil {
+		return nil
+	}
+	return &c.Contract.Address
+}
+
+// PendingTx is a fully-specified, not-yet-signed transaction, as it is shown to Preflight checks.
+type PendingTx struct {
+	Call
+	From     common.Address
+	Nonce    uint64
+	Gas      uint64
+	GasPrice *big.Int
+	Data     []byte
+}
+
+// Transaction returns the unsigned transaction.
+func (p *PendingTx) Transaction() *types.Transaction {
+	value := p.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	if p.Contract == nil {
+		return types.NewContractCreation(p.Nonce, value, p.Gas, p.GasPrice, p.Data)
+	}
+	return types.NewTransaction(p.Nonce, p.Contract.Address, value, p.Gas, p.GasPrice, p.Data)
+}
+
+// A Preflight check runs on every transaction before it is signed. Returning an error aborts
+// the transaction.
+type Preflight func(ctx context.Context, tx *PendingTx) error
+
+// Transactor builds, checks, signs, and sends transactions from a single account. Every
+// transaction it sends is recorded in Audit, if Audit is set.
+type Transactor struct {
+	Backend Backend
+	Signer  signer.Signer
+	ChainID *big.Int
+
+	Preflight []Preflight
+
+	// GasPricePercent, if set, scales the node's suggested gas price, as 200 to double it, for
+	// transactions that must be mined at once.
+	GasPricePercent uint64
+
+	Audit   *audit.Log
+	Network string
+	Command string
+}
+
+// From is the account that the Transactor sends from.
+func (t *Transactor) From() common.Address {
+	return t.Signer.Address()
+}
+
+// Prepare packs call and fills in nonce, gas, and gas price, without signing or sending.
+func (t *Transactor) Prepare(ctx context.Context, call Call) (*PendingTx, error) {
+	data, err := call.data()
+	if err != nil {
+		return nil, err
+	}
+	from := t.From()
+	nonce, err := t.Backend.PendingNonceAt(ctx, from)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading account nonce")
+	}
+	gasPrice, err := t.Backend.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "suggesting gas price")
+	}
+	if t.GasPricePercent != 0 {
+		gasPrice.Mul(gasPrice, new(big.Int).SetUint64(t.GasPricePercent))
+		gasPrice.Div(gasPrice, big.NewInt(100))
+	}
+	gas, err := t.Backend.EstimateGas(ctx, ethereum.CallMsg{
+		From:  from,
+		To:    call.to(),
+		Value: call.Value,
+		Data:  data,
+	})
+	if err != nil {
+		// Gas estimation fails when the call would revert, so this is usually the first
+		// sign that the transaction is not going to work.
+		return nil, errors.Wrapf(err, "estimating gas for %v", call)
+	}
+	return &PendingTx{
+		Call:     call,
+		From:     from,
+		Nonce:    nonce,
+		Gas:      gas,
+		GasPrice: gasPrice,
+		Data:     data,
+	}, nil
+}
+
+// Send prepares, checks, signs, and submits call, returning the submitted transaction.
+func (t *Transactor) Send(ctx context.Context, call Call) (*types.Transaction, error) {
+	pending, err := t.Prepare(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+	for _, check := range t.Preflight {
+		if err := check(ctx, pending); err != nil {
+			return nil, errors.Wrapf(err, "preflight check for %v", call)
+		}
+	}
+	tx, err := signer.SignTx(ctx, t.Signer, pending.Transaction(), t.ChainID)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Backend.SendTransaction(ctx, tx); err != nil {
+		return nil, errors.Wrapf(err, "sending %v", call)
+	}
+	if err := t.record(call, tx.Hash(), audit.StatusSubmitted, nil); err != nil {
+		return tx, err
+	}
+	return tx, nil
+}
+
+// SendAndWait sends call and waits for it to be mined. A reverted transaction is an error.
+func (t *Transactor) SendAndWait(ctx context.Context, call Call) (*types.Receipt, error) {
+	tx, err := t.Send(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+	receipt, err := bind.WaitMined(ctx, t.Backend, tx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "waiting for %v", tx.Hash().Hex())
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		err = errors.Errorf("transaction %v (%v) reverted", tx.Hash().Hex(), call)
+		if auditErr := t.record(call, tx.Hash(), audit.StatusFailed, err); auditErr != nil {
+			return receipt, auditErr
+		}
+		return receipt, err
+	}
+	return receipt, t.record(call, tx.Hash(), audit.StatusConfirmed, nil)
+}
+
+func (t *Transactor) record(call Call, hash common.Hash, status string, err error) error {
+	if t.Audit == nil {
+		return nil
+	}
+	e := audit.Entry{
+		Network:  t.Network,
+		ChainID:  t.ChainID.Uint64(),
+		Command:  t.Command,
+		Operator: t.From().Hex(),
+		Method:   call.Method,
+		Args:     formatArgList(call.Args),
+		TxHash:   hash.Hex(),
+		Status:   status,
+	}
+	if call.Contract != nil {
+		e.Contract, e.Address = call.Contract.Name, call.Contract.Address.Hex()
+	} else {
+		e.Contract, e.Method = call.Create.Name, "constructor"
+	}
+	if call.Data != nil && call.Method == "" {
+		e.Args = []string{fmt.Sprintf("0x%x", call.Data)}
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return errors.Wrap(t.Audit.Append(e), "recording to audit log")
+}
+
+// FormatArgs renders contract call arguments as a parenthesized list.
+func FormatArgs(args []interface{}) string {
+	s := "("
+	for i, arg := range formatArgList(args) {
+		if i > 0 {
+			s += ", "
+		}
+		s += arg
+	}
+	return s + ")"
+}
+
+func formatArgList(args []interface{}) []string {
+	result := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case common.Address:
+			result[i] = v.Hex()
+		case []common.Address:
+			hexes := make([]string, len(v))
+			for j, a := range v {
+				hexes[j] = a.Hex()
+			}
+			result[i] = "[" + strings.Join(hexes, " ") + "]"
+		case []byte:
+			result[i] = fmt.Sprintf("0x%x", v)
+		case [32]byte:
+			result[i] = fmt.Sprintf("0x%x", v)
+		default:
+			result[i] = fmt.Sprint(v)
+		}
+	}
+	return result
+}
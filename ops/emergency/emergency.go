@@ -0,0 +1,303 @@
+// Package emergency implements the incident-response runbook: pause the token, freeze the
+// addresses involved, snapshot the state of the deployment to disk, and tell everyone who needs
+// to know. Each step is attempted even if an earlier one fails, since in an incident a partial
+// response beats none; the Report says what happened.
+package emergency
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+// Chain is the runbook's view of the deployment.
+type Chain interface {
+	Paused(ctx context.Context) (bool, error)
+	Pause(ctx context.Context) (common.Hash, error)
+
+	// CanFreeze reports whether the deployed Reserve supports freezing addresses at all.
+	CanFreeze() bool
+	Frozen(ctx context.Context, addr common.Address) (bool, error)
+	Freeze(ctx context.Context, addr common.Address) (common.Hash, error)
+
+	// Snapshot reads the current state of the deployment, including the given accounts.
+	Snapshot(ctx context.Context, accounts []common.Address) (*Snapshot, error)
+}
+
+// Snapshot is the state of a deployment at one block.
+type Snapshot struct {
+	Time    time.Time `json:"time"`
+	Network string    `json:"network"`
+	ChainID uint64    `json:"chainId"`
+	Block   uint64    `json:"block"`
+
+	Contracts map[string]common.Address `json:"contracts"`
+
+	// Reserve holds the Reserve's roles and settings, by view method name.
+	Reserve map[string]string `json:"reserve"`
+
+	Accounts []Account `json:"accounts"`
+
+	// Report is what the runbook did before the snapshot was taken.
+	Report *Report `json:"report,omitempty"`
+}
+
+// Account is the state of one address involved in the incident.
+type Account struct {
+	Address common.Address `json:"address"`
+	Balance string         `json:"balance"`
+	Frozen  *bool          `json:"frozen,omitempty"`
+}
+
+// Step outcomes.
+const (
+	OutcomeDone    = "done"
+	OutcomeSkipped = "skipped"
+	OutcomeFailed  = "failed"
+)
+
+// Step is one action of the runbook.
+type Step struct {
+	Action  string `json:"action"`
+	Outcome string `json:"outcome"`
+	TxHash  string `json:"txHash,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+func (s Step) String() string {
+	line := fmt.Sprintf("%v: %v", s.Action, s.Outcome)
+	if s.TxHash != "" {
+		line += " in " + s.TxHash
+	}
+	if s.Detail != "" {
+		line += " (" + s.Detail + ")"
+	}
+	return line
+}
+
+// Report is the record of one run of the runbook.
+type Report struct {
+	Operator common.Address `json:"operator"`
+	Reason   string         `json:"reason"`
+	Steps    []Step         `json:"steps"`
+
+	// SnapshotFile is where the snapshot was written, if it was.
+	SnapshotFile string `json:"snapshotFile,omitempty"`
+}
+
+// Failed reports whether any step failed.
+func (r *Report) Failed() bool {
+	for _, s := range r.Steps {
+		if s.Outcome == OutcomeFailed {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) add(action, outcome string, hash common.Hash, detail string) {
+	s := Step{Action: action, Outcome: outcome, Detail: detail}
+	if hash != (common.Hash{}) {
+		s.TxHash = hash.Hex()
+	}
+	r.Steps = append(r.Steps, s)
+}
+
+func (r *Report) fail(action string, err error) {
+	r.add(action, OutcomeFailed, common.Hash{}, err.Error())
+}
+
+// Runbook carries out the emergency response against one deployment.
+type Runbook struct {
+	Chain       Chain
+	Notifier    *Notifier
+	SnapshotDir string
+	Network     string
+
+	// Log, if set, receives each step as it completes.
+	Log io.Writer
+
+	now func() time.Time
+}
+
+// Run pauses the Reserve, freezes accounts, writes a snapshot, and sends notifications. It
+// returns the report, and an error if any step failed.
+func (b *Runbook) Run(ctx context.Context, operator common.Address, reason string, accounts []common.Address) (*Report, error) {
+	r := &Report{Operator: operator, Reason: reason}
+	b.pause(ctx, r)
+	b.freeze(ctx, r, accounts)
+	b.snapshot(ctx, r, accounts)
+	b.notify(ctx, r)
+	if r.Failed() {
+		return r, errors.New("emergency response incomplete; see the report")
+	}
+	return r, nil
+}
+
+func (b *Runbook) logStep(r *Report) {
+	if b.Log != nil {
+		fmt.Fprintln(b.Log, r.Steps[len(r.Steps)-1])
+	}
+}
+
+func (b *Runbook) pause(ctx context.Context, r *Report) {
+	defer b.logStep(r)
+	paused, err := b.Chain.Paused(ctx)
+	if err != nil {
+		r.fail("pause", err)
+		return
+	}
+	if paused {
+		r.add("pause", OutcomeSkipped, common.Hash{}, "already paused")
+		return
+	}
+	hash, err := b.Chain.Pause(ctx)
+	if err != nil {
+		r.fail("pause", err)
+		return
+	}
+	r.add("pause", OutcomeDone, hash, "")
+}
+
+func (b *Runbook) freeze(ctx context.Context, r *Report, accounts []common.Address) {
+	if len(accounts) > 0 && !b.Chain.CanFreeze() {
+		r.fail("freeze", errors.Errorf("the deployed Reserve cannot freeze addresses; %v left unfrozen", len(accounts)))
+		b.logStep(r)
+		return
+	}
+	for _, addr := range accounts {
+		action := "freeze " + addr.Hex()
+		frozen, err := b.Chain.Frozen(ctx, addr)
+		switch {
+		case err != nil:
+			r.fail(action, err)
+		case frozen:
+			r.add(action, OutcomeSkipped, common.Hash{}, "already frozen")
+		default:
+			hash, err := b.Chain.Freeze(ctx, addr)
+			if err != nil {
+				r.fail(action, err)
+			} else {
+				r.add(action, OutcomeDone, hash, "")
+			}
+		}
+		b.logStep(r)
+	}
+}
+
+func (b *Runbook) snapshot(ctx context.Context, r *Report, accounts []common.Address) {
+	defer b.logStep(r)
+	snap, err := b.Chain.Snapshot(ctx, accounts)
+	if err != nil {
+		r.fail("snapshot", err)
+		return
+	}
+	now := time.Now
+	if b.now != nil {
+		now = b.now
+	}
+	snap.Time = now().UTC()
+	snap.Report = r
+	path := filepath.Join(b.SnapshotDir, fmt.Sprintf("emergency-%v-%v.json", b.Network, snap.Time.Format("20060102T150405Z")))
+	if err := writeJSON(path, snap); err != nil {
+		r.fail("snapshot", err)
+		return
+	}
+	r.SnapshotFile = path
+	r.add("snapshot", OutcomeDone, common.Hash{}, fmt.Sprintf("block %v, %v", snap.Block, path))
+}
+
+func (b *Runbook) notify(ctx context.Context, r *Report) {
+	defer b.logStep(r)
+	if b.Notifier == nil || len(b.Notifier.Webhooks) == 0 {
+		r.add("notify", OutcomeSkipped, common.Hash{}, "no webhooks configured")
+		return
+	}
+	if err := b.Notifier.Notify(ctx, b.message(r)); err != nil {
+		r.fail("notify", err)
+		return
+	}
+	r.add("notify", OutcomeDone, common.Hash{}, fmt.Sprintf("%v webhooks", len(b.Notifier.Webhooks)))
+}
+
+// message is the notification text for r.
+func (b *Runbook) message(r *Report) string {
+	lines := []string{
+		fmt.Sprintf("RSV emergency response on %v by %v", b.Network, r.Operator.Hex()),
+		"Reason: " + r.Reason,
+	}
+	for _, s := range r.Steps {
+		lines = append(lines, "- "+s.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// writeJSON writes v to path, failing rather than replacing an existing file.
+func writeJSON(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding snapshot")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, "creating snapshot")
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return errors.Wrap(err, "writing snapshot")
+	}
+	return errors.Wrap(f.Close(), "writing snapshot")
+}
+
+// ReadAddresses reads a list of addresses, one per line. Blank lines and anything after a "#"
+// are ignored. Mixed-case addresses must be correctly EIP-55 checksummed.
+func ReadAddresses(r io.Reader) ([]common.Address, error) {
+	var result []common.Address
+	seen := make(map[common.Address]bool)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		addr, err := ParseAddress(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %v", n)
+		}
+		if !seen[addr] {
+			seen[addr] = true
+			result = append(result, addr)
+		}
+	}
+	return result, errors.Wrap(scanner.Err(), "reading addresses")
+}
+
+// ParseAddress parses a hex address, checking its EIP-55 checksum if it has mixed case.
+func ParseAddress(s string) (common.Address, error) {
+	if !common.IsHexAddress(s) {
+		return common.Address{}, errors.Errorf("%q is not an address", s)
+	}
+	addr := common.HexToAddress(s)
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	mixed := strings.ToLower(digits) != digits && strings.ToUpper(digits) != digits
+	if mixed && addr.Hex()[2:] != digits {
+		return common.Address{}, errors.Errorf("%q has a bad EIP-55 checksum", s)
+	}
+	if addr == (common.Address{}) {
+		return common.Address{}, errors.New("the zero address is not allowed")
+	}
+	return addr, nil
+}
@@ -0,0 +1,223 @@
+package emergency
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	alice = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	bob   = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+)
+
+type fakeChain struct {
+	paused    bool
+	canFreeze bool
+	frozen    map[common.Address]bool
+	pauseErr  error
+	sent      []string
+}
+
+func newFakeChain() *fakeChain {
+	return &fakeChain{canFreeze: true, frozen: make(map[common.Address]bool)}
+}
+
+func (c *fakeChain) Paused(ctx context.Context) (bool, error) { return c.paused, nil }
+
+func (c *fakeChain) Pause(ctx context.Context) (common.Hash, error) {
+	if c.pauseErr != nil {
+		return common.Hash{}, c.pauseErr
+	}
+	c.paused = true
+	c.sent = append(c.sent, "pause")
+	return common.HexToHash("0x01"), nil
+}
+
+func (c *fakeChain) CanFreeze() bool { return c.canFreeze }
+
+func (c *fakeChain) Frozen(ctx context.Context, addr common.Address) (bool, error) {
+	return c.frozen[addr], nil
+}
+
+func (c *fakeChain) Freeze(ctx context.Context, addr common.Address) (common.Hash, error) {
+	c.frozen[addr] = true
+	c.sent = append(c.sent, "freeze "+addr.Hex())
+	return common.HexToHash("0x02"), nil
+}
+
+func (c *fakeChain) Snapshot(ctx context.Context, accounts []common.Address) (*Snapshot, error) {
+	snap := &Snapshot{Network: "test", ChainID: 5, Block: 100, Reserve: map[string]string{"paused": "true"}}
+	for _, addr := range accounts {
+		frozen := c.frozen[addr]
+		snap.Accounts = append(snap.Accounts, Account{Address: addr, Balance: "7", Frozen: &frozen})
+	}
+	return snap, nil
+}
+
+// webhook records the messages posted to it, and answers with status.
+type webhook struct {
+	server   *httptest.Server
+	messages []string
+}
+
+func newWebhook(t *testing.T, env string, status int) *webhook {
+	w := &webhook{}
+	w.server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var body struct{ Text string }
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.messages = append(w.messages, body.Text)
+		rw.WriteHeader(status)
+	}))
+	os.Setenv(env, w.server.URL)
+	return w
+}
+
+func newRunbook(t *testing.T, c Chain, hooks ...Webhook) (*Runbook, func()) {
+	dir, err := ioutil.TempDir("", "emergency")
+	require.NoError(t, err)
+	n, err := NewNotifier(hooks)
+	require.NoError(t, err)
+	b := &Runbook{
+		Chain:       c,
+		Notifier:    n,
+		SnapshotDir: dir,
+		Network:     "test",
+		now:         func() time.Time { return time.Date(2020, 7, 20, 12, 0, 0, 0, time.UTC) },
+	}
+	return b, func() { os.RemoveAll(dir) }
+}
+
+func TestRun(t *testing.T) {
+	hook := newWebhook(t, "EMERGENCY_TEST_HOOK", http.StatusOK)
+	defer hook.server.Close()
+	c := newFakeChain()
+	c.frozen[bob] = true
+	b, cleanup := newRunbook(t, c, Webhook{Name: "ops", URLEnv: "EMERGENCY_TEST_HOOK"})
+	defer cleanup()
+
+	report, err := b.Run(context.Background(), alice, "key compromise", []common.Address{alice, bob})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pause", "freeze " + alice.Hex()}, c.sent)
+
+	var outcomes []string
+	for _, s := range report.Steps {
+		outcomes = append(outcomes, s.Action+" "+s.Outcome)
+	}
+	assert.Equal(t, []string{
+		"pause done",
+		"freeze " + alice.Hex() + " done",
+		"freeze " + bob.Hex() + " skipped",
+		"snapshot done",
+		"notify done",
+	}, outcomes)
+
+	// The snapshot records both the state and what we did to reach it.
+	b2, err := ioutil.ReadFile(report.SnapshotFile)
+	require.NoError(t, err)
+	var snap Snapshot
+	require.NoError(t, json.Unmarshal(b2, &snap))
+	assert.Equal(t, uint64(100), snap.Block)
+	assert.Equal(t, time.Date(2020, 7, 20, 12, 0, 0, 0, time.UTC), snap.Time)
+	require.Len(t, snap.Accounts, 2)
+	assert.True(t, *snap.Accounts[0].Frozen)
+	require.NotNil(t, snap.Report)
+	assert.Len(t, snap.Report.Steps, 3)
+	assert.True(t, strings.HasSuffix(report.SnapshotFile, "emergency-test-20200720T120000Z.json"))
+
+	require.Len(t, hook.messages, 1)
+	assert.Contains(t, hook.messages[0], "Reason: key compromise")
+	assert.Contains(t, hook.messages[0], "freeze "+alice.Hex()+": done")
+
+	// A second run in the same second must not overwrite the first snapshot.
+	_, err = b.Run(context.Background(), alice, "again", nil)
+	assert.Error(t, err)
+}
+
+func TestRunContinuesPastFailures(t *testing.T) {
+	hook := newWebhook(t, "EMERGENCY_TEST_HOOK", http.StatusOK)
+	defer hook.server.Close()
+	c := newFakeChain()
+	c.canFreeze = false
+	c.pauseErr = errors.New("signer is not the pauser")
+	b, cleanup := newRunbook(t, c, Webhook{Name: "ops", URLEnv: "EMERGENCY_TEST_HOOK"})
+	defer cleanup()
+
+	report, err := b.Run(context.Background(), alice, "exploit", []common.Address{bob})
+	assert.Error(t, err)
+	assert.True(t, report.Failed())
+	require.Len(t, report.Steps, 4)
+	assert.Equal(t, OutcomeFailed, report.Steps[0].Outcome)
+	assert.Equal(t, OutcomeFailed, report.Steps[1].Outcome)
+	assert.Contains(t, report.Steps[1].Detail, "cannot freeze")
+	assert.Equal(t, OutcomeDone, report.Steps[2].Outcome)
+	assert.Equal(t, OutcomeDone, report.Steps[3].Outcome)
+
+	// The notification says what went wrong.
+	require.Len(t, hook.messages, 1)
+	assert.Contains(t, hook.messages[0], "signer is not the pauser")
+}
+
+func TestRunAlreadyPaused(t *testing.T) {
+	c := newFakeChain()
+	c.paused = true
+	b, cleanup := newRunbook(t, c)
+	defer cleanup()
+
+	report, err := b.Run(context.Background(), alice, "drill", nil)
+	require.NoError(t, err)
+	assert.Empty(t, c.sent)
+	assert.Equal(t, OutcomeSkipped, report.Steps[0].Outcome)
+	assert.Equal(t, "notify", report.Steps[2].Action)
+	assert.Equal(t, OutcomeSkipped, report.Steps[2].Outcome)
+}
+
+func TestNotifyReportsFailedWebhooks(t *testing.T) {
+	good := newWebhook(t, "EMERGENCY_TEST_GOOD", http.StatusOK)
+	defer good.server.Close()
+	bad := newWebhook(t, "EMERGENCY_TEST_BAD", http.StatusForbidden)
+	defer bad.server.Close()
+
+	n, err := NewNotifier([]Webhook{{Name: "bad", URLEnv: "EMERGENCY_TEST_BAD"}, {Name: "good", URLEnv: "EMERGENCY_TEST_GOOD"}})
+	require.NoError(t, err)
+	err = n.Notify(context.Background(), "hello")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad: HTTP 403")
+	assert.NotContains(t, err.Error(), "good")
+	assert.Equal(t, []string{"hello"}, good.messages)
+
+	_, err = NewNotifier([]Webhook{{Name: "unset", URLEnv: "EMERGENCY_TEST_UNSET"}})
+	assert.Error(t, err)
+}
+
+func TestReadAddresses(t *testing.T) {
+	addrs, err := ReadAddresses(strings.NewReader(`
+# Attacker addresses
+0x4B481872f31bab47C6780D5488c84D309b1B8Bb6
+0xaedcfcdd80573c2a312d15d6bb9d921a01e4fb0f  # lowercase is fine
+0x4B481872f31bab47C6780D5488c84D309b1B8Bb6
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []common.Address{
+		common.HexToAddress("0x4B481872f31bab47C6780D5488c84D309b1B8Bb6"),
+		common.HexToAddress("0xAeDCFcdD80573c2a312d15d6Bb9d921a01E4FB0f"),
+	}, addrs)
+
+	_, err = ReadAddresses(strings.NewReader("0x4b481872f31bab47C6780D5488c84D309b1B8Bb6\n"))
+	assert.Error(t, err, "bad checksum")
+	_, err = ReadAddresses(strings.NewReader("0x1234\n"))
+	assert.Error(t, err, "too short")
+	_, err = ReadAddresses(strings.NewReader("0x0000000000000000000000000000000000000000\n"))
+	assert.Error(t, err, "zero address")
+}
@@ -0,0 +1,80 @@
+package emergency
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Webhook is an HTTP endpoint to notify, such as a Slack incoming webhook. Webhook URLs are
+// credentials, so the config names an environment variable holding the URL instead.
+type Webhook struct {
+	Name   string `json:"name"`
+	URLEnv string `json:"urlEnv"`
+}
+
+// Notifier posts messages to webhooks.
+type Notifier struct {
+	Webhooks []Webhook
+	HTTP     *http.Client
+}
+
+// NewNotifier returns a Notifier for hooks, checking that each one's URL is set.
+func NewNotifier(hooks []Webhook) (*Notifier, error) {
+	for _, h := range hooks {
+		if h.URLEnv == "" {
+			return nil, errors.Errorf("config: webhook %q has no urlEnv", h.Name)
+		}
+		if os.Getenv(h.URLEnv) == "" {
+			return nil, errors.Errorf("environment variable %v (webhook %q) is empty", h.URLEnv, h.Name)
+		}
+	}
+	return &Notifier{Webhooks: hooks, HTTP: &http.Client{Timeout: 15 * time.Second}}, nil
+}
+
+// Notify posts text to every webhook, as a JSON object {"text": text}, which Slack and most
+// chat services accept. It tries them all, and returns an error naming those that failed.
+func (n *Notifier) Notify(ctx context.Context, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return errors.Wrap(err, "encoding notification")
+	}
+	var failed []string
+	for _, h := range n.Webhooks {
+		if err := n.post(ctx, os.Getenv(h.URLEnv), body); err != nil {
+			failed = append(failed, h.Name+": "+err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("notifying webhooks: %v", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (n *Notifier) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "building request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.HTTP.Do(req)
+	if err != nil {
+		// The error includes the URL, which is a secret.
+		return errors.New("request failed")
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("HTTP %v", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package emergency
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// The Reserve's view methods that a snapshot records, by result type. Methods that the deployed
+// Reserve's ABI lacks are left out.
+var (
+	addressViews = []string{
+		"owner", "nominatedOwner", "minter", "pauser", "guardian", "freezer", "wiper", "feeRecipient",
+		"trustedTxFee", "trustedRelayer", "getEternalStorageAddress",
+	}
+	intViews  = []string{"totalSupply", "maxSupply", "mintCap"}
+	boolViews = []string{"paused"}
+)
+
+// onchain is the Chain of a live deployment.
+type onchain struct {
+	s       *session.Session
+	tx      *chain.Transactor
+	reserve *chain.Contract
+}
+
+// NewChain returns the Chain for the deployment that s is connected to.
+func NewChain(s *session.Session) (Chain, error) {
+	tx, err := s.RequireTransactor()
+	if err != nil {
+		return nil, err
+	}
+	reserve, err := s.Contract("Reserve")
+	if err != nil {
+		return nil, err
+	}
+	return &onchain{s: s, tx: tx, reserve: reserve}, nil
+}
+
+func (c *onchain) hasMethod(name string) bool {
+	_, ok := c.reserve.ABI.Methods[name]
+	return ok
+}
+
+func (c *onchain) Paused(ctx context.Context) (bool, error) {
+	return c.reserve.CallBool(ctx, "paused")
+}
+
+func (c *onchain) Pause(ctx context.Context) (common.Hash, error) {
+	return c.send(ctx, "pause")
+}
+
+func (c *onchain) CanFreeze() bool {
+	return c.hasMethod("freeze") && c.hasMethod("frozen")
+}
+
+func (c *onchain) Frozen(ctx context.Context, addr common.Address) (bool, error) {
+	return c.reserve.CallBool(ctx, "frozen", addr)
+}
+
+func (c *onchain) Freeze(ctx context.Context, addr common.Address) (common.Hash, error) {
+	return c.send(ctx, "freeze", addr)
+}
+
+func (c *onchain) send(ctx context.Context, method string, args ...interface{}) (common.Hash, error) {
+	receipt, err := c.tx.SendAndWait(ctx, chain.Call{Contract: c.reserve, Method: method, Args: args})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return receipt.TxHash, nil
+}
+
+func (c *onchain) Snapshot(ctx context.Context, accounts []common.Address) (*Snapshot, error) {
+	header, err := c.s.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading latest block")
+	}
+	snap := &Snapshot{
+		Network:   c.s.Config.Network,
+		ChainID:   c.s.ChainID.Uint64(),
+		Block:     header.Number.Uint64(),
+		Contracts: c.s.Manifest.Contracts,
+		Reserve:   make(map[string]string),
+	}
+	// Read everything at the same block, so the snapshot is consistent.
+	reserve := c.reserve.At(header.Number)
+	for _, method := range addressViews {
+		if c.hasMethod(method) {
+			v, err := reserve.CallAddress(ctx, method)
+			if err != nil {
+				return nil, err
+			}
+			snap.Reserve[method] = v.Hex()
+		}
+	}
+	for _, method := range intViews {
+		if c.hasMethod(method) {
+			v, err := reserve.CallBig(ctx, method)
+			if err != nil {
+				return nil, err
+			}
+			snap.Reserve[method] = v.String()
+		}
+	}
+	for _, method := range boolViews {
+		if c.hasMethod(method) {
+			v, err := reserve.CallBool(ctx, method)
+			if err != nil {
+				return nil, err
+			}
+			snap.Reserve[method] = fmt.Sprint(v)
+		}
+	}
+	for _, addr := range accounts {
+		balance, err := reserve.CallBig(ctx, "balanceOf", addr)
+		if err != nil {
+			return nil, err
+		}
+		account := Account{Address: addr, Balance: balance.String()}
+		if c.CanFreeze() {
+			frozen, err := reserve.CallBool(ctx, "frozen", addr)
+			if err != nil {
+				return nil, err
+			}
+			account.Frozen = &frozen
+		}
+		snap.Accounts = append(snap.Accounts, account)
+	}
+	return snap, nil
+}
@@ -0,0 +1,272 @@
+// Package ens resolves and manages ENS names for deployed contracts, so that operators can refer
+// to contracts as, say, rsv.reserveprotocol.eth rather than by address.
+//
+// Names are hashed as described in EIP-137, after lowercasing; full UTS-46 normalization is not
+// implemented, so names should be plain lowercase ASCII.
+package ens
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/manifest"
+)
+
+// RegistryAddress is where the ENS registry lives on mainnet and the public testnets.
+var RegistryAddress = common.HexToAddress("0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e")
+
+// RegistryABI is the part of the ENS registry interface that the tools use.
+const RegistryABI = `[
+	{"type":"function","name":"owner","constant":true,"inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"resolver","constant":true,"inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"setSubnodeOwner","constant":false,"inputs":[
+		{"name":"node","type":"bytes32"},{"name":"label","type":"bytes32"},{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"bytes32"}]},
+	{"type":"function","name":"setResolver","constant":false,"inputs":[
+		{"name":"node","type":"bytes32"},{"name":"resolver","type":"address"}],"outputs":[]}
+]`
+
+// ResolverABI is the part of the public resolver interface that the tools use.
+const ResolverABI = `[
+	{"type":"function","name":"addr","constant":true,"inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"name","constant":true,"inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"setAddr","constant":false,"inputs":[
+		{"name":"node","type":"bytes32"},{"name":"addr","type":"address"}],"outputs":[]}
+]`
+
+func artifact(name, abiJSON string) *chain.Artifact {
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		panic(err)
+	}
+	return &chain.Artifact{Name: name, ABI: parsed, ABIJSON: abiJSON}
+}
+
+var (
+	registryArtifact = artifact("ENSRegistry", RegistryABI)
+	resolverArtifact = artifact("ENSResolver", ResolverABI)
+)
+
+// NameHash is the ENS node of name.
+func NameHash(name string) common.Hash {
+	var node common.Hash
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(strings.ToLower(name), ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = crypto.Keccak256Hash(node[:], LabelHash(labels[i]).Bytes())
+	}
+	return node
+}
+
+// LabelHash is the hash of a single label of a name.
+func LabelHash(label string) common.Hash {
+	return crypto.Keccak256Hash([]byte(label))
+}
+
+// ReverseName is the name under addr.reverse that holds addr's reverse record.
+func ReverseName(addr common.Address) string {
+	return hex.EncodeToString(addr.Bytes()) + ".addr.reverse"
+}
+
+// ErrNoResolver means that a name has no resolver set, and so resolves to nothing.
+var ErrNoResolver = errors.New("name has no resolver")
+
+// ENS is a connection to an ENS registry.
+type ENS struct {
+	backend  chain.Backend
+	registry *chain.Contract
+}
+
+// New returns a connection to the registry at registry.
+func New(backend chain.Backend, registry common.Address) *ENS {
+	return &ENS{backend: backend, registry: registryArtifact.Bind(registry, backend)}
+}
+
+// Open returns a connection to the ENS registry at RegistryAddress, or nil if the chain that
+// backend is connected to has no registry there.
+func Open(ctx context.Context, backend chain.Backend) (*ENS, error) {
+	code, err := backend.CodeAt(ctx, RegistryAddress, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "looking for the ENS registry")
+	}
+	if len(code) == 0 {
+		return nil, nil
+	}
+	return New(backend, RegistryAddress), nil
+}
+
+func (e *ENS) resolver(ctx context.Context, node common.Hash) (*chain.Contract, error) {
+	addr, err := e.registry.CallAddress(ctx, "resolver", node)
+	if err != nil {
+		return nil, err
+	}
+	if addr == (common.Address{}) {
+		return nil, ErrNoResolver
+	}
+	return resolverArtifact.Bind(addr, e.backend), nil
+}
+
+// Resolve returns the address that name points to.
+func (e *ENS) Resolve(ctx context.Context, name string) (common.Address, error) {
+	resolver, err := e.resolver(ctx, NameHash(name))
+	if err != nil {
+		return common.Address{}, errors.Wrapf(err, "resolving %v", name)
+	}
+	return resolver.CallAddress(ctx, "addr", NameHash(name))
+}
+
+// Reverse returns the name that addr's reverse record claims, or "" if there is none. A claim
+// counts only if the name resolves back to addr, since anyone can claim any name in their own
+// reverse record.
+func (e *ENS) Reverse(ctx context.Context, addr common.Address) (string, error) {
+	node := NameHash(ReverseName(addr))
+	resolver, err := e.resolver(ctx, node)
+	if errors.Cause(err) == ErrNoResolver {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	name, err := resolver.CallString(ctx, "name", node)
+	if err != nil || name == "" {
+		return "", err
+	}
+	forward, err := e.Resolve(ctx, name)
+	if errors.Cause(err) == ErrNoResolver {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if forward != addr {
+		return "", nil
+	}
+	return name, nil
+}
+
+// Set points name at addr, sending whichever transactions are needed from tx's account:
+// creating name under its parent (which must then be ours), giving it the parent's resolver,
+// and setting its address. It does nothing if name already resolves to addr.
+func (e *ENS) Set(ctx context.Context, tx *chain.Transactor, name string, addr common.Address) error {
+	name = strings.ToLower(name)
+	dot := strings.Index(name, ".")
+	if dot <= 0 {
+		return errors.Errorf("%q has no parent domain", name)
+	}
+	label, parent := name[:dot], name[dot+1:]
+	node, parentNode := NameHash(name), NameHash(parent)
+
+	owner, err := e.registry.CallAddress(ctx, "owner", node)
+	if err != nil {
+		return err
+	}
+	switch owner {
+	case tx.From():
+	case common.Address{}:
+		parentOwner, err := e.registry.CallAddress(ctx, "owner", parentNode)
+		if err != nil {
+			return err
+		}
+		if parentOwner != tx.From() {
+			return errors.Errorf("%v is owned by %v, so we (%v) can't create %v", parent, parentOwner.Hex(), tx.From().Hex(), name)
+		}
+		if _, err := tx.SendAndWait(ctx, chain.Call{
+			Contract: e.registry,
+			Method:   "setSubnodeOwner",
+			Args:     []interface{}{parentNode, LabelHash(label), tx.From()},
+		}); err != nil {
+			return err
+		}
+	default:
+		return errors.Errorf("%v is owned by %v, not by us (%v)", name, owner.Hex(), tx.From().Hex())
+	}
+
+	resolver, err := e.resolver(ctx, node)
+	if errors.Cause(err) == ErrNoResolver {
+		if resolver, err = e.resolver(ctx, parentNode); err != nil {
+			return errors.Wrapf(err, "finding a resolver for %v from %v", name, parent)
+		}
+		if _, err := tx.SendAndWait(ctx, chain.Call{
+			Contract: e.registry,
+			Method:   "setResolver",
+			Args:     []interface{}{node, resolver.Address},
+		}); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	current, err := resolver.CallAddress(ctx, "addr", node)
+	if err != nil {
+		return err
+	}
+	if current == addr {
+		return nil
+	}
+	_, err = tx.SendAndWait(ctx, chain.Call{Contract: resolver, Method: "setAddr", Args: []interface{}{node, addr}})
+	return err
+}
+
+// Namer labels addresses for display with their names: the manifest names of contracts and
+// the ENS names recorded for them, and verified ENS reverse records. It caches lookups.
+type Namer struct {
+	ens   *ENS
+	names map[common.Address][]string
+	cache map[common.Address]string
+}
+
+// NewNamer returns a Namer that knows the contracts in m. ens may be nil, to skip ENS lookups.
+func NewNamer(e *ENS, m *manifest.Manifest) *Namer {
+	n := &Namer{ens: e, names: make(map[common.Address][]string), cache: make(map[common.Address]string)}
+	for name, addr := range m.Contracts {
+		n.names[addr] = append(n.names[addr], name)
+		if ensName, ok := m.Names[name]; ok {
+			n.names[addr] = append(n.names[addr], ensName)
+		}
+	}
+	for _, names := range n.names {
+		sort.Strings(names)
+	}
+	return n
+}
+
+// Label returns addr in hex, followed by whatever names it is known by, e.g.
+// "0x1234...abcd (Reserve, rsv.reserveprotocol.eth)". Failed ENS lookups are treated as no name:
+// labels are for reading, and must never stop a command.
+func (n *Namer) Label(ctx context.Context, addr common.Address) string {
+	names := append([]string(nil), n.names[addr]...)
+	if n.ens != nil {
+		name, ok := n.cache[addr]
+		if !ok {
+			name, _ = n.ens.Reverse(ctx, addr)
+			n.cache[addr] = name
+		}
+		if name != "" && !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return addr.Hex()
+	}
+	return fmt.Sprintf("%v (%v)", addr.Hex(), strings.Join(names, ", "))
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package ens
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/reserve-protocol/rsv-beta/ops/manifest"
+)
+
+func TestNameHash(t *testing.T) {
+	// From EIP-137.
+	assert.Equal(t, common.Hash{}, NameHash(""))
+	assert.Equal(t, common.HexToHash("0x93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae"), NameHash("eth"))
+	assert.Equal(t, common.HexToHash("0xde9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f"), NameHash("foo.eth"))
+	assert.Equal(t, NameHash("foo.eth"), NameHash("Foo.ETH"))
+}
+
+func TestReverseName(t *testing.T) {
+	addr := common.HexToAddress("0x4B481872f31bab47C6780D5488c84D309b1B8Bb6")
+	assert.Equal(t, "4b481872f31bab47c6780d5488c84d309b1b8bb6.addr.reverse", ReverseName(addr))
+}
+
+func TestNamerWithoutENS(t *testing.T) {
+	reserve := common.HexToAddress("0x196f4727526eA7FB1e17b2071B3d8eAA38486988")
+	other := common.HexToAddress("0x4B481872f31bab47C6780D5488c84D309b1B8Bb6")
+	n := NewNamer(nil, &manifest.Manifest{
+		Contracts: map[string]common.Address{"Reserve": reserve},
+		Names:     map[string]string{"Reserve": "rsv.reserveprotocol.eth"},
+	})
+	assert.Equal(t, reserve.Hex()+" (Reserve, rsv.reserveprotocol.eth)", n.Label(context.Background(), reserve))
+	assert.Equal(t, other.Hex(), n.Label(context.Background(), other))
+}
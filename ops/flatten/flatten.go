@@ -0,0 +1,201 @@
+// Package flatten bundles a contract's source with everything that it imports, for verifying the
+// contract on Etherscan and for handing to auditors, as a single flattened file or as solc's
+// standard-JSON input.
+//
+// Imports are resolved as solc resolves them when `make json` compiles from the repo root: a
+// path starting with "./" or "../" relative to the importing file, and any other relative to the
+// root. Files come in a fixed order, each after everything it imports and otherwise in the order
+// of the import statements, so the output depends on nothing but the sources. The standard-JSON
+// input names each file by the same path as the build does, so compiling it with the settings in
+// build.lock.json reproduces the code, metadata hash and all.
+package flatten
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// importStatement matches an import statement, over however many lines it takes.
+	importStatement = regexp.MustCompile(`(?m)^[ \t]*import\b[^;]*;[ \t]*\n?`)
+
+	// importPath matches the quoted path in an import statement.
+	importPath = regexp.MustCompile(`"([^"]+)"|'([^']+)'`)
+
+	// importAlias matches an import statement that renames what it imports.
+	importAlias = regexp.MustCompile(`\bas\b`)
+
+	// pragmaStatement matches a pragma, such as that of the solidity version.
+	pragmaStatement = regexp.MustCompile(`(?m)^[ \t]*pragma\s+([^;]+);[ \t]*\n?`)
+)
+
+// file is a source file, read.
+type file struct {
+	path    string // relative to the repo, with forward slashes
+	src     []byte
+	imports []string
+}
+
+// Sources lists source, a path relative to repoDir, and every file that it imports, directly
+// or not, each once and after everything it imports.
+func Sources(repoDir, source string) ([]string, error) {
+	files, err := resolve(repoDir, source)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	return paths, nil
+}
+
+// resolve reads source and everything it imports, in the order of Sources.
+func resolve(repoDir, source string) ([]*file, error) {
+	var files []*file
+	seen := make(map[string]bool)
+	var visit func(p, from string) error
+	visit = func(p, from string) error {
+		if seen[p] {
+			return nil
+		}
+		seen[p] = true
+		src, err := ioutil.ReadFile(filepath.Join(repoDir, filepath.FromSlash(p)))
+		if err != nil {
+			if from != "" {
+				return errors.Wrapf(err, "%v imports %v", from, p)
+			}
+			return errors.Wrap(err, "reading the contract's source")
+		}
+		f := &file{path: p, src: src}
+		for _, stmt := range importStatement.FindAll(src, -1) {
+			m := importPath.FindSubmatch(stmt)
+			if m == nil {
+				return errors.Errorf("%v: no path in %q", p, bytes.TrimSpace(stmt))
+			}
+			imported := string(m[1]) + string(m[2])
+			if strings.HasPrefix(imported, "./") || strings.HasPrefix(imported, "../") {
+				imported = path.Join(path.Dir(p), imported)
+			}
+			imported = path.Clean(imported)
+			if strings.HasPrefix(imported, "../") {
+				return errors.Errorf("%v imports %v, outside the repo", p, imported)
+			}
+			f.imports = append(f.imports, imported)
+			if err := visit(imported, p); err != nil {
+				return err
+			}
+		}
+		files = append(files, f)
+		return nil
+	}
+	if err := visit(path.Clean(filepath.ToSlash(source)), ""); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// Flatten returns source and everything it imports as a single file: each file's pragmas once,
+// at the top, and then each file without its pragmas and imports, in the order of Sources. It
+// fails if the files need different solidity versions, or if an import renames what it imports,
+// as one file can't.
+func Flatten(repoDir, source string) ([]byte, error) {
+	files, err := resolve(repoDir, source)
+	if err != nil {
+		return nil, err
+	}
+	var pragmas []string
+	havePragma := make(map[string]bool)
+	version := ""
+	var body bytes.Buffer
+	for _, f := range files {
+		for _, stmt := range importStatement.FindAll(f.src, -1) {
+			if importAlias.Match(importPath.ReplaceAll(stmt, nil)) {
+				return nil, errors.Errorf("%v: can't flatten %q, which renames what it imports", f.path, bytes.TrimSpace(stmt))
+			}
+		}
+		for _, m := range pragmaStatement.FindAllSubmatch(f.src, -1) {
+			pragma := strings.Join(strings.Fields(string(m[1])), " ")
+			if strings.HasPrefix(pragma, "solidity ") {
+				if version != "" && pragma != version {
+					return nil, errors.Errorf("%v needs %v, but other files need %v", f.path, pragma, version)
+				}
+				version = pragma
+			}
+			if !havePragma[pragma] {
+				havePragma[pragma] = true
+				pragmas = append(pragmas, pragma)
+			}
+		}
+		src := importStatement.ReplaceAll(f.src, nil)
+		src = pragmaStatement.ReplaceAll(src, nil)
+		fmt.Fprintf(&body, "\n// File: %v\n\n%s\n", f.path, bytes.TrimSpace(src))
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// %v, flattened: the files it imports, and then its own.\n\n", files[len(files)-1].path)
+	for _, pragma := range pragmas {
+		fmt.Fprintf(&out, "pragma %v;\n", pragma)
+	}
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+// Settings are the compiler settings of a standard-JSON input.
+type Settings struct {
+	Optimizer  int    // optimizer runs, or 0 for none
+	EVMVersion string // the compiler's default if empty
+}
+
+// StandardJSON returns solc's standard-JSON input for compiling source: the contents of it and
+// of everything it imports, with s. It asks for the outputs that verification compares.
+func StandardJSON(repoDir, source string, s Settings) ([]byte, error) {
+	files, err := resolve(repoDir, source)
+	if err != nil {
+		return nil, err
+	}
+	type content struct {
+		Content string `json:"content"`
+	}
+	input := struct {
+		Language string             `json:"language"`
+		Sources  map[string]content `json:"sources"`
+		Settings struct {
+			Optimizer struct {
+				Enabled bool `json:"enabled"`
+				Runs    int  `json:"runs"`
+			} `json:"optimizer"`
+			EVMVersion      string                         `json:"evmVersion,omitempty"`
+			OutputSelection map[string]map[string][]string `json:"outputSelection"`
+		} `json:"settings"`
+	}{
+		Language: "Solidity",
+		Sources:  make(map[string]content),
+	}
+	for _, f := range files {
+		input.Sources[f.path] = content{Content: string(f.src)}
+	}
+	input.Settings.Optimizer.Enabled = s.Optimizer > 0
+	input.Settings.Optimizer.Runs = s.Optimizer
+	if s.Optimizer == 0 {
+		// solc's default, which it records in the metadata even with the optimizer off.
+		input.Settings.Optimizer.Runs = 200
+	}
+	input.Settings.EVMVersion = s.EVMVersion
+	input.Settings.OutputSelection = map[string]map[string][]string{
+		"*": {"*": {"abi", "evm.bytecode.object", "evm.deployedBytecode.object", "metadata"}},
+	}
+	b, err := json.MarshalIndent(input, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling the standard-JSON input")
+	}
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,158 @@
+package flatten
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeRepo writes files, by path, to a new directory, and returns it.
+func writeRepo(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "flatten")
+	require.NoError(t, err)
+	for p, src := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, p)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, p), []byte(src), 0644))
+	}
+	return dir
+}
+
+var repo = map[string]string{
+	"contracts/Vault.sol": `pragma solidity 0.5.7;
+
+import "./zeppelin/math/SafeMath.sol";
+import "./ownership/Ownable.sol";
+
+contract Vault is Ownable {}
+`,
+	"contracts/ownership/Ownable.sol": `pragma solidity 0.5.7;
+import '../zeppelin/GSN/Context.sol';
+
+contract Ownable is Context {}
+`,
+	"contracts/zeppelin/GSN/Context.sol": "pragma solidity 0.5.7;\n\ncontract Context {}\n",
+	"contracts/zeppelin/math/SafeMath.sol": `pragma  solidity   0.5.7;
+import {Context}
+    from "../GSN/Context.sol";
+
+library SafeMath {}
+`,
+}
+
+func TestSources(t *testing.T) {
+	dir := writeRepo(t, repo)
+	defer os.RemoveAll(dir)
+
+	sources, err := Sources(dir, "contracts/Vault.sol")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"contracts/zeppelin/GSN/Context.sol",
+		"contracts/zeppelin/math/SafeMath.sol",
+		"contracts/ownership/Ownable.sol",
+		"contracts/Vault.sol",
+	}, sources)
+
+	_, err = Sources(dir, "contracts/Missing.sol")
+	assert.Error(t, err)
+	missing := writeRepo(t, map[string]string{"contracts/A.sol": `import "./B.sol";`})
+	defer os.RemoveAll(missing)
+	_, err = Sources(missing, "contracts/A.sol")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "contracts/A.sol imports contracts/B.sol")
+}
+
+func TestFlatten(t *testing.T) {
+	dir := writeRepo(t, repo)
+	defer os.RemoveAll(dir)
+
+	flat, err := Flatten(dir, "contracts/Vault.sol")
+	require.NoError(t, err)
+	assert.Equal(t, `// contracts/Vault.sol, flattened: the files it imports, and then its own.
+
+pragma solidity 0.5.7;
+
+// File: contracts/zeppelin/GSN/Context.sol
+
+contract Context {}
+
+// File: contracts/zeppelin/math/SafeMath.sol
+
+library SafeMath {}
+
+// File: contracts/ownership/Ownable.sol
+
+contract Ownable is Context {}
+
+// File: contracts/Vault.sol
+
+contract Vault is Ownable {}
+`, string(flat))
+
+	for name, src := range map[string]string{
+		"versions": "pragma solidity 0.5.8;\ncontract Context {}\n",
+		"alias":    "pragma solidity 0.5.7;\nimport * as math from \"../math/SafeMath.sol\";\ncontract Context {}\n",
+	} {
+		bad := writeRepo(t, repo)
+		defer os.RemoveAll(bad)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(bad, "contracts/zeppelin/GSN/Context.sol"), []byte(src), 0644))
+		_, err := Flatten(bad, "contracts/Vault.sol")
+		assert.Error(t, err, name)
+	}
+}
+
+func TestStandardJSON(t *testing.T) {
+	dir := writeRepo(t, repo)
+	defer os.RemoveAll(dir)
+
+	b, err := StandardJSON(dir, "contracts/Vault.sol", Settings{Optimizer: 100000, EVMVersion: "petersburg"})
+	require.NoError(t, err)
+	var input struct {
+		Language string
+		Sources  map[string]struct{ Content string }
+		Settings struct {
+			Optimizer struct {
+				Enabled bool
+				Runs    int
+			}
+			EVMVersion string `json:"evmVersion"`
+		}
+	}
+	require.NoError(t, json.Unmarshal(b, &input))
+	assert.Equal(t, "Solidity", input.Language)
+	assert.Len(t, input.Sources, 4)
+	assert.Equal(t, repo["contracts/ownership/Ownable.sol"], input.Sources["contracts/ownership/Ownable.sol"].Content)
+	assert.True(t, input.Settings.Optimizer.Enabled)
+	assert.Equal(t, 100000, input.Settings.Optimizer.Runs)
+	assert.Equal(t, "petersburg", input.Settings.EVMVersion)
+
+	again, err := StandardJSON(dir, "contracts/Vault.sol", Settings{Optimizer: 100000, EVMVersion: "petersburg"})
+	require.NoError(t, err)
+	assert.Equal(t, b, again)
+}
+
+// TestRepoContracts flattens every contract in the repo, checking that each comes out with one
+// solidity pragma, no imports, and every file it needs once.
+func TestRepoContracts(t *testing.T) {
+	paths, err := filepath.Glob("../../contracts/*.sol")
+	require.NoError(t, err)
+	more, err := filepath.Glob("../../contracts/rsv/*.sol")
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+	for _, p := range append(paths, more...) {
+		rel, err := filepath.Rel("../..", p)
+		require.NoError(t, err)
+		flat, err := Flatten("../..", rel)
+		require.NoError(t, err, rel)
+		assert.Equal(t, 1, strings.Count(string(flat), "pragma solidity "), rel)
+		assert.NotContains(t, string(flat), "\nimport ", rel)
+		sources, err := Sources("../..", rel)
+		require.NoError(t, err, rel)
+		assert.Equal(t, len(sources), strings.Count(string(flat), "\n// File: "), rel)
+	}
+}
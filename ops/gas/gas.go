@@ -0,0 +1,104 @@
+// Package gas reads, writes, and compares the gas golden file: the gas that each of the gas
+// benchmarks in tests/ used, committed so that a change's effect on gas shows in its diff.
+//
+// The benchmarks run on the simulated backend, whose gas is the same from run to run, so any
+// change in the file is a change in the contracts, their compiler settings, or the benchmarks.
+package gas
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Report maps each benchmark to the gas that its transaction used. Benchmarks are named for the
+// function they call, as "<Contract>.<function>", with the case in parentheses where a function
+// has more than one: "Reserve.transfer (new holder)". Deploying a contract is "<Contract>
+// deployment", which rsvsize reads.
+type Report map[string]uint64
+
+// Parse parses a report written by Marshal.
+func Parse(b []byte) (Report, error) {
+	var r Report
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, errors.Wrap(err, "parsing the gas report")
+	}
+	if r == nil {
+		return nil, errors.New("the gas report is empty")
+	}
+	return r, nil
+}
+
+// Marshal returns the report as JSON with one benchmark to a line, sorted by name, so that the
+// committed file diffs well.
+func (r Report) Marshal() ([]byte, error) {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling the gas report")
+	}
+	return append(b, '\n'), nil
+}
+
+// ReadFile reads a report written by WriteFile.
+func ReadFile(path string) (Report, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the gas report")
+	}
+	r, err := Parse(b)
+	return r, errors.Wrap(err, path)
+}
+
+// WriteFile writes the report to path.
+func WriteFile(path string, r Report) error {
+	b, err := r.Marshal()
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(ioutil.WriteFile(path, b, 0644), "writing the gas report")
+}
+
+// Delta is how one benchmark's gas changed. A transaction uses at least 21000 gas, so an Old or
+// New of 0 means that the benchmark was added or removed.
+type Delta struct {
+	Name string `json:"name"`
+	Old  uint64 `json:"old"`
+	New  uint64 `json:"new"`
+}
+
+// Change is the gas that the benchmark uses now, less what it used before.
+func (d Delta) Change() int64 {
+	return int64(d.New) - int64(d.Old)
+}
+
+func (d Delta) String() string {
+	switch {
+	case d.Old == 0:
+		return fmt.Sprintf("%v: added, %v", d.Name, d.New)
+	case d.New == 0:
+		return fmt.Sprintf("%v: removed, was %v", d.Name, d.Old)
+	}
+	return fmt.Sprintf("%v: %v -> %v (%+d, %+.2f%%)",
+		d.Name, d.Old, d.New, d.Change(), float64(d.Change())*100/float64(d.Old))
+}
+
+// Diff lists the benchmarks whose gas differs between old and new, those in only one of the two
+// included, sorted by name.
+func Diff(old, new Report) []Delta {
+	var deltas []Delta
+	for name, gas := range old {
+		if new[name] != gas {
+			deltas = append(deltas, Delta{Name: name, Old: gas, New: new[name]})
+		}
+	}
+	for name, gas := range new {
+		if _, ok := old[name]; !ok {
+			deltas = append(deltas, Delta{Name: name, New: gas})
+		}
+	}
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].Name < deltas[j].Name })
+	return deltas
+}
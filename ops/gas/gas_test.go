@@ -0,0 +1,62 @@
+package gas
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	old := Report{
+		"Manager.issue":                52000,
+		"Reserve.transfer":             35000,
+		"Reserve.transfer (with hook)": 41000,
+		"Reserve.approve":              46000,
+	}
+	new := Report{
+		"Manager.issue":                51000,
+		"Reserve.transfer":             35000,
+		"Reserve.transfer (with hook)": 41210,
+		"Reserve.burnFrom":             30000,
+	}
+	deltas := Diff(old, new)
+	assert.Equal(t, []Delta{
+		{Name: "Manager.issue", Old: 52000, New: 51000},
+		{Name: "Reserve.approve", Old: 46000},
+		{Name: "Reserve.burnFrom", New: 30000},
+		{Name: "Reserve.transfer (with hook)", Old: 41000, New: 41210},
+	}, deltas)
+
+	assert.Equal(t, "Manager.issue: 52000 -> 51000 (-1000, -1.92%)", deltas[0].String())
+	assert.Equal(t, "Reserve.approve: removed, was 46000", deltas[1].String())
+	assert.Equal(t, "Reserve.burnFrom: added, 30000", deltas[2].String())
+	assert.Equal(t, "Reserve.transfer (with hook): 41000 -> 41210 (+210, +0.51%)", deltas[3].String())
+
+	assert.Empty(t, Diff(old, old))
+}
+
+func TestReportFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gas")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gas.json")
+
+	r := Report{"Reserve.transfer": 35000, "Manager.issue": 52000}
+	require.NoError(t, WriteFile(path, r))
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"Manager.issue\": 52000,\n  \"Reserve.transfer\": 35000\n}\n", string(b))
+
+	read, err := ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, r, read)
+
+	_, err = Parse([]byte("null"))
+	assert.Error(t, err)
+	_, err = ReadFile(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,301 @@
+// Package guardian watches an RSV deployment's peg and backing every block, and pauses the
+// Reserve from a dedicated guardian key, the Reserve's pauser, as soon as a threshold is
+// crossed: the Vault covers too little of the supply, or an oracle prices a basket token too far
+// from a dollar.
+//
+// A guardian acts at most once per breach: after it has paused, the Reserve stays in the hands
+// of its operators, who may unpause it while the breach lasts. It acts again only after every
+// threshold has held for a reading. In dry-run mode, it checks that the pause would succeed
+// without sending it, and in alert-only mode it only says what it would do.
+package guardian
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+)
+
+// Modes a Guardian runs in.
+const (
+	// Pause sends the pause transaction.
+	Pause = "pause"
+
+	// DryRun checks that the pause transaction would succeed, without sending it.
+	DryRun = "dry-run"
+
+	// AlertOnly only alerts.
+	AlertOnly = "alert"
+)
+
+// Feed is a Chainlink price feed of a basket token in dollars, such as USDC/USD.
+type Feed struct {
+	Name    string         `json:"name"`
+	Address common.Address `json:"address"`
+}
+
+// Thresholds are what a Guardian pauses on. A zero threshold is off.
+type Thresholds struct {
+	// MinCollateralization is the least fraction of the supply that the Vault must cover in
+	// every basket token, as 1 for fully.
+	MinCollateralization float64 `json:"minCollateralization,omitempty"`
+
+	// MaxDeviation is how far from 1 each feed's price may be, as 0.03 for 3%.
+	MaxDeviation float64 `json:"maxDeviation,omitempty"`
+
+	// MaxPriceAgeSeconds is how long a feed may go without an update. A stale price is alerted
+	// about, but is no grounds to pause on.
+	MaxPriceAgeSeconds int `json:"maxPriceAgeSeconds,omitempty"`
+}
+
+// Price is a feed's latest answer.
+type Price struct {
+	Feed      string
+	Value     float64
+	UpdatedAt time.Time
+}
+
+// Reading is what a Guardian checks: the deployment's state, and the feeds' prices, at one
+// block.
+type Reading struct {
+	State  *metrics.State
+	Time   time.Time // of the block
+	Prices []Price
+}
+
+// Breach is a threshold crossed.
+type Breach struct {
+	// Check names the threshold, such as "backing" or "peg USDC/USD". It identifies the alert
+	// from breach to resolution.
+	Check string
+
+	Summary string
+
+	// Pause is whether the breach is grounds to pause.
+	Pause bool
+}
+
+// Check returns the thresholds that r crosses.
+func (t Thresholds) Check(r *Reading) []Breach {
+	var breaches []Breach
+	if t.MinCollateralization > 0 {
+		var short []string
+		for _, token := range r.State.Tokens {
+			if ratio := r.State.Ratio(token); ratio < t.MinCollateralization {
+				name := token.Symbol
+				if name == "" {
+					name = token.Address.Hex()
+				}
+				short = append(short, fmt.Sprintf("%v at %.2f%%", name, 100*ratio))
+			}
+		}
+		if len(short) > 0 {
+			breaches = append(breaches, Breach{
+				Check:   "backing",
+				Summary: fmt.Sprintf("the Vault covers less than %.2f%% of the supply in %v", 100*t.MinCollateralization, strings.Join(short, ", ")),
+				Pause:   true,
+			})
+		}
+	}
+	for _, p := range r.Prices {
+		if t.MaxDeviation > 0 && math.Abs(p.Value-1) > t.MaxDeviation {
+			breaches = append(breaches, Breach{
+				Check:   "peg " + p.Feed,
+				Summary: fmt.Sprintf("%v is at %v, more than %.2f%% off the dollar", p.Feed, p.Value, 100*t.MaxDeviation),
+				Pause:   true,
+			})
+		}
+		maxAge := time.Duration(t.MaxPriceAgeSeconds) * time.Second
+		if age := r.Time.Sub(p.UpdatedAt); maxAge > 0 && age > maxAge {
+			breaches = append(breaches, Breach{
+				Check:   "stale " + p.Feed,
+				Summary: fmt.Sprintf("%v has not been updated in %v", p.Feed, age.Round(time.Second)),
+			})
+		}
+	}
+	return breaches
+}
+
+// Pauser pauses the Reserve from the guardian key.
+type Pauser interface {
+	// Check fails if the pause transaction would revert.
+	Check(ctx context.Context) error
+
+	// Pause sends the pause transaction, without waiting for it to be mined.
+	Pause(ctx context.Context) (common.Hash, error)
+}
+
+// Guardian reads the deployment every Interval and, at each new block, checks it against
+// Thresholds, alerting when a threshold is crossed and when it holds again, and pausing the
+// Reserve once Consecutive readings in a row cross one that is grounds to pause.
+type Guardian struct {
+	Read       func(ctx context.Context) (*Reading, error)
+	Thresholds Thresholds
+
+	// Mode is Pause, DryRun, or AlertOnly.
+	Mode   string
+	Pauser Pauser
+
+	// Consecutive is how many readings in a row must cross a threshold before the Guardian
+	// acts (default 1), so that one odd oracle answer can be ridden out.
+	Consecutive int
+
+	// Post delivers a message, such as emergency.Notifier.Notify. Messages that could not be
+	// delivered are posted again at the next block.
+	Post func(ctx context.Context, text string) error
+
+	Network string
+
+	// Interval is how often to read; under the block time, so that no block is missed.
+	Interval time.Duration
+
+	// MaxReadFailures is how many reads in a row may fail before the Guardian alerts that it
+	// cannot see the chain; zero means 3.
+	MaxReadFailures int
+
+	block        uint64
+	breached     map[string]bool
+	streak       int
+	acted        bool
+	readFailures int
+	blind        bool
+}
+
+// Run checks every new block until ctx is done.
+func (g *Guardian) Run(ctx context.Context) error {
+	ticker := time.NewTicker(g.Interval)
+	defer ticker.Stop()
+	for {
+		if err := g.Step(ctx); err != nil {
+			log.Printf("guardian: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Step reads the deployment once and, if the block is new, checks it and acts.
+func (g *Guardian) Step(ctx context.Context) error {
+	if g.breached == nil {
+		g.breached = map[string]bool{}
+	}
+	r, err := g.Read(ctx)
+	if err != nil {
+		g.readFailures++
+		max := g.MaxReadFailures
+		if max == 0 {
+			max = 3
+		}
+		if g.readFailures >= max && !g.blind {
+			if g.post(ctx, fmt.Sprintf("RSV on %v: guardian cannot read the chain (%v failures in a row): %v", g.Network, g.readFailures, err)) {
+				g.blind = true
+			}
+		}
+		return errors.Wrap(err, "reading")
+	}
+	g.readFailures = 0
+	if g.blind && g.post(ctx, fmt.Sprintf("RSV on %v: guardian can read the chain again, at block %v", g.Network, r.State.Block)) {
+		g.blind = false
+	}
+	if r.State.Block <= g.block {
+		return nil
+	}
+	g.block = r.State.Block
+
+	breaches := g.Thresholds.Check(r)
+	current := map[string]bool{}
+	var grounds []string
+	for _, b := range breaches {
+		current[b.Check] = true
+		if b.Pause {
+			grounds = append(grounds, b.Summary)
+		}
+		if !g.breached[b.Check] {
+			log.Printf("guardian: %v: %v", b.Check, b.Summary)
+			if g.post(ctx, fmt.Sprintf("RSV on %v: guardian: %v crossed at block %v: %v", g.Network, b.Check, r.State.Block, b.Summary)) {
+				g.breached[b.Check] = true
+			}
+		}
+	}
+	var resolved []string
+	for check := range g.breached {
+		if !current[check] {
+			resolved = append(resolved, check)
+		}
+	}
+	sort.Strings(resolved)
+	for _, check := range resolved {
+		log.Printf("guardian: %v: resolved", check)
+		if g.post(ctx, fmt.Sprintf("RSV on %v: guardian: %v holds again at block %v", g.Network, check, r.State.Block)) {
+			delete(g.breached, check)
+		}
+	}
+
+	if len(grounds) == 0 {
+		g.streak, g.acted = 0, false
+		return nil
+	}
+	g.streak++
+	consecutive := g.Consecutive
+	if consecutive == 0 {
+		consecutive = 1
+	}
+	if g.acted || g.streak < consecutive {
+		return nil
+	}
+	if r.State.Paused {
+		g.acted = true
+		return nil
+	}
+	return g.act(ctx, r.State.Block, strings.Join(grounds, "; "))
+}
+
+// act pauses the Reserve, or says it would, for grounds.
+func (g *Guardian) act(ctx context.Context, block uint64, grounds string) error {
+	var text string
+	switch g.Mode {
+	case Pause:
+		hash, err := g.Pauser.Pause(ctx)
+		if err != nil {
+			// Try again at the next block.
+			g.post(ctx, fmt.Sprintf("RSV on %v: guardian FAILED to pause the Reserve at block %v, and will try again: %v (grounds: %v)", g.Network, block, err, grounds))
+			return errors.Wrap(err, "pausing")
+		}
+		text = fmt.Sprintf("RSV on %v: guardian PAUSED the Reserve at block %v (tx %v): %v", g.Network, block, hash.Hex(), grounds)
+	case DryRun:
+		outcome := "and the pause transaction would succeed"
+		if err := g.Pauser.Check(ctx); err != nil {
+			outcome = fmt.Sprintf("but the pause transaction would FAIL: %v", err)
+		}
+		text = fmt.Sprintf("RSV on %v: guardian (dry run) would pause the Reserve at block %v, %v: %v", g.Network, block, outcome, grounds)
+	default:
+		text = fmt.Sprintf("RSV on %v: guardian (alert only) would pause the Reserve at block %v: %v", g.Network, block, grounds)
+	}
+	log.Printf("guardian: %v", text)
+	g.acted = true
+	g.post(ctx, text)
+	return nil
+}
+
+// post delivers text, reporting whether it was delivered.
+func (g *Guardian) post(ctx context.Context, text string) bool {
+	if g.Post == nil {
+		return true
+	}
+	if err := g.Post(ctx, text); err != nil {
+		log.Printf("guardian: posting: %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,201 @@
+package guardian
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+)
+
+var start = time.Unix(1600000000, 0)
+
+// reading is a reading at block of 100 RSV backed by a Vault holding balance qTokens of a
+// 6-decimal token, of which 100e6 back it fully, and of one feed priced at price.
+func reading(block uint64, balance int64, price float64, paused bool) *Reading {
+	supply := new(big.Int).Mul(big.NewInt(100), big.NewInt(1e18))
+	return &Reading{
+		State: &metrics.State{
+			Block:       block,
+			Supply:      supply,
+			RSVDecimals: 18,
+			Paused:      paused,
+			Tokens: []metrics.Token{{
+				Symbol:   "USDC",
+				Decimals: 6,
+				Weight:   new(big.Int).Mul(big.NewInt(1e6), big.NewInt(1e18)),
+				Balance:  big.NewInt(balance),
+			}},
+		},
+		Time:   start.Add(time.Duration(block) * 12 * time.Second),
+		Prices: []Price{{Feed: "USDC/USD", Value: price, UpdatedAt: start}},
+	}
+}
+
+func TestThresholdsCheck(t *testing.T) {
+	th := Thresholds{MinCollateralization: 1, MaxDeviation: 0.03, MaxPriceAgeSeconds: 3600}
+
+	assert.Empty(t, th.Check(reading(1, 100e6, 0.99, false)))
+
+	breaches := th.Check(reading(1, 90e6, 0.95, false))
+	require.Len(t, breaches, 2)
+	assert.Equal(t, "backing", breaches[0].Check)
+	assert.Contains(t, breaches[0].Summary, "USDC at 90.00%")
+	assert.True(t, breaches[0].Pause)
+	assert.Equal(t, "peg USDC/USD", breaches[1].Check)
+	assert.True(t, breaches[1].Pause)
+
+	breaches = th.Check(reading(400, 100e6, 1, false))
+	require.Len(t, breaches, 1)
+	assert.Equal(t, "stale USDC/USD", breaches[0].Check)
+	assert.False(t, breaches[0].Pause)
+
+	assert.Empty(t, Thresholds{}.Check(reading(400, 0, 0, false)))
+}
+
+type pauser struct {
+	checks, pauses int
+	err            error
+}
+
+func (p *pauser) Check(ctx context.Context) error {
+	p.checks++
+	return p.err
+}
+
+func (p *pauser) Pause(ctx context.Context) (common.Hash, error) {
+	p.pauses++
+	return common.HexToHash("0x1"), p.err
+}
+
+// harness runs a Guardian over readings returned by next.
+type harness struct {
+	g     *Guardian
+	p     *pauser
+	next  *Reading
+	posts []string
+}
+
+func newHarness(mode string) *harness {
+	h := &harness{p: &pauser{}}
+	h.g = &Guardian{
+		Read: func(ctx context.Context) (*Reading, error) {
+			if h.next == nil {
+				return nil, errors.New("node down")
+			}
+			return h.next, nil
+		},
+		Thresholds: Thresholds{MinCollateralization: 1, MaxDeviation: 0.03},
+		Mode:       mode,
+		Pauser:     h.p,
+		Post: func(ctx context.Context, text string) error {
+			h.posts = append(h.posts, text)
+			return nil
+		},
+		Network: "testnet",
+	}
+	return h
+}
+
+func (h *harness) step(t *testing.T, r *Reading) {
+	h.next = r
+	h.posts = nil
+	h.g.Step(context.Background())
+}
+
+func TestGuardianPause(t *testing.T) {
+	h := newHarness(Pause)
+
+	h.step(t, reading(1, 100e6, 1, false))
+	assert.Empty(t, h.posts)
+
+	h.step(t, reading(2, 90e6, 1, false))
+	assert.Equal(t, 1, h.p.pauses)
+	require.Len(t, h.posts, 2)
+	assert.Contains(t, h.posts[0], "backing crossed at block 2")
+	assert.Contains(t, h.posts[1], "guardian PAUSED the Reserve at block 2")
+
+	// The same block again, and the breach lasting after the pause, do nothing more.
+	h.step(t, reading(2, 90e6, 1, false))
+	h.step(t, reading(3, 90e6, 1, true))
+	assert.Equal(t, 1, h.p.pauses)
+	assert.Empty(t, h.posts)
+
+	// Unpaused by the operators while the breach lasts, the Reserve is left alone.
+	h.step(t, reading(4, 90e6, 1, false))
+	assert.Equal(t, 1, h.p.pauses)
+
+	// Once the breach clears, the guardian is armed again.
+	h.step(t, reading(5, 100e6, 1, false))
+	require.Len(t, h.posts, 1)
+	assert.Contains(t, h.posts[0], "backing holds again at block 5")
+	h.step(t, reading(6, 100e6, 0.9, false))
+	assert.Equal(t, 2, h.p.pauses)
+}
+
+func TestGuardianPauseFails(t *testing.T) {
+	h := newHarness(Pause)
+	h.p.err = errors.New("out of gas")
+
+	h.step(t, reading(1, 90e6, 1, false))
+	require.Len(t, h.posts, 2)
+	assert.Contains(t, h.posts[1], "FAILED to pause")
+
+	// It tries again at the next block.
+	h.p.err = nil
+	h.step(t, reading(2, 90e6, 1, false))
+	assert.Equal(t, 2, h.p.pauses)
+	require.Len(t, h.posts, 1)
+	assert.Contains(t, h.posts[0], "PAUSED")
+}
+
+func TestGuardianModes(t *testing.T) {
+	h := newHarness(DryRun)
+	h.p.err = errors.New("not the pauser")
+	h.step(t, reading(1, 90e6, 1, false))
+	assert.Equal(t, 0, h.p.pauses)
+	assert.Equal(t, 1, h.p.checks)
+	require.Len(t, h.posts, 2)
+	assert.Contains(t, h.posts[1], "(dry run) would pause the Reserve at block 1, but the pause transaction would FAIL: not the pauser")
+
+	h = newHarness(AlertOnly)
+	h.step(t, reading(1, 90e6, 1, false))
+	assert.Equal(t, 0, h.p.pauses+h.p.checks)
+	require.Len(t, h.posts, 2)
+	assert.Contains(t, h.posts[1], "(alert only) would pause the Reserve at block 1")
+}
+
+func TestGuardianConsecutive(t *testing.T) {
+	h := newHarness(Pause)
+	h.g.Consecutive = 2
+
+	h.step(t, reading(1, 100e6, 0.5, false))
+	h.step(t, reading(2, 100e6, 1, false))
+	h.step(t, reading(3, 100e6, 0.5, false))
+	assert.Equal(t, 0, h.p.pauses)
+	h.step(t, reading(4, 100e6, 0.5, false))
+	assert.Equal(t, 1, h.p.pauses)
+}
+
+func TestGuardianReadFailures(t *testing.T) {
+	h := newHarness(Pause)
+	for i := 0; i < 2; i++ {
+		h.step(t, nil)
+		assert.Empty(t, h.posts)
+	}
+	h.step(t, nil)
+	require.Len(t, h.posts, 1)
+	assert.Contains(t, h.posts[0], "cannot read the chain (3 failures in a row)")
+	h.step(t, nil)
+	assert.Empty(t, h.posts)
+
+	h.step(t, reading(1, 100e6, 1, false))
+	require.Len(t, h.posts, 1)
+	assert.Contains(t, h.posts[0], "can read the chain again")
+}
@@ -0,0 +1,162 @@
+package guardian
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// aggregatorABI is the part of Chainlink's AggregatorV3Interface that the guardian reads.
+const aggregatorABI = `[
+	{"type":"function","name":"decimals","constant":true,"inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"latestRoundData","constant":true,"inputs":[],"outputs":[
+		{"name":"roundId","type":"uint80"},{"name":"answer","type":"int256"},{"name":"startedAt","type":"uint256"},
+		{"name":"updatedAt","type":"uint256"},{"name":"answeredInRound","type":"uint80"}]}
+]`
+
+var aggregatorArtifact = func() *chain.Artifact {
+	parsed, err := abi.JSON(strings.NewReader(aggregatorABI))
+	if err != nil {
+		panic(err)
+	}
+	return &chain.Artifact{Name: "Aggregator", ABI: parsed, ABIJSON: aggregatorABI}
+}()
+
+// roundData is what latestRoundData returns.
+type roundData struct {
+	RoundId         *big.Int
+	Answer          *big.Int
+	StartedAt       *big.Int
+	UpdatedAt       *big.Int
+	AnsweredInRound *big.Int
+}
+
+// Chain reads the deployment and its price feeds, and pauses the Reserve, through a session.
+type Chain struct {
+	client   *chain.Client
+	metrics  *metrics.Reader
+	reserve  *chain.Contract
+	feeds    []*chain.Contract
+	names    []string
+	decimals []uint8
+	tx       *chain.Transactor
+}
+
+// NewChain returns a Chain for the deployment that s is connected to, pausing from s's
+// Transactor if it has one.
+func NewChain(ctx context.Context, s *session.Session, feeds []Feed) (*Chain, error) {
+	reader, err := metrics.NewReader(s)
+	if err != nil {
+		return nil, err
+	}
+	reserve, err := s.Contract("Reserve")
+	if err != nil {
+		return nil, err
+	}
+	c := &Chain{client: s.Client, metrics: reader, reserve: reserve, tx: s.Transactor}
+	b := s.Client.NewBatch(nil)
+	c.decimals = make([]uint8, len(feeds))
+	for i, f := range feeds {
+		feed := aggregatorArtifact.Bind(f.Address, s.Client)
+		c.feeds = append(c.feeds, feed)
+		c.names = append(c.names, f.Name)
+		if err := b.Add(feed, &c.decimals[i], "decimals"); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.Do(ctx); err != nil {
+		return nil, errors.Wrap(err, "reading price feed decimals")
+	}
+	return c, nil
+}
+
+// Read reads the deployment and the feeds at the latest block.
+func (c *Chain) Read(ctx context.Context) (*Reading, error) {
+	head, err := c.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting the latest block")
+	}
+	state, err := c.metrics.ReadAt(ctx, head.Number.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	r := &Reading{State: state, Time: time.Unix(int64(head.Time), 0)}
+	rounds := make([]roundData, len(c.feeds))
+	b := c.client.NewBatch(head.Number)
+	for i, feed := range c.feeds {
+		if err := b.Add(feed, &rounds[i], "latestRoundData"); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.Do(ctx); err != nil {
+		return nil, errors.Wrap(err, "reading price feeds")
+	}
+	for i, round := range rounds {
+		value, _ := new(big.Float).Quo(
+			new(big.Float).SetInt(round.Answer),
+			new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(c.decimals[i])), nil)),
+		).Float64()
+		r.Prices = append(r.Prices, Price{Feed: c.names[i], Value: value, UpdatedAt: time.Unix(round.UpdatedAt.Int64(), 0)})
+	}
+	return r, nil
+}
+
+// Pauser returns the Pauser that pauses the Reserve from the session's signer.
+func (c *Chain) Pauser() (Pauser, error) {
+	if c.tx == nil {
+		return nil, errors.New("config: no signer is configured")
+	}
+	return &reservePauser{tx: c.tx, call: chain.Call{Contract: c.reserve, Method: "pause"}}, nil
+}
+
+// CheckPauser fails unless the session's signer holds the Reserve's guardian or pauser role.
+// Reserves from before the guardian role only have the pauser.
+func (c *Chain) CheckPauser(ctx context.Context) error {
+	if c.tx == nil {
+		return errors.New("config: no signer is configured")
+	}
+	if _, ok := c.reserve.ABI.Methods["guardian"]; ok {
+		guardian, err := c.reserve.CallAddress(ctx, "guardian")
+		if err != nil {
+			return errors.Wrap(err, "reading the Reserve's guardian")
+		}
+		if guardian == c.tx.From() {
+			return nil
+		}
+	}
+	pauser, err := c.reserve.CallAddress(ctx, "pauser")
+	if err != nil {
+		return errors.Wrap(err, "reading the Reserve's pauser")
+	}
+	if pauser != c.tx.From() {
+		return errors.Errorf("the signer %v is not the Reserve's guardian or pauser, %v", c.tx.From().Hex(), pauser.Hex())
+	}
+	return nil
+}
+
+type reservePauser struct {
+	tx   *chain.Transactor
+	call chain.Call
+}
+
+func (p *reservePauser) Check(ctx context.Context) error {
+	_, err := p.tx.Prepare(ctx, p.call)
+	return err
+}
+
+func (p *reservePauser) Pause(ctx context.Context) (common.Hash, error) {
+	tx, err := p.tx.Send(ctx, p.call)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
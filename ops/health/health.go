@@ -0,0 +1,237 @@
+// Package health checks that a deployment is up and as expected: that the node answers and is
+// current, that every manifest contract has code, that the switches are where they should be,
+// and that the contracts' owners are the expected addresses. It serves the result at /healthz,
+// as JSON, for load balancers and uptime monitors.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Reading is what is read of the deployment, at one block.
+type Reading struct {
+	Block     uint64
+	BlockTime time.Time
+
+	// Code is whether there is code at each manifest contract, by name.
+	Code map[string]bool
+
+	// Switches are the positions of the switches, as "Reserve.paused", "Reserve.transfersPaused",
+	// "Manager.issuancePaused", "Manager.redemptionPaused", and "Manager.emergency".
+	Switches map[string]bool
+
+	// Owners are the owners of the contracts that have one, by name.
+	Owners map[string]common.Address
+}
+
+// Expect is what the deployment is expected to be.
+type Expect struct {
+	// Switches are the expected positions of the switches, keyed as in Reading. The switches
+	// not given are expected off.
+	Switches map[string]bool
+
+	// Owners are the expected owners, by contract name. The owners of the contracts not given
+	// are not checked.
+	Owners map[string]common.Address
+
+	// MaxBlockAge is how old the latest block may be before the node is taken to be stuck or
+	// syncing; zero means any age.
+	MaxBlockAge time.Duration
+}
+
+// The checks, in the order reported.
+const (
+	RPC      = "rpc"
+	Code     = "code"
+	Switches = "switches"
+	Owners   = "owners"
+)
+
+// Check is the result of one check.
+type Check struct {
+	Name string `json:"name"`
+	OK   bool   `json:"ok"`
+
+	// Detail says what is wrong, or, for the rpc check, how current the node is.
+	Detail string `json:"detail,omitempty"`
+}
+
+// Status is the result of all the checks.
+type Status struct {
+	OK      bool      `json:"ok"`
+	Time    time.Time `json:"time"`
+	Network string    `json:"network"`
+	Block   uint64    `json:"block,omitempty"`
+	Checks  []Check   `json:"checks"`
+}
+
+// Evaluate checks r, read at now, against e. If reading failed, with err, the node check fails
+// and the others are not made.
+func Evaluate(r *Reading, err error, e Expect, now time.Time) *Status {
+	st := &Status{Time: now.UTC()}
+	if err != nil {
+		st.Checks = []Check{{Name: RPC, Detail: err.Error()}}
+		for _, name := range []string{Code, Switches, Owners} {
+			st.Checks = append(st.Checks, Check{Name: name, Detail: "not checked"})
+		}
+		return st
+	}
+	st.Block = r.Block
+
+	age := now.Sub(r.BlockTime).Round(time.Second)
+	node := Check{Name: RPC, OK: true, Detail: fmt.Sprintf("latest block %v, %v old", r.Block, age)}
+	if e.MaxBlockAge > 0 && age > e.MaxBlockAge {
+		node.OK = false
+		node.Detail += fmt.Sprintf(", over the limit of %v", e.MaxBlockAge)
+	}
+	st.Checks = append(st.Checks, node)
+
+	var problems []string
+	for _, name := range sortedKeys(r.Code) {
+		if !r.Code[name] {
+			problems = append(problems, "no code at "+name)
+		}
+	}
+	st.Checks = append(st.Checks, check(Code, problems))
+
+	problems = nil
+	for _, name := range sortedKeys(r.Switches) {
+		if on := r.Switches[name]; on != e.Switches[name] {
+			problems = append(problems, fmt.Sprintf("%v is %v, expected %v", name, on, !on))
+		}
+	}
+	for _, name := range sortedKeys(e.Switches) {
+		if _, ok := r.Switches[name]; !ok {
+			problems = append(problems, name+" is not read")
+		}
+	}
+	st.Checks = append(st.Checks, check(Switches, problems))
+
+	problems = nil
+	names := make([]string, 0, len(e.Owners))
+	for name := range e.Owners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		owner, ok := r.Owners[name]
+		switch {
+		case !ok:
+			problems = append(problems, "the owner of "+name+" is not read")
+		case owner != e.Owners[name]:
+			problems = append(problems, fmt.Sprintf("the owner of %v is %v, expected %v", name, owner.Hex(), e.Owners[name].Hex()))
+		}
+	}
+	st.Checks = append(st.Checks, check(Owners, problems))
+
+	st.OK = true
+	for _, c := range st.Checks {
+		st.OK = st.OK && c.OK
+	}
+	return st
+}
+
+func check(name string, problems []string) Check {
+	return Check{Name: name, OK: len(problems) == 0, Detail: strings.Join(problems, "; ")}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Checker checks the deployment every Interval, and serves the latest status.
+type Checker struct {
+	// Read reads the deployment, as Reader.Read does.
+	Read func(ctx context.Context) (*Reading, error)
+
+	Expect   Expect
+	Network  string
+	Interval time.Duration
+
+	mu     sync.Mutex
+	status *Status
+}
+
+// Run checks every Interval until ctx is done.
+func (c *Checker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		c.Refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh checks once, logging each change of the status.
+func (c *Checker) Refresh(ctx context.Context) *Status {
+	r, err := c.Read(ctx)
+	st := Evaluate(r, err, c.Expect, time.Now())
+	st.Network = c.Network
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.status == nil || c.status.OK != st.OK {
+		if st.OK {
+			log.Printf("health: ok at block %v", st.Block)
+		} else {
+			for _, check := range st.Checks {
+				if !check.OK {
+					log.Printf("health: %v failed: %v", check.Name, check.Detail)
+				}
+			}
+		}
+	}
+	c.status = st
+	return st
+}
+
+// ServeHTTP serves the latest status, as JSON: with 200 OK if every check passed, and 503
+// Service Unavailable if one failed, no check has been made yet, or the last is over three
+// intervals old, so that a hung checker is not taken for a healthy one.
+func (c *Checker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	st := c.status
+	c.mu.Unlock()
+
+	code := http.StatusOK
+	switch {
+	case st == nil:
+		st = &Status{Time: time.Now().UTC(), Network: c.Network, Checks: []Check{{Name: RPC, Detail: "not checked yet"}}}
+		code = http.StatusServiceUnavailable
+	case time.Since(st.Time) > 3*c.Interval:
+		stale := *st
+		stale.OK = false
+		stale.Checks = append([]Check{{Name: "checker", Detail: fmt.Sprintf("the last check was at %v", st.Time.Format(time.RFC3339))}}, st.Checks...)
+		st = &stale
+		code = http.StatusServiceUnavailable
+	case !st.OK:
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(st); err != nil {
+		log.Printf("health: writing response: %v", err)
+	}
+}
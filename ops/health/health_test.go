@@ -0,0 +1,111 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	owner    = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	intruder = common.HexToAddress("0x0000000000000000000000000000000000000bad")
+)
+
+func reading(now time.Time) *Reading {
+	return &Reading{
+		Block:     100,
+		BlockTime: now.Add(-20 * time.Second),
+		Code:      map[string]bool{"Reserve": true, "Manager": true, "Vault": true},
+		Switches:  map[string]bool{"Reserve.paused": false, "Manager.issuancePaused": true, "Manager.emergency": false},
+		Owners:    map[string]common.Address{"Reserve": owner, "Manager": owner},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	e := Expect{
+		Switches:    map[string]bool{"Manager.issuancePaused": true},
+		Owners:      map[string]common.Address{"Reserve": owner, "Manager": owner},
+		MaxBlockAge: time.Minute,
+	}
+
+	st := Evaluate(reading(now), nil, e, now)
+	assert.True(t, st.OK)
+	assert.Equal(t, uint64(100), st.Block)
+	require.Len(t, st.Checks, 4)
+	assert.Equal(t, Check{Name: RPC, OK: true, Detail: "latest block 100, 20s old"}, st.Checks[0])
+	for _, c := range st.Checks[1:] {
+		assert.Equal(t, Check{Name: c.Name, OK: true}, c)
+	}
+
+	r := reading(now)
+	r.BlockTime = now.Add(-2 * time.Minute)
+	r.Code["Vault"] = false
+	r.Switches["Reserve.paused"] = true
+	r.Owners["Manager"] = intruder
+	delete(r.Owners, "Reserve")
+	st = Evaluate(r, nil, e, now)
+	assert.False(t, st.OK)
+	assert.Equal(t, []Check{
+		{Name: RPC, Detail: "latest block 100, 2m0s old, over the limit of 1m0s"},
+		{Name: Code, Detail: "no code at Vault"},
+		{Name: Switches, Detail: "Reserve.paused is true, expected false"},
+		{Name: Owners, Detail: "the owner of Manager is " + intruder.Hex() + ", expected " + owner.Hex() + "; the owner of Reserve is not read"},
+	}, st.Checks)
+
+	st = Evaluate(nil, errors.New("dial tcp: connection refused"), e, now)
+	assert.False(t, st.OK)
+	assert.Equal(t, Check{Name: RPC, Detail: "dial tcp: connection refused"}, st.Checks[0])
+	assert.Equal(t, Check{Name: Owners, Detail: "not checked"}, st.Checks[3])
+}
+
+func TestChecker(t *testing.T) {
+	var err error
+	c := &Checker{
+		Read: func(ctx context.Context) (*Reading, error) {
+			return reading(time.Now()), err
+		},
+		Expect:   Expect{Switches: map[string]bool{"Manager.issuancePaused": true}},
+		Network:  "testnet",
+		Interval: time.Hour,
+	}
+	get := func() (int, Status) {
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		var st Status
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &st))
+		return w.Code, st
+	}
+
+	code, st := get()
+	assert.Equal(t, http.StatusServiceUnavailable, code, "nothing is checked yet")
+	assert.False(t, st.OK)
+
+	c.Refresh(context.Background())
+	code, st = get()
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, st.OK)
+	assert.Equal(t, "testnet", st.Network)
+
+	err = errors.New("node down")
+	c.Refresh(context.Background())
+	code, st = get()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "node down", st.Checks[0].Detail)
+
+	// A status that is too old fails, however it came out.
+	err = nil
+	c.Refresh(context.Background())
+	c.status.Time = time.Now().Add(-4 * time.Hour)
+	code, st = get()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "checker", st.Checks[0].Name)
+}
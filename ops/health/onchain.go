@@ -0,0 +1,137 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// ownableABI is the view that the Reader reads the owners with.
+const ownableABI = `[
+	{"type":"function","name":"owner","constant":true,"inputs":[],"outputs":[{"name":"","type":"address"}]}
+]`
+
+var ownableArtifact = func() *chain.Artifact {
+	parsed, err := abi.JSON(strings.NewReader(ownableABI))
+	if err != nil {
+		panic(err)
+	}
+	return &chain.Artifact{Name: "Ownable", ABI: parsed, ABIJSON: ownableABI}
+}()
+
+// switches are the switches read, by contract.
+var switches = []struct{ contract, view string }{
+	{"Reserve", "paused"}, {"Reserve", "transfersPaused"},
+	{"Manager", "issuancePaused"}, {"Manager", "redemptionPaused"}, {"Manager", "emergency"},
+}
+
+// Reader reads a deployment for the checks.
+type Reader struct {
+	client    *chain.Client
+	contracts map[string]common.Address
+	switches  map[string]*chain.Contract
+	owners    map[string]*chain.Contract
+}
+
+// NewReader returns a Reader for the deployment that s is connected to, reading the owners of
+// the named contracts.
+func NewReader(s *session.Session, owners []string) (*Reader, error) {
+	r := &Reader{
+		client:    s.Client,
+		contracts: s.Manifest.Contracts,
+		switches:  map[string]*chain.Contract{},
+		owners:    map[string]*chain.Contract{},
+	}
+	for _, sw := range switches {
+		if r.switches[sw.contract] != nil {
+			continue
+		}
+		c, err := s.Contract(sw.contract)
+		if err != nil {
+			return nil, err
+		}
+		r.switches[sw.contract] = c
+	}
+	for _, name := range owners {
+		addr, err := s.Manifest.Address(name)
+		if err != nil {
+			return nil, err
+		}
+		c := ownableArtifact.Bind(addr, s.Client)
+		c.Name = name
+		r.owners[name] = c
+	}
+	return r, nil
+}
+
+// Read reads the deployment at the latest block.
+func (r *Reader) Read(ctx context.Context) (*Reading, error) {
+	head, err := r.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting the latest block")
+	}
+	reading := &Reading{
+		Block:     head.Number.Uint64(),
+		BlockTime: time.Unix(int64(head.Time), 0),
+		Code:      map[string]bool{},
+		Switches:  map[string]bool{},
+		Owners:    map[string]common.Address{},
+	}
+
+	names := make([]string, 0, len(r.contracts))
+	for name := range r.contracts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		code, err := r.client.CodeAt(ctx, r.contracts[name], head.Number)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading the code of %v", name)
+		}
+		reading.Code[name] = len(code) > 0
+	}
+
+	// Calls to a contract without code fail the batch, so only those with code are read; the
+	// code check reports the rest.
+	b := r.client.NewBatch(head.Number)
+	switchResults := map[string]*bool{}
+	for _, sw := range switches {
+		c := r.switches[sw.contract]
+		if !reading.Code[sw.contract] {
+			continue
+		}
+		key := sw.contract + "." + sw.view
+		switchResults[key] = new(bool)
+		if err := b.Add(c, switchResults[key], sw.view); err != nil {
+			return nil, err
+		}
+	}
+	ownerResults := map[string]*common.Address{}
+	for name, c := range r.owners {
+		if !reading.Code[name] {
+			continue
+		}
+		ownerResults[name] = new(common.Address)
+		if err := b.Add(c, ownerResults[name], "owner"); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.Do(ctx); err != nil {
+		return nil, err
+	}
+	for key, on := range switchResults {
+		reading.Switches[key] = *on
+	}
+	for name, owner := range ownerResults {
+		reading.Owners[name] = *owner
+	}
+	return reading, nil
+}
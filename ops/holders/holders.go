@@ -0,0 +1,289 @@
+// Package holders reconstructs the RSV holder set, balances, and allowances from the token's
+// Transfer and Approval events.
+//
+// Every change to a balance emits a Transfer (mints from, and burns to, the zero address), and
+// every change to an allowance emits an Approval carrying the new allowance, so replaying the
+// events in order from the token's deployment gives the full state without reading storage.
+package holders
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// Topics of the events that State replays.
+var (
+	TransferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	ApprovalTopic = crypto.Keccak256Hash([]byte("Approval(address,address,uint256)"))
+)
+
+// Allowance is an outstanding allowance.
+type Allowance struct {
+	Holder  common.Address
+	Spender common.Address
+	Amount  *big.Int
+}
+
+type pair struct {
+	holder, spender common.Address
+}
+
+// State is the token state rebuilt from events so far.
+type State struct {
+	balances   map[common.Address]*big.Int
+	allowances map[pair]*big.Int
+
+	// seen is every address that has sent or received RSV.
+	seen map[common.Address]bool
+
+	// Supply is minted minus burned.
+	Supply *big.Int
+
+	// Block is the last block whose events have been applied.
+	Block uint64
+
+	Transfers uint64
+	Approvals uint64
+}
+
+// NewState returns the state before any events.
+func NewState() *State {
+	return &State{
+		balances:   make(map[common.Address]*big.Int),
+		allowances: make(map[pair]*big.Int),
+		seen:       make(map[common.Address]bool),
+		Supply:     new(big.Int),
+	}
+}
+
+// Copy returns a copy of s, which changes independently of s.
+func (s *State) Copy() *State {
+	c := *s
+	c.balances = make(map[common.Address]*big.Int, len(s.balances))
+	for addr, b := range s.balances {
+		c.balances[addr] = b
+	}
+	c.allowances = make(map[pair]*big.Int, len(s.allowances))
+	for p, a := range s.allowances {
+		c.allowances[p] = a
+	}
+	c.seen = make(map[common.Address]bool, len(s.seen))
+	for addr := range s.seen {
+		c.seen[addr] = true
+	}
+	c.Supply = new(big.Int).Set(s.Supply)
+	return &c
+}
+
+// Apply applies one log. Logs other than Transfer and Approval are ignored.
+func (s *State) Apply(l types.Log) error {
+	if len(l.Topics) == 0 {
+		return nil
+	}
+	switch l.Topics[0] {
+	case TransferTopic, ApprovalTopic:
+	default:
+		return nil
+	}
+	if len(l.Topics) != 3 || len(l.Data) != 32 {
+		return errors.Errorf("malformed event in tx %v, log %v", l.TxHash.Hex(), l.Index)
+	}
+	from := common.BytesToAddress(l.Topics[1].Bytes())
+	to := common.BytesToAddress(l.Topics[2].Bytes())
+	value := new(big.Int).SetBytes(l.Data)
+
+	if l.Topics[0] == ApprovalTopic {
+		s.Approvals++
+		if value.Sign() == 0 {
+			delete(s.allowances, pair{from, to})
+		} else {
+			s.allowances[pair{from, to}] = value
+		}
+		return nil
+	}
+
+	s.Transfers++
+	if from == (common.Address{}) {
+		s.Supply.Add(s.Supply, value)
+	} else if err := s.add(from, new(big.Int).Neg(value)); err != nil {
+		return errors.Wrapf(err, "in tx %v, log %v", l.TxHash.Hex(), l.Index)
+	}
+	if to == (common.Address{}) {
+		s.Supply.Sub(s.Supply, value)
+	} else if err := s.add(to, value); err != nil {
+		return errors.Wrapf(err, "in tx %v, log %v", l.TxHash.Hex(), l.Index)
+	}
+	return nil
+}
+
+func (s *State) add(addr common.Address, delta *big.Int) error {
+	s.seen[addr] = true
+	balance := new(big.Int).Add(s.Balance(addr), delta)
+	switch balance.Sign() {
+	case -1:
+		return errors.Errorf("balance of %v would go negative; were events missed?", addr.Hex())
+	case 0:
+		delete(s.balances, addr)
+	default:
+		s.balances[addr] = balance
+	}
+	return nil
+}
+
+// Balance returns addr's balance.
+func (s *State) Balance(addr common.Address) *big.Int {
+	if b, ok := s.balances[addr]; ok {
+		return b
+	}
+	return new(big.Int)
+}
+
+// Holder is an address with a nonzero balance.
+type Holder struct {
+	Address common.Address
+	Balance *big.Int
+}
+
+// Holders returns every address with a nonzero balance, largest balance first.
+func (s *State) Holders() []Holder {
+	result := make([]Holder, 0, len(s.balances))
+	for addr, balance := range s.balances {
+		result = append(result, Holder{addr, balance})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if c := result[i].Balance.Cmp(result[j].Balance); c != 0 {
+			return c > 0
+		}
+		return result[i].Address.Hex() < result[j].Address.Hex()
+	})
+	return result
+}
+
+// Addresses returns every address that has ever sent or received RSV, in order, including those
+// whose balance is now zero.
+func (s *State) Addresses() []common.Address {
+	result := make([]common.Address, 0, len(s.seen))
+	for addr := range s.seen {
+		result = append(result, addr)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Hex() < result[j].Hex() })
+	return result
+}
+
+// Allowances returns every nonzero allowance, ordered by holder and then spender.
+func (s *State) Allowances() []Allowance {
+	result := make([]Allowance, 0, len(s.allowances))
+	for p, amount := range s.allowances {
+		result = append(result, Allowance{p.holder, p.spender, amount})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Holder != b.Holder {
+			return a.Holder.Hex() < b.Holder.Hex()
+		}
+		return a.Spender.Hex() < b.Spender.Hex()
+	})
+	return result
+}
+
+// HoldersSum is the sum of all balances, which must equal Supply if no events were missed.
+func (s *State) HoldersSum() *big.Int {
+	sum := new(big.Int)
+	for _, b := range s.balances {
+		sum.Add(sum, b)
+	}
+	return sum
+}
+
+// Divergence is an address whose rebuilt balance differs from the balance the contract reports.
+type Divergence struct {
+	Address common.Address
+	Rebuilt *big.Int
+	OnChain *big.Int
+}
+
+// Diff compares the rebuilt balance of each of addresses with onChain, the balances the
+// contract reports for them at s.Block, and returns those that differ, in the order given.
+func (s *State) Diff(addresses []common.Address, onChain []*big.Int) ([]Divergence, error) {
+	if len(addresses) != len(onChain) {
+		return nil, errors.Errorf("%v addresses, but %v balances", len(addresses), len(onChain))
+	}
+	var result []Divergence
+	for i, addr := range addresses {
+		if rebuilt := s.Balance(addr); rebuilt.Cmp(onChain[i]) != 0 {
+			result = append(result, Divergence{addr, rebuilt, onChain[i]})
+		}
+	}
+	return result, nil
+}
+
+// WriteDivergencesCSV writes "address,rebuilt,onChain,difference" rows, with a header. The
+// difference is onChain - rebuilt.
+func WriteDivergencesCSV(w io.Writer, divergences []Divergence) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"address", "rebuilt", "onChain", "difference"})
+	for _, d := range divergences {
+		out.Write([]string{d.Address.Hex(), d.Rebuilt.String(), d.OnChain.String(), new(big.Int).Sub(d.OnChain, d.Rebuilt).String()})
+	}
+	out.Flush()
+	return errors.Wrap(out.Error(), "writing CSV")
+}
+
+// WriteJSON writes the whole state as one JSON document. Amounts are decimal strings of
+// attoRSV, since they overflow the integers that most JSON readers handle.
+func (s *State) WriteJSON(w io.Writer) error {
+	type holder struct {
+		Address common.Address `json:"address"`
+		Balance string         `json:"balance"`
+	}
+	type allowance struct {
+		Holder  common.Address `json:"holder"`
+		Spender common.Address `json:"spender"`
+		Amount  string         `json:"amount"`
+	}
+	doc := struct {
+		Block      uint64      `json:"block"`
+		Supply     string      `json:"supply"`
+		Holders    []holder    `json:"holders"`
+		Allowances []allowance `json:"allowances"`
+	}{Block: s.Block, Supply: s.Supply.String(), Holders: []holder{}, Allowances: []allowance{}}
+	for _, h := range s.Holders() {
+		doc.Holders = append(doc.Holders, holder{h.Address, h.Balance.String()})
+	}
+	for _, a := range s.Allowances() {
+		doc.Allowances = append(doc.Allowances, allowance{a.Holder, a.Spender, a.Amount.String()})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return errors.Wrap(enc.Encode(doc), "writing JSON")
+}
+
+// WriteHoldersCSV writes "address,balance" rows, with a header.
+func (s *State) WriteHoldersCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"address", "balance"})
+	for _, h := range s.Holders() {
+		out.Write([]string{h.Address.Hex(), h.Balance.String()})
+	}
+	out.Flush()
+	return errors.Wrap(out.Error(), "writing CSV")
+}
+
+// WriteAllowancesCSV writes "holder,spender,allowance" rows, with a header.
+func (s *State) WriteAllowancesCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"holder", "spender", "allowance"})
+	for _, a := range s.Allowances() {
+		out.Write([]string{a.Holder.Hex(), a.Spender.Hex(), a.Amount.String()})
+	}
+	out.Flush()
+	return errors.Wrap(out.Error(), "writing CSV")
+}
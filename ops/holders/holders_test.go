@@ -0,0 +1,174 @@
+package holders
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/merkle"
+)
+
+var (
+	zero  = common.Address{}
+	alice = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	bob   = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	carol = common.HexToAddress("0x00000000000000000000000000000000000ca201")
+)
+
+func event(block uint64, topic common.Hash, from, to common.Address, value int64) types.Log {
+	return types.Log{
+		BlockNumber: block,
+		Topics:      []common.Hash{topic, from.Hash(), to.Hash()},
+		Data:        common.LeftPadBytes(big.NewInt(value).Bytes(), 32),
+	}
+}
+
+func transfer(block uint64, from, to common.Address, value int64) types.Log {
+	return event(block, TransferTopic, from, to, value)
+}
+
+func approval(block uint64, holder, spender common.Address, value int64) types.Log {
+	return event(block, ApprovalTopic, holder, spender, value)
+}
+
+func TestApply(t *testing.T) {
+	s := NewState()
+	for _, l := range []types.Log{
+		transfer(1, zero, alice, 100),
+		transfer(2, alice, bob, 30),
+		approval(2, alice, carol, 50),
+		approval(3, bob, carol, 5),
+		transfer(3, alice, carol, 20),
+		approval(3, alice, carol, 30),
+		approval(4, bob, carol, 0),
+		transfer(4, bob, zero, 30),
+		{Topics: []common.Hash{common.HexToHash("0x1234")}},
+	} {
+		require.NoError(t, s.Apply(l))
+	}
+
+	assert.Equal(t, big.NewInt(70), s.Supply)
+	assert.Equal(t, s.Supply, s.HoldersSum())
+	assert.Equal(t, []Holder{{alice, big.NewInt(50)}, {carol, big.NewInt(20)}}, s.Holders())
+	assert.Equal(t, []Allowance{{alice, carol, big.NewInt(30)}}, s.Allowances())
+	assert.Equal(t, uint64(4), s.Transfers)
+	assert.Equal(t, uint64(4), s.Approvals)
+
+	// Spending more than we know about means events were missed.
+	assert.Error(t, s.Apply(transfer(5, bob, alice, 1)))
+}
+
+func TestWrite(t *testing.T) {
+	s := NewState()
+	require.NoError(t, s.Apply(transfer(1, zero, alice, 100)))
+	require.NoError(t, s.Apply(approval(1, alice, bob, 7)))
+	s.Block = 1
+
+	var buf bytes.Buffer
+	require.NoError(t, s.WriteHoldersCSV(&buf))
+	assert.Equal(t, "address,balance\n"+alice.Hex()+",100\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, s.WriteAllowancesCSV(&buf))
+	assert.Equal(t, "holder,spender,allowance\n"+alice.Hex()+","+bob.Hex()+",7\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, s.WriteJSON(&buf))
+	assert.Contains(t, buf.String(), `"supply": "100"`)
+	assert.Contains(t, buf.String(), `"balance": "100"`)
+}
+
+func TestDiff(t *testing.T) {
+	s := NewState()
+	require.NoError(t, s.Apply(transfer(1, zero, alice, 100)))
+	require.NoError(t, s.Apply(transfer(2, alice, bob, 100)))
+
+	// Alice's balance was rebuilt as zero, but storage kept some; bob matches; carol was missed.
+	addresses := []common.Address{alice, bob, carol}
+	divergences, err := s.Diff(addresses, []*big.Int{big.NewInt(5), big.NewInt(100), big.NewInt(1)})
+	require.NoError(t, err)
+	assert.Equal(t, []Divergence{{alice, big.NewInt(0), big.NewInt(5)}, {carol, big.NewInt(0), big.NewInt(1)}}, divergences)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDivergencesCSV(&buf, divergences[:1]))
+	assert.Equal(t, "address,rebuilt,onChain,difference\n"+alice.Hex()+",0,5,5\n", buf.String())
+
+	_, err = s.Diff(addresses, nil)
+	assert.Error(t, err)
+}
+
+// fakeNode serves logs, refusing queries that span more than limit blocks.
+type fakeNode struct {
+	logs    []types.Log
+	limit   uint64
+	queries int
+}
+
+func (n *fakeNode) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	n.queries++
+	from, to := q.FromBlock.Uint64(), q.ToBlock.Uint64()
+	if to-from+1 > n.limit {
+		return nil, errors.New("query returned more than 10000 results")
+	}
+	var result []types.Log
+	for _, l := range n.logs {
+		if l.BlockNumber >= from && l.BlockNumber <= to {
+			result = append(result, l)
+		}
+	}
+	return result, nil
+}
+
+func TestScan(t *testing.T) {
+	node := &fakeNode{limit: 3, logs: []types.Log{
+		transfer(1, zero, alice, 100),
+		transfer(5, alice, bob, 40),
+		transfer(9, bob, carol, 10),
+		transfer(12, carol, alice, 10),
+	}}
+	s := NewState()
+	var progress []uint64
+	require.NoError(t, s.Scan(context.Background(), node, []common.Address{alice}, 0, 10, 8, func(s *State) {
+		progress = append(progress, s.Block)
+	}))
+	assert.Equal(t, uint64(10), s.Block)
+	assert.Equal(t, []Holder{{alice, big.NewInt(60)}, {bob, big.NewInt(30)}, {carol, big.NewInt(10)}}, s.Holders())
+	assert.Equal(t, []uint64{1, 3, 5, 7, 9, 10}, progress)
+}
+
+func TestSnapshot(t *testing.T) {
+	s := NewState()
+	for _, l := range []types.Log{
+		transfer(1, zero, carol, 300),
+		transfer(2, carol, alice, 100),
+		transfer(3, carol, bob, 200),
+	} {
+		require.NoError(t, s.Apply(l))
+	}
+	assert.ElementsMatch(t, []common.Address{alice, bob, carol}, s.Addresses(), "carol's balance is zero, but she has held RSV")
+
+	snap := &Snapshot{Block: 3}
+	snap.SetHolders(s.Holders())
+	assert.Equal(t, "300", snap.Supply)
+	require.Len(t, snap.Holders, 2)
+	assert.Equal(t, bob, snap.Holders[0].Address, "holders are in address order")
+	assert.Equal(t, "100", snap.Holders[1].Balance)
+	for i, h := range snap.Holders {
+		balance, _ := new(big.Int).SetString(h.Balance, 10)
+		assert.Equal(t, uint64(i), h.Index)
+		assert.True(t, merkle.Verify(snap.MerkleRoot, Leaf(h.Index, h.Address, balance), h.Proof))
+	}
+	// A leaf is the packed encoding of the index, address, and balance.
+	packed := append(common.LeftPadBytes([]byte{1}, 32), alice.Bytes()...)
+	packed = append(packed, common.LeftPadBytes(big.NewInt(100).Bytes(), 32)...)
+	assert.Equal(t, crypto.Keccak256Hash(packed), Leaf(1, alice, big.NewInt(100)))
+}
@@ -0,0 +1,64 @@
+package holders
+
+import (
+	"context"
+	"math/big"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+// LogFilterer is the part of a node connection that Scan needs. *ethclient.Client is one.
+type LogFilterer interface {
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// Scan applies the Transfer and Approval events that contracts emitted in blocks from to to,
+// inclusive, to s. It asks for at most chunk blocks at a time, and halves the chunk whenever the
+// node refuses a query (nodes cap how many logs one query may return). progress, if not nil, is
+// called after each chunk.
+//
+// When the token has been upgraded in place, as with the Reserve's eternal storage, contracts
+// must list every implementation that has shared the storage, so that no balance changes are
+// missed.
+func (s *State) Scan(ctx context.Context, node LogFilterer, contracts []common.Address, from, to, chunk uint64, progress func(*State)) error {
+	if chunk == 0 {
+		chunk = 10000
+	}
+	topics := [][]common.Hash{{TransferTopic, ApprovalTopic}}
+	for start := from; start <= to; {
+		end := start + chunk - 1
+		if end > to {
+			end = to
+		}
+		logs, err := node.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(start),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: contracts,
+			Topics:    topics,
+		})
+		if err != nil {
+			if chunk > 1 && ctx.Err() == nil {
+				chunk /= 2
+				continue
+			}
+			return errors.Wrapf(err, "reading logs of blocks %v-%v", start, end)
+		}
+		for _, l := range logs {
+			if l.Removed {
+				continue
+			}
+			if err := s.Apply(l); err != nil {
+				return err
+			}
+		}
+		s.Block = end
+		if progress != nil {
+			progress(s)
+		}
+		start = end + 1
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package holders
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/reserve-protocol/rsv-beta/ops/merkle"
+)
+
+// Snapshot is every holder's balance at one block, committed to by a Merkle root, for
+// distributions and votes that pay out or count by balance.
+//
+// Each holder is a leaf keccak256(abi.encodePacked(uint256 index, address account, uint256
+// balance)), in the format of Uniswap's merkle-distributor, with holders indexed in address
+// order. Proofs verify with OpenZeppelin's MerkleProof.
+type Snapshot struct {
+	Network   string         `json:"network"`
+	ChainID   uint64         `json:"chainId"`
+	Token     common.Address `json:"token"`
+	Block     uint64         `json:"block"`
+	BlockHash common.Hash    `json:"blockHash"`
+
+	// Source is how the balances were found: "events", replaying Transfer events, or
+	// "archive", reading balanceOf at Block.
+	Source string `json:"source"`
+
+	// Supply is the sum of the balances, in attoRSV.
+	Supply     string      `json:"supply"`
+	MerkleRoot common.Hash `json:"merkleRoot"`
+
+	Holders []SnapshotEntry `json:"holders"`
+}
+
+// SnapshotEntry is one holder of a Snapshot.
+type SnapshotEntry struct {
+	Index   uint64         `json:"index"`
+	Address common.Address `json:"address"`
+	Balance string         `json:"balance"` // attoRSV
+	Proof   []common.Hash  `json:"proof"`
+}
+
+// Leaf is the Merkle leaf of a holder.
+func Leaf(index uint64, addr common.Address, balance *big.Int) common.Hash {
+	return crypto.Keccak256Hash(
+		math.PaddedBigBytes(new(big.Int).SetUint64(index), 32),
+		addr.Bytes(),
+		math.PaddedBigBytes(balance, 32),
+	)
+}
+
+// SetHolders fills in the Supply, MerkleRoot, and Holders of s from holders, leaving out zero
+// balances.
+func (s *Snapshot) SetHolders(holders []Holder) {
+	var nonzero []Holder
+	for _, h := range holders {
+		if h.Balance.Sign() > 0 {
+			nonzero = append(nonzero, h)
+		}
+	}
+	sort.Slice(nonzero, func(i, j int) bool {
+		return bytes.Compare(nonzero[i].Address[:], nonzero[j].Address[:]) < 0
+	})
+
+	supply := new(big.Int)
+	leaves := make([]common.Hash, len(nonzero))
+	for i, h := range nonzero {
+		supply.Add(supply, h.Balance)
+		leaves[i] = Leaf(uint64(i), h.Address, h.Balance)
+	}
+	tree := merkle.New(leaves)
+	s.Supply = supply.String()
+	s.MerkleRoot = tree.Root()
+	s.Holders = make([]SnapshotEntry, len(nonzero))
+	for i, h := range nonzero {
+		s.Holders[i] = SnapshotEntry{Index: uint64(i), Address: h.Address, Balance: h.Balance.String(), Proof: tree.Proof(i)}
+	}
+}
+
+// Write writes s as indented JSON.
+func (s *Snapshot) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
@@ -0,0 +1,397 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/safe"
+)
+
+// ordinary are the events of ordinary use of the contracts; every other event records a
+// privileged operation.
+var ordinary = map[string]bool{
+	"Transfer":              true,
+	"Approval":              true,
+	"Issuance":              true,
+	"Redemption":            true,
+	"SingleRedemption":      true,
+	"Bid":                   true,
+	"OperationDropped":      true,
+	"EmergencyRedemption":   true,
+	"Withdrawal":            true,
+	"FeeTaken":              true,
+	"TransferForwarded":     true,
+	"TransferFromForwarded": true,
+	"ApproveForwarded":      true,
+	"PermitForwarded":       true,
+	"FlashMinted":           true,
+	"Snapshot":              true,
+	"BridgeMinted":          true,
+	"BridgeBurned":          true,
+	"SendToChain":           true,
+	"ReceiveFromChain":      true,
+	"MessageFailed":         true,
+	"RetryMessageSuccess":   true,
+	"DelegateChanged":       true,
+	"DelegateVotesChanged":  true,
+	"AuthorizationUsed":     true,
+	"InsuranceDeposited":    true,
+	"FeesDistributed":       true,
+	"FeesClaimed":           true,
+	"AuthorizationCanceled": true,
+}
+
+// AdminEvent reports whether e records a privileged operation, such as a proposal, a role or
+// setting change, pausing, or a change of ownership, rather than ordinary use.
+func AdminEvent(e Event) bool {
+	return e.Event != "" && !ordinary[e.Event]
+}
+
+// AdminNode is what Admin needs of an Ethereum node. *chain.Client is one.
+type AdminNode interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error)
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+}
+
+// AdminOp is a transaction that made privileged operations.
+type AdminOp struct {
+	TxHash common.Hash
+	Block  uint64
+	Time   time.Time
+
+	// Events are the admin events it emitted, as "Reserve.Paused".
+	Events []string
+
+	// Sender sent the transaction. To is the contract that the Safe, or the sender, called, and
+	// Method the method called, if To is an indexed contract.
+	Sender common.Address
+	To     common.Address
+	Method string
+
+	// Safe, if a Safe executed the operations, is how its owners approved them.
+	Safe *SafeApproval
+}
+
+// SafeApproval is how the owners of a Safe approved an AdminOp.
+type SafeApproval struct {
+	Address    common.Address
+	SafeTxHash common.Hash
+
+	// Signers are the owners whose signatures the Safe checked.
+	Signers []common.Address
+
+	// Proposal is what the Safe Transaction Service knows of the transaction: its nonce, its
+	// proposer, and when each owner confirmed it. It is nil if the service is not configured or
+	// knows nothing of the transaction.
+	Proposal *safe.Proposal
+
+	// Link is the Safe transaction's page, if Admin has a link template.
+	Link string
+}
+
+// Admin appends a record of every transaction in the indexed blocks that emitted an admin
+// event to the admin_ops table, with the Safe transaction, if any, that produced it: the
+// owners who signed it and, from a Safe Transaction Service, who proposed it and when each
+// owner confirmed it. The table is an audit trail: records are only ever added to it, never
+// changed or deleted, so Admin stays Depth blocks behind the indexer, beyond the
+// reorganizations that the indexer undoes.
+type Admin struct {
+	Node  AdminNode
+	Store *Store
+
+	// Indexer is the name of the indexer whose events to record.
+	Indexer string
+
+	// Contracts are the indexed contracts, for naming the methods called.
+	Contracts []Contract
+
+	// ChainID is for recovering the senders of transactions.
+	ChainID *big.Int
+
+	// Depth is how far behind the indexer's progress Admin stays (default 64).
+	Depth uint64
+
+	// Service, if set, is the Safe Transaction Service to read proposals from.
+	Service *safe.Service
+
+	// Link, if set, is the template of a link to a Safe transaction, as safe.Link takes.
+	Link string
+}
+
+// Step records the admin operations in the blocks stored since the last Step.
+func (a *Admin) Step(ctx context.Context) error {
+	st, err := a.Store.State(ctx, a.Indexer)
+	if err != nil || st == nil {
+		return err
+	}
+	last, _ := st.Last()
+	depth := a.Depth
+	if depth == 0 {
+		depth = 64
+	}
+	if last < depth {
+		return nil
+	}
+	head := last - depth
+	checked, ok, err := a.Store.adminChecked(ctx, a.Indexer)
+	if err != nil {
+		return err
+	}
+	if ok && checked >= head {
+		return nil
+	}
+	var from uint64
+	if ok {
+		from = checked + 1
+	}
+
+	// The admin events of each transaction, and the transactions in chain order.
+	var hashes []common.Hash
+	events := map[common.Hash][]Event{}
+	first := map[common.Hash]Event{}
+	for _, c := range a.Contracts {
+		stored, err := a.Store.Events(ctx, c.Name, "", from)
+		if err != nil {
+			return err
+		}
+		for _, e := range stored {
+			if e.Block > head || !AdminEvent(e) {
+				continue
+			}
+			if f, seen := first[e.TxHash]; !seen {
+				hashes = append(hashes, e.TxHash)
+				first[e.TxHash] = e
+			} else if e.LogIndex < f.LogIndex {
+				first[e.TxHash] = e
+			}
+			events[e.TxHash] = append(events[e.TxHash], e)
+		}
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		x, y := first[hashes[i]], first[hashes[j]]
+		return x.Block < y.Block || x.Block == y.Block && x.LogIndex < y.LogIndex
+	})
+	ops := make([]AdminOp, 0, len(hashes))
+	for _, h := range hashes {
+		op, err := a.op(ctx, events[h])
+		if err != nil {
+			return err
+		}
+		ops = append(ops, *op)
+	}
+	if err := a.Store.saveAdminOps(ctx, a.Indexer, ops, head); err != nil {
+		return err
+	}
+	if len(ops) > 0 {
+		log.Printf("indexer: recorded %v admin operations in blocks %v-%v", len(ops), from, head)
+	}
+	return nil
+}
+
+// op reads the transaction that emitted events.
+func (a *Admin) op(ctx context.Context, events []Event) (*AdminOp, error) {
+	sort.Slice(events, func(i, j int) bool { return events[i].LogIndex < events[j].LogIndex })
+	hash := events[0].TxHash
+	op := &AdminOp{TxHash: hash, Block: events[0].Block}
+	for _, e := range events {
+		op.Events = append(op.Events, e.Contract+"."+e.Event)
+	}
+	header, err := a.Node.HeaderByNumber(ctx, new(big.Int).SetUint64(op.Block))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading header %v", op.Block)
+	}
+	op.Time = time.Unix(int64(header.Time), 0).UTC()
+	tx, _, err := a.Node.TransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading tx %v", hash.Hex())
+	}
+	receipt, err := a.Node.TransactionReceipt(ctx, hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading the receipt of %v", hash.Hex())
+	}
+	if op.Sender, err = types.Sender(types.NewEIP155Signer(a.ChainID), tx); err != nil {
+		return nil, errors.Wrapf(err, "recovering the sender of tx %v", hash.Hex())
+	}
+
+	data := tx.Data()
+	if tx.To() != nil {
+		op.To = *tx.To()
+	}
+	exec, err := safe.Executed(tx, receipt)
+	if err != nil {
+		return nil, err
+	}
+	if exec != nil {
+		op.To, data = exec.To, exec.Data
+		op.Safe = &SafeApproval{Address: exec.Safe, SafeTxHash: exec.SafeTxHash, Signers: exec.Signers}
+		if exec.Operation == safe.DelegateCall {
+			// The Safe ran another contract's code, such as a batch of calls, as its own.
+			data = nil
+		}
+		if a.Service != nil {
+			if op.Safe.Proposal, err = a.Service.Proposal(ctx, exec.SafeTxHash); err != nil {
+				return nil, errors.Wrapf(err, "reading Safe transaction %v", exec.SafeTxHash.Hex())
+			}
+		}
+		if a.Link != "" {
+			op.Safe.Link = safe.Link(a.Link, exec.Safe, exec.SafeTxHash)
+		}
+	}
+	for _, c := range a.Contracts {
+		if c.Address != op.To || len(data) < 4 {
+			continue
+		}
+		if method, err := c.Artifact.ABI.MethodById(data[:4]); err == nil {
+			op.Method = method.Name
+		}
+	}
+	return op, nil
+}
+
+const insertAdminOp = `INSERT INTO admin_ops (tx_hash, block_number, time, events, sender, target, method,
+	safe, safe_tx_hash, signers, proposal, link) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (tx_hash) DO NOTHING`
+
+const upsertAdminChecked = `INSERT INTO admin_checks (name, block) VALUES (?, ?)
+	ON CONFLICT (name) DO UPDATE SET block = excluded.block`
+
+// saveAdminOps appends ops, and stores that the named indexer's blocks up to checked are
+// recorded, in one database transaction.
+func (s *Store) saveAdminOps(ctx context.Context, name string, ops []AdminOp, checked uint64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting database transaction")
+	}
+	defer tx.Rollback()
+	for _, op := range ops {
+		var safeAddr, safeTxHash, link string
+		signers, proposal := []byte("[]"), []byte("null")
+		if a := op.Safe; a != nil {
+			safeAddr, safeTxHash, link = a.Address.Hex(), a.SafeTxHash.Hex(), a.Link
+			if signers, err = json.Marshal(a.Signers); err != nil {
+				return errors.Wrap(err, "encoding signers")
+			}
+			if proposal, err = json.Marshal(a.Proposal); err != nil {
+				return errors.Wrap(err, "encoding proposal")
+			}
+		}
+		events, err := json.Marshal(op.Events)
+		if err != nil {
+			return errors.Wrap(err, "encoding events")
+		}
+		_, err = tx.ExecContext(ctx, s.rebind(insertAdminOp), op.TxHash.Hex(), int64(op.Block), op.Time.Unix(),
+			string(events), op.Sender.Hex(), op.To.Hex(), op.Method, safeAddr, safeTxHash, string(signers), string(proposal), link)
+		if err != nil {
+			return errors.Wrapf(err, "storing the admin operation of tx %v", op.TxHash.Hex())
+		}
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind(upsertAdminChecked), name, int64(checked)); err != nil {
+		return errors.Wrap(err, "storing admin checks")
+	}
+	return errors.Wrap(tx.Commit(), "committing admin operations")
+}
+
+// adminChecked returns the last block of the named indexer recorded by Admin, if any is.
+func (s *Store) adminChecked(ctx context.Context, name string) (uint64, bool, error) {
+	var block int64
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT block FROM admin_checks WHERE name = ?`), name).Scan(&block)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, errors.Wrap(err, "reading admin checks")
+	}
+	return uint64(block), true, nil
+}
+
+// AdminOps returns the recorded admin operations in blocks from from to to, in chain order.
+func (s *Store) AdminOps(ctx context.Context, from, to time.Time) ([]AdminOp, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT tx_hash, block_number, time, events, sender, target, method,
+		safe, safe_tx_hash, signers, proposal, link FROM admin_ops WHERE time >= ? AND time <= ? ORDER BY block_number, tx_hash`),
+		from.Unix(), to.Unix())
+	if err != nil {
+		return nil, errors.Wrap(err, "querying admin operations")
+	}
+	defer rows.Close()
+	var ops []AdminOp
+	for rows.Next() {
+		var op AdminOp
+		var block, unix int64
+		var txHash, events, sender, target, safeAddr, safeTxHash, signers, proposal, link string
+		err := rows.Scan(&txHash, &block, &unix, &events, &sender, &target, &op.Method,
+			&safeAddr, &safeTxHash, &signers, &proposal, &link)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading admin operations")
+		}
+		op.TxHash, op.Block, op.Time = common.HexToHash(txHash), uint64(block), time.Unix(unix, 0).UTC()
+		op.Sender, op.To = common.HexToAddress(sender), common.HexToAddress(target)
+		if err := json.Unmarshal([]byte(events), &op.Events); err != nil {
+			return nil, errors.Wrapf(err, "parsing the events of tx %v", txHash)
+		}
+		if safeAddr != "" {
+			a := &SafeApproval{Address: common.HexToAddress(safeAddr), SafeTxHash: common.HexToHash(safeTxHash), Link: link}
+			if err := json.Unmarshal([]byte(signers), &a.Signers); err != nil {
+				return nil, errors.Wrapf(err, "parsing the signers of tx %v", txHash)
+			}
+			if err := json.Unmarshal([]byte(proposal), &a.Proposal); err != nil {
+				return nil, errors.Wrapf(err, "parsing the Safe proposal of tx %v", txHash)
+			}
+			op.Safe = a
+		}
+		ops = append(ops, op)
+	}
+	return ops, errors.Wrap(rows.Err(), "reading admin operations")
+}
+
+// WriteAdminOpsCSV writes ops as CSV, one row per operation. Lists, such as the signers, are
+// separated by spaces, and confirmations are given as owner@time.
+func WriteAdminOpsCSV(w io.Writer, ops []AdminOp) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"time", "block", "tx_hash", "events", "sender", "to", "method",
+		"safe", "safe_tx_hash", "safe_nonce", "signers", "proposer", "confirmations", "link"})
+	for _, op := range ops {
+		row := []string{op.Time.Format(time.RFC3339), strconv.FormatUint(op.Block, 10), op.TxHash.Hex(),
+			strings.Join(op.Events, " "), op.Sender.Hex(), op.To.Hex(), op.Method}
+		var safeAddr, safeTxHash, nonce, signers, proposer, confirmations, link string
+		if a := op.Safe; a != nil {
+			safeAddr, safeTxHash, link = a.Address.Hex(), a.SafeTxHash.Hex(), a.Link
+			signers = joinAddresses(a.Signers)
+			if p := a.Proposal; p != nil {
+				nonce = strconv.FormatUint(p.Nonce, 10)
+				if p.Proposer != (common.Address{}) {
+					proposer = p.Proposer.Hex()
+				}
+				var c []string
+				for _, conf := range p.Confirmations {
+					c = append(c, conf.Owner.Hex()+"@"+conf.SubmissionDate.UTC().Format(time.RFC3339))
+				}
+				confirmations = strings.Join(c, " ")
+			}
+		}
+		out.Write(append(row, safeAddr, safeTxHash, nonce, signers, proposer, confirmations, link))
+	}
+	out.Flush()
+	return errors.Wrap(out.Error(), "writing CSV")
+}
+
+func joinAddresses(addrs []common.Address) string {
+	s := make([]string, len(addrs))
+	for i, a := range addrs {
+		s[i] = a.Hex()
+	}
+	return strings.Join(s, " ")
+}
@@ -0,0 +1,344 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+// The anomaly rules, as named in flags.
+const (
+	LargeTransfer = "large-transfer"
+	Concentration = "concentration"
+	MintBurst     = "mint-burst"
+)
+
+// Rules are the heuristics that Anomalies flags RSV transfers by. A nil rule is off.
+type Rules struct {
+	// LargeTransfer flags each transfer, mint, or burn of more than this many attoRSV.
+	LargeTransfer *big.Int
+
+	// Concentration flags the transfer or mint by which an account has received more than
+	// Percent of the supply within Blocks blocks.
+	Concentration *ConcentrationRule
+
+	// MintBurst flags the mint by which more than Amount attoRSV have been minted within
+	// Blocks blocks.
+	MintBurst *MintBurstRule
+}
+
+// ConcentrationRule is the Concentration rule.
+type ConcentrationRule struct {
+	Percent float64
+	Blocks  uint64
+}
+
+// MintBurstRule is the MintBurst rule.
+type MintBurstRule struct {
+	Amount *big.Int
+	Blocks uint64
+}
+
+// Flag marks an indexed event for review.
+type Flag struct {
+	Rule     string
+	Block    uint64
+	TxHash   common.Hash
+	LogIndex uint
+
+	// Detail describes what the rule found.
+	Detail string
+
+	// Posted is whether the alert for the flag has been delivered.
+	Posted bool
+}
+
+// Anomalies checks the Reserve's indexed Transfer events against Rules, stores a flag in the
+// flags table for each event that breaks one, and alerts about it. It only checks the blocks
+// that the indexer has stored, and checks each block once; if a reorganization replaces blocks
+// already checked, their flags are deleted with their events, and the replacing blocks checked.
+//
+// The supply is the sum of the mints and burns indexed, so for the Concentration rule the
+// indexer must have indexed the Reserve from its deployment.
+type Anomalies struct {
+	Store *Store
+
+	// Indexer is the name of the indexer whose events to check.
+	Indexer string
+
+	Rules Rules
+
+	// Post, if set, delivers the alert for each flag, such as emergency.Notifier.Notify. Flags
+	// whose alert could not be delivered are posted again at the next Step.
+	Post func(ctx context.Context, text string) error
+
+	Network string
+}
+
+// received is an amount an account received, or was minted, in a block.
+type received struct {
+	block uint64
+	value *big.Int
+}
+
+// window sums the amounts received in the last blocks blocks.
+type window struct {
+	blocks uint64
+	items  []received
+	sum    *big.Int
+}
+
+func newWindow(blocks uint64) *window {
+	return &window{blocks: blocks, sum: new(big.Int)}
+}
+
+// add adds value received at block, forgetting what was received before the window, and
+// returns the sum before and after.
+func (w *window) add(block uint64, value *big.Int) (*big.Int, *big.Int) {
+	for len(w.items) > 0 && w.items[0].block+w.blocks <= block {
+		w.sum.Sub(w.sum, w.items[0].value)
+		w.items = w.items[1:]
+	}
+	before := new(big.Int).Set(w.sum)
+	w.items = append(w.items, received{block, value})
+	w.sum.Add(w.sum, value)
+	return before, new(big.Int).Set(w.sum)
+}
+
+// Step checks the blocks stored since the last Step, then posts the alerts not yet posted.
+func (a *Anomalies) Step(ctx context.Context) error {
+	st, err := a.Store.State(ctx, a.Indexer)
+	if err != nil || st == nil {
+		return err
+	}
+	head, _ := st.Last()
+	checked, ok, err := a.Store.checked(ctx, a.Indexer)
+	if err != nil {
+		return err
+	}
+	if !ok || checked < head {
+		var from uint64
+		if ok {
+			from = checked + 1
+		}
+		flags, err := a.check(ctx, from, head)
+		if err != nil {
+			return err
+		}
+		if err := a.Store.saveFlags(ctx, a.Indexer, flags, head); err != nil {
+			return err
+		}
+		if len(flags) > 0 {
+			log.Printf("indexer: flagged %v events in blocks %v-%v", len(flags), from, head)
+		}
+	}
+	if a.Post == nil {
+		return nil
+	}
+	unposted, err := a.Store.flags(ctx, "", 0, true)
+	if err != nil {
+		return err
+	}
+	for _, f := range unposted {
+		text := fmt.Sprintf("RSV on %v: anomaly (%v): %v (block %v, tx %v)", a.Network, f.Rule, f.Detail, f.Block, f.TxHash.Hex())
+		if err := a.Post(ctx, text); err != nil {
+			return errors.Wrapf(err, "posting %v flag of log %v of tx %v", f.Rule, f.LogIndex, f.TxHash.Hex())
+		}
+		if err := a.Store.markPosted(ctx, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check returns the flags of the transfers in blocks from to to. The windowed rules also read
+// the transfers of the blocks just before.
+func (a *Anomalies) check(ctx context.Context, from, to uint64) ([]Flag, error) {
+	r := a.Rules
+	var span uint64
+	if r.Concentration != nil && r.Concentration.Blocks > span {
+		span = r.Concentration.Blocks
+	}
+	if r.MintBurst != nil && r.MintBurst.Blocks > span {
+		span = r.MintBurst.Blocks
+	}
+	start := uint64(0)
+	if from > span {
+		start = from - span
+	}
+	supply := new(big.Int)
+	if r.Concentration != nil && start > 0 {
+		minted, burned, err := a.Store.volume(ctx, 0, start-1)
+		if err != nil {
+			return nil, err
+		}
+		supply.Sub(minted, burned)
+	}
+	events, err := a.Store.Events(ctx, "Reserve", "Transfer", start)
+	if err != nil {
+		return nil, err
+	}
+
+	var flags []Flag
+	accounts := map[common.Address]*window{}
+	var mints *window
+	if r.MintBurst != nil {
+		mints = newWindow(r.MintBurst.Blocks)
+	}
+	zero := common.Address{}
+	for _, e := range events {
+		if e.Block > to {
+			break
+		}
+		var args map[string]string
+		if err := json.Unmarshal([]byte(e.Args), &args); err != nil {
+			return nil, errors.Wrapf(err, "parsing arguments of log %v of tx %v", e.LogIndex, e.TxHash.Hex())
+		}
+		value, ok := new(big.Int).SetString(args["value"], 10)
+		if !ok {
+			return nil, errors.Errorf("bad transfer value %q in log %v of tx %v", args["value"], e.LogIndex, e.TxHash.Hex())
+		}
+		sender, recipient := common.HexToAddress(args["from"]), common.HexToAddress(args["to"])
+		if sender == zero {
+			supply.Add(supply, value)
+		}
+		if recipient == zero {
+			supply.Sub(supply, value)
+		}
+		flag := func(rule, detail string, args ...interface{}) {
+			if e.Block >= from {
+				flags = append(flags, Flag{Rule: rule, Block: e.Block, TxHash: e.TxHash, LogIndex: e.LogIndex, Detail: fmt.Sprintf(detail, args...)})
+			}
+		}
+
+		if r.LargeTransfer != nil && value.Cmp(r.LargeTransfer) > 0 {
+			flag(LargeTransfer, "%v of %v RSV from %v to %v, over the limit of %v RSV",
+				kind(sender, recipient), rsv(value), sender.Hex(), recipient.Hex(), rsv(r.LargeTransfer))
+		}
+		if r.Concentration != nil && recipient != zero {
+			w := accounts[recipient]
+			if w == nil {
+				w = newWindow(r.Concentration.Blocks)
+				accounts[recipient] = w
+			}
+			before, after := w.add(e.Block, value)
+			// The limit is a share of the supply: supply * percent / 100, in hundredths of a percent.
+			limit := new(big.Int).Mul(supply, big.NewInt(int64(r.Concentration.Percent*100)))
+			limit.Div(limit, big.NewInt(10000))
+			if supply.Sign() > 0 && before.Cmp(limit) <= 0 && after.Cmp(limit) > 0 {
+				flag(Concentration, "%v received %v RSV within %v blocks, over %v%% of the supply of %v RSV",
+					recipient.Hex(), rsv(after), r.Concentration.Blocks, r.Concentration.Percent, rsv(supply))
+			}
+		}
+		if mints != nil && sender == zero {
+			before, after := mints.add(e.Block, value)
+			if before.Cmp(r.MintBurst.Amount) <= 0 && after.Cmp(r.MintBurst.Amount) > 0 {
+				flag(MintBurst, "%v RSV minted within %v blocks, over the limit of %v RSV",
+					rsv(after), r.MintBurst.Blocks, rsv(r.MintBurst.Amount))
+			}
+		}
+	}
+	return flags, nil
+}
+
+func kind(from, to common.Address) string {
+	switch {
+	case from == common.Address{}:
+		return "mint"
+	case to == common.Address{}:
+		return "burn"
+	}
+	return "transfer"
+}
+
+func rsv(n *big.Int) string {
+	return units.Format(n, 18)
+}
+
+const insertFlag = `INSERT INTO flags (tx_hash, log_index, rule, block_number, detail, posted) VALUES (?, ?, ?, ?, ?, 0)
+	ON CONFLICT (tx_hash, log_index, rule) DO NOTHING`
+
+const upsertChecked = `INSERT INTO anomaly_checks (name, block) VALUES (?, ?)
+	ON CONFLICT (name) DO UPDATE SET block = excluded.block`
+
+// saveFlags stores flags, and that the named indexer's blocks up to checked are checked, in one
+// database transaction. Storing a flag again keeps the first.
+func (s *Store) saveFlags(ctx context.Context, name string, flags []Flag, checked uint64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting database transaction")
+	}
+	defer tx.Rollback()
+	for _, f := range flags {
+		_, err := tx.ExecContext(ctx, s.rebind(insertFlag), f.TxHash.Hex(), f.LogIndex, f.Rule, int64(f.Block), f.Detail)
+		if err != nil {
+			return errors.Wrapf(err, "storing %v flag of log %v of tx %v", f.Rule, f.LogIndex, f.TxHash.Hex())
+		}
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind(upsertChecked), name, int64(checked)); err != nil {
+		return errors.Wrap(err, "storing anomaly checks")
+	}
+	return errors.Wrap(tx.Commit(), "committing flags")
+}
+
+// checked returns the last block of the named indexer checked for anomalies, if any is.
+func (s *Store) checked(ctx context.Context, name string) (uint64, bool, error) {
+	var block int64
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT block FROM anomaly_checks WHERE name = ?`), name).Scan(&block)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, errors.Wrap(err, "reading anomaly checks")
+	}
+	return uint64(block), true, nil
+}
+
+func (s *Store) markPosted(ctx context.Context, f Flag) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`UPDATE flags SET posted = 1 WHERE tx_hash = ? AND log_index = ? AND rule = ?`),
+		f.TxHash.Hex(), f.LogIndex, f.Rule)
+	return errors.Wrap(err, "marking flag posted")
+}
+
+// Flags returns the stored flags of rule, or of every rule if rule is "", from block from on,
+// in chain order, for review.
+func (s *Store) Flags(ctx context.Context, rule string, from uint64) ([]Flag, error) {
+	return s.flags(ctx, rule, from, false)
+}
+
+func (s *Store) flags(ctx context.Context, rule string, from uint64, unposted bool) ([]Flag, error) {
+	query := `SELECT tx_hash, log_index, rule, block_number, detail, posted FROM flags WHERE block_number >= ?`
+	args := []interface{}{int64(from)}
+	if rule != "" {
+		query += ` AND rule = ?`
+		args = append(args, rule)
+	}
+	if unposted {
+		query += ` AND posted = 0`
+	}
+	rows, err := s.db.QueryContext(ctx, s.rebind(query+` ORDER BY block_number, log_index, rule`), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying flags")
+	}
+	defer rows.Close()
+	var flags []Flag
+	for rows.Next() {
+		var f Flag
+		var txHash string
+		var block, posted int64
+		if err := rows.Scan(&txHash, &f.LogIndex, &f.Rule, &block, &f.Detail, &posted); err != nil {
+			return nil, errors.Wrap(err, "reading flags")
+		}
+		f.TxHash, f.Block, f.Posted = common.HexToHash(txHash), uint64(block), posted != 0
+		flags = append(flags, f)
+	}
+	return flags, errors.Wrap(rows.Err(), "reading flags")
+}
@@ -0,0 +1,350 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+// GasNode is what Gas needs of an Ethereum node. *chain.Client is one.
+type GasNode interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+	NonceAt(ctx context.Context, account common.Address, number *big.Int) (uint64, error)
+}
+
+// Operator is a key whose gas spending Gas tracks, such as the deployer's, the relayer's, or an
+// admin's.
+type Operator struct {
+	Name    string
+	Address common.Address
+}
+
+// Budget is the most an operator should spend on gas in each period.
+type Budget struct {
+	Operator string
+
+	// Period is "day", "week" (starting Monday), or "month", in UTC.
+	Period string
+
+	// Limit is in wei.
+	Limit *big.Int
+}
+
+// PeriodStart returns the start of the budget period containing t.
+func PeriodStart(period string, t time.Time) (time.Time, error) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case "day":
+		return day, nil
+	case "week":
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)), nil
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	return time.Time{}, errors.Errorf("unknown budget period %q; use day, week, or month", period)
+}
+
+// Spend is the gas paid for one transaction an operator sent.
+type Spend struct {
+	TxHash   common.Hash
+	Operator string
+	Address  common.Address
+	Block    uint64
+	Time     time.Time
+	GasUsed  uint64
+	GasPrice *big.Int
+
+	// Cost is GasUsed times GasPrice, in wei.
+	Cost *big.Int
+}
+
+// Gas records what each of Operators pays for gas, for every transaction it sends, failed ones
+// included, in blocks that the indexer has stored. It alerts when an operator spends more than a
+// budget in a period, once per period.
+//
+// Tracking starts at the indexer's progress when Gas first steps, and at an operator's first
+// Step after it is added. Each Step reads the operators' nonces at the indexer's progress, and
+// only reads the blocks since the last Step if some nonce has moved, so that blocks in which no
+// operator sent anything cost nothing; the nonces must be read within the state a node keeps,
+// which without an archive is the last 128 blocks.
+type Gas struct {
+	Node  GasNode
+	Store *Store
+
+	// Indexer is the name of the indexer whose progress to follow.
+	Indexer string
+
+	// ChainID is for recovering the senders of transactions.
+	ChainID *big.Int
+
+	Operators []Operator
+	Budgets   []Budget
+
+	// Post, if set, delivers the alert for each budget overrun, such as
+	// emergency.Notifier.Notify. An alert that could not be delivered is posted again at the
+	// next Step.
+	Post func(ctx context.Context, text string) error
+
+	Network string
+}
+
+// Step records the operators' transactions since the last Step, then checks the budgets.
+func (g *Gas) Step(ctx context.Context) error {
+	st, err := g.Store.State(ctx, g.Indexer)
+	if err != nil || st == nil {
+		return err
+	}
+	head, _ := st.Last()
+	number := new(big.Int).SetUint64(head)
+	checked, nonces, ok, err := g.Store.gasChecked(ctx, g.Indexer)
+	if err != nil {
+		return err
+	}
+	if ok && checked >= head {
+		return g.checkBudgets(ctx, head)
+	}
+
+	// The operators whose nonces have moved, with how many transactions each sent.
+	latest := map[common.Address]uint64{}
+	sent := map[common.Address]uint64{}
+	var pending uint64
+	for _, o := range g.Operators {
+		nonce, err := g.Node.NonceAt(ctx, o.Address, number)
+		if err != nil {
+			return errors.Wrapf(err, "reading the nonce of %v (%v) at block %v", o.Name, o.Address.Hex(), head)
+		}
+		latest[o.Address] = nonce
+		if last, known := nonces[o.Address]; ok && known && nonce > last {
+			sent[o.Address] = nonce - last
+			pending += nonce - last
+		}
+	}
+
+	var spends []Spend
+	signer := types.NewEIP155Signer(g.ChainID)
+	for b := checked + 1; ok && pending > 0 && b <= head; b++ {
+		block, err := g.Node.BlockByNumber(ctx, new(big.Int).SetUint64(b))
+		if err != nil {
+			return errors.Wrapf(err, "reading block %v", b)
+		}
+		for _, tx := range block.Transactions() {
+			from, err := types.Sender(signer, tx)
+			if err != nil || sent[from] == 0 {
+				continue
+			}
+			receipt, err := g.Node.TransactionReceipt(ctx, tx.Hash())
+			if err != nil {
+				return errors.Wrapf(err, "reading the receipt of %v", tx.Hash().Hex())
+			}
+			spends = append(spends, Spend{
+				TxHash:   tx.Hash(),
+				Operator: g.name(from),
+				Address:  from,
+				Block:    b,
+				Time:     time.Unix(int64(block.Time()), 0).UTC(),
+				GasUsed:  receipt.GasUsed,
+				GasPrice: tx.GasPrice(),
+				Cost:     new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), tx.GasPrice()),
+			})
+			sent[from]--
+			pending--
+		}
+	}
+	if err := g.Store.saveSpends(ctx, g.Indexer, spends, head, latest); err != nil {
+		return err
+	}
+	if len(spends) > 0 {
+		log.Printf("indexer: recorded the gas of %v operator transactions in blocks %v-%v", len(spends), checked+1, head)
+	}
+	return g.checkBudgets(ctx, head)
+}
+
+func (g *Gas) name(addr common.Address) string {
+	for _, o := range g.Operators {
+		if o.Address == addr {
+			return o.Name
+		}
+	}
+	return addr.Hex()
+}
+
+// checkBudgets alerts about each budget overrun of the period containing block, once.
+func (g *Gas) checkBudgets(ctx context.Context, block uint64) error {
+	if len(g.Budgets) == 0 {
+		return nil
+	}
+	header, err := g.Node.HeaderByNumber(ctx, new(big.Int).SetUint64(block))
+	if err != nil {
+		return errors.Wrapf(err, "reading header %v", block)
+	}
+	now := time.Unix(int64(header.Time), 0)
+	for _, b := range g.Budgets {
+		start, err := PeriodStart(b.Period, now)
+		if err != nil {
+			return err
+		}
+		alerted, err := g.Store.budgetAlerted(ctx, b, start)
+		if err != nil {
+			return err
+		}
+		if alerted {
+			continue
+		}
+		totals, err := g.Store.GasTotals(ctx, start, now)
+		if err != nil {
+			return err
+		}
+		spent := new(big.Int)
+		for _, t := range totals {
+			if t.Operator == b.Operator {
+				spent.Add(spent, t.Cost)
+			}
+		}
+		if spent.Cmp(b.Limit) <= 0 {
+			continue
+		}
+		text := fmt.Sprintf("RSV on %v: %v has spent %v ETH on gas this %v, over its budget of %v ETH",
+			g.Network, b.Operator, units.Format(spent, 18), b.Period, units.Format(b.Limit, 18))
+		log.Printf("indexer: %v", text)
+		if g.Post != nil {
+			if err := g.Post(ctx, text); err != nil {
+				return errors.Wrapf(err, "posting the %v budget alert of %v", b.Period, b.Operator)
+			}
+		}
+		if err := g.Store.markBudgetAlerted(ctx, b, start); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const insertSpend = `INSERT INTO gas_spends (tx_hash, operator, address, block_number, time, gas_used, gas_price, cost)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (tx_hash) DO NOTHING`
+
+const upsertGasChecked = `INSERT INTO gas_checks (name, block, nonces) VALUES (?, ?, ?)
+	ON CONFLICT (name) DO UPDATE SET block = excluded.block, nonces = excluded.nonces`
+
+// saveSpends stores spends, and that the named indexer's blocks up to checked are checked with
+// the operators at nonces, in one database transaction.
+func (s *Store) saveSpends(ctx context.Context, name string, spends []Spend, checked uint64, nonces map[common.Address]uint64) error {
+	data, err := json.Marshal(nonces)
+	if err != nil {
+		return errors.Wrap(err, "encoding nonces")
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting database transaction")
+	}
+	defer tx.Rollback()
+	for _, sp := range spends {
+		_, err := tx.ExecContext(ctx, s.rebind(insertSpend), sp.TxHash.Hex(), sp.Operator, sp.Address.Hex(),
+			int64(sp.Block), sp.Time.Unix(), int64(sp.GasUsed), sp.GasPrice.String(), sp.Cost.String())
+		if err != nil {
+			return errors.Wrapf(err, "storing the gas of tx %v", sp.TxHash.Hex())
+		}
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind(upsertGasChecked), name, int64(checked), string(data)); err != nil {
+		return errors.Wrap(err, "storing gas checks")
+	}
+	return errors.Wrap(tx.Commit(), "committing gas spends")
+}
+
+// gasChecked returns the last block of the named indexer checked for operator transactions, and
+// the operators' nonces then, if any block is.
+func (s *Store) gasChecked(ctx context.Context, name string) (uint64, map[common.Address]uint64, bool, error) {
+	var block int64
+	var data string
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT block, nonces FROM gas_checks WHERE name = ?`), name).Scan(&block, &data)
+	if err == sql.ErrNoRows {
+		return 0, nil, false, nil
+	}
+	if err != nil {
+		return 0, nil, false, errors.Wrap(err, "reading gas checks")
+	}
+	var nonces map[common.Address]uint64
+	if err := json.Unmarshal([]byte(data), &nonces); err != nil {
+		return 0, nil, false, errors.Wrap(err, "parsing gas checks")
+	}
+	return uint64(block), nonces, true, nil
+}
+
+func (s *Store) budgetAlerted(ctx context.Context, b Budget, start time.Time) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT COUNT(*) FROM gas_budget_alerts WHERE operator = ? AND period = ? AND start = ?`),
+		b.Operator, b.Period, start.Unix()).Scan(&n)
+	return n > 0, errors.Wrap(err, "reading budget alerts")
+}
+
+func (s *Store) markBudgetAlerted(ctx context.Context, b Budget, start time.Time) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO gas_budget_alerts (operator, period, start) VALUES (?, ?, ?)
+		ON CONFLICT (operator, period, start) DO NOTHING`), b.Operator, b.Period, start.Unix())
+	return errors.Wrap(err, "storing budget alert")
+}
+
+// GasTotal is what one operator key spent on gas over some time.
+type GasTotal struct {
+	Operator     string
+	Address      common.Address
+	Transactions int
+
+	// Cost is in wei.
+	Cost *big.Int
+}
+
+// GasTotals returns what each operator key spent on gas in blocks from from to to, ordered by
+// operator and then address.
+func (s *Store) GasTotals(ctx context.Context, from, to time.Time) ([]GasTotal, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT operator, address, cost FROM gas_spends WHERE time >= ? AND time <= ?`),
+		from.Unix(), to.Unix())
+	if err != nil {
+		return nil, errors.Wrap(err, "querying gas spends")
+	}
+	defer rows.Close()
+	byKey := map[Operator]*GasTotal{}
+	for rows.Next() {
+		var operator, address, cost string
+		if err := rows.Scan(&operator, &address, &cost); err != nil {
+			return nil, errors.Wrap(err, "reading gas spends")
+		}
+		c, err := parseBig(cost)
+		if err != nil {
+			return nil, err
+		}
+		key := Operator{operator, common.HexToAddress(address)}
+		t, ok := byKey[key]
+		if !ok {
+			t = &GasTotal{Operator: key.Name, Address: key.Address, Cost: new(big.Int)}
+			byKey[key] = t
+		}
+		t.Transactions++
+		t.Cost.Add(t.Cost, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading gas spends")
+	}
+	totals := make([]GasTotal, 0, len(byKey))
+	for _, t := range byKey {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Operator != totals[j].Operator {
+			return totals[i].Operator < totals[j].Operator
+		}
+		return totals[i].Address.Hex() < totals[j].Address.Hex()
+	})
+	return totals, nil
+}
@@ -0,0 +1,325 @@
+// Package indexer follows the chain and stores every event of the deployment's contracts in a
+// SQL database (SQLite or Postgres), as the basis for reporting and monitoring.
+//
+// Events come from a stream.Stream, so only blocks with enough confirmations are indexed, and
+// the events of blocks that a deeper reorganization orphans are deleted again. Each batch of
+// events is stored together with the indexer's stream state in one database transaction, and
+// storing an event again replaces it, so an indexer that is stopped or crashes picks up where it
+// left off without gaps or duplicates. If the chain reorganizes deeper than the stream
+// remembers, the indexer stops rather than guess.
+package indexer
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/backfill"
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/stream"
+)
+
+// Event is one stored log.
+type Event struct {
+	Block     uint64
+	BlockHash common.Hash
+	TxHash    common.Hash
+	LogIndex  uint
+
+	// Contract is the manifest name of the contract that emitted the event.
+	Contract string
+	Address  common.Address
+
+	// Event is the event's name, or "" if the log couldn't be decoded.
+	Event string
+
+	// Args is a JSON object of the decoded arguments. Integers are decimal strings and byte
+	// strings are 0x-prefixed hex.
+	Args string
+
+	// Topics is a JSON array of the log's topics, and Data its hex data, as the node gave them.
+	Topics string
+	Data   string
+
+	// Removed marks an event whose block has left the chain, to be deleted rather than stored.
+	Removed bool
+}
+
+// Log returns the log that e was stored from.
+func (e Event) Log() (types.Log, error) {
+	l := types.Log{
+		Address:     e.Address,
+		BlockNumber: e.Block,
+		BlockHash:   e.BlockHash,
+		TxHash:      e.TxHash,
+		Index:       e.LogIndex,
+		Removed:     e.Removed,
+	}
+	if err := json.Unmarshal([]byte(e.Topics), &l.Topics); err != nil {
+		return types.Log{}, errors.Wrapf(err, "parsing topics of log %v of tx %v", e.LogIndex, e.TxHash.Hex())
+	}
+	data, err := hexutil.Decode(e.Data)
+	if err != nil {
+		return types.Log{}, errors.Wrapf(err, "parsing data of log %v of tx %v", e.LogIndex, e.TxHash.Hex())
+	}
+	l.Data = data
+	return l, nil
+}
+
+// Contract is a contract to index.
+type Contract struct {
+	Name     string
+	Address  common.Address
+	Artifact *chain.Artifact
+}
+
+// Indexer copies the events of Contracts into Store.
+type Indexer struct {
+	Node      stream.Node
+	Store     *Store
+	Contracts []Contract
+
+	// Name keys the indexer's stream state, so that indexers of different deployments can
+	// share a database.
+	Name string
+
+	// From is the first block to index when the indexer hasn't started yet.
+	From uint64
+
+	Confirmations uint64
+
+	// Chunk is the most blocks fetched in one query. Chunks are halved while the node refuses
+	// them as too large.
+	Chunk uint64
+
+	// Depth is how many blocks beyond the confirmations a reorganization may reach and still
+	// be undone; see stream.Stream.
+	Depth uint64
+
+	PollInterval time.Duration
+
+	// Series, if set, is brought up to date after each step.
+	Series *Series
+
+	// Anomalies, if set, checks the blocks of each step.
+	Anomalies *Anomalies
+
+	// Gas, if set, records the gas that operators pay in the blocks of each step.
+	Gas *Gas
+
+	// Admin, if set, records the admin operations in the blocks of each step, once they are
+	// too old to be reorganized.
+	Admin *Admin
+
+	// Backfill, if set, fetches the blocks too old to be reorganized, as a new indexer
+	// starting from a block long past has to, adapting its queries to the node and limiting
+	// their rate, and storing the state after each chunk. The stream then takes over for the
+	// recent blocks. Its Node and Addresses default to the indexer's.
+	Backfill *backfill.Walker
+}
+
+// Run indexes until ctx is done, or until the chain reorganizes deeper than the indexer can undo.
+func (ix *Indexer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(ix.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := ix.Step(ctx); err != nil {
+			if errors.Cause(err) == ErrReorg {
+				return err
+			}
+			log.Printf("indexer: %v", err)
+		} else {
+			if ix.Series != nil {
+				if err := ix.Series.Step(ctx); err != nil {
+					log.Printf("indexer: %v", err)
+				}
+			}
+			if ix.Anomalies != nil {
+				if err := ix.Anomalies.Step(ctx); err != nil {
+					log.Printf("indexer: %v", err)
+				}
+			}
+			if ix.Gas != nil {
+				if err := ix.Gas.Step(ctx); err != nil {
+					log.Printf("indexer: %v", err)
+				}
+			}
+			if ix.Admin != nil {
+				if err := ix.Admin.Step(ctx); err != nil {
+					log.Printf("indexer: %v", err)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ErrReorg means that the chain has reorganized deeper than the indexer can undo.
+var ErrReorg = stream.ErrReorg
+
+// Step indexes every confirmed block beyond the stream state, first deleting the events of any
+// blocks that have left the chain.
+func (ix *Indexer) Step(ctx context.Context) error {
+	addresses := make([]common.Address, len(ix.Contracts))
+	for i, c := range ix.Contracts {
+		addresses[i] = c.Address
+	}
+	s := &stream.Stream{
+		Node:          ix.Node,
+		Addresses:     addresses,
+		From:          ix.From,
+		Confirmations: ix.Confirmations,
+		Chunk:         ix.Chunk,
+		Depth:         ix.Depth,
+	}
+	saved, err := ix.Store.State(ctx, ix.Name)
+	if err != nil {
+		return err
+	}
+	var st stream.State
+	if saved != nil {
+		st = *saved
+	}
+	if ix.Backfill != nil {
+		if st, err = ix.backfill(ctx, addresses, st); err != nil {
+			return err
+		}
+	}
+	for {
+		b, err := s.Next(ctx, st)
+		if err != nil {
+			if errors.Cause(err) == ErrReorg {
+				return errors.Wrap(err, "re-index from an earlier block")
+			}
+			return err
+		}
+		if b == nil {
+			return nil
+		}
+		events := make([]Event, len(b.Logs))
+		for i, l := range b.Logs {
+			events[i] = ix.decode(l)
+		}
+		if err := ix.Store.Save(ctx, ix.Name, events, b.State); err != nil {
+			return err
+		}
+		switch {
+		case len(events) > 0 && events[0].Removed:
+			log.Printf("indexer: blocks %v-%v left the chain; deleted their %v events", b.From, b.To, len(events))
+		case len(events) > 0:
+			log.Printf("indexer: stored %v events from blocks %v-%v", len(events), b.From, b.To)
+		}
+		st = b.State
+	}
+}
+
+// backfill stores the blocks after st that are more than Depth blocks beyond the
+// confirmations, and returns the state after them.
+func (ix *Indexer) backfill(ctx context.Context, addresses []common.Address, st stream.State) (stream.State, error) {
+	head, err := ix.Node.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return st, errors.Wrap(err, "reading head")
+	}
+	depth := ix.Depth
+	if depth == 0 {
+		depth = 64
+	}
+	if head.Number.Uint64() < ix.Confirmations+depth {
+		return st, nil
+	}
+	safe := head.Number.Uint64() - ix.Confirmations - depth
+	next := ix.From
+	if last, ok := st.Last(); ok {
+		next = last + 1
+	}
+	if next > safe {
+		return st, nil
+	}
+
+	w := ix.Backfill
+	if w.Node == nil {
+		w.Node = ix.Node
+	}
+	if w.Addresses == nil {
+		w.Addresses = addresses
+	}
+	log.Printf("indexer: backfilling blocks %v-%v", next, safe)
+	err = w.Walk(ctx, next, safe, func(ctx context.Context, c backfill.Chunk) error {
+		events := make([]Event, len(c.Logs))
+		for i, l := range c.Logs {
+			events[i] = ix.decode(l)
+		}
+		state := stream.State{Blocks: []stream.Block{{Number: c.To, Hash: c.Hash}}}
+		if err := ix.Store.Save(ctx, ix.Name, events, state); err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			log.Printf("indexer: stored %v events from blocks %v-%v", len(events), c.From, c.To)
+		}
+		st = state
+		return nil
+	})
+	return st, err
+}
+
+// decode turns a log into an Event, decoding it with the emitting contract's ABI if possible.
+func (ix *Indexer) decode(l types.Log) Event {
+	topics, _ := json.Marshal(l.Topics)
+	e := Event{
+		Block:     l.BlockNumber,
+		BlockHash: l.BlockHash,
+		TxHash:    l.TxHash,
+		LogIndex:  l.Index,
+		Address:   l.Address,
+		Args:      "{}",
+		Topics:    string(topics),
+		Data:      "0x" + hex.EncodeToString(l.Data),
+		Removed:   l.Removed,
+	}
+	for _, c := range ix.Contracts {
+		if c.Address != l.Address {
+			continue
+		}
+		e.Contract = c.Name
+		decoded, err := c.Artifact.DecodeLog(l.Topics, l.Data)
+		if err != nil {
+			log.Printf("indexer: storing log %v of tx %v undecoded: %v", l.Index, l.TxHash.Hex(), err)
+			break
+		}
+		args := make(map[string]string, len(decoded.Names))
+		for i, name := range decoded.Names {
+			args[name] = jsonValue(decoded.Values[i])
+		}
+		b, _ := json.Marshal(args)
+		e.Event, e.Args = decoded.Name, string(b)
+		break
+	}
+	return e
+}
+
+// jsonValue renders a decoded argument as a string.
+func jsonValue(v interface{}) string {
+	switch v := v.(type) {
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case []byte:
+		return "0x" + hex.EncodeToString(v)
+	case [32]byte:
+		return "0x" + hex.EncodeToString(v[:])
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,625 @@
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/backfill"
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/safe"
+	"github.com/reserve-protocol/rsv-beta/ops/stream"
+)
+
+const transferABI = `[{"anonymous":false,"type":"event","name":"Transfer","inputs":[
+	{"indexed":true,"name":"from","type":"address"},
+	{"indexed":true,"name":"to","type":"address"},
+	{"indexed":false,"name":"value","type":"uint256"}]}]`
+
+var (
+	reserve = common.HexToAddress("0x196f4727526eA7FB1e17b2071B3d8eAA38486988")
+	alice   = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	bob     = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+
+	transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+)
+
+// fakeNode serves a chain of headers and logs, refusing queries that span more than limit
+// blocks.
+type fakeNode struct {
+	head   uint64
+	salt   byte // changes every block hash, to simulate a reorganization
+	logs   []types.Log
+	limit  uint64
+	ranges [][2]uint64
+}
+
+func (n *fakeNode) header(number uint64) *types.Header {
+	return &types.Header{Number: new(big.Int).SetUint64(number), Time: number * 600, Extra: []byte{n.salt}}
+}
+
+func (n *fakeNode) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		return n.header(n.head), nil
+	}
+	return n.header(number.Uint64()), nil
+}
+
+func (n *fakeNode) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	from, to := q.FromBlock.Uint64(), q.ToBlock.Uint64()
+	if to-from+1 > n.limit {
+		return nil, errors.New("query returned more than 10000 results")
+	}
+	n.ranges = append(n.ranges, [2]uint64{from, to})
+	var result []types.Log
+	for _, l := range n.logs {
+		if l.BlockNumber >= from && l.BlockNumber <= to {
+			l.BlockHash = n.header(l.BlockNumber).Hash()
+			result = append(result, l)
+		}
+	}
+	return result, nil
+}
+
+func transfer(block uint64, index uint, from, to common.Address, value int64) types.Log {
+	return types.Log{
+		Address:     reserve,
+		BlockNumber: block,
+		TxHash:      common.BigToHash(big.NewInt(int64(block))),
+		Index:       index,
+		Topics:      []common.Hash{transferTopic, from.Hash(), to.Hash()},
+		Data:        common.LeftPadBytes(big.NewInt(value).Bytes(), 32),
+	}
+}
+
+func openTestStore(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "indexer")
+	require.NoError(t, err)
+	store, err := OpenStore(SQLite, filepath.Join(dir, "events.db"))
+	require.NoError(t, err)
+	return store, func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func testIndexer(t *testing.T, node stream.Node, store *Store) *Indexer {
+	parsed, err := abi.JSON(strings.NewReader(transferABI))
+	require.NoError(t, err)
+	return &Indexer{
+		Node:          node,
+		Store:         store,
+		Contracts:     []Contract{{Name: "Reserve", Address: reserve, Artifact: &chain.Artifact{Name: "Reserve", ABI: parsed}}},
+		Name:          "test",
+		From:          1,
+		Confirmations: 2,
+		Chunk:         8,
+	}
+}
+
+func TestStep(t *testing.T) {
+	store, done := openTestStore(t)
+	defer done()
+	node := &fakeNode{head: 12, limit: 4, logs: []types.Log{
+		transfer(2, 0, common.Address{}, alice, 100),
+		transfer(5, 3, alice, bob, 40),
+		transfer(11, 0, bob, alice, 1),
+		{Address: reserve, BlockNumber: 6, Index: 1, Topics: []common.Hash{common.HexToHash("0x1234")}},
+	}}
+	ix := testIndexer(t, node, store)
+	ctx := context.Background()
+
+	require.NoError(t, ix.Step(ctx))
+	// Only blocks with two confirmations are indexed, in chunks that the node accepts.
+	assert.Equal(t, [][2]uint64{{1, 4}, {5, 8}, {9, 10}}, node.ranges)
+	events, err := store.Events(ctx, "Reserve", "", 0)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, "Transfer", events[0].Event)
+	assert.Equal(t, `{"from":"`+common.Address{}.Hex()+`","to":"`+alice.Hex()+`","value":"100"}`, events[0].Args)
+	assert.Equal(t, uint64(5), events[1].Block)
+	assert.Equal(t, uint(3), events[1].LogIndex)
+	assert.Equal(t, "", events[2].Event, "undecodable logs are kept")
+
+	st, err := store.State(ctx, "test")
+	require.NoError(t, err)
+	last, _ := st.Last()
+	assert.Equal(t, uint64(10), last)
+
+	// Resuming picks up after the stream state.
+	node.head, node.ranges = 13, nil
+	require.NoError(t, ix.Step(ctx))
+	assert.Equal(t, [][2]uint64{{11, 11}}, node.ranges)
+	transfers, err := store.Events(ctx, "Reserve", "Transfer", 6)
+	require.NoError(t, err)
+	assert.Len(t, transfers, 1)
+
+	// A reorganization deeper than the stream remembers stops the indexer.
+	node.salt = 1
+	assert.Equal(t, ErrReorg, errors.Cause(ix.Step(ctx)))
+}
+
+func TestStepResumesFromCursor(t *testing.T) {
+	store, done := openTestStore(t)
+	defer done()
+	ctx := context.Background()
+	node := &fakeNode{head: 12, limit: 100}
+	_, err := store.db.Exec(`INSERT INTO cursors (name, block, block_hash) VALUES (?, ?, ?)`, "test", 6, node.header(6).Hash().Hex())
+	require.NoError(t, err)
+
+	require.NoError(t, testIndexer(t, node, store).Step(ctx))
+	assert.Equal(t, [][2]uint64{{7, 10}}, node.ranges)
+}
+
+func TestStepBackfills(t *testing.T) {
+	store, done := openTestStore(t)
+	defer done()
+	ctx := context.Background()
+	node := &fakeNode{head: 200, limit: 100, logs: []types.Log{
+		transfer(3, 0, common.Address{}, alice, 100),
+		transfer(120, 0, alice, bob, 40),
+		transfer(190, 0, bob, alice, 1),
+	}}
+	ix := testIndexer(t, node, store)
+	ix.Depth = 10
+	ix.Backfill = &backfill.Walker{Chunk: 50}
+
+	// Blocks 1-188 are too deep to be reorganized, so they are backfilled, in growing chunks;
+	// the stream takes the rest.
+	require.NoError(t, ix.Step(ctx))
+	assert.Equal(t, [][2]uint64{{1, 50}, {51, 150}, {151, 188}, {189, 196}, {197, 198}}, node.ranges)
+	events, err := store.Events(ctx, "Reserve", "Transfer", 0)
+	require.NoError(t, err)
+	assert.Len(t, events, 3)
+	st, err := store.State(ctx, "test")
+	require.NoError(t, err)
+	last, _ := st.Last()
+	assert.Equal(t, uint64(198), last)
+
+	// Once caught up, the stream does all the work.
+	node.head, node.ranges = 202, nil
+	require.NoError(t, ix.Step(ctx))
+	assert.Equal(t, [][2]uint64{{199, 200}}, node.ranges)
+}
+
+func TestSaveIsIdempotent(t *testing.T) {
+	store, done := openTestStore(t)
+	defer done()
+	ctx := context.Background()
+	ix := testIndexer(t, &fakeNode{}, store)
+	e := ix.decode(transfer(2, 0, alice, bob, 5))
+	l, err := e.Log()
+	require.NoError(t, err)
+	assert.Equal(t, transfer(2, 0, alice, bob, 5), l, "the log can be rebuilt from the stored event")
+
+	require.NoError(t, store.Save(ctx, "test", []Event{e}, stream.State{Blocks: []stream.Block{{Number: 2}}}))
+	e.Args = `{"replaced":"true"}`
+	require.NoError(t, store.Save(ctx, "test", []Event{e}, stream.State{Blocks: []stream.Block{{Number: 3}}}))
+
+	events, err := store.Events(ctx, "Reserve", "", 0)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, `{"replaced":"true"}`, events[0].Args)
+	st, err := store.State(ctx, "test")
+	require.NoError(t, err)
+	last, _ := st.Last()
+	assert.Equal(t, uint64(3), last)
+
+	st, err = store.State(ctx, "other")
+	require.NoError(t, err)
+	assert.Nil(t, st)
+
+	// Removed events are deleted.
+	e.Removed = true
+	require.NoError(t, store.Save(ctx, "test", []Event{e}, stream.State{Blocks: []stream.Block{{Number: 1}}}))
+	events, err = store.Events(ctx, "Reserve", "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestPage(t *testing.T) {
+	store, done := openTestStore(t)
+	defer done()
+	ctx := context.Background()
+	ix := testIndexer(t, &fakeNode{}, store)
+	var events []Event
+	for _, l := range []types.Log{
+		transfer(2, 0, common.Address{}, alice, 100),
+		transfer(2, 1, alice, bob, 10),
+		transfer(4, 0, bob, bob, 1),
+		transfer(5, 0, alice, common.Address{}, 3),
+	} {
+		events = append(events, ix.decode(l))
+	}
+	require.NoError(t, store.Save(ctx, "test", events, stream.State{Blocks: []stream.Block{{Number: 5}}}))
+
+	page, err := store.Page(ctx, Query{Contract: "Reserve", Event: "Transfer", Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, []uint64{5, 4}, []uint64{page[0].Block, page[1].Block}, "newest first")
+	page, err = store.Page(ctx, Query{Contract: "Reserve", Before: &Position{page[1].Block, page[1].LogIndex}, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, Position{2, 1}, Position{page[0].Block, page[0].LogIndex})
+
+	page, err = store.Page(ctx, Query{Contract: "Reserve", Topic: bob.Hash(), Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, page, 2)
+	page, err = store.Page(ctx, Query{Contract: "Reserve", Topic: alice.Hash(), Before: &Position{2, 1}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, uint64(2), page[0].Block)
+}
+
+func TestSeries(t *testing.T) {
+	store, done := openTestStore(t)
+	defer done()
+	ctx := context.Background()
+	ix := testIndexer(t, &fakeNode{}, store)
+	save := func(head uint64, logs ...types.Log) {
+		var events []Event
+		for _, l := range logs {
+			events = append(events, ix.decode(l))
+		}
+		require.NoError(t, store.Save(ctx, "test", events, stream.State{Blocks: []stream.Block{{Number: head}}}))
+	}
+	// Blocks are 600 seconds apart, so six to the hour.
+	save(15,
+		transfer(2, 0, common.Address{}, alice, 100),
+		transfer(7, 0, common.Address{}, bob, 50),
+		transfer(8, 0, bob, alice, 5),
+		transfer(13, 0, alice, common.Address{}, 30),
+	)
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	var reads []uint64
+	series := &Series{
+		Node:      &fakeNode{},
+		Store:     store,
+		Indexer:   "test",
+		Intervals: []string{"hour", "day"},
+		ReadAt: func(ctx context.Context, block uint64) (*metrics.State, error) {
+			reads = append(reads, block)
+			return &metrics.State{Supply: big.NewInt(0), Tokens: []metrics.Token{
+				{Address: usdc, Symbol: "USDC", Weight: big.NewInt(0), Balance: big.NewInt(int64(block))},
+			}}, nil
+		},
+	}
+
+	require.NoError(t, series.Step(ctx))
+	points, err := store.Points(ctx, "hour", time.Unix(0, 0), time.Unix(1e6, 0))
+	require.NoError(t, err)
+	require.Len(t, points, 2, "the hour that block 15 is in hasn't ended")
+	assert.Equal(t, time.Unix(0, 0).UTC(), points[0].Start)
+	assert.Equal(t, uint64(5), points[0].Block, "the last block before 1:00")
+	assert.Equal(t, "100", points[0].Supply.String())
+	assert.Equal(t, uint64(11), points[1].Block)
+	assert.Equal(t, []string{"150", "50", "0"}, []string{points[1].Supply.String(), points[1].Minted.String(), points[1].Burned.String()})
+	require.Len(t, points[1].Backing, 1)
+	assert.Equal(t, "11", points[1].Backing[0].Balance.String())
+	assert.Equal(t, []uint64{5, 11}, reads)
+	days, err := store.Points(ctx, "day", time.Unix(0, 0), time.Unix(1e6, 0))
+	require.NoError(t, err)
+	assert.Empty(t, days)
+
+	// Later steps continue from the last point stored.
+	save(20)
+	require.NoError(t, series.Step(ctx))
+	points, err = store.Points(ctx, "hour", time.Unix(7200, 0), time.Unix(1e6, 0))
+	require.NoError(t, err)
+	require.Len(t, points, 1)
+	assert.Equal(t, uint64(17), points[0].Block)
+	assert.Equal(t, []string{"120", "0", "30"}, []string{points[0].Supply.String(), points[0].Minted.String(), points[0].Burned.String()})
+
+	var csv bytes.Buffer
+	require.NoError(t, WritePointsCSV(&csv, points))
+	assert.Equal(t, "start,block,supply,minted,burned,USDC_balance,USDC_needed\n"+
+		"1970-01-01T02:00:00Z,17,120,0,30,17,0\n", csv.String())
+}
+
+func TestAnomalies(t *testing.T) {
+	store, done := openTestStore(t)
+	defer done()
+	ctx := context.Background()
+	ix := testIndexer(t, &fakeNode{}, store)
+	save := func(head uint64, logs ...types.Log) {
+		var events []Event
+		for _, l := range logs {
+			events = append(events, ix.decode(l))
+		}
+		require.NoError(t, store.Save(ctx, "test", events, stream.State{Blocks: []stream.Block{{Number: head}}}))
+	}
+	save(9,
+		transfer(2, 0, common.Address{}, alice, 100), // large, and half the supply at once
+		transfer(3, 0, common.Address{}, bob, 30),    // 130 minted within 5 blocks
+		transfer(4, 0, bob, alice, 10),               // alice was already over half
+		transfer(8, 0, alice, bob, 40),
+		transfer(9, 0, bob, bob, 30), // bob has received 70 of 130 within 3 blocks
+	)
+	var posted []string
+	fail := true
+	a := &Anomalies{
+		Store:   store,
+		Indexer: "test",
+		Rules: Rules{
+			LargeTransfer: big.NewInt(50),
+			Concentration: &ConcentrationRule{Percent: 50, Blocks: 3},
+			MintBurst:     &MintBurstRule{Amount: big.NewInt(120), Blocks: 5},
+		},
+		Post: func(ctx context.Context, text string) error {
+			if fail && len(posted) == 1 {
+				fail = false
+				return errors.New("webhook down")
+			}
+			posted = append(posted, text)
+			return nil
+		},
+		Network: "testnet",
+	}
+
+	assert.Error(t, a.Step(ctx))
+	flags, err := store.Flags(ctx, "", 0)
+	require.NoError(t, err)
+	var got []string
+	for _, f := range flags {
+		got = append(got, fmt.Sprint(f.Block, " ", f.Rule))
+	}
+	assert.Equal(t, []string{"2 concentration", "2 large-transfer", "3 mint-burst", "9 concentration"}, got)
+	assert.Equal(t, []bool{true, false, false, false}, []bool{flags[0].Posted, flags[1].Posted, flags[2].Posted, flags[3].Posted})
+
+	// The alerts not yet posted are posted at the next step.
+	require.NoError(t, a.Step(ctx))
+	require.Len(t, posted, 4)
+	assert.Equal(t, "RSV on testnet: anomaly (large-transfer): mint of 0.0000000000000001 RSV from "+
+		common.Address{}.Hex()+" to "+alice.Hex()+", over the limit of 0.00000000000000005 RSV (block 2, tx "+
+		flags[1].TxHash.Hex()+")", posted[1])
+	assert.Contains(t, posted[3], bob.Hex()+" received 0.00000000000000007 RSV within 3 blocks, over 50% of the supply")
+
+	// Later steps check only the new blocks.
+	save(12, transfer(11, 0, common.Address{}, alice, 60))
+	require.NoError(t, a.Step(ctx))
+	flags, err = store.Flags(ctx, LargeTransfer, 10)
+	require.NoError(t, err)
+	require.Len(t, flags, 1)
+	assert.Equal(t, uint64(11), flags[0].Block)
+	assert.Len(t, posted, 5)
+
+	// A reorganization deletes the flags of the orphaned events, and the replacing blocks are
+	// checked.
+	removed := ix.decode(transfer(11, 0, common.Address{}, alice, 60))
+	removed.Removed = true
+	require.NoError(t, store.Save(ctx, "test", []Event{removed}, stream.State{Blocks: []stream.Block{{Number: 10}}}))
+	flags, err = store.Flags(ctx, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, flags)
+	save(12, transfer(12, 0, bob, alice, 55))
+	require.NoError(t, a.Step(ctx))
+	flags, err = store.Flags(ctx, "", 10)
+	require.NoError(t, err)
+	require.Len(t, flags, 1)
+	assert.Equal(t, uint64(12), flags[0].Block)
+}
+
+// gasNode serves blocks of transactions, with the headers of a fakeNode.
+type gasNode struct {
+	fakeNode
+	txs   map[uint64][]*types.Transaction
+	reads []uint64
+}
+
+func (n *gasNode) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	n.reads = append(n.reads, number.Uint64())
+	return types.NewBlock(n.header(number.Uint64()), n.txs[number.Uint64()], nil, nil), nil
+}
+
+func (n *gasNode) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	return &types.Receipt{GasUsed: 21000}, nil
+}
+
+func (n *gasNode) NonceAt(ctx context.Context, account common.Address, number *big.Int) (uint64, error) {
+	var nonce uint64
+	for block, txs := range n.txs {
+		for _, tx := range txs {
+			if from, _ := types.Sender(types.NewEIP155Signer(big.NewInt(1)), tx); from == account && block <= number.Uint64() {
+				nonce++
+			}
+		}
+	}
+	return nonce, nil
+}
+
+func TestGas(t *testing.T) {
+	store, done := openTestStore(t)
+	defer done()
+	ctx := context.Background()
+	relayer, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	stranger, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	nonces := map[*ecdsa.PrivateKey]uint64{}
+	send := func(key *ecdsa.PrivateKey, gwei int64) *types.Transaction {
+		tx := types.NewTransaction(nonces[key], bob, new(big.Int), 21000, big.NewInt(gwei*1e9), nil)
+		nonces[key]++
+		signed, err := types.SignTx(tx, types.NewEIP155Signer(big.NewInt(1)), key)
+		require.NoError(t, err)
+		return signed
+	}
+	node := &gasNode{txs: map[uint64][]*types.Transaction{5: {send(relayer, 1)}}}
+	save := func(head uint64) {
+		require.NoError(t, store.Save(ctx, "test", nil, stream.State{Blocks: []stream.Block{{Number: head}}}))
+	}
+	var posted []string
+	g := &Gas{
+		Node:      node,
+		Store:     store,
+		Indexer:   "test",
+		ChainID:   big.NewInt(1),
+		Operators: []Operator{{Name: "relayer", Address: crypto.PubkeyToAddress(relayer.PublicKey)}},
+		Budgets:   []Budget{{Operator: "relayer", Period: "day", Limit: big.NewInt(4e14)}},
+		Post: func(ctx context.Context, text string) error {
+			posted = append(posted, text)
+			return nil
+		},
+		Network: "testnet",
+	}
+
+	// Tracking starts at the indexer's progress.
+	save(10)
+	require.NoError(t, g.Step(ctx))
+	assert.Empty(t, node.reads)
+
+	// Blocks are read only when an operator has sent something, and only until all of it is found.
+	node.txs[12] = []*types.Transaction{send(stranger, 50), send(relayer, 10)}
+	node.txs[14] = []*types.Transaction{send(relayer, 10)}
+	node.txs[16] = []*types.Transaction{send(relayer, 10)}
+	save(15)
+	require.NoError(t, g.Step(ctx))
+	assert.Equal(t, []uint64{11, 12, 13, 14}, node.reads)
+	totals, err := store.GasTotals(ctx, time.Unix(0, 0), time.Unix(15*600, 0))
+	require.NoError(t, err)
+	assert.Equal(t, []GasTotal{{Operator: "relayer", Address: g.Operators[0].Address, Transactions: 2, Cost: big.NewInt(42e13)}}, totals)
+	require.Len(t, posted, 1)
+	assert.Equal(t, "RSV on testnet: relayer has spent 0.00042 ETH on gas this day, over its budget of 0.0004 ETH", posted[0])
+
+	// A budget alerts once a period.
+	node.reads = nil
+	save(20)
+	require.NoError(t, g.Step(ctx))
+	assert.Equal(t, []uint64{16}, node.reads)
+	assert.Len(t, posted, 1)
+
+	start, err := PeriodStart("week", time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 2, 24, 0, 0, 0, 0, time.UTC), start)
+}
+
+// adminNode serves transactions and their receipts, with the headers of a fakeNode.
+type adminNode struct {
+	fakeNode
+	txs      map[common.Hash]*types.Transaction
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (n *adminNode) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	return n.txs[hash], false, nil
+}
+
+func (n *adminNode) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	return n.receipts[hash], nil
+}
+
+func TestAdmin(t *testing.T) {
+	store, done := openTestStore(t)
+	defer done()
+	ctx := context.Background()
+	reserveABI, err := abi.JSON(strings.NewReader(`[
+		{"type":"function","name":"pause","constant":false,"inputs":[],"outputs":[]},
+		{"type":"function","name":"changeMaxSupply","constant":false,"inputs":[{"name":"newMaxSupply","type":"uint256"}],"outputs":[]}]`))
+	require.NoError(t, err)
+	safeABI, err := abi.JSON(strings.NewReader(safe.ABI))
+	require.NoError(t, err)
+	pauser, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	owner, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	wallet := common.HexToAddress("0x00000000000000000000000000000000005afe00")
+	node := &adminNode{txs: map[common.Hash]*types.Transaction{}, receipts: map[common.Hash]*types.Receipt{}}
+	send := func(key *ecdsa.PrivateKey, to common.Address, data []byte, logs ...*types.Log) common.Hash {
+		tx, err := types.SignTx(types.NewTransaction(0, to, new(big.Int), 100000, big.NewInt(1e9), data), types.NewEIP155Signer(big.NewInt(1)), key)
+		require.NoError(t, err)
+		node.txs[tx.Hash()], node.receipts[tx.Hash()] = tx, &types.Receipt{Logs: logs}
+		return tx.Hash()
+	}
+	event := func(block uint64, index uint, hash common.Hash, name string) Event {
+		return Event{Block: block, TxHash: hash, LogIndex: index, Contract: "Reserve", Address: reserve, Event: name, Args: "{}", Topics: "[]", Data: "0x"}
+	}
+
+	// The pauser pauses directly, and the owners change the maximum supply through their Safe.
+	pause, err := reserveABI.Pack("pause")
+	require.NoError(t, err)
+	paused := send(pauser, reserve, pause)
+	safeTxHash := crypto.Keccak256Hash([]byte("safe tx"))
+	sig, err := crypto.Sign(safeTxHash.Bytes(), owner)
+	require.NoError(t, err)
+	sig[64] += 27
+	change, err := reserveABI.Pack("changeMaxSupply", big.NewInt(1e6))
+	require.NoError(t, err)
+	exec, err := safeABI.Pack("execTransaction", reserve, new(big.Int), change, uint8(safe.Call),
+		new(big.Int), new(big.Int), new(big.Int), common.Address{}, common.Address{}, sig)
+	require.NoError(t, err)
+	changed := send(pauser, wallet, exec, &types.Log{
+		Address: wallet,
+		Topics:  []common.Hash{safeABI.Events["ExecutionSuccess"].Id()},
+		Data:    append(safeTxHash.Bytes(), make([]byte, 32)...),
+	})
+	events := []Event{
+		event(3, 0, paused, "Paused"),
+		event(4, 0, common.BigToHash(big.NewInt(4)), "Transfer"),
+		event(6, 1, changed, "MaxSupplyChanged"),
+	}
+	require.NoError(t, store.Save(ctx, "test", events, stream.State{Blocks: []stream.Block{{Number: 8}}}))
+
+	a := &Admin{
+		Node:      node,
+		Store:     store,
+		Indexer:   "test",
+		Contracts: []Contract{{Name: "Reserve", Address: reserve, Artifact: &chain.Artifact{Name: "Reserve", ABI: reserveABI}}},
+		ChainID:   big.NewInt(1),
+		Depth:     4,
+		Link:      "https://app.safe.global/transactions/tx?safe=eth:{safe}&id=multisig_{safe}_{safeTxHash}",
+	}
+
+	// Admin stays Depth blocks behind the indexer.
+	require.NoError(t, a.Step(ctx))
+	ops, err := store.AdminOps(ctx, time.Unix(0, 0), time.Unix(100*600, 0))
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+	assert.Equal(t, AdminOp{
+		TxHash: paused,
+		Block:  3,
+		Time:   time.Unix(3*600, 0).UTC(),
+		Events: []string{"Reserve.Paused"},
+		Sender: crypto.PubkeyToAddress(pauser.PublicKey),
+		To:     reserve,
+		Method: "pause",
+	}, ops[0])
+
+	require.NoError(t, store.Save(ctx, "test", nil, stream.State{Blocks: []stream.Block{{Number: 12}}}))
+	require.NoError(t, a.Step(ctx))
+	ops, err = store.AdminOps(ctx, time.Unix(0, 0), time.Unix(100*600, 0))
+	require.NoError(t, err)
+	require.Len(t, ops, 2)
+	assert.Equal(t, "changeMaxSupply", ops[1].Method)
+	assert.Equal(t, reserve, ops[1].To)
+	assert.Equal(t, &SafeApproval{
+		Address:    wallet,
+		SafeTxHash: safeTxHash,
+		Signers:    []common.Address{crypto.PubkeyToAddress(owner.PublicKey)},
+		Link:       "https://app.safe.global/transactions/tx?safe=eth:" + wallet.Hex() + "&id=multisig_" + wallet.Hex() + "_" + safeTxHash.Hex(),
+	}, ops[1].Safe)
+
+	var b bytes.Buffer
+	require.NoError(t, WriteAdminOpsCSV(&b, ops))
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "time,block,tx_hash,events,sender,to,method,safe,safe_tx_hash,safe_nonce,signers,proposer,confirmations,link", lines[0])
+	assert.Equal(t, fmt.Sprintf("1970-01-01T00:30:00Z,3,%v,Reserve.Paused,%v,%v,pause,,,,,,,", paused.Hex(),
+		crypto.PubkeyToAddress(pauser.PublicKey).Hex(), reserve.Hex()), lines[1])
+}
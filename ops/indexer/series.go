@@ -0,0 +1,409 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+)
+
+// Intervals are the lengths of time series buckets, by name. Buckets are aligned to UTC.
+var Intervals = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+}
+
+// Point is one bucket of a time series.
+type Point struct {
+	Start time.Time
+
+	// Block is the last block before the bucket ended.
+	Block uint64
+
+	// Supply is the supply at Block, and Minted and Burned what was minted and burned during
+	// the bucket, in attoRSV.
+	Supply *big.Int
+	Minted *big.Int
+	Burned *big.Int
+
+	// Backing is the Vault's balance of each basket token at Block, if it was read.
+	Backing []Backing
+}
+
+// Backing is the Vault's balance of one basket token.
+type Backing struct {
+	Token  common.Address
+	Symbol string
+
+	// Balance is the Vault's balance, and Needed the balance needed to back the supply, in
+	// qTokens.
+	Balance *big.Int
+	Needed  *big.Int
+}
+
+// HeaderReader reads block headers.
+type HeaderReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// Series materializes time series of the supply, mints and burns, and the Vault's backing from
+// the Reserve's indexed Transfer events. It only materializes buckets that ended before the
+// indexer's progress, so a bucket, once stored, is final.
+//
+// The supply is the sum of the mints and burns indexed, so the indexer must have indexed the
+// Reserve from its deployment.
+type Series struct {
+	Node  HeaderReader
+	Store *Store
+
+	// Indexer is the name of the indexer whose events to use.
+	Indexer string
+
+	// Intervals are the names of the series to materialize, from Intervals.
+	Intervals []string
+
+	// ReadAt, if set, reads the state of the deployment at a block, for the backing. Reading the
+	// backing of past buckets needs an archive node.
+	ReadAt func(ctx context.Context, block uint64) (*metrics.State, error)
+}
+
+// Step materializes every bucket that has ended since the last one stored.
+func (s *Series) Step(ctx context.Context) error {
+	st, err := s.Store.State(ctx, s.Indexer)
+	if err != nil || st == nil {
+		return err
+	}
+	head, _ := st.Last()
+	for _, name := range s.Intervals {
+		if err := s.step(ctx, name, head); err != nil {
+			return errors.Wrapf(err, "%v series", name)
+		}
+	}
+	return nil
+}
+
+func (s *Series) step(ctx context.Context, name string, head uint64) error {
+	d, ok := Intervals[name]
+	if !ok {
+		return errors.Errorf("unknown interval %q", name)
+	}
+	headTime, err := s.blockTime(ctx, head)
+	if err != nil {
+		return err
+	}
+
+	last, err := s.Store.lastPoint(ctx, name)
+	if err != nil {
+		return err
+	}
+	var start time.Time
+	var prev uint64 // the last block of the previous bucket
+	supply := new(big.Int)
+	if last != nil {
+		start, prev, supply = last.Start.Add(d), last.Block, last.Supply
+	} else {
+		// Start with the bucket of the first transfer.
+		first, ok, err := s.Store.firstTransfer(ctx)
+		if err != nil || !ok {
+			return err
+		}
+		t, err := s.blockTime(ctx, first)
+		if err != nil {
+			return err
+		}
+		start, prev = t.Truncate(d), first-1
+	}
+
+	for !start.Add(d).After(headTime) {
+		end := start.Add(d)
+		block, err := s.lastBlockBefore(ctx, end, prev, head)
+		if err != nil {
+			return err
+		}
+		minted, burned, err := s.Store.volume(ctx, prev+1, block)
+		if err != nil {
+			return err
+		}
+		supply = new(big.Int).Add(supply, minted)
+		supply.Sub(supply, burned)
+		p := Point{Start: start, Block: block, Supply: supply, Minted: minted, Burned: burned}
+		if s.ReadAt != nil {
+			state, err := s.ReadAt(ctx, block)
+			if err != nil {
+				return errors.Wrapf(err, "reading the backing at block %v", block)
+			}
+			for _, t := range state.Tokens {
+				p.Backing = append(p.Backing, Backing{Token: t.Address, Symbol: t.Symbol, Balance: t.Balance, Needed: state.Needed(t)})
+			}
+		}
+		if err := s.Store.savePoint(ctx, name, p); err != nil {
+			return err
+		}
+		start, prev = end, block
+	}
+	return nil
+}
+
+func (s *Series) blockTime(ctx context.Context, number uint64) (time.Time, error) {
+	return blockTime(ctx, s.Node, number)
+}
+
+func (s *Series) lastBlockBefore(ctx context.Context, t time.Time, lo, hi uint64) (uint64, error) {
+	return LastBlockBefore(ctx, s.Node, t, lo, hi)
+}
+
+func blockTime(ctx context.Context, node HeaderReader, number uint64) (time.Time, error) {
+	header, err := node.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "reading header %v", number)
+	}
+	return time.Unix(int64(header.Time), 0).UTC(), nil
+}
+
+// LastBlockBefore finds the last block before t, given a block lo before t and a block hi not
+// before it.
+func LastBlockBefore(ctx context.Context, node HeaderReader, t time.Time, lo, hi uint64) (uint64, error) {
+	loTime, err := blockTime(ctx, node, lo)
+	if err != nil {
+		return 0, err
+	}
+	hiTime, err := blockTime(ctx, node, hi)
+	if err != nil {
+		return 0, err
+	}
+	// Block times are regular enough that interpolating finds the block in a few steps, but
+	// bisecting every other step bounds the worst case.
+	for i := 0; hi-lo > 1; i++ {
+		mid := lo + (hi-lo)/2
+		if i%2 == 0 && hiTime.After(loTime) {
+			mid = lo + uint64(float64(hi-lo)*float64(t.Sub(loTime))/float64(hiTime.Sub(loTime)))
+			if mid <= lo {
+				mid = lo + 1
+			} else if mid >= hi {
+				mid = hi - 1
+			}
+		}
+		midTime, err := blockTime(ctx, node, mid)
+		if err != nil {
+			return 0, err
+		}
+		if midTime.Before(t) {
+			lo, loTime = mid, midTime
+		} else {
+			hi, hiTime = mid, midTime
+		}
+	}
+	return lo, nil
+}
+
+// firstTransfer returns the block of the first indexed Transfer of the Reserve.
+func (s *Store) firstTransfer(ctx context.Context) (uint64, bool, error) {
+	var block sql.NullInt64
+	err := s.db.QueryRowContext(ctx, s.rebind(
+		`SELECT MIN(block_number) FROM events WHERE contract = ? AND event = ?`), "Reserve", "Transfer").Scan(&block)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "finding the first transfer")
+	}
+	return uint64(block.Int64), block.Valid, nil
+}
+
+// volume sums the Reserve's mints and burns in blocks from to to.
+func (s *Store) volume(ctx context.Context, from, to uint64) (*big.Int, *big.Int, error) {
+	minted, burned := new(big.Int), new(big.Int)
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT args FROM events
+		WHERE contract = ? AND event = ? AND block_number >= ? AND block_number <= ?`),
+		"Reserve", "Transfer", int64(from), int64(to))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "querying transfers")
+	}
+	defer rows.Close()
+	zero := common.Address{}.Hex()
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, nil, errors.Wrap(err, "reading transfers")
+		}
+		var args map[string]string
+		if err := json.Unmarshal([]byte(data), &args); err != nil {
+			return nil, nil, errors.Wrap(err, "parsing transfer")
+		}
+		value, ok := new(big.Int).SetString(args["value"], 10)
+		if !ok {
+			return nil, nil, errors.Errorf("bad transfer value %q", args["value"])
+		}
+		if args["from"] == zero {
+			minted.Add(minted, value)
+		}
+		if args["to"] == zero {
+			burned.Add(burned, value)
+		}
+	}
+	return minted, burned, errors.Wrap(rows.Err(), "reading transfers")
+}
+
+const upsertPoint = `INSERT INTO series (name, start, block, supply, minted, burned) VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (name, start) DO UPDATE SET
+		block = excluded.block, supply = excluded.supply, minted = excluded.minted, burned = excluded.burned`
+
+const upsertBacking = `INSERT INTO series_backing (name, start, token, symbol, balance, needed) VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (name, start, token) DO UPDATE SET
+		symbol = excluded.symbol, balance = excluded.balance, needed = excluded.needed`
+
+func (s *Store) savePoint(ctx context.Context, name string, p Point) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting database transaction")
+	}
+	defer tx.Rollback()
+	start := p.Start.Unix()
+	_, err = tx.ExecContext(ctx, s.rebind(upsertPoint), name, start, int64(p.Block),
+		p.Supply.String(), p.Minted.String(), p.Burned.String())
+	if err != nil {
+		return errors.Wrap(err, "storing series point")
+	}
+	for _, b := range p.Backing {
+		_, err := tx.ExecContext(ctx, s.rebind(upsertBacking), name, start, b.Token.Hex(), b.Symbol,
+			b.Balance.String(), b.Needed.String())
+		if err != nil {
+			return errors.Wrap(err, "storing series backing")
+		}
+	}
+	return errors.Wrap(tx.Commit(), "committing series point")
+}
+
+func (s *Store) lastPoint(ctx context.Context, name string) (*Point, error) {
+	var start sql.NullInt64
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT MAX(start) FROM series WHERE name = ?`), name).Scan(&start)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading series")
+	}
+	if !start.Valid {
+		return nil, nil
+	}
+	points, err := s.Points(ctx, name, time.Unix(start.Int64, 0), time.Unix(start.Int64, 0))
+	if err != nil || len(points) == 0 {
+		return nil, err
+	}
+	return &points[0], nil
+}
+
+// Points returns the stored points of the named series whose buckets start from from to to,
+// in order.
+func (s *Store) Points(ctx context.Context, name string, from, to time.Time) ([]Point, error) {
+	args := []interface{}{name, from.Unix(), to.Unix()}
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT start, block, supply, minted, burned FROM series
+		WHERE name = ? AND start >= ? AND start <= ? ORDER BY start`), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying series")
+	}
+	defer rows.Close()
+	var points []Point
+	index := map[int64]int{}
+	for rows.Next() {
+		var start, block int64
+		var supply, minted, burned string
+		if err := rows.Scan(&start, &block, &supply, &minted, &burned); err != nil {
+			return nil, errors.Wrap(err, "reading series")
+		}
+		p := Point{Start: time.Unix(start, 0).UTC(), Block: uint64(block)}
+		if p.Supply, err = parseBig(supply); err != nil {
+			return nil, err
+		}
+		if p.Minted, err = parseBig(minted); err != nil {
+			return nil, err
+		}
+		if p.Burned, err = parseBig(burned); err != nil {
+			return nil, err
+		}
+		index[start] = len(points)
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading series")
+	}
+
+	rows, err = s.db.QueryContext(ctx, s.rebind(`SELECT start, token, symbol, balance, needed FROM series_backing
+		WHERE name = ? AND start >= ? AND start <= ? ORDER BY start, token`), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying series backing")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var start int64
+		var token, balance, needed string
+		var b Backing
+		if err := rows.Scan(&start, &token, &b.Symbol, &balance, &needed); err != nil {
+			return nil, errors.Wrap(err, "reading series backing")
+		}
+		b.Token = common.HexToAddress(token)
+		if b.Balance, err = parseBig(balance); err != nil {
+			return nil, err
+		}
+		if b.Needed, err = parseBig(needed); err != nil {
+			return nil, err
+		}
+		if i, ok := index[start]; ok {
+			points[i].Backing = append(points[i].Backing, b)
+		}
+	}
+	return points, errors.Wrap(rows.Err(), "reading series backing")
+}
+
+func parseBig(s string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, errors.Errorf("bad stored amount %q", s)
+	}
+	return n, nil
+}
+
+// WritePointsCSV writes points as CSV, one row per point, with the backing of each token that
+// any of them has as two more columns: the Vault's balance and the balance needed.
+func WritePointsCSV(w io.Writer, points []Point) error {
+	var tokens []Backing
+	seen := map[common.Address]bool{}
+	for _, p := range points {
+		for _, b := range p.Backing {
+			if !seen[b.Token] {
+				seen[b.Token] = true
+				tokens = append(tokens, b)
+			}
+		}
+	}
+	out := csv.NewWriter(w)
+	header := []string{"start", "block", "supply", "minted", "burned"}
+	for _, t := range tokens {
+		label := t.Symbol
+		if label == "" {
+			label = t.Token.Hex()
+		}
+		header = append(header, label+"_balance", label+"_needed")
+	}
+	out.Write(header)
+	for _, p := range points {
+		row := []string{p.Start.Format(time.RFC3339), strconv.FormatUint(p.Block, 10),
+			p.Supply.String(), p.Minted.String(), p.Burned.String()}
+		for _, t := range tokens {
+			balance, needed := "", ""
+			for _, b := range p.Backing {
+				if b.Token == t.Token {
+					balance, needed = b.Balance.String(), b.Needed.String()
+				}
+			}
+			row = append(row, balance, needed)
+		}
+		out.Write(row)
+	}
+	out.Flush()
+	return errors.Wrap(out.Error(), "writing CSV")
+}
@@ -0,0 +1,365 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/stream"
+
+	// The supported database drivers.
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Supported database drivers.
+const (
+	SQLite   = "sqlite3"
+	Postgres = "postgres"
+)
+
+// schema is valid for both SQLite and Postgres.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS events (
+		tx_hash      TEXT    NOT NULL,
+		log_index    INTEGER NOT NULL,
+		block_number BIGINT  NOT NULL,
+		block_hash   TEXT    NOT NULL,
+		contract     TEXT    NOT NULL,
+		address      TEXT    NOT NULL,
+		event        TEXT    NOT NULL,
+		args         TEXT    NOT NULL,
+		topics       TEXT    NOT NULL,
+		data         TEXT    NOT NULL,
+		PRIMARY KEY (tx_hash, log_index)
+	)`,
+	`CREATE INDEX IF NOT EXISTS events_block ON events (block_number)`,
+	`CREATE INDEX IF NOT EXISTS events_event ON events (contract, event)`,
+	// cursors holds the positions of indexers from before streams; each is read once, to
+	// start the indexer's stream.
+	`CREATE TABLE IF NOT EXISTS cursors (
+		name       TEXT   PRIMARY KEY,
+		block      BIGINT NOT NULL,
+		block_hash TEXT   NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS streams (
+		name  TEXT PRIMARY KEY,
+		state TEXT NOT NULL
+	)`,
+	// series holds the points of each time series, and series_backing the Vault's balance of
+	// each basket token at them; see Series.
+	`CREATE TABLE IF NOT EXISTS series (
+		name   TEXT   NOT NULL,
+		start  BIGINT NOT NULL,
+		block  BIGINT NOT NULL,
+		supply TEXT   NOT NULL,
+		minted TEXT   NOT NULL,
+		burned TEXT   NOT NULL,
+		PRIMARY KEY (name, start)
+	)`,
+	`CREATE TABLE IF NOT EXISTS series_backing (
+		name    TEXT   NOT NULL,
+		start   BIGINT NOT NULL,
+		token   TEXT   NOT NULL,
+		symbol  TEXT   NOT NULL,
+		balance TEXT   NOT NULL,
+		needed  TEXT   NOT NULL,
+		PRIMARY KEY (name, start, token)
+	)`,
+	// flags holds the events flagged for review, and anomaly_checks the last block of each
+	// indexer checked; see Anomalies.
+	`CREATE TABLE IF NOT EXISTS flags (
+		tx_hash      TEXT    NOT NULL,
+		log_index    INTEGER NOT NULL,
+		rule         TEXT    NOT NULL,
+		block_number BIGINT  NOT NULL,
+		detail       TEXT    NOT NULL,
+		posted       INTEGER NOT NULL,
+		PRIMARY KEY (tx_hash, log_index, rule)
+	)`,
+	`CREATE TABLE IF NOT EXISTS anomaly_checks (
+		name  TEXT   PRIMARY KEY,
+		block BIGINT NOT NULL
+	)`,
+	// gas_spends holds the gas paid for each operator transaction, gas_checks the last block of
+	// each indexer checked and the operators' nonces then, and gas_budget_alerts the budget
+	// periods already alerted about; see Gas.
+	`CREATE TABLE IF NOT EXISTS gas_spends (
+		tx_hash      TEXT   PRIMARY KEY,
+		operator     TEXT   NOT NULL,
+		address      TEXT   NOT NULL,
+		block_number BIGINT NOT NULL,
+		time         BIGINT NOT NULL,
+		gas_used     BIGINT NOT NULL,
+		gas_price    TEXT   NOT NULL,
+		cost         TEXT   NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS gas_spends_time ON gas_spends (time)`,
+	`CREATE TABLE IF NOT EXISTS gas_checks (
+		name   TEXT   PRIMARY KEY,
+		block  BIGINT NOT NULL,
+		nonces TEXT   NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS gas_budget_alerts (
+		operator TEXT   NOT NULL,
+		period   TEXT   NOT NULL,
+		start    BIGINT NOT NULL,
+		PRIMARY KEY (operator, period, start)
+	)`,
+	// admin_ops is the audit trail of privileged operations, which is only ever added to, and
+	// admin_checks the last block of each indexer recorded; see Admin.
+	`CREATE TABLE IF NOT EXISTS admin_ops (
+		tx_hash      TEXT   PRIMARY KEY,
+		block_number BIGINT NOT NULL,
+		time         BIGINT NOT NULL,
+		events       TEXT   NOT NULL,
+		sender       TEXT   NOT NULL,
+		target       TEXT   NOT NULL,
+		method       TEXT   NOT NULL,
+		safe         TEXT   NOT NULL,
+		safe_tx_hash TEXT   NOT NULL,
+		signers      TEXT   NOT NULL,
+		proposal     TEXT   NOT NULL,
+		link         TEXT   NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS admin_ops_time ON admin_ops (time)`,
+	`CREATE TABLE IF NOT EXISTS admin_checks (
+		name  TEXT   PRIMARY KEY,
+		block BIGINT NOT NULL
+	)`,
+}
+
+// Store keeps indexed events, and the stream state of each indexer, in a SQL database.
+type Store struct {
+	db     *sql.DB
+	driver string
+}
+
+// OpenStore opens the database, creating the tables it needs if they don't exist yet.
+func OpenStore(driver, dsn string) (*Store, error) {
+	if driver != SQLite && driver != Postgres {
+		return nil, errors.Errorf("unsupported database driver %q; use %v or %v", driver, SQLite, Postgres)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening database")
+	}
+	if driver == SQLite {
+		// SQLite allows one writer at a time.
+		db.SetMaxOpenConns(1)
+	}
+	s := &Store{db: db, driver: driver}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, errors.Wrap(err, "creating tables")
+		}
+	}
+	return s, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// rebind rewrites the ?-placeholders of query for the store's driver.
+func (s *Store) rebind(query string) string {
+	if s.driver != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// State returns the named indexer's stream state, or nil if the indexer hasn't started.
+func (s *Store) State(ctx context.Context, name string) (*stream.State, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT state FROM streams WHERE name = ?`), name).Scan(&data)
+	if err == nil {
+		var st stream.State
+		if err := json.Unmarshal([]byte(data), &st); err != nil {
+			return nil, errors.Wrapf(err, "parsing stream state of %v", name)
+		}
+		return &st, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "reading stream state")
+	}
+
+	var block int64
+	var hash string
+	err = s.db.QueryRowContext(ctx, s.rebind(`SELECT block, block_hash FROM cursors WHERE name = ?`), name).Scan(&block, &hash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading cursor")
+	}
+	return &stream.State{Blocks: []stream.Block{{Number: uint64(block), Hash: common.HexToHash(hash)}}}, nil
+}
+
+const upsertEvent = `INSERT INTO events
+	(tx_hash, log_index, block_number, block_hash, contract, address, event, args, topics, data)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (tx_hash, log_index) DO UPDATE SET
+		block_number = excluded.block_number, block_hash = excluded.block_hash,
+		contract = excluded.contract, address = excluded.address, event = excluded.event,
+		args = excluded.args, topics = excluded.topics, data = excluded.data`
+
+const deleteEvent = `DELETE FROM events WHERE tx_hash = ? AND log_index = ?`
+
+const deleteFlags = `DELETE FROM flags WHERE tx_hash = ? AND log_index = ?`
+
+// rewindChecks moves the anomaly checks back to the stream state after a reorganization, so
+// that the replacing blocks are checked.
+const rewindChecks = `UPDATE anomaly_checks SET block = ? WHERE name = ? AND block > ?`
+
+const upsertState = `INSERT INTO streams (name, state) VALUES (?, ?)
+	ON CONFLICT (name) DO UPDATE SET state = excluded.state`
+
+// Save stores events, deleting those marked Removed with their flags, and the named indexer's
+// stream state, in one database transaction. Storing an event again replaces it, so a batch
+// interrupted before it committed can simply be redone. If the stream state goes back, as after
+// a reorganization, so do the indexer's anomaly checks.
+func (s *Store) Save(ctx context.Context, name string, events []Event, st stream.State) error {
+	state, err := json.Marshal(st)
+	if err != nil {
+		return errors.Wrap(err, "encoding stream state")
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting database transaction")
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.rebind(upsertEvent))
+	if err != nil {
+		return errors.Wrap(err, "preparing insert")
+	}
+	defer stmt.Close()
+	for _, e := range events {
+		if e.Removed {
+			if _, err := tx.ExecContext(ctx, s.rebind(deleteEvent), e.TxHash.Hex(), e.LogIndex); err != nil {
+				return errors.Wrapf(err, "deleting log %v of tx %v", e.LogIndex, e.TxHash.Hex())
+			}
+			if _, err := tx.ExecContext(ctx, s.rebind(deleteFlags), e.TxHash.Hex(), e.LogIndex); err != nil {
+				return errors.Wrapf(err, "deleting flags of log %v of tx %v", e.LogIndex, e.TxHash.Hex())
+			}
+			continue
+		}
+		_, err := stmt.ExecContext(ctx, e.TxHash.Hex(), e.LogIndex, int64(e.Block), e.BlockHash.Hex(),
+			e.Contract, e.Address.Hex(), e.Event, e.Args, e.Topics, e.Data)
+		if err != nil {
+			return errors.Wrapf(err, "storing log %v of tx %v", e.LogIndex, e.TxHash.Hex())
+		}
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind(upsertState), name, string(state)); err != nil {
+		return errors.Wrap(err, "storing stream state")
+	}
+	if last, ok := st.Last(); ok {
+		if _, err := tx.ExecContext(ctx, s.rebind(rewindChecks), int64(last), name, int64(last)); err != nil {
+			return errors.Wrap(err, "rewinding anomaly checks")
+		}
+	}
+	return errors.Wrap(tx.Commit(), "committing events")
+}
+
+// Events returns the stored events of a contract from block from on, in chain order. An empty
+// event name matches every event.
+func (s *Store) Events(ctx context.Context, contract, event string, from uint64) ([]Event, error) {
+	query := `SELECT tx_hash, log_index, block_number, block_hash, contract, address, event, args, topics, data
+		FROM events WHERE contract = ? AND block_number >= ?`
+	args := []interface{}{contract, int64(from)}
+	if event != "" {
+		query += ` AND event = ?`
+		args = append(args, event)
+	}
+	rows, err := s.db.QueryContext(ctx, s.rebind(query+` ORDER BY block_number, log_index`), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying events")
+	}
+	defer rows.Close()
+	return scanEvents(rows)
+}
+
+func scanEvents(rows *sql.Rows) ([]Event, error) {
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var txHash, blockHash, address string
+		var block int64
+		err := rows.Scan(&txHash, &e.LogIndex, &block, &blockHash, &e.Contract, &address, &e.Event, &e.Args, &e.Topics, &e.Data)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading events")
+		}
+		e.TxHash, e.BlockHash, e.Address = common.HexToHash(txHash), common.HexToHash(blockHash), common.HexToAddress(address)
+		e.Block = uint64(block)
+		events = append(events, e)
+	}
+	return events, errors.Wrap(rows.Err(), "reading events")
+}
+
+// Position is where an event is in the chain.
+type Position struct {
+	Block    uint64
+	LogIndex uint
+}
+
+// Query selects stored events for Page.
+type Query struct {
+	Contract string
+	Event    string // "" matches every event
+
+	// Topic, if set, matches only events that have it among their topics, such as an indexed
+	// address padded to 32 bytes.
+	Topic common.Hash
+
+	// Before, if set, matches only events before it.
+	Before *Position
+
+	Limit int
+}
+
+// Page returns up to q.Limit of the stored events that match q, newest first. To page through
+// them, pass the position of the last event of a page as Before of the next.
+func (s *Store) Page(ctx context.Context, q Query) ([]Event, error) {
+	query := `SELECT tx_hash, log_index, block_number, block_hash, contract, address, event, args, topics, data
+		FROM events WHERE contract = ?`
+	args := []interface{}{q.Contract}
+	if q.Event != "" {
+		query += ` AND event = ?`
+		args = append(args, q.Event)
+	}
+	if q.Topic != (common.Hash{}) {
+		// Topics are stored as the JSON array the node gave, in lowercase hex.
+		query += ` AND topics LIKE ?`
+		args = append(args, `%"`+strings.ToLower(q.Topic.Hex())+`"%`)
+	}
+	if q.Before != nil {
+		query += ` AND (block_number < ? OR (block_number = ? AND log_index < ?))`
+		args = append(args, int64(q.Before.Block), int64(q.Before.Block), q.Before.LogIndex)
+	}
+	query += ` ORDER BY block_number DESC, log_index DESC LIMIT ?`
+	args = append(args, q.Limit)
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying events")
+	}
+	defer rows.Close()
+	return scanEvents(rows)
+}
@@ -0,0 +1,659 @@
+// Package layout works out where compiled contracts keep their state variables, and checks that
+// a new implementation behind a proxy keeps every variable of the old one where it was.
+//
+// A proxy's storage outlives its implementations, so an upgrade whose new implementation moves
+// or retypes a variable reads the old value as something else: a balance as an owner, say. That
+// is the worst way an upgrade can go wrong, and nothing on chain catches it.
+//
+// solc 0.5.7 predates the compiler's own storage-layout output, so the layout is computed from
+// the AST that `make json` includes in each artifact, by Solidity's rules: state variables are
+// laid out from the most basic contract to the most derived, in declaration order, starting at
+// slot 0; a value smaller than 32 bytes shares the slot of the one before it if it fits; and
+// mappings, dynamic arrays, strings, bytes, static arrays, and structs take whole slots of their
+// own.
+//
+// Layouts can also be written to JSON files, to commit, and Diff reports how a contract's layout
+// changed between two of them.
+package layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Variable is one state variable, or one member of a struct.
+type Variable struct {
+	Contract string `json:"contract,omitempty"` // the contract that declares it
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Slot     uint64 `json:"slot"`
+	Offset   int    `json:"offset"` // in bytes, from the low-order end of the slot
+	Size     int    `json:"size"`   // in bytes: 32 for each slot of a variable that takes whole slots
+}
+
+func (v Variable) String() string {
+	return fmt.Sprintf("slot %v offset %v: %v %v (%v)", v.Slot, v.Offset, v.Type, v.Name, v.Contract)
+}
+
+// end returns the position, counting in bytes from the start of slot 0, just past v.
+func (v Variable) end() uint64 {
+	return v.Slot*32 + uint64(v.Offset) + uint64(v.Size)
+}
+
+// Layout is the storage layout of a contract.
+type Layout struct {
+	Contract  string     `json:"contract"`
+	Variables []Variable `json:"variables"`
+
+	// Structs holds the members of each struct that the variables use, by canonical name,
+	// such as "Manager.Proposal", with slots counted from the start of the struct.
+	Structs map[string][]Variable `json:"structs,omitempty"`
+}
+
+// Load computes the layout of the contract called name from its artifact in dir, as written by
+// `make json`.
+func Load(dir, name string) (*Layout, error) {
+	path := filepath.Join(dir, name+".json")
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening artifact for %v (has `make json` been run?)", name)
+	}
+	defer f.Close()
+
+	var combined struct {
+		Sources map[string]struct {
+			AST *node
+		}
+	}
+	if err := json.NewDecoder(f).Decode(&combined); err != nil {
+		return nil, errors.Wrapf(err, "parsing solc output in %v", path)
+	}
+	var roots []*node
+	for source, s := range combined.Sources {
+		if s.AST == nil {
+			return nil, errors.Errorf("%v has no AST for %v; rebuild it with `make json`", path, source)
+		}
+		roots = append(roots, s.AST)
+	}
+	if len(roots) == 0 {
+		return nil, errors.Errorf("%v has no ASTs; rebuild it with `make json`", path)
+	}
+	return FromAST(roots, name)
+}
+
+// node is a node of solc's legacy JSON AST, the format of combined-JSON output.
+type node struct {
+	ID         int             `json:"id"`
+	Name       string          `json:"name"`
+	Attributes json.RawMessage `json:"attributes"`
+	Children   []*node         `json:"children"`
+}
+
+// attributes are the node attributes that layouts need.
+type attributes struct {
+	Name                    string `json:"name"`
+	CanonicalName           string `json:"canonicalName"`
+	Type                    string `json:"type"`
+	Constant                bool   `json:"constant"`
+	StateVariable           bool   `json:"stateVariable"`
+	LinearizedBaseContracts []int  `json:"linearizedBaseContracts"`
+}
+
+func (n *node) attributes() (attributes, error) {
+	var a attributes
+	err := json.Unmarshal(n.Attributes, &a)
+	return a, errors.Wrapf(err, "parsing the attributes of AST node %v", n.ID)
+}
+
+// builder lays out variables, using the contracts and structs of a set of ASTs.
+type builder struct {
+	contracts map[int]*node
+	structs   map[string]*node
+	layout    *Layout
+}
+
+// FromAST computes the layout of the contract called name from the ASTs of its source and
+// every source it imports.
+func FromAST(roots []*node, name string) (*Layout, error) {
+	b := &builder{
+		contracts: make(map[int]*node),
+		structs:   make(map[string]*node),
+		layout:    &Layout{Contract: name, Structs: make(map[string][]Variable)},
+	}
+	var contract *node
+	var walk func(n *node) error
+	walk = func(n *node) error {
+		switch n.Name {
+		case "ContractDefinition":
+			a, err := n.attributes()
+			if err != nil {
+				return err
+			}
+			b.contracts[n.ID] = n
+			if a.Name == name {
+				contract = n
+			}
+		case "StructDefinition":
+			a, err := n.attributes()
+			if err != nil {
+				return err
+			}
+			b.structs[a.CanonicalName] = n
+		}
+		for _, child := range n.Children {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, root := range roots {
+		if err := walk(root); err != nil {
+			return nil, err
+		}
+	}
+	if contract == nil {
+		return nil, errors.Errorf("no contract %v in the AST", name)
+	}
+
+	a, err := contract.attributes()
+	if err != nil {
+		return nil, err
+	}
+	// linearizedBaseContracts runs from the contract itself to its most basic base.
+	var vars []*node
+	var owners []string
+	for i := len(a.LinearizedBaseContracts) - 1; i >= 0; i-- {
+		base, ok := b.contracts[a.LinearizedBaseContracts[i]]
+		if !ok {
+			return nil, errors.Errorf("%v: base contract %v is not in the AST", name, a.LinearizedBaseContracts[i])
+		}
+		baseAttributes, err := base.attributes()
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range base.Children {
+			if child.Name == "VariableDeclaration" {
+				vars = append(vars, child)
+				owners = append(owners, baseAttributes.Name)
+			}
+		}
+	}
+	variables, _, err := b.place(vars, owners, true)
+	if err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	b.layout.Variables = variables
+	return b.layout, nil
+}
+
+// place lays out declarations from slot 0, and returns them along with how many slots they
+// take. If stateOnly is set, only state variables are placed, and constants are skipped;
+// otherwise decls are a struct's members.
+func (b *builder) place(decls []*node, owners []string, stateOnly bool) ([]Variable, uint64, error) {
+	var variables []Variable
+	var slot uint64
+	offset := 0
+	for i, decl := range decls {
+		a, err := decl.attributes()
+		if err != nil {
+			return nil, 0, err
+		}
+		if stateOnly && (!a.StateVariable || a.Constant) {
+			continue
+		}
+		t := normalize(a.Type)
+		size, slots, err := b.storage(t)
+		if err == nil {
+			err = b.structsIn(t)
+		}
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "variable %v", a.Name)
+		}
+		v := Variable{Name: a.Name, Type: t}
+		if owners != nil {
+			v.Contract = owners[i]
+		}
+		if slots > 0 {
+			if offset > 0 {
+				slot, offset = slot+1, 0
+			}
+			v.Slot, v.Size = slot, int(slots)*32
+			slot += slots
+		} else {
+			if offset+size > 32 {
+				slot, offset = slot+1, 0
+			}
+			v.Slot, v.Offset, v.Size = slot, offset, size
+			offset += size
+		}
+		variables = append(variables, v)
+	}
+	if offset > 0 {
+		slot++
+	}
+	return variables, slot, nil
+}
+
+// storage returns how a value of type t is stored: packed, in size bytes of a slot, or, if
+// slots is nonzero, in that many whole slots of its own.
+func (b *builder) storage(t string) (size int, slots uint64, err error) {
+	switch {
+	case strings.HasPrefix(t, "mapping("):
+		return 0, 1, nil
+	case strings.HasSuffix(t, "]"):
+		open := strings.LastIndex(t, "[")
+		if open < 0 {
+			return 0, 0, errors.Errorf("can't parse type %q", t)
+		}
+		length := t[open+1 : len(t)-1]
+		if length == "" {
+			return 0, 1, nil // dynamic array
+		}
+		n, err := strconv.ParseUint(length, 10, 64)
+		if err != nil {
+			return 0, 0, errors.Errorf("can't parse the length of type %q", t)
+		}
+		elemSize, elemSlots, err := b.storage(t[:open])
+		if err != nil {
+			return 0, 0, err
+		}
+		if elemSlots > 0 {
+			return 0, n * elemSlots, nil
+		}
+		perSlot := uint64(32 / elemSize)
+		return 0, (n + perSlot - 1) / perSlot, nil
+	case t == "string" || t == "bytes":
+		return 0, 1, nil
+	case t == "bool":
+		return 1, 0, nil
+	case t == "address" || t == "address payable" || strings.HasPrefix(t, "contract "):
+		return 20, 0, nil
+	case strings.HasPrefix(t, "enum "):
+		return 1, 0, nil
+	case strings.HasPrefix(t, "function "):
+		if strings.Contains(t, " external") {
+			return 24, 0, nil
+		}
+		return 8, 0, nil
+	case strings.HasPrefix(t, "struct "):
+		name := strings.TrimPrefix(t, "struct ")
+		def, ok := b.structs[name]
+		if !ok {
+			return 0, 0, errors.Errorf("struct %v is not in the AST", name)
+		}
+		if _, ok := b.layout.Structs[name]; !ok {
+			b.layout.Structs[name] = nil // so that a struct that maps to itself isn't laid out forever
+		}
+		members, slots, err := b.place(def.Children, nil, false)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "struct %v", name)
+		}
+		b.layout.Structs[name] = members
+		if slots == 0 {
+			slots = 1
+		}
+		return 0, slots, nil
+	}
+	for _, prefix := range []string{"uint", "int", "bytes"} {
+		if !strings.HasPrefix(t, prefix) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimPrefix(t, prefix))
+		if err != nil {
+			break
+		}
+		if prefix != "bytes" {
+			n /= 8
+		}
+		if n < 1 || n > 32 {
+			break
+		}
+		return n, 0, nil
+	}
+	return 0, 0, errors.Errorf("don't know how type %q is stored", t)
+}
+
+var structName = regexp.MustCompile(`struct ([A-Za-z0-9_$.]+)`)
+
+// structsIn lays out the structs that type t refers to, such as the values of a mapping, which
+// take no slots of their own where t is stored but are compared all the same.
+func (b *builder) structsIn(t string) error {
+	for _, match := range structName.FindAllStringSubmatch(t, -1) {
+		if _, ok := b.layout.Structs[match[1]]; ok {
+			continue
+		}
+		if _, _, err := b.storage("struct " + match[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalize strips the data locations that solc writes into type names, such as the
+// " storage ref" of "struct Manager.Proposal storage ref".
+func normalize(t string) string {
+	for _, location := range []string{" storage ref", " storage pointer", " memory", " calldata"} {
+		t = strings.Replace(t, location, "", -1)
+	}
+	return t
+}
+
+// The kinds of Change.
+const (
+	Added   = "added"
+	Removed = "removed"
+	Renamed = "renamed"
+	Retyped = "retyped" // or, from Compare, replaced by another variable
+	Moved   = "moved"   // only from Diff
+)
+
+// Change is a difference between an old layout and a new one.
+type Change struct {
+	Kind   string    `json:"kind"`
+	Struct string    `json:"struct,omitempty"` // from Diff, the struct whose member changed
+	Old    *Variable `json:"old,omitempty"`    // nil for a variable that only the new layout has
+	New    *Variable `json:"new,omitempty"`    // nil for a variable that only the old layout has
+
+	// Problem says why the change would corrupt the proxy's storage, or is "" if it is safe.
+	Problem string `json:"problem,omitempty"`
+}
+
+func (c Change) String() string {
+	var s string
+	switch {
+	case c.Old == nil:
+		s = fmt.Sprintf("added %v", c.New)
+	case c.New == nil:
+		s = fmt.Sprintf("removed %v", c.Old)
+	case c.Kind == Moved:
+		s = fmt.Sprintf("moved %v to slot %v offset %v", c.Old, c.New.Slot, c.New.Offset)
+		if c.Old.Type != c.New.Type {
+			s += fmt.Sprintf(", as %v", c.New.Type)
+		}
+	case c.Old.Name != c.New.Name && c.Old.Type == c.New.Type:
+		s = fmt.Sprintf("renamed %v to %v", c.Old, c.New.Name)
+	default:
+		s = fmt.Sprintf("%v is now %v", c.Old, c.New)
+	}
+	if c.Struct != "" {
+		s = "in struct " + c.Struct + ", " + s
+	}
+	if c.Problem != "" {
+		s += ": " + c.Problem
+	}
+	return s
+}
+
+// Compare compares the layout of an old implementation with that of a new one. The new one
+// must keep every old variable at its slot and offset, with the same type, and may add
+// variables only after all of the old ones; renaming a variable is allowed, but reported. The
+// members of the structs that both use are held to the same rules.
+func Compare(old, new *Layout) []Change {
+	changes := compare(old.Variables, new.Variables)
+	for name, oldMembers := range old.Structs {
+		if newMembers, ok := new.Structs[name]; ok {
+			for _, c := range compare(oldMembers, newMembers) {
+				if c.Problem != "" {
+					c.Problem = "in struct " + name + ", " + c.Problem
+				}
+				changes = append(changes, c)
+			}
+		}
+	}
+	return changes
+}
+
+// Incompatible returns the changes that have problems.
+func Incompatible(changes []Change) []Change {
+	var bad []Change
+	for _, c := range changes {
+		if c.Problem != "" {
+			bad = append(bad, c)
+		}
+	}
+	return bad
+}
+
+func compare(old, new []Variable) []Change {
+	var changes []Change
+	var end uint64 // just past the last of the old variables
+	at := make(map[[2]uint64]int)
+	for i, v := range new {
+		at[[2]uint64{v.Slot, uint64(v.Offset)}] = i
+	}
+	kept := make(map[int]bool)
+	for i := range old {
+		o := &old[i]
+		if o.end() > end {
+			end = o.end()
+		}
+		j, ok := at[[2]uint64{o.Slot, uint64(o.Offset)}]
+		if !ok {
+			changes = append(changes, Change{Kind: Removed, Old: o, Problem: "its storage would be read as something else"})
+			continue
+		}
+		kept[j] = true
+		n := &new[j]
+		switch {
+		case !compatible(o.Type, n.Type) || o.Size != n.Size:
+			changes = append(changes, Change{Kind: Retyped, Old: o, New: n, Problem: "the type changed"})
+		case o.Name != n.Name:
+			changes = append(changes, Change{Kind: Renamed, Old: o, New: n})
+		}
+	}
+	for j := range new {
+		if kept[j] {
+			continue
+		}
+		n := &new[j]
+		c := Change{Kind: Added, New: n}
+		if n.Slot*32+uint64(n.Offset) < end {
+			c.Problem = "it is among the old variables, not after them"
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// compatible reports whether values of type a can be read as type b. Contracts are stored as
+// their addresses.
+func compatible(a, b string) bool {
+	address := func(t string) bool {
+		return t == "address" || t == "address payable" || strings.HasPrefix(t, "contract ")
+	}
+	return a == b || (address(a) && address(b))
+}
+
+// Diff lists how the layout of a contract changed between two revisions of it, matching
+// variables by the contract that declares them and their name rather than by where they are
+// stored, so that a variable moved by one inserted before it is reported as moved, not as the
+// type change that Compare sees. A variable that only one layout has is reported as renamed if
+// the other has a variable of the same type at its place, and otherwise as added or removed. The
+// members of the structs are compared the same way. Diff only describes the changes: whether a
+// proxy can take the new layout over is for Compare to say.
+func Diff(old, new *Layout) []Change {
+	changes := diff("", old.Variables, new.Variables)
+	var names []string
+	for name := range old.Structs {
+		names = append(names, name)
+	}
+	for name := range new.Structs {
+		if _, ok := old.Structs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		changes = append(changes, diff(name, old.Structs[name], new.Structs[name])...)
+	}
+	return changes
+}
+
+func diff(structName string, old, new []Variable) []Change {
+	key := func(v Variable) string { return v.Contract + "." + v.Name }
+	byKey := make(map[string]int)
+	for j, v := range new {
+		byKey[key(v)] = j
+	}
+	// The change to each old variable, if any, to list in their order.
+	changed := make([]*Change, len(old))
+	matched := make(map[int]bool)
+	for i := range old {
+		o := &old[i]
+		j, ok := byKey[key(*o)]
+		if !ok {
+			continue
+		}
+		matched[j] = true
+		n := &new[j]
+		switch {
+		case o.Slot != n.Slot || o.Offset != n.Offset:
+			changed[i] = &Change{Kind: Moved, Struct: structName, Old: o, New: n}
+		case o.Type != n.Type || o.Size != n.Size:
+			changed[i] = &Change{Kind: Retyped, Struct: structName, Old: o, New: n}
+		}
+	}
+
+	// The old variables left were renamed, if a new one of the same type took their place, or
+	// else removed.
+	at := make(map[[2]uint64]int)
+	for j, v := range new {
+		if !matched[j] {
+			at[[2]uint64{v.Slot, uint64(v.Offset)}] = j
+		}
+	}
+	for i := range old {
+		o := &old[i]
+		if _, ok := byKey[key(*o)]; ok {
+			continue
+		}
+		j, ok := at[[2]uint64{o.Slot, uint64(o.Offset)}]
+		if ok && !matched[j] && new[j].Type == o.Type && new[j].Size == o.Size {
+			matched[j] = true
+			changed[i] = &Change{Kind: Renamed, Struct: structName, Old: o, New: &new[j]}
+			continue
+		}
+		changed[i] = &Change{Kind: Removed, Struct: structName, Old: o}
+	}
+
+	var changes []Change
+	for _, c := range changed {
+		if c != nil {
+			changes = append(changes, *c)
+		}
+	}
+	for j := range new {
+		if !matched[j] {
+			changes = append(changes, Change{Kind: Added, Struct: structName, New: &new[j]})
+		}
+	}
+	return changes
+}
+
+// LoadAll computes the layout of every contract that has an artifact in dir, by name.
+func LoadAll(dir string) (map[string]*Layout, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "listing artifacts")
+	}
+	if len(paths) == 0 {
+		return nil, errors.Errorf("no artifacts in %v (has `make json` been run?)", dir)
+	}
+	layouts := make(map[string]*Layout)
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		l, err := Load(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		layouts[name] = l
+	}
+	return layouts, nil
+}
+
+// Marshal returns the layout as WriteDir writes it: indented JSON, ending in a newline.
+func (l *Layout) Marshal() ([]byte, error) {
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return nil, errors.Wrapf(err, "encoding the layout of %v", l.Contract)
+	}
+	return append(b, '\n'), nil
+}
+
+// Parse parses a layout, as Marshal writes it.
+func Parse(b []byte) (*Layout, error) {
+	var l Layout
+	if err := json.Unmarshal(b, &l); err != nil {
+		return nil, errors.Wrap(err, "parsing the layout")
+	}
+	if l.Contract == "" {
+		return nil, errors.New("the layout names no contract")
+	}
+	return &l, nil
+}
+
+// ReadFile reads a layout written by WriteDir.
+func ReadFile(path string) (*Layout, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the layout")
+	}
+	l, err := Parse(b)
+	return l, errors.Wrap(err, path)
+}
+
+// ReadDir reads the layouts that WriteDir wrote to dir, by contract.
+func ReadDir(dir string) (map[string]*Layout, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "listing layouts")
+	}
+	layouts := make(map[string]*Layout)
+	for _, path := range paths {
+		l, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		layouts[l.Contract] = l
+	}
+	return layouts, nil
+}
+
+// WriteDir writes each layout to dir, as <contract>.json, and removes the other JSON files
+// there, so that dir holds exactly these layouts.
+func WriteDir(dir string, layouts map[string]*Layout) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "creating the layouts directory")
+	}
+	stale, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return errors.Wrap(err, "listing layouts")
+	}
+	for _, path := range stale {
+		if _, ok := layouts[strings.TrimSuffix(filepath.Base(path), ".json")]; !ok {
+			if err := os.Remove(path); err != nil {
+				return errors.Wrap(err, "removing a stale layout")
+			}
+		}
+	}
+	for name, l := range layouts {
+		b, err := l.Marshal()
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".json"), b, 0644); err != nil {
+			return errors.Wrapf(err, "writing the layout of %v", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,367 @@
+package layout
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// AST builders, in solc's legacy format.
+
+func newNode(id int, name string, attrs map[string]interface{}, children ...*node) *node {
+	b, err := json.Marshal(attrs)
+	if err != nil {
+		panic(err)
+	}
+	return &node{ID: id, Name: name, Attributes: b, Children: children}
+}
+
+func contractNode(id int, name string, linearized []int, children ...*node) *node {
+	return newNode(id, "ContractDefinition", map[string]interface{}{
+		"name": name, "linearizedBaseContracts": linearized, "contractKind": "contract",
+	}, children...)
+}
+
+func stateVar(name, t string) *node {
+	return newNode(0, "VariableDeclaration", map[string]interface{}{
+		"name": name, "type": t, "stateVariable": true, "constant": false,
+	})
+}
+
+func constantVar(name, t string) *node {
+	return newNode(0, "VariableDeclaration", map[string]interface{}{
+		"name": name, "type": t, "stateVariable": true, "constant": true,
+	})
+}
+
+func member(name, t string) *node {
+	return newNode(0, "VariableDeclaration", map[string]interface{}{
+		"name": name, "type": t, "stateVariable": false, "constant": false,
+	})
+}
+
+func structNode(canonicalName string, members ...*node) *node {
+	return newNode(0, "StructDefinition", map[string]interface{}{
+		"name": canonicalName, "canonicalName": canonicalName,
+	}, members...)
+}
+
+func function(name string) *node {
+	return newNode(0, "FunctionDefinition", map[string]interface{}{"name": name, "constant": true})
+}
+
+func source(children ...*node) *node {
+	return newNode(0, "SourceUnit", map[string]interface{}{}, children...)
+}
+
+// positions returns "name slot offset size" for each variable.
+func positions(vars []Variable) [][4]interface{} {
+	var got [][4]interface{}
+	for _, v := range vars {
+		got = append(got, [4]interface{}{v.Name, v.Slot, v.Offset, v.Size})
+	}
+	return got
+}
+
+func TestPacking(t *testing.T) {
+	l, err := FromAST([]*node{source(contractNode(1, "C", []int{1},
+		stateVar("a", "address"),
+		stateVar("b", "bool"),
+		constantVar("k", "uint256"),
+		function("f"),
+		stateVar("c", "uint256"),
+		stateVar("d", "uint8"),
+		stateVar("e", "int16"),
+		stateVar("m", "mapping(address => uint256)"),
+		stateVar("f", "bool"),
+		stateVar("g", "bytes32"),
+		stateVar("h", "uint256[3]"),
+		stateVar("i", "uint128[3]"),
+		stateVar("j", "address[]"),
+		stateVar("s", "string"),
+		stateVar("x", "contract IERC20"),
+		stateVar("y", "bytes4"),
+	))}, "C")
+	require.NoError(t, err)
+	assert.Equal(t, [][4]interface{}{
+		{"a", uint64(0), 0, 20},
+		{"b", uint64(0), 20, 1},
+		{"c", uint64(1), 0, 32},
+		{"d", uint64(2), 0, 1},
+		{"e", uint64(2), 1, 2},
+		{"m", uint64(3), 0, 32},
+		{"f", uint64(4), 0, 1},
+		{"g", uint64(5), 0, 32},
+		{"h", uint64(6), 0, 96},
+		{"i", uint64(9), 0, 64},
+		{"j", uint64(11), 0, 32},
+		{"s", uint64(12), 0, 32},
+		{"x", uint64(13), 0, 20},
+		{"y", uint64(13), 20, 4},
+	}, positions(l.Variables))
+}
+
+func TestInheritance(t *testing.T) {
+	// Base's variables come first, even from another source.
+	l, err := FromAST([]*node{
+		source(contractNode(2, "Derived", []int{2, 1}, stateVar("paused", "bool"))),
+		source(contractNode(1, "Base", []int{1}, stateVar("owner", "address"), stateVar("period", "uint256"))),
+	}, "Derived")
+	require.NoError(t, err)
+	assert.Equal(t, [][4]interface{}{
+		{"owner", uint64(0), 0, 20},
+		{"period", uint64(1), 0, 32},
+		{"paused", uint64(2), 0, 1},
+	}, positions(l.Variables))
+	assert.Equal(t, "Base", l.Variables[0].Contract)
+	assert.Equal(t, "Derived", l.Variables[2].Contract)
+
+	_, err = FromAST([]*node{source(contractNode(2, "Derived", []int{2, 1}))}, "Derived")
+	assert.Error(t, err)
+	_, err = FromAST([]*node{source(contractNode(2, "Derived", []int{2}))}, "Missing")
+	assert.Error(t, err)
+}
+
+func TestStructs(t *testing.T) {
+	l, err := FromAST([]*node{source(contractNode(1, "C", []int{1},
+		structNode("C.S", member("a", "address"), member("b", "uint96"), member("c", "uint256")),
+		structNode("C.Node", member("value", "uint256"), member("next", "mapping(uint256 => struct C.Node storage ref)")),
+		stateVar("flag", "bool"),
+		stateVar("s", "struct C.S storage ref"),
+		stateVar("after", "bool"),
+		stateVar("byID", "mapping(uint256 => struct C.Node storage ref)"),
+	))}, "C")
+	require.NoError(t, err)
+	assert.Equal(t, [][4]interface{}{
+		{"flag", uint64(0), 0, 1},
+		{"s", uint64(1), 0, 64},
+		{"after", uint64(3), 0, 1},
+		{"byID", uint64(4), 0, 32},
+	}, positions(l.Variables))
+	assert.Equal(t, "struct C.S", l.Variables[1].Type)
+	assert.Equal(t, [][4]interface{}{
+		{"a", uint64(0), 0, 20},
+		{"b", uint64(0), 20, 12},
+		{"c", uint64(1), 0, 32},
+	}, positions(l.Structs["C.S"]))
+	// A struct used only as a mapping's values is laid out too, even one that maps to itself.
+	assert.Equal(t, [][4]interface{}{
+		{"value", uint64(0), 0, 32},
+		{"next", uint64(1), 0, 32},
+	}, positions(l.Structs["C.Node"]))
+
+	_, err = FromAST([]*node{source(contractNode(1, "C", []int{1}, stateVar("s", "struct D.S storage ref")))}, "C")
+	assert.Error(t, err)
+	_, err = FromAST([]*node{source(contractNode(1, "C", []int{1}, stateVar("q", "fixed128x18")))}, "C")
+	assert.Error(t, err)
+}
+
+// layoutOf lays out a contract C with the given state variables, each a name and a type.
+func layoutOf(t *testing.T, vars ...string) *Layout {
+	var children []*node
+	for i := 0; i < len(vars); i += 2 {
+		children = append(children, stateVar(vars[i], vars[i+1]))
+	}
+	l, err := FromAST([]*node{source(contractNode(1, "C", []int{1}, children...))}, "C")
+	require.NoError(t, err)
+	return l
+}
+
+func problems(changes []Change) []string {
+	var got []string
+	for _, c := range Incompatible(changes) {
+		got = append(got, c.String())
+	}
+	return got
+}
+
+func TestCompare(t *testing.T) {
+	old := layoutOf(t, "owner", "address", "paused", "bool", "data", "contract Storage", "supply", "uint256")
+
+	// Appending is safe, as is a contract stored as a plain address.
+	changes := Compare(old, layoutOf(t,
+		"owner", "address", "paused", "bool", "data", "address", "supply", "uint256", "cap", "uint256"))
+	assert.Empty(t, problems(changes))
+	require.Len(t, changes, 1)
+	assert.Equal(t, "added slot 3 offset 0: uint256 cap (C)", changes[0].String())
+
+	// So is renaming, which is reported.
+	changes = Compare(old, layoutOf(t, "admin", "address", "paused", "bool", "data", "contract Storage", "supply", "uint256"))
+	assert.Empty(t, problems(changes))
+	require.Len(t, changes, 1)
+	assert.Equal(t, "renamed slot 0 offset 0: address owner (C) to admin", changes[0].String())
+
+	// A changed type is not, even of the same size.
+	assert.Equal(t, []string{
+		"slot 2 offset 0: uint256 supply (C) is now slot 2 offset 0: int256 supply (C): the type changed",
+	}, problems(Compare(old, layoutOf(t, "owner", "address", "paused", "bool", "data", "contract Storage", "supply", "int256"))))
+
+	// Nor is inserting a variable, which moves everything after it.
+	assert.Equal(t, []string{
+		"slot 1 offset 0: contract Storage data (C) is now slot 1 offset 0: uint256 cap (C): the type changed",
+		"slot 2 offset 0: uint256 supply (C) is now slot 2 offset 0: contract Storage data (C): the type changed",
+	}, problems(Compare(old, layoutOf(t,
+		"owner", "address", "paused", "bool", "cap", "uint256", "data", "contract Storage", "supply", "uint256"))))
+
+	// Nor squeezing one into the old variables' padding.
+	assert.Equal(t, []string{
+		"added slot 0 offset 21: bool frozen (C): it is among the old variables, not after them",
+	}, problems(Compare(old, layoutOf(t,
+		"owner", "address", "paused", "bool", "frozen", "bool", "data", "contract Storage", "supply", "uint256"))))
+
+	// Nor removing one.
+	assert.Equal(t, []string{
+		"removed slot 2 offset 0: uint256 supply (C): its storage would be read as something else",
+	}, problems(Compare(old, layoutOf(t, "owner", "address", "paused", "bool", "data", "contract Storage"))))
+}
+
+func TestCompareStructs(t *testing.T) {
+	layout := func(members ...*node) *Layout {
+		l, err := FromAST([]*node{source(contractNode(1, "C", []int{1},
+			structNode("C.S", members...),
+			stateVar("byID", "mapping(uint256 => struct C.S storage ref)"),
+		))}, "C")
+		require.NoError(t, err)
+		return l
+	}
+	old := layout(member("a", "address"), member("b", "uint256"))
+
+	// A struct kept only in a mapping can grow, but its members can't move, or be packed
+	// among the old ones.
+	assert.Empty(t, problems(Compare(old, layout(member("a", "address"), member("b", "uint256"), member("c", "bool")))))
+	assert.Equal(t, []string{
+		"added slot 0 offset 20: bool c (): in struct C.S, it is among the old variables, not after them",
+	}, problems(Compare(old, layout(member("a", "address"), member("c", "bool"), member("b", "uint256")))))
+	assert.Equal(t, []string{
+		"slot 1 offset 0: uint256 b () is now slot 1 offset 0: bytes32 b (): in struct C.S, the type changed",
+	}, problems(Compare(old, layout(member("a", "address"), member("b", "bytes32")))))
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "layout")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(name string, combined interface{}) {
+		b, err := json.Marshal(combined)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+".json"), b, 0644))
+	}
+	write("C", map[string]interface{}{
+		"contracts": map[string]interface{}{"contracts/C.sol:C": map[string]string{"abi": "[]"}},
+		"sources": map[string]interface{}{
+			"contracts/C.sol": map[string]interface{}{
+				"AST": source(contractNode(1, "C", []int{1}, stateVar("owner", "address"))),
+			},
+		},
+	})
+	l, err := Load(dir, "C")
+	require.NoError(t, err)
+	assert.Equal(t, "C", l.Contract)
+	assert.Equal(t, [][4]interface{}{{"owner", uint64(0), 0, 20}}, positions(l.Variables))
+
+	// An artifact built without the AST can't be laid out.
+	write("D", map[string]interface{}{
+		"contracts": map[string]interface{}{"contracts/D.sol:D": map[string]string{"abi": "[]"}},
+		"sources":   map[string]interface{}{"contracts/D.sol": map[string]interface{}{}},
+	})
+	_, err = Load(dir, "D")
+	assert.Error(t, err)
+	_, err = Load(dir, "E")
+	assert.Error(t, err)
+}
+
+func diffs(changes []Change) []string {
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Kind+": "+c.String())
+	}
+	return got
+}
+
+func TestDiff(t *testing.T) {
+	old := layoutOf(t, "owner", "address", "paused", "bool", "data", "contract Storage", "supply", "uint256")
+	assert.Empty(t, Diff(old, old))
+
+	// Inserting a variable moves the ones after it, where Compare sees their types change.
+	assert.Equal(t, []string{
+		"moved: moved slot 1 offset 0: contract Storage data (C) to slot 2 offset 0",
+		"moved: moved slot 2 offset 0: uint256 supply (C) to slot 3 offset 0",
+		"added: added slot 1 offset 0: uint256 cap (C)",
+	}, diffs(Diff(old, layoutOf(t,
+		"owner", "address", "paused", "bool", "cap", "uint256", "data", "contract Storage", "supply", "uint256"))))
+
+	// A renamed variable keeps its place and type; one retyped keeps its name.
+	assert.Equal(t, []string{
+		"renamed: renamed slot 0 offset 0: address owner (C) to admin",
+		"retyped: slot 2 offset 0: uint256 supply (C) is now slot 2 offset 0: int256 supply (C)",
+	}, diffs(Diff(old, layoutOf(t, "admin", "address", "paused", "bool", "data", "contract Storage", "supply", "int256"))))
+
+	// Without a variable of the same type in its place, a variable is removed, not renamed.
+	assert.Equal(t, []string{
+		"removed: removed slot 1 offset 0: contract Storage data (C)",
+		"moved: moved slot 2 offset 0: uint256 supply (C) to slot 1 offset 0",
+		"added: added slot 2 offset 0: bytes32 root (C)",
+	}, diffs(Diff(old, layoutOf(t, "owner", "address", "paused", "bool", "supply", "uint256", "root", "bytes32"))))
+}
+
+func TestDiffStructs(t *testing.T) {
+	layout := func(structs ...*node) *Layout {
+		children := append(structs, stateVar("byID", "mapping(uint256 => struct C.S storage ref)"))
+		l, err := FromAST([]*node{source(contractNode(1, "C", []int{1}, children...))}, "C")
+		require.NoError(t, err)
+		return l
+	}
+	old := layout(structNode("C.S", member("a", "address"), member("b", "uint256")))
+	assert.Equal(t, []string{
+		"moved: in struct C.S, moved slot 0 offset 0: address a () to slot 1 offset 0, as address payable",
+		"moved: in struct C.S, moved slot 1 offset 0: uint256 b () to slot 0 offset 0",
+	}, diffs(Diff(old, layout(structNode("C.S", member("b", "uint256"), member("a", "address payable"))))))
+
+	// A struct that the new layout uses, and the old one doesn't, has all of its members added.
+	l, err := FromAST([]*node{source(contractNode(1, "C", []int{1},
+		structNode("C.S", member("a", "address"), member("b", "uint256")),
+		structNode("C.T", member("x", "bool")),
+		stateVar("byID", "mapping(uint256 => struct C.S storage ref)"),
+		stateVar("t", "struct C.T storage ref"),
+	))}, "C")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"added: added slot 1 offset 0: struct C.T t (C)",
+		"added: in struct C.T, added slot 0 offset 0: bool x ()",
+	}, diffs(Diff(old, l)))
+}
+
+func TestLayoutFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "layouts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := layoutOf(t, "owner", "address", "paused", "bool")
+	d := &Layout{Contract: "D", Variables: []Variable{{Contract: "D", Name: "x", Type: "uint256", Size: 32}}}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Gone.json"), []byte(`{"contract": "Gone"}`), 0644))
+	require.NoError(t, WriteDir(dir, map[string]*Layout{"C": c, "D": d}))
+
+	layouts, err := ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, layouts, 2, "the stale layout is removed")
+	assert.Equal(t, c.Variables, layouts["C"].Variables)
+	assert.Empty(t, Diff(c, layouts["C"]))
+	assert.Empty(t, Compare(d, layouts["D"]))
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "C.json"))
+	require.NoError(t, err)
+	want, err := c.Marshal()
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(b))
+
+	_, err = Parse([]byte(`{"variables": []}`))
+	assert.Error(t, err)
+	_, err = ReadFile(filepath.Join(dir, "Missing.json"))
+	assert.Error(t, err)
+}
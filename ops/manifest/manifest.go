@@ -0,0 +1,96 @@
+// Package manifest reads and writes deployment manifests: the record of which contract lives at
+// which address on a given network.
+package manifest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+// Manifest describes one deployment of the RSV system.
+type Manifest struct {
+	Network string `json:"network"`
+	ChainID uint64 `json:"chainId"`
+
+	// Contracts maps contract names (as in the Makefile, e.g. "Reserve" or "Manager") to the
+	// address of the live instance of that contract.
+	Contracts map[string]common.Address `json:"contracts"`
+
+	// Names maps contract names to the ENS names that point at them.
+	Names map[string]string `json:"names,omitempty"`
+}
+
+// Load reads the manifest at path.
+func Load(path string) (*Manifest, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading manifest")
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, errors.Wrapf(err, "parsing manifest %v", path)
+	}
+	if m.Contracts == nil {
+		m.Contracts = make(map[string]common.Address)
+	}
+	if m.Names == nil {
+		m.Names = make(map[string]string)
+	}
+	return &m, nil
+}
+
+// Save writes the manifest to path.
+func (m *Manifest) Save(path string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding manifest")
+	}
+	return errors.Wrap(ioutil.WriteFile(path, append(b, '\n'), 0644), "writing manifest")
+}
+
+// Address returns the address of the contract called name.
+func (m *Manifest) Address(name string) (common.Address, error) {
+	addr, ok := m.Contracts[name]
+	if !ok || addr == (common.Address{}) {
+		return common.Address{}, errors.Errorf("manifest for %v has no %v address", m.Network, name)
+	}
+	return addr, nil
+}
+
+// NameOf returns the name of the contract at addr, if the manifest has it. If several names
+// share the address, the first in sorted order is returned.
+func (m *Manifest) NameOf(addr common.Address) (string, bool) {
+	names := make([]string, 0, len(m.Contracts))
+	for name, a := range m.Contracts {
+		if a == addr {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "", false
+	}
+	sort.Strings(names)
+	return names[0], true
+}
+
+// Resolve replaces "@Name" references in args with the addresses of the named contracts.
+func (m *Manifest) Resolve(args []string) ([]string, error) {
+	result := make([]string, len(args))
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "@") {
+			result[i] = arg
+			continue
+		}
+		addr, err := m.Address(arg[1:])
+		if err != nil {
+			return nil, err
+		}
+		result[i] = addr.Hex()
+	}
+	return result, nil
+}
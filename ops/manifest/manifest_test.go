@@ -0,0 +1,25 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	vault := common.HexToAddress("0xAeDCFcdD80573c2a312d15d6Bb9d921a01E4FB0f")
+	m := &Manifest{Network: "test", Contracts: map[string]common.Address{"Vault": vault}}
+
+	args, err := m.Resolve([]string{"@Vault", "17", "0x01"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{vault.Hex(), "17", "0x01"}, args)
+
+	_, err = m.Resolve([]string{"@Basket"})
+	assert.Error(t, err)
+
+	name, ok := m.NameOf(vault)
+	assert.True(t, ok)
+	assert.Equal(t, "Vault", name)
+}
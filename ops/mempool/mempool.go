@@ -0,0 +1,544 @@
+// Package mempool watches the node's pending transactions for privileged calls to the
+// deployment's contracts, and posts an alert for each one whose sender is not expected to make
+// it, before the call is mined.
+//
+// Only calls made directly to the contracts can be seen: a call made through another contract,
+// such as a multisig wallet, a timelock, or a GSN relay hub, is the inner call of a transaction
+// to that contract, and shows up only once it is mined, to rsvwatch.
+package mempool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+)
+
+// Permissions are the privileged methods of each contract, with the roles that may call them,
+// as the contracts' modifiers allow. Each role is also the name of the view that returns its
+// holder, unless it is in RoleSets.
+var Permissions = map[string]map[string][]string{
+	"Reserve": {
+		"changeMinter":             {"owner", "minter"},
+		"changePauser":             {"owner", "pauser"},
+		"changeGuardian":           {"owner"},
+		"changeFreezer":            {"owner", "freezer"},
+		"changeWiper":              {"owner", "wiper"},
+		"grantRole":                {"owner", "minter", "pauser", "freezer", "wiper", "feeRecipient", "snapshotter"},
+		"revokeRole":               {"owner", "minter", "pauser", "freezer", "wiper", "feeRecipient", "snapshotter"},
+		"renounceRole":             {"minter", "pauser", "guardian", "freezer", "wiper", "feeRecipient", "snapshotter"},
+		"changeFeeRecipient":       {"owner", "feeRecipient"},
+		"changeSnapshotter":        {"owner", "snapshotter"},
+		"transferEternalStorage":   {"owner"},
+		"changeRelayer":            {"owner"},
+		"changeForwarder":          {"owner"},
+		"changeTransferHook":       {"owner"},
+		"changeTxFeeHelper":        {"owner"},
+		"changeMaxSupply":          {"owner"},
+		"changeMintCap":            {"owner"},
+		"changeFlashMintCap":       {"owner"},
+		"changeFlashMintFee":       {"owner"},
+		"changeChainId":            {"owner"},
+		"sweep":                    {"owner"},
+		"acceptUpgrade":            {"owner"},
+		"upgradeTo":                {"owner"},
+		"upgradeToAndCall":         {"owner"},
+		"pause":                    {"pauser", "guardian"},
+		"unpause":                  {"pauser"},
+		"pauseTransfers":           {"pauser", "guardian"},
+		"unpauseTransfers":         {"pauser"},
+		"startEmergencyRedemption": {"owner"},
+		"freeze":                   {"freezer"},
+		"unfreeze":                 {"freezer"},
+		"proposeWipe":              {"wiper"},
+		"cancelWipe":               {"owner", "wiper"},
+		"wipe":                     {"wiper"},
+		"snapshot":                 {"snapshotter"},
+		"mint":                     {"minter"},
+		"burnFrom":                 {"minter"},
+		"emergencyBurnFrom":        {"minter"},
+		"relayTransfer":            {"trustedRelayer"},
+		"relayApprove":             {"trustedRelayer"},
+		"relayTransferFrom":        {"trustedRelayer"},
+		"nominateNewOwner":         {"owner"},
+		"changeNominationPeriod":   {"owner"},
+		"renounceOwnership":        {"owner"},
+		"acceptOwnership":          {"nominatedOwner"},
+	},
+	"Manager": {
+		"setIssuancePaused":         {"operator"},
+		"setRedemptionPaused":       {"operator"},
+		"setEmergency":              {"operator"},
+		"clearProposals":            {"operator"},
+		"acceptProposal":            {"operator"},
+		"executeProposal":           {"operator"},
+		"vetoProposal":              {"vetoer"},
+		"setVault":                  {"owner"},
+		"addOperator":               {"owner"},
+		"removeOperator":            {"owner"},
+		"setVetoer":                 {"owner"},
+		"setSeigniorage":            {"owner"},
+		"setRedemptionFee":          {"owner"},
+		"setRedemptionFeeRecipient": {"owner"},
+		"setIssuanceFee":            {"owner"},
+		"setIssuanceFeeRecipient":   {"owner"},
+		"setOracle":                 {"owner"},
+		"setAuction":                {"owner"},
+		"setRegistry":               {"owner"},
+		"setPermit2":                {"owner"},
+		"setExposureCap":            {"owner"},
+		"startAuction":              {"owner"},
+		"cancelAuction":             {"operator"},
+		"setDelay":                  {"owner"},
+		"setProposalValidity":       {"owner"},
+		"sweep":                     {"owner"},
+		"sweepVault":                {"owner"},
+		"nominateNewOwner":          {"owner"},
+		"changeNominationPeriod":    {"owner"},
+		"renounceOwnership":         {"owner"},
+		"acceptOwnership":           {"nominatedOwner"},
+	},
+	"Vault": {
+		"changeManager":          {"owner"},
+		"withdrawTo":             {"manager"},
+		"nominateNewOwner":       {"owner"},
+		"changeNominationPeriod": {"owner"},
+		"renounceOwnership":      {"owner"},
+		"acceptOwnership":        {"nominatedOwner"},
+	},
+	"YieldVault": {
+		"changeManager":          {"owner"},
+		"withdrawTo":             {"manager"},
+		"setYieldSource":         {"owner"},
+		"deposit":                {"owner"},
+		"withdraw":               {"owner"},
+		"nominateNewOwner":       {"owner"},
+		"changeNominationPeriod": {"owner"},
+		"renounceOwnership":      {"owner"},
+		"acceptOwnership":        {"nominatedOwner"},
+	},
+	"Timelock": {
+		"queueTransaction":   {"admin"},
+		"cancelTransaction":  {"admin"},
+		"executeTransaction": {"admin", "keeper"},
+		"acceptAdmin":        {"pendingAdmin"},
+	},
+	"CollateralOracle": {
+		"setFeed":                {"owner"},
+		"setMaxDeviation":        {"owner"},
+		"setExchangeRateSource":  {"owner"},
+		"nominateNewOwner":       {"owner"},
+		"changeNominationPeriod": {"owner"},
+		"renounceOwnership":      {"owner"},
+		"acceptOwnership":        {"nominatedOwner"},
+	},
+	"CollateralRegistry": {
+		"approve":                {"owner"},
+		"remove":                 {"owner"},
+		"nominateNewOwner":       {"owner"},
+		"changeNominationPeriod": {"owner"},
+		"renounceOwnership":      {"owner"},
+		"acceptOwnership":        {"nominatedOwner"},
+	},
+	"DutchAuction": {
+		"start": {"manager"},
+	},
+	"InsuranceFund": {
+		"setManager":             {"owner"},
+		"topUp":                  {"owner"},
+		"nominateNewOwner":       {"owner"},
+		"changeNominationPeriod": {"owner"},
+		"renounceOwnership":      {"owner"},
+		"acceptOwnership":        {"nominatedOwner"},
+	},
+	"FeeTreasury": {
+		"setTargets":              {"owner"},
+		"setDistributionInterval": {"owner"},
+		"nominateNewOwner":        {"owner"},
+		"changeNominationPeriod":  {"owner"},
+		"renounceOwnership":       {"owner"},
+		"acceptOwnership":         {"nominatedOwner"},
+	},
+	"Relayer": {
+		"setRSV":                 {"owner"},
+		"nominateNewOwner":       {"owner"},
+		"changeNominationPeriod": {"owner"},
+		"renounceOwnership":      {"owner"},
+		"acceptOwnership":        {"nominatedOwner"},
+	},
+	"BridgeAdapter": {
+		"changeBridge":           {"owner"},
+		"changeMintLimit":        {"owner"},
+		"changeBurnLimit":        {"owner"},
+		"mint":                   {"bridge"},
+		"burn":                   {"bridge"},
+		"nominateNewOwner":       {"owner"},
+		"changeNominationPeriod": {"owner"},
+		"renounceOwnership":      {"owner"},
+		"acceptOwnership":        {"nominatedOwner"},
+	},
+	"OFTAdapter": {
+		"setTrustedRemoteAddress": {"owner"},
+		"nominateNewOwner":        {"owner"},
+		"changeNominationPeriod":  {"owner"},
+		"renounceOwnership":       {"owner"},
+		"acceptOwnership":         {"nominatedOwner"},
+	},
+}
+
+// RoleSets are the roles that many accounts hold at once, with the views that return how many
+// hold each one and the holder at an index.
+var RoleSets = map[string]map[string]RoleSet{
+	"Manager": {"operator": {Length: "operatorsLength", Holder: "operators"}},
+}
+
+// RoleSet names the views of a role that many accounts hold at once.
+type RoleSet struct {
+	Length, Holder string
+}
+
+// Node is the part of a node the watcher reads pending transactions from.
+type Node interface {
+	TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error)
+}
+
+// Watcher posts an alert for each pending privileged call from an unexpected sender.
+type Watcher struct {
+	Node Node
+
+	// Pending sends the hashes of new pending transactions until ctx is done or it fails, as
+	// Pending does.
+	Pending func(ctx context.Context, hashes chan<- common.Hash) error
+
+	// Signer recovers the senders of transactions, e.g. types.NewEIP155Signer(chainID).
+	Signer types.Signer
+
+	Contracts []*chain.Contract
+
+	// Roles returns the current holders of each role, keyed as "Reserve.owner", such as Roles
+	// does.
+	Roles func(ctx context.Context) (map[string][]common.Address, error)
+
+	// Expected, if set for a call, keyed as "Reserve.mint", lists its expected senders, in place
+	// of the holders of the roles that may make it.
+	Expected map[string][]common.Address
+
+	// Post delivers a message, such as emergency.Notifier.Notify.
+	Post func(ctx context.Context, text string) error
+
+	// Label renders an address for the messages, such as ens.Namer.Label; by default, in hex.
+	Label func(ctx context.Context, addr common.Address) string
+
+	Network string
+
+	// RolesInterval is how often to read the role holders again.
+	RolesInterval time.Duration
+
+	mu      sync.Mutex
+	roles   map[string][]common.Address
+	rolesAt time.Time
+	alerted map[common.Hash]bool
+}
+
+// workers is how many pending transactions are fetched at once. The pending transactions of a
+// busy chain arrive faster than they can be fetched one by one.
+const workers = 8
+
+// forget is how many alerted transactions are remembered, so that a transaction seen twice is
+// posted once.
+const forget = 10000
+
+// Run watches until ctx is done. If the pending transactions fail, as when the node restarts, it
+// logs the error and starts them again.
+func (w *Watcher) Run(ctx context.Context) error {
+	hashes := make(chan common.Hash, 4096)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for hash := range hashes {
+				if err := w.Handle(ctx, hash); err != nil && ctx.Err() == nil {
+					log.Printf("mempool: %v", err)
+				}
+			}
+		}()
+	}
+	defer close(hashes)
+	for {
+		err := w.Pending(ctx, hashes)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("mempool: %v; starting again", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// Handle checks the pending transaction with hash, posting an alert if it is a privileged call
+// from an unexpected sender.
+func (w *Watcher) Handle(ctx context.Context, hash common.Hash) error {
+	tx, pending, err := w.Node.TransactionByHash(ctx, hash)
+	if err != nil {
+		if err == ethereum.NotFound {
+			// Already dropped or replaced.
+			return nil
+		}
+		return errors.Wrapf(err, "reading tx %v", hash.Hex())
+	}
+	if !pending || tx.To() == nil {
+		return nil
+	}
+	var contract *chain.Contract
+	for _, c := range w.Contracts {
+		if c.Address == *tx.To() {
+			contract = c
+		}
+	}
+	if contract == nil || len(tx.Data()) < 4 {
+		return nil
+	}
+	method, err := contract.ABI.MethodById(tx.Data()[:4])
+	if err != nil {
+		return nil
+	}
+	roles, ok := Permissions[contract.Name][method.Name]
+	if !ok {
+		return nil
+	}
+	from, err := types.Sender(w.Signer, tx)
+	if err != nil {
+		return errors.Wrapf(err, "recovering the sender of tx %v", hash.Hex())
+	}
+
+	expected, err := w.expected(ctx, contract.Name, method.Name, roles)
+	if err != nil {
+		return err
+	}
+	for _, e := range expected {
+		if e.addr == from {
+			return nil
+		}
+	}
+
+	w.mu.Lock()
+	if w.alerted == nil || len(w.alerted) >= forget {
+		w.alerted = map[common.Hash]bool{}
+	}
+	seen := w.alerted[hash]
+	w.alerted[hash] = true
+	w.mu.Unlock()
+	if seen {
+		return nil
+	}
+
+	call := method.Name + "(?)"
+	if values, err := method.Inputs.UnpackValues(tx.Data()[4:]); err == nil {
+		call = method.Name + w.format(ctx, values)
+	}
+	var want []string
+	for _, e := range expected {
+		want = append(want, e.role+" "+w.label(ctx, e.addr))
+	}
+	if len(want) == 0 {
+		want = []string{strings.Join(roles, " or ") + ", held by no one"}
+	}
+	text := fmt.Sprintf("RSV on %v: PENDING %v.%v from %v, who is not the expected %v (tx %v, not yet mined)",
+		w.Network, contract.Name, call, w.label(ctx, from), strings.Join(want, " or "), hash.Hex())
+	if err := w.Post(ctx, text); err != nil {
+		return errors.Wrapf(err, "posting tx %v", hash.Hex())
+	}
+	return nil
+}
+
+// sender is an expected sender of a call.
+type sender struct {
+	role string // the role it holds, or "sender" if it is configured
+	addr common.Address
+}
+
+// expected returns the expected senders of contract's method, which roles may call.
+func (w *Watcher) expected(ctx context.Context, contract, method string, roles []string) ([]sender, error) {
+	if list, ok := w.Expected[contract+"."+method]; ok {
+		result := make([]sender, len(list))
+		for i, addr := range list {
+			result[i] = sender{"sender", addr}
+		}
+		return result, nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.roles == nil || time.Since(w.rolesAt) >= w.RolesInterval {
+		holders, err := w.Roles(ctx)
+		if err != nil {
+			if w.roles == nil {
+				return nil, err
+			}
+			// Keep the holders last read, rather than alert on every privileged call.
+			log.Printf("mempool: %v", err)
+		} else {
+			w.roles, w.rolesAt = holders, time.Now()
+		}
+	}
+	var result []sender
+	for _, role := range roles {
+		for _, addr := range w.roles[contract+"."+role] {
+			if addr != (common.Address{}) {
+				result = append(result, sender{role, addr})
+			}
+		}
+	}
+	return result, nil
+}
+
+func (w *Watcher) label(ctx context.Context, addr common.Address) string {
+	if w.Label != nil {
+		return w.Label(ctx, addr)
+	}
+	return addr.Hex()
+}
+
+// format formats call arguments as chain.FormatArgs does, with addresses labeled.
+func (w *Watcher) format(ctx context.Context, values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		if addr, ok := v.(common.Address); ok {
+			parts[i] = w.label(ctx, addr)
+		} else {
+			parts[i] = strings.Trim(chain.FormatArgs([]interface{}{v}), "()")
+		}
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// Roles returns a func reading the holders of the roles of Permissions that contracts have: in
+// one batch, and in a second for the holders of the roles of RoleSets.
+func Roles(client *chain.Client, contracts []*chain.Contract) func(ctx context.Context) (map[string][]common.Address, error) {
+	return func(ctx context.Context) (map[string][]common.Address, error) {
+		b := client.NewBatch(nil)
+		results := map[string]*common.Address{}
+		lengths := map[string]*big.Int{}
+		sets := map[string]*chain.Contract{}
+		for _, c := range contracts {
+			for _, roles := range Permissions[c.Name] {
+				for _, role := range roles {
+					key := c.Name + "." + role
+					if results[key] != nil || lengths[key] != nil {
+						continue
+					}
+					if set, ok := RoleSets[c.Name][role]; ok {
+						if _, ok := c.ABI.Methods[set.Length]; !ok {
+							continue
+						}
+						lengths[key], sets[key] = new(big.Int), c
+						if err := b.Add(c, lengths[key], set.Length); err != nil {
+							return nil, err
+						}
+						continue
+					}
+					if _, ok := c.ABI.Methods[role]; !ok {
+						continue
+					}
+					results[key] = new(common.Address)
+					if err := b.Add(c, results[key], role); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		if err := b.Do(ctx); err != nil {
+			return nil, errors.Wrap(err, "reading role holders")
+		}
+		holders := make(map[string][]common.Address, len(results)+len(lengths))
+		for key, addr := range results {
+			holders[key] = []common.Address{*addr}
+		}
+
+		b = client.NewBatch(nil)
+		members := map[string][]*common.Address{}
+		for key, n := range lengths {
+			c := sets[key]
+			set := RoleSets[c.Name][strings.TrimPrefix(key, c.Name+".")]
+			for i := int64(0); i < n.Int64(); i++ {
+				addr := new(common.Address)
+				if err := b.Add(c, addr, set.Holder, big.NewInt(i)); err != nil {
+					return nil, err
+				}
+				members[key] = append(members[key], addr)
+			}
+		}
+		if err := b.Do(ctx); err != nil {
+			return nil, errors.Wrap(err, "reading role holders")
+		}
+		for key, addrs := range members {
+			for _, addr := range addrs {
+				holders[key] = append(holders[key], *addr)
+			}
+		}
+		return holders, nil
+	}
+}
+
+// Pending returns a func sending the hashes of new pending transactions, for Watcher.Pending.
+// It subscribes to them if the connection supports subscriptions, as a websocket or IPC one
+// does, and otherwise polls a pending transaction filter every interval.
+func Pending(client *rpc.Client, interval time.Duration) func(ctx context.Context, hashes chan<- common.Hash) error {
+	return func(ctx context.Context, hashes chan<- common.Hash) error {
+		sub, err := client.EthSubscribe(ctx, hashes, "newPendingTransactions")
+		if err == rpc.ErrNotificationsUnsupported {
+			return poll(ctx, client, interval, hashes)
+		}
+		if err != nil {
+			return errors.Wrap(err, "subscribing to pending transactions")
+		}
+		defer sub.Unsubscribe()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-sub.Err():
+			return errors.Wrap(err, "pending transactions subscription")
+		}
+	}
+}
+
+func poll(ctx context.Context, client *rpc.Client, interval time.Duration, hashes chan<- common.Hash) error {
+	var id string
+	if err := client.CallContext(ctx, &id, "eth_newPendingTransactionFilter"); err != nil {
+		return errors.Wrap(err, "creating a pending transaction filter")
+	}
+	defer client.Call(nil, "eth_uninstallFilter", id)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		var changes []common.Hash
+		if err := client.CallContext(ctx, &changes, "eth_getFilterChanges", id); err != nil {
+			// Most likely the node forgot the filter, which Run will create again.
+			return errors.Wrap(err, "polling the pending transaction filter")
+		}
+		for _, hash := range changes {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case hashes <- hash:
+			}
+		}
+	}
+}
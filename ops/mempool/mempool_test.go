@@ -0,0 +1,120 @@
+package mempool
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"strings"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+)
+
+const reserveABI = `[
+	{"type":"function","name":"mint","stateMutability":"nonpayable","inputs":[
+		{"name":"account","type":"address"},{"name":"value","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"pause","stateMutability":"nonpayable","inputs":[],"outputs":[]},
+	{"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[
+		{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]}]`
+
+var (
+	reserve = common.HexToAddress("0x196f4727526eA7FB1e17b2071B3d8eAA38486988")
+	alice   = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+)
+
+// fakeNode serves the pending transactions it was given.
+type fakeNode map[common.Hash]*types.Transaction
+
+func (n fakeNode) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	tx, ok := n[hash]
+	if !ok {
+		return nil, false, ethereum.NotFound
+	}
+	return tx, true, nil
+}
+
+func TestWatcher(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(reserveABI))
+	require.NoError(t, err)
+	artifact := &chain.Artifact{Name: "Reserve", ABI: parsed}
+	minterKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	attackerKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	otherMinterKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	minter := crypto.PubkeyToAddress(minterKey.PublicKey)
+	attacker := crypto.PubkeyToAddress(attackerKey.PublicKey)
+
+	signer := types.NewEIP155Signer(big.NewInt(1))
+	node := fakeNode{}
+	send := func(nonce uint64, key *ecdsa.PrivateKey, method string, args ...interface{}) common.Hash {
+		data, err := parsed.Pack(method, args...)
+		require.NoError(t, err)
+		tx, err := types.SignTx(types.NewTransaction(nonce, reserve, big.NewInt(0), 100000, big.NewInt(1), data), signer, key)
+		require.NoError(t, err)
+		node[tx.Hash()] = tx
+		return tx.Hash()
+	}
+
+	var posted []string
+	reads := 0
+	w := &Watcher{
+		Node:      node,
+		Signer:    signer,
+		Contracts: []*chain.Contract{artifact.Bind(reserve, nil)},
+		Roles: func(ctx context.Context) (map[string][]common.Address, error) {
+			reads++
+			otherMinter := crypto.PubkeyToAddress(otherMinterKey.PublicKey)
+			return map[string][]common.Address{"Reserve.minter": {minter, otherMinter}}, nil
+		},
+		Post: func(ctx context.Context, text string) error {
+			posted = append(posted, text)
+			return nil
+		},
+		Network:       "testnet",
+		RolesInterval: 1 << 62,
+	}
+	ctx := context.Background()
+
+	// Either minter minting, anyone transferring, and a dropped tx are all fine.
+	for _, hash := range []common.Hash{
+		send(0, minterKey, "mint", alice, big.NewInt(5)),
+		send(0, otherMinterKey, "mint", alice, big.NewInt(5)),
+		send(0, attackerKey, "transfer", alice, big.NewInt(5)),
+		common.HexToHash("0x1234"),
+	} {
+		require.NoError(t, w.Handle(ctx, hash))
+	}
+	assert.Empty(t, posted)
+
+	// Someone else minting is not, and is posted once however often it is seen.
+	mint := send(1, attackerKey, "mint", attacker, big.NewInt(1000))
+	require.NoError(t, w.Handle(ctx, mint))
+	require.NoError(t, w.Handle(ctx, mint))
+	require.Len(t, posted, 1)
+	assert.Contains(t, posted[0], "RSV on testnet: PENDING Reserve.mint("+attacker.Hex()+", 1000) from "+attacker.Hex())
+	assert.Contains(t, posted[0], "not the expected minter "+minter.Hex()+" or minter ")
+	assert.Contains(t, posted[0], mint.Hex())
+
+	// No one holds the pauser or guardian role here, so whoever pauses is unexpected.
+	require.NoError(t, w.Handle(ctx, send(2, minterKey, "pause")))
+	require.Len(t, posted, 2)
+	assert.Contains(t, posted[1], "Reserve.pause() from "+minter.Hex()+", who is not the expected pauser or guardian, held by no one")
+	assert.Equal(t, 1, reads, "role holders are read once per interval")
+
+	// Configured senders take the place of the role holders.
+	w.Expected = map[string][]common.Address{"Reserve.mint": {attacker}}
+	require.NoError(t, w.Handle(ctx, send(3, attackerKey, "mint", attacker, big.NewInt(1))))
+	require.NoError(t, w.Handle(ctx, send(4, minterKey, "mint", alice, big.NewInt(1))))
+	require.Len(t, posted, 3)
+	assert.Contains(t, posted[2], "from "+minter.Hex()+", who is not the expected sender "+attacker.Hex())
+}
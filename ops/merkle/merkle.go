@@ -0,0 +1,75 @@
+// Package merkle builds Merkle trees of keccak256 hashes that Solidity can check proofs
+// against, as with OpenZeppelin's MerkleProof.verify: each parent is the hash of its two
+// children in sorted order, so a proof is just the list of siblings from leaf to root. A node
+// without a sibling moves up a level unchanged.
+package merkle
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tree is a Merkle tree over a list of leaves.
+type Tree struct {
+	// levels[0] are the leaves; the last level is the root alone.
+	levels [][]common.Hash
+}
+
+// New builds the tree over leaves, in the order given.
+func New(leaves []common.Hash) *Tree {
+	level := append([]common.Hash(nil), leaves...)
+	t := &Tree{levels: [][]common.Hash{level}}
+	for len(level) > 1 {
+		next := make([]common.Hash, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, hashPair(level[i], level[i+1]))
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+	return t
+}
+
+func hashPair(a, b common.Hash) common.Hash {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+	return crypto.Keccak256Hash(a[:], b[:])
+}
+
+// Root is the root of the tree; the zero hash for a tree without leaves.
+func (t *Tree) Root() common.Hash {
+	top := t.levels[len(t.levels)-1]
+	if len(top) == 0 {
+		return common.Hash{}
+	}
+	return top[0]
+}
+
+// Proof returns the siblings on the path from leaf i to the root.
+func (t *Tree) Proof(i int) []common.Hash {
+	proof := []common.Hash{}
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := i ^ 1
+		if sibling < len(level) {
+			proof = append(proof, level[sibling])
+		}
+		i /= 2
+	}
+	return proof
+}
+
+// Verify reports whether proof proves that leaf is in the tree with the given root.
+func Verify(root, leaf common.Hash, proof []common.Hash) bool {
+	h := leaf
+	for _, sibling := range proof {
+		h = hashPair(h, sibling)
+	}
+	return h == root
+}
@@ -0,0 +1,41 @@
+package merkle
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func leaves(n int) []common.Hash {
+	var result []common.Hash
+	for i := 0; i < n; i++ {
+		result = append(result, crypto.Keccak256Hash([]byte{byte(i)}))
+	}
+	return result
+}
+
+func TestTree(t *testing.T) {
+	assert.Equal(t, common.Hash{}, New(nil).Root())
+	one := leaves(1)
+	assert.Equal(t, one[0], New(one).Root())
+
+	// The root of two leaves is the hash of both, in sorted order.
+	two := leaves(2)
+	a, b := two[0], two[1]
+	if a.Hex() > b.Hex() {
+		a, b = b, a
+	}
+	assert.Equal(t, crypto.Keccak256Hash(a[:], b[:]), New(two).Root())
+	assert.Equal(t, New(two).Root(), New([]common.Hash{two[1], two[0]}).Root())
+
+	for n := 1; n <= 9; n++ {
+		l := leaves(n)
+		tree := New(l)
+		for i := range l {
+			assert.True(t, Verify(tree.Root(), l[i], tree.Proof(i)), "leaf %v of %v", i, n)
+		}
+		assert.False(t, Verify(tree.Root(), crypto.Keccak256Hash([]byte("other")), tree.Proof(0)))
+	}
+}
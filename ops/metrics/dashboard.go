@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Definition describes one metric: what is exported, and how it is graphed. The exporters
+// write their metrics from their definitions, and WriteDashboard draws the dashboard from the
+// same ones, so that the two can't drift apart.
+type Definition struct {
+	Name string
+
+	// Kind is "gauge" or "counter".
+	Kind string
+
+	Help string
+
+	// Panel, if set, graphs the metric on the dashboard.
+	Panel *Panel
+}
+
+// Panel is a graph of a metric.
+type Panel struct {
+	// Row groups the panels on the dashboard, in the order the rows first appear.
+	Row   string
+	Title string
+
+	// Expr is the Prometheus query graphed; by default, the metric itself.
+	Expr string
+
+	// Legend names each series, as "{{symbol}}"; by default, the metric's labels.
+	Legend string
+
+	// Unit is the Grafana unit of the values, as "short" (the default), "percentunit" for
+	// ratios, or "s".
+	Unit string
+}
+
+// Grafana's JSON model of a dashboard, as much of it as WriteDashboard sets.
+type (
+	dashboard struct {
+		Inputs        []input   `json:"__inputs"`
+		Title         string    `json:"title"`
+		UID           string    `json:"uid"`
+		Tags          []string  `json:"tags"`
+		Timezone      string    `json:"timezone"`
+		Editable      bool      `json:"editable"`
+		SchemaVersion int       `json:"schemaVersion"`
+		Version       int       `json:"version"`
+		Refresh       string    `json:"refresh"`
+		Time          timeRange `json:"time"`
+		Templating    list      `json:"templating"`
+		Annotations   list      `json:"annotations"`
+		Panels        []panel   `json:"panels"`
+	}
+	input struct {
+		Name       string `json:"name"`
+		Label      string `json:"label"`
+		Type       string `json:"type"`
+		PluginID   string `json:"pluginId"`
+		PluginName string `json:"pluginName"`
+	}
+	timeRange struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	list struct {
+		List []interface{} `json:"list"`
+	}
+	gridPos struct {
+		H int `json:"h"`
+		W int `json:"w"`
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+	panel struct {
+		ID          int      `json:"id"`
+		Type        string   `json:"type"`
+		Title       string   `json:"title"`
+		Description string   `json:"description,omitempty"`
+		GridPos     gridPos  `json:"gridPos"`
+		Collapsed   *bool    `json:"collapsed,omitempty"`
+		Panels      []panel  `json:"panels,omitempty"`
+		Datasource  string   `json:"datasource,omitempty"`
+		Targets     []target `json:"targets,omitempty"`
+		YAxes       []yAxis  `json:"yaxes,omitempty"`
+		Lines       bool     `json:"lines,omitempty"`
+		LineWidth   int      `json:"linewidth,omitempty"`
+		Fill        int      `json:"fill,omitempty"`
+		NullPoint   string   `json:"nullPointMode,omitempty"`
+		Legend      *legend  `json:"legend,omitempty"`
+	}
+	target struct {
+		Expr         string `json:"expr"`
+		LegendFormat string `json:"legendFormat,omitempty"`
+		RefID        string `json:"refId"`
+	}
+	yAxis struct {
+		Format string `json:"format"`
+		Show   bool   `json:"show"`
+	}
+	legend struct {
+		Show bool `json:"show"`
+	}
+)
+
+// WriteDashboard writes a Grafana dashboard, ready to import, graphing each of defs that has a
+// panel. On import, Grafana asks which Prometheus data source to use.
+func WriteDashboard(out io.Writer, title, uid string, defs []Definition) error {
+	d := dashboard{
+		Inputs:        []input{{Name: "DS_PROMETHEUS", Label: "Prometheus", Type: "datasource", PluginID: "prometheus", PluginName: "Prometheus"}},
+		Title:         title,
+		UID:           uid,
+		Tags:          []string{"rsv"},
+		Timezone:      "utc",
+		Editable:      true,
+		SchemaVersion: 18,
+		Version:       1,
+		Refresh:       "1m",
+		Time:          timeRange{From: "now-7d", To: "now"},
+		Templating:    list{List: []interface{}{}},
+		Annotations:   list{List: []interface{}{}},
+		Panels:        []panel{},
+	}
+
+	// Rows in the order they first appear, each with its definitions in order.
+	var rows []string
+	byRow := map[string][]Definition{}
+	for _, def := range defs {
+		if def.Panel == nil {
+			continue
+		}
+		if _, ok := byRow[def.Panel.Row]; !ok {
+			rows = append(rows, def.Panel.Row)
+		}
+		byRow[def.Panel.Row] = append(byRow[def.Panel.Row], def)
+	}
+
+	id, y := 1, 0
+	collapsed := false
+	for _, row := range rows {
+		d.Panels = append(d.Panels, panel{ID: id, Type: "row", Title: row, Collapsed: &collapsed, GridPos: gridPos{H: 1, W: 24, Y: y}})
+		id, y = id+1, y+1
+		for i, def := range byRow[row] {
+			p := def.Panel
+			expr, unit := p.Expr, p.Unit
+			if expr == "" {
+				expr = def.Name
+			}
+			if unit == "" {
+				unit = "short"
+			}
+			d.Panels = append(d.Panels, panel{
+				ID:          id,
+				Type:        "graph",
+				Title:       p.Title,
+				Description: def.Help,
+				GridPos:     gridPos{H: 8, W: 12, X: 12 * (i % 2), Y: y + 8*(i/2)},
+				Datasource:  "${DS_PROMETHEUS}",
+				Targets:     []target{{Expr: expr, LegendFormat: p.Legend, RefID: "A"}},
+				YAxes:       []yAxis{{Format: unit, Show: true}, {Format: "short", Show: false}},
+				Lines:       true,
+				LineWidth:   1,
+				Fill:        1,
+				NullPoint:   "null",
+				Legend:      &legend{Show: true},
+			})
+			id++
+		}
+		y += 8 * ((len(byRow[row]) + 1) / 2)
+	}
+
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding dashboard")
+	}
+	_, err = out.Write(append(b, '\n'))
+	return errors.Wrap(err, "writing dashboard")
+}
@@ -0,0 +1,326 @@
+// Package metrics exports the state of an RSV deployment as Prometheus gauges: the supply, the
+// Vault's holdings of each basket token and how far they cover the supply, the pause and
+// emergency switches, the proposals still pending, and who holds each admin role.
+//
+// Metrics are written in the Prometheus text exposition format, so the exporter needs no
+// client library.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Token is one token of the basket.
+type Token struct {
+	Address  common.Address
+	Symbol   string // may be empty
+	Decimals uint8
+
+	// Weight is the basket weight, in aqToken/RSV.
+	Weight *big.Int
+
+	// Balance is the Vault's balance, in qTokens.
+	Balance *big.Int
+
+	// Insurance is the InsuranceFund's balance, in qTokens, or nil if the deployment has no
+	// fund.
+	Insurance *big.Int
+}
+
+// Role is an admin role and its holder.
+type Role struct {
+	Contract string
+	Role     string
+	Holder   common.Address
+}
+
+// State is the state of a deployment at one block.
+type State struct {
+	Block uint64
+
+	Supply      *big.Int // attoRSV
+	RSVDecimals uint8
+
+	Paused           bool
+	TransfersPaused  bool
+	IssuancePaused   bool
+	RedemptionPaused bool
+	Emergency        bool
+
+	Tokens []Token
+
+	// Vault and Basket are the Manager's current Vault and Basket.
+	Vault, Basket common.Address
+
+	// PendingProposals counts the Manager's proposals that are created or accepted, but not
+	// yet completed or cancelled.
+	PendingProposals int
+
+	Roles []Role
+}
+
+// weightScale is the Manager's WEIGHT_SCALE: weights are in aqTokens, 1e18 per qToken.
+var weightScale = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+
+// Needed is the balance of t, in qTokens, that the Vault must hold to back the supply: the
+// supply times the weight, rounded up.
+func (s *State) Needed(t Token) *big.Int {
+	scale := new(big.Int).Mul(weightScale, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(s.RSVDecimals)), nil))
+	needed := new(big.Int).Mul(s.Supply, t.Weight)
+	needed.Add(needed, new(big.Int).Sub(scale, big.NewInt(1)))
+	return needed.Div(needed, scale)
+}
+
+// Ratio is how many times over the Vault's balance of t covers the supply: 1 is exactly
+// collateralized, as the Manager's isFullyCollateralized counts it. With nothing to cover, it
+// is +Inf.
+func (s *State) Ratio(t Token) float64 {
+	// The balance needed is supply * weight / (1e18 * 10^RSVDecimals).
+	needed := new(big.Int).Mul(s.Supply, t.Weight)
+	if needed.Sign() == 0 {
+		return math.Inf(1)
+	}
+	scaled := new(big.Int).Mul(t.Balance, weightScale)
+	scaled.Mul(scaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(s.RSVDecimals)), nil))
+	ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(scaled), new(big.Float).SetInt(needed)).Float64()
+	return ratio
+}
+
+// Collateralization is the smallest Ratio over the basket: the fraction of the supply that the
+// Vault could redeem.
+func (s *State) Collateralization() float64 {
+	min := math.Inf(1)
+	for _, t := range s.Tokens {
+		min = math.Min(min, s.Ratio(t))
+	}
+	return min
+}
+
+// decimal converts an integer amount in units of 10^-decimals to a float.
+func decimal(amount *big.Int, decimals uint8) float64 {
+	f := new(big.Float).SetInt(amount)
+	f.Quo(f, new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	v, _ := f.Float64()
+	return v
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// TextWriter writes metrics in the Prometheus text format. After an error, it writes nothing
+// more, and Err returns the error.
+type TextWriter struct {
+	w   io.Writer
+	err error
+}
+
+// NewTextWriter returns a TextWriter writing to w.
+func NewTextWriter(w io.Writer) *TextWriter {
+	return &TextWriter{w: w}
+}
+
+// Err returns the first error writing, if any.
+func (w *TextWriter) Err() error {
+	return w.err
+}
+
+func (w *TextWriter) printf(format string, args ...interface{}) {
+	if w.err == nil {
+		_, w.err = fmt.Fprintf(w.w, format, args...)
+	}
+}
+
+// Header introduces the metric name, of the given kind ("gauge" or "counter").
+func (w *TextWriter) Header(name, kind, help string) {
+	w.printf("# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+}
+
+// Define introduces the metric of d.
+func (w *TextWriter) Define(d Definition) {
+	w.Header(d.Name, d.Kind, d.Help)
+}
+
+// Metric writes the metric of d with a single, unlabelled sample.
+func (w *TextWriter) Metric(d Definition, value float64) {
+	w.Define(d)
+	w.Sample(d.Name, nil, value)
+}
+
+// Gauge writes a gauge with a single, unlabelled sample.
+func (w *TextWriter) Gauge(name, help string, value float64) {
+	w.Header(name, "gauge", help)
+	w.Sample(name, nil, value)
+}
+
+// Sample writes one sample; labels alternate names and values.
+func (w *TextWriter) Sample(name string, labels []string, value float64) {
+	var parts []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		parts = append(parts, fmt.Sprintf("%v=%q", labels[i], labels[i+1]))
+	}
+	if len(parts) > 0 {
+		name += "{" + strings.Join(parts, ",") + "}"
+	}
+	w.printf("%v %v\n", name, formatValue(value))
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return fmt.Sprint(v)
+}
+
+// The exporter's metrics.
+var (
+	blockMetric = Definition{Name: "rsv_block", Kind: "gauge", Help: "Block that the other metrics were read at.",
+		Panel: &Panel{Row: "Exporter", Title: "Block read"}}
+	totalSupply = Definition{Name: "rsv_total_supply", Kind: "gauge", Help: "Total supply of RSV.",
+		Panel: &Panel{Row: "Supply and backing", Title: "Total supply", Legend: "RSV"}}
+	paused = Definition{Name: "rsv_paused", Kind: "gauge", Help: "Whether the Reserve is paused (1) or not (0).",
+		Panel: &Panel{Row: "Switches", Title: "Reserve paused", Legend: "paused"}}
+	transfersPaused = Definition{Name: "rsv_transfers_paused", Kind: "gauge", Help: "Whether transfers of RSV alone are paused.",
+		Panel: &Panel{Row: "Switches", Title: "Transfers paused", Legend: "transfers paused"}}
+	issuancePaused = Definition{Name: "rsv_issuance_paused", Kind: "gauge", Help: "Whether issuance through the Manager is paused.",
+		Panel: &Panel{Row: "Switches", Title: "Issuance paused", Legend: "issuance paused"}}
+	redemptionPaused = Definition{Name: "rsv_redemption_paused", Kind: "gauge", Help: "Whether redemption through the Manager is paused.",
+		Panel: &Panel{Row: "Switches", Title: "Redemption paused", Legend: "redemption paused"}}
+	emergency = Definition{Name: "rsv_emergency", Kind: "gauge", Help: "Whether the Manager is in emergency mode.",
+		Panel: &Panel{Row: "Switches", Title: "Emergency", Legend: "emergency"}}
+	pendingProposals = Definition{Name: "rsv_pending_proposals", Kind: "gauge", Help: "Manager proposals created or accepted but not yet completed or cancelled.",
+		Panel: &Panel{Row: "Switches", Title: "Pending proposals", Legend: "pending"}}
+	collateralization = Definition{Name: "rsv_collateralization_ratio", Kind: "gauge", Help: "Smallest ratio of Vault balance to the balance needed, over the basket.",
+		Panel: &Panel{Row: "Supply and backing", Title: "Backing ratio", Legend: "backing", Unit: "percentunit"}}
+	vaultBalance = Definition{Name: "rsv_vault_balance", Kind: "gauge", Help: "Vault balance of each basket token, in whole tokens.",
+		Panel: &Panel{Row: "Supply and backing", Title: "Vault balances", Legend: "{{symbol}}"}}
+	insuranceBalance = Definition{Name: "rsv_insurance_balance", Kind: "gauge", Help: "InsuranceFund balance of each basket token, in whole tokens.",
+		Panel: &Panel{Row: "Supply and backing", Title: "Insurance fund balances", Legend: "{{symbol}}"}}
+	collateralRatio = Definition{Name: "rsv_collateral_ratio", Kind: "gauge", Help: "Ratio of the Vault balance of each basket token to the balance needed to back the supply.",
+		Panel: &Panel{Row: "Supply and backing", Title: "Backing ratio by token", Legend: "{{symbol}}", Unit: "percentunit"}}
+	roleInfo        = Definition{Name: "rsv_role_info", Kind: "gauge", Help: "Holder of each admin role; always 1."}
+	refreshFailures = Definition{Name: "rsv_metrics_refresh_failures_total", Kind: "counter", Help: "Failed attempts to read the chain.",
+		Panel: &Panel{Row: "Exporter", Title: "Read failures per hour", Expr: "increase(rsv_metrics_refresh_failures_total[1h])", Legend: "failures"}}
+	lastRefresh = Definition{Name: "rsv_metrics_last_refresh_timestamp_seconds", Kind: "gauge", Help: "When the metrics were last read from the chain.",
+		Panel: &Panel{Row: "Exporter", Title: "Data age", Expr: "time() - rsv_metrics_last_refresh_timestamp_seconds", Legend: "age", Unit: "s"}}
+)
+
+// Definitions are the exporter's metrics.
+var Definitions = []Definition{
+	totalSupply, collateralization, collateralRatio, vaultBalance, insuranceBalance,
+	paused, transfersPaused, issuancePaused, redemptionPaused, emergency, pendingProposals, roleInfo,
+	blockMetric, lastRefresh, refreshFailures,
+}
+
+// WriteText writes s in the Prometheus text format.
+func WriteText(out io.Writer, s *State) error {
+	w := NewTextWriter(out)
+	w.Metric(blockMetric, float64(s.Block))
+	w.Metric(totalSupply, decimal(s.Supply, s.RSVDecimals))
+	w.Metric(paused, boolValue(s.Paused))
+	w.Metric(transfersPaused, boolValue(s.TransfersPaused))
+	w.Metric(issuancePaused, boolValue(s.IssuancePaused))
+	w.Metric(redemptionPaused, boolValue(s.RedemptionPaused))
+	w.Metric(emergency, boolValue(s.Emergency))
+	w.Metric(pendingProposals, float64(s.PendingProposals))
+	w.Metric(collateralization, s.Collateralization())
+
+	w.Define(vaultBalance)
+	for _, t := range s.Tokens {
+		w.Sample(vaultBalance.Name, []string{"token", t.Address.Hex(), "symbol", t.Symbol}, decimal(t.Balance, t.Decimals))
+	}
+	w.Define(insuranceBalance)
+	for _, t := range s.Tokens {
+		if t.Insurance != nil {
+			w.Sample(insuranceBalance.Name, []string{"token", t.Address.Hex(), "symbol", t.Symbol}, decimal(t.Insurance, t.Decimals))
+		}
+	}
+	w.Define(collateralRatio)
+	for _, t := range s.Tokens {
+		w.Sample(collateralRatio.Name, []string{"token", t.Address.Hex(), "symbol", t.Symbol}, s.Ratio(t))
+	}
+	w.Define(roleInfo)
+	for _, r := range s.Roles {
+		w.Sample(roleInfo.Name, []string{"contract", r.Contract, "role", r.Role, "address", r.Holder.Hex()}, 1)
+	}
+	return w.err
+}
+
+// Exporter refreshes the state periodically, and serves the latest as metrics.
+type Exporter struct {
+	Read     func(ctx context.Context) (*State, error)
+	Interval time.Duration
+
+	mu        sync.Mutex
+	state     *State
+	refreshed time.Time
+	failures  uint64
+}
+
+// Run refreshes the state every Interval until ctx is done.
+func (e *Exporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+	for {
+		e.Refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh reads the state once. On failure the previous state is kept, and the failure counted.
+func (e *Exporter) Refresh(ctx context.Context) {
+	s, err := e.Read(ctx)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		log.Printf("metrics: %v", err)
+		e.failures++
+		return
+	}
+	e.state, e.refreshed = s, time.Now()
+}
+
+// ServeHTTP serves the metrics. Until the first successful refresh, only the exporter's own
+// metrics are served, so that a stale-data alert can distinguish "no data" from bad data.
+func (e *Exporter) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	s, refreshed, failures := e.state, e.refreshed, e.failures
+	e.mu.Unlock()
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w := NewTextWriter(rw)
+	w.Metric(refreshFailures, float64(failures))
+	if s == nil {
+		return
+	}
+	w.Metric(lastRefresh, float64(refreshed.Unix()))
+	if w.err == nil {
+		w.err = WriteText(rw, s)
+	}
+	if w.err != nil {
+		log.Printf("metrics: writing response: %v", w.err)
+	}
+}
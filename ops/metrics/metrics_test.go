@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	usdc = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	tusd = common.HexToAddress("0x0000000000085d4780B73119b644AE5ecd22b376")
+)
+
+func e(n int64, decimals int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), new(big.Int).Exp(big.NewInt(10), big.NewInt(decimals), nil))
+}
+
+func testState() *State {
+	return &State{
+		Block:          7,
+		Supply:         e(1000, 18), // 1000 RSV
+		RSVDecimals:    18,
+		Paused:         true,
+		IssuancePaused: true,
+		Tokens: []Token{
+			// Half a USDC per RSV, and the Vault holds 500 USDC: exactly backed.
+			{Address: usdc, Symbol: "USDC", Decimals: 6, Weight: e(5, 23), Balance: e(500, 6)},
+			// Half a TUSD per RSV, and the Vault holds 750 TUSD.
+			{Address: tusd, Symbol: "TUSD", Decimals: 18, Weight: e(5, 35), Balance: e(750, 18), Insurance: e(20, 18)},
+		},
+		PendingProposals: 2,
+		Roles:            []Role{{Contract: "Reserve", Role: "owner", Holder: usdc}},
+	}
+}
+
+func TestRatio(t *testing.T) {
+	s := testState()
+	assert.Equal(t, 1.0, s.Ratio(s.Tokens[0]))
+	assert.Equal(t, 1.5, s.Ratio(s.Tokens[1]))
+	assert.Equal(t, 1.0, s.Collateralization())
+	assert.Equal(t, e(500, 6), s.Needed(s.Tokens[0]))
+	assert.Equal(t, e(500, 18), s.Needed(s.Tokens[1]))
+
+	s.Tokens[0].Balance = e(250, 6)
+	assert.Equal(t, 0.5, s.Collateralization())
+
+	s.Supply = new(big.Int)
+	assert.True(t, math.IsInf(s.Collateralization(), 1), "nothing to back")
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteText(&buf, testState()))
+	text := buf.String()
+	for _, line := range []string{
+		"# TYPE rsv_total_supply gauge",
+		"rsv_total_supply 1000",
+		"rsv_paused 1",
+		"rsv_transfers_paused 0",
+		"rsv_issuance_paused 1",
+		"rsv_redemption_paused 0",
+		"rsv_emergency 0",
+		"rsv_pending_proposals 2",
+		"rsv_collateralization_ratio 1",
+		`rsv_vault_balance{token="` + usdc.Hex() + `",symbol="USDC"} 500`,
+		`rsv_collateral_ratio{token="` + tusd.Hex() + `",symbol="TUSD"} 1.5`,
+		`rsv_insurance_balance{token="` + tusd.Hex() + `",symbol="TUSD"} 20`,
+		`rsv_role_info{contract="Reserve",role="owner",address="` + usdc.Hex() + `"} 1`,
+	} {
+		assert.Contains(t, strings.Split(text, "\n"), line)
+	}
+}
+
+func TestExporter(t *testing.T) {
+	var fail bool
+	e := &Exporter{Read: func(ctx context.Context) (*State, error) {
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		return testState(), nil
+	}}
+	get := func() string {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		return rec.Body.String()
+	}
+
+	fail = true
+	e.Refresh(context.Background())
+	assert.Contains(t, get(), "rsv_metrics_refresh_failures_total 1\n")
+	assert.NotContains(t, get(), "rsv_total_supply")
+
+	fail = false
+	e.Refresh(context.Background())
+	assert.Contains(t, get(), "rsv_total_supply 1000\n")
+
+	// A failed refresh keeps serving the last state.
+	fail = true
+	e.Refresh(context.Background())
+	assert.Contains(t, get(), "rsv_metrics_refresh_failures_total 2\n")
+	assert.Contains(t, get(), "rsv_total_supply 1000\n")
+}
+
+func TestDashboard(t *testing.T) {
+	// Everything exported is defined.
+	var buf bytes.Buffer
+	require.NoError(t, WriteText(&buf, testState()))
+	defined := map[string]bool{}
+	for _, d := range Definitions {
+		defined[d.Name] = true
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			assert.True(t, defined[strings.Fields(line)[2]], line)
+		}
+	}
+
+	buf.Reset()
+	require.NoError(t, WriteDashboard(&buf, "RSV", "rsv", Definitions))
+	var d struct {
+		UID    string
+		Panels []struct {
+			Type, Title string
+			GridPos     struct{ X, Y, W, H int }
+			Targets     []struct{ Expr string }
+		}
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &d))
+	assert.Equal(t, "rsv", d.UID)
+
+	var graphs []string
+	var exprs []string
+	for _, p := range d.Panels {
+		if p.Type == "graph" {
+			graphs = append(graphs, p.Title)
+			exprs = append(exprs, p.Targets[0].Expr)
+		}
+	}
+	var want []string
+	for _, def := range Definitions {
+		if def.Panel != nil {
+			want = append(want, def.Panel.Title)
+		}
+	}
+	assert.Equal(t, want, graphs)
+	assert.Contains(t, exprs, "rsv_total_supply")
+	assert.Equal(t, "row", d.Panels[0].Type)
+	assert.Equal(t, 12, d.Panels[2].GridPos.X, "two graphs a line")
+}
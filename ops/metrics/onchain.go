@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// tokenABI is the part of ERC20 that the exporter reads from basket tokens.
+const tokenABI = `[
+	{"type":"function","name":"balanceOf","constant":true,"inputs":[{"name":"","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"decimals","constant":true,"inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"symbol","constant":true,"inputs":[],"outputs":[{"name":"","type":"string"}]}
+]`
+
+// proposalABI is the part of the Proposal interface that the exporter reads.
+const proposalABI = `[
+	{"type":"function","name":"state","constant":true,"inputs":[],"outputs":[{"name":"","type":"uint8"}]}
+]`
+
+// Proposal states, as in Proposal.sol.
+const (
+	proposalCreated  = 0
+	proposalAccepted = 1
+)
+
+// roleViews are the admin roles reported, by contract. Views that a contract's ABI lacks are
+// left out. The Manager's operators are reported too, each in the role "operator".
+var roleViews = []struct{ contract, view string }{
+	{"Reserve", "owner"}, {"Reserve", "minter"}, {"Reserve", "pauser"}, {"Reserve", "freezer"},
+	{"Reserve", "guardian"}, {"Reserve", "wiper"}, {"Reserve", "feeRecipient"}, {"Reserve", "snapshotter"},
+	{"Manager", "owner"}, {"Manager", "vetoer"},
+	{"Vault", "owner"}, {"Vault", "manager"},
+}
+
+func mustParse(s string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+var (
+	tokenArtifact    = &chain.Artifact{Name: "ERC20", ABI: mustParse(tokenABI), ABIJSON: tokenABI}
+	proposalArtifact = &chain.Artifact{Name: "Proposal", ABI: mustParse(proposalABI), ABIJSON: proposalABI}
+)
+
+// Reader reads the State of a deployment on chain. Each read is a few batched requests, all
+// pinned to one block.
+type Reader struct {
+	client        *chain.Client
+	reserve       *chain.Contract
+	manager       *chain.Contract
+	vault, basket *chain.Artifact
+
+	// insurance is the manifest's InsuranceFund, if it has one.
+	insurance *common.Address
+}
+
+// NewReader returns a Reader for the deployment that s is connected to.
+func NewReader(s *session.Session) (*Reader, error) {
+	reserve, err := s.Contract("Reserve")
+	if err != nil {
+		return nil, err
+	}
+	manager, err := s.Contract("Manager")
+	if err != nil {
+		return nil, err
+	}
+	vault, err := s.Artifacts.Load("Vault")
+	if err != nil {
+		return nil, err
+	}
+	basket, err := s.Artifacts.Load("Basket")
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{client: s.Client, reserve: reserve, manager: manager, vault: vault, basket: basket}
+	if addr, ok := s.Manifest.Contracts["InsuranceFund"]; ok {
+		r.insurance = &addr
+	}
+	return r, nil
+}
+
+// Read reads the state at the latest block.
+func (r *Reader) Read(ctx context.Context) (*State, error) {
+	head, err := r.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting the latest block")
+	}
+	return r.ReadAt(ctx, head.Number.Uint64())
+}
+
+// ReadAt reads the state at the given block. Unless the node keeps archive state, the block
+// must be recent.
+func (r *Reader) ReadAt(ctx context.Context, number uint64) (*State, error) {
+	block := new(big.Int).SetUint64(number)
+	s := &State{Block: number, Supply: new(big.Int)}
+	var err error
+
+	// The Reserve and Manager, and where the Manager's Basket and Vault are.
+	var (
+		basketAddr, vaultAddr common.Address
+		proposals             = new(big.Int)
+		operators             = new(big.Int)
+	)
+	b := r.client.NewBatch(block)
+	add := func(c *chain.Contract, result interface{}, method string, args ...interface{}) {
+		if err == nil {
+			err = b.Add(c, result, method, args...)
+		}
+	}
+	add(r.reserve, &s.Supply, "totalSupply")
+	add(r.reserve, &s.RSVDecimals, "decimals")
+	add(r.reserve, &s.Paused, "paused")
+	add(r.reserve, &s.TransfersPaused, "transfersPaused")
+	add(r.manager, &s.IssuancePaused, "issuancePaused")
+	add(r.manager, &s.RedemptionPaused, "redemptionPaused")
+	add(r.manager, &s.Emergency, "emergency")
+	add(r.manager, &basketAddr, "trustedBasket")
+	add(r.manager, &vaultAddr, "trustedVault")
+	add(r.manager, &proposals, "proposalsLength")
+	if _, ok := r.manager.ABI.Methods["operatorsLength"]; ok {
+		add(r.manager, &operators, "operatorsLength")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Do(ctx); err != nil {
+		return nil, err
+	}
+	s.Vault, s.Basket = vaultAddr, basketAddr
+	vault := r.vault.Bind(vaultAddr, r.client)
+	basket := r.basket.Bind(basketAddr, r.client)
+
+	// The roles, the basket's tokens, and the proposals.
+	contracts := map[string]*chain.Contract{"Reserve": r.reserve, "Manager": r.manager, "Vault": vault}
+	var tokens []common.Address
+	b = r.client.NewBatch(block)
+	add(basket, &tokens, "getTokens")
+	for _, v := range roleViews {
+		if _, ok := contracts[v.contract].ABI.Methods[v.view]; ok {
+			s.Roles = append(s.Roles, Role{Contract: v.contract, Role: v.view})
+		}
+	}
+	views := len(s.Roles)
+	for i := int64(0); i < operators.Int64(); i++ {
+		s.Roles = append(s.Roles, Role{Contract: "Manager", Role: "operator"})
+	}
+	for i := range s.Roles {
+		if i < views {
+			add(contracts[s.Roles[i].Contract], &s.Roles[i].Holder, s.Roles[i].Role)
+		} else {
+			add(r.manager, &s.Roles[i].Holder, "operators", big.NewInt(int64(i-views)))
+		}
+	}
+	proposalAddrs := make([]common.Address, proposals.Int64())
+	for i := range proposalAddrs {
+		add(r.manager, &proposalAddrs[i], "trustedProposals", big.NewInt(int64(i)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Do(ctx); err != nil {
+		return nil, err
+	}
+
+	// The Vault's holdings, the weights, and the state of each proposal.
+	s.Tokens = make([]Token, len(tokens))
+	b = r.client.NewBatch(block)
+	for i, addr := range tokens {
+		t := &s.Tokens[i]
+		t.Address, t.Weight, t.Balance = addr, new(big.Int), new(big.Int)
+		token := tokenArtifact.Bind(addr, r.client)
+		add(basket, &t.Weight, "weights", addr)
+		add(token, &t.Balance, "balanceOf", vaultAddr)
+		add(token, &t.Decimals, "decimals")
+		if r.insurance != nil {
+			t.Insurance = new(big.Int)
+			add(token, &t.Insurance, "balanceOf", *r.insurance)
+		}
+	}
+	states := make([]uint8, len(proposalAddrs))
+	for i, addr := range proposalAddrs {
+		add(proposalArtifact.Bind(addr, r.client), &states[i], "state")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Do(ctx); err != nil {
+		return nil, err
+	}
+	for _, state := range states {
+		if state == proposalCreated || state == proposalAccepted {
+			s.PendingProposals++
+		}
+	}
+
+	// Symbols only label the metrics, and some tokens return bytes32 instead of a string, so
+	// they are read on their own and may be missing.
+	b = r.client.NewBatch(block)
+	symbols := make([]string, len(tokens))
+	for i, addr := range tokens {
+		add(tokenArtifact.Bind(addr, r.client), &symbols[i], "symbol")
+	}
+	if err == nil && b.Do(ctx) == nil {
+		for i := range s.Tokens {
+			s.Tokens[i].Symbol = symbols[i]
+		}
+	}
+	return s, err
+}
@@ -0,0 +1,279 @@
+// Package params plans governance parameter changes: given the desired value of each admin
+// parameter of the deployed contracts, it works out the smallest list of admin transactions
+// that brings the chain to that state.
+//
+// A desired-parameters file is a JSON object from contract name to parameter name to value:
+//
+//	{
+//	    "Reserve": {"minter": "0x4B48...8Bb6", "maxSupply": "1000000000000000000000000000"},
+//	    "Manager": {"seigniorage": "10", "issuancePaused": "false", "vetoer": "0xAeDC...FB0f"},
+//	    "Relayer": {"trustedRSV": "@Reserve"}
+//	}
+//
+// Values are strings: addresses in hex, or "@Name" for the manifest address of contract Name;
+// integers in decimal, in the contract's own units; and "true" or "false". Parameters that the
+// file leaves out are left alone.
+package params
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/manifest"
+)
+
+// Kinds of parameter value.
+const (
+	Address = "address"
+	Uint    = "uint"
+	Bool    = "bool"
+)
+
+// Param is one admin parameter: a view method that reads it and a method that sets it.
+type Param struct {
+	Contract string
+	Name     string // also its getter
+	Setter   string
+	Kind     string
+
+	// Roles are the getters of the contract's roles that may call Setter, or the roles of
+	// Memberships.
+	Roles []string
+}
+
+// Memberships are the roles that many accounts hold at once, keyed as "Manager.operator", with
+// the view that returns whether an account holds each.
+var Memberships = map[string]string{
+	"Manager.operator": "isOperator",
+}
+
+// Params lists the parameters that can be managed, in the order that changes are made. Order
+// matters where one change takes away the authority for another: a role comes after the
+// parameters that its holder may set. It also matters where one change needs another first: a
+// fee needs its recipient.
+var Params = []Param{
+	{Contract: "Reserve", Name: "trustedTxFee", Setter: "changeTxFeeHelper", Kind: Address, Roles: []string{"owner"}},
+	{Contract: "Reserve", Name: "trustedRelayer", Setter: "changeRelayer", Kind: Address, Roles: []string{"owner"}},
+	{Contract: "Reserve", Name: "maxSupply", Setter: "changeMaxSupply", Kind: Uint, Roles: []string{"owner"}},
+	{Contract: "Reserve", Name: "feeRecipient", Setter: "changeFeeRecipient", Kind: Address, Roles: []string{"owner", "feeRecipient"}},
+	{Contract: "Reserve", Name: "minter", Setter: "changeMinter", Kind: Address, Roles: []string{"owner", "minter"}},
+	{Contract: "Reserve", Name: "pauser", Setter: "changePauser", Kind: Address, Roles: []string{"owner", "pauser"}},
+
+	{Contract: "Manager", Name: "emergency", Setter: "setEmergency", Kind: Bool, Roles: []string{"operator"}},
+	{Contract: "Manager", Name: "issuancePaused", Setter: "setIssuancePaused", Kind: Bool, Roles: []string{"operator"}},
+	{Contract: "Manager", Name: "redemptionPaused", Setter: "setRedemptionPaused", Kind: Bool, Roles: []string{"operator"}},
+	{Contract: "Manager", Name: "seigniorage", Setter: "setSeigniorage", Kind: Uint, Roles: []string{"owner"}},
+	{Contract: "Manager", Name: "redemptionFeeRecipient", Setter: "setRedemptionFeeRecipient", Kind: Address, Roles: []string{"owner"}},
+	{Contract: "Manager", Name: "redemptionFee", Setter: "setRedemptionFee", Kind: Uint, Roles: []string{"owner"}},
+	{Contract: "Manager", Name: "issuanceFeeRecipient", Setter: "setIssuanceFeeRecipient", Kind: Address, Roles: []string{"owner"}},
+	{Contract: "Manager", Name: "issuanceFee", Setter: "setIssuanceFee", Kind: Uint, Roles: []string{"owner"}},
+	{Contract: "Manager", Name: "trustedOracle", Setter: "setOracle", Kind: Address, Roles: []string{"owner"}},
+	{Contract: "Manager", Name: "trustedAuction", Setter: "setAuction", Kind: Address, Roles: []string{"owner"}},
+	{Contract: "Manager", Name: "trustedRegistry", Setter: "setRegistry", Kind: Address, Roles: []string{"owner"}},
+	{Contract: "Manager", Name: "trustedPermit2", Setter: "setPermit2", Kind: Address, Roles: []string{"owner"}},
+	{Contract: "Manager", Name: "delay", Setter: "setDelay", Kind: Uint, Roles: []string{"owner"}},
+	{Contract: "Manager", Name: "proposalValidity", Setter: "setProposalValidity", Kind: Uint, Roles: []string{"owner"}},
+	{Contract: "Manager", Name: "trustedVault", Setter: "setVault", Kind: Address, Roles: []string{"owner"}},
+	{Contract: "Manager", Name: "vetoer", Setter: "setVetoer", Kind: Address, Roles: []string{"owner"}},
+
+	{Contract: "Vault", Name: "manager", Setter: "changeManager", Kind: Address, Roles: []string{"owner"}},
+
+	{Contract: "InsuranceFund", Name: "manager", Setter: "setManager", Kind: Address, Roles: []string{"owner"}},
+
+	{Contract: "FeeTreasury", Name: "distributionInterval", Setter: "setDistributionInterval", Kind: Uint, Roles: []string{"owner"}},
+
+	{Contract: "Relayer", Name: "trustedRSV", Setter: "setRSV", Kind: Address, Roles: []string{"owner"}},
+}
+
+// Desired is the contents of a desired-parameters file.
+type Desired map[string]map[string]string
+
+// LoadDesired reads a desired-parameters file, and checks that it names only known parameters.
+func LoadDesired(path string) (Desired, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading desired parameters")
+	}
+	var d Desired
+	if err := json.Unmarshal(b, &d); err != nil {
+		return nil, errors.Wrapf(err, "parsing desired parameters %v", path)
+	}
+	for contract, values := range d {
+		for name := range values {
+			if Find(contract, name) == nil {
+				return nil, errors.Errorf("%v: %v.%v is not a parameter this tool manages", path, contract, name)
+			}
+		}
+	}
+	return d, nil
+}
+
+// Find returns the parameter contract.name, or nil if there is none.
+func Find(contract, name string) *Param {
+	for i := range Params {
+		if Params[i].Contract == contract && Params[i].Name == name {
+			return &Params[i]
+		}
+	}
+	return nil
+}
+
+// BySetter returns the parameter that setter sets, or nil if there is none.
+func BySetter(setter string) *Param {
+	for i := range Params {
+		if Params[i].Setter == setter {
+			return &Params[i]
+		}
+	}
+	return nil
+}
+
+// Normalize parses a desired value of the given kind, and returns it in the form that a
+// Reader returns: checksummed hex addresses, decimal integers, and "true" or "false".
+func Normalize(m *manifest.Manifest, kind, value string) (string, error) {
+	switch kind {
+	case Address:
+		if strings.HasPrefix(value, "@") {
+			addr, err := m.Address(value[1:])
+			return addr.Hex(), err
+		}
+		if !common.IsHexAddress(value) {
+			return "", errors.Errorf("%q is not an address", value)
+		}
+		addr := common.HexToAddress(value)
+		if strings.ToLower(value) != value && addr.Hex() != value {
+			return "", errors.Errorf("%q is not EIP-55 checksummed", value)
+		}
+		return addr.Hex(), nil
+	case Uint:
+		n, ok := new(big.Int).SetString(value, 10)
+		if !ok || n.Sign() < 0 {
+			return "", errors.Errorf("%q is not a non-negative decimal integer", value)
+		}
+		return n.String(), nil
+	case Bool:
+		if value != "true" && value != "false" {
+			return "", errors.Errorf("%q is not true or false", value)
+		}
+		return value, nil
+	}
+	return "", errors.Errorf("unknown kind %q", kind)
+}
+
+// Reader reads the current value of a contract's view method, called with args, normalized as
+// in Normalize.
+type Reader interface {
+	Read(ctx context.Context, contract, getter, kind string, args ...interface{}) (string, error)
+}
+
+// Change is one transaction of a plan.
+type Change struct {
+	Param
+	Current string
+	Desired string
+
+	// Authorized reports whether the planned signer holds one of Param.Roles, as of the
+	// changes before this one.
+	Authorized bool
+}
+
+// Arg is the argument to pass to the setter.
+func (c Change) Arg() interface{} {
+	switch c.Kind {
+	case Address:
+		return common.HexToAddress(c.Desired)
+	case Uint:
+		n, _ := new(big.Int).SetString(c.Desired, 10)
+		return n
+	}
+	return c.Desired == "true"
+}
+
+// Plan returns the changes needed to bring the chain, as read by r, to d, in the order they
+// must be made, when sent by signer.
+func Plan(ctx context.Context, r Reader, m *manifest.Manifest, d Desired, signer common.Address) ([]Change, error) {
+	// Roles as they will stand after each change, so that authorization accounts for changes
+	// earlier in the plan.
+	roles := make(map[string]string)
+	role := func(contract, getter string) (string, error) {
+		key := contract + "." + getter
+		if v, ok := roles[key]; ok {
+			return v, nil
+		}
+		v, err := r.Read(ctx, contract, getter, Address)
+		roles[key] = v
+		return v, err
+	}
+
+	var changes []Change
+	for _, p := range Params {
+		value, ok := d[p.Contract][p.Name]
+		if !ok {
+			continue
+		}
+		want, err := Normalize(m, p.Kind, value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%v.%v", p.Contract, p.Name)
+		}
+		current, err := r.Read(ctx, p.Contract, p.Name, p.Kind)
+		if err != nil {
+			return nil, err
+		}
+		if current == want {
+			continue
+		}
+		c := Change{Param: p, Current: current, Desired: want}
+		for _, getter := range p.Roles {
+			if view, ok := Memberships[p.Contract+"."+getter]; ok {
+				holds, err := r.Read(ctx, p.Contract, view, Bool, signer)
+				if err != nil {
+					return nil, err
+				}
+				c.Authorized = c.Authorized || holds == "true"
+				continue
+			}
+			holder, err := role(p.Contract, getter)
+			if err != nil {
+				return nil, err
+			}
+			if holder == signer.Hex() {
+				c.Authorized = true
+			}
+		}
+		if p.Kind == Address {
+			roles[p.Contract+"."+p.Name] = want
+		}
+		changes = append(changes, c)
+	}
+	return orderDelay(changes), nil
+}
+
+// orderDelay puts a change of the Manager's proposalValidity before one of its delay if the new
+// delay isn't under the current validity: each setter keeps the delay under the validity, so
+// raising both past it must raise the validity first.
+func orderDelay(changes []Change) []Change {
+	delay, validity := -1, -1
+	for i, c := range changes {
+		switch {
+		case c.Contract == "Manager" && c.Name == "delay":
+			delay = i
+		case c.Contract == "Manager" && c.Name == "proposalValidity":
+			validity = i
+		}
+	}
+	if delay < 0 || validity < 0 {
+		return changes
+	}
+	newDelay, _ := new(big.Int).SetString(changes[delay].Desired, 10)
+	oldValidity, _ := new(big.Int).SetString(changes[validity].Current, 10)
+	if newDelay.Cmp(oldValidity) >= 0 {
+		changes[delay], changes[validity] = changes[validity], changes[delay]
+	}
+	return changes
+}
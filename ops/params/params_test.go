@@ -0,0 +1,146 @@
+package params
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/manifest"
+)
+
+var (
+	owner     = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	operator  = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	newVetoer = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+	relayer   = common.HexToAddress("0x00000000000000000000000000000000000000dd")
+	reserve   = common.HexToAddress("0x00000000000000000000000000000000000000ee")
+)
+
+// fakeReader serves values from a map of "Contract.getter", or "Contract.getter(address)" for a
+// view called with an address, to normalized value.
+type fakeReader map[string]string
+
+func (r fakeReader) Read(ctx context.Context, contract, getter, kind string, args ...interface{}) (string, error) {
+	key := contract + "." + getter
+	for _, arg := range args {
+		key += "(" + arg.(common.Address).Hex() + ")"
+	}
+	v, ok := r[key]
+	if !ok {
+		return "", errors.Errorf("no %v", key)
+	}
+	return v, nil
+}
+
+func chainState() fakeReader {
+	return fakeReader{
+		"Reserve.owner":          owner.Hex(),
+		"Reserve.trustedRelayer": relayer.Hex(),
+		"Reserve.maxSupply":      "1000",
+		"Manager.owner":          owner.Hex(),
+		"Manager.vetoer":         owner.Hex(),
+		"Manager.issuancePaused": "false",
+		"Manager.seigniorage":    "10",
+
+		"Manager.isOperator(" + owner.Hex() + ")":    "false",
+		"Manager.isOperator(" + operator.Hex() + ")": "true",
+	}
+}
+
+func testManifest() *manifest.Manifest {
+	return &manifest.Manifest{Contracts: map[string]common.Address{"Reserve": reserve, "Relayer": relayer}}
+}
+
+func summarize(changes []Change) []string {
+	var result []string
+	for _, c := range changes {
+		s := c.Contract + "." + c.Setter + " " + c.Desired
+		if !c.Authorized {
+			s += " (unauthorized)"
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+func TestPlan(t *testing.T) {
+	d := Desired{
+		"Reserve": {"trustedRelayer": "@Relayer", "maxSupply": "2000"},
+		"Manager": {"seigniorage": "10", "issuancePaused": "true", "vetoer": newVetoer.Hex()},
+	}
+	changes, err := Plan(context.Background(), chainState(), testManifest(), d, owner)
+	require.NoError(t, err)
+	// Unchanged values need no transaction; the owner can't set issuancePaused.
+	assert.Equal(t, []string{
+		"Reserve.changeMaxSupply 2000",
+		"Manager.setIssuancePaused true (unauthorized)",
+		"Manager.setVetoer " + newVetoer.Hex(),
+	}, summarize(changes))
+	assert.Equal(t, big.NewInt(2000), changes[0].Arg())
+	assert.Equal(t, true, changes[1].Arg())
+	assert.Equal(t, newVetoer, changes[2].Arg())
+
+	// An operator can pause issuance, but not set the vetoer.
+	changes, err = Plan(context.Background(), chainState(), testManifest(), d, operator)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"Reserve.changeMaxSupply 2000 (unauthorized)",
+		"Manager.setIssuancePaused true",
+		"Manager.setVetoer " + newVetoer.Hex() + " (unauthorized)",
+	}, summarize(changes))
+}
+
+func TestPlanDelayAndValidity(t *testing.T) {
+	state := chainState()
+	state["Manager.delay"] = "86400"
+	state["Manager.proposalValidity"] = "604800"
+
+	// Lowering both, the delay goes first, to stay under the validity.
+	d := Desired{"Manager": {"delay": "3600", "proposalValidity": "7200"}}
+	changes, err := Plan(context.Background(), state, testManifest(), d, owner)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Manager.setDelay 3600", "Manager.setProposalValidity 7200"}, summarize(changes))
+
+	// Raising the delay past the current validity, the validity goes first.
+	d = Desired{"Manager": {"delay": "1209600", "proposalValidity": "2419200"}}
+	changes, err = Plan(context.Background(), state, testManifest(), d, owner)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Manager.setProposalValidity 2419200", "Manager.setDelay 1209600"}, summarize(changes))
+}
+
+func TestPlanRejectsBadValues(t *testing.T) {
+	for _, d := range []Desired{
+		{"Reserve": {"maxSupply": "-1"}},
+		{"Reserve": {"maxSupply": "1e18"}},
+		{"Manager": {"issuancePaused": "yes"}},
+		{"Manager": {"vetoer": "0x00000000000000000000000000000000000000Bb"}},
+		{"Manager": {"vetoer": "@Basket"}},
+	} {
+		_, err := Plan(context.Background(), chainState(), testManifest(), d, owner)
+		assert.Error(t, err, "%v", d)
+	}
+}
+
+func TestLoadDesired(t *testing.T) {
+	dir, err := ioutil.TempDir("", "params")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "params.json")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"Manager": {"seigniorage": "5"}}`), 0644))
+	d, err := LoadDesired(path)
+	require.NoError(t, err)
+	assert.Equal(t, "5", d["Manager"]["seigniorage"])
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"Manager": {"totalSupply": "5"}}`), 0644))
+	_, err = LoadDesired(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+// Package prompt implements the interactive confirmations that operator tools ask for before
+// doing anything consequential.
+package prompt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Prompter asks questions on Out and reads answers from In.
+type Prompter struct {
+	in  *bufio.Reader
+	Out io.Writer
+}
+
+// New returns a Prompter reading from in and writing to out.
+func New(in io.Reader, out io.Writer) *Prompter {
+	return &Prompter{in: bufio.NewReader(in), Out: out}
+}
+
+// Ask prints question and returns the answer, without surrounding whitespace.
+func (p *Prompter) Ask(question string) (string, error) {
+	fmt.Fprint(p.Out, question+" ")
+	line, err := p.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", errors.Wrap(err, "reading answer")
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// Confirm asks a yes/no question, and returns nil only if the answer is "yes".
+func (p *Prompter) Confirm(question string) error {
+	answer, err := p.Ask(question + " [yes/no]")
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(answer) != "yes" {
+		return errors.New("not confirmed")
+	}
+	return nil
+}
+
+// Expect asks the operator to type want, and returns nil only if they typed it exactly.
+func (p *Prompter) Expect(question, want string) error {
+	answer, err := p.Ask(question)
+	if err != nil {
+		return err
+	}
+	if answer != want {
+		return errors.Errorf("expected %q, got %q", want, answer)
+	}
+	return nil
+}
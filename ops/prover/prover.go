@@ -0,0 +1,325 @@
+// Package prover runs the formal specs in certora/ on a prover and reads back the outcome of each
+// rule, so that what is proved of the contracts is kept, and checked, in the repo.
+//
+// A Run is one job: the contract to verify, the spec to verify it against, and its scene, the
+// other contracts that the prover models along with it, as linked to its storage. The runs are
+// committed in certora/runs.json, and Conf turns each into the configuration file that the prover
+// takes. A Prover starts a job from that file and polls it until it is done; Certora is the one
+// we use, and another prover need only start a job and report how each rule came out.
+package prover
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Run is one prover job, as committed in certora/runs.json. Paths are relative to the repo.
+type Run struct {
+	Name string `json:"name"`
+
+	// Contract is verified against Spec. Files are the sources of the scene: the file of
+	// Contract, and of each contract it is linked to.
+	Contract string   `json:"contract"`
+	Spec     string   `json:"spec"`
+	Files    []string `json:"files"`
+
+	// Link sets a contract's address-typed storage variables to the other contracts of the
+	// scene, each as "Contract:variable=Other".
+	Link []string `json:"link,omitempty"`
+
+	// Rules, if set, are the only rules to check; otherwise, the spec's every rule is.
+	Rules []string `json:"rules,omitempty"`
+
+	// LoopIter is how many times the prover unrolls each loop, and OptimisticLoop whether it
+	// assumes that loops exit within that many iterations, rather than checking that they do.
+	LoopIter       int  `json:"loopIter,omitempty"`
+	OptimisticLoop bool `json:"optimisticLoop,omitempty"`
+}
+
+// ReadRuns reads the runs in path, and checks that each is complete and named uniquely.
+func ReadRuns(path string) ([]Run, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the prover runs")
+	}
+	var runs []Run
+	if err := json.Unmarshal(b, &runs); err != nil {
+		return nil, errors.Wrapf(err, "parsing %v", path)
+	}
+	if len(runs) == 0 {
+		return nil, errors.Errorf("%v lists no runs", path)
+	}
+	names := make(map[string]bool)
+	for i, r := range runs {
+		switch {
+		case r.Name == "":
+			return nil, errors.Errorf("%v: run %v has no name", path, i)
+		case names[r.Name]:
+			return nil, errors.Errorf("%v: two runs are named %v", path, r.Name)
+		case r.Contract == "" || r.Spec == "" || len(r.Files) == 0:
+			return nil, errors.Errorf("%v: run %v needs a contract, a spec, and its files", path, r.Name)
+		}
+		names[r.Name] = true
+	}
+	return runs, nil
+}
+
+// Conf returns the run's configuration file for certoraRun, compiling its files with solc. The
+// job is submitted without waiting for it, so that a Prover can poll it instead.
+func (r Run) Conf(solc string) ([]byte, error) {
+	conf := map[string]interface{}{
+		"files":            r.Files,
+		"verify":           r.Contract + ":" + r.Spec,
+		"solc":             solc,
+		"msg":              r.Name,
+		"wait_for_results": "none",
+	}
+	if len(r.Link) > 0 {
+		conf["link"] = r.Link
+	}
+	if len(r.Rules) > 0 {
+		conf["rule"] = r.Rules
+	}
+	if r.LoopIter > 0 {
+		conf["loop_iter"] = strconv.Itoa(r.LoopIter)
+	}
+	if r.OptimisticLoop {
+		conf["optimistic_loop"] = true
+	}
+	b, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return nil, errors.Wrapf(err, "marshaling the configuration of %v", r.Name)
+	}
+	return append(b, '\n'), nil
+}
+
+// WriteConf writes the run's configuration to dir/<name>.conf, and returns its path.
+func (r Run) WriteConf(dir, solc string) (string, error) {
+	b, err := r.Conf(solc)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrap(err, "creating the configuration directory")
+	}
+	p := filepath.Join(dir, r.Name+".conf")
+	return p, errors.Wrapf(ioutil.WriteFile(p, b, 0644), "writing %v", p)
+}
+
+// Job is a run that a prover has started.
+type Job struct {
+	Run string `json:"run"`
+
+	// URL is the job's report, which also identifies it to Poll.
+	URL string `json:"url"`
+}
+
+// Rule is how one rule of a spec came out. Status is the prover's: SUCCESS if the rule was
+// proved. A parametric rule, checked against each of the contract's methods, fails if it fails
+// against any, and Failing lists those.
+type Rule struct {
+	Name    string   `json:"name"`
+	Status  string   `json:"status"`
+	Failing []string `json:"failing,omitempty"`
+}
+
+// Passed reports whether the rule was proved.
+func (r Rule) Passed() bool {
+	return r.Status == success
+}
+
+const success = "SUCCESS"
+
+// Result is how every rule of a job came out, sorted by rule.
+type Result struct {
+	Job   Job    `json:"job"`
+	Rules []Rule `json:"rules"`
+}
+
+// Failed returns the rules that weren't proved.
+func (r *Result) Failed() []Rule {
+	var failed []Rule
+	for _, rule := range r.Rules {
+		if !rule.Passed() {
+			failed = append(failed, rule)
+		}
+	}
+	return failed
+}
+
+// Prover starts jobs and polls them.
+type Prover interface {
+	// Start starts the job of the run whose configuration is conf.
+	Start(ctx context.Context, run, conf string) (*Job, error)
+
+	// Poll returns the job's result once it is done, and nil while it still runs.
+	Poll(ctx context.Context, job *Job) (*Result, error)
+}
+
+// Wait polls job every interval until it is done, or ctx is.
+func Wait(ctx context.Context, p Prover, job *Job, interval time.Duration) (*Result, error) {
+	for {
+		r, err := p.Poll(ctx, job)
+		if err != nil || r != nil {
+			return r, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrapf(ctx.Err(), "waiting for %v (%v)", job.Run, job.URL)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Certora runs jobs on the Certora Prover with certoraRun, which reads the key to submit them
+// with from $CERTORAKEY.
+type Certora struct {
+	// Command is the certoraRun executable.
+	Command string
+
+	HTTP *http.Client
+}
+
+// NewCertora returns a Certora that submits jobs with command.
+func NewCertora(command string) *Certora {
+	return &Certora{Command: command, HTTP: &http.Client{Timeout: time.Minute}}
+}
+
+// reportURL matches the link to its report that certoraRun prints once it has submitted a job.
+var reportURL = regexp.MustCompile(`https://prover\.certora\.com/output/[^\s"']+`)
+
+// Start submits the job with certoraRun.
+func (c *Certora) Start(ctx context.Context, run, conf string) (*Job, error) {
+	cmd := exec.CommandContext(ctx, c.Command, conf)
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "running %v %v: %s", c.Command, conf, lastLines(out.String(), 10))
+	}
+	link := reportURL.FindString(out.String())
+	if link == "" {
+		return nil, errors.Errorf("%v %v printed no job link: %s", c.Command, conf, lastLines(out.String(), 10))
+	}
+	return &Job{Run: run, URL: link}, nil
+}
+
+// Poll fetches the job's output.json, which the report is rendered from. The prover serves it
+// once the job is done, and not found until then.
+func (c *Certora) Poll(ctx context.Context, job *Job) (*Result, error) {
+	u, err := url.Parse(job.URL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing the link of %v", job.Run)
+	}
+	u.Path = path.Join(u.Path, "output.json")
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "building the request for results")
+	}
+	resp, err := c.HTTP.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "polling %v", job.Run)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading the results of %v", job.Run)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, errors.Errorf("polling %v: HTTP %v: %s", job.Run, resp.StatusCode, lastLines(string(b), 3))
+	}
+	rules, err := ParseOutput(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "the results of %v", job.Run)
+	}
+	return &Result{Job: *job, Rules: rules}, nil
+}
+
+// ParseOutput reads the rules of Certora's output.json, in which each rule's value is its
+// status, or, for a parametric rule, the methods it was checked against listed under each status.
+func ParseOutput(b []byte) ([]Rule, error) {
+	var output struct {
+		Rules map[string]json.RawMessage `json:"rules"`
+	}
+	if err := json.Unmarshal(b, &output); err != nil {
+		return nil, errors.Wrap(err, "parsing output.json")
+	}
+	if len(output.Rules) == 0 {
+		return nil, errors.New("output.json has no rules")
+	}
+	var rules []Rule
+	for name, raw := range output.Rules {
+		rule := Rule{Name: name}
+		if err := json.Unmarshal(raw, &rule.Status); err != nil {
+			var methods map[string][]string
+			if err := json.Unmarshal(raw, &methods); err != nil {
+				return nil, errors.Errorf("rule %v: unexpected result %s", name, raw)
+			}
+			rule.Status = success
+			for status, list := range methods {
+				if status != success && len(list) > 0 {
+					rule.Status = "FAILURE"
+					rule.Failing = append(rule.Failing, list...)
+				}
+			}
+			sort.Strings(rule.Failing)
+		}
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules, nil
+}
+
+// Format renders the results as a table of each run's rules, with the methods that a parametric
+// rule fails against, and a line for each run with its report.
+func Format(results []*Result) string {
+	width := len("rule")
+	for _, r := range results {
+		for _, rule := range r.Rules {
+			if len(rule.Name) > width {
+				width = len(rule.Name)
+			}
+		}
+	}
+	var b bytes.Buffer
+	for _, r := range results {
+		fmt.Fprintf(&b, "%v: %v of %v rules proved; %v\n",
+			r.Job.Run, len(r.Rules)-len(r.Failed()), len(r.Rules), r.Job.URL)
+		for _, rule := range r.Rules {
+			line := fmt.Sprintf("  %-*v  %v", width, rule.Name, rule.Status)
+			if len(rule.Failing) > 0 {
+				line += " in " + strings.Join(rule.Failing, ", ")
+			}
+			fmt.Fprintln(&b, line)
+		}
+	}
+	return b.String()
+}
+
+// lastLines returns the last n lines of s, where a tool puts its error.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,163 @@
+package prover
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRuns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prover")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "runs.json")
+
+	for _, bad := range []string{
+		`[]`,
+		`{}`,
+		`[{"contract": "Reserve", "spec": "Reserve.spec", "files": ["Reserve.sol"]}]`,
+		`[{"name": "reserve", "spec": "Reserve.spec", "files": ["Reserve.sol"]}]`,
+		`[{"name": "reserve", "contract": "Reserve", "spec": "Reserve.spec", "files": ["Reserve.sol"]},
+		  {"name": "reserve", "contract": "Vault", "spec": "Vault.spec", "files": ["Vault.sol"]}]`,
+	} {
+		require.NoError(t, ioutil.WriteFile(path, []byte(bad), 0644))
+		_, err := ReadRuns(path)
+		assert.Error(t, err, bad)
+	}
+}
+
+// TestRepoRuns checks that the repo's runs are complete, and name files that exist.
+func TestRepoRuns(t *testing.T) {
+	runs, err := ReadRuns("../../certora/runs.json")
+	require.NoError(t, err)
+	for _, r := range runs {
+		for _, f := range append([]string{r.Spec}, r.Files...) {
+			_, err := os.Stat(filepath.Join("../..", f))
+			assert.NoError(t, err, r.Name)
+		}
+	}
+}
+
+func TestConf(t *testing.T) {
+	r := Run{
+		Name:           "reserve",
+		Contract:       "Reserve",
+		Spec:           "certora/specs/Reserve.spec",
+		Files:          []string{"contracts/rsv/Reserve.sol", "contracts/rsv/ReserveEternalStorage.sol"},
+		Link:           []string{"Reserve:trustedData=ReserveEternalStorage"},
+		LoopIter:       2,
+		OptimisticLoop: true,
+	}
+	b, err := r.Conf("solc-0.5.7")
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "files": [
+    "contracts/rsv/Reserve.sol",
+    "contracts/rsv/ReserveEternalStorage.sol"
+  ],
+  "link": [
+    "Reserve:trustedData=ReserveEternalStorage"
+  ],
+  "loop_iter": "2",
+  "msg": "reserve",
+  "optimistic_loop": true,
+  "solc": "solc-0.5.7",
+  "verify": "Reserve:certora/specs/Reserve.spec",
+  "wait_for_results": "none"
+}
+`, string(b))
+
+	r.Rules = []string{"mintAddsToSupply"}
+	b, err = r.Conf("solc")
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"rule": [
+    "mintAddsToSupply"
+  ]`)
+}
+
+func TestParseOutput(t *testing.T) {
+	rules, err := ParseOutput([]byte(`{
+		"rules": {
+			"mintAddsToSupply": "SUCCESS",
+			"supplyWithinMax": {"SUCCESS": ["mint(address,uint256)"], "FAILURE": ["upgradeTo(address)", "changeMaxSupply(uint256)"]},
+			"pausedBlocksMint": "FAILURE",
+			"onlyPauserPauses": {"SUCCESS": ["pause()", "unpause()"], "FAILURE": []}
+		}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, []Rule{
+		{Name: "mintAddsToSupply", Status: "SUCCESS"},
+		{Name: "onlyPauserPauses", Status: "SUCCESS"},
+		{Name: "pausedBlocksMint", Status: "FAILURE"},
+		{Name: "supplyWithinMax", Status: "FAILURE", Failing: []string{"changeMaxSupply(uint256)", "upgradeTo(address)"}},
+	}, rules)
+
+	result := &Result{Job: Job{Run: "reserve", URL: "https://prover.certora.com/output/1/abc"}, Rules: rules}
+	assert.Len(t, result.Failed(), 2)
+	assert.Equal(t, ""+
+		"reserve: 2 of 4 rules proved; https://prover.certora.com/output/1/abc\n"+
+		"  mintAddsToSupply  SUCCESS\n"+
+		"  onlyPauserPauses  SUCCESS\n"+
+		"  pausedBlocksMint  FAILURE\n"+
+		"  supplyWithinMax   FAILURE in changeMaxSupply(uint256), upgradeTo(address)\n",
+		Format([]*Result{result}))
+
+	for _, bad := range []string{`{}`, `{"rules": {}}`, `{"rules": {"a": 1}}`, `[`} {
+		_, err := ParseOutput([]byte(bad))
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestCertoraPoll(t *testing.T) {
+	done := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/output/1/abc/output.json", r.URL.Path)
+		assert.Equal(t, "key", r.URL.Query().Get("anonymousKey"))
+		if !done {
+			done = true
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"rules": {"mintAddsToSupply": "SUCCESS"}}`))
+	}))
+	defer server.Close()
+
+	c := NewCertora("certoraRun")
+	job := &Job{Run: "reserve", URL: server.URL + "/output/1/abc?anonymousKey=key"}
+	r, err := c.Poll(context.Background(), job)
+	require.NoError(t, err)
+	assert.Nil(t, r, "the job is still running")
+
+	r, err = Wait(context.Background(), c, job, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Job: *job, Rules: []Rule{{Name: "mintAddsToSupply", Status: "SUCCESS"}}}, r)
+}
+
+func TestCertoraStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prover")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// A stand-in for certoraRun, printing what it does once it has submitted a job.
+	fake := filepath.Join(dir, "certoraRun")
+	require.NoError(t, ioutil.WriteFile(fake, []byte(`#!/bin/sh
+echo "Job submitted to server"
+echo "Follow your job and see verification results at https://prover.certora.com/output/1/abc?anonymousKey=key"
+`), 0755))
+	job, err := NewCertora(fake).Start(context.Background(), "reserve", "reserve.conf")
+	require.NoError(t, err)
+	assert.Equal(t, &Job{Run: "reserve", URL: "https://prover.certora.com/output/1/abc?anonymousKey=key"}, job)
+
+	_, err = NewCertora("true").Start(context.Background(), "reserve", "reserve.conf")
+	assert.Error(t, err, "no link printed")
+	_, err = NewCertora("false").Start(context.Background(), "reserve", "reserve.conf")
+	assert.Error(t, err)
+}
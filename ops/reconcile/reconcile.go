@@ -0,0 +1,271 @@
+// Package reconcile compares, block by block, what the Vault should hold of each basket token
+// to back the outstanding RSV supply with what it actually holds, and keeps a record of the
+// differences over time. Where the deployment has an InsuranceFund, it also tracks whether the
+// fund holds enough to make good each shortfall.
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+)
+
+// Entry compares the Vault's balance of one token with the balance needed, at one block.
+// Amounts are in qTokens.
+type Entry struct {
+	Time    time.Time      `json:"time"`
+	Block   uint64         `json:"block"`
+	Token   common.Address `json:"token"`
+	Symbol  string         `json:"symbol,omitempty"`
+	Supply  string         `json:"supply"` // attoRSV
+	Weight  string         `json:"weight"` // aqToken/RSV
+	Needed  string         `json:"needed"`
+	Balance string         `json:"balance"`
+
+	// Surplus is Balance - Needed; negative when the token is short.
+	Surplus string `json:"surplus"`
+
+	// Insurance is the InsuranceFund's balance of the token, if the deployment has a fund.
+	Insurance string `json:"insurance,omitempty"`
+}
+
+// Short reports whether the Vault holds less of the token than it needs.
+func (e Entry) Short() bool {
+	return len(e.Surplus) > 0 && e.Surplus[0] == '-'
+}
+
+// Uncovered reports whether the Vault is short of the token by more than the InsuranceFund
+// could top it up with.
+func (e Entry) Uncovered() bool {
+	if !e.Short() {
+		return false
+	}
+	surplus, _ := new(big.Int).SetString(e.Surplus, 10)
+	insurance, ok := new(big.Int).SetString(e.Insurance, 10)
+	if !ok {
+		return true
+	}
+	return surplus.Add(surplus, insurance).Sign() < 0
+}
+
+// Compare returns an entry for each token in s.
+func Compare(s *metrics.State, now time.Time) []Entry {
+	var entries []Entry
+	for _, t := range s.Tokens {
+		needed := s.Needed(t)
+		e := Entry{
+			Time:    now.UTC(),
+			Block:   s.Block,
+			Token:   t.Address,
+			Symbol:  t.Symbol,
+			Supply:  s.Supply.String(),
+			Weight:  t.Weight.String(),
+			Needed:  needed.String(),
+			Balance: t.Balance.String(),
+			Surplus: new(big.Int).Sub(t.Balance, needed).String(),
+		}
+		if t.Insurance != nil {
+			e.Insurance = t.Insurance.String()
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Node is what the Reconciler needs of an Ethereum node, besides reading the state.
+type Node interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// Reconciler compares every Interval-th block. It appends an entry to the record whenever a
+// token's surplus changes, so the record is the history of the discrepancies.
+type Reconciler struct {
+	Node Node
+
+	// ReadAt reads the state at a block, as metrics.Reader.ReadAt does.
+	ReadAt func(ctx context.Context, block uint64) (*metrics.State, error)
+
+	// Interval is how many blocks apart the comparisons are; zero means every block.
+	Interval uint64
+
+	// Record is the JSON-lines file that entries are appended to.
+	Record string
+
+	PollInterval time.Duration
+
+	mu        sync.Mutex
+	next      uint64
+	latest    []Entry
+	surpluses map[common.Address]string
+	shortages uint64
+	uncovered uint64
+}
+
+// Run compares blocks as they arrive until ctx is done.
+func (r *Reconciler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := r.Step(ctx); err != nil {
+			log.Printf("reconcile: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// maxLag is how far behind the head the Reconciler may fall before it skips ahead. Nodes without
+// archive state keep the state of only the last 128 blocks.
+const maxLag = 100
+
+// Step compares each block due up to the chain head. The first Step starts at the head.
+func (r *Reconciler) Step(ctx context.Context) error {
+	header, err := r.Node.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "getting the latest block")
+	}
+	head := header.Number.Uint64()
+	interval := r.Interval
+	if interval == 0 {
+		interval = 1
+	}
+	if r.next == 0 {
+		r.next = head
+	}
+	if head > r.next+maxLag {
+		log.Printf("reconcile: skipping blocks %v to %v, too far behind the head", r.next, head-1)
+		r.next = head
+	}
+	for ; r.next <= head; r.next += interval {
+		s, err := r.ReadAt(ctx, r.next)
+		if err != nil {
+			return errors.Wrapf(err, "reading block %v", r.next)
+		}
+		if err := r.observe(Compare(s, time.Now())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observe records the entries whose surplus changed.
+func (r *Reconciler) observe(entries []Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var changed []Entry
+	short, uncovered := false, false
+	for _, e := range entries {
+		if last, ok := r.surpluses[e.Token]; !ok || last != e.Surplus {
+			changed = append(changed, e)
+		}
+		short = short || e.Short()
+		uncovered = uncovered || e.Uncovered()
+	}
+	if err := r.append(changed); err != nil {
+		return err
+	}
+	surpluses := map[common.Address]string{}
+	for _, e := range entries {
+		surpluses[e.Token] = e.Surplus
+		switch {
+		case e.Short() && e.Insurance != "" && !e.Uncovered():
+			log.Printf("reconcile: block %v: the Vault is short %v of %v, which the insurance fund's %v covers", e.Block, e.Surplus[1:], e.Token.Hex(), e.Insurance)
+		case e.Short():
+			log.Printf("reconcile: block %v: the Vault is short %v of %v", e.Block, e.Surplus[1:], e.Token.Hex())
+		}
+	}
+	r.surpluses, r.latest = surpluses, entries
+	if short {
+		r.shortages++
+	}
+	if uncovered {
+		r.uncovered++
+	}
+	return nil
+}
+
+func (r *Reconciler) append(entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(r.Record, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, "opening the record")
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return errors.Wrap(err, "writing the record")
+		}
+	}
+	return errors.Wrap(f.Close(), "writing the record")
+}
+
+// The reconciler's metrics.
+var (
+	shortBlocks = metrics.Definition{Name: "rsv_reconcile_short_blocks_total", Kind: "counter", Help: "Blocks compared at which the Vault was short of some token.",
+		Panel: &metrics.Panel{Row: "Reconciliation", Title: "Short blocks per hour", Expr: "increase(rsv_reconcile_short_blocks_total[1h])", Legend: "short blocks"}}
+	uncoveredBlocks = metrics.Definition{Name: "rsv_reconcile_uncovered_blocks_total", Kind: "counter", Help: "Blocks compared at which the Vault was short of some token by more than the insurance fund holds of it.",
+		Panel: &metrics.Panel{Row: "Reconciliation", Title: "Uncovered blocks per hour", Expr: "increase(rsv_reconcile_uncovered_blocks_total[1h])", Legend: "uncovered blocks"}}
+	blockMetric = metrics.Definition{Name: "rsv_reconcile_block", Kind: "gauge", Help: "Block of the latest comparison."}
+	needed      = metrics.Definition{Name: "rsv_reconcile_needed", Kind: "gauge", Help: "Balance of each token, in qTokens, that the Vault needs to back the supply."}
+	balance     = metrics.Definition{Name: "rsv_reconcile_balance", Kind: "gauge", Help: "Balance of each token, in qTokens, that the Vault holds."}
+	insurance   = metrics.Definition{Name: "rsv_reconcile_insurance", Kind: "gauge", Help: "Balance of each token, in qTokens, that the insurance fund holds."}
+	surplus     = metrics.Definition{Name: "rsv_reconcile_surplus", Kind: "gauge", Help: "Balance less the balance needed, of each token, in qTokens; negative when short.",
+		Panel: &metrics.Panel{Row: "Reconciliation", Title: "Surplus by token (qTokens)", Legend: "{{symbol}}"}}
+)
+
+// Definitions are the reconciler's metrics.
+var Definitions = []metrics.Definition{surplus, shortBlocks, uncoveredBlocks, needed, balance, insurance, blockMetric}
+
+// ServeHTTP serves the latest comparison as Prometheus metrics.
+func (r *Reconciler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	latest, shortages, uncovered := r.latest, r.shortages, r.uncovered
+	r.mu.Unlock()
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w := metrics.NewTextWriter(rw)
+	w.Metric(shortBlocks, float64(shortages))
+	w.Metric(uncoveredBlocks, float64(uncovered))
+	if len(latest) > 0 {
+		w.Metric(blockMetric, float64(latest[0].Block))
+	}
+	for _, m := range []struct {
+		def   metrics.Definition
+		value func(e Entry) string
+	}{
+		{needed, func(e Entry) string { return e.Needed }},
+		{balance, func(e Entry) string { return e.Balance }},
+		{surplus, func(e Entry) string { return e.Surplus }},
+		{insurance, func(e Entry) string { return e.Insurance }},
+	} {
+		w.Define(m.def)
+		for _, e := range latest {
+			if m.value(e) == "" {
+				continue
+			}
+			v, _ := new(big.Float).SetString(m.value(e))
+			f, _ := v.Float64()
+			w.Sample(m.def.Name, []string{"token", e.Token.Hex(), "symbol", e.Symbol}, f)
+		}
+	}
+	if err := w.Err(); err != nil {
+		log.Printf("reconcile: writing response: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package reconcile
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+)
+
+var usdc = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+
+type fakeNode struct{ head uint64 }
+
+func (n *fakeNode) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: new(big.Int).SetUint64(n.head)}, nil
+}
+
+func readRecord(t *testing.T, path string) []Entry {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		entries = append(entries, e)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func TestReconciler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reconcile")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// 1000 RSV, backed by half a USDC each, with the balance at each block.
+	balances := map[uint64]int64{10: 500e6, 12: 500e6, 14: 499e6, 16: 499e6}
+	var read []uint64
+	node := &fakeNode{head: 10}
+	r := &Reconciler{
+		Node: node,
+		ReadAt: func(ctx context.Context, block uint64) (*metrics.State, error) {
+			read = append(read, block)
+			return &metrics.State{
+				Block:       block,
+				Supply:      new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)),
+				RSVDecimals: 18,
+				Tokens: []metrics.Token{{
+					Address: usdc, Symbol: "USDC", Decimals: 6,
+					Weight:  new(big.Int).Mul(big.NewInt(5e5), big.NewInt(1e18)),
+					Balance: big.NewInt(balances[block]),
+				}},
+			}, nil
+		},
+		Interval: 2,
+		Record:   filepath.Join(dir, "record.jsonl"),
+	}
+	ctx := context.Background()
+
+	require.NoError(t, r.Step(ctx))
+	node.head = 17
+	require.NoError(t, r.Step(ctx))
+	assert.Equal(t, []uint64{10, 12, 14, 16}, read)
+
+	// Only changes are recorded.
+	entries := readRecord(t, r.Record)
+	require.Len(t, entries, 2)
+	assert.Equal(t, uint64(10), entries[0].Block)
+	assert.Equal(t, "0", entries[0].Surplus)
+	assert.False(t, entries[0].Short())
+	assert.Equal(t, uint64(14), entries[1].Block)
+	assert.Equal(t, "500000000", entries[1].Needed)
+	assert.Equal(t, "-1000000", entries[1].Surplus)
+	assert.True(t, entries[1].Short())
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	assert.Contains(t, body, "rsv_reconcile_short_blocks_total 2\n")
+	assert.Contains(t, body, "rsv_reconcile_block 16\n")
+	assert.Contains(t, body, `rsv_reconcile_surplus{token="`+usdc.Hex()+`",symbol="USDC"} -1e+06`+"\n")
+
+	// Far behind the head, it skips ahead rather than read state the node may have pruned.
+	read, node.head = nil, 1000
+	require.NoError(t, r.Step(ctx))
+	assert.Equal(t, []uint64{1000}, read)
+}
+
+func TestInsurance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reconcile")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// 1000 RSV, backed by half a USDC each, and the insurance fund's balance at each block.
+	insurance := map[uint64]int64{10: 5e5, 11: 2e6}
+	node := &fakeNode{head: 10}
+	r := &Reconciler{
+		Node: node,
+		ReadAt: func(ctx context.Context, block uint64) (*metrics.State, error) {
+			return &metrics.State{
+				Block:       block,
+				Supply:      new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)),
+				RSVDecimals: 18,
+				Tokens: []metrics.Token{{
+					Address: usdc, Symbol: "USDC", Decimals: 6,
+					Weight:    new(big.Int).Mul(big.NewInt(5e5), big.NewInt(1e18)),
+					Balance:   big.NewInt(499e6),
+					Insurance: big.NewInt(insurance[block]),
+				}},
+			}, nil
+		},
+		Record: filepath.Join(dir, "record.jsonl"),
+	}
+	ctx := context.Background()
+
+	// Short a USDC, with half a USDC in the fund: uncovered.
+	require.NoError(t, r.Step(ctx))
+	// With two USDC in the fund, the shortfall is covered.
+	node.head = 11
+	require.NoError(t, r.Step(ctx))
+
+	entries := readRecord(t, r.Record)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "500000", entries[0].Insurance)
+	assert.True(t, entries[0].Uncovered())
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	assert.Contains(t, body, "rsv_reconcile_short_blocks_total 2\n")
+	assert.Contains(t, body, "rsv_reconcile_uncovered_blocks_total 1\n")
+	assert.Contains(t, body, `rsv_reconcile_insurance{token="`+usdc.Hex()+`",symbol="USDC"} 2e+06`+"\n")
+
+	// Without a fund, every shortfall is uncovered.
+	e := entries[0]
+	e.Insurance = ""
+	assert.True(t, e.Uncovered())
+	e.Surplus = "0"
+	assert.False(t, e.Uncovered())
+}
@@ -0,0 +1,151 @@
+package relay
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/authorize"
+	"github.com/reserve-protocol/rsv-beta/ops/signer"
+)
+
+// The functions below build and sign requests for clients of the relayer, such as a wallet
+// backend that lets holders with no ether issue and move RSV. Each takes the signer's next
+// unused Relayer nonce, Relayer.nonce(signer) plus one for each of their requests still
+// pending, and rsv, the Reserve that the Relayer forwards to.
+
+// SignTransfer returns from's request to transfer amount attoRSV to to, paying fee.
+func SignTransfer(ctx context.Context, from signer.Signer, rsv, to common.Address, amount, fee, nonce *big.Int) (Request, error) {
+	return sign(ctx, from, rsv, nonce, Request{
+		Kind:   KindTransfer,
+		From:   from.Address(),
+		To:     to,
+		Amount: amount.String(),
+		Fee:    fee.String(),
+	})
+}
+
+// SignApprove returns holder's request to approve spender for amount attoRSV, paying fee.
+func SignApprove(ctx context.Context, holder signer.Signer, rsv, spender common.Address, amount, fee, nonce *big.Int) (Request, error) {
+	return sign(ctx, holder, rsv, nonce, Request{
+		Kind:    KindApprove,
+		Holder:  holder.Address(),
+		Spender: spender,
+		Amount:  amount.String(),
+		Fee:     fee.String(),
+	})
+}
+
+// SignTransferFrom returns spender's request to move amount attoRSV from holder to to, out of
+// holder's allowance for spender, paying fee.
+func SignTransferFrom(ctx context.Context, spender signer.Signer, rsv, holder, to common.Address, amount, fee, nonce *big.Int) (Request, error) {
+	return sign(ctx, spender, rsv, nonce, Request{
+		Kind:    KindTransferFrom,
+		Holder:  holder,
+		Spender: spender.Address(),
+		To:      to,
+		Amount:  amount.String(),
+		Fee:     fee.String(),
+	})
+}
+
+// SignPermit returns holder's request to permit spender to spend amount attoRSV, until the Unix
+// time deadline, paying fee. domain is the Reserve's (see authorize.Reserve), and permitNonce
+// is holder's next unused Reserve nonce, Reserve.nonces(holder) plus one for each of their
+// permits still pending.
+func SignPermit(
+	ctx context.Context, holder signer.Signer, domain authorize.Domain, spender common.Address,
+	amount, deadline, fee, nonce, permitNonce *big.Int,
+) (Request, error) {
+	permit := authorize.Permit{
+		Holder:   holder.Address(),
+		Spender:  spender,
+		Value:    amount,
+		Nonce:    permitNonce,
+		Deadline: deadline,
+	}
+	sig, err := authorize.Sign(ctx, holder, permit.Hash(domain))
+	if err != nil {
+		return Request{}, errors.Wrap(err, "signing permit")
+	}
+	return sign(ctx, holder, domain.Contract, nonce, Request{
+		Kind:     KindPermit,
+		Holder:   holder.Address(),
+		Spender:  spender,
+		Amount:   amount.String(),
+		Fee:      fee.String(),
+		Deadline: deadline.String(),
+		Permit:   append(append(append([]byte(nil), sig.R[:]...), sig.S[:]...), sig.V),
+	})
+}
+
+// sign fills in req's signature by s over nonce.
+func sign(ctx context.Context, s signer.Signer, rsv common.Address, nonce *big.Int, req Request) (Request, error) {
+	req.Sig = make([]byte, 65) // a placeholder, for parse
+	p, err := req.parse()
+	if err != nil {
+		return Request{}, err
+	}
+	if req.Sig, err = Sign(ctx, s, p.hash(rsv, nonce)); err != nil {
+		return Request{}, errors.Wrapf(err, "signing %v request", req.Kind)
+	}
+	return req, nil
+}
+
+// Verify checks that r is well formed and that its signer signed it over nonce, as the
+// relayer will before accepting it.
+func (r *Request) Verify(rsv common.Address, nonce *big.Int) error {
+	p, err := r.parse()
+	if err != nil {
+		return err
+	}
+	return p.verify(rsv, nonce)
+}
+
+// VerifyPermit checks that r is a permit, and that its holder signed the permit over
+// permitNonce in domain, as the Reserve will.
+func (r *Request) VerifyPermit(domain authorize.Domain, permitNonce *big.Int) error {
+	p, err := r.parse()
+	if err != nil {
+		return err
+	}
+	if p.Kind != KindPermit {
+		return errors.Errorf("%v request is not a permit", p.Kind)
+	}
+	return p.verifyPermit(domain, permitNonce)
+}
+
+func (p *parsed) verify(rsv common.Address, nonce *big.Int) error {
+	recovered, err := Recover(p.hash(rsv, nonce), p.Sig)
+	if err != nil {
+		return err
+	}
+	if recovered != p.Signer() {
+		return errors.Errorf("signature is not from %v over nonce %v", p.Signer().Hex(), nonce)
+	}
+	return nil
+}
+
+func (p *parsed) verifyPermit(domain authorize.Domain, permitNonce *big.Int) error {
+	var sig authorize.Signature
+	copy(sig.R[:], p.Permit[:32])
+	copy(sig.S[:], p.Permit[32:64])
+	sig.V = p.Permit[64]
+	permit := authorize.Permit{
+		Holder:   p.Holder,
+		Spender:  p.Spender,
+		Value:    p.amount,
+		Nonce:    permitNonce,
+		Deadline: p.deadline,
+	}
+	recovered, err := authorize.Recover(permit.Hash(domain), sig)
+	if err != nil {
+		return errors.Wrap(err, "permit")
+	}
+	if recovered != p.Holder {
+		return errors.Errorf("permit is not signed by %v over Reserve nonce %v", p.Holder.Hex(), permitNonce)
+	}
+	return nil
+}
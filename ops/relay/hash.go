@@ -0,0 +1,125 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/signer"
+)
+
+// The hash functions below mirror the message construction in contracts/rsv/Relayer.sol:
+//
+//	keccak256(abi.encodePacked(address(trustedRSV), "<method>", <args...>, nonce[signer]))
+//
+// wrapped in the "\x19Ethereum Signed Message:\n32" prefix that ECDSA.toEthSignedMessageHash adds.
+
+// TransferHash is the hash that `from` signs to authorize Relayer.forwardTransfer.
+func TransferHash(rsv, from, to common.Address, amount, fee, nonce *big.Int) common.Hash {
+	return ethSignedHash(crypto.Keccak256Hash(
+		rsv.Bytes(),
+		[]byte("forwardTransfer"),
+		from.Bytes(),
+		to.Bytes(),
+		uint256(amount),
+		uint256(fee),
+		uint256(nonce),
+	))
+}
+
+// ApproveHash is the hash that `holder` signs to authorize Relayer.forwardApprove.
+func ApproveHash(rsv, holder, spender common.Address, amount, fee, nonce *big.Int) common.Hash {
+	return ethSignedHash(crypto.Keccak256Hash(
+		rsv.Bytes(),
+		[]byte("forwardApprove"),
+		holder.Bytes(),
+		spender.Bytes(),
+		uint256(amount),
+		uint256(fee),
+		uint256(nonce),
+	))
+}
+
+// TransferFromHash is the hash that `spender` signs to authorize Relayer.forwardTransferFrom.
+func TransferFromHash(rsv, holder, spender, to common.Address, amount, fee, nonce *big.Int) common.Hash {
+	return ethSignedHash(crypto.Keccak256Hash(
+		rsv.Bytes(),
+		[]byte("forwardTransferFrom"),
+		holder.Bytes(),
+		spender.Bytes(),
+		to.Bytes(),
+		uint256(amount),
+		uint256(fee),
+		uint256(nonce),
+	))
+}
+
+// PermitHash is the hash that `holder` signs to authorize Relayer.forwardPermit: the fee for
+// relaying an EIP-2612 permit, which holder signs separately (see authorize.Permit).
+func PermitHash(rsv, holder, spender common.Address, amount, deadline, fee, nonce *big.Int) common.Hash {
+	return ethSignedHash(crypto.Keccak256Hash(
+		rsv.Bytes(),
+		[]byte("forwardPermit"),
+		holder.Bytes(),
+		spender.Bytes(),
+		uint256(amount),
+		uint256(deadline),
+		uint256(fee),
+		uint256(nonce),
+	))
+}
+
+// Sign signs hash with s, producing a signature in the form Relayer.sol expects (V is 27 or 28).
+func Sign(ctx context.Context, s signer.Signer, hash common.Hash) ([]byte, error) {
+	sig, err := s.SignHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(sig) != 65 {
+		return nil, errors.Errorf("signature has length %v, want 65", len(sig))
+	}
+	sig = append([]byte(nil), sig...)
+	if sig[64] < 27 {
+		sig[64] += 27
+	}
+	return sig, nil
+}
+
+// Recover returns the address that produced sig over hash. Like OpenZeppelin's ECDSA.recover,
+// it rejects malleable (high-S) signatures and V values other than 27 and 28.
+func Recover(hash common.Hash, sig []byte) (common.Address, error) {
+	if len(sig) != 65 {
+		return common.Address{}, errors.Errorf("signature has length %v, want 65", len(sig))
+	}
+	v := sig[64]
+	if v != 27 && v != 28 {
+		return common.Address{}, errors.Errorf("signature has invalid v value %v", v)
+	}
+	s := new(big.Int).SetBytes(sig[32:64])
+	if s.Cmp(secp256k1HalfN) > 0 {
+		return common.Address{}, errors.New("signature has invalid s value")
+	}
+	normalized := append(append([]byte(nil), sig[:64]...), v-27)
+	pub, err := crypto.SigToPub(hash.Bytes(), normalized)
+	if err != nil {
+		return common.Address{}, errors.Wrap(err, "recovering signer")
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+var secp256k1HalfN, _ = new(big.Int).SetString("7fffffffffffffffffffffffffffffff5d576e7357a4501ddfe92f46681b20a0", 16)
+
+func ethSignedHash(hash common.Hash) common.Hash {
+	return crypto.Keccak256Hash(
+		[]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%v", len(hash))),
+		hash.Bytes(),
+	)
+}
+
+func uint256(n *big.Int) []byte {
+	return common.LeftPadBytes(n.Bytes(), 32)
+}
@@ -0,0 +1,68 @@
+package relay
+
+import (
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+)
+
+// The relayer's metrics.
+var (
+	queueDepth = metrics.Definition{Name: "rsv_relayer_queue_depth", Kind: "gauge", Help: "Requests not yet confirmed or failed, by status.",
+		Panel: &metrics.Panel{Row: "Relayer", Title: "Queue depth", Legend: "{{status}}"}}
+	requestsTotal = metrics.Definition{Name: "rsv_relayer_requests_total", Kind: "counter", Help: "Requests finished, by status.",
+		Panel: &metrics.Panel{Row: "Relayer", Title: "Requests finished per hour", Expr: "increase(rsv_relayer_requests_total[1h])", Legend: "{{status}}"}}
+	oldestPending = metrics.Definition{Name: "rsv_relayer_oldest_pending_seconds", Kind: "gauge", Help: "Age of the oldest request not yet confirmed or failed; 0 if there is none.",
+		Panel: &metrics.Panel{Row: "Relayer", Title: "Oldest pending request", Legend: "age", Unit: "s"}}
+	stuckRequests = metrics.Definition{Name: "rsv_relayer_stuck_requests", Kind: "gauge", Help: "Requests still pending past the relayer's deadline.",
+		Panel: &metrics.Panel{Row: "Relayer", Title: "Stuck requests", Legend: "stuck"}}
+	gasSpent = metrics.Definition{Name: "rsv_relayer_gas_spent_eth_total", Kind: "counter", Help: "ETH paid for the gas of relayed transactions.",
+		Panel: &metrics.Panel{Row: "Relayer", Title: "Gas spend per hour (ETH)", Expr: "increase(rsv_relayer_gas_spent_eth_total[1h])", Legend: "ETH"}}
+)
+
+// Definitions are the relayer's metrics.
+var Definitions = []metrics.Definition{queueDepth, oldestPending, stuckRequests, gasSpent, requestsTotal}
+
+var weiPerEth = new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil))
+
+// ServeMetrics serves the relayer's metrics, as counted from its records.
+func (s *Service) ServeMetrics(rw http.ResponseWriter, req *http.Request) {
+	now := time.Now()
+	counts := map[string]int{}
+	var oldest time.Duration
+	stuck := 0
+	spent := new(big.Int)
+	for _, r := range s.store.All() {
+		counts[r.Status]++
+		if age := now.Sub(r.Received); r.pending() && age > oldest {
+			oldest = age
+		}
+		if r.stuck(s.config.Deadline, now) {
+			stuck++
+		}
+		if cost, ok := new(big.Int).SetString(r.GasCost, 10); ok {
+			spent.Add(spent, cost)
+		}
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w := metrics.NewTextWriter(rw)
+	w.Define(queueDepth)
+	for _, status := range []string{StatusQueued, StatusSubmitted, StatusMined} {
+		w.Sample(queueDepth.Name, []string{"status", status}, float64(counts[status]))
+	}
+	w.Metric(oldestPending, oldest.Seconds())
+	w.Metric(stuckRequests, float64(stuck))
+	w.Define(requestsTotal)
+	for _, status := range []string{StatusConfirmed, StatusFailed} {
+		w.Sample(requestsTotal.Name, []string{"status", status}, float64(counts[status]))
+	}
+	eth, _ := new(big.Float).Quo(new(big.Float).SetInt(spent), weiPerEth).Float64()
+	w.Metric(gasSpent, eth)
+	if err := w.Err(); err != nil {
+		log.Printf("relay: writing metrics: %v", err)
+	}
+}
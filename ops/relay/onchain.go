@@ -0,0 +1,134 @@
+package relay
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/session"
+)
+
+// onchain is the Chain of a live deployment.
+type onchain struct {
+	client  *chain.Client
+	relayer *chain.Contract
+	reserve *chain.Contract
+	tx      *chain.Transactor
+	rsv     common.Address
+}
+
+// NewChain returns the Chain for the deployment that s is connected to. It checks that the
+// manifest's Relayer and Reserve trust each other, since otherwise every request would fail.
+func NewChain(ctx context.Context, s *session.Session) (Chain, error) {
+	tx, err := s.RequireTransactor()
+	if err != nil {
+		return nil, err
+	}
+	relayer, err := s.Contract("Relayer")
+	if err != nil {
+		return nil, err
+	}
+	reserve, err := s.Contract("Reserve")
+	if err != nil {
+		return nil, err
+	}
+	rsv, err := relayer.CallAddress(ctx, "trustedRSV")
+	if err != nil {
+		return nil, err
+	}
+	if rsv != reserve.Address {
+		return nil, errors.Errorf("%v trusts Reserve at %v, not %v", relayer, rsv.Hex(), reserve)
+	}
+	trusted, err := reserve.CallAddress(ctx, "trustedRelayer")
+	if err != nil {
+		return nil, err
+	}
+	if trusted != relayer.Address {
+		return nil, errors.Errorf("%v trusts relayer %v, not %v", reserve, trusted.Hex(), relayer)
+	}
+	return &onchain{client: s.Client, relayer: relayer, reserve: reserve, tx: tx, rsv: rsv}, nil
+}
+
+func (c *onchain) RSV() common.Address {
+	return c.rsv
+}
+
+func (c *onchain) Paused(ctx context.Context) (bool, error) {
+	return c.reserve.CallBool(ctx, "paused")
+}
+
+func (c *onchain) Nonce(ctx context.Context, signer common.Address) (*big.Int, error) {
+	return c.relayer.CallBig(ctx, "nonce", signer)
+}
+
+func (c *onchain) Balance(ctx context.Context, holder common.Address) (*big.Int, error) {
+	return c.reserve.CallBig(ctx, "balanceOf", holder)
+}
+
+func (c *onchain) Allowance(ctx context.Context, holder, spender common.Address) (*big.Int, error) {
+	return c.reserve.CallBig(ctx, "allowance", holder, spender)
+}
+
+func (c *onchain) PermitNonce(ctx context.Context, holder common.Address) (*big.Int, error) {
+	return c.reserve.CallBig(ctx, "nonces", holder)
+}
+
+func (c *onchain) ChainID(ctx context.Context) (*big.Int, error) {
+	return c.reserve.CallBig(ctx, "chainId")
+}
+
+func (c *onchain) Submit(ctx context.Context, method string, args []interface{}) (common.Hash, error) {
+	tx, err := c.tx.Send(ctx, chain.Call{Contract: c.relayer, Method: method, Args: args})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
+// Receipt reads the raw receipt, because go-ethereum's Receipt type doesn't carry the block number,
+// together with the transaction, for its gas price.
+func (c *onchain) Receipt(ctx context.Context, hash common.Hash) (*Receipt, error) {
+	var raw *struct {
+		BlockNumber *hexutil.Big   `json:"blockNumber"`
+		Status      hexutil.Uint64 `json:"status"`
+		GasUsed     hexutil.Uint64 `json:"gasUsed"`
+	}
+	var tx *struct {
+		GasPrice *hexutil.Big `json:"gasPrice"`
+	}
+	batch := []rpc.BatchElem{
+		{Method: "eth_getTransactionReceipt", Args: []interface{}{hash}, Result: &raw},
+		{Method: "eth_getTransactionByHash", Args: []interface{}{hash}, Result: &tx},
+	}
+	err := c.client.RPC.BatchCallContext(ctx, batch)
+	if err == nil {
+		err = batch[0].Error
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading receipt for %v", hash.Hex())
+	}
+	if raw == nil || raw.BlockNumber == nil {
+		return nil, nil
+	}
+	r := &Receipt{
+		Success: raw.Status == 1,
+		Block:   (*big.Int)(raw.BlockNumber).Uint64(),
+	}
+	if batch[1].Error == nil && tx != nil && tx.GasPrice != nil {
+		r.GasCost = new(big.Int).Mul(new(big.Int).SetUint64(uint64(raw.GasUsed)), (*big.Int)(tx.GasPrice))
+	}
+	return r, nil
+}
+
+func (c *onchain) Head(ctx context.Context) (uint64, error) {
+	header, err := c.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "reading latest block")
+	}
+	return header.Number.Uint64(), nil
+}
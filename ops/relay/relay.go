@@ -0,0 +1,401 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/authorize"
+)
+
+// Statuses of a relayed request.
+const (
+	StatusQueued    = "queued"    // accepted, not yet sent
+	StatusSubmitted = "submitted" // sent, not yet mined
+	StatusMined     = "mined"     // mined, waiting for confirmations
+	StatusConfirmed = "confirmed" // mined at least Confirmations blocks deep
+	StatusFailed    = "failed"    // could not be sent, or reverted
+)
+
+// Record is the relayer's state for one request.
+type Record struct {
+	ID      string  `json:"id"`
+	Request Request `json:"request"`
+	Status  string  `json:"status"`
+	TxHash  string  `json:"txHash,omitempty"`
+	Block   uint64  `json:"block,omitempty"`
+	Error   string  `json:"error,omitempty"`
+
+	// GasCost is what the relayer paid for the transaction's gas, in wei, once it is mined.
+	GasCost string `json:"gasCost,omitempty"`
+
+	Received  time.Time `json:"received"`
+	Submitted time.Time `json:"submitted,omitempty"`
+	Finished  time.Time `json:"finished,omitempty"`
+
+	// Alerted is when the request was reported stuck, if it has been.
+	Alerted time.Time `json:"alerted,omitempty"`
+}
+
+func (r *Record) active() bool {
+	return r.Status == StatusQueued || r.Status == StatusSubmitted
+}
+
+// pending reports whether the request is still on its way to being confirmed.
+func (r *Record) pending() bool {
+	return r.active() || r.Status == StatusMined
+}
+
+// stuck reports whether the request is still pending deadline after it was received.
+func (r *Record) stuck(deadline time.Duration, now time.Time) bool {
+	return deadline > 0 && r.pending() && now.Sub(r.Received) > deadline
+}
+
+// Receipt is the part of a transaction receipt that the relayer cares about.
+type Receipt struct {
+	Success bool
+	Block   uint64
+
+	// GasCost is the gas used times the gas price, in wei; nil if unknown.
+	GasCost *big.Int
+}
+
+// Chain is the relayer's view of the blockchain.
+type Chain interface {
+	// RSV is the address of the Reserve contract that the Relayer forwards to.
+	RSV() common.Address
+	Paused(ctx context.Context) (bool, error)
+	Nonce(ctx context.Context, signer common.Address) (*big.Int, error)
+	Balance(ctx context.Context, holder common.Address) (*big.Int, error)
+	Allowance(ctx context.Context, holder, spender common.Address) (*big.Int, error)
+
+	// PermitNonce is the Reserve's EIP-2612 nonce for holder, and ChainID is the chain ID that
+	// the Reserve's signatures are made for, zero while they are disabled.
+	PermitNonce(ctx context.Context, holder common.Address) (*big.Int, error)
+	ChainID(ctx context.Context) (*big.Int, error)
+
+	// Submit sends a call to the Relayer contract, paying for gas.
+	Submit(ctx context.Context, method string, args []interface{}) (common.Hash, error)
+
+	// Receipt returns nil if the transaction is not yet mined.
+	Receipt(ctx context.Context, hash common.Hash) (*Receipt, error)
+	Head(ctx context.Context) (uint64, error)
+}
+
+// Config tunes a Service.
+type Config struct {
+	// MinFee is the smallest fee, in attoRSV, that the relayer accepts for its trouble.
+	MinFee *big.Int
+
+	// Confirmations is how many blocks deep a transaction must be to count as confirmed.
+	Confirmations uint64
+
+	// PollInterval is how often the service sends queued requests and checks on sent ones.
+	PollInterval time.Duration
+
+	// Deadline is how long after it is received a request should be confirmed; one that isn't
+	// is stuck. Zero means no deadline.
+	Deadline time.Duration
+
+	// Post, if set, delivers the alert for each stuck request, once, such as
+	// emergency.Notifier.Notify. An alert that could not be delivered is posted again at the
+	// next poll.
+	Post func(ctx context.Context, text string) error
+
+	// Network names the chain in alerts.
+	Network string
+}
+
+// Service validates, queues, submits, and tracks relay requests.
+type Service struct {
+	chain  Chain
+	config Config
+	store  *Store
+
+	mu sync.Mutex
+}
+
+// NewService returns a Service backed by chain, keeping its state in store.
+func NewService(chain Chain, store *Store, config Config) *Service {
+	if config.MinFee == nil {
+		config.MinFee = new(big.Int)
+	}
+	if config.PollInterval == 0 {
+		config.PollInterval = 5 * time.Second
+	}
+	return &Service{chain: chain, config: config, store: store}
+}
+
+// ErrInvalid wraps errors caused by a bad request, as opposed to a problem on our side.
+type ErrInvalid struct{ error }
+
+func invalid(format string, args ...interface{}) error {
+	return ErrInvalid{errors.Errorf(format, args...)}
+}
+
+// Submit validates req and queues it, returning its record. Submitting a request that has
+// already been accepted returns the existing record.
+func (s *Service) Submit(ctx context.Context, req Request) (*Record, error) {
+	p, err := req.parse()
+	if err != nil {
+		return nil, ErrInvalid{err}
+	}
+	id := crypto.Keccak256Hash(req.Sig).Hex()
+
+	// Validation reads on-chain state and the queue together, so serialize it.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing := s.store.Get(id); existing != nil {
+		return existing, nil
+	}
+	if err := s.validate(ctx, p); err != nil {
+		return nil, err
+	}
+	record := &Record{
+		ID:       id,
+		Request:  req,
+		Status:   StatusQueued,
+		Received: time.Now().UTC(),
+	}
+	if err := s.store.Put(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (s *Service) validate(ctx context.Context, p *parsed) error {
+	paused, err := s.chain.Paused(ctx)
+	if err != nil {
+		return err
+	}
+	if paused {
+		return invalid("Reserve is paused")
+	}
+	if p.fee.Cmp(s.config.MinFee) < 0 {
+		return invalid("fee %v is below the minimum of %v attoRSV", p.fee, s.config.MinFee)
+	}
+
+	// The signature must be over the signer's next unused Relayer nonce: the on-chain nonce,
+	// plus one for each of their requests that we've accepted but that hasn't been mined.
+	signer := p.Signer()
+	nonce, err := s.chain.Nonce(ctx, signer)
+	if err != nil {
+		return err
+	}
+	for _, r := range s.store.All() {
+		if r.active() && r.Request.Signer() == signer {
+			nonce.Add(nonce, big.NewInt(1))
+		}
+	}
+	if err := p.verify(s.chain.RSV(), nonce); err != nil {
+		return ErrInvalid{err}
+	}
+
+	// Check that the transfers the request implies can succeed now.
+	need := func(holder common.Address, amount *big.Int, what string) error {
+		balance, err := s.chain.Balance(ctx, holder)
+		if err != nil {
+			return err
+		}
+		if balance.Cmp(amount) < 0 {
+			return invalid("%v holds %v attoRSV, but %v requires %v", holder.Hex(), balance, what, amount)
+		}
+		return nil
+	}
+	switch p.Kind {
+	case KindTransfer:
+		return need(p.From, new(big.Int).Add(p.amount, p.fee), "amount plus fee")
+	case KindApprove:
+		return need(p.Holder, p.fee, "the fee")
+	case KindPermit:
+		if err := s.validatePermit(ctx, p); err != nil {
+			return err
+		}
+		return need(p.Holder, p.fee, "the fee")
+	default:
+		if p.Holder == p.Spender {
+			if err := need(p.Holder, new(big.Int).Add(p.amount, p.fee), "amount plus fee"); err != nil {
+				return err
+			}
+		} else {
+			if err := need(p.Holder, p.amount, "the amount"); err != nil {
+				return err
+			}
+			if err := need(p.Spender, p.fee, "the fee"); err != nil {
+				return err
+			}
+		}
+		allowance, err := s.chain.Allowance(ctx, p.Holder, p.Spender)
+		if err != nil {
+			return err
+		}
+		if allowance.Cmp(p.amount) < 0 {
+			return invalid("%v has approved %v for only %v attoRSV", p.Holder.Hex(), p.Spender.Hex(), allowance)
+		}
+	}
+	return nil
+}
+
+// validatePermit checks that p's EIP-2612 permit is unexpired and signed over the holder's next
+// unused Reserve nonce, counting the permits of theirs that we've accepted but not yet sent.
+func (s *Service) validatePermit(ctx context.Context, p *parsed) error {
+	if p.deadline.Cmp(big.NewInt(time.Now().Unix())) < 0 {
+		return invalid("permit expired at %v", p.deadline)
+	}
+	chainID, err := s.chain.ChainID(ctx)
+	if err != nil {
+		return err
+	}
+	if chainID.Sign() == 0 {
+		return invalid("the Reserve does not accept signatures yet")
+	}
+	nonce, err := s.chain.PermitNonce(ctx, p.Holder)
+	if err != nil {
+		return err
+	}
+	for _, r := range s.store.All() {
+		if r.active() && r.Request.Kind == KindPermit && r.Request.Holder == p.Holder {
+			nonce.Add(nonce, big.NewInt(1))
+		}
+	}
+	if err := p.verifyPermit(authorize.Reserve(s.chain.RSV(), chainID), nonce); err != nil {
+		return ErrInvalid{err}
+	}
+	return nil
+}
+
+// Get returns the record with the given ID, or nil.
+func (s *Service) Get(id string) *Record {
+	return s.store.Get(id)
+}
+
+// Run sends queued requests and tracks sent ones until ctx is done.
+func (s *Service) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.step(ctx); err != nil {
+			log.Printf("relay: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// step does one round of sending and tracking, then alerts about the requests that are stuck,
+// even if the round failed.
+func (s *Service) step(ctx context.Context) error {
+	records := s.store.All()
+	sort.Slice(records, func(i, j int) bool { return records[i].Received.Before(records[j].Received) })
+
+	err := s.advance(ctx, records)
+	if alertErr := s.alertStuck(ctx, records, time.Now()); err == nil {
+		err = alertErr
+	}
+	return err
+}
+
+// advance sends the queued records and tracks the sent ones, in order.
+func (s *Service) advance(ctx context.Context, records []*Record) error {
+	head, err := s.chain.Head(ctx)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		switch r.Status {
+		case StatusQueued:
+			err = s.send(ctx, r)
+		case StatusSubmitted, StatusMined:
+			err = s.track(ctx, r, head)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send submits a queued request. Requests are sent strictly in the order they arrived, since a
+// signer's later requests depend on the nonces of their earlier ones.
+func (s *Service) send(ctx context.Context, r *Record) error {
+	p, err := r.Request.parse()
+	if err != nil {
+		return s.finish(r, StatusFailed, err)
+	}
+	hash, err := s.chain.Submit(ctx, p.method(), p.args())
+	if err != nil {
+		return s.finish(r, StatusFailed, err)
+	}
+	r.Status = StatusSubmitted
+	r.TxHash = hash.Hex()
+	r.Submitted = time.Now().UTC()
+	return s.store.Put(r)
+}
+
+func (s *Service) track(ctx context.Context, r *Record, head uint64) error {
+	receipt, err := s.chain.Receipt(ctx, common.HexToHash(r.TxHash))
+	if err != nil || receipt == nil {
+		return err
+	}
+	if receipt.GasCost != nil {
+		r.GasCost = receipt.GasCost.String()
+	}
+	if !receipt.Success {
+		return s.finish(r, StatusFailed, errors.New("transaction reverted"))
+	}
+	r.Block = receipt.Block
+	if head+1 >= receipt.Block+s.config.Confirmations {
+		return s.finish(r, StatusConfirmed, nil)
+	}
+	if r.Status != StatusMined {
+		r.Status = StatusMined
+		return s.store.Put(r)
+	}
+	return nil
+}
+
+// alertStuck reports each stuck record not yet reported.
+func (s *Service) alertStuck(ctx context.Context, records []*Record, now time.Time) error {
+	for _, r := range records {
+		if !r.stuck(s.config.Deadline, now) || !r.Alerted.IsZero() {
+			continue
+		}
+		text := fmt.Sprintf("RSV on %v: relay request %v from %v has been %v for %v, past the deadline of %v",
+			s.config.Network, r.ID, r.Request.Signer().Hex(), r.Status, now.Sub(r.Received).Round(time.Second), s.config.Deadline)
+		if r.TxHash != "" {
+			text += " (tx " + r.TxHash + ")"
+		}
+		log.Printf("relay: %v", text)
+		if s.config.Post != nil {
+			if err := s.config.Post(ctx, text); err != nil {
+				return errors.Wrapf(err, "posting the alert for request %v", r.ID)
+			}
+		}
+		r.Alerted = now.UTC()
+		if err := s.store.Put(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) finish(r *Record, status string, err error) error {
+	r.Status = status
+	r.Finished = time.Now().UTC()
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return s.store.Put(r)
+}
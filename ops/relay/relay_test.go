@@ -0,0 +1,338 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/authorize"
+	"github.com/reserve-protocol/rsv-beta/ops/signer"
+)
+
+// fakeChain is an in-memory Chain.
+type fakeChain struct {
+	paused    bool
+	nonces    map[common.Address]int64
+	balances  map[common.Address]int64
+	allowance int64
+
+	permitNonces map[common.Address]int64
+	chainID      int64
+
+	submitted []string
+	receipts  map[common.Hash]*Receipt
+	head      uint64
+}
+
+func (f *fakeChain) RSV() common.Address { return common.HexToAddress("0x5") }
+
+func (f *fakeChain) Paused(context.Context) (bool, error) { return f.paused, nil }
+
+func (f *fakeChain) Nonce(_ context.Context, a common.Address) (*big.Int, error) {
+	return big.NewInt(f.nonces[a]), nil
+}
+
+func (f *fakeChain) Balance(_ context.Context, a common.Address) (*big.Int, error) {
+	return big.NewInt(f.balances[a]), nil
+}
+
+func (f *fakeChain) Allowance(context.Context, common.Address, common.Address) (*big.Int, error) {
+	return big.NewInt(f.allowance), nil
+}
+
+func (f *fakeChain) PermitNonce(_ context.Context, a common.Address) (*big.Int, error) {
+	return big.NewInt(f.permitNonces[a]), nil
+}
+
+func (f *fakeChain) ChainID(context.Context) (*big.Int, error) { return big.NewInt(f.chainID), nil }
+
+func (f *fakeChain) Submit(_ context.Context, method string, _ []interface{}) (common.Hash, error) {
+	f.submitted = append(f.submitted, method)
+	return common.BigToHash(big.NewInt(int64(len(f.submitted)))), nil
+}
+
+func (f *fakeChain) Receipt(_ context.Context, h common.Hash) (*Receipt, error) {
+	return f.receipts[h], nil
+}
+
+func (f *fakeChain) Head(context.Context) (uint64, error) { return f.head, nil }
+
+// newTestService returns a Service on ch, and a function that removes its state.
+func newTestService(t *testing.T, ch *fakeChain) (*Service, func()) {
+	dir, err := ioutil.TempDir("", "relay")
+	require.NoError(t, err)
+	store, err := OpenStore(filepath.Join(dir, "state.json"))
+	require.NoError(t, err)
+	return NewService(ch, store, Config{MinFee: big.NewInt(2), Confirmations: 3}), func() { os.RemoveAll(dir) }
+}
+
+func newTestKey(t *testing.T) signer.Signer {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	return signer.NewKey(key)
+}
+
+func signedTransfer(t *testing.T, from signer.Signer, to common.Address, amount, fee, nonce int64) Request {
+	hash := TransferHash(
+		common.HexToAddress("0x5"), from.Address(), to,
+		big.NewInt(amount), big.NewInt(fee), big.NewInt(nonce),
+	)
+	sig, err := Sign(context.Background(), from, hash)
+	require.NoError(t, err)
+	return Request{
+		Kind:   KindTransfer,
+		Sig:    sig,
+		From:   from.Address(),
+		To:     to,
+		Amount: big.NewInt(amount).String(),
+		Fee:    big.NewInt(fee).String(),
+	}
+}
+
+func TestRecoverRejectsMalleableSignatures(t *testing.T) {
+	key := newTestKey(t)
+	hash := TransferHash(common.Address{}, key.Address(), common.Address{}, big.NewInt(1), big.NewInt(0), big.NewInt(0))
+	sig, err := Sign(context.Background(), key, hash)
+	require.NoError(t, err)
+
+	recovered, err := Recover(hash, sig)
+	require.NoError(t, err)
+	assert.Equal(t, key.Address(), recovered)
+
+	// Flip s to n - s and v to match; the signature is still valid ECDSA, but OpenZeppelin rejects it.
+	n := crypto.S256().Params().N
+	s := new(big.Int).Sub(n, new(big.Int).SetBytes(sig[32:64]))
+	flipped := append(append(append([]byte(nil), sig[:32]...), common.LeftPadBytes(s.Bytes(), 32)...), 55-sig[64])
+	_, err = Recover(hash, flipped)
+	assert.Error(t, err)
+}
+
+func TestSubmitValidates(t *testing.T) {
+	ctx := context.Background()
+	alice, to := newTestKey(t), common.HexToAddress("0x7")
+	ch := &fakeChain{
+		nonces:   map[common.Address]int64{alice.Address(): 4},
+		balances: map[common.Address]int64{alice.Address(): 100},
+	}
+	svc, cleanup := newTestService(t, ch)
+	defer cleanup()
+
+	_, err := svc.Submit(ctx, signedTransfer(t, alice, to, 10, 2, 3))
+	assert.IsType(t, ErrInvalid{}, err, "stale nonce")
+
+	_, err = svc.Submit(ctx, signedTransfer(t, alice, to, 10, 1, 4))
+	assert.IsType(t, ErrInvalid{}, err, "fee below minimum")
+
+	_, err = svc.Submit(ctx, signedTransfer(t, alice, to, 99, 2, 4))
+	assert.IsType(t, ErrInvalid{}, err, "amount plus fee exceeds balance")
+
+	first, err := svc.Submit(ctx, signedTransfer(t, alice, to, 10, 2, 4))
+	require.NoError(t, err)
+	assert.Equal(t, StatusQueued, first.Status)
+
+	// A second request must use the nonce after the queued one.
+	_, err = svc.Submit(ctx, signedTransfer(t, alice, to, 10, 2, 4))
+	require.NoError(t, err, "resubmission returns the existing record")
+	_, err = svc.Submit(ctx, signedTransfer(t, alice, to, 10, 2, 5))
+	require.NoError(t, err)
+
+	ch.paused = true
+	_, err = svc.Submit(ctx, signedTransfer(t, alice, to, 10, 2, 6))
+	assert.IsType(t, ErrInvalid{}, err, "paused")
+}
+
+func TestStepTracksConfirmations(t *testing.T) {
+	ctx := context.Background()
+	alice := newTestKey(t)
+	ch := &fakeChain{
+		balances: map[common.Address]int64{alice.Address(): 100},
+		receipts: map[common.Hash]*Receipt{},
+		head:     10,
+	}
+	svc, cleanup := newTestService(t, ch)
+	defer cleanup()
+	record, err := svc.Submit(ctx, signedTransfer(t, alice, common.HexToAddress("0x7"), 10, 2, 0))
+	require.NoError(t, err)
+
+	require.NoError(t, svc.step(ctx))
+	assert.Equal(t, []string{"forwardTransfer"}, ch.submitted)
+	assert.Equal(t, StatusSubmitted, svc.Get(record.ID).Status)
+
+	ch.receipts[common.HexToHash(svc.Get(record.ID).TxHash)] = &Receipt{Success: true, Block: 11, GasCost: big.NewInt(21e14)}
+	ch.head = 12
+	require.NoError(t, svc.step(ctx))
+	assert.Equal(t, StatusMined, svc.Get(record.ID).Status)
+
+	ch.head = 13
+	require.NoError(t, svc.step(ctx))
+	assert.Equal(t, StatusConfirmed, svc.Get(record.ID).Status)
+	assert.Equal(t, uint64(11), svc.Get(record.ID).Block)
+	assert.Equal(t, "2100000000000000", svc.Get(record.ID).GasCost)
+	assert.Len(t, ch.submitted, 1)
+
+	rec := httptest.NewRecorder()
+	svc.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	lines := strings.Split(rec.Body.String(), "\n")
+	assert.Contains(t, lines, `rsv_relayer_queue_depth{status="submitted"} 0`)
+	assert.Contains(t, lines, `rsv_relayer_requests_total{status="confirmed"} 1`)
+	assert.Contains(t, lines, "rsv_relayer_gas_spent_eth_total 0.0021")
+}
+
+func TestStuckRequests(t *testing.T) {
+	ctx := context.Background()
+	alice := newTestKey(t)
+	ch := &fakeChain{
+		balances: map[common.Address]int64{alice.Address(): 100},
+		receipts: map[common.Hash]*Receipt{},
+		head:     10,
+	}
+	svc, cleanup := newTestService(t, ch)
+	defer cleanup()
+	var posted []string
+	fail := true
+	svc.config.Deadline = time.Hour
+	svc.config.Network = "testnet"
+	svc.config.Post = func(ctx context.Context, text string) error {
+		if fail {
+			fail = false
+			return errors.New("webhook down")
+		}
+		posted = append(posted, text)
+		return nil
+	}
+	old, err := svc.Submit(ctx, signedTransfer(t, alice, common.HexToAddress("0x7"), 10, 2, 0))
+	require.NoError(t, err)
+	old.Received = old.Received.Add(-2 * time.Hour)
+	require.NoError(t, svc.store.Put(old))
+	_, err = svc.Submit(ctx, signedTransfer(t, alice, common.HexToAddress("0x7"), 10, 2, 1))
+	require.NoError(t, err)
+
+	// The alert that fails to post is posted at the next step, and only then.
+	assert.Error(t, svc.step(ctx))
+	require.NoError(t, svc.step(ctx))
+	require.NoError(t, svc.step(ctx))
+	require.Len(t, posted, 1)
+	assert.Contains(t, posted[0], "RSV on testnet: relay request "+old.ID+" from "+alice.Address().Hex()+" has been submitted for 2h0m")
+	assert.Contains(t, posted[0], "(tx "+svc.Get(old.ID).TxHash+")")
+
+	rec := httptest.NewRecorder()
+	svc.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/queue", nil))
+	var q Queue
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &q))
+	require.Len(t, q.Requests, 2)
+	assert.Equal(t, old.ID, q.Requests[0].ID)
+	assert.True(t, q.Requests[0].Stuck)
+	assert.True(t, q.Requests[0].AgeSeconds >= 7200)
+	assert.False(t, q.Requests[1].Stuck)
+	assert.Equal(t, float64(3600), q.DeadlineSeconds)
+
+	rec = httptest.NewRecorder()
+	svc.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, strings.Split(rec.Body.String(), "\n"), "rsv_relayer_stuck_requests 1")
+	assert.Contains(t, rec.Body.String(), "rsv_relayer_oldest_pending_seconds 720")
+}
+
+func TestSignedRequestsVerify(t *testing.T) {
+	ctx := context.Background()
+	alice, bob := newTestKey(t), newTestKey(t)
+	rsv, to := common.HexToAddress("0x5"), common.HexToAddress("0x7")
+	domain := authorize.Reserve(rsv, big.NewInt(1))
+	amount, fee, nonce := big.NewInt(10), big.NewInt(2), big.NewInt(3)
+
+	transfer, err := SignTransfer(ctx, alice, rsv, to, amount, fee, nonce)
+	require.NoError(t, err)
+	approve, err := SignApprove(ctx, alice, rsv, bob.Address(), amount, fee, nonce)
+	require.NoError(t, err)
+	transferFrom, err := SignTransferFrom(ctx, bob, rsv, alice.Address(), to, amount, fee, nonce)
+	require.NoError(t, err)
+	permit, err := SignPermit(ctx, alice, domain, bob.Address(), amount, big.NewInt(2e9), fee, nonce, big.NewInt(5))
+	require.NoError(t, err)
+
+	for _, req := range []Request{transfer, approve, transferFrom, permit} {
+		assert.NoError(t, req.Verify(rsv, nonce), req.Kind)
+		assert.Error(t, req.Verify(rsv, big.NewInt(4)), req.Kind)
+		assert.Error(t, req.Verify(to, nonce), req.Kind)
+
+		// The request survives the trip to the relayer.
+		b, err := json.Marshal(req)
+		require.NoError(t, err)
+		var decoded Request
+		require.NoError(t, json.Unmarshal(b, &decoded))
+		assert.NoError(t, decoded.Verify(rsv, nonce), req.Kind)
+	}
+	assert.Equal(t, "forwardPermit", mustParse(t, permit).method())
+
+	require.NoError(t, permit.VerifyPermit(domain, big.NewInt(5)))
+	assert.Error(t, permit.VerifyPermit(domain, big.NewInt(4)))
+	assert.Error(t, permit.VerifyPermit(authorize.Reserve(rsv, big.NewInt(2)), big.NewInt(5)))
+	assert.Error(t, approve.VerifyPermit(domain, big.NewInt(5)))
+
+	// The relayer's signature covers the permit's deadline too.
+	later := permit
+	later.Deadline = "2000000001"
+	assert.Error(t, later.Verify(rsv, nonce))
+}
+
+func mustParse(t *testing.T, req Request) *parsed {
+	p, err := req.parse()
+	require.NoError(t, err)
+	return p
+}
+
+func TestSubmitValidatesPermits(t *testing.T) {
+	ctx := context.Background()
+	alice, spender := newTestKey(t), common.HexToAddress("0x8")
+	ch := &fakeChain{
+		nonces:       map[common.Address]int64{alice.Address(): 1},
+		permitNonces: map[common.Address]int64{alice.Address(): 7},
+		balances:     map[common.Address]int64{alice.Address(): 2},
+	}
+	svc, cleanup := newTestService(t, ch)
+	defer cleanup()
+	domain := authorize.Reserve(ch.RSV(), big.NewInt(1))
+	deadline := big.NewInt(time.Now().Add(time.Hour).Unix())
+	permit := func(fee, nonce, permitNonce int64, deadline *big.Int) Request {
+		req, err := SignPermit(ctx, alice, domain, spender, big.NewInt(100), deadline,
+			big.NewInt(fee), big.NewInt(nonce), big.NewInt(permitNonce))
+		require.NoError(t, err)
+		return req
+	}
+
+	_, err := svc.Submit(ctx, permit(2, 1, 7, deadline))
+	assert.IsType(t, ErrInvalid{}, err, "signatures not enabled")
+	ch.chainID = 1
+
+	_, err = svc.Submit(ctx, permit(2, 1, 6, deadline))
+	assert.IsType(t, ErrInvalid{}, err, "stale permit nonce")
+	_, err = svc.Submit(ctx, permit(2, 1, 7, big.NewInt(time.Now().Add(-time.Minute).Unix())))
+	assert.IsType(t, ErrInvalid{}, err, "expired")
+	_, err = svc.Submit(ctx, permit(3, 1, 7, deadline))
+	assert.IsType(t, ErrInvalid{}, err, "fee exceeds balance")
+
+	// The holder needs no allowance or ether, only the fee.
+	record, err := svc.Submit(ctx, permit(2, 1, 7, deadline))
+	require.NoError(t, err)
+	assert.Equal(t, StatusQueued, record.Status)
+
+	// A second permit must use the Reserve nonce after the queued one.
+	_, err = svc.Submit(ctx, permit(2, 2, 7, deadline))
+	assert.IsType(t, ErrInvalid{}, err, "permit nonce of the queued permit")
+	_, err = svc.Submit(ctx, permit(2, 2, 8, deadline))
+	require.NoError(t, err)
+
+	require.NoError(t, svc.step(ctx))
+	assert.Equal(t, []string{"forwardPermit", "forwardPermit"}, ch.submitted)
+}
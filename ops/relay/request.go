@@ -0,0 +1,163 @@
+// Package relay implements the relayer service: it accepts signed metatransaction requests for
+// the Relayer contract, checks them, pays the gas to submit them, and tracks them until they
+// are confirmed.
+package relay
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+// Kinds of request, one per forwarding method on the Relayer contract.
+const (
+	KindTransfer     = "transfer"
+	KindApprove      = "approve"
+	KindTransferFrom = "transferFrom"
+	KindPermit       = "permit"
+)
+
+// Request is a signed metatransaction, as submitted by a client.
+//
+// Which address fields are used depends on Kind:
+//
+//	transfer:     From sends Amount to To.                     Signed by From.
+//	approve:      Holder approves Spender for Amount.          Signed by Holder.
+//	transferFrom: Spender moves Amount from Holder to To.      Signed by Spender.
+//	permit:       Holder permits Spender to spend Amount.      Signed by Holder.
+//
+// A permit carries two signatures: Permit is Holder's EIP-2612 permit, valid until Deadline,
+// which the Reserve checks against its own nonce for Holder; Sig authorizes the fee, as for
+// the other kinds. Unlike an approve's, a permit's approval can be read by any wallet that
+// shows EIP-712 messages, and it expires.
+//
+// Amounts are decimal strings of attoRSV, so that clients in any language can send them intact.
+type Request struct {
+	Kind    string         `json:"kind"`
+	Sig     hexutil.Bytes  `json:"sig"`
+	From    common.Address `json:"from,omitempty"`
+	Holder  common.Address `json:"holder,omitempty"`
+	Spender common.Address `json:"spender,omitempty"`
+	To      common.Address `json:"to,omitempty"`
+	Amount  string         `json:"amount"`
+	Fee     string         `json:"fee"`
+
+	// Deadline and Permit are only for permits.
+	Deadline string        `json:"deadline,omitempty"`
+	Permit   hexutil.Bytes `json:"permit,omitempty"`
+}
+
+// parsed is a Request with its amounts converted to integers.
+type parsed struct {
+	*Request
+	amount   *big.Int
+	fee      *big.Int
+	deadline *big.Int
+}
+
+func (r *Request) parse() (*parsed, error) {
+	amount, err := parseAmount("amount", r.Amount)
+	if err != nil {
+		return nil, err
+	}
+	fee, err := parseAmount("fee", r.Fee)
+	if err != nil {
+		return nil, err
+	}
+	deadline := new(big.Int)
+	var zero common.Address
+	switch r.Kind {
+	case KindTransfer:
+		if r.From == zero || r.To == zero {
+			return nil, errors.New("transfer requires from and to")
+		}
+	case KindApprove:
+		if r.Holder == zero || r.Spender == zero {
+			return nil, errors.New("approve requires holder and spender")
+		}
+	case KindTransferFrom:
+		if r.Holder == zero || r.Spender == zero || r.To == zero {
+			return nil, errors.New("transferFrom requires holder, spender, and to")
+		}
+	case KindPermit:
+		if r.Holder == zero || r.Spender == zero {
+			return nil, errors.New("permit requires holder and spender")
+		}
+		if deadline, err = parseAmount("deadline", r.Deadline); err != nil {
+			return nil, err
+		}
+		if len(r.Permit) != 65 {
+			return nil, errors.Errorf("permit has length %v, want 65", len(r.Permit))
+		}
+	default:
+		return nil, errors.Errorf("unknown kind %q", r.Kind)
+	}
+	if len(r.Sig) != 65 {
+		return nil, errors.Errorf("sig has length %v, want 65", len(r.Sig))
+	}
+	return &parsed{Request: r, amount: amount, fee: fee, deadline: deadline}, nil
+}
+
+// Signer is the account whose signature authorizes r, and whose Relayer nonce r consumes.
+func (r *Request) Signer() common.Address {
+	switch r.Kind {
+	case KindTransfer:
+		return r.From
+	case KindApprove, KindPermit:
+		return r.Holder
+	default:
+		return r.Spender
+	}
+}
+
+// hash is the message that Signer must have signed, given their Relayer nonce.
+func (p *parsed) hash(rsv common.Address, nonce *big.Int) common.Hash {
+	switch p.Kind {
+	case KindTransfer:
+		return TransferHash(rsv, p.From, p.To, p.amount, p.fee, nonce)
+	case KindApprove:
+		return ApproveHash(rsv, p.Holder, p.Spender, p.amount, p.fee, nonce)
+	case KindPermit:
+		return PermitHash(rsv, p.Holder, p.Spender, p.amount, p.deadline, p.fee, nonce)
+	default:
+		return TransferFromHash(rsv, p.Holder, p.Spender, p.To, p.amount, p.fee, nonce)
+	}
+}
+
+// method and args are the Relayer contract call that forwards p.
+func (p *parsed) method() string {
+	switch p.Kind {
+	case KindTransfer:
+		return "forwardTransfer"
+	case KindApprove:
+		return "forwardApprove"
+	case KindPermit:
+		return "forwardPermit"
+	default:
+		return "forwardTransferFrom"
+	}
+}
+
+func (p *parsed) args() []interface{} {
+	sig := []byte(p.Sig)
+	switch p.Kind {
+	case KindTransfer:
+		return []interface{}{sig, p.From, p.To, p.amount, p.fee}
+	case KindApprove:
+		return []interface{}{sig, p.Holder, p.Spender, p.amount, p.fee}
+	case KindPermit:
+		return []interface{}{sig, p.Holder, p.Spender, p.amount, p.deadline, []byte(p.Permit), p.fee}
+	default:
+		return []interface{}{sig, p.Holder, p.Spender, p.To, p.amount, p.fee}
+	}
+}
+
+func parseAmount(field, s string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 {
+		return nil, errors.Errorf("%v must be a non-negative decimal integer, got %q", field, s)
+	}
+	return n, nil
+}
@@ -0,0 +1,109 @@
+package relay
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Handler serves the relayer's HTTP API:
+//
+//	POST /relay       submit a Request; responds 202 with its Record
+//	GET  /relay/<id>  look up a Record
+//	GET  /queue       the Records still pending, oldest first, for operators
+//	GET  /metrics     the relayer's Prometheus metrics
+func (s *Service) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/relay", s.handleSubmit)
+	mux.HandleFunc("/relay/", s.handleGet)
+	mux.HandleFunc("/queue", s.handleQueue)
+	mux.HandleFunc("/metrics", s.ServeMetrics)
+	return mux
+}
+
+// maxRequestBytes bounds request bodies; a valid Request is a few hundred bytes.
+const maxRequestBytes = 16 << 10
+
+func (s *Service) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	var req Request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "malformed request: "+err.Error())
+		return
+	}
+	record, err := s.Submit(r.Context(), req)
+	if err != nil {
+		if _, ok := err.(ErrInvalid); ok {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		log.Printf("relay: submitting request: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, record)
+}
+
+func (s *Service) handleGet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	record := s.Get(strings.TrimPrefix(r.URL.Path, "/relay/"))
+	if record == nil {
+		writeError(w, http.StatusNotFound, "no such request")
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+// Pending is a request still on its way to being confirmed, in /queue.
+type Pending struct {
+	*Record
+
+	AgeSeconds float64 `json:"ageSeconds"`
+
+	// Stuck is whether the request is past the relayer's deadline.
+	Stuck bool `json:"stuck"`
+}
+
+// Queue is the response of /queue.
+type Queue struct {
+	DeadlineSeconds float64   `json:"deadlineSeconds,omitempty"`
+	Requests        []Pending `json:"requests"`
+}
+
+func (s *Service) handleQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	now := time.Now()
+	records := s.store.All()
+	sort.Slice(records, func(i, j int) bool { return records[i].Received.Before(records[j].Received) })
+	q := Queue{DeadlineSeconds: s.config.Deadline.Seconds(), Requests: []Pending{}}
+	for _, record := range records {
+		if record.pending() {
+			q.Requests = append(q.Requests, Pending{record, now.Sub(record.Received).Seconds(), record.stuck(s.config.Deadline, now)})
+		}
+	}
+	writeJSON(w, http.StatusOK, q)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("relay: writing response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,98 @@
+package relay
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Store holds relay records, persisted as a JSON file so that the queue survives restarts.
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+// OpenStore loads the store at path. A missing file is an empty store.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, records: make(map[string]*Record)}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading relay state")
+	}
+	var records []*Record
+	if err := json.Unmarshal(b, &records); err != nil {
+		return nil, errors.Wrapf(err, "parsing relay state %v", path)
+	}
+	for _, r := range records {
+		s.records[r.ID] = r
+	}
+	return s, nil
+}
+
+// Get returns a copy of the record with the given ID, or nil.
+func (s *Store) Get(id string) *Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[id]
+	if !ok {
+		return nil
+	}
+	copy := *r
+	return &copy
+}
+
+// All returns copies of every record.
+func (s *Store) All() []*Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]*Record, 0, len(s.records))
+	for _, r := range s.records {
+		copy := *r
+		result = append(result, &copy)
+	}
+	return result
+}
+
+// Put inserts or replaces r, and writes the store to disk.
+func (s *Store) Put(r *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copy := *r
+	s.records[r.ID] = &copy
+	return s.save()
+}
+
+// save atomically replaces the file on disk. Callers must hold s.mu.
+func (s *Store) save() error {
+	records := make([]*Record, 0, len(s.records))
+	for _, r := range s.records {
+		records = append(records, r)
+	}
+	b, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding relay state")
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), ".relay-state-")
+	if err != nil {
+		return errors.Wrap(err, "writing relay state")
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "writing relay state")
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "writing relay state")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), s.path), "writing relay state")
+}
@@ -0,0 +1,63 @@
+package report
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Mail sends reports by email, through an SMTP server. As with webhooks, the config names
+// environment variables holding the credentials rather than holding them.
+type Mail struct {
+	// Server is the SMTP server, as host:port. smtp.SendMail upgrades the connection with
+	// STARTTLS when the server offers it, and refuses to authenticate without it.
+	Server string   `json:"server"`
+	From   string   `json:"from"`
+	To     []string `json:"to"`
+
+	// UsernameEnv and PasswordEnv, if set, name the environment variables holding the
+	// credentials to authenticate with.
+	UsernameEnv string `json:"usernameEnv,omitempty"`
+	PasswordEnv string `json:"passwordEnv,omitempty"`
+}
+
+// sendMail is smtp.SendMail, but for tests.
+var sendMail = smtp.SendMail
+
+// Check checks that m is complete, and that its credentials are set.
+func (m *Mail) Check() error {
+	if m.Server == "" || m.From == "" || len(m.To) == 0 {
+		return errors.New("config: mail needs server, from, and to")
+	}
+	for _, env := range []string{m.UsernameEnv, m.PasswordEnv} {
+		if env != "" && os.Getenv(env) == "" {
+			return errors.Errorf("environment variable %v (mail) is empty", env)
+		}
+	}
+	return nil
+}
+
+// Send sends a plain-text email.
+func (m *Mail) Send(subject, body string) error {
+	var auth smtp.Auth
+	if m.UsernameEnv != "" {
+		host, _, err := net.SplitHostPort(m.Server)
+		if err != nil {
+			return errors.Wrap(err, "mail server")
+		}
+		auth = smtp.PlainAuth("", os.Getenv(m.UsernameEnv), os.Getenv(m.PasswordEnv), host)
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %v\r\n", m.From)
+	fmt.Fprintf(&msg, "To: %v\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %v\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %v\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return errors.Wrap(sendMail(m.Server, auth, m.From, m.To, []byte(msg.String())), "sending mail")
+}
@@ -0,0 +1,300 @@
+// Package report compiles a digest of an RSV deployment's operations over a window of time,
+// usually a UTC day, from the database of an indexer following it: what was minted and burned,
+// the large transfers, the governance and admin events, the anomalies flagged, and what the
+// operator keys spent on gas, with the backing ratio at the window's end. A Reporter sends one
+// each day to chat webhooks and by email.
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/indexer"
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/units"
+)
+
+// Transfer is a transfer, mint, or burn of RSV.
+type Transfer struct {
+	Block  uint64
+	TxHash common.Hash
+	From   common.Address
+	To     common.Address
+	Value  *big.Int // attoRSV
+}
+
+// Kind returns "mint", "burn", or "transfer".
+func (t Transfer) Kind() string {
+	switch {
+	case t.From == (common.Address{}):
+		return "mint"
+	case t.To == (common.Address{}):
+		return "burn"
+	}
+	return "transfer"
+}
+
+// Report is the digest of one window.
+type Report struct {
+	Network string
+
+	// The window is From, inclusive, to To, exclusive; blocks FromBlock to ToBlock are in it.
+	From, To           time.Time
+	FromBlock, ToBlock uint64
+
+	// Supply changes, in attoRSV.
+	Minted, Burned          *big.Int
+	Mints, Burns, Transfers int
+
+	// LargeTransfers are the transfers, mints, and burns over the Compiler's LargeTransfer.
+	LargeTransfers []Transfer
+
+	// Governance is every indexed event that isn't part of ordinary use: proposals, role and
+	// setting changes, pausing, and ownership.
+	Governance []indexer.Event
+
+	Flags []indexer.Flag
+	Gas   []indexer.GasTotal
+
+	// Backing is the state at ToBlock, if the Compiler reads it.
+	Backing *metrics.State
+}
+
+// Compiler compiles reports from an indexer's database.
+type Compiler struct {
+	Node  indexer.HeaderReader
+	Store *indexer.Store
+
+	// Indexer is the name of the indexer whose progress to check.
+	Indexer string
+
+	// Contracts are the indexed contracts whose governance events to report; by default the
+	// Reserve, Manager, and Vault. The Reserve's transfers are always reported.
+	Contracts []string
+
+	// LargeTransfer, if set, is the amount in attoRSV over which transfers are listed.
+	LargeTransfer *big.Int
+
+	// ReadAt, if set, reads the state of the deployment at a block, for the backing.
+	ReadAt func(ctx context.Context, block uint64) (*metrics.State, error)
+
+	Network string
+}
+
+// Compile compiles the report of the window from from to to. It fails if the indexer hasn't yet
+// stored the whole window.
+func (c *Compiler) Compile(ctx context.Context, from, to time.Time) (*Report, error) {
+	st, err := c.Store.State(ctx, c.Indexer)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return nil, errors.New("the indexer has not started")
+	}
+	head, _ := st.Last()
+	header, err := c.Node.HeaderByNumber(ctx, new(big.Int).SetUint64(head))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading header %v", head)
+	}
+	if headTime := time.Unix(int64(header.Time), 0); headTime.Before(to) {
+		return nil, errors.Errorf("the indexer has only reached block %v, at %v, short of %v", head, headTime.UTC(), to.UTC())
+	}
+	before, err := indexer.LastBlockBefore(ctx, c.Node, from, 0, head)
+	if err != nil {
+		return nil, err
+	}
+	last, err := indexer.LastBlockBefore(ctx, c.Node, to, before, head)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{
+		Network:   c.Network,
+		From:      from.UTC(),
+		To:        to.UTC(),
+		FromBlock: before + 1,
+		ToBlock:   last,
+		Minted:    new(big.Int),
+		Burned:    new(big.Int),
+	}
+	if err := c.transfers(ctx, r); err != nil {
+		return nil, err
+	}
+	contracts := c.Contracts
+	if len(contracts) == 0 {
+		contracts = []string{"Reserve", "Manager", "Vault"}
+	}
+	for _, name := range contracts {
+		events, err := c.Store.Events(ctx, name, "", r.FromBlock)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			if e.Block <= r.ToBlock && indexer.AdminEvent(e) {
+				r.Governance = append(r.Governance, e)
+			}
+		}
+	}
+	sort.SliceStable(r.Governance, func(i, j int) bool {
+		a, b := r.Governance[i], r.Governance[j]
+		return a.Block < b.Block || a.Block == b.Block && a.LogIndex < b.LogIndex
+	})
+
+	flags, err := c.Store.Flags(ctx, "", r.FromBlock)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range flags {
+		if f.Block <= r.ToBlock {
+			r.Flags = append(r.Flags, f)
+		}
+	}
+	if r.Gas, err = c.Store.GasTotals(ctx, from, to.Add(-time.Second)); err != nil {
+		return nil, err
+	}
+	if c.ReadAt != nil {
+		if r.Backing, err = c.ReadAt(ctx, r.ToBlock); err != nil {
+			return nil, errors.Wrapf(err, "reading the backing at block %v", r.ToBlock)
+		}
+	}
+	return r, nil
+}
+
+// transfers sums the Reserve's transfers in the window into r.
+func (c *Compiler) transfers(ctx context.Context, r *Report) error {
+	events, err := c.Store.Events(ctx, "Reserve", "Transfer", r.FromBlock)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if e.Block > r.ToBlock {
+			break
+		}
+		var args map[string]string
+		if err := json.Unmarshal([]byte(e.Args), &args); err != nil {
+			return errors.Wrapf(err, "parsing arguments of log %v of tx %v", e.LogIndex, e.TxHash.Hex())
+		}
+		value, ok := new(big.Int).SetString(args["value"], 10)
+		if !ok {
+			return errors.Errorf("bad transfer value %q in tx %v", args["value"], e.TxHash.Hex())
+		}
+		t := Transfer{Block: e.Block, TxHash: e.TxHash, From: common.HexToAddress(args["from"]), To: common.HexToAddress(args["to"]), Value: value}
+		switch t.Kind() {
+		case "mint":
+			r.Mints++
+			r.Minted.Add(r.Minted, value)
+		case "burn":
+			r.Burns++
+			r.Burned.Add(r.Burned, value)
+		default:
+			r.Transfers++
+		}
+		if c.LargeTransfer != nil && value.Cmp(c.LargeTransfer) > 0 {
+			r.LargeTransfers = append(r.LargeTransfers, t)
+		}
+	}
+	return nil
+}
+
+// Subject is a one-line title of the report.
+func (r *Report) Subject() string {
+	return fmt.Sprintf("RSV on %v: operations report for %v", r.Network, r.window())
+}
+
+func (r *Report) window() string {
+	if r.To.Sub(r.From) == 24*time.Hour && r.From.Equal(r.From.Truncate(24*time.Hour)) {
+		return r.From.Format("2006-01-02")
+	}
+	return r.From.Format("2006-01-02 15:04") + " to " + r.To.Format("2006-01-02 15:04") + " UTC"
+}
+
+// Text renders the report as plain text, for chat and email.
+func (r *Report) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v (blocks %v-%v)\n\n", r.Subject(), r.FromBlock, r.ToBlock)
+
+	net := new(big.Int).Sub(r.Minted, r.Burned)
+	fmt.Fprintf(&b, "Supply: minted %v RSV in %v, burned %v RSV in %v, net %v RSV; %v.\n",
+		rsv(r.Minted), count(r.Mints, "mint"), rsv(r.Burned), count(r.Burns, "burn"), rsv(net), count(r.Transfers, "transfer"))
+
+	if s := r.Backing; s != nil {
+		fmt.Fprintf(&b, "Backing at block %v: supply %v RSV, collateralization %v", s.Block, rsv(s.Supply), percent(s.Collateralization()))
+		var tokens []string
+		for _, t := range s.Tokens {
+			tokens = append(tokens, fmt.Sprintf("%v %v", t.Symbol, percent(s.Ratio(t))))
+		}
+		if len(tokens) > 0 {
+			fmt.Fprintf(&b, " (%v)", strings.Join(tokens, ", "))
+		}
+		b.WriteString(".\n")
+	}
+
+	fmt.Fprintf(&b, "\nLarge transfers: %v\n", len(r.LargeTransfers))
+	for _, t := range r.LargeTransfers {
+		fmt.Fprintf(&b, "- %v of %v RSV from %v to %v (block %v, tx %v)\n", t.Kind(), rsv(t.Value), t.From.Hex(), t.To.Hex(), t.Block, t.TxHash.Hex())
+	}
+
+	fmt.Fprintf(&b, "\nGovernance and admin events: %v\n", len(r.Governance))
+	for _, e := range r.Governance {
+		fmt.Fprintf(&b, "- %v.%v%v (block %v, tx %v)\n", e.Contract, e.Event, formatArgs(e.Args), e.Block, e.TxHash.Hex())
+	}
+
+	fmt.Fprintf(&b, "\nAnomalies flagged: %v\n", len(r.Flags))
+	for _, f := range r.Flags {
+		fmt.Fprintf(&b, "- %v: %v (block %v)\n", f.Rule, f.Detail, f.Block)
+	}
+
+	total := new(big.Int)
+	for _, g := range r.Gas {
+		total.Add(total, g.Cost)
+	}
+	fmt.Fprintf(&b, "\nGas spent: %v ETH\n", units.Format(total, 18))
+	for _, g := range r.Gas {
+		fmt.Fprintf(&b, "- %v (%v): %v ETH in %v\n", g.Operator, g.Address.Hex(), units.Format(g.Cost, 18), count(g.Transactions, "transaction"))
+	}
+	return b.String()
+}
+
+// formatArgs renders an event's JSON arguments as " name=value ...", in name order.
+func formatArgs(data string) string {
+	var args map[string]string
+	if err := json.Unmarshal([]byte(data), &args); err != nil || len(args) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, " %v=%v", name, args[name])
+	}
+	return b.String()
+}
+
+func rsv(n *big.Int) string {
+	return units.Format(n, 18)
+}
+
+func count(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%v %vs", n, noun)
+}
+
+func percent(r float64) string {
+	if r > 1e6 {
+		return "unbounded"
+	}
+	return fmt.Sprintf("%.2f%%", 100*r)
+}
@@ -0,0 +1,178 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/indexer"
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/stream"
+)
+
+var (
+	alice = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	bob   = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+)
+
+// headers serves headers 600 seconds apart: a day is 144 blocks.
+type headers struct{}
+
+func (headers) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: number, Time: number.Uint64() * 600}, nil
+}
+
+func attoRSV(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil))
+}
+
+func event(block uint64, contract, name string, args map[string]string) indexer.Event {
+	b, _ := json.Marshal(args)
+	return indexer.Event{
+		Block:    block,
+		TxHash:   common.BigToHash(big.NewInt(int64(block))),
+		Contract: contract,
+		Event:    name,
+		Args:     string(b),
+		Topics:   "[]",
+		Data:     "0x",
+	}
+}
+
+func transfer(block uint64, from, to common.Address, value *big.Int) indexer.Event {
+	return event(block, "Reserve", "Transfer", map[string]string{"from": from.Hex(), "to": to.Hex(), "value": value.String()})
+}
+
+func testCompiler(t *testing.T) (*Compiler, func()) {
+	dir, err := ioutil.TempDir("", "report")
+	require.NoError(t, err)
+	store, err := indexer.OpenStore(indexer.SQLite, filepath.Join(dir, "events.db"))
+	require.NoError(t, err)
+	zero := common.Address{}
+	issuance := event(170, "Manager", "Issuance", map[string]string{"user": bob.Hex(), "amount": "1"})
+	issuance.LogIndex = 1
+	events := []indexer.Event{
+		transfer(100, zero, alice, attoRSV(1000)), // the day before
+		transfer(150, zero, alice, attoRSV(500)),
+		transfer(160, alice, bob, attoRSV(200)),
+		event(170, "Manager", "ProposalAccepted", map[string]string{"id": "3", "proposer": bob.Hex()}),
+		issuance,
+		transfer(200, bob, zero, attoRSV(50)),
+		event(290, "Reserve", "Paused", map[string]string{"account": alice.Hex()}), // the day after
+	}
+	require.NoError(t, store.Save(context.Background(), "test", events, stream.State{Blocks: []stream.Block{{Number: 300}}}))
+	c := &Compiler{
+		Node:          headers{},
+		Store:         store,
+		Indexer:       "test",
+		LargeTransfer: attoRSV(300),
+		ReadAt: func(ctx context.Context, block uint64) (*metrics.State, error) {
+			return &metrics.State{Block: block, Supply: attoRSV(1450), RSVDecimals: 18}, nil
+		},
+		Network: "testnet",
+	}
+	return c, func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	c, done := testCompiler(t)
+	defer done()
+	day := time.Unix(86400, 0)
+	r, err := c.Compile(context.Background(), day, day.Add(24*time.Hour))
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(144), r.FromBlock)
+	assert.Equal(t, uint64(287), r.ToBlock)
+	assert.Equal(t, attoRSV(500), r.Minted)
+	assert.Equal(t, attoRSV(50), r.Burned)
+	assert.Equal(t, []int{1, 1, 1}, []int{r.Mints, r.Burns, r.Transfers})
+	require.Len(t, r.LargeTransfers, 1)
+	assert.Equal(t, "mint", r.LargeTransfers[0].Kind())
+	require.Len(t, r.Governance, 1)
+	assert.Equal(t, "ProposalAccepted", r.Governance[0].Event)
+	assert.Equal(t, uint64(287), r.Backing.Block)
+
+	text := r.Text()
+	for _, line := range []string{
+		"RSV on testnet: operations report for 1970-01-02 (blocks 144-287)",
+		"Supply: minted 500 RSV in 1 mint, burned 50 RSV in 1 burn, net 450 RSV; 1 transfer.",
+		"Backing at block 287: supply 1450 RSV, collateralization unbounded.",
+		"- mint of 500 RSV from " + common.Address{}.Hex() + " to " + alice.Hex() + " (block 150, tx " + common.BigToHash(big.NewInt(150)).Hex() + ")",
+		"- Manager.ProposalAccepted id=3 proposer=" + bob.Hex() + " (block 170, tx " + common.BigToHash(big.NewInt(170)).Hex() + ")",
+		"Gas spent: 0 ETH",
+	} {
+		assert.Contains(t, strings.Split(text, "\n"), line)
+	}
+
+	// A day the indexer hasn't finished can't be reported yet.
+	_, err = c.Compile(context.Background(), day.Add(24*time.Hour), day.Add(48*time.Hour))
+	assert.Error(t, err)
+}
+
+func TestReporter(t *testing.T) {
+	c, done := testCompiler(t)
+	defer done()
+	dir, err := ioutil.TempDir("", "report")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var posted []string
+	var mailed []string
+	failMail := true
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if failMail {
+			failMail = false
+			return errors.New("connection refused")
+		}
+		mailed = append(mailed, string(msg))
+		return nil
+	}
+	defer func() { sendMail = smtp.SendMail }()
+	r := &Reporter{
+		Compiler: c,
+		Delay:    time.Hour,
+		Post: func(ctx context.Context, text string) error {
+			posted = append(posted, text)
+			return nil
+		},
+		Mail:      &Mail{Server: "smtp.example.com:587", From: "ops@example.com", To: []string{"team@example.com"}},
+		StateFile: filepath.Join(dir, "report.json"),
+	}
+	ctx := context.Background()
+
+	// Until the delay after the day has passed, the day before is due, and the indexer has it.
+	now := time.Unix(2*86400+1800, 0)
+	assert.Error(t, r.Step(ctx, now), "the mail fails")
+	require.Len(t, posted, 1)
+	assert.Contains(t, posted[0], "operations report for 1970-01-01")
+	assert.Empty(t, mailed)
+
+	// Only the mail is tried again.
+	require.NoError(t, r.Step(ctx, now))
+	require.NoError(t, r.Step(ctx, now))
+	assert.Len(t, posted, 1)
+	require.Len(t, mailed, 1)
+	assert.Contains(t, mailed[0], "Subject: RSV on testnet: operations report for 1970-01-01\r\n")
+	assert.Contains(t, mailed[0], "To: team@example.com\r\n")
+
+	// Once the delay has passed, the day just ended is due.
+	require.NoError(t, r.Step(ctx, now.Add(time.Hour)))
+	require.Len(t, posted, 2)
+	assert.Contains(t, posted[1], "operations report for 1970-01-02")
+}
@@ -0,0 +1,128 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Reporter sends the report of each UTC day once the day has ended and the indexer has stored
+// it, to Post and by Mail. A report is sent once to each; if one fails, only it is tried again.
+// After a pause of several days, only the last day's report is sent.
+type Reporter struct {
+	Compiler *Compiler
+
+	// Delay is how long after the end of a day to wait before reporting it, for the indexer to
+	// store the day's last blocks.
+	Delay time.Duration
+
+	// Post, if set, delivers the report to chat, such as emergency.Notifier.Notify.
+	Post func(ctx context.Context, text string) error
+
+	// Mail, if set, emails the report.
+	Mail *Mail
+
+	// StateFile records what has been sent, across restarts.
+	StateFile string
+
+	// Interval is how often to check whether a report is due.
+	Interval time.Duration
+}
+
+// sent is the Reporter's state.
+type sent struct {
+	Day    string `json:"day"`
+	Posted bool   `json:"posted"`
+	Mailed bool   `json:"mailed"`
+}
+
+// Run sends the reports as they come due until ctx is done.
+func (r *Reporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Step(ctx, time.Now()); err != nil {
+			log.Printf("report: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Step sends the report of the last day to have ended by now, less the Delay, if it hasn't been
+// sent.
+func (r *Reporter) Step(ctx context.Context, now time.Time) error {
+	to := now.UTC().Add(-r.Delay).Truncate(24 * time.Hour)
+	from := to.Add(-24 * time.Hour)
+	state, err := r.load()
+	if err != nil {
+		return err
+	}
+	day := from.Format("2006-01-02")
+	if state.Day != day {
+		state = sent{Day: day}
+	}
+	if (state.Posted || r.Post == nil) && (state.Mailed || r.Mail == nil) {
+		return nil
+	}
+
+	report, err := r.Compiler.Compile(ctx, from, to)
+	if err != nil {
+		return errors.Wrapf(err, "compiling the report of %v", day)
+	}
+	text := report.Text()
+	var failed error
+	if r.Post != nil && !state.Posted {
+		if err := r.Post(ctx, "```\n"+text+"```"); err != nil {
+			failed = errors.Wrapf(err, "posting the report of %v", day)
+		} else {
+			state.Posted = true
+		}
+	}
+	if r.Mail != nil && !state.Mailed {
+		if err := r.Mail.Send(report.Subject(), text); err != nil {
+			failed = errors.Wrapf(err, "mailing the report of %v", day)
+		} else {
+			state.Mailed = true
+		}
+	}
+	if err := r.save(state); err != nil {
+		return err
+	}
+	if failed == nil {
+		log.Printf("report: sent the report of %v", day)
+	}
+	return failed
+}
+
+func (r *Reporter) load() (sent, error) {
+	var s sent
+	b, err := ioutil.ReadFile(r.StateFile)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, errors.Wrap(err, "reading report state")
+	}
+	return s, errors.Wrap(json.Unmarshal(b, &s), "parsing report state")
+}
+
+func (r *Reporter) save(s sent) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding report state")
+	}
+	tmp := r.StateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "writing report state")
+	}
+	return errors.Wrap(os.Rename(tmp, r.StateFile), "writing report state")
+}
@@ -0,0 +1,155 @@
+// Package rotate moves a role, such as the Reserve's minter, from one key to another, checking
+// each step and undoing the change if it can't be verified.
+//
+// Each role is a single address held by the contract, so granting it to the new key and revoking
+// it from the old one happen in the same transaction. The rotation still reads the role back
+// twice after that transaction, once to verify that the new key holds it and once more to verify
+// that the old key no longer does, so that a change by anyone else in between is caught.
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/params"
+)
+
+// Roles are the roles that can be rotated, each named by the parameter that holds it.
+var Roles = []struct {
+	Name     string
+	Contract string
+}{
+	{"minter", "Reserve"},
+	{"pauser", "Reserve"},
+	{"freezer", "Reserve"},
+	{"vetoer", "Manager"},
+}
+
+// Lookup returns the parameter that holds the named role.
+func Lookup(role string) (*params.Param, error) {
+	for _, r := range Roles {
+		if r.Name != role {
+			continue
+		}
+		p := params.Find(r.Contract, r.Name)
+		if p == nil {
+			return nil, errors.Errorf("the %v role is not supported by this version of the tools", role)
+		}
+		return p, nil
+	}
+	return nil, errors.Errorf("unknown role %q", role)
+}
+
+// Chain is the rotation's view of the deployment.
+type Chain interface {
+	// Holder returns the address that contract's getter returns.
+	Holder(ctx context.Context, contract, getter string) (common.Address, error)
+
+	// Set sends p's setter with addr, and waits for it to be mined.
+	Set(ctx context.Context, p *params.Param, addr common.Address) (common.Hash, error)
+}
+
+// Rotation moves one role, sending from Signer.
+type Rotation struct {
+	Chain  Chain
+	Param  *params.Param
+	Signer common.Address
+	Log    io.Writer
+}
+
+// Rotate moves the role from old to new. Nothing is sent unless old holds the role and Signer
+// may change it. If the change goes through but can't be verified, Rotate gives the role back to
+// old, if Signer's authority survived the change.
+func (r *Rotation) Rotate(ctx context.Context, old, new common.Address) error {
+	p := r.Param
+	if new == (common.Address{}) {
+		return errors.New("refusing to give a role to the zero address")
+	}
+	if new == old {
+		return errors.Errorf("%v already holds %v.%v", new.Hex(), p.Contract, p.Name)
+	}
+	holder, err := r.Chain.Holder(ctx, p.Contract, p.Name)
+	if err != nil {
+		return err
+	}
+	if holder != old {
+		return errors.Errorf("%v.%v is held by %v, not %v", p.Contract, p.Name, holder.Hex(), old.Hex())
+	}
+
+	// The signer may roll back only through a role other than the one being rotated away.
+	authorized, canRollback := false, false
+	for _, getter := range p.Roles {
+		h, err := r.Chain.Holder(ctx, p.Contract, getter)
+		if err != nil {
+			return err
+		}
+		if h == r.Signer {
+			authorized = true
+			canRollback = canRollback || getter != p.Name || r.Signer == new
+		}
+	}
+	if !authorized {
+		return errors.Errorf("signer %v may not change %v.%v; that needs %v", r.Signer.Hex(), p.Contract, p.Name, p.Roles)
+	}
+
+	r.logf("Granting %v.%v to %v, revoking it from %v ...", p.Contract, p.Name, new.Hex(), old.Hex())
+	hash, sendErr := r.Chain.Set(ctx, p, new)
+	if sendErr == nil {
+		r.logf("  sent in %v", hash.Hex())
+	}
+	holder, err = r.Chain.Holder(ctx, p.Contract, p.Name)
+	if err != nil {
+		return errors.Wrap(err, "verifying the grant; check the role by hand")
+	}
+	if sendErr != nil && holder == old {
+		return errors.Wrap(sendErr, "nothing changed")
+	}
+
+	verifyErr := r.check(holder == new, "grant", "%v holds %v.%v", new.Hex(), p.Contract, p.Name)
+	if verifyErr == nil {
+		if holder, err = r.Chain.Holder(ctx, p.Contract, p.Name); err != nil {
+			return errors.Wrap(err, "verifying the revocation; check the role by hand")
+		}
+		verifyErr = r.check(holder != old, "revocation", "%v no longer holds %v.%v", old.Hex(), p.Contract, p.Name)
+		if verifyErr == nil {
+			return nil
+		}
+	}
+	if !canRollback {
+		return errors.Wrapf(verifyErr, "%v can no longer change %v.%v, so the change cannot be rolled back here; "+
+			"the role's holder or the contract owner must restore it", r.Signer.Hex(), p.Contract, p.Name)
+	}
+
+	r.logf("Rolling back: giving %v.%v back to %v ...", p.Contract, p.Name, old.Hex())
+	hash, err = r.Chain.Set(ctx, p, old)
+	if err != nil {
+		return errors.Wrapf(verifyErr, "and rolling back failed (%v)", err)
+	}
+	r.logf("  sent in %v", hash.Hex())
+	if holder, err = r.Chain.Holder(ctx, p.Contract, p.Name); err != nil || holder != old {
+		return errors.Wrapf(verifyErr, "and the rollback did not verify (held by %v, %v)", holder.Hex(), err)
+	}
+	r.logf("  rolled back: %v holds %v.%v again", old.Hex(), p.Contract, p.Name)
+	return errors.Wrap(verifyErr, "rolled back")
+}
+
+// check logs the fact if ok holds, and otherwise returns an error saying that the named step
+// did not verify.
+func (r *Rotation) check(ok bool, step, format string, args ...interface{}) error {
+	fact := fmt.Sprintf(format, args...)
+	if !ok {
+		return errors.Errorf("%v did not verify: expected %v", step, fact)
+	}
+	r.logf("  verified: %v", fact)
+	return nil
+}
+
+func (r *Rotation) logf(format string, args ...interface{}) {
+	if r.Log != nil {
+		fmt.Fprintf(r.Log, format+"\n", args...)
+	}
+}
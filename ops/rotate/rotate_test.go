@@ -0,0 +1,113 @@
+package rotate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/params"
+)
+
+var (
+	owner = common.HexToAddress("0x000000000000000000000000000000000000a0a0")
+	alice = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	bob   = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	carol = common.HexToAddress("0x00000000000000000000000000000000000ca201")
+)
+
+// fakeChain holds the Reserve's roles. hijack, if set, takes the role right after each change.
+type fakeChain struct {
+	roles  map[string]common.Address
+	hijack *common.Address
+	setErr error
+	sent   []common.Address
+}
+
+func newFakeChain() *fakeChain {
+	return &fakeChain{roles: map[string]common.Address{"owner": owner, "minter": alice}}
+}
+
+func (c *fakeChain) Holder(ctx context.Context, contract, getter string) (common.Address, error) {
+	return c.roles[getter], nil
+}
+
+func (c *fakeChain) Set(ctx context.Context, p *params.Param, addr common.Address) (common.Hash, error) {
+	if c.setErr != nil {
+		return common.Hash{}, c.setErr
+	}
+	c.sent = append(c.sent, addr)
+	c.roles[p.Name] = addr
+	if c.hijack != nil {
+		c.roles[p.Name] = *c.hijack
+		c.hijack = nil
+	}
+	return common.HexToHash("0x01"), nil
+}
+
+func rotation(t *testing.T, c *fakeChain, signer common.Address) *Rotation {
+	p, err := Lookup("minter")
+	require.NoError(t, err)
+	return &Rotation{Chain: c, Param: p, Signer: signer}
+}
+
+func TestLookup(t *testing.T) {
+	p, err := Lookup("vetoer")
+	require.NoError(t, err)
+	assert.Equal(t, "Manager", p.Contract)
+	assert.Equal(t, "setVetoer", p.Setter)
+
+	_, err = Lookup("owner")
+	assert.Error(t, err)
+
+	// The Manager's operators are many, and are added and removed rather than rotated.
+	_, err = Lookup("operator")
+	assert.Error(t, err)
+}
+
+func TestRotate(t *testing.T) {
+	c := newFakeChain()
+	require.NoError(t, rotation(t, c, owner).Rotate(context.Background(), alice, bob))
+	assert.Equal(t, bob, c.roles["minter"])
+	assert.Equal(t, []common.Address{bob}, c.sent)
+
+	// The old key may hand its own role on.
+	c = newFakeChain()
+	require.NoError(t, rotation(t, c, alice).Rotate(context.Background(), alice, bob))
+	assert.Equal(t, bob, c.roles["minter"])
+}
+
+func TestRotateRefuses(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeChain()
+	assert.Error(t, rotation(t, c, owner).Rotate(ctx, bob, carol), "old key doesn't hold the role")
+	assert.Error(t, rotation(t, c, carol).Rotate(ctx, alice, bob), "signer not authorized")
+	assert.Error(t, rotation(t, c, owner).Rotate(ctx, alice, common.Address{}), "zero address")
+	assert.Error(t, rotation(t, c, owner).Rotate(ctx, alice, alice), "no change")
+	assert.Empty(t, c.sent)
+
+	c.setErr = errors.New("out of gas")
+	assert.Error(t, rotation(t, c, owner).Rotate(ctx, alice, bob))
+	assert.Equal(t, alice, c.roles["minter"])
+}
+
+func TestRotateRollsBack(t *testing.T) {
+	c := newFakeChain()
+	c.hijack = &carol
+	err := rotation(t, c, owner).Rotate(context.Background(), alice, bob)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rolled back")
+	assert.Equal(t, alice, c.roles["minter"])
+	assert.Equal(t, []common.Address{bob, alice}, c.sent)
+
+	// Without the owner's authority, there is no way back.
+	c = newFakeChain()
+	c.hijack = &carol
+	err = rotation(t, c, alice).Rotate(context.Background(), alice, bob)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot be rolled back")
+	assert.Equal(t, []common.Address{bob}, c.sent)
+}
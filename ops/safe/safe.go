@@ -0,0 +1,220 @@
+// Package safe reads the approvals behind transactions executed by a Safe (formerly Gnosis Safe)
+// multisig wallet: which owners signed the Safe transaction, from the signatures the wallet
+// checked on chain, and, from a Safe Transaction Service, when each confirmed it.
+//
+// A Safe transaction is identified by its safeTxHash, the EIP-712 hash that the owners sign.
+// Safes from version 1.1.1 emit it in their ExecutionSuccess event, which is where Executed
+// takes it from, so that it needn't be recomputed for each version's domain.
+package safe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// ABI is the part of the Safe interface that the package uses.
+const ABI = `[
+	{"type":"function","name":"execTransaction","constant":false,"inputs":[
+		{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"},
+		{"name":"operation","type":"uint8"},{"name":"safeTxGas","type":"uint256"},{"name":"baseGas","type":"uint256"},
+		{"name":"gasPrice","type":"uint256"},{"name":"gasToken","type":"address"},{"name":"refundReceiver","type":"address"},
+		{"name":"signatures","type":"bytes"}],"outputs":[{"name":"success","type":"bool"}]},
+	{"type":"event","name":"ExecutionSuccess","anonymous":false,"inputs":[
+		{"name":"txHash","type":"bytes32","indexed":false},{"name":"payment","type":"uint256","indexed":false}]}
+]`
+
+var parsed abi.ABI
+
+func init() {
+	var err error
+	if parsed, err = abi.JSON(strings.NewReader(ABI)); err != nil {
+		panic(err)
+	}
+}
+
+// Operations that a Safe transaction can make.
+const (
+	Call         = 0
+	DelegateCall = 1
+)
+
+// Execution is a Safe transaction that was executed.
+type Execution struct {
+	// Safe is the wallet, and SafeTxHash the hash its owners signed.
+	Safe       common.Address
+	SafeTxHash common.Hash
+
+	// To, Value, Data, and Operation are the call the wallet made.
+	To        common.Address
+	Value     *big.Int
+	Data      []byte
+	Operation uint8
+
+	// Signers are the owners whose signatures the wallet checked, in the order given.
+	Signers []common.Address
+}
+
+// Executed returns the Safe transaction that tx executed, given its receipt, or nil if tx isn't a
+// successful call of execTransaction.
+func Executed(tx *types.Transaction, receipt *types.Receipt) (*Execution, error) {
+	method := parsed.Methods["execTransaction"]
+	if tx.To() == nil || len(tx.Data()) < 4 || !bytes.Equal(tx.Data()[:4], method.Id()) {
+		return nil, nil
+	}
+	var hash *common.Hash
+	topic := parsed.Events["ExecutionSuccess"].Id()
+	for _, l := range receipt.Logs {
+		if l.Address != *tx.To() || len(l.Topics) == 0 || l.Topics[0] != topic {
+			continue
+		}
+		// Later versions index the hash.
+		h := l.Topics[len(l.Topics)-1]
+		if len(l.Topics) == 1 && len(l.Data) >= 32 {
+			h = common.BytesToHash(l.Data[:32])
+		}
+		hash = &h
+	}
+	if hash == nil {
+		return nil, nil
+	}
+	values, err := method.Inputs.UnpackValues(tx.Data()[4:])
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding execTransaction of tx %v", tx.Hash().Hex())
+	}
+	e := &Execution{
+		Safe:       *tx.To(),
+		SafeTxHash: *hash,
+		To:         values[0].(common.Address),
+		Value:      values[1].(*big.Int),
+		Data:       values[2].([]byte),
+		Operation:  values[3].(uint8),
+	}
+	if e.Signers, err = Signers(e.SafeTxHash, values[9].([]byte)); err != nil {
+		return nil, errors.Wrapf(err, "tx %v", tx.Hash().Hex())
+	}
+	return e, nil
+}
+
+// Signers returns the owners that signatures, as execTransaction takes them, are from.
+//
+// Each signature is 65 bytes, r, s, and v, where v tells how to read it: 0 is a contract
+// signature and 1 an approved hash, both with the owner in r; over 30 is an eth_sign
+// signature, with v 4 greater; otherwise it is an ECDSA signature of hash. The dynamic parts of
+// contract signatures follow the 65-byte parts, starting at the least s of them.
+func Signers(hash common.Hash, signatures []byte) ([]common.Address, error) {
+	end := len(signatures) / 65 * 65
+	var signers []common.Address
+	for i := 0; i+65 <= end; i += 65 {
+		sig := signatures[i : i+65]
+		r, s, v := sig[:32], sig[32:64], sig[64]
+		switch {
+		case v == 0 || v == 1:
+			if v == 0 {
+				if offset := new(big.Int).SetBytes(s); offset.IsUint64() && offset.Uint64() < uint64(end) {
+					end = int(offset.Uint64())
+					if i+65 > end {
+						return nil, errors.New("contract signature data overlaps the signatures")
+					}
+				}
+			}
+			signers = append(signers, common.BytesToAddress(r))
+		default:
+			digest := hash
+			if v > 30 {
+				digest = crypto.Keccak256Hash([]byte("\x19Ethereum Signed Message:\n32"), hash.Bytes())
+				v -= 4
+			}
+			if v != 27 && v != 28 {
+				return nil, errors.Errorf("signature %v has invalid v value %v", i/65, sig[64])
+			}
+			pub, err := crypto.SigToPub(digest.Bytes(), append(append([]byte(nil), sig[:64]...), v-27))
+			if err != nil {
+				return nil, errors.Wrapf(err, "recovering the signer of signature %v", i/65)
+			}
+			signers = append(signers, crypto.PubkeyToAddress(*pub))
+		}
+	}
+	return signers, nil
+}
+
+// Link returns the link to a Safe transaction that template gives, with "{safe}" and
+// "{safeTxHash}" replaced, such as
+//
+//	https://app.safe.global/transactions/tx?safe=eth:{safe}&id=multisig_{safe}_{safeTxHash}
+func Link(template string, safe common.Address, safeTxHash common.Hash) string {
+	return strings.NewReplacer("{safe}", safe.Hex(), "{safeTxHash}", safeTxHash.Hex()).Replace(template)
+}
+
+// Service is a Safe Transaction Service, which keeps the Safe transactions that owners propose
+// and confirm before they are executed.
+type Service struct {
+	// URL is the service's root, such as https://safe-transaction-mainnet.safe.global.
+	URL string
+
+	HTTP *http.Client
+}
+
+// NewService returns the service at url.
+func NewService(url string) *Service {
+	return &Service{URL: strings.TrimSuffix(url, "/"), HTTP: &http.Client{Timeout: time.Minute}}
+}
+
+// Proposal is what the service knows of a Safe transaction.
+type Proposal struct {
+	Nonce uint64 `json:"nonce"`
+
+	// Proposer is who proposed it, where the service records it.
+	Proposer common.Address `json:"proposer"`
+
+	// ConfirmationsRequired is the Safe's threshold when it was proposed.
+	ConfirmationsRequired int            `json:"confirmationsRequired"`
+	Confirmations         []Confirmation `json:"confirmations"`
+}
+
+// Confirmation is an owner's signature of a proposal.
+type Confirmation struct {
+	Owner          common.Address `json:"owner"`
+	SubmissionDate time.Time      `json:"submissionDate"`
+}
+
+// Proposal returns what the service knows of the Safe transaction with hash safeTxHash, or nil
+// if it knows nothing, as for a transaction its owners signed elsewhere.
+func (s *Service) Proposal(ctx context.Context, safeTxHash common.Hash) (*Proposal, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%v/api/v1/multisig-transactions/%v/", s.URL, safeTxHash.Hex()), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "building Safe Transaction Service request")
+	}
+	resp, err := s.HTTP.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "querying Safe Transaction Service")
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading Safe Transaction Service response")
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("Safe Transaction Service returned %v: %.200s", resp.Status, body)
+	}
+	var p Proposal
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, errors.Wrap(err, "parsing Safe Transaction Service response")
+	}
+	return &p, nil
+}
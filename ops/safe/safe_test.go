@@ -0,0 +1,127 @@
+package safe
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	wallet  = common.HexToAddress("0x00000000000000000000000000000000005afe00")
+	reserve = common.HexToAddress("0x196f4727526eA7FB1e17b2071B3d8eAA38486988")
+	owner   = common.HexToAddress("0x000000000000000000000000000000000000abcd")
+)
+
+func sign(t *testing.T, hash common.Hash, ethSign bool) ([]byte, common.Address) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	digest := hash
+	if ethSign {
+		digest = crypto.Keccak256Hash([]byte("\x19Ethereum Signed Message:\n32"), hash.Bytes())
+	}
+	sig, err := crypto.Sign(digest.Bytes(), key)
+	require.NoError(t, err)
+	sig[64] += 27
+	if ethSign {
+		sig[64] += 4
+	}
+	return sig, crypto.PubkeyToAddress(key.PublicKey)
+}
+
+func TestSigners(t *testing.T) {
+	hash := crypto.Keccak256Hash([]byte("safe tx"))
+	ecdsa, a := sign(t, hash, false)
+	ethSign, b := sign(t, hash, true)
+	approved := append(append(common.LeftPadBytes(owner.Bytes(), 32), make([]byte, 32)...), 1)
+
+	// A contract signature has its data after the 65-byte parts, at the offset in s.
+	contract := append(common.LeftPadBytes(wallet.Bytes(), 32), common.LeftPadBytes(big.NewInt(4*65).Bytes(), 32)...)
+	contract = append(contract, 0)
+	dynamic := append(common.LeftPadBytes(big.NewInt(65).Bytes(), 32), make([]byte, 65)...)
+
+	var signatures []byte
+	for _, s := range [][]byte{ecdsa, ethSign, approved, contract, dynamic} {
+		signatures = append(signatures, s...)
+	}
+	signers, err := Signers(hash, signatures)
+	require.NoError(t, err)
+	assert.Equal(t, []common.Address{a, b, owner, wallet}, signers)
+
+	ecdsa[64] = 29
+	_, err = Signers(hash, ecdsa)
+	assert.Error(t, err)
+}
+
+func TestExecuted(t *testing.T) {
+	hash := crypto.Keccak256Hash([]byte("safe tx"))
+	sig, signer := sign(t, hash, false)
+	pause := crypto.Keccak256([]byte("pause()"))[:4]
+	data, err := parsed.Pack("execTransaction", reserve, new(big.Int), pause, uint8(Call),
+		new(big.Int), new(big.Int), new(big.Int), common.Address{}, common.Address{}, sig)
+	require.NoError(t, err)
+	tx := types.NewTransaction(0, wallet, new(big.Int), 100000, big.NewInt(1e9), data)
+	success := &types.Log{
+		Address: wallet,
+		Topics:  []common.Hash{parsed.Events["ExecutionSuccess"].Id()},
+		Data:    append(hash.Bytes(), make([]byte, 32)...),
+	}
+
+	e, err := Executed(tx, &types.Receipt{Logs: []*types.Log{success}})
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	assert.Equal(t, wallet, e.Safe)
+	assert.Equal(t, hash, e.SafeTxHash)
+	assert.Equal(t, reserve, e.To)
+	assert.Equal(t, pause, e.Data)
+	assert.Equal(t, []common.Address{signer}, e.Signers)
+
+	// Without the event, the Safe transaction failed, and the call wasn't made.
+	e, err = Executed(tx, &types.Receipt{})
+	require.NoError(t, err)
+	assert.Nil(t, e)
+
+	// Nor is a call of anything else a Safe transaction.
+	e, err = Executed(types.NewTransaction(0, reserve, new(big.Int), 100000, big.NewInt(1e9), pause), &types.Receipt{Logs: []*types.Log{success}})
+	require.NoError(t, err)
+	assert.Nil(t, e)
+
+	assert.Equal(t, "https://app.safe.global/transactions/tx?safe=eth:"+wallet.Hex()+"&id=multisig_"+wallet.Hex()+"_"+hash.Hex(),
+		Link("https://app.safe.global/transactions/tx?safe=eth:{safe}&id=multisig_{safe}_{safeTxHash}", wallet, hash))
+}
+
+func TestProposal(t *testing.T) {
+	hash := crypto.Keccak256Hash([]byte("safe tx"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/multisig-transactions/"+hash.Hex()+"/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"safe": "` + wallet.Hex() + `", "nonce": 7, "proposer": "` + owner.Hex() + `",
+			"confirmationsRequired": 2, "confirmations": [{"owner": "` + owner.Hex() + `",
+			"submissionDate": "2020-03-01T12:00:00Z", "signatureType": "EOA"}]}`))
+	}))
+	defer server.Close()
+	s := NewService(server.URL + "/")
+
+	p, err := s.Proposal(context.Background(), hash)
+	require.NoError(t, err)
+	assert.Equal(t, &Proposal{
+		Nonce:                 7,
+		Proposer:              owner,
+		ConfirmationsRequired: 2,
+		Confirmations:         []Confirmation{{Owner: owner, SubmissionDate: time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)}},
+	}, p)
+
+	p, err = s.Proposal(context.Background(), common.Hash{})
+	require.NoError(t, err)
+	assert.Nil(t, p)
+}
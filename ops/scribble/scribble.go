@@ -0,0 +1,141 @@
+// Package scribble builds the contracts instrumented with their Scribble annotations, so that the
+// Go tests can run against code that checks them.
+//
+// An annotation is a comment on a function, such as
+//
+//	/// #if_succeeds {:msg "minting adds value to the supply"} totalSupply == old(totalSupply) + value;
+//
+// which Scribble (https://github.com/ConsenSys/scribble) compiles into a check at the end of the
+// function. Instrument copies the contracts and has Scribble instrument the copies in place,
+// leaving the sources in the repo alone; Compile builds an instrumented contract as `make json`
+// builds the original. The checks are instrumented with --no-assert, so a violated annotation
+// doesn't revert the transaction, which a test might expect to succeed or fail for its own
+// reasons, but emits an AssertionFailed event, which Violations finds in a receipt's logs.
+package scribble
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/build"
+)
+
+// eventABI is the event that instrumented code emits when an annotation is violated.
+const eventABI = `[
+	{"type":"event","name":"AssertionFailed","anonymous":false,"inputs":[{"name":"message","type":"string","indexed":false}]}
+]`
+
+var event = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(eventABI))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// AssertionFailed is the topic of the event that instrumented code emits when an annotation is
+// violated.
+var AssertionFailed = event.Events["AssertionFailed"].Id()
+
+// Violations returns the message of each annotation that logs report violated, in order. Scribble
+// prefixes each message with the annotation's id, as in "3: minting adds value to the supply".
+func Violations(logs []*types.Log) []string {
+	var messages []string
+	for _, log := range logs {
+		if len(log.Topics) == 0 || log.Topics[0] != AssertionFailed {
+			continue
+		}
+		var message string
+		if err := event.Unpack(&message, "AssertionFailed", log.Data); err != nil {
+			message = "(unparseable AssertionFailed event: " + err.Error() + ")"
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// Instrument copies the contracts in repo's contracts/ to dir/contracts, and has the scribble
+// executable instrument the copies of sources, paths relative to the repo, and everything they
+// import, for solc version. The sources without annotations are left as they are.
+func Instrument(ctx context.Context, scribble, repo, dir string, sources []string, version string) error {
+	dst := filepath.Join(dir, "contracts")
+	if err := os.RemoveAll(dst); err != nil {
+		return errors.Wrap(err, "removing the last instrumented contracts")
+	}
+	if err := copyTree(filepath.Join(repo, "contracts"), dst); err != nil {
+		return errors.Wrap(err, "copying the contracts")
+	}
+	args := []string{
+		"--output-mode", "files", "--arm", "--no-assert",
+		"--compiler-version", version,
+		"--utils-output-path", "contracts",
+	}
+	cmd := exec.CommandContext(ctx, scribble, append(args, sources...)...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "running %v: %s", scribble, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
+
+// Compile compiles the instrumented copy in dir of the contract that r records, with the solc
+// executable, as `make json` compiles the original, and returns solc's combined-json output.
+func Compile(ctx context.Context, solc, dir string, r *build.Record) ([]byte, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding the instrumented contracts")
+	}
+	args := []string{"--allow-paths", filepath.Join(abs, "contracts")}
+	if r.Optimizer > 0 {
+		args = append(args, "--optimize", "--optimize-runs", strconv.Itoa(r.Optimizer))
+	}
+	if r.EVMVersion != "" {
+		args = append(args, "--evm-version", r.EVMVersion)
+	}
+	args = append(args, r.Remappings...)
+	args = append(args,
+		"--combined-json=abi,ast,bin,bin-runtime,metadata,srcmap,srcmap-runtime,userdoc,devdoc",
+		r.Source)
+	cmd := exec.CommandContext(ctx, solc, args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "compiling the instrumented %v: %s", r.Contract, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// copyTree copies the files under src to dst, making the directories it needs.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, b, 0644)
+	})
+}
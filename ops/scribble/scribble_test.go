@@ -0,0 +1,67 @@
+package scribble
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// message ABI-encodes the data of an AssertionFailed event of s.
+func message(s string) []byte {
+	data := common.LeftPadBytes([]byte{0x20}, 32)
+	data = append(data, common.LeftPadBytes([]byte{byte(len(s))}, 32)...)
+	return append(data, common.RightPadBytes([]byte(s), (len(s)+31)/32*32)...)
+}
+
+func TestViolations(t *testing.T) {
+	assert.Equal(t, "0xb42604cb105a16c8f6db8a41e6b00c0c1b4826465e8bc504b3eb3e88b3e6a4a0", AssertionFailed.Hex())
+
+	transfer := common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	logs := []*types.Log{
+		{Topics: []common.Hash{transfer}, Data: common.LeftPadBytes([]byte{1}, 32)},
+		{Topics: []common.Hash{AssertionFailed}, Data: message("0: minting adds value to the supply")},
+		{},
+		{Topics: []common.Hash{AssertionFailed}, Data: message("4: the Vault stays fully collateralized")},
+	}
+	assert.Equal(t, []string{
+		"0: minting adds value to the supply",
+		"4: the Vault stays fully collateralized",
+	}, Violations(logs))
+	assert.Empty(t, Violations(logs[:1]))
+
+	bad := Violations([]*types.Log{{Topics: []common.Hash{AssertionFailed}, Data: []byte{1}}})
+	require.Len(t, bad, 1)
+	assert.Contains(t, bad[0], "unparseable")
+}
+
+func TestInstrument(t *testing.T) {
+	repo, err := ioutil.TempDir("", "scribble")
+	require.NoError(t, err)
+	defer os.RemoveAll(repo)
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "contracts", "rsv"), 0755))
+	source := []byte("pragma solidity 0.5.7;\n")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(repo, "contracts", "rsv", "Reserve.sol"), source, 0644))
+
+	dir := filepath.Join(repo, "scribble")
+	stale := filepath.Join(dir, "contracts", "Stale.sol")
+	require.NoError(t, os.MkdirAll(filepath.Dir(stale), 0755))
+	require.NoError(t, ioutil.WriteFile(stale, source, 0644))
+
+	// true stands in for scribble, leaving the copies as they are.
+	ctx := context.Background()
+	require.NoError(t, Instrument(ctx, "true", repo, dir, []string{"contracts/rsv/Reserve.sol"}, "0.5.7"))
+	b, err := ioutil.ReadFile(filepath.Join(dir, "contracts", "rsv", "Reserve.sol"))
+	require.NoError(t, err)
+	assert.Equal(t, source, b)
+	_, err = os.Stat(stale)
+	assert.True(t, os.IsNotExist(err), "the last instrumented copy is left over")
+
+	assert.Error(t, Instrument(ctx, "false", repo, dir, []string{"contracts/rsv/Reserve.sol"}, "0.5.7"))
+}
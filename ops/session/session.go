@@ -0,0 +1,212 @@
+// Package session sets up the shared context that every operator tool runs in: a node
+// connection, the deployment manifest, contract artifacts, a signer, and the audit trail.
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/audit"
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/manifest"
+	"github.com/reserve-protocol/rsv-beta/ops/prompt"
+	"github.com/reserve-protocol/rsv-beta/ops/signer"
+)
+
+// Config is the part of a tool's configuration file that describes where and as whom it runs.
+// Tools embed it in their own configuration types.
+type Config struct {
+	RPC       string        `json:"rpc"`
+	Network   string        `json:"network"`
+	ChainID   uint64        `json:"chainId"`
+	Manifest  string        `json:"manifest"`
+	Artifacts string        `json:"artifacts,omitempty"`
+	AuditLog  string        `json:"auditLog,omitempty"`
+	Signer    signer.Config `json:"signer"`
+
+	// RPCs, if set in place of RPC, are several HTTP(S) providers to spread the requests over,
+	// failing over between them, with reads of contract state needing Quorum of them to agree
+	// and providers more than MaxLagBlocks (default 5) behind the others skipped. See
+	// chain.Pool.
+	RPCs         []string `json:"rpcs,omitempty"`
+	Quorum       int      `json:"quorum,omitempty"`
+	MaxLagBlocks uint64   `json:"maxLagBlocks,omitempty"`
+
+	// ConfirmNetwork makes interactive tools ask for the network name to be typed before the
+	// first transaction, as they always do on mainnet.
+	ConfirmNetwork bool `json:"confirmNetwork,omitempty"`
+}
+
+// MainnetChainID is the chain ID of Ethereum mainnet.
+const MainnetChainID = 1
+
+// LoadConfig reads a JSON configuration file at path into v.
+func LoadConfig(path string, v interface{}) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "reading config")
+	}
+	return errors.Wrapf(json.Unmarshal(b, v), "parsing config %v", path)
+}
+
+// Session is an opened Config.
+type Session struct {
+	Config    Config
+	Client    *chain.Client
+	ChainID   *big.Int
+	Manifest  *manifest.Manifest
+	Artifacts *chain.Artifacts
+	Audit     *audit.Log
+
+	// Transactor is nil if the configuration has no signer.
+	Transactor *chain.Transactor
+}
+
+// Open connects to the configured node and loads everything else the Config refers to.
+// command names the tool invocation in the audit trail.
+func Open(ctx context.Context, c Config, command string) (*Session, error) {
+	if c.RPC == "" && len(c.RPCs) == 0 {
+		return nil, errors.New("config: rpc is not set")
+	}
+	if c.RPC != "" && len(c.RPCs) > 0 {
+		return nil, errors.New("config: set rpc or rpcs, not both")
+	}
+	if c.Manifest == "" {
+		return nil, errors.New("config: manifest is not set")
+	}
+	if c.ChainID == 0 {
+		return nil, errors.New("config: chainId is not set")
+	}
+	m, err := manifest.Load(c.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	client, err := dial(c)
+	if err != nil {
+		return nil, err
+	}
+	rpcChainID, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkChain(c, m, rpcChainID); err != nil {
+		return nil, err
+	}
+	s := &Session{
+		Config:    c,
+		Client:    client,
+		ChainID:   new(big.Int).SetUint64(c.ChainID),
+		Manifest:  m,
+		Artifacts: chain.NewArtifacts(c.Artifacts),
+	}
+	if c.AuditLog != "" {
+		if s.Audit, err = audit.Open(c.AuditLog); err != nil {
+			return nil, err
+		}
+	}
+	sgn, err := signer.Open(c.Signer)
+	if err != nil {
+		return nil, err
+	}
+	if sgn != nil {
+		s.Transactor = &chain.Transactor{
+			Backend: client,
+			Signer:  sgn,
+			ChainID: s.ChainID,
+			Audit:   s.Audit,
+			Network: c.Network,
+			Command: command,
+		}
+	}
+	return s, nil
+}
+
+// dial connects to the node, or the providers, of c.
+func dial(c Config) (*chain.Client, error) {
+	if len(c.RPCs) == 0 {
+		return chain.Dial(c.RPC)
+	}
+	maxLag := c.MaxLagBlocks
+	if maxLag == 0 {
+		maxLag = 5
+	}
+	p := &chain.Pool{URLs: c.RPCs, Quorum: c.Quorum, MaxLag: maxLag}
+	client, err := p.Dial()
+	return client, errors.Wrap(err, "config: rpcs")
+}
+
+// endpoint describes where c connects, for messages.
+func endpoint(c Config) string {
+	if len(c.RPCs) > 0 {
+		return strings.Join(c.RPCs, ", ")
+	}
+	return c.RPC
+}
+
+// checkChain checks that the node, the config, and the manifest all agree on the chain. A
+// manifest with no chain ID is accepted only if it lists no contracts yet, as when starting a
+// deployment; it is then stamped with the config's chain.
+func checkChain(c Config, m *manifest.Manifest, rpcChainID *big.Int) error {
+	if !rpcChainID.IsUint64() || rpcChainID.Uint64() != c.ChainID {
+		return errors.Errorf("the node at %v is on chain %v, but the config says chain %v (%v)", endpoint(c), rpcChainID, c.ChainID, c.Network)
+	}
+	if m.ChainID == 0 {
+		if len(m.Contracts) > 0 {
+			return errors.Errorf("manifest %v lists contracts but has no chainId", c.Manifest)
+		}
+		m.ChainID, m.Network = c.ChainID, c.Network
+	}
+	if m.ChainID != c.ChainID {
+		return errors.Errorf("manifest %v is for chain %v (%v), but the config says chain %v (%v)",
+			c.Manifest, m.ChainID, m.Network, c.ChainID, c.Network)
+	}
+	if m.Network != "" && m.Network != c.Network {
+		return errors.Errorf("manifest %v is for network %q, but the config says %q", c.Manifest, m.Network, c.Network)
+	}
+	return nil
+}
+
+// Interlock returns a Preflight check that, on mainnet or when the config sets confirmNetwork,
+// asks the operator to type the network name before the session's first transaction.
+// Interactive tools install it; daemons, which have no one to ask, don't.
+func (s *Session) Interlock(p *prompt.Prompter) chain.Preflight {
+	confirmed := false
+	return func(ctx context.Context, tx *chain.PendingTx) error {
+		if confirmed || s.ChainID.Uint64() != MainnetChainID && !s.Config.ConfirmNetwork {
+			return nil
+		}
+		fmt.Fprintf(p.Out, "This will send transactions on %v (chain %v).\n", s.Config.Network, s.ChainID)
+		if err := p.Expect("Type the network name to confirm:", s.Config.Network); err != nil {
+			return err
+		}
+		confirmed = true
+		return nil
+	}
+}
+
+// Contract binds the manifest's instance of the contract called name.
+func (s *Session) Contract(name string) (*chain.Contract, error) {
+	addr, err := s.Manifest.Address(name)
+	if err != nil {
+		return nil, err
+	}
+	artifact, err := s.Artifacts.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	return artifact.Bind(addr, s.Client), nil
+}
+
+// RequireTransactor returns the session's Transactor, or an error if no signer is configured.
+func (s *Session) RequireTransactor() (*chain.Transactor, error) {
+	if s.Transactor == nil {
+		return nil, errors.New("config: no signer is configured")
+	}
+	return s.Transactor, nil
+}
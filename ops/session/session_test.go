@@ -0,0 +1,35 @@
+package session
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/manifest"
+)
+
+func TestCheckChain(t *testing.T) {
+	c := Config{RPC: "http://localhost:8545", Network: "ropsten", ChainID: 3, Manifest: "ropsten.json"}
+	reserve := map[string]common.Address{"Reserve": common.HexToAddress("0x1")}
+
+	m := &manifest.Manifest{Network: "ropsten", ChainID: 3, Contracts: reserve}
+	assert.NoError(t, checkChain(c, m, big.NewInt(3)))
+	assert.Error(t, checkChain(c, m, big.NewInt(1)), "node on another chain")
+
+	m = &manifest.Manifest{Network: "mainnet", ChainID: 1, Contracts: reserve}
+	assert.Error(t, checkChain(c, m, big.NewInt(3)), "manifest from another chain")
+
+	m = &manifest.Manifest{Network: "kovan", ChainID: 3, Contracts: reserve}
+	assert.Error(t, checkChain(c, m, big.NewInt(3)), "manifest from another network")
+
+	m = &manifest.Manifest{Contracts: reserve}
+	assert.Error(t, checkChain(c, m, big.NewInt(3)), "unstamped manifest with contracts")
+
+	m = &manifest.Manifest{}
+	require.NoError(t, checkChain(c, m, big.NewInt(3)))
+	assert.Equal(t, uint64(3), m.ChainID)
+	assert.Equal(t, "ropsten", m.Network)
+}
@@ -0,0 +1,300 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// DefaultFireblocksURL is the Fireblocks API root.
+const DefaultFireblocksURL = "https://api.fireblocks.io"
+
+// FireblocksConfig configures a Signer whose key is held in Fireblocks MPC custody. Each
+// signature is a Fireblocks RAW signing transaction, so it goes through the workspace's
+// Transaction Authorization Policy and may wait for approval from other signers.
+type FireblocksConfig struct {
+	// APIKeyEnv names the environment variable holding the API user's key.
+	APIKeyEnv string `json:"apiKeyEnv"`
+
+	// SecretKey is the path of the API user's RSA private key, in PEM form.
+	SecretKey string `json:"secretKey"`
+
+	// VaultAccountID is the vault account whose key signs.
+	VaultAccountID string `json:"vaultAccountId"`
+
+	// AssetID selects the vault's key; it defaults to "ETH".
+	AssetID string `json:"assetId,omitempty"`
+
+	// Address is the vault's Ethereum address. Every signature is checked against it.
+	Address common.Address `json:"address"`
+
+	// URL overrides DefaultFireblocksURL.
+	URL string `json:"url,omitempty"`
+
+	// PollSeconds is how often to check on a pending signature; it defaults to 5.
+	PollSeconds int `json:"pollSeconds,omitempty"`
+
+	// TimeoutMinutes bounds the wait for approvals; it defaults to 60.
+	TimeoutMinutes int `json:"timeoutMinutes,omitempty"`
+}
+
+// Fireblocks is a Signer backed by the Fireblocks raw signing API.
+type Fireblocks struct {
+	config    FireblocksConfig
+	apiKey    string
+	secretKey *rsa.PrivateKey
+	http      *http.Client
+	poll      time.Duration
+}
+
+// NewFireblocks returns a Signer for c.
+func NewFireblocks(c FireblocksConfig) (*Fireblocks, error) {
+	if c.APIKeyEnv == "" || c.SecretKey == "" || c.VaultAccountID == "" {
+		return nil, errors.New("signer: fireblocks needs apiKeyEnv, secretKey, and vaultAccountId")
+	}
+	if c.Address == (common.Address{}) {
+		return nil, errors.New("signer: fireblocks.address is not set")
+	}
+	apiKey := os.Getenv(c.APIKeyEnv)
+	if apiKey == "" {
+		return nil, errors.Errorf("signer: environment variable %v is not set", c.APIKeyEnv)
+	}
+	pemBytes, err := ioutil.ReadFile(c.SecretKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "signer: reading Fireblocks secret key")
+	}
+	secretKey, err := parseRSAKey(pemBytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "signer: parsing %v", c.SecretKey)
+	}
+	if c.AssetID == "" {
+		c.AssetID = "ETH"
+	}
+	if c.URL == "" {
+		c.URL = DefaultFireblocksURL
+	}
+	if c.PollSeconds == 0 {
+		c.PollSeconds = 5
+	}
+	if c.TimeoutMinutes == 0 {
+		c.TimeoutMinutes = 60
+	}
+	return &Fireblocks{
+		config:    c,
+		apiKey:    apiKey,
+		secretKey: secretKey,
+		http:      &http.Client{Timeout: 30 * time.Second},
+		poll:      time.Duration(c.PollSeconds) * time.Second,
+	}, nil
+}
+
+func parseRSAKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// Address implements Signer.
+func (f *Fireblocks) Address() common.Address {
+	return f.config.Address
+}
+
+// Fireblocks transaction statuses that mean no signature is coming.
+var fireblocksFailed = map[string]bool{
+	"CANCELLED": true,
+	"REJECTED":  true,
+	"BLOCKED":   true,
+	"FAILED":    true,
+	"TIMEOUT":   true,
+}
+
+type fireblocksTx struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	SubStatus      string `json:"subStatus"`
+	SignedMessages []struct {
+		Content   string `json:"content"`
+		Signature struct {
+			R string `json:"r"`
+			S string `json:"s"`
+			V int    `json:"v"`
+		} `json:"signature"`
+	} `json:"signedMessages"`
+}
+
+// SignHash implements Signer. It blocks until the signing request is approved and signed, or
+// fails, or ctx is done.
+func (f *Fireblocks) SignHash(ctx context.Context, hash common.Hash) ([]byte, error) {
+	request := map[string]interface{}{
+		"operation": "RAW",
+		"assetId":   f.config.AssetID,
+		"source":    map[string]string{"type": "VAULT_ACCOUNT", "id": f.config.VaultAccountID},
+		"note":      "RSV operator signature for " + hash.Hex(),
+		"extraParameters": map[string]interface{}{
+			"rawMessageData": map[string]interface{}{
+				"messages": []map[string]string{{"content": hex.EncodeToString(hash.Bytes())}},
+			},
+		},
+	}
+	var created fireblocksTx
+	if err := f.do(ctx, http.MethodPost, "/v1/transactions", request, &created); err != nil {
+		return nil, errors.Wrap(err, "creating Fireblocks signing request")
+	}
+	log.Printf("Fireblocks signing request %v created; waiting for approval", created.ID)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(f.config.TimeoutMinutes)*time.Minute)
+	defer cancel()
+	status := created.Status
+	for {
+		var tx fireblocksTx
+		if err := f.do(ctx, http.MethodGet, "/v1/transactions/"+created.ID, nil, &tx); err != nil {
+			return nil, errors.Wrapf(err, "checking Fireblocks signing request %v", created.ID)
+		}
+		if tx.Status != status {
+			log.Printf("Fireblocks signing request %v: %v", tx.ID, tx.Status)
+			status = tx.Status
+		}
+		if fireblocksFailed[tx.Status] {
+			return nil, errors.Errorf("Fireblocks signing request %v %v (%v)", tx.ID, strings.ToLower(tx.Status), tx.SubStatus)
+		}
+		if tx.Status == "COMPLETED" {
+			return f.signature(hash, &tx)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrapf(ctx.Err(), "waiting for Fireblocks signing request %v", created.ID)
+		case <-time.After(f.poll):
+		}
+	}
+}
+
+// signature extracts the signature over hash from a completed transaction, puts it in canonical
+// (low-S) form, and checks that it is from the configured address.
+func (f *Fireblocks) signature(hash common.Hash, tx *fireblocksTx) ([]byte, error) {
+	if len(tx.SignedMessages) != 1 {
+		return nil, errors.Errorf("Fireblocks returned %v signatures, expected 1", len(tx.SignedMessages))
+	}
+	m := tx.SignedMessages[0]
+	r, errR := hex.DecodeString(m.Signature.R)
+	s, errS := hex.DecodeString(m.Signature.S)
+	if errR != nil || errS != nil || len(r) > 32 || len(s) > 32 || m.Signature.V > 1 || m.Signature.V < 0 {
+		return nil, errors.New("Fireblocks returned a malformed signature")
+	}
+	n := ethcrypto.S256().Params().N
+	sInt, v := new(big.Int).SetBytes(s), byte(m.Signature.V)
+	if sInt.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		sInt.Sub(n, sInt)
+		v ^= 1
+	}
+	sig := append(append(common.LeftPadBytes(r, 32), common.LeftPadBytes(sInt.Bytes(), 32)...), v)
+
+	pub, err := ethcrypto.SigToPub(hash.Bytes(), sig)
+	if err != nil {
+		return nil, errors.Wrap(err, "recovering Fireblocks signer")
+	}
+	if from := ethcrypto.PubkeyToAddress(*pub); from != f.config.Address {
+		return nil, errors.Errorf("Fireblocks signature is from %v, expected %v", from.Hex(), f.config.Address.Hex())
+	}
+	return sig, nil
+}
+
+// do makes an authenticated API call, decoding the JSON response into result.
+func (f *Fireblocks) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	token, err := f.token(path, payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, f.config.URL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-API-Key", f.apiKey)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := f.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("HTTP %v: %v", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	return json.Unmarshal(b, result)
+}
+
+// token builds the short-lived JWT that authenticates one API call. Fireblocks binds it to the
+// request path and a hash of the body.
+func (f *Fireblocks) token(path string, body []byte) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	bodyHash := sha256.Sum256(body)
+	now := time.Now().Unix()
+	claims, err := json.Marshal(map[string]interface{}{
+		"uri":      path,
+		"nonce":    hex.EncodeToString(nonce),
+		"iat":      now,
+		"exp":      now + 25,
+		"sub":      f.apiKey,
+		"bodyHash": hex.EncodeToString(bodyHash[:]),
+	})
+	if err != nil {
+		return "", err
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+	unsigned := encode([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, f.secretKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", errors.Wrap(err, "signing Fireblocks API token")
+	}
+	return fmt.Sprintf("%v.%v", unsigned, encode(sig)), nil
+}
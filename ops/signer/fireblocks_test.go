@@ -0,0 +1,157 @@
+package signer
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFireblocks serves the two API calls that the Fireblocks signer makes, checking their
+// authentication tokens, and signs with vault after one round of pending approval.
+type fakeFireblocks struct {
+	t       *testing.T
+	apiUser *rsa.PublicKey
+	vault   *Key
+	content string
+	polls   int
+	reject  bool
+}
+
+func (f *fakeFireblocks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	require.NoError(f.t, err)
+	f.checkToken(r, body)
+
+	if r.Method == http.MethodPost {
+		var req struct {
+			Operation       string
+			ExtraParameters struct {
+				RawMessageData struct {
+					Messages []struct{ Content string }
+				}
+			}
+		}
+		require.NoError(f.t, json.Unmarshal(body, &req))
+		assert.Equal(f.t, "RAW", req.Operation)
+		f.content = req.ExtraParameters.RawMessageData.Messages[0].Content
+		fmt.Fprint(w, `{"id": "tx-1", "status": "SUBMITTED"}`)
+		return
+	}
+
+	assert.Equal(f.t, "/v1/transactions/tx-1", r.URL.Path)
+	f.polls++
+	switch {
+	case f.polls == 1:
+		fmt.Fprint(w, `{"id": "tx-1", "status": "PENDING_AUTHORIZATION"}`)
+	case f.reject:
+		fmt.Fprint(w, `{"id": "tx-1", "status": "REJECTED", "subStatus": "REJECTED_BY_USER"}`)
+	default:
+		hash, err := hex.DecodeString(f.content)
+		require.NoError(f.t, err)
+		sig, err := f.vault.SignHash(context.Background(), common.BytesToHash(hash))
+		require.NoError(f.t, err)
+
+		// Return the high-S twin of the signature, which the signer must normalize.
+		n := ethcrypto.S256().Params().N
+		highS := new(big.Int).Sub(n, new(big.Int).SetBytes(sig[32:64]))
+		fmt.Fprintf(w, `{"id": "tx-1", "status": "COMPLETED", "signedMessages": [{"content": %q,
+			"signature": {"r": "%x", "s": "%x", "v": %v}}]}`, f.content, sig[:32], highS.Bytes(), sig[64]^1)
+	}
+}
+
+func (f *fakeFireblocks) checkToken(r *http.Request, body []byte) {
+	parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+	require.Len(f.t, parts, 3)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(f.t, err)
+	require.NoError(f.t, rsa.VerifyPKCS1v15(f.apiUser, crypto.SHA256, digest[:], sig))
+
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(f.t, err)
+	var c struct {
+		URI      string
+		Sub      string
+		BodyHash string
+	}
+	require.NoError(f.t, json.Unmarshal(claims, &c))
+	bodyHash := sha256.Sum256(body)
+	assert.Equal(f.t, r.URL.Path, c.URI)
+	assert.Equal(f.t, "api-key", c.Sub)
+	assert.Equal(f.t, "api-key", r.Header.Get("X-API-Key"))
+	assert.Equal(f.t, hex.EncodeToString(bodyHash[:]), c.BodyHash)
+}
+
+func newTestFireblocks(t *testing.T, reject bool) (*Fireblocks, *Key, func()) {
+	apiUser, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	vaultKey, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	vault := NewKey(vaultKey)
+
+	fake := &fakeFireblocks{t: t, apiUser: &apiUser.PublicKey, vault: vault, reject: reject}
+	server := httptest.NewServer(fake)
+
+	keyFile, err := ioutil.TempFile("", "fireblocks")
+	require.NoError(t, err)
+	require.NoError(t, pem.Encode(keyFile, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(apiUser)}))
+	require.NoError(t, keyFile.Close())
+	os.Setenv("FIREBLOCKS_TEST_KEY", "api-key")
+
+	f, err := NewFireblocks(FireblocksConfig{
+		APIKeyEnv:      "FIREBLOCKS_TEST_KEY",
+		SecretKey:      keyFile.Name(),
+		VaultAccountID: "0",
+		Address:        vault.Address(),
+		URL:            server.URL,
+	})
+	require.NoError(t, err)
+	f.poll = 0
+	return f, vault, func() {
+		server.Close()
+		os.Remove(keyFile.Name())
+		os.Unsetenv("FIREBLOCKS_TEST_KEY")
+	}
+}
+
+func TestFireblocksSignHash(t *testing.T) {
+	f, vault, cleanup := newTestFireblocks(t, false)
+	defer cleanup()
+
+	hash := ethcrypto.Keccak256Hash([]byte("message"))
+	sig, err := f.SignHash(context.Background(), hash)
+	require.NoError(t, err)
+
+	want, err := vault.SignHash(context.Background(), hash)
+	require.NoError(t, err)
+	assert.Equal(t, want, sig, "signature should be normalized to low-S form")
+}
+
+func TestFireblocksRejected(t *testing.T) {
+	f, _, cleanup := newTestFireblocks(t, true)
+	defer cleanup()
+
+	_, err := f.SignHash(context.Background(), ethcrypto.Keccak256Hash([]byte("message")))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rejected")
+}
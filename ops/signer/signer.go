@@ -0,0 +1,147 @@
+// Package signer provides the signing backends that operator tools use to authorize
+// transactions.
+//
+// A Signer only ever signs 32-byte hashes; building and hashing transactions stays in the tools,
+// so every backend signs exactly the same bytes.
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Signer produces secp256k1 signatures on behalf of a single account.
+type Signer interface {
+	// Address is the account whose key this Signer uses.
+	Address() common.Address
+
+	// SignHash signs a 32-byte hash, returning a 65-byte [R || S || V] signature with V in {0, 1}.
+	SignHash(ctx context.Context, hash common.Hash) ([]byte, error)
+}
+
+// SignTx signs tx for chainID with s, using EIP-155 replay protection.
+func SignTx(ctx context.Context, s Signer, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	txSigner := types.NewEIP155Signer(chainID)
+	sig, err := s.SignHash(ctx, txSigner.Hash(tx))
+	if err != nil {
+		return nil, errors.Wrap(err, "signing transaction")
+	}
+	signed, err := tx.WithSignature(txSigner, sig)
+	if err != nil {
+		return nil, errors.Wrap(err, "attaching signature")
+	}
+	if !signed.Protected() || signed.ChainId().Cmp(chainID) != 0 {
+		return nil, errors.Errorf("signed transaction is for chain %v, expected %v", signed.ChainId(), chainID)
+	}
+	from, err := types.Sender(txSigner, signed)
+	if err != nil {
+		return nil, errors.Wrap(err, "recovering signer")
+	}
+	if from != s.Address() {
+		return nil, errors.Errorf("signature is from %v, expected %v", from.Hex(), s.Address().Hex())
+	}
+	return signed, nil
+}
+
+// Key is a Signer backed by a private key held in memory.
+type Key struct {
+	key *ecdsa.PrivateKey
+}
+
+// NewKey returns a Signer for key.
+func NewKey(key *ecdsa.PrivateKey) *Key {
+	return &Key{key: key}
+}
+
+// Address implements Signer.
+func (k *Key) Address() common.Address {
+	return crypto.PubkeyToAddress(k.key.PublicKey)
+}
+
+// SignHash implements Signer.
+func (k *Key) SignHash(_ context.Context, hash common.Hash) ([]byte, error) {
+	return crypto.Sign(hash.Bytes(), k.key)
+}
+
+// Config selects and configures a signing backend.
+type Config struct {
+	// Keystore is the path of an encrypted JSON key file.
+	Keystore string `json:"keystore,omitempty"`
+
+	// PassphraseEnv names the environment variable holding the keystore passphrase.
+	// If it is empty or unset, the passphrase is read from the terminal.
+	PassphraseEnv string `json:"passphraseEnv,omitempty"`
+
+	// KeyEnv names an environment variable holding a hex-encoded private key.
+	// This is meant for test networks; prefer a keystore everywhere else.
+	KeyEnv string `json:"keyEnv,omitempty"`
+
+	// Fireblocks signs with a key held in Fireblocks MPC custody.
+	Fireblocks *FireblocksConfig `json:"fireblocks,omitempty"`
+}
+
+// Open returns the Signer described by c, or nil if c doesn't describe one.
+func Open(c Config) (Signer, error) {
+	configured := 0
+	for _, set := range []bool{c.Keystore != "", c.KeyEnv != "", c.Fireblocks != nil} {
+		if set {
+			configured++
+		}
+	}
+	switch {
+	case configured > 1:
+		return nil, errors.New("signer: set only one of keystore, keyEnv, and fireblocks")
+	case c.Fireblocks != nil:
+		return NewFireblocks(*c.Fireblocks)
+	case c.Keystore != "":
+		return openKeystore(c.Keystore, c.PassphraseEnv)
+	case c.KeyEnv != "":
+		hexKey := os.Getenv(c.KeyEnv)
+		if hexKey == "" {
+			return nil, errors.Errorf("signer: environment variable %v is not set", c.KeyEnv)
+		}
+		key, err := crypto.HexToECDSA(strings.TrimPrefix(hexKey, "0x"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "signer: parsing key from %v", c.KeyEnv)
+		}
+		return NewKey(key), nil
+	}
+	return nil, nil
+}
+
+func openKeystore(path, passphraseEnv string) (Signer, error) {
+	keyJSON, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "signer: reading keystore")
+	}
+	passphrase := ""
+	if passphraseEnv != "" {
+		passphrase = os.Getenv(passphraseEnv)
+	}
+	if passphrase == "" {
+		fmt.Fprintf(os.Stderr, "Passphrase for %v: ", path)
+		b, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, errors.Wrap(err, "signer: reading passphrase")
+		}
+		passphrase = string(b)
+	}
+	key, err := keystore.DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, errors.Wrap(err, "signer: decrypting keystore")
+	}
+	return NewKey(key.PrivateKey), nil
+}
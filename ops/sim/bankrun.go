@@ -0,0 +1,263 @@
+package sim
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+
+	"github.com/pkg/errors"
+)
+
+// BankRunParams are what a redemption run is drawn from.
+//
+// A run starts when a basket token depegs. Holders panic: in each step, each holder redeems
+// with a chance of the panic then, which fades, grows with the share of the supply redeemed,
+// and drifts at random. RSV trades at par less a discount for the panic, and an arbitrageur
+// trades against the gap: they redeem RSV bought under its backing, net of the redemption fee,
+// and issue RSV against the basket when par, net of the issuance fee, is worth more than it.
+type BankRunParams struct {
+	// Steps is how many rounds of decisions a run lasts, and Holders how many holders the
+	// supply is spread across, at random.
+	Steps   int `json:"steps"`
+	Holders int `json:"holders"`
+
+	// Depeg is how far the first basket token's price falls, as a fraction, when the run
+	// starts, and Shock the panic that it starts with.
+	Depeg float64 `json:"depeg"`
+	Shock float64 `json:"shock"`
+
+	// Contagion is how much the panic grows by for all of the supply redeemed in a step,
+	// Calm the share of it that fades each step, and Noise the standard deviation of its
+	// random move each step.
+	Contagion float64 `json:"contagion"`
+	Calm      float64 `json:"calm"`
+	Noise     float64 `json:"noise"`
+
+	// RedeemShare is the share of their RSV that a panicking holder redeems.
+	RedeemShare float64 `json:"redeemShare"`
+
+	// Discount is how far under par RSV trades at full panic, as a fraction.
+	Discount float64 `json:"discount"`
+
+	// Arbitrage is the share of the supply that the arbitrageur trades in a step for each
+	// unit of gap in price, as a fraction of the backing, and Inventory the share of the
+	// supply that they start with, to redeem.
+	Arbitrage float64 `json:"arbitrage"`
+	Inventory float64 `json:"inventory"`
+
+	// RedemptionFee and IssuanceFee are the Manager's fees, in BPS.
+	RedemptionFee uint32 `json:"redemptionFee"`
+	IssuanceFee   uint32 `json:"issuanceFee"`
+}
+
+// Check checks that the parameters make sense.
+func (p BankRunParams) Check() error {
+	fraction := func(v float64) bool { return v >= 0 && v <= 1 }
+	switch {
+	case p.Steps < 1:
+		return errors.Errorf("steps must be at least 1, not %v", p.Steps)
+	case p.Holders < 1:
+		return errors.Errorf("holders must be at least 1, not %v", p.Holders)
+	case !fraction(p.Depeg) || p.Depeg == 1:
+		return errors.Errorf("depeg must be in [0, 1), not %v", p.Depeg)
+	case !fraction(p.Shock) || !fraction(p.Calm) || !fraction(p.RedeemShare) || !fraction(p.Discount) ||
+		!fraction(p.Inventory):
+		return errors.New("shock, calm, redeemShare, discount, and inventory must be in [0, 1]")
+	case p.Contagion < 0 || p.Noise < 0 || p.Arbitrage < 0:
+		return errors.New("contagion, noise, and arbitrage must not be negative")
+	case p.RedemptionFee > 1000 || p.IssuanceFee > 1000:
+		// As Manager.setRedemptionFee and setIssuanceFee require.
+		return errors.New("fees must be at most 1000 BPS")
+	}
+	return nil
+}
+
+// BankRun is one run in progress: its agents' decisions, drawn from its seed.
+type BankRun struct {
+	Params BankRunParams
+	Seed   int64
+
+	// Panic is the holders' panic in the next step.
+	Panic float64
+
+	rand *rand.Rand
+}
+
+// Start starts the run of seed, which is the same for the same parameters and seed.
+func (p BankRunParams) Start(seed int64) *BankRun {
+	return &BankRun{Params: p, Seed: seed, Panic: p.Shock, rand: rand.New(rand.NewSource(seed))}
+}
+
+// Holdings draws the shares of the supply that the holders start with, which sum to 1.
+func (r *BankRun) Holdings() []float64 {
+	shares := make([]float64, r.Params.Holders)
+	var sum float64
+	for i := range shares {
+		shares[i] = r.rand.ExpFloat64()
+		sum += shares[i]
+	}
+	for i := range shares {
+		shares[i] /= sum
+	}
+	return shares
+}
+
+// Market is the state of a run that its agents decide on, with amounts in RSV.
+type Market struct {
+	// Backing is what the Vault holds for each RSV, in dollars.
+	Backing float64
+
+	Supply      float64
+	Holders     []float64
+	Arbitrageur float64
+}
+
+// Step is the agents' decisions in one step of a run, with amounts in RSV.
+type Step struct {
+	// Panic is the holders' panic, and Price what RSV trades at, in dollars.
+	Panic float64
+	Price float64
+
+	// Redeem is what each holder redeems.
+	Redeem []float64
+
+	// Issue and ArbitrageRedeem are what the arbitrageur issues and redeems; at most one is
+	// more than zero.
+	Issue           float64
+	ArbitrageRedeem float64
+}
+
+// Redeemed is what the step redeems in all.
+func (s Step) Redeemed() float64 {
+	sum := s.ArbitrageRedeem
+	for _, v := range s.Redeem {
+		sum += v
+	}
+	return sum
+}
+
+// Step decides what each agent does in the market, and updates the panic for the next step by
+// how much of the supply they redeem.
+func (r *BankRun) Step(m Market) Step {
+	p := r.Params
+	s := Step{Panic: r.Panic, Price: 1 - p.Discount*r.Panic, Redeem: make([]float64, len(m.Holders))}
+	for i, balance := range m.Holders {
+		if balance > 0 && r.rand.Float64() < r.Panic {
+			s.Redeem[i] = balance * p.RedeemShare
+		}
+	}
+
+	// What redeeming a dollar's worth of RSV returns, and what issuing a dollar's worth of
+	// basket returns, at market prices.
+	redeemed := m.Backing * (1 - bps(p.RedemptionFee)) / s.Price
+	issued := s.Price * (1 - bps(p.IssuanceFee)) / m.Backing
+	switch {
+	case redeemed > 1:
+		s.ArbitrageRedeem = math.Min(m.Arbitrageur, p.Arbitrage*(redeemed-1)*m.Supply)
+	case issued > 1:
+		s.Issue = p.Arbitrage * (issued - 1) * m.Supply
+	}
+
+	share := 0.0
+	if m.Supply > 0 {
+		share = s.Redeemed() / m.Supply
+	}
+	next := r.Panic*(1-p.Calm) + p.Contagion*share + p.Noise*r.rand.NormFloat64()
+	r.Panic = math.Max(0, math.Min(1, next))
+	return s
+}
+
+func bps(v uint32) float64 {
+	return float64(v) / 10000
+}
+
+// Quantity is amount whole tokens in qTokens of a token of decimals, rounded down; the inverse
+// of Value.
+func Quantity(amount float64, decimals uint32) *big.Int {
+	f := new(big.Float).SetPrec(256).SetFloat64(amount)
+	f.Mul(f, new(big.Float).SetInt(pow10(decimals)))
+	i, _ := f.Int(nil)
+	return i
+}
+
+// BankRunOutcome is how one run came out on the contracts. Shares are of the supply the run
+// started with.
+type BankRunOutcome struct {
+	Seed int64 `json:"seed"`
+
+	// MinBacking and FinalBacking are the Vault's holdings at market prices over the supply at
+	// a dollar per RSV, at their least and at the end.
+	MinBacking   float64 `json:"minBacking"`
+	FinalBacking float64 `json:"finalBacking"`
+
+	Redeemed    float64 `json:"redeemed"`
+	Issued      float64 `json:"issued"`
+	FinalSupply float64 `json:"finalSupply"`
+	PeakPanic   float64 `json:"peakPanic"`
+
+	// Fees is what the fee recipients took, at market prices, in dollars over the supply.
+	Fees float64 `json:"fees"`
+}
+
+// BankRunReport is the distributions of a set of runs' outcomes.
+type BankRunReport struct {
+	Params       BankRunParams `json:"params"`
+	Runs         int           `json:"runs"`
+	MinBacking   Summary       `json:"minBacking"`
+	FinalBacking Summary       `json:"finalBacking"`
+	Redeemed     Summary       `json:"redeemed"`
+	Issued       Summary       `json:"issued"`
+	FinalSupply  Summary       `json:"finalSupply"`
+	PeakPanic    Summary       `json:"peakPanic"`
+	Fees         Summary       `json:"fees"`
+
+	// Worst is the outcome with the lowest backing ratio, to replay by its seed.
+	Worst BankRunOutcome `json:"worst"`
+}
+
+// NewBankRunReport summarizes outcomes of runs drawn from p.
+func NewBankRunReport(p BankRunParams, outcomes []BankRunOutcome) BankRunReport {
+	r := BankRunReport{Params: p, Runs: len(outcomes)}
+	var minBacking, finalBacking, redeemed, issued, finalSupply, peakPanic, fees []float64
+	for i, o := range outcomes {
+		minBacking = append(minBacking, o.MinBacking)
+		finalBacking = append(finalBacking, o.FinalBacking)
+		redeemed = append(redeemed, o.Redeemed)
+		issued = append(issued, o.Issued)
+		finalSupply = append(finalSupply, o.FinalSupply)
+		peakPanic = append(peakPanic, o.PeakPanic)
+		fees = append(fees, o.Fees)
+		if i == 0 || o.MinBacking < r.Worst.MinBacking {
+			r.Worst = o
+		}
+	}
+	r.MinBacking = Summarize(minBacking)
+	r.FinalBacking = Summarize(finalBacking)
+	r.Redeemed = Summarize(redeemed)
+	r.Issued = Summarize(issued)
+	r.FinalSupply = Summarize(finalSupply)
+	r.PeakPanic = Summarize(peakPanic)
+	r.Fees = Summarize(fees)
+	return r
+}
+
+// Format returns the report as a table of each distribution, with shares as percentages.
+func (r BankRunReport) Format() string {
+	var b bytes.Buffer
+	p := r.Params
+	fmt.Fprintf(&b, "%v runs of %v steps across %v holders, a %v depeg, fees of %v and %v BPS\n",
+		r.Runs, p.Steps, p.Holders, p.Depeg, p.RedemptionFee, p.IssuanceFee)
+	writeTable(&b, []row{
+		{"min backing", r.MinBacking, 100, "%"},
+		{"final backing", r.FinalBacking, 100, "%"},
+		{"redeemed", r.Redeemed, 100, "%"},
+		{"issued", r.Issued, 100, "%"},
+		{"final supply", r.FinalSupply, 100, "%"},
+		{"peak panic", r.PeakPanic, 100, "%"},
+		{"fees", r.Fees, 100, "%"},
+	})
+	fmt.Fprintf(&b, "The lowest backing ratio, %.3f%%, is of seed %v.\n", r.Worst.MinBacking*100, r.Worst.Seed)
+	return b.String()
+}
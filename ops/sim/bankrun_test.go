@@ -0,0 +1,117 @@
+package sim
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var bankRun = BankRunParams{
+	Steps:         20,
+	Holders:       10,
+	Depeg:         0.1,
+	Shock:         0.3,
+	Contagion:     2,
+	Calm:          0.2,
+	Noise:         0.05,
+	RedeemShare:   0.5,
+	Discount:      0.2,
+	Arbitrage:     1,
+	Inventory:     0.1,
+	RedemptionFee: 10,
+	IssuanceFee:   10,
+}
+
+func TestBankRunCheck(t *testing.T) {
+	assert.NoError(t, bankRun.Check())
+	for _, change := range []func(*BankRunParams){
+		func(p *BankRunParams) { p.Steps = 0 },
+		func(p *BankRunParams) { p.Holders = 0 },
+		func(p *BankRunParams) { p.Depeg = 1 },
+		func(p *BankRunParams) { p.Shock = 1.5 },
+		func(p *BankRunParams) { p.Contagion = -1 },
+		func(p *BankRunParams) { p.RedemptionFee = 1001 },
+	} {
+		p := bankRun
+		change(&p)
+		assert.Error(t, p.Check(), "%+v", p)
+	}
+}
+
+func TestHoldings(t *testing.T) {
+	shares := bankRun.Start(1).Holdings()
+	require.Len(t, shares, 10)
+	var sum float64
+	for _, s := range shares {
+		assert.True(t, s > 0)
+		sum += s
+	}
+	assert.InDelta(t, 1, sum, 1e-12)
+	assert.Equal(t, shares, bankRun.Start(1).Holdings(), "the same seed draws the same holdings")
+}
+
+func TestBankRunStep(t *testing.T) {
+	market := Market{Backing: 1, Supply: 100, Holders: []float64{50, 50}, Arbitrageur: 10}
+
+	// Without panic, no holder redeems, and the arbitrageur has no gap to trade.
+	p := bankRun
+	p.Shock, p.Noise = 0, 0
+	s := p.Start(1).Step(market)
+	assert.Equal(t, Step{Panic: 0, Price: 1, Redeem: []float64{0, 0}}, s)
+
+	// In full panic, every holder redeems, and RSV trades far enough under its backing that the
+	// arbitrageur redeems all they have. The panic stays full.
+	p.Shock = 1
+	r := p.Start(1)
+	s = r.Step(market)
+	assert.Equal(t, 0.8, s.Price)
+	assert.Equal(t, []float64{25, 25}, s.Redeem)
+	assert.Equal(t, 10.0, s.ArbitrageRedeem)
+	assert.Equal(t, 0.0, s.Issue)
+	assert.Equal(t, 60.0, s.Redeemed())
+	assert.Equal(t, 1.0, r.Panic)
+
+	// With the basket depegged and the panic gone, RSV trades at par, over its backing, so the
+	// arbitrageur issues against the basket.
+	p.Shock = 0
+	market.Backing = 0.9
+	r = p.Start(1)
+	s = r.Step(market)
+	assert.Equal(t, 0.0, s.ArbitrageRedeem)
+	assert.InDelta(t, (1*(1-0.001)/0.9-1)*100, s.Issue, 1e-9)
+
+	// Redemptions feed the panic, which fades otherwise.
+	p.Shock, p.Calm, p.Contagion = 0.5, 0.5, 1
+	market.Backing = 1
+	r = p.Start(3)
+	s = r.Step(market)
+	assert.InDelta(t, 0.25+s.Redeemed()/100, r.Panic, 1e-12)
+}
+
+func TestQuantity(t *testing.T) {
+	assert.Equal(t, "2500000", Quantity(2.5, 6).String())
+	assert.Equal(t, "1000000000000000000000000", Quantity(1e6, 18).String())
+	assert.Equal(t, "0", Quantity(0, 18).String())
+	v := Value(Quantity(math.Pi, 18), 18, 1)
+	assert.InDelta(t, math.Pi, v, 1e-15)
+}
+
+func TestBankRunReport(t *testing.T) {
+	r := NewBankRunReport(bankRun, []BankRunOutcome{
+		{Seed: 1, MinBacking: 0.9, FinalBacking: 0.9, Redeemed: 0.5, FinalSupply: 0.5, PeakPanic: 0.8, Fees: 0.001},
+		{Seed: 2, MinBacking: 0.89, FinalBacking: 0.9, Redeemed: 0.2, Issued: 0.1, FinalSupply: 0.9, PeakPanic: 0.4},
+	})
+	assert.Equal(t, 2, r.Runs)
+	assert.Equal(t, int64(2), r.Worst.Seed)
+	assert.Equal(t, 0.35, r.Redeemed.Mean)
+
+	lines := strings.Split(r.Format(), "\n")
+	require.Len(t, lines, 11)
+	assert.Equal(t, "2 runs of 20 steps across 10 holders, a 0.1 depeg, fees of 10 and 10 BPS", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "                   mean"), lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "min backing     89.500%   89.000%"), lines[2])
+	assert.Equal(t, "The lowest backing ratio, 89.000%, is of seed 2.", lines[9])
+}
@@ -0,0 +1,281 @@
+// Package sim draws randomized scenarios for the tests in tests/ to play against the contracts,
+// and summarizes how they came out: basket migrations, for choosing a migration's parameters
+// before running it for real, and redemption runs, for seeing how the backing and the fees hold
+// up under one; see BankRunParams.
+//
+// A migration moves the basket's weight out of one token, `from`, and into another, `to`, in
+// tranches, each a RebalanceProposal of a portion of what is left of `from`. For each tranche, the
+// proposer quotes a rate: the market price of `from` in `to` when they propose, less a markup.
+// The price then moves while the proposal waits out the Manager's delay. A rational proposer
+// withdraws a proposal that the move has made a loss for them, and any proposer may walk away, so
+// some tranches go unfilled. The tests in tests/ play each Scenario against the contracts on the
+// simulated backend, and value what the Vault ends up holding at market prices; Summarize and
+// Report make distributions of the outcomes.
+package sim
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Params are what a migration's scenarios are drawn from.
+type Params struct {
+	// Tranches is how many proposals the migration is split into.
+	Tranches int `json:"tranches"`
+
+	// Volatility is the standard deviation of the log of the price's move over each tranche's
+	// delay: 0.01 is about a 1% move.
+	Volatility float64 `json:"volatility"`
+
+	// MaxMarkup is the most that a proposer quotes under the market price, as a fraction: each
+	// tranche's markup is uniform in [0, MaxMarkup].
+	MaxMarkup float64 `json:"maxMarkup"`
+
+	// WalkAway is the chance that a proposer withdraws a proposal whatever the price does.
+	WalkAway float64 `json:"walkAway"`
+}
+
+// Check checks that the parameters make sense.
+func (p Params) Check() error {
+	switch {
+	case p.Tranches < 1 || p.Tranches > 10000:
+		return errors.Errorf("tranches must be from 1 to 10000, not %v", p.Tranches)
+	case p.Volatility < 0:
+		return errors.Errorf("volatility must not be negative, not %v", p.Volatility)
+	case p.MaxMarkup < 0 || p.MaxMarkup >= 1:
+		return errors.Errorf("maxMarkup must be in [0, 1), not %v", p.MaxMarkup)
+	case p.WalkAway < 0 || p.WalkAway > 1:
+		return errors.Errorf("walkAway must be in [0, 1], not %v", p.WalkAway)
+	}
+	return nil
+}
+
+// Tranche is one proposal of a migration.
+type Tranche struct {
+	// Portion is the portion of what is left of `from` that the tranche moves, in BPS, so that
+	// all of it has moved once every tranche fills.
+	Portion uint32 `json:"portion"`
+
+	// Markup is how far under the market price the proposer quotes, as a fraction.
+	Markup float64 `json:"markup"`
+
+	// Move is the log of the price's move while the proposal waits out the delay.
+	Move float64 `json:"move"`
+
+	// WalksAway is whether the proposer withdraws the proposal however the price moves.
+	WalksAway bool `json:"walksAway"`
+}
+
+// Quote is the rate, in `to` per `from`, that the proposer quotes at the market price.
+func (t Tranche) Quote(price float64) float64 {
+	return price * (1 - t.Markup)
+}
+
+// Moved is the market price once the proposal has waited out its delay.
+func (t Tranche) Moved(price float64) float64 {
+	return price * math.Exp(t.Move)
+}
+
+// Fills reports whether the proposer lets the proposal, quoted at quote, be executed at the
+// market price then: not if they walk away, nor if what they would get is worth less than what
+// they would pay.
+func (t Tranche) Fills(quote, price float64) bool {
+	return !t.WalksAway && price >= quote
+}
+
+// Scenario is one randomized migration, whose price starts at 1.
+type Scenario struct {
+	Seed     int64     `json:"seed"`
+	Tranches []Tranche `json:"tranches"`
+}
+
+// Draw draws the scenario of seed, which is the same for the same parameters and seed.
+func (p Params) Draw(seed int64) Scenario {
+	r := rand.New(rand.NewSource(seed))
+	s := Scenario{Seed: seed}
+	for i := 0; i < p.Tranches; i++ {
+		s.Tranches = append(s.Tranches, Tranche{
+			Portion:   uint32(10000 / (p.Tranches - i)),
+			Markup:    r.Float64() * p.MaxMarkup,
+			Move:      r.NormFloat64() * p.Volatility,
+			WalksAway: r.Float64() < p.WalkAway,
+		})
+	}
+	return s
+}
+
+// Rate converts a rate in whole `to` per whole `from` into a RebalanceProposal's rate: aqTo per
+// aqFrom, scaled by 1e18, rounded down.
+func Rate(rate float64, fromDecimals, toDecimals uint32) *big.Int {
+	scaled := new(big.Float).SetPrec(256).SetFloat64(rate)
+	scaled.Mul(scaled, new(big.Float).SetInt(pow10(18+toDecimals)))
+	scaled.Quo(scaled, new(big.Float).SetInt(pow10(fromDecimals)))
+	i, _ := scaled.Int(nil)
+	return i
+}
+
+// Value is amount qTokens of a token of decimals, at price dollars per whole token, in dollars.
+func Value(amount *big.Int, decimals uint32, price float64) float64 {
+	f := new(big.Float).SetPrec(256).SetInt(amount)
+	f.Quo(f, new(big.Float).SetInt(pow10(decimals)))
+	v, _ := f.Float64()
+	return v * price
+}
+
+func pow10(n uint32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// Outcome is how one scenario came out on the contracts.
+type Outcome struct {
+	Seed int64 `json:"seed"`
+
+	// BackingRatio is the Vault's holdings at the final market prices, over the supply at a
+	// dollar per RSV; `to` is a dollar.
+	BackingRatio float64 `json:"backingRatio"`
+
+	// Slippage is what the Vault gave up to the proposers, as a fraction of the value of what
+	// it sent them, at the market price of each execution.
+	Slippage float64 `json:"slippage"`
+
+	// Filled is how many of the tranches were executed, and Migrated the portion of the weight
+	// of `from` that moved.
+	Filled   int     `json:"filled"`
+	Migrated float64 `json:"migrated"`
+}
+
+// Summary is the distribution of a sample.
+type Summary struct {
+	N      int     `json:"n"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stdDev"`
+	Min    float64 `json:"min"`
+	P5     float64 `json:"p5"`
+	P25    float64 `json:"p25"`
+	P50    float64 `json:"p50"`
+	P75    float64 `json:"p75"`
+	P95    float64 `json:"p95"`
+	Max    float64 `json:"max"`
+}
+
+// Summarize summarizes the distribution of values, with percentiles by nearest rank.
+func Summarize(values []float64) Summary {
+	if len(values) == 0 {
+		return Summary{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	mean := sum / float64(len(sorted))
+	var squares float64
+	for _, v := range sorted {
+		squares += (v - mean) * (v - mean)
+	}
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i]
+	}
+	return Summary{
+		N:      len(sorted),
+		Mean:   mean,
+		StdDev: math.Sqrt(squares / float64(len(sorted))),
+		Min:    sorted[0],
+		P5:     rank(0.05),
+		P25:    rank(0.25),
+		P50:    rank(0.5),
+		P75:    rank(0.75),
+		P95:    rank(0.95),
+		Max:    sorted[len(sorted)-1],
+	}
+}
+
+// Report is the distributions of a set of outcomes.
+type Report struct {
+	Params       Params  `json:"params"`
+	Runs         int     `json:"runs"`
+	BackingRatio Summary `json:"backingRatio"`
+	Slippage     Summary `json:"slippage"`
+	Filled       Summary `json:"filled"`
+	Migrated     Summary `json:"migrated"`
+
+	// Worst is the outcome with the lowest backing ratio, to replay by its seed.
+	Worst Outcome `json:"worst"`
+}
+
+// NewReport summarizes outcomes of scenarios drawn from p.
+func NewReport(p Params, outcomes []Outcome) Report {
+	r := Report{Params: p, Runs: len(outcomes)}
+	var backing, slippage, filled, migrated []float64
+	for i, o := range outcomes {
+		backing = append(backing, o.BackingRatio)
+		slippage = append(slippage, o.Slippage)
+		filled = append(filled, float64(o.Filled))
+		migrated = append(migrated, o.Migrated)
+		if i == 0 || o.BackingRatio < r.Worst.BackingRatio {
+			r.Worst = o
+		}
+	}
+	r.BackingRatio = Summarize(backing)
+	r.Slippage = Summarize(slippage)
+	r.Filled = Summarize(filled)
+	r.Migrated = Summarize(migrated)
+	return r
+}
+
+// Format returns the report as a table of each distribution, with ratios as percentages.
+func (r Report) Format() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%v migrations of %v tranches, volatility %v, markups up to %v, walking away %v\n",
+		r.Runs, r.Params.Tranches, r.Params.Volatility, r.Params.MaxMarkup, r.Params.WalkAway)
+	writeTable(&b, []row{
+		{"backing ratio", r.BackingRatio, 100, "%"},
+		{"slippage", r.Slippage, 100, "%"},
+		{"filled", r.Filled, 1, ""},
+		{"migrated", r.Migrated, 100, "%"},
+	})
+	fmt.Fprintf(&b, "The lowest backing ratio, %.3f%%, is of seed %v.\n", r.Worst.BackingRatio*100, r.Worst.Seed)
+	return b.String()
+}
+
+// row is a row of a report's table: a distribution, scaled and with its unit.
+type row struct {
+	name    string
+	summary Summary
+	scale   float64
+	unit    string
+}
+
+// writeTable writes rows to b as a table of their means and percentiles.
+func writeTable(b *bytes.Buffer, rows []row) {
+	width := 0
+	for _, r := range rows {
+		if len(r.name) > width {
+			width = len(r.name)
+		}
+	}
+	fmt.Fprintf(b, "%-*v", width, "")
+	for _, column := range []string{"mean", "min", "p5", "p25", "p50", "p75", "p95", "max"} {
+		fmt.Fprintf(b, "  %8v", column)
+	}
+	fmt.Fprintln(b)
+	for _, r := range rows {
+		s := r.summary
+		fmt.Fprintf(b, "%-*v", width, r.name)
+		for _, v := range []float64{s.Mean, s.Min, s.P5, s.P25, s.P50, s.P75, s.P95, s.Max} {
+			fmt.Fprintf(b, "  %8v", fmt.Sprintf("%.3f", v*r.scale)+r.unit)
+		}
+		fmt.Fprintln(b)
+	}
+}
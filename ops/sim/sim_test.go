@@ -0,0 +1,107 @@
+package sim
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	good := Params{Tranches: 4, Volatility: 0.01, MaxMarkup: 0.003, WalkAway: 0.05}
+	assert.NoError(t, good.Check())
+	for _, bad := range []Params{
+		{Tranches: 0},
+		{Tranches: 4, Volatility: -1},
+		{Tranches: 4, MaxMarkup: 1},
+		{Tranches: 4, WalkAway: 1.5},
+	} {
+		assert.Error(t, bad.Check(), "%+v", bad)
+	}
+}
+
+func TestDraw(t *testing.T) {
+	p := Params{Tranches: 4, Volatility: 0.01, MaxMarkup: 0.003, WalkAway: 0.5}
+	s := p.Draw(7)
+	assert.Equal(t, s, p.Draw(7), "the same seed draws the same scenario")
+	assert.NotEqual(t, s, p.Draw(8))
+	require.Len(t, s.Tranches, 4)
+
+	// Each tranche moves what's left, so all of `from` moves once each fills.
+	left := 1.0
+	for i, tr := range s.Tranches {
+		assert.Equal(t, []uint32{2500, 3333, 5000, 10000}[i], tr.Portion)
+		assert.True(t, tr.Markup >= 0 && tr.Markup <= p.MaxMarkup)
+		left *= 1 - float64(tr.Portion)/10000
+	}
+	assert.Equal(t, 0.0, left)
+
+	// Without volatility, markups, or walking away, every tranche fills at the price.
+	for _, tr := range (Params{Tranches: 3}).Draw(1).Tranches {
+		assert.Equal(t, 1.0, tr.Moved(1))
+		assert.True(t, tr.Fills(tr.Quote(1), tr.Moved(1)))
+	}
+}
+
+func TestFills(t *testing.T) {
+	tr := Tranche{Markup: 0.01, Move: math.Log(0.995)}
+	quote := tr.Quote(2)
+	assert.InDelta(t, 1.98, quote, 1e-12)
+	assert.InDelta(t, 1.99, tr.Moved(2), 1e-12)
+	assert.True(t, tr.Fills(quote, tr.Moved(2)), "the proposer still profits")
+	assert.False(t, tr.Fills(quote, 1.97), "the proposer would lose")
+
+	tr.WalksAway = true
+	assert.False(t, tr.Fills(quote, tr.Moved(2)))
+}
+
+func TestRate(t *testing.T) {
+	assert.Equal(t, "1000000000000000000", Rate(1, 18, 18).String())
+	// A rate of 1 from a 6-decimal token to an 18-decimal one is 1e12 aqTo per aqFrom.
+	assert.Equal(t, "1000000000000000000000000000000", Rate(1, 6, 18).String())
+	assert.Equal(t, "500000", Rate(0.5, 18, 6).String())
+
+	assert.InDelta(t, 2.5, Value(big.NewInt(2500000), 6, 1), 1e-12)
+	assert.InDelta(t, 5, Value(new(big.Int).Mul(big.NewInt(25), pow10(17)), 18, 2), 1e-12)
+}
+
+func TestSummarize(t *testing.T) {
+	assert.Equal(t, Summary{}, Summarize(nil))
+
+	var values []float64
+	for i := 100; i > 0; i-- {
+		values = append(values, float64(i))
+	}
+	s := Summarize(values)
+	assert.Equal(t, 100, s.N)
+	assert.Equal(t, 50.5, s.Mean)
+	assert.InDelta(t, 28.866, s.StdDev, 1e-3)
+	assert.Equal(t, []float64{1, 5, 25, 50, 75, 95, 100},
+		[]float64{s.Min, s.P5, s.P25, s.P50, s.P75, s.P95, s.Max})
+	assert.Equal(t, 100.0, values[0], "the values are left unsorted")
+
+	one := Summarize([]float64{3})
+	assert.Equal(t, Summary{N: 1, Mean: 3, Min: 3, P5: 3, P25: 3, P50: 3, P75: 3, P95: 3, Max: 3}, one)
+}
+
+func TestReport(t *testing.T) {
+	p := Params{Tranches: 2, Volatility: 0.01, MaxMarkup: 0.003}
+	r := NewReport(p, []Outcome{
+		{Seed: 1, BackingRatio: 1.001, Slippage: 0.001, Filled: 2, Migrated: 1},
+		{Seed: 2, BackingRatio: 0.98, Slippage: 0.002, Filled: 1, Migrated: 0.5},
+		{Seed: 3, BackingRatio: 0.99, Slippage: 0, Filled: 0, Migrated: 0},
+	})
+	assert.Equal(t, 3, r.Runs)
+	assert.Equal(t, int64(2), r.Worst.Seed)
+	assert.Equal(t, 0.98, r.BackingRatio.Min)
+	assert.Equal(t, 1.0, r.Filled.Mean)
+
+	lines := strings.Split(r.Format(), "\n")
+	require.Len(t, lines, 8)
+	assert.Equal(t, "3 migrations of 2 tranches, volatility 0.01, markups up to 0.003, walking away 0", lines[0])
+	assert.True(t, strings.HasPrefix(lines[2], "backing ratio   99.033%   98.000%"), lines[2])
+	assert.Equal(t, "The lowest backing ratio, 98.000%, is of seed 2.", lines[6])
+}
@@ -0,0 +1,179 @@
+// Package sizes reads, writes, and compares the size report: each deployed contract's code size
+// and what deploying it costs, committed so that a change's cost against the EIP-170 limit on
+// deployed code shows in its diff, and so that the file's git history shows what each change
+// cost.
+package sizes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// MaxCodeSize is the most deployed code that a contract may have, per EIP-170, and MaxInitCodeSize
+// the most init code that may deploy it, per EIP-3860.
+const (
+	MaxCodeSize     = 24576
+	MaxInitCodeSize = 2 * MaxCodeSize
+)
+
+// DeploymentBenchmark is the name of the gas benchmark, in the gas report (see ops/gas), of
+// deploying contract.
+func DeploymentBenchmark(contract string) string {
+	return contract + " deployment"
+}
+
+// Size is a contract's code size, in bytes, and the gas that deploying it used in the gas
+// benchmarks, or 0 if they don't deploy it.
+type Size struct {
+	Runtime   int    `json:"runtime"`
+	Init      int    `json:"init"`
+	DeployGas uint64 `json:"deployGas,omitempty"`
+}
+
+// Headroom is how many more bytes the contract's deployed code may grow by.
+func (s Size) Headroom() int {
+	return MaxCodeSize - s.Runtime
+}
+
+// Report maps each contract to its size.
+type Report map[string]Size
+
+// Parse parses a report written by Marshal.
+func Parse(b []byte) (Report, error) {
+	var r Report
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, errors.Wrap(err, "parsing the size report")
+	}
+	if r == nil {
+		return nil, errors.New("the size report is empty")
+	}
+	return r, nil
+}
+
+// Marshal returns the report as indented JSON, sorted by contract, so that the committed file
+// diffs well.
+func (r Report) Marshal() ([]byte, error) {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling the size report")
+	}
+	return append(b, '\n'), nil
+}
+
+// ReadFile reads a report written by WriteFile.
+func ReadFile(path string) (Report, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the size report")
+	}
+	r, err := Parse(b)
+	return r, errors.Wrap(err, path)
+}
+
+// WriteFile writes the report to path.
+func WriteFile(path string, r Report) error {
+	b, err := r.Marshal()
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(ioutil.WriteFile(path, b, 0644), "writing the size report")
+}
+
+// Contracts returns the report's contracts, largest deployed code first.
+func (r Report) Contracts() []string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := r[names[i]], r[names[j]]
+		if a.Runtime != b.Runtime {
+			return a.Runtime > b.Runtime
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// Over returns the contracts whose code is over the EIP-170 or EIP-3860 limit.
+func (r Report) Over() []string {
+	var over []string
+	for _, name := range r.Contracts() {
+		if r[name].Runtime > MaxCodeSize || r[name].Init > MaxInitCodeSize {
+			over = append(over, name)
+		}
+	}
+	return over
+}
+
+// Format returns the report as a table, largest deployed code first, with how much of the
+// EIP-170 limit each contract uses.
+func (r Report) Format() string {
+	width := len("contract")
+	for name := range r {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%-*v  %8v  %8v  %8v  %9v  %10v\n",
+		width, "contract", "deployed", "of limit", "headroom", "init code", "deploy gas")
+	for _, name := range r.Contracts() {
+		s := r[name]
+		gas := "-"
+		if s.DeployGas != 0 {
+			gas = fmt.Sprint(s.DeployGas)
+		}
+		fmt.Fprintf(&b, "%-*v  %8v  %7.1f%%  %8v  %9v  %10v\n",
+			width, name, s.Runtime, float64(s.Runtime)*100/MaxCodeSize, s.Headroom(), s.Init, gas)
+	}
+	return b.String()
+}
+
+// Delta is how one contract's size changed. A contract that is in only one of the two reports
+// has a zero Size in the other.
+type Delta struct {
+	Contract string `json:"contract"`
+	Old      Size   `json:"old"`
+	New      Size   `json:"new"`
+}
+
+func (d Delta) String() string {
+	switch {
+	case d.Old == Size{}:
+		return fmt.Sprintf("%v: added, %v bytes deployed, %v of init code", d.Contract, d.New.Runtime, d.New.Init)
+	case d.New == Size{}:
+		return fmt.Sprintf("%v: removed, was %v bytes deployed", d.Contract, d.Old.Runtime)
+	}
+	s := fmt.Sprintf("%v: deployed %v -> %v (%+d), init code %v -> %v (%+d)", d.Contract,
+		d.Old.Runtime, d.New.Runtime, d.New.Runtime-d.Old.Runtime,
+		d.Old.Init, d.New.Init, d.New.Init-d.Old.Init)
+	if d.Old.DeployGas != 0 && d.New.DeployGas != 0 {
+		s += fmt.Sprintf(", deploy gas %v -> %v (%+d)",
+			d.Old.DeployGas, d.New.DeployGas, int64(d.New.DeployGas)-int64(d.Old.DeployGas))
+	}
+	return s + fmt.Sprintf(", %v bytes of headroom", d.New.Headroom())
+}
+
+// Diff lists the contracts whose sizes differ between old and new, those in only one of the two
+// included, sorted by contract.
+func Diff(old, new Report) []Delta {
+	var deltas []Delta
+	for name, size := range old {
+		if new[name] != size {
+			deltas = append(deltas, Delta{Contract: name, Old: size, New: new[name]})
+		}
+	}
+	for name, size := range new {
+		if _, ok := old[name]; !ok {
+			deltas = append(deltas, Delta{Contract: name, New: size})
+		}
+	}
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].Contract < deltas[j].Contract })
+	return deltas
+}
@@ -0,0 +1,86 @@
+package sizes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sizes")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sizes.json")
+
+	r := Report{
+		"Reserve": {Runtime: 23000, Init: 24500, DeployGas: 5100000},
+		"Vault":   {Runtime: 4000, Init: 4300},
+	}
+	require.NoError(t, WriteFile(path, r))
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "Reserve": {
+    "runtime": 23000,
+    "init": 24500,
+    "deployGas": 5100000
+  },
+  "Vault": {
+    "runtime": 4000,
+    "init": 4300
+  }
+}
+`, string(b))
+	read, err := ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, r, read)
+
+	_, err = Parse([]byte("null"))
+	assert.Error(t, err)
+}
+
+func TestFormat(t *testing.T) {
+	r := Report{
+		"Vault":   {Runtime: 4000, Init: 4300},
+		"Reserve": {Runtime: 23000, Init: 24500, DeployGas: 5100000},
+	}
+	assert.Equal(t, []string{"Reserve", "Vault"}, r.Contracts())
+	assert.Equal(t, ""+
+		"contract  deployed  of limit  headroom  init code  deploy gas\n"+
+		"Reserve      23000     93.6%      1576      24500     5100000\n"+
+		"Vault         4000     16.3%     20576       4300           -\n",
+		r.Format())
+
+	assert.Empty(t, r.Over())
+	r["Manager"] = Size{Runtime: MaxCodeSize + 1, Init: 26000}
+	r["Factory"] = Size{Runtime: 2000, Init: MaxInitCodeSize + 1}
+	assert.Equal(t, []string{"Manager", "Factory"}, r.Over())
+}
+
+func TestDiff(t *testing.T) {
+	old := Report{
+		"Reserve": {Runtime: 23000, Init: 24500, DeployGas: 5100000},
+		"Vault":   {Runtime: 4000, Init: 4300},
+		"Relayer": {Runtime: 3000, Init: 3200},
+	}
+	new := Report{
+		"Reserve":  {Runtime: 23100, Init: 24650, DeployGas: 5130000},
+		"Vault":    {Runtime: 4000, Init: 4300},
+		"Registry": {Runtime: 1000, Init: 1100},
+	}
+	var lines []string
+	for _, d := range Diff(old, new) {
+		lines = append(lines, d.String())
+	}
+	assert.Equal(t, []string{
+		"Registry: added, 1000 bytes deployed, 1100 of init code",
+		"Relayer: removed, was 3000 bytes deployed",
+		"Reserve: deployed 23000 -> 23100 (+100), init code 24500 -> 24650 (+150), " +
+			"deploy gas 5100000 -> 5130000 (+30000), 1476 bytes of headroom",
+	}, lines)
+	assert.Empty(t, Diff(old, old))
+}
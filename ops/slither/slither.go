@@ -0,0 +1,195 @@
+// Package slither runs the Slither static analyzer on the contracts and compares what it finds
+// with a baseline of findings already triaged, so that only new findings need attention.
+//
+// The baseline is in the format of Slither's own triage database, slither.db.json, which
+// `slither --triage-mode` writes: a JSON list of findings as Slither reports them. A finding
+// matches the baseline if one there has the same detector and the same source elements, by
+// kind, name, file, and enclosing function or contract; line numbers and Slither's wording are
+// ignored, so that findings don't come back as new when code above them moves or Slither is
+// upgraded.
+package slither
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Finding is one result of a Slither detector.
+type Finding struct {
+	Check       string    `json:"check"`
+	Impact      string    `json:"impact"`
+	Confidence  string    `json:"confidence"`
+	Description string    `json:"description"`
+	Elements    []Element `json:"elements"`
+
+	// raw is the finding as Slither reported it, which a baseline keeps whole.
+	raw json.RawMessage
+}
+
+// Element is a piece of source that a finding is about.
+type Element struct {
+	Type          string `json:"type"`
+	Name          string `json:"name"`
+	SourceMapping struct {
+		Filename string `json:"filename_relative"`
+	} `json:"source_mapping"`
+	TypeSpecificFields struct {
+		Parent *struct {
+			Type string `json:"type"`
+			Name string `json:"name"`
+		} `json:"parent"`
+	} `json:"type_specific_fields"`
+}
+
+// UnmarshalJSON keeps the raw finding along with its fields.
+func (f *Finding) UnmarshalJSON(b []byte) error {
+	type plain Finding
+	if err := json.Unmarshal(b, (*plain)(f)); err != nil {
+		return err
+	}
+	f.raw = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+// MarshalJSON writes the finding as Slither reported it.
+func (f Finding) MarshalJSON() ([]byte, error) {
+	if f.raw != nil {
+		return f.raw, nil
+	}
+	type plain Finding
+	return json.Marshal(plain(f))
+}
+
+// Key identifies the finding across runs: its detector and its elements, without lines.
+func (f Finding) Key() string {
+	parts := make([]string, len(f.Elements))
+	for i, e := range f.Elements {
+		parts[i] = e.Type + " " + e.Name + " in " + e.SourceMapping.Filename
+		if p := e.TypeSpecificFields.Parent; p != nil {
+			parts[i] += " (" + p.Type + " " + p.Name + ")"
+		}
+	}
+	sort.Strings(parts)
+	return f.Check + ": " + strings.Join(parts, "; ")
+}
+
+// String is the finding's description, on one line.
+func (f Finding) String() string {
+	return f.Check + " [" + f.Impact + "/" + f.Confidence + "]: " + strings.Join(strings.Fields(f.Description), " ")
+}
+
+// Impacts are Slither's impact levels, least first.
+var Impacts = []string{"Optimization", "Informational", "Low", "Medium", "High"}
+
+// AtLeast reports whether the finding's impact is at least min. A level Slither adds later
+// counts as the highest.
+func (f Finding) AtLeast(min string) bool {
+	rank := func(impact string) int {
+		for i, level := range Impacts {
+			if strings.EqualFold(level, impact) {
+				return i
+			}
+		}
+		return len(Impacts)
+	}
+	return rank(f.Impact) >= rank(min)
+}
+
+// Parse reads the findings from the output of `slither --json -`.
+func Parse(r io.Reader) ([]Finding, error) {
+	var output struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+		Results struct {
+			Detectors []Finding `json:"detectors"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(r).Decode(&output); err != nil {
+		return nil, errors.Wrap(err, "parsing Slither's output")
+	}
+	if !output.Success {
+		return nil, errors.Errorf("slither failed: %v", output.Error)
+	}
+	return output.Results.Detectors, nil
+}
+
+// Run runs slither on target, such as "contracts", and returns its findings. Slither exits with
+// an error when it finds anything, so only its output says whether it succeeded.
+func Run(ctx context.Context, slither, target string, args ...string) ([]Finding, error) {
+	cmd := exec.CommandContext(ctx, slither, append([]string{target, "--json", "-"}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	runErr := cmd.Run()
+	if stdout.Len() == 0 {
+		if runErr == nil {
+			runErr = errors.New("no output")
+		}
+		return nil, errors.Wrapf(runErr, "running %v: %s", slither, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return Parse(&stdout)
+}
+
+// LoadBaseline reads a baseline in the format of slither.db.json.
+func LoadBaseline(path string) ([]Finding, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the Slither baseline")
+	}
+	var baseline []Finding
+	if err := json.Unmarshal(b, &baseline); err != nil {
+		return nil, errors.Wrapf(err, "parsing the Slither baseline %v", path)
+	}
+	return baseline, nil
+}
+
+// WriteBaseline writes findings to path as a baseline, one per line, sorted by Key so that
+// updates diff well.
+func WriteBaseline(path string, findings []Finding) error {
+	sorted := append([]Finding(nil), findings...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Key() < sorted[j].Key() })
+	var buf bytes.Buffer
+	buf.WriteString("[\n")
+	for i, f := range sorted {
+		b, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		if i < len(sorted)-1 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+	return errors.Wrap(ioutil.WriteFile(path, buf.Bytes(), 0644), "writing the Slither baseline")
+}
+
+// Compare returns the findings that the baseline lacks, and the baseline's findings that are
+// no longer found, each in the order given.
+func Compare(findings, baseline []Finding) (fresh, fixed []Finding) {
+	known := make(map[string]bool, len(baseline))
+	for _, f := range baseline {
+		known[f.Key()] = true
+	}
+	found := make(map[string]bool, len(findings))
+	for _, f := range findings {
+		found[f.Key()] = true
+		if !known[f.Key()] {
+			fresh = append(fresh, f)
+		}
+	}
+	for _, f := range baseline {
+		if !found[f.Key()] {
+			fixed = append(fixed, f)
+		}
+	}
+	return fresh, fixed
+}
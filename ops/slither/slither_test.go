@@ -0,0 +1,102 @@
+package slither
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// finding returns a calls-loop finding in function of Manager.sol, at line.
+func finding(function, line string) string {
+	return `{"check": "calls-loop", "impact": "Low", "confidence": "Medium",
+		"description": "Manager.` + function + `() has external calls inside a loop (contracts/Manager.sol#` + line + `)\n",
+		"elements": [{"type": "node", "name": "i < trustedBasket.size()",
+			"source_mapping": {"filename_relative": "contracts/Manager.sol", "lines": [` + line + `]},
+			"type_specific_fields": {"parent": {"type": "function", "name": "` + function + `"}}}]}`
+}
+
+const solcVersion = `{"check": "solc-version", "impact": "Informational", "confidence": "High",
+	"description": "Pragma version 0.5.7 allows old versions (contracts/Vault.sol#1)\n",
+	"elements": [{"type": "pragma", "name": "0.5.7", "source_mapping": {"filename_relative": "contracts/Vault.sol", "lines": [1]}}]}`
+
+func output(findings ...string) string {
+	return `{"success": true, "error": null, "results": {"detectors": [` + strings.Join(findings, ",") + `]}}`
+}
+
+func TestParse(t *testing.T) {
+	findings, err := Parse(strings.NewReader(output(finding("issue", "211"), solcVersion)))
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+	assert.Equal(t, "calls-loop: node i < trustedBasket.size() in contracts/Manager.sol (function issue)", findings[0].Key())
+	assert.Equal(t, "calls-loop [Low/Medium]: Manager.issue() has external calls inside a loop (contracts/Manager.sol#211)", findings[0].String())
+	assert.Equal(t, "solc-version: pragma 0.5.7 in contracts/Vault.sol", findings[1].Key())
+
+	assert.True(t, findings[0].AtLeast("Low"))
+	assert.True(t, findings[0].AtLeast("informational"))
+	assert.False(t, findings[0].AtLeast("Medium"))
+	assert.False(t, findings[1].AtLeast("Low"))
+
+	_, err = Parse(strings.NewReader(`{"success": false, "error": "compilation failed", "results": {}}`))
+	assert.EqualError(t, err, "slither failed: compilation failed")
+	_, err = Parse(strings.NewReader("Traceback (most recent call last):"))
+	assert.Error(t, err)
+}
+
+func TestCompare(t *testing.T) {
+	baseline, err := Parse(strings.NewReader(output(finding("issue", "211"), finding("redeem", "300"), solcVersion)))
+	require.NoError(t, err)
+
+	// The issue finding moved down the file, the redeem one was fixed, and a new one appeared.
+	findings, err := Parse(strings.NewReader(output(solcVersion, finding("issue", "240"), finding("sweep", "500"))))
+	require.NoError(t, err)
+	fresh, fixed := Compare(findings, baseline)
+	require.Len(t, fresh, 1)
+	assert.Contains(t, fresh[0].Key(), "function sweep")
+	require.Len(t, fixed, 1)
+	assert.Contains(t, fixed[0].Key(), "function redeem")
+
+	fresh, fixed = Compare(baseline, baseline)
+	assert.Empty(t, fresh)
+	assert.Empty(t, fixed)
+}
+
+func TestBaseline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slither")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slither.db.json")
+
+	findings, err := Parse(strings.NewReader(output(solcVersion, finding("issue", "211"))))
+	require.NoError(t, err)
+	require.NoError(t, WriteBaseline(path, findings))
+	baseline, err := LoadBaseline(path)
+	require.NoError(t, err)
+
+	// Sorted by key, and kept whole, fields this package doesn't read included.
+	require.Len(t, baseline, 2)
+	assert.Equal(t, findings[1].Key(), baseline[0].Key())
+	assert.Equal(t, findings[0].Key(), baseline[1].Key())
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"lines":[211]`)
+
+	_, err = LoadBaseline(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+// TestCommittedBaseline checks that the repo's baseline parses, and that every finding in it has
+// a key of its own to match.
+func TestCommittedBaseline(t *testing.T) {
+	baseline, err := LoadBaseline("../../slither.db.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, baseline)
+	for _, f := range baseline {
+		assert.NotEmpty(t, f.Check)
+		assert.NotEmpty(t, f.Elements, f.String())
+	}
+}
@@ -0,0 +1,478 @@
+// Package spec parses invariant specifications, such as
+//
+//	vault[token] * 1e36 >= supply * weight[token]
+//
+// and checks them against the state of a deployment, as metrics.State holds it. The tests check
+// every spec in invariants.spec after every step of the fuzz tests, and rsvalert alerts when one
+// fails on chain, so both check the same properties of the same state, each spec reading the
+// same variables in the same way.
+//
+// A spec compares two expressions with one of >=, <=, >, <, ==, and !=. Expressions are made of
+// integers, which may be written as 1e18, true and false, which are 1 and 0, the variables below,
+// +, -, *, /, which rounds toward zero, and parentheses; all arithmetic is on integers of any size. A spec that mentions a
+// variable indexed by [token] holds if it holds for every token of the basket.
+//
+// The variables are the fields of metrics.State, booleans being 1 for true and 0 for false:
+//
+//	supply            the RSV supply, in attoRSV
+//	rsvDecimals       the Reserve's decimals
+//	paused            whether the Reserve is paused
+//	transfersPaused   whether the Reserve's transfers are paused
+//	issuancePaused    whether the Manager's issuance is paused
+//	redemptionPaused  whether the Manager's redemption is paused
+//	emergency         whether the Manager is in an emergency
+//	pendingProposals  how many of the Manager's proposals are created or accepted
+//	tokens            how many tokens the basket has
+//	vault[token]      the Vault's balance of the token, in qTokens
+//	weight[token]     the token's basket weight, in aqToken/RSV
+//	decimals[token]   the token's decimals
+//	insurance[token]  the InsuranceFund's balance of the token, in qTokens
+package spec
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+)
+
+// scalars and indexed are the variables that specs may read; see the package doc.
+var (
+	scalars = map[string]bool{
+		"supply": true, "rsvDecimals": true, "paused": true, "transfersPaused": true,
+		"issuancePaused": true, "redemptionPaused": true, "emergency": true,
+		"pendingProposals": true, "tokens": true,
+	}
+	indexed = map[string]bool{"vault": true, "weight": true, "decimals": true, "insurance": true}
+)
+
+// Env holds the values of the variables.
+type Env struct {
+	Scalars map[string]*big.Int
+
+	// Tokens names the basket's tokens, in order, and Indexed holds each indexed variable's
+	// value for each token, by name. A token missing from a variable has no value for it.
+	Tokens  []string
+	Indexed map[string]map[string]*big.Int
+}
+
+// FromState returns the variables of s. Its tokens are named by symbol, or by address if they
+// have none.
+func FromState(s *metrics.State) *Env {
+	flag := func(b bool) *big.Int {
+		if b {
+			return big.NewInt(1)
+		}
+		return big.NewInt(0)
+	}
+	env := &Env{
+		Scalars: map[string]*big.Int{
+			"supply":           s.Supply,
+			"rsvDecimals":      big.NewInt(int64(s.RSVDecimals)),
+			"paused":           flag(s.Paused),
+			"transfersPaused":  flag(s.TransfersPaused),
+			"issuancePaused":   flag(s.IssuancePaused),
+			"redemptionPaused": flag(s.RedemptionPaused),
+			"emergency":        flag(s.Emergency),
+			"pendingProposals": big.NewInt(int64(s.PendingProposals)),
+			"tokens":           big.NewInt(int64(len(s.Tokens))),
+		},
+		Indexed: map[string]map[string]*big.Int{
+			"vault": {}, "weight": {}, "decimals": {}, "insurance": {},
+		},
+	}
+	for _, t := range s.Tokens {
+		name := t.Symbol
+		if name == "" {
+			name = t.Address.Hex()
+		}
+		env.Tokens = append(env.Tokens, name)
+		env.Indexed["vault"][name] = t.Balance
+		env.Indexed["weight"][name] = t.Weight
+		env.Indexed["decimals"][name] = big.NewInt(int64(t.Decimals))
+		if t.Insurance != nil {
+			env.Indexed["insurance"][name] = t.Insurance
+		}
+	}
+	return env
+}
+
+// expr is a parsed expression.
+type expr interface {
+	// eval evaluates the expression for token, recording the variables it reads in values.
+	eval(env *Env, token string, values map[string]string) (*big.Int, error)
+	// format formats the expression, indexed variables indexed by token.
+	format(token string) string
+}
+
+type number struct {
+	value  *big.Int
+	source string
+}
+
+func (n number) eval(*Env, string, map[string]string) (*big.Int, error) { return n.value, nil }
+func (n number) format(string) string                                   { return n.source }
+
+type variable struct {
+	name    string
+	indexed bool
+}
+
+func (v variable) eval(env *Env, token string, values map[string]string) (*big.Int, error) {
+	var value *big.Int
+	if v.indexed {
+		value = env.Indexed[v.name][token]
+	} else {
+		value = env.Scalars[v.name]
+	}
+	if value == nil {
+		return nil, errors.Errorf("%v is unknown", v.format(token))
+	}
+	values[v.format(token)] = value.String()
+	return value, nil
+}
+
+func (v variable) format(token string) string {
+	if v.indexed {
+		return v.name + "[" + token + "]"
+	}
+	return v.name
+}
+
+type binary struct {
+	op          byte
+	left, right expr
+}
+
+func (b binary) eval(env *Env, token string, values map[string]string) (*big.Int, error) {
+	x, err := b.left.eval(env, token, values)
+	if err != nil {
+		return nil, err
+	}
+	y, err := b.right.eval(env, token, values)
+	if err != nil {
+		return nil, err
+	}
+	switch b.op {
+	case '+':
+		return new(big.Int).Add(x, y), nil
+	case '-':
+		return new(big.Int).Sub(x, y), nil
+	case '*':
+		return new(big.Int).Mul(x, y), nil
+	}
+	if y.Sign() == 0 {
+		return nil, errors.Errorf("%v divides by zero", b.format(token))
+	}
+	return new(big.Int).Quo(x, y), nil
+}
+
+func (b binary) format(token string) string {
+	return "(" + b.left.format(token) + " " + string(b.op) + " " + b.right.format(token) + ")"
+}
+
+// comparisons are the operators that a spec may compare with, and whether each holds for each
+// result of big.Int.Cmp.
+var comparisons = map[string]func(cmp int) bool{
+	">=": func(c int) bool { return c >= 0 },
+	"<=": func(c int) bool { return c <= 0 },
+	">":  func(c int) bool { return c > 0 },
+	"<":  func(c int) bool { return c < 0 },
+	"==": func(c int) bool { return c == 0 },
+	"!=": func(c int) bool { return c != 0 },
+}
+
+// Spec is a parsed invariant specification.
+type Spec struct {
+	Name   string
+	Doc    string // what the spec says, in words; may be empty
+	Source string
+
+	op          string
+	left, right expr
+	perToken    bool
+}
+
+// Failure is a check of a spec that failed: for Token, if the spec is per token, the left side
+// compared with the right as the spec requires, or could not be evaluated, as Err says.
+type Failure struct {
+	Token       string
+	Left, Right *big.Int // nil if Err is set
+	Err         error
+
+	// Values are the variables that the spec read, by name, as "vault[USDC]".
+	Values map[string]string
+}
+
+// Describe says how the spec failed, with the values that it compared.
+func (s *Spec) Describe(f Failure) string {
+	if f.Err != nil {
+		return fmt.Sprintf("%v: %v", s.Name, f.Err)
+	}
+	return fmt.Sprintf("%v: %v %v %v fails, as %v is not %v %v",
+		s.Name, top(s.left, f.Token), s.op, top(s.right, f.Token), f.Left, s.op, f.Right)
+}
+
+// top formats e without the parentheses around the whole of it.
+func top(e expr, token string) string {
+	if b, ok := e.(binary); ok {
+		return b.left.format(token) + " " + string(b.op) + " " + b.right.format(token)
+	}
+	return e.format(token)
+}
+
+// Check checks the spec against env: for each token of the basket if the spec is per token,
+// and otherwise once. It returns the checks that failed.
+func (s *Spec) Check(env *Env) []Failure {
+	tokens := []string{""}
+	if s.perToken {
+		tokens = env.Tokens
+	}
+	var failures []Failure
+	for _, token := range tokens {
+		f := Failure{Token: token, Values: map[string]string{}}
+		if f.Left, f.Err = s.left.eval(env, token, f.Values); f.Err == nil {
+			f.Right, f.Err = s.right.eval(env, token, f.Values)
+		}
+		if f.Err != nil {
+			f.Left, f.Right = nil, nil
+			failures = append(failures, f)
+		} else if !comparisons[s.op](f.Left.Cmp(f.Right)) {
+			failures = append(failures, f)
+		}
+	}
+	return failures
+}
+
+// Parse parses the spec called name, whose text is src.
+func Parse(name, src string) (*Spec, error) {
+	p := &parser{src: src}
+	s := &Spec{Name: name, Source: strings.TrimSpace(src)}
+	var err error
+	if s.left, err = p.sum(); err != nil {
+		return nil, errors.Wrapf(err, "spec %v", name)
+	}
+	p.space()
+	for _, op := range []string{">=", "<=", "==", "!=", ">", "<"} {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			s.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+	if s.op == "" {
+		return nil, errors.Errorf("spec %v: expected a comparison at %q", name, p.rest())
+	}
+	if s.right, err = p.sum(); err != nil {
+		return nil, errors.Wrapf(err, "spec %v", name)
+	}
+	if p.space(); p.pos < len(p.src) {
+		return nil, errors.Errorf("spec %v: unexpected %q", name, p.rest())
+	}
+	s.perToken = p.perToken
+	return s, nil
+}
+
+// parser parses an expression by recursive descent.
+type parser struct {
+	src      string
+	pos      int
+	perToken bool // whether a variable indexed by [token] was parsed
+}
+
+func (p *parser) space() {
+	for p.pos < len(p.src) && strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *parser) rest() string {
+	if p.pos >= len(p.src) {
+		return "the end"
+	}
+	return p.src[p.pos:]
+}
+
+// peek returns the next character after any space, or 0 at the end.
+func (p *parser) peek() byte {
+	p.space()
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) sum() (expr, error) {
+	left, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for c := p.peek(); c == '+' || c == '-'; c = p.peek() {
+		p.pos++
+		right, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{c, left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) product() (expr, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for c := p.peek(); c == '*' || c == '/'; c = p.peek() {
+		p.pos++
+		right, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{c, left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) primary() (expr, error) {
+	c := p.peek()
+	switch {
+	case c == '(':
+		p.pos++
+		e, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, errors.Errorf("expected ) at %q", p.rest())
+		}
+		p.pos++
+		return e, nil
+	case c >= '0' && c <= '9':
+		return p.number()
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		return p.variable()
+	}
+	return nil, errors.Errorf("expected a number, a variable, or ( at %q", p.rest())
+}
+
+func (p *parser) number() (expr, error) {
+	start := p.pos
+	for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == 'e') {
+		p.pos++
+	}
+	source := p.src[start:p.pos]
+	parts := strings.Split(source, "e")
+	mantissa, ok := new(big.Int).SetString(parts[0], 10)
+	if !ok || len(parts) > 2 {
+		return nil, errors.Errorf("%q is not an integer", source)
+	}
+	if len(parts) == 2 {
+		exp, ok := new(big.Int).SetString(parts[1], 10)
+		if !ok || exp.Cmp(big.NewInt(100)) > 0 {
+			return nil, errors.Errorf("%q is not an integer of at most 100 digits", source)
+		}
+		mantissa.Mul(mantissa, new(big.Int).Exp(big.NewInt(10), exp, nil))
+	}
+	return number{mantissa, source}, nil
+}
+
+func (p *parser) variable() (expr, error) {
+	start := p.pos
+	for p.pos < len(p.src) && isIdent(p.src[p.pos]) {
+		p.pos++
+	}
+	v := variable{name: p.src[start:p.pos]}
+	switch v.name {
+	case "true":
+		return number{big.NewInt(1), v.name}, nil
+	case "false":
+		return number{big.NewInt(0), v.name}, nil
+	}
+	if p.peek() == '[' {
+		p.pos++
+		p.space()
+		index := p.pos
+		for p.pos < len(p.src) && isIdent(p.src[p.pos]) {
+			p.pos++
+		}
+		if p.src[index:p.pos] != "token" || p.peek() != ']' {
+			return nil, errors.Errorf("%v must be indexed by [token]", v.name)
+		}
+		p.pos++
+		v.indexed = true
+	}
+	switch {
+	case v.indexed && !indexed[v.name]:
+		return nil, errors.Errorf("%v is not a variable indexed by token", v.name)
+	case !v.indexed && indexed[v.name]:
+		return nil, errors.Errorf("%v must be indexed by [token]", v.name)
+	case !v.indexed && !scalars[v.name]:
+		return nil, errors.Errorf("there is no variable %v", v.name)
+	}
+	p.perToken = p.perToken || v.indexed
+	return v, nil
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// ParseFile parses a file of specs, one to a line, as "<name>: <spec>". Lines starting with #
+// are comments; those just before a spec are its Doc. Names are unique.
+func ParseFile(b []byte) ([]*Spec, error) {
+	var specs []*Spec
+	seen := make(map[string]bool)
+	var doc []string
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			doc = nil
+			continue
+		case strings.HasPrefix(line, "#"):
+			doc = append(doc, strings.TrimSpace(strings.TrimPrefix(line, "#")))
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon <= 0 {
+			return nil, errors.Errorf("line %v: expected <name>: <spec>", n)
+		}
+		name := strings.TrimSpace(line[:colon])
+		if seen[name] {
+			return nil, errors.Errorf("line %v: spec %v is already defined", n, name)
+		}
+		seen[name] = true
+		s, err := Parse(name, line[colon+1:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %v", n)
+		}
+		s.Doc = strings.Join(doc, " ")
+		doc = nil
+		specs = append(specs, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading specs")
+	}
+	if len(specs) == 0 {
+		return nil, errors.New("no specs")
+	}
+	return specs, nil
+}
+
+// ReadFile reads a file of specs; see ParseFile.
+func ReadFile(path string) ([]*Spec, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading specs")
+	}
+	specs, err := ParseFile(b)
+	return specs, errors.Wrap(err, path)
+}
@@ -0,0 +1,133 @@
+package spec
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+)
+
+func e(n int64, decimals int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), new(big.Int).Exp(big.NewInt(10), big.NewInt(decimals), nil))
+}
+
+var usdc = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+
+func testState() *metrics.State {
+	return &metrics.State{
+		Supply:      e(1000, 18), // 1000 RSV
+		RSVDecimals: 18,
+		Paused:      true,
+		Tokens: []metrics.Token{
+			// Half a USDC per RSV, and the Vault holds 500 USDC: exactly backed.
+			{Address: usdc, Symbol: "USDC", Decimals: 6, Weight: e(5, 23), Balance: e(500, 6)},
+			// Half a token per RSV, and the Vault holds 400 of them.
+			{Address: common.HexToAddress("0x01"), Decimals: 18, Weight: e(5, 35), Balance: e(400, 18), Insurance: e(20, 18)},
+		},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	env := FromState(testState())
+	other := "0x0000000000000000000000000000000000000001"
+	assert.Equal(t, []string{"USDC", other}, env.Tokens)
+
+	backed, err := Parse("backed", "vault[token] * 1e36 >= supply * weight[ token ]")
+	require.NoError(t, err)
+	failures := backed.Check(env)
+	require.Len(t, failures, 1)
+	assert.Equal(t, other, failures[0].Token)
+	assert.Equal(t, map[string]string{
+		"vault[" + other + "]":  e(400, 18).String(),
+		"supply":                e(1000, 18).String(),
+		"weight[" + other + "]": e(5, 35).String(),
+	}, failures[0].Values)
+	assert.Equal(t, "backed: vault["+other+"] * 1e36 >= supply * weight["+other+"] fails, as "+
+		e(400, 54).String()+" is not >= "+e(500, 54).String(), backed.Describe(failures[0]))
+
+	for src, holds := range map[string]bool{
+		"paused == 1":                          true,
+		"paused != true":                       false,
+		"emergency == 0 ":                      true,
+		"tokens - 2 < 1":                       true,
+		"(supply + 1e18) / 2 == 5005e17":       true,
+		"supply / 3 * 3 == supply":             false,
+		"decimals[token] <= 18":                true,
+		"vault[token] * 2 > 999000000":         true,
+		"rsvDecimals * rsvDecimals + 1 == 325": true,
+	} {
+		s, err := Parse("spec", src)
+		require.NoError(t, err, src)
+		assert.Equal(t, holds, len(s.Check(env)) == 0, src)
+	}
+
+	// USDC has no insurance, and division by zero is an error.
+	for _, src := range []string{"insurance[token] >= 0", "supply / pendingProposals > 0"} {
+		s, err := Parse("spec", src)
+		require.NoError(t, err, src)
+		failures := s.Check(env)
+		require.Len(t, failures, 1, src)
+		assert.Error(t, failures[0].Err, src)
+		assert.Nil(t, failures[0].Left, src)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"supply",
+		"supply >= ",
+		"supply >= 0 0",
+		"supplyy >= 0",
+		"vault >= 0",
+		"supply[token] >= 0",
+		"vault[i] >= 0",
+		"(supply >= 0",
+		"(supply + 1 >= 0",
+		"1e >= 0",
+		"1e1e1 >= 0",
+		"1e1000 >= 0",
+		"supply >= -1",
+		"supply => 0",
+	} {
+		_, err := Parse("spec", src)
+		assert.Error(t, err, src)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	specs, err := ParseFile([]byte(`# The header.
+
+# The Vault backs the supply.
+# In every token.
+backed: vault[token] * 1e36 >= supply * weight[token]
+notPaused: paused == 0
+`))
+	require.NoError(t, err)
+	require.Len(t, specs, 2)
+	assert.Equal(t, "backed", specs[0].Name)
+	assert.Equal(t, "The Vault backs the supply. In every token.", specs[0].Doc)
+	assert.Equal(t, "vault[token] * 1e36 >= supply * weight[token]", specs[0].Source)
+	assert.Equal(t, "", specs[1].Doc)
+
+	for _, bad := range []string{"", "# only a comment\n", "backed vault[token] >= 0\n", "a: paused == 0\na: paused == 1\n", "a: paused\n"} {
+		_, err := ParseFile([]byte(bad))
+		assert.Error(t, err, bad)
+	}
+}
+
+// TestRepoSpecs checks that the repo's invariants.spec parses, and holds of a backed state.
+func TestRepoSpecs(t *testing.T) {
+	specs, err := ReadFile("../../invariants.spec")
+	require.NoError(t, err)
+	s := testState()
+	s.Tokens = s.Tokens[:1]
+	for _, spec := range specs {
+		assert.NotEmpty(t, spec.Doc, spec.Name)
+		assert.Empty(t, spec.Check(FromState(s)), spec.Name)
+	}
+}
@@ -0,0 +1,236 @@
+// Package stream follows the logs of a set of contracts in a way that survives chain
+// reorganizations. Logs are delivered once their block has the configured number of
+// confirmations. The stream remembers the hashes of the recent blocks it delivered from; if one
+// of them leaves the chain, it delivers each log it had delivered from the orphaned blocks again,
+// marked Removed, and then the logs of the blocks that replaced them. A consumer that undoes
+// what it did for a removed log therefore never acts on an orphaned one.
+//
+// The stream itself keeps no state: each batch comes with the State to resume from, which the
+// consumer stores together with the effects of the batch.
+package stream
+
+import (
+	"context"
+	"math/big"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+// Node is what the stream needs from an Ethereum node.
+type Node interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// Stream delivers the logs matching Addresses and Topics.
+type Stream struct {
+	Node      Node
+	Addresses []common.Address
+	Topics    [][]common.Hash
+
+	// From is the first block delivered from an empty State.
+	From uint64
+
+	// Confirmations is how many blocks a block must be under before its logs are delivered.
+	Confirmations uint64
+
+	// Chunk is the most blocks fetched in one query (default 10000). Chunks are halved while
+	// the node refuses them as too large.
+	Chunk uint64
+
+	// Depth is how many blocks back reorganizations are compensated for (default 64). A
+	// reorganization deeper than Depth blocks beyond the confirmations is ErrReorg.
+	Depth uint64
+}
+
+// State is where a stream is: the recent blocks it delivered logs from, with the logs, and the
+// last block it delivered, oldest first.
+type State struct {
+	Blocks []Block `json:"blocks"`
+}
+
+// Block is a delivered block.
+type Block struct {
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
+	Logs   []types.Log `json:"logs,omitempty"`
+}
+
+// Last is the number of the last block delivered, and whether there is one.
+func (st State) Last() (uint64, bool) {
+	if len(st.Blocks) == 0 {
+		return 0, false
+	}
+	return st.Blocks[len(st.Blocks)-1].Number, true
+}
+
+// Batch is what the stream delivers at once.
+type Batch struct {
+	// Logs are the removed logs, newest first, if the chain reorganized; or else the logs of
+	// blocks From to To, in chain order.
+	Logs []types.Log
+
+	From, To uint64
+
+	// State is where the stream is once the batch is handled.
+	State State
+}
+
+// ErrReorg means that the chain has reorganized deeper than the stream can compensate for.
+var ErrReorg = errors.New("the chain has been reorganized beyond the blocks the stream remembers")
+
+// Tip returns a State just past the latest confirmed block, to start a stream from the head
+// without delivering the past.
+func (s *Stream) Tip(ctx context.Context) (State, error) {
+	head, err := s.Node.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return State{}, errors.Wrap(err, "reading head")
+	}
+	last := head.Number.Uint64()
+	if last >= s.Confirmations {
+		last -= s.Confirmations
+	}
+	header, err := s.header(ctx, last)
+	if err != nil {
+		return State{}, err
+	}
+	return State{Blocks: []Block{{Number: last, Hash: header.Hash()}}}, nil
+}
+
+// Next returns the next batch after st, or nil if there are no more confirmed blocks yet.
+func (s *Stream) Next(ctx context.Context, st State) (*Batch, error) {
+	head, err := s.Node.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading head")
+	}
+	if head.Number.Uint64() < s.Confirmations {
+		return nil, nil
+	}
+	last := head.Number.Uint64() - s.Confirmations
+
+	next := s.From
+	if n, ok := st.Last(); ok {
+		removed, err := s.reorganized(ctx, st)
+		if err != nil || removed != nil {
+			return removed, err
+		}
+		next = n + 1
+	}
+	if next > last {
+		return nil, nil
+	}
+
+	chunk := s.Chunk
+	if chunk == 0 {
+		chunk = 10000
+	}
+	for {
+		to := next + chunk - 1
+		if to > last || to < next {
+			to = last
+		}
+		logs, err := s.Node.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(next),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: s.Addresses,
+			Topics:    s.Topics,
+		})
+		if err != nil {
+			if chunk > 1 && ctx.Err() == nil {
+				chunk /= 2
+				continue
+			}
+			return nil, errors.Wrapf(err, "fetching logs of blocks %v-%v", next, to)
+		}
+		header, err := s.header(ctx, to)
+		if err != nil {
+			return nil, err
+		}
+		state, err := s.advance(st, logs, to, header.Hash())
+		if err != nil {
+			return nil, err
+		}
+		return &Batch{Logs: logs, From: next, To: to, State: state}, nil
+	}
+}
+
+// reorganized checks the blocks of st against the chain. If some have left it, it returns the
+// batch that removes their logs.
+func (s *Stream) reorganized(ctx context.Context, st State) (*Batch, error) {
+	for i := len(st.Blocks) - 1; i >= 0; i-- {
+		b := st.Blocks[i]
+		header, err := s.header(ctx, b.Number)
+		if err != nil {
+			return nil, err
+		}
+		if header.Hash() != b.Hash {
+			continue
+		}
+		if i == len(st.Blocks)-1 {
+			return nil, nil
+		}
+		// Blocks i+1 on are orphaned: remove their logs, newest first.
+		var removed []types.Log
+		orphaned := st.Blocks[i+1:]
+		for j := len(orphaned) - 1; j >= 0; j-- {
+			logs := orphaned[j].Logs
+			for k := len(logs) - 1; k >= 0; k-- {
+				l := logs[k]
+				l.Removed = true
+				removed = append(removed, l)
+			}
+		}
+		return &Batch{
+			Logs:  removed,
+			From:  b.Number + 1,
+			To:    orphaned[len(orphaned)-1].Number,
+			State: State{Blocks: append([]Block(nil), st.Blocks[:i+1]...)},
+		}, nil
+	}
+	last, _ := st.Last()
+	return nil, errors.Wrapf(ErrReorg, "none of the delivered blocks up to %v is still on the chain", last)
+}
+
+// advance returns st after delivering logs, of the blocks up to to, whose hash is hash.
+func (s *Stream) advance(st State, logs []types.Log, to uint64, hash common.Hash) (State, error) {
+	blocks := append([]Block(nil), st.Blocks...)
+	for _, l := range logs {
+		n := len(blocks) - 1
+		if n >= 0 && blocks[n].Number == l.BlockNumber {
+			if blocks[n].Hash != l.BlockHash {
+				return State{}, errors.Errorf("logs of block %v came from two different blocks; the chain is reorganizing", l.BlockNumber)
+			}
+			blocks[n].Logs = append(blocks[n].Logs, l)
+			continue
+		}
+		blocks = append(blocks, Block{Number: l.BlockNumber, Hash: l.BlockHash, Logs: []types.Log{l}})
+	}
+	if n := len(blocks) - 1; n >= 0 && blocks[n].Number == to {
+		if blocks[n].Hash != hash {
+			return State{}, errors.Errorf("block %v changed while its logs were read; the chain is reorganizing", to)
+		}
+	} else {
+		blocks = append(blocks, Block{Number: to, Hash: hash})
+	}
+
+	depth := s.Depth
+	if depth == 0 {
+		depth = 64
+	}
+	keep := 0
+	for keep < len(blocks)-1 && blocks[keep].Number+depth < to {
+		keep++
+	}
+	return State{Blocks: blocks[keep:]}, nil
+}
+
+func (s *Stream) header(ctx context.Context, number uint64) (*types.Header, error) {
+	header, err := s.Node.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading block %v", number)
+	}
+	return header, nil
+}
@@ -0,0 +1,142 @@
+package stream
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChain is a chain of empty headers. Changing a block's fork changes its hash, and the
+// hashes and logs of the blocks after it, as a reorganization would.
+type fakeChain struct {
+	head  uint64
+	forks map[uint64]byte
+	logs  map[uint64][]uint // log indexes by block, on the current chain
+	limit uint64
+}
+
+func (c *fakeChain) fork(number uint64) byte {
+	var f byte
+	for n, v := range c.forks {
+		if n <= number && v > f {
+			f = v
+		}
+	}
+	return f
+}
+
+func (c *fakeChain) header(number uint64) *types.Header {
+	return &types.Header{Number: new(big.Int).SetUint64(number), Extra: []byte{c.fork(number)}}
+}
+
+func (c *fakeChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		return c.header(c.head), nil
+	}
+	if number.Uint64() > c.head {
+		return nil, errors.New("not found")
+	}
+	return c.header(number.Uint64()), nil
+}
+
+func (c *fakeChain) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	from, to := q.FromBlock.Uint64(), q.ToBlock.Uint64()
+	if c.limit > 0 && to-from+1 > c.limit {
+		return nil, errors.New("query returned more than 10000 results")
+	}
+	var logs []types.Log
+	for n := from; n <= to; n++ {
+		for _, index := range c.logs[n] {
+			logs = append(logs, types.Log{
+				BlockNumber: n,
+				BlockHash:   c.header(n).Hash(),
+				Index:       index,
+				TxHash:      common.BytesToHash([]byte{byte(n), byte(index), c.fork(n)}),
+			})
+		}
+	}
+	return logs, nil
+}
+
+type position struct {
+	block   uint64
+	index   uint
+	removed bool
+}
+
+func positions(logs []types.Log) []position {
+	result := []position{}
+	for _, l := range logs {
+		result = append(result, position{l.BlockNumber, l.Index, l.Removed})
+	}
+	return result
+}
+
+func TestNext(t *testing.T) {
+	chain := &fakeChain{head: 12, forks: map[uint64]byte{}, logs: map[uint64][]uint{3: {0}, 8: {0, 1}, 10: {4}}}
+	s := &Stream{Node: chain, From: 1, Confirmations: 2, Chunk: 5}
+	ctx := context.Background()
+
+	b, err := s.Next(ctx, State{})
+	require.NoError(t, err)
+	assert.Equal(t, []position{{3, 0, false}}, positions(b.Logs))
+	assert.Equal(t, [2]uint64{1, 5}, [2]uint64{b.From, b.To})
+	b, err = s.Next(ctx, b.State)
+	require.NoError(t, err)
+	assert.Equal(t, []position{{8, 0, false}, {8, 1, false}, {10, 4, false}}, positions(b.Logs))
+	assert.Equal(t, uint64(10), b.To, "only confirmed blocks are delivered")
+	st := b.State
+	b, err = s.Next(ctx, st)
+	require.NoError(t, err)
+	assert.Nil(t, b, "up to date")
+
+	// Blocks 8 on are replaced; the new block 8 has a different log.
+	chain.forks[8] = 1
+	chain.logs[8] = []uint{2}
+	delete(chain.logs, 10)
+	chain.head = 13
+	b, err = s.Next(ctx, st)
+	require.NoError(t, err)
+	assert.Equal(t, []position{{10, 4, true}, {8, 1, true}, {8, 0, true}}, positions(b.Logs))
+	b, err = s.Next(ctx, b.State)
+	require.NoError(t, err)
+	assert.Equal(t, []position{{8, 2, false}}, positions(b.Logs))
+	assert.Equal(t, [2]uint64{6, 10}, [2]uint64{b.From, b.To})
+
+	// A reorganization deeper than the stream remembers stops it.
+	chain.forks[1] = 2
+	_, err = s.Next(ctx, b.State)
+	assert.Equal(t, ErrReorg, errors.Cause(err))
+}
+
+func TestNextHalvesChunks(t *testing.T) {
+	chain := &fakeChain{head: 20, forks: map[uint64]byte{}, logs: map[uint64][]uint{}, limit: 3}
+	s := &Stream{Node: chain, From: 1, Chunk: 8}
+	b, err := s.Next(context.Background(), State{})
+	require.NoError(t, err)
+	assert.Equal(t, [2]uint64{1, 2}, [2]uint64{b.From, b.To})
+}
+
+func TestDepth(t *testing.T) {
+	chain := &fakeChain{head: 100, forks: map[uint64]byte{}, logs: map[uint64][]uint{10: {0}, 95: {0}}}
+	s := &Stream{Node: chain, From: 1, Depth: 10}
+	b, err := s.Next(context.Background(), State{})
+	require.NoError(t, err)
+	var numbers []uint64
+	for _, block := range b.State.Blocks {
+		numbers = append(numbers, block.Number)
+	}
+	assert.Equal(t, []uint64{95, 100}, numbers, "blocks further back than Depth are forgotten")
+
+	tip, err := s.Tip(context.Background())
+	require.NoError(t, err)
+	last, _ := tip.Last()
+	assert.Equal(t, uint64(100), last)
+}
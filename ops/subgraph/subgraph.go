@@ -0,0 +1,303 @@
+// Package subgraph generates a subgraph for The Graph that indexes the events of deployed
+// contracts: the subgraph manifest, the GraphQL schema, the ABIs, and the AssemblyScript
+// mappings, all derived from the deployment manifest and the compiled artifacts so that they
+// never need editing by hand.
+//
+// Each event becomes an immutable entity named <Contract><Event>, such as ReserveTransfer, with
+// an id of "<transaction hash>-<log index>", the fields blockNumber, blockTimestamp,
+// transactionHash, and logIndex, and one field per event parameter, named as in the ABI. A
+// parameter whose name clashes with one of those fields gets a trailing underscore, and an
+// unnamed one is called param<i>, as graph-cli calls it.
+package subgraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+)
+
+// Source is one contract for the subgraph to index.
+type Source struct {
+	Name       string
+	Address    common.Address
+	StartBlock uint64
+	Artifact   *chain.Artifact
+}
+
+// Files are the generated files, by slash-separated path relative to the subgraph directory.
+type Files map[string][]byte
+
+// reserved are the fields every entity has.
+var reserved = map[string]bool{
+	"id": true, "blockNumber": true, "blockTimestamp": true, "transactionHash": true, "logIndex": true,
+}
+
+type field struct {
+	Name   string // entity field
+	Param  string // event parameter, as graph-cli names it
+	Type   string // GraphQL type
+	Assign string // AssemblyScript expression for the value
+}
+
+type handler struct {
+	Source    string
+	Event     string
+	Signature string // as in the subgraph manifest, e.g. Transfer(indexed address,indexed address,uint256)
+	Entity    string
+	Fields    []field
+}
+
+type dataSource struct {
+	Source
+	Handlers []handler
+}
+
+// Generate generates the subgraph for sources on network, which must be a network name that
+// The Graph knows, such as mainnet or ropsten.
+func Generate(network string, sources []Source) (Files, error) {
+	files := Files{}
+	var dataSources []dataSource
+	for _, s := range sources {
+		ds := dataSource{Source: s}
+		names := make([]string, 0, len(s.Artifact.ABI.Events))
+		for name := range s.Artifact.ABI.Events {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			event := s.Artifact.ABI.Events[name]
+			if event.Anonymous {
+				// Handlers match events by signature, which anonymous events don't log.
+				continue
+			}
+			h, err := newHandler(s.Name, event)
+			if err != nil {
+				return nil, err
+			}
+			ds.Handlers = append(ds.Handlers, h)
+		}
+		if len(ds.Handlers) == 0 {
+			return nil, errors.Errorf("%v has no events to index", s.Name)
+		}
+		dataSources = append(dataSources, ds)
+
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, []byte(s.Artifact.ABIJSON), "", "  "); err != nil {
+			return nil, errors.Wrapf(err, "formatting ABI of %v", s.Name)
+		}
+		indented.WriteByte('\n')
+		files["abis/"+s.Name+".json"] = indented.Bytes()
+	}
+
+	data := map[string]interface{}{"Network": network, "DataSources": dataSources}
+	for path, t := range templates {
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, data); err != nil {
+			return nil, errors.Wrapf(err, "generating %v", path)
+		}
+		files[path] = buf.Bytes()
+	}
+	return files, nil
+}
+
+func newHandler(contract string, event abi.Event) (handler, error) {
+	h := handler{Source: contract, Event: event.Name, Entity: contract + event.Name}
+	types := make([]string, len(event.Inputs))
+	for i, input := range event.Inputs {
+		types[i] = input.Type.String()
+		if input.Indexed {
+			types[i] = "indexed " + types[i]
+		}
+
+		param := input.Name
+		if param == "" {
+			param = fmt.Sprintf("param%v", i)
+		}
+		name := param
+		if reserved[name] {
+			name += "_"
+		}
+		typ, cast, err := graphType(input.Type, input.Indexed)
+		if err != nil {
+			return handler{}, errors.Wrapf(err, "%v.%v parameter %v", contract, event.Name, param)
+		}
+		assign := "event.params." + param
+		if cast != "" {
+			assign = fmt.Sprintf("changetype<%v>(%v)", cast, assign)
+		}
+		h.Fields = append(h.Fields, field{Name: name, Param: param, Type: typ, Assign: assign})
+	}
+	h.Signature = fmt.Sprintf("%v(%v)", event.Name, strings.Join(types, ","))
+	return h, nil
+}
+
+// graphType returns the GraphQL type of an event parameter of type t, and, when the
+// AssemblyScript value graph-cli generates for it needs a cast to fit the entity field, the type
+// to cast it to.
+func graphType(t abi.Type, indexed bool) (string, string, error) {
+	if indexed {
+		switch t.T {
+		case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+			// The log has only the hash of an indexed dynamic value.
+			return "Bytes!", "", nil
+		}
+	}
+	switch t.T {
+	case abi.AddressTy, abi.FixedBytesTy, abi.BytesTy, abi.HashTy:
+		return "Bytes!", "", nil
+	case abi.BoolTy:
+		return "Boolean!", "", nil
+	case abi.StringTy:
+		return "String!", "", nil
+	case abi.IntTy, abi.UintTy:
+		// graph-cli maps integers that fit in an i32 to i32, and everything wider to BigInt.
+		if t.Size < 32 || (t.T == abi.IntTy && t.Size == 32) {
+			return "Int!", "", nil
+		}
+		return "BigInt!", "", nil
+	case abi.SliceTy, abi.ArrayTy:
+		elem, cast, err := graphType(*t.Elem, false)
+		if err != nil {
+			return "", "", err
+		}
+		if strings.HasPrefix(elem, "[") {
+			return "", "", errors.Errorf("nested array type %v is not supported", t)
+		}
+		if t.Elem.T == abi.AddressTy {
+			cast = "Bytes[]"
+		}
+		return "[" + elem + "]!", cast, nil
+	}
+	return "", "", errors.Errorf("type %v is not supported", t)
+}
+
+// Write writes files under dir.
+func (files Files) Write(dir string) error {
+	for _, path := range files.paths() {
+		full := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return errors.Wrap(err, "creating subgraph directory")
+		}
+		if err := ioutil.WriteFile(full, files[path], 0644); err != nil {
+			return errors.Wrapf(err, "writing %v", full)
+		}
+	}
+	return nil
+}
+
+// Stale returns the paths of the files that are missing from dir, or differ from files there.
+func (files Files) Stale(dir string) ([]string, error) {
+	var stale []string
+	for _, path := range files.paths() {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if os.IsNotExist(err) {
+			stale = append(stale, path)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "reading subgraph")
+		}
+		if !bytes.Equal(b, files[path]) {
+			stale = append(stale, path)
+		}
+	}
+	return stale, nil
+}
+
+func (files Files) paths() []string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+var templates = map[string]*template.Template{
+	"subgraph.yaml": template.Must(template.New("").Parse(`# Generated by rsvadmin subgraph; do not edit.
+specVersion: 0.0.2
+schema:
+  file: ./schema.graphql
+dataSources:
+{{- range .DataSources}}
+  - kind: ethereum/contract
+    name: {{.Name}}
+    network: {{$.Network}}
+    source:
+      address: "{{.Address.Hex}}"
+      abi: {{.Name}}
+      startBlock: {{.StartBlock}}
+    mapping:
+      kind: ethereum/events
+      apiVersion: 0.0.4
+      language: wasm/assemblyscript
+      entities:
+{{- range .Handlers}}
+        - {{.Entity}}
+{{- end}}
+      abis:
+        - name: {{.Name}}
+          file: ./abis/{{.Name}}.json
+      eventHandlers:
+{{- range .Handlers}}
+        - event: {{.Signature}}
+          handler: handle{{.Entity}}
+{{- end}}
+      file: ./src/mapping.ts
+{{- end}}
+`)),
+
+	"schema.graphql": template.Must(template.New("").Parse(`# Generated by rsvadmin subgraph; do not edit.
+{{range .DataSources}}{{range .Handlers}}
+type {{.Entity}} @entity {
+  id: ID!
+  blockNumber: BigInt!
+  blockTimestamp: BigInt!
+  transactionHash: Bytes!
+  logIndex: BigInt!
+{{- range .Fields}}
+  {{.Name}}: {{.Type}}
+{{- end}}
+}
+{{end}}{{end}}`)),
+
+	"src/mapping.ts": template.Must(template.New("").Parse(`// Generated by rsvadmin subgraph; do not edit.
+import { Bytes } from "@graphprotocol/graph-ts"
+{{range .DataSources}}
+import {
+{{- range .Handlers}}
+  {{.Event}} as {{.Entity}}Event,
+{{- end}}
+} from "../generated/{{.Name}}/{{.Name}}"
+{{- end}}
+import {
+{{- range .DataSources}}{{range .Handlers}}
+  {{.Entity}},
+{{- end}}{{end}}
+} from "../generated/schema"
+{{range .DataSources}}{{range .Handlers}}
+export function handle{{.Entity}}(event: {{.Entity}}Event): void {
+  let entity = new {{.Entity}}(event.transaction.hash.toHex() + "-" + event.logIndex.toString())
+  entity.blockNumber = event.block.number
+  entity.blockTimestamp = event.block.timestamp
+  entity.transactionHash = event.transaction.hash
+  entity.logIndex = event.logIndex
+{{- range .Fields}}
+  entity.{{.Name}} = {{.Assign}}
+{{- end}}
+  entity.save()
+}
+{{end}}{{end}}`)),
+}
@@ -0,0 +1,78 @@
+package subgraph
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+)
+
+const managerABI = `[
+	{"anonymous":false,"type":"event","name":"WeightsProposed","inputs":[
+		{"indexed":true,"name":"id","type":"uint256"},{"indexed":true,"name":"proposer","type":"address"},
+		{"indexed":false,"name":"tokens","type":"address[]"},{"indexed":false,"name":"weights","type":"uint256[]"}]},
+	{"anonymous":false,"type":"event","name":"EmergencyChanged","inputs":[
+		{"indexed":true,"name":"","type":"bool"},{"indexed":true,"name":"newVal","type":"bool"}]},
+	{"anonymous":true,"type":"event","name":"Hidden","inputs":[]}]`
+
+func TestGenerate(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(managerABI))
+	require.NoError(t, err)
+	manager := common.HexToAddress("0x5c66566B8Ae5071a5C4ae9F8d7A6c209c3fdd6C8")
+	files, err := Generate("ropsten", []Source{{
+		Name:       "Manager",
+		Address:    manager,
+		StartBlock: 7000000,
+		Artifact:   &chain.Artifact{Name: "Manager", ABI: parsed, ABIJSON: managerABI},
+	}})
+	require.NoError(t, err)
+
+	manifest := string(files["subgraph.yaml"])
+	assert.Contains(t, manifest, `      address: "`+manager.Hex()+`"`)
+	assert.Contains(t, manifest, "      startBlock: 7000000\n")
+	assert.Contains(t, manifest, "        - event: WeightsProposed(indexed uint256,indexed address,address[],uint256[])\n"+
+		"          handler: handleManagerWeightsProposed\n")
+	assert.NotContains(t, manifest, "Hidden", "anonymous events can't be matched")
+
+	schema := string(files["schema.graphql"])
+	assert.Contains(t, schema, "type ManagerWeightsProposed @entity {\n  id: ID!\n")
+	assert.Contains(t, schema, "  id_: BigInt!\n  proposer: Bytes!\n  tokens: [Bytes!]!\n  weights: [BigInt!]!\n}")
+	assert.Contains(t, schema, "  param0: Boolean!\n  newVal: Boolean!\n")
+
+	mapping := string(files["src/mapping.ts"])
+	assert.Contains(t, mapping, "  entity.id_ = event.params.id\n")
+	assert.Contains(t, mapping, "  entity.tokens = changetype<Bytes[]>(event.params.tokens)\n")
+	assert.Contains(t, mapping, `} from "../generated/Manager/Manager"`)
+	assert.Contains(t, string(files["abis/Manager.json"]), `"name": "WeightsProposed"`)
+
+	dir, err := ioutil.TempDir("", "subgraph")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	stale, err := files.Stale(dir)
+	require.NoError(t, err)
+	assert.Len(t, stale, 4)
+	require.NoError(t, files.Write(dir))
+	stale, err = files.Stale(dir)
+	require.NoError(t, err)
+	assert.Empty(t, stale)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "schema.graphql"), []byte("edited"), 0644))
+	stale, err = files.Stale(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"schema.graphql"}, stale)
+}
+
+func TestGenerateUnsupported(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(`[{"anonymous":false,"type":"event","name":"Grid","inputs":[
+		{"indexed":false,"name":"cells","type":"uint256[][]"}]}]`))
+	require.NoError(t, err)
+	_, err = Generate("ropsten", []Source{{Name: "Grid", Artifact: &chain.Artifact{ABI: parsed, ABIJSON: "[]"}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,246 @@
+// Package tenderly simulates transactions with the Tenderly simulation API, so that operators
+// can see what a transaction will do before it is signed.
+package tenderly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+)
+
+// DefaultURL is the Tenderly API root.
+const DefaultURL = "https://api.tenderly.co/api/v1"
+
+// Config locates a Tenderly project.
+type Config struct {
+	Account string `json:"account"`
+	Project string `json:"project"`
+
+	// AccessKeyEnv names the environment variable holding the Tenderly access key.
+	AccessKeyEnv string `json:"accessKeyEnv"`
+
+	// URL overrides DefaultURL.
+	URL string `json:"url,omitempty"`
+}
+
+// Enabled reports whether c configures a project at all.
+func (c Config) Enabled() bool {
+	return c.Account != "" || c.Project != ""
+}
+
+// Client talks to one Tenderly project.
+type Client struct {
+	config    Config
+	accessKey string
+	http      *http.Client
+}
+
+// New returns a Client for c.
+func New(c Config) (*Client, error) {
+	if c.Account == "" || c.Project == "" {
+		return nil, errors.New("config: tenderly needs both account and project")
+	}
+	if c.AccessKeyEnv == "" {
+		return nil, errors.New("config: tenderly.accessKeyEnv is not set")
+	}
+	key := os.Getenv(c.AccessKeyEnv)
+	if key == "" {
+		return nil, errors.Errorf("environment variable %v (tenderly.accessKeyEnv) is empty", c.AccessKeyEnv)
+	}
+	if c.URL == "" {
+		c.URL = DefaultURL
+	}
+	return &Client{config: c, accessKey: key, http: &http.Client{Timeout: time.Minute}}, nil
+}
+
+// Result is the outcome of a simulation.
+type Result struct {
+	// ID identifies the saved simulation in the Tenderly dashboard.
+	ID string
+
+	Success bool
+	Error   string
+	GasUsed uint64
+
+	Logs         []Log
+	StateChanges []StateChange
+}
+
+// Log is an event emitted during a simulation.
+type Log struct {
+	Address common.Address `json:"address"`
+	Topics  []common.Hash  `json:"topics"`
+	Data    hexutil.Bytes  `json:"data"`
+}
+
+// StateChange is one storage variable, or raw slot, changed by a simulation. Name is set when
+// Tenderly could decode the variable from the contract's verified source.
+type StateChange struct {
+	Address  common.Address
+	Name     string
+	Slot     string
+	Original string
+	Dirty    string
+}
+
+// DashboardURL links to the saved simulation.
+func (c *Client) DashboardURL(r *Result) string {
+	return fmt.Sprintf("https://dashboard.tenderly.co/%v/%v/simulator/%v", c.config.Account, c.config.Project, r.ID)
+}
+
+type simulateRequest struct {
+	NetworkID      string `json:"network_id"`
+	From           string `json:"from"`
+	To             string `json:"to,omitempty"`
+	Input          string `json:"input"`
+	Gas            uint64 `json:"gas"`
+	GasPrice       string `json:"gas_price"`
+	Value          string `json:"value"`
+	Save           bool   `json:"save"`
+	SaveIfFails    bool   `json:"save_if_fails"`
+	SimulationType string `json:"simulation_type"`
+}
+
+type simulateResponse struct {
+	Transaction struct {
+		Status          bool   `json:"status"`
+		ErrorMessage    string `json:"error_message"`
+		GasUsed         uint64 `json:"gas_used"`
+		TransactionInfo struct {
+			Logs []struct {
+				Raw Log `json:"raw"`
+			} `json:"logs"`
+			StateDiff []struct {
+				Address common.Address `json:"address"`
+				Soltype *struct {
+					Name string `json:"name"`
+				} `json:"soltype"`
+				Original json.RawMessage `json:"original"`
+				Dirty    json.RawMessage `json:"dirty"`
+				Raw      []struct {
+					Key      string `json:"key"`
+					Original string `json:"original"`
+					Dirty    string `json:"dirty"`
+				} `json:"raw"`
+			} `json:"state_diff"`
+		} `json:"transaction_info"`
+	} `json:"transaction"`
+	Simulation struct {
+		ID string `json:"id"`
+	} `json:"simulation"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Simulate runs tx against the current state of the chain with the given ID.
+func (c *Client) Simulate(ctx context.Context, chainID uint64, tx *chain.PendingTx) (*Result, error) {
+	value := "0"
+	if tx.Value != nil {
+		value = tx.Value.String()
+	}
+	to := ""
+	if tx.Contract != nil {
+		to = tx.Contract.Address.Hex()
+	}
+	body, err := json.Marshal(simulateRequest{
+		NetworkID:      fmt.Sprint(chainID),
+		From:           tx.From.Hex(),
+		To:             to,
+		Input:          hexutil.Encode(tx.Data),
+		Gas:            tx.Gas,
+		GasPrice:       tx.GasPrice.String(),
+		Value:          value,
+		Save:           true,
+		SaveIfFails:    true,
+		SimulationType: "full",
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding simulation request")
+	}
+	url := fmt.Sprintf("%v/account/%v/project/%v/simulate", c.config.URL, c.config.Account, c.config.Project)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "building simulation request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Access-Key", c.accessKey)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "calling Tenderly")
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading Tenderly response")
+	}
+	var parsed simulateResponse
+	if err := json.Unmarshal(b, &parsed); err != nil {
+		return nil, errors.Wrapf(err, "parsing Tenderly response (HTTP %v)", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message := strings.TrimSpace(string(b))
+		if parsed.Error != nil {
+			message = parsed.Error.Message
+		}
+		return nil, errors.Errorf("Tenderly returned HTTP %v: %v", resp.StatusCode, message)
+	}
+
+	t := parsed.Transaction
+	result := &Result{
+		ID:      parsed.Simulation.ID,
+		Success: t.Status,
+		Error:   t.ErrorMessage,
+		GasUsed: t.GasUsed,
+	}
+	for _, l := range t.TransactionInfo.Logs {
+		result.Logs = append(result.Logs, l.Raw)
+	}
+	for _, d := range t.TransactionInfo.StateDiff {
+		if d.Soltype != nil {
+			result.StateChanges = append(result.StateChanges, StateChange{
+				Address:  d.Address,
+				Name:     d.Soltype.Name,
+				Original: compact(d.Original),
+				Dirty:    compact(d.Dirty),
+			})
+			continue
+		}
+		for _, raw := range d.Raw {
+			result.StateChanges = append(result.StateChanges, StateChange{
+				Address:  d.Address,
+				Slot:     raw.Key,
+				Original: raw.Original,
+				Dirty:    raw.Dirty,
+			})
+		}
+	}
+	return result, nil
+}
+
+// compact renders a decoded JSON value on one line, unquoting plain strings.
+func compact(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var buf bytes.Buffer
+	if json.Compact(&buf, raw) != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
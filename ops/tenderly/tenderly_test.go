@@ -0,0 +1,72 @@
+package tenderly
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+)
+
+const response = `{
+	"simulation": {"id": "sim-1"},
+	"transaction": {
+		"status": false,
+		"error_message": "execution reverted",
+		"gas_used": 21432,
+		"transaction_info": {
+			"logs": [{"raw": {"address": "0x0000000000000000000000000000000000000005", "topics": [], "data": "0x"}}],
+			"state_diff": [
+				{"address": "0x0000000000000000000000000000000000000005", "soltype": {"name": "paused"}, "original": false, "dirty": true},
+				{"address": "0x0000000000000000000000000000000000000006", "raw": [{"key": "0x01", "original": "0x00", "dirty": "0x02"}]}
+			]
+		}
+	}
+}`
+
+func TestSimulate(t *testing.T) {
+	var got simulateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/account/acct/project/proj/simulate", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("X-Access-Key"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	os.Setenv("TENDERLY_TEST_KEY", "secret")
+	defer os.Unsetenv("TENDERLY_TEST_KEY")
+	c, err := New(Config{Account: "acct", Project: "proj", AccessKeyEnv: "TENDERLY_TEST_KEY", URL: server.URL})
+	require.NoError(t, err)
+
+	result, err := c.Simulate(context.Background(), 3, &chain.PendingTx{
+		Call:     chain.Call{Contract: &chain.Contract{Address: common.HexToAddress("0x5")}},
+		From:     common.HexToAddress("0x9"),
+		Gas:      50000,
+		GasPrice: big.NewInt(7),
+		Data:     []byte{0x84, 0x56, 0xcb, 0x59},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "3", got.NetworkID)
+	assert.Equal(t, "0x8456cb59", got.Input)
+	assert.Equal(t, "7", got.GasPrice)
+	assert.Equal(t, "0", got.Value)
+
+	assert.Equal(t, "sim-1", result.ID)
+	assert.False(t, result.Success)
+	assert.Equal(t, "execution reverted", result.Error)
+	assert.Len(t, result.Logs, 1)
+	assert.Equal(t, []StateChange{
+		{Address: common.HexToAddress("0x5"), Name: "paused", Original: "false", Dirty: "true"},
+		{Address: common.HexToAddress("0x6"), Slot: "0x01", Original: "0x00", Dirty: "0x02"},
+	}, result.StateChanges)
+}
@@ -0,0 +1,172 @@
+// Package timelock tracks admin operations that go through a Compound-style Timelock
+// contract, keeping the preimage of every operation we queue so that it can be executed with
+// exactly the calldata that was queued.
+//
+// The Timelock identifies an operation only by
+//
+//	keccak256(abi.encode(target, value, signature, data, eta))
+//
+// so without the preimage, a queued operation can neither be checked nor executed.
+package timelock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+)
+
+// ABI is the part of the Compound Timelock interface that the tools use.
+const ABI = `[
+	{"type":"function","name":"admin","constant":true,"inputs":[],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"delay","constant":true,"inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"GRACE_PERIOD","constant":true,"inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"queuedTransactions","constant":true,"inputs":[{"name":"","type":"bytes32"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"queueTransaction","constant":false,"inputs":[
+		{"name":"target","type":"address"},{"name":"value","type":"uint256"},{"name":"signature","type":"string"},
+		{"name":"data","type":"bytes"},{"name":"eta","type":"uint256"}],"outputs":[{"name":"","type":"bytes32"}]},
+	{"type":"function","name":"cancelTransaction","constant":false,"inputs":[
+		{"name":"target","type":"address"},{"name":"value","type":"uint256"},{"name":"signature","type":"string"},
+		{"name":"data","type":"bytes"},{"name":"eta","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"executeTransaction","constant":false,"payable":true,"inputs":[
+		{"name":"target","type":"address"},{"name":"value","type":"uint256"},{"name":"signature","type":"string"},
+		{"name":"data","type":"bytes"},{"name":"eta","type":"uint256"}],"outputs":[{"name":"","type":"bytes"}]}
+]`
+
+// Artifact returns an artifact for the Timelock interface, named "Timelock".
+func Artifact() *chain.Artifact {
+	parsed, err := abi.JSON(strings.NewReader(ABI))
+	if err != nil {
+		panic(err)
+	}
+	return &chain.Artifact{Name: "Timelock", ABI: parsed, ABIJSON: ABI}
+}
+
+// Outcomes of an operation, once it is no longer queued.
+const (
+	OutcomeExecuted  = "executed"
+	OutcomeCancelled = "cancelled"
+)
+
+// Operation is the preimage of one queued Timelock transaction, plus what we know about it.
+type Operation struct {
+	Target    common.Address `json:"target"`
+	Value     *big.Int       `json:"value"`
+	Signature string         `json:"signature"`
+	Data      hexutil.Bytes  `json:"data"`
+	ETA       uint64         `json:"eta"`
+
+	// Description is the call in readable form, e.g. "Manager.setEmergency(true)".
+	Description string `json:"description"`
+
+	QueueTx   string `json:"queueTx,omitempty"`
+	Outcome   string `json:"outcome,omitempty"`
+	OutcomeTx string `json:"outcomeTx,omitempty"`
+}
+
+var hashArgs = func() abi.Arguments {
+	types := []string{"address", "uint256", "string", "bytes", "uint256"}
+	args := make(abi.Arguments, len(types))
+	for i, name := range types {
+		t, err := abi.NewType(name, nil)
+		if err != nil {
+			panic(err)
+		}
+		args[i] = abi.Argument{Type: t}
+	}
+	return args
+}()
+
+// Args are the arguments that identify o to queueTransaction, executeTransaction, and
+// cancelTransaction.
+func (o *Operation) Args() []interface{} {
+	value := o.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	return []interface{}{o.Target, value, o.Signature, []byte(o.Data), new(big.Int).SetUint64(o.ETA)}
+}
+
+// Hash is the Timelock's identifier for o.
+func (o *Operation) Hash() common.Hash {
+	encoded, err := hashArgs.Pack(o.Args()...)
+	if err != nil {
+		panic(err) // the argument types are fixed, so packing cannot fail
+	}
+	return crypto.Keccak256Hash(encoded)
+}
+
+// Calldata is what the Timelock will send to Target when o is executed.
+func (o *Operation) Calldata() []byte {
+	if o.Signature == "" {
+		return append([]byte(nil), o.Data...)
+	}
+	return append(crypto.Keccak256([]byte(o.Signature))[:4], o.Data...)
+}
+
+// Store is a file of operation preimages, keyed by hash.
+type Store struct {
+	path string
+	ops  map[common.Hash]*Operation
+}
+
+// OpenStore loads the preimage store at path. A missing file is an empty store.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, ops: make(map[common.Hash]*Operation)}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading timelock preimages")
+	}
+	if err := json.Unmarshal(b, &s.ops); err != nil {
+		return nil, errors.Wrapf(err, "parsing timelock preimages %v", path)
+	}
+	for hash, op := range s.ops {
+		if op.Hash() != hash {
+			return nil, errors.Errorf("%v: preimage stored under %v hashes to %v", path, hash.Hex(), op.Hash().Hex())
+		}
+	}
+	return s, nil
+}
+
+// Get returns the operation with the given hash, or nil.
+func (s *Store) Get(hash common.Hash) *Operation {
+	return s.ops[hash]
+}
+
+// Put adds or updates op, and writes the store to disk.
+func (s *Store) Put(op *Operation) error {
+	s.ops[op.Hash()] = op
+	b, err := json.MarshalIndent(s.ops, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding timelock preimages")
+	}
+	tmp := filepath.Join(filepath.Dir(s.path), "."+filepath.Base(s.path)+".tmp")
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return errors.Wrap(err, "writing timelock preimages")
+	}
+	return errors.Wrap(os.Rename(tmp, s.path), "writing timelock preimages")
+}
+
+// All returns every stored operation, ordered by ETA.
+func (s *Store) All() []*Operation {
+	result := make([]*Operation, 0, len(s.ops))
+	for _, op := range s.ops {
+		result = append(result, op)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ETA < result[j].ETA })
+	return result
+}
@@ -0,0 +1,78 @@
+package timelock
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOperation() *Operation {
+	return &Operation{
+		Target:      common.HexToAddress("0x4B481872f31bab47C6780D5488c84D309b1B8Bb6"),
+		Value:       big.NewInt(0),
+		Signature:   "setEmergency(bool)",
+		Data:        common.LeftPadBytes([]byte{1}, 32),
+		ETA:         1600000000,
+		Description: "Manager.setEmergency(true)",
+	}
+}
+
+func TestCalldata(t *testing.T) {
+	op := testOperation()
+	calldata := op.Calldata()
+	assert.Equal(t, crypto.Keccak256([]byte(op.Signature))[:4], calldata[:4])
+	assert.Equal(t, []byte(op.Data), calldata[4:])
+}
+
+func TestHashCoversEveryField(t *testing.T) {
+	base := testOperation().Hash()
+	changes := []func(*Operation){
+		func(o *Operation) { o.Target = common.HexToAddress("0x1") },
+		func(o *Operation) { o.Value = big.NewInt(1) },
+		func(o *Operation) { o.Signature = "setEmergency(uint256)" },
+		func(o *Operation) { o.Data = common.LeftPadBytes([]byte{0}, 32) },
+		func(o *Operation) { o.ETA++ },
+	}
+	for i, change := range changes {
+		op := testOperation()
+		change(op)
+		assert.NotEqual(t, base, op.Hash(), "change %v", i)
+	}
+
+	op := testOperation()
+	op.Description, op.QueueTx = "something else", "0x1234"
+	assert.Equal(t, base, op.Hash(), "bookkeeping fields are not part of the hash")
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timelock")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "preimages.json")
+
+	s, err := OpenStore(path)
+	require.NoError(t, err)
+	op := testOperation()
+	require.NoError(t, s.Put(op))
+
+	s, err = OpenStore(path)
+	require.NoError(t, err)
+	require.NotNil(t, s.Get(op.Hash()))
+	assert.Equal(t, op.Description, s.Get(op.Hash()).Description)
+
+	// A preimage edited after the fact no longer matches its hash, and is refused.
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	tampered := strings.Replace(string(b), "1600000000", "1600000001", 1)
+	require.NoError(t, ioutil.WriteFile(path, []byte(tampered), 0644))
+	_, err = OpenStore(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,62 @@
+// Package units converts between human-readable decimal token amounts and the integer quanta
+// that contracts deal in (e.g. RSV and attoRSV).
+package units
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Parse converts a decimal string like "1.5" into quanta of a token with the given number of
+// decimals. It rejects negative amounts and amounts more precise than the token allows.
+func Parse(s string, decimals uint8) (*big.Int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.New("empty amount")
+	}
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if len(frac) > int(decimals) {
+		return nil, errors.Errorf("amount %q has more than %v decimal places", s, decimals)
+	}
+	digits := whole + frac + strings.Repeat("0", int(decimals)-len(frac))
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return nil, errors.Errorf("invalid amount %q", s)
+		}
+	}
+	n, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, errors.Errorf("invalid amount %q", s)
+	}
+	return n, nil
+}
+
+// Format renders quanta of a token with the given number of decimals as a decimal string,
+// without trailing zeroes.
+func Format(n *big.Int, decimals uint8) string {
+	if n == nil {
+		return "0"
+	}
+	neg := n.Sign() < 0
+	digits := new(big.Int).Abs(n).String()
+	if len(digits) <= int(decimals) {
+		digits = strings.Repeat("0", int(decimals)-len(digits)+1) + digits
+	}
+	whole, frac := digits[:len(digits)-int(decimals)], strings.TrimRight(digits[len(digits)-int(decimals):], "0")
+	s := whole
+	if frac != "" {
+		s += "." + frac
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
@@ -0,0 +1,42 @@
+package units
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in       string
+		decimals uint8
+		want     string
+	}{
+		{"1", 18, "1000000000000000000"},
+		{"1.5", 18, "1500000000000000000"},
+		{"0.000001", 6, "1"},
+		{".25", 2, "25"},
+		{"100", 0, "100"},
+	}
+	for _, c := range cases {
+		got, err := Parse(c.in, c.decimals)
+		require.NoError(t, err, c.in)
+		assert.Equal(t, c.want, got.String(), c.in)
+	}
+
+	for _, bad := range []string{"", "-1", "1.0000001", "1e18", "1,000", "0x10"} {
+		_, err := Parse(bad, 6)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	n, _ := new(big.Int).SetString("1500000000000000000", 10)
+	assert.Equal(t, "1.5", Format(n, 18))
+	assert.Equal(t, "0.000001", Format(big.NewInt(1), 6))
+	assert.Equal(t, "0", Format(big.NewInt(0), 6))
+	assert.Equal(t, "100", Format(big.NewInt(100), 0))
+	assert.Equal(t, "-0.5", Format(big.NewInt(-50), 2))
+}
@@ -0,0 +1,364 @@
+// Package upgrade plans upgrades of a deployment, and the rollback of each one.
+//
+// An upgrade plan is a list of admin calls that move the deployment onto contracts that have
+// already been deployed (with rsvdeploy), followed by the manifest entries to re-point:
+//
+//	{
+//	    "description": "Reserve 2.1 -> 2.2",
+//	    "steps": [
+//	        {"contract": "Reserve", "method": "nominateNewOwner", "args": ["@ReserveV2"]},
+//	        {"contract": "ReserveV2", "method": "acceptUpgrade", "args": ["@Reserve"]},
+//	        {"contract": "ReserveV2", "method": "changeMinter", "args": ["@Manager"]},
+//	        {"contract": "Relayer", "method": "setRSV", "args": ["@ReserveV2"]}
+//	    ],
+//	    "manifest": {"Reserve": "@ReserveV2"}
+//	}
+//
+// Before anything is sent, Inverse works out the rollback: the calls that put back every
+// parameter the plan changes, as read from the chain. A Reserve whose eternal storage has been
+// taken over by acceptUpgrade can't be revived, since it renounces its ownership, so the
+// rollback instead deploys a fresh instance of its code, moves the eternal storage to it, and
+// gives it the old Reserve's roles. A Reserve behind a ReserveProxy is instead upgraded in place,
+// with upgradeTo, and rolled back by upgrading it back to the implementation it had. A plan
+// with a step that has no known inverse is refused.
+package upgrade
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/manifest"
+	"github.com/reserve-protocol/rsv-beta/ops/params"
+)
+
+// Step is one transaction of a plan: a call, or (in rollbacks only) a deployment.
+//
+// Arguments are strings, parsed according to the method they are passed to (see
+// chain.ParseArgs). An argument "@Name" stands for the manifest address of contract Name.
+type Step struct {
+	Contract string   `json:"contract,omitempty"`
+	Method   string   `json:"method,omitempty"`
+	Args     []string `json:"args,omitempty"`
+
+	// Deploy, if set, is the artifact to deploy, with no constructor arguments, recorded in the
+	// manifest as As. The artifact's deployed code must match that of the contract at Matches.
+	Deploy  string `json:"deploy,omitempty"`
+	As      string `json:"as,omitempty"`
+	Matches string `json:"matches,omitempty"`
+
+	// Undoes is the step of the upgrade plan, counting from 1, that a rollback step undoes.
+	Undoes int `json:"undoes,omitempty"`
+
+	// Layout, for an upgradeTo or upgradeToAndCall, is the layout file of the implementation
+	// that the proxy has before the step, as `rsvlayout extract` committed it when that
+	// implementation was released. The new implementation is checked against it, rather than
+	// against the artifact that matches the deployed code, which needs none to match.
+	Layout string `json:"layout,omitempty"`
+}
+
+func (s Step) String() string {
+	if s.Deploy != "" {
+		return fmt.Sprintf("deploy %v as %v", s.Deploy, s.As)
+	}
+	return fmt.Sprintf("%v.%v(%v)", s.Contract, s.Method, strings.Join(s.Args, ", "))
+}
+
+// Plan is an upgrade plan.
+type Plan struct {
+	Description string `json:"description,omitempty"`
+	Steps       []Step `json:"steps"`
+
+	// Manifest maps contract names to the "@Name" reference or address to record for them once
+	// every step is done.
+	Manifest map[string]string `json:"manifest,omitempty"`
+}
+
+// Load reads an upgrade plan.
+func Load(path string) (*Plan, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading upgrade plan")
+	}
+	var p Plan
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, errors.Wrapf(err, "parsing upgrade plan %v", path)
+	}
+	if len(p.Steps) == 0 {
+		return nil, errors.Errorf("upgrade plan %v has no steps", path)
+	}
+	for i, s := range p.Steps {
+		if s.Deploy != "" {
+			return nil, errors.Errorf("%v: step %v deploys a contract; deploy new contracts with rsvdeploy first", path, i+1)
+		}
+		if s.Contract == "" || s.Method == "" {
+			return nil, errors.Errorf("%v: step %v needs a contract and a method", path, i+1)
+		}
+		if s.Layout != "" && s.Method != "upgradeTo" && s.Method != "upgradeToAndCall" {
+			return nil, errors.Errorf("%v: step %v has a layout, but only upgradeTo and upgradeToAndCall take one", path, i+1)
+		}
+		if s.Layout != "" && !strings.HasSuffix(s.Layout, ".json") {
+			return nil, errors.Errorf("%v: step %v: the layout %v is not a .json file", path, i+1, s.Layout)
+		}
+	}
+	return &p, nil
+}
+
+// Record is a manifest entry that a rollback restores.
+type Record struct {
+	Name    string `json:"name"`
+	Address string `json:"address"` // an address, or "@Name"
+
+	// Undoes is as for Step; 0 means that the record applies however far the upgrade got.
+	Undoes int `json:"undoes,omitempty"`
+}
+
+// Rollback is the inverse of an upgrade plan.
+type Rollback struct {
+	// For is the path of the upgrade plan.
+	For string `json:"for"`
+
+	// Executed is how many of the upgrade plan's steps have been carried out. Only the rollback
+	// steps that undo those are run.
+	Executed int `json:"executed"`
+
+	// Steps are in the order that they run: deployments first, then the inverses of the
+	// upgrade's steps, last step first.
+	Steps    []Step   `json:"steps"`
+	Manifest []Record `json:"manifest"`
+
+	// RolledBack is how many of the Pending steps have been carried out.
+	RolledBack int `json:"rolledBack"`
+}
+
+// LoadRollback reads a rollback plan.
+func LoadRollback(path string) (*Rollback, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading rollback plan")
+	}
+	var r Rollback
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, errors.Wrapf(err, "parsing rollback plan %v", path)
+	}
+	return &r, nil
+}
+
+// Save writes the rollback plan to path.
+func (r *Rollback) Save(path string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding rollback plan")
+	}
+	return errors.Wrap(ioutil.WriteFile(path, append(b, '\n'), 0644), "writing rollback plan")
+}
+
+// Pending returns the rollback steps that undo executed upgrade steps.
+func (r *Rollback) Pending() []Step {
+	var steps []Step
+	for _, s := range r.Steps {
+		if s.Undoes <= r.Executed {
+			steps = append(steps, s)
+		}
+	}
+	return steps
+}
+
+// PendingRecords returns the manifest records that apply, given how far the upgrade got.
+func (r *Rollback) PendingRecords() []Record {
+	var records []Record
+	for _, rec := range r.Manifest {
+		if rec.Undoes <= r.Executed {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+// reserveRoles are the Reserve parameters that a replacement Reserve is given, in order.
+func reserveRoles() []params.Param {
+	var roles []params.Param
+	for _, p := range params.Params {
+		if p.Contract == "Reserve" {
+			roles = append(roles, p)
+		}
+	}
+	return roles
+}
+
+// Inverse returns the rollback of p, reading the current state of the deployment in m with r.
+// signer is the account that will send the rollback, and so the owner of anything it deploys.
+func Inverse(ctx context.Context, r params.Reader, m *manifest.Manifest, p *Plan, signer common.Address) (*Rollback, error) {
+	// Parameter values as they will stand before each step.
+	state := make(map[string]string)
+	read := func(contract, getter, kind string) (string, error) {
+		key := contract + "." + getter
+		if v, ok := state[key]; ok {
+			return v, nil
+		}
+		v, err := r.Read(ctx, contract, getter, kind)
+		state[key] = v
+		return v, errors.Wrapf(err, "reading %v", key)
+	}
+
+	// First find the upgrades: the contracts that the upgrade retires, which the rollback
+	// replaces, and the contracts that the rollback retires in turn.
+	replacement := make(map[string]string) // retired address -> name of its replacement
+	retiredByRollback := make(map[string]bool)
+	used := make(map[string]bool)
+	for name := range m.Contracts {
+		used[name] = true
+	}
+	retiredByUpgrade := make(map[string]bool)
+	previousNames := make(map[int]string) // acceptUpgrade step -> name of the retired Reserve
+	for i, s := range p.Steps {
+		if s.Method != "acceptUpgrade" {
+			continue
+		}
+		if len(s.Args) != 1 {
+			return nil, errors.Errorf("step %v: acceptUpgrade takes one argument", i+1)
+		}
+		previous, err := address(m, s.Args[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "step %v", i+1)
+		}
+		previousName, ok := strings.TrimPrefix(s.Args[0], "@"), strings.HasPrefix(s.Args[0], "@")
+		if !ok {
+			if previousName, ok = m.NameOf(previous); !ok {
+				return nil, errors.Errorf("step %v: %v is not in the manifest, so its code can't be redeployed", i+1, previous.Hex())
+			}
+		}
+		previousNames[i] = previousName
+		name := previousName + "Rollback"
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%vRollback%v", previousName, n)
+		}
+		used[name] = true
+		replacement[previous.Hex()] = name
+		retiredByUpgrade[previousName] = true
+		retiredByRollback[s.Contract] = true
+	}
+	// restore returns the argument that puts back value, which may be a retired contract.
+	restore := func(value string) string {
+		if name, ok := replacement[value]; ok {
+			return "@" + name
+		}
+		return value
+	}
+
+	rollback := &Rollback{}
+	var deploys []Step
+	inverses := make([][]Step, len(p.Steps))
+	for i, s := range p.Steps {
+		undoes := i + 1
+		switch {
+		case s.Method == "acceptUpgrade":
+			previous, _ := address(m, s.Args[0])
+			previousName := previousNames[i]
+			name := replacement[previous.Hex()]
+			deploys = append(deploys, Step{Deploy: previousName, As: name, Matches: previous.Hex(), Undoes: undoes})
+			steps := []Step{
+				{Contract: s.Contract, Method: "nominateNewOwner", Args: []string{"@" + name}},
+				{Contract: name, Method: "acceptUpgrade", Args: []string{"@" + s.Contract}},
+			}
+			// The replacement gets the roles that the old Reserve had before the upgrade.
+			for _, role := range reserveRoles() {
+				v, err := r.Read(ctx, previousName, role.Name, role.Kind)
+				if err != nil {
+					return nil, err
+				}
+				steps = append(steps, Step{Contract: name, Method: role.Setter, Args: []string{restore(v)}})
+			}
+			owner, err := r.Read(ctx, previousName, "owner", params.Address)
+			if err != nil {
+				return nil, err
+			}
+			if owner != signer.Hex() {
+				steps = append(steps, Step{Contract: name, Method: "nominateNewOwner", Args: []string{owner}})
+			}
+			for j := range steps {
+				steps[j].Undoes = undoes
+			}
+			inverses[i] = steps
+			rollback.Manifest = append(rollback.Manifest, Record{Name: previousName, Address: "@" + name, Undoes: undoes})
+		case retiredByUpgrade[s.Contract] || retiredByRollback[s.Contract]:
+			// Calls to a Reserve that the upgrade retires (such as the nomination that
+			// acceptUpgrade needs) can't be undone on it, and calls to one that the rollback
+			// retires don't matter; either way the replacement is set up from scratch.
+		case s.Method == "upgradeTo":
+			if len(s.Args) != 1 {
+				return nil, errors.Errorf("step %v: upgradeTo takes one argument", i+1)
+			}
+			current, err := read(s.Contract, "implementation", params.Address)
+			if err != nil {
+				return nil, err
+			}
+			if current == (common.Address{}).Hex() {
+				return nil, errors.Errorf("step %v: %v is not behind a proxy", i+1, s.Contract)
+			}
+			desired, err := address(m, s.Args[0])
+			if err != nil {
+				return nil, errors.Wrapf(err, "step %v", i+1)
+			}
+			state[s.Contract+".implementation"] = desired.Hex()
+			inverses[i] = []Step{{Contract: s.Contract, Method: s.Method, Args: []string{current}, Undoes: undoes}}
+		case params.BySetter(s.Method) != nil:
+			param := params.BySetter(s.Method)
+			if len(s.Args) != 1 {
+				return nil, errors.Errorf("step %v: %v takes one argument", i+1, s.Method)
+			}
+			current, err := read(s.Contract, param.Name, param.Kind)
+			if err != nil {
+				return nil, err
+			}
+			desired, err := params.Normalize(m, param.Kind, s.Args[0])
+			if err != nil {
+				return nil, errors.Wrapf(err, "step %v", i+1)
+			}
+			state[s.Contract+"."+param.Name] = desired
+			inverses[i] = []Step{{Contract: s.Contract, Method: s.Method, Args: []string{restore(current)}, Undoes: undoes}}
+		default:
+			return nil, errors.Errorf("step %v: don't know how to undo %v, so the upgrade can't be rolled back", i+1, s)
+		}
+	}
+
+	rollback.Steps = deploys
+	for i := len(inverses) - 1; i >= 0; i-- {
+		rollback.Steps = append(rollback.Steps, inverses[i]...)
+	}
+
+	// Put back the manifest entries that the plan re-points, unless a replacement has
+	// already been recorded for them.
+	recorded := make(map[string]bool)
+	for _, rec := range rollback.Manifest {
+		recorded[rec.Name] = true
+	}
+	names := make([]string, 0, len(p.Manifest))
+	for name := range p.Manifest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if recorded[name] {
+			continue
+		}
+		previous, err := m.Address(name)
+		if err != nil {
+			return nil, errors.Wrap(err, "plan manifest")
+		}
+		rollback.Manifest = append(rollback.Manifest, Record{Name: name, Address: restore(previous.Hex())})
+	}
+	return rollback, nil
+}
+
+// address resolves a step argument that must be an address.
+func address(m *manifest.Manifest, arg string) (common.Address, error) {
+	v, err := params.Normalize(m, params.Address, arg)
+	return common.HexToAddress(v), err
+}
@@ -0,0 +1,183 @@
+package upgrade
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/manifest"
+)
+
+var (
+	signer    = common.HexToAddress("0x000000000000000000000000000000000000a0a0")
+	reserve   = common.HexToAddress("0x0000000000000000000000000000000000000001")
+	reserveV2 = common.HexToAddress("0x0000000000000000000000000000000000000002")
+	relayer   = common.HexToAddress("0x0000000000000000000000000000000000000003")
+	mgr       = common.HexToAddress("0x0000000000000000000000000000000000000004")
+	managerV2 = common.HexToAddress("0x0000000000000000000000000000000000000005")
+	vault     = common.HexToAddress("0x0000000000000000000000000000000000000006")
+	pauser    = common.HexToAddress("0x0000000000000000000000000000000000000007")
+	implV1    = common.HexToAddress("0x0000000000000000000000000000000000000008")
+	implV2    = common.HexToAddress("0x0000000000000000000000000000000000000009")
+)
+
+type fakeReader map[string]string
+
+func (r fakeReader) Read(ctx context.Context, contract, getter, kind string, args ...interface{}) (string, error) {
+	v, ok := r[contract+"."+getter]
+	if !ok {
+		return "", errors.Errorf("no %v.%v", contract, getter)
+	}
+	return v, nil
+}
+
+func testManifest() *manifest.Manifest {
+	return &manifest.Manifest{Network: "test", Contracts: map[string]common.Address{
+		"Reserve": reserve, "ReserveV2": reserveV2, "Relayer": relayer,
+		"Manager": mgr, "ManagerV2": managerV2, "Vault": vault,
+	}}
+}
+
+func testReader() fakeReader {
+	zero := common.Address{}.Hex()
+	return fakeReader{
+		"Reserve.owner":          signer.Hex(),
+		"Reserve.trustedTxFee":   zero,
+		"Reserve.trustedRelayer": relayer.Hex(),
+		"Reserve.maxSupply":      "1000",
+		"Reserve.feeRecipient":   signer.Hex(),
+		"Reserve.minter":         mgr.Hex(),
+		"Reserve.pauser":         pauser.Hex(),
+		"Relayer.trustedRSV":     reserve.Hex(),
+		"Vault.manager":          mgr.Hex(),
+	}
+}
+
+func testPlan() *Plan {
+	return &Plan{
+		Steps: []Step{
+			{Contract: "Reserve", Method: "nominateNewOwner", Args: []string{"@ReserveV2"}},
+			{Contract: "ReserveV2", Method: "acceptUpgrade", Args: []string{"@Reserve"}},
+			{Contract: "ReserveV2", Method: "changeMinter", Args: []string{"@ManagerV2"}},
+			{Contract: "Relayer", Method: "setRSV", Args: []string{"@ReserveV2"}},
+			{Contract: "Vault", Method: "changeManager", Args: []string{"@ManagerV2"}},
+		},
+		Manifest: map[string]string{"Reserve": "@ReserveV2", "Manager": "@ManagerV2"},
+	}
+}
+
+func TestInverse(t *testing.T) {
+	r, err := Inverse(context.Background(), testReader(), testManifest(), testPlan(), signer)
+	require.NoError(t, err)
+
+	var got []string
+	for _, s := range r.Steps {
+		got = append(got, s.String())
+	}
+	assert.Equal(t, []string{
+		"deploy Reserve as ReserveRollback",
+		"Vault.changeManager(" + mgr.Hex() + ")",
+		"Relayer.setRSV(@ReserveRollback)",
+		"ReserveV2.nominateNewOwner(@ReserveRollback)",
+		"ReserveRollback.acceptUpgrade(@ReserveV2)",
+		"ReserveRollback.changeTxFeeHelper(" + common.Address{}.Hex() + ")",
+		"ReserveRollback.changeRelayer(" + relayer.Hex() + ")",
+		"ReserveRollback.changeMaxSupply(1000)",
+		"ReserveRollback.changeFeeRecipient(" + signer.Hex() + ")",
+		"ReserveRollback.changeMinter(" + mgr.Hex() + ")",
+		"ReserveRollback.changePauser(" + pauser.Hex() + ")",
+	}, got)
+	assert.Equal(t, reserve.Hex(), r.Steps[0].Matches)
+	assert.Equal(t, []Record{
+		{Name: "Reserve", Address: "@ReserveRollback", Undoes: 2},
+		{Name: "Manager", Address: mgr.Hex()},
+	}, r.Manifest)
+
+	// Only what was done is undone.
+	r.Executed = 1
+	assert.Empty(t, r.Pending())
+	assert.Len(t, r.PendingRecords(), 1)
+	r.Executed = 4
+	assert.Len(t, r.Pending(), 10)
+	assert.Len(t, r.PendingRecords(), 2)
+}
+
+func TestInverseRestoresOwner(t *testing.T) {
+	reader := testReader()
+	reader["Reserve.owner"] = pauser.Hex()
+	r, err := Inverse(context.Background(), reader, testManifest(), testPlan(), signer)
+	require.NoError(t, err)
+	last := r.Steps[len(r.Steps)-1]
+	assert.Equal(t, "ReserveRollback.nominateNewOwner("+pauser.Hex()+")", last.String())
+}
+
+func TestInverseRefuses(t *testing.T) {
+	p := testPlan()
+	p.Steps = append(p.Steps, Step{Contract: "Manager", Method: "renounceOwnership", Args: []string{"I hereby renounce"}})
+	_, err := Inverse(context.Background(), testReader(), testManifest(), p, signer)
+	assert.Error(t, err)
+
+	// Vault.manager can't be read.
+	reader := testReader()
+	delete(reader, "Vault.manager")
+	_, err = Inverse(context.Background(), reader, testManifest(), testPlan(), signer)
+	assert.Error(t, err)
+}
+
+func TestInverseOfProxyUpgrade(t *testing.T) {
+	m := testManifest()
+	m.Contracts["ReserveImplV2"] = implV2
+	reader := testReader()
+	reader["Reserve.implementation"] = implV1.Hex()
+	p := &Plan{Steps: []Step{
+		{Contract: "Reserve", Method: "upgradeTo", Args: []string{"@ReserveImplV2"}},
+		{Contract: "Reserve", Method: "changeMinter", Args: []string{"@ManagerV2"}},
+		{Contract: "Reserve", Method: "upgradeTo", Args: []string{implV1.Hex()}},
+	}}
+
+	// The proxy keeps its address, so the rollback just upgrades back, step by step.
+	r, err := Inverse(context.Background(), reader, m, p, signer)
+	require.NoError(t, err)
+	var got []string
+	for _, s := range r.Steps {
+		got = append(got, s.String())
+	}
+	assert.Equal(t, []string{
+		"Reserve.upgradeTo(" + implV2.Hex() + ")",
+		"Reserve.changeMinter(" + mgr.Hex() + ")",
+		"Reserve.upgradeTo(" + implV1.Hex() + ")",
+	}, got)
+	assert.Empty(t, r.Manifest)
+
+	// A Reserve that isn't behind a proxy can't be upgraded in place.
+	reader["Reserve.implementation"] = common.Address{}.Hex()
+	_, err = Inverse(context.Background(), reader, m, p, signer)
+	assert.Error(t, err)
+}
+
+func TestLoadLayout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upgrade")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	load := func(plan string) (*Plan, error) {
+		path := filepath.Join(dir, "plan.json")
+		require.NoError(t, ioutil.WriteFile(path, []byte(plan), 0644))
+		return Load(path)
+	}
+
+	p, err := load(`{"steps": [{"contract": "Reserve", "method": "upgradeTo", "args": ["@ReserveImplV2"], "layout": "layouts/Reserve.json"}]}`)
+	require.NoError(t, err)
+	assert.Equal(t, "layouts/Reserve.json", p.Steps[0].Layout)
+
+	_, err = load(`{"steps": [{"contract": "Reserve", "method": "changeMinter", "args": ["@Manager"], "layout": "layouts/Reserve.json"}]}`)
+	assert.Error(t, err)
+	_, err = load(`{"steps": [{"contract": "Reserve", "method": "upgradeTo", "args": ["@ReserveImplV2"], "layout": "Reserve"}]}`)
+	assert.Error(t, err)
+}
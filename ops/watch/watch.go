@@ -0,0 +1,341 @@
+// Package watch follows the chain for privileged events of the deployment's contracts, such as
+// ownership transfers, pauses, role changes, and basket proposals, and posts a readable message
+// for each one as soon as it is mined.
+//
+// Events come from a stream.Stream. If a reorganization orphans an event already posted, a
+// retraction is posted. The watcher keeps its stream state, and the events of the current batch
+// not yet posted, in a state file, so that a restart neither misses an event nor posts one twice.
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/indexer"
+	"github.com/reserve-protocol/rsv-beta/ops/stream"
+)
+
+// Messages are the privileged events watched by default, with how to describe them. Each
+// {name} is replaced by the event argument of that name.
+var Messages = map[string]string{
+	"OwnershipTransferred":       "ownership transferred from {previousOwner} to {newOwner}",
+	"NewOwnerNominated":          "{nominee} nominated as the next owner by {previousOwner}",
+	"NominationExpired":          "nomination of {nominee} as the next owner expired",
+	"NominationPeriodChanged":    "ownership nominations now expire after {newPeriod} seconds",
+	"Paused":                     "paused by {account}",
+	"Unpaused":                   "unpaused by {account}",
+	"TransfersPaused":            "transfers paused by {account}",
+	"TransfersUnpaused":          "transfers unpaused by {account}",
+	"EmergencyRedemptionStarted": "emergency redemption started by {account}",
+	"Upgraded":                   "implementation upgraded to {implementation}",
+	"MinterChanged":              "minter changed to {newMinter}",
+	"PauserChanged":              "pauser changed to {newPauser}",
+	"GuardianChanged":            "guardian changed to {newGuardian}",
+	"FreezerChanged":             "freezer changed to {newFreezer}",
+	"AddressFrozen":              "{account} frozen",
+	"AddressUnfrozen":            "{account} unfrozen",
+	"WiperChanged":               "wiper changed to {newWiper}",
+	"WipeProposed":               "wipe of the frozen {account} proposed, ready at {readyAt}",
+	"WipeCanceled":               "wipe of {account} canceled",
+	"FrozenBalanceWiped":         "{value} attoRSV of the frozen {account} wiped by {wipedBy}",
+	"FeeRecipientChanged":        "fee recipient changed to {newFeeRecipient}",
+	"SnapshotterChanged":         "snapshotter changed to {newSnapshotter}",
+	"MaxSupplyChanged":           "max supply changed to {newMaxSupply}",
+	"MintCapChanged":             "mint cap changed to {newMintCap} attoRSV a day",
+	"FlashMintCapChanged":        "flash mint cap changed to {newFlashMintCap} attoRSV a loan",
+	"FlashMintFeeChanged":        "flash mint fee changed to {newFlashMintFee} BPS",
+	"TxFeeHelperChanged":         "fee helper changed to {newTxFeeHelper}",
+	"TrustedRelayerChanged":      "relayer changed to {newTrustedRelayer}",
+	"TrustedForwarderChanged":    "EIP-2771 forwarder changed to {newTrustedForwarder}",
+	"TransferHookChanged":        "transfer hook changed to {newTransferHook}",
+	"ChainIdChanged":             "permit chain ID changed to {newChainId}",
+	"EternalStorageTransferred":  "eternal storage transferred to {newReserveAddress}",
+	"TokenSwept":                 "{amount} of {token} swept to {to}",
+
+	"OperatorAdded":                 "operator {account} added",
+	"OperatorRemoved":               "operator {account} removed",
+	"VetoerChanged":                 "vetoer changed from {oldAccount} to {newAccount}",
+	"IssuancePausedChanged":         "issuancePaused changed from {oldVal} to {newVal}",
+	"RedemptionPausedChanged":       "redemptionPaused changed from {oldVal} to {newVal}",
+	"EmergencyChanged":              "emergency changed from {oldVal} to {newVal}",
+	"VaultChanged":                  "vault changed from {oldVaultAddr} to {newVaultAddr}",
+	"DelayChanged":                  "proposal delay changed from {oldVal} to {newVal} seconds",
+	"ProposalValidityChanged":       "proposal validity changed from {oldVal} to {newVal} seconds",
+	"SeigniorageChanged":            "seigniorage changed from {oldVal} to {newVal} bps",
+	"RedemptionFeeChanged":          "redemption fee changed from {oldVal} to {newVal} bps",
+	"RedemptionFeeRecipientChanged": "redemption fee recipient changed from {oldAccount} to {newAccount}",
+	"IssuanceFeeChanged":            "issuance fee changed from {oldVal} to {newVal} bps",
+	"IssuanceFeeRecipientChanged":   "issuance fee recipient changed from {oldAccount} to {newAccount}",
+	"OracleChanged":                 "collateral oracle changed from {oldOracle} to {newOracle}",
+	"AuctionChanged":                "Dutch auction changed from {oldAuction} to {newAuction}",
+	"RegistryChanged":               "collateral registry changed from {oldRegistry} to {newRegistry}",
+	"Permit2Changed":                "Permit2 changed from {oldPermit2} to {newPermit2}",
+	"ExposureCapChanged":            "exposure cap of {token} changed from {oldVal} to {newVal} bps",
+	"ProposalsCleared":              "all proposals cleared",
+	"VaultTokenSwept":               "{amount} of {token} swept out of the Vault to {to}",
+	"WeightsProposed":               "proposal {id} by {proposer}: new weights {weights} for {tokens}",
+	"SwapProposed":                  "proposal {id} by {proposer}: swap {amounts} of {tokens} (to the Vault: {toVault})",
+	"RebalanceProposed":             "proposal {id} by {proposer}: exchange {portion} bps of {fromToken} for {toToken} at rate {rate}",
+	"ProposalAccepted":              "proposal {id} by {proposer} accepted",
+	"ProposalCanceled":              "proposal {id} by {proposer} cancelled by {canceler}",
+	"ProposalCancelled":             "proposal {id} withdrawn by its proposer {proposer}: {reason}",
+	"ProposalVetoed":                "proposal {id} by {proposer} vetoed by {vetoer}",
+	"ProposalExpired":               "proposal {id} by {proposer} expired",
+	"ProposalExecuted":              "proposal {id} by {proposer} executed by {executor}: basket {oldBasket} replaced by {newBasket}",
+
+	"ManagerTransferred": "manager transferred from {previousManager} to {newManager}",
+	"Withdrawal":         "withdrawal of {amount} of {token} to {to}",
+	"YieldSourceChanged": "yield source {source} approved: {approved}",
+	"YieldDeposited":     "{assets} deposited into the yield source {source}, for {shares} shares",
+	"YieldWithdrawn":     "{shares} shares withdrawn from the yield source {source}, for {assets}",
+
+	"AuctionStarted": "auction {id}: {sellAmount} of {sellToken} for {buyToken}, at prices falling from {startPrice} to {endPrice} until {end}",
+	"AuctionClosed":  "auction {id} closed, with {unsold} unsold",
+
+	"FeedChanged":               "price feed of {token} changed to {aggregator}, with a heartbeat of {heartbeat} seconds",
+	"MaxDeviationChanged":       "max price deviation changed from {oldVal} to {newVal} bps",
+	"ExchangeRateSourceChanged": "exchange rate source of {token} changed to {source}",
+
+	"CollateralApproved": "{token} approved as collateral, with {decimals} decimals, price feed {feed}, and a weight cap of {cap}",
+	"CollateralRemoved":  "{token} removed from the approved collateral",
+
+	"ManagerChanged": "manager changed from {oldManager} to {newManager}",
+	"VaultToppedUp":  "{amount} of {token} paid from the insurance fund to the Vault {vault}: {reason}",
+
+	"TargetsChanged":              "fee treasury targets changed to {targets} with shares {shares} (BPS)",
+	"DistributionIntervalChanged": "fee distribution interval changed from {oldVal} to {newVal} seconds",
+
+	"BridgeChanged":    "bridge operator changed from {oldBridge} to {newBridge}",
+	"MintLimitChanged": "bridge mint limit changed from {oldVal} to {newVal} attoRSV a day",
+	"BurnLimitChanged": "bridge burn limit changed from {oldVal} to {newVal} attoRSV a day",
+
+	"SetTrustedRemoteAddress": "OFT adapter's trusted remote on LayerZero chain {remoteChainId} set to {remoteAddress}",
+
+	"NewAdmin":           "admin changed to {newAdmin}",
+	"NewPendingAdmin":    "{newPendingAdmin} nominated as the next admin",
+	"NewDelay":           "delay changed to {newDelay} seconds",
+	"NewKeeper":          "keeper changed to {newKeeper}",
+	"QueueTransaction":   "transaction {txHash} queued, executable from {eta}: {target}.{signature} with {data}",
+	"CancelTransaction":  "transaction {txHash} cancelled: {target}.{signature} with {data}",
+	"ExecuteTransaction": "transaction {txHash} executed: {target}.{signature} with {data}",
+
+	"OperationScheduled": "transaction {txHash} scheduled for keepers, executable from {eta}",
+	"OperationDropped":   "transaction {txHash} dropped from the keepers' schedule",
+
+	"ActionExecuted":   "guardians executed action {action} for {account}, value {value}, at nonce {nonce}",
+	"GuardianAdded":    "guardian {guardian} added",
+	"GuardianRemoved":  "guardian {guardian} removed",
+	"ThresholdChanged": "guardian threshold changed from {oldVal} to {newVal}",
+}
+
+// Watcher posts a message for each watched event.
+type Watcher struct {
+	Node      stream.Node
+	Contracts []indexer.Contract
+
+	// Messages are the events to watch, by name, with how to describe them.
+	Messages map[string]string
+
+	// Post delivers a message, such as emergency.Notifier.Notify.
+	Post func(ctx context.Context, text string) error
+
+	// Label renders an address for the messages, such as ens.Namer.Label; by default, in hex.
+	Label func(ctx context.Context, addr common.Address) string
+
+	Network string
+
+	// Confirmations is how many blocks an event must be under before it is posted. Zero posts
+	// events as soon as they are mined, at the risk of having to retract one that a
+	// reorganization undoes.
+	Confirmations uint64
+
+	// StateFile keeps the watcher's position.
+	StateFile string
+
+	PollInterval time.Duration
+}
+
+// state is what the state file keeps: the stream state, and the logs of the batch that led to it
+// that are still to be posted.
+type state struct {
+	Stream  stream.State `json:"stream"`
+	Pending []types.Log  `json:"pending,omitempty"`
+}
+
+// Run watches until ctx is done.
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := w.Step(ctx); err != nil {
+			log.Printf("watch: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Step posts every watched event since the last Step. The first Step, with no state file yet,
+// starts at the head.
+func (w *Watcher) Step(ctx context.Context) error {
+	topics := w.topics()
+	if len(topics) == 0 {
+		return errors.New("the contracts emit none of the watched events")
+	}
+	addresses := make([]common.Address, len(w.Contracts))
+	for i, c := range w.Contracts {
+		addresses[i] = c.Address
+	}
+	s := &stream.Stream{
+		Node:          w.Node,
+		Addresses:     addresses,
+		Topics:        [][]common.Hash{topics},
+		Confirmations: w.Confirmations,
+	}
+
+	st, err := w.load()
+	if err != nil {
+		return err
+	}
+	if st == nil {
+		tip, err := s.Tip(ctx)
+		if err != nil {
+			return err
+		}
+		last, _ := tip.Last()
+		log.Printf("watch: starting after block %v", last)
+		st = &state{Stream: tip}
+		if err := w.save(*st); err != nil {
+			return err
+		}
+	}
+	for {
+		for len(st.Pending) > 0 {
+			l := st.Pending[0]
+			if err := w.Post(ctx, w.message(ctx, l)); err != nil {
+				return errors.Wrapf(err, "posting log %v of tx %v", l.Index, l.TxHash.Hex())
+			}
+			st.Pending = st.Pending[1:]
+			if err := w.save(*st); err != nil {
+				return err
+			}
+		}
+		b, err := s.Next(ctx, st.Stream)
+		if err != nil || b == nil {
+			return err
+		}
+		st = &state{Stream: b.State, Pending: b.Logs}
+		if err := w.save(*st); err != nil {
+			return err
+		}
+	}
+}
+
+// topics returns the topics of the watched events that the contracts have.
+func (w *Watcher) topics() []common.Hash {
+	seen := map[common.Hash]bool{}
+	var topics []common.Hash
+	for _, c := range w.Contracts {
+		for _, e := range c.Artifact.ABI.Events {
+			if _, ok := w.Messages[e.Name]; ok && !seen[e.Id()] {
+				seen[e.Id()] = true
+				topics = append(topics, e.Id())
+			}
+		}
+	}
+	return topics
+}
+
+var placeholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// message describes l, e.g. "RSV on mainnet: Reserve paused by 0x12... (block 8000000, tx 0x34...)".
+func (w *Watcher) message(ctx context.Context, l types.Log) string {
+	var contract indexer.Contract
+	for _, c := range w.Contracts {
+		if c.Address == l.Address {
+			contract = c
+		}
+	}
+	where := fmt.Sprintf("(block %v, tx %v)", l.BlockNumber, l.TxHash.Hex())
+	event, err := contract.Artifact.DecodeLog(l.Topics, l.Data)
+	if err != nil {
+		return fmt.Sprintf("RSV on %v: %v emitted an undecodable event %v: %v", w.Network, contract.Name, where, err)
+	}
+	args := map[string]interface{}{}
+	for i, name := range event.Names {
+		args[name] = event.Values[i]
+	}
+	text := placeholder.ReplaceAllStringFunc(w.Messages[event.Name], func(p string) string {
+		v, ok := args[p[1:len(p)-1]]
+		if !ok {
+			return p
+		}
+		return w.format(ctx, v)
+	})
+	if text == "" {
+		text = event.String()
+	}
+	if l.Removed {
+		return fmt.Sprintf("RSV on %v: RETRACTED, a reorganization undid: %v %v %v", w.Network, contract.Name, text, where)
+	}
+	return fmt.Sprintf("RSV on %v: %v %v %v", w.Network, contract.Name, text, where)
+}
+
+func (w *Watcher) format(ctx context.Context, v interface{}) string {
+	switch v := v.(type) {
+	case common.Address:
+		if w.Label != nil {
+			return w.Label(ctx, v)
+		}
+		return v.Hex()
+	case []common.Address:
+		parts := make([]string, len(v))
+		for i, a := range v {
+			parts[i] = w.format(ctx, a)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	return strings.Trim(chain.FormatArgs([]interface{}{v}), "()")
+}
+
+func (w *Watcher) load() (*state, error) {
+	data, err := ioutil.ReadFile(w.StateFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading state")
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, errors.Wrapf(err, "parsing %v", w.StateFile)
+	}
+	return &st, nil
+}
+
+func (w *Watcher) save(st state) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return errors.Wrap(err, "encoding state")
+	}
+	tmp := w.StateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return errors.Wrap(err, "writing state")
+	}
+	return errors.Wrap(os.Rename(tmp, w.StateFile), "writing state")
+}
@@ -0,0 +1,138 @@
+package watch
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/ops/chain"
+	"github.com/reserve-protocol/rsv-beta/ops/indexer"
+)
+
+const reserveABI = `[
+	{"anonymous":false,"type":"event","name":"Paused","inputs":[{"indexed":true,"name":"account","type":"address"}]},
+	{"anonymous":false,"type":"event","name":"MinterChanged","inputs":[{"indexed":true,"name":"newMinter","type":"address"}]},
+	{"anonymous":false,"type":"event","name":"Transfer","inputs":[
+		{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},
+		{"indexed":false,"name":"value","type":"uint256"}]}]`
+
+var (
+	reserve = common.HexToAddress("0x196f4727526eA7FB1e17b2071B3d8eAA38486988")
+	alice   = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+)
+
+// fakeNode serves logs, filtering them by block range and topic as a node would.
+type fakeNode struct {
+	head uint64
+	logs []types.Log
+}
+
+func header(number uint64) *types.Header {
+	return &types.Header{Number: new(big.Int).SetUint64(number)}
+}
+
+func (n *fakeNode) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		return header(n.head), nil
+	}
+	return header(number.Uint64()), nil
+}
+
+func (n *fakeNode) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	var result []types.Log
+	for _, l := range n.logs {
+		if l.BlockNumber < q.FromBlock.Uint64() || l.BlockNumber > q.ToBlock.Uint64() {
+			continue
+		}
+		for _, topic := range q.Topics[0] {
+			if l.Topics[0] == topic {
+				result = append(result, l)
+			}
+		}
+	}
+	return result, nil
+}
+
+func event(parsed abi.ABI, name string, block uint64, index uint, arg common.Address) types.Log {
+	return types.Log{
+		Address:     reserve,
+		BlockNumber: block,
+		Index:       index,
+		BlockHash:   header(block).Hash(),
+		TxHash:      common.BigToHash(big.NewInt(int64(block))),
+		Topics:      []common.Hash{parsed.Events[name].Id(), arg.Hash()},
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	parsed, err := abi.JSON(strings.NewReader(reserveABI))
+	require.NoError(t, err)
+
+	node := &fakeNode{head: 10}
+	var posted []string
+	failAfter := -1
+	w := &Watcher{
+		Node:      node,
+		Contracts: []indexer.Contract{{Name: "Reserve", Address: reserve, Artifact: &chain.Artifact{Name: "Reserve", ABI: parsed}}},
+		Messages:  Messages,
+		Post: func(ctx context.Context, text string) error {
+			if failAfter == 0 {
+				return errors.New("HTTP 500")
+			}
+			failAfter--
+			posted = append(posted, text)
+			return nil
+		},
+		Network:   "ropsten",
+		StateFile: filepath.Join(dir, "state.json"),
+	}
+	ctx := context.Background()
+
+	// The first Step starts at the head, without posting what came before.
+	node.logs = []types.Log{event(parsed, "Paused", 9, 0, alice)}
+	require.NoError(t, w.Step(ctx))
+	assert.Empty(t, posted)
+
+	node.head = 12
+	node.logs = append(node.logs,
+		event(parsed, "Paused", 11, 0, alice),
+		event(parsed, "Transfer", 11, 1, alice),
+		event(parsed, "MinterChanged", 11, 2, alice),
+	)
+	failAfter = 1
+	assert.Error(t, w.Step(ctx))
+	require.Equal(t, []string{
+		"RSV on ropsten: Reserve paused by " + alice.Hex() + " (block 11, tx " + common.BigToHash(big.NewInt(11)).Hex() + ")",
+	}, posted)
+
+	// After a failure, posting resumes with the next event of the batch.
+	failAfter = -1
+	require.NoError(t, w.Step(ctx))
+	require.Len(t, posted, 2)
+	assert.True(t, strings.HasPrefix(posted[1], "RSV on ropsten: Reserve minter changed to "+alice.Hex()), posted[1])
+
+	// Nothing is posted twice, even after a restart.
+	w2 := *w
+	require.NoError(t, w2.Step(ctx))
+	assert.Len(t, posted, 2)
+
+	// An event posted from a block that leaves the chain is retracted.
+	orphan := node.logs[3]
+	orphan.Removed = true
+	require.Contains(t, w.message(ctx, orphan), "RSV on ropsten: RETRACTED, a reorganization undid: Reserve minter changed to ")
+}
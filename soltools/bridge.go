@@ -1,4 +1,6 @@
-// Package soltools provides a Go-to-JavaScript bridge to use 0x's suite of sol-X tools from Go.
+// Package soltools provides a Go-to-JavaScript bridge to use 0x's suite of sol-X tools from Go,
+// a harness that compiles the contracts with several versions of solc and compares them, and a
+// generator of Echidna and Medusa fuzzing harnesses.
 //
 // 0x has a suite of tools for Solidity development:
 //
@@ -0,0 +1,356 @@
+package soltools
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/pkg/errors"
+)
+
+// Setting is one way to compile the contracts: a pinned solc version and its optimizer runs.
+type Setting struct {
+	Version string // such as "0.5.7"
+	Runs    int    // 0 compiles without the optimizer
+}
+
+func (s Setting) String() string {
+	if s.Runs == 0 {
+		return "solc " + s.Version + ", unoptimized"
+	}
+	return fmt.Sprintf("solc %v, %v runs", s.Version, s.Runs)
+}
+
+// ParseSettings parses a comma-separated list of settings, each a solc version, optionally
+// followed by a colon and the optimizer runs: "0.5.7:10000,0.5.17:200,0.5.17".
+func ParseSettings(list string) ([]Setting, error) {
+	var settings []Setting
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, ":", 2)
+		s := Setting{Version: parts[0]}
+		if !isVersion(s.Version) {
+			return nil, errors.Errorf("%q is not a solc version, such as 0.5.7", s.Version)
+		}
+		if len(parts) == 2 {
+			runs, err := strconv.Atoi(parts[1])
+			if err != nil || runs <= 0 {
+				return nil, errors.Errorf("%q: optimizer runs must be a positive integer", item)
+			}
+			s.Runs = runs
+		}
+		settings = append(settings, s)
+	}
+	if len(settings) == 0 {
+		return nil, errors.New("no compiler settings")
+	}
+	return settings, nil
+}
+
+// isVersion reports whether v is a version of the form major.minor.patch.
+func isVersion(v string) bool {
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	for _, p := range parts {
+		if _, err := strconv.Atoi(p); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Target is a contract to compile, and the source file, relative to the repo root, that
+// defines it.
+type Target struct {
+	Contract string
+	Source   string
+}
+
+// Output is a contract as compiled with one setting.
+type Output struct {
+	ABI        string
+	Bin        string // hex, without 0x
+	BinRuntime string // hex, without 0x
+}
+
+// RuntimeSize is the size of the deployed bytecode in bytes, without its metadata hash.
+func (o *Output) RuntimeSize() int {
+	return len(stripMetadata(o.BinRuntime)) / 2
+}
+
+// Compiler runs one pinned version of solc over the repo's contracts.
+//
+// The contracts pin the version they are compiled with, so the compiler compiles a copy of
+// them, made on first use, that pins its own version instead. Close removes the copy.
+type Compiler struct {
+	Setting
+	Path    string // the solc binary
+	RepoDir string
+
+	staged string // the root of the copy
+}
+
+// NewCompiler finds the solc binary for s's version and checks that it is that version. It looks
+// for solc-<version> in $SOLC_DIR, then on the PATH, and then for a solc on the PATH.
+func NewCompiler(repoDir string, s Setting) (*Compiler, error) {
+	var candidates []string
+	if dir := os.Getenv("SOLC_DIR"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "solc-"+s.Version))
+	}
+	candidates = append(candidates, "solc-"+s.Version, "solc")
+	for _, name := range candidates {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+		out, err := exec.Command(path, "--version").Output()
+		if err != nil {
+			return nil, errors.Wrapf(err, "running %v --version", path)
+		}
+		if strings.Contains(string(out), "Version: "+s.Version+"+") {
+			return &Compiler{Setting: s, Path: path, RepoDir: repoDir}, nil
+		}
+	}
+	return nil, errors.Errorf("no solc %v: install it as solc-%v in $SOLC_DIR or on the PATH", s.Version, s.Version)
+}
+
+// pragma matches the version pragma of a Solidity file.
+var pragma = regexp.MustCompile(`(?m)^pragma solidity [^;]+;`)
+
+// stage copies the repo's contracts to a temporary directory, pinning c's version.
+func (c *Compiler) stage() error {
+	dir, err := ioutil.TempDir("", "soltools")
+	if err != nil {
+		return err
+	}
+	c.staged = dir
+	root := filepath.Join(c.RepoDir, "contracts")
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".sol" {
+			return err
+		}
+		rel, err := filepath.Rel(c.RepoDir, path)
+		if err != nil {
+			return err
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		src = pragma.ReplaceAll(src, []byte("pragma solidity "+c.Version+";"))
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(rel)), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dir, rel), src, 0644)
+	})
+}
+
+// Close removes the compiler's copy of the contracts.
+func (c *Compiler) Close() error {
+	if c.staged == "" {
+		return nil
+	}
+	return os.RemoveAll(c.staged)
+}
+
+// Compile compiles the contract of t, with the same flags as the Makefile.
+func (c *Compiler) Compile(ctx context.Context, t Target) (*Output, error) {
+	if c.staged == "" {
+		if err := c.stage(); err != nil {
+			return nil, errors.Wrap(err, "copying the contracts")
+		}
+	}
+	args := []string{"--allow-paths", filepath.Join(c.staged, "contracts")}
+	if c.Runs > 0 {
+		args = append(args, "--optimize", "--optimize-runs", strconv.Itoa(c.Runs))
+	}
+	args = append(args, "--combined-json=abi,bin,bin-runtime", t.Source)
+	cmd := exec.CommandContext(ctx, c.Path, args...)
+	cmd.Dir = c.staged
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "compiling %v with %v: %s", t.Source, c.Setting, stderr.Bytes())
+	}
+
+	// As in genABI.go, the contracts are keyed as <.sol filename>:<contract name>.
+	var result struct {
+		Contracts map[string]struct {
+			ABI        string
+			Bin        string
+			BinRuntime string `json:"bin-runtime"`
+		}
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, errors.Wrapf(err, "parsing solc output for %v", t.Source)
+	}
+	for key, contract := range result.Contracts {
+		if key[strings.LastIndex(key, ":")+1:] == t.Contract {
+			return &Output{ABI: contract.ABI, Bin: contract.Bin, BinRuntime: contract.BinRuntime}, nil
+		}
+	}
+	return nil, errors.Errorf("no %v in the solc output for %v", t.Contract, t.Source)
+}
+
+// Matrix is every target compiled with every setting.
+type Matrix struct {
+	Settings []Setting
+	Targets  []Target
+	Outputs  map[Setting]map[string]*Output // by contract name
+}
+
+// CompileMatrix compiles every target with every setting. It fails if any solc version is
+// missing or any compilation fails, such as where a newer compiler refuses code that the pinned
+// one accepts.
+func CompileMatrix(ctx context.Context, repoDir string, settings []Setting, targets []Target) (*Matrix, error) {
+	m := &Matrix{Settings: settings, Targets: targets, Outputs: make(map[Setting]map[string]*Output)}
+	for _, s := range settings {
+		c, err := NewCompiler(repoDir, s)
+		if err != nil {
+			return nil, err
+		}
+		m.Outputs[s] = make(map[string]*Output)
+		err = m.compile(ctx, c)
+		if closeErr := c.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// compile compiles every target with c.
+func (m *Matrix) compile(ctx context.Context, c *Compiler) error {
+	for _, t := range m.Targets {
+		out, err := c.Compile(ctx, t)
+		if err != nil {
+			return err
+		}
+		m.Outputs[c.Setting][t.Contract] = out
+	}
+	return nil
+}
+
+// Diff is how a contract compiled with one setting differs from the same contract compiled
+// with another.
+type Diff struct {
+	Contract string
+	From, To Setting
+
+	// ABI lists what the ABI lost, as "- function ...", and gained, as "+ function ...".
+	ABI []string
+
+	// SizeFrom and SizeTo are the sizes of the deployed bytecode, and SameRuntime whether it is
+	// the same but for its metadata hash.
+	SizeFrom, SizeTo int
+	SameRuntime      bool
+}
+
+// Diff compares every target as compiled with from and with to.
+func (m *Matrix) Diff(from, to Setting) ([]Diff, error) {
+	var diffs []Diff
+	for _, t := range m.Targets {
+		a, b := m.Outputs[from][t.Contract], m.Outputs[to][t.Contract]
+		if a == nil || b == nil {
+			return nil, errors.Errorf("%v was not compiled with both %v and %v", t.Contract, from, to)
+		}
+		changes, err := DiffABI(a.ABI, b.ABI)
+		if err != nil {
+			return nil, errors.Wrap(err, t.Contract)
+		}
+		diffs = append(diffs, Diff{
+			Contract:    t.Contract,
+			From:        from,
+			To:          to,
+			ABI:         changes,
+			SizeFrom:    a.RuntimeSize(),
+			SizeTo:      b.RuntimeSize(),
+			SameRuntime: stripMetadata(a.BinRuntime) == stripMetadata(b.BinRuntime),
+		})
+	}
+	return diffs, nil
+}
+
+// DiffABI lists the constructor, functions, and events that ABI a has and b lacks, as
+// "- <signature>", followed by those that b has and a lacks, as "+ <signature>". Each
+// signature includes the names of the arguments and what the function returns, so that a
+// change to either shows up as one removed and one added.
+func DiffABI(a, b string) ([]string, error) {
+	sigsA, err := abiSignatures(a)
+	if err != nil {
+		return nil, err
+	}
+	sigsB, err := abiSignatures(b)
+	if err != nil {
+		return nil, err
+	}
+	var removed, added []string
+	for sig := range sigsA {
+		if !sigsB[sig] {
+			removed = append(removed, "- "+sig)
+		}
+	}
+	for sig := range sigsB {
+		if !sigsA[sig] {
+			added = append(added, "+ "+sig)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	return append(removed, added...), nil
+}
+
+func abiSignatures(s string) (map[string]bool, error) {
+	parsed, err := abi.JSON(strings.NewReader(s))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing ABI")
+	}
+	inputs := make([]string, len(parsed.Constructor.Inputs))
+	for i, input := range parsed.Constructor.Inputs {
+		inputs[i] = input.Type.String() + " " + input.Name
+	}
+	sigs := map[string]bool{"constructor(" + strings.Join(inputs, ", ") + ")": true}
+	for _, method := range parsed.Methods {
+		sigs[method.String()] = true
+	}
+	for _, event := range parsed.Events {
+		sigs[event.String()] = true
+	}
+	return sigs, nil
+}
+
+// stripMetadata removes the CBOR-encoded metadata that solc appends to the hex bytecode bin,
+// whose length is in its last two bytes, so that bytecode compiled from the same code in
+// another directory, or by another build of the same compiler, compares equal.
+func stripMetadata(bin string) string {
+	code, err := hex.DecodeString(bin)
+	if err != nil || len(code) < 2 {
+		return bin
+	}
+	n := int(code[len(code)-2])<<8 | int(code[len(code)-1])
+	start := len(code) - 2 - n
+	// A CBOR map of one to three entries, as every version of solc writes.
+	if start < 0 || code[start] < 0xa1 || code[start] > 0xa3 {
+		return bin
+	}
+	return bin[:2*start]
+}
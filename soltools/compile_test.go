@@ -0,0 +1,90 @@
+package soltools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSettings(t *testing.T) {
+	settings, err := ParseSettings("0.5.7:10000, 0.5.17:200,0.5.17")
+	require.NoError(t, err)
+	assert.Equal(t, []Setting{{"0.5.7", 10000}, {"0.5.17", 200}, {"0.5.17", 0}}, settings)
+	assert.Equal(t, "solc 0.5.7, 10000 runs", settings[0].String())
+	assert.Equal(t, "solc 0.5.17, unoptimized", settings[2].String())
+
+	for _, bad := range []string{"", "0.5", "v0.5.7", "0.5.7:", "0.5.7:0", "0.5.7:many"} {
+		_, err := ParseSettings(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+// A contract with one view, time(), as solc 0.5.7 compiles it, metadata hash included.
+const timeRuntime = "6080604052348015600f57600080fd5b50600436106044577c0100000000000000000000000000000000000000000000000000000000600035046316ada54781146049575b600080fd5b604f6061565b60408051918252519081900360200190f35b429056fea165627a7a723058205524d6a0c4d80ea5535c2ea64615c2619a21518e242cb929275cbd678b04468f0029"
+
+func TestStripMetadata(t *testing.T) {
+	stripped := stripMetadata(timeRuntime)
+	assert.Equal(t, timeRuntime[:len(timeRuntime)-2*43], stripped)
+	assert.Equal(t, len(stripped)/2, (&Output{BinRuntime: timeRuntime}).RuntimeSize())
+
+	// Another hash, such as of the same code in another directory, strips to the same code.
+	other := timeRuntime[:len(timeRuntime)-68] + "00000000000000000000000000000000000000000000000000000000000000000029"
+	assert.Equal(t, stripped, stripMetadata(other))
+
+	// Bytecode without metadata is left alone.
+	assert.Equal(t, stripped, stripMetadata(stripped))
+	assert.Equal(t, "", stripMetadata(""))
+}
+
+func TestDiffABI(t *testing.T) {
+	a := `[
+		{"type":"constructor","inputs":[{"name":"interval","type":"uint256"}]},
+		{"type":"function","name":"claim","inputs":[{"name":"token","type":"address"}],"outputs":[]},
+		{"type":"function","name":"shares","constant":true,"inputs":[{"name":"","type":"uint256"}],"outputs":[{"name":"","type":"uint256"}]},
+		{"type":"event","name":"FeesClaimed","inputs":[{"name":"token","type":"address","indexed":true}]}
+	]`
+	changes, err := DiffABI(a, a)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	b := `[
+		{"type":"constructor","inputs":[{"name":"interval","type":"uint256"}]},
+		{"type":"function","name":"claim","inputs":[{"name":"token","type":"address"}],"outputs":[]},
+		{"type":"function","name":"shares","constant":true,"inputs":[{"name":"","type":"uint256"}],"outputs":[{"name":"","type":"uint128"}]},
+		{"type":"event","name":"FeesClaimed","inputs":[{"name":"token","type":"address","indexed":false}]},
+		{"type":"function","name":"distribute","inputs":[{"name":"token","type":"address"}],"outputs":[]}
+	]`
+	changes, err = DiffABI(a, b)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"- event FeesClaimed(address indexed token)",
+		"- function shares(uint256 ) constant returns(uint256)",
+		"+ event FeesClaimed(address token)",
+		"+ function distribute(address token) returns()",
+		"+ function shares(uint256 ) constant returns(uint128)",
+	}, changes)
+
+	_, err = DiffABI(a, "not an ABI")
+	assert.Error(t, err)
+}
+
+// TestCompile compiles a contract of the repo's with the solc version it pins, when that is
+// installed.
+func TestCompile(t *testing.T) {
+	s := Setting{Version: "0.5.7", Runs: 1000000}
+	c, err := NewCompiler("..", s)
+	if err != nil {
+		t.Skip(err)
+	}
+	m, err := CompileMatrix(context.Background(), c.RepoDir, []Setting{s, {Version: s.Version}},
+		[]Target{{Contract: "FeeTreasury", Source: "contracts/FeeTreasury.sol"}})
+	require.NoError(t, err)
+	diffs, err := m.Diff(s, Setting{Version: s.Version})
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+	assert.Empty(t, diffs[0].ABI, "the optimizer leaves the ABI alone")
+	assert.False(t, diffs[0].SameRuntime)
+	assert.True(t, diffs[0].SizeFrom > 0 && diffs[0].SizeTo > 0)
+}
@@ -0,0 +1,370 @@
+package soltools
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// Invariant is a property that must hold of a deployment after every transaction, which the Go
+// fuzz tests check against the simulated chain and a Harness checks in Solidity.
+type Invariant struct {
+	Name string // a Solidity identifier; the harness checks it as echidna_<Name>
+	Doc  string // one sentence, for the property's doc comment
+
+	// Solidity is the body of a view function that returns whether the invariant holds, indented
+	// however suits the Go source it is in. It may read the harness's manager, reserve, vault, and
+	// tokens, using SafeMath for uint256; a revert counts as the invariant failing.
+	Solidity string
+}
+
+// Harness is an Echidna and Medusa fuzzing harness of invariants: a Solidity contract that
+// deploys a Manager, with a Reserve, a Vault, and a basket of BasicERC20 tokens, as the Go fuzz
+// tests do, and gives the fuzzers its issuance, redemption, and proposals to call in random
+// sequences, checking each invariant after every call.
+type Harness struct {
+	Contract   string     // the harness contract's name, such as "ManagerHarness"
+	Weights    []*big.Int // the first basket's weight of each token, unit: aqToken/RSV
+	TestLimit  int        // the calls each fuzzer makes; 0 means 50000
+	Invariants []Invariant
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// maxHarnessTokens is the most basket tokens a harness can choose among, by bit of a uint16.
+const maxHarnessTokens = 16
+
+func (h Harness) validate() error {
+	if !identifier.MatchString(h.Contract) {
+		return errors.Errorf("harness contract %q is not a Solidity identifier", h.Contract)
+	}
+	if len(h.Weights) == 0 || len(h.Weights) > maxHarnessTokens {
+		return errors.Errorf("a harness needs 1 to %v tokens, not %v", maxHarnessTokens, len(h.Weights))
+	}
+	for i, w := range h.Weights {
+		if w == nil || w.Sign() <= 0 {
+			return errors.Errorf("the weight of token %v must be positive", i)
+		}
+	}
+	if len(h.Invariants) == 0 {
+		return errors.New("a harness needs invariants to check")
+	}
+	names := make(map[string]bool)
+	for _, inv := range h.Invariants {
+		if !identifier.MatchString(inv.Name) {
+			return errors.Errorf("invariant %q is not a Solidity identifier", inv.Name)
+		}
+		if names[inv.Name] {
+			return errors.Errorf("two invariants are named %v", inv.Name)
+		}
+		names[inv.Name] = true
+		if strings.TrimSpace(inv.Solidity) == "" {
+			return errors.Errorf("invariant %v has no Solidity", inv.Name)
+		}
+	}
+	return nil
+}
+
+// Write writes the harness to dir, relative to the repo at repoDir: the contract, as
+// <Contract>.sol, with echidna.yaml and medusa.json to fuzz it with. The fuzzers are run from the
+// repo root, as `echidna <dir>/<Contract>.sol --contract <Contract> --config <dir>/echidna.yaml`
+// and `medusa fuzz --config <dir>/medusa.json`, and keep their corpora in dir.
+func (h Harness) Write(repoDir, dir string) error {
+	sol, err := h.Solidity(dir)
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{
+		h.Contract + ".sol": sol,
+		"echidna.yaml":      h.EchidnaConfig(dir),
+		"medusa.json":       h.MedusaConfig(dir),
+	}
+	out := filepath.Join(repoDir, dir)
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return errors.Wrap(err, "creating the harness directory")
+	}
+	for name, b := range files {
+		if err := ioutil.WriteFile(filepath.Join(out, name), b, 0644); err != nil {
+			return errors.Wrapf(err, "writing %v", name)
+		}
+	}
+	return nil
+}
+
+// Solidity returns the harness contract, to be written to dir, relative to the repo root.
+func (h Harness) Solidity(dir string) ([]byte, error) {
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+	contracts, err := filepath.Rel(filepath.FromSlash(dir), "contracts")
+	if err != nil {
+		return nil, errors.Wrapf(err, "finding contracts/ from %v", dir)
+	}
+	var buf bytes.Buffer
+	err = harnessTemplate.Execute(&buf, map[string]interface{}{
+		"Contract":   h.Contract,
+		"Contracts":  filepath.ToSlash(contracts),
+		"Weights":    h.Weights,
+		"Invariants": h.Invariants,
+	})
+	return buf.Bytes(), errors.Wrap(err, "generating the harness")
+}
+
+func (h Harness) testLimit() int {
+	if h.TestLimit == 0 {
+		return 50000
+	}
+	return h.TestLimit
+}
+
+// callSequence is how many calls the fuzzers make before starting over from the deployment, and
+// maxDelay is the most time they let pass between calls, enough for a proposal's delay.
+const (
+	callSequence = 100
+	maxDelay     = 2 * 24 * 60 * 60
+)
+
+// EchidnaConfig returns echidna.yaml for the harness in dir. The harness's constructor deploys
+// the whole system, so it is far larger than EIP-170 allows; Echidna is told to allow it.
+func (h Harness) EchidnaConfig(dir string) []byte {
+	var buf bytes.Buffer
+	template.Must(template.New("echidna").Parse(`# Generated by soltools.Harness; DO NOT EDIT.
+testMode: property
+prefix: "echidna_"
+testLimit: {{.TestLimit}}
+seqLen: {{.SeqLen}}
+maxTimeDelay: {{.MaxDelay}}
+codeSize: 4294967295
+corpusDir: "{{.Dir}}/corpus-echidna"
+`)).Execute(&buf, map[string]interface{}{
+		"TestLimit": h.testLimit(),
+		"SeqLen":    callSequence,
+		"MaxDelay":  maxDelay,
+		"Dir":       path.Clean(filepath.ToSlash(dir)),
+	})
+	return buf.Bytes()
+}
+
+// MedusaConfig returns medusa.json for the harness in dir, checking the same properties as
+// Echidna does.
+func (h Harness) MedusaConfig(dir string) []byte {
+	dir = path.Clean(filepath.ToSlash(dir))
+	config := map[string]interface{}{
+		"fuzzing": map[string]interface{}{
+			"workers":                10,
+			"testLimit":              h.testLimit(),
+			"callSequenceLength":     callSequence,
+			"corpusDirectory":        dir + "/corpus-medusa",
+			"targetContracts":        []string{h.Contract},
+			"blockTimestampDelayMax": maxDelay,
+			// The harness's constructor deploys the whole system.
+			"transactionGasLimit": 100000000,
+			"blockGasLimit":       125000000,
+			"testing": map[string]interface{}{
+				"propertyTesting":  map[string]interface{}{"enabled": true, "testPrefixes": []string{"echidna_"}},
+				"assertionTesting": map[string]interface{}{"enabled": false},
+			},
+			"chainConfig": map[string]interface{}{"codeSizeCheckDisabled": true},
+		},
+		"compilation": map[string]interface{}{
+			"platform": "crytic-compile",
+			"platformConfig": map[string]interface{}{
+				"target": dir + "/" + h.Contract + ".sol",
+				"args":   []string{},
+			},
+		},
+	}
+	b, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		panic(err) // it's all maps, strings, and numbers
+	}
+	return append(b, '\n')
+}
+
+// indent re-indents the lines of s by n spaces, in place of the indentation they have in common,
+// counting a tab as four spaces.
+func indent(n int, s string) string {
+	lines := strings.Split(strings.TrimRight(s, " \t\n"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	common := -1
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(strings.Replace(line, "\t", "    ", -1), " ")
+		if lines[i] == "" {
+			continue
+		}
+		if margin := len(lines[i]) - len(strings.TrimLeft(lines[i], " ")); common < 0 || margin < common {
+			common = margin
+		}
+	}
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = strings.Repeat(" ", n) + line[common:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var harnessTemplate = template.Must(template.New("harness").Funcs(template.FuncMap{
+	"indent": indent,
+}).Parse(`pragma solidity 0.5.7;
+
+// Generated by soltools.Harness from the invariants of the Go fuzz tests; DO NOT EDIT.
+
+import "{{.Contracts}}/Manager.sol";
+import "{{.Contracts}}/Vault.sol";
+import "{{.Contracts}}/rsv/Reserve.sol";
+import "{{.Contracts}}/rsv/ReserveEternalStorage.sol";
+import "{{.Contracts}}/test/PreviousReserve.sol";
+import "{{.Contracts}}/test/BasicERC20.sol";
+
+/// Deploys a Manager, with a Reserve, a Vault, and a basket of BasicERC20 tokens, as the Go fuzz
+/// tests do, and is its owner, operator, proposer, and only issuer. Echidna and Medusa call its
+/// actions in random sequences, and check each echidna_ property after every call.
+contract {{.Contract}} {
+    using SafeMath for uint256;
+
+    // The most one issue may issue, as in the Go fuzz tests. unit: attoRSV
+    uint256 internal constant MAX_ISSUE = 1e30;
+
+    // What the harness keeps of each token, far less than the BasicERC20 mints it, so that the
+    // properties' arithmetic stays clear of overflow. unit: qToken
+    uint256 internal constant FUNDS = 1e30;
+
+    Manager public manager;
+    Reserve public reserve;
+    Vault public vault;
+    address[] public tokens;
+
+    constructor() public {
+        // Deploy the Reserve by upgrading from a PreviousReserve, taking its eternal storage.
+        PreviousReserve previous = new PreviousReserve();
+        ReserveEternalStorage eternalStorage =
+            ReserveEternalStorage(previous.getEternalStorageAddress());
+        reserve = new Reserve();
+        previous.nominateNewOwner(address(reserve));
+        reserve.acceptUpgrade(address(previous));
+        eternalStorage.acceptOwnership();
+
+        vault = new Vault();
+        uint256[] memory weights = new uint256[]({{len .Weights}});
+        for (uint256 i = 0; i < weights.length; i++) {
+            BasicERC20 token = new BasicERC20();
+            token.transfer(address(1), token.balanceOf(address(this)).sub(FUNDS));
+            tokens.push(address(token));
+        }
+{{- range $i, $w := .Weights}}
+        weights[{{$i}}] = {{$w}};
+{{- end}}
+        manager = new Manager(
+            address(vault),
+            address(reserve),
+            address(new ProposalFactory()),
+            address(new Basket(Basket(address(0)), tokens, weights)),
+            address(this),
+            0
+        );
+
+        manager.setEmergency(false);
+        reserve.changeMinter(address(manager));
+        reserve.changePauser(address(manager));
+        vault.changeManager(address(manager));
+        for (uint256 i = 0; i < tokens.length; i++) {
+            IERC20(tokens[i]).approve(address(manager), uint256(-1));
+        }
+    }
+
+    // ============================== Actions =================================
+
+    /// Issue up to MAX_ISSUE attoRSV.
+    function issue(uint256 rsvAmount) external {
+        manager.issue(rsvAmount % MAX_ISSUE);
+    }
+
+    /// Redeem up to the whole supply, all of which the harness holds.
+    function redeem(uint256 rsvAmount) external {
+        rsvAmount = rsvAmount % reserve.totalSupply().add(1);
+        reserve.approve(address(manager), rsvAmount);
+        manager.redeem(rsvAmount);
+    }
+
+    /// Propose new weights for the tokens that mask selects, a bit for each.
+    function proposeWeights(uint16 mask, uint256[] calldata weights) external {
+        address[] memory chosen = _choose(mask);
+        require(weights.length >= chosen.length, "too few weights");
+        uint256[] memory chosenWeights = new uint256[](chosen.length);
+        for (uint256 i = 0; i < chosen.length; i++) {
+            chosenWeights[i] = weights[i];
+        }
+        manager.proposeWeights(chosen, chosenWeights);
+    }
+
+    /// Propose a swap with the Vault of the tokens that mask selects, a bit for each.
+    function proposeSwap(uint16 mask, uint256[] calldata amounts, bool[] calldata toVault)
+    external
+    {
+        address[] memory chosen = _choose(mask);
+        require(amounts.length >= chosen.length, "too few amounts");
+        require(toVault.length >= chosen.length, "too few directions");
+        uint256[] memory chosenAmounts = new uint256[](chosen.length);
+        bool[] memory chosenToVault = new bool[](chosen.length);
+        for (uint256 i = 0; i < chosen.length; i++) {
+            chosenAmounts[i] = amounts[i];
+            chosenToVault[i] = toVault[i];
+        }
+        manager.proposeSwap(chosen, chosenAmounts, chosenToVault);
+    }
+
+    /// Accept a proposal, picked by id modulo their number.
+    function acceptProposal(uint256 id) external {
+        manager.acceptProposal(_proposal(id));
+    }
+
+    /// Execute a proposal, picked by id modulo their number, once its delay has passed.
+    function executeProposal(uint256 id) external {
+        manager.executeProposal(_proposal(id));
+    }
+
+    /// The ID of a proposal, picked by id modulo their number.
+    function _proposal(uint256 id) internal view returns (uint256) {
+        uint256 n = manager.proposalsLength();
+        require(n > 0, "no proposals");
+        return id % n;
+    }
+
+    /// The tokens that mask selects, a bit for each, in order.
+    function _choose(uint16 mask) internal view returns (address[] memory chosen) {
+        uint256 n;
+        for (uint256 i = 0; i < tokens.length; i++) {
+            if ((mask & (uint16(1) << i)) != 0) {
+                n++;
+            }
+        }
+        chosen = new address[](n);
+        n = 0;
+        for (uint256 i = 0; i < tokens.length; i++) {
+            if ((mask & (uint16(1) << i)) != 0) {
+                chosen[n++] = tokens[i];
+            }
+        }
+    }
+
+    // ============================= Properties ===============================
+{{range .Invariants}}
+    /// {{.Doc}}
+    function echidna_{{.Name}}() public view returns (bool) {
+{{indent 8 .Solidity}}
+    }
+{{end -}}
+}
+`))
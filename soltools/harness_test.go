@@ -0,0 +1,125 @@
+package soltools
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testHarness() Harness {
+	return Harness{
+		Contract: "ManagerHarness",
+		Weights:  []*big.Int{big.NewInt(5e17), big.NewInt(5e17)},
+		Invariants: []Invariant{
+			{
+				Name:     "collateralized",
+				Doc:      "The Manager reports the Vault fully collateralized.",
+				Solidity: "return manager.isFullyCollateralized();",
+			},
+			{
+				Name: "withinMaxSupply",
+				Doc:  "The supply is at most the Reserve's maxSupply.",
+				Solidity: `
+					uint256 supply = reserve.totalSupply();
+
+					return supply <= reserve.maxSupply();
+				`,
+			},
+		},
+	}
+}
+
+func TestHarnessSolidity(t *testing.T) {
+	sol, err := testHarness().Solidity("tests/echidna")
+	require.NoError(t, err)
+	src := string(sol)
+
+	assert.Contains(t, src, `import "../../contracts/Manager.sol";`)
+	assert.Contains(t, src, "contract ManagerHarness {")
+	assert.Contains(t, src, "new uint256[](2);")
+	assert.Contains(t, src, "        weights[0] = 500000000000000000;\n        weights[1] = 500000000000000000;\n")
+	assert.Contains(t, src, `    /// The Manager reports the Vault fully collateralized.
+    function echidna_collateralized() public view returns (bool) {
+        return manager.isFullyCollateralized();
+    }
+
+    /// The supply is at most the Reserve's maxSupply.
+    function echidna_withinMaxSupply() public view returns (bool) {
+        uint256 supply = reserve.totalSupply();
+
+        return supply <= reserve.maxSupply();
+    }
+}
+`)
+	for i, line := range strings.Split(src, "\n") {
+		assert.True(t, len(line) <= 100, "line %v is longer than 100 columns: %v", i+1, line)
+		assert.Equal(t, strings.TrimRight(line, " "), line, "line %v has trailing spaces", i+1)
+	}
+}
+
+func TestHarnessInvalid(t *testing.T) {
+	for name, change := range map[string]func(*Harness){
+		"contract":  func(h *Harness) { h.Contract = "Manager Harness" },
+		"no tokens": func(h *Harness) { h.Weights = nil },
+		"weight":    func(h *Harness) { h.Weights[1] = big.NewInt(0) },
+		"none":      func(h *Harness) { h.Invariants = nil },
+		"name":      func(h *Harness) { h.Invariants[0].Name = "fully-collateralized" },
+		"duplicate": func(h *Harness) { h.Invariants[1].Name = h.Invariants[0].Name },
+		"empty":     func(h *Harness) { h.Invariants[1].Solidity = " \n" },
+	} {
+		h := testHarness()
+		change(&h)
+		_, err := h.Solidity("tests/echidna")
+		assert.Error(t, err, name)
+	}
+}
+
+func TestHarnessWrite(t *testing.T) {
+	repo, err := ioutil.TempDir("", "harness")
+	require.NoError(t, err)
+	defer os.RemoveAll(repo)
+	require.NoError(t, testHarness().Write(repo, "tests/echidna"))
+
+	for _, name := range []string{"ManagerHarness.sol", "echidna.yaml", "medusa.json"} {
+		_, err := os.Stat(filepath.Join(repo, "tests", "echidna", name))
+		assert.NoError(t, err, name)
+	}
+
+	echidna, err := ioutil.ReadFile(filepath.Join(repo, "tests", "echidna", "echidna.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(echidna), "testLimit: 50000\n")
+	assert.Contains(t, string(echidna), `corpusDir: "tests/echidna/corpus-echidna"`)
+
+	b, err := ioutil.ReadFile(filepath.Join(repo, "tests", "echidna", "medusa.json"))
+	require.NoError(t, err)
+	var medusa struct {
+		Fuzzing struct {
+			TargetContracts []string
+			CorpusDirectory string
+			Testing         struct {
+				PropertyTesting struct {
+					Enabled      bool
+					TestPrefixes []string
+				}
+			}
+		}
+		Compilation struct {
+			PlatformConfig struct {
+				Target string
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(b, &medusa))
+	assert.Equal(t, []string{"ManagerHarness"}, medusa.Fuzzing.TargetContracts)
+	assert.Equal(t, "tests/echidna/corpus-medusa", medusa.Fuzzing.CorpusDirectory)
+	assert.True(t, medusa.Fuzzing.Testing.PropertyTesting.Enabled)
+	assert.Equal(t, []string{"echidna_"}, medusa.Fuzzing.Testing.PropertyTesting.TestPrefixes)
+	assert.Equal(t, "tests/echidna/ManagerHarness.sol", medusa.Compilation.PlatformConfig.Target)
+}
@@ -0,0 +1,461 @@
+// +build all
+
+package tests
+
+import (
+	"fmt"
+	"math/big"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/soltools"
+)
+
+func TestDutchAuction(t *testing.T) {
+	suite.Run(t, new(DutchAuctionSuite))
+}
+
+type DutchAuctionSuite struct {
+	TestSuite
+
+	auction        *abi.DutchAuction
+	auctionAddress common.Address
+
+	// A token that the Vault holds outside the basket, as a basket migration leaves behind.
+	stray        *abi.BasicERC20
+	strayAddress common.Address
+}
+
+var (
+	// Compile-time check that DutchAuctionSuite implements the interfaces we think it does.
+	// If it does not implement these interfaces, then the corresponding setup and teardown
+	// functions will not actually run.
+	_ suite.BeforeTest       = &DutchAuctionSuite{}
+	_ suite.SetupAllSuite    = &DutchAuctionSuite{}
+	_ suite.TearDownAllSuite = &DutchAuctionSuite{}
+)
+
+// SetupSuite runs once, before all of the tests in the suite.
+func (s *DutchAuctionSuite) SetupSuite() {
+	s.setup()
+}
+
+// TearDownSuite runs once, after all of the tests in the suite.
+func (s *DutchAuctionSuite) TearDownSuite() {
+	if coverageEnabled {
+		// Write coverage profile to disk.
+		s.Assert().NoError(s.node.(*soltools.Backend).WriteCoverage())
+
+		// Close the node.js process.
+		s.Assert().NoError(s.node.(*soltools.Backend).Close())
+
+		// Process coverage profile into an HTML report.
+		if out, err := exec.Command("npx", "istanbul", "report", "html").CombinedOutput(); err != nil {
+			fmt.Println()
+			fmt.Println("I generated coverage information in coverage/coverage.json.")
+			fmt.Println("I tried to process it with `istanbul` to turn it into a readable report, but failed.")
+			fmt.Println("The error I got when running istanbul was:", err)
+			fmt.Println("Istanbul's output was:\n" + string(out))
+		}
+	}
+}
+
+// BeforeTest runs before each test in the suite. It deploys a Manager backing 1000 RSV, whose
+// Vault also holds 1000 of a stray token, and a DutchAuction for it.
+func (s *DutchAuctionSuite) BeforeTest(suiteName, testName string) {
+	s.owner = s.account[0]
+	s.operator = s.account[1]
+	s.proposer = s.account[5]
+	s.deployReserve()
+
+	var tx *types.Transaction
+	var err error
+	s.vaultAddress, tx, s.vault, err = abi.DeployVault(s.signer, s.node)
+	s.logParsers[s.vaultAddress] = s.vault
+	s.requireTx(tx, err)
+
+	s.proposalFactoryAddress, tx, s.proposalFactory, err = abi.DeployProposalFactory(s.signer, s.node)
+	s.logParsers[s.proposalFactoryAddress] = s.proposalFactory
+	s.requireTx(tx, err)
+
+	s.erc20s = make([]*abi.BasicERC20, 3)
+	s.erc20Addresses = make([]common.Address, 3)
+	for i := range s.erc20s {
+		s.erc20Addresses[i], tx, s.erc20s[i], err = abi.DeployBasicERC20(s.signer, s.node)
+		s.logParsers[s.erc20Addresses[i]] = s.erc20s[i]
+		s.requireTx(tx, err)
+	}
+	s.weights = []*big.Int{shiftLeft(1, 35), shiftLeft(3, 35), shiftLeft(6, 35)}
+	s.basketAddress, tx, s.basket, err = abi.DeployBasket(
+		s.signer, s.node, zeroAddress(), s.erc20Addresses, s.weights,
+	)
+	s.logParsers[s.basketAddress] = s.basket
+	s.requireTx(tx, err)
+
+	s.managerAddress, tx, s.manager, err = abi.DeployManager(
+		s.signer, s.node,
+		s.vaultAddress, s.reserveAddress, s.proposalFactoryAddress, s.basketAddress, s.operator.address(), bigInt(0),
+	)
+	s.logParsers[s.managerAddress] = s.manager
+	s.requireTx(tx, err)
+	s.requireTx(s.manager.SetEmergency(signer(s.operator), false))
+	s.requireTx(s.reserve.ChangeMinter(s.signer, s.managerAddress))
+	s.requireTx(s.vault.ChangeManager(s.signer, s.managerAddress))
+
+	s.fundAccountWithErc20sAndApprove(s.proposer, []*big.Int{shiftLeft(1, 46), shiftLeft(1, 46), shiftLeft(1, 46)})
+	s.requireTx(s.manager.Issue(signer(s.proposer), shiftLeft(1000, 18)))
+
+	s.strayAddress, tx, s.stray, err = abi.DeployBasicERC20(s.signer, s.node)
+	s.logParsers[s.strayAddress] = s.stray
+	s.requireTx(tx, err)
+	s.requireTx(s.stray.Transfer(s.signer, s.vaultAddress, shiftLeft(1000, 18)))
+
+	s.auctionAddress, tx, s.auction, err = abi.DeployDutchAuction(s.signer, s.node, s.managerAddress)
+	s.logParsers[s.auctionAddress] = s.auction
+	s.requireTx(tx, err)
+	s.requireTxWithStrictEvents(s.manager.SetAuction(s.signer, s.auctionAddress))(
+		abi.ManagerAuctionChanged{OldAuction: zeroAddress(), NewAuction: s.auctionAddress},
+	)
+}
+
+// startAuction starts a lot of `amount` of the stray token for token0, at prices falling from
+// `startPrice` to `endPrice` over `duration`, and returns its ID.
+func (s *DutchAuctionSuite) startAuction(amount, startPrice, endPrice *big.Int, duration time.Duration) *big.Int {
+	s.requireTx(s.manager.StartAuction(
+		s.signer, s.strayAddress, s.erc20Addresses[0], amount, startPrice, endPrice, seconds(duration),
+	))
+	length, err := s.auction.LotsLength(nil)
+	s.Require().NoError(err)
+	return bigInt(0).Sub(length, bigInt(1))
+}
+
+// expectedPrice is the price of a lot from `startPrice` to `endPrice` between `start` and `end`,
+// at `now`: falling linearly, rounded up.
+func expectedPrice(startPrice, endPrice, start, end, now *big.Int) *big.Int {
+	if now.Cmp(end) >= 0 {
+		return endPrice
+	}
+	drop := bigInt(0).Mul(bigInt(0).Sub(startPrice, endPrice), bigInt(0).Sub(now, start))
+	drop.Div(drop, bigInt(0).Sub(end, start))
+	return bigInt(0).Sub(startPrice, drop)
+}
+
+// bidCost is what `amount` costs at `price`, rounded up.
+func bidCost(amount, price *big.Int) *big.Int {
+	cost, remainder := bigInt(0).DivMod(bigInt(0).Mul(amount, price), shiftLeft(1, 18), bigInt(0))
+	if remainder.Sign() > 0 {
+		cost.Add(cost, bigInt(1))
+	}
+	return cost
+}
+
+// fundBidder gives `bidder` plenty of token0, approved for the auction.
+func (s *DutchAuctionSuite) fundBidder(bidder account) {
+	s.requireTx(s.erc20s[0].Transfer(s.signer, bidder.address(), shiftLeft(1, 30)))
+	s.requireTx(s.erc20s[0].Approve(signer(bidder), s.auctionAddress, shiftLeft(1, 30)))
+}
+
+// assertBalance asserts that `holder` has `amount` of `token`.
+func (s *DutchAuctionSuite) assertBalance(token *abi.BasicERC20, holder common.Address, amount *big.Int) {
+	balance, err := token.BalanceOf(nil, holder)
+	s.Require().NoError(err)
+	s.Equal(amount.String(), balance.String())
+}
+
+// TestDeploy tests that the auction deploys.
+func (s *DutchAuctionSuite) TestDeploy() {}
+
+// TestConstructor tests that the constructor sets state correctly, and refuses no manager.
+func (s *DutchAuctionSuite) TestConstructor() {
+	manager, err := s.auction.Manager(nil)
+	s.Require().NoError(err)
+	s.Equal(s.managerAddress, manager)
+	length, err := s.auction.LotsLength(nil)
+	s.Require().NoError(err)
+	s.Equal("0", length.String())
+	auction, err := s.manager.TrustedAuction(nil)
+	s.Require().NoError(err)
+	s.Equal(s.auctionAddress, auction)
+
+	_, tx, _, err := abi.DeployDutchAuction(s.signer, s.node, zeroAddress())
+	s.requireTxFails(tx, err)
+}
+
+// TestStartAuction tests that the owner can auction the Vault's stray tokens for a basket token
+// through the Manager, and that the lot starts as asked.
+func (s *DutchAuctionSuite) TestStartAuction() {
+	amount, startPrice, endPrice := shiftLeft(600, 18), shiftLeft(12, 17), shiftLeft(8, 17)
+	tx, err := s.manager.StartAuction(
+		s.signer, s.strayAddress, s.erc20Addresses[0], amount, startPrice, endPrice, seconds(time.Hour),
+	)
+	s.requireTxWithStrictEvents(tx, err)(
+		abi.BasicERC20Transfer{From: s.vaultAddress, To: s.auctionAddress, Value: amount},
+		abi.VaultWithdrawal{Token: s.strayAddress, Amount: amount, To: s.auctionAddress},
+		abi.DutchAuctionAuctionStarted{
+			Id:         bigInt(0),
+			SellToken:  s.strayAddress,
+			BuyToken:   s.erc20Addresses[0],
+			SellAmount: amount,
+			StartPrice: startPrice,
+			EndPrice:   endPrice,
+			End:        bigInt(0).Add(s.currentTimestamp(), seconds(time.Hour)),
+		},
+	)
+
+	lot, err := s.auction.Lots(nil, bigInt(0))
+	s.Require().NoError(err)
+	s.Equal(s.strayAddress, lot.SellToken)
+	s.Equal(s.erc20Addresses[0], lot.BuyToken)
+	s.Equal(s.vaultAddress, lot.ProceedsTo)
+	s.Equal(amount.String(), lot.Remaining.String())
+	s.Equal(s.currentTimestamp().String(), lot.Start.String())
+	s.True(lot.Open)
+	committed, err := s.auction.Committed(nil, s.strayAddress)
+	s.Require().NoError(err)
+	s.Equal(amount.String(), committed.String())
+	s.assertBalance(s.stray, s.vaultAddress, shiftLeft(400, 18))
+
+	// At the start, the lot is at its start price.
+	price, err := s.auction.Price(nil, bigInt(0))
+	s.Require().NoError(err)
+	s.Equal(startPrice.String(), price.String())
+	s.assertManagerCollateralized()
+}
+
+// TestStartAuctionRequirements tests that only the owner can start a lot, only through the
+// Manager, and only of a token outside the basket for one in it, at a falling price.
+func (s *DutchAuctionSuite) TestStartAuctionRequirements() {
+	amount, startPrice, endPrice := shiftLeft(100, 18), shiftLeft(12, 17), shiftLeft(8, 17)
+	token0 := s.erc20Addresses[0]
+	start := func(from account, sell, buy common.Address, startPrice, endPrice *big.Int, duration time.Duration) {
+		s.requireTxFails(s.manager.StartAuction(signer(from), sell, buy, amount, startPrice, endPrice, seconds(duration)))
+	}
+
+	// Not by the operator, nor anyone but the owner.
+	start(s.operator, s.strayAddress, token0, startPrice, endPrice, time.Hour)
+	start(s.account[2], s.strayAddress, token0, startPrice, endPrice, time.Hour)
+
+	// Not a basket token, nor RSV, and not for a token outside the basket.
+	start(s.owner, s.erc20Addresses[1], token0, startPrice, endPrice, time.Hour)
+	start(s.owner, s.reserveAddress, token0, startPrice, endPrice, time.Hour)
+	start(s.owner, s.strayAddress, s.reserveAddress, startPrice, endPrice, time.Hour)
+
+	// The price must fall, over some time.
+	start(s.owner, s.strayAddress, token0, endPrice, startPrice, time.Hour)
+	start(s.owner, s.strayAddress, token0, startPrice, endPrice, 0)
+
+	// Not directly.
+	s.requireTx(s.stray.Transfer(s.signer, s.auctionAddress, amount))
+	s.requireTxFails(s.auction.Start(
+		s.signer, s.strayAddress, token0, amount, startPrice, endPrice, seconds(time.Hour), s.vaultAddress,
+	))
+
+	// Not during an emergency.
+	s.requireTx(s.manager.SetEmergency(signer(s.operator), true))
+	start(s.owner, s.strayAddress, token0, startPrice, endPrice, time.Hour)
+	s.requireTx(s.manager.SetEmergency(signer(s.operator), false))
+
+	// Not without an auction.
+	s.requireTx(s.manager.SetAuction(s.signer, zeroAddress()))
+	start(s.owner, s.strayAddress, token0, startPrice, endPrice, time.Hour)
+
+	length, err := s.auction.LotsLength(nil)
+	s.Require().NoError(err)
+	s.Equal("0", length.String())
+	s.assertBalance(s.stray, s.vaultAddress, shiftLeft(1000, 18))
+}
+
+// TestPriceFalls tests that a lot's price falls linearly from its start price to its end price,
+// and stays there.
+func (s *DutchAuctionSuite) TestPriceFalls() {
+	startPrice, endPrice := shiftLeft(12, 17), shiftLeft(8, 17)
+	id := s.startAuction(shiftLeft(100, 18), startPrice, endPrice, time.Hour)
+	lot, err := s.auction.Lots(nil, id)
+	s.Require().NoError(err)
+
+	for i := 0; i < 8; i++ {
+		s.Require().NoError(s.node.(backend).AdjustTime(7 * time.Minute))
+		price, err := s.auction.Price(nil, id)
+		s.Require().NoError(err)
+		s.Equal(
+			expectedPrice(startPrice, endPrice, lot.Start, lot.End, s.currentTimestamp()).String(),
+			price.String(),
+		)
+		s.True(price.Cmp(endPrice) >= 0)
+		s.True(price.Cmp(startPrice) <= 0)
+	}
+
+	s.Require().NoError(s.node.(backend).AdjustTime(time.Hour))
+	price, err := s.auction.Price(nil, id)
+	s.Require().NoError(err)
+	s.Equal(endPrice.String(), price.String())
+
+	_, err = s.auction.Price(nil, bigInt(1))
+	s.Error(err)
+}
+
+// bidder is a simulated bidder, who bids for `wants` of the lot as soon as the price is at most
+// `reservation`.
+type bidder struct {
+	account     account
+	reservation *big.Int
+	wants       *big.Int
+
+	got, paid *big.Int
+}
+
+// TestBidders simulates bidders of different valuations over the course of a lot: each buys as
+// soon as the price falls to what it will pay, at the price of its bid's block, until the lot
+// sells out; the Vault receives every payment, and the bidder who comes last gets what's left.
+func (s *DutchAuctionSuite) TestBidders() {
+	startPrice, endPrice := shiftLeft(12, 17), shiftLeft(8, 17)
+	id := s.startAuction(shiftLeft(1000, 18), startPrice, endPrice, time.Hour)
+	lot, err := s.auction.Lots(nil, id)
+	s.Require().NoError(err)
+
+	bidders := []*bidder{
+		{account: s.account[2], reservation: shiftLeft(11, 17), wants: shiftLeft(300, 18)},
+		{account: s.account[3], reservation: shiftLeft(10, 17), wants: shiftLeft(500, 18)},
+		{account: s.account[4], reservation: shiftLeft(9, 17), wants: shiftLeft(400, 18)},
+		{account: s.account[6], reservation: shiftLeft(7, 17), wants: shiftLeft(1000, 18)},
+	}
+	for _, b := range bidders {
+		s.fundBidder(b.account)
+		b.got, b.paid = bigInt(0), bigInt(0)
+	}
+	vaultBefore, err := s.erc20s[0].BalanceOf(nil, s.vaultAddress)
+	s.Require().NoError(err)
+
+	remaining := shiftLeft(1000, 18)
+	proceeds := bigInt(0)
+	for step := 0; step < 11 && remaining.Sign() > 0; step++ {
+		s.Require().NoError(s.node.(backend).AdjustTime(5 * time.Minute))
+		for _, b := range bidders {
+			price, err := s.auction.Price(nil, id)
+			s.Require().NoError(err)
+			if b.got.Sign() > 0 || remaining.Sign() == 0 || price.Cmp(b.reservation) > 0 {
+				continue
+			}
+
+			s.requireTx(s.auction.Bid(signer(b.account), id, b.wants, b.reservation))
+
+			// The bid was made at the price of its own block.
+			price = expectedPrice(startPrice, endPrice, lot.Start, lot.End, s.currentTimestamp())
+			s.True(price.Cmp(b.reservation) <= 0)
+			amount := b.wants
+			if amount.Cmp(remaining) > 0 {
+				amount = remaining
+			}
+			b.got, b.paid = amount, bidCost(amount, price)
+			remaining = bigInt(0).Sub(remaining, amount)
+			proceeds.Add(proceeds, b.paid)
+		}
+	}
+
+	// The three highest bidders bought it all, the third only what the first two left, and the
+	// lowest never bid.
+	s.Equal("0", remaining.String())
+	s.Equal(shiftLeft(200, 18).String(), bidders[2].got.String())
+	s.Equal("0", bidders[3].got.String())
+	for _, b := range bidders {
+		s.assertBalance(s.stray, b.account.address(), b.got)
+		s.assertBalance(s.erc20s[0], b.account.address(), bigInt(0).Sub(shiftLeft(1, 30), b.paid))
+		if b.got.Sign() > 0 {
+			// Each paid no more than its valuation, and the earlier the bid, the higher the price.
+			s.True(b.paid.Cmp(bidCost(b.got, b.reservation)) <= 0)
+		}
+	}
+	s.True(bidders[0].paid.Cmp(bidCost(bidders[0].got, bidders[1].reservation)) > 0)
+
+	// The Vault has the proceeds, as surplus over the basket; the lot sold out and closed.
+	s.assertBalance(s.erc20s[0], s.vaultAddress, bigInt(0).Add(vaultBefore, proceeds))
+	s.assertBalance(s.stray, s.auctionAddress, bigInt(0))
+	lot, err = s.auction.Lots(nil, id)
+	s.Require().NoError(err)
+	s.False(lot.Open)
+	s.requireTxFails(s.auction.Close(s.signer, id))
+	s.requireTxFails(s.auction.Bid(signer(bidders[3].account), id, shiftLeft(1, 18), startPrice))
+	s.assertManagerCollateralized()
+}
+
+// TestBid tests a single bid's events, payment, and limits.
+func (s *DutchAuctionSuite) TestBid() {
+	startPrice, endPrice := shiftLeft(12, 17), shiftLeft(8, 17)
+	id := s.startAuction(shiftLeft(100, 18), startPrice, endPrice, time.Hour)
+	lot, err := s.auction.Lots(nil, id)
+	s.Require().NoError(err)
+	buyer := s.account[2]
+	s.fundBidder(buyer)
+
+	// Nothing, or at a price above the buyer's max, fails.
+	s.requireTxFails(s.auction.Bid(signer(buyer), id, bigInt(0), startPrice))
+	s.requireTxFails(s.auction.Bid(signer(buyer), id, shiftLeft(10, 18), endPrice))
+	s.requireTxFails(s.auction.Bid(signer(buyer), bigInt(1), shiftLeft(10, 18), startPrice))
+
+	s.Require().NoError(s.node.(backend).AdjustTime(20 * time.Minute))
+	amount := shiftLeft(30, 18)
+	tx, err := s.auction.Bid(signer(buyer), id, amount, startPrice)
+	price := expectedPrice(startPrice, endPrice, lot.Start, lot.End, s.currentTimestamp())
+	cost := bidCost(amount, price)
+	s.requireTxWithStrictEvents(tx, err)(
+		abi.BasicERC20Transfer{From: buyer.address(), To: s.vaultAddress, Value: cost},
+		abi.BasicERC20Approval{
+			Owner: buyer.address(), Spender: s.auctionAddress, Value: bigInt(0).Sub(shiftLeft(1, 30), cost),
+		},
+		abi.BasicERC20Transfer{From: s.auctionAddress, To: buyer.address(), Value: amount},
+		abi.DutchAuctionBid{Id: id, Bidder: buyer.address(), Amount: amount, Cost: cost, BidPrice: price},
+	)
+
+	// A bid for more than is left buys what's left, and closes the lot.
+	tx, err = s.auction.Bid(signer(buyer), id, shiftLeft(1000, 18), startPrice)
+	s.requireTx(tx, err)(
+		abi.DutchAuctionAuctionClosed{Id: id, Unsold: bigInt(0)},
+	)
+	s.assertBalance(s.stray, buyer.address(), shiftLeft(100, 18))
+	committed, err := s.auction.Committed(nil, s.strayAddress)
+	s.Require().NoError(err)
+	s.Equal("0", committed.String())
+}
+
+// TestCloseAuction tests that a lot can be closed by anyone once it has ended, and before by the
+// operator through the Manager, returning what is unsold to the Vault.
+func (s *DutchAuctionSuite) TestCloseAuction() {
+	startPrice, endPrice := shiftLeft(12, 17), shiftLeft(8, 17)
+	first := s.startAuction(shiftLeft(400, 18), startPrice, endPrice, time.Hour)
+	second := s.startAuction(shiftLeft(500, 18), startPrice, endPrice, time.Hour)
+	s.assertBalance(s.stray, s.vaultAddress, shiftLeft(100, 18))
+	buyer := s.account[2]
+	s.fundBidder(buyer)
+	s.requireTx(s.auction.Bid(signer(buyer), first, shiftLeft(150, 18), startPrice))
+
+	// Before the end, only the operator can close a lot, through the Manager.
+	s.requireTxFails(s.auction.Close(signer(buyer), first))
+	s.requireTxFails(s.auction.Close(s.signer, first))
+	s.requireTxFails(s.manager.CancelAuction(s.signer, second))
+	s.requireTxWithStrictEvents(s.manager.CancelAuction(signer(s.operator), second))(
+		abi.BasicERC20Transfer{From: s.auctionAddress, To: s.vaultAddress, Value: shiftLeft(500, 18)},
+		abi.DutchAuctionAuctionClosed{Id: second, Unsold: shiftLeft(500, 18)},
+	)
+	s.assertBalance(s.stray, s.vaultAddress, shiftLeft(600, 18))
+	s.requireTxFails(s.manager.CancelAuction(signer(s.operator), second))
+
+	// After the end, there are no bids, and anyone can close it.
+	s.Require().NoError(s.node.(backend).AdjustTime(time.Hour))
+	s.requireTxFails(s.auction.Bid(signer(buyer), first, shiftLeft(1, 18), startPrice))
+	s.requireTxWithStrictEvents(s.auction.Close(signer(buyer), first))(
+		abi.BasicERC20Transfer{From: s.auctionAddress, To: s.vaultAddress, Value: shiftLeft(250, 18)},
+		abi.DutchAuctionAuctionClosed{Id: first, Unsold: shiftLeft(250, 18)},
+	)
+	s.assertBalance(s.stray, s.vaultAddress, shiftLeft(850, 18))
+	s.assertBalance(s.stray, s.auctionAddress, bigInt(0))
+	committed, err := s.auction.Committed(nil, s.strayAddress)
+	s.Require().NoError(err)
+	s.Equal("0", committed.String())
+	s.assertManagerCollateralized()
+}
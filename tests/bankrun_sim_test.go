@@ -0,0 +1,295 @@
+// +build sim
+
+package tests
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/ops/sim"
+)
+
+func TestBankRunSim(t *testing.T) {
+	suite.Run(t, new(BankRunSimSuite))
+}
+
+// BankRunSimSuite plays redemption runs, drawn by ops/sim, against the Manager and Vault on a
+// fresh simulated backend each: holder agents redeem as they panic, and an arbitrageur issues
+// and redeems against the price of RSV. It reports how the backing and the fees are distributed
+// across the runs; see `make bankrun`.
+type BankRunSimSuite struct {
+	TestSuite
+	decimals []uint32
+
+	// holders are the accounts that the holder agents redeem from, each agent from the one at
+	// its index modulo their number, and arbitrageur the arbitrageur's.
+	holders     []account
+	arbitrageur account
+
+	// redemptionFees and issuanceFees are the fee recipients.
+	redemptionFees, issuanceFees account
+}
+
+var (
+	bankRuns          = flag.Int("bank-runs", 100, "redemption runs to simulate")
+	bankSeed          = flag.Int64("bank-seed", 1, "the seed of the first run, each next one's one more")
+	bankSteps         = flag.Int("bank-steps", 30, "rounds of decisions in each run")
+	bankHolders       = flag.Int("bank-holders", 50, "holders the supply is spread across")
+	bankDepeg         = flag.Float64("bank-depeg", 0.1, "how far the first basket token's price falls, as a fraction")
+	bankShock         = flag.Float64("bank-shock", 0.3, "the holders' panic when the run starts, from 0 to 1")
+	bankContagion     = flag.Float64("bank-contagion", 2, "how much the panic grows by for all of the supply redeemed in a step")
+	bankCalm          = flag.Float64("bank-calm", 0.2, "the share of the panic that fades each step")
+	bankNoise         = flag.Float64("bank-noise", 0.05, "standard deviation of the panic's random move each step")
+	bankRedeemShare   = flag.Float64("bank-redeem-share", 0.5, "the share of their RSV that a panicking holder redeems")
+	bankDiscount      = flag.Float64("bank-discount", 0.2, "how far under par RSV trades at full panic, as a fraction")
+	bankArbitrage     = flag.Float64("bank-arbitrage", 1, "the share of the supply that the arbitrageur trades for each unit of gap in price")
+	bankInventory     = flag.Float64("bank-inventory", 0.1, "the share of the supply that the arbitrageur starts with")
+	bankRedemptionFee = flag.Int("bank-redemption-fee", 10, "the Manager's redemption fee, in BPS")
+	bankIssuanceFee   = flag.Int("bank-issuance-fee", 10, "the Manager's issuance fee, in BPS")
+	bankDecimals      = flag.String("bank-decimals", "6,18,6", "decimals of each basket token, the first of which depegs")
+	bankReport        = flag.String("bank-report", "", "write the report to this file, as JSON")
+)
+
+// bankSupply is the RSV that the holders and the arbitrageur hold when each run starts: a million.
+var bankSupply = shiftLeft(1, 24)
+
+// SetupSuite runs once, before all of the tests in the suite.
+func (s *BankRunSimSuite) SetupSuite() {
+	s.decimals = nil
+	for _, d := range strings.Split(*bankDecimals, ",") {
+		n, err := strconv.ParseUint(d, 10, 32)
+		s.Require().NoError(err, "-bank-decimals")
+		s.decimals = append(s.decimals, uint32(n))
+	}
+}
+
+// deploy deploys the contracts to a new node, with the supply issued by the arbitrageur against
+// a basket of a dollar of the tokens, split evenly, and the fees set.
+func (s *BankRunSimSuite) deploy(p sim.BankRunParams) {
+	s.setup()
+	s.operator = s.account[1]
+	s.arbitrageur = s.account[5]
+	s.holders = []account{s.account[2], s.account[3], s.account[4], s.account[6], s.account[7]}
+	s.redemptionFees, s.issuanceFees = s.account[8], s.account[9]
+	s.deployReserve()
+
+	vaultAddress, tx, vault, err := abi.DeployVault(s.signer, s.node)
+	s.logParsers[vaultAddress] = vault
+	s.requireTx(tx, err)
+	s.vaultAddress, s.vault = vaultAddress, vault
+
+	propFactoryAddress, tx, propFactory, err := abi.DeployProposalFactory(s.signer, s.node)
+	s.logParsers[propFactoryAddress] = propFactory
+	s.requireTx(tx, err)
+
+	s.erc20s = make([]*abi.BasicERC20, len(s.decimals))
+	s.erc20Addresses = make([]common.Address, len(s.decimals))
+	weights := make([]*big.Int, len(s.decimals))
+	for i, d := range s.decimals {
+		erc20Address, tx, erc20, err := abi.DeployBasicERC20(s.signer, s.node)
+		s.logParsers[erc20Address] = erc20
+		s.requireTx(tx, err)
+		s.erc20s[i], s.erc20Addresses[i] = erc20, erc20Address
+		weights[i] = new(big.Int).Div(shiftLeft(1, 18+d), bigInt(uint32(len(s.decimals))))
+	}
+
+	basketAddress, tx, basket, err := abi.DeployBasket(s.signer, s.node, zeroAddress(), s.erc20Addresses, weights)
+	s.logParsers[basketAddress] = basket
+	s.requireTx(tx, err)
+	s.basketAddress, s.basket = basketAddress, basket
+
+	managerAddress, tx, manager, err := abi.DeployManager(
+		s.signer, s.node,
+		vaultAddress, s.reserveAddress, propFactoryAddress, basketAddress, s.operator.address(), bigInt(0),
+	)
+	s.logParsers[managerAddress] = manager
+	s.requireTx(tx, err)
+	s.managerAddress, s.manager = managerAddress, manager
+
+	s.requireTx(s.manager.SetEmergency(signer(s.operator), false))
+	s.requireTx(s.reserve.ChangeMinter(s.signer, managerAddress))
+	s.requireTx(s.vault.ChangeManager(s.signer, managerAddress))
+	s.requireTx(s.manager.SetRedemptionFeeRecipient(s.signer, s.redemptionFees.address()))
+	s.requireTx(s.manager.SetRedemptionFee(s.signer, bigInt(p.RedemptionFee)))
+	s.requireTx(s.manager.SetIssuanceFeeRecipient(s.signer, s.issuanceFees.address()))
+	s.requireTx(s.manager.SetIssuanceFee(s.signer, bigInt(p.IssuanceFee)))
+
+	var amounts []*big.Int
+	for range s.erc20s {
+		amounts = append(amounts, shiftLeft(1, 40))
+	}
+	s.fundAccountWithErc20sAndApprove(s.arbitrageur, amounts)
+	for _, acc := range append([]account{s.arbitrageur}, s.holders...) {
+		s.requireTx(s.reserve.Approve(signer(acc), managerAddress, shiftLeft(1, 40)))
+	}
+
+	// The issuance fee comes out of what the arbitrageur is minted, so issue enough for them to
+	// be left with bankSupply.
+	gross := new(big.Int).Mul(bankSupply, bigInt(10000))
+	gross.Div(gross, bigInt(10000-p.IssuanceFee))
+	s.requireTx(s.manager.Issue(signer(s.arbitrageur), gross))
+}
+
+// prices are the market prices of the basket tokens, in dollars, once the first has depegged.
+func (s *BankRunSimSuite) prices(p sim.BankRunParams) []float64 {
+	prices := make([]float64, len(s.decimals))
+	for i := range prices {
+		prices[i] = 1
+	}
+	prices[0] = 1 - p.Depeg
+	return prices
+}
+
+// valueOf returns what holder holds of the basket tokens, at prices, in dollars.
+func (s *BankRunSimSuite) valueOf(holder common.Address, prices []float64) float64 {
+	var value float64
+	for i, erc20 := range s.erc20s {
+		balance, err := erc20.BalanceOf(nil, holder)
+		s.Require().NoError(err)
+		value += sim.Value(balance, s.decimals[i], prices[i])
+	}
+	return value
+}
+
+// rsvOf returns acc's RSV.
+func (s *BankRunSimSuite) rsvOf(acc account) *big.Int {
+	balance, err := s.reserve.BalanceOf(nil, acc.address())
+	s.Require().NoError(err)
+	return balance
+}
+
+// redeem redeems amount RSV of acc's, or all of it if it has less, and returns how much.
+func (s *BankRunSimSuite) redeem(acc account, amount float64) float64 {
+	q := sim.Quantity(amount, 18)
+	if balance := s.rsvOf(acc); q.Cmp(balance) > 0 {
+		q = balance
+	}
+	if q.Sign() == 0 {
+		return 0
+	}
+	s.requireTx(s.manager.Redeem(signer(acc), q))
+	return sim.Value(q, 18, 1)
+}
+
+// play plays run on newly deployed contracts. The holder agents' RSV is spread across s.holders,
+// and each step, each account redeems what its agents decide to, after the arbitrageur trades.
+func (s *BankRunSimSuite) play(run *sim.BankRun) sim.BankRunOutcome {
+	p := run.Params
+	s.deploy(p)
+	prices := s.prices(p)
+	supply, err := s.reserve.TotalSupply(nil)
+	s.Require().NoError(err)
+	start := sim.Value(supply, 18, 1)
+	// The fee of the first issuance is the deployment's, not the run's.
+	startFees := s.rsvOf(s.issuanceFees)
+
+	// Hand out all but the arbitrageur's inventory to the holders.
+	holdings := run.Holdings()
+	balances := make([]float64, len(holdings))
+	perAccount := make([]float64, len(s.holders))
+	for i, share := range holdings {
+		balances[i] = share * sim.Value(bankSupply, 18, 1) * (1 - p.Inventory)
+		perAccount[i%len(s.holders)] += balances[i]
+	}
+	for i, acc := range s.holders {
+		amount := sim.Quantity(perAccount[i], 18)
+		if balance := s.rsvOf(s.arbitrageur); amount.Cmp(balance) > 0 {
+			amount = balance
+		}
+		s.requireTx(s.reserve.Transfer(signer(s.arbitrageur), acc.address(), amount))
+	}
+
+	outcome := sim.BankRunOutcome{Seed: run.Seed, MinBacking: math.Inf(1)}
+	var redeemed, issued float64
+	for step := 0; step < p.Steps; step++ {
+		supply, err = s.reserve.TotalSupply(nil)
+		s.Require().NoError(err)
+		market := sim.Market{
+			Backing:     s.valueOf(s.vaultAddress, prices) / sim.Value(supply, 18, 1),
+			Supply:      sim.Value(supply, 18, 1),
+			Holders:     balances,
+			Arbitrageur: sim.Value(s.rsvOf(s.arbitrageur), 18, 1),
+		}
+		outcome.MinBacking = math.Min(outcome.MinBacking, market.Backing)
+		outcome.PeakPanic = math.Max(outcome.PeakPanic, run.Panic)
+		decisions := run.Step(market)
+
+		if q := sim.Quantity(decisions.Issue, 18); q.Sign() > 0 {
+			s.requireTx(s.manager.Issue(signer(s.arbitrageur), q))
+			issued += sim.Value(q, 18, 1)
+		}
+		if decisions.ArbitrageRedeem > 0 {
+			redeemed += s.redeem(s.arbitrageur, decisions.ArbitrageRedeem)
+		}
+		toRedeem := make([]float64, len(s.holders))
+		for i, amount := range decisions.Redeem {
+			toRedeem[i%len(s.holders)] += amount
+			balances[i] -= amount
+		}
+		for i, acc := range s.holders {
+			redeemed += s.redeem(acc, toRedeem[i])
+		}
+		// However the run goes, the Vault stays collateralized.
+		s.assertManagerCollateralized()
+	}
+
+	supply, err = s.reserve.TotalSupply(nil)
+	s.Require().NoError(err)
+	outcome.FinalBacking = s.valueOf(s.vaultAddress, prices) / sim.Value(supply, 18, 1)
+	outcome.MinBacking = math.Min(outcome.MinBacking, outcome.FinalBacking)
+	outcome.Redeemed = redeemed / start
+	outcome.Issued = issued / start
+	outcome.FinalSupply = sim.Value(supply, 18, 1) / start
+
+	// The redemption fee is paid in collateral, and the issuance fee in RSV, as backed as the
+	// rest of it.
+	fees := s.valueOf(s.redemptionFees.address(), prices)
+	fees += sim.Value(new(big.Int).Sub(s.rsvOf(s.issuanceFees), startFees), 18, outcome.FinalBacking)
+	outcome.Fees = fees / start
+	return outcome
+}
+
+// TestBankRuns plays -bank-runs redemption runs, and prints the report of them.
+func (s *BankRunSimSuite) TestBankRuns() {
+	params := sim.BankRunParams{
+		Steps:         *bankSteps,
+		Holders:       *bankHolders,
+		Depeg:         *bankDepeg,
+		Shock:         *bankShock,
+		Contagion:     *bankContagion,
+		Calm:          *bankCalm,
+		Noise:         *bankNoise,
+		RedeemShare:   *bankRedeemShare,
+		Discount:      *bankDiscount,
+		Arbitrage:     *bankArbitrage,
+		Inventory:     *bankInventory,
+		RedemptionFee: uint32(*bankRedemptionFee),
+		IssuanceFee:   uint32(*bankIssuanceFee),
+	}
+	s.Require().NoError(params.Check())
+
+	var outcomes []sim.BankRunOutcome
+	for i := 0; i < *bankRuns; i++ {
+		outcomes = append(outcomes, s.play(params.Start(*bankSeed+int64(i))))
+	}
+
+	report := sim.NewBankRunReport(params, outcomes)
+	fmt.Printf("\nRedemption runs on a basket of tokens of %v decimals:\n", s.decimals)
+	fmt.Print(report.Format())
+	if *bankReport != "" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		s.Require().NoError(err)
+		s.Require().NoError(ioutil.WriteFile(*bankReport, append(b, '\n'), 0644))
+	}
+}
@@ -1,4 +1,4 @@
-// +build all fuzz
+// +build all fuzz fork sim
 
 package tests
 
@@ -26,6 +26,7 @@ import (
 	"github.com/stretchr/testify/suite"
 
 	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/ops/scribble"
 	"github.com/reserve-protocol/rsv-beta/soltools"
 )
 
@@ -160,6 +161,10 @@ func (s *TestSuite) _requireTxStatus(tx *types.Transaction, err error, status ui
 	receipt, err := bind.WaitMined(context.Background(), s.node, tx)
 	s.Require().NoError(err)
 	s.Require().Equal(status, receipt.Status)
+	// Contracts instrumented by rsvscribble log their violated annotations rather than revert.
+	for _, violation := range scribble.Violations(receipt.Logs) {
+		s.Failf("Scribble annotation violated", "%v", violation)
+	}
 	return receipt
 }
 
@@ -207,6 +212,46 @@ func (s *TestSuite) assertBasket(basket *abi.Basket, tokens []common.Address, we
 	}
 }
 
+// erc165 is the ERC-165 interface, which every contract with supportsInterface supports.
+var erc165 = []string{"supportsInterface(bytes4)"}
+
+// assertInterfaces asserts that supports, a contract's supportsInterface, reports each of
+// interfaces, and not 0xffffffff, which ERC-165 reserves. Each interface is the signatures of
+// its functions, and its ID is worked out from contractABI, the contract's ABI, so that an
+// interface the contract advertises but no longer implements in full fails.
+func (s *TestSuite) assertInterfaces(
+	contractABI string,
+	supports func(*bind.CallOpts, [4]byte) (bool, error),
+	interfaces map[string][]string,
+) {
+	parsed, err := ethabi.JSON(strings.NewReader(contractABI))
+	s.Require().NoError(err)
+	methods := make(map[string]ethabi.Method)
+	for _, method := range parsed.Methods {
+		methods[method.Sig()] = method
+	}
+
+	for name, signatures := range interfaces {
+		var id [4]byte
+		for _, signature := range signatures {
+			method, ok := methods[signature]
+			if !s.Truef(ok, "%v: the ABI has no %v", name, signature) {
+				continue
+			}
+			for i, b := range method.Id() {
+				id[i] ^= b
+			}
+		}
+		supported, err := supports(nil, id)
+		s.Require().NoError(err)
+		s.Truef(supported, "%v (%x) is not supported", name, id)
+	}
+
+	supported, err := supports(nil, [4]byte{0xff, 0xff, 0xff, 0xff})
+	s.Require().NoError(err)
+	s.False(supported)
+}
+
 // currentTimestamp retrieves the current block time.
 func (s *TestSuite) currentTimestamp() *big.Int {
 	result := new(big.Int)
@@ -284,6 +329,10 @@ func (s *TestSuite) setup() {
 		"ff12e391b79415e941a94de3bf3a9aee577aed0731e297d5cfa0b8a1e02fa1d0",
 		"752dd9cf65e68cfaba7d60225cbdbc1f4729dd5e5507def72815ed0d8abc6249",
 		"efb595a0178eb79a8df953f87c5148402a224cdf725e88c0146727c6aceadccd",
+		"83c6d2cc5ddcf9711a6d59b417dc20eb48afd58d45290099e5987e3d768f328f",
+		"bb2d3f7c9583780a7d3904a2f55d792707c345f21de1bacb2d389934d82796b2",
+		"b2fd4d29c1390b71b8795ae81196bfd60293adf99f9d32a0aff06288fcdac55f",
+		"23cb7121166b9a2f93ae0b7c05bde02eae50d64449b2cbb42bc84e9d38d6cc89",
 	}
 	s.account = make([]account, len(keys))
 	for i, key := range keys {
@@ -355,6 +404,30 @@ func (b backend) AdjustTime(delta time.Duration) error {
 	return b.SimulatedBackend.AdjustTime(delta)
 }
 
+// mineBlocks mines n empty blocks.
+func (s *TestSuite) mineBlocks(n int) {
+	for i := 0; i < n; i++ {
+		s.node.(backend).Commit()
+	}
+}
+
+// nextBlockAt moves the chain's clock so that the next transaction is mined at time `t`,
+// which must be at least two blocks away.
+func (s *TestSuite) nextBlockAt(t *big.Int) {
+	before := s.currentTimestamp()
+	s.mineBlocks(1)
+	now := s.currentTimestamp()
+	spacing := bigInt(0).Sub(now, before)
+
+	// AdjustTime mines a block `spacing` plus the adjustment after this one, and the next
+	// transaction's block comes `spacing` after that.
+	adjustment := bigInt(0).Sub(t, now)
+	adjustment.Sub(adjustment, bigInt(0).Mul(spacing, bigInt(2)))
+	s.Require().True(adjustment.Sign() >= 0, "too late to mine at %v", t)
+	s.Require().NoError(s.node.(backend).AdjustTime(time.Duration(adjustment.Int64()) * time.Second))
+	s.Require().Equal(bigInt(0).Sub(t, spacing).String(), s.currentTimestamp().String())
+}
+
 // signer returns a *bind.TransactOpts that uses a's private key to sign transactions.
 func signer(a account) *bind.TransactOpts {
 	return bind.NewKeyedTransactor(a.key)
@@ -451,6 +524,52 @@ func (s *TestSuite) fundAccountWithErc20sAndApprove(acc account, amounts []*big.
 	}
 }
 
+// What MockFlashBorrower does with a loan.
+const (
+	flashRepay uint8 = iota
+	flashRepayAmountOnly
+	flashRefuse
+	flashIssue
+	flashRedeem
+	flashBorrowAgain
+	flashMint
+	flashBurn
+)
+
+// deployFlashBorrower deploys a MockFlashBorrower that borrows from the Reserve, and issues and
+// redeems through manager.
+func (s *TestSuite) deployFlashBorrower(manager common.Address) (common.Address, *abi.MockFlashBorrower) {
+	address, tx, borrower, err := abi.DeployMockFlashBorrower(s.signer, s.node, s.reserveAddress, manager)
+	s.logParsers[address] = borrower
+	s.requireTx(tx, err)
+	return address, borrower
+}
+
+// deployReserve deploys a Reserve to s.node as a deployment does, by upgrading from a
+// PreviousReserve whose eternal storage it takes over, and starts s.logParsers over with them.
+// The Reserve is unpaused, and the owner is its pauser and fee recipient, but it has no minter.
+func (s *TestSuite) deployReserve() {
+	oldReserveAddress, tx, oldReserve, err := abi.DeployPreviousReserve(s.signer, s.node)
+	s.logParsers = map[common.Address]logParser{
+		oldReserveAddress: oldReserve,
+	}
+	s.requireTx(tx, err)
+
+	s.eternalStorageAddress, err = oldReserve.GetEternalStorageAddress(nil)
+	s.Require().NoError(err)
+	s.eternalStorage, err = abi.NewReserveEternalStorage(s.eternalStorageAddress, s.node)
+	s.Require().NoError(err)
+	s.logParsers[s.eternalStorageAddress] = s.eternalStorage
+
+	s.reserveAddress, tx, s.reserve, err = abi.DeployReserve(s.signer, s.node)
+	s.logParsers[s.reserveAddress] = s.reserve
+	s.requireTx(tx, err)
+
+	s.requireTx(oldReserve.NominateNewOwner(s.signer, s.reserveAddress))
+	s.requireTx(s.reserve.AcceptUpgrade(s.signer, oldReserveAddress))
+	s.requireTx(s.eternalStorage.AcceptOwnership(s.signer))
+}
+
 func (s *TestSuite) changeBasketUsingWeightProposal(tokens []common.Address, weights []*big.Int) {
 	// Propose the new basket.
 	s.requireTx(s.manager.ProposeWeights(signer(s.proposer), tokens, weights))
@@ -0,0 +1,316 @@
+// +build all
+
+package tests
+
+import (
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/soltools"
+)
+
+func TestBridgeAdapter(t *testing.T) {
+	suite.Run(t, new(BridgeAdapterSuite))
+}
+
+// BridgeAdapterSuite tests the BridgeAdapter on the suite's chain, which plays an L2.
+type BridgeAdapterSuite struct {
+	TestSuite
+
+	adapter        *abi.BridgeAdapter
+	adapterAddress common.Address
+	bridge         account
+}
+
+var (
+	// Compile-time check that BridgeAdapterSuite implements the interfaces we think it does.
+	// If it does not implement these interfaces, then the corresponding setup and teardown
+	// functions will not actually run.
+	_ suite.BeforeTest       = &BridgeAdapterSuite{}
+	_ suite.SetupAllSuite    = &BridgeAdapterSuite{}
+	_ suite.TearDownAllSuite = &BridgeAdapterSuite{}
+)
+
+// remoteToken stands in for the RSV contract on L1, except in TestDepositOnL1MintOnL2.
+var remoteToken = common.BigToAddress(bigInt(0x11))
+
+// SetupSuite runs once, before all of the tests in the suite.
+func (s *BridgeAdapterSuite) SetupSuite() {
+	s.setup()
+}
+
+// TearDownSuite runs once, after all of the tests in the suite.
+func (s *BridgeAdapterSuite) TearDownSuite() {
+	if coverageEnabled {
+		// Write coverage profile to disk.
+		s.Assert().NoError(s.node.(*soltools.Backend).WriteCoverage())
+
+		// Close the node.js process.
+		s.Assert().NoError(s.node.(*soltools.Backend).Close())
+
+		// Process coverage profile into an HTML report.
+		if out, err := exec.Command("npx", "istanbul", "report", "html").CombinedOutput(); err != nil {
+			fmt.Println()
+			fmt.Println("I generated coverage information in coverage/coverage.json.")
+			fmt.Println("I tried to process it with `istanbul` to turn it into a readable report, but failed.")
+			fmt.Println("The error I got when running istanbul was:", err)
+			fmt.Println("Istanbul's output was:\n" + string(out))
+		}
+	}
+}
+
+// BeforeTest runs before each test in the suite.
+func (s *BridgeAdapterSuite) BeforeTest(suiteName, testName string) {
+	s.owner = s.account[0]
+	s.bridge = s.account[5]
+	s.deployReserve()
+	s.adapterAddress, s.adapter = s.deployAdapter(remoteToken)
+}
+
+// deployAdapter deploys a BridgeAdapter for the Reserve, operated by s.bridge, and makes it the
+// Reserve's minter. Its limits are left at zero.
+func (s *BridgeAdapterSuite) deployAdapter(remote common.Address) (common.Address, *abi.BridgeAdapter) {
+	address, tx, adapter, err := abi.DeployBridgeAdapter(
+		s.signer, s.node, s.reserveAddress, remote, s.bridge.address(),
+	)
+	s.logParsers[address] = adapter
+	s.requireTxWithStrictEvents(tx, err)(
+		abi.BridgeAdapterOwnershipTransferred{PreviousOwner: zeroAddress(), NewOwner: s.owner.address()},
+		abi.BridgeAdapterBridgeChanged{OldBridge: zeroAddress(), NewBridge: s.bridge.address()},
+	)
+	s.requireTxWithStrictEvents(s.reserve.ChangeMinter(s.signer, address))(
+		abi.ReserveMinterChanged{NewMinter: address},
+	)
+	return address, adapter
+}
+
+// setLimits sets the adapter's mint and burn limits.
+func (s *BridgeAdapterSuite) setLimits(adapter *abi.BridgeAdapter, mintLimit, burnLimit uint32) {
+	s.requireTx(adapter.ChangeMintLimit(s.signer, bigInt(mintLimit)))
+	s.requireTx(adapter.ChangeBurnLimit(s.signer, bigInt(burnLimit)))
+}
+
+// depositID returns an ID for the nth deposit of a test.
+func depositID(n uint32) [32]byte {
+	return common.BigToHash(bigInt(n))
+}
+
+func (s *BridgeAdapterSuite) TestDeploy() {}
+
+// TestConstructor tests that the constructor sets initial state appropriately.
+func (s *BridgeAdapterSuite) TestConstructor() {
+	owner, err := s.adapter.Owner(nil)
+	s.Require().NoError(err)
+	s.Equal(s.owner.address(), owner)
+
+	rsv, err := s.adapter.TrustedRSV(nil)
+	s.Require().NoError(err)
+	s.Equal(s.reserveAddress, rsv)
+
+	remote, err := s.adapter.RemoteToken(nil)
+	s.Require().NoError(err)
+	s.Equal(remoteToken, remote)
+
+	bridge, err := s.adapter.Bridge(nil)
+	s.Require().NoError(err)
+	s.Equal(s.bridge.address(), bridge)
+
+	// Nothing can be minted or burned until the owner sets the limits.
+	mintable, err := s.adapter.MintableInWindow(nil)
+	s.Require().NoError(err)
+	s.Equal("0", mintable.String())
+	burnable, err := s.adapter.BurnableInWindow(nil)
+	s.Require().NoError(err)
+	s.Equal("0", burnable.String())
+	s.requireTxFails(s.adapter.Mint(signer(s.bridge), depositID(1), s.account[1].address(), bigInt(1)))
+}
+
+func (s *BridgeAdapterSuite) TestChangeBridge() {
+	newBridge := s.account[4]
+	s.requireTxWithStrictEvents(s.adapter.ChangeBridge(s.signer, newBridge.address()))(
+		abi.BridgeAdapterBridgeChanged{OldBridge: s.bridge.address(), NewBridge: newBridge.address()},
+	)
+	bridge, err := s.adapter.Bridge(nil)
+	s.Require().NoError(err)
+	s.Equal(newBridge.address(), bridge)
+
+	// Only the new bridge can mint.
+	s.setLimits(s.adapter, 1000, 1000)
+	recipient := s.account[1].address()
+	s.requireTxFails(s.adapter.Mint(signer(s.bridge), depositID(1), recipient, bigInt(1)))
+	s.requireTx(s.adapter.Mint(signer(newBridge), depositID(1), recipient, bigInt(1)))
+
+	// The zero address stops all bridging.
+	s.requireTx(s.adapter.ChangeBridge(s.signer, zeroAddress()))
+	s.requireTxFails(s.adapter.Mint(signer(newBridge), depositID(2), recipient, bigInt(1)))
+}
+
+func (s *BridgeAdapterSuite) TestChangeLimits() {
+	s.requireTxWithStrictEvents(s.adapter.ChangeMintLimit(s.signer, bigInt(1000)))(
+		abi.BridgeAdapterMintLimitChanged{OldVal: bigInt(0), NewVal: bigInt(1000)},
+	)
+	s.requireTxWithStrictEvents(s.adapter.ChangeBurnLimit(s.signer, bigInt(500)))(
+		abi.BridgeAdapterBurnLimitChanged{OldVal: bigInt(0), NewVal: bigInt(500)},
+	)
+
+	mintLimit, err := s.adapter.MintLimit(nil)
+	s.Require().NoError(err)
+	s.Equal("1000", mintLimit.String())
+	burnLimit, err := s.adapter.BurnLimit(nil)
+	s.Require().NoError(err)
+	s.Equal("500", burnLimit.String())
+}
+
+// TestOnlyOwner checks that only the owner can change the bridge or the limits.
+func (s *BridgeAdapterSuite) TestOnlyOwner() {
+	for _, a := range []account{s.bridge, s.account[1]} {
+		s.requireTxFails(s.adapter.ChangeBridge(signer(a), a.address()))
+		s.requireTxFails(s.adapter.ChangeMintLimit(signer(a), bigInt(1)))
+		s.requireTxFails(s.adapter.ChangeBurnLimit(signer(a), bigInt(1)))
+	}
+}
+
+// TestOnlyBridge checks that only the bridge can mint and burn, not even the owner.
+func (s *BridgeAdapterSuite) TestOnlyBridge() {
+	s.setLimits(s.adapter, 1000, 1000)
+	holder := s.account[1]
+	s.requireTx(s.adapter.Mint(signer(s.bridge), depositID(1), holder.address(), bigInt(100)))
+	s.requireTx(s.reserve.Approve(signer(holder), s.adapterAddress, bigInt(100)))
+
+	for _, a := range []account{s.owner, holder} {
+		s.requireTxFails(s.adapter.Mint(signer(a), depositID(2), holder.address(), bigInt(1)))
+		s.requireTxFails(s.adapter.Burn(signer(a), holder.address(), bigInt(1)))
+	}
+	s.assertRSVTotalSupply(bigInt(100))
+}
+
+func (s *BridgeAdapterSuite) TestMint() {
+	s.setLimits(s.adapter, 1000, 1000)
+	recipient := s.account[1].address()
+
+	s.requireTxWithStrictEvents(s.adapter.Mint(signer(s.bridge), depositID(1), recipient, bigInt(300)))(
+		mintingTransfer(recipient, bigInt(300)),
+		abi.BridgeAdapterBridgeMinted{DepositId: depositID(1), To: recipient, Amount: bigInt(300)},
+	)
+	s.assertRSVBalance(recipient, bigInt(300))
+
+	minted, err := s.adapter.Minted(nil, depositID(1))
+	s.Require().NoError(err)
+	s.True(minted)
+
+	// A deposit is minted only once, even if the bridge relays it again.
+	s.requireTxFails(s.adapter.Mint(signer(s.bridge), depositID(1), recipient, bigInt(300)))
+	s.assertRSVBalance(recipient, bigInt(300))
+}
+
+// TestMintLimit checks mints at and across the limit, and that a new window restores it.
+func (s *BridgeAdapterSuite) TestMintLimit() {
+	s.setLimits(s.adapter, 1000, 1000)
+	recipient := s.account[1].address()
+
+	s.requireTx(s.adapter.Mint(signer(s.bridge), depositID(1), recipient, bigInt(600)))
+	s.requireTxFails(s.adapter.Mint(signer(s.bridge), depositID(2), recipient, bigInt(401)))
+	s.requireTx(s.adapter.Mint(signer(s.bridge), depositID(2), recipient, bigInt(400)))
+	s.requireTxFails(s.adapter.Mint(signer(s.bridge), depositID(3), recipient, bigInt(1)))
+
+	mintable, err := s.adapter.MintableInWindow(nil)
+	s.Require().NoError(err)
+	s.Equal("0", mintable.String())
+
+	// Once the window ends, the whole limit is back.
+	s.Require().NoError(s.node.(backend).AdjustTime(24*time.Hour + time.Minute))
+	mintable, err = s.adapter.MintableInWindow(nil)
+	s.Require().NoError(err)
+	s.Equal("1000", mintable.String())
+	s.requireTx(s.adapter.Mint(signer(s.bridge), depositID(3), recipient, bigInt(1000)))
+	s.assertRSVBalance(recipient, bigInt(2000))
+}
+
+func (s *BridgeAdapterSuite) TestBurn() {
+	s.setLimits(s.adapter, 1000, 500)
+	holder := s.account[1]
+	s.requireTx(s.adapter.Mint(signer(s.bridge), depositID(1), holder.address(), bigInt(1000)))
+
+	// Burning takes an allowance to the adapter.
+	s.requireTxFails(s.adapter.Burn(signer(s.bridge), holder.address(), bigInt(100)))
+	s.requireTx(s.reserve.Approve(signer(holder), s.adapterAddress, bigInt(1000)))
+	s.requireTxWithStrictEvents(s.adapter.Burn(signer(s.bridge), holder.address(), bigInt(100)))(
+		burningTransfer(holder.address(), bigInt(100)),
+		abi.ReserveApproval{Owner: holder.address(), Spender: s.adapterAddress, Value: bigInt(900)},
+		abi.BridgeAdapterBridgeBurned{From: holder.address(), Amount: bigInt(100)},
+	)
+	s.assertRSVBalance(holder.address(), bigInt(900))
+	s.assertRSVTotalSupply(bigInt(900))
+
+	// The burn limit holds, separately from the mint limit.
+	s.requireTxFails(s.adapter.Burn(signer(s.bridge), holder.address(), bigInt(401)))
+	s.requireTx(s.adapter.Burn(signer(s.bridge), holder.address(), bigInt(400)))
+	burnable, err := s.adapter.BurnableInWindow(nil)
+	s.Require().NoError(err)
+	s.Equal("0", burnable.String())
+	s.assertRSVTotalSupply(bigInt(500))
+}
+
+// TestDepositOnL1MintOnL2 follows RSV over the bridge and back, between a second simulated
+// chain, playing L1, and the suite's chain, playing L2. On L1 the bridge keeps deposits in its
+// escrow, as canonical bridges do; the bridge operator relays each one to the adapter on L2,
+// which mints it, and releases withdrawals that the adapter has burned.
+func (s *BridgeAdapterSuite) TestDepositOnL1MintOnL2() {
+	// The L1 is always a fast node, even when the suite measures coverage.
+	l1 := &TestSuite{account: s.account, signer: s.signer, owner: s.owner}
+	l1.SetT(s.T())
+	l1.createFastNode()
+	l1.deployReserve()
+	l1.requireTx(l1.reserve.ChangeMinter(l1.signer, l1.owner.address()))
+
+	adapterAddress, adapter := s.deployAdapter(l1.reserveAddress)
+	s.setLimits(adapter, 1000, 1000)
+
+	holder := s.account[1]
+	escrow := s.account[4] // stands in for the L1 side of the bridge
+	l1.requireTx(l1.reserve.Mint(l1.signer, holder.address(), bigInt(500)))
+
+	// The holder deposits on L1.
+	tx, err := l1.reserve.Transfer(signer(holder), escrow.address(), bigInt(300))
+	receipt := l1._requireTxStatus(tx, err, types.ReceiptStatusSuccessful)
+
+	// The bridge operator finds the deposit on L1...
+	deposits, err := l1.reserve.FilterTransfer(
+		&bind.FilterOpts{Start: receipt.BlockNumber.Uint64()},
+		nil,
+		[]common.Address{escrow.address()},
+	)
+	s.Require().NoError(err)
+	s.Require().True(deposits.Next())
+	deposit := deposits.Event
+	s.False(deposits.Next())
+	s.Require().NoError(deposits.Error())
+	id := [32]byte(deposit.Raw.TxHash)
+
+	// ...and mints it on L2.
+	s.requireTxWithStrictEvents(adapter.Mint(signer(s.bridge), id, deposit.From, deposit.Value))(
+		mintingTransfer(holder.address(), bigInt(300)),
+		abi.BridgeAdapterBridgeMinted{DepositId: id, To: holder.address(), Amount: bigInt(300)},
+	)
+	s.assertRSVBalance(holder.address(), bigInt(300))
+	s.requireTxFails(adapter.Mint(signer(s.bridge), id, deposit.From, deposit.Value))
+
+	// The holder withdraws 100 on L2, and the bridge releases it on L1.
+	s.requireTx(s.reserve.Approve(signer(holder), adapterAddress, bigInt(100)))
+	s.requireTx(adapter.Burn(signer(s.bridge), holder.address(), bigInt(100)))
+	l1.requireTx(l1.reserve.Transfer(signer(escrow), holder.address(), bigInt(100)))
+
+	// All the RSV on L2 is backed by RSV in escrow on L1.
+	s.assertRSVTotalSupply(bigInt(200))
+	s.assertRSVBalance(holder.address(), bigInt(200))
+	l1.assertRSVBalance(escrow.address(), bigInt(200))
+	l1.assertRSVBalance(holder.address(), bigInt(300))
+}
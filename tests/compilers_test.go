@@ -0,0 +1,85 @@
+// +build solc
+
+package tests
+
+import (
+	"context"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/soltools"
+)
+
+var solcMatrix = flag.String("solc-matrix", "0.5.7:1000000", "the compiler settings to compare, the first being the baseline")
+
+// maxRuntimeSize is the most deployed bytecode a contract may have, per EIP-170.
+const maxRuntimeSize = 24576
+
+// compilerTargets are the contracts that are deployed, and their sources.
+var compilerTargets = []soltools.Target{
+	{Contract: "Basket", Source: "contracts/Basket.sol"},
+	{Contract: "Manager", Source: "contracts/Manager.sol"},
+	{Contract: "ProposalFactory", Source: "contracts/Proposal.sol"},
+	{Contract: "SwapProposal", Source: "contracts/Proposal.sol"},
+	{Contract: "WeightProposal", Source: "contracts/Proposal.sol"},
+	{Contract: "RebalanceProposal", Source: "contracts/Proposal.sol"},
+	{Contract: "Vault", Source: "contracts/Vault.sol"},
+	{Contract: "YieldVault", Source: "contracts/YieldVault.sol"},
+	{Contract: "Timelock", Source: "contracts/Timelock.sol"},
+	{Contract: "CollateralOracle", Source: "contracts/CollateralOracle.sol"},
+	{Contract: "DutchAuction", Source: "contracts/DutchAuction.sol"},
+	{Contract: "Upkeep", Source: "contracts/Upkeep.sol"},
+	{Contract: "CollateralRegistry", Source: "contracts/CollateralRegistry.sol"},
+	{Contract: "GuardianMultisig", Source: "contracts/GuardianMultisig.sol"},
+	{Contract: "InsuranceFund", Source: "contracts/InsuranceFund.sol"},
+	{Contract: "FeeTreasury", Source: "contracts/FeeTreasury.sol"},
+	{Contract: "Reserve", Source: "contracts/rsv/Reserve.sol"},
+	{Contract: "ReserveProxy", Source: "contracts/rsv/ReserveProxy.sol"},
+	{Contract: "ReserveEternalStorage", Source: "contracts/rsv/ReserveEternalStorage.sol"},
+	{Contract: "Relayer", Source: "contracts/rsv/Relayer.sol"},
+	{Contract: "BridgeAdapter", Source: "contracts/rsv/BridgeAdapter.sol"},
+	{Contract: "OFTAdapter", Source: "contracts/rsv/OFTAdapter.sol"},
+	{Contract: "RSVVotes", Source: "contracts/rsv/RSVVotes.sol"},
+}
+
+// TestCompilerMatrix compiles the deployed contracts with each of -solc-matrix and compares each
+// setting with the first. The ABIs must not change, since the bindings and the ops tools are
+// built from them, and no contract may outgrow EIP-170's limit; how the bytecode changes, it
+// logs.
+func TestCompilerMatrix(t *testing.T) {
+	settings, err := soltools.ParseSettings(*solcMatrix)
+	require.NoError(t, err)
+	repoDir := os.Getenv("REPO_DIR")
+	if repoDir == "" {
+		repoDir = ".."
+	}
+	m, err := soltools.CompileMatrix(context.Background(), repoDir, settings, compilerTargets)
+	require.NoError(t, err)
+
+	baseline := settings[0]
+	for _, s := range settings {
+		for _, target := range compilerTargets {
+			if size := m.Outputs[s][target.Contract].RuntimeSize(); size > maxRuntimeSize {
+				t.Errorf("%v: %v is %v bytes deployed, over the limit of %v", s, target.Contract, size, maxRuntimeSize)
+			}
+		}
+		if s == baseline {
+			continue
+		}
+		diffs, err := m.Diff(baseline, s)
+		require.NoError(t, err)
+		for _, d := range diffs {
+			for _, change := range d.ABI {
+				t.Errorf("%v: %v's ABI changed from %v: %v", s, d.Contract, baseline, change)
+			}
+			same := ""
+			if d.SameRuntime {
+				same = " (same bytecode)"
+			}
+			t.Logf("%v: %v is %v bytes deployed, %+d from %v%v", s, d.Contract, d.SizeTo, d.SizeTo-d.SizeFrom, baseline, same)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// +build all
+
+package tests
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/soltools"
+)
+
+func TestCreate2Deployer(t *testing.T) {
+	suite.Run(t, new(Create2DeployerSuite))
+}
+
+type Create2DeployerSuite struct {
+	TestSuite
+
+	deployer        *abi.Create2Deployer
+	deployerAddress common.Address
+}
+
+var (
+	// Compile-time check that Create2DeployerSuite implements the interfaces we think it does.
+	// If it does not implement these interfaces, then the corresponding setup and teardown
+	// functions will not actually run.
+	_ suite.BeforeTest       = &Create2DeployerSuite{}
+	_ suite.SetupAllSuite    = &Create2DeployerSuite{}
+	_ suite.TearDownAllSuite = &Create2DeployerSuite{}
+)
+
+// SetupSuite runs once, before all of the tests in the suite.
+func (s *Create2DeployerSuite) SetupSuite() {
+	s.setup()
+}
+
+// TearDownSuite runs once, after all of the tests in the suite.
+func (s *Create2DeployerSuite) TearDownSuite() {
+	if coverageEnabled {
+		// Write coverage profile to disk.
+		s.Assert().NoError(s.node.(*soltools.Backend).WriteCoverage())
+
+		// Close the node.js process.
+		s.Assert().NoError(s.node.(*soltools.Backend).Close())
+
+		// Process coverage profile into an HTML report.
+		if out, err := exec.Command("npx", "istanbul", "report", "html").CombinedOutput(); err != nil {
+			fmt.Println()
+			fmt.Println("I generated coverage information in coverage/coverage.json.")
+			fmt.Println("I tried to process it with `istanbul` to turn it into a readable report, but failed.")
+			fmt.Println("The error I got when running istanbul was:", err)
+			fmt.Println("Istanbul's output was:\n" + string(out))
+		}
+	}
+}
+
+// BeforeTest runs before each test in the suite.
+func (s *Create2DeployerSuite) BeforeTest(suiteName, testName string) {
+	s.owner = s.account[0]
+
+	deployerAddress, tx, deployer, err := abi.DeployCreate2Deployer(s.signer, s.node)
+	s.logParsers = map[common.Address]logParser{
+		deployerAddress: deployer,
+	}
+	s.requireTxWithStrictEvents(tx, err)()
+	s.deployer = deployer
+	s.deployerAddress = deployerAddress
+}
+
+// vaultInitCode is the init code for a Vault, which takes no constructor arguments.
+func vaultInitCode() []byte {
+	return hexutil.MustDecode(abi.VaultBin)
+}
+
+// expectedAddress computes the CREATE2 address independently of the contract.
+func (s *Create2DeployerSuite) expectedAddress(deployer common.Address, salt [32]byte, initCode []byte) common.Address {
+	fullSalt := crypto.Keccak256Hash(deployer.Bytes(), salt[:])
+	return crypto.CreateAddress2(s.deployerAddress, fullSalt, crypto.Keccak256(initCode))
+}
+
+func (s *Create2DeployerSuite) TestDeploy() {
+	salt := crypto.Keccak256Hash([]byte("rsv"))
+	expected := s.expectedAddress(s.owner.address(), salt, vaultInitCode())
+
+	computed, err := s.deployer.ComputeAddress(nil, s.owner.address(), salt, vaultInitCode())
+	s.Require().NoError(err)
+	s.Equal(expected, computed)
+
+	vault, err := abi.NewVault(expected, s.node)
+	s.Require().NoError(err)
+	s.logParsers[expected] = vault
+
+	s.requireTxWithStrictEvents(s.deployer.Deploy(s.signer, salt, vaultInitCode()))(
+		abi.VaultOwnershipTransferred{PreviousOwner: zeroAddress(), NewOwner: s.deployerAddress},
+		abi.VaultManagerTransferred{PreviousManager: zeroAddress(), NewManager: s.deployerAddress},
+		abi.Create2DeployerDeployed{Addr: expected, Deployer: s.owner.address(), Salt: salt},
+	)
+
+	deployedBy, err := s.deployer.DeployerOf(nil, expected)
+	s.Require().NoError(err)
+	s.Equal(s.owner.address(), deployedBy)
+
+	// The same salt and code can't be deployed twice.
+	s.requireTxFails(s.deployer.Deploy(s.signer, salt, vaultInitCode()))
+}
+
+func (s *Create2DeployerSuite) TestAddressDependsOnCaller() {
+	salt := crypto.Keccak256Hash([]byte("rsv"))
+	other := s.account[1]
+
+	mine, err := s.deployer.ComputeAddress(nil, s.owner.address(), salt, vaultInitCode())
+	s.Require().NoError(err)
+	theirs, err := s.deployer.ComputeAddress(nil, other.address(), salt, vaultInitCode())
+	s.Require().NoError(err)
+	s.NotEqual(mine, theirs)
+	s.Equal(s.expectedAddress(other.address(), salt, vaultInitCode()), theirs)
+}
+
+func (s *Create2DeployerSuite) TestExecute() {
+	salt := crypto.Keccak256Hash([]byte("rsv"))
+	vaultAddress := s.expectedAddress(s.owner.address(), salt, vaultInitCode())
+	vault, err := abi.NewVault(vaultAddress, s.node)
+	s.Require().NoError(err)
+	s.logParsers[vaultAddress] = vault
+	s.requireTx(s.deployer.Deploy(s.signer, salt, vaultInitCode()))
+
+	vaultABI, err := ethabi.JSON(strings.NewReader(abi.VaultABI))
+	s.Require().NoError(err)
+	nominate, err := vaultABI.Pack("nominateNewOwner", s.owner.address())
+	s.Require().NoError(err)
+
+	// Only the account that deployed the vault can act for the deployer.
+	other := s.account[1]
+	s.requireTxFails(s.deployer.Execute(signer(other), vaultAddress, nominate))
+
+	// The deploying account hands ownership to itself.
+	s.requireTxWithStrictEvents(s.deployer.Execute(s.signer, vaultAddress, nominate))(
+		abi.VaultNewOwnerNominated{PreviousOwner: s.deployerAddress, Nominee: s.owner.address()},
+		abi.Create2DeployerExecuted{Target: vaultAddress, Deployer: s.owner.address(), Data: nominate},
+	)
+	s.requireTxWithStrictEvents(vault.AcceptOwnership(s.signer))(
+		abi.VaultOwnershipTransferred{PreviousOwner: s.deployerAddress, NewOwner: s.owner.address()},
+	)
+
+	// A call that reverts makes execute revert.
+	s.requireTxFails(s.deployer.Execute(s.signer, vaultAddress, nominate))
+}
@@ -0,0 +1,328 @@
+// +build fork
+
+package tests
+
+import (
+	"context"
+	"flag"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/ops/authorize"
+	keys "github.com/reserve-protocol/rsv-beta/ops/signer"
+)
+
+// The fork tests run against a fork of mainnet, whose accounts include those of the test
+// mnemonic, e.g. one started with
+//
+//	ganache-cli --fork <mainnet node> -m "concert load couple harbor equip island argue ramp clarify fence smart topic"
+var forkRPC = flag.String("fork-rpc", "http://localhost:8545", "JSON-RPC endpoint of a mainnet fork")
+
+func TestFork(t *testing.T) {
+	suite.Run(t, new(ForkSuite))
+}
+
+type ForkSuite struct {
+	TestSuite
+}
+
+var (
+	// Compile-time check that ForkSuite implements the interfaces we think it does.
+	// If it does not implement these interfaces, then the corresponding setup and teardown
+	// functions will not actually run.
+	_ suite.SetupAllSuite = &ForkSuite{}
+)
+
+// SetupSuite runs once, before all of the tests in the suite.
+func (s *ForkSuite) SetupSuite() {
+	s.setup()
+	s.owner = s.account[0]
+	s.logParsers = map[common.Address]logParser{}
+
+	client, err := ethclient.Dial(*forkRPC)
+	s.Require().NoError(err)
+	s.node = client
+}
+
+// mainnetFeed is a Chainlink dollar feed of a mainnet stablecoin.
+type mainnetFeed struct {
+	name       string
+	token      common.Address
+	decimals   uint8
+	aggregator common.Address
+}
+
+var mainnetFeeds = []mainnetFeed{
+	{
+		"USDC/USD",
+		common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), 6,
+		common.HexToAddress("0x8fFfFfd4AfB6115b954Bd326cbe7B4BA576818f6"),
+	},
+	{
+		"USDT/USD",
+		common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7"), 6,
+		common.HexToAddress("0x3E7d1eAB13ad0104d2750B8863b489D65364e32D"),
+	},
+	{
+		"DAI/USD",
+		common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), 18,
+		common.HexToAddress("0xAed0c38402a5d19df6E4c03F4E2DceD6e29c1ee9"),
+	},
+}
+
+// TestCollateralOracleOnMainnetFeeds tests that the CollateralOracle reads the mainnet feeds of
+// the stablecoins, as their AggregatorV3Interface.
+func (s *ForkSuite) TestCollateralOracleOnMainnetFeeds() {
+	oracleAddress, tx, oracle, err := abi.DeployCollateralOracle(s.signer, s.node, bigInt(300))
+	s.logParsers[oracleAddress] = oracle
+	s.requireTx(tx, err)
+
+	// The feeds are updated at least daily, or on a deviation from their last answer.
+	heartbeat := big.NewInt(int64((25 * time.Hour) / time.Second))
+	for _, feed := range mainnetFeeds {
+		s.requireTx(oracle.SetFeed(s.signer, feed.token, feed.aggregator, heartbeat, feed.decimals))
+
+		// The price is the feed's answer, in 18 decimals.
+		aggregator, err := abi.NewMockAggregator(feed.aggregator, s.node)
+		s.Require().NoError(err)
+		round, err := aggregator.LatestRoundData(nil)
+		s.Require().NoError(err)
+		decimals, err := aggregator.Decimals(nil)
+		s.Require().NoError(err)
+		price, err := oracle.Price(nil, feed.token)
+		s.Require().NoError(err, feed.name)
+		expected := new(big.Int).Mul(round.Answer, shiftLeft(1, 18))
+		expected.Div(expected, shiftLeft(1, uint32(decimals)))
+		s.Equal(expected.String(), price.String(), feed.name)
+
+		// A whole token is worth the price.
+		value, err := oracle.Value(nil, feed.token, shiftLeft(1, uint32(feed.decimals)))
+		s.Require().NoError(err, feed.name)
+		s.Equal(price.String(), value.String(), feed.name)
+	}
+}
+
+var (
+	// sDAI is Spark's Savings DAI, an ERC-4626 vault of DAI earning the DAI Savings Rate.
+	sDAI = common.HexToAddress("0x83F20F44975D03b1b09e64809B757c47f942BEeA")
+	dai  = common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F")
+	weth = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+
+	uniswapV2Router = common.HexToAddress("0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D")
+	uniswapV2ABI    = `[{"type":"function","name":"swapExactETHForTokens","stateMutability":"payable",
+		"inputs":[{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},
+		{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],
+		"outputs":[{"name":"amounts","type":"uint256[]"}]}]`
+)
+
+// buyDAI swaps `eth` wei for DAI on Uniswap, for s.owner.
+func (s *ForkSuite) buyDAI(eth *big.Int) {
+	parsed, err := ethabi.JSON(strings.NewReader(uniswapV2ABI))
+	s.Require().NoError(err)
+	router := bind.NewBoundContract(uniswapV2Router, parsed, s.node, s.node, s.node)
+
+	opts := *s.signer
+	opts.Value = eth
+	deadline := big.NewInt(time.Now().Add(time.Hour).Unix())
+	s.requireTx(router.Transact(&opts, "swapExactETHForTokens",
+		big.NewInt(0), []common.Address{weth, dai}, s.owner.address(), deadline,
+	))
+}
+
+// TestYieldVaultOnSDAI tests that a YieldVault deposits DAI into sDAI, tracks its shares as sDAI
+// counts them, and withdraws the DAI again. A MockManager stands in for the Manager.
+func (s *ForkSuite) TestYieldVaultOnSDAI() {
+	managerAddress, tx, manager, err := abi.DeployMockManager(s.signer, s.node)
+	s.logParsers[managerAddress] = manager
+	s.requireTx(tx, err)
+	vaultAddress, tx, vault, err := abi.DeployYieldVault(s.signer, s.node)
+	s.logParsers[vaultAddress] = vault
+	s.requireTx(tx, err)
+	s.requireTx(vault.ChangeManager(s.signer, managerAddress))
+	s.requireTx(vault.SetYieldSource(s.signer, sDAI, true))
+
+	daiToken, err := abi.NewBasicERC20(dai, s.node)
+	s.Require().NoError(err)
+	source, err := abi.NewMockYieldSource(sDAI, s.node)
+	s.Require().NoError(err)
+	s.logParsers[dai] = daiToken
+	s.logParsers[sDAI] = source
+
+	// Put 100 DAI in the Vault.
+	amount := shiftLeft(100, 18)
+	s.buyDAI(shiftLeft(1, 18))
+	s.requireTx(daiToken.Transfer(s.signer, vaultAddress, amount))
+
+	// Not while the Manager is undercollateralized.
+	s.requireTx(manager.SetFullyCollateralized(s.signer, false))
+	s.requireTxFails(vault.Deposit(s.signer, sDAI, amount))
+	s.requireTx(manager.SetFullyCollateralized(s.signer, true))
+
+	s.requireTx(vault.Deposit(s.signer, sDAI, amount))
+	held, err := vault.SharesHeld(nil, sDAI)
+	s.Require().NoError(err)
+	balance, err := source.BalanceOf(nil, vaultAddress)
+	s.Require().NoError(err)
+	s.Equal(balance.String(), held.String())
+	s.True(held.Sign() > 0)
+
+	// sDAI rounds against the depositor, by at most a wei, and only earns from there.
+	assets, err := vault.AssetsOf(nil, sDAI)
+	s.Require().NoError(err)
+	s.True(assets.Cmp(new(big.Int).Sub(amount, bigInt(1))) >= 0, "assets %v", assets)
+
+	s.requireTx(vault.Withdraw(s.signer, sDAI, held))
+	balance, err = daiToken.BalanceOf(nil, vaultAddress)
+	s.Require().NoError(err)
+	s.True(balance.Cmp(new(big.Int).Sub(amount, bigInt(2))) >= 0, "balance %v", balance)
+	held, err = vault.SharesHeld(nil, sDAI)
+	s.Require().NoError(err)
+	s.Equal("0", held.String())
+}
+
+// lzEndpoint is LayerZero's v1 endpoint on mainnet, and arbitrum its ID for Arbitrum.
+var (
+	lzEndpoint        = common.HexToAddress("0x66A71Dcef29A0fFBDBE3c6a460a3B5BC225Cd675")
+	arbitrum   uint16 = 110
+)
+
+// TestOFTAdapterOnLayerZeroEndpoint tests that a lockbox OFTAdapter sends RSV through the mainnet
+// LayerZero endpoint, paying the fee that the endpoint quotes. Nothing relays it from the fork,
+// so the test goes as far as the endpoint accepting the message.
+func (s *ForkSuite) TestOFTAdapterOnLayerZeroEndpoint() {
+	s.deployReserve()
+	s.requireTx(s.reserve.ChangeMinter(s.signer, s.owner.address()))
+	adapterAddress, tx, adapter, err := abi.DeployOFTAdapter(
+		s.signer, s.node, s.reserveAddress, lzEndpoint, true,
+	)
+	s.logParsers[adapterAddress] = adapter
+	s.requireTx(tx, err)
+
+	// Any address will do for the remote adapter, since nothing reaches it.
+	remote := s.account[3].address()
+	s.requireTx(adapter.SetTrustedRemoteAddress(s.signer, arbitrum, remote.Bytes()))
+
+	holder := s.account[1]
+	amount := shiftLeft(100, 18)
+	s.requireTx(s.reserve.Mint(s.signer, holder.address(), amount))
+	s.requireTx(s.reserve.Approve(signer(holder), adapterAddress, amount))
+
+	fees, err := adapter.EstimateSendFee(nil, arbitrum, holder.address().Bytes(), amount, false, []byte{})
+	s.Require().NoError(err)
+	s.True(fees.NativeFee.Sign() > 0)
+
+	opts := signer(holder)
+	opts.Value = fees.NativeFee
+	s.requireTx(adapter.SendFrom(
+		opts, holder.address(), arbitrum, holder.address().Bytes(), amount,
+		holder.address(), common.Address{}, []byte{},
+	))(
+		abi.OFTAdapterSendToChain{
+			DstChainId: arbitrum,
+			From:       holder.address(),
+			ToAddress:  holder.address().Bytes(),
+			Amount:     amount,
+		},
+	)
+	s.assertRSVBalance(adapterAddress, amount)
+	s.assertRSVBalance(holder.address(), bigInt(0))
+}
+
+var permit2ABI = `[{"type":"function","name":"DOMAIN_SEPARATOR","stateMutability":"view","inputs":[],
+	"outputs":[{"name":"","type":"bytes32"}]},
+	{"type":"function","name":"allowance","stateMutability":"view",
+	"inputs":[{"name":"owner","type":"address"},{"name":"token","type":"address"},{"name":"spender","type":"address"}],
+	"outputs":[{"name":"amount","type":"uint160"},{"name":"expiration","type":"uint48"},{"name":"nonce","type":"uint48"}]}]`
+
+// permit2Allowance is an allowance of the canonical Permit2.
+type permit2Allowance struct {
+	Amount, Expiration, Nonce *big.Int
+}
+
+// TestIssueWithPermit2OnCanonicalPermit2 tests that a Manager of a DAI basket issues RSV for
+// DAI pulled through the canonical Permit2, by a batch permit that the issuer signs with the
+// authorize package, and that the permit can't be used twice.
+func (s *ForkSuite) TestIssueWithPermit2OnCanonicalPermit2() {
+	parsed, err := ethabi.JSON(strings.NewReader(permit2ABI))
+	s.Require().NoError(err)
+	permit2 := bind.NewBoundContract(authorize.Permit2Address, parsed, s.node, s.node, s.node)
+
+	// The authorize package's domain is Permit2's on this chain.
+	chainID, err := s.node.(*ethclient.Client).ChainID(context.Background())
+	s.Require().NoError(err)
+	var separator [32]byte
+	s.Require().NoError(permit2.Call(nil, &separator, "DOMAIN_SEPARATOR"))
+	s.Require().Equal(common.Hash(separator), authorize.Permit2(chainID).Separator())
+
+	// A Manager of a basket of a DAI per RSV.
+	s.deployReserve()
+	var tx *types.Transaction
+	s.vaultAddress, tx, s.vault, err = abi.DeployVault(s.signer, s.node)
+	s.logParsers[s.vaultAddress] = s.vault
+	s.requireTx(tx, err)
+	s.proposalFactoryAddress, tx, s.proposalFactory, err = abi.DeployProposalFactory(s.signer, s.node)
+	s.logParsers[s.proposalFactoryAddress] = s.proposalFactory
+	s.requireTx(tx, err)
+	s.basketAddress, tx, s.basket, err = abi.DeployBasket(
+		s.signer, s.node, zeroAddress(), []common.Address{dai}, []*big.Int{shiftLeft(1, 36)},
+	)
+	s.logParsers[s.basketAddress] = s.basket
+	s.requireTx(tx, err)
+	s.managerAddress, tx, s.manager, err = abi.DeployManager(
+		s.signer, s.node,
+		s.vaultAddress, s.reserveAddress, s.proposalFactoryAddress, s.basketAddress, s.owner.address(), bigInt(0),
+	)
+	s.logParsers[s.managerAddress] = s.manager
+	s.requireTx(tx, err)
+	s.requireTx(s.manager.SetEmergency(s.signer, false))
+	s.requireTx(s.reserve.ChangeMinter(s.signer, s.managerAddress))
+	s.requireTx(s.vault.ChangeManager(s.signer, s.managerAddress))
+	s.requireTx(s.manager.SetPermit2(s.signer, authorize.Permit2Address))
+
+	// The issuer approves Permit2, once, and never the Manager.
+	daiToken, err := abi.NewBasicERC20(dai, s.node)
+	s.Require().NoError(err)
+	s.logParsers[dai] = daiToken
+	s.buyDAI(shiftLeft(1, 18))
+	s.requireTx(daiToken.Approve(s.signer, authorize.Permit2Address, shiftLeft(1, 46)))
+
+	rsvAmount := shiftLeft(100, 18)
+	var allowance permit2Allowance
+	s.Require().NoError(permit2.Call(nil, &allowance, "allowance", s.owner.address(), dai, s.managerAddress))
+	batch := authorize.PermitBatch{
+		Details: []authorize.PermitDetails{{
+			Token:      dai,
+			Amount:     rsvAmount,
+			Expiration: big.NewInt(time.Now().Add(time.Hour).Unix()),
+			Nonce:      allowance.Nonce,
+		}},
+		Spender:     s.managerAddress,
+		SigDeadline: big.NewInt(time.Now().Add(time.Hour).Unix()),
+	}
+	sig, err := authorize.Sign(context.Background(), keys.NewKey(s.owner.key), batch.Hash(authorize.Permit2(chainID)))
+	s.Require().NoError(err)
+	permitCall, err := batch.Call(s.owner.address(), sig)
+	s.Require().NoError(err)
+
+	s.requireTx(s.manager.IssueWithPermit2(s.signer, rsvAmount, permitCall))(
+		abi.ManagerIssuance{User: s.owner.address(), Amount: rsvAmount},
+	)
+	s.assertRSVBalance(s.owner.address(), rsvAmount)
+	balance, err := daiToken.BalanceOf(nil, s.vaultAddress)
+	s.Require().NoError(err)
+	s.Equal(rsvAmount.String(), balance.String())
+
+	// The permit's nonce is spent, and so is its allowance.
+	s.requireTxFails(s.manager.IssueWithPermit2(s.signer, rsvAmount, permitCall))
+	s.requireTxFails(s.manager.IssueWithPermit2(s.signer, rsvAmount, []byte{}))
+}
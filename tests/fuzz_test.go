@@ -51,11 +51,9 @@ var (
 func (s *ManagerFuzzSuite) SetupSuite() {
 	s.setup()
 	rand.Seed(time.Now().UnixNano())
-	for _, d := range strings.Split(*decimals, ",") {
-		dInt, err := strconv.Atoi(d)
-		s.Require().NoError(err)
-		s.decimals = append(s.decimals, uint32(dInt))
-	}
+	var err error
+	s.decimals, err = parseDecimals(*decimals)
+	s.Require().NoError(err)
 	s.numTokens = len(s.decimals)
 	s.addressToDecimals = make(map[common.Address]uint32)
 }
@@ -307,17 +305,27 @@ func (s *ManagerFuzzSuite) TestByFuzzing() {
 			s.printRoundingError(erc20Balances)
 		}
 
-		// Check our on-chain invariant.
-		s.assertManagerCollateralized()
-
-		// This is an off-chain calculation that should be identical to the on-chain one.
-		s.assertManagerCollateralizedOffChain()
+		// Check our invariants, on-chain and off-chain alike; see invariants_test.go.
+		s.assertInvariants()
 		fmt.Print("\n")
 	}
 }
 
 // ===================================== Helpers ===========================================
 
+// parseDecimals parses a comma-separated list of token decimals, such as -decimals.
+func parseDecimals(list string) ([]uint32, error) {
+	var decimals []uint32
+	for _, d := range strings.Split(list, ",") {
+		dInt, err := strconv.Atoi(d)
+		if err != nil {
+			return nil, err
+		}
+		decimals = append(decimals, uint32(dInt))
+	}
+	return decimals, nil
+}
+
 // chooseTokenSet chooses a subset of `tokens` using a binomial distribution.
 func (s *ManagerFuzzSuite) chooseTokenSet() ([]*abi.BasicERC20, []common.Address) {
 	var addresses []common.Address
@@ -573,7 +581,7 @@ func (s *ManagerFuzzSuite) printMetrics() {
 }
 
 // assertManagerCollateralizedOffChain is the same calculation that happens on-chain.
-func (s *ManagerFuzzSuite) assertManagerCollateralizedOffChain() {
+func (s *TestSuite) assertManagerCollateralizedOffChain() {
 	basketAddress, err := s.manager.TrustedBasket(nil)
 	s.Require().NoError(err)
 
@@ -0,0 +1,45 @@
+// +build all
+
+package tests
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/reserve-protocol/rsv-beta/ops/gas"
+)
+
+var gasReportPath = flag.String("gas-report", "", "write the gas that the gas benchmarks used to this file")
+
+// gasReport is the gas that the benchmarks have used in this run, by benchmark. The benchmarks
+// are the tests named Test...Gas; `rsvgas run` runs just them, and writes this to gas.json.
+var gasReport = gas.Report{}
+
+// recordGas requires that a transaction succeeds, records the gas that it used under name, and
+// returns it.
+func (s *TestSuite) recordGas(name string, tx *types.Transaction, err error) uint64 {
+	gasUsed := s._requireTxStatus(tx, err, types.ReceiptStatusSuccessful).GasUsed
+	if coverageEnabled {
+		// Instrumented contracts use more gas than the real ones; don't report it.
+		return gasUsed
+	}
+	gasReport[name] = gasUsed
+	return gasUsed
+}
+
+// TestMain runs the tests, then writes the gas report to -gas-report if they all passed.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	code := m.Run()
+	if code == 0 && *gasReportPath != "" {
+		if err := gas.WriteFile(*gasReportPath, gasReport); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
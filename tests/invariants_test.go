@@ -0,0 +1,183 @@
+// +build all fuzz
+
+package tests
+
+import (
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/ops/metrics"
+	"github.com/reserve-protocol/rsv-beta/ops/spec"
+	"github.com/reserve-protocol/rsv-beta/soltools"
+)
+
+// invariant is a property that must hold after every transaction, in Go for the TestSuite to
+// check and in Solidity for the fuzzing harness.
+type invariant struct {
+	soltools.Invariant
+	check func(s *TestSuite)
+}
+
+// invariants are the properties that the fuzz tests check after every step. `make harness`
+// writes the same properties into a harness for Echidna and Medusa, so keep each invariant's Go
+// check and its Solidity in step.
+var invariants = []invariant{
+	{
+		Invariant: soltools.Invariant{
+			Name:     "collateralized",
+			Doc:      "The Manager reports the Vault fully collateralized.",
+			Solidity: "return manager.isFullyCollateralized();",
+		},
+		check: (*TestSuite).assertManagerCollateralized,
+	},
+	{
+		Invariant: soltools.Invariant{
+			Name: "backed",
+			Doc:  "The Vault holds, of each basket token, the supply times its weight.",
+			Solidity: `
+				Basket basket = manager.trustedBasket();
+				if (basket.size() == 0) {
+					return false;
+				}
+				uint256 supply = reserve.totalSupply();
+				for (uint256 i = 0; i < basket.size(); i++) {
+					address token = basket.tokens(i);
+					uint256 balance = IERC20(token).balanceOf(address(vault));
+					// checking units: [attoRSV * aqToken/RSV] <= [qToken * 1e36]
+					if (supply.mul(basket.weights(token)) > balance.mul(1e36)) {
+						return false;
+					}
+				}
+				return true;
+			`,
+		},
+		check: (*TestSuite).assertManagerCollateralizedOffChain,
+	},
+	{
+		Invariant: soltools.Invariant{
+			Name:     "withinMaxSupply",
+			Doc:      "The supply is at most the Reserve's maxSupply.",
+			Solidity: "return reserve.totalSupply() <= reserve.maxSupply();",
+		},
+		check: (*TestSuite).assertWithinMaxSupply,
+	},
+}
+
+// assertInvariants asserts that every invariant holds, and every spec of invariants.spec.
+func (s *TestSuite) assertInvariants() {
+	for _, inv := range invariants {
+		inv.check(s)
+	}
+	s.assertSpecs()
+}
+
+// specs are the specs of invariants.spec, which rsvalert checks on chain too.
+var specs []*spec.Spec
+
+// assertSpecs asserts that every spec of invariants.spec holds of the deployment.
+func (s *TestSuite) assertSpecs() {
+	if specs == nil {
+		repoDir := os.Getenv("REPO_DIR")
+		if repoDir == "" {
+			repoDir = ".."
+		}
+		var err error
+		specs, err = spec.ReadFile(filepath.Join(repoDir, "invariants.spec"))
+		s.Require().NoError(err)
+	}
+	env := spec.FromState(s.readState())
+	for _, sp := range specs {
+		for _, f := range sp.Check(env) {
+			s.Fail(sp.Describe(f))
+		}
+	}
+}
+
+// readState reads the parts of the deployment's state that specs are about, as rsvalert reads
+// them on chain. The test tokens have no symbols or decimals, so specs name them by address.
+func (s *TestSuite) readState() *metrics.State {
+	var state metrics.State
+	var err error
+	state.Supply, err = s.reserve.TotalSupply(nil)
+	s.Require().NoError(err)
+	state.RSVDecimals, err = s.reserve.Decimals(nil)
+	s.Require().NoError(err)
+	state.Paused, err = s.reserve.Paused(nil)
+	s.Require().NoError(err)
+	state.TransfersPaused, err = s.reserve.TransfersPaused(nil)
+	s.Require().NoError(err)
+	state.IssuancePaused, err = s.manager.IssuancePaused(nil)
+	s.Require().NoError(err)
+	state.RedemptionPaused, err = s.manager.RedemptionPaused(nil)
+	s.Require().NoError(err)
+	state.Emergency, err = s.manager.Emergency(nil)
+	s.Require().NoError(err)
+	state.Vault = s.vaultAddress
+	state.Basket, err = s.manager.TrustedBasket(nil)
+	s.Require().NoError(err)
+
+	basket, err := abi.NewBasket(state.Basket, s.node)
+	s.Require().NoError(err)
+	tokens, err := basket.GetTokens(nil)
+	s.Require().NoError(err)
+	for _, token := range tokens {
+		t := metrics.Token{Address: token}
+		t.Weight, err = basket.Weights(nil, token)
+		s.Require().NoError(err)
+		erc20, err := abi.NewBasicERC20(token, s.node)
+		s.Require().NoError(err)
+		t.Balance, err = erc20.BalanceOf(nil, s.vaultAddress)
+		s.Require().NoError(err)
+		state.Tokens = append(state.Tokens, t)
+	}
+	return &state
+}
+
+// assertWithinMaxSupply asserts that the supply of RSV is at most the Reserve's maxSupply.
+func (s *TestSuite) assertWithinMaxSupply() {
+	supply, err := s.reserve.TotalSupply(nil)
+	s.Require().NoError(err)
+	maxSupply, err := s.reserve.MaxSupply(nil)
+	s.Require().NoError(err)
+	s.True(supply.Cmp(maxSupply) <= 0, "supply %v is over maxSupply %v", supply, maxSupply)
+}
+
+var harnessDir = flag.String("harness", "", "write the Echidna and Medusa harness of the invariants to this directory, relative to the repo")
+
+// TestWriteHarness writes the fuzzing harness of the invariants to -harness, with a basket of a
+// token for each of -decimals, evenly weighted. `make harness` runs it.
+func TestWriteHarness(t *testing.T) {
+	if *harnessDir == "" {
+		t.Skip("no -harness directory to write to")
+	}
+	digits, err := parseDecimals(*decimals)
+	require.NoError(t, err)
+
+	// Split the weight of a whole RSV evenly, the remainder going to the last token.
+	share := new(big.Int).Div(shiftLeft(1, 18), big.NewInt(int64(len(digits))))
+	rest := new(big.Int).Sub(shiftLeft(1, 18), new(big.Int).Mul(share, big.NewInt(int64(len(digits)))))
+	weights := make([]*big.Int, len(digits))
+	for i, d := range digits {
+		weight := new(big.Int).Set(share)
+		if i == len(digits)-1 {
+			weight.Add(weight, rest)
+		}
+		weights[i] = weight.Mul(weight, shiftLeft(1, d))
+	}
+
+	h := soltools.Harness{Contract: "ManagerHarness", Weights: weights}
+	for _, inv := range invariants {
+		h.Invariants = append(h.Invariants, inv.Invariant)
+	}
+	repoDir := os.Getenv("REPO_DIR")
+	if repoDir == "" {
+		repoDir = ".."
+	}
+	require.NoError(t, h.Write(repoDir, *harnessDir))
+}
@@ -3,13 +3,20 @@
 package tests
 
 import (
+	"context"
+	"fmt"
 	"math/big"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/ops/authorize"
+	"github.com/reserve-protocol/rsv-beta/ops/sizes"
 )
 
 func TestManager(t *testing.T) {
@@ -162,9 +169,10 @@ func (s *ManagerSuite) BeforeTest(suiteName, testName string) {
 	)
 
 	s.logParsers[managerAddress] = manager
-	s.requireTx(tx, err)(abi.ManagerOwnershipTransferred{
-		PreviousOwner: zeroAddress(), NewOwner: s.owner.address(),
-	})
+	s.requireTx(tx, err)(
+		abi.ManagerOwnershipTransferred{PreviousOwner: zeroAddress(), NewOwner: s.owner.address()},
+		abi.ManagerOperatorAdded{Account: s.operator.address()},
+	)
 	s.manager = manager
 	s.managerAddress = managerAddress
 
@@ -219,17 +227,26 @@ func (s *ManagerSuite) TestConstructor() {
 	s.Require().NoError(err)
 	s.Equal(s.proposalFactoryAddress, proposalFactory)
 
-	operator, err := s.manager.Operator(nil)
-	s.Require().NoError(err)
-	s.Equal(s.operator.address(), operator)
+	s.assertOperators(s.operator.address())
 
 	seigniorage, err := s.manager.Seigniorage(nil)
 	s.Require().NoError(err)
 	s.Equal(bigInt(0).String(), seigniorage.String())
 
+	vetoer, err := s.manager.Vetoer(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), vetoer)
+
 	// `emergency` is tested in `BeforeTest`
 }
 
+func (s *ManagerSuite) TestSupportsInterface() {
+	s.assertInterfaces(abi.ManagerABI, s.manager.SupportsInterface, map[string][]string{
+		"ERC-165": erc165,
+		"issuer":  {"issue(uint256)", "redeem(uint256)", "toIssue(uint256)", "toRedeem(uint256)"},
+	})
+}
+
 // TestSetIssuancePaused tests that `setIssuancePaused` changes the state as expected.
 func (s *ManagerSuite) TestSetIssuancePaused() {
 	// Confirm Issuance is Unpaused.
@@ -264,6 +281,74 @@ func (s *ManagerSuite) TestSetIssuancePausedIsProtected() {
 	s.requireTxFails(s.manager.SetIssuancePaused(s.signer, true))
 }
 
+// TestSetRedemptionPaused tests that `setRedemptionPaused` changes the state as expected.
+func (s *ManagerSuite) TestSetRedemptionPaused() {
+	paused, err := s.manager.RedemptionPaused(nil)
+	s.Require().NoError(err)
+	s.Equal(false, paused)
+
+	s.requireTxWithStrictEvents(s.manager.SetRedemptionPaused(signer(s.operator), true))(
+		abi.ManagerRedemptionPausedChanged{OldVal: false, NewVal: true},
+	)
+	paused, err = s.manager.RedemptionPaused(nil)
+	s.Require().NoError(err)
+	s.Equal(true, paused)
+
+	s.requireTxWithStrictEvents(s.manager.SetRedemptionPaused(signer(s.operator), false))(
+		abi.ManagerRedemptionPausedChanged{OldVal: true, NewVal: false},
+	)
+	paused, err = s.manager.RedemptionPaused(nil)
+	s.Require().NoError(err)
+	s.Equal(false, paused)
+}
+
+// TestSetRedemptionPausedIsProtected tests that `setRedemptionPaused` can only be called by the
+// operator.
+func (s *ManagerSuite) TestSetRedemptionPausedIsProtected() {
+	s.requireTxFails(s.manager.SetRedemptionPaused(signer(s.account[2]), true))
+	s.requireTxFails(s.manager.SetRedemptionPaused(s.signer, true))
+}
+
+// TestPauseFlags tests each combination of `issuancePaused`, `redemptionPaused`, and the
+// Reserve's `transfersPaused`: each stops just what it names.
+func (s *ManagerSuite) TestPauseFlags() {
+	amount := shiftLeft(1, 18)
+	s.requireTx(s.manager.Issue(signer(s.proposer), shiftLeft(10, 18)))
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, shiftLeft(10, 18)))
+
+	type flags struct{ issuance, redemption, transfers bool }
+	set := func(f flags) {
+		s.requireTx(s.manager.SetIssuancePaused(signer(s.operator), f.issuance))
+		s.requireTx(s.manager.SetRedemptionPaused(signer(s.operator), f.redemption))
+		if f.transfers {
+			s.requireTx(s.reserve.PauseTransfers(s.signer))
+		} else {
+			s.requireTx(s.reserve.UnpauseTransfers(s.signer))
+		}
+	}
+	check := func(paused bool, tx *types.Transaction, err error) {
+		if paused {
+			s.requireTxFails(tx, err)
+		} else {
+			s.requireTx(tx, err)
+		}
+	}
+
+	for i := 0; i < 8; i++ {
+		f := flags{issuance: i&1 != 0, redemption: i&2 != 0, transfers: i&4 != 0}
+		set(f)
+		check(f.issuance, s.manager.Issue(signer(s.proposer), amount))
+		check(f.redemption, s.manager.Redeem(signer(s.proposer), amount))
+		check(f.transfers, s.reserve.Transfer(signer(s.proposer), s.account[2].address(), bigInt(1)))
+	}
+
+	// Emergency redemption is never paused; it opens only while the Reserve is.
+	set(flags{issuance: true, redemption: true, transfers: true})
+	s.requireTx(s.reserve.Pause(s.signer))
+	s.requireTx(s.reserve.StartEmergencyRedemption(s.signer))
+	s.requireTx(s.manager.EmergencyRedeem(signer(s.proposer), amount))
+}
+
 // TestSetEmergency tests that `setEmergency` changes the state as expected.
 func (s *ManagerSuite) TestSetEmergency() {
 	// Confirm we being not in an emergency.
@@ -326,25 +411,126 @@ func (s *ManagerSuite) TestSetVaultIsProtected() {
 	s.requireTxFails(s.manager.SetVault(signer(s.operator), s.account[3].address()))
 }
 
-// TestSetOperator tests that `setOperator` manipulates state correctly.
-func (s *ManagerSuite) TestSetOperator() {
-	newOperator := s.account[5].address()
-	s.requireTxWithStrictEvents(s.manager.SetOperator(s.signer, newOperator))(
-		abi.ManagerOperatorChanged{
-			OldAccount: s.operator.address(), NewAccount: newOperator,
-		},
+// assertOperators asserts that the Manager's operators are exactly `operators`, in order.
+func (s *ManagerSuite) assertOperators(operators ...common.Address) {
+	length, err := s.manager.OperatorsLength(nil)
+	s.Require().NoError(err)
+	s.Require().Equal(bigInt(uint32(len(operators))).String(), length.String())
+	for i, operator := range operators {
+		found, err := s.manager.Operators(nil, bigInt(uint32(i)))
+		s.Require().NoError(err)
+		s.Equal(operator, found)
+
+		isOperator, err := s.manager.IsOperator(nil, operator)
+		s.Require().NoError(err)
+		s.True(isOperator)
+	}
+}
+
+// TestAddOperator tests that `addOperator` manipulates state correctly, and that every
+// operator may act as the operator.
+func (s *ManagerSuite) TestAddOperator() {
+	second, third := s.account[6], s.account[7]
+	s.requireTxWithStrictEvents(s.manager.AddOperator(s.signer, second.address()))(
+		abi.ManagerOperatorAdded{Account: second.address()},
 	)
+	s.requireTxWithStrictEvents(s.manager.AddOperator(s.signer, third.address()))(
+		abi.ManagerOperatorAdded{Account: third.address()},
+	)
+	s.assertOperators(s.operator.address(), second.address(), third.address())
 
-	// Check that state is correct.
-	foundOperator, err := s.manager.Operator(nil)
+	// The operators can share the work between them.
+	s.requireTxWithStrictEvents(s.manager.SetIssuancePaused(signer(second), true))(
+		abi.ManagerIssuancePausedChanged{OldVal: false, NewVal: true},
+	)
+	s.requireTxWithStrictEvents(s.manager.SetIssuancePaused(signer(third), false))(
+		abi.ManagerIssuancePausedChanged{OldVal: true, NewVal: false},
+	)
+	s.requireTx(s.manager.ProposeWeights(signer(s.proposer), s.erc20Addresses, s.weights))
+	proposalsLength, err := s.manager.ProposalsLength(nil)
+	s.Require().NoError(err)
+	s.requireTx(s.manager.CancelProposal(signer(third), bigInt(0).Sub(proposalsLength, bigInt(1))))
+
+	// Neither the zero address nor an operator can be added.
+	s.requireTxFails(s.manager.AddOperator(s.signer, zeroAddress()))
+	s.requireTxFails(s.manager.AddOperator(s.signer, second.address()))
+	s.assertOperators(s.operator.address(), second.address(), third.address())
+}
+
+// TestRemoveOperator tests that `removeOperator` manipulates state correctly, and that a removed
+// operator can no longer act as the operator while the others carry on.
+func (s *ManagerSuite) TestRemoveOperator() {
+	second, third := s.account[6], s.account[7]
+	s.requireTx(s.manager.AddOperator(s.signer, second.address()))
+	s.requireTx(s.manager.AddOperator(s.signer, third.address()))
+
+	// The last operator takes the place of the one removed.
+	s.requireTxWithStrictEvents(s.manager.RemoveOperator(s.signer, s.operator.address()))(
+		abi.ManagerOperatorRemoved{Account: s.operator.address()},
+	)
+	s.assertOperators(third.address(), second.address())
+	isOperator, err := s.manager.IsOperator(nil, s.operator.address())
 	s.Require().NoError(err)
-	s.Equal(newOperator, foundOperator)
+	s.False(isOperator)
+
+	// The removed operator is locked out at once, and the others are not.
+	s.requireTxFails(s.manager.SetIssuancePaused(signer(s.operator), true))
+	s.requireTx(s.manager.SetIssuancePaused(signer(second), true))
+
+	// An account that isn't an operator can't be removed.
+	s.requireTxFails(s.manager.RemoveOperator(s.signer, s.operator.address()))
+
+	// Removing the last operators leaves no one to act as the operator.
+	s.requireTx(s.manager.RemoveOperator(s.signer, third.address()))
+	s.requireTxWithStrictEvents(s.manager.RemoveOperator(s.signer, second.address()))(
+		abi.ManagerOperatorRemoved{Account: second.address()},
+	)
+	s.assertOperators()
+	s.requireTxFails(s.manager.SetIssuancePaused(signer(second), false))
+}
+
+// TestOperatorsAreProtected tests that `addOperator` and `removeOperator` can only be called by
+// owner.
+func (s *ManagerSuite) TestOperatorsAreProtected() {
+	s.requireTxFails(s.manager.AddOperator(signer(s.account[2]), s.account[5].address()))
+	s.requireTxFails(s.manager.AddOperator(signer(s.operator), s.account[5].address()))
+	s.requireTxFails(s.manager.RemoveOperator(signer(s.account[2]), s.operator.address()))
+	s.requireTxFails(s.manager.RemoveOperator(signer(s.operator), s.operator.address()))
+	s.assertOperators(s.operator.address())
 }
 
-// TestSetOperatorIsProtected tests that `setOperator` can only be called by owner.
-func (s *ManagerSuite) TestSetOperatorIsProtected() {
-	s.requireTxFails(s.manager.SetOperator(signer(s.account[2]), s.account[5].address()))
-	s.requireTxFails(s.manager.SetOperator(signer(s.operator), s.account[5].address()))
+// TestTimelockedBasketChange tests a basket change by a Timelock as the operator, which waits out
+// the Timelock's delay to accept and to execute the proposal.
+func (s *ManagerSuite) TestTimelockedBasketChange() {
+	timelockAddress, tl := s.deployTimelock()
+	s.requireTx(s.manager.AddOperator(s.signer, timelockAddress))
+	s.requireTx(s.manager.RemoveOperator(s.signer, s.operator.address()))
+
+	newWeights := []*big.Int{shiftLeft(6, 35), shiftLeft(1, 35), shiftLeft(3, 35)}
+	s.requireTx(s.manager.ProposeWeights(signer(s.proposer), s.erc20Addresses, newWeights))
+	proposalsLength, err := s.manager.ProposalsLength(nil)
+	s.Require().NoError(err)
+	proposalID := bigInt(0).Sub(proposalsLength, bigInt(1))
+	proposalAddress, err := s.manager.TrustedProposals(nil, proposalID)
+	s.Require().NoError(err)
+	proposal, err := abi.NewWeightProposal(proposalAddress, s.node)
+	s.Require().NoError(err)
+	s.logParsers[proposalAddress] = proposal
+	basketAddress, err := proposal.TrustedBasket(nil)
+	s.Require().NoError(err)
+	basket, err := abi.NewBasket(basketAddress, s.node)
+	s.Require().NoError(err)
+
+	// The old operator can no longer accept it.
+	s.requireTxFails(s.manager.AcceptProposal(signer(s.operator), proposalID))
+
+	s.throughTimelock(tl, s.timelockOperation(abi.ManagerABI, s.managerAddress, "acceptProposal", proposalID))(
+		abi.ManagerProposalAccepted{Id: proposalID, Proposer: s.proposer.address()},
+	)
+	s.throughTimelock(tl, s.timelockOperation(abi.ManagerABI, s.managerAddress, "executeProposal", proposalID))
+
+	s.assertBasket(basket, s.erc20Addresses, newWeights)
+	s.assertManagerCollateralized()
 }
 
 // TestSetSeigniorage tests that `setSeigniorage` manipulates state correctly.
@@ -375,6 +561,412 @@ func (s *ManagerSuite) TestSetSeigniorageRequires() {
 	s.requireTxFails(s.manager.SetSeigniorage(s.signer, seigniorage))
 }
 
+// TestSetRedemptionFee tests that `setRedemptionFee` manipulates state correctly.
+func (s *ManagerSuite) TestSetRedemptionFee() {
+	recipient := s.account[3].address()
+
+	// A fee needs a recipient.
+	s.requireTxFails(s.manager.SetRedemptionFee(s.signer, bigInt(10)))
+
+	s.requireTxWithStrictEvents(s.manager.SetRedemptionFeeRecipient(s.signer, recipient))(
+		abi.ManagerRedemptionFeeRecipientChanged{OldAccount: zeroAddress(), NewAccount: recipient},
+	)
+	s.requireTxWithStrictEvents(s.manager.SetRedemptionFee(s.signer, bigInt(10)))(
+		abi.ManagerRedemptionFeeChanged{OldVal: bigInt(0), NewVal: bigInt(10)},
+	)
+
+	// Check that state is correct.
+	fee, err := s.manager.RedemptionFee(nil)
+	s.Require().NoError(err)
+	s.Equal(bigInt(10).String(), fee.String())
+	foundRecipient, err := s.manager.RedemptionFeeRecipient(nil)
+	s.Require().NoError(err)
+	s.Equal(recipient, foundRecipient)
+
+	// Back to no fee.
+	s.requireTxWithStrictEvents(s.manager.SetRedemptionFee(s.signer, bigInt(0)))(
+		abi.ManagerRedemptionFeeChanged{OldVal: bigInt(10), NewVal: bigInt(0)},
+	)
+}
+
+// TestSetRedemptionFeeIsProtected tests that the redemption fee and its recipient can only be
+// set by the owner, and within bounds.
+func (s *ManagerSuite) TestSetRedemptionFeeIsProtected() {
+	recipient := s.account[3].address()
+	s.requireTxFails(s.manager.SetRedemptionFeeRecipient(signer(s.account[2]), recipient))
+	s.requireTxFails(s.manager.SetRedemptionFeeRecipient(signer(s.operator), recipient))
+	s.requireTxFails(s.manager.SetRedemptionFeeRecipient(s.signer, zeroAddress()))
+
+	s.requireTx(s.manager.SetRedemptionFeeRecipient(s.signer, recipient))
+	s.requireTxFails(s.manager.SetRedemptionFee(signer(s.account[2]), bigInt(10)))
+	s.requireTxFails(s.manager.SetRedemptionFee(signer(s.operator), bigInt(10)))
+	s.requireTxFails(s.manager.SetRedemptionFee(s.signer, bigInt(1001)))
+	s.requireTx(s.manager.SetRedemptionFee(s.signer, bigInt(1000)))
+}
+
+// deployInsuranceFund deploys an InsuranceFund for the Manager, owned by s.owner.
+func (s *ManagerSuite) deployInsuranceFund() (common.Address, *abi.InsuranceFund) {
+	address, tx, fund, err := abi.DeployInsuranceFund(s.signer, s.node, s.managerAddress)
+	s.logParsers[address] = fund
+	s.requireTxWithStrictEvents(tx, err)(
+		abi.InsuranceFundOwnershipTransferred{PreviousOwner: zeroAddress(), NewOwner: s.owner.address()},
+	)
+	return address, fund
+}
+
+// assertInsurance asserts the fund's accounting of `token`.
+func (s *ManagerSuite) assertInsurance(fund *abi.InsuranceFund, token common.Address, deposited, drawn, fees *big.Int) {
+	found, err := fund.Deposited(nil, token)
+	s.Require().NoError(err)
+	s.Equal(deposited.String(), found.String(), "deposited")
+	found, err = fund.Drawn(nil, token)
+	s.Require().NoError(err)
+	s.Equal(drawn.String(), found.String(), "drawn")
+	found, err = fund.FeesReceived(nil, token)
+	s.Require().NoError(err)
+	s.Equal(fees.String(), found.String(), "fees received")
+}
+
+// TestInsuranceFundAccounting tests that the fund tells the redemption fees it receives apart
+// from deposits.
+func (s *ManagerSuite) TestInsuranceFundAccounting() {
+	fundAddress, fund := s.deployInsuranceFund()
+	s.requireTx(s.manager.SetRedemptionFeeRecipient(s.signer, fundAddress))
+	s.requireTx(s.manager.SetRedemptionFee(s.signer, bigInt(10)))
+
+	rsvAmount := shiftLeft(1000, 18)
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, rsvAmount))
+	before := s.tokenBalances(s.vaultAddress, s.proposer.address())
+	s.requireTx(s.manager.Redeem(signer(s.proposer), rsvAmount))
+	after := s.tokenBalances(s.vaultAddress, s.proposer.address(), fundAddress)
+
+	// Whatever left the Vault and didn't reach the redeemer is the fund's fee.
+	fees := make([]*big.Int, len(s.erc20s))
+	for i, token := range s.erc20Addresses {
+		fees[i] = bigInt(0).Sub(before[0][i], after[0][i])
+		fees[i].Sub(fees[i], bigInt(0).Sub(after[1][i], before[1][i]))
+		s.True(fees[i].Sign() > 0)
+		s.Equal(fees[i].String(), after[2][i].String())
+		s.assertInsurance(fund, token, bigInt(0), bigInt(0), fees[i])
+	}
+
+	// Deposits are counted apart.
+	token := s.erc20Addresses[0]
+	amount := shiftLeft(5, 18)
+	s.requireTxFails(fund.Deposit(signer(s.proposer), token, amount))
+	s.requireTx(s.erc20s[0].Approve(signer(s.proposer), fundAddress, amount))
+	s.requireTxFails(fund.Deposit(signer(s.proposer), token, bigInt(0)))
+	s.requireTxWithStrictEvents(fund.Deposit(signer(s.proposer), token, amount))(
+		abi.BasicERC20Transfer{From: s.proposer.address(), To: fundAddress, Value: amount},
+		abi.BasicERC20Approval{Owner: s.proposer.address(), Spender: fundAddress, Value: bigInt(0)},
+		abi.InsuranceFundInsuranceDeposited{Token: token, From: s.proposer.address(), Amount: amount},
+	)
+	s.assertInsurance(fund, token, amount, bigInt(0), fees[0])
+	balance, err := fund.BalanceOf(nil, token)
+	s.Require().NoError(err)
+	s.Equal(bigInt(0).Add(fees[0], amount).String(), balance.String())
+}
+
+// TestInsuranceFundTopUp tests that the fund's owner can make good an impairment of the Vault's
+// backing, in basket tokens only.
+func (s *ManagerSuite) TestInsuranceFundTopUp() {
+	fundAddress, fund := s.deployInsuranceFund()
+	rsvAmount := shiftLeft(1000, 18)
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+	token, loss := s.erc20Addresses[1], shiftLeft(1, 18)
+	s.requireTx(s.erc20s[1].Approve(signer(s.proposer), fundAddress, shiftLeft(3, 18)))
+	s.requireTx(fund.Deposit(signer(s.proposer), token, shiftLeft(3, 18)))
+
+	// Lose some of the Vault's token1, as if to an exploit.
+	s.requireTx(s.vault.ChangeManager(s.signer, s.owner.address()))
+	s.requireTx(s.vault.WithdrawTo(s.signer, token, loss, s.owner.address()))
+	s.requireTx(s.vault.ChangeManager(s.signer, s.managerAddress))
+	collateralized, err := s.manager.IsFullyCollateralized(nil)
+	s.Require().NoError(err)
+	s.False(collateralized)
+	s.requireTxFails(s.manager.Redeem(signer(s.proposer), bigInt(1)))
+
+	// Only the owner tops up, only in basket tokens, and only with what the fund holds.
+	stray, strayToken := s.deployStrayToken()
+	s.requireTx(strayToken.Transfer(s.signer, fundAddress, bigInt(100)))
+	s.requireTxFails(fund.TopUp(signer(s.operator), token, loss, "exploit"))
+	s.requireTxFails(fund.TopUp(s.signer, stray, bigInt(100), "exploit"))
+	s.requireTxFails(fund.TopUp(s.signer, token, bigInt(0), "exploit"))
+	s.requireTxFails(fund.TopUp(s.signer, token, shiftLeft(4, 18), "exploit"))
+
+	s.requireTxWithStrictEvents(fund.TopUp(s.signer, token, loss, "exploit"))(
+		abi.BasicERC20Transfer{From: fundAddress, To: s.vaultAddress, Value: loss},
+		abi.InsuranceFundVaultToppedUp{Token: token, Vault: s.vaultAddress, Amount: loss, Reason: "exploit"},
+	)
+	collateralized, err = s.manager.IsFullyCollateralized(nil)
+	s.Require().NoError(err)
+	s.True(collateralized)
+	s.assertInsurance(fund, token, shiftLeft(3, 18), loss, bigInt(0))
+
+	// Redemption goes on.
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, rsvAmount))
+	s.requireTx(s.manager.Redeem(signer(s.proposer), rsvAmount))
+}
+
+// TestInsuranceFundSetManager tests that only the owner can change the fund's Manager.
+func (s *ManagerSuite) TestInsuranceFundSetManager() {
+	fundAddress, fund := s.deployInsuranceFund()
+	newManager := s.account[3].address()
+	s.requireTxFails(fund.SetManager(signer(s.operator), newManager))
+	s.requireTxWithStrictEvents(fund.SetManager(s.signer, newManager))(
+		abi.InsuranceFundManagerChanged{OldManager: s.managerAddress, NewManager: newManager},
+	)
+	manager, err := fund.Manager(nil)
+	s.Require().NoError(err)
+	s.Equal(newManager, manager)
+
+	// With no Manager to ask for the basket, it can't top up.
+	s.requireTx(s.erc20s[0].Transfer(signer(s.proposer), fundAddress, bigInt(1)))
+	s.requireTxFails(fund.TopUp(s.signer, s.erc20Addresses[0], bigInt(1), "none"))
+}
+
+// deployFeeTreasury deploys a FeeTreasury owned by s.owner, distributing each token at most
+// once an hour, to s.account[2..4] in shares of 33.33%, 33.33%, and 33.34%.
+func (s *ManagerSuite) deployFeeTreasury() (common.Address, *abi.FeeTreasury) {
+	address, tx, treasury, err := abi.DeployFeeTreasury(s.signer, s.node, bigInt(3600))
+	s.logParsers[address] = treasury
+	s.requireTxWithStrictEvents(tx, err)(
+		abi.FeeTreasuryOwnershipTransferred{PreviousOwner: zeroAddress(), NewOwner: s.owner.address()},
+	)
+	targets := []common.Address{s.account[2].address(), s.account[3].address(), s.account[4].address()}
+	shares := []*big.Int{bigInt(3333), bigInt(3333), bigInt(3334)}
+	s.requireTxWithStrictEvents(treasury.SetTargets(s.signer, targets, shares))(
+		abi.FeeTreasuryTargetsChanged{Targets: targets, Shares: shares},
+	)
+	return address, treasury
+}
+
+// assertClaimable asserts what each of s.account[2..4] may claim of `token`, and that the
+// treasury holds exactly what it has credited plus `undistributed`.
+func (s *ManagerSuite) assertClaimable(
+	treasury *abi.FeeTreasury, treasuryAddress, token common.Address, undistributed *big.Int, claimable ...*big.Int,
+) {
+	total := bigInt(0)
+	for i, want := range claimable {
+		found, err := treasury.Claimable(nil, token, s.account[2+i].address())
+		s.Require().NoError(err)
+		s.Equal(want.String(), found.String(), "claimable by target %v", i)
+		total.Add(total, want)
+	}
+	found, err := treasury.TotalClaimable(nil, token)
+	s.Require().NoError(err)
+	s.Equal(total.String(), found.String(), "total claimable")
+	found, err = treasury.Undistributed(nil, token)
+	s.Require().NoError(err)
+	s.Equal(undistributed.String(), found.String(), "undistributed")
+
+	erc20, err := abi.NewBasicERC20(token, s.node)
+	s.Require().NoError(err)
+	balance, err := erc20.BalanceOf(nil, treasuryAddress)
+	s.Require().NoError(err)
+	s.Equal(total.Add(total, undistributed).String(), balance.String(), "balance")
+}
+
+// TestFeeTreasurySetTargets tests that only the owner sets the targets, and only with shares
+// that total 100%.
+func (s *ManagerSuite) TestFeeTreasurySetTargets() {
+	_, treasury := s.deployFeeTreasury()
+	target0, target1 := s.account[2].address(), s.account[6].address()
+	s.requireTxFails(treasury.SetTargets(
+		signer(s.operator), []common.Address{target0}, []*big.Int{bigInt(10000)},
+	))
+	s.requireTxFails(treasury.SetTargets(
+		s.signer, []common.Address{target0, target1}, []*big.Int{bigInt(10000)},
+	))
+	s.requireTxFails(treasury.SetTargets(
+		s.signer, []common.Address{target0, zeroAddress()}, []*big.Int{bigInt(5000), bigInt(5000)},
+	))
+	s.requireTxFails(treasury.SetTargets(
+		s.signer, []common.Address{target0, target1}, []*big.Int{bigInt(10000), bigInt(0)},
+	))
+	s.requireTxFails(treasury.SetTargets(
+		s.signer, []common.Address{target0, target1}, []*big.Int{bigInt(5000), bigInt(4999)},
+	))
+	s.requireTxFails(treasury.SetTargets(
+		s.signer, []common.Address{target0, target1}, []*big.Int{bigInt(5000), bigInt(5001)},
+	))
+	length, err := treasury.TargetsLength(nil)
+	s.Require().NoError(err)
+	s.Equal("3", length.String())
+
+	targets := []common.Address{target1, target0}
+	shares := []*big.Int{bigInt(9000), bigInt(1000)}
+	s.requireTxWithStrictEvents(treasury.SetTargets(s.signer, targets, shares))(
+		abi.FeeTreasuryTargetsChanged{Targets: targets, Shares: shares},
+	)
+	length, err = treasury.TargetsLength(nil)
+	s.Require().NoError(err)
+	s.Equal("2", length.String())
+	for i := range targets {
+		target, err := treasury.Targets(nil, bigInt(uint32(i)))
+		s.Require().NoError(err)
+		s.Equal(targets[i], target)
+		share, err := treasury.Shares(nil, bigInt(uint32(i)))
+		s.Require().NoError(err)
+		s.Equal(shares[i].String(), share.String())
+	}
+
+	// Only the owner sets the interval.
+	s.requireTxFails(treasury.SetDistributionInterval(signer(s.operator), bigInt(60)))
+	s.requireTxWithStrictEvents(treasury.SetDistributionInterval(s.signer, bigInt(60)))(
+		abi.FeeTreasuryDistributionIntervalChanged{OldVal: bigInt(3600), NewVal: bigInt(60)},
+	)
+}
+
+// TestFeeTreasuryAccrual tests that distributions credit each target exactly its share of the
+// issuance fees, rounded down, and carry the dust over to the next distribution.
+func (s *ManagerSuite) TestFeeTreasuryAccrual() {
+	treasuryAddress, treasury := s.deployFeeTreasury()
+	s.requireTx(s.manager.SetIssuanceFeeRecipient(s.signer, treasuryAddress))
+	s.requireTx(s.manager.SetIssuanceFee(s.signer, bigInt(10)))
+
+	// Nothing to distribute yet.
+	s.requireTxFails(treasury.Distribute(signer(s.account[6]), s.reserveAddress))
+
+	// 0.1% of 1000.0123 RSV, and the targets' shares of it.
+	s.requireTx(s.manager.Issue(signer(s.proposer), big.NewInt(1000012300000000000)))
+	fee, err := s.reserve.BalanceOf(nil, treasuryAddress)
+	s.Require().NoError(err)
+	s.False(fee.Sign() == 0)
+	s.assertClaimable(treasury, treasuryAddress, s.reserveAddress, fee, bigInt(0), bigInt(0), bigInt(0))
+
+	bps := bigInt(10000)
+	share := func(amount *big.Int, bp uint32) *big.Int {
+		return bigInt(0).Div(bigInt(0).Mul(amount, bigInt(bp)), bps)
+	}
+	claimable := []*big.Int{share(fee, 3333), share(fee, 3333), share(fee, 3334)}
+	credited := bigInt(0).Add(claimable[0], bigInt(0).Add(claimable[1], claimable[2]))
+	dust := bigInt(0).Sub(fee, credited)
+
+	// Anyone may distribute.
+	s.requireTxWithStrictEvents(treasury.Distribute(signer(s.account[6]), s.reserveAddress))(
+		abi.FeeTreasuryFeesDistributed{Token: s.reserveAddress, By: s.account[6].address(), Amount: credited},
+	)
+	s.assertClaimable(treasury, treasuryAddress, s.reserveAddress, dust, claimable...)
+
+	// More fees can't be distributed until the interval has passed.
+	s.requireTx(s.manager.Issue(signer(s.proposer), big.NewInt(777777777777777777)))
+	balance, err := s.reserve.BalanceOf(nil, treasuryAddress)
+	s.Require().NoError(err)
+	fee = bigInt(0).Sub(balance, credited)
+	s.assertClaimable(treasury, treasuryAddress, s.reserveAddress, fee, claimable...)
+	s.requireTxFails(treasury.Distribute(signer(s.account[6]), s.reserveAddress))
+
+	// The next distribution shares out the dust along with the new fees.
+	s.Require().NoError(s.node.(backend).AdjustTime(time.Hour))
+	more := []*big.Int{share(fee, 3333), share(fee, 3333), share(fee, 3334)}
+	moreCredited := bigInt(0).Add(more[0], bigInt(0).Add(more[1], more[2]))
+	s.requireTxWithStrictEvents(treasury.Distribute(signer(s.account[6]), s.reserveAddress))(
+		abi.FeeTreasuryFeesDistributed{Token: s.reserveAddress, By: s.account[6].address(), Amount: moreCredited},
+	)
+	for i := range claimable {
+		claimable[i].Add(claimable[i], more[i])
+	}
+	credited.Add(credited, moreCredited)
+	s.assertClaimable(treasury, treasuryAddress, s.reserveAddress, bigInt(0).Sub(balance, credited), claimable...)
+
+	// Each token is distributed on its own schedule.
+	s.requireTx(s.erc20s[0].Transfer(signer(s.proposer), treasuryAddress, bigInt(10001)))
+	s.requireTxWithStrictEvents(treasury.Distribute(signer(s.account[6]), s.erc20Addresses[0]))(
+		abi.FeeTreasuryFeesDistributed{Token: s.erc20Addresses[0], By: s.account[6].address(), Amount: bigInt(10000)},
+	)
+	s.assertClaimable(treasury, treasuryAddress, s.erc20Addresses[0], bigInt(1), bigInt(3333), bigInt(3333), bigInt(3334))
+}
+
+// TestFeeTreasuryClaim tests that each target claims what it has been credited, and that a
+// change of targets leaves what is owed claimable.
+func (s *ManagerSuite) TestFeeTreasuryClaim() {
+	treasuryAddress, treasury := s.deployFeeTreasury()
+	s.requireTx(s.manager.SetRedemptionFeeRecipient(s.signer, treasuryAddress))
+	s.requireTx(s.manager.SetRedemptionFee(s.signer, bigInt(10)))
+	rsvAmount := shiftLeft(1000, 18)
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, rsvAmount))
+	s.requireTx(s.manager.Redeem(signer(s.proposer), rsvAmount))
+
+	token := s.erc20Addresses[2]
+	fee, err := s.erc20s[2].BalanceOf(nil, treasuryAddress)
+	s.Require().NoError(err)
+	s.False(fee.Sign() == 0)
+	s.requireTx(treasury.Distribute(signer(s.account[6]), token))
+	owed, err := treasury.Claimable(nil, token, s.account[4].address())
+	s.Require().NoError(err)
+
+	// Targets are replaced, but the old ones may still claim.
+	s.requireTx(treasury.SetTargets(s.signer, []common.Address{s.account[6].address()}, []*big.Int{bigInt(10000)}))
+	s.requireTxFails(treasury.Claim(signer(s.account[6]), token))
+	s.requireTxWithStrictEvents(treasury.Claim(signer(s.account[4]), token))(
+		abi.BasicERC20Transfer{From: treasuryAddress, To: s.account[4].address(), Value: owed},
+		abi.FeeTreasuryFeesClaimed{Token: token, Target: s.account[4].address(), Amount: owed},
+	)
+	balance, err := s.erc20s[2].BalanceOf(nil, s.account[4].address())
+	s.Require().NoError(err)
+	s.Equal(owed.String(), balance.String())
+
+	// Nothing is left to claim, and what was claimed isn't distributed again.
+	s.requireTxFails(treasury.Claim(signer(s.account[4]), token))
+	claimable := make([]*big.Int, 2)
+	undistributed := bigInt(0).Sub(fee, owed)
+	for i := range claimable {
+		claimable[i], err = treasury.Claimable(nil, token, s.account[2+i].address())
+		s.Require().NoError(err)
+		undistributed.Sub(undistributed, claimable[i])
+	}
+	s.assertClaimable(treasury, treasuryAddress, token, undistributed, claimable[0], claimable[1], bigInt(0))
+}
+
+// TestSetIssuanceFee tests that `setIssuanceFee` manipulates state correctly.
+func (s *ManagerSuite) TestSetIssuanceFee() {
+	recipient := s.account[3].address()
+
+	// A fee needs a recipient.
+	s.requireTxFails(s.manager.SetIssuanceFee(s.signer, bigInt(10)))
+
+	s.requireTxWithStrictEvents(s.manager.SetIssuanceFeeRecipient(s.signer, recipient))(
+		abi.ManagerIssuanceFeeRecipientChanged{OldAccount: zeroAddress(), NewAccount: recipient},
+	)
+	s.requireTxWithStrictEvents(s.manager.SetIssuanceFee(s.signer, bigInt(10)))(
+		abi.ManagerIssuanceFeeChanged{OldVal: bigInt(0), NewVal: bigInt(10)},
+	)
+
+	// Check that state is correct.
+	fee, err := s.manager.IssuanceFee(nil)
+	s.Require().NoError(err)
+	s.Equal(bigInt(10).String(), fee.String())
+	foundRecipient, err := s.manager.IssuanceFeeRecipient(nil)
+	s.Require().NoError(err)
+	s.Equal(recipient, foundRecipient)
+
+	// The redemption fee is separate.
+	fee, err = s.manager.RedemptionFee(nil)
+	s.Require().NoError(err)
+	s.Equal("0", fee.String())
+	foundRecipient, err = s.manager.RedemptionFeeRecipient(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), foundRecipient)
+}
+
+// TestSetIssuanceFeeIsProtected tests that the issuance fee and its recipient can only be set
+// by the owner, and within bounds.
+func (s *ManagerSuite) TestSetIssuanceFeeIsProtected() {
+	recipient := s.account[3].address()
+	s.requireTxFails(s.manager.SetIssuanceFeeRecipient(signer(s.account[2]), recipient))
+	s.requireTxFails(s.manager.SetIssuanceFeeRecipient(signer(s.operator), recipient))
+	s.requireTxFails(s.manager.SetIssuanceFeeRecipient(s.signer, zeroAddress()))
+
+	s.requireTx(s.manager.SetIssuanceFeeRecipient(s.signer, recipient))
+	s.requireTxFails(s.manager.SetIssuanceFee(signer(s.account[2]), bigInt(10)))
+	s.requireTxFails(s.manager.SetIssuanceFee(signer(s.operator), bigInt(10)))
+	s.requireTxFails(s.manager.SetIssuanceFee(s.signer, bigInt(1001)))
+	s.requireTx(s.manager.SetIssuanceFee(s.signer, bigInt(1000)))
+}
+
 // TestSetDelay tests that `setDelay` manipulates state correctly.
 func (s *ManagerSuite) TestSetDelay() {
 	delay := bigInt(172800) // 48 hours
@@ -388,6 +980,11 @@ func (s *ManagerSuite) TestSetDelay() {
 	foundDelay, err := s.manager.Delay(nil)
 	s.Require().NoError(err)
 	s.Equal(delay.String(), foundDelay.String())
+
+	// The delay must be under the validity, 7 days, or proposals would expire before they could
+	// be executed.
+	s.requireTxFails(s.manager.SetDelay(s.signer, bigInt(7*86400)))
+	s.requireTx(s.manager.SetDelay(s.signer, bigInt(7*86400-1)))
 }
 
 // TestSetDelayIsProtected tests that `setDelay` can only be called by owner.
@@ -397,6 +994,116 @@ func (s *ManagerSuite) TestSetDelayIsProtected() {
 	s.requireTxFails(s.manager.SetDelay(signer(s.operator), delay))
 }
 
+// TestSetProposalValidity tests that the owner can set how long new proposals stay valid.
+func (s *ManagerSuite) TestSetProposalValidity() {
+	validity := bigInt(3 * 86400) // 3 days
+	s.requireTxWithStrictEvents(s.manager.SetProposalValidity(s.signer, validity))(
+		abi.ManagerProposalValidityChanged{
+			OldVal: bigInt(7 * 86400), NewVal: validity,
+		},
+	)
+
+	// Check that state is correct.
+	foundValidity, err := s.manager.ProposalValidity(nil)
+	s.Require().NoError(err)
+	s.Equal(validity.String(), foundValidity.String())
+
+	// The validity must be over the delay, 24 hours.
+	s.requireTxFails(s.manager.SetProposalValidity(s.signer, bigInt(86400)))
+	s.requireTx(s.manager.SetProposalValidity(s.signer, bigInt(86401)))
+}
+
+// TestSetProposalValidityIsProtected tests that `setProposalValidity` can only be called by owner.
+func (s *ManagerSuite) TestSetProposalValidityIsProtected() {
+	validity := bigInt(1)
+	s.requireTxFails(s.manager.SetProposalValidity(signer(s.account[2]), validity))
+	s.requireTxFails(s.manager.SetProposalValidity(signer(s.operator), validity))
+}
+
+// deployStrayToken deploys an ERC-20 that isn't collateral, as if sent to the system by
+// mistake, with the whole supply held by s.owner.
+func (s *ManagerSuite) deployStrayToken() (common.Address, *abi.BasicERC20) {
+	address, tx, token, err := abi.DeployBasicERC20(s.signer, s.node)
+	s.logParsers[address] = token
+	s.requireTx(tx, err)
+	return address, token
+}
+
+// TestSweep tests that the owner can recover tokens sent to the Manager.
+func (s *ManagerSuite) TestSweep() {
+	tokenAddress, token := s.deployStrayToken()
+	recipient := s.account[3].address()
+	s.requireTx(token.Transfer(s.signer, s.managerAddress, bigInt(100)))
+
+	s.requireTxWithStrictEvents(s.manager.Sweep(s.signer, tokenAddress, bigInt(100), recipient))(
+		abi.BasicERC20Transfer{From: s.managerAddress, To: recipient, Value: bigInt(100)},
+		abi.ManagerTokenSwept{Token: tokenAddress, To: recipient, Amount: bigInt(100)},
+	)
+	balance, err := token.BalanceOf(nil, recipient)
+	s.Require().NoError(err)
+	s.Equal("100", balance.String())
+
+	s.requireTxFails(s.manager.Sweep(s.signer, tokenAddress, bigInt(1), recipient))
+	s.requireTxFails(s.manager.Sweep(s.signer, tokenAddress, bigInt(0), zeroAddress()))
+}
+
+func (s *ManagerSuite) TestSweepIsProtected() {
+	tokenAddress, token := s.deployStrayToken()
+	s.requireTx(token.Transfer(s.signer, s.managerAddress, bigInt(100)))
+	s.requireTx(token.Transfer(s.signer, s.vaultAddress, bigInt(100)))
+
+	for _, acc := range []account{s.account[2], s.operator} {
+		s.requireTxFails(s.manager.Sweep(signer(acc), tokenAddress, bigInt(100), acc.address()))
+		s.requireTxFails(s.manager.SweepVault(signer(acc), tokenAddress, bigInt(100), acc.address()))
+	}
+}
+
+// TestSweepVault tests that the owner can recover a token sent to the Vault that isn't
+// collateral.
+func (s *ManagerSuite) TestSweepVault() {
+	s.requireTx(s.manager.Issue(signer(s.proposer), shiftLeft(1, 27)))
+	tokenAddress, token := s.deployStrayToken()
+	recipient := s.account[3].address()
+	s.requireTx(token.Transfer(s.signer, s.vaultAddress, bigInt(100)))
+
+	s.requireTxWithStrictEvents(s.manager.SweepVault(s.signer, tokenAddress, bigInt(60), recipient))(
+		abi.BasicERC20Transfer{From: s.vaultAddress, To: recipient, Value: bigInt(60)},
+		abi.VaultWithdrawal{Token: tokenAddress, Amount: bigInt(60), To: recipient},
+		abi.ManagerVaultTokenSwept{Token: tokenAddress, To: recipient, Amount: bigInt(60)},
+	)
+	balance, err := token.BalanceOf(nil, s.vaultAddress)
+	s.Require().NoError(err)
+	s.Equal("40", balance.String())
+	s.assertManagerCollateralized()
+}
+
+// TestSweepVaultCannotTakeCollateralOrRSV tests that neither a basket token, even one the Vault
+// holds more of than the supply needs, nor RSV sent to the Vault can be swept out of it.
+func (s *ManagerSuite) TestSweepVaultCannotTakeCollateralOrRSV() {
+	rsvAmount := shiftLeft(1, 27)
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+	recipient := s.account[3].address()
+
+	for i, erc20 := range s.erc20s {
+		s.requireTx(erc20.Transfer(s.signer, s.vaultAddress, bigInt(1000)))
+		s.requireTxFails(s.manager.SweepVault(s.signer, s.erc20Addresses[i], bigInt(1000), recipient))
+		s.requireTxFails(s.manager.SweepVault(s.signer, s.erc20Addresses[i], bigInt(1), recipient))
+	}
+
+	s.requireTx(s.reserve.Transfer(signer(s.proposer), s.vaultAddress, rsvAmount))
+	s.requireTxFails(s.manager.SweepVault(s.signer, s.reserveAddress, rsvAmount, recipient))
+	s.assertRSVBalance(s.vaultAddress, rsvAmount)
+
+	// Nor does sweeping the Manager reach into the Vault.
+	s.requireTxFails(s.manager.Sweep(s.signer, s.erc20Addresses[0], bigInt(1), recipient))
+	for _, erc20 := range s.erc20s {
+		balance, err := erc20.BalanceOf(nil, recipient)
+		s.Require().NoError(err)
+		s.Equal("0", balance.String())
+	}
+	s.assertManagerCollateralized()
+}
+
 // TestClearProposals tests that `clearProposals` manipulates state correctly.
 func (s *ManagerSuite) TestClearProposals() {
 	// ProposalsLength should start at 1.
@@ -409,10 +1116,14 @@ func (s *ManagerSuite) TestClearProposals() {
 		abi.ManagerProposalsCleared{},
 	)
 
-	// Check that the length is now 0.
+	// Check that the length is unchanged, and that every proposal so far is cleared.
 	proposalsLength, err = s.manager.ProposalsLength(nil)
 	s.Require().NoError(err)
-	s.Equal(bigInt(0).String(), proposalsLength.String())
+	s.Equal(bigInt(1).String(), proposalsLength.String())
+	firstLive, err := s.manager.FirstLiveProposal(nil)
+	s.Require().NoError(err)
+	s.Equal(bigInt(1).String(), firstLive.String())
+	s.requireTxFails(s.manager.AcceptProposal(signer(s.operator), bigInt(0)))
 }
 
 // TestClearProposalsIsProtected tests that `clearProposals` can only be called by owner.
@@ -460,6 +1171,97 @@ func (s *ManagerSuite) TestIssue() {
 	s.assertManagerCollateralized()
 }
 
+// TestIssueWithZeroFee tests that with a recipient set but no fee, the issuer gets all of the
+// RSV.
+func (s *ManagerSuite) TestIssueWithZeroFee() {
+	rsvAmount := shiftLeft(1, 27)
+	issuer, recipient := s.proposer.address(), s.account[3].address()
+	s.requireTx(s.manager.SetIssuanceFeeRecipient(s.signer, recipient))
+
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))(
+		mintingTransfer(issuer, rsvAmount),
+		abi.ManagerIssuance{User: issuer, Amount: rsvAmount},
+	)
+	s.assertRSVBalance(issuer, rsvAmount)
+	s.assertRSVBalance(recipient, bigInt(0))
+	s.assertRSVTotalSupply(rsvAmount)
+	s.assertManagerCollateralized()
+}
+
+// TestIssuanceFee tests that the issuance fee is taken out of the issued RSV, rounding down,
+// and that the collateral, rounded up, backs the fee along with the rest, for baskets of tokens
+// of many decimals.
+func (s *ManagerSuite) TestIssuanceFee() {
+	issuer, recipient := s.proposer.address(), s.account[3].address()
+	s.requireTx(s.manager.SetIssuanceFeeRecipient(s.signer, recipient))
+	bps, scale := bigInt(10000), shiftLeft(1, 36)
+
+	// Amounts that divide evenly by nothing in particular: neither the fee nor the collateral is
+	// exact.
+	rsvAmounts := []*big.Int{
+		bigInt(0).Add(shiftLeft(1234, 18), bigInt(56789)),
+		bigInt(9999),
+		bigInt(7),
+	}
+
+	supply := bigInt(0)
+	issued, fees := bigInt(0), bigInt(0)
+	for _, decimals := range [][]uint32{{6, 18, 6}, {0, 2, 8}, {18, 27, 36}} {
+		// One tenth of an RSV is backed by 2, 3, and 5 whole tokens.
+		weights := make([]*big.Int, len(decimals))
+		for i, share := range []uint32{2, 3, 5} {
+			weights[i] = shiftLeft(share, decimals[i]+17)
+		}
+		s.changeBasketUsingWeightProposal(s.erc20Addresses, weights)
+
+		for _, feeBPS := range []uint32{1, 25, 1000} {
+			s.requireTx(s.manager.SetIssuanceFee(s.signer, bigInt(feeBPS)))
+			for _, rsvAmount := range rsvAmounts {
+				msg := fmt.Sprintf("decimals %v, fee %v, amount %v", decimals, feeBPS, rsvAmount)
+
+				// The fee rounds down: fee <= rsvAmount * feeBPS / 10000 < fee + 1.
+				product := bigInt(0).Mul(rsvAmount, bigInt(feeBPS))
+				fee := bigInt(0).Div(product, bps)
+				s.True(bigInt(0).Mul(fee, bps).Cmp(product) <= 0, msg)
+				s.True(bigInt(0).Mul(bigInt(0).Add(fee, bigInt(1)), bps).Cmp(product) > 0, msg)
+				net := bigInt(0).Sub(rsvAmount, fee)
+
+				before := s.tokenBalances(s.vaultAddress)[0]
+				events := []fmt.Stringer{mintingTransfer(issuer, net)}
+				if fee.Sign() > 0 {
+					events = append(events, mintingTransfer(recipient, fee))
+				}
+				events = append(events, abi.ManagerIssuance{User: issuer, Amount: rsvAmount})
+				s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))(events...)
+				after := s.tokenBalances(s.vaultAddress)[0]
+
+				// The supply grows by all of rsvAmount, and the collateral backs all of it,
+				// rounding up: (paid - 1) * 1e36 < rsvAmount * weight <= paid * 1e36.
+				supply.Add(supply, rsvAmount)
+				issued.Add(issued, net)
+				fees.Add(fees, fee)
+				s.assertRSVTotalSupply(supply)
+				s.assertRSVBalance(issuer, issued)
+				s.assertRSVBalance(recipient, fees)
+				for i, weight := range weights {
+					paid := bigInt(0).Sub(after[i], before[i])
+					owed := bigInt(0).Mul(rsvAmount, weight)
+					s.True(bigInt(0).Mul(paid, scale).Cmp(owed) >= 0, msg)
+					s.True(bigInt(0).Mul(bigInt(0).Sub(paid, bigInt(1)), scale).Cmp(owed) < 0, msg)
+				}
+				s.assertManagerCollateralized()
+			}
+		}
+		s.requireTx(s.manager.SetIssuanceFee(s.signer, bigInt(0)))
+
+		// Redeem everything, so that the next basket starts from nothing.
+		s.requireTx(s.reserve.Transfer(signer(s.account[3]), issuer, fees))
+		s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, supply))
+		s.requireTx(s.manager.Redeem(signer(s.proposer), supply))
+		supply, issued, fees = bigInt(0), bigInt(0), bigInt(0)
+	}
+}
+
 // TestIssueIsProtected tests that `issue` reverts when in an emergency or it is paused.
 func (s *ManagerSuite) TestIssueIsProtected() {
 	amount := bigInt(1)
@@ -524,25 +1326,650 @@ func (s *ManagerSuite) TestIssueRequireStatements() {
 	s.assertManagerCollateralized()
 }
 
-// TestRedeem tests that `redeem` compensates the person with the correct amounts.
-func (s *ManagerSuite) TestRedeem() {
-	// Issue.
-	rsvAmount := shiftLeft(1, 27) // 1 billion
-	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+// deployMockPermit2 deploys a MockPermit2 and sets it as the Manager's Permit2.
+func (s *ManagerSuite) deployMockPermit2() (common.Address, *abi.MockPermit2) {
+	address, tx, permit2, err := abi.DeployMockPermit2(s.signer, s.node)
+	s.logParsers[address] = permit2
+	s.requireTx(tx, err)
+	s.requireTxWithStrictEvents(s.manager.SetPermit2(s.signer, address))(
+		abi.ManagerPermit2Changed{OldPermit2: zeroAddress(), NewPermit2: address},
+	)
+	return address, permit2
+}
 
-	redeemer := s.account[4]
+// permit2Call returns the calldata of a Permit2 batch permit from `owner`, granting the Manager
+// `amounts` of the basket tokens. The MockPermit2 doesn't check the signature.
+func (s *ManagerSuite) permit2Call(owner common.Address, amounts []*big.Int) []byte {
+	batch := authorize.PermitBatch{Spender: s.managerAddress, SigDeadline: bigInt(0)}
+	for i, token := range s.erc20Addresses {
+		batch.Details = append(batch.Details, authorize.PermitDetails{
+			Token: token, Amount: amounts[i], Expiration: bigInt(0), Nonce: bigInt(0),
+		})
+	}
+	data, err := batch.Call(owner, authorize.Signature{V: 27})
+	s.Require().NoError(err)
+	return data
+}
 
-	// Send the RSV to someone else who doesn't have any Erc20s.
-	s.requireTx(s.reserve.Transfer(signer(s.proposer), redeemer.address(), rsvAmount))
+// TestSetPermit2IsProtected tests that only the owner sets Permit2.
+func (s *ManagerSuite) TestSetPermit2IsProtected() {
+	s.requireTxFails(s.manager.SetPermit2(signer(s.operator), s.account[3].address()))
+	permit2, err := s.manager.TrustedPermit2(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), permit2)
+}
 
-	// Redeem that RSV.
-	s.requireTx(s.reserve.Approve(signer(redeemer), s.managerAddress, rsvAmount))
-	s.requireTx(s.manager.Redeem(signer(redeemer), rsvAmount))
+// TestIssueWithPermit2 tests that `issueWithPermit2` pulls the collateral through Permit2, from
+// an issuer who has approved Permit2 but not the Manager.
+func (s *ManagerSuite) TestIssueWithPermit2() {
+	rsvAmount := shiftLeft(1000, 18)
+	issuer := s.proposer
 
-	// Figure out what to expect back.
-	amounts := s.computeExpectedRedeemAmounts(rsvAmount)
+	// Not until Permit2 is set.
+	s.requireTxFails(s.manager.IssueWithPermit2(signer(issuer), rsvAmount, []byte{}))
+	permit2Address, permit2 := s.deployMockPermit2()
 
-	// Assert our balances are what we expected.
+	amounts, err := s.manager.ToIssue(nil, rsvAmount)
+	s.Require().NoError(err)
+	for _, erc20 := range s.erc20s {
+		s.requireTx(erc20.Approve(signer(issuer), s.managerAddress, bigInt(0)))
+		s.requireTx(erc20.Approve(signer(issuer), permit2Address, shiftLeft(1, 46)))
+	}
+	s.requireTxFails(s.manager.Issue(signer(issuer), rsvAmount))
+
+	// Without a permit, there's no allowance to pull.
+	s.requireTxFails(s.manager.IssueWithPermit2(signer(issuer), rsvAmount, []byte{}))
+
+	before := s.tokenBalances(issuer.address(), s.vaultAddress)
+	s.requireTx(s.manager.IssueWithPermit2(signer(issuer), rsvAmount, s.permit2Call(issuer.address(), amounts)))(
+		mintingTransfer(issuer.address(), rsvAmount),
+		abi.ManagerIssuance{User: issuer.address(), Amount: rsvAmount},
+	)
+	s.assertRSVBalance(issuer.address(), rsvAmount)
+	after := s.tokenBalances(issuer.address(), s.vaultAddress)
+	for i, token := range s.erc20Addresses {
+		s.Equal(amounts[i].String(), bigInt(0).Sub(before[0][i], after[0][i]).String())
+		s.Equal(amounts[i].String(), bigInt(0).Sub(after[1][i], before[1][i]).String())
+
+		// The allowance is spent.
+		allowance, err := permit2.Allowance(nil, issuer.address(), token, s.managerAddress)
+		s.Require().NoError(err)
+		s.Equal("0", allowance.String())
+	}
+	s.assertManagerCollateralized()
+
+	// An allowance already granted serves without a permit.
+	double := make([]*big.Int, len(amounts))
+	for i := range amounts {
+		double[i] = bigInt(0).Mul(amounts[i], bigInt(2))
+	}
+	s.requireTx(s.manager.IssueWithPermit2(signer(issuer), rsvAmount, s.permit2Call(issuer.address(), double)))
+	s.requireTx(s.manager.IssueWithPermit2(signer(issuer), rsvAmount, []byte{}))
+	s.requireTxFails(s.manager.IssueWithPermit2(signer(issuer), rsvAmount, []byte{}))
+	s.assertRSVBalance(issuer.address(), bigInt(0).Mul(rsvAmount, bigInt(3)))
+}
+
+// TestIssueWithPermit2IsProtected tests that `issueWithPermit2` only forwards the sender's own
+// batch permits to Permit2, and is paused with `issue`.
+func (s *ManagerSuite) TestIssueWithPermit2IsProtected() {
+	rsvAmount := shiftLeft(1000, 18)
+	issuer, other := s.proposer, s.account[4]
+	permit2Address, _ := s.deployMockPermit2()
+	amounts, err := s.manager.ToIssue(nil, rsvAmount)
+	s.Require().NoError(err)
+	for _, erc20 := range s.erc20s {
+		s.requireTx(erc20.Approve(signer(issuer), permit2Address, shiftLeft(1, 46)))
+	}
+	permitCall := s.permit2Call(issuer.address(), amounts)
+
+	// Someone else's permit.
+	s.requireTxFails(s.manager.IssueWithPermit2(signer(other), rsvAmount, permitCall))
+
+	// Calls other than a batch permit, such as a transfer of another's allowance to the Manager.
+	s.requireTxFails(s.manager.IssueWithPermit2(signer(issuer), rsvAmount, permitCall[:35]))
+	transfer := append([]byte{0x36, 0xc7, 0x85, 0x16}, permitCall[4:]...)
+	s.requireTxFails(s.manager.IssueWithPermit2(signer(issuer), rsvAmount, transfer))
+
+	// Paused with issuance.
+	s.requireTx(s.manager.SetIssuancePaused(signer(s.operator), true))
+	s.requireTxFails(s.manager.IssueWithPermit2(signer(issuer), rsvAmount, permitCall))
+	s.requireTx(s.manager.SetIssuancePaused(signer(s.operator), false))
+	s.requireTx(s.manager.SetEmergency(signer(s.operator), true))
+	s.requireTxFails(s.manager.IssueWithPermit2(signer(issuer), rsvAmount, permitCall))
+	s.requireTx(s.manager.SetEmergency(signer(s.operator), false))
+
+	s.requireTx(s.manager.IssueWithPermit2(signer(issuer), rsvAmount, permitCall))
+}
+
+// withGasLimit sets opts to send its transaction without estimating its gas, which fails for a
+// transaction that reverts against the pending state.
+func withGasLimit(opts *bind.TransactOpts) *bind.TransactOpts {
+	opts.GasLimit = 2e6
+	return opts
+}
+
+// sameBlock sends the transactions that `send` make through a binding of the Manager that doesn't
+// mine each one, and then mines them in one block, in order, returning their receipts. Each
+// transaction must be sent with withGasLimit.
+func (s *ManagerSuite) sameBlock(send ...func(manager *abi.Manager) (*types.Transaction, error)) []*types.Receipt {
+	node := s.node.(backend).SimulatedBackend
+	manager, err := abi.NewManager(s.managerAddress, node)
+	s.Require().NoError(err)
+	txs := make([]*types.Transaction, len(send))
+	for i, f := range send {
+		txs[i], err = f(manager)
+		s.Require().NoError(err)
+	}
+	node.Commit()
+
+	receipts := make([]*types.Receipt, len(txs))
+	for i, tx := range txs {
+		receipts[i], err = s.node.TransactionReceipt(context.Background(), tx.Hash())
+		s.Require().NoError(err)
+	}
+	return receipts
+}
+
+// acceptWeights proposes and accepts `weights` for the basket's tokens, and waits out the delay,
+// returning the ID of the proposal, which is ready to execute.
+func (s *ManagerSuite) acceptWeights(weights []*big.Int) *big.Int {
+	s.requireTx(s.manager.ProposeWeights(signer(s.proposer), s.erc20Addresses, weights))
+	length, err := s.manager.ProposalsLength(nil)
+	s.Require().NoError(err)
+	id := bigInt(0).Sub(length, bigInt(1))
+	proposal, err := s.manager.TrustedProposals(nil, id)
+	s.Require().NoError(err)
+	s.logParsers[proposal], err = abi.NewWeightProposal(proposal, s.node)
+	s.Require().NoError(err)
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), id))
+	s.Require().NoError(s.node.(backend).AdjustTime(24 * time.Hour))
+	return id
+}
+
+// TestIssueWithMaxIn tests that `issueWithMaxIn` takes no more collateral than the issuer
+// allows, of the basket the issuer names.
+func (s *ManagerSuite) TestIssueWithMaxIn() {
+	rsvAmount := shiftLeft(1000, 18)
+	quoted, err := s.manager.ToIssue(nil, rsvAmount)
+	s.Require().NoError(err)
+	less := make([]*big.Int, len(quoted))
+	for i := range quoted {
+		less[i] = bigInt(0).Set(quoted[i])
+	}
+	less[2].Sub(less[2], bigInt(1))
+	reordered := []common.Address{s.erc20Addresses[1], s.erc20Addresses[0], s.erc20Addresses[2]}
+
+	s.requireTxFails(s.manager.IssueWithMaxIn(signer(s.proposer), rsvAmount, s.erc20Addresses, less))
+	s.requireTxFails(s.manager.IssueWithMaxIn(signer(s.proposer), rsvAmount, reordered, quoted))
+	s.requireTxFails(s.manager.IssueWithMaxIn(signer(s.proposer), rsvAmount, s.erc20Addresses[:2], quoted[:2]))
+	s.requireTxFails(s.manager.IssueWithMaxIn(signer(s.proposer), rsvAmount, s.erc20Addresses, quoted[:2]))
+	s.assertRSVBalance(s.proposer.address(), bigInt(0))
+
+	s.requireTx(s.manager.IssueWithMaxIn(signer(s.proposer), rsvAmount, s.erc20Addresses, quoted))(
+		abi.ManagerIssuance{User: s.proposer.address(), Amount: rsvAmount},
+	)
+	s.assertRSVBalance(s.proposer.address(), rsvAmount)
+
+	// It is paused with `issue`.
+	s.requireTx(s.manager.SetIssuancePaused(signer(s.operator), true))
+	s.requireTxFails(s.manager.IssueWithMaxIn(signer(s.proposer), rsvAmount, s.erc20Addresses, quoted))
+}
+
+// TestIssueWithMaxInFrontrun tests that an issuer who quoted the basket is protected by
+// `issueWithMaxIn` from a change of weights landing just ahead of the issuance, in the same
+// block, where `issue` pays whatever the new basket asks.
+func (s *ManagerSuite) TestIssueWithMaxInFrontrun() {
+	issuer := s.account[4]
+	rsvAmount := shiftLeft(1000, 18)
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+	s.fundAccountWithErc20sAndApprove(issuer, []*big.Int{shiftLeft(1, 40), shiftLeft(1, 40), shiftLeft(1, 40)})
+
+	// Unprotected, the issuer pays the doubled weight of token0 that lands first.
+	quoted, err := s.manager.ToIssue(nil, rsvAmount)
+	s.Require().NoError(err)
+	id := s.acceptWeights([]*big.Int{shiftLeft(2, 35), shiftLeft(3, 35), shiftLeft(6, 35)})
+	before := s.tokenBalances(issuer.address())
+	receipts := s.sameBlock(
+		func(m *abi.Manager) (*types.Transaction, error) {
+			return m.ExecuteProposal(withGasLimit(signer(s.operator)), id)
+		},
+		func(m *abi.Manager) (*types.Transaction, error) {
+			return m.Issue(withGasLimit(signer(issuer)), rsvAmount)
+		},
+	)
+	s.Equal(types.ReceiptStatusSuccessful, receipts[0].Status)
+	s.Equal(types.ReceiptStatusSuccessful, receipts[1].Status)
+	after := s.tokenBalances(issuer.address())
+	paid := bigInt(0).Sub(before[0][0], after[0][0])
+	s.Equal(bigInt(0).Mul(quoted[0], bigInt(2)).String(), paid.String())
+
+	// Protected, the issuance fails instead.
+	quoted, err = s.manager.ToIssue(nil, rsvAmount)
+	s.Require().NoError(err)
+	id = s.acceptWeights([]*big.Int{shiftLeft(4, 35), shiftLeft(3, 35), shiftLeft(6, 35)})
+	before = s.tokenBalances(issuer.address())
+	receipts = s.sameBlock(
+		func(m *abi.Manager) (*types.Transaction, error) {
+			return m.ExecuteProposal(withGasLimit(signer(s.operator)), id)
+		},
+		func(m *abi.Manager) (*types.Transaction, error) {
+			return m.IssueWithMaxIn(withGasLimit(signer(issuer)), rsvAmount, s.erc20Addresses, quoted)
+		},
+	)
+	s.Equal(types.ReceiptStatusSuccessful, receipts[0].Status)
+	s.Equal(types.ReceiptStatusFailed, receipts[1].Status)
+	s.Equal(fmt.Sprint(before), fmt.Sprint(s.tokenBalances(issuer.address())))
+	s.assertRSVBalance(issuer.address(), rsvAmount)
+
+	// A fresh quote goes through.
+	quoted, err = s.manager.ToIssue(nil, rsvAmount)
+	s.Require().NoError(err)
+	s.requireTx(s.manager.IssueWithMaxIn(signer(issuer), rsvAmount, s.erc20Addresses, quoted))
+	s.assertRSVBalance(issuer.address(), bigInt(0).Mul(rsvAmount, bigInt(2)))
+}
+
+// TestRedeemWithMinOutFrontrun tests that a redeemer who quoted the basket is protected by
+// `redeemWithMinOut` from a change of weights landing just ahead of the redemption, in the same
+// block, and that it pays no less than the redeemer allows.
+func (s *ManagerSuite) TestRedeemWithMinOutFrontrun() {
+	redeemer := s.account[4]
+	rsvAmount := shiftLeft(1000, 18)
+	s.requireTx(s.manager.Issue(signer(s.proposer), bigInt(0).Mul(rsvAmount, bigInt(2))))
+	s.requireTx(s.reserve.Transfer(signer(s.proposer), redeemer.address(), rsvAmount))
+	s.requireTx(s.reserve.Approve(signer(redeemer), s.managerAddress, rsvAmount))
+
+	quoted, err := s.manager.ToRedeem(nil, rsvAmount)
+	s.Require().NoError(err)
+	more := make([]*big.Int, len(quoted))
+	for i := range quoted {
+		more[i] = bigInt(0).Set(quoted[i])
+	}
+	more[1].Add(more[1], bigInt(1))
+	s.requireTxFails(s.manager.RedeemWithMinOut(signer(redeemer), rsvAmount, s.erc20Addresses, more))
+	s.requireTxFails(s.manager.RedeemWithMinOut(signer(redeemer), rsvAmount, s.erc20Addresses[1:], quoted[1:]))
+
+	// Halving token0's weight, just ahead of the redemption, would pay half as much of it.
+	id := s.acceptWeights([]*big.Int{shiftLeft(5, 34), shiftLeft(3, 35), shiftLeft(6, 35)})
+	receipts := s.sameBlock(
+		func(m *abi.Manager) (*types.Transaction, error) {
+			return m.ExecuteProposal(withGasLimit(signer(s.operator)), id)
+		},
+		func(m *abi.Manager) (*types.Transaction, error) {
+			return m.RedeemWithMinOut(withGasLimit(signer(redeemer)), rsvAmount, s.erc20Addresses, quoted)
+		},
+	)
+	s.Equal(types.ReceiptStatusSuccessful, receipts[0].Status)
+	s.Equal(types.ReceiptStatusFailed, receipts[1].Status)
+	s.assertRSVBalance(redeemer.address(), rsvAmount)
+
+	// A fresh quote goes through, paying exactly what was quoted.
+	quoted, err = s.manager.ToRedeem(nil, rsvAmount)
+	s.Require().NoError(err)
+	s.requireTx(s.manager.RedeemWithMinOut(signer(redeemer), rsvAmount, s.erc20Addresses, quoted))(
+		abi.ManagerRedemption{User: redeemer.address(), Amount: rsvAmount},
+	)
+	s.assertRSVBalance(redeemer.address(), bigInt(0))
+	balances := s.tokenBalances(redeemer.address())
+	for i := range quoted {
+		s.Equal(quoted[i].String(), balances[0][i].String())
+	}
+}
+
+// TestOracleCheckedIssuance tests that, with an oracle, issuance must leave the Vault worth the
+// supply by fresh prices near a dollar, and that redemption goes on regardless.
+func (s *ManagerSuite) TestOracleCheckedIssuance() {
+	oracleAddress, oracle := s.deployCollateralOracle(300)
+	aggregators := make([]*abi.MockAggregator, len(s.erc20Addresses))
+	for i, token := range s.erc20Addresses {
+		var address common.Address
+		address, aggregators[i] = s.deployMockAggregator(8, shiftLeft(1, 8))
+		s.setFeed(oracle, token, address, time.Hour, 18)
+	}
+	s.requireTxWithStrictEvents(s.manager.SetOracle(s.signer, oracleAddress))(
+		abi.ManagerOracleChanged{OldOracle: zeroAddress(), NewOracle: oracleAddress},
+	)
+	foundOracle, err := s.manager.TrustedOracle(nil)
+	s.Require().NoError(err)
+	s.Equal(oracleAddress, foundOracle)
+
+	// At a dollar each, the Vault is worth exactly the supply.
+	rsvAmount := shiftLeft(1000, 18)
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+	value, err := s.manager.CollateralValue(nil)
+	s.Require().NoError(err)
+	s.Equal(rsvAmount.String(), value.String())
+
+	// token2, 60% of the basket, at 98 cents: within the deviation, but the Vault is worth less
+	// than the supply.
+	s.requireTx(aggregators[2].SetAnswer(s.signer, bigInt(98000000), s.currentTimestamp()))
+	value, err = s.manager.CollateralValue(nil)
+	s.Require().NoError(err)
+	s.Equal(shiftLeft(988, 18).String(), value.String())
+	s.requireTxFails(s.manager.Issue(signer(s.proposer), shiftLeft(1, 18)))
+
+	// Redemption goes on.
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, shiftLeft(1, 18)))
+	s.requireTx(s.manager.Redeem(signer(s.proposer), shiftLeft(1, 18)))
+
+	// At 96 cents, too far off a dollar to price.
+	s.requireTx(aggregators[2].SetAnswer(s.signer, bigInt(96000000), s.currentTimestamp()))
+	_, err = s.manager.CollateralValue(nil)
+	s.Error(err)
+	s.requireTxFails(s.manager.Issue(signer(s.proposer), shiftLeft(1, 18)))
+
+	// Back at a dollar, issuance resumes, until a feed goes stale.
+	s.requireTx(aggregators[2].SetAnswer(s.signer, shiftLeft(1, 8), s.currentTimestamp()))
+	s.requireTx(s.manager.Issue(signer(s.proposer), shiftLeft(1, 18)))
+	s.Require().NoError(s.node.(backend).AdjustTime(2 * time.Hour))
+	s.requireTxFails(s.manager.Issue(signer(s.proposer), shiftLeft(1, 18)))
+
+	// Without the oracle, issuance goes on.
+	s.requireTxWithStrictEvents(s.manager.SetOracle(s.signer, zeroAddress()))(
+		abi.ManagerOracleChanged{OldOracle: oracleAddress, NewOracle: zeroAddress()},
+	)
+	s.requireTx(s.manager.Issue(signer(s.proposer), shiftLeft(1, 18)))
+	_, err = s.manager.CollateralValue(nil)
+	s.Error(err)
+	s.assertManagerCollateralized()
+}
+
+// wrappedAmount returns `rsvAmount` of RSV in a wrapper of weight `weight`, in the underlying
+// token, at exchange rate `rate`, rounded up or down.
+func wrappedAmount(rsvAmount, weight, rate *big.Int, up bool) *big.Int {
+	denominator := bigInt(0).Mul(shiftLeft(1, 18), rate) // qRSV/RSV * aqUnderlying/qToken
+	quotient, remainder := bigInt(0).DivMod(bigInt(0).Mul(rsvAmount, weight), denominator, bigInt(0))
+	if up && remainder.Sign() > 0 {
+		quotient.Add(quotient, bigInt(1))
+	}
+	return quotient
+}
+
+// TestInterestBearingCollateral tests a basket holding a cToken, whose weight is in its
+// underlying token: issuance takes in cTokens at the exchange rate of the day, redemption pays
+// out fewer as the rate grows, and the interest in between stays in the Vault.
+func (s *ManagerSuite) TestInterestBearingCollateral() {
+	// A cToken of 8 decimals, worth 0.02 of an 18-decimal underlying token, as cDAI once was.
+	rate := bigInt(0).Mul(bigInt(2), shiftLeft(1, 26))
+	cTokenAddress, tx, cToken, err := abi.DeployMockCToken(s.signer, s.node, rate)
+	s.logParsers[cTokenAddress] = cToken
+	s.requireTx(tx, err)
+	s.requireTx(cToken.Transfer(s.signer, s.proposer.address(), shiftLeft(1, 46)))
+	s.requireTx(cToken.Approve(signer(s.proposer), s.managerAddress, shiftLeft(1, 46)))
+
+	// Every token at a dollar; the cToken's feed is its underlying token's.
+	oracleAddress, oracle := s.deployCollateralOracle(300)
+	tokens := append(append([]common.Address{}, s.erc20Addresses...), cTokenAddress)
+	for _, token := range tokens {
+		aggregator, _ := s.deployMockAggregator(8, shiftLeft(1, 8))
+		s.setFeed(oracle, token, aggregator, time.Hour, 18)
+	}
+	s.requireTxWithStrictEvents(oracle.SetExchangeRateSource(s.signer, cTokenAddress, cTokenAddress))(
+		abi.CollateralOracleExchangeRateSourceChanged{Token: cTokenAddress, Source: cTokenAddress},
+	)
+	s.requireTx(s.manager.SetOracle(s.signer, oracleAddress))
+
+	// 0.3 of the underlying token per RSV.
+	weight := shiftLeft(3, 35)
+	weights := []*big.Int{shiftLeft(1, 35), shiftLeft(3, 35), shiftLeft(3, 35), weight}
+	s.changeBasketUsingWeightProposal(tokens, weights)
+
+	// Issuance takes in the cTokens worth 300 of the underlying token, for 1000 RSV.
+	rsvAmount := shiftLeft(1000, 18)
+	issued := wrappedAmount(rsvAmount, weight, rate, true)
+	s.Equal(shiftLeft(15000, 8).String(), issued.String())
+	amounts, err := s.manager.ToIssue(nil, rsvAmount)
+	s.Require().NoError(err)
+	s.Equal(issued.String(), amounts[3].String())
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+	value, err := s.manager.CollateralValue(nil)
+	s.Require().NoError(err)
+	s.Equal(rsvAmount.String(), value.String())
+
+	// The rate grows by a tenth, and with it the Vault's value.
+	rate = bigInt(0).Mul(bigInt(22), shiftLeft(1, 25))
+	s.requireTx(cToken.SetExchangeRate(s.signer, rate))
+	value, err = s.manager.CollateralValue(nil)
+	s.Require().NoError(err)
+	s.Equal(shiftLeft(1030, 18).String(), value.String())
+	s.assertManagerCollateralized()
+
+	// Redemption pays out fewer cTokens, still worth 300 of the underlying token, rounded down.
+	redeemed := wrappedAmount(rsvAmount, weight, rate, false)
+	amounts, err = s.manager.ToRedeem(nil, rsvAmount)
+	s.Require().NoError(err)
+	s.Equal(redeemed.String(), amounts[3].String())
+	worth := bigInt(0).Div(bigInt(0).Mul(redeemed, rate), shiftLeft(1, 18))
+	s.True(worth.Cmp(shiftLeft(300, 18)) <= 0)
+	s.True(bigInt(0).Sub(shiftLeft(300, 18), worth).Cmp(bigInt(0).Div(rate, shiftLeft(1, 18))) <= 0)
+
+	before, err := cToken.BalanceOf(nil, s.proposer.address())
+	s.Require().NoError(err)
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, rsvAmount))
+	s.requireTx(s.manager.Redeem(signer(s.proposer), rsvAmount))
+	after, err := cToken.BalanceOf(nil, s.proposer.address())
+	s.Require().NoError(err)
+	s.Equal(redeemed.String(), bigInt(0).Sub(after, before).String())
+
+	// The interest stays in the Vault.
+	s.assertRSVTotalSupply(bigInt(0))
+	left, err := cToken.BalanceOf(nil, s.vaultAddress)
+	s.Require().NoError(err)
+	s.Equal(bigInt(0).Sub(issued, redeemed).String(), left.String())
+	s.True(left.Sign() > 0)
+
+	// Should the rate fall below what the supply needs, issuance stops.
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+	s.requireTx(cToken.SetExchangeRate(s.signer, shiftLeft(1, 26)))
+	collateralized, err := s.manager.IsFullyCollateralized(nil)
+	s.Require().NoError(err)
+	s.False(collateralized)
+	s.requireTxFails(s.manager.Issue(signer(s.proposer), rsvAmount))
+}
+
+// TestSetOracleIsProtected tests that only the owner can set the oracle.
+func (s *ManagerSuite) TestSetOracleIsProtected() {
+	oracleAddress, _ := s.deployCollateralOracle(300)
+	s.requireTxFails(s.manager.SetOracle(signer(s.account[2]), oracleAddress))
+	s.requireTxFails(s.manager.SetOracle(signer(s.operator), oracleAddress))
+	foundOracle, err := s.manager.TrustedOracle(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), foundOracle)
+}
+
+// setSingleRedemptionOracle sets an oracle pricing each basket token at a dollar, with feeds of
+// an hour's heartbeat, and returns the tokens' aggregators.
+func (s *ManagerSuite) setSingleRedemptionOracle() []*abi.MockAggregator {
+	oracleAddress, oracle := s.deployCollateralOracle(300)
+	aggregators := make([]*abi.MockAggregator, len(s.erc20Addresses))
+	for i, token := range s.erc20Addresses {
+		var address common.Address
+		address, aggregators[i] = s.deployMockAggregator(8, shiftLeft(1, 8))
+		s.setFeed(oracle, token, address, time.Hour, 18)
+	}
+	s.requireTx(s.manager.SetOracle(s.signer, oracleAddress))
+	return aggregators
+}
+
+// singleRedeemEvents are the events of a redemption of rsvAmount in token by redeemer, who had
+// approved the Manager for exactly rsvAmount, getting amount and paying fee to recipient.
+func (s *ManagerSuite) singleRedeemEvents(
+	redeemer, recipient, token common.Address, rsvAmount, amount, fee *big.Int,
+) []fmt.Stringer {
+	events := []fmt.Stringer{
+		burningTransfer(redeemer, rsvAmount),
+		abi.ReserveApproval{Owner: redeemer, Spender: s.managerAddress, Value: bigInt(0)},
+		abi.BasicERC20Transfer{From: s.vaultAddress, To: redeemer, Value: amount},
+		abi.VaultWithdrawal{Token: token, Amount: amount, To: redeemer},
+	}
+	if fee.Sign() > 0 {
+		events = append(events,
+			abi.BasicERC20Transfer{From: s.vaultAddress, To: recipient, Value: fee},
+			abi.VaultWithdrawal{Token: token, Amount: fee, To: recipient},
+		)
+	}
+	return append(events, abi.ManagerSingleRedemption{
+		User: redeemer, Token: token, Amount: rsvAmount, TokenAmount: amount,
+	})
+}
+
+// TestRedeemSingle tests that `redeemSingle` pays out the whole redemption in one token, from
+// the Vault's inventory of it beyond what the basket needs, until that inventory runs out.
+func (s *ManagerSuite) TestRedeemSingle() {
+	s.setSingleRedemptionOracle()
+	token, erc20 := s.erc20Addresses[0], s.erc20s[0]
+	redeemer := s.account[4]
+
+	// 1000 RSV puts 100, 300, and 600 of the tokens in the Vault; 500 more of token0 leaves it
+	// 500 beyond the basket's 100.
+	s.requireTx(s.manager.Issue(signer(s.proposer), shiftLeft(1000, 18)))
+	s.requireTx(erc20.Transfer(signer(s.proposer), s.vaultAddress, shiftLeft(500, 18)))
+	s.requireTx(s.reserve.Transfer(signer(s.proposer), redeemer.address(), shiftLeft(1000, 18)))
+
+	// At a dollar, 400 RSV is worth 400 of token0, and nothing else leaves the Vault.
+	rsvAmount := shiftLeft(400, 18)
+	quoted, err := s.manager.ToRedeemSingle(nil, token, rsvAmount)
+	s.Require().NoError(err)
+	s.Equal(rsvAmount.String(), quoted.String())
+	s.requireTx(s.reserve.Approve(signer(redeemer), s.managerAddress, rsvAmount))
+	s.requireTxWithStrictEvents(s.manager.RedeemSingle(signer(redeemer), token, rsvAmount))(
+		s.singleRedeemEvents(redeemer.address(), zeroAddress(), token, rsvAmount, rsvAmount, bigInt(0))...,
+	)
+	balance, err := erc20.BalanceOf(nil, redeemer.address())
+	s.Require().NoError(err)
+	s.Equal(rsvAmount.String(), balance.String())
+	for _, other := range s.erc20s[1:] {
+		balance, err := other.BalanceOf(nil, redeemer.address())
+		s.Require().NoError(err)
+		s.Equal("0", balance.String())
+	}
+	s.assertRSVTotalSupply(shiftLeft(600, 18))
+	s.assertManagerCollateralized()
+
+	// Token1 has no inventory beyond the basket's, so not even 1 RSV redeems in it.
+	s.requireTx(s.reserve.Approve(signer(redeemer), s.managerAddress, shiftLeft(600, 18)))
+	s.requireTxFails(s.manager.RedeemSingle(signer(redeemer), s.erc20Addresses[1], shiftLeft(1, 18)))
+
+	// Token0 has 200 in the Vault, and the remaining 600 RSV need 60 of it. Redeeming r RSV
+	// needs 200 >= (600 - r) / 10 + r, so 156 RSV don't redeem; 150 do, and then 5 more, but
+	// not 6.
+	s.requireTxFails(s.manager.RedeemSingle(signer(redeemer), token, shiftLeft(156, 18)))
+	s.requireTx(s.manager.RedeemSingle(signer(redeemer), token, shiftLeft(150, 18)))
+	s.requireTxFails(s.manager.RedeemSingle(signer(redeemer), token, shiftLeft(6, 18)))
+	s.requireTx(s.manager.RedeemSingle(signer(redeemer), token, shiftLeft(5, 18)))
+	s.assertRSVBalance(redeemer.address(), shiftLeft(445, 18))
+	s.assertManagerCollateralized()
+
+	// The basket redemption still takes the rest.
+	s.requireTx(s.manager.Redeem(signer(redeemer), shiftLeft(445, 18)))
+	s.assertRSVTotalSupply(bigInt(0))
+	s.assertManagerCollateralized()
+}
+
+// TestRedeemSingleWithFee tests that the redemption fee of `redeemSingle` is paid in the same
+// token, out of the same inventory.
+func (s *ManagerSuite) TestRedeemSingleWithFee() {
+	s.setSingleRedemptionOracle()
+	token, erc20 := s.erc20Addresses[2], s.erc20s[2]
+	redeemer, recipient := s.proposer.address(), s.account[3].address()
+	s.requireTx(s.manager.SetRedemptionFeeRecipient(s.signer, recipient))
+	s.requireTx(s.manager.SetRedemptionFee(s.signer, bigInt(100)))
+
+	s.requireTx(s.manager.Issue(signer(s.proposer), shiftLeft(1000, 18)))
+	s.requireTx(erc20.Transfer(signer(s.proposer), s.vaultAddress, shiftLeft(100, 18)))
+
+	// 1% of 100 RSV, worth 100 of token2, goes to the recipient.
+	rsvAmount := shiftLeft(100, 18)
+	quoted, err := s.manager.ToRedeemSingle(nil, token, rsvAmount)
+	s.Require().NoError(err)
+	s.Equal(shiftLeft(99, 18).String(), quoted.String())
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, rsvAmount))
+	s.requireTxWithStrictEvents(s.manager.RedeemSingle(signer(s.proposer), token, rsvAmount))(
+		s.singleRedeemEvents(redeemer, recipient, token, rsvAmount, shiftLeft(99, 18), shiftLeft(1, 18))...,
+	)
+	balance, err := erc20.BalanceOf(nil, recipient)
+	s.Require().NoError(err)
+	s.Equal(shiftLeft(1, 18).String(), balance.String())
+
+	// The fee comes out of the same inventory: the Vault holds 600 of token2, of which the
+	// remaining 900 RSV need 540, less 0.6 for each RSV redeemed, so at most 150 RSV redeem in
+	// it, fee included.
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, shiftLeft(900, 18)))
+	s.requireTxFails(s.manager.RedeemSingle(signer(s.proposer), token, shiftLeft(151, 18)))
+	s.requireTx(s.manager.RedeemSingle(signer(s.proposer), token, shiftLeft(150, 18)))
+	s.assertManagerCollateralized()
+}
+
+// TestRedeemSinglePrices tests that `redeemSingle` pays at the oracle's price, refuses prices
+// that are stale or too far from a dollar, and needs an oracle and a basket token, while
+// `redeem` goes on regardless.
+func (s *ManagerSuite) TestRedeemSinglePrices() {
+	aggregators := s.setSingleRedemptionOracle()
+	token, erc20 := s.erc20Addresses[0], s.erc20s[0]
+	s.requireTx(s.manager.Issue(signer(s.proposer), shiftLeft(1000, 18)))
+	s.requireTx(erc20.Transfer(signer(s.proposer), s.vaultAddress, shiftLeft(500, 18)))
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, shiftLeft(1000, 18)))
+
+	// At 98 cents, 100 RSV is worth more of token0, rounded down.
+	s.requireTx(aggregators[0].SetAnswer(s.signer, bigInt(98000000), s.currentTimestamp()))
+	rsvAmount := shiftLeft(100, 18)
+	expected := bigInt(0).Div(bigInt(0).Mul(rsvAmount, shiftLeft(1, 18)), shiftLeft(98, 16))
+	quoted, err := s.manager.ToRedeemSingle(nil, token, rsvAmount)
+	s.Require().NoError(err)
+	s.Equal(expected.String(), quoted.String())
+	before, err := erc20.BalanceOf(nil, s.proposer.address())
+	s.Require().NoError(err)
+	s.requireTx(s.manager.RedeemSingle(signer(s.proposer), token, rsvAmount))
+	after, err := erc20.BalanceOf(nil, s.proposer.address())
+	s.Require().NoError(err)
+	s.Equal(expected.String(), bigInt(0).Sub(after, before).String())
+
+	// At 96 cents, too far off a dollar to price.
+	s.requireTx(aggregators[0].SetAnswer(s.signer, bigInt(96000000), s.currentTimestamp()))
+	_, err = s.manager.ToRedeemSingle(nil, token, rsvAmount)
+	s.Error(err)
+	s.requireTxFails(s.manager.RedeemSingle(signer(s.proposer), token, rsvAmount))
+
+	// Back at a dollar, until the feed goes stale; basket redemption goes on all the while.
+	s.requireTx(aggregators[0].SetAnswer(s.signer, shiftLeft(1, 8), s.currentTimestamp()))
+	s.requireTx(s.manager.RedeemSingle(signer(s.proposer), token, rsvAmount))
+	s.Require().NoError(s.node.(backend).AdjustTime(2 * time.Hour))
+	_, err = s.manager.ToRedeemSingle(nil, token, rsvAmount)
+	s.Error(err)
+	s.requireTxFails(s.manager.RedeemSingle(signer(s.proposer), token, rsvAmount))
+	s.requireTx(s.manager.Redeem(signer(s.proposer), rsvAmount))
+
+	// A fresh answer lets it through again.
+	s.requireTx(aggregators[0].SetAnswer(s.signer, shiftLeft(1, 8), s.currentTimestamp()))
+	s.requireTx(s.manager.RedeemSingle(signer(s.proposer), token, rsvAmount))
+
+	// Neither a token outside the basket, nor any token without an oracle.
+	s.requireTxFails(s.manager.RedeemSingle(signer(s.proposer), s.reserveAddress, rsvAmount))
+	s.requireTx(s.manager.SetOracle(s.signer, zeroAddress()))
+	s.requireTxFails(s.manager.RedeemSingle(signer(s.proposer), token, rsvAmount))
+	s.requireTxFails(s.manager.RedeemSingle(signer(s.proposer), token, bigInt(0)))
+	s.assertRSVTotalSupply(shiftLeft(600, 18))
+	s.assertManagerCollateralized()
+}
+
+// TestRedeem tests that `redeem` compensates the person with the correct amounts.
+func (s *ManagerSuite) TestRedeem() {
+	// Issue.
+	rsvAmount := shiftLeft(1, 27) // 1 billion
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+
+	redeemer := s.account[4]
+
+	// Send the RSV to someone else who doesn't have any Erc20s.
+	s.requireTx(s.reserve.Transfer(signer(s.proposer), redeemer.address(), rsvAmount))
+
+	// Redeem that RSV.
+	s.requireTx(s.reserve.Approve(signer(redeemer), s.managerAddress, rsvAmount))
+	s.requireTx(s.manager.Redeem(signer(redeemer), rsvAmount))
+
+	// Figure out what to expect back.
+	amounts := s.computeExpectedRedeemAmounts(rsvAmount)
+
+	// Assert our balances are what we expected.
 	for i, erc20 := range s.erc20s {
 		// Expect no leftover tokens.
 		balance, err := erc20.BalanceOf(nil, redeemer.address())
@@ -553,6 +1980,274 @@ func (s *ManagerSuite) TestRedeem() {
 	s.assertManagerCollateralized()
 }
 
+// TestIssueRedeemGas records what issuing and redeeming cost, the first issuance paying for the
+// issuer's new RSV balance.
+func (s *ManagerSuite) TestIssueRedeemGas() {
+	rsvAmount := shiftLeft(1, 27) // 1 billion
+	s.recordGas("Manager.issue (new holder)", s.manager.Issue(signer(s.proposer), rsvAmount))
+	s.recordGas("Manager.issue", s.manager.Issue(signer(s.proposer), rsvAmount))
+
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, rsvAmount))
+	s.recordGas("Manager.redeem", s.manager.Redeem(signer(s.proposer), rsvAmount))
+	s.assertManagerCollateralized()
+}
+
+// TestDeploymentGas records what deploying each of the Manager's contracts and the contracts
+// around it costs, for rsvsize. The Reserve's are in the ReserveSuite's TestDeploymentGas.
+func (s *ManagerSuite) TestDeploymentGas() {
+	_, tx, _, err := abi.DeployVault(s.signer, s.node)
+	s.recordGas(sizes.DeploymentBenchmark("Vault"), tx, err)
+	_, tx, _, err = abi.DeployYieldVault(s.signer, s.node)
+	s.recordGas(sizes.DeploymentBenchmark("YieldVault"), tx, err)
+	_, tx, _, err = abi.DeployProposalFactory(s.signer, s.node)
+	s.recordGas(sizes.DeploymentBenchmark("ProposalFactory"), tx, err)
+	_, tx, _, err = abi.DeployBasket(s.signer, s.node, zeroAddress(), s.erc20Addresses, s.weights)
+	s.recordGas(sizes.DeploymentBenchmark("Basket"), tx, err)
+	_, tx, _, err = abi.DeployManager(
+		s.signer, s.node,
+		s.vaultAddress, s.reserveAddress, s.proposalFactoryAddress, s.basketAddress, s.operator.address(), bigInt(0),
+	)
+	s.recordGas(sizes.DeploymentBenchmark("Manager"), tx, err)
+
+	timelockAddress, tx, _, err := abi.DeployTimelock(s.signer, s.node, s.owner.address(), seconds(timelockDelay))
+	s.recordGas(sizes.DeploymentBenchmark("Timelock"), tx, err)
+	_, tx, _, err = abi.DeployUpkeep(s.signer, s.node, timelockAddress, s.managerAddress)
+	s.recordGas(sizes.DeploymentBenchmark("Upkeep"), tx, err)
+	_, tx, _, err = abi.DeployCollateralOracle(s.signer, s.node, bigInt(300))
+	s.recordGas(sizes.DeploymentBenchmark("CollateralOracle"), tx, err)
+	_, tx, _, err = abi.DeployCollateralRegistry(s.signer, s.node)
+	s.recordGas(sizes.DeploymentBenchmark("CollateralRegistry"), tx, err)
+	_, tx, _, err = abi.DeployDutchAuction(s.signer, s.node, s.managerAddress)
+	s.recordGas(sizes.DeploymentBenchmark("DutchAuction"), tx, err)
+	_, tx, _, err = abi.DeployInsuranceFund(s.signer, s.node, s.managerAddress)
+	s.recordGas(sizes.DeploymentBenchmark("InsuranceFund"), tx, err)
+	_, tx, _, err = abi.DeployFeeTreasury(s.signer, s.node, bigInt(3600))
+	s.recordGas(sizes.DeploymentBenchmark("FeeTreasury"), tx, err)
+	_, tx, _, err = abi.DeployCreate2Deployer(s.signer, s.node)
+	s.recordGas(sizes.DeploymentBenchmark("Create2Deployer"), tx, err)
+}
+
+// TestFlashLoanCannotIssueOrRedeem tests that RSV lent by a flash loan can be neither issued
+// against nor redeemed, though the same borrower can issue and redeem outside of one.
+func (s *ManagerSuite) TestFlashLoanCannotIssueOrRedeem() {
+	borrowerAddress, borrower := s.deployFlashBorrower(s.managerAddress)
+	rsvAmount := shiftLeft(1, 27)
+
+	// Give the borrower plenty of collateral, approved for the Manager.
+	for i, erc20 := range s.erc20s {
+		s.requireTx(erc20.Transfer(s.signer, borrowerAddress, shiftLeft(1, 46)))
+		s.requireTx(borrower.Approve(s.signer, s.erc20Addresses[i], s.managerAddress, shiftLeft(1, 46)))
+	}
+
+	s.requireTx(borrower.Issue(s.signer, rsvAmount))
+	s.requireTx(s.reserve.ChangeFlashMintCap(s.signer, rsvAmount))
+
+	s.requireTxFails(borrower.Borrow(s.signer, rsvAmount, flashIssue))
+	s.requireTxFails(borrower.Borrow(s.signer, rsvAmount, flashRedeem))
+	s.assertRSVBalance(borrowerAddress, rsvAmount)
+	s.assertRSVTotalSupply(rsvAmount)
+	s.assertManagerCollateralized()
+
+	// Loans themselves still work, and so do issuance and redemption once they're repaid.
+	s.requireTx(borrower.Borrow(s.signer, rsvAmount, flashRepay))
+	s.requireTx(borrower.Issue(s.signer, rsvAmount))
+	s.requireTx(borrower.Redeem(s.signer, bigInt(0).Mul(rsvAmount, bigInt(2))))
+	s.assertRSVBalance(borrowerAddress, bigInt(0))
+	s.assertRSVTotalSupply(bigInt(0))
+	s.assertManagerCollateralized()
+}
+
+// redeemEvents are the events of a redemption of rsvAmount by redeemer, who had approved the
+// Manager for exactly rsvAmount, getting amounts and paying fees to recipient.
+func (s *ManagerSuite) redeemEvents(
+	redeemer, recipient common.Address, rsvAmount *big.Int, amounts, fees []*big.Int,
+) []fmt.Stringer {
+	events := []fmt.Stringer{
+		burningTransfer(redeemer, rsvAmount),
+		abi.ReserveApproval{Owner: redeemer, Spender: s.managerAddress, Value: bigInt(0)},
+	}
+	for i, token := range s.erc20Addresses {
+		events = append(events,
+			abi.BasicERC20Transfer{From: s.vaultAddress, To: redeemer, Value: amounts[i]},
+			abi.VaultWithdrawal{Token: token, Amount: amounts[i], To: redeemer},
+		)
+		if fees != nil && fees[i].Sign() > 0 {
+			events = append(events,
+				abi.BasicERC20Transfer{From: s.vaultAddress, To: recipient, Value: fees[i]},
+				abi.VaultWithdrawal{Token: token, Amount: fees[i], To: recipient},
+			)
+		}
+	}
+	return append(events, abi.ManagerRedemption{User: redeemer, Amount: rsvAmount})
+}
+
+// TestRedeemWithZeroFee tests that with a recipient set but no fee, a redemption is just as it
+// is without either.
+func (s *ManagerSuite) TestRedeemWithZeroFee() {
+	rsvAmount := shiftLeft(1, 27)
+	redeemer, recipient := s.proposer.address(), s.account[3].address()
+	s.requireTx(s.manager.SetRedemptionFeeRecipient(s.signer, recipient))
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+
+	amounts := s.computeExpectedRedeemAmounts(rsvAmount)
+	quoted, err := s.manager.ToRedeem(nil, rsvAmount)
+	s.Require().NoError(err)
+	s.Equal(fmt.Sprint(amounts), fmt.Sprint(quoted))
+
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, rsvAmount))
+	s.requireTxWithStrictEvents(s.manager.Redeem(signer(s.proposer), rsvAmount))(
+		s.redeemEvents(redeemer, recipient, rsvAmount, amounts, nil)...,
+	)
+	for _, erc20 := range s.erc20s {
+		balance, err := erc20.BalanceOf(nil, recipient)
+		s.Require().NoError(err)
+		s.Equal("0", balance.String())
+	}
+	s.assertManagerCollateralized()
+}
+
+// TestRedemptionFee tests the fee math for baskets of tokens of many decimals, and that the
+// fee and the redeemer's share together are what a redemption without a fee would withdraw.
+func (s *ManagerSuite) TestRedemptionFee() {
+	redeemer, recipient := s.proposer.address(), s.account[3].address()
+	s.requireTx(s.manager.SetRedemptionFeeRecipient(s.signer, recipient))
+
+	// An amount that divides evenly by nothing in particular.
+	rsvAmount := bigInt(0).Add(shiftLeft(1234, 18), bigInt(56789))
+
+	for _, decimals := range [][]uint32{{6, 18, 6}, {0, 2, 8}, {18, 27, 36}, {1, 0, 3}} {
+		// One tenth of an RSV is backed by 2, 3, and 5 whole tokens.
+		weights := make([]*big.Int, len(decimals))
+		for i, share := range []uint32{2, 3, 5} {
+			weights[i] = shiftLeft(share, decimals[i]+17)
+		}
+		s.changeBasketUsingWeightProposal(s.erc20Addresses, weights)
+
+		for _, fee := range []uint32{1, 25, 1000} {
+			s.requireTx(s.manager.SetRedemptionFee(s.signer, bigInt(fee)))
+			s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+
+			feeRSV := bigInt(0).Div(bigInt(0).Mul(rsvAmount, bigInt(fee)), bigInt(10000))
+			amounts := s.computeExpectedRedeemAmounts(bigInt(0).Sub(rsvAmount, feeRSV))
+			gross := s.computeExpectedRedeemAmounts(rsvAmount)
+			fees := make([]*big.Int, len(gross))
+			for i := range gross {
+				fees[i] = bigInt(0).Sub(gross[i], amounts[i])
+			}
+
+			quoted, err := s.manager.ToRedeem(nil, rsvAmount)
+			s.Require().NoError(err)
+			s.Equal(fmt.Sprint(amounts), fmt.Sprint(quoted), "decimals %v, fee %v", decimals, fee)
+
+			before := s.tokenBalances(redeemer, recipient, s.vaultAddress)
+			s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, rsvAmount))
+			s.requireTxWithStrictEvents(s.manager.Redeem(signer(s.proposer), rsvAmount))(
+				s.redeemEvents(redeemer, recipient, rsvAmount, amounts, fees)...,
+			)
+			after := s.tokenBalances(redeemer, recipient, s.vaultAddress)
+
+			for i := range s.erc20s {
+				msg := fmt.Sprintf("decimals %v, fee %v, token %v", decimals, fee, i)
+				s.Equal(amounts[i].String(), bigInt(0).Sub(after[0][i], before[0][i]).String(), msg)
+				s.Equal(fees[i].String(), bigInt(0).Sub(after[1][i], before[1][i]).String(), msg)
+				s.Equal(gross[i].String(), bigInt(0).Sub(before[2][i], after[2][i]).String(), msg)
+			}
+			s.assertManagerCollateralized()
+		}
+		s.requireTx(s.manager.SetRedemptionFee(s.signer, bigInt(0)))
+	}
+}
+
+// tokenBalances returns the balance of each collateral token of each holder.
+func (s *ManagerSuite) tokenBalances(holders ...common.Address) [][]*big.Int {
+	balances := make([][]*big.Int, len(holders))
+	for i, holder := range holders {
+		for _, erc20 := range s.erc20s {
+			balance, err := erc20.BalanceOf(nil, holder)
+			s.Require().NoError(err)
+			balances[i] = append(balances[i], balance)
+		}
+	}
+	return balances
+}
+
+// weightedAmounts returns `rsvAmount` of RSV in each of `weights`' tokens, rounded up or down.
+func weightedAmounts(rsvAmount *big.Int, weights []*big.Int, up bool) []*big.Int {
+	scale := shiftLeft(1, 36) // aqToken/qToken * qRSV/RSV
+	amounts := make([]*big.Int, len(weights))
+	for i, weight := range weights {
+		quotient, remainder := bigInt(0).DivMod(bigInt(0).Mul(rsvAmount, weight), scale, bigInt(0))
+		if up && remainder.Sign() > 0 {
+			quotient.Add(quotient, bigInt(1))
+		}
+		amounts[i] = quotient
+	}
+	return amounts
+}
+
+// TestDecimalMatrix tests issuance, redemption, and swaps for baskets of tokens of every mix of
+// few and many decimals, in amounts from one qRSV to a billion RSV: each issuance takes in its
+// amounts rounded up, each redemption pays out its amounts rounded down, and the Vault stays
+// fully collateralized throughout. A billion RSV of a 36-decimal token weighs more than a
+// uint256 holds, before the division that brings it back to qTokens.
+func (s *ManagerSuite) TestDecimalMatrix() {
+	rsvAmounts := []*big.Int{
+		bigInt(1),
+		bigInt(0).Add(shiftLeft(1234, 18), bigInt(56789)),
+		shiftLeft(1, 27),
+	}
+	matrix := [][]uint32{
+		{2, 2, 2}, {2, 6, 18}, {6, 18, 6}, {18, 6, 2},
+		{8, 27, 12}, {2, 18, 36}, {36, 6, 36}, {36, 36, 36},
+	}
+	for _, decimals := range matrix {
+		// One tenth of an RSV is backed by 2, 3, and 5 whole tokens, and 7 aqTokens more, so
+		// that nearly every amount rounds.
+		weights := make([]*big.Int, len(decimals))
+		for i, share := range []uint32{2, 3, 5} {
+			weights[i] = bigInt(0).Add(shiftLeft(share, decimals[i]+17), bigInt(7))
+		}
+		s.changeBasketUsingWeightProposal(s.erc20Addresses, weights)
+
+		for _, rsvAmount := range rsvAmounts {
+			msg := fmt.Sprintf("decimals %v, amount %v", decimals, rsvAmount)
+			amounts := weightedAmounts(rsvAmount, weights, true)
+			quoted, err := s.manager.ToIssue(nil, rsvAmount)
+			s.Require().NoError(err, msg)
+			s.Equal(fmt.Sprint(amounts), fmt.Sprint(quoted), msg)
+
+			before := s.tokenBalances(s.vaultAddress)[0]
+			s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+			for i, balance := range s.tokenBalances(s.vaultAddress)[0] {
+				s.Equal(amounts[i].String(), bigInt(0).Sub(balance, before[i]).String(), msg)
+			}
+			s.assertManagerCollateralized()
+		}
+
+		// Swap a hundred million of each token, in or out, against more than a billion RSV.
+		swapAmounts := make([]*big.Int, len(decimals))
+		for i := range decimals {
+			swapAmounts[i] = shiftLeft(1, decimals[i]+8)
+		}
+		s.changeBasketUsingSwapProposal(s.erc20Addresses, swapAmounts, []bool{true, false, true})
+
+		for _, rsvAmount := range rsvAmounts {
+			msg := fmt.Sprintf("decimals %v, amount %v", decimals, rsvAmount)
+			before := s.tokenBalances(s.vaultAddress)[0]
+			s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, rsvAmount))
+			s.requireTx(s.manager.Redeem(signer(s.proposer), rsvAmount))
+			after := s.tokenBalances(s.vaultAddress)[0]
+
+			amounts := s.computeExpectedRedeemAmounts(rsvAmount)
+			for i := range amounts {
+				s.Equal(amounts[i].String(), bigInt(0).Sub(before[i], after[i]).String(), msg)
+			}
+			s.assertManagerCollateralized()
+		}
+		s.assertRSVTotalSupply(bigInt(0))
+	}
+}
+
 // TestRedeemIsProtected tests that `redeem` compensates the person with the correct amounts.
 func (s *ManagerSuite) TestRedeemIsProtected() {
 	// Issue.
@@ -622,6 +2317,155 @@ func (s *ManagerSuite) TestRedeemRequireStatements() {
 	s.assertManagerCollateralized()
 }
 
+// pauseReserve makes the owner the Reserve's pauser, so that it can unpause again, and pauses
+// the Reserve.
+func (s *ManagerSuite) pauseReserve() {
+	s.requireTx(s.reserve.ChangePauser(s.signer, s.owner.address()))
+	s.requireTx(s.reserve.Pause(s.signer))
+}
+
+// emergencyRedeemAmounts returns each basket token's share of the Vault that `rsvAmount` can
+// redeem in an emergency, rounded down.
+func (s *ManagerSuite) emergencyRedeemAmounts(rsvAmount *big.Int) []*big.Int {
+	supply, err := s.reserve.TotalSupply(nil)
+	s.Require().NoError(err)
+	amounts := s.tokenBalances(s.vaultAddress)[0]
+	for i := range amounts {
+		amounts[i] = bigInt(0).Div(bigInt(0).Mul(amounts[i], rsvAmount), supply)
+	}
+	return amounts
+}
+
+// emergencyRedeem redeems `rsvAmount` for `redeemer` in an emergency, and checks that it gets
+// its pro-rata share of the Vault.
+func (s *ManagerSuite) emergencyRedeem(redeemer account, rsvAmount *big.Int) {
+	amounts := s.emergencyRedeemAmounts(rsvAmount)
+	before := s.tokenBalances(redeemer.address())[0]
+	allowance, err := s.reserve.Allowance(nil, redeemer.address(), s.managerAddress)
+	s.Require().NoError(err)
+
+	events := []fmt.Stringer{
+		burningTransfer(redeemer.address(), rsvAmount),
+		abi.ReserveApproval{
+			Owner: redeemer.address(), Spender: s.managerAddress, Value: bigInt(0).Sub(allowance, rsvAmount),
+		},
+	}
+	for i, token := range s.erc20Addresses {
+		if amounts[i].Sign() > 0 {
+			events = append(events,
+				abi.BasicERC20Transfer{From: s.vaultAddress, To: redeemer.address(), Value: amounts[i]},
+				abi.VaultWithdrawal{Token: token, Amount: amounts[i], To: redeemer.address()},
+			)
+		}
+	}
+	events = append(events, abi.ManagerEmergencyRedemption{User: redeemer.address(), Amount: rsvAmount})
+	s.requireTxWithStrictEvents(s.manager.EmergencyRedeem(signer(redeemer), rsvAmount))(events...)
+
+	for i, balance := range s.tokenBalances(redeemer.address())[0] {
+		s.Equal(bigInt(0).Add(before[i], amounts[i]).String(), balance.String())
+	}
+}
+
+// TestEmergencyRedeem tests that holders redeem their pro-rata share of the Vault once emergency
+// redemption opens, however the Vault is backed, and that the last RSV takes what is left.
+func (s *ManagerSuite) TestEmergencyRedeem() {
+	rsvAmount := shiftLeft(1, 27)
+	holder := s.account[3]
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+	half := bigInt(0).Div(rsvAmount, bigInt(2))
+	s.requireTx(s.reserve.Transfer(signer(s.proposer), holder.address(), half))
+
+	// Approvals can't be made while paused.
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, rsvAmount))
+	s.requireTx(s.reserve.Approve(signer(holder), s.managerAddress, half))
+
+	// Make the Vault back RSV unevenly: extra of the first token, and short of the second.
+	s.requireTx(s.erc20s[0].Transfer(signer(s.proposer), s.vaultAddress, shiftLeft(7, 30)))
+	s.requireTx(s.vault.ChangeManager(s.signer, s.owner.address()))
+	s.requireTx(s.vault.WithdrawTo(s.signer, s.erc20Addresses[1], shiftLeft(1, 30), s.owner.address()))
+	s.requireTx(s.vault.ChangeManager(s.signer, s.managerAddress))
+
+	s.pauseReserve()
+	s.requireTx(s.reserve.StartEmergencyRedemption(s.signer))
+
+	// An amount that divides evenly by nothing in particular.
+	s.emergencyRedeem(s.proposer, bigInt(0).Add(shiftLeft(1234, 18), bigInt(56789)))
+	s.emergencyRedeem(holder, bigInt(1))
+	s.emergencyRedeem(holder, bigInt(0).Sub(half, bigInt(1)))
+
+	// The last holder redeems the whole Vault.
+	rest, err := s.reserve.BalanceOf(nil, s.proposer.address())
+	s.Require().NoError(err)
+	vault := s.tokenBalances(s.vaultAddress)[0]
+	s.emergencyRedeem(s.proposer, rest)
+	s.assertRSVTotalSupply(bigInt(0))
+	for i, balance := range s.tokenBalances(s.vaultAddress)[0] {
+		s.Equal("0", balance.String(), "token %v of %v", i, vault[i])
+	}
+}
+
+// TestEmergencyRedeemAfterDelay tests that emergency redemption opens on its own once the
+// Reserve has been paused for EMERGENCY_REDEMPTION_DELAY, and even in a Manager emergency.
+func (s *ManagerSuite) TestEmergencyRedeemAfterDelay() {
+	rsvAmount := shiftLeft(1, 27)
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, rsvAmount))
+	s.requireTx(s.manager.SetEmergency(signer(s.operator), true))
+
+	s.pauseReserve()
+	s.Require().NoError(s.node.(backend).AdjustTime(30*24*time.Hour - time.Minute))
+	s.requireTxFails(s.manager.EmergencyRedeem(signer(s.proposer), bigInt(1)))
+	s.Require().NoError(s.node.(backend).AdjustTime(time.Minute))
+	s.emergencyRedeem(s.proposer, rsvAmount)
+	s.assertRSVTotalSupply(bigInt(0))
+}
+
+// TestEmergencyRedeemIsProtected tests that emergency redemption can't be used while the system
+// is healthy, nor before it opens, nor after the Reserve unpauses, nor by frozen accounts.
+func (s *ManagerSuite) TestEmergencyRedeemIsProtected() {
+	rsvAmount := shiftLeft(1, 27)
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, rsvAmount))
+	vault := s.tokenBalances(s.vaultAddress)[0]
+
+	// Not while the Reserve runs, even after the delay; it can't be started early either.
+	s.requireTxFails(s.manager.EmergencyRedeem(signer(s.proposer), bigInt(1)))
+	s.Require().NoError(s.node.(backend).AdjustTime(31 * 24 * time.Hour))
+	s.requireTxFails(s.manager.EmergencyRedeem(signer(s.proposer), bigInt(1)))
+	s.requireTxFails(s.reserve.StartEmergencyRedemption(s.signer))
+
+	// Not during a short pause, nor can anyone but the Reserve's owner start it.
+	s.pauseReserve()
+	s.requireTxFails(s.manager.EmergencyRedeem(signer(s.proposer), bigInt(1)))
+	s.requireTxFails(s.reserve.StartEmergencyRedemption(signer(s.operator)))
+	s.requireTxFails(s.reserve.StartEmergencyRedemption(signer(s.proposer)))
+	s.requireTxFails(s.manager.EmergencyRedeem(signer(s.proposer), bigInt(1)))
+
+	// Not around the Manager.
+	s.requireTx(s.reserve.StartEmergencyRedemption(s.signer))
+	s.requireTxFails(s.reserve.EmergencyBurnFrom(signer(s.proposer), s.proposer.address(), bigInt(1)))
+
+	// Not for nothing, nor more than the holder has.
+	s.requireTxFails(s.manager.EmergencyRedeem(signer(s.proposer), bigInt(0)))
+	s.requireTxFails(s.manager.EmergencyRedeem(signer(s.operator), bigInt(1)))
+	s.requireTxFails(s.manager.EmergencyRedeem(signer(s.proposer), bigInt(0).Add(rsvAmount, bigInt(1))))
+
+	// Not from a frozen account.
+	s.requireTx(s.reserve.ChangeFreezer(s.signer, s.owner.address()))
+	s.requireTx(s.reserve.Freeze(s.signer, s.proposer.address()))
+	s.requireTxFails(s.manager.EmergencyRedeem(signer(s.proposer), bigInt(1)))
+	s.requireTx(s.reserve.Unfreeze(s.signer, s.proposer.address()))
+
+	// Not once the Reserve unpauses.
+	s.requireTx(s.reserve.Unpause(s.signer))
+	s.requireTxFails(s.manager.EmergencyRedeem(signer(s.proposer), bigInt(1)))
+
+	// Nothing was redeemed.
+	s.assertRSVTotalSupply(rsvAmount)
+	s.Equal(fmt.Sprint(vault), fmt.Sprint(s.tokenBalances(s.vaultAddress)[0]))
+	s.assertManagerCollateralized()
+}
+
 // TestProposeWeightsUseCase sets a basket, issues RSV, changes the basket, and redeems RSV.
 func (s *ManagerSuite) TestProposeWeightsFullUsecase() {
 	// Issue a billion RSV.
@@ -673,6 +2517,35 @@ func (s *ManagerSuite) TestProposeSwapFullUsecase() {
 	s.assertRSVTotalSupply(bigInt(0))
 }
 
+// TestProposalGas records what each step of changing the basket costs, by a WeightProposal and
+// by a SwapProposal.
+func (s *ManagerSuite) TestProposalGas() {
+	s.requireTx(s.manager.Issue(signer(s.proposer), shiftLeft(1, 27)))
+	lastProposal := func() *big.Int {
+		length, err := s.manager.ProposalsLength(nil)
+		s.Require().NoError(err)
+		return new(big.Int).Sub(length, bigInt(1))
+	}
+
+	weights := []*big.Int{shiftLeft(2, 35), shiftLeft(3, 35), shiftLeft(5, 35)}
+	s.recordGas("Manager.proposeWeights",
+		s.manager.ProposeWeights(signer(s.proposer), s.erc20Addresses, weights))
+	id := lastProposal()
+	s.recordGas("Manager.acceptProposal", s.manager.AcceptProposal(signer(s.operator), id))
+	s.Require().NoError(s.node.(backend).AdjustTime(24 * time.Hour))
+	s.recordGas("Manager.executeProposal (weights)", s.manager.ExecuteProposal(signer(s.operator), id))
+
+	amounts := []*big.Int{shiftLeft(2, 17), shiftLeft(3, 17), shiftLeft(1, 17)}
+	toVault := []bool{true, false, true}
+	s.recordGas("Manager.proposeSwap",
+		s.manager.ProposeSwap(signer(s.proposer), s.erc20Addresses, amounts, toVault))
+	id = lastProposal()
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), id))
+	s.Require().NoError(s.node.(backend).AdjustTime(24 * time.Hour))
+	s.recordGas("Manager.executeProposal (swap)", s.manager.ExecuteProposal(signer(s.operator), id))
+	s.assertManagerCollateralized()
+}
+
 // TestRemoveTokenUsecase removes a token from the initial basket
 func (s *ManagerSuite) TestRemoveTokenUsecase() {
 	// Check basket size == 3
@@ -714,6 +2587,744 @@ func (s *ManagerSuite) TestAddTokenUsecase() {
 	s.Equal("4", size.String())
 }
 
+// proposalState returns the state of proposal `id`: 0 created, 1 accepted, 2 cancelled, or 3
+// completed.
+func (s *ManagerSuite) proposalState(id *big.Int) uint8 {
+	proposalAddress, err := s.manager.TrustedProposals(nil, id)
+	s.Require().NoError(err)
+	proposal, err := abi.NewRebalanceProposal(proposalAddress, s.node)
+	s.Require().NoError(err)
+	state, err := proposal.State(nil)
+	s.Require().NoError(err)
+	return state
+}
+
+// TestSetVetoer tests that only the owner can set the vetoer.
+func (s *ManagerSuite) TestSetVetoer() {
+	vetoer := s.account[6].address()
+	found, err := s.manager.Vetoer(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), found)
+
+	s.requireTxFails(s.manager.SetVetoer(signer(s.operator), vetoer))
+	s.requireTxFails(s.manager.SetVetoer(signer(s.account[6]), vetoer))
+	s.requireTxWithStrictEvents(s.manager.SetVetoer(s.signer, vetoer))(
+		abi.ManagerVetoerChanged{OldAccount: zeroAddress(), NewAccount: vetoer},
+	)
+	found, err = s.manager.Vetoer(nil)
+	s.Require().NoError(err)
+	s.Equal(vetoer, found)
+
+	// The vetoer can't pass the role on itself.
+	s.requireTxFails(s.manager.SetVetoer(signer(s.account[6]), s.account[7].address()))
+	s.requireTxWithStrictEvents(s.manager.SetVetoer(s.signer, zeroAddress()))(
+		abi.ManagerVetoerChanged{OldAccount: vetoer, NewAccount: zeroAddress()},
+	)
+}
+
+// TestVetoProposal tests that only the vetoer can veto, and only an accepted proposal, which is
+// then cancelled for good.
+func (s *ManagerSuite) TestVetoProposal() {
+	vetoer := s.account[6]
+	s.requireTx(s.manager.SetVetoer(s.signer, vetoer.address()))
+	id := s.proposeRebalance(s.erc20Addresses[1], s.erc20Addresses[0], 3333, shiftLeft(1, 18))
+
+	// Not before the proposal is accepted.
+	s.requireTxFails(s.manager.VetoProposal(signer(vetoer), id))
+	s.Equal(uint8(0), s.proposalState(id))
+
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), id))
+	s.Equal(uint8(1), s.proposalState(id))
+
+	// Not by anyone else, however else they may cancel it.
+	for _, acc := range []account{s.owner, s.operator, s.proposer, s.account[7]} {
+		s.requireTxFails(s.manager.VetoProposal(signer(acc), id))
+	}
+	s.Equal(uint8(1), s.proposalState(id))
+
+	// Nor a proposal that doesn't exist.
+	s.requireTxFails(s.manager.VetoProposal(signer(vetoer), bigInt(0).Add(id, bigInt(1))))
+
+	s.requireTxWithStrictEvents(s.manager.VetoProposal(signer(vetoer), id))(
+		abi.RebalanceProposalProposalCancelled{Proposer: s.proposer.address()},
+		abi.ManagerProposalVetoed{Id: id, Proposer: s.proposer.address(), Vetoer: vetoer.address()},
+	)
+	s.Equal(uint8(2), s.proposalState(id))
+
+	// A vetoed proposal can't be vetoed again, accepted again, or executed.
+	s.requireTxFails(s.manager.VetoProposal(signer(vetoer), id))
+	s.requireTxFails(s.manager.AcceptProposal(signer(s.operator), id))
+	s.Require().NoError(s.node.(backend).AdjustTime(25 * time.Hour))
+	s.requireTxFails(s.manager.ExecuteProposal(signer(s.operator), id))
+	s.Equal(uint8(2), s.proposalState(id))
+}
+
+// TestVetoWindow tests that a proposal can be vetoed while it waits out the delay, even during an
+// emergency, but not once it can be executed.
+func (s *ManagerSuite) TestVetoWindow() {
+	vetoer := s.account[6]
+	s.requireTx(s.manager.SetVetoer(s.signer, vetoer.address()))
+
+	// During an emergency, with most of the Manager stopped, a veto still goes through.
+	first := s.proposeRebalance(s.erc20Addresses[1], s.erc20Addresses[0], 3333, shiftLeft(1, 18))
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), first))
+	s.Require().NoError(s.node.(backend).AdjustTime(23 * time.Hour))
+	s.requireTx(s.manager.SetEmergency(signer(s.operator), true))
+	s.requireTx(s.manager.VetoProposal(signer(vetoer), first))
+	s.Equal(uint8(2), s.proposalState(first))
+	s.requireTx(s.manager.SetEmergency(signer(s.operator), false))
+
+	// Once the delay has passed, the vetoer is too late, and the proposal runs.
+	second := s.proposeRebalance(s.erc20Addresses[1], s.erc20Addresses[0], 3333, shiftLeft(1, 18))
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), second))
+	s.Require().NoError(s.node.(backend).AdjustTime(25 * time.Hour))
+	s.requireTxFails(s.manager.VetoProposal(signer(vetoer), second))
+	s.Equal(uint8(1), s.proposalState(second))
+	s.executeProposal(second)
+	s.Equal(uint8(3), s.proposalState(second))
+	s.requireTxFails(s.manager.VetoProposal(signer(vetoer), second))
+	s.assertManagerCollateralized()
+}
+
+// TestWithdrawProposal tests that a proposer can cancel its own pending proposal, with a reason,
+// and that the proposal can then never be accepted or executed.
+func (s *ManagerSuite) TestWithdrawProposal() {
+	id := s.proposeRebalance(s.erc20Addresses[1], s.erc20Addresses[0], 3333, shiftLeft(1, 18))
+
+	// Not by anyone else, however else they may cancel it.
+	for _, acc := range []account{s.owner, s.operator, s.account[7]} {
+		s.requireTxFails(s.manager.WithdrawProposal(signer(acc), id, "not mine"))
+	}
+	// Nor a proposal that doesn't exist.
+	s.requireTxFails(s.manager.WithdrawProposal(signer(s.proposer), bigInt(0).Add(id, bigInt(1)), "none"))
+	// Nor during an emergency.
+	s.requireTx(s.manager.SetEmergency(signer(s.operator), true))
+	s.requireTxFails(s.manager.WithdrawProposal(signer(s.proposer), id, "emergency"))
+	s.requireTx(s.manager.SetEmergency(signer(s.operator), false))
+	s.Equal(uint8(0), s.proposalState(id))
+
+	s.requireTxWithStrictEvents(s.manager.WithdrawProposal(signer(s.proposer), id, "wrong rate"))(
+		abi.RebalanceProposalProposalCancelled{Proposer: s.proposer.address()},
+		abi.ManagerProposalCancelled{Id: id, Proposer: s.proposer.address(), Reason: "wrong rate"},
+	)
+	s.Equal(uint8(2), s.proposalState(id))
+
+	// A withdrawn proposal can't be withdrawn again, accepted, or executed, however long we wait.
+	s.requireTxFails(s.manager.WithdrawProposal(signer(s.proposer), id, "again"))
+	s.requireTxFails(s.manager.AcceptProposal(signer(s.operator), id))
+	s.Require().NoError(s.node.(backend).AdjustTime(25 * time.Hour))
+	s.requireTxFails(s.manager.ExecuteProposal(signer(s.operator), id))
+	s.Equal(uint8(2), s.proposalState(id))
+}
+
+// TestWithdrawAcceptedProposal tests that a proposer can cancel its proposal once accepted, which
+// then can't be executed even after the delay, but not once it has been executed.
+func (s *ManagerSuite) TestWithdrawAcceptedProposal() {
+	first := s.proposeRebalance(s.erc20Addresses[1], s.erc20Addresses[0], 3333, shiftLeft(1, 18))
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), first))
+	s.requireTxWithStrictEvents(s.manager.WithdrawProposal(signer(s.proposer), first, ""))(
+		abi.RebalanceProposalProposalCancelled{Proposer: s.proposer.address()},
+		abi.ManagerProposalCancelled{Id: first, Proposer: s.proposer.address(), Reason: ""},
+	)
+	s.Require().NoError(s.node.(backend).AdjustTime(25 * time.Hour))
+	s.requireTxFails(s.manager.ExecuteProposal(signer(s.operator), first))
+	s.Equal(uint8(2), s.proposalState(first))
+
+	second := s.proposeRebalance(s.erc20Addresses[1], s.erc20Addresses[0], 3333, shiftLeft(1, 18))
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), second))
+	s.Require().NoError(s.node.(backend).AdjustTime(25 * time.Hour))
+	s.executeProposal(second)
+	s.requireTxFails(s.manager.WithdrawProposal(signer(s.proposer), second, "too late"))
+	s.Equal(uint8(3), s.proposalState(second))
+}
+
+// TestWithdrawnProposalID tests that a withdrawn proposal keeps its ID: the next proposal gets a
+// new one, and a new contract, and the withdrawn one stays cancelled.
+func (s *ManagerSuite) TestWithdrawnProposalID() {
+	withdrawn := s.proposeRebalance(s.erc20Addresses[1], s.erc20Addresses[0], 3333, shiftLeft(1, 18))
+	withdrawnAddress, err := s.manager.TrustedProposals(nil, withdrawn)
+	s.Require().NoError(err)
+	s.requireTx(s.manager.WithdrawProposal(signer(s.proposer), withdrawn, "resubmitting"))
+
+	length, err := s.manager.ProposalsLength(nil)
+	s.Require().NoError(err)
+	s.Equal(bigInt(0).Add(withdrawn, bigInt(1)).String(), length.String())
+
+	next := s.proposeRebalance(s.erc20Addresses[1], s.erc20Addresses[0], 3333, shiftLeft(1, 18))
+	s.Equal(bigInt(0).Add(withdrawn, bigInt(1)).String(), next.String())
+	nextAddress, err := s.manager.TrustedProposals(nil, next)
+	s.Require().NoError(err)
+	s.NotEqual(withdrawnAddress, nextAddress)
+
+	found, err := s.manager.TrustedProposals(nil, withdrawn)
+	s.Require().NoError(err)
+	s.Equal(withdrawnAddress, found)
+	s.Equal(uint8(2), s.proposalState(withdrawn))
+	s.Equal(uint8(0), s.proposalState(next))
+
+	// Accepting and executing the new proposal leaves the withdrawn one alone.
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), next))
+	s.Require().NoError(s.node.(backend).AdjustTime(25 * time.Hour))
+	s.requireTxFails(s.manager.ExecuteProposal(signer(s.operator), withdrawn))
+	s.executeProposal(next)
+	s.Equal(uint8(2), s.proposalState(withdrawn))
+
+	// Clearing the proposals doesn't free their IDs: the next proposal gets a new one, and the
+	// old proposals stay where they were, out of reach.
+	s.requireTx(s.manager.ClearProposals(signer(s.operator)))
+	cleared := s.proposeRebalance(s.erc20Addresses[0], s.erc20Addresses[1], 3333, shiftLeft(1, 18))
+	s.Equal(bigInt(0).Add(next, bigInt(1)).String(), cleared.String())
+	s.NotEqual(withdrawn.String(), cleared.String())
+	clearedAddress, err := s.manager.TrustedProposals(nil, cleared)
+	s.Require().NoError(err)
+	s.NotEqual(withdrawnAddress, clearedAddress)
+	found, err = s.manager.TrustedProposals(nil, withdrawn)
+	s.Require().NoError(err)
+	s.Equal(withdrawnAddress, found)
+	s.requireTxFails(s.manager.CancelProposal(signer(s.proposer), withdrawn))
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), cleared))
+}
+
+// proposalDeadline returns the deadline of proposal `id`.
+func (s *ManagerSuite) proposalDeadline(id *big.Int) *big.Int {
+	deadline, err := s.manager.ProposalDeadlines(nil, id)
+	s.Require().NoError(err)
+	return deadline
+}
+
+// TestProposalDeadline tests that a proposal's deadline is its validity after it was made, and
+// that changing the validity changes the deadlines of new proposals only.
+func (s *ManagerSuite) TestProposalDeadline() {
+	first := s.proposeRebalance(s.erc20Addresses[1], s.erc20Addresses[0], 3333, shiftLeft(1, 18))
+	week := bigInt(7 * 86400)
+	s.Equal(bigInt(0).Add(s.currentTimestamp(), week).String(), s.proposalDeadline(first).String())
+
+	twoDays := bigInt(2 * 86400)
+	s.requireTx(s.manager.SetProposalValidity(s.signer, twoDays))
+	second := s.proposeRebalance(s.erc20Addresses[1], s.erc20Addresses[0], 3333, shiftLeft(1, 18))
+	s.Equal(bigInt(0).Add(s.currentTimestamp(), twoDays).String(), s.proposalDeadline(second).String())
+	s.True(s.proposalDeadline(first).Cmp(s.proposalDeadline(second)) > 0)
+}
+
+// TestAcceptDeadline tests that a proposal can be accepted until the last second that leaves
+// time to execute it after the delay and by its deadline, and that past its deadline, accepting
+// it expires it instead.
+func (s *ManagerSuite) TestAcceptDeadline() {
+	early := s.proposeRebalance(s.erc20Addresses[1], s.erc20Addresses[0], 3333, shiftLeft(1, 18))
+	onTime := s.proposeRebalance(s.erc20Addresses[1], s.erc20Addresses[0], 3333, shiftLeft(1, 18))
+	deadline := s.proposalDeadline(onTime)
+	s.True(s.proposalDeadline(early).Cmp(deadline) < 0)
+	delay, err := s.manager.Delay(nil)
+	s.Require().NoError(err)
+
+	// A second before the delay would run to the deadline, the proposal can still be accepted,
+	// and then executed at its deadline. The other proposal's deadline came a block earlier, so
+	// it is too late to accept, but not yet expired.
+	lastChance := bigInt(0).Sub(deadline, delay)
+	lastChance.Sub(lastChance, bigInt(1))
+	s.nextBlockAt(lastChance)
+	s.requireTxFails(s.manager.AcceptProposal(signer(s.operator), early))
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), onTime))
+	s.Equal(lastChance.String(), s.currentTimestamp().String())
+	s.Equal(uint8(0), s.proposalState(early))
+	s.nextBlockAt(deadline)
+	s.executeProposal(onTime)
+	s.Equal(uint8(3), s.proposalState(onTime))
+
+	// Past its deadline, accepting the other proposal expires it.
+	s.requireTxWithStrictEvents(s.manager.AcceptProposal(signer(s.operator), early))(
+		abi.RebalanceProposalProposalCancelled{Proposer: s.proposer.address()},
+		abi.ManagerProposalExpired{Id: early, Proposer: s.proposer.address()},
+	)
+	s.Equal(uint8(2), s.proposalState(early))
+
+	// An expired proposal isn't pending, so it can't be expired again.
+	s.requireTxFails(s.manager.AcceptProposal(signer(s.operator), early))
+	s.requireTxFails(s.manager.ExpireProposal(signer(s.account[7]), early))
+}
+
+// TestExecuteDeadline tests that an accepted proposal can be executed until its deadline, at the
+// very block, and that one second after, executing it expires it instead, as anyone can.
+func (s *ManagerSuite) TestExecuteDeadline() {
+	onTime := s.proposeRebalance(s.erc20Addresses[1], s.erc20Addresses[0], 3333, shiftLeft(1, 18))
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), onTime))
+	deadline := s.proposalDeadline(onTime)
+
+	// Until the deadline, the proposal can't be expired, and at the deadline it can still be
+	// executed.
+	s.requireTxFails(s.manager.ExpireProposal(signer(s.account[7]), onTime))
+	s.Equal(uint8(1), s.proposalState(onTime))
+	s.nextBlockAt(deadline)
+	s.executeProposal(onTime)
+	s.Equal(deadline.String(), s.currentTimestamp().String())
+	s.Equal(uint8(3), s.proposalState(onTime))
+
+	// A completed proposal can't be expired, even after its deadline.
+	s.requireTxFails(s.manager.ExpireProposal(signer(s.account[7]), onTime))
+
+	late := s.proposeRebalance(s.erc20Addresses[1], s.erc20Addresses[0], 3333, shiftLeft(1, 18))
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), late))
+	deadline = s.proposalDeadline(late)
+
+	// One second after the deadline, executing the proposal expires it, moving no tokens.
+	s.nextBlockAt(bigInt(0).Add(deadline, bigInt(1)))
+	vault := s.tokenBalances(s.vaultAddress)[0]
+	s.requireTxWithStrictEvents(s.manager.ExecuteProposal(signer(s.operator), late))(
+		abi.RebalanceProposalProposalCancelled{Proposer: s.proposer.address()},
+		abi.ManagerProposalExpired{Id: late, Proposer: s.proposer.address()},
+	)
+	s.Equal(uint8(2), s.proposalState(late))
+	s.Equal(fmt.Sprint(vault), fmt.Sprint(s.tokenBalances(s.vaultAddress)[0]))
+
+	// Anyone can expire a proposal that is still pending past its deadline.
+	expired := s.proposeRebalance(s.erc20Addresses[1], s.erc20Addresses[0], 3333, shiftLeft(1, 18))
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), expired))
+	s.nextBlockAt(bigInt(0).Add(s.proposalDeadline(expired), bigInt(1)))
+	s.requireTxWithStrictEvents(s.manager.ExpireProposal(signer(s.account[7]), expired))(
+		abi.RebalanceProposalProposalCancelled{Proposer: s.proposer.address()},
+		abi.ManagerProposalExpired{Id: expired, Proposer: s.proposer.address()},
+	)
+	s.Equal(uint8(2), s.proposalState(expired))
+
+	// Expiring is for good.
+	s.requireTxFails(s.manager.ExpireProposal(signer(s.account[7]), late))
+	s.requireTxFails(s.manager.AcceptProposal(signer(s.operator), late))
+	s.requireTxFails(s.manager.ExecuteProposal(signer(s.operator), late))
+	s.requireTxFails(s.manager.ExecuteProposal(signer(s.operator), expired))
+	s.requireTxFails(s.manager.ExpireProposal(signer(s.account[7]), bigInt(0).Add(expired, bigInt(1))))
+	s.assertManagerCollateralized()
+}
+
+// proposeRebalance makes a RebalanceProposal from the proposer, and returns its id.
+func (s *ManagerSuite) proposeRebalance(from, to common.Address, portion uint32, rate *big.Int) *big.Int {
+	id, err := s.manager.ProposalsLength(nil)
+	s.Require().NoError(err)
+	s.requireTx(s.manager.ProposeRebalance(signer(s.proposer), from, to, bigInt(portion), rate))(
+		abi.ManagerRebalanceProposed{
+			Id: id, Proposer: s.proposer.address(), FromToken: from, ToToken: to, Portion: bigInt(portion), Rate: rate,
+		},
+	)
+
+	proposalAddress, err := s.manager.TrustedProposals(nil, id)
+	s.Require().NoError(err)
+	proposal, err := abi.NewRebalanceProposal(proposalAddress, s.node)
+	s.Require().NoError(err)
+	s.logParsers[proposalAddress] = proposal
+	return id
+}
+
+// executeProposal executes the accepted proposal `id`, whose delay has passed, and makes the
+// new basket s.basket.
+func (s *ManagerSuite) executeProposal(id *big.Int) {
+	oldBasketAddress := s.basketAddress
+	events := s.requireTx(s.manager.ExecuteProposal(signer(s.operator), id))
+
+	basketAddress, err := s.manager.TrustedBasket(nil)
+	s.Require().NoError(err)
+	basket, err := abi.NewBasket(basketAddress, s.node)
+	s.Require().NoError(err)
+	s.basketAddress, s.basket = basketAddress, basket
+	s.logParsers[basketAddress] = basket
+
+	events(abi.ManagerProposalExecuted{
+		Id: id, Proposer: s.proposer.address(), Executor: s.operator.address(),
+		OldBasket: oldBasketAddress, NewBasket: basketAddress,
+	})
+	s.assertManagerCollateralized()
+}
+
+// rebalancedWeights returns the weights that a RebalanceProposal of `portion` of `from` for
+// `to` at `rate` gives the two tokens, from the current basket: what leaves rounds down, and
+// what enters rounds up.
+func (s *ManagerSuite) rebalancedWeights(from, to common.Address, portion uint32, rate *big.Int) (*big.Int, *big.Int) {
+	fromWeight, err := s.basket.Weights(nil, from)
+	s.Require().NoError(err)
+	toWeight, err := s.basket.Weights(nil, to)
+	s.Require().NoError(err)
+
+	moved := bigInt(0).Div(bigInt(0).Mul(fromWeight, bigInt(portion)), bigInt(10000))
+	added := bigInt(0).Mul(moved, rate)
+	added.Add(added, bigInt(0).Sub(shiftLeft(1, 18), bigInt(1)))
+	added.Div(added, shiftLeft(1, 18))
+	return bigInt(0).Sub(fromWeight, moved), bigInt(0).Add(toWeight, added)
+}
+
+// TestProposeRebalance tests that a RebalanceProposal exchanges a portion of one token for
+// another, rounding in the Vault's favor, and leaves the other weights alone.
+func (s *ManagerSuite) TestProposeRebalance() {
+	rsvAmount := bigInt(0).Add(shiftLeft(1, 27), bigInt(1))
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+
+	// A third of the 0.3 token1 of each RSV, for a third as much token0: neither divides evenly.
+	from, to := s.erc20Addresses[1], s.erc20Addresses[0]
+	portion, rate := uint32(3333), bigInt(0).Div(shiftLeft(1, 18), bigInt(3))
+	fromWeight, toWeight := s.rebalancedWeights(from, to, portion, rate)
+	oldFromWeight, err := s.basket.Weights(nil, from)
+	s.Require().NoError(err)
+	oldToWeight, err := s.basket.Weights(nil, to)
+	s.Require().NoError(err)
+
+	id := s.proposeRebalance(from, to, portion, rate)
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), id))(
+		abi.ManagerProposalAccepted{Id: id, Proposer: s.proposer.address()},
+	)
+	s.requireTxFails(s.manager.ExecuteProposal(signer(s.operator), id))
+	s.Require().NoError(s.node.(backend).AdjustTime(24 * time.Hour))
+
+	before := s.tokenBalances(s.proposer.address())[0]
+	s.executeProposal(id)
+	after := s.tokenBalances(s.proposer.address())[0]
+
+	// The new basket lists the two tokens first, and keeps token2 as it was.
+	s.assertBasket(
+		s.basket,
+		[]common.Address{from, to, s.erc20Addresses[2]},
+		[]*big.Int{fromWeight, toWeight, s.weights[2]},
+	)
+
+	// The proposer got token1, rounded down, and paid token0, rounded up.
+	scale := shiftLeft(1, 36)
+	out := bigInt(0).Div(bigInt(0).Mul(rsvAmount, bigInt(0).Sub(oldFromWeight, fromWeight)), scale)
+	in := bigInt(0).Mul(rsvAmount, bigInt(0).Sub(toWeight, oldToWeight))
+	in.Add(in, bigInt(0).Sub(scale, bigInt(1)))
+	in.Div(in, scale)
+	s.Equal(out.String(), bigInt(0).Sub(after[1], before[1]).String())
+	s.Equal(in.String(), bigInt(0).Sub(before[0], after[0]).String())
+	s.Equal(before[2].String(), after[2].String())
+
+	// Everything can still be redeemed.
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, rsvAmount))
+	s.requireTx(s.manager.Redeem(signer(s.proposer), rsvAmount))
+	s.assertRSVTotalSupply(bigInt(0))
+	s.assertManagerCollateralized()
+}
+
+// TestProposeRebalanceToNewToken tests that a RebalanceProposal can exchange all of a token for
+// one that isn't yet in the basket.
+func (s *ManagerSuite) TestProposeRebalanceToNewToken() {
+	rsvAmount := shiftLeft(1, 27)
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+
+	newTokenAddress, tx, newToken, err := abi.DeployBasicERC20(s.signer, s.node)
+	s.requireTx(tx, err)
+	s.logParsers[newTokenAddress] = newToken
+	s.requireTx(newToken.Transfer(s.signer, s.proposer.address(), shiftLeft(1, 46)))
+	s.requireTx(newToken.Approve(signer(s.proposer), s.managerAddress, shiftLeft(1, 46)))
+
+	// All of token2, for twice as much of the new token.
+	id := s.proposeRebalance(s.erc20Addresses[2], newTokenAddress, 10000, shiftLeft(2, 18))
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), id))
+	s.Require().NoError(s.node.(backend).AdjustTime(24 * time.Hour))
+	s.executeProposal(id)
+
+	// token2 is left in the basket, with a weight of zero.
+	s.assertBasket(
+		s.basket,
+		[]common.Address{s.erc20Addresses[2], newTokenAddress, s.erc20Addresses[0], s.erc20Addresses[1]},
+		[]*big.Int{bigInt(0), shiftLeft(12, 35), s.weights[0], s.weights[1]},
+	)
+	balance, err := s.erc20s[2].BalanceOf(nil, s.vaultAddress)
+	s.Require().NoError(err)
+	s.Equal("0", balance.String())
+	balance, err = newToken.BalanceOf(nil, s.vaultAddress)
+	s.Require().NoError(err)
+	s.Equal(shiftLeft(12, 26).String(), balance.String())
+
+	// Issuance and redemption go on with the new token.
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+	total := bigInt(0).Mul(rsvAmount, bigInt(2))
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, total))
+	s.requireTx(s.manager.Redeem(signer(s.proposer), total))
+	s.assertRSVTotalSupply(bigInt(0))
+	s.assertManagerCollateralized()
+}
+
+// TestProposeRebalanceKeepsOtherChanges tests that a RebalanceProposal is computed from the
+// basket when it is executed, so that it doesn't undo a proposal executed in the meantime.
+func (s *ManagerSuite) TestProposeRebalanceKeepsOtherChanges() {
+	s.requireTx(s.manager.Issue(signer(s.proposer), shiftLeft(1, 27)))
+
+	// Half of token0, for as much token1.
+	id := s.proposeRebalance(s.erc20Addresses[0], s.erc20Addresses[1], 5000, shiftLeft(1, 18))
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), id))
+
+	// Another proposal changes every weight first.
+	s.changeBasketUsingWeightProposal(
+		s.erc20Addresses, []*big.Int{shiftLeft(2, 35), shiftLeft(3, 35), shiftLeft(5, 35)},
+	)
+	s.executeProposal(id)
+	s.assertBasket(
+		s.basket,
+		s.erc20Addresses,
+		[]*big.Int{shiftLeft(1, 35), shiftLeft(4, 35), shiftLeft(5, 35)},
+	)
+}
+
+// TestProposeRebalanceRequires tests the requirements of a RebalanceProposal.
+func (s *ManagerSuite) TestProposeRebalanceRequires() {
+	token0, token1 := s.erc20Addresses[0], s.erc20Addresses[1]
+	rate := shiftLeft(1, 18)
+	s.requireTxFails(s.manager.ProposeRebalance(signer(s.proposer), token0, token0, bigInt(5000), rate))
+	s.requireTxFails(s.manager.ProposeRebalance(signer(s.proposer), token0, token1, bigInt(0), rate))
+	s.requireTxFails(s.manager.ProposeRebalance(signer(s.proposer), token0, token1, bigInt(10001), rate))
+
+	// Not in an emergency.
+	s.requireTx(s.manager.SetEmergency(signer(s.operator), true))
+	s.requireTxFails(s.manager.ProposeRebalance(signer(s.proposer), token0, token1, bigInt(5000), rate))
+	s.requireTx(s.manager.SetEmergency(signer(s.operator), false))
+
+	// A token that isn't in the basket can be proposed, but the proposal can't be executed.
+	notInBasket := s.account[2].address()
+	id := s.proposeRebalance(notInBasket, token1, 5000, rate)
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), id))
+	s.Require().NoError(s.node.(backend).AdjustTime(24 * time.Hour))
+	s.requireTxFails(s.manager.ExecuteProposal(signer(s.operator), id))
+}
+
+// useRegistry deploys a CollateralRegistry approving the basket's tokens, without caps, and
+// sets it on the Manager.
+func (s *ManagerSuite) useRegistry() (common.Address, *abi.CollateralRegistry) {
+	registryAddress, registry := s.deployCollateralRegistry()
+	for _, token := range s.erc20Addresses {
+		s.approveCollateral(registry, token, 18, zeroAddress(), bigInt(0))
+	}
+	s.requireTxWithStrictEvents(s.manager.SetRegistry(s.signer, registryAddress))(
+		abi.ManagerRegistryChanged{OldRegistry: zeroAddress(), NewRegistry: registryAddress},
+	)
+	return registryAddress, registry
+}
+
+// TestSetRegistry tests that the owner can set and unset the collateral registry.
+func (s *ManagerSuite) TestSetRegistry() {
+	registryAddress, _ := s.useRegistry()
+	found, err := s.manager.TrustedRegistry(nil)
+	s.Require().NoError(err)
+	s.Equal(registryAddress, found)
+
+	s.requireTxWithStrictEvents(s.manager.SetRegistry(s.signer, zeroAddress()))(
+		abi.ManagerRegistryChanged{OldRegistry: registryAddress, NewRegistry: zeroAddress()},
+	)
+	found, err = s.manager.TrustedRegistry(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), found)
+}
+
+// TestSetRegistryIsProtected tests that `setRegistry` can only be called by owner.
+func (s *ManagerSuite) TestSetRegistryIsProtected() {
+	registryAddress, _ := s.deployCollateralRegistry()
+	s.requireTxFails(s.manager.SetRegistry(signer(s.account[2]), registryAddress))
+	s.requireTxFails(s.manager.SetRegistry(signer(s.operator), registryAddress))
+}
+
+// TestRegistryRejectsUnapprovedTokens tests that, once the registry is set, no proposal can
+// bring a token it doesn't approve into the basket.
+func (s *ManagerSuite) TestRegistryRejectsUnapprovedTokens() {
+	strayAddress, _ := s.deployStrayToken()
+	tokens := append([]common.Address{strayAddress}, s.erc20Addresses...)
+	weights := append([]*big.Int{shiftLeft(1, 35)}, s.weights...)
+	rate := shiftLeft(1, 18)
+
+	// Without a registry, any token can be proposed.
+	s.requireTx(s.manager.ProposeWeights(signer(s.proposer), tokens, weights))
+
+	_, registry := s.useRegistry()
+	s.requireTxFails(s.manager.ProposeWeights(signer(s.proposer), tokens, weights))
+	s.requireTxFails(s.manager.ProposeRebalance(signer(s.proposer), s.erc20Addresses[2], strayAddress, bigInt(5000), rate))
+	s.requireTxFails(s.manager.ProposeSwap(
+		signer(s.proposer), []common.Address{strayAddress}, []*big.Int{bigInt(1)}, []bool{true},
+	))
+
+	// Proposals of approved tokens go on.
+	s.requireTx(s.manager.ProposeWeights(signer(s.proposer), s.erc20Addresses, s.weights))
+	s.proposeRebalance(s.erc20Addresses[2], s.erc20Addresses[0], 5000, rate)
+
+	// Once approved, the token can be proposed.
+	s.approveCollateral(registry, strayAddress, 18, zeroAddress(), bigInt(0))
+	s.requireTx(s.manager.ProposeWeights(signer(s.proposer), tokens, weights))
+	s.proposeRebalance(s.erc20Addresses[2], strayAddress, 5000, rate)
+}
+
+// TestRegistryCaps tests that no proposal can give a token more weight than its cap.
+func (s *ManagerSuite) TestRegistryCaps() {
+	s.requireTx(s.manager.Issue(signer(s.proposer), shiftLeft(1, 27)))
+	_, registry := s.useRegistry()
+	token0 := s.erc20Addresses[0]
+	s.approveCollateral(registry, token0, 18, zeroAddress(), shiftLeft(2, 35))
+
+	// A weight proposal above the cap is refused; one at the cap is fine.
+	above := []*big.Int{shiftLeft(3, 35), shiftLeft(3, 35), shiftLeft(4, 35)}
+	s.requireTxFails(s.manager.ProposeWeights(signer(s.proposer), s.erc20Addresses, above))
+	s.requireTx(s.manager.ProposeWeights(
+		signer(s.proposer), s.erc20Addresses, []*big.Int{shiftLeft(2, 35), shiftLeft(3, 35), shiftLeft(5, 35)},
+	))
+
+	// A rebalance's weights are only known once it's executed, so it's checked then: half of
+	// token2, for as much token0, would give token0 a weight of 4e35.
+	id := s.proposeRebalance(s.erc20Addresses[2], token0, 5000, shiftLeft(1, 18))
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), id))
+	s.Require().NoError(s.node.(backend).AdjustTime(24 * time.Hour))
+	s.requireTxFails(s.manager.ExecuteProposal(signer(s.operator), id))
+
+	// Raising the cap lets it through.
+	s.approveCollateral(registry, token0, 18, zeroAddress(), shiftLeft(4, 35))
+	s.executeProposal(id)
+	s.assertBasket(
+		s.basket,
+		s.erc20Addresses,
+		[]*big.Int{shiftLeft(4, 35), shiftLeft(3, 35), shiftLeft(3, 35)},
+	)
+}
+
+// TestRegistryRemovedToken tests that, once a basket token is removed from the registry, only
+// proposals that take it out of the basket can be executed.
+func (s *ManagerSuite) TestRegistryRemovedToken() {
+	_, registry := s.useRegistry()
+	token2 := s.erc20Addresses[2]
+
+	// A proposal that keeps token2, accepted before its removal.
+	s.requireTx(s.manager.ProposeWeights(signer(s.proposer), s.erc20Addresses, s.weights))
+	proposalsLength, err := s.manager.ProposalsLength(nil)
+	s.Require().NoError(err)
+	keeping := bigInt(0).Sub(proposalsLength, bigInt(1))
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), keeping))
+
+	s.requireTxWithStrictEvents(registry.Remove(s.signer, token2))(
+		abi.CollateralRegistryCollateralRemoved{Token: token2},
+	)
+	s.Require().NoError(s.node.(backend).AdjustTime(24 * time.Hour))
+	s.requireTxFails(s.manager.ExecuteProposal(signer(s.operator), keeping))
+	s.requireTxFails(s.manager.ProposeWeights(signer(s.proposer), s.erc20Addresses, s.weights))
+
+	// A proposal that takes token2 out goes through.
+	s.changeBasketUsingWeightProposal(
+		s.erc20Addresses[:2], []*big.Int{shiftLeft(4, 35), shiftLeft(6, 35)},
+	)
+}
+
+// TestSetExposureCap tests that the owner can cap a token's exposure, up to 100%.
+func (s *ManagerSuite) TestSetExposureCap() {
+	token := s.erc20Addresses[2]
+	s.requireTxWithStrictEvents(s.manager.SetExposureCap(s.signer, token, bigInt(5000)))(
+		abi.ManagerExposureCapChanged{Token: token, OldVal: bigInt(0), NewVal: bigInt(5000)},
+	)
+	found, err := s.manager.ExposureCaps(nil, token)
+	s.Require().NoError(err)
+	s.Equal("5000", found.String())
+
+	s.requireTxWithStrictEvents(s.manager.SetExposureCap(s.signer, token, bigInt(10000)))(
+		abi.ManagerExposureCapChanged{Token: token, OldVal: bigInt(5000), NewVal: bigInt(10000)},
+	)
+	s.requireTxFails(s.manager.SetExposureCap(s.signer, token, bigInt(10001)))
+}
+
+// TestSetExposureCapIsProtected tests that `setExposureCap` can only be called by owner.
+func (s *ManagerSuite) TestSetExposureCapIsProtected() {
+	token := s.erc20Addresses[2]
+	s.requireTxFails(s.manager.SetExposureCap(signer(s.account[2]), token, bigInt(5000)))
+	s.requireTxFails(s.manager.SetExposureCap(signer(s.operator), token, bigInt(5000)))
+}
+
+// setDollarOracle sets an oracle pricing each basket token, of `tokenDecimals` decimals, at a
+// dollar, and returns the tokens' aggregators.
+func (s *ManagerSuite) setDollarOracle(tokenDecimals []uint8) []*abi.MockAggregator {
+	oracleAddress, oracle := s.deployCollateralOracle(300)
+	aggregators := make([]*abi.MockAggregator, len(s.erc20Addresses))
+	for i, token := range s.erc20Addresses {
+		var address common.Address
+		address, aggregators[i] = s.deployMockAggregator(8, shiftLeft(1, 8))
+		s.setFeed(oracle, token, address, time.Hour, tokenDecimals[i])
+	}
+	s.requireTx(s.manager.SetOracle(s.signer, oracleAddress))
+	return aggregators
+}
+
+// TestExposureCapOnIssuance tests that issuance must leave each capped token within its cap of
+// the Vault's value.
+func (s *ManagerSuite) TestExposureCapOnIssuance() {
+	token2 := s.erc20Addresses[2]
+	rsvAmount := shiftLeft(1000, 18)
+
+	// A capped token needs the oracle.
+	s.requireTx(s.manager.SetExposureCap(s.signer, token2, bigInt(6000)))
+	s.requireTxFails(s.manager.Issue(signer(s.proposer), rsvAmount))
+	aggregators := s.setDollarOracle([]uint8{18, 18, 18})
+
+	// At a dollar each, token2 is exactly 60% of the Vault.
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+	s.requireTx(s.manager.SetExposureCap(s.signer, token2, bigInt(5999)))
+	s.requireTxFails(s.manager.Issue(signer(s.proposer), rsvAmount))
+
+	// At $1.01, token2 is 606/1006 of the Vault, a little over 6023 BPS.
+	s.requireTx(aggregators[2].SetAnswer(s.signer, bigInt(101000000), s.currentTimestamp()))
+	s.requireTx(s.manager.SetExposureCap(s.signer, token2, bigInt(6023)))
+	s.requireTxFails(s.manager.Issue(signer(s.proposer), rsvAmount))
+	s.requireTx(s.manager.SetExposureCap(s.signer, token2, bigInt(6024)))
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+
+	// Redemption goes on regardless.
+	s.requireTx(s.manager.SetExposureCap(s.signer, token2, bigInt(5000)))
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, rsvAmount))
+	s.requireTx(s.manager.Redeem(signer(s.proposer), rsvAmount))
+
+	// Once uncapped, issuance resumes.
+	s.requireTx(s.manager.SetExposureCap(s.signer, token2, bigInt(0)))
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+	s.assertManagerCollateralized()
+}
+
+// TestExposureCapDecimals tests that exposure is the share of value, whatever the tokens'
+// decimals: each basket backs a tenth of an RSV with 2, 3, and 5 whole tokens, so that token2
+// is 50% of it, exactly.
+func (s *ManagerSuite) TestExposureCapDecimals() {
+	matrix := [][]uint8{
+		{18, 18, 18}, {2, 6, 18}, {6, 18, 6}, {18, 6, 2}, {8, 27, 12}, {36, 6, 36},
+	}
+	token2 := s.erc20Addresses[2]
+	for _, decimals := range matrix {
+		msg := fmt.Sprintf("decimals %v", decimals)
+		s.setDollarOracle(decimals)
+		weights := make([]*big.Int, len(decimals))
+		for i, share := range []uint32{2, 3, 5} {
+			weights[i] = shiftLeft(share, uint32(decimals[i])+17)
+		}
+
+		// Just over the cap, the proposal can't be accepted.
+		s.requireTx(s.manager.SetExposureCap(s.signer, token2, bigInt(4999)))
+		s.requireTx(s.manager.ProposeWeights(signer(s.proposer), s.erc20Addresses, weights))
+		proposalsLength, err := s.manager.ProposalsLength(nil)
+		s.Require().NoError(err, msg)
+		id := bigInt(0).Sub(proposalsLength, bigInt(1))
+		s.requireTxFails(s.manager.AcceptProposal(signer(s.operator), id))
+
+		// At the cap, it passes.
+		s.requireTx(s.manager.SetExposureCap(s.signer, token2, bigInt(5000)))
+		s.changeBasketUsingWeightProposal(s.erc20Addresses, weights)
+	}
+}
+
+// TestExposureCapOnExecution tests that a rebalance, whose basket is only known once executed,
+// can't be executed if it would take a token over its cap.
+func (s *ManagerSuite) TestExposureCapOnExecution() {
+	s.requireTx(s.manager.Issue(signer(s.proposer), shiftLeft(1, 27)))
+	s.setDollarOracle([]uint8{18, 18, 18})
+	token0 := s.erc20Addresses[0]
+
+	// Half of token2, for as much token0, makes token0 40% of the basket.
+	s.requireTx(s.manager.SetExposureCap(s.signer, token0, bigInt(3999)))
+	id := s.proposeRebalance(s.erc20Addresses[2], token0, 5000, shiftLeft(1, 18))
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), id))
+	s.Require().NoError(s.node.(backend).AdjustTime(24 * time.Hour))
+	s.requireTxFails(s.manager.ExecuteProposal(signer(s.operator), id))
+
+	s.requireTx(s.manager.SetExposureCap(s.signer, token0, bigInt(4000)))
+	s.executeProposal(id)
+	s.assertBasket(
+		s.basket,
+		s.erc20Addresses,
+		[]*big.Int{shiftLeft(4, 35), shiftLeft(3, 35), shiftLeft(3, 35)},
+	)
+}
+
 // TestUpgrade tests that we can upgrade to a new Manager smoothly.
 func (s *ManagerSuite) TestUpgrade() {
 	// Pause the old Manager.
@@ -722,7 +3333,7 @@ func (s *ManagerSuite) TestUpgrade() {
 	)
 
 	// Record the operator.
-	operator, err := s.manager.Operator(nil)
+	operator, err := s.manager.Operators(nil, bigInt(0))
 	s.Require().NoError(err)
 
 	// Record the old seigniorage.
@@ -748,6 +3359,7 @@ func (s *ManagerSuite) TestUpgrade() {
 			PreviousOwner: zeroAddress(),
 			NewOwner:      s.owner.address(),
 		},
+		abi.ManagerV2OperatorAdded{Account: operator},
 	)
 
 	// Update the Vault.
@@ -776,13 +3388,10 @@ func (s *ManagerSuite) TestUpgrade() {
 	s.Require().NoError(err)
 	s.Equal(false, emergency)
 
-	// Set the operator to the old Manager as the zero address to wrap things up.
+	// Remove the old Manager's operator to wrap things up.
 	// Note: The owner of old Manager remains valid, just in case.
-	s.requireTxWithStrictEvents(s.manager.SetOperator(s.signer, zeroAddress()))(
-		abi.ManagerOperatorChanged{
-			OldAccount: s.operator.address(),
-			NewAccount: zeroAddress(),
-		},
+	s.requireTxWithStrictEvents(s.manager.RemoveOperator(s.signer, s.operator.address()))(
+		abi.ManagerOperatorRemoved{Account: s.operator.address()},
 	)
 
 	// Confirm we have upgraded.
@@ -799,3 +3408,147 @@ func (s *ManagerSuite) TestUpgrade() {
 	s.requireTx(s.reserve.Approve(signer(s.proposer), v2Address, amount))
 	s.requireTx(v2.Redeem(signer(s.proposer), amount))
 }
+
+// useYieldVault swaps the empty Vault for a YieldVault, with a MockYieldSource of the first
+// basket token approved, and sets seigniorage so that issuance leaves a surplus to deposit.
+func (s *ManagerSuite) useYieldVault() (*abi.YieldVault, common.Address, *abi.MockYieldSource) {
+	vaultAddress, tx, vault, err := abi.DeployYieldVault(s.signer, s.node)
+	s.logParsers[vaultAddress] = vault
+	s.requireTx(tx, err)
+	s.requireTx(vault.ChangeManager(s.signer, s.managerAddress))
+	s.requireTx(s.manager.SetVault(s.signer, vaultAddress))
+	s.vaultAddress = vaultAddress
+	s.vault, err = abi.NewVault(vaultAddress, s.node)
+	s.Require().NoError(err)
+
+	sourceAddress, tx, source, err := abi.DeployMockYieldSource(s.signer, s.node, s.erc20Addresses[0])
+	s.logParsers[sourceAddress] = source
+	s.requireTx(tx, err)
+	s.requireTxWithStrictEvents(vault.SetYieldSource(s.signer, sourceAddress, true))(
+		abi.YieldVaultYieldSourceChanged{Source: sourceAddress, Approved: true},
+	)
+
+	s.requireTx(s.manager.SetSeigniorage(s.signer, bigInt(1000)))
+	return vault, sourceAddress, source
+}
+
+// surplus returns how much of the first basket token the Vault holds beyond what the supply
+// needs.
+func (s *ManagerSuite) surplus() *big.Int {
+	supply, err := s.reserve.TotalSupply(nil)
+	s.Require().NoError(err)
+	weight, err := s.basket.Weights(nil, s.erc20Addresses[0])
+	s.Require().NoError(err)
+	needed := weightedAmounts(supply, []*big.Int{weight}, true)[0]
+	return bigInt(0).Sub(s.tokenBalances(s.vaultAddress)[0][0], needed)
+}
+
+// TestYieldVaultDeposit tests that the YieldVault deposits its surplus into a yield source,
+// and no more, and that the Manager pays redemptions from what stays behind.
+func (s *ManagerSuite) TestYieldVaultDeposit() {
+	vault, sourceAddress, _ := s.useYieldVault()
+	rsvAmount := shiftLeft(1, 27)
+	s.requireTx(s.manager.Issue(signer(s.proposer), rsvAmount))
+	surplus := s.surplus()
+	s.True(surplus.Sign() > 0)
+
+	// Not a qToken more than the surplus.
+	s.requireTxFails(vault.Deposit(s.signer, sourceAddress, bigInt(0).Add(surplus, bigInt(1))))
+	s.requireTxWithStrictEvents(vault.Deposit(s.signer, sourceAddress, surplus))(
+		abi.BasicERC20Approval{Owner: s.vaultAddress, Spender: sourceAddress, Value: surplus},
+		abi.BasicERC20Transfer{From: s.vaultAddress, To: sourceAddress, Value: surplus},
+		abi.BasicERC20Approval{Owner: s.vaultAddress, Spender: sourceAddress, Value: bigInt(0)},
+		abi.MockYieldSourceTransfer{From: zeroAddress(), To: s.vaultAddress, Value: surplus},
+		abi.YieldVaultYieldDeposited{Source: sourceAddress, Assets: surplus, Shares: surplus},
+	)
+	s.assertManagerCollateralized()
+	s.Equal("0", s.surplus().String())
+
+	held, err := vault.SharesHeld(nil, sourceAddress)
+	s.Require().NoError(err)
+	s.Equal(surplus.String(), held.String())
+	assets, err := vault.AssetsOf(nil, sourceAddress)
+	s.Require().NoError(err)
+	s.Equal(surplus.String(), assets.String())
+
+	// Redemption still works, all the way down.
+	s.requireTx(s.reserve.Approve(signer(s.proposer), s.managerAddress, rsvAmount))
+	s.requireTx(s.manager.Redeem(signer(s.proposer), rsvAmount))
+	s.assertRSVTotalSupply(bigInt(0))
+	s.assertManagerCollateralized()
+}
+
+// TestYieldVaultWithdraw tests that withdrawing shares realizes their yield as backing, and that
+// a loss in the source only costs the surplus.
+func (s *ManagerSuite) TestYieldVaultWithdraw() {
+	vault, sourceAddress, source := s.useYieldVault()
+	s.requireTx(s.manager.Issue(signer(s.proposer), shiftLeft(1, 27)))
+	surplus := s.surplus()
+	s.requireTx(vault.Deposit(s.signer, sourceAddress, surplus))
+
+	// A tenth of yield.
+	yield := bigInt(0).Div(surplus, bigInt(10))
+	s.requireTx(s.erc20s[0].Transfer(signer(s.proposer), sourceAddress, yield))
+	assets, err := vault.AssetsOf(nil, sourceAddress)
+	s.Require().NoError(err)
+	s.Equal(bigInt(0).Add(surplus, yield).String(), assets.String())
+
+	// Withdraw half of the shares, for half of the assets.
+	half := bigInt(0).Div(surplus, bigInt(2))
+	halfAssets, err := source.ConvertToAssets(nil, half)
+	s.Require().NoError(err)
+	before := s.tokenBalances(s.vaultAddress)[0][0]
+	s.requireTxWithStrictEvents(vault.Withdraw(s.signer, sourceAddress, half))(
+		abi.MockYieldSourceTransfer{From: s.vaultAddress, To: zeroAddress(), Value: half},
+		abi.BasicERC20Transfer{From: sourceAddress, To: s.vaultAddress, Value: halfAssets},
+		abi.YieldVaultYieldWithdrawn{Source: sourceAddress, Assets: halfAssets, Shares: half},
+	)
+	after := s.tokenBalances(s.vaultAddress)[0][0]
+	s.Equal(bigInt(0).Add(before, halfAssets).String(), after.String())
+	s.Equal(halfAssets.String(), s.surplus().String())
+
+	// The source loses almost everything; the supply stays backed.
+	left := s.tokenBalances(sourceAddress)[0][0]
+	s.requireTx(source.Lose(s.signer, bigInt(0).Sub(left, bigInt(1))))
+	held, err := vault.SharesHeld(nil, sourceAddress)
+	s.Require().NoError(err)
+	s.requireTx(vault.Withdraw(s.signer, sourceAddress, held))
+	held, err = vault.SharesHeld(nil, sourceAddress)
+	s.Require().NoError(err)
+	s.Equal("0", held.String())
+	s.assertManagerCollateralized()
+
+	// Not more shares than it holds.
+	s.requireTxFails(vault.Withdraw(s.signer, sourceAddress, bigInt(1)))
+}
+
+// TestYieldVaultIsProtected tests that only the owner manages yield sources, and only into
+// approved ones.
+func (s *ManagerSuite) TestYieldVaultIsProtected() {
+	vault, sourceAddress, _ := s.useYieldVault()
+	s.requireTx(s.manager.Issue(signer(s.proposer), shiftLeft(1, 27)))
+	surplus := s.surplus()
+	third := bigInt(0).Div(surplus, bigInt(3))
+
+	for _, other := range []account{s.operator, s.proposer, s.account[3]} {
+		s.requireTxFails(vault.SetYieldSource(signer(other), s.account[4].address(), true))
+		s.requireTxFails(vault.Deposit(signer(other), sourceAddress, third))
+	}
+	s.requireTx(vault.Deposit(s.signer, sourceAddress, third))
+	for _, other := range []account{s.operator, s.proposer, s.account[3]} {
+		s.requireTxFails(vault.Withdraw(signer(other), sourceAddress, bigInt(1)))
+	}
+
+	// A source that isn't approved takes no deposits, but gives back what it holds.
+	otherAddress, tx, other, err := abi.DeployMockYieldSource(s.signer, s.node, s.erc20Addresses[0])
+	s.logParsers[otherAddress] = other
+	s.requireTx(tx, err)
+	s.requireTxFails(vault.Deposit(s.signer, otherAddress, third))
+
+	s.requireTxWithStrictEvents(vault.SetYieldSource(s.signer, sourceAddress, false))(
+		abi.YieldVaultYieldSourceChanged{Source: sourceAddress, Approved: false},
+	)
+	s.requireTxFails(vault.Deposit(s.signer, sourceAddress, third))
+	s.requireTx(vault.Withdraw(s.signer, sourceAddress, third))
+	s.Equal(surplus.String(), s.surplus().String())
+}
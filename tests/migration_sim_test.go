@@ -0,0 +1,203 @@
+// +build sim
+
+package tests
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/ops/sim"
+)
+
+func TestMigrationSim(t *testing.T) {
+	suite.Run(t, new(MigrationSimSuite))
+}
+
+// MigrationSimSuite plays randomized basket migrations, drawn by ops/sim, against the contracts
+// on a fresh simulated backend each, and reports how the backing ratio and slippage of them are
+// distributed. It is for choosing a migration's parameters before running it for real; see
+// `make simulate`.
+type MigrationSimSuite struct {
+	TestSuite
+	fromDecimals, toDecimals uint32
+}
+
+var (
+	simRuns       = flag.Int("sim-runs", 100, "migrations to simulate")
+	simSeed       = flag.Int64("sim-seed", 1, "the seed of the first migration, each next one's one more")
+	simTranches   = flag.Int("sim-tranches", 4, "proposals to split each migration into")
+	simVolatility = flag.Float64("sim-volatility", 0.01, "standard deviation of the log of the price's move over a proposal's delay")
+	simMarkup     = flag.Float64("sim-markup", 0.003, "the most that a proposer quotes under the market price, as a fraction")
+	simWalkAway   = flag.Float64("sim-walkaway", 0.05, "the chance that a proposer walks away from a proposal")
+	simDecimals   = flag.String("sim-decimals", "6,18", "decimals of the token migrated from, and of the one migrated to")
+	simReport     = flag.String("sim-report", "", "write the report to this file, as JSON")
+)
+
+// simSupply is the RSV in circulation through each migration: a million.
+var simSupply = shiftLeft(1, 24)
+
+// SetupSuite runs once, before all of the tests in the suite.
+func (s *MigrationSimSuite) SetupSuite() {
+	decimals := strings.Split(*simDecimals, ",")
+	s.Require().Len(decimals, 2, "-sim-decimals")
+	from, err := strconv.ParseUint(decimals[0], 10, 32)
+	s.Require().NoError(err)
+	to, err := strconv.ParseUint(decimals[1], 10, 32)
+	s.Require().NoError(err)
+	s.fromDecimals, s.toDecimals = uint32(from), uint32(to)
+}
+
+// deploy deploys the contracts to a new node, with RSV issued against a basket of `from`
+// alone, a token of it for each RSV, and the proposer funded with plenty of both tokens.
+func (s *MigrationSimSuite) deploy() {
+	s.setup()
+	s.operator = s.account[1]
+	s.proposer = s.account[5]
+	s.deployReserve()
+
+	vaultAddress, tx, vault, err := abi.DeployVault(s.signer, s.node)
+	s.logParsers[vaultAddress] = vault
+	s.requireTx(tx, err)
+	s.vaultAddress, s.vault = vaultAddress, vault
+
+	propFactoryAddress, tx, propFactory, err := abi.DeployProposalFactory(s.signer, s.node)
+	s.logParsers[propFactoryAddress] = propFactory
+	s.requireTx(tx, err)
+
+	s.erc20s = make([]*abi.BasicERC20, 2)
+	s.erc20Addresses = make([]common.Address, 2)
+	for i := range s.erc20s {
+		erc20Address, tx, erc20, err := abi.DeployBasicERC20(s.signer, s.node)
+		s.logParsers[erc20Address] = erc20
+		s.requireTx(tx, err)
+		s.erc20s[i], s.erc20Addresses[i] = erc20, erc20Address
+	}
+
+	basketAddress, tx, basket, err := abi.DeployBasket(
+		s.signer, s.node, zeroAddress(), s.erc20Addresses[:1], []*big.Int{shiftLeft(1, 18+s.fromDecimals)},
+	)
+	s.logParsers[basketAddress] = basket
+	s.requireTx(tx, err)
+	s.basketAddress, s.basket = basketAddress, basket
+
+	managerAddress, tx, manager, err := abi.DeployManager(
+		s.signer, s.node,
+		vaultAddress, s.reserveAddress, propFactoryAddress, basketAddress, s.operator.address(), bigInt(0),
+	)
+	s.logParsers[managerAddress] = manager
+	s.requireTx(tx, err)
+	s.managerAddress, s.manager = managerAddress, manager
+
+	s.requireTx(s.manager.SetEmergency(signer(s.operator), false))
+	s.requireTx(s.reserve.ChangeMinter(s.signer, managerAddress))
+	s.requireTx(s.vault.ChangeManager(s.signer, managerAddress))
+
+	s.fundAccountWithErc20sAndApprove(s.proposer, []*big.Int{shiftLeft(1, 40), shiftLeft(1, 40)})
+	s.requireTx(s.manager.Issue(signer(s.proposer), simSupply))
+}
+
+// vaultBalances returns the Vault's balances of `from` and `to`.
+func (s *MigrationSimSuite) vaultBalances() (*big.Int, *big.Int) {
+	from, err := s.erc20s[0].BalanceOf(nil, s.vaultAddress)
+	s.Require().NoError(err)
+	to, err := s.erc20s[1].BalanceOf(nil, s.vaultAddress)
+	s.Require().NoError(err)
+	return from, to
+}
+
+// migrate plays scenario on newly deployed contracts. Each tranche is proposed at the proposer's
+// quote, and accepted; once the delay has passed, the operator executes it if the proposer lets
+// them, and the proposer withdraws it otherwise.
+func (s *MigrationSimSuite) migrate(scenario sim.Scenario) sim.Outcome {
+	s.deploy()
+	from, to := s.erc20Addresses[0], s.erc20Addresses[1]
+	delay, err := s.manager.Delay(nil)
+	s.Require().NoError(err)
+	startWeight, err := s.basket.Weights(nil, from)
+	s.Require().NoError(err)
+
+	// The market price of `from`, in `to`, which is worth a dollar.
+	price := 1.0
+	outcome := sim.Outcome{Seed: scenario.Seed}
+	var sent, received float64
+	for _, tranche := range scenario.Tranches {
+		quote := tranche.Quote(price)
+		id, err := s.manager.ProposalsLength(nil)
+		s.Require().NoError(err)
+		s.requireTx(s.manager.ProposeRebalance(
+			signer(s.proposer), from, to, bigInt(tranche.Portion), sim.Rate(quote, s.fromDecimals, s.toDecimals),
+		))
+		s.requireTx(s.manager.AcceptProposal(signer(s.operator), id))
+		s.Require().NoError(s.node.(backend).AdjustTime(time.Duration(delay.Int64())*time.Second + time.Minute))
+
+		price = tranche.Moved(price)
+		if !tranche.Fills(quote, price) {
+			s.requireTx(s.manager.WithdrawProposal(signer(s.proposer), id, "price moved"))
+			continue
+		}
+
+		fromBefore, toBefore := s.vaultBalances()
+		s.requireTx(s.manager.ExecuteProposal(signer(s.operator), id))
+		fromAfter, toAfter := s.vaultBalances()
+		sent += sim.Value(new(big.Int).Sub(fromBefore, fromAfter), s.fromDecimals, price)
+		received += sim.Value(new(big.Int).Sub(toAfter, toBefore), s.toDecimals, 1)
+		outcome.Filled++
+
+		s.basketAddress, err = s.manager.TrustedBasket(nil)
+		s.Require().NoError(err)
+		s.basket, err = abi.NewBasket(s.basketAddress, s.node)
+		s.Require().NoError(err)
+	}
+
+	fromHeld, toHeld := s.vaultBalances()
+	supply, err := s.reserve.TotalSupply(nil)
+	s.Require().NoError(err)
+	held := sim.Value(fromHeld, s.fromDecimals, price) + sim.Value(toHeld, s.toDecimals, 1)
+	outcome.BackingRatio = held / sim.Value(supply, 18, 1)
+	if sent > 0 {
+		outcome.Slippage = (sent - received) / sent
+	}
+	endWeight, err := s.basket.Weights(nil, from)
+	s.Require().NoError(err)
+	moved, _ := new(big.Rat).SetFrac(new(big.Int).Sub(startWeight, endWeight), startWeight).Float64()
+	outcome.Migrated = moved
+	return outcome
+}
+
+// TestMigrations plays -sim-runs migrations, and prints the report of them.
+func (s *MigrationSimSuite) TestMigrations() {
+	params := sim.Params{
+		Tranches:   *simTranches,
+		Volatility: *simVolatility,
+		MaxMarkup:  *simMarkup,
+		WalkAway:   *simWalkAway,
+	}
+	s.Require().NoError(params.Check())
+
+	var outcomes []sim.Outcome
+	for i := 0; i < *simRuns; i++ {
+		outcomes = append(outcomes, s.migrate(params.Draw(*simSeed+int64(i))))
+		// The contracts keep the Vault collateralized however a migration goes.
+		s.assertManagerCollateralized()
+	}
+
+	report := sim.NewReport(params, outcomes)
+	fmt.Printf("\nMigrating from a token of %v decimals to one of %v:\n", s.fromDecimals, s.toDecimals)
+	fmt.Print(report.Format())
+	if *simReport != "" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		s.Require().NoError(err)
+		s.Require().NoError(ioutil.WriteFile(*simReport, append(b, '\n'), 0644))
+	}
+}
@@ -0,0 +1,371 @@
+// +build all
+
+package tests
+
+import (
+	"fmt"
+	"math/big"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/ops/authorize"
+	"github.com/reserve-protocol/rsv-beta/soltools"
+)
+
+func TestGuardianMultisig(t *testing.T) {
+	suite.Run(t, new(GuardianMultisigSuite))
+}
+
+type GuardianMultisigSuite struct {
+	TestSuite
+
+	multisig        *abi.GuardianMultisig
+	multisigAddress common.Address
+	guardians       []account
+}
+
+var (
+	// Compile-time check that GuardianMultisigSuite implements the interfaces we think it does.
+	// If it does not implement these interfaces, then the corresponding setup and teardown
+	// functions will not actually run.
+	_ suite.BeforeTest       = &GuardianMultisigSuite{}
+	_ suite.SetupAllSuite    = &GuardianMultisigSuite{}
+	_ suite.TearDownAllSuite = &GuardianMultisigSuite{}
+)
+
+// multisigChainID is the chain ID that the suite's multisigs are deployed for.
+var multisigChainID = bigInt(1)
+
+// SetupSuite runs once, before all of the tests in the suite.
+func (s *GuardianMultisigSuite) SetupSuite() {
+	s.setup()
+}
+
+// TearDownSuite runs once, after all of the tests in the suite.
+func (s *GuardianMultisigSuite) TearDownSuite() {
+	if coverageEnabled {
+		// Write coverage profile to disk.
+		s.Assert().NoError(s.node.(*soltools.Backend).WriteCoverage())
+
+		// Close the node.js process.
+		s.Assert().NoError(s.node.(*soltools.Backend).Close())
+
+		// Process coverage profile into an HTML report.
+		if out, err := exec.Command("npx", "istanbul", "report", "html").CombinedOutput(); err != nil {
+			fmt.Println()
+			fmt.Println("I generated coverage information in coverage/coverage.json.")
+			fmt.Println("I tried to process it with `istanbul` to turn it into a readable report, but failed.")
+			fmt.Println("The error I got when running istanbul was:", err)
+			fmt.Println("Istanbul's output was:\n" + string(out))
+		}
+	}
+}
+
+// BeforeTest runs before each test in the suite.
+func (s *GuardianMultisigSuite) BeforeTest(suiteName, testName string) {
+	s.owner = s.account[0]
+	s.deployReserve()
+
+	// Three guardians, two of whom must sign, as the Reserve's guardian and freezer.
+	s.guardians = []account{s.account[2], s.account[3], s.account[4]}
+	addresses := []common.Address{s.guardians[0].address(), s.guardians[1].address(), s.guardians[2].address()}
+	address, tx, multisig, err := abi.DeployGuardianMultisig(
+		s.signer, s.node, s.reserveAddress, multisigChainID, addresses, bigInt(2),
+	)
+	s.logParsers[address] = multisig
+	s.requireTxWithStrictEvents(tx, err)(
+		abi.GuardianMultisigGuardianAdded{Guardian: addresses[0]},
+		abi.GuardianMultisigGuardianAdded{Guardian: addresses[1]},
+		abi.GuardianMultisigGuardianAdded{Guardian: addresses[2]},
+		abi.GuardianMultisigThresholdChanged{OldVal: bigInt(0), NewVal: bigInt(2)},
+	)
+	s.multisigAddress, s.multisig = address, multisig
+
+	s.requireTxWithStrictEvents(s.reserve.ChangeGuardian(s.signer, address))(
+		abi.ReserveGuardianChanged{NewGuardian: address},
+	)
+	s.requireTxWithStrictEvents(s.reserve.ChangeFreezer(s.signer, address))(
+		abi.ReserveFreezerChanged{NewFreezer: address},
+	)
+}
+
+// action returns the action `kind`, at the multisig's current nonce, valid for an hour.
+func (s *GuardianMultisigSuite) action(kind uint8, target common.Address, value uint32) authorize.Action {
+	nonce, err := s.multisig.Nonce(nil)
+	s.Require().NoError(err)
+	return authorize.Action{
+		Kind:     kind,
+		Account:  target,
+		Value:    bigInt(value),
+		Nonce:    nonce,
+		Deadline: bigInt(0).Add(s.currentTimestamp(), bigInt(3600)),
+	}
+}
+
+// sign returns the signatures of `a` by `signers`, for the multisig at `address`, in the order
+// that execute takes them.
+func (s *GuardianMultisigSuite) sign(
+	a authorize.Action, address common.Address, signers ...account,
+) *authorize.Signatures {
+	hash := a.Hash(authorize.GuardianMultisig(address, multisigChainID))
+	var sigs []authorize.Signature
+	for _, acc := range signers {
+		raw, err := crypto.Sign(hash.Bytes(), acc.key)
+		s.Require().NoError(err)
+		raw = addToLastByte(raw)
+		var sig authorize.Signature
+		copy(sig.R[:], raw[:32])
+		copy(sig.S[:], raw[32:64])
+		sig.V = raw[64]
+		sigs = append(sigs, sig)
+	}
+	collected, err := authorize.Collect(hash, sigs)
+	s.Require().NoError(err)
+	return collected
+}
+
+// execute submits `a` with `sigs` from a non-guardian account, as anyone may.
+func (s *GuardianMultisigSuite) execute(a authorize.Action, sigs *authorize.Signatures) func(...fmt.Stringer) {
+	return s.requireTxWithStrictEvents(s.multisig.Execute(
+		signer(s.account[1]), a.Kind, a.Account, a.Value, a.Deadline, sigs.V, sigs.R, sigs.S,
+	))
+}
+
+// executeFails checks that `a` can't be executed with `sigs`.
+func (s *GuardianMultisigSuite) executeFails(a authorize.Action, sigs *authorize.Signatures) {
+	s.requireTxFails(s.multisig.Execute(
+		signer(s.account[1]), a.Kind, a.Account, a.Value, a.Deadline, sigs.V, sigs.R, sigs.S,
+	))
+}
+
+// executed is the ActionExecuted event of `a`.
+func executed(a authorize.Action) abi.GuardianMultisigActionExecuted {
+	return abi.GuardianMultisigActionExecuted{Action: a.Kind, Account: a.Account, Value: a.Value, Nonce: a.Nonce}
+}
+
+// assertGuardians asserts that the multisig's guardians are exactly `guardians`, in order,
+// with threshold `threshold`.
+func (s *GuardianMultisigSuite) assertGuardians(threshold uint32, guardians ...common.Address) {
+	length, err := s.multisig.GuardiansLength(nil)
+	s.Require().NoError(err)
+	s.Require().Equal(fmt.Sprint(len(guardians)), length.String())
+	for i, guardian := range guardians {
+		found, err := s.multisig.Guardians(nil, bigInt(uint32(i)))
+		s.Require().NoError(err)
+		s.Equal(guardian, found)
+		isGuardian, err := s.multisig.IsGuardian(nil, guardian)
+		s.Require().NoError(err)
+		s.True(isGuardian)
+	}
+	found, err := s.multisig.Threshold(nil)
+	s.Require().NoError(err)
+	s.Equal(fmt.Sprint(threshold), found.String())
+}
+
+// TestConstructor tests the multisig's initial state, and that it refuses a bad set of
+// guardians.
+func (s *GuardianMultisigSuite) TestConstructor() {
+	s.assertGuardians(2, s.guardians[0].address(), s.guardians[1].address(), s.guardians[2].address())
+	nonce, err := s.multisig.Nonce(nil)
+	s.Require().NoError(err)
+	s.Equal("0", nonce.String())
+	reserve, err := s.multisig.Reserve(nil)
+	s.Require().NoError(err)
+	s.Equal(s.reserveAddress, reserve)
+	isGuardian, err := s.multisig.IsGuardian(nil, s.account[5].address())
+	s.Require().NoError(err)
+	s.False(isGuardian)
+
+	// Its digest is the one that guardians are given to sign.
+	a := s.action(authorize.Freeze, s.account[5].address(), 0)
+	digest, err := s.multisig.Digest(nil, a.Kind, a.Account, a.Value, a.Deadline)
+	s.Require().NoError(err)
+	s.Equal(a.Hash(authorize.GuardianMultisig(s.multisigAddress, multisigChainID)), common.Hash(digest))
+
+	two := []common.Address{s.account[2].address(), s.account[3].address()}
+	for _, c := range []struct {
+		reserve   common.Address
+		guardians []common.Address
+		threshold uint32
+	}{
+		{zeroAddress(), two, 1},
+		{s.reserveAddress, two, 0},
+		{s.reserveAddress, two, 3},
+		{s.reserveAddress, []common.Address{two[0], two[0]}, 1},
+		{s.reserveAddress, []common.Address{two[0], zeroAddress()}, 1},
+		{s.reserveAddress, nil, 0},
+	} {
+		_, tx, _, err := abi.DeployGuardianMultisig(s.signer, s.node, c.reserve, multisigChainID, c.guardians, bigInt(c.threshold))
+		s.requireTxFails(tx, err)
+	}
+}
+
+// TestPause tests that two guardians can pause the Reserve, but one can't.
+func (s *GuardianMultisigSuite) TestPause() {
+	a := s.action(authorize.Pause, zeroAddress(), 0)
+	s.executeFails(a, s.sign(a, s.multisigAddress, s.guardians[0]))
+
+	s.execute(a, s.sign(a, s.multisigAddress, s.guardians[2], s.guardians[0]))(
+		executed(a),
+		abi.ReservePaused{Account: s.multisigAddress},
+	)
+	paused, err := s.reserve.Paused(nil)
+	s.Require().NoError(err)
+	s.True(paused)
+
+	// It can't unpause: that is left to the pauser.
+	s.requireTxFails(s.reserve.Unpause(signer(s.guardians[0])))
+}
+
+// TestPauseTakesNoArguments tests that a pause names neither an account nor a value.
+func (s *GuardianMultisigSuite) TestPauseTakesNoArguments() {
+	a := s.action(authorize.Pause, s.account[5].address(), 0)
+	s.executeFails(a, s.sign(a, s.multisigAddress, s.guardians[0], s.guardians[1]))
+	a = s.action(authorize.Pause, zeroAddress(), 1)
+	s.executeFails(a, s.sign(a, s.multisigAddress, s.guardians[0], s.guardians[1]))
+
+	// Nor is there any action past the last.
+	a = s.action(authorize.ChangeThreshold+1, zeroAddress(), 0)
+	s.executeFails(a, s.sign(a, s.multisigAddress, s.guardians[0], s.guardians[1]))
+}
+
+// TestFreeze tests that the guardians can freeze and unfreeze accounts.
+func (s *GuardianMultisigSuite) TestFreeze() {
+	target := s.account[5].address()
+	a := s.action(authorize.Freeze, target, 0)
+	s.execute(a, s.sign(a, s.multisigAddress, s.guardians[0], s.guardians[1]))(
+		executed(a),
+		abi.ReserveAddressFrozen{Account: target},
+	)
+	frozen, err := s.reserve.Frozen(nil, target)
+	s.Require().NoError(err)
+	s.True(frozen)
+
+	a = s.action(authorize.Unfreeze, target, 0)
+	s.execute(a, s.sign(a, s.multisigAddress, s.guardians[1], s.guardians[2]))(
+		executed(a),
+		abi.ReserveAddressUnfrozen{Account: target},
+	)
+	frozen, err = s.reserve.Frozen(nil, target)
+	s.Require().NoError(err)
+	s.False(frozen)
+}
+
+// TestReplayProtection tests that signatures are good for one execution, of one action, by one
+// multisig, on one chain, until their deadline.
+func (s *GuardianMultisigSuite) TestReplayProtection() {
+	target := s.account[5].address()
+	freeze := s.action(authorize.Freeze, target, 0)
+	sigs := s.sign(freeze, s.multisigAddress, s.guardians[0], s.guardians[1])
+
+	// Signatures for another chain, or another multisig, don't count.
+	otherChain := *s.sign(freeze, s.multisigAddress, s.guardians[0], s.guardians[1])
+	hash := freeze.Hash(authorize.GuardianMultisig(s.multisigAddress, bigInt(2)))
+	for i := range otherChain.V {
+		raw, err := crypto.Sign(hash.Bytes(), s.guardians[i].key)
+		s.Require().NoError(err)
+		raw = addToLastByte(raw)
+		otherChain.V[i] = raw[64]
+		copy(otherChain.R[i][:], raw[:32])
+		copy(otherChain.S[i][:], raw[32:64])
+	}
+	s.executeFails(freeze, &otherChain)
+	s.executeFails(freeze, s.sign(freeze, s.account[5].address(), s.guardians[0], s.guardians[1]))
+
+	// Nor do signatures of another action.
+	unfreeze := s.action(authorize.Unfreeze, target, 0)
+	s.executeFails(unfreeze, sigs)
+
+	// The same signer twice counts once, so its signatures are out of order.
+	once := s.sign(freeze, s.multisigAddress, s.guardians[0])
+	twice := &authorize.Signatures{
+		V: append(once.V, once.V[0]), R: append(once.R, once.R[0]), S: append(once.S, once.S[0]),
+	}
+	s.executeFails(freeze, twice)
+
+	// Signatures in descending order of signer are refused too.
+	reversed := &authorize.Signatures{
+		V: []uint8{sigs.V[1], sigs.V[0]}, R: [][32]byte{sigs.R[1], sigs.R[0]}, S: [][32]byte{sigs.S[1], sigs.S[0]},
+	}
+	s.executeFails(freeze, reversed)
+
+	// A non-guardian's signature doesn't count.
+	s.executeFails(freeze, s.sign(freeze, s.multisigAddress, s.guardians[0], s.account[5]))
+
+	// The signatures work once; then the nonce has moved on.
+	s.execute(freeze, sigs)(executed(freeze), abi.ReserveAddressFrozen{Account: target})
+	s.executeFails(freeze, sigs)
+	nonce, err := s.multisig.Nonce(nil)
+	s.Require().NoError(err)
+	s.Equal("1", nonce.String())
+
+	// Signatures past their deadline are void.
+	unfreeze = s.action(authorize.Unfreeze, target, 0)
+	sigs = s.sign(unfreeze, s.multisigAddress, s.guardians[0], s.guardians[1])
+	s.Require().NoError(s.node.(backend).AdjustTime(2 * time.Hour))
+	s.executeFails(unfreeze, sigs)
+}
+
+// TestThresholdChanges tests that the guardians can change their threshold, and add and remove
+// guardians, by the same m-of-n approval.
+func (s *GuardianMultisigSuite) TestThresholdChanges() {
+	g0, g1, g2 := s.guardians[0], s.guardians[1], s.guardians[2]
+	newcomer := s.account[5]
+
+	// The threshold must be from one to the number of guardians.
+	for _, value := range []uint32{0, 4} {
+		a := s.action(authorize.ChangeThreshold, zeroAddress(), value)
+		s.executeFails(a, s.sign(a, s.multisigAddress, g0, g1))
+	}
+
+	// Three of three.
+	a := s.action(authorize.ChangeThreshold, zeroAddress(), 3)
+	s.execute(a, s.sign(a, s.multisigAddress, g0, g1))(
+		executed(a),
+		abi.GuardianMultisigThresholdChanged{OldVal: bigInt(2), NewVal: bigInt(3)},
+	)
+	s.assertGuardians(3, g0.address(), g1.address(), g2.address())
+	a = s.action(authorize.Pause, zeroAddress(), 0)
+	s.executeFails(a, s.sign(a, s.multisigAddress, g0, g1))
+
+	// Three of four.
+	a = s.action(authorize.AddGuardian, newcomer.address(), 3)
+	s.execute(a, s.sign(a, s.multisigAddress, g0, g1, g2))(
+		executed(a),
+		abi.GuardianMultisigGuardianAdded{Guardian: newcomer.address()},
+		abi.GuardianMultisigThresholdChanged{OldVal: bigInt(3), NewVal: bigInt(3)},
+	)
+	s.assertGuardians(3, g0.address(), g1.address(), g2.address(), newcomer.address())
+	a = s.action(authorize.AddGuardian, newcomer.address(), 3)
+	s.executeFails(a, s.sign(a, s.multisigAddress, g0, g1, g2))
+
+	// Two of three, without g0: the last guardian takes its place.
+	a = s.action(authorize.RemoveGuardian, g0.address(), 2)
+	s.execute(a, s.sign(a, s.multisigAddress, g1, g2, newcomer))(
+		executed(a),
+		abi.GuardianMultisigGuardianRemoved{Guardian: g0.address()},
+		abi.GuardianMultisigThresholdChanged{OldVal: bigInt(3), NewVal: bigInt(2)},
+	)
+	s.assertGuardians(2, newcomer.address(), g1.address(), g2.address())
+	isGuardian, err := s.multisig.IsGuardian(nil, g0.address())
+	s.Require().NoError(err)
+	s.False(isGuardian)
+
+	// Removing a guardian can't leave the threshold above the number left.
+	a = s.action(authorize.RemoveGuardian, g1.address(), 3)
+	s.executeFails(a, s.sign(a, s.multisigAddress, g1, g2))
+
+	// g0 no longer counts, and the newcomer does.
+	a = s.action(authorize.Pause, zeroAddress(), 0)
+	s.executeFails(a, s.sign(a, s.multisigAddress, g0, g1))
+	s.execute(a, s.sign(a, s.multisigAddress, newcomer, g1))(
+		executed(a),
+		abi.ReservePaused{Account: s.multisigAddress},
+	)
+}
@@ -0,0 +1,448 @@
+// +build all
+
+package tests
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os/exec"
+	"testing"
+
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/soltools"
+)
+
+func TestOFTAdapter(t *testing.T) {
+	suite.Run(t, new(OFTAdapterSuite))
+}
+
+// OFTAdapterSuite tests a pair of OFTAdapters, each with its own Reserve and MockLZEndpoint, that
+// play RSV's home chain and a remote chain on the suite's one chain. The endpoints deliver each
+// message as it is sent, so a transfer between the chains happens in one transaction.
+//
+// s.reserve is the home chain's Reserve, whose adapter is a lockbox; s.remoteReserve is the
+// remote chain's, whose minter is its adapter.
+type OFTAdapterSuite struct {
+	TestSuite
+
+	homeEndpoint   common.Address
+	homeAdapter    *abi.OFTAdapter
+	homeAddress    common.Address
+	remoteEndpoint common.Address
+	remoteAdapter  *abi.OFTAdapter
+	remoteAddress  common.Address
+	remoteReserve  *abi.Reserve
+	remoteRSV      common.Address
+}
+
+var (
+	// Compile-time check that OFTAdapterSuite implements the interfaces we think it does.
+	// If it does not implement these interfaces, then the corresponding setup and teardown
+	// functions will not actually run.
+	_ suite.BeforeTest       = &OFTAdapterSuite{}
+	_ suite.SetupAllSuite    = &OFTAdapterSuite{}
+	_ suite.TearDownAllSuite = &OFTAdapterSuite{}
+)
+
+// The LayerZero chain IDs of the two chains, which are LayerZero's for Ethereum and Arbitrum.
+const (
+	homeChain   uint16 = 101
+	remoteChain uint16 = 110
+)
+
+// lzFee is what the mock endpoints charge for each message, in wei.
+var lzFee = bigInt(1000)
+
+// SetupSuite runs once, before all of the tests in the suite.
+func (s *OFTAdapterSuite) SetupSuite() {
+	s.setup()
+}
+
+// TearDownSuite runs once, after all of the tests in the suite.
+func (s *OFTAdapterSuite) TearDownSuite() {
+	if coverageEnabled {
+		// Write coverage profile to disk.
+		s.Assert().NoError(s.node.(*soltools.Backend).WriteCoverage())
+
+		// Close the node.js process.
+		s.Assert().NoError(s.node.(*soltools.Backend).Close())
+
+		// Process coverage profile into an HTML report.
+		if out, err := exec.Command("npx", "istanbul", "report", "html").CombinedOutput(); err != nil {
+			fmt.Println()
+			fmt.Println("I generated coverage information in coverage/coverage.json.")
+			fmt.Println("I tried to process it with `istanbul` to turn it into a readable report, but failed.")
+			fmt.Println("The error I got when running istanbul was:", err)
+			fmt.Println("Istanbul's output was:\n" + string(out))
+		}
+	}
+}
+
+// BeforeTest runs before each test in the suite.
+func (s *OFTAdapterSuite) BeforeTest(suiteName, testName string) {
+	s.owner = s.account[0]
+
+	// The remote chain's Reserve first, since deployReserve starts the log parsers over.
+	s.deployReserve()
+	s.remoteReserve, s.remoteRSV = s.reserve, s.reserveAddress
+	remoteParsers := s.logParsers
+	s.deployReserve()
+	for address, parser := range remoteParsers {
+		s.logParsers[address] = parser
+	}
+
+	s.homeEndpoint = s.deployEndpoint(homeChain)
+	s.remoteEndpoint = s.deployEndpoint(remoteChain)
+	s.homeAddress, s.homeAdapter = s.deployAdapter(s.reserveAddress, s.homeEndpoint, true)
+	s.remoteAddress, s.remoteAdapter = s.deployAdapter(s.remoteRSV, s.remoteEndpoint, false)
+	s.requireTx(s.remoteReserve.ChangeMinter(s.signer, s.remoteAddress))
+
+	// Each adapter is reached through its own chain's endpoint.
+	for _, address := range []common.Address{s.homeEndpoint, s.remoteEndpoint} {
+		endpoint, err := abi.NewMockLZEndpoint(address, s.node)
+		s.Require().NoError(err)
+		s.requireTx(endpoint.SetDestLzEndpoint(s.signer, s.homeAddress, s.homeEndpoint))
+		s.requireTx(endpoint.SetDestLzEndpoint(s.signer, s.remoteAddress, s.remoteEndpoint))
+	}
+
+	s.requireTx(s.homeAdapter.SetTrustedRemoteAddress(s.signer, remoteChain, s.remoteAddress.Bytes()))
+	s.requireTx(s.remoteAdapter.SetTrustedRemoteAddress(s.signer, homeChain, s.homeAddress.Bytes()))
+
+	// The holder has RSV on the home chain, and has approved its adapter for it.
+	s.requireTx(s.reserve.ChangeMinter(s.signer, s.owner.address()))
+	s.requireTx(s.reserve.Mint(s.signer, s.account[1].address(), bigInt(1000)))
+	s.requireTx(s.reserve.Approve(signer(s.account[1]), s.homeAddress, bigInt(1000)))
+}
+
+// deployEndpoint deploys a MockLZEndpoint for the chain with LayerZero ID chainID.
+func (s *OFTAdapterSuite) deployEndpoint(chainID uint16) common.Address {
+	address, tx, endpoint, err := abi.DeployMockLZEndpoint(s.signer, s.node, chainID, lzFee)
+	s.logParsers[address] = endpoint
+	s.requireTx(tx, err)
+	return address
+}
+
+// deployAdapter deploys an OFTAdapter for the Reserve at rsv, using the endpoint at endpoint.
+func (s *OFTAdapterSuite) deployAdapter(
+	rsv, endpoint common.Address, lockbox bool,
+) (common.Address, *abi.OFTAdapter) {
+	address, tx, adapter, err := abi.DeployOFTAdapter(s.signer, s.node, rsv, endpoint, lockbox)
+	s.logParsers[address] = adapter
+	s.requireTx(tx, err)
+	return address, adapter
+}
+
+// withFee returns opts for a from, sending value wei along.
+func withFee(from account, value *big.Int) *bind.TransactOpts {
+	opts := signer(from)
+	opts.Value = value
+	return opts
+}
+
+// path is the path of messages from the adapter at src to the adapter at dst.
+func path(src, dst common.Address) []byte {
+	return append(src.Bytes(), dst.Bytes()...)
+}
+
+func (s *OFTAdapterSuite) assertRemoteRSVBalance(address common.Address, amount *big.Int) {
+	balance, err := s.remoteReserve.BalanceOf(nil, address)
+	s.NoError(err)
+	s.Equal(amount.String(), balance.String())
+}
+
+func (s *OFTAdapterSuite) TestDeploy() {}
+
+// TestConstructor tests that the constructor sets initial state appropriately.
+func (s *OFTAdapterSuite) TestConstructor() {
+	owner, err := s.homeAdapter.Owner(nil)
+	s.Require().NoError(err)
+	s.Equal(s.owner.address(), owner)
+
+	rsv, err := s.homeAdapter.TrustedRSV(nil)
+	s.Require().NoError(err)
+	s.Equal(s.reserveAddress, rsv)
+
+	endpoint, err := s.homeAdapter.LzEndpoint(nil)
+	s.Require().NoError(err)
+	s.Equal(s.homeEndpoint, endpoint)
+
+	lockbox, err := s.homeAdapter.Lockbox(nil)
+	s.Require().NoError(err)
+	s.True(lockbox)
+	lockbox, err = s.remoteAdapter.Lockbox(nil)
+	s.Require().NoError(err)
+	s.False(lockbox)
+}
+
+func (s *OFTAdapterSuite) TestSetTrustedRemoteAddress() {
+	remote, err := s.homeAdapter.GetTrustedRemoteAddress(nil, remoteChain)
+	s.Require().NoError(err)
+	s.Equal(s.remoteAddress.Bytes(), remote)
+	lookup, err := s.homeAdapter.TrustedRemoteLookup(nil, remoteChain)
+	s.Require().NoError(err)
+	s.Equal(path(s.remoteAddress, s.homeAddress), lookup)
+
+	// An empty address trusts none.
+	s.requireTxWithStrictEvents(s.homeAdapter.SetTrustedRemoteAddress(s.signer, remoteChain, []byte{}))(
+		abi.OFTAdapterSetTrustedRemoteAddress{RemoteChainId: remoteChain, RemoteAddress: []byte{}},
+	)
+	_, err = s.homeAdapter.GetTrustedRemoteAddress(nil, remoteChain)
+	s.Error(err)
+	s.requireTxFails(s.homeAdapter.SendFrom(
+		withFee(s.account[1], lzFee),
+		s.account[1].address(), remoteChain, s.account[2].address().Bytes(), bigInt(1),
+		s.account[1].address(), zeroAddress(), []byte{},
+	))
+
+	// Only the owner sets them.
+	s.requireTxFails(s.homeAdapter.SetTrustedRemoteAddress(
+		signer(s.account[1]), remoteChain, s.account[1].address().Bytes(),
+	))
+	s.requireTxWithStrictEvents(s.homeAdapter.SetTrustedRemoteAddress(
+		s.signer, remoteChain, s.remoteAddress.Bytes(),
+	))(
+		abi.OFTAdapterSetTrustedRemoteAddress{
+			RemoteChainId: remoteChain, RemoteAddress: s.remoteAddress.Bytes(),
+		},
+	)
+}
+
+func (s *OFTAdapterSuite) TestEstimateSendFee() {
+	fees, err := s.homeAdapter.EstimateSendFee(
+		nil, remoteChain, s.account[2].address().Bytes(), bigInt(100), false, []byte{},
+	)
+	s.Require().NoError(err)
+	s.Equal(lzFee.String(), fees.NativeFee.String())
+	s.Equal("0", fees.ZroFee.String())
+}
+
+// TestSendAndReturn sends RSV from the home chain, where it is locked, to the remote chain,
+// where it is minted, and some of it back again.
+func (s *OFTAdapterSuite) TestSendAndReturn() {
+	holder, recipient := s.account[1], s.account[2]
+
+	s.requireTxWithStrictEvents(s.homeAdapter.SendFrom(
+		withFee(holder, lzFee),
+		holder.address(), remoteChain, recipient.address().Bytes(), bigInt(300),
+		holder.address(), zeroAddress(), []byte{},
+	))(
+		abi.ReserveTransfer{From: holder.address(), To: s.homeAddress, Value: bigInt(300)},
+		abi.ReserveApproval{Owner: holder.address(), Spender: s.homeAddress, Value: bigInt(700)},
+		mintingTransfer(recipient.address(), bigInt(300)),
+		abi.OFTAdapterReceiveFromChain{SrcChainId: homeChain, To: recipient.address(), Amount: bigInt(300)},
+		abi.MockLZEndpointDelivered{
+			SrcChainId: homeChain,
+			SrcAddress: path(s.homeAddress, s.remoteAddress),
+			DstAddress: s.remoteAddress,
+			Nonce:      1,
+		},
+		abi.OFTAdapterSendToChain{
+			DstChainId: remoteChain,
+			From:       holder.address(),
+			ToAddress:  recipient.address().Bytes(),
+			Amount:     bigInt(300),
+		},
+	)
+	s.assertRSVBalance(holder.address(), bigInt(700))
+	s.assertRSVBalance(s.homeAddress, bigInt(300))
+	s.assertRemoteRSVBalance(recipient.address(), bigInt(300))
+
+	// Sending back burns it on the remote chain, out of an allowance to its adapter.
+	s.requireTx(s.remoteReserve.Approve(signer(recipient), s.remoteAddress, bigInt(100)))
+	s.requireTxWithStrictEvents(s.remoteAdapter.SendFrom(
+		withFee(recipient, lzFee),
+		recipient.address(), homeChain, holder.address().Bytes(), bigInt(100),
+		recipient.address(), zeroAddress(), []byte{},
+	))(
+		burningTransfer(recipient.address(), bigInt(100)),
+		abi.ReserveApproval{Owner: recipient.address(), Spender: s.remoteAddress, Value: bigInt(0)},
+		abi.ReserveTransfer{From: s.homeAddress, To: holder.address(), Value: bigInt(100)},
+		abi.OFTAdapterReceiveFromChain{SrcChainId: remoteChain, To: holder.address(), Amount: bigInt(100)},
+		abi.MockLZEndpointDelivered{
+			SrcChainId: remoteChain,
+			SrcAddress: path(s.remoteAddress, s.homeAddress),
+			DstAddress: s.homeAddress,
+			Nonce:      1,
+		},
+		abi.OFTAdapterSendToChain{
+			DstChainId: homeChain,
+			From:       recipient.address(),
+			ToAddress:  holder.address().Bytes(),
+			Amount:     bigInt(100),
+		},
+	)
+
+	// All the RSV on the remote chain is locked on the home chain.
+	s.assertRSVBalance(holder.address(), bigInt(800))
+	s.assertRSVBalance(s.homeAddress, bigInt(200))
+	s.assertRemoteRSVBalance(recipient.address(), bigInt(200))
+	remoteSupply, err := s.remoteReserve.TotalSupply(nil)
+	s.Require().NoError(err)
+	s.Equal("200", remoteSupply.String())
+	s.assertRSVTotalSupply(bigInt(1000))
+}
+
+func (s *OFTAdapterSuite) TestSendFromRequirements() {
+	holder := s.account[1]
+	to := s.account[2].address().Bytes()
+
+	// Only for the sender itself.
+	s.requireTxFails(s.homeAdapter.SendFrom(
+		withFee(s.account[3], lzFee),
+		holder.address(), remoteChain, to, bigInt(1), holder.address(), zeroAddress(), []byte{},
+	))
+	// Only to a trusted chain.
+	s.requireTxFails(s.homeAdapter.SendFrom(
+		withFee(holder, lzFee),
+		holder.address(), 111, to, bigInt(1), holder.address(), zeroAddress(), []byte{},
+	))
+	// Not nothing.
+	s.requireTxFails(s.homeAdapter.SendFrom(
+		withFee(holder, lzFee),
+		holder.address(), remoteChain, to, bigInt(0), holder.address(), zeroAddress(), []byte{},
+	))
+	// Not without the fee.
+	s.requireTxFails(s.homeAdapter.SendFrom(
+		withFee(holder, bigInt(999)),
+		holder.address(), remoteChain, to, bigInt(1), holder.address(), zeroAddress(), []byte{},
+	))
+	// Not more than is approved.
+	s.requireTxFails(s.homeAdapter.SendFrom(
+		withFee(holder, lzFee),
+		holder.address(), remoteChain, to, bigInt(1001), holder.address(), zeroAddress(), []byte{},
+	))
+	s.assertRSVBalance(holder.address(), bigInt(1000))
+}
+
+// TestFeeRefund tests that what is sent along beyond the fee is refunded.
+func (s *OFTAdapterSuite) TestFeeRefund() {
+	holder, refund := s.account[1], s.account[4]
+	balanceOf := func(address common.Address) *big.Int {
+		balance, err := s.node.(interface {
+			BalanceAt(context.Context, common.Address, *big.Int) (*big.Int, error)
+		}).BalanceAt(context.Background(), address, nil)
+		s.Require().NoError(err)
+		return balance
+	}
+	before := balanceOf(refund.address())
+	s.requireTx(s.homeAdapter.SendFrom(
+		withFee(holder, bigInt(5000)),
+		holder.address(), remoteChain, holder.address().Bytes(), bigInt(1),
+		refund.address(), zeroAddress(), []byte{},
+	))
+	s.Equal(new(big.Int).Add(before, bigInt(4000)).String(), balanceOf(refund.address()).String())
+	s.Equal(lzFee.String(), balanceOf(s.homeEndpoint).String())
+}
+
+// TestLzReceiveOnlyFromTrustedRemote tests that an adapter accepts messages only from its
+// endpoint, and only from its trusted remotes.
+func (s *OFTAdapterSuite) TestLzReceiveOnlyFromTrustedRemote() {
+	payload := s.payload(s.account[1].address(), bigInt(100))
+
+	// A message straight to the adapter.
+	s.requireTxFails(s.remoteAdapter.LzReceive(
+		s.signer, homeChain, path(s.homeAddress, s.remoteAddress), 1, payload,
+	))
+
+	// A message through the endpoint from an adapter that isn't trusted.
+	endpoint, err := abi.NewMockLZEndpoint(s.remoteEndpoint, s.node)
+	s.Require().NoError(err)
+	s.requireTxFails(endpoint.ReceivePayload(
+		s.signer, homeChain, path(s.account[3].address(), s.remoteAddress), s.remoteAddress, 1, payload,
+	))
+	s.requireTxFails(endpoint.ReceivePayload(
+		s.signer, remoteChain, path(s.homeAddress, s.remoteAddress), s.remoteAddress, 1, payload,
+	))
+
+	// Only the adapter calls its own nonblockingLzReceive.
+	s.requireTxFails(s.remoteAdapter.NonblockingLzReceive(
+		s.signer, homeChain, path(s.homeAddress, s.remoteAddress), 1, payload,
+	))
+
+	supply, err := s.remoteReserve.TotalSupply(nil)
+	s.Require().NoError(err)
+	s.Equal("0", supply.String())
+}
+
+// payload returns the message of a transfer of amount attotokens to to, as an adapter sends it.
+func (s *OFTAdapterSuite) payload(to common.Address, amount *big.Int) []byte {
+	var args ethabi.Arguments
+	for _, t := range []string{"uint16", "bytes", "uint256"} {
+		typ, err := ethabi.NewType(t, nil)
+		s.Require().NoError(err)
+		args = append(args, ethabi.Argument{Type: typ})
+	}
+	payload, err := args.Pack(uint16(0), to.Bytes(), amount)
+	s.Require().NoError(err)
+	return payload
+}
+
+// TestFailedMessage tests that a transfer that can't be made, to an account frozen on the
+// remote chain, is kept for retrying, and that the messages behind it still go through.
+func (s *OFTAdapterSuite) TestFailedMessage() {
+	holder, frozen, other := s.account[1], s.account[2], s.account[3]
+	s.requireTx(s.remoteReserve.ChangeFreezer(s.signer, s.owner.address()))
+	s.requireTx(s.remoteReserve.Freeze(s.signer, frozen.address()))
+
+	tx, err := s.homeAdapter.SendFrom(
+		withFee(holder, lzFee),
+		holder.address(), remoteChain, frozen.address().Bytes(), bigInt(300),
+		holder.address(), zeroAddress(), []byte{},
+	)
+	receipt := s._requireTxStatus(tx, err, types.ReceiptStatusSuccessful)
+
+	// The RSV is locked, but not minted.
+	s.assertRSVBalance(s.homeAddress, bigInt(300))
+	s.assertRemoteRSVBalance(frozen.address(), bigInt(0))
+	failures, err := s.remoteAdapter.FilterMessageFailed(
+		&bind.FilterOpts{Start: receipt.BlockNumber.Uint64()},
+	)
+	s.Require().NoError(err)
+	s.Require().True(failures.Next())
+	failure := failures.Event
+	s.False(failures.Next())
+	s.Require().NoError(failures.Error())
+	srcAddress := path(s.homeAddress, s.remoteAddress)
+	payload := s.payload(frozen.address(), bigInt(300))
+	s.Equal(homeChain, failure.SrcChainId)
+	s.Equal(srcAddress, failure.SrcAddress)
+	s.Equal(uint64(1), failure.Nonce)
+	s.Equal(payload, failure.Payload)
+
+	// The next message still goes through.
+	s.requireTx(s.homeAdapter.SendFrom(
+		withFee(holder, lzFee),
+		holder.address(), remoteChain, other.address().Bytes(), bigInt(100),
+		holder.address(), zeroAddress(), []byte{},
+	))
+	s.assertRemoteRSVBalance(other.address(), bigInt(100))
+
+	// The failed one can't be retried while the recipient is frozen, nor with another payload.
+	s.requireTxFails(s.remoteAdapter.RetryMessage(signer(other), homeChain, srcAddress, 1, payload))
+	s.requireTx(s.remoteReserve.Unfreeze(s.signer, frozen.address()))
+	s.requireTxFails(s.remoteAdapter.RetryMessage(
+		signer(other), homeChain, srcAddress, 1, s.payload(other.address(), bigInt(300)),
+	))
+
+	// Then anyone can retry it, once.
+	s.requireTxWithStrictEvents(s.remoteAdapter.RetryMessage(signer(other), homeChain, srcAddress, 1, payload))(
+		mintingTransfer(frozen.address(), bigInt(300)),
+		abi.OFTAdapterReceiveFromChain{SrcChainId: homeChain, To: frozen.address(), Amount: bigInt(300)},
+		abi.OFTAdapterRetryMessageSuccess{
+			SrcChainId:  homeChain,
+			SrcAddress:  srcAddress,
+			Nonce:       1,
+			PayloadHash: crypto.Keccak256Hash(payload),
+		},
+	)
+	s.assertRemoteRSVBalance(frozen.address(), bigInt(300))
+	s.requireTxFails(s.remoteAdapter.RetryMessage(signer(other), homeChain, srcAddress, 1, payload))
+}
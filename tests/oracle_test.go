@@ -0,0 +1,354 @@
+// +build all
+
+package tests
+
+import (
+	"fmt"
+	"math/big"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/soltools"
+)
+
+func TestCollateralOracle(t *testing.T) {
+	suite.Run(t, new(CollateralOracleSuite))
+}
+
+type CollateralOracleSuite struct {
+	TestSuite
+
+	oracle        *abi.CollateralOracle
+	oracleAddress common.Address
+}
+
+var (
+	// Compile-time check that CollateralOracleSuite implements the interfaces we think it does.
+	// If it does not implement these interfaces, then the corresponding setup and teardown
+	// functions will not actually run.
+	_ suite.BeforeTest       = &CollateralOracleSuite{}
+	_ suite.SetupAllSuite    = &CollateralOracleSuite{}
+	_ suite.TearDownAllSuite = &CollateralOracleSuite{}
+)
+
+// SetupSuite runs once, before all of the tests in the suite.
+func (s *CollateralOracleSuite) SetupSuite() {
+	s.setup()
+}
+
+// TearDownSuite runs once, after all of the tests in the suite.
+func (s *CollateralOracleSuite) TearDownSuite() {
+	if coverageEnabled {
+		// Write coverage profile to disk.
+		s.Assert().NoError(s.node.(*soltools.Backend).WriteCoverage())
+
+		// Close the node.js process.
+		s.Assert().NoError(s.node.(*soltools.Backend).Close())
+
+		// Process coverage profile into an HTML report.
+		if out, err := exec.Command("npx", "istanbul", "report", "html").CombinedOutput(); err != nil {
+			fmt.Println()
+			fmt.Println("I generated coverage information in coverage/coverage.json.")
+			fmt.Println("I tried to process it with `istanbul` to turn it into a readable report, but failed.")
+			fmt.Println("The error I got when running istanbul was:", err)
+			fmt.Println("Istanbul's output was:\n" + string(out))
+		}
+	}
+}
+
+// BeforeTest runs before each test in the suite.
+func (s *CollateralOracleSuite) BeforeTest(suiteName, testName string) {
+	s.owner = s.account[0]
+	s.logParsers = map[common.Address]logParser{}
+	s.oracleAddress, s.oracle = s.deployCollateralOracle(300)
+}
+
+// deployCollateralOracle deploys a CollateralOracle owned by s.owner.
+func (s *TestSuite) deployCollateralOracle(maxDeviation uint32) (common.Address, *abi.CollateralOracle) {
+	address, tx, oracle, err := abi.DeployCollateralOracle(s.signer, s.node, bigInt(maxDeviation))
+	s.logParsers[address] = oracle
+	s.requireTxWithStrictEvents(tx, err)(
+		abi.CollateralOracleOwnershipTransferred{PreviousOwner: zeroAddress(), NewOwner: s.owner.address()},
+	)
+	return address, oracle
+}
+
+// deployMockAggregator deploys a MockAggregator of `decimals` decimals, answering `answer` as of
+// now.
+func (s *TestSuite) deployMockAggregator(decimals uint8, answer *big.Int) (common.Address, *abi.MockAggregator) {
+	address, tx, aggregator, err := abi.DeployMockAggregator(s.signer, s.node, decimals)
+	s.logParsers[address] = aggregator
+	s.requireTx(tx, err)
+	s.requireTx(aggregator.SetAnswer(s.signer, answer, s.currentTimestamp()))
+	return address, aggregator
+}
+
+// setFeed sets the oracle's feed for `token`, and checks the event.
+func (s *TestSuite) setFeed(
+	oracle *abi.CollateralOracle, token, aggregator common.Address, heartbeat time.Duration, tokenDecimals uint8,
+) {
+	s.requireTxWithStrictEvents(oracle.SetFeed(s.signer, token, aggregator, seconds(heartbeat), tokenDecimals))(
+		abi.CollateralOracleFeedChanged{
+			Token: token, Aggregator: aggregator, Heartbeat: seconds(heartbeat), TokenDecimals: tokenDecimals,
+		},
+	)
+}
+
+// assertPrice asserts that the oracle prices a whole `token` at `price` aUSD.
+func (s *CollateralOracleSuite) assertPrice(token common.Address, price *big.Int) {
+	found, err := s.oracle.Price(nil, token)
+	s.Require().NoError(err)
+	s.Equal(price.String(), found.String())
+}
+
+// assertNoPrice asserts that the oracle refuses to price `token`.
+func (s *CollateralOracleSuite) assertNoPrice(token common.Address) {
+	_, err := s.oracle.Price(nil, token)
+	s.Error(err)
+	_, err = s.oracle.Value(nil, token, bigInt(1))
+	s.Error(err)
+}
+
+// TestDeploy tests that the oracle deploys.
+func (s *CollateralOracleSuite) TestDeploy() {}
+
+// TestConstructor tests that the constructor sets state correctly.
+func (s *CollateralOracleSuite) TestConstructor() {
+	maxDeviation, err := s.oracle.MaxDeviation(nil)
+	s.Require().NoError(err)
+	s.Equal("300", maxDeviation.String())
+	owner, err := s.oracle.Owner(nil)
+	s.Require().NoError(err)
+	s.Equal(s.owner.address(), owner)
+
+	_, tx, _, err := abi.DeployCollateralOracle(s.signer, s.node, bigInt(10001))
+	s.requireTxFails(tx, err)
+}
+
+// TestPrice tests that the price is scaled from the feed's decimals to 18.
+func (s *CollateralOracleSuite) TestPrice() {
+	// 1.0001 dollars.
+	price := bigInt(0).Add(shiftLeft(1, 18), shiftLeft(1, 14))
+	for _, decimals := range []uint8{4, 6, 8, 18, 27} {
+		answer := bigInt(0).Add(shiftLeft(1, uint32(decimals)), shiftLeft(1, uint32(decimals)-4))
+		token := common.BigToAddress(bigInt(uint32(decimals)))
+		aggregator, _ := s.deployMockAggregator(decimals, answer)
+		s.setFeed(s.oracle, token, aggregator, time.Hour, 6)
+		s.assertPrice(token, price)
+	}
+}
+
+// TestValue tests that values are computed from the token's decimals, rounding down.
+func (s *CollateralOracleSuite) TestValue() {
+	// 1.02 dollars.
+	aggregator, _ := s.deployMockAggregator(8, bigInt(102000000))
+	price := bigInt(0).Mul(bigInt(102), shiftLeft(1, 16))
+
+	for _, decimals := range []uint8{0, 2, 6, 18, 36} {
+		token := common.BigToAddress(bigInt(uint32(decimals) + 1))
+		s.setFeed(s.oracle, token, aggregator, time.Hour, decimals)
+
+		// 5 whole tokens are worth 5.1 dollars.
+		value, err := s.oracle.Value(nil, token, shiftLeft(5, uint32(decimals)))
+		s.Require().NoError(err)
+		s.Equal(bigInt(0).Mul(price, bigInt(5)).String(), value.String(), "decimals %v", decimals)
+
+		// 1 qToken is worth price / 10**decimals, rounded down.
+		value, err = s.oracle.Value(nil, token, bigInt(1))
+		s.Require().NoError(err)
+		s.Equal(bigInt(0).Div(price, shiftLeft(1, uint32(decimals))).String(), value.String(), "decimals %v", decimals)
+	}
+}
+
+// TestExchangeRate tests that a token with an exchange rate source is valued as its underlying
+// token, at that rate, and that one without is its own underlying.
+func (s *CollateralOracleSuite) TestExchangeRate() {
+	// A cToken of 8 decimals, worth 0.02 of an 18-decimal underlying token at a dollar.
+	rate := bigInt(0).Mul(bigInt(2), shiftLeft(1, 26))
+	cTokenAddress, tx, cToken, err := abi.DeployMockCToken(s.signer, s.node, rate)
+	s.logParsers[cTokenAddress] = cToken
+	s.requireTx(tx, err)
+	aggregator, _ := s.deployMockAggregator(8, shiftLeft(1, 8))
+	s.setFeed(s.oracle, cTokenAddress, aggregator, time.Hour, 18)
+
+	found, err := s.oracle.ExchangeRate(nil, cTokenAddress)
+	s.Require().NoError(err)
+	s.Equal(shiftLeft(1, 18).String(), found.String())
+
+	s.requireTxWithStrictEvents(s.oracle.SetExchangeRateSource(s.signer, cTokenAddress, cTokenAddress))(
+		abi.CollateralOracleExchangeRateSourceChanged{Token: cTokenAddress, Source: cTokenAddress},
+	)
+	found, err = s.oracle.ExchangeRate(nil, cTokenAddress)
+	s.Require().NoError(err)
+	s.Equal(rate.String(), found.String())
+
+	// 50 whole cTokens are worth one dollar, and grow with the rate.
+	value, err := s.oracle.Value(nil, cTokenAddress, shiftLeft(50, 8))
+	s.Require().NoError(err)
+	s.Equal(shiftLeft(1, 18).String(), value.String())
+	s.requireTx(cToken.SetExchangeRate(s.signer, bigInt(0).Mul(bigInt(3), shiftLeft(1, 26))))
+	value, err = s.oracle.Value(nil, cTokenAddress, shiftLeft(50, 8))
+	s.Require().NoError(err)
+	s.Equal(shiftLeft(15, 17).String(), value.String())
+
+	// A rate of zero is refused.
+	s.requireTx(cToken.SetExchangeRate(s.signer, bigInt(0)))
+	_, err = s.oracle.ExchangeRate(nil, cTokenAddress)
+	s.Error(err)
+	_, err = s.oracle.Value(nil, cTokenAddress, shiftLeft(50, 8))
+	s.Error(err)
+
+	// Clearing the source makes the token its own underlying again.
+	s.requireTx(s.oracle.SetExchangeRateSource(s.signer, cTokenAddress, zeroAddress()))
+	value, err = s.oracle.Value(nil, cTokenAddress, shiftLeft(50, 18))
+	s.Require().NoError(err)
+	s.Equal(shiftLeft(50, 18).String(), value.String())
+}
+
+// TestStalePrice tests that a feed not updated within its heartbeat gives no price.
+func (s *CollateralOracleSuite) TestStalePrice() {
+	token := s.account[3].address()
+	address, aggregator := s.deployMockAggregator(8, shiftLeft(1, 8))
+	s.setFeed(s.oracle, token, address, time.Hour, 6)
+	s.assertPrice(token, shiftLeft(1, 18))
+
+	s.Require().NoError(s.node.(backend).AdjustTime(time.Hour + time.Minute))
+	s.assertNoPrice(token)
+
+	// An update makes it fresh again.
+	s.requireTx(aggregator.SetAnswer(s.signer, shiftLeft(1, 8), s.currentTimestamp()))
+	s.assertPrice(token, shiftLeft(1, 18))
+
+	// As does a longer heartbeat.
+	s.Require().NoError(s.node.(backend).AdjustTime(time.Hour + time.Minute))
+	s.assertNoPrice(token)
+	s.setFeed(s.oracle, token, address, 24*time.Hour, 6)
+	s.assertPrice(token, shiftLeft(1, 18))
+}
+
+// TestIncompleteRound tests that a round answered in an earlier round gives no price.
+func (s *CollateralOracleSuite) TestIncompleteRound() {
+	token := s.account[3].address()
+	address, aggregator := s.deployMockAggregator(8, shiftLeft(1, 8))
+	s.setFeed(s.oracle, token, address, time.Hour, 6)
+
+	s.requireTx(aggregator.StartRound(s.signer))
+	s.assertNoPrice(token)
+	s.requireTx(aggregator.SetAnswer(s.signer, shiftLeft(1, 8), s.currentTimestamp()))
+	s.assertPrice(token, shiftLeft(1, 18))
+}
+
+// TestNonPositivePrice tests that an answer of zero or less gives no price.
+func (s *CollateralOracleSuite) TestNonPositivePrice() {
+	token := s.account[3].address()
+	address, aggregator := s.deployMockAggregator(8, bigInt(0))
+	s.setFeed(s.oracle, token, address, time.Hour, 6)
+	s.assertNoPrice(token)
+
+	s.requireTx(aggregator.SetAnswer(s.signer, big.NewInt(-1), s.currentTimestamp()))
+	s.assertNoPrice(token)
+}
+
+// TestDeviation tests that a price more than maxDeviation from a dollar is refused.
+func (s *CollateralOracleSuite) TestDeviation() {
+	token := s.account[3].address()
+	address, aggregator := s.deployMockAggregator(8, shiftLeft(1, 8))
+	s.setFeed(s.oracle, token, address, time.Hour, 6)
+
+	// 3% either way is fine, but no more.
+	cases := []struct {
+		answer uint32
+		ok     bool
+	}{
+		{97000000, true},
+		{96999999, false},
+		{103000000, true},
+		{103000001, false},
+		{50000000, false},
+	}
+	for _, c := range cases {
+		s.requireTx(aggregator.SetAnswer(s.signer, bigInt(c.answer), s.currentTimestamp()))
+		if c.ok {
+			s.assertPrice(token, shiftLeft(c.answer, 10))
+		} else {
+			s.assertNoPrice(token)
+		}
+	}
+
+	// A wider deviation lets a lower price through.
+	s.requireTxWithStrictEvents(s.oracle.SetMaxDeviation(s.signer, bigInt(5000)))(
+		abi.CollateralOracleMaxDeviationChanged{OldVal: bigInt(300), NewVal: bigInt(5000)},
+	)
+	s.assertPrice(token, shiftLeft(5, 17))
+}
+
+// TestRemoveFeed tests that a token without a feed gives no price.
+func (s *CollateralOracleSuite) TestRemoveFeed() {
+	token := s.account[3].address()
+	s.assertNoPrice(token)
+
+	address, _ := s.deployMockAggregator(8, shiftLeft(1, 8))
+	s.setFeed(s.oracle, token, address, time.Hour, 6)
+	s.assertPrice(token, shiftLeft(1, 18))
+
+	s.setFeed(s.oracle, token, zeroAddress(), 0, 0)
+	s.assertNoPrice(token)
+}
+
+// TestSettersAreProtected tests that only the owner can set feeds, exchange rate sources, and
+// the max deviation, and only to sensible values.
+func (s *CollateralOracleSuite) TestSettersAreProtected() {
+	token := s.account[3].address()
+	address, _ := s.deployMockAggregator(8, shiftLeft(1, 8))
+
+	s.requireTxFails(s.oracle.SetFeed(signer(s.account[2]), token, address, seconds(time.Hour), 6))
+	s.requireTxFails(s.oracle.SetFeed(s.signer, token, address, bigInt(0), 6))
+	s.requireTxFails(s.oracle.SetMaxDeviation(signer(s.account[2]), bigInt(100)))
+	s.requireTxFails(s.oracle.SetMaxDeviation(s.signer, bigInt(10001)))
+	s.requireTxFails(s.oracle.SetExchangeRateSource(signer(s.account[2]), token, address))
+
+	// Check that nothing changed.
+	s.assertNoPrice(token)
+	maxDeviation, err := s.oracle.MaxDeviation(nil)
+	s.Require().NoError(err)
+	s.Equal("300", maxDeviation.String())
+	source, err := s.oracle.ExchangeRateSources(nil, token)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), source)
+}
+
+// TestAmountOf tests that `amountOf` inverts `value`, rounding down, through the price, the
+// token's decimals, and any exchange rate.
+func (s *CollateralOracleSuite) TestAmountOf() {
+	token := s.account[3].address()
+	address, _ := s.deployMockAggregator(8, bigInt(98000000))
+	s.setFeed(s.oracle, token, address, time.Hour, 6)
+
+	// At 98 cents, a dollar is worth 1.020408 of a 6-decimal token, rounded down.
+	amount, err := s.oracle.AmountOf(nil, token, shiftLeft(1, 18))
+	s.Require().NoError(err)
+	s.Equal("1020408", amount.String())
+	value, err := s.oracle.Value(nil, token, amount)
+	s.Require().NoError(err)
+	s.True(value.Cmp(shiftLeft(1, 18)) <= 0)
+
+	// A wrapper worth 0.02 of the token takes 50 times as many.
+	cTokenAddress, tx, cToken, err := abi.DeployMockCToken(s.signer, s.node, shiftLeft(2, 16))
+	s.logParsers[cTokenAddress] = cToken
+	s.requireTx(tx, err)
+	s.setFeed(s.oracle, cTokenAddress, address, time.Hour, 6)
+	s.requireTx(s.oracle.SetExchangeRateSource(s.signer, cTokenAddress, cTokenAddress))
+	amount, err = s.oracle.AmountOf(nil, cTokenAddress, shiftLeft(1, 18))
+	s.Require().NoError(err)
+	s.Equal("51020400", amount.String())
+
+	// No price, no amount.
+	s.requireTx(s.oracle.SetMaxDeviation(s.signer, bigInt(100)))
+	_, err = s.oracle.AmountOf(nil, token, shiftLeft(1, 18))
+	s.Error(err)
+}
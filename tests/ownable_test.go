@@ -3,7 +3,9 @@
 package tests
 
 import (
+	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/suite"
@@ -249,3 +251,124 @@ func (s *OwnableSuite) TestUseCases() {
 	s.requireTxFails(s.ownable.NominateNewOwner(s.signer, s.owner.address()))
 	s.requireTxFails(s.ownable.NominateNewOwner(signer(firstOwner), firstOwner.address()))
 }
+
+// nominationPeriod is the default time for which a nomination stands.
+const nominationPeriod = 30 * 24 * time.Hour
+
+func (s *OwnableSuite) assertNominatedOwner(expected common.Address) {
+	nominee, err := s.ownable.NominatedOwner(nil)
+	s.Require().NoError(err)
+	s.Equal(expected, nominee)
+}
+
+// TestNominationDeadline tests that a nomination's deadline is the nomination period after it.
+func (s *OwnableSuite) TestNominationDeadline() {
+	period, err := s.ownable.NominationPeriod(nil)
+	s.Require().NoError(err)
+	s.Equal(bigInt(uint32(nominationPeriod/time.Second)).String(), period.String())
+
+	before := s.currentTimestamp()
+	s.requireTx(s.ownable.NominateNewOwner(s.signer, s.account[1].address()))
+	deadline, err := s.ownable.NominationDeadline(nil)
+	s.Require().NoError(err)
+	nominatedAt := new(big.Int).Sub(deadline, period)
+	s.True(nominatedAt.Cmp(before) >= 0 && nominatedAt.Cmp(s.currentTimestamp()) <= 0)
+}
+
+// TestNominationExpires tests that a nomination can't be accepted after its deadline.
+func (s *OwnableSuite) TestNominationExpires() {
+	newOwner := s.account[1]
+	s.requireTx(s.ownable.NominateNewOwner(s.signer, newOwner.address()))
+
+	// Nothing to expire yet.
+	s.requireTxFails(s.ownable.ExpireNomination(signer(s.account[2])))
+
+	s.Require().NoError(s.node.(backend).AdjustTime(nominationPeriod + time.Hour))
+	s.assertNominatedOwner(zeroAddress())
+	s.requireTxFails(s.ownable.AcceptOwnership(signer(newOwner)))
+
+	// Anyone can clear the expired nomination, once.
+	s.requireTxWithStrictEvents(s.ownable.ExpireNomination(signer(s.account[2])))(
+		abi.BasicOwnableNominationExpired{Nominee: newOwner.address()},
+	)
+	s.requireTxFails(s.ownable.ExpireNomination(signer(s.account[2])))
+	s.requireTxFails(s.ownable.AcceptOwnership(signer(newOwner)))
+
+	ownerAddress, err := s.ownable.Owner(nil)
+	s.Require().NoError(err)
+	s.Equal(s.owner.address(), ownerAddress)
+
+	// The owner can nominate again.
+	s.requireTx(s.ownable.NominateNewOwner(s.signer, newOwner.address()))
+	s.assertNominatedOwner(newOwner.address())
+	s.requireTxWithStrictEvents(s.ownable.AcceptOwnership(signer(newOwner)))(
+		abi.BasicOwnableOwnershipTransferred{
+			PreviousOwner: s.owner.address(), NewOwner: newOwner.address(),
+		},
+	)
+}
+
+// TestAcceptNominationBeforeDeadline tests that a nomination stands until its deadline, and
+// that an accepted one doesn't expire.
+func (s *OwnableSuite) TestAcceptNominationBeforeDeadline() {
+	newOwner := s.account[1]
+	s.requireTx(s.ownable.NominateNewOwner(s.signer, newOwner.address()))
+
+	s.Require().NoError(s.node.(backend).AdjustTime(nominationPeriod - time.Hour))
+	s.assertNominatedOwner(newOwner.address())
+	s.requireTxWithStrictEvents(s.ownable.AcceptOwnership(signer(newOwner)))(
+		abi.BasicOwnableOwnershipTransferred{
+			PreviousOwner: s.owner.address(), NewOwner: newOwner.address(),
+		},
+	)
+
+	s.Require().NoError(s.node.(backend).AdjustTime(2 * time.Hour))
+	s.requireTxFails(s.ownable.ExpireNomination(signer(s.account[2])))
+}
+
+// TestRenominationResetsDeadline tests that nominating again starts a new nomination period.
+func (s *OwnableSuite) TestRenominationResetsDeadline() {
+	newOwner := s.account[1]
+	s.requireTx(s.ownable.NominateNewOwner(s.signer, newOwner.address()))
+	s.Require().NoError(s.node.(backend).AdjustTime(nominationPeriod * 2 / 3))
+	s.requireTx(s.ownable.NominateNewOwner(s.signer, newOwner.address()))
+	s.Require().NoError(s.node.(backend).AdjustTime(nominationPeriod * 2 / 3))
+
+	s.requireTx(s.ownable.AcceptOwnership(signer(newOwner)))
+	ownerAddress, err := s.ownable.Owner(nil)
+	s.Require().NoError(err)
+	s.Equal(newOwner.address(), ownerAddress)
+}
+
+// TestChangeNominationPeriod unit tests the changeNominationPeriod function.
+func (s *OwnableSuite) TestChangeNominationPeriod() {
+	hour := bigInt(3600)
+	s.requireTxWithStrictEvents(s.ownable.ChangeNominationPeriod(s.signer, hour))(
+		abi.BasicOwnableNominationPeriodChanged{NewPeriod: hour},
+	)
+	period, err := s.ownable.NominationPeriod(nil)
+	s.Require().NoError(err)
+	s.Equal(hour.String(), period.String())
+
+	// Later nominations expire after the new period.
+	newOwner := s.account[1]
+	s.requireTx(s.ownable.NominateNewOwner(s.signer, newOwner.address()))
+	s.Require().NoError(s.node.(backend).AdjustTime(2 * time.Hour))
+	s.requireTxFails(s.ownable.AcceptOwnership(signer(newOwner)))
+}
+
+// TestChangeNominationPeriodNegativeCases makes sure changeNominationPeriod reverts when it is
+// supposed to.
+func (s *OwnableSuite) TestChangeNominationPeriodNegativeCases() {
+	s.requireTxFails(s.ownable.ChangeNominationPeriod(signer(s.account[1]), bigInt(3600)))
+	s.requireTxFails(s.ownable.ChangeNominationPeriod(s.signer, bigInt(0)))
+	s.requireTxFails(s.ownable.ChangeNominationPeriod(s.signer, bigInt(366*24*60*60)))
+
+	// Nor can a nominee change it.
+	s.requireTx(s.ownable.NominateNewOwner(s.signer, s.account[1].address()))
+	s.requireTxFails(s.ownable.ChangeNominationPeriod(signer(s.account[1]), bigInt(3600)))
+
+	period, err := s.ownable.NominationPeriod(nil)
+	s.Require().NoError(err)
+	s.Equal(bigInt(uint32(nominationPeriod/time.Second)).String(), period.String())
+}
@@ -0,0 +1,198 @@
+// +build all
+
+package tests
+
+import (
+	"fmt"
+	"math/big"
+	"os/exec"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/soltools"
+)
+
+func TestCollateralRegistry(t *testing.T) {
+	suite.Run(t, new(CollateralRegistrySuite))
+}
+
+type CollateralRegistrySuite struct {
+	TestSuite
+
+	registry        *abi.CollateralRegistry
+	registryAddress common.Address
+}
+
+var (
+	// Compile-time check that CollateralRegistrySuite implements the interfaces we think it does.
+	// If it does not implement these interfaces, then the corresponding setup and teardown
+	// functions will not actually run.
+	_ suite.BeforeTest       = &CollateralRegistrySuite{}
+	_ suite.SetupAllSuite    = &CollateralRegistrySuite{}
+	_ suite.TearDownAllSuite = &CollateralRegistrySuite{}
+)
+
+// SetupSuite runs once, before all of the tests in the suite.
+func (s *CollateralRegistrySuite) SetupSuite() {
+	s.setup()
+}
+
+// TearDownSuite runs once, after all of the tests in the suite.
+func (s *CollateralRegistrySuite) TearDownSuite() {
+	if coverageEnabled {
+		// Write coverage profile to disk.
+		s.Assert().NoError(s.node.(*soltools.Backend).WriteCoverage())
+
+		// Close the node.js process.
+		s.Assert().NoError(s.node.(*soltools.Backend).Close())
+
+		// Process coverage profile into an HTML report.
+		if out, err := exec.Command("npx", "istanbul", "report", "html").CombinedOutput(); err != nil {
+			fmt.Println()
+			fmt.Println("I generated coverage information in coverage/coverage.json.")
+			fmt.Println("I tried to process it with `istanbul` to turn it into a readable report, but failed.")
+			fmt.Println("The error I got when running istanbul was:", err)
+			fmt.Println("Istanbul's output was:\n" + string(out))
+		}
+	}
+}
+
+// BeforeTest runs before each test in the suite.
+func (s *CollateralRegistrySuite) BeforeTest(suiteName, testName string) {
+	s.owner = s.account[0]
+	s.logParsers = map[common.Address]logParser{}
+	s.registryAddress, s.registry = s.deployCollateralRegistry()
+}
+
+// deployCollateralRegistry deploys a CollateralRegistry owned by s.owner.
+func (s *TestSuite) deployCollateralRegistry() (common.Address, *abi.CollateralRegistry) {
+	address, tx, registry, err := abi.DeployCollateralRegistry(s.signer, s.node)
+	s.logParsers[address] = registry
+	s.requireTxWithStrictEvents(tx, err)(
+		abi.CollateralRegistryOwnershipTransferred{PreviousOwner: zeroAddress(), NewOwner: s.owner.address()},
+	)
+	return address, registry
+}
+
+// approveCollateral approves `token` in `registry`, and checks the event.
+func (s *TestSuite) approveCollateral(
+	registry *abi.CollateralRegistry, token common.Address, decimals uint8, feed common.Address, maxWeight *big.Int,
+) {
+	s.requireTxWithStrictEvents(registry.Approve(s.signer, token, decimals, feed, maxWeight))(
+		abi.CollateralRegistryCollateralApproved{Token: token, Decimals: decimals, Feed: feed, Cap: maxWeight},
+	)
+}
+
+// assertCollateral asserts that the registry holds `token` as approved with the given metadata.
+func (s *CollateralRegistrySuite) assertCollateral(
+	token common.Address, decimals uint8, feed common.Address, maxWeight *big.Int,
+) {
+	c, err := s.registry.Collaterals(nil, token)
+	s.Require().NoError(err)
+	s.True(c.Approved)
+	s.Equal(decimals, c.Decimals)
+	s.Equal(feed, c.Feed)
+	s.Equal(maxWeight.String(), c.Cap.String())
+
+	approved, err := s.registry.IsApproved(nil, token)
+	s.Require().NoError(err)
+	s.True(approved)
+	foundCap, err := s.registry.CapOf(nil, token)
+	s.Require().NoError(err)
+	s.Equal(maxWeight.String(), foundCap.String())
+}
+
+// assertTokens asserts that the registry's token list is exactly `tokens`, in order.
+func (s *CollateralRegistrySuite) assertTokens(tokens ...common.Address) {
+	length, err := s.registry.TokensLength(nil)
+	s.Require().NoError(err)
+	s.Require().Equal(fmt.Sprint(len(tokens)), length.String())
+	for i, token := range tokens {
+		found, err := s.registry.Tokens(nil, bigInt(uint32(i)))
+		s.Require().NoError(err)
+		s.Equal(token, found)
+	}
+}
+
+// TestConstructor tests that the registry starts empty, owned by its deployer.
+func (s *CollateralRegistrySuite) TestConstructor() {
+	owner, err := s.registry.Owner(nil)
+	s.Require().NoError(err)
+	s.Equal(s.owner.address(), owner)
+	s.assertTokens()
+}
+
+// TestApprove tests that the owner can approve tokens, and update their metadata by approving
+// them again.
+func (s *CollateralRegistrySuite) TestApprove() {
+	token0, token1 := s.account[2].address(), s.account[3].address()
+	feed := s.account[4].address()
+
+	s.approveCollateral(s.registry, token0, 18, feed, shiftLeft(5, 35))
+	s.approveCollateral(s.registry, token1, 6, zeroAddress(), bigInt(0))
+	s.assertCollateral(token0, 18, feed, shiftLeft(5, 35))
+	s.assertCollateral(token1, 6, zeroAddress(), bigInt(0))
+	s.assertTokens(token0, token1)
+
+	// Approving again updates the metadata, without listing the token twice.
+	s.approveCollateral(s.registry, token0, 8, zeroAddress(), shiftLeft(3, 25))
+	s.assertCollateral(token0, 8, zeroAddress(), shiftLeft(3, 25))
+	s.assertTokens(token0, token1)
+
+	// Other tokens aren't approved.
+	approved, err := s.registry.IsApproved(nil, s.account[5].address())
+	s.Require().NoError(err)
+	s.False(approved)
+}
+
+// TestApproveIsProtected tests that `approve` can only be called by the owner, and never for
+// the zero address.
+func (s *CollateralRegistrySuite) TestApproveIsProtected() {
+	token := s.account[2].address()
+	s.requireTxFails(s.registry.Approve(signer(s.account[1]), token, 18, zeroAddress(), bigInt(0)))
+	s.requireTxFails(s.registry.Approve(s.signer, zeroAddress(), 18, zeroAddress(), bigInt(0)))
+	s.assertTokens()
+}
+
+// TestRemove tests that the owner can remove tokens, and approve them again.
+func (s *CollateralRegistrySuite) TestRemove() {
+	token0, token1, token2 := s.account[2].address(), s.account[3].address(), s.account[4].address()
+	for _, token := range []common.Address{token0, token1, token2} {
+		s.approveCollateral(s.registry, token, 18, zeroAddress(), bigInt(0))
+	}
+
+	// The last token takes the place of the removed one.
+	s.requireTxWithStrictEvents(s.registry.Remove(s.signer, token0))(
+		abi.CollateralRegistryCollateralRemoved{Token: token0},
+	)
+	s.assertTokens(token2, token1)
+	c, err := s.registry.Collaterals(nil, token0)
+	s.Require().NoError(err)
+	s.False(c.Approved)
+	s.Equal(uint8(0), c.Decimals)
+
+	// A token that isn't approved can't be removed.
+	s.requireTxFails(s.registry.Remove(s.signer, token0))
+
+	// Removing the last token.
+	s.requireTxWithStrictEvents(s.registry.Remove(s.signer, token1))(
+		abi.CollateralRegistryCollateralRemoved{Token: token1},
+	)
+	s.assertTokens(token2)
+
+	// A removed token can be approved again.
+	s.approveCollateral(s.registry, token0, 6, zeroAddress(), bigInt(0))
+	s.assertCollateral(token0, 6, zeroAddress(), bigInt(0))
+	s.assertTokens(token2, token0)
+}
+
+// TestRemoveIsProtected tests that `remove` can only be called by the owner.
+func (s *CollateralRegistrySuite) TestRemoveIsProtected() {
+	token := s.account[2].address()
+	s.approveCollateral(s.registry, token, 18, zeroAddress(), bigInt(0))
+	s.requireTxFails(s.registry.Remove(signer(s.account[1]), token))
+	s.assertTokens(token)
+}
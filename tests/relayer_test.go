@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/suite"
 
 	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/ops/authorize"
 )
 
 func TestRelayer(t *testing.T) {
@@ -640,6 +641,137 @@ func (s *RelayerSuite) TestTransferFromFailsFromScammer() {
 
 }
 
+// TestPermitWithFee checks that a holder with no ether can have a relayer submit their EIP-2612
+// permit, paying the relayer a fee in RSV.
+func (s *RelayerSuite) TestPermitWithFee() {
+	relayer := s.account[4]
+	holder := s.account[1]
+	spender := s.account[2]
+	recipient := s.account[3]
+	amount := bigInt(100)
+	fee := bigInt(1)
+	chainID := s.enablePermits()
+
+	s.requireTxWithStrictEvents(s.reserve.Mint(s.signer, holder.address(), new(big.Int).Add(amount, fee)))(
+		mintingTransfer(holder.address(), new(big.Int).Add(amount, fee)),
+	)
+
+	deadline := new(big.Int).Add(s.currentTimestamp(), bigInt(3600))
+	permitSig := s.signPermit(holder, spender.address(), amount, bigInt(0), deadline, chainID)
+	nonce, err := s.relayer.Nonce(nil, holder.address())
+	s.Require().NoError(err)
+	sig, err := crypto.Sign(s.permitHash(holder.address(), spender.address(), amount, deadline, fee, nonce), holder.key)
+	s.Require().NoError(err)
+	sig = addToLastByte(sig)
+
+	s.requireTxWithStrictEvents(s.relayer.ForwardPermit(
+		signer(relayer), sig, holder.address(), spender.address(), amount, deadline, permitSig, fee,
+	))(
+		abi.ReserveTransfer{
+			From:  holder.address(),
+			To:    relayer.address(),
+			Value: fee,
+		},
+		abi.RelayerFeeTaken{
+			From:  holder.address(),
+			To:    relayer.address(),
+			Value: fee,
+		},
+		abi.ReserveApproval{
+			Owner:   holder.address(),
+			Spender: spender.address(),
+			Value:   amount,
+		},
+		abi.RelayerPermitForwarded{
+			Sig:     sig,
+			Holder:  holder.address(),
+			Spender: spender.address(),
+			Amount:  amount,
+			Fee:     fee,
+		},
+	)
+	s.assertRSVBalance(holder.address(), amount)
+	s.assertRSVBalance(relayer.address(), fee)
+	s.assertRSVAllowance(holder.address(), spender.address(), amount)
+
+	// Both nonces are used up.
+	nonce, err = s.relayer.Nonce(nil, holder.address())
+	s.Require().NoError(err)
+	s.Equal("1", nonce.String())
+	permitNonce, err := s.reserve.Nonces(nil, holder.address())
+	s.Require().NoError(err)
+	s.Equal("1", permitNonce.String())
+
+	// The spender can relay the transfer from the allowance too.
+	nonce, err = s.relayer.Nonce(nil, spender.address())
+	s.Require().NoError(err)
+	sig, err = crypto.Sign(s.transferFromHash(holder.address(), spender.address(), recipient.address(), amount, bigInt(0), nonce), spender.key)
+	s.Require().NoError(err)
+	s.requireTx(s.relayer.ForwardTransferFrom(
+		signer(relayer), addToLastByte(sig), holder.address(), spender.address(), recipient.address(), amount, bigInt(0),
+	))
+	s.assertRSVBalance(holder.address(), bigInt(0))
+	s.assertRSVBalance(recipient.address(), amount)
+	s.assertRSVAllowance(holder.address(), spender.address(), bigInt(0))
+}
+
+// TestPermitFailsWithBadSignatures checks that forwardPermit needs both of the holder's
+// signatures, each over the same permit.
+func (s *RelayerSuite) TestPermitFailsWithBadSignatures() {
+	relayer := s.account[4]
+	holder := s.account[1]
+	spender := s.account[2]
+	scammer := s.account[2] // the scammer is the spender!
+	amount := bigInt(100)
+	chainID := s.enablePermits()
+
+	deadline := new(big.Int).Add(s.currentTimestamp(), bigInt(3600))
+	nonce, err := s.relayer.Nonce(nil, holder.address())
+	s.Require().NoError(err)
+	holderSig, err := crypto.Sign(s.permitHash(holder.address(), spender.address(), amount, deadline, bigInt(0), nonce), holder.key)
+	s.Require().NoError(err)
+	holderSig = addToLastByte(holderSig)
+
+	// The scammer signs the fee authorization.
+	scammerSig, err := crypto.Sign(s.permitHash(holder.address(), spender.address(), amount, deadline, bigInt(0), nonce), scammer.key)
+	s.Require().NoError(err)
+	permitSig := s.signPermit(holder, spender.address(), amount, bigInt(0), deadline, chainID)
+	s.requireTxFails(s.relayer.ForwardPermit(
+		signer(relayer), addToLastByte(scammerSig), holder.address(), spender.address(), amount, deadline, permitSig, bigInt(0),
+	))
+
+	// The scammer signs the permit.
+	scammerPermit := s.signPermit(scammer, spender.address(), amount, bigInt(0), deadline, chainID)
+	s.requireTxFails(s.relayer.ForwardPermit(
+		signer(relayer), holderSig, holder.address(), spender.address(), amount, deadline, scammerPermit, bigInt(0),
+	))
+
+	// The permit is for more than the relayer message.
+	bigger := s.signPermit(holder, spender.address(), bigInt(1000), bigInt(0), deadline, chainID)
+	s.requireTxFails(s.relayer.ForwardPermit(
+		signer(relayer), holderSig, holder.address(), spender.address(), amount, deadline, bigger, bigInt(0),
+	))
+
+	// The permit has expired.
+	past := new(big.Int).Sub(s.currentTimestamp(), bigInt(1))
+	expiredSig, err := crypto.Sign(s.permitHash(holder.address(), spender.address(), amount, past, bigInt(0), nonce), holder.key)
+	s.Require().NoError(err)
+	s.requireTxFails(s.relayer.ForwardPermit(
+		signer(relayer), addToLastByte(expiredSig), holder.address(), spender.address(), amount, past,
+		s.signPermit(holder, spender.address(), amount, bigInt(0), past, chainID), bigInt(0),
+	))
+
+	// A truncated permit signature.
+	s.requireTxFails(s.relayer.ForwardPermit(
+		signer(relayer), holderSig, holder.address(), spender.address(), amount, deadline, permitSig[:64], bigInt(0),
+	))
+
+	s.assertRSVAllowance(holder.address(), spender.address(), bigInt(0))
+	permitNonce, err := s.reserve.Nonces(nil, holder.address())
+	s.Require().NoError(err)
+	s.Equal("0", permitNonce.String())
+}
+
 func (s *RelayerSuite) TestSetRSVProtected() {
 	scammer := s.account[1]
 
@@ -721,6 +853,53 @@ func (s *RelayerSuite) approveHash(
 	).Bytes()
 }
 
+func (s *RelayerSuite) permitHash(
+	holder common.Address,
+	spender common.Address,
+	amount *big.Int,
+	deadline *big.Int,
+	fee *big.Int,
+	nonce *big.Int,
+) []byte {
+	interimHash := crypto.Keccak256Hash(
+		s.reserveAddress.Bytes(),
+		[]byte("forwardPermit"),
+		holder.Bytes(),
+		spender.Bytes(),
+		common.LeftPadBytes(amount.Bytes(), 32),
+		common.LeftPadBytes(deadline.Bytes(), 32),
+		common.LeftPadBytes(fee.Bytes(), 32),
+		common.LeftPadBytes(nonce.Bytes(), 32),
+	)
+	return crypto.Keccak256Hash(
+		[]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%v", len(interimHash))),
+		interimHash.Bytes(),
+	).Bytes()
+}
+
+// enablePermits sets the Reserve's chain ID, so that it accepts permits, and returns it.
+func (s *RelayerSuite) enablePermits() *big.Int {
+	chainID := bigInt(1337)
+	s.requireTxWithStrictEvents(s.reserve.ChangeChainId(s.signer, chainID))(
+		abi.ReserveChainIdChanged{NewChainId: chainID},
+	)
+	return chainID
+}
+
+// signPermit returns holder's 65-byte EIP-2612 signature of a permit, as forwardPermit takes it.
+func (s *RelayerSuite) signPermit(holder account, spender common.Address, value, nonce, deadline, chainID *big.Int) []byte {
+	permit := authorize.Permit{
+		Holder:   holder.address(),
+		Spender:  spender,
+		Value:    value,
+		Nonce:    nonce,
+		Deadline: deadline,
+	}
+	sig, err := crypto.Sign(permit.Hash(authorize.Reserve(s.reserveAddress, chainID)).Bytes(), holder.key)
+	s.Require().NoError(err)
+	return addToLastByte(sig)
+}
+
 func addToLastByte(sig []byte) []byte {
 	v := []byte{27}
 	v[0] = v[0] + sig[64]
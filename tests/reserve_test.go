@@ -3,13 +3,23 @@
 package tests
 
 import (
+	"context"
+	"fmt"
 	"math/big"
+	"strings"
 	"testing"
+	"time"
 
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/ops/authorize"
+	"github.com/reserve-protocol/rsv-beta/ops/sizes"
 )
 
 func TestReserve(t *testing.T) {
@@ -119,6 +129,9 @@ func (s *ReserveSuite) BeforeTest(suiteName, testName string) {
 	s.requireTxWithStrictEvents(s.reserve.ChangeFeeRecipient(s.signer, deployerAddress))(
 		abi.ReserveFeeRecipientChanged{NewFeeRecipient: deployerAddress},
 	)
+	s.requireTxWithStrictEvents(s.reserve.ChangeFreezer(s.signer, deployerAddress))(
+		abi.ReserveFreezerChanged{NewFreezer: deployerAddress},
+	)
 }
 
 func (s *ReserveSuite) TestDeploy() {}
@@ -138,14 +151,59 @@ func (s *ReserveSuite) TestConstructor() {
 	maxSupply, err := s.reserve.MaxSupply(nil)
 	s.Require().NoError(err)
 	s.Equal(maxUint256().String(), maxSupply.String())
+	maxSupplySet, err := s.reserve.MaxSupplySet(nil)
+	s.Require().NoError(err)
+	s.False(maxSupplySet)
+
+	// `mintCap`
+	mintCap, err := s.reserve.MintCap(nil)
+	s.Require().NoError(err)
+	s.Equal(maxUint256().String(), mintCap.String())
 
 	// `paused` is tested by BeforeTest
 
+	// `emergencyRedemptionStarted`
+	started, err := s.reserve.EmergencyRedemptionStarted(nil)
+	s.Require().NoError(err)
+	s.False(started)
+
 	// `trustedTxFee`
 	trustedTxFee, err := s.reserve.TrustedTxFee(nil)
 	s.Require().NoError(err)
 	s.Equal(zeroAddress(), trustedTxFee)
 
+	// `transferHook`
+	transferHook, err := s.reserve.TransferHook(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), transferHook)
+
+	// `snapshotter` and `currentSnapshotId`: no snapshots until there is a snapshotter.
+	snapshotter, err := s.reserve.Snapshotter(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), snapshotter)
+	snapshotID, err := s.reserve.CurrentSnapshotId(nil)
+	s.Require().NoError(err)
+	s.Equal("0", snapshotID.String())
+
+	// `initialized`, and a Reserve deployed on its own has no implementation behind it.
+	initialized, err := s.reserve.Initialized(nil)
+	s.Require().NoError(err)
+	s.True(initialized)
+	implementation, err := s.reserve.Implementation(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), implementation)
+
+	// `flashMintCap`, `flashMintFee`, and `flashMinting`: no flash mints until there is a cap.
+	flashMintCap, err := s.reserve.FlashMintCap(nil)
+	s.Require().NoError(err)
+	s.Equal("0", flashMintCap.String())
+	flashMintFee, err := s.reserve.FlashMintFee(nil)
+	s.Require().NoError(err)
+	s.Equal("0", flashMintFee.String())
+	flashMinting, err := s.reserve.FlashMinting(nil)
+	s.Require().NoError(err)
+	s.False(flashMinting)
+
 	// `trustedData` cannot be read because it is internal
 }
 
@@ -234,6 +292,21 @@ func (s *ReserveSuite) TestChangePauser() {
 	s.Equal(s.account[3].address(), pauser)
 }
 
+func (s *ReserveSuite) TestChangeGuardian() {
+	guardian, err := s.reserve.Guardian(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), guardian)
+
+	// Change as owner.
+	s.requireTxWithStrictEvents(s.reserve.ChangeGuardian(s.signer, s.account[2].address()))(
+		abi.ReserveGuardianChanged{NewGuardian: s.account[2].address()},
+	)
+
+	guardian, err = s.reserve.Guardian(nil)
+	s.Require().NoError(err)
+	s.Equal(s.account[2].address(), guardian)
+}
+
 func (s *ReserveSuite) TestChangeFeeRecipient() {
 	feeRecipient, err := s.reserve.FeeRecipient(nil)
 	s.Require().NoError(err)
@@ -302,6 +375,236 @@ func (s *ReserveSuite) TestChangeMaxSupply() {
 	maxSupply, err = s.reserve.MaxSupply(nil)
 	s.Require().NoError(err)
 	s.Equal(amount, maxSupply)
+
+	// Once set, it can only be raised.
+	s.requireTxFails(s.reserve.ChangeMaxSupply(s.signer, bigInt(9)))
+	s.requireTxWithStrictEvents(s.reserve.ChangeMaxSupply(s.signer, bigInt(10)))(
+		abi.ReserveMaxSupplyChanged{NewMaxSupply: bigInt(10)},
+	)
+	s.requireTxWithStrictEvents(s.reserve.ChangeMaxSupply(s.signer, bigInt(20)))(
+		abi.ReserveMaxSupplyChanged{NewMaxSupply: bigInt(20)},
+	)
+	s.requireTxFails(s.reserve.ChangeMaxSupply(s.signer, bigInt(10)))
+
+	maxSupply, err = s.reserve.MaxSupply(nil)
+	s.Require().NoError(err)
+	s.Equal(bigInt(20), maxSupply)
+	maxSupplySet, err := s.reserve.MaxSupplySet(nil)
+	s.Require().NoError(err)
+	s.True(maxSupplySet)
+}
+
+func (s *ReserveSuite) TestChangeMaxSupplyToUnlimited() {
+	s.requireTx(s.reserve.ChangeMaxSupply(s.signer, bigInt(10)))
+
+	// Raising the cap all the way is still setting it, so it can't then be lowered.
+	s.requireTxWithStrictEvents(s.reserve.ChangeMaxSupply(s.signer, maxUint256()))(
+		abi.ReserveMaxSupplyChanged{NewMaxSupply: maxUint256()},
+	)
+	s.requireTxFails(s.reserve.ChangeMaxSupply(s.signer, bigInt(10)))
+	s.requireTxFails(s.reserve.ChangeMaxSupply(s.signer, new(big.Int).Sub(maxUint256(), bigInt(1))))
+
+	maxSupply, err := s.reserve.MaxSupply(nil)
+	s.Require().NoError(err)
+	s.Equal(maxUint256().String(), maxSupply.String())
+}
+
+func (s *ReserveSuite) TestChangeMaxSupplyFirstToUnlimited() {
+	// Even a first cap of unlimited holds the cap to only rising.
+	s.requireTx(s.reserve.ChangeMaxSupply(s.signer, maxUint256()))
+	s.requireTxFails(s.reserve.ChangeMaxSupply(s.signer, bigInt(10)))
+}
+
+func (s *ReserveSuite) TestUpgradeKeepsMaxSupplySet() {
+	s.requireTx(s.reserve.ChangeMaxSupply(s.signer, bigInt(1000)))
+
+	newKey := s.account[2]
+	newTokenAddress, tx, newToken, err := abi.DeployReserveV2(signer(newKey), s.node)
+	s.logParsers[newTokenAddress] = newToken
+	s.requireTx(tx, err)
+	s.requireTx(s.reserve.NominateNewOwner(s.signer, newTokenAddress))
+	s.requireTx(newToken.AcceptUpgrade(signer(newKey), s.reserveAddress))
+
+	// The new Reserve holds the cap to only rising too.
+	maxSupplySet, err := newToken.MaxSupplySet(nil)
+	s.Require().NoError(err)
+	s.True(maxSupplySet)
+	s.requireTxFails(newToken.ChangeMaxSupply(signer(newKey), bigInt(999)))
+	s.requireTx(newToken.ChangeMaxSupply(signer(newKey), bigInt(1001)))
+}
+
+func (s *ReserveSuite) TestChangeMaxSupplyBelowTotalSupply() {
+	s.requireTx(s.reserve.Mint(s.signer, s.account[1].address(), bigInt(100)))
+
+	// The first cap can't be set below what is already out there.
+	s.requireTxFails(s.reserve.ChangeMaxSupply(s.signer, bigInt(99)))
+	s.requireTx(s.reserve.ChangeMaxSupply(s.signer, bigInt(100)))
+	s.requireTxFails(s.reserve.Mint(s.signer, s.account[1].address(), bigInt(1)))
+}
+
+func (s *ReserveSuite) TestChangeMintCap() {
+	amount := bigInt(1000)
+	s.requireTxWithStrictEvents(s.reserve.ChangeMintCap(s.signer, amount))(
+		abi.ReserveMintCapChanged{NewMintCap: amount},
+	)
+
+	mintCap, err := s.reserve.MintCap(nil)
+	s.Require().NoError(err)
+	s.Equal(amount.String(), mintCap.String())
+	s.assertMintable(amount)
+}
+
+func (s *ReserveSuite) TestChangeFlashMintCap() {
+	amount := bigInt(1000)
+	s.requireTxWithStrictEvents(s.reserve.ChangeFlashMintCap(s.signer, amount))(
+		abi.ReserveFlashMintCapChanged{NewFlashMintCap: amount},
+	)
+
+	flashMintCap, err := s.reserve.FlashMintCap(nil)
+	s.Require().NoError(err)
+	s.Equal(amount.String(), flashMintCap.String())
+}
+
+func (s *ReserveSuite) TestChangeFlashMintFee() {
+	// Up to 10%, in BPS.
+	s.requireTxWithStrictEvents(s.reserve.ChangeFlashMintFee(s.signer, bigInt(1000)))(
+		abi.ReserveFlashMintFeeChanged{NewFlashMintFee: bigInt(1000)},
+	)
+	s.requireTxFails(s.reserve.ChangeFlashMintFee(s.signer, bigInt(1001)))
+
+	flashMintFee, err := s.reserve.FlashMintFee(nil)
+	s.Require().NoError(err)
+	s.Equal("1000", flashMintFee.String())
+}
+
+// assertMintable asserts how much can still be minted in the current mint window.
+func (s *ReserveSuite) assertMintable(expected *big.Int) {
+	mintable, err := s.reserve.MintableInWindow(nil)
+	s.Require().NoError(err)
+	s.Equal(expected.String(), mintable.String())
+}
+
+func (s *ReserveSuite) TestMintCap() {
+	recipient := s.account[1].address()
+	s.requireTx(s.reserve.ChangeMintCap(s.signer, bigInt(1000)))
+
+	// Mints within the cap add up.
+	s.requireTxWithStrictEvents(s.reserve.Mint(s.signer, recipient, bigInt(600)))(
+		mintingTransfer(recipient, bigInt(600)),
+	)
+	s.assertMintable(bigInt(400))
+	s.requireTx(s.reserve.Mint(s.signer, recipient, bigInt(400)))
+	s.assertMintable(bigInt(0))
+
+	// One more attotoken is too many.
+	s.requireTxFails(s.reserve.Mint(s.signer, recipient, bigInt(1)))
+	s.assertRSVBalance(recipient, bigInt(1000))
+	s.assertRSVTotalSupply(bigInt(1000))
+
+	// Burning doesn't give any of the window back.
+	s.requireTx(s.reserve.Approve(signer(s.account[1]), s.owner.address(), bigInt(500)))
+	s.requireTx(s.reserve.BurnFrom(s.signer, recipient, bigInt(500)))
+	s.requireTxFails(s.reserve.Mint(s.signer, recipient, bigInt(1)))
+}
+
+func (s *ReserveSuite) TestMintCapExceededInOneMint() {
+	recipient := s.account[1].address()
+	s.requireTx(s.reserve.ChangeMintCap(s.signer, bigInt(1000)))
+
+	s.requireTxFails(s.reserve.Mint(s.signer, recipient, bigInt(1001)))
+	s.assertMintable(bigInt(1000))
+	s.requireTx(s.reserve.Mint(s.signer, recipient, bigInt(1000)))
+}
+
+func (s *ReserveSuite) TestMintCapRollingWindow() {
+	recipient := s.account[1].address()
+	s.requireTx(s.reserve.ChangeMintCap(s.signer, bigInt(1000)))
+
+	// Mints are counted by the hour, so start at the top of one, far enough ahead for
+	// nextBlockAt.
+	hour := bigInt(3600)
+	start := bigInt(0).Add(s.currentTimestamp(), bigInt(60))
+	start.Add(start, hour).Sub(start, bigInt(0).Mod(start, hour))
+	at := func(seconds uint32) *big.Int {
+		return bigInt(0).Add(start, bigInt(seconds))
+	}
+
+	// Fill the cap at the very start and the very end of the hour.
+	s.nextBlockAt(at(0))
+	s.requireTx(s.reserve.Mint(s.signer, recipient, bigInt(400)))
+	s.nextBlockAt(at(3599))
+	s.requireTx(s.reserve.Mint(s.signer, recipient, bigInt(600)))
+
+	// At the top of the hour a day later, the second mint is a second over 23 hours old; with
+	// an hour still to go, the cap can't be minted again across the day's end.
+	s.nextBlockAt(at(24 * 3600))
+	s.requireTxFails(s.reserve.Mint(s.signer, recipient, bigInt(1)))
+
+	// At the last second of that hour, the second mint is exactly a day old, and the first a
+	// second short of 25 hours: both still count.
+	s.nextBlockAt(at(25*3600 - 1))
+	s.requireTxFails(s.reserve.Mint(s.signer, recipient, bigInt(1)))
+
+	// Into the next hour, both have left the window, and the whole cap is back.
+	s.nextBlockAt(at(25*3600 + 60))
+	s.requireTx(s.reserve.Mint(s.signer, recipient, bigInt(1000)))
+	s.assertMintable(bigInt(0))
+
+	s.assertRSVBalance(recipient, bigInt(2000))
+}
+
+func (s *ReserveSuite) TestMintCapLoweredMidWindow() {
+	recipient := s.account[1].address()
+	s.requireTx(s.reserve.ChangeMintCap(s.signer, bigInt(1000)))
+	s.requireTx(s.reserve.Mint(s.signer, recipient, bigInt(600)))
+
+	// Lowering the cap below what was already minted stops minting for the rest of the window.
+	s.requireTx(s.reserve.ChangeMintCap(s.signer, bigInt(500)))
+	s.assertMintable(bigInt(0))
+	s.requireTxFails(s.reserve.Mint(s.signer, recipient, bigInt(1)))
+
+	// Raising it lets the rest through.
+	s.requireTx(s.reserve.ChangeMintCap(s.signer, bigInt(800)))
+	s.assertMintable(bigInt(200))
+	s.requireTx(s.reserve.Mint(s.signer, recipient, bigInt(200)))
+}
+
+func (s *ReserveSuite) TestMaxSupply() {
+	recipient := s.account[1].address()
+	s.requireTx(s.reserve.ChangeMaxSupply(s.signer, bigInt(1000)))
+
+	// Mints may take the supply up to the max supply exactly.
+	s.requireTx(s.reserve.Mint(s.signer, recipient, bigInt(600)))
+	s.requireTxWithStrictEvents(s.reserve.Mint(s.signer, recipient, bigInt(400)))(
+		mintingTransfer(recipient, bigInt(400)),
+	)
+	s.assertRSVTotalSupply(bigInt(1000))
+
+	// One more attotoken is too many.
+	s.requireTxFails(s.reserve.Mint(s.signer, recipient, bigInt(1)))
+	s.assertRSVTotalSupply(bigInt(1000))
+
+	// Burning makes room again.
+	s.requireTx(s.reserve.Approve(signer(s.account[1]), s.owner.address(), bigInt(100)))
+	s.requireTx(s.reserve.BurnFrom(s.signer, recipient, bigInt(100)))
+	s.requireTx(s.reserve.Mint(s.signer, recipient, bigInt(100)))
+	s.assertRSVTotalSupply(bigInt(1000))
+}
+
+func (s *ReserveSuite) TestMaxSupplyExceededInOneMint() {
+	recipient := s.account[1].address()
+	s.requireTx(s.reserve.ChangeMaxSupply(s.signer, bigInt(1000)))
+	s.requireTx(s.reserve.Mint(s.signer, recipient, bigInt(600)))
+
+	// A mint that would cross the max supply fails whole; none of it is minted.
+	s.requireTxFails(s.reserve.Mint(s.signer, recipient, bigInt(401)))
+	s.assertRSVBalance(recipient, bigInt(600))
+	s.assertRSVTotalSupply(bigInt(600))
+
+	// Raising the max supply lets it through.
+	s.requireTx(s.reserve.ChangeMaxSupply(s.signer, bigInt(1001)))
+	s.requireTx(s.reserve.Mint(s.signer, recipient, bigInt(401)))
+	s.assertRSVTotalSupply(bigInt(1001))
 }
 
 func (s *ReserveSuite) TestTransfer() {
@@ -330,6 +633,158 @@ func (s *ReserveSuite) TestTransfer() {
 	s.assertRSVTotalSupply(amount)
 }
 
+func (s *ReserveSuite) TestTransferBatch() {
+	sender := s.account[1]
+	recipients := []common.Address{s.account[2].address(), s.account[3].address(), s.account[2].address()}
+	amounts := []*big.Int{bigInt(10), bigInt(20), bigInt(30)}
+	s.requireTx(s.reserve.Mint(s.signer, sender.address(), bigInt(100)))
+
+	// Each transfer is its own Transfer event, and a recipient may be paid more than once.
+	s.requireTxWithStrictEvents(s.reserve.TransferBatch(signer(sender), recipients, amounts))(
+		abi.ReserveTransfer{From: sender.address(), To: recipients[0], Value: amounts[0]},
+		abi.ReserveTransfer{From: sender.address(), To: recipients[1], Value: amounts[1]},
+		abi.ReserveTransfer{From: sender.address(), To: recipients[2], Value: amounts[2]},
+	)
+	s.assertRSVBalance(sender.address(), bigInt(40))
+	s.assertRSVBalance(s.account[2].address(), bigInt(40))
+	s.assertRSVBalance(s.account[3].address(), bigInt(20))
+	s.assertRSVTotalSupply(bigInt(100))
+
+	// An empty batch does nothing.
+	s.requireTxWithStrictEvents(s.reserve.TransferBatch(signer(sender), nil, nil))()
+}
+
+// TestTransferBatchIsAtomic tests that if any transfer in a batch fails, none are made.
+func (s *ReserveSuite) TestTransferBatchIsAtomic() {
+	sender := s.account[1]
+	s.requireTx(s.reserve.Mint(s.signer, sender.address(), bigInt(100)))
+	ok := s.account[2].address()
+
+	for _, bad := range []struct {
+		recipient common.Address
+		amount    *big.Int
+	}{
+		{zeroAddress(), bigInt(1)},
+		{s.account[3].address(), bigInt(91)}, // more than the sender has left
+	} {
+		s.requireTxFails(s.reserve.TransferBatch(signer(sender),
+			[]common.Address{ok, bad.recipient, ok},
+			[]*big.Int{bigInt(10), bad.amount, bigInt(10)},
+		))
+	}
+
+	// A frozen recipient fails the whole batch too.
+	s.requireTx(s.reserve.Freeze(s.signer, s.account[3].address()))
+	s.requireTxFails(s.reserve.TransferBatch(signer(sender),
+		[]common.Address{ok, s.account[3].address()}, []*big.Int{bigInt(10), bigInt(10)},
+	))
+
+	// As do lists of different lengths.
+	s.requireTxFails(s.reserve.TransferBatch(signer(sender), []common.Address{ok, ok}, []*big.Int{bigInt(10)}))
+	s.requireTxFails(s.reserve.TransferBatch(signer(sender), []common.Address{ok}, []*big.Int{bigInt(10), bigInt(10)}))
+
+	s.assertRSVBalance(sender.address(), bigInt(100))
+	s.assertRSVBalance(ok, bigInt(0))
+}
+
+func (s *ReserveSuite) TestTransferBatchFailsWhenPausedOrFrozen() {
+	sender := s.account[1]
+	s.requireTx(s.reserve.Mint(s.signer, sender.address(), bigInt(100)))
+	recipients, amounts := []common.Address{s.account[2].address()}, []*big.Int{bigInt(10)}
+
+	s.requireTx(s.reserve.Pause(s.signer))
+	s.requireTxFails(s.reserve.TransferBatch(signer(sender), recipients, amounts))
+	s.requireTx(s.reserve.Unpause(s.signer))
+
+	s.requireTx(s.reserve.Freeze(s.signer, sender.address()))
+	s.requireTxFails(s.reserve.TransferBatch(signer(sender), recipients, amounts))
+	s.requireTx(s.reserve.Unfreeze(s.signer, sender.address()))
+
+	s.requireTx(s.reserve.TransferBatch(signer(sender), recipients, amounts))
+	s.assertRSVBalance(s.account[2].address(), bigInt(10))
+}
+
+// TestTransferBatchGas compares the gas of a batch of transfers with that of as many single
+// transfers, each to a new holder, and logs and records both.
+func (s *ReserveSuite) TestTransferBatchGas() {
+	sender := s.account[1]
+	s.requireTx(s.reserve.Mint(s.signer, sender.address(), shiftLeft(1, 18)))
+
+	next := uint32(1000)
+	newHolders := func(n int) []common.Address {
+		holders := make([]common.Address, n)
+		for i := range holders {
+			holders[i] = common.BigToAddress(bigInt(next))
+			next++
+		}
+		return holders
+	}
+	for _, n := range []int{1, 10, 50, 100} {
+		var singles uint64
+		for _, to := range newHolders(n) {
+			singles += s.recordGas("Reserve.transfer (new holder)",
+				s.reserve.Transfer(signer(sender), to, bigInt(1)))
+		}
+		amounts := make([]*big.Int, n)
+		for i := range amounts {
+			amounts[i] = bigInt(1)
+		}
+		batch := s.recordGas(fmt.Sprintf("Reserve.transferBatch (%v new holders)", n),
+			s.reserve.TransferBatch(signer(sender), newHolders(n), amounts))
+
+		s.T().Logf("%3v transfers: %8v gas in one batch, %8v gas singly (%v%%)",
+			n, batch, singles, batch*100/singles)
+		if n > 1 {
+			s.Less(batch, singles)
+		}
+	}
+}
+
+// TestDeploymentGas records what deploying the Reserve, a ReserveProxy in front of it, and the
+// contracts built on it costs, for rsvsize. The Reserve's includes its eternal storage, which it
+// deploys.
+func (s *ReserveSuite) TestDeploymentGas() {
+	implementation, tx, _, err := abi.DeployReserve(s.signer, s.node)
+	s.recordGas(sizes.DeploymentBenchmark("Reserve"), tx, err)
+	_, tx, _, err = abi.DeployReserveProxy(s.signer, s.node, implementation, s.reserveCalldata("initialize"))
+	s.recordGas(sizes.DeploymentBenchmark("ReserveProxy"), tx, err)
+
+	_, tx, _, err = abi.DeployRelayer(s.signer, s.node, s.reserveAddress)
+	s.recordGas(sizes.DeploymentBenchmark("Relayer"), tx, err)
+	_, tx, _, err = abi.DeployRSVVotes(s.signer, s.node, s.reserveAddress)
+	s.recordGas(sizes.DeploymentBenchmark("RSVVotes"), tx, err)
+	_, tx, _, err = abi.DeployBridgeAdapter(
+		s.signer, s.node, s.reserveAddress, s.account[3].address(), s.account[4].address(),
+	)
+	s.recordGas(sizes.DeploymentBenchmark("BridgeAdapter"), tx, err)
+	_, tx, _, err = abi.DeployOFTAdapter(s.signer, s.node, s.reserveAddress, s.account[4].address(), true)
+	s.recordGas(sizes.DeploymentBenchmark("OFTAdapter"), tx, err)
+	guardians := []common.Address{s.account[1].address(), s.account[2].address(), s.account[3].address()}
+	_, tx, _, err = abi.DeployGuardianMultisig(
+		s.signer, s.node, s.reserveAddress, multisigChainID, guardians, bigInt(2),
+	)
+	s.recordGas(sizes.DeploymentBenchmark("GuardianMultisig"), tx, err)
+}
+
+// TestERC20Gas records what minting, burning, and the ERC-20 functions other than transfer cost.
+func (s *ReserveSuite) TestERC20Gas() {
+	holder, spender := s.account[1], s.account[2]
+	s.recordGas("Reserve.mint (new holder)", s.reserve.Mint(s.signer, holder.address(), bigInt(1000)))
+	s.recordGas("Reserve.mint", s.reserve.Mint(s.signer, holder.address(), bigInt(1000)))
+
+	s.recordGas("Reserve.approve", s.reserve.Approve(signer(holder), spender.address(), bigInt(500)))
+	s.recordGas("Reserve.increaseAllowance",
+		s.reserve.IncreaseAllowance(signer(holder), spender.address(), bigInt(500)))
+	s.recordGas("Reserve.transferFrom (new holder)",
+		s.reserve.TransferFrom(signer(spender), holder.address(), spender.address(), bigInt(100)))
+	s.recordGas("Reserve.transferFrom",
+		s.reserve.TransferFrom(signer(spender), holder.address(), spender.address(), bigInt(100)))
+
+	s.requireTx(s.reserve.Approve(signer(holder), s.owner.address(), bigInt(100)))
+	s.recordGas("Reserve.burnFrom", s.reserve.BurnFrom(s.signer, holder.address(), bigInt(100)))
+	s.assertRSVTotalSupply(bigInt(1900))
+}
+
 func (s *ReserveSuite) TestTransferExceedsFunds() {
 	sender := s.account[1]
 	recipient := common.BigToAddress(bigInt(1))
@@ -766,6 +1221,211 @@ func (s *ReserveSuite) TestChangePauserFailsForNonPauser() {
 
 //////////////////////
 
+func (s *ReserveSuite) TestChangeGuardianFailsForNonOwner() {
+	s.requireTx(s.reserve.ChangeGuardian(s.signer, s.account[2].address()))
+
+	// Not even the guardian can hand its role on.
+	s.requireTxFails(s.reserve.ChangeGuardian(signer(s.account[2]), s.account[1].address()))
+	s.requireTxFails(s.reserve.ChangeGuardian(signer(s.account[1]), s.account[1].address()))
+
+	// Nor can the pauser.
+	s.requireTx(s.reserve.ChangePauser(s.signer, s.account[3].address()))
+	s.requireTxFails(s.reserve.ChangeGuardian(signer(s.account[3]), s.account[3].address()))
+
+	guardian, err := s.reserve.Guardian(nil)
+	s.Require().NoError(err)
+	s.Equal(s.account[2].address(), guardian)
+}
+
+func (s *ReserveSuite) TestGuardianPauses() {
+	guardian := s.account[2]
+	s.requireTx(s.reserve.ChangePauser(s.signer, s.account[3].address()))
+	s.requireTx(s.reserve.ChangeGuardian(s.signer, guardian.address()))
+	s.requireTx(s.reserve.Mint(s.signer, s.account[1].address(), bigInt(100)))
+
+	s.requireTxWithStrictEvents(s.reserve.Pause(signer(guardian)))(
+		abi.ReservePaused{Account: guardian.address()},
+	)
+	paused, err := s.reserve.Paused(nil)
+	s.Require().NoError(err)
+	s.True(paused)
+	s.requireTxFails(s.reserve.Transfer(signer(s.account[1]), s.account[4].address(), bigInt(1)))
+
+	// Neither the guardian nor the owner, which isn't the pauser, can unpause.
+	s.requireTxFails(s.reserve.Unpause(signer(guardian)))
+	s.requireTxFails(s.reserve.Unpause(s.signer))
+
+	// The pauser unpauses, and can still pause too.
+	s.requireTxWithStrictEvents(s.reserve.Unpause(signer(s.account[3])))(
+		abi.ReserveUnpaused{Account: s.account[3].address()},
+	)
+	s.requireTxWithStrictEvents(s.reserve.Pause(signer(s.account[3])))(
+		abi.ReservePaused{Account: s.account[3].address()},
+	)
+
+	// Pausing again while paused is harmless.
+	s.requireTx(s.reserve.Pause(signer(guardian)))
+}
+
+func (s *ReserveSuite) TestGuardianCannotChangeState() {
+	guardian := s.account[2]
+	s.requireTx(s.reserve.ChangeGuardian(s.signer, guardian.address()))
+	g := signer(guardian)
+
+	s.requireTxFails(s.reserve.Mint(g, guardian.address(), bigInt(1)))
+	s.requireTxFails(s.reserve.ChangeMinter(g, guardian.address()))
+	s.requireTxFails(s.reserve.ChangePauser(g, guardian.address()))
+	s.requireTxFails(s.reserve.ChangeFeeRecipient(g, guardian.address()))
+	s.requireTxFails(s.reserve.ChangeRelayer(g, guardian.address()))
+	s.requireTxFails(s.reserve.ChangeForwarder(g, guardian.address()))
+	s.requireTxFails(s.reserve.ChangeTransferHook(g, guardian.address()))
+	s.requireTxFails(s.reserve.ChangeMaxSupply(g, bigInt(1)))
+	s.requireTxFails(s.reserve.ChangeMintCap(g, bigInt(1)))
+	s.requireTxFails(s.reserve.ChangeFlashMintCap(g, bigInt(1)))
+	s.requireTxFails(s.reserve.ChangeFlashMintFee(g, bigInt(1)))
+	s.requireTxFails(s.reserve.ChangeChainId(g, bigInt(1)))
+	s.requireTxFails(s.reserve.Sweep(g, s.reserveAddress, bigInt(0), guardian.address()))
+
+	s.requireTx(s.reserve.Pause(g))
+	s.requireTxFails(s.reserve.TransferEternalStorage(g, guardian.address()))
+	s.requireTxFails(s.reserve.Unpause(g))
+
+	pauser, err := s.reserve.Pauser(nil)
+	s.Require().NoError(err)
+	s.Equal(s.owner.address(), pauser)
+	s.assertRSVTotalSupply(bigInt(0))
+}
+
+func (s *ReserveSuite) TestRemovedGuardianCannotPause() {
+	s.requireTx(s.reserve.ChangeGuardian(s.signer, s.account[2].address()))
+	s.requireTxWithStrictEvents(s.reserve.ChangeGuardian(s.signer, zeroAddress()))(
+		abi.ReserveGuardianChanged{NewGuardian: zeroAddress()},
+	)
+	s.requireTxFails(s.reserve.Pause(signer(s.account[2])))
+}
+
+func (s *ReserveSuite) assertEmergencyRedemptionOpen(expected bool) {
+	open, err := s.reserve.EmergencyRedemptionOpen(nil)
+	s.Require().NoError(err)
+	s.Equal(expected, open)
+}
+
+// TestEmergencyRedemptionDelay tests that emergency redemption opens only once a pause has lasted
+// EMERGENCY_REDEMPTION_DELAY, and closes on unpausing.
+func (s *ReserveSuite) TestEmergencyRedemptionDelay() {
+	delay, err := s.reserve.EMERGENCYREDEMPTIONDELAY(nil)
+	s.Require().NoError(err)
+	s.Equal(seconds(30*24*time.Hour).String(), delay.String())
+
+	// Time unpaused doesn't count toward the delay.
+	s.Require().NoError(s.node.(backend).AdjustTime(31 * 24 * time.Hour))
+	s.assertEmergencyRedemptionOpen(false)
+	s.requireTx(s.reserve.Pause(s.signer))
+	s.assertEmergencyRedemptionOpen(false)
+
+	s.Require().NoError(s.node.(backend).AdjustTime(30*24*time.Hour - time.Minute))
+	s.assertEmergencyRedemptionOpen(false)
+	s.Require().NoError(s.node.(backend).AdjustTime(time.Minute))
+	s.assertEmergencyRedemptionOpen(true)
+
+	// Unpausing closes it, and the next pause starts the delay over.
+	s.requireTx(s.reserve.Unpause(s.signer))
+	s.assertEmergencyRedemptionOpen(false)
+	s.requireTx(s.reserve.Pause(s.signer))
+	s.assertEmergencyRedemptionOpen(false)
+}
+
+// TestEmergencyRedemptionDelayIgnoresRepause tests that pausing again while paused doesn't put
+// emergency redemption off.
+func (s *ReserveSuite) TestEmergencyRedemptionDelayIgnoresRepause() {
+	guardian := s.account[2]
+	s.requireTx(s.reserve.ChangeGuardian(s.signer, guardian.address()))
+
+	s.requireTx(s.reserve.Pause(s.signer))
+	pausedAt, err := s.reserve.PausedAt(nil)
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.node.(backend).AdjustTime(20 * 24 * time.Hour))
+	s.requireTx(s.reserve.Pause(signer(guardian)))
+	found, err := s.reserve.PausedAt(nil)
+	s.Require().NoError(err)
+	s.Equal(pausedAt.String(), found.String())
+
+	s.Require().NoError(s.node.(backend).AdjustTime(10 * 24 * time.Hour))
+	s.assertEmergencyRedemptionOpen(true)
+}
+
+// TestStartEmergencyRedemption tests that the owner can open emergency redemption early, but
+// only while paused, and only until the next unpause.
+func (s *ReserveSuite) TestStartEmergencyRedemption() {
+	// Not while unpaused.
+	s.requireTxFails(s.reserve.StartEmergencyRedemption(s.signer))
+	s.assertEmergencyRedemptionOpen(false)
+
+	s.requireTx(s.reserve.Pause(s.signer))
+	s.requireTxWithStrictEvents(s.reserve.StartEmergencyRedemption(s.signer))(
+		abi.ReserveEmergencyRedemptionStarted{Account: s.owner.address()},
+	)
+	s.assertEmergencyRedemptionOpen(true)
+
+	s.requireTx(s.reserve.Unpause(s.signer))
+	started, err := s.reserve.EmergencyRedemptionStarted(nil)
+	s.Require().NoError(err)
+	s.False(started)
+	s.assertEmergencyRedemptionOpen(false)
+	s.requireTx(s.reserve.Pause(s.signer))
+	s.assertEmergencyRedemptionOpen(false)
+}
+
+// TestStartEmergencyRedemptionFailsForNonOwner tests that neither other roles nor anyone else
+// can open emergency redemption early.
+func (s *ReserveSuite) TestStartEmergencyRedemptionFailsForNonOwner() {
+	pauser := s.account[3]
+	guardian := s.account[2]
+	s.requireTx(s.reserve.ChangePauser(s.signer, pauser.address()))
+	s.requireTx(s.reserve.ChangeGuardian(s.signer, guardian.address()))
+	s.requireTx(s.reserve.Pause(signer(guardian)))
+
+	s.requireTxFails(s.reserve.StartEmergencyRedemption(signer(pauser)))
+	s.requireTxFails(s.reserve.StartEmergencyRedemption(signer(guardian)))
+	s.requireTxFails(s.reserve.StartEmergencyRedemption(signer(s.account[4])))
+	s.assertEmergencyRedemptionOpen(false)
+}
+
+// TestEmergencyBurnFrom tests that the minter can burn as burnFrom does while emergency
+// redemption is open, and only then.
+func (s *ReserveSuite) TestEmergencyBurnFrom() {
+	holder := s.account[1]
+	amount := bigInt(100)
+	s.requireTx(s.reserve.Mint(s.signer, holder.address(), amount))
+	s.requireTx(s.reserve.Approve(signer(holder), s.owner.address(), amount))
+
+	// Not while unpaused, nor while paused before emergency redemption opens.
+	s.requireTxFails(s.reserve.EmergencyBurnFrom(s.signer, holder.address(), bigInt(10)))
+	s.requireTx(s.reserve.Pause(s.signer))
+	s.requireTxFails(s.reserve.EmergencyBurnFrom(s.signer, holder.address(), bigInt(10)))
+	s.assertRSVBalance(holder.address(), amount)
+
+	s.requireTx(s.reserve.StartEmergencyRedemption(s.signer))
+	s.requireTxWithStrictEvents(s.reserve.EmergencyBurnFrom(s.signer, holder.address(), bigInt(10)))(
+		abi.ReserveTransfer{From: holder.address(), To: zeroAddress(), Value: bigInt(10)},
+		abi.ReserveApproval{Owner: holder.address(), Spender: s.owner.address(), Value: bigInt(90)},
+	)
+	s.assertRSVBalance(holder.address(), bigInt(90))
+	s.assertRSVTotalSupply(bigInt(90))
+
+	// Not beyond the allowance, nor by anyone but the minter.
+	s.requireTxFails(s.reserve.EmergencyBurnFrom(s.signer, holder.address(), bigInt(91)))
+	s.requireTxFails(s.reserve.EmergencyBurnFrom(signer(holder), holder.address(), bigInt(10)))
+	s.requireTxFails(s.reserve.EmergencyBurnFrom(signer(s.account[2]), holder.address(), bigInt(10)))
+
+	// Nor from a frozen account.
+	s.requireTx(s.reserve.Freeze(s.signer, holder.address()))
+	s.requireTxFails(s.reserve.EmergencyBurnFrom(s.signer, holder.address(), bigInt(10)))
+	s.assertRSVBalance(holder.address(), bigInt(90))
+	s.assertRSVTotalSupply(bigInt(90))
+}
+
 func (s *ReserveSuite) TestChangeFeeRecipientFailsForNonFeeRecipient() {
 	s.requireTxFails(s.reserve.ChangeFeeRecipient(signer(s.account[2]), s.account[1].address()))
 }
@@ -782,6 +1442,61 @@ func (s *ReserveSuite) TestChangeMaxSupplyFailsForNonOwner() {
 	s.requireTxFails(s.reserve.ChangeMaxSupply(signer(s.account[2]), bigInt(1)))
 }
 
+func (s *ReserveSuite) TestChangeMintCapFailsForNonOwner() {
+	s.requireTxFails(s.reserve.ChangeMintCap(signer(s.account[2]), bigInt(1)))
+
+	// Not even the minter can raise its own limit.
+	minter := s.account[1]
+	s.requireTx(s.reserve.ChangeMinter(s.signer, minter.address()))
+	s.requireTxFails(s.reserve.ChangeMintCap(signer(minter), maxUint256()))
+}
+
+func (s *ReserveSuite) TestChangeFlashMintCapFailsForNonOwner() {
+	s.requireTxFails(s.reserve.ChangeFlashMintCap(signer(s.account[2]), bigInt(1)))
+}
+
+func (s *ReserveSuite) TestChangeFlashMintFeeFailsForNonOwner() {
+	s.requireTxFails(s.reserve.ChangeFlashMintFee(signer(s.account[2]), bigInt(1)))
+}
+
+// TestSweep tests that the owner can recover tokens, RSV among them, sent to the Reserve.
+func (s *ReserveSuite) TestSweep() {
+	tokenAddress, tx, token, err := abi.DeployBasicERC20(s.signer, s.node)
+	s.logParsers[tokenAddress] = token
+	s.requireTx(tx, err)
+	recipient := s.account[3].address()
+
+	s.requireTx(token.Transfer(s.signer, s.reserveAddress, bigInt(100)))
+	s.requireTxWithStrictEvents(s.reserve.Sweep(s.signer, tokenAddress, bigInt(100), recipient))(
+		abi.BasicERC20Transfer{From: s.reserveAddress, To: recipient, Value: bigInt(100)},
+		abi.ReserveTokenSwept{Token: tokenAddress, To: recipient, Amount: bigInt(100)},
+	)
+	balance, err := token.BalanceOf(nil, recipient)
+	s.Require().NoError(err)
+	s.Equal("100", balance.String())
+
+	s.requireTx(s.reserve.Mint(s.signer, s.reserveAddress, bigInt(50)))
+	s.requireTxWithStrictEvents(s.reserve.Sweep(s.signer, s.reserveAddress, bigInt(50), recipient))(
+		abi.ReserveTransfer{From: s.reserveAddress, To: recipient, Value: bigInt(50)},
+		abi.ReserveTokenSwept{Token: s.reserveAddress, To: recipient, Amount: bigInt(50)},
+	)
+	s.assertRSVBalance(s.reserveAddress, bigInt(0))
+	s.assertRSVBalance(recipient, bigInt(50))
+	s.assertRSVTotalSupply(bigInt(50))
+
+	// No more than there is, and not to address zero.
+	s.requireTxFails(s.reserve.Sweep(s.signer, s.reserveAddress, bigInt(1), recipient))
+	s.requireTx(token.Transfer(s.signer, s.reserveAddress, bigInt(100)))
+	s.requireTxFails(s.reserve.Sweep(s.signer, tokenAddress, bigInt(101), recipient))
+	s.requireTxFails(s.reserve.Sweep(s.signer, tokenAddress, bigInt(100), zeroAddress()))
+}
+
+func (s *ReserveSuite) TestSweepFailsForNonOwner() {
+	s.requireTx(s.reserve.Mint(s.signer, s.reserveAddress, bigInt(50)))
+	s.requireTxFails(s.reserve.Sweep(signer(s.account[2]), s.reserveAddress, bigInt(50), s.account[2].address()))
+	s.assertRSVBalance(s.reserveAddress, bigInt(50))
+}
+
 ///////////////////////
 
 func (s *ReserveSuite) TestMintFailsForNonMinter() {
@@ -1118,3 +1833,2380 @@ func (s *ReserveSuite) TestTxFees() {
 	// Try a transaction again, this time it should revert
 	s.requireTxFails(s.reserve.Transfer(signer(sender), receiver.address(), amount))
 }
+
+//////////////////
+
+// deployTransferHook deploys a MockTransferHook and makes it the Reserve's transfer hook.
+func (s *ReserveSuite) deployTransferHook() (common.Address, *abi.MockTransferHook) {
+	address, tx, hook, err := abi.DeployMockTransferHook(s.signer, s.node)
+	s.requireTx(tx, err)
+	s.logParsers[address] = hook
+
+	s.requireTxWithStrictEvents(s.reserve.ChangeTransferHook(s.signer, address))(
+		abi.ReserveTransferHookChanged{NewTransferHook: address},
+	)
+	return address, hook
+}
+
+func (s *ReserveSuite) TestChangeTransferHook() {
+	hookAddress, _ := s.deployTransferHook()
+	transferHook, err := s.reserve.TransferHook(nil)
+	s.Require().NoError(err)
+	s.Equal(hookAddress, transferHook)
+
+	// The zero address unsets it.
+	s.requireTxWithStrictEvents(s.reserve.ChangeTransferHook(s.signer, zeroAddress()))(
+		abi.ReserveTransferHookChanged{NewTransferHook: zeroAddress()},
+	)
+	transferHook, err = s.reserve.TransferHook(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), transferHook)
+}
+
+func (s *ReserveSuite) TestChangeTransferHookFailsForNonOwner() {
+	s.requireTxFails(s.reserve.ChangeTransferHook(signer(s.account[2]), s.account[1].address()))
+}
+
+func (s *ReserveSuite) TestTransferHook() {
+	sender, spender, recipient, blocked := s.account[1], s.account[2], s.account[3], s.account[4]
+	s.requireTx(s.reserve.Mint(s.signer, sender.address(), bigInt(1000)))
+	s.requireTx(s.reserve.Approve(signer(sender), spender.address(), bigInt(1000)))
+	_, hook := s.deployTransferHook()
+	s.requireTx(hook.SetBlocked(s.signer, blocked.address(), true))
+
+	// Transfers the hook allows go through as before.
+	s.requireTxWithStrictEvents(s.reserve.Transfer(signer(sender), recipient.address(), bigInt(100)))(
+		abi.ReserveTransfer{From: sender.address(), To: recipient.address(), Value: bigInt(100)},
+	)
+
+	// Every kind of transfer to or from a blocked account is refused.
+	s.requireTxFails(s.reserve.Transfer(signer(sender), blocked.address(), bigInt(1)))
+	s.requireTxFails(s.reserve.TransferFrom(signer(spender), sender.address(), blocked.address(), bigInt(1)))
+	s.requireTxFails(s.reserve.TransferBatch(
+		signer(sender),
+		[]common.Address{recipient.address(), blocked.address()},
+		[]*big.Int{bigInt(1), bigInt(1)},
+	))
+	s.assertRSVBalance(sender.address(), bigInt(900))
+	s.assertRSVBalance(recipient.address(), bigInt(100))
+
+	// Minting and burning don't consult the hook.
+	s.requireTx(s.reserve.Mint(s.signer, blocked.address(), bigInt(10)))
+	s.requireTxFails(s.reserve.Transfer(signer(blocked), recipient.address(), bigInt(1)))
+	s.requireTx(s.reserve.Approve(signer(blocked), s.owner.address(), bigInt(10)))
+	s.requireTx(s.reserve.BurnFrom(s.signer, blocked.address(), bigInt(10)))
+	s.assertRSVBalance(blocked.address(), bigInt(0))
+
+	// Unblocked, the account can receive again.
+	s.requireTx(hook.SetBlocked(s.signer, blocked.address(), false))
+	s.requireTx(s.reserve.Transfer(signer(sender), blocked.address(), bigInt(1)))
+	s.assertRSVBalance(blocked.address(), bigInt(1))
+}
+
+// TestTransferHookCanBeUnset shows that a hook that refuses, or reverts on, every transfer
+// can't stop them for good: the owner unsets it.
+func (s *ReserveSuite) TestTransferHookCanBeUnset() {
+	sender, recipient := s.account[1], s.account[2]
+	s.requireTx(s.reserve.Mint(s.signer, sender.address(), bigInt(1000)))
+	hookAddress, hook := s.deployTransferHook()
+
+	s.requireTx(hook.SetRefuses(s.signer, true))
+	s.requireTxFails(s.reserve.Transfer(signer(sender), recipient.address(), bigInt(1)))
+	s.requireTx(hook.SetRefuses(s.signer, false))
+	s.requireTx(hook.SetReverts(s.signer, true))
+	s.requireTxFails(s.reserve.Transfer(signer(sender), recipient.address(), bigInt(1)))
+
+	s.requireTxWithStrictEvents(s.reserve.ChangeTransferHook(s.signer, zeroAddress()))(
+		abi.ReserveTransferHookChanged{NewTransferHook: zeroAddress()},
+	)
+	s.requireTx(s.reserve.Transfer(signer(sender), recipient.address(), bigInt(1)))
+	s.assertRSVBalance(recipient.address(), bigInt(1))
+
+	// Nor can it stop its own replacement.
+	s.requireTx(s.reserve.ChangeTransferHook(s.signer, hookAddress))
+	s.requireTx(s.reserve.ChangeTransferHook(s.signer, zeroAddress()))
+}
+
+// TestTransferHookGas logs and records what a transfer costs with and without a transfer hook.
+func (s *ReserveSuite) TestTransferHookGas() {
+	sender, recipient := s.account[1], s.account[2]
+	s.requireTx(s.reserve.Mint(s.signer, sender.address(), bigInt(1000)))
+	s.requireTx(s.reserve.Mint(s.signer, recipient.address(), bigInt(1000)))
+
+	// Both accounts already hold RSV, so neither transfer pays for a new balance.
+	without := s.recordGas("Reserve.transfer",
+		s.reserve.Transfer(signer(sender), recipient.address(), bigInt(1)))
+	s.deployTransferHook()
+	with := s.recordGas("Reserve.transfer (with a hook)",
+		s.reserve.Transfer(signer(sender), recipient.address(), bigInt(1)))
+
+	s.T().Logf("transfer: %v gas without a hook, %v gas with MockTransferHook (+%v)",
+		without, with, with-without)
+	s.Less(without, with)
+}
+
+//////////////////
+
+// snapshot takes a snapshot as the snapshotter, and returns its ID.
+func (s *ReserveSuite) snapshot(snapshotter account) *big.Int {
+	before, err := s.reserve.CurrentSnapshotId(nil)
+	s.Require().NoError(err)
+	id := new(big.Int).Add(before, bigInt(1))
+	s.requireTxWithStrictEvents(s.reserve.Snapshot(signer(snapshotter)))(
+		abi.ReserveSnapshot{Id: id},
+	)
+	return id
+}
+
+func (s *ReserveSuite) assertRSVBalanceAt(address common.Address, id *big.Int, amount *big.Int) {
+	balance, err := s.reserve.BalanceOfAt(nil, address, id)
+	s.NoError(err)
+	s.Equal(amount.String(), balance.String(), "balance of %v at snapshot %v", address.Hex(), id)
+}
+
+func (s *ReserveSuite) assertRSVTotalSupplyAt(id *big.Int, amount *big.Int) {
+	totalSupply, err := s.reserve.TotalSupplyAt(nil, id)
+	s.NoError(err)
+	s.Equal(amount.String(), totalSupply.String(), "total supply at snapshot %v", id)
+}
+
+func (s *ReserveSuite) TestChangeSnapshotter() {
+	snapshotter := s.account[1]
+	s.requireTxWithStrictEvents(s.reserve.ChangeSnapshotter(s.signer, snapshotter.address()))(
+		abi.ReserveSnapshotterChanged{NewSnapshotter: snapshotter.address()},
+	)
+	holder, err := s.reserve.Snapshotter(nil)
+	s.Require().NoError(err)
+	s.Equal(snapshotter.address(), holder)
+
+	// The snapshotter hands it on; no one else can.
+	s.requireTxFails(s.reserve.ChangeSnapshotter(signer(s.account[2]), s.account[2].address()))
+	s.requireTxWithStrictEvents(s.reserve.ChangeSnapshotter(signer(snapshotter), s.account[2].address()))(
+		abi.ReserveSnapshotterChanged{NewSnapshotter: s.account[2].address()},
+	)
+}
+
+func (s *ReserveSuite) TestSnapshotOnlySnapshotter() {
+	snapshotter := s.account[1]
+	s.requireTxFails(s.reserve.Snapshot(s.signer))
+	s.requireTx(s.reserve.ChangeSnapshotter(s.signer, snapshotter.address()))
+
+	// Not even the admin.
+	s.requireTxFails(s.reserve.Snapshot(s.signer))
+	s.requireTxFails(s.reserve.Snapshot(signer(s.account[2])))
+	s.Equal("1", s.snapshot(snapshotter).String())
+	s.Equal("2", s.snapshot(snapshotter).String())
+}
+
+func (s *ReserveSuite) TestSnapshotIds() {
+	// No snapshot yet, so no ID is valid.
+	_, err := s.reserve.BalanceOfAt(nil, s.account[1].address(), bigInt(1))
+	s.Error(err)
+
+	s.requireTx(s.reserve.ChangeSnapshotter(s.signer, s.owner.address()))
+	id := s.snapshot(s.owner)
+	_, err = s.reserve.BalanceOfAt(nil, s.account[1].address(), id)
+	s.NoError(err)
+	_, err = s.reserve.BalanceOfAt(nil, s.account[1].address(), bigInt(0))
+	s.Error(err)
+	_, err = s.reserve.BalanceOfAt(nil, s.account[1].address(), bigInt(2))
+	s.Error(err)
+	_, err = s.reserve.TotalSupplyAt(nil, bigInt(0))
+	s.Error(err)
+	_, err = s.reserve.TotalSupplyAt(nil, bigInt(2))
+	s.Error(err)
+}
+
+// TestSnapshotBalances interleaves transfers, mints, and burns with snapshots, and checks every
+// balance and the total supply at every snapshot against a model of the same history.
+func (s *ReserveSuite) TestSnapshotBalances() {
+	alice, bob, carol := s.account[1], s.account[2], s.account[3]
+	holders := []account{alice, bob, carol}
+	s.requireTx(s.reserve.ChangeSnapshotter(s.signer, s.owner.address()))
+	s.requireTx(s.reserve.Approve(signer(carol), s.owner.address(), maxUint256()))
+
+	// The model: the balances after each step, and as of each snapshot.
+	balances := map[common.Address]int64{}
+	var supply int64
+	type state struct {
+		id       *big.Int
+		balances map[common.Address]int64
+		supply   int64
+	}
+	var snapshots []state
+	take := func() {
+		copied := map[common.Address]int64{}
+		for address, balance := range balances {
+			copied[address] = balance
+		}
+		snapshots = append(snapshots, state{s.snapshot(s.owner), copied, supply})
+	}
+	mint := func(to account, value int64) {
+		s.requireTx(s.reserve.Mint(s.signer, to.address(), big.NewInt(value)))
+		balances[to.address()] += value
+		supply += value
+	}
+	transfer := func(from, to account, value int64) {
+		s.requireTx(s.reserve.Transfer(signer(from), to.address(), big.NewInt(value)))
+		balances[from.address()] -= value
+		balances[to.address()] += value
+	}
+	burn := func(from account, value int64) {
+		s.requireTx(s.reserve.BurnFrom(s.signer, from.address(), big.NewInt(value)))
+		balances[from.address()] -= value
+		supply -= value
+	}
+
+	take() // before anything
+	mint(alice, 1000)
+	take()
+	transfer(alice, bob, 100)
+	transfer(alice, bob, 50)
+	take()
+	take() // with nothing in between
+	mint(carol, 500)
+	transfer(bob, carol, 150)
+	take()
+	burn(carol, 200)
+	transfer(carol, alice, 1)
+	transfer(alice, carol, 1)
+	take()
+	transfer(alice, alice, 10)
+	mint(bob, 7)
+
+	for _, snap := range snapshots {
+		for _, holder := range holders {
+			s.assertRSVBalanceAt(holder.address(), snap.id, big.NewInt(snap.balances[holder.address()]))
+		}
+		s.assertRSVTotalSupplyAt(snap.id, big.NewInt(snap.supply))
+	}
+	for _, holder := range holders {
+		s.assertRSVBalance(holder.address(), big.NewInt(balances[holder.address()]))
+	}
+	s.assertRSVTotalSupply(big.NewInt(supply))
+}
+
+// TestSnapshotFees tests that a snapshot records the balance of a transfer's fee recipient
+// before the fee changes it.
+func (s *ReserveSuite) TestSnapshotFees() {
+	sender, recipient, feeRecipient := s.account[1], s.account[2], s.account[3]
+	s.requireTx(s.reserve.ChangeSnapshotter(s.signer, s.owner.address()))
+	s.requireTx(s.reserve.Mint(s.signer, sender.address(), bigInt(1000)))
+	feeAddress, tx, _, err := abi.DeployBasicTxFee(s.signer, s.node, bigInt(10))
+	s.requireTx(tx, err)
+	s.requireTx(s.reserve.ChangeTxFeeHelper(s.signer, feeAddress))
+	s.requireTx(s.reserve.ChangeFeeRecipient(s.signer, feeRecipient.address()))
+
+	id := s.snapshot(s.owner)
+	s.requireTx(s.reserve.Transfer(signer(sender), recipient.address(), bigInt(100)))
+	s.assertRSVBalance(feeRecipient.address(), bigInt(10))
+	s.assertRSVBalanceAt(feeRecipient.address(), id, bigInt(0))
+	s.assertRSVBalanceAt(sender.address(), id, bigInt(1000))
+	s.assertRSVBalanceAt(recipient.address(), id, bigInt(0))
+	s.assertRSVTotalSupplyAt(id, bigInt(1000))
+}
+
+// TestSnapshotGas measures what snapshots add to the gas of a transfer: nothing much before the
+// first snapshot, a new record for each account after it, and little once both are recorded.
+func (s *ReserveSuite) TestSnapshotGas() {
+	sender, recipient := s.account[1], s.account[2]
+	s.requireTx(s.reserve.Mint(s.signer, sender.address(), bigInt(1000)))
+	s.requireTx(s.reserve.Mint(s.signer, recipient.address(), bigInt(1000)))
+	s.requireTx(s.reserve.ChangeSnapshotter(s.signer, s.owner.address()))
+
+	// Both accounts already hold RSV, so no transfer pays for a new balance.
+	none := s.recordGas("Reserve.transfer (before any snapshot)",
+		s.reserve.Transfer(signer(sender), recipient.address(), bigInt(1)))
+	s.snapshot(s.owner)
+	first := s.recordGas("Reserve.transfer (first since a snapshot)",
+		s.reserve.Transfer(signer(sender), recipient.address(), bigInt(1)))
+	recorded := s.recordGas("Reserve.transfer (after a snapshot)",
+		s.reserve.Transfer(signer(sender), recipient.address(), bigInt(1)))
+
+	s.T().Logf("transfer: %v gas with no snapshot taken, %v (+%v) as the first since a snapshot, "+
+		"%v (+%v) after that", none, first, first-none, recorded, recorded-none)
+	s.Less(none, first)
+	s.Less(recorded, first)
+}
+
+//////////////////
+
+// permitChainID is the chain ID that the permit tests set on the Reserve.
+var permitChainID = bigInt(1337)
+
+// secp256k1N is the order of the secp256k1 curve.
+var secp256k1N, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+
+// enablePermits sets the Reserve's chain ID, and funds the ecrecover precompile.
+func (s *ReserveSuite) enablePermits() {
+	s.requireTxWithStrictEvents(s.reserve.ChangeChainId(s.signer, permitChainID))(
+		abi.ReserveChainIdChanged{NewChainId: permitChainID},
+	)
+	s.fundEcrecover()
+}
+
+// fundEcrecover funds the ecrecover precompile, which private chains only run once it holds
+// some wei (see RelayerSuite.BeforeTest).
+func (s *ReserveSuite) fundEcrecover() {
+	s.requireTx(s.sendRaw(s.account[0], common.BytesToAddress([]byte{1}), bigInt(1), nil))
+}
+
+// sendRaw sends a transaction of value wei and calldata data from "from" to "to", for calls
+// that the bindings can't make.
+func (s *ReserveSuite) sendRaw(from account, to common.Address, value *big.Int, data []byte) (*types.Transaction, error) {
+	nonce, err := s.node.PendingNonceAt(context.Background(), from.address())
+	s.Require().NoError(err)
+	tx, err := types.SignTx(
+		types.NewTransaction(nonce, to, value, 210000, bigInt(1), data),
+		types.HomesteadSigner{},
+		from.key,
+	)
+	s.Require().NoError(err)
+	return tx, s.node.SendTransaction(context.Background(), tx)
+}
+
+// domainSeparator is the EIP-712 domain separator of the Reserve on chainID, computed
+// independently of the contract.
+func (s *ReserveSuite) domainSeparator(chainID *big.Int) []byte {
+	return crypto.Keccak256(
+		crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)")),
+		crypto.Keccak256([]byte("Reserve")),
+		crypto.Keccak256([]byte("2.1")),
+		common.LeftPadBytes(chainID.Bytes(), 32),
+		common.LeftPadBytes(s.reserveAddress.Bytes(), 32),
+	)
+}
+
+// signPermit returns holder's signature of a permit for spender to spend value, on chainID.
+func (s *ReserveSuite) signPermit(
+	holder account, spender common.Address, value, nonce, deadline, chainID *big.Int,
+) (v uint8, r, sig [32]byte) {
+	structHash := crypto.Keccak256(
+		crypto.Keccak256([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)")),
+		common.LeftPadBytes(holder.address().Bytes(), 32),
+		common.LeftPadBytes(spender.Bytes(), 32),
+		common.LeftPadBytes(value.Bytes(), 32),
+		common.LeftPadBytes(nonce.Bytes(), 32),
+		common.LeftPadBytes(deadline.Bytes(), 32),
+	)
+	digest := crypto.Keccak256([]byte("\x19\x01"), s.domainSeparator(chainID), structHash)
+	signature, err := crypto.Sign(digest, holder.key)
+	s.Require().NoError(err)
+	copy(r[:], signature[:32])
+	copy(sig[:], signature[32:64])
+	return signature[64] + 27, r, sig
+}
+
+// permitDeadline is a deadline an hour from the current block.
+func (s *ReserveSuite) permitDeadline() *big.Int {
+	return new(big.Int).Add(s.currentTimestamp(), bigInt(3600))
+}
+
+func (s *ReserveSuite) TestDomainSeparator() {
+	s.enablePermits()
+
+	separator, err := s.reserve.DOMAINSEPARATOR(nil)
+	s.Require().NoError(err)
+	s.Equal(common.Bytes2Hex(s.domainSeparator(permitChainID)), common.Bytes2Hex(separator[:]))
+
+	typeHash, err := s.reserve.PERMITTYPEHASH(nil)
+	s.Require().NoError(err)
+	s.Equal(
+		common.Bytes2Hex(crypto.Keccak256([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)"))),
+		common.Bytes2Hex(typeHash[:]),
+	)
+
+	chainID, err := s.reserve.ChainId(nil)
+	s.Require().NoError(err)
+	s.Equal(permitChainID.String(), chainID.String())
+}
+
+func (s *ReserveSuite) TestPermit() {
+	s.enablePermits()
+	holder := s.account[1]
+	spender := s.account[2]
+	submitter := s.account[3]
+	recipient := s.account[4]
+	amount := bigInt(100)
+
+	s.requireTx(s.reserve.Mint(s.signer, holder.address(), amount))
+
+	// Anyone can submit the holder's signed permit.
+	deadline := s.permitDeadline()
+	v, r, sig := s.signPermit(holder, spender.address(), amount, bigInt(0), deadline, permitChainID)
+	s.requireTxWithStrictEvents(s.reserve.Permit(signer(submitter), holder.address(), spender.address(), amount, deadline, v, r, sig))(
+		abi.ReserveApproval{Owner: holder.address(), Spender: spender.address(), Value: amount},
+	)
+	s.assertRSVAllowance(holder.address(), spender.address(), amount)
+
+	nonce, err := s.reserve.Nonces(nil, holder.address())
+	s.Require().NoError(err)
+	s.Equal("1", nonce.String())
+
+	// The spender can use the allowance.
+	s.requireTx(s.reserve.TransferFrom(signer(spender), holder.address(), recipient.address(), amount))(
+		abi.ReserveTransfer{From: holder.address(), To: recipient.address(), Value: amount},
+	)
+	s.assertRSVBalance(recipient.address(), amount)
+	s.assertRSVAllowance(holder.address(), spender.address(), bigInt(0))
+
+	// The next permit signs the next nonce.
+	v, r, sig = s.signPermit(holder, spender.address(), bigInt(7), bigInt(1), deadline, permitChainID)
+	s.requireTxWithStrictEvents(s.reserve.Permit(signer(submitter), holder.address(), spender.address(), bigInt(7), deadline, v, r, sig))(
+		abi.ReserveApproval{Owner: holder.address(), Spender: spender.address(), Value: bigInt(7)},
+	)
+	s.assertRSVAllowance(holder.address(), spender.address(), bigInt(7))
+}
+
+func (s *ReserveSuite) TestPermitNonceReuse() {
+	s.enablePermits()
+	holder := s.account[1]
+	spender := s.account[2]
+	deadline := s.permitDeadline()
+
+	v, r, sig := s.signPermit(holder, spender.address(), bigInt(100), bigInt(0), deadline, permitChainID)
+	s.requireTx(s.reserve.Permit(s.signer, holder.address(), spender.address(), bigInt(100), deadline, v, r, sig))
+
+	// Lowering the allowance, then replaying the permit, leaves the allowance lowered.
+	s.requireTx(s.reserve.Approve(signer(holder), spender.address(), bigInt(1)))
+	s.requireTxFails(s.reserve.Permit(s.signer, holder.address(), spender.address(), bigInt(100), deadline, v, r, sig))
+	s.assertRSVAllowance(holder.address(), spender.address(), bigInt(1))
+
+	// A permit signed for a nonce not yet reached fails too.
+	v, r, sig = s.signPermit(holder, spender.address(), bigInt(100), bigInt(2), deadline, permitChainID)
+	s.requireTxFails(s.reserve.Permit(s.signer, holder.address(), spender.address(), bigInt(100), deadline, v, r, sig))
+
+	nonce, err := s.reserve.Nonces(nil, holder.address())
+	s.Require().NoError(err)
+	s.Equal("1", nonce.String())
+}
+
+func (s *ReserveSuite) TestPermitDeadline() {
+	s.enablePermits()
+	holder := s.account[1]
+	spender := s.account[2]
+
+	// A permit whose deadline has passed fails.
+	past := new(big.Int).Sub(s.currentTimestamp(), bigInt(1))
+	v, r, sig := s.signPermit(holder, spender.address(), bigInt(100), bigInt(0), past, permitChainID)
+	s.requireTxFails(s.reserve.Permit(s.signer, holder.address(), spender.address(), bigInt(100), past, v, r, sig))
+
+	// As does one that expires before it is submitted.
+	deadline := new(big.Int).Add(s.currentTimestamp(), bigInt(60))
+	v, r, sig = s.signPermit(holder, spender.address(), bigInt(100), bigInt(0), deadline, permitChainID)
+	s.Require().NoError(s.node.(backend).AdjustTime(time.Hour))
+	s.requireTxFails(s.reserve.Permit(s.signer, holder.address(), spender.address(), bigInt(100), deadline, v, r, sig))
+	s.assertRSVAllowance(holder.address(), spender.address(), bigInt(0))
+}
+
+func (s *ReserveSuite) TestPermitMalleability() {
+	s.enablePermits()
+	holder := s.account[1]
+	spender := s.account[2]
+	deadline := s.permitDeadline()
+	v, r, sig := s.signPermit(holder, spender.address(), bigInt(100), bigInt(0), deadline, permitChainID)
+
+	// The other signature of the same digest, with s in the upper half order, recovers to the
+	// same signer but is refused.
+	var highS [32]byte
+	copy(highS[:], common.LeftPadBytes(new(big.Int).Sub(secp256k1N, new(big.Int).SetBytes(sig[:])).Bytes(), 32))
+	s.requireTxFails(s.reserve.Permit(s.signer, holder.address(), spender.address(), bigInt(100), deadline, 55-v, r, highS))
+
+	// So is a v other than 27 or 28.
+	s.requireTxFails(s.reserve.Permit(s.signer, holder.address(), spender.address(), bigInt(100), deadline, v-27, r, sig))
+	s.assertRSVAllowance(holder.address(), spender.address(), bigInt(0))
+
+	// The signature as made still works.
+	s.requireTx(s.reserve.Permit(s.signer, holder.address(), spender.address(), bigInt(100), deadline, v, r, sig))
+	s.assertRSVAllowance(holder.address(), spender.address(), bigInt(100))
+}
+
+func (s *ReserveSuite) TestPermitInvalidSignature() {
+	s.enablePermits()
+	holder := s.account[1]
+	spender := s.account[2]
+	deadline := s.permitDeadline()
+
+	// Signed by someone else.
+	v, r, sig := s.signPermit(spender, spender.address(), bigInt(100), bigInt(0), deadline, permitChainID)
+	s.requireTxFails(s.reserve.Permit(s.signer, holder.address(), spender.address(), bigInt(100), deadline, v, r, sig))
+
+	// Signed for a different value.
+	v, r, sig = s.signPermit(holder, spender.address(), bigInt(1), bigInt(0), deadline, permitChainID)
+	s.requireTxFails(s.reserve.Permit(s.signer, holder.address(), spender.address(), bigInt(100), deadline, v, r, sig))
+
+	// Garbage that recovers to no address, submitted for the zero address.
+	s.requireTxFails(s.reserve.Permit(s.signer, zeroAddress(), spender.address(), bigInt(100), deadline, 27, [32]byte{}, [32]byte{}))
+	s.assertRSVAllowance(holder.address(), spender.address(), bigInt(0))
+}
+
+func (s *ReserveSuite) TestPermitAcrossForks() {
+	holder := s.account[1]
+	spender := s.account[2]
+	deadline := s.permitDeadline()
+
+	// Permits are refused until the chain ID is set.
+	v, r, sig := s.signPermit(holder, spender.address(), bigInt(100), bigInt(0), deadline, bigInt(0))
+	s.requireTxFails(s.reserve.Permit(s.signer, holder.address(), spender.address(), bigInt(100), deadline, v, r, sig))
+
+	s.enablePermits()
+	v, r, sig = s.signPermit(holder, spender.address(), bigInt(100), bigInt(0), deadline, permitChainID)
+
+	// After a fork to a new chain ID, the chain's permits signed before the fork are void, and
+	// those for the new chain ID work.
+	forked := bigInt(1338)
+	s.requireTxWithStrictEvents(s.reserve.ChangeChainId(s.signer, forked))(
+		abi.ReserveChainIdChanged{NewChainId: forked},
+	)
+	s.requireTxFails(s.reserve.Permit(s.signer, holder.address(), spender.address(), bigInt(100), deadline, v, r, sig))
+
+	v, r, sig = s.signPermit(holder, spender.address(), bigInt(100), bigInt(0), deadline, forked)
+	s.requireTx(s.reserve.Permit(s.signer, holder.address(), spender.address(), bigInt(100), deadline, v, r, sig))
+	s.assertRSVAllowance(holder.address(), spender.address(), bigInt(100))
+}
+
+func (s *ReserveSuite) TestPermitFailsWhenPaused() {
+	s.enablePermits()
+	holder := s.account[1]
+	spender := s.account[2]
+	deadline := s.permitDeadline()
+	v, r, sig := s.signPermit(holder, spender.address(), bigInt(100), bigInt(0), deadline, permitChainID)
+
+	s.requireTx(s.reserve.Pause(s.signer))
+	s.requireTxFails(s.reserve.Permit(s.signer, holder.address(), spender.address(), bigInt(100), deadline, v, r, sig))
+	s.assertRSVAllowance(holder.address(), spender.address(), bigInt(0))
+}
+
+func (s *ReserveSuite) TestChangeChainIdFailsForNonOwner() {
+	s.requireTxFails(s.reserve.ChangeChainId(signer(s.account[2]), bigInt(1)))
+}
+
+//////////////////
+
+// signAuthorization returns a's signature of the EIP-712 digest hash.
+func (s *ReserveSuite) signAuthorization(a account, hash common.Hash) authorize.Signature {
+	signature, err := crypto.Sign(hash.Bytes(), a.key)
+	s.Require().NoError(err)
+	var sig authorize.Signature
+	copy(sig.R[:], signature[:32])
+	copy(sig.S[:], signature[32:64])
+	sig.V = signature[64] + 27
+	return sig
+}
+
+// authorization returns an authorization, valid for the next hour, for from to transfer value
+// to to, with a fresh nonce.
+func (s *ReserveSuite) authorization(from account, to common.Address, value *big.Int, receive bool) authorize.Transfer {
+	nonce, err := authorize.NewNonce()
+	s.Require().NoError(err)
+	return authorize.Transfer{
+		From:        from.address(),
+		To:          to,
+		Value:       value,
+		ValidAfter:  bigInt(0),
+		ValidBefore: s.permitDeadline(),
+		Nonce:       nonce,
+		Receive:     receive,
+	}
+}
+
+func (s *ReserveSuite) assertAuthorizationState(authorizer common.Address, nonce common.Hash, used bool) {
+	state, err := s.reserve.AuthorizationState(nil, authorizer, nonce)
+	s.Require().NoError(err)
+	s.Equal(used, state)
+}
+
+func (s *ReserveSuite) TestTransferWithAuthorization() {
+	s.enablePermits()
+	domain := authorize.Reserve(s.reserveAddress, permitChainID)
+	from := s.account[1]
+	to := s.account[2]
+	submitter := s.account[3]
+	amount := bigInt(100)
+
+	s.requireTx(s.reserve.Mint(s.signer, from.address(), amount))
+
+	// Anyone can submit the authorization.
+	a := s.authorization(from, to.address(), bigInt(60), false)
+	sig := s.signAuthorization(from, a.Hash(domain))
+	s.assertAuthorizationState(from.address(), a.Nonce, false)
+	s.requireTxWithStrictEvents(s.reserve.TransferWithAuthorization(
+		signer(submitter), a.From, a.To, a.Value, a.ValidAfter, a.ValidBefore, a.Nonce, sig.V, sig.R, sig.S,
+	))(
+		abi.ReserveAuthorizationUsed{Authorizer: from.address(), Nonce: a.Nonce},
+		abi.ReserveTransfer{From: from.address(), To: to.address(), Value: bigInt(60)},
+	)
+	s.assertRSVBalance(from.address(), bigInt(40))
+	s.assertRSVBalance(to.address(), bigInt(60))
+	s.assertAuthorizationState(from.address(), a.Nonce, true)
+
+	// Each authorization can be used once.
+	s.requireTxFails(s.reserve.TransferWithAuthorization(
+		signer(submitter), a.From, a.To, a.Value, a.ValidAfter, a.ValidBefore, a.Nonce, sig.V, sig.R, sig.S,
+	))
+	s.assertRSVBalance(to.address(), bigInt(60))
+
+	// Nonces needn't be used in order, and a transfer beyond the balance fails.
+	a = s.authorization(from, to.address(), bigInt(41), false)
+	sig = s.signAuthorization(from, a.Hash(domain))
+	s.requireTxFails(s.reserve.TransferWithAuthorization(
+		signer(submitter), a.From, a.To, a.Value, a.ValidAfter, a.ValidBefore, a.Nonce, sig.V, sig.R, sig.S,
+	))
+	s.assertAuthorizationState(from.address(), a.Nonce, false)
+}
+
+func (s *ReserveSuite) TestReceiveWithAuthorization() {
+	s.enablePermits()
+	domain := authorize.Reserve(s.reserveAddress, permitChainID)
+	from := s.account[1]
+	to := s.account[2]
+	amount := bigInt(100)
+
+	s.requireTx(s.reserve.Mint(s.signer, from.address(), amount))
+	a := s.authorization(from, to.address(), amount, true)
+	sig := s.signAuthorization(from, a.Hash(domain))
+
+	// Only the payee can submit it.
+	s.requireTxFails(s.reserve.ReceiveWithAuthorization(
+		signer(s.account[3]), a.From, a.To, a.Value, a.ValidAfter, a.ValidBefore, a.Nonce, sig.V, sig.R, sig.S,
+	))
+
+	// It can't be used for transferWithAuthorization.
+	s.requireTxFails(s.reserve.TransferWithAuthorization(
+		signer(s.account[3]), a.From, a.To, a.Value, a.ValidAfter, a.ValidBefore, a.Nonce, sig.V, sig.R, sig.S,
+	))
+
+	s.requireTxWithStrictEvents(s.reserve.ReceiveWithAuthorization(
+		signer(to), a.From, a.To, a.Value, a.ValidAfter, a.ValidBefore, a.Nonce, sig.V, sig.R, sig.S,
+	))(
+		abi.ReserveAuthorizationUsed{Authorizer: from.address(), Nonce: a.Nonce},
+		abi.ReserveTransfer{From: from.address(), To: to.address(), Value: amount},
+	)
+	s.assertRSVBalance(to.address(), amount)
+}
+
+func (s *ReserveSuite) TestAuthorizationValidity() {
+	s.enablePermits()
+	domain := authorize.Reserve(s.reserveAddress, permitChainID)
+	from := s.account[1]
+	to := s.account[2]
+	s.requireTx(s.reserve.Mint(s.signer, from.address(), bigInt(100)))
+
+	// Not yet valid.
+	a := s.authorization(from, to.address(), bigInt(1), false)
+	a.ValidAfter = new(big.Int).Add(s.currentTimestamp(), bigInt(600))
+	sig := s.signAuthorization(from, a.Hash(domain))
+	s.requireTxFails(s.reserve.TransferWithAuthorization(
+		s.signer, a.From, a.To, a.Value, a.ValidAfter, a.ValidBefore, a.Nonce, sig.V, sig.R, sig.S,
+	))
+
+	// Valid once validAfter has passed, until validBefore.
+	s.Require().NoError(s.node.(backend).AdjustTime(20 * time.Minute))
+	s.requireTx(s.reserve.TransferWithAuthorization(
+		s.signer, a.From, a.To, a.Value, a.ValidAfter, a.ValidBefore, a.Nonce, sig.V, sig.R, sig.S,
+	))
+
+	a = s.authorization(from, to.address(), bigInt(1), false)
+	sig = s.signAuthorization(from, a.Hash(domain))
+	s.Require().NoError(s.node.(backend).AdjustTime(2 * time.Hour))
+	s.requireTxFails(s.reserve.TransferWithAuthorization(
+		s.signer, a.From, a.To, a.Value, a.ValidAfter, a.ValidBefore, a.Nonce, sig.V, sig.R, sig.S,
+	))
+	s.assertRSVBalance(to.address(), bigInt(1))
+}
+
+func (s *ReserveSuite) TestCancelAuthorization() {
+	s.enablePermits()
+	domain := authorize.Reserve(s.reserveAddress, permitChainID)
+	from := s.account[1]
+	to := s.account[2]
+	s.requireTx(s.reserve.Mint(s.signer, from.address(), bigInt(100)))
+
+	a := s.authorization(from, to.address(), bigInt(100), false)
+	sig := s.signAuthorization(from, a.Hash(domain))
+
+	// Cancelling needs the authorizer's signature of the cancellation.
+	cancel := authorize.Cancel{Authorizer: from.address(), Nonce: a.Nonce}
+	wrong := s.signAuthorization(to, cancel.Hash(domain))
+	s.requireTxFails(s.reserve.CancelAuthorization(s.signer, cancel.Authorizer, cancel.Nonce, wrong.V, wrong.R, wrong.S))
+
+	cancelSig := s.signAuthorization(from, cancel.Hash(domain))
+	s.requireTxWithStrictEvents(s.reserve.CancelAuthorization(s.signer, cancel.Authorizer, cancel.Nonce, cancelSig.V, cancelSig.R, cancelSig.S))(
+		abi.ReserveAuthorizationCanceled{Authorizer: from.address(), Nonce: a.Nonce},
+	)
+	s.assertAuthorizationState(from.address(), a.Nonce, true)
+
+	// The cancelled authorization can't be used, nor cancelled again.
+	s.requireTxFails(s.reserve.TransferWithAuthorization(
+		s.signer, a.From, a.To, a.Value, a.ValidAfter, a.ValidBefore, a.Nonce, sig.V, sig.R, sig.S,
+	))
+	s.requireTxFails(s.reserve.CancelAuthorization(s.signer, cancel.Authorizer, cancel.Nonce, cancelSig.V, cancelSig.R, cancelSig.S))
+	s.assertRSVBalance(to.address(), bigInt(0))
+}
+
+func (s *ReserveSuite) TestAuthorizationInvalidSignature() {
+	s.enablePermits()
+	domain := authorize.Reserve(s.reserveAddress, permitChainID)
+	from := s.account[1]
+	to := s.account[2]
+	s.requireTx(s.reserve.Mint(s.signer, from.address(), bigInt(100)))
+
+	// Signed by the payee.
+	a := s.authorization(from, to.address(), bigInt(100), false)
+	sig := s.signAuthorization(to, a.Hash(domain))
+	s.requireTxFails(s.reserve.TransferWithAuthorization(
+		s.signer, a.From, a.To, a.Value, a.ValidAfter, a.ValidBefore, a.Nonce, sig.V, sig.R, sig.S,
+	))
+
+	// Signed for another chain.
+	sig = s.signAuthorization(from, a.Hash(authorize.Reserve(s.reserveAddress, bigInt(1))))
+	s.requireTxFails(s.reserve.TransferWithAuthorization(
+		s.signer, a.From, a.To, a.Value, a.ValidAfter, a.ValidBefore, a.Nonce, sig.V, sig.R, sig.S,
+	))
+
+	// Submitted with a larger value than signed.
+	sig = s.signAuthorization(from, a.Hash(domain))
+	s.requireTxFails(s.reserve.TransferWithAuthorization(
+		s.signer, a.From, a.To, bigInt(101), a.ValidAfter, a.ValidBefore, a.Nonce, sig.V, sig.R, sig.S,
+	))
+
+	// Paused.
+	s.requireTx(s.reserve.Pause(s.signer))
+	s.requireTxFails(s.reserve.TransferWithAuthorization(
+		s.signer, a.From, a.To, a.Value, a.ValidAfter, a.ValidBefore, a.Nonce, sig.V, sig.R, sig.S,
+	))
+	s.assertRSVBalance(to.address(), bigInt(0))
+	s.assertAuthorizationState(from.address(), a.Nonce, false)
+}
+
+///////////////////////
+
+func (s *ReserveSuite) TestChangeForwarder() {
+	forwarder, err := s.reserve.TrustedForwarder(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), forwarder)
+
+	// The zero address is never trusted.
+	trusted, err := s.reserve.IsTrustedForwarder(nil, zeroAddress())
+	s.Require().NoError(err)
+	s.False(trusted)
+
+	// Change as owner.
+	s.requireTxWithStrictEvents(s.reserve.ChangeForwarder(s.signer, s.account[2].address()))(
+		abi.ReserveTrustedForwarderChanged{NewTrustedForwarder: s.account[2].address()},
+	)
+
+	forwarder, err = s.reserve.TrustedForwarder(nil)
+	s.Require().NoError(err)
+	s.Equal(s.account[2].address(), forwarder)
+	trusted, err = s.reserve.IsTrustedForwarder(nil, s.account[2].address())
+	s.Require().NoError(err)
+	s.True(trusted)
+	trusted, err = s.reserve.IsTrustedForwarder(nil, s.account[3].address())
+	s.Require().NoError(err)
+	s.False(trusted)
+}
+
+func (s *ReserveSuite) TestChangeForwarderFailsForNonOwner() {
+	s.requireTxFails(s.reserve.ChangeForwarder(signer(s.account[2]), s.account[1].address()))
+}
+
+// deployForwarder deploys a BasicForwarder, and makes it the Reserve's trusted forwarder if
+// trusted is set.
+func (s *ReserveSuite) deployForwarder(trusted bool) (common.Address, *abi.BasicForwarder) {
+	address, tx, forwarder, err := abi.DeployBasicForwarder(s.signer, s.node)
+	s.requireTx(tx, err)
+	s.logParsers[address] = forwarder
+	s.fundEcrecover()
+
+	if trusted {
+		s.requireTxWithStrictEvents(s.reserve.ChangeForwarder(s.signer, address))(
+			abi.ReserveTrustedForwarderChanged{NewTrustedForwarder: address},
+		)
+	}
+	return address, forwarder
+}
+
+// newHolder returns an account with a fresh key, which holds no ether.
+func (s *ReserveSuite) newHolder() account {
+	key, err := crypto.GenerateKey()
+	s.Require().NoError(err)
+	return account{key}
+}
+
+// reserveCalldata packs a call to the Reserve's method.
+func (s *ReserveSuite) reserveCalldata(method string, args ...interface{}) []byte {
+	parsed, err := ethabi.JSON(strings.NewReader(abi.ReserveABI))
+	s.Require().NoError(err)
+	data, err := parsed.Pack(method, args...)
+	s.Require().NoError(err)
+	return data
+}
+
+// signForward returns from's signature, for forwarder at forwarderAddress, of its next call to
+// the Reserve with calldata data.
+func (s *ReserveSuite) signForward(
+	forwarder *abi.BasicForwarder, forwarderAddress common.Address, from account, data []byte,
+) []byte {
+	nonce, err := forwarder.Nonces(nil, from.address())
+	s.Require().NoError(err)
+	hash := crypto.Keccak256(
+		forwarderAddress.Bytes(),
+		from.address().Bytes(),
+		s.reserveAddress.Bytes(),
+		data,
+		common.LeftPadBytes(nonce.Bytes(), 32),
+	)
+	sig, err := crypto.Sign(crypto.Keccak256([]byte("\x19Ethereum Signed Message:\n32"), hash), from.key)
+	s.Require().NoError(err)
+	return addToLastByte(sig)
+}
+
+// forward has submitter send from's call to the Reserve with calldata data through forwarder.
+func (s *ReserveSuite) forward(
+	forwarder *abi.BasicForwarder, forwarderAddress common.Address, submitter, from account, data []byte,
+) (*types.Transaction, error) {
+	sig := s.signForward(forwarder, forwarderAddress, from, data)
+	return forwarder.Execute(signer(submitter), from.address(), s.reserveAddress, data, sig)
+}
+
+func (s *ReserveSuite) assertETHBalance(address common.Address, expected *big.Int) {
+	balance, err := s.node.(interface {
+		BalanceAt(context.Context, common.Address, *big.Int) (*big.Int, error)
+	}).BalanceAt(context.Background(), address, nil)
+	s.Require().NoError(err)
+	s.Equal(expected.String(), balance.String())
+}
+
+func (s *ReserveSuite) TestForwardedTransfer() {
+	forwarderAddress, forwarder := s.deployForwarder(true)
+	holder := s.newHolder()
+	recipient := s.account[2].address()
+	submitter := s.account[4]
+	s.requireTx(s.reserve.Mint(s.signer, holder.address(), bigInt(100)))
+
+	data := s.reserveCalldata("transfer", recipient, bigInt(60))
+	sig := s.signForward(forwarder, forwarderAddress, holder, data)
+	s.requireTxWithStrictEvents(forwarder.Execute(signer(submitter), holder.address(), s.reserveAddress, data, sig))(
+		abi.ReserveTransfer{From: holder.address(), To: recipient, Value: bigInt(60)},
+	)
+	s.assertRSVBalance(holder.address(), bigInt(40))
+	s.assertRSVBalance(recipient, bigInt(60))
+	s.assertRSVBalance(forwarderAddress, bigInt(0))
+	s.assertETHBalance(holder.address(), bigInt(0))
+
+	// A replayed signature fails at the forwarder.
+	s.requireTxFails(forwarder.Execute(signer(submitter), holder.address(), s.reserveAddress, data, sig))
+	s.assertRSVBalance(recipient, bigInt(60))
+
+	// As does one by another account.
+	data = s.reserveCalldata("transfer", recipient, bigInt(40))
+	sig = s.signForward(forwarder, forwarderAddress, s.account[2], data)
+	s.requireTxFails(forwarder.Execute(signer(submitter), holder.address(), s.reserveAddress, data, sig))
+	s.assertRSVBalance(holder.address(), bigInt(40))
+}
+
+func (s *ReserveSuite) TestForwardedApprove() {
+	forwarderAddress, forwarder := s.deployForwarder(true)
+	holder := s.newHolder()
+	spender := s.account[2]
+	recipient := s.account[3].address()
+	s.requireTx(s.reserve.Mint(s.signer, holder.address(), bigInt(100)))
+
+	s.requireTxWithStrictEvents(s.forward(
+		forwarder, forwarderAddress, s.account[4], holder, s.reserveCalldata("approve", spender.address(), bigInt(50)),
+	))(
+		abi.ReserveApproval{Owner: holder.address(), Spender: spender.address(), Value: bigInt(50)},
+	)
+	s.requireTxWithStrictEvents(s.forward(
+		forwarder, forwarderAddress, s.account[4], holder, s.reserveCalldata("increaseAllowance", spender.address(), bigInt(20)),
+	))(
+		abi.ReserveApproval{Owner: holder.address(), Spender: spender.address(), Value: bigInt(70)},
+	)
+	s.requireTxWithStrictEvents(s.forward(
+		forwarder, forwarderAddress, s.account[4], holder, s.reserveCalldata("decreaseAllowance", spender.address(), bigInt(10)),
+	))(
+		abi.ReserveApproval{Owner: holder.address(), Spender: spender.address(), Value: bigInt(60)},
+	)
+	s.assertRSVAllowance(holder.address(), spender.address(), bigInt(60))
+
+	// The spender spends it directly.
+	s.requireTxWithStrictEvents(s.reserve.TransferFrom(signer(spender), holder.address(), recipient, bigInt(30)))(
+		abi.ReserveTransfer{From: holder.address(), To: recipient, Value: bigInt(30)},
+		abi.ReserveApproval{Owner: holder.address(), Spender: spender.address(), Value: bigInt(30)},
+	)
+
+	// And through the forwarder.
+	s.requireTxWithStrictEvents(s.forward(
+		forwarder, forwarderAddress, s.account[4], spender, s.reserveCalldata("transferFrom", holder.address(), recipient, bigInt(30)),
+	))(
+		abi.ReserveTransfer{From: holder.address(), To: recipient, Value: bigInt(30)},
+		abi.ReserveApproval{Owner: holder.address(), Spender: spender.address(), Value: bigInt(0)},
+	)
+	s.assertRSVBalance(holder.address(), bigInt(40))
+	s.assertRSVBalance(recipient, bigInt(60))
+	s.assertETHBalance(holder.address(), bigInt(0))
+}
+
+func (s *ReserveSuite) TestUntrustedForwarder() {
+	forwarderAddress, forwarder := s.deployForwarder(false)
+	holder := s.newHolder()
+	recipient := s.account[2].address()
+	s.requireTx(s.reserve.Mint(s.signer, holder.address(), bigInt(100)))
+	data := s.reserveCalldata("transfer", recipient, bigInt(100))
+
+	// An untrusted forwarder transfers its own (nonexistent) RSV.
+	s.requireTxFails(s.forward(forwarder, forwarderAddress, s.account[4], holder, data))
+
+	// Once trusted, it transfers the holder's.
+	s.requireTx(s.reserve.ChangeForwarder(s.signer, forwarderAddress))
+	s.requireTx(s.forward(forwarder, forwarderAddress, s.account[4], holder, data))
+	s.assertRSVBalance(recipient, bigInt(100))
+
+	// Turning forwarding off makes it untrusted again.
+	s.requireTxWithStrictEvents(s.reserve.ChangeForwarder(s.signer, zeroAddress()))(
+		abi.ReserveTrustedForwarderChanged{NewTrustedForwarder: zeroAddress()},
+	)
+	s.requireTxFails(s.forward(
+		forwarder, forwarderAddress, s.account[4], s.account[2], s.reserveCalldata("transfer", holder.address(), bigInt(100)),
+	))
+	s.assertRSVBalance(recipient, bigInt(100))
+}
+
+func (s *ReserveSuite) TestForwardedCallsFailWhenPaused() {
+	forwarderAddress, forwarder := s.deployForwarder(true)
+	holder := s.newHolder()
+	s.requireTx(s.reserve.Mint(s.signer, holder.address(), bigInt(100)))
+	s.requireTx(s.reserve.Pause(s.signer))
+
+	s.requireTxFails(s.forward(
+		forwarder, forwarderAddress, s.account[4], holder, s.reserveCalldata("transfer", s.account[2].address(), bigInt(100)),
+	))
+	s.assertRSVBalance(holder.address(), bigInt(100))
+}
+
+func (s *ReserveSuite) TestForwarderCannotExerciseRoles() {
+	forwarderAddress, forwarder := s.deployForwarder(true)
+
+	// The owner is also the pauser, minter, and fee recipient, but none of that is forwarded.
+	s.requireTxFails(s.forward(forwarder, forwarderAddress, s.account[4], s.owner, s.reserveCalldata("pause")))
+	s.requireTxFails(s.forward(
+		forwarder, forwarderAddress, s.account[4], s.owner, s.reserveCalldata("mint", s.account[2].address(), bigInt(100)),
+	))
+	s.requireTxFails(s.forward(
+		forwarder, forwarderAddress, s.account[4], s.owner, s.reserveCalldata("changeForwarder", zeroAddress()),
+	))
+
+	paused, err := s.reserve.Paused(nil)
+	s.Require().NoError(err)
+	s.False(paused)
+	s.assertRSVTotalSupply(bigInt(0))
+	s.assertRSVBalance(s.account[2].address(), bigInt(0))
+}
+
+func (s *ReserveSuite) TestAppendedSenderIgnoredFromOthers() {
+	s.deployForwarder(true)
+	sender := s.account[1]
+	victim := s.account[3].address()
+	recipient := s.account[2].address()
+	s.requireTx(s.reserve.Mint(s.signer, sender.address(), bigInt(100)))
+	s.requireTx(s.reserve.Mint(s.signer, victim, bigInt(100)))
+
+	// A sender that isn't the forwarder appends another address, as the forwarder would, and
+	// still moves only its own RSV.
+	data := append(s.reserveCalldata("transfer", recipient, bigInt(100)), victim.Bytes()...)
+	s.requireTxWithStrictEvents(s.sendRaw(sender, s.reserveAddress, bigInt(0), data))(
+		abi.ReserveTransfer{From: sender.address(), To: recipient, Value: bigInt(100)},
+	)
+	s.assertRSVBalance(sender.address(), bigInt(0))
+	s.assertRSVBalance(victim, bigInt(100))
+	s.assertRSVBalance(recipient, bigInt(100))
+}
+
+///////////////////////
+
+func (s *ReserveSuite) TestChangeFreezer() {
+	freezer, err := s.reserve.Freezer(nil)
+	s.Require().NoError(err)
+	s.Equal(s.owner.address(), freezer)
+
+	// Change as owner.
+	s.requireTxWithStrictEvents(s.reserve.ChangeFreezer(s.signer, s.account[2].address()))(
+		abi.ReserveFreezerChanged{NewFreezer: s.account[2].address()},
+	)
+
+	freezer, err = s.reserve.Freezer(nil)
+	s.Require().NoError(err)
+	s.Equal(s.account[2].address(), freezer)
+
+	// Change as freezer.
+	s.requireTxWithStrictEvents(s.reserve.ChangeFreezer(signer(s.account[2]), s.account[3].address()))(
+		abi.ReserveFreezerChanged{NewFreezer: s.account[3].address()},
+	)
+
+	freezer, err = s.reserve.Freezer(nil)
+	s.Require().NoError(err)
+	s.Equal(s.account[3].address(), freezer)
+}
+
+func (s *ReserveSuite) TestChangeFreezerFailsForNonFreezer() {
+	s.requireTxFails(s.reserve.ChangeFreezer(signer(s.account[2]), s.account[1].address()))
+}
+
+func (s *ReserveSuite) TestFreezeFailsForNonFreezer() {
+	target := s.account[1].address()
+	s.requireTxFails(s.reserve.Freeze(signer(s.account[2]), target))
+
+	// Not even the owner can freeze, once it isn't the freezer.
+	s.requireTx(s.reserve.ChangeFreezer(s.signer, s.account[3].address()))
+	s.requireTxFails(s.reserve.Freeze(s.signer, target))
+
+	s.requireTx(s.reserve.Freeze(signer(s.account[3]), target))
+	s.requireTxFails(s.reserve.Unfreeze(s.signer, target))
+	s.requireTxFails(s.reserve.Unfreeze(signer(s.account[2]), target))
+	s.assertFrozen(target, true)
+}
+
+func (s *ReserveSuite) assertFrozen(account common.Address, expected bool) {
+	frozen, err := s.reserve.Frozen(nil, account)
+	s.Require().NoError(err)
+	s.Equal(expected, frozen)
+}
+
+func (s *ReserveSuite) TestFreeze() {
+	target := s.account[1].address()
+	s.assertFrozen(target, false)
+
+	s.requireTxWithStrictEvents(s.reserve.Freeze(s.signer, target))(
+		abi.ReserveAddressFrozen{Account: target},
+	)
+	s.assertFrozen(target, true)
+	s.assertFrozen(s.account[2].address(), false)
+
+	s.requireTxWithStrictEvents(s.reserve.Unfreeze(s.signer, target))(
+		abi.ReserveAddressUnfrozen{Account: target},
+	)
+	s.assertFrozen(target, false)
+}
+
+func (s *ReserveSuite) TestFreezeWhilePaused() {
+	target := s.account[1].address()
+	s.requireTx(s.reserve.Pause(s.signer))
+
+	s.requireTxWithStrictEvents(s.reserve.Freeze(s.signer, target))(
+		abi.ReserveAddressFrozen{Account: target},
+	)
+	s.assertFrozen(target, true)
+	s.requireTxWithStrictEvents(s.reserve.Unfreeze(s.signer, target))(
+		abi.ReserveAddressUnfrozen{Account: target},
+	)
+	s.assertFrozen(target, false)
+}
+
+func (s *ReserveSuite) TestFrozenTransfer() {
+	frozen := s.account[1]
+	other := s.account[2]
+	s.requireTx(s.reserve.Mint(s.signer, frozen.address(), bigInt(100)))
+	s.requireTx(s.reserve.Mint(s.signer, other.address(), bigInt(100)))
+	s.requireTx(s.reserve.Freeze(s.signer, frozen.address()))
+
+	// A frozen account can neither send nor receive.
+	s.requireTxFails(s.reserve.Transfer(signer(frozen), other.address(), bigInt(1)))
+	s.requireTxFails(s.reserve.Transfer(signer(other), frozen.address(), bigInt(1)))
+	s.requireTxFails(s.reserve.Transfer(signer(frozen), frozen.address(), bigInt(1)))
+	s.assertRSVBalance(frozen.address(), bigInt(100))
+	s.assertRSVBalance(other.address(), bigInt(100))
+
+	// Others still can.
+	s.requireTxWithStrictEvents(s.reserve.Transfer(signer(other), s.account[3].address(), bigInt(10)))(
+		abi.ReserveTransfer{From: other.address(), To: s.account[3].address(), Value: bigInt(10)},
+	)
+
+	// Once unfrozen, it can again.
+	s.requireTx(s.reserve.Unfreeze(s.signer, frozen.address()))
+	s.requireTxWithStrictEvents(s.reserve.Transfer(signer(frozen), other.address(), bigInt(100)))(
+		abi.ReserveTransfer{From: frozen.address(), To: other.address(), Value: bigInt(100)},
+	)
+	s.assertRSVBalance(frozen.address(), bigInt(0))
+	s.assertRSVBalance(other.address(), bigInt(190))
+}
+
+func (s *ReserveSuite) TestFrozenTransferFrom() {
+	holder := s.account[1]
+	spender := s.account[2]
+	recipient := s.account[3]
+	s.requireTx(s.reserve.Mint(s.signer, holder.address(), bigInt(100)))
+	s.requireTx(s.reserve.Approve(signer(holder), spender.address(), bigInt(100)))
+
+	// Frozen holder, spender, or recipient: each stops the transfer.
+	for _, frozen := range []account{holder, spender, recipient} {
+		s.requireTx(s.reserve.Freeze(s.signer, frozen.address()))
+		s.requireTxFails(s.reserve.TransferFrom(signer(spender), holder.address(), recipient.address(), bigInt(50)))
+		s.requireTx(s.reserve.Unfreeze(s.signer, frozen.address()))
+	}
+	s.assertRSVBalance(holder.address(), bigInt(100))
+	s.assertRSVAllowance(holder.address(), spender.address(), bigInt(100))
+
+	// A frozen holder can still approve, but its tokens can't be spent.
+	s.requireTx(s.reserve.Freeze(s.signer, holder.address()))
+	s.requireTxWithStrictEvents(s.reserve.Approve(signer(holder), spender.address(), bigInt(50)))(
+		abi.ReserveApproval{Owner: holder.address(), Spender: spender.address(), Value: bigInt(50)},
+	)
+	s.requireTxFails(s.reserve.TransferFrom(signer(spender), holder.address(), recipient.address(), bigInt(50)))
+
+	s.requireTx(s.reserve.Unfreeze(s.signer, holder.address()))
+	s.requireTxWithStrictEvents(s.reserve.TransferFrom(signer(spender), holder.address(), recipient.address(), bigInt(50)))(
+		abi.ReserveTransfer{From: holder.address(), To: recipient.address(), Value: bigInt(50)},
+		abi.ReserveApproval{Owner: holder.address(), Spender: spender.address(), Value: bigInt(0)},
+	)
+	s.assertRSVBalance(recipient.address(), bigInt(50))
+}
+
+func (s *ReserveSuite) TestFrozenMint() {
+	frozen := s.account[1].address()
+	s.requireTx(s.reserve.Freeze(s.signer, frozen))
+
+	s.requireTxFails(s.reserve.Mint(s.signer, frozen, bigInt(100)))
+	s.assertRSVBalance(frozen, bigInt(0))
+	s.assertRSVTotalSupply(bigInt(0))
+
+	s.requireTx(s.reserve.Unfreeze(s.signer, frozen))
+	s.requireTxWithStrictEvents(s.reserve.Mint(s.signer, frozen, bigInt(100)))(
+		mintingTransfer(frozen, bigInt(100)),
+	)
+	s.assertRSVTotalSupply(bigInt(100))
+}
+
+func (s *ReserveSuite) TestFrozenBurn() {
+	deployerAddress := s.owner.address()
+	frozen := s.account[1]
+	s.requireTx(s.reserve.Mint(s.signer, frozen.address(), bigInt(100)))
+	s.requireTx(s.reserve.Approve(signer(frozen), deployerAddress, bigInt(100)))
+	s.requireTx(s.reserve.Freeze(s.signer, frozen.address()))
+
+	s.requireTxFails(s.reserve.BurnFrom(s.signer, frozen.address(), bigInt(100)))
+	s.assertRSVBalance(frozen.address(), bigInt(100))
+	s.assertRSVTotalSupply(bigInt(100))
+
+	s.requireTx(s.reserve.Unfreeze(s.signer, frozen.address()))
+	s.requireTxWithStrictEvents(s.reserve.BurnFrom(s.signer, frozen.address(), bigInt(100)))(
+		abi.ReserveTransfer{From: frozen.address(), To: zeroAddress(), Value: bigInt(100)},
+		abi.ReserveApproval{Owner: frozen.address(), Spender: deployerAddress, Value: bigInt(0)},
+	)
+	s.assertRSVTotalSupply(bigInt(0))
+}
+
+func (s *ReserveSuite) TestFrozenAuthorizedTransfer() {
+	s.enablePermits()
+	domain := authorize.Reserve(s.reserveAddress, permitChainID)
+	from := s.account[1]
+	to := s.account[2]
+	s.requireTx(s.reserve.Mint(s.signer, from.address(), bigInt(100)))
+
+	// Neither a signed transfer from a frozen account, nor one to it, goes through.
+	for _, frozen := range []account{from, to} {
+		s.requireTx(s.reserve.Freeze(s.signer, frozen.address()))
+		a := s.authorization(from, to.address(), bigInt(100), false)
+		sig := s.signAuthorization(from, a.Hash(domain))
+		s.requireTxFails(s.reserve.TransferWithAuthorization(
+			s.signer, a.From, a.To, a.Value, a.ValidAfter, a.ValidBefore, a.Nonce, sig.V, sig.R, sig.S,
+		))
+		s.assertAuthorizationState(from.address(), a.Nonce, false)
+		s.requireTx(s.reserve.Unfreeze(s.signer, frozen.address()))
+	}
+	s.assertRSVBalance(from.address(), bigInt(100))
+}
+
+func (s *ReserveSuite) TestFrozenForwardedTransfer() {
+	forwarderAddress, forwarder := s.deployForwarder(true)
+	holder := s.newHolder()
+	s.requireTx(s.reserve.Mint(s.signer, holder.address(), bigInt(100)))
+	s.requireTx(s.reserve.Freeze(s.signer, holder.address()))
+
+	s.requireTxFails(s.forward(
+		forwarder, forwarderAddress, s.account[4], holder, s.reserveCalldata("transfer", s.account[2].address(), bigInt(100)),
+	))
+	s.assertRSVBalance(holder.address(), bigInt(100))
+}
+
+///////////////////////
+
+func (s *ReserveSuite) TestChangeWiper() {
+	wiper, err := s.reserve.Wiper(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), wiper)
+
+	// Change as owner.
+	s.requireTxWithStrictEvents(s.reserve.ChangeWiper(s.signer, s.account[2].address()))(
+		abi.ReserveWiperChanged{NewWiper: s.account[2].address()},
+	)
+
+	// Change as wiper.
+	s.requireTxWithStrictEvents(s.reserve.ChangeWiper(signer(s.account[2]), s.account[3].address()))(
+		abi.ReserveWiperChanged{NewWiper: s.account[3].address()},
+	)
+
+	wiper, err = s.reserve.Wiper(nil)
+	s.Require().NoError(err)
+	s.Equal(s.account[3].address(), wiper)
+}
+
+func (s *ReserveSuite) TestChangeWiperFailsForNonWiper() {
+	s.requireTxFails(s.reserve.ChangeWiper(signer(s.account[2]), s.account[1].address()))
+}
+
+// setUpWipe gives wiper the wiper role, and target a balance of value, frozen.
+func (s *ReserveSuite) setUpWipe(wiper account, target common.Address, value *big.Int) {
+	s.requireTx(s.reserve.ChangeWiper(s.signer, wiper.address()))
+	s.requireTx(s.reserve.Mint(s.signer, target, value))
+	s.requireTx(s.reserve.Freeze(s.signer, target))
+}
+
+func (s *ReserveSuite) assertWipeReadyAt(account common.Address, expected *big.Int) {
+	readyAt, err := s.reserve.WipeReadyAt(nil, account)
+	s.Require().NoError(err)
+	s.Equal(expected.String(), readyAt.String())
+}
+
+func (s *ReserveSuite) TestWipe() {
+	wiper := s.account[2]
+	target := s.account[1].address()
+	s.setUpWipe(wiper, target, bigInt(100))
+	s.requireTx(s.reserve.Mint(s.signer, s.account[3].address(), bigInt(50)))
+
+	delay, err := s.reserve.WIPEDELAY(nil)
+	s.Require().NoError(err)
+	s.Equal(bigInt(2*24*60*60).String(), delay.String())
+
+	before := s.currentTimestamp()
+	assertEvents := s.requireTxWithStrictEvents(s.reserve.ProposeWipe(signer(wiper), target))
+	readyAt, err := s.reserve.WipeReadyAt(nil, target)
+	s.Require().NoError(err)
+	assertEvents(abi.ReserveWipeProposed{Account: target, ReadyAt: readyAt})
+	proposedAt := new(big.Int).Sub(readyAt, delay)
+	s.True(proposedAt.Cmp(before) >= 0 && proposedAt.Cmp(s.currentTimestamp()) <= 0)
+
+	// Not before the delay has passed.
+	s.requireTxFails(s.reserve.Wipe(signer(wiper), target))
+	s.Require().NoError(s.node.(backend).AdjustTime(47 * time.Hour))
+	s.requireTxFails(s.reserve.Wipe(signer(wiper), target))
+	s.assertRSVBalance(target, bigInt(100))
+
+	// Nor by anyone but the wiper, not even the owner.
+	s.Require().NoError(s.node.(backend).AdjustTime(time.Hour))
+	s.requireTxFails(s.reserve.Wipe(s.signer, target))
+	s.requireTxFails(s.reserve.Wipe(signer(s.account[3]), target))
+
+	s.requireTxWithStrictEvents(s.reserve.Wipe(signer(wiper), target))(
+		abi.ReserveTransfer{From: target, To: zeroAddress(), Value: bigInt(100)},
+		abi.ReserveFrozenBalanceWiped{Account: target, Value: bigInt(100), WipedBy: wiper.address()},
+	)
+	s.assertRSVBalance(target, bigInt(0))
+	s.assertRSVTotalSupply(bigInt(50))
+	s.assertWipeReadyAt(target, bigInt(0))
+	s.assertFrozen(target, true)
+
+	// Each wipe is proposed afresh.
+	s.requireTxFails(s.reserve.Wipe(signer(wiper), target))
+}
+
+func (s *ReserveSuite) TestWipeWhilePaused() {
+	wiper := s.account[2]
+	target := s.account[1].address()
+	s.setUpWipe(wiper, target, bigInt(100))
+	s.requireTx(s.reserve.Pause(s.signer))
+
+	s.requireTx(s.reserve.ProposeWipe(signer(wiper), target))
+	s.Require().NoError(s.node.(backend).AdjustTime(48 * time.Hour))
+	s.requireTx(s.reserve.Wipe(signer(wiper), target))
+	s.assertRSVBalance(target, bigInt(0))
+	s.assertRSVTotalSupply(bigInt(0))
+}
+
+func (s *ReserveSuite) TestWipeFailsForUnfrozen() {
+	wiper := s.account[2]
+	target := s.account[1].address()
+	s.requireTx(s.reserve.ChangeWiper(s.signer, wiper.address()))
+	s.requireTx(s.reserve.Mint(s.signer, target, bigInt(100)))
+
+	// An unfrozen account can't be proposed for a wipe, or wiped.
+	s.requireTxFails(s.reserve.ProposeWipe(signer(wiper), target))
+	s.requireTxFails(s.reserve.Wipe(signer(wiper), target))
+
+	// Unfreezing cancels a proposed wipe...
+	s.requireTx(s.reserve.Freeze(s.signer, target))
+	s.requireTx(s.reserve.ProposeWipe(signer(wiper), target))
+	s.requireTxWithStrictEvents(s.reserve.Unfreeze(s.signer, target))(
+		abi.ReserveAddressUnfrozen{Account: target},
+		abi.ReserveWipeCanceled{Account: target},
+	)
+	s.assertWipeReadyAt(target, bigInt(0))
+	s.Require().NoError(s.node.(backend).AdjustTime(48 * time.Hour))
+	s.requireTxFails(s.reserve.Wipe(signer(wiper), target))
+
+	// ...so that refreezing doesn't revive it.
+	s.requireTx(s.reserve.Freeze(s.signer, target))
+	s.requireTxFails(s.reserve.Wipe(signer(wiper), target))
+	s.assertRSVBalance(target, bigInt(100))
+	s.assertRSVTotalSupply(bigInt(100))
+}
+
+func (s *ReserveSuite) TestCancelWipe() {
+	wiper := s.account[2]
+	target := s.account[1].address()
+	s.setUpWipe(wiper, target, bigInt(100))
+
+	s.requireTxFails(s.reserve.CancelWipe(signer(wiper), target))
+
+	// The wiper and the owner can cancel; others can't.
+	for _, canceler := range []account{wiper, s.owner} {
+		s.requireTx(s.reserve.ProposeWipe(signer(wiper), target))
+		s.requireTxFails(s.reserve.ProposeWipe(signer(wiper), target))
+		s.requireTxFails(s.reserve.CancelWipe(signer(s.account[3]), target))
+		s.requireTxWithStrictEvents(s.reserve.CancelWipe(signer(canceler), target))(
+			abi.ReserveWipeCanceled{Account: target},
+		)
+		s.assertWipeReadyAt(target, bigInt(0))
+	}
+
+	s.Require().NoError(s.node.(backend).AdjustTime(48 * time.Hour))
+	s.requireTxFails(s.reserve.Wipe(signer(wiper), target))
+	s.assertRSVBalance(target, bigInt(100))
+}
+
+func (s *ReserveSuite) TestProposeWipeFailsForNonWiper() {
+	target := s.account[1].address()
+	s.setUpWipe(s.account[2], target, bigInt(100))
+
+	// Not even the owner or the freezer may propose a wipe.
+	s.requireTxFails(s.reserve.ProposeWipe(s.signer, target))
+	s.requireTxFails(s.reserve.ProposeWipe(signer(s.account[3]), target))
+	s.assertWipeReadyAt(target, bigInt(0))
+}
+
+///////////////////////
+
+// Role identifiers, computed independently of the contract.
+var (
+	adminRole        = [32]byte{}
+	minterRole       = crypto.Keccak256Hash([]byte("MINTER_ROLE"))
+	pauserRole       = crypto.Keccak256Hash([]byte("PAUSER_ROLE"))
+	guardianRole     = crypto.Keccak256Hash([]byte("GUARDIAN_ROLE"))
+	freezerRole      = crypto.Keccak256Hash([]byte("FREEZER_ROLE"))
+	wiperRole        = crypto.Keccak256Hash([]byte("WIPER_ROLE"))
+	feeRecipientRole = crypto.Keccak256Hash([]byte("FEE_RECIPIENT_ROLE"))
+	snapshotterRole  = crypto.Keccak256Hash([]byte("SNAPSHOTTER_ROLE"))
+)
+
+func (s *ReserveSuite) TestRoleIds() {
+	for _, role := range []struct {
+		get      func(*bind.CallOpts) ([32]byte, error)
+		expected [32]byte
+	}{
+		{s.reserve.ADMINROLE, adminRole},
+		{s.reserve.MINTERROLE, minterRole},
+		{s.reserve.PAUSERROLE, pauserRole},
+		{s.reserve.GUARDIANROLE, guardianRole},
+		{s.reserve.FREEZERROLE, freezerRole},
+		{s.reserve.WIPERROLE, wiperRole},
+		{s.reserve.FEERECIPIENTROLE, feeRecipientRole},
+		{s.reserve.SNAPSHOTTERROLE, snapshotterRole},
+	} {
+		id, err := role.get(nil)
+		s.Require().NoError(err)
+		s.Equal(common.Hash(role.expected).Hex(), common.Hash(id).Hex())
+	}
+}
+
+// roleHolders are the accounts of a role matrix, each holding one role, but the stranger.
+type roleHolders struct {
+	admin, minter, pauser, guardian, freezer, wiper, stranger account
+}
+
+func (h roleHolders) all() []account {
+	return []account{h.admin, h.minter, h.pauser, h.guardian, h.freezer, h.wiper, h.stranger}
+}
+
+// setUpRoles gives each role to a different account, and funds a stranger that holds none.
+func (s *ReserveSuite) setUpRoles() roleHolders {
+	h := roleHolders{
+		admin:    s.owner,
+		minter:   s.account[1],
+		pauser:   s.account[2],
+		guardian: s.account[3],
+		freezer:  s.account[4],
+		wiper:    s.account[5],
+		stranger: s.newHolder(),
+	}
+	s.requireTx(s.sendRaw(s.account[0], h.stranger.address(), new(big.Int).Exp(bigInt(10), bigInt(17), nil), nil))
+	s.requireTx(s.reserve.ChangeMinter(s.signer, h.minter.address()))
+	s.requireTx(s.reserve.ChangePauser(s.signer, h.pauser.address()))
+	s.requireTx(s.reserve.ChangeGuardian(s.signer, h.guardian.address()))
+	s.requireTx(s.reserve.ChangeFreezer(s.signer, h.freezer.address()))
+	s.requireTx(s.reserve.ChangeWiper(s.signer, h.wiper.address()))
+	return h
+}
+
+func (s *ReserveSuite) TestRoleViews() {
+	h := s.setUpRoles()
+	holders := map[[32]byte]account{
+		adminRole:    h.admin,
+		minterRole:   h.minter,
+		pauserRole:   h.pauser,
+		guardianRole: h.guardian,
+		freezerRole:  h.freezer,
+		wiperRole:    h.wiper,
+	}
+	for role, holder := range holders {
+		got, err := s.reserve.RoleHolder(nil, role)
+		s.Require().NoError(err)
+		s.Equal(holder.address(), got)
+
+		for _, a := range h.all() {
+			has, err := s.reserve.HasRole(nil, role, a.address())
+			s.Require().NoError(err)
+			s.Equal(a == holder, has, "hasRole(%x, %v)", role, a.address().Hex())
+
+			// The admin may grant any role but its own; holders may hand theirs on, except the
+			// guardian.
+			expected := role != adminRole && (a == h.admin || (a == holder && role != guardianRole))
+			can, err := s.reserve.CanGrantRole(nil, role, a.address())
+			s.Require().NoError(err)
+			s.Equal(expected, can, "canGrantRole(%x, %v)", role, a.address().Hex())
+		}
+	}
+
+	// The fee recipient is still the deployer, and no one holds an unknown role.
+	has, err := s.reserve.HasRole(nil, feeRecipientRole, h.admin.address())
+	s.Require().NoError(err)
+	s.True(has)
+	unknown, err := s.reserve.RoleHolder(nil, crypto.Keccak256Hash([]byte("UNKNOWN_ROLE")))
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), unknown)
+	has, err = s.reserve.HasRole(nil, minterRole, zeroAddress())
+	s.Require().NoError(err)
+	s.False(has)
+}
+
+func (s *ReserveSuite) TestRoleMatrix() {
+	h := s.setUpRoles()
+	target := s.newHolder().address()
+	maxSupply, err := s.reserve.MaxSupply(nil)
+	s.Require().NoError(err)
+	mintCap, err := s.reserve.MintCap(nil)
+	s.Require().NoError(err)
+
+	// Each call leaves the roles as they were when it succeeds, so that the calls can be made
+	// one after another.
+	for _, c := range []struct {
+		name    string
+		call    func(*bind.TransactOpts) (*types.Transaction, error)
+		allowed []account
+	}{
+		{"changeMinter", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.ChangeMinter(o, h.minter.address())
+		}, []account{h.admin, h.minter}},
+		{"changePauser", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.ChangePauser(o, h.pauser.address())
+		}, []account{h.admin, h.pauser}},
+		{"changeGuardian", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.ChangeGuardian(o, h.guardian.address())
+		}, []account{h.admin}},
+		{"changeFreezer", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.ChangeFreezer(o, h.freezer.address())
+		}, []account{h.admin, h.freezer}},
+		{"changeWiper", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.ChangeWiper(o, h.wiper.address())
+		}, []account{h.admin, h.wiper}},
+		{"grantRole(minter)", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.GrantRole(o, minterRole, h.minter.address())
+		}, []account{h.admin, h.minter}},
+		{"grantRole(guardian)", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.GrantRole(o, guardianRole, h.guardian.address())
+		}, []account{h.admin}},
+		{"grantRole(admin)", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.GrantRole(o, adminRole, h.admin.address())
+		}, nil},
+		{"changeMaxSupply", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.ChangeMaxSupply(o, maxSupply)
+		}, []account{h.admin}},
+		{"changeMintCap", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.ChangeMintCap(o, mintCap)
+		}, []account{h.admin}},
+		{"changeRelayer", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.ChangeRelayer(o, zeroAddress())
+		}, []account{h.admin}},
+		{"changeForwarder", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.ChangeForwarder(o, zeroAddress())
+		}, []account{h.admin}},
+		{"changeTxFeeHelper", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.ChangeTxFeeHelper(o, zeroAddress())
+		}, []account{h.admin}},
+		{"changeTransferHook", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.ChangeTransferHook(o, zeroAddress())
+		}, []account{h.admin}},
+		{"changeChainId", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.ChangeChainId(o, permitChainID)
+		}, []account{h.admin}},
+		{"mint", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.Mint(o, target, bigInt(1))
+		}, []account{h.minter}},
+		{"freeze", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.Freeze(o, target)
+		}, []account{h.freezer}},
+		{"unfreeze", func(o *bind.TransactOpts) (*types.Transaction, error) {
+			return s.reserve.Unfreeze(o, target)
+		}, []account{h.freezer}},
+	} {
+		for _, a := range h.all() {
+			allowed := false
+			for _, b := range c.allowed {
+				allowed = allowed || a == b
+			}
+			tx, err := c.call(signer(a))
+			if allowed {
+				s.requireTx(tx, err)
+			} else {
+				s.requireTxFails(tx, err)
+			}
+		}
+	}
+	s.assertRSVBalance(target, bigInt(1))
+}
+
+func (s *ReserveSuite) TestPauseMatrix() {
+	h := s.setUpRoles()
+
+	for _, a := range []account{h.admin, h.minter, h.freezer, h.wiper, h.stranger} {
+		s.requireTxFails(s.reserve.Pause(signer(a)))
+	}
+	for _, pauser := range []account{h.pauser, h.guardian} {
+		s.requireTxWithStrictEvents(s.reserve.Pause(signer(pauser)))(
+			abi.ReservePaused{Account: pauser.address()},
+		)
+		for _, a := range []account{h.admin, h.minter, h.guardian, h.freezer, h.wiper, h.stranger} {
+			s.requireTxFails(s.reserve.Unpause(signer(a)))
+		}
+		s.requireTxWithStrictEvents(s.reserve.Unpause(signer(h.pauser)))(
+			abi.ReserveUnpaused{Account: h.pauser.address()},
+		)
+	}
+}
+
+func (s *ReserveSuite) TestPauseTransfersMatrix() {
+	h := s.setUpRoles()
+
+	for _, a := range []account{h.admin, h.minter, h.freezer, h.wiper, h.stranger} {
+		s.requireTxFails(s.reserve.PauseTransfers(signer(a)))
+	}
+	for _, pauser := range []account{h.pauser, h.guardian} {
+		s.requireTxWithStrictEvents(s.reserve.PauseTransfers(signer(pauser)))(
+			abi.ReserveTransfersPaused{Account: pauser.address()},
+		)
+		for _, a := range []account{h.admin, h.minter, h.guardian, h.freezer, h.wiper, h.stranger} {
+			s.requireTxFails(s.reserve.UnpauseTransfers(signer(a)))
+		}
+		s.requireTxWithStrictEvents(s.reserve.UnpauseTransfers(signer(h.pauser)))(
+			abi.ReserveTransfersUnpaused{Account: h.pauser.address()},
+		)
+	}
+}
+
+// TestTransfersPaused tests that pausing transfers stops every kind of transfer, but neither
+// approvals nor minting and burning, and starts no clock toward emergency redemption.
+func (s *ReserveSuite) TestTransfersPaused() {
+	h := s.setUpRoles()
+	holder, spender := s.account[2], s.account[3]
+	s.requireTx(s.reserve.Mint(signer(h.minter), holder.address(), bigInt(10)))
+
+	s.requireTx(s.reserve.PauseTransfers(signer(h.pauser)))
+	paused, err := s.reserve.TransfersPaused(nil)
+	s.Require().NoError(err)
+	s.True(paused)
+	paused, err = s.reserve.Paused(nil)
+	s.Require().NoError(err)
+	s.False(paused)
+	open, err := s.reserve.EmergencyRedemptionOpen(nil)
+	s.Require().NoError(err)
+	s.False(open)
+
+	s.requireTxFails(s.reserve.Transfer(signer(holder), spender.address(), bigInt(1)))
+	s.requireTxFails(s.reserve.TransferBatch(
+		signer(holder), []common.Address{spender.address()}, []*big.Int{bigInt(1)},
+	))
+	s.requireTx(s.reserve.Approve(signer(holder), spender.address(), bigInt(5)))
+	s.requireTxFails(s.reserve.TransferFrom(signer(spender), holder.address(), spender.address(), bigInt(1)))
+
+	// The minter mints and burns, so issuance and redemption go on.
+	s.requireTx(s.reserve.Mint(signer(h.minter), holder.address(), bigInt(1)))
+	s.requireTx(s.reserve.Approve(signer(holder), h.minter.address(), bigInt(1)))
+	s.requireTx(s.reserve.BurnFrom(signer(h.minter), holder.address(), bigInt(1)))
+	s.assertRSVBalance(holder.address(), bigInt(10))
+
+	s.requireTx(s.reserve.UnpauseTransfers(signer(h.pauser)))
+	s.requireTx(s.reserve.Transfer(signer(holder), spender.address(), bigInt(1)))
+	s.assertRSVBalance(spender.address(), bigInt(1))
+}
+
+func (s *ReserveSuite) TestGrantRole() {
+	h := s.setUpRoles()
+
+	// The admin grants a role, with the role's own event.
+	s.requireTxWithStrictEvents(s.reserve.GrantRole(s.signer, minterRole, h.stranger.address()))(
+		abi.ReserveMinterChanged{NewMinter: h.stranger.address()},
+	)
+	minter, err := s.reserve.Minter(nil)
+	s.Require().NoError(err)
+	s.Equal(h.stranger.address(), minter)
+	s.requireTxFails(s.reserve.Mint(signer(h.minter), h.minter.address(), bigInt(1)))
+	s.requireTx(s.reserve.Mint(signer(h.stranger), h.minter.address(), bigInt(1)))
+
+	// The new holder hands it on.
+	s.requireTxWithStrictEvents(s.reserve.GrantRole(signer(h.stranger), minterRole, h.minter.address()))(
+		abi.ReserveMinterChanged{NewMinter: h.minter.address()},
+	)
+
+	// The guardian can't hand its role on, and no one can grant an unknown role.
+	s.requireTxFails(s.reserve.GrantRole(signer(h.guardian), guardianRole, h.stranger.address()))
+	s.requireTxWithStrictEvents(s.reserve.GrantRole(s.signer, guardianRole, h.stranger.address()))(
+		abi.ReserveGuardianChanged{NewGuardian: h.stranger.address()},
+	)
+	s.requireTxFails(s.reserve.GrantRole(s.signer, crypto.Keccak256Hash([]byte("UNKNOWN_ROLE")), h.stranger.address()))
+	for _, role := range [][32]byte{freezerRole, wiperRole, feeRecipientRole, snapshotterRole, pauserRole} {
+		s.requireTx(s.reserve.GrantRole(s.signer, role, h.stranger.address()))
+		has, err := s.reserve.HasRole(nil, role, h.stranger.address())
+		s.Require().NoError(err)
+		s.True(has)
+	}
+}
+
+func (s *ReserveSuite) TestRevokeRole() {
+	h := s.setUpRoles()
+
+	// Only what is held can be revoked.
+	s.requireTxFails(s.reserve.RevokeRole(s.signer, freezerRole, h.stranger.address()))
+	s.requireTxFails(s.reserve.RevokeRole(signer(h.stranger), freezerRole, h.freezer.address()))
+	s.requireTxFails(s.reserve.RevokeRole(signer(h.guardian), guardianRole, h.guardian.address()))
+	s.requireTxFails(s.reserve.RevokeRole(s.signer, adminRole, h.admin.address()))
+
+	s.requireTxWithStrictEvents(s.reserve.RevokeRole(s.signer, freezerRole, h.freezer.address()))(
+		abi.ReserveFreezerChanged{NewFreezer: zeroAddress()},
+	)
+	s.requireTxFails(s.reserve.Freeze(signer(h.freezer), h.stranger.address()))
+	s.requireTxWithStrictEvents(s.reserve.RevokeRole(s.signer, guardianRole, h.guardian.address()))(
+		abi.ReserveGuardianChanged{NewGuardian: zeroAddress()},
+	)
+	s.requireTxFails(s.reserve.Pause(signer(h.guardian)))
+
+	// A holder may revoke its own role.
+	s.requireTxWithStrictEvents(s.reserve.RevokeRole(signer(h.wiper), wiperRole, h.wiper.address()))(
+		abi.ReserveWiperChanged{NewWiper: zeroAddress()},
+	)
+	for _, role := range [][32]byte{freezerRole, guardianRole, wiperRole} {
+		holder, err := s.reserve.RoleHolder(nil, role)
+		s.Require().NoError(err)
+		s.Equal(zeroAddress(), holder)
+	}
+}
+
+func (s *ReserveSuite) TestRenounceRole() {
+	h := s.setUpRoles()
+
+	// Even the guardian may give up its role; the admin gives up its role only by
+	// renouncing ownership.
+	s.requireTxWithStrictEvents(s.reserve.RenounceRole(signer(h.guardian), guardianRole))(
+		abi.ReserveGuardianChanged{NewGuardian: zeroAddress()},
+	)
+	s.requireTxFails(s.reserve.RenounceRole(signer(h.guardian), guardianRole))
+	s.requireTxFails(s.reserve.RenounceRole(signer(h.stranger), minterRole))
+	s.requireTxFails(s.reserve.RenounceRole(s.signer, adminRole))
+
+	owner, err := s.reserve.Owner(nil)
+	s.Require().NoError(err)
+	s.Equal(h.admin.address(), owner)
+	minter, err := s.reserve.Minter(nil)
+	s.Require().NoError(err)
+	s.Equal(h.minter.address(), minter)
+}
+
+func (s *ReserveSuite) TestAdminChangesByNomination() {
+	h := s.setUpRoles()
+	newAdmin := h.stranger
+
+	s.requireTxWithStrictEvents(s.reserve.NominateNewOwner(s.signer, newAdmin.address()))(
+		abi.ReserveNewOwnerNominated{PreviousOwner: h.admin.address(), Nominee: newAdmin.address()},
+	)
+
+	// Nominated is not yet admin.
+	has, err := s.reserve.HasRole(nil, adminRole, newAdmin.address())
+	s.Require().NoError(err)
+	s.False(has)
+	s.requireTxFails(s.reserve.ChangeMaxSupply(signer(newAdmin), bigInt(1)))
+
+	s.requireTxWithStrictEvents(s.reserve.AcceptOwnership(signer(newAdmin)))(
+		abi.ReserveOwnershipTransferred{PreviousOwner: h.admin.address(), NewOwner: newAdmin.address()},
+	)
+	has, err = s.reserve.HasRole(nil, adminRole, newAdmin.address())
+	s.Require().NoError(err)
+	s.True(has)
+	has, err = s.reserve.HasRole(nil, adminRole, h.admin.address())
+	s.Require().NoError(err)
+	s.False(has)
+
+	// The old admin lost its powers, and the new one has them, over every other role too.
+	s.requireTxFails(s.reserve.ChangeMinter(s.signer, h.admin.address()))
+	s.requireTxFails(s.reserve.ChangeGuardian(s.signer, h.admin.address()))
+	s.requireTx(s.reserve.ChangeGuardian(signer(newAdmin), h.admin.address()))
+	s.requireTx(s.reserve.GrantRole(signer(newAdmin), minterRole, newAdmin.address()))
+	s.requireTx(s.reserve.Mint(signer(newAdmin), newAdmin.address(), bigInt(1)))
+}
+
+func (s *ReserveSuite) TestRolesAfterUpgrade() {
+	// BeforeTest upgraded from PreviousReserve, and then gave the deployer its roles with the
+	// per-role setters, as a migration plan does.
+	deployer := s.owner.address()
+	for _, role := range [][32]byte{adminRole, minterRole, pauserRole, freezerRole, feeRecipientRole} {
+		has, err := s.reserve.HasRole(nil, role, deployer)
+		s.Require().NoError(err)
+		s.True(has, "deployer lacks role %x", role)
+	}
+	for _, role := range [][32]byte{guardianRole, wiperRole, snapshotterRole} {
+		holder, err := s.reserve.RoleHolder(nil, role)
+		s.Require().NoError(err)
+		s.Equal(zeroAddress(), holder)
+	}
+}
+
+///////////////////////
+
+// ERC-1363 hook return values.
+var (
+	erc1363Received = [4]byte{0x88, 0xa7, 0xca, 0x5c}
+	erc1363Approved = [4]byte{0x7b, 0x04, 0xa2, 0xd0}
+)
+
+// deployERC1363Receiver deploys a MockERC1363Receiver that answers its hooks with retval, or
+// reverts if reverts is set.
+func (s *ReserveSuite) deployERC1363Receiver(retval [4]byte, reverts bool) (common.Address, *abi.MockERC1363Receiver) {
+	address, tx, receiver, err := abi.DeployMockERC1363Receiver(s.signer, s.node, retval, reverts)
+	s.logParsers[address] = receiver
+	s.requireTx(tx, err)
+	return address, receiver
+}
+
+// andCall sends from's call to the Reserve's method with the given signature, such as
+// "transferAndCall(address,uint256,bytes)". The bindings tell overloaded methods apart only by
+// the order solc lists them in, so this finds the method by its signature instead.
+func (s *ReserveSuite) andCall(from account, signature string, args ...interface{}) (*types.Transaction, error) {
+	parsed, err := ethabi.JSON(strings.NewReader(abi.ReserveABI))
+	s.Require().NoError(err)
+	for _, method := range parsed.Methods {
+		if method.Sig() == signature {
+			input, err := method.Inputs.Pack(args...)
+			s.Require().NoError(err)
+			return s.sendRaw(from, s.reserveAddress, bigInt(0), append(method.Id(), input...))
+		}
+	}
+	s.Require().FailNow("no such method", signature)
+	return nil, nil
+}
+
+func (s *ReserveSuite) TestSupportsInterface() {
+	s.assertInterfaces(abi.ReserveABI, s.reserve.SupportsInterface, map[string][]string{
+		"ERC-165": erc165,
+		"ERC-20": {
+			"totalSupply()",
+			"balanceOf(address)",
+			"transfer(address,uint256)",
+			"transferFrom(address,address,uint256)",
+			"approve(address,uint256)",
+			"allowance(address,address)",
+		},
+		"ERC-20 metadata": {"name()", "symbol()", "decimals()"},
+		"EIP-2612": {
+			"permit(address,address,uint256,uint256,uint8,bytes32,bytes32)",
+			"nonces(address)",
+			"DOMAIN_SEPARATOR()",
+		},
+		"EIP-2771": {"isTrustedForwarder(address)"},
+		"EIP-3009": {
+			"transferWithAuthorization(address,address,uint256,uint256,uint256,bytes32,uint8,bytes32,bytes32)",
+			"receiveWithAuthorization(address,address,uint256,uint256,uint256,bytes32,uint8,bytes32,bytes32)",
+			"cancelAuthorization(address,bytes32,uint8,bytes32,bytes32)",
+			"authorizationState(address,bytes32)",
+		},
+		"ERC-1363": {
+			"transferAndCall(address,uint256)",
+			"transferAndCall(address,uint256,bytes)",
+			"transferFromAndCall(address,address,uint256)",
+			"transferFromAndCall(address,address,uint256,bytes)",
+			"approveAndCall(address,uint256)",
+			"approveAndCall(address,uint256,bytes)",
+		},
+		"ERC-1822": {"proxiableUUID()"},
+		"ERC-3156 lender": {
+			"maxFlashLoan(address)",
+			"flashFee(address,uint256)",
+			"flashLoan(address,address,uint256,bytes)",
+		},
+	})
+
+	// Nor does it claim what it doesn't implement, such as ERC-721.
+	supported, err := s.reserve.SupportsInterface(nil, [4]byte{0x80, 0xac, 0x58, 0xcd})
+	s.Require().NoError(err)
+	s.False(supported)
+}
+
+func (s *ReserveSuite) TestTransferAndCall() {
+	sender := s.account[1]
+	receiverAddress, _ := s.deployERC1363Receiver(erc1363Received, false)
+	s.requireTx(s.reserve.Mint(s.signer, sender.address(), bigInt(100)))
+
+	// The hook sees the tokens already received.
+	s.requireTxWithStrictEvents(s.andCall(sender, "transferAndCall(address,uint256)", receiverAddress, bigInt(40)))(
+		abi.ReserveTransfer{From: sender.address(), To: receiverAddress, Value: bigInt(40)},
+		abi.MockERC1363ReceiverReceived{
+			Operator: sender.address(), From: sender.address(), Value: bigInt(40), Data: []byte{}, Balance: bigInt(40),
+		},
+	)
+
+	data := []byte("order 17")
+	s.requireTxWithStrictEvents(
+		s.andCall(sender, "transferAndCall(address,uint256,bytes)", receiverAddress, bigInt(60), data),
+	)(
+		abi.ReserveTransfer{From: sender.address(), To: receiverAddress, Value: bigInt(60)},
+		abi.MockERC1363ReceiverReceived{
+			Operator: sender.address(), From: sender.address(), Value: bigInt(60), Data: data, Balance: bigInt(100),
+		},
+	)
+	s.assertRSVBalance(sender.address(), bigInt(0))
+	s.assertRSVBalance(receiverAddress, bigInt(100))
+}
+
+func (s *ReserveSuite) TestTransferFromAndCall() {
+	holder := s.account[1]
+	spender := s.account[2]
+	receiverAddress, _ := s.deployERC1363Receiver(erc1363Received, false)
+	s.requireTx(s.reserve.Mint(s.signer, holder.address(), bigInt(100)))
+	s.requireTx(s.reserve.Approve(signer(holder), spender.address(), bigInt(100)))
+
+	// The hook is told both who moved the tokens and whose they were.
+	s.requireTxWithStrictEvents(
+		s.andCall(spender, "transferFromAndCall(address,address,uint256)", holder.address(), receiverAddress, bigInt(40)),
+	)(
+		abi.ReserveTransfer{From: holder.address(), To: receiverAddress, Value: bigInt(40)},
+		abi.ReserveApproval{Owner: holder.address(), Spender: spender.address(), Value: bigInt(60)},
+		abi.MockERC1363ReceiverReceived{
+			Operator: spender.address(), From: holder.address(), Value: bigInt(40), Data: []byte{}, Balance: bigInt(40),
+		},
+	)
+
+	data := []byte{0xde, 0xad}
+	s.requireTxWithStrictEvents(s.andCall(
+		spender, "transferFromAndCall(address,address,uint256,bytes)", holder.address(), receiverAddress, bigInt(60), data,
+	))(
+		abi.ReserveTransfer{From: holder.address(), To: receiverAddress, Value: bigInt(60)},
+		abi.ReserveApproval{Owner: holder.address(), Spender: spender.address(), Value: bigInt(0)},
+		abi.MockERC1363ReceiverReceived{
+			Operator: spender.address(), From: holder.address(), Value: bigInt(60), Data: data, Balance: bigInt(100),
+		},
+	)
+	s.assertRSVBalance(receiverAddress, bigInt(100))
+	s.assertRSVAllowance(holder.address(), spender.address(), bigInt(0))
+
+	// It spends only an allowance.
+	s.requireTx(s.reserve.Mint(s.signer, holder.address(), bigInt(1)))
+	s.requireTxFails(s.andCall(
+		spender, "transferFromAndCall(address,address,uint256)", holder.address(), receiverAddress, bigInt(1),
+	))
+	s.assertRSVBalance(holder.address(), bigInt(1))
+}
+
+func (s *ReserveSuite) TestApproveAndCall() {
+	holder := s.account[1]
+	spenderAddress, _ := s.deployERC1363Receiver(erc1363Approved, false)
+
+	// The hook sees the allowance already set.
+	s.requireTxWithStrictEvents(s.andCall(holder, "approveAndCall(address,uint256)", spenderAddress, bigInt(40)))(
+		abi.ReserveApproval{Owner: holder.address(), Spender: spenderAddress, Value: bigInt(40)},
+		abi.MockERC1363ReceiverApproved{Owner: holder.address(), Value: bigInt(40), Data: []byte{}, Allowance: bigInt(40)},
+	)
+
+	data := []byte("subscription")
+	s.requireTxWithStrictEvents(
+		s.andCall(holder, "approveAndCall(address,uint256,bytes)", spenderAddress, bigInt(70), data),
+	)(
+		abi.ReserveApproval{Owner: holder.address(), Spender: spenderAddress, Value: bigInt(70)},
+		abi.MockERC1363ReceiverApproved{Owner: holder.address(), Value: bigInt(70), Data: data, Allowance: bigInt(70)},
+	)
+	s.assertRSVAllowance(holder.address(), spenderAddress, bigInt(70))
+}
+
+func (s *ReserveSuite) TestAndCallRevertInHook() {
+	holder := s.account[1]
+	spender := s.account[2]
+	s.requireTx(s.reserve.Mint(s.signer, holder.address(), bigInt(100)))
+	s.requireTx(s.reserve.Approve(signer(holder), spender.address(), bigInt(100)))
+
+	// A hook that reverts, or that answers with anything but its selector, undoes the whole call.
+	reverting, _ := s.deployERC1363Receiver(erc1363Received, true)
+	wrongAnswer, _ := s.deployERC1363Receiver(erc1363Approved, false)
+	for _, to := range []common.Address{reverting, wrongAnswer} {
+		s.requireTxFails(s.andCall(holder, "transferAndCall(address,uint256)", to, bigInt(1)))
+		s.requireTxFails(s.andCall(holder, "transferAndCall(address,uint256,bytes)", to, bigInt(1), []byte{1}))
+		s.requireTxFails(s.andCall(
+			spender, "transferFromAndCall(address,address,uint256)", holder.address(), to, bigInt(1),
+		))
+		s.requireTxFails(s.andCall(
+			spender, "transferFromAndCall(address,address,uint256,bytes)", holder.address(), to, bigInt(1), []byte{1},
+		))
+		s.assertRSVBalance(to, bigInt(0))
+	}
+	s.assertRSVBalance(holder.address(), bigInt(100))
+	s.assertRSVAllowance(holder.address(), spender.address(), bigInt(100))
+
+	reverting, _ = s.deployERC1363Receiver(erc1363Approved, true)
+	wrongAnswer, _ = s.deployERC1363Receiver(erc1363Received, false)
+	for _, to := range []common.Address{reverting, wrongAnswer} {
+		s.requireTxFails(s.andCall(holder, "approveAndCall(address,uint256)", to, bigInt(1)))
+		s.requireTxFails(s.andCall(holder, "approveAndCall(address,uint256,bytes)", to, bigInt(1), []byte{1}))
+		s.assertRSVAllowance(holder.address(), to, bigInt(0))
+	}
+}
+
+func (s *ReserveSuite) TestAndCallToNonContract() {
+	holder := s.account[1]
+	spender := s.account[2]
+	s.requireTx(s.reserve.Mint(s.signer, holder.address(), bigInt(100)))
+	s.requireTx(s.reserve.Approve(signer(holder), spender.address(), bigInt(100)))
+
+	// Neither an account without code nor a contract without the hooks can receive.
+	for _, to := range []common.Address{s.account[3].address(), s.eternalStorageAddress} {
+		s.requireTxFails(s.andCall(holder, "transferAndCall(address,uint256)", to, bigInt(1)))
+		s.requireTxFails(s.andCall(
+			spender, "transferFromAndCall(address,address,uint256)", holder.address(), to, bigInt(1),
+		))
+		s.requireTxFails(s.andCall(holder, "approveAndCall(address,uint256)", to, bigInt(1)))
+		s.assertRSVBalance(to, bigInt(0))
+		s.assertRSVAllowance(holder.address(), to, bigInt(0))
+	}
+	s.assertRSVBalance(holder.address(), bigInt(100))
+}
+
+func (s *ReserveSuite) TestAndCallFailsWhenPausedOrFrozen() {
+	holder := s.account[1]
+	receiverAddress, _ := s.deployERC1363Receiver(erc1363Received, false)
+	s.requireTx(s.reserve.Mint(s.signer, holder.address(), bigInt(100)))
+
+	s.requireTx(s.reserve.Pause(s.signer))
+	s.requireTxFails(s.andCall(holder, "transferAndCall(address,uint256)", receiverAddress, bigInt(1)))
+	s.requireTx(s.reserve.Unpause(s.signer))
+
+	s.requireTx(s.reserve.Freeze(s.signer, receiverAddress))
+	s.requireTxFails(s.andCall(holder, "transferAndCall(address,uint256)", receiverAddress, bigInt(1)))
+	s.assertRSVBalance(holder.address(), bigInt(100))
+}
+
+///////////////////////
+
+// enableFlashMints lets flash loans of up to cap attoRSV be made, for fee BPS.
+func (s *ReserveSuite) enableFlashMints(cap, fee *big.Int) {
+	s.requireTx(s.reserve.ChangeFlashMintCap(s.signer, cap))
+	s.requireTx(s.reserve.ChangeFlashMintFee(s.signer, fee))
+}
+
+func (s *ReserveSuite) TestFlashLoan() {
+	borrowerAddress, borrower := s.deployFlashBorrower(zeroAddress())
+	feeRecipient := s.account[3].address()
+	s.requireTx(s.reserve.ChangeFeeRecipient(s.signer, feeRecipient))
+	s.enableFlashMints(bigInt(1000), bigInt(100)) // 1%
+
+	// The borrower pays the fee out of what it already holds.
+	amount, fee := bigInt(1000), bigInt(10)
+	s.requireTx(s.reserve.Mint(s.signer, borrowerAddress, bigInt(25)))
+
+	s.requireTxWithStrictEvents(borrower.Borrow(s.signer, amount, flashRepay))(
+		mintingTransfer(borrowerAddress, amount),
+		abi.MockFlashBorrowerBorrowed{
+			Initiator: borrowerAddress,
+			Token:     s.reserveAddress,
+			Amount:    amount,
+			Fee:       fee,
+			Balance:   bigInt(1025),
+		},
+		abi.ReserveApproval{Owner: borrowerAddress, Spender: s.reserveAddress, Value: bigInt(1010)},
+		abi.ReserveApproval{Owner: borrowerAddress, Spender: s.reserveAddress, Value: bigInt(0)},
+		burningTransfer(borrowerAddress, amount),
+		abi.ReserveTransfer{From: borrowerAddress, To: feeRecipient, Value: fee},
+		abi.ReserveFlashMinted{
+			Receiver: borrowerAddress, Initiator: borrowerAddress, Value: amount, Fee: fee,
+		},
+	)
+
+	// Only the fee has moved, and the loan didn't count against the mint cap.
+	s.assertRSVBalance(borrowerAddress, bigInt(15))
+	s.assertRSVBalance(feeRecipient, fee)
+	s.assertRSVTotalSupply(bigInt(25))
+	s.assertMintable(maxUint256())
+	flashMinting, err := s.reserve.FlashMinting(nil)
+	s.Require().NoError(err)
+	s.False(flashMinting)
+
+	// Fees round down, so a small enough loan is free.
+	s.requireTxWithStrictEvents(borrower.Borrow(s.signer, bigInt(99), flashRepay))(
+		mintingTransfer(borrowerAddress, bigInt(99)),
+		abi.MockFlashBorrowerBorrowed{
+			Initiator: borrowerAddress,
+			Token:     s.reserveAddress,
+			Amount:    bigInt(99),
+			Fee:       bigInt(0),
+			Balance:   bigInt(114),
+		},
+		abi.ReserveApproval{Owner: borrowerAddress, Spender: s.reserveAddress, Value: bigInt(99)},
+		abi.ReserveApproval{Owner: borrowerAddress, Spender: s.reserveAddress, Value: bigInt(0)},
+		burningTransfer(borrowerAddress, bigInt(99)),
+		abi.ReserveFlashMinted{
+			Receiver: borrowerAddress, Initiator: borrowerAddress, Value: bigInt(99), Fee: bigInt(0),
+		},
+	)
+	s.assertRSVBalance(borrowerAddress, bigInt(15))
+}
+
+func (s *ReserveSuite) TestFlashLoanFromAnotherInitiator() {
+	borrowerAddress, _ := s.deployFlashBorrower(zeroAddress())
+	s.enableFlashMints(bigInt(1000), bigInt(0))
+	initiator := s.account[4]
+	repay := common.LeftPadBytes([]byte{flashRepay}, 32)
+
+	s.requireTx(s.reserve.FlashLoan(signer(initiator), borrowerAddress, s.reserveAddress, bigInt(500), repay))(
+		abi.ReserveFlashMinted{
+			Receiver: borrowerAddress, Initiator: initiator.address(), Value: bigInt(500), Fee: bigInt(0),
+		},
+	)
+
+	// Flash loans are only of RSV.
+	s.requireTxFails(s.reserve.FlashLoan(signer(initiator), borrowerAddress, s.eternalStorageAddress, bigInt(500), repay))
+	s.assertRSVTotalSupply(bigInt(0))
+}
+
+func (s *ReserveSuite) TestFlashLoanMustBeRepaid() {
+	borrowerAddress, borrower := s.deployFlashBorrower(zeroAddress())
+	s.enableFlashMints(bigInt(1000), bigInt(100))
+
+	// Without the fee, without enough RSV to pay it, or without accepting the loan, it fails.
+	s.requireTx(s.reserve.Mint(s.signer, borrowerAddress, bigInt(5)))
+	s.requireTxFails(borrower.Borrow(s.signer, bigInt(1000), flashRepayAmountOnly))
+	s.requireTxFails(borrower.Borrow(s.signer, bigInt(1000), flashRepay))
+	s.requireTxFails(borrower.Borrow(s.signer, bigInt(1000), flashRefuse))
+	s.assertRSVBalance(borrowerAddress, bigInt(5))
+	s.assertRSVTotalSupply(bigInt(5))
+
+	// With enough RSV for the fee, it works.
+	s.requireTx(s.reserve.Mint(s.signer, borrowerAddress, bigInt(5)))
+	s.requireTx(borrower.Borrow(s.signer, bigInt(1000), flashRepay))
+	s.assertRSVBalance(borrowerAddress, bigInt(0))
+}
+
+func (s *ReserveSuite) TestFlashLoanCap() {
+	_, borrower := s.deployFlashBorrower(zeroAddress())
+
+	// There is no cap until the owner sets one.
+	s.requireTxFails(borrower.Borrow(s.signer, bigInt(1), flashRepay))
+
+	s.enableFlashMints(bigInt(1000), bigInt(0))
+	s.requireTxFails(borrower.Borrow(s.signer, bigInt(1001), flashRepay))
+	s.requireTx(borrower.Borrow(s.signer, bigInt(1000), flashRepay))
+
+	// A loan can take the supply up to maxSupply, but not past it.
+	s.requireTx(s.reserve.Mint(s.signer, s.account[1].address(), bigInt(100)))
+	s.requireTx(s.reserve.ChangeMaxSupply(s.signer, bigInt(600)))
+	s.requireTxFails(borrower.Borrow(s.signer, bigInt(501), flashRepay))
+	s.requireTx(borrower.Borrow(s.signer, bigInt(500), flashRepay))
+}
+
+func (s *ReserveSuite) TestMaxFlashLoanAndFlashFee() {
+	maxFlashLoan := func(token common.Address) string {
+		max, err := s.reserve.MaxFlashLoan(nil, token)
+		s.Require().NoError(err)
+		return max.String()
+	}
+	s.Equal("0", maxFlashLoan(s.reserveAddress))
+
+	s.enableFlashMints(bigInt(1000), bigInt(25))
+	s.Equal("1000", maxFlashLoan(s.reserveAddress))
+	s.Equal("0", maxFlashLoan(s.eternalStorageAddress))
+
+	// Less, if maxSupply leaves less room.
+	s.requireTx(s.reserve.Mint(s.signer, s.account[1].address(), bigInt(100)))
+	s.requireTx(s.reserve.ChangeMaxSupply(s.signer, bigInt(600)))
+	s.Equal("500", maxFlashLoan(s.reserveAddress))
+	s.requireTx(s.reserve.Mint(s.signer, s.account[1].address(), bigInt(500)))
+	s.Equal("0", maxFlashLoan(s.reserveAddress))
+
+	// None while paused.
+	s.requireTx(s.reserve.ChangeMaxSupply(s.signer, maxUint256()))
+	s.requireTx(s.reserve.Pause(s.signer))
+	s.Equal("0", maxFlashLoan(s.reserveAddress))
+
+	fee, err := s.reserve.FlashFee(nil, s.reserveAddress, bigInt(1000))
+	s.Require().NoError(err)
+	s.Equal("2", fee.String())
+	_, err = s.reserve.FlashFee(nil, s.eternalStorageAddress, bigInt(1000))
+	s.Error(err)
+}
+
+func (s *ReserveSuite) TestFlashLoanFailsWhenPausedOrFrozen() {
+	borrowerAddress, borrower := s.deployFlashBorrower(zeroAddress())
+	s.enableFlashMints(bigInt(1000), bigInt(0))
+
+	s.requireTx(s.reserve.Pause(s.signer))
+	s.requireTxFails(borrower.Borrow(s.signer, bigInt(1), flashRepay))
+	s.requireTx(s.reserve.Unpause(s.signer))
+
+	s.requireTx(s.reserve.Freeze(s.signer, borrowerAddress))
+	s.requireTxFails(borrower.Borrow(s.signer, bigInt(1), flashRepay))
+	s.requireTx(s.reserve.Unfreeze(s.signer, borrowerAddress))
+
+	s.requireTx(borrower.Borrow(s.signer, bigInt(1), flashRepay))
+}
+
+// TestFlashLoanLocksMinting tests that no loan can be taken out during another, and that not
+// even the minter can mint or burn while one is out.
+func (s *ReserveSuite) TestFlashLoanLocksMinting() {
+	borrowerAddress, borrower := s.deployFlashBorrower(zeroAddress())
+	s.enableFlashMints(bigInt(1000), bigInt(0))
+	s.requireTx(s.reserve.ChangeMinter(s.signer, borrowerAddress))
+
+	s.requireTxFails(borrower.Borrow(s.signer, bigInt(500), flashBorrowAgain))
+	s.requireTxFails(borrower.Borrow(s.signer, bigInt(500), flashMint))
+	s.requireTxFails(borrower.Borrow(s.signer, bigInt(500), flashBurn))
+	s.assertRSVTotalSupply(bigInt(0))
+
+	// The same minter can mint and burn outside of a loan.
+	s.requireTx(s.reserve.ChangeMinter(s.signer, s.owner.address()))
+	s.requireTx(s.reserve.Mint(s.signer, borrowerAddress, bigInt(1)))
+	s.requireTx(borrower.Borrow(s.signer, bigInt(500), flashRepay))
+	s.assertRSVTotalSupply(bigInt(1))
+}
+
+///////////////////////
+
+// implementationSlot is the ERC-1967 slot that a ReserveProxy keeps its implementation in.
+var implementationSlot = common.BigToHash(
+	bigInt(0).Sub(crypto.Keccak256Hash([]byte("eip1967.proxy.implementation")).Big(), bigInt(1)),
+)
+
+// deployReserveImplementation deploys a Reserve to put behind a ReserveProxy.
+func (s *ReserveSuite) deployReserveImplementation() common.Address {
+	address, tx, implementation, err := abi.DeployReserve(s.signer, s.node)
+	s.logParsers[address] = implementation
+	s.requireTx(tx, err)
+	return address
+}
+
+// deployReserveProxy deploys a ReserveProxy in front of the Reserve at implementation,
+// initialized by s.owner, and returns the Reserve binding at the proxy's address: the Reserve's
+// bindings work unchanged there, and parse the proxy's own events too.
+func (s *ReserveSuite) deployReserveProxy(implementation common.Address) (common.Address, *abi.Reserve) {
+	address, tx, _, err := abi.DeployReserveProxy(s.signer, s.node, implementation, s.reserveCalldata("initialize"))
+	proxied, bindErr := abi.NewReserve(address, s.node)
+	s.Require().NoError(bindErr)
+	s.logParsers[address] = proxied
+	s.requireTxWithStrictEvents(tx, err)(
+		abi.ReserveUpgraded{Implementation: implementation},
+		abi.ReserveOwnershipTransferred{PreviousOwner: zeroAddress(), NewOwner: s.owner.address()},
+	)
+	return address, proxied
+}
+
+func (s *ReserveSuite) TestProxyInitialize() {
+	implementation := s.deployReserveImplementation()
+	proxyAddress, proxied := s.deployReserveProxy(implementation)
+
+	// The proxy's fields are set as the constructor sets a Reserve's.
+	owner, err := proxied.Owner(nil)
+	s.Require().NoError(err)
+	s.Equal(s.owner.address(), owner)
+	pauser, err := proxied.Pauser(nil)
+	s.Require().NoError(err)
+	s.Equal(s.owner.address(), pauser)
+	feeRecipient, err := proxied.FeeRecipient(nil)
+	s.Require().NoError(err)
+	s.Equal(s.owner.address(), feeRecipient)
+	maxSupply, err := proxied.MaxSupply(nil)
+	s.Require().NoError(err)
+	s.Equal(maxUint256().String(), maxSupply.String())
+	mintCap, err := proxied.MintCap(nil)
+	s.Require().NoError(err)
+	s.Equal(maxUint256().String(), mintCap.String())
+	paused, err := proxied.Paused(nil)
+	s.Require().NoError(err)
+	s.True(paused)
+	period, err := proxied.NominationPeriod(nil)
+	s.Require().NoError(err)
+	s.Equal(seconds(30*24*time.Hour).String(), period.String())
+	found, err := proxied.Implementation(nil)
+	s.Require().NoError(err)
+	s.Equal(implementation, found)
+
+	// The proxy keeps the implementation in the ERC-1967 slot.
+	slot, err := s.node.StorageAt(context.Background(), proxyAddress, implementationSlot, nil)
+	s.Require().NoError(err)
+	s.Equal(implementation, common.BytesToAddress(slot))
+
+	// Neither the proxy nor the implementation can be initialized again.
+	s.requireTxFails(proxied.Initialize(signer(s.account[2])))
+	impl, err := abi.NewReserve(implementation, s.node)
+	s.Require().NoError(err)
+	s.requireTxFails(impl.Initialize(signer(s.account[2])))
+	owner, err = proxied.Owner(nil)
+	s.Require().NoError(err)
+	s.Equal(s.owner.address(), owner)
+
+	// Only the implementation reports the slot, and a proxy can't front anything else.
+	uuid, err := impl.ProxiableUUID(nil)
+	s.Require().NoError(err)
+	s.Equal(implementationSlot.Hex(), common.Hash(uuid).Hex())
+	_, err = proxied.ProxiableUUID(nil)
+	s.Error(err)
+	for _, notImplementation := range []common.Address{proxyAddress, s.eternalStorageAddress, s.account[3].address()} {
+		_, tx, _, err := abi.DeployReserveProxy(s.signer, s.node, notImplementation, s.reserveCalldata("initialize"))
+		s.requireTxFails(tx, err)
+	}
+}
+
+func (s *ReserveSuite) TestProxyRequiresInitialization() {
+	// A proxy deployed without initializing would be anyone's to initialize, and own.
+	implementation := s.deployReserveImplementation()
+	_, tx, _, err := abi.DeployReserveProxy(s.signer, s.node, implementation, nil)
+	s.requireTxFails(tx, err)
+}
+
+func (s *ReserveSuite) TestProxyUpgrade() {
+	holder, spender, frozen, guardian := s.account[1], s.account[2], s.account[3], s.account[4]
+	s.requireTx(s.reserve.Mint(s.signer, holder.address(), bigInt(100)))
+	s.requireTx(s.reserve.Approve(signer(holder), spender.address(), bigInt(30)))
+
+	// Move the eternal storage from the Reserve deployed on its own to one behind a proxy, as
+	// for any other replacement.
+	proxyAddress, proxied := s.deployReserveProxy(s.deployReserveImplementation())
+	s.requireTx(s.reserve.NominateNewOwner(s.signer, proxyAddress))
+	s.requireTx(proxied.AcceptUpgrade(s.signer, s.reserveAddress))(
+		abi.ReserveEternalStorageTransferred{NewReserveAddress: proxyAddress},
+	)
+
+	// Set it up, and then upgrade it.
+	s.requireTx(proxied.ChangeMinter(s.signer, s.owner.address()))
+	s.requireTx(proxied.ChangeFreezer(s.signer, s.owner.address()))
+	s.requireTx(proxied.ChangeGuardian(s.signer, guardian.address()))
+	s.requireTx(proxied.ChangeMintCap(s.signer, bigInt(1000)))
+	s.requireTx(proxied.Mint(s.signer, frozen.address(), bigInt(5)))
+	s.requireTx(proxied.Freeze(s.signer, frozen.address()))
+	version, err := proxied.Version(nil)
+	s.Require().NoError(err)
+	s.Equal("2.1", version)
+
+	v2Address, tx, _, err := abi.DeployReserveV2(s.signer, s.node)
+	s.requireTx(tx, err)
+	s.requireTxWithStrictEvents(proxied.UpgradeTo(s.signer, v2Address))(
+		abi.ReserveUpgraded{Implementation: v2Address},
+	)
+
+	// The token keeps its address, and everything in it survives.
+	v2, err := abi.NewReserveV2(proxyAddress, s.node)
+	s.Require().NoError(err)
+	s.logParsers[proxyAddress] = v2
+	version, err = v2.Version(nil)
+	s.Require().NoError(err)
+	s.Equal("2.2", version)
+	implementation, err := v2.Implementation(nil)
+	s.Require().NoError(err)
+	s.Equal(v2Address, implementation)
+
+	for _, c := range []struct {
+		holder  common.Address
+		balance int
+	}{{holder.address(), 100}, {frozen.address(), 5}, {spender.address(), 0}} {
+		balance, err := v2.BalanceOf(nil, c.holder)
+		s.Require().NoError(err)
+		s.Equal(bigInt(uint32(c.balance)).String(), balance.String())
+	}
+	supply, err := v2.TotalSupply(nil)
+	s.Require().NoError(err)
+	s.Equal("105", supply.String())
+	allowance, err := v2.Allowance(nil, holder.address(), spender.address())
+	s.Require().NoError(err)
+	s.Equal("30", allowance.String())
+
+	for _, role := range []struct {
+		id       common.Hash
+		expected common.Address
+	}{
+		{adminRole, s.owner.address()},
+		{minterRole, s.owner.address()},
+		{pauserRole, s.owner.address()},
+		{guardianRole, guardian.address()},
+		{freezerRole, s.owner.address()},
+	} {
+		holder, err := v2.RoleHolder(nil, role.id)
+		s.Require().NoError(err)
+		s.Equal(role.expected, holder, role.id.Hex())
+	}
+	isFrozen, err := v2.Frozen(nil, frozen.address())
+	s.Require().NoError(err)
+	s.True(isFrozen)
+	mintable, err := v2.MintableInWindow(nil)
+	s.Require().NoError(err)
+	s.Equal("995", mintable.String())
+
+	// And it still works.
+	s.requireTxWithStrictEvents(v2.TransferFrom(signer(spender), holder.address(), spender.address(), bigInt(30)))(
+		abi.ReserveV2Transfer{From: holder.address(), To: spender.address(), Value: bigInt(30)},
+		abi.ReserveV2Approval{Owner: holder.address(), Spender: spender.address(), Value: bigInt(0)},
+	)
+	s.requireTxFails(v2.Transfer(signer(frozen), holder.address(), bigInt(1)))
+	s.requireTx(v2.Mint(s.signer, holder.address(), bigInt(995)))
+	s.requireTxFails(v2.Mint(s.signer, holder.address(), bigInt(1)))
+}
+
+func (s *ReserveSuite) TestProxyUpgradeIsProtected() {
+	implementation := s.deployReserveImplementation()
+	proxyAddress, proxied := s.deployReserveProxy(implementation)
+	v2Address, tx, _, err := abi.DeployReserveV2(s.signer, s.node)
+	s.requireTx(tx, err)
+
+	// Only the admin can upgrade, and only to an upgradeable Reserve.
+	s.requireTxFails(proxied.UpgradeTo(signer(s.account[2]), v2Address))
+	s.requireTxFails(proxied.UpgradeToAndCall(signer(s.account[2]), v2Address, nil))
+	for _, notImplementation := range []common.Address{proxyAddress, s.eternalStorageAddress, s.account[3].address()} {
+		s.requireTxFails(proxied.UpgradeTo(s.signer, notImplementation))
+	}
+
+	// The implementation, on its own, can't be upgraded, even by its owner.
+	impl, err := abi.NewReserve(implementation, s.node)
+	s.Require().NoError(err)
+	s.requireTxFails(impl.UpgradeTo(s.signer, v2Address))
+
+	// A failing call undoes the upgrade it follows.
+	s.requireTxFails(proxied.UpgradeToAndCall(s.signer, v2Address, s.reserveCalldata("initialize")))
+	found, err := proxied.Implementation(nil)
+	s.Require().NoError(err)
+	s.Equal(implementation, found)
+
+	// A call that succeeds runs as the admin, in the proxy.
+	s.requireTxWithStrictEvents(proxied.UpgradeToAndCall(s.signer, v2Address, s.reserveCalldata("changeMaxSupply", bigInt(5))))(
+		abi.ReserveUpgraded{Implementation: v2Address},
+		abi.ReserveMaxSupplyChanged{NewMaxSupply: bigInt(5)},
+	)
+	found, err = proxied.Implementation(nil)
+	s.Require().NoError(err)
+	s.Equal(v2Address, found)
+	maxSupply, err := proxied.MaxSupply(nil)
+	s.Require().NoError(err)
+	s.Equal("5", maxSupply.String())
+
+	// Upgrading back works too.
+	s.requireTxWithStrictEvents(proxied.UpgradeTo(s.signer, implementation))(
+		abi.ReserveUpgraded{Implementation: implementation},
+	)
+}
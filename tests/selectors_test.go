@@ -0,0 +1,65 @@
+// +build all
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/ops/abicheck"
+)
+
+// selectorContracts are the ABIs audited for colliding selectors and topics: the deployed
+// contracts, the Reserve's upgrade, and the forwarder that the tests relay calls through.
+var selectorContracts = map[string]string{
+	"Basket":                abi.BasketABI,
+	"Manager":               abi.ManagerABI,
+	"SwapProposal":          abi.SwapProposalABI,
+	"WeightProposal":        abi.WeightProposalABI,
+	"RebalanceProposal":     abi.RebalanceProposalABI,
+	"Vault":                 abi.VaultABI,
+	"YieldVault":            abi.YieldVaultABI,
+	"ProposalFactory":       abi.ProposalFactoryABI,
+	"Create2Deployer":       abi.Create2DeployerABI,
+	"Timelock":              abi.TimelockABI,
+	"CollateralOracle":      abi.CollateralOracleABI,
+	"DutchAuction":          abi.DutchAuctionABI,
+	"Upkeep":                abi.UpkeepABI,
+	"CollateralRegistry":    abi.CollateralRegistryABI,
+	"GuardianMultisig":      abi.GuardianMultisigABI,
+	"InsuranceFund":         abi.InsuranceFundABI,
+	"FeeTreasury":           abi.FeeTreasuryABI,
+	"Reserve":               abi.ReserveABI,
+	"ReserveV2":             abi.ReserveV2ABI,
+	"ReserveProxy":          abi.ReserveProxyABI,
+	"ReserveEternalStorage": abi.ReserveEternalStorageABI,
+	"Relayer":               abi.RelayerABI,
+	"BridgeAdapter":         abi.BridgeAdapterABI,
+	"OFTAdapter":            abi.OFTAdapterABI,
+	"RSVVotes":              abi.RSVVotesABI,
+	"BasicForwarder":        abi.BasicForwarderABI,
+}
+
+// TestSelectorCollisions checks that no two functions of the contracts share a selector, and no
+// two events a topic, and that the ReserveProxy shadows none of the Reserve's functions, before or
+// after its upgrade; `rsvabi selectors` runs the same audit on the artifacts.
+func TestSelectorCollisions(t *testing.T) {
+	contracts := make(map[string]abicheck.ABI)
+	for name, raw := range selectorContracts {
+		a, err := abicheck.Parse([]byte(raw))
+		require.NoError(t, err, name)
+		contracts[name] = a
+	}
+	findings := abicheck.Audit(contracts, []abicheck.Proxy{
+		{Proxy: "ReserveProxy", Implementation: "Reserve"},
+		{Proxy: "ReserveProxy", Implementation: "ReserveV2"},
+	})
+	for _, f := range findings {
+		t.Log(f)
+	}
+	assert.Empty(t, findings)
+	assert.NotEmpty(t, abicheck.IDs(contracts))
+}
@@ -0,0 +1,390 @@
+// +build all
+
+package tests
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/ops/timelock"
+)
+
+func TestTimelock(t *testing.T) {
+	suite.Run(t, new(TimelockSuite))
+}
+
+type TimelockSuite struct {
+	TestSuite
+
+	timelock        *abi.Timelock
+	timelockAddress common.Address
+}
+
+var (
+	// Compile-time check that TimelockSuite implements the interfaces we think it does.
+	// If it does not implement these interfaces, then the corresponding setup and teardown
+	// functions will not actually run.
+	_ suite.BeforeTest       = &TimelockSuite{}
+	_ suite.SetupAllSuite    = &TimelockSuite{}
+	_ suite.TearDownAllSuite = &TimelockSuite{}
+)
+
+// timelockDelay is the delay of the Timelocks in the tests.
+const timelockDelay = 2 * 24 * time.Hour
+
+// SetupSuite runs once, before all of the tests in the suite.
+func (s *TimelockSuite) SetupSuite() {
+	s.setup()
+}
+
+// BeforeTest runs before each test in the suite.
+func (s *TimelockSuite) BeforeTest(suiteName, testName string) {
+	s.owner = s.account[0]
+	s.logParsers = map[common.Address]logParser{}
+
+	s.timelockAddress, s.timelock = s.deployTimelock()
+
+	// A Reserve administered by the Timelock.
+	reserveAddress, tx, reserve, err := abi.DeployReserve(s.signer, s.node)
+	s.logParsers[reserveAddress] = reserve
+	s.requireTx(tx, err)
+	s.reserve = reserve
+	s.reserveAddress = reserveAddress
+
+	s.requireTx(s.reserve.NominateNewOwner(s.signer, s.timelockAddress))
+	s.throughTimelock(s.timelock, s.timelockOperation(abi.ReserveABI, s.reserveAddress, "acceptOwnership"))(
+		abi.ReserveOwnershipTransferred{PreviousOwner: s.owner.address(), NewOwner: s.timelockAddress},
+	)
+}
+
+// deployTimelock deploys a Timelock with timelockDelay, administered by s.owner.
+func (s *TestSuite) deployTimelock() (common.Address, *abi.Timelock) {
+	address, tx, tl, err := abi.DeployTimelock(s.signer, s.node, s.owner.address(), seconds(timelockDelay))
+	s.logParsers[address] = tl
+	s.requireTxWithStrictEvents(tx, err)(
+		abi.TimelockNewAdmin{NewAdmin: s.owner.address()},
+		abi.TimelockNewDelay{NewDelay: seconds(timelockDelay)},
+	)
+	return address, tl
+}
+
+// timelockOperation returns the Timelock operation that calls method of the contract at target,
+// which has ABI abiJSON, with args, with an ETA a little over timelockDelay after the next block.
+func (s *TestSuite) timelockOperation(
+	abiJSON string, target common.Address, method string, args ...interface{},
+) *timelock.Operation {
+	parsed, err := ethabi.JSON(strings.NewReader(abiJSON))
+	s.Require().NoError(err)
+	m, ok := parsed.Methods[method]
+	s.Require().True(ok, method)
+	data, err := m.Inputs.Pack(args...)
+	s.Require().NoError(err)
+	return &timelock.Operation{
+		Target:    target,
+		Value:     bigInt(0),
+		Signature: m.Sig(),
+		Data:      data,
+		ETA:       s.currentTimestamp().Uint64() + uint64((timelockDelay+time.Minute)/time.Second),
+	}
+}
+
+func (s *TestSuite) queueOperation(tl *abi.Timelock, from account, op *timelock.Operation) (*types.Transaction, error) {
+	return tl.QueueTransaction(signer(from), op.Target, op.Value, op.Signature, op.Data, eta(op))
+}
+
+func (s *TestSuite) cancelOperation(tl *abi.Timelock, from account, op *timelock.Operation) (*types.Transaction, error) {
+	return tl.CancelTransaction(signer(from), op.Target, op.Value, op.Signature, op.Data, eta(op))
+}
+
+func (s *TestSuite) executeOperation(tl *abi.Timelock, from account, op *timelock.Operation) (*types.Transaction, error) {
+	return tl.ExecuteTransaction(signer(from), op.Target, op.Value, op.Signature, op.Data, eta(op))
+}
+
+// throughTimelock makes op through tl, as its admin: it queues op, waits out timelockDelay, and
+// executes it. Like requireTx, it returns a closure asserting the events of the execution.
+func (s *TestSuite) throughTimelock(tl *abi.Timelock, op *timelock.Operation) func(assertEvent ...fmt.Stringer) {
+	s.requireTx(s.queueOperation(tl, s.owner, op))
+	s.Require().NoError(s.node.(backend).AdjustTime(timelockDelay + time.Hour))
+	return s.requireTx(s.executeOperation(tl, s.owner, op))
+}
+
+func eta(op *timelock.Operation) *big.Int {
+	return new(big.Int).SetUint64(op.ETA)
+}
+
+func seconds(d time.Duration) *big.Int {
+	return big.NewInt(int64(d / time.Second))
+}
+
+func (s *TimelockSuite) assertQueued(op *timelock.Operation, expected bool) {
+	queued, err := s.timelock.QueuedTransactions(nil, op.Hash())
+	s.Require().NoError(err)
+	s.Equal(expected, queued)
+}
+
+func queueEvent(op *timelock.Operation) abi.TimelockQueueTransaction {
+	return abi.TimelockQueueTransaction{
+		TxHash: op.Hash(), Target: op.Target, Value: op.Value, Signature: op.Signature, Data: op.Data, Eta: eta(op),
+	}
+}
+
+func cancelEvent(op *timelock.Operation) abi.TimelockCancelTransaction {
+	return abi.TimelockCancelTransaction{
+		TxHash: op.Hash(), Target: op.Target, Value: op.Value, Signature: op.Signature, Data: op.Data, Eta: eta(op),
+	}
+}
+
+func executeEvent(op *timelock.Operation) abi.TimelockExecuteTransaction {
+	return abi.TimelockExecuteTransaction{
+		TxHash: op.Hash(), Target: op.Target, Value: op.Value, Signature: op.Signature, Data: op.Data, Eta: eta(op),
+	}
+}
+
+// TestConstructor tests that the Timelock starts with its admin and delay.
+func (s *TimelockSuite) TestConstructor() {
+	admin, err := s.timelock.Admin(nil)
+	s.Require().NoError(err)
+	s.Equal(s.owner.address(), admin)
+
+	pendingAdmin, err := s.timelock.PendingAdmin(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), pendingAdmin)
+
+	keeper, err := s.timelock.Keeper(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), keeper)
+
+	delay, err := s.timelock.Delay(nil)
+	s.Require().NoError(err)
+	s.Equal(seconds(timelockDelay).String(), delay.String())
+
+	reserveOwner, err := s.reserve.Owner(nil)
+	s.Require().NoError(err)
+	s.Equal(s.timelockAddress, reserveOwner)
+}
+
+// TestConstructorRequiresDelayInRange tests that a Timelock can't be deployed with a delay
+// outside MINIMUM_DELAY and MAXIMUM_DELAY.
+func (s *TimelockSuite) TestConstructorRequiresDelayInRange() {
+	_, tx, _, err := abi.DeployTimelock(s.signer, s.node, s.owner.address(), seconds(24*time.Hour))
+	s.requireTxFails(tx, err)
+	_, tx, _, err = abi.DeployTimelock(s.signer, s.node, s.owner.address(), seconds(31*24*time.Hour))
+	s.requireTxFails(tx, err)
+}
+
+// TestTimelockedMinterChange tests that a minter change through the Timelock waits out the
+// delay, and that the Timelock identifies it by the hash that rsvadmin computes.
+func (s *TimelockSuite) TestTimelockedMinterChange() {
+	minter := s.account[2].address()
+	op := s.timelockOperation(abi.ReserveABI, s.reserveAddress, "changeMinter", minter)
+
+	// The old owner can't change the minter directly.
+	s.requireTxFails(s.reserve.ChangeMinter(s.signer, minter))
+
+	// Nor can it queue the change with too early an ETA.
+	early := *op
+	early.ETA -= uint64(time.Hour / time.Second)
+	s.requireTxFails(s.queueOperation(s.timelock, s.owner, &early))
+
+	s.requireTxWithStrictEvents(s.queueOperation(s.timelock, s.owner, op))(queueEvent(op))
+	s.assertQueued(op, true)
+
+	// Within the delay, it can't be executed.
+	s.Require().NoError(s.node.(backend).AdjustTime(timelockDelay - time.Hour))
+	s.requireTxFails(s.executeOperation(s.timelock, s.owner, op))
+
+	// After it, only the admin can execute it.
+	s.Require().NoError(s.node.(backend).AdjustTime(2 * time.Hour))
+	s.requireTxFails(s.executeOperation(s.timelock, s.account[1], op))
+	s.requireTxWithStrictEvents(s.executeOperation(s.timelock, s.owner, op))(
+		abi.ReserveMinterChanged{NewMinter: minter},
+		executeEvent(op),
+	)
+	s.assertQueued(op, false)
+
+	got, err := s.reserve.Minter(nil)
+	s.Require().NoError(err)
+	s.Equal(minter, got)
+
+	// It can only be executed once.
+	s.requireTxFails(s.executeOperation(s.timelock, s.owner, op))
+}
+
+// TestExecuteWithoutSignature tests that an operation with no signature sends its data as the
+// whole calldata.
+func (s *TimelockSuite) TestExecuteWithoutSignature() {
+	minter := s.account[2].address()
+	op := s.timelockOperation(abi.ReserveABI, s.reserveAddress, "changeMinter", minter)
+	op.Data = op.Calldata()
+	op.Signature = ""
+
+	s.throughTimelock(s.timelock, op)(
+		abi.ReserveMinterChanged{NewMinter: minter},
+		executeEvent(op),
+	)
+}
+
+// TestTimelockedUpgradeNomination tests that nominating the Reserve's next implementation
+// through the Timelock waits out the delay.
+func (s *TimelockSuite) TestTimelockedUpgradeNomination() {
+	next := s.account[3].address()
+	s.requireTxFails(s.reserve.NominateNewOwner(s.signer, next))
+
+	s.throughTimelock(s.timelock, s.timelockOperation(abi.ReserveABI, s.reserveAddress, "nominateNewOwner", next))(
+		abi.ReserveNewOwnerNominated{PreviousOwner: s.timelockAddress, Nominee: next},
+	)
+	nominee, err := s.reserve.NominatedOwner(nil)
+	s.Require().NoError(err)
+	s.Equal(next, nominee)
+}
+
+// TestCancel tests that a cancelled operation can't be executed.
+func (s *TimelockSuite) TestCancel() {
+	op := s.timelockOperation(abi.ReserveABI, s.reserveAddress, "changeMinter", s.account[2].address())
+	s.requireTx(s.queueOperation(s.timelock, s.owner, op))
+
+	// Only the admin can cancel.
+	s.requireTxFails(s.cancelOperation(s.timelock, s.account[1], op))
+
+	// Within the delay, the admin cancels.
+	s.Require().NoError(s.node.(backend).AdjustTime(timelockDelay / 2))
+	s.requireTxWithStrictEvents(s.cancelOperation(s.timelock, s.owner, op))(cancelEvent(op))
+	s.assertQueued(op, false)
+
+	s.Require().NoError(s.node.(backend).AdjustTime(timelockDelay))
+	s.requireTxFails(s.executeOperation(s.timelock, s.owner, op))
+
+	minter, err := s.reserve.Minter(nil)
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), minter)
+}
+
+// TestExecuteFailsWhenStale tests that an operation not executed within GRACE_PERIOD of its ETA
+// lapses, and can be queued again.
+func (s *TimelockSuite) TestExecuteFailsWhenStale() {
+	op := s.timelockOperation(abi.ReserveABI, s.reserveAddress, "changeMinter", s.account[2].address())
+	s.requireTx(s.queueOperation(s.timelock, s.owner, op))
+
+	s.Require().NoError(s.node.(backend).AdjustTime(timelockDelay + 15*24*time.Hour))
+	s.requireTxFails(s.executeOperation(s.timelock, s.owner, op))
+
+	op = s.timelockOperation(abi.ReserveABI, s.reserveAddress, "changeMinter", s.account[2].address())
+	s.throughTimelock(s.timelock, op)(executeEvent(op))
+}
+
+// TestFailedCallStaysQueued tests that an operation whose call reverts can't be executed, and
+// can be once the call would succeed.
+func (s *TimelockSuite) TestFailedCallStaysQueued() {
+	// The Timelock isn't the pauser, so it can't unpause.
+	op := s.timelockOperation(abi.ReserveABI, s.reserveAddress, "unpause")
+	s.requireTx(s.queueOperation(s.timelock, s.owner, op))
+	s.Require().NoError(s.node.(backend).AdjustTime(timelockDelay + time.Hour))
+	s.requireTxFails(s.executeOperation(s.timelock, s.owner, op))
+	s.assertQueued(op, true)
+
+	s.requireTx(s.reserve.ChangePauser(s.signer, s.timelockAddress))
+	s.requireTxWithStrictEvents(s.executeOperation(s.timelock, s.owner, op))(
+		abi.ReserveUnpaused{Account: s.timelockAddress},
+		executeEvent(op),
+	)
+}
+
+// TestKeeper tests that the keeper, set through the Timelock, can execute operations once they
+// are due, as the admin can, but can't queue or cancel them.
+func (s *TimelockSuite) TestKeeper() {
+	keeper := s.account[4]
+	setKeeper := s.timelockOperation(abi.TimelockABI, s.timelockAddress, "setKeeper", keeper.address())
+	s.requireTxFails(s.timelock.SetKeeper(s.signer, keeper.address()))
+	s.throughTimelock(s.timelock, setKeeper)(
+		abi.TimelockNewKeeper{NewKeeper: keeper.address()},
+		executeEvent(setKeeper),
+	)
+
+	op := s.timelockOperation(abi.ReserveABI, s.reserveAddress, "changeMinter", s.account[2].address())
+	s.requireTxFails(s.queueOperation(s.timelock, keeper, op))
+	s.requireTx(s.queueOperation(s.timelock, s.owner, op))
+	s.requireTxFails(s.cancelOperation(s.timelock, keeper, op))
+
+	// Not before it is due.
+	s.requireTxFails(s.executeOperation(s.timelock, keeper, op))
+	s.Require().NoError(s.node.(backend).AdjustTime(timelockDelay + time.Hour))
+	s.requireTxWithStrictEvents(s.executeOperation(s.timelock, keeper, op))(
+		abi.ReserveMinterChanged{NewMinter: s.account[2].address()},
+		executeEvent(op),
+	)
+
+	// Once unset, the keeper can't execute.
+	unsetKeeper := s.timelockOperation(abi.TimelockABI, s.timelockAddress, "setKeeper", zeroAddress())
+	s.throughTimelock(s.timelock, unsetKeeper)(
+		abi.TimelockNewKeeper{NewKeeper: zeroAddress()},
+		executeEvent(unsetKeeper),
+	)
+	op = s.timelockOperation(abi.ReserveABI, s.reserveAddress, "changeMinter", s.account[3].address())
+	s.requireTx(s.queueOperation(s.timelock, s.owner, op))
+	s.Require().NoError(s.node.(backend).AdjustTime(timelockDelay + time.Hour))
+	s.requireTxFails(s.executeOperation(s.timelock, keeper, op))
+}
+
+// TestQueueIsProtected tests that only the admin can queue operations.
+func (s *TimelockSuite) TestQueueIsProtected() {
+	op := s.timelockOperation(abi.ReserveABI, s.reserveAddress, "changeMinter", s.account[2].address())
+	s.requireTxFails(s.queueOperation(s.timelock, s.account[1], op))
+}
+
+// TestSetDelay tests that the delay can be changed only through the Timelock itself, and then
+// only within range.
+func (s *TimelockSuite) TestSetDelay() {
+	newDelay := 3 * 24 * time.Hour
+	s.requireTxFails(s.timelock.SetDelay(s.signer, seconds(newDelay)))
+
+	s.throughTimelock(s.timelock, s.timelockOperation(abi.TimelockABI, s.timelockAddress, "setDelay", seconds(newDelay)))(
+		abi.TimelockNewDelay{NewDelay: seconds(newDelay)},
+	)
+	delay, err := s.timelock.Delay(nil)
+	s.Require().NoError(err)
+	s.Equal(seconds(newDelay).String(), delay.String())
+
+	// Operations queued from now on wait out the new delay.
+	op := s.timelockOperation(abi.ReserveABI, s.reserveAddress, "changeMinter", s.account[2].address())
+	s.requireTxFails(s.queueOperation(s.timelock, s.owner, op))
+
+	// A delay out of range fails on execution.
+	op = s.timelockOperation(abi.TimelockABI, s.timelockAddress, "setDelay", seconds(time.Hour))
+	op.ETA += uint64(24 * time.Hour / time.Second)
+	s.requireTx(s.queueOperation(s.timelock, s.owner, op))
+	s.Require().NoError(s.node.(backend).AdjustTime(newDelay + time.Hour))
+	s.requireTxFails(s.executeOperation(s.timelock, s.owner, op))
+}
+
+// TestChangeAdmin tests that the admin changes hands through the Timelock and the nominee's
+// acceptance.
+func (s *TimelockSuite) TestChangeAdmin() {
+	newAdmin := s.account[1]
+	s.requireTxFails(s.timelock.SetPendingAdmin(s.signer, newAdmin.address()))
+
+	s.throughTimelock(s.timelock, s.timelockOperation(abi.TimelockABI, s.timelockAddress, "setPendingAdmin", newAdmin.address()))(
+		abi.TimelockNewPendingAdmin{NewPendingAdmin: newAdmin.address()},
+	)
+	s.requireTxFails(s.timelock.AcceptAdmin(signer(s.account[2])))
+	s.requireTxWithStrictEvents(s.timelock.AcceptAdmin(signer(newAdmin)))(
+		abi.TimelockNewAdmin{NewAdmin: newAdmin.address()},
+	)
+
+	admin, err := s.timelock.Admin(nil)
+	s.Require().NoError(err)
+	s.Equal(newAdmin.address(), admin)
+
+	// The old admin can no longer queue.
+	op := s.timelockOperation(abi.ReserveABI, s.reserveAddress, "changeMinter", s.account[2].address())
+	s.requireTxFails(s.queueOperation(s.timelock, s.owner, op))
+	s.requireTx(s.queueOperation(s.timelock, newAdmin, op))(queueEvent(op))
+}
@@ -0,0 +1,444 @@
+// +build all
+
+package tests
+
+import (
+	"fmt"
+	"math/big"
+	"os/exec"
+	"testing"
+	"time"
+
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/ops/timelock"
+	"github.com/reserve-protocol/rsv-beta/soltools"
+)
+
+func TestUpkeep(t *testing.T) {
+	suite.Run(t, new(UpkeepSuite))
+}
+
+type UpkeepSuite struct {
+	TestSuite
+
+	timelock        *abi.Timelock
+	timelockAddress common.Address
+
+	upkeep        *abi.Upkeep
+	upkeepAddress common.Address
+
+	keeper *keeperSimulator
+}
+
+var (
+	// Compile-time check that UpkeepSuite implements the interfaces we think it does.
+	// If it does not implement these interfaces, then the corresponding setup and teardown
+	// functions will not actually run.
+	_ suite.BeforeTest       = &UpkeepSuite{}
+	_ suite.SetupAllSuite    = &UpkeepSuite{}
+	_ suite.TearDownAllSuite = &UpkeepSuite{}
+)
+
+// The tasks of Upkeep.performUpkeep.
+const (
+	taskExecute uint8 = iota
+	taskDrop
+	taskExpire
+)
+
+// SetupSuite runs once, before all of the tests in the suite.
+func (s *UpkeepSuite) SetupSuite() {
+	s.setup()
+}
+
+// TearDownSuite runs once, after all of the tests in the suite.
+func (s *UpkeepSuite) TearDownSuite() {
+	if coverageEnabled {
+		// Write coverage profile to disk.
+		s.Assert().NoError(s.node.(*soltools.Backend).WriteCoverage())
+
+		// Close the node.js process.
+		s.Assert().NoError(s.node.(*soltools.Backend).Close())
+
+		// Process coverage profile into an HTML report.
+		if out, err := exec.Command("npx", "istanbul", "report", "html").CombinedOutput(); err != nil {
+			fmt.Println()
+			fmt.Println("I generated coverage information in coverage/coverage.json.")
+			fmt.Println("I tried to process it with `istanbul` to turn it into a readable report, but failed.")
+			fmt.Println("The error I got when running istanbul was:", err)
+			fmt.Println("Istanbul's output was:\n" + string(out))
+		}
+	}
+}
+
+// BeforeTest runs before each test in the suite. It deploys a Manager, a Timelock administered
+// by s.owner, and an Upkeep for both, which the Timelock makes its keeper.
+func (s *UpkeepSuite) BeforeTest(suiteName, testName string) {
+	s.owner = s.account[0]
+	s.operator = s.account[1]
+	s.proposer = s.account[5]
+	s.deployReserve()
+
+	var tx *types.Transaction
+	var err error
+	s.vaultAddress, tx, s.vault, err = abi.DeployVault(s.signer, s.node)
+	s.logParsers[s.vaultAddress] = s.vault
+	s.requireTx(tx, err)
+
+	s.proposalFactoryAddress, tx, s.proposalFactory, err = abi.DeployProposalFactory(s.signer, s.node)
+	s.logParsers[s.proposalFactoryAddress] = s.proposalFactory
+	s.requireTx(tx, err)
+
+	s.erc20s = make([]*abi.BasicERC20, 3)
+	s.erc20Addresses = make([]common.Address, 3)
+	for i := range s.erc20s {
+		s.erc20Addresses[i], tx, s.erc20s[i], err = abi.DeployBasicERC20(s.signer, s.node)
+		s.logParsers[s.erc20Addresses[i]] = s.erc20s[i]
+		s.requireTx(tx, err)
+	}
+	s.weights = []*big.Int{shiftLeft(1, 35), shiftLeft(3, 35), shiftLeft(6, 35)}
+	s.basketAddress, tx, s.basket, err = abi.DeployBasket(
+		s.signer, s.node, zeroAddress(), s.erc20Addresses, s.weights,
+	)
+	s.logParsers[s.basketAddress] = s.basket
+	s.requireTx(tx, err)
+
+	s.managerAddress, tx, s.manager, err = abi.DeployManager(
+		s.signer, s.node,
+		s.vaultAddress, s.reserveAddress, s.proposalFactoryAddress, s.basketAddress, s.operator.address(), bigInt(0),
+	)
+	s.logParsers[s.managerAddress] = s.manager
+	s.requireTx(tx, err)
+	s.requireTx(s.manager.SetEmergency(signer(s.operator), false))
+	s.requireTx(s.reserve.ChangeMinter(s.signer, s.managerAddress))
+	s.requireTx(s.vault.ChangeManager(s.signer, s.managerAddress))
+
+	s.timelockAddress, s.timelock = s.deployTimelock()
+	s.upkeepAddress, tx, s.upkeep, err = abi.DeployUpkeep(s.signer, s.node, s.timelockAddress, s.managerAddress)
+	s.logParsers[s.upkeepAddress] = s.upkeep
+	s.requireTx(tx, err)
+
+	setKeeper := s.timelockOperation(abi.TimelockABI, s.timelockAddress, "setKeeper", s.upkeepAddress)
+	s.throughTimelock(s.timelock, setKeeper)(abi.TimelockNewKeeper{NewKeeper: s.upkeepAddress})
+
+	s.keeper = &keeperSimulator{s: &s.TestSuite, upkeep: s.upkeep, from: s.account[6]}
+}
+
+// keeperSimulator plays a keeper network for an Upkeep: like the network's nodes, it calls
+// checkUpkeep off chain, and sends what it finds to performUpkeep, from its own account.
+type keeperSimulator struct {
+	s      *TestSuite
+	upkeep *abi.Upkeep
+	from   account
+}
+
+// check returns the performData of the upkeep's next task, or nil if there is none.
+func (k *keeperSimulator) check() []byte {
+	result, err := k.upkeep.CheckUpkeep(nil, []byte{})
+	k.s.Require().NoError(err)
+	if !result.UpkeepNeeded {
+		k.s.Empty(result.PerformData)
+		return nil
+	}
+	return result.PerformData
+}
+
+// perform sends performData to performUpkeep. Like requireTx, it returns a closure asserting the
+// transaction's events.
+func (k *keeperSimulator) perform(performData []byte) func(assertEvent ...fmt.Stringer) {
+	return k.s.requireTx(k.upkeep.PerformUpkeep(signer(k.from), performData))
+}
+
+// run performs the upkeep's tasks until there are none left, and returns how many it performed.
+func (k *keeperSimulator) run() int {
+	for n := 0; ; n++ {
+		performData := k.check()
+		if performData == nil {
+			return n
+		}
+		k.s.Require().True(n < 20, "the upkeep keeps finding tasks")
+		k.perform(performData)
+	}
+}
+
+var performArgs = func() ethabi.Arguments {
+	var args ethabi.Arguments
+	for _, t := range []string{"uint8", "uint256", "bytes32"} {
+		typ, err := ethabi.NewType(t, nil)
+		if err != nil {
+			panic(err)
+		}
+		args = append(args, ethabi.Argument{Type: typ})
+	}
+	return args
+}()
+
+// performData encodes a task of Upkeep.performUpkeep.
+func (s *UpkeepSuite) performData(task uint8, index *big.Int, txHash common.Hash) []byte {
+	data, err := performArgs.Pack(task, index, [32]byte(txHash))
+	s.Require().NoError(err)
+	return data
+}
+
+// checkTask asserts that the upkeep's next task is `task`, of `index` and `txHash`, and returns
+// its performData.
+func (s *UpkeepSuite) checkTask(task uint8, index *big.Int, txHash common.Hash) []byte {
+	performData := s.keeper.check()
+	s.Require().NotNil(performData, "no task")
+	s.Equal(s.performData(task, index, txHash), performData)
+	return performData
+}
+
+// delayOperation returns a Timelock operation that sets the Timelock's own delay to `delay`, to
+// be executed `later` after the earliest ETA.
+func (s *UpkeepSuite) delayOperation(delay, later time.Duration) *timelock.Operation {
+	op := s.timelockOperation(abi.TimelockABI, s.timelockAddress, "setDelay", seconds(delay))
+	op.ETA += uint64(later / time.Second)
+	return op
+}
+
+// schedule queues op on the Timelock and schedules it on the Upkeep.
+func (s *UpkeepSuite) schedule(op *timelock.Operation) {
+	s.requireTx(s.queueOperation(s.timelock, s.owner, op))
+	s.requireTxWithStrictEvents(
+		s.upkeep.Schedule(s.signer, op.Target, op.Value, op.Signature, op.Data, eta(op)),
+	)(
+		abi.UpkeepOperationScheduled{TxHash: op.Hash(), Eta: eta(op)},
+	)
+}
+
+func (s *UpkeepSuite) assertOperationsLength(expected uint32) {
+	length, err := s.upkeep.OperationsLength(nil)
+	s.Require().NoError(err)
+	s.Equal(bigInt(expected).String(), length.String())
+}
+
+func (s *UpkeepSuite) assertTimelockDelay(expected time.Duration) {
+	delay, err := s.timelock.Delay(nil)
+	s.Require().NoError(err)
+	s.Equal(seconds(expected).String(), delay.String())
+}
+
+// TestConstructor tests that the Upkeep starts with its Timelock and Manager, and nothing
+// scheduled, and that the Timelock made it its keeper.
+func (s *UpkeepSuite) TestConstructor() {
+	tl, err := s.upkeep.Timelock(nil)
+	s.Require().NoError(err)
+	s.Equal(s.timelockAddress, tl)
+	manager, err := s.upkeep.Manager(nil)
+	s.Require().NoError(err)
+	s.Equal(s.managerAddress, manager)
+	s.assertOperationsLength(0)
+	s.Nil(s.keeper.check())
+
+	keeper, err := s.timelock.Keeper(nil)
+	s.Require().NoError(err)
+	s.Equal(s.upkeepAddress, keeper)
+
+	_, tx, _, err := abi.DeployUpkeep(s.signer, s.node, zeroAddress(), s.managerAddress)
+	s.requireTxFails(tx, err)
+	_, tx, _, err = abi.DeployUpkeep(s.signer, s.node, s.timelockAddress, zeroAddress())
+	s.requireTxFails(tx, err)
+}
+
+// TestSchedule tests that only the Timelock's admin can schedule operations, and only those it
+// has queued.
+func (s *UpkeepSuite) TestSchedule() {
+	op := s.delayOperation(3*24*time.Hour, 0)
+	schedule := func(from account) (*types.Transaction, error) {
+		return s.upkeep.Schedule(signer(from), op.Target, op.Value, op.Signature, op.Data, eta(op))
+	}
+
+	s.requireTxFails(schedule(s.owner))
+	s.requireTx(s.queueOperation(s.timelock, s.owner, op))
+	s.requireTxFails(schedule(s.account[6]))
+	s.requireTxFails(schedule(s.operator))
+	s.requireTxWithStrictEvents(schedule(s.owner))(
+		abi.UpkeepOperationScheduled{TxHash: op.Hash(), Eta: eta(op)},
+	)
+	s.assertOperationsLength(1)
+
+	scheduled, err := s.upkeep.Operations(nil, bigInt(0))
+	s.Require().NoError(err)
+	s.Equal(op.Target, scheduled.Target)
+	s.Equal(op.Value.String(), scheduled.Value.String())
+	s.Equal(op.Signature, scheduled.Signature)
+	s.Equal(op.Data, scheduled.Data)
+	s.Equal(eta(op).String(), scheduled.Eta.String())
+
+	// Nothing is due yet.
+	s.Nil(s.keeper.check())
+}
+
+// TestExecutesDueOperations tests that the keeper executes each operation once it is due, and
+// only then.
+func (s *UpkeepSuite) TestExecutesDueOperations() {
+	first := s.delayOperation(3*24*time.Hour, 0)
+	second := s.delayOperation(4*24*time.Hour, 24*time.Hour)
+	s.schedule(second)
+	s.schedule(first)
+	s.Equal(0, s.keeper.run())
+
+	s.Require().NoError(s.node.(backend).AdjustTime(timelockDelay + time.Hour))
+	performData := s.checkTask(taskExecute, bigInt(1), first.Hash())
+	s.keeper.perform(performData)(
+		abi.TimelockNewDelay{NewDelay: seconds(3 * 24 * time.Hour)},
+		executeEvent(first),
+	)
+	s.assertTimelockDelay(3 * 24 * time.Hour)
+	s.assertOperationsLength(1)
+	s.Equal(0, s.keeper.run())
+
+	s.Require().NoError(s.node.(backend).AdjustTime(24 * time.Hour))
+	s.Equal(1, s.keeper.run())
+	s.assertTimelockDelay(4 * 24 * time.Hour)
+	s.assertOperationsLength(0)
+
+	// Neither can be executed again.
+	s.requireTxFails(s.executeOperation(s.timelock, s.owner, first))
+	s.requireTxFails(s.executeOperation(s.timelock, s.owner, second))
+}
+
+// TestDropsDeadOperations tests that the keeper drops the operations that can no longer be
+// executed: those cancelled, those executed by the admin, and those gone stale.
+func (s *UpkeepSuite) TestDropsDeadOperations() {
+	cancelled := s.delayOperation(3*24*time.Hour, 0)
+	executed := s.delayOperation(4*24*time.Hour, 0)
+	stale := s.delayOperation(5*24*time.Hour, 0)
+	s.schedule(cancelled)
+	s.schedule(executed)
+	s.schedule(stale)
+
+	s.requireTx(s.cancelOperation(s.timelock, s.owner, cancelled))
+	s.checkTask(taskDrop, bigInt(0), cancelled.Hash())
+
+	// Say the keepers are down from here until the last operation is stale.
+	s.Require().NoError(s.node.(backend).AdjustTime(timelockDelay + time.Hour))
+	s.requireTx(s.executeOperation(s.timelock, s.owner, executed))
+	s.Require().NoError(s.node.(backend).AdjustTime(15 * 24 * time.Hour))
+
+	performData := s.checkTask(taskDrop, bigInt(0), cancelled.Hash())
+	s.keeper.perform(performData)(abi.UpkeepOperationDropped{TxHash: cancelled.Hash()})
+	s.assertOperationsLength(2)
+
+	// The last operation moved into the dropped one's place.
+	s.checkTask(taskDrop, bigInt(0), stale.Hash())
+	s.requireTxFails(s.upkeep.PerformUpkeep(signer(s.account[7]), s.performData(taskDrop, bigInt(2), stale.Hash())))
+	s.requireTxFails(s.upkeep.PerformUpkeep(signer(s.account[7]), s.performData(taskDrop, bigInt(1), stale.Hash())))
+	s.Equal(2, s.keeper.run())
+	s.assertOperationsLength(0)
+
+	// Only the operation that the admin executed took effect.
+	s.assertTimelockDelay(4 * 24 * time.Hour)
+	s.requireTxFails(s.executeOperation(s.timelock, s.owner, stale))
+}
+
+// TestExpiresProposals tests that the keeper expires the proposals still pending after their
+// deadlines, and no others.
+func (s *UpkeepSuite) TestExpiresProposals() {
+	s.requireTx(s.manager.SetProposalValidity(s.signer, seconds(48*time.Hour)))
+	propose := func() *big.Int {
+		id, err := s.manager.ProposalsLength(nil)
+		s.Require().NoError(err)
+		s.requireTx(s.manager.ProposeRebalance(
+			signer(s.proposer), s.erc20Addresses[1], s.erc20Addresses[0], bigInt(3333), shiftLeft(1, 18),
+		))
+		proposalAddress, err := s.manager.TrustedProposals(nil, id)
+		s.Require().NoError(err)
+		proposal, err := abi.NewRebalanceProposal(proposalAddress, s.node)
+		s.Require().NoError(err)
+		s.logParsers[proposalAddress] = proposal
+		return id
+	}
+	state := func(id *big.Int) uint8 {
+		proposalAddress, err := s.manager.TrustedProposals(nil, id)
+		s.Require().NoError(err)
+		proposal, err := abi.NewRebalanceProposal(proposalAddress, s.node)
+		s.Require().NoError(err)
+		state, err := proposal.State(nil)
+		s.Require().NoError(err)
+		return state
+	}
+
+	created := propose()
+	accepted := propose()
+	withdrawn := propose()
+	s.requireTx(s.manager.AcceptProposal(signer(s.operator), accepted))
+	s.requireTx(s.manager.WithdrawProposal(signer(s.proposer), withdrawn, "changed my mind"))
+
+	s.Require().NoError(s.node.(backend).AdjustTime(47 * time.Hour))
+	s.Equal(0, s.keeper.run())
+	s.requireTxFails(s.upkeep.PerformUpkeep(signer(s.account[7]), s.performData(taskExpire, created, common.Hash{})))
+
+	s.Require().NoError(s.node.(backend).AdjustTime(2 * time.Hour))
+	performData := s.checkTask(taskExpire, created, common.Hash{})
+	s.keeper.perform(performData)(
+		abi.ManagerProposalExpired{Id: created, Proposer: s.proposer.address()},
+	)
+	s.Equal(1, s.keeper.run())
+	s.Equal(uint8(2), state(created))
+	s.Equal(uint8(2), state(accepted))
+	s.Equal(uint8(2), state(withdrawn))
+
+	// A proposal made since isn't due to expire.
+	fresh := propose()
+	s.Equal(0, s.keeper.run())
+	s.Equal(uint8(0), state(fresh))
+
+	// Nor, even past its deadline, is one that has been cleared.
+	s.requireTx(s.manager.ClearProposals(signer(s.operator)))
+	s.Require().NoError(s.node.(backend).AdjustTime(49 * time.Hour))
+	s.Equal(0, s.keeper.run())
+	s.Equal(uint8(0), state(fresh))
+}
+
+// TestOperationsBeforeProposals tests that the keeper does due operations before expiring
+// proposals, one task at a time.
+func (s *UpkeepSuite) TestOperationsBeforeProposals() {
+	s.requireTx(s.manager.ProposeRebalance(
+		signer(s.proposer), s.erc20Addresses[1], s.erc20Addresses[0], bigInt(3333), shiftLeft(1, 18),
+	))
+	op := s.delayOperation(3*24*time.Hour, 6*24*time.Hour)
+	s.schedule(op)
+
+	s.Require().NoError(s.node.(backend).AdjustTime(timelockDelay + 7*24*time.Hour))
+	s.checkTask(taskExecute, bigInt(0), op.Hash())
+	s.Equal(2, s.keeper.run())
+	s.assertTimelockDelay(3 * 24 * time.Hour)
+	s.assertOperationsLength(0)
+}
+
+// TestPerformUpkeepChecks tests that performUpkeep, which anyone can call, does only the tasks
+// that are due.
+func (s *UpkeepSuite) TestPerformUpkeepChecks() {
+	op := s.delayOperation(3*24*time.Hour, 0)
+	s.schedule(op)
+	perform := func(task uint8, index *big.Int, txHash common.Hash) (*types.Transaction, error) {
+		return s.upkeep.PerformUpkeep(signer(s.account[7]), s.performData(task, index, txHash))
+	}
+
+	// Before the operation is due, it can't be executed, nor dropped while queued.
+	s.requireTxFails(perform(taskExecute, bigInt(0), op.Hash()))
+	s.requireTxFails(perform(taskDrop, bigInt(0), op.Hash()))
+
+	s.Require().NoError(s.node.(backend).AdjustTime(timelockDelay + time.Hour))
+	s.requireTxFails(perform(taskExecute, bigInt(1), op.Hash()))
+	s.requireTxFails(perform(taskExecute, bigInt(0), common.Hash{}))
+	s.requireTxFails(perform(3, bigInt(0), op.Hash()))
+
+	// There is no proposal to expire.
+	s.requireTxFails(perform(taskExpire, bigInt(0), common.Hash{}))
+
+	// Anyone can perform a due task.
+	s.requireTxWithStrictEvents(perform(taskExecute, bigInt(0), op.Hash()))(
+		abi.TimelockNewDelay{NewDelay: seconds(3 * 24 * time.Hour)},
+		executeEvent(op),
+	)
+	s.assertOperationsLength(0)
+	s.requireTxFails(perform(taskExecute, bigInt(0), op.Hash()))
+}
@@ -112,6 +112,13 @@ func (s *VaultSuite) TestConstructor() {
 	s.Equal(s.owner.address(), managerAddress)
 }
 
+func (s *VaultSuite) TestSupportsInterface() {
+	s.assertInterfaces(abi.VaultABI, s.vault.SupportsInterface, map[string][]string{
+		"ERC-165": erc165,
+		"Vault":   {"withdrawTo(address,uint256,address)"},
+	})
+}
+
 // TestChangeManager unit tests the changeManager function.
 func (s *VaultSuite) TestChangeManager() {
 	// Change the Manager address.
@@ -0,0 +1,373 @@
+// +build all
+
+package tests
+
+import (
+	"fmt"
+	"math/big"
+	"os/exec"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/reserve-protocol/rsv-beta/abi"
+	"github.com/reserve-protocol/rsv-beta/soltools"
+)
+
+func TestRSVVotes(t *testing.T) {
+	suite.Run(t, new(RSVVotesSuite))
+}
+
+type RSVVotesSuite struct {
+	TestSuite
+
+	votes        *abi.RSVVotes
+	votesAddress common.Address
+}
+
+var (
+	// Compile-time check that RSVVotesSuite implements the interfaces we think it does.
+	// If it does not implement these interfaces, then the corresponding setup and teardown
+	// functions will not actually run.
+	_ suite.BeforeTest       = &RSVVotesSuite{}
+	_ suite.SetupAllSuite    = &RSVVotesSuite{}
+	_ suite.TearDownAllSuite = &RSVVotesSuite{}
+)
+
+// SetupSuite runs once, before all of the tests in the suite.
+func (s *RSVVotesSuite) SetupSuite() {
+	s.setup()
+}
+
+// TearDownSuite runs once, after all of the tests in the suite.
+func (s *RSVVotesSuite) TearDownSuite() {
+	if coverageEnabled {
+		// Write coverage profile to disk.
+		s.Assert().NoError(s.node.(*soltools.Backend).WriteCoverage())
+
+		// Close the node.js process.
+		s.Assert().NoError(s.node.(*soltools.Backend).Close())
+
+		// Process coverage profile into an HTML report.
+		if out, err := exec.Command("npx", "istanbul", "report", "html").CombinedOutput(); err != nil {
+			fmt.Println()
+			fmt.Println("I generated coverage information in coverage/coverage.json.")
+			fmt.Println("I tried to process it with `istanbul` to turn it into a readable report, but failed.")
+			fmt.Println("The error I got when running istanbul was:", err)
+			fmt.Println("Istanbul's output was:\n" + string(out))
+		}
+	}
+}
+
+// BeforeTest runs before each test in the suite.
+func (s *RSVVotesSuite) BeforeTest(suiteName, testName string) {
+	s.owner = s.account[0]
+	s.deployReserve()
+	s.requireTx(s.reserve.ChangeMinter(s.signer, s.owner.address()))
+
+	var tx *types.Transaction
+	var err error
+	s.votesAddress, tx, s.votes, err = abi.DeployRSVVotes(s.signer, s.node, s.reserveAddress)
+	s.logParsers[s.votesAddress] = s.votes
+	s.requireTx(tx, err)
+
+	// Each of the first few accounts holds 1000 RSV, and has approved the vote token for it.
+	for _, holder := range s.account[1:4] {
+		s.requireTx(s.reserve.Mint(s.signer, holder.address(), bigInt(1000)))
+		s.requireTx(s.reserve.Approve(signer(holder), s.votesAddress, bigInt(1000)))
+	}
+}
+
+// blockOf requires that a transaction succeeds, and returns the number of its block.
+func (s *RSVVotesSuite) blockOf(tx *types.Transaction, err error) uint64 {
+	return s._requireTxStatus(tx, err, types.ReceiptStatusSuccessful).BlockNumber.Uint64()
+}
+
+func (s *RSVVotesSuite) assertVotes(account common.Address, votes *big.Int) {
+	got, err := s.votes.GetVotes(nil, account)
+	s.Require().NoError(err)
+	s.Equal(votes.String(), got.String(), "votes of %v", account.Hex())
+}
+
+func (s *RSVVotesSuite) assertVRSVBalance(account common.Address, amount *big.Int) {
+	balance, err := s.votes.BalanceOf(nil, account)
+	s.Require().NoError(err)
+	s.Equal(amount.String(), balance.String(), "vRSV of %v", account.Hex())
+}
+
+func (s *RSVVotesSuite) TestDeploy() {}
+
+// TestConstructor tests that the constructor sets initial state appropriately.
+func (s *RSVVotesSuite) TestConstructor() {
+	underlying, err := s.votes.Underlying(nil)
+	s.Require().NoError(err)
+	s.Equal(s.reserveAddress, underlying)
+
+	name, err := s.votes.Name(nil)
+	s.Require().NoError(err)
+	s.Equal("Reserve Votes", name)
+	symbol, err := s.votes.Symbol(nil)
+	s.Require().NoError(err)
+	s.Equal("vRSV", symbol)
+	decimals, err := s.votes.Decimals(nil)
+	s.Require().NoError(err)
+	s.EqualValues(18, decimals)
+
+	supply, err := s.votes.TotalSupply(nil)
+	s.Require().NoError(err)
+	s.Equal("0", supply.String())
+}
+
+func (s *RSVVotesSuite) TestDepositAndWithdraw() {
+	holder, other := s.account[1], s.account[2]
+
+	s.requireTxWithStrictEvents(s.votes.DepositFor(signer(holder), other.address(), bigInt(300)))(
+		abi.ReserveTransfer{From: holder.address(), To: s.votesAddress, Value: bigInt(300)},
+		abi.ReserveApproval{Owner: holder.address(), Spender: s.votesAddress, Value: bigInt(700)},
+		abi.RSVVotesTransfer{From: zeroAddress(), To: other.address(), Value: bigInt(300)},
+	)
+	s.assertRSVBalance(holder.address(), bigInt(700))
+	s.assertRSVBalance(s.votesAddress, bigInt(300))
+	s.assertVRSVBalance(other.address(), bigInt(300))
+
+	// Only what the sender holds, and has approved.
+	s.requireTxFails(s.votes.DepositFor(signer(holder), holder.address(), bigInt(701)))
+	s.requireTxFails(s.votes.WithdrawTo(signer(holder), holder.address(), bigInt(1)))
+
+	s.requireTxWithStrictEvents(s.votes.WithdrawTo(signer(other), holder.address(), bigInt(100)))(
+		abi.RSVVotesTransfer{From: other.address(), To: zeroAddress(), Value: bigInt(100)},
+		abi.ReserveTransfer{From: s.votesAddress, To: holder.address(), Value: bigInt(100)},
+	)
+	s.assertRSVBalance(holder.address(), bigInt(800))
+	s.assertRSVBalance(s.votesAddress, bigInt(200))
+	s.assertVRSVBalance(other.address(), bigInt(200))
+	s.requireTxFails(s.votes.WithdrawTo(signer(other), other.address(), bigInt(201)))
+}
+
+// TestDepositWithFee tests that a deposit is credited with what arrives, after RSV's fee.
+func (s *RSVVotesSuite) TestDepositWithFee() {
+	holder := s.account[1]
+	feeAddress, tx, _, err := abi.DeployBasicTxFee(s.signer, s.node, bigInt(10))
+	s.requireTx(tx, err)
+	s.requireTx(s.reserve.ChangeTxFeeHelper(s.signer, feeAddress))
+
+	s.requireTx(s.votes.DepositFor(signer(holder), holder.address(), bigInt(100)))
+	s.assertVRSVBalance(holder.address(), bigInt(90))
+	s.assertRSVBalance(s.votesAddress, bigInt(90))
+}
+
+func (s *RSVVotesSuite) TestDelegate() {
+	holder, delegate := s.account[1], s.account[2]
+	s.requireTx(s.votes.DepositFor(signer(holder), holder.address(), bigInt(500)))
+
+	// Undelegated vRSV counts for no one.
+	s.assertVotes(holder.address(), bigInt(0))
+	current, err := s.votes.Delegates(nil, holder.address())
+	s.Require().NoError(err)
+	s.Equal(zeroAddress(), current)
+
+	s.requireTxWithStrictEvents(s.votes.Delegate(signer(holder), holder.address()))(
+		abi.RSVVotesDelegateChanged{
+			Delegator: holder.address(), FromDelegate: zeroAddress(), ToDelegate: holder.address(),
+		},
+		abi.RSVVotesDelegateVotesChanged{
+			Delegate: holder.address(), PreviousBalance: bigInt(0), NewBalance: bigInt(500),
+		},
+	)
+	s.assertVotes(holder.address(), bigInt(500))
+
+	s.requireTxWithStrictEvents(s.votes.Delegate(signer(holder), delegate.address()))(
+		abi.RSVVotesDelegateChanged{
+			Delegator: holder.address(), FromDelegate: holder.address(), ToDelegate: delegate.address(),
+		},
+		abi.RSVVotesDelegateVotesChanged{
+			Delegate: holder.address(), PreviousBalance: bigInt(500), NewBalance: bigInt(0),
+		},
+		abi.RSVVotesDelegateVotesChanged{
+			Delegate: delegate.address(), PreviousBalance: bigInt(0), NewBalance: bigInt(500),
+		},
+	)
+	s.assertVotes(holder.address(), bigInt(0))
+	s.assertVotes(delegate.address(), bigInt(500))
+
+	// Votes follow later deposits and withdrawals to the delegate.
+	s.requireTxWithStrictEvents(s.votes.DepositFor(signer(holder), holder.address(), bigInt(100)))(
+		abi.ReserveTransfer{From: holder.address(), To: s.votesAddress, Value: bigInt(100)},
+		abi.ReserveApproval{Owner: holder.address(), Spender: s.votesAddress, Value: bigInt(400)},
+		abi.RSVVotesTransfer{From: zeroAddress(), To: holder.address(), Value: bigInt(100)},
+		abi.RSVVotesDelegateVotesChanged{
+			Delegate: delegate.address(), PreviousBalance: bigInt(500), NewBalance: bigInt(600),
+		},
+	)
+	s.requireTx(s.votes.WithdrawTo(signer(holder), holder.address(), bigInt(250)))
+	s.assertVotes(delegate.address(), bigInt(350))
+
+	// Delegating to no one takes the votes away.
+	s.requireTx(s.votes.Delegate(signer(holder), zeroAddress()))
+	s.assertVotes(delegate.address(), bigInt(0))
+}
+
+// TestTransferMovesVotes tests that a transfer moves votes between the delegates of its sender
+// and recipient, checkpointing each.
+func (s *RSVVotesSuite) TestTransferMovesVotes() {
+	alice, bob, carol := s.account[1], s.account[2], s.account[3]
+	for _, holder := range []account{alice, bob, carol} {
+		s.requireTx(s.votes.DepositFor(signer(holder), holder.address(), bigInt(1000)))
+	}
+	s.requireTx(s.votes.Delegate(signer(alice), alice.address()))
+	s.requireTx(s.votes.Delegate(signer(bob), alice.address()))
+
+	// Between two accounts with the same delegate, nothing moves.
+	s.requireTxWithStrictEvents(s.votes.Transfer(signer(bob), alice.address(), bigInt(100)))(
+		abi.RSVVotesTransfer{From: bob.address(), To: alice.address(), Value: bigInt(100)},
+	)
+	s.assertVotes(alice.address(), bigInt(2000))
+
+	// To an undelegated account, the votes go.
+	s.requireTxWithStrictEvents(s.votes.Transfer(signer(alice), carol.address(), bigInt(300)))(
+		abi.RSVVotesTransfer{From: alice.address(), To: carol.address(), Value: bigInt(300)},
+		abi.RSVVotesDelegateVotesChanged{
+			Delegate: alice.address(), PreviousBalance: bigInt(2000), NewBalance: bigInt(1700),
+		},
+	)
+
+	// From one delegate's to another's, they move.
+	s.requireTx(s.votes.Delegate(signer(carol), bob.address()))
+	s.assertVotes(bob.address(), bigInt(1300))
+	s.requireTxWithStrictEvents(s.votes.Transfer(signer(carol), bob.address(), bigInt(200)))(
+		abi.RSVVotesTransfer{From: carol.address(), To: bob.address(), Value: bigInt(200)},
+		abi.RSVVotesDelegateVotesChanged{
+			Delegate: bob.address(), PreviousBalance: bigInt(1300), NewBalance: bigInt(1100),
+		},
+		abi.RSVVotesDelegateVotesChanged{
+			Delegate: alice.address(), PreviousBalance: bigInt(1700), NewBalance: bigInt(1900),
+		},
+	)
+	s.assertVotes(alice.address(), bigInt(1900))
+	s.assertVotes(bob.address(), bigInt(1100))
+
+	// Each change of alice's votes made a checkpoint: delegations, then the two transfers.
+	count, err := s.votes.NumCheckpoints(nil, alice.address())
+	s.Require().NoError(err)
+	s.EqualValues(4, count)
+	for i, want := range []int64{1000, 2000, 1700, 1900} {
+		checkpoint, err := s.votes.Checkpoints(nil, alice.address(), uint32(i))
+		s.Require().NoError(err)
+		s.Equal(fmt.Sprint(want), checkpoint.Votes.String(), "checkpoint %v", i)
+	}
+}
+
+func (s *RSVVotesSuite) TestFrozenAccounts() {
+	holder, frozen := s.account[1], s.account[2]
+	s.requireTx(s.votes.DepositFor(signer(holder), holder.address(), bigInt(500)))
+	s.requireTx(s.votes.DepositFor(signer(frozen), frozen.address(), bigInt(500)))
+	s.requireTx(s.reserve.ChangeFreezer(s.signer, s.owner.address()))
+	s.requireTx(s.reserve.Freeze(s.signer, frozen.address()))
+
+	s.requireTxFails(s.votes.Transfer(signer(frozen), holder.address(), bigInt(1)))
+	s.requireTxFails(s.votes.Transfer(signer(holder), frozen.address(), bigInt(1)))
+	s.requireTxFails(s.votes.WithdrawTo(signer(holder), frozen.address(), bigInt(1)))
+
+	s.requireTx(s.reserve.Unfreeze(s.signer, frozen.address()))
+	s.requireTx(s.votes.Transfer(signer(frozen), holder.address(), bigInt(1)))
+}
+
+// TestGetPastVotes runs deposits, delegations, transfers, and withdrawals over many blocks,
+// some of them empty, and checks getPastVotes and getPastTotalSupply at every block against a
+// model of the same history.
+func (s *RSVVotesSuite) TestGetPastVotes() {
+	alice, bob, carol := s.account[1], s.account[2], s.account[3]
+	delegates := []account{alice, bob, carol}
+
+	// The model: the votes of each delegate and the total supply, from each block on.
+	type state struct {
+		block  uint64
+		votes  map[common.Address]int64
+		supply int64
+	}
+	var history []state
+	votes := map[common.Address]int64{}
+	var supply int64
+	record := func(block uint64) {
+		copied := map[common.Address]int64{}
+		for address, v := range votes {
+			copied[address] = v
+		}
+		history = append(history, state{block, copied, supply})
+	}
+
+	start := s.blockOf(s.votes.DepositFor(signer(alice), alice.address(), bigInt(600)))
+	supply += 600
+	record(start)
+	s.mineBlocks(3)
+
+	block := s.blockOf(s.votes.Delegate(signer(alice), alice.address()))
+	votes[alice.address()] += 600
+	record(block)
+	s.mineBlocks(2)
+
+	block = s.blockOf(s.votes.DepositFor(signer(bob), bob.address(), bigInt(400)))
+	supply += 400
+	record(block)
+	block = s.blockOf(s.votes.Delegate(signer(bob), carol.address()))
+	votes[carol.address()] += 400
+	record(block)
+	s.mineBlocks(5)
+
+	block = s.blockOf(s.votes.Transfer(signer(alice), bob.address(), bigInt(250)))
+	votes[alice.address()] -= 250
+	votes[carol.address()] += 250
+	record(block)
+	s.mineBlocks(1)
+
+	block = s.blockOf(s.votes.Delegate(signer(alice), bob.address()))
+	votes[bob.address()] += votes[alice.address()]
+	votes[alice.address()] = 0
+	record(block)
+
+	block = s.blockOf(s.votes.WithdrawTo(signer(bob), bob.address(), bigInt(150)))
+	votes[carol.address()] -= 150
+	supply -= 150
+	record(block)
+	s.mineBlocks(4)
+
+	block = s.blockOf(s.votes.DepositFor(signer(carol), carol.address(), bigInt(1000)))
+	supply += 1000
+	record(block)
+	end := s.blockOf(s.votes.Delegate(signer(carol), alice.address()))
+	votes[alice.address()] += 1000
+	record(end)
+
+	// The latest block isn't mined as far as a view is concerned, so mine one past it.
+	_, err := s.votes.GetPastVotes(nil, alice.address(), new(big.Int).SetUint64(end))
+	s.Error(err)
+	s.mineBlocks(1)
+
+	before := new(big.Int).SetUint64(start - 1)
+	for _, delegate := range delegates {
+		got, err := s.votes.GetPastVotes(nil, delegate.address(), before)
+		s.Require().NoError(err)
+		s.Equal("0", got.String())
+	}
+	i := 0
+	for b := start; b <= end; b++ {
+		for i+1 < len(history) && history[i+1].block <= b {
+			i++
+		}
+		at := new(big.Int).SetUint64(b)
+		for _, delegate := range delegates {
+			got, err := s.votes.GetPastVotes(nil, delegate.address(), at)
+			s.Require().NoError(err)
+			s.Equal(fmt.Sprint(history[i].votes[delegate.address()]), got.String(),
+				"votes of %v at block %v", delegate.address().Hex(), b)
+		}
+		got, err := s.votes.GetPastTotalSupply(nil, at)
+		s.Require().NoError(err)
+		s.Equal(fmt.Sprint(history[i].supply), got.String(), "total supply at block %v", b)
+	}
+	for _, delegate := range delegates {
+		s.assertVotes(delegate.address(), big.NewInt(votes[delegate.address()]))
+	}
+}